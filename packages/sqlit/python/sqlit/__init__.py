@@ -0,0 +1,113 @@
+"""DB-API 2.0 driver of SQLit.
+
+The driver speaks the binary protocol of proto.Server, or posts the queries to the HTTP API of
+the SQL adapter as a fallback:
+
+    import sqlit
+
+    conn = sqlit.connect("db-id", host="127.0.0.1", port=4662)
+    cur = conn.cursor()
+    cur.execute("SELECT name FROM users WHERE id = ?", (1,))
+    print(cur.fetchone())
+
+Every statement is committed on its own by the server, so commit() and rollback() have nothing
+to do. The SQLAlchemy dialect is registered as sqlit://host:port/database.
+"""
+
+import datetime
+import time
+
+from .connection import Connection, Cursor, connect
+from .exceptions import (
+    DatabaseError,
+    DataError,
+    Error,
+    IntegrityError,
+    InterfaceError,
+    InternalError,
+    NotSupportedError,
+    OperationalError,
+    ProgrammingError,
+    Warning,  # noqa: A004
+)
+
+__all__ = [
+    "apilevel",
+    "threadsafety",
+    "paramstyle",
+    "connect",
+    "Connection",
+    "Cursor",
+    "Warning",
+    "Error",
+    "InterfaceError",
+    "DatabaseError",
+    "DataError",
+    "OperationalError",
+    "IntegrityError",
+    "InternalError",
+    "ProgrammingError",
+    "NotSupportedError",
+    "Date",
+    "Time",
+    "Timestamp",
+    "DateFromTicks",
+    "TimeFromTicks",
+    "TimestampFromTicks",
+    "Binary",
+    "STRING",
+    "BINARY",
+    "NUMBER",
+    "DATETIME",
+    "ROWID",
+]
+
+__version__ = "0.1.0"
+
+apilevel = "2.0"
+# the connections may be shared by the threads, the cursors may not
+threadsafety = 1
+paramstyle = "qmark"
+
+# SQLite version bundled by the driver of the miners, the SQLAlchemy dialect picks the SQL
+# features by it
+sqlite_version = "3.46.1"
+sqlite_version_info = (3, 46, 1)
+
+Date = datetime.date
+Time = datetime.time
+Timestamp = datetime.datetime
+Binary = bytes
+
+
+def DateFromTicks(ticks: float) -> datetime.date:  # noqa: N802
+    return Date(*time.localtime(ticks)[:3])
+
+
+def TimeFromTicks(ticks: float) -> datetime.time:  # noqa: N802
+    return Time(*time.localtime(ticks)[3:6])
+
+
+def TimestampFromTicks(ticks: float) -> datetime.datetime:  # noqa: N802
+    return Timestamp(*time.localtime(ticks)[:6])
+
+
+class _TypeObject:
+    """Type object comparing equal to the type codes of its group."""
+
+    def __init__(self, *codes: str) -> None:
+        self.codes = frozenset(codes)
+
+    def __eq__(self, other: object) -> bool:
+        return other in self.codes
+
+    def __hash__(self) -> int:
+        return hash(self.codes)
+
+
+# the type codes of Cursor.description are the SQLite storage classes
+STRING = _TypeObject("TEXT")
+BINARY = _TypeObject("BLOB")
+NUMBER = _TypeObject("INTEGER", "REAL")
+DATETIME = _TypeObject()
+ROWID = _TypeObject("INTEGER")
@@ -0,0 +1,271 @@
+"""DB-API 2.0 connections and cursors."""
+
+from __future__ import annotations
+
+import datetime
+import decimal
+import re
+from collections.abc import Iterator, Mapping, Sequence
+from typing import Any
+
+from .exceptions import InterfaceError, NotSupportedError, OperationalError, ProgrammingError
+from .protocol import BinaryTransport, HTTPTransport, Result
+
+DEFAULT_PORT = 4662
+
+# the statements returning rows, the others are sent as exec requests
+_QUERY_KEYWORDS = frozenset(
+    {"select", "with", "pragma", "explain", "values", "show", "desc", "describe"}
+)
+_LEADING = re.compile(r"^(?:\s+|--[^\n]*(?:\n|$)|/\*.*?\*/)*", re.S)
+_KEYWORD = re.compile(r"[(\s]*([A-Za-z]+)")
+
+
+def connect(
+    database: str,
+    host: str = "127.0.0.1",
+    port: int = DEFAULT_PORT,
+    timeout: float | None = 30.0,
+    http_endpoint: str | None = None,
+    transport: str = "auto",
+) -> Connection:
+    """Connect to a SQLit database.
+
+    transport is "binary" for the binary protocol at host:port, "http" for the SQL adapter at
+    http_endpoint, or "auto" for the binary protocol falling back to http_endpoint if the binary
+    protocol is not reachable.
+    """
+    if not database:
+        raise InterfaceError("database is required")
+    if transport == "http":
+        if not http_endpoint:
+            raise InterfaceError("http_endpoint is required by the http transport")
+        return Connection(database, HTTPTransport(http_endpoint, timeout))
+    if transport not in ("auto", "binary"):
+        raise InterfaceError(f"unknown transport {transport!r}")
+    try:
+        return Connection(database, BinaryTransport(host, port, timeout))
+    except OperationalError:
+        if transport == "auto" and http_endpoint:
+            return Connection(database, HTTPTransport(http_endpoint, timeout))
+        raise
+
+
+def is_query(operation: str) -> bool:
+    """Report whether the statement returns rows, by its first keyword."""
+    match = _KEYWORD.match(_LEADING.sub("", operation, count=1))
+    return match is not None and match.group(1).lower() in _QUERY_KEYWORDS
+
+
+def convert_param(value: Any) -> Any:
+    """Convert a parameter to a value of the protocol."""
+    if value is None or isinstance(value, (bool, int, float, str, bytes)):
+        return value
+    if isinstance(value, (bytearray, memoryview)):
+        return bytes(value)
+    if isinstance(value, (datetime.date, datetime.time)):
+        # date and datetime are stored as the ISO 8601 text, like the sqlite3 adapters
+        return value.isoformat(" ") if isinstance(value, datetime.datetime) else value.isoformat()
+    if isinstance(value, decimal.Decimal):
+        return str(value)
+    raise ProgrammingError(f"unsupported parameter type {type(value).__name__}")
+
+
+def _params(parameters: Sequence[Any] | None) -> list[Any]:
+    if parameters is None:
+        return []
+    if isinstance(parameters, Mapping):
+        raise ProgrammingError("named parameters are not supported, use the qmark style")
+    if isinstance(parameters, (str, bytes)):
+        raise ProgrammingError("parameters must be a sequence")
+    return [convert_param(p) for p in parameters]
+
+
+def _type_code(rows: list[tuple[Any, ...]], index: int) -> str | None:
+    """Infer the storage class of a column by its first non-null value."""
+    for row in rows:
+        value = row[index]
+        if value is None:
+            continue
+        if isinstance(value, (bool, int)):
+            return "INTEGER"
+        if isinstance(value, float):
+            return "REAL"
+        if isinstance(value, bytes):
+            return "BLOB"
+        return "TEXT"
+    return None
+
+
+class Connection:
+    """Connection to a SQLit database.
+
+    Every statement is committed by the server on its own, so the connection is always in the
+    autocommit mode.
+    """
+
+    def __init__(self, database: str, transport: BinaryTransport | HTTPTransport) -> None:
+        self.database = database
+        self._transport: BinaryTransport | HTTPTransport | None = transport
+
+    @property
+    def transport(self) -> BinaryTransport | HTTPTransport:
+        if self._transport is None:
+            raise InterfaceError("connection is closed")
+        return self._transport
+
+    @property
+    def closed(self) -> bool:
+        return self._transport is None
+
+    @property
+    def autocommit(self) -> bool:
+        return True
+
+    @autocommit.setter
+    def autocommit(self, value: bool) -> None:
+        if not value:
+            raise NotSupportedError("transactions are not supported, every statement autocommits")
+
+    @property
+    def in_transaction(self) -> bool:
+        return False
+
+    def close(self) -> None:
+        if self._transport is not None:
+            self._transport.close()
+            self._transport = None
+
+    def commit(self) -> None:
+        """Do nothing but check the connection, the statements are committed already."""
+        _ = self.transport
+
+    def rollback(self) -> None:
+        """Do nothing but check the connection, the statements are committed already."""
+        _ = self.transport
+
+    def ping(self) -> None:
+        self.transport.ping()
+
+    def cursor(self) -> Cursor:
+        return Cursor(self)
+
+    def execute(self, operation: str, parameters: Sequence[Any] | None = None) -> Cursor:
+        """Create a cursor and execute the statement, like sqlite3."""
+        return self.cursor().execute(operation, parameters)
+
+    def __enter__(self) -> Connection:
+        return self
+
+    def __exit__(self, *exc: object) -> None:
+        self.close()
+
+
+class Cursor:
+    """Cursor of a connection, the rows are fetched all at once by execute."""
+
+    def __init__(self, connection: Connection) -> None:
+        self.connection: Connection | None = connection
+        self.arraysize = 1
+        self.description: tuple[tuple[Any, ...], ...] | None = None
+        self.rowcount = -1
+        self.lastrowid: int | None = None
+        self._rows: list[tuple[Any, ...]] = []
+        self._pos = 0
+
+    def _conn(self) -> Connection:
+        if self.connection is None:
+            raise InterfaceError("cursor is closed")
+        return self.connection
+
+    def close(self) -> None:
+        self.connection = None
+        self._rows = []
+
+    def execute(self, operation: str, parameters: Sequence[Any] | None = None) -> Cursor:
+        conn = self._conn()
+        params = _params(parameters)
+        transport = conn.transport
+        self._reset()
+        if is_query(operation):
+            self._set_result(transport.query(conn.database, operation, params))
+        else:
+            result = transport.execute(conn.database, operation, params)
+            self.rowcount = result.rows_affected
+            self.lastrowid = result.last_insert_id
+        return self
+
+    def executemany(self, operation: str, seq_of_parameters: Sequence[Sequence[Any]]) -> Cursor:
+        conn = self._conn()
+        if is_query(operation):
+            raise ProgrammingError("executemany() can't execute the queries")
+        transport = conn.transport
+        self._reset()
+        total = 0
+        for parameters in seq_of_parameters:
+            result = transport.execute(conn.database, operation, _params(parameters))
+            total += max(result.rows_affected, 0)
+            self.lastrowid = result.last_insert_id
+        self.rowcount = total
+        return self
+
+    def _reset(self) -> None:
+        self.description = None
+        self.rowcount = -1
+        self.lastrowid = None
+        self._rows = []
+        self._pos = 0
+
+    def _set_result(self, result: Result) -> None:
+        self._rows = result.rows
+        self.description = tuple(
+            (name, _type_code(result.rows, i), None, None, None, None, None)
+            for i, name in enumerate(result.columns)
+        )
+        self.rowcount = len(result.rows)
+
+    def _check_result(self) -> None:
+        self._conn()
+        if self.description is None:
+            raise ProgrammingError("no result set, execute a query first")
+
+    def fetchone(self) -> tuple[Any, ...] | None:
+        self._check_result()
+        if self._pos >= len(self._rows):
+            return None
+        self._pos += 1
+        return self._rows[self._pos - 1]
+
+    def fetchmany(self, size: int | None = None) -> list[tuple[Any, ...]]:
+        self._check_result()
+        size = self.arraysize if size is None else size
+        rows = self._rows[self._pos : self._pos + size]
+        self._pos += len(rows)
+        return rows
+
+    def fetchall(self) -> list[tuple[Any, ...]]:
+        self._check_result()
+        rows = self._rows[self._pos :]
+        self._pos = len(self._rows)
+        return rows
+
+    def setinputsizes(self, sizes: Any) -> None:
+        pass
+
+    def setoutputsize(self, size: Any, column: Any = None) -> None:
+        pass
+
+    def __iter__(self) -> Iterator[tuple[Any, ...]]:
+        return self
+
+    def __next__(self) -> tuple[Any, ...]:
+        row = self.fetchone()
+        if row is None:
+            raise StopIteration
+        return row
+
+    def __enter__(self) -> Cursor:
+        return self
+
+    def __exit__(self, *exc: object) -> None:
+        self.close()
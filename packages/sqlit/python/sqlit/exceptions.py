@@ -0,0 +1,56 @@
+"""DB-API 2.0 exceptions."""
+
+
+class Warning(Exception):  # noqa: A001 - the name is required by DB-API 2.0
+    """Important warnings like data truncations."""
+
+
+class Error(Exception):
+    """Base class of the other error exceptions."""
+
+
+class InterfaceError(Error):
+    """Errors of the driver rather than the database, like a closed cursor."""
+
+
+class DatabaseError(Error):
+    """Errors of the database."""
+
+
+class DataError(DatabaseError):
+    """Errors of the processed data, like an out of range value."""
+
+
+class OperationalError(DatabaseError):
+    """Errors of the database operation, like a lost connection or a missing table."""
+
+
+class IntegrityError(DatabaseError):
+    """Errors of the relational integrity, like a failed constraint."""
+
+
+class InternalError(DatabaseError):
+    """Internal errors of the database."""
+
+
+class ProgrammingError(DatabaseError):
+    """Programming errors, like a syntax error or a wrong number of parameters."""
+
+
+class NotSupportedError(DatabaseError):
+    """Methods or features not supported by the database."""
+
+
+def error_from_message(message: str) -> DatabaseError:
+    """Map a server error message to the exception class of the sqlite3 module."""
+    lower = message.lower()
+    if "constraint failed" in lower:
+        return IntegrityError(message)
+    if "not supported" in lower or "unknown request type" in lower:
+        return NotSupportedError(message)
+    if "arguments, got" in lower:
+        # database/sql refuses the wrong number of parameters
+        return ProgrammingError(message)
+    if any(s in lower for s in ("syntax error", "no such", "already exists", "database not found")):
+        return OperationalError(message)
+    return DatabaseError(message)
@@ -0,0 +1,291 @@
+"""Transports of the SQLit queries.
+
+BinaryTransport speaks the binary protocol of proto.Server (src/proto/binary.go), all the integers
+are little-endian:
+
+    header   magic "SQLT" u32, version u8, type u8, flags u16, request id u32
+    request  header, body length u32, database string, sql string, binding count u16, values
+    string   length u32, utf-8 bytes
+    value    type u8, then length u32 and data unless the type is null
+
+The responses start with a header of the request id, followed by:
+
+    error    string
+    exec     success u8, last insert id i64, rows affected i64
+    query    success u8, column count u8, column strings, row count u32, rows of values
+    pong     nothing
+
+HTTPTransport posts the queries to the /v1/query and /v1/exec endpoints of the SQL adapter.
+"""
+
+from __future__ import annotations
+
+import itertools
+import json
+import math
+import socket
+import struct
+import threading
+import urllib.error
+import urllib.request
+from dataclasses import dataclass, field
+from typing import Any
+
+from .exceptions import (
+    DataError,
+    InterfaceError,
+    NotSupportedError,
+    OperationalError,
+    error_from_message,
+)
+
+MAGIC = 0x544C5153
+PROTOCOL_VERSION = 1
+HEADER = struct.Struct("<IBBHI")
+
+TYPE_QUERY = 1
+TYPE_EXEC = 2
+TYPE_PING = 6
+TYPE_RESULT = 128
+TYPE_ERROR = 129
+TYPE_PONG = 134
+
+VALUE_NULL = 0
+VALUE_INT64 = 1
+VALUE_FLOAT64 = 2
+VALUE_STRING = 3
+VALUE_BLOB = 4
+VALUE_BOOL = 5
+
+MAX_MESSAGE_SIZE = 16 * 1024 * 1024
+MAX_BINDINGS = 0xFFFF
+
+INT64_MIN = -(2**63)
+INT64_MAX = 2**63 - 1
+
+
+@dataclass
+class Result:
+    """Result of a query or an exec."""
+
+    columns: list[str] = field(default_factory=list)
+    rows: list[tuple[Any, ...]] = field(default_factory=list)
+    last_insert_id: int = 0
+    rows_affected: int = -1
+
+
+def encode_value(value: Any) -> bytes:
+    """Encode a parameter as a typed value, the values are converted by the caller."""
+    if value is None:
+        return bytes([VALUE_NULL])
+    if isinstance(value, bool):
+        return _typed(VALUE_BOOL, b"\x01" if value else b"\x00")
+    if isinstance(value, int):
+        if not INT64_MIN <= value <= INT64_MAX:
+            raise DataError(f"integer {value} out of the int64 range")
+        return _typed(VALUE_INT64, struct.pack("<q", value))
+    if isinstance(value, float):
+        return _typed(VALUE_FLOAT64, struct.pack("<d", value))
+    if isinstance(value, str):
+        return _typed(VALUE_STRING, value.encode("utf-8"))
+    if isinstance(value, (bytes, bytearray, memoryview)):
+        return _typed(VALUE_BLOB, bytes(value))
+    raise InterfaceError(f"unsupported parameter type {type(value).__name__}")
+
+
+def _typed(value_type: int, data: bytes) -> bytes:
+    return struct.pack("<BI", value_type, len(data)) + data
+
+
+def _string(s: str) -> bytes:
+    data = s.encode("utf-8")
+    return struct.pack("<I", len(data)) + data
+
+
+def encode_request(
+    msg_type: int, request_id: int, database: str, sql: str, params: list[Any]
+) -> bytes:
+    """Encode a request message."""
+    if len(params) > MAX_BINDINGS:
+        raise InterfaceError(f"too many parameters: {len(params)}")
+    body = b"".join(
+        [_string(database), _string(sql), struct.pack("<H", len(params))]
+        + [encode_value(p) for p in params]
+    )
+    if len(body) > MAX_MESSAGE_SIZE:
+        raise DataError("request exceeds the maximum message size")
+    header = HEADER.pack(MAGIC, PROTOCOL_VERSION, msg_type, 0, request_id)
+    return header + struct.pack("<I", len(body)) + body
+
+
+class _Reader:
+    """Reads the fields of a response from a socket."""
+
+    def __init__(self, sock: socket.socket) -> None:
+        self.sock = sock
+
+    def read(self, n: int) -> bytes:
+        if n > MAX_MESSAGE_SIZE:
+            raise InterfaceError("response field exceeds the maximum message size")
+        chunks = []
+        while n > 0:
+            chunk = self.sock.recv(min(n, 65536))
+            if not chunk:
+                raise OperationalError("connection closed by the server")
+            chunks.append(chunk)
+            n -= len(chunk)
+        return b"".join(chunks)
+
+    def unpack(self, fmt: str) -> tuple[Any, ...]:
+        return struct.unpack(fmt, self.read(struct.calcsize(fmt)))
+
+    def string(self) -> str:
+        (length,) = self.unpack("<I")
+        return self.read(length).decode("utf-8", errors="replace")
+
+    def value(self) -> Any:
+        (value_type,) = self.unpack("<B")
+        if value_type == VALUE_NULL:
+            return None
+        (length,) = self.unpack("<I")
+        data = self.read(length)
+        if value_type == VALUE_INT64 and length == 8:
+            return struct.unpack("<q", data)[0]
+        if value_type == VALUE_FLOAT64 and length == 8:
+            return struct.unpack("<d", data)[0]
+        if value_type == VALUE_STRING:
+            return data.decode("utf-8", errors="replace")
+        if value_type == VALUE_BLOB:
+            return data
+        if value_type == VALUE_BOOL and length == 1:
+            return data != b"\x00"
+        raise InterfaceError(f"invalid value type {value_type} of length {length}")
+
+
+class BinaryTransport:
+    """Runs the queries over a TCP connection to proto.Server."""
+
+    def __init__(self, host: str, port: int, timeout: float | None) -> None:
+        try:
+            self.sock = socket.create_connection((host, port), timeout=timeout)
+        except OSError as e:
+            raise OperationalError(f"connect to {host}:{port} failed: {e}") from e
+        self.sock.setsockopt(socket.IPPROTO_TCP, socket.TCP_NODELAY, 1)
+        self.reader = _Reader(self.sock)
+        self.ids = itertools.count(1)
+        self.lock = threading.Lock()
+        self.broken = False
+
+    def close(self) -> None:
+        self.sock.close()
+
+    def ping(self) -> None:
+        self._roundtrip(TYPE_PING, "", "", [])
+
+    def query(self, database: str, sql: str, params: list[Any]) -> Result:
+        return self._roundtrip(TYPE_QUERY, database, sql, params)
+
+    def execute(self, database: str, sql: str, params: list[Any]) -> Result:
+        return self._roundtrip(TYPE_EXEC, database, sql, params)
+
+    def _roundtrip(self, msg_type: int, database: str, sql: str, params: list[Any]) -> Result:
+        message = encode_request(
+            msg_type, next(self.ids) & 0xFFFFFFFF, database, sql, params
+        )
+        with self.lock:
+            if self.broken:
+                raise OperationalError("connection is broken by a previous failure")
+            try:
+                self.sock.sendall(message)
+                return self._read_response(msg_type, HEADER.unpack_from(message)[4])
+            except (OSError, InterfaceError) as e:
+                # the stream is out of sync after a partial message
+                self.broken = True
+                if isinstance(e, InterfaceError):
+                    raise
+                raise OperationalError(f"connection failed: {e}") from e
+
+    def _read_response(self, msg_type: int, request_id: int) -> Result:
+        magic, _, resp_type, _, resp_id = self.reader.unpack(HEADER.format)
+        if magic != MAGIC:
+            raise InterfaceError("invalid magic number of the response")
+        if resp_id != request_id:
+            raise InterfaceError(f"response of request {resp_id}, expecting {request_id}")
+        if resp_type == TYPE_ERROR:
+            raise error_from_message(self.reader.string())
+        if msg_type == TYPE_PING:
+            if resp_type != TYPE_PONG:
+                raise InterfaceError(f"unexpected response type {resp_type} of ping")
+            return Result()
+        if resp_type != TYPE_RESULT:
+            raise InterfaceError(f"unexpected response type {resp_type}")
+        (success,) = self.reader.unpack("<B")
+        if success != 1:
+            raise InterfaceError("unexpected failed result")
+        if msg_type == TYPE_EXEC:
+            last_insert_id, rows_affected = self.reader.unpack("<qq")
+            return Result(last_insert_id=last_insert_id, rows_affected=rows_affected)
+        (column_count,) = self.reader.unpack("<B")
+        columns = [self.reader.string() for _ in range(column_count)]
+        (row_count,) = self.reader.unpack("<I")
+        rows = [
+            tuple(self.reader.value() for _ in range(column_count)) for _ in range(row_count)
+        ]
+        return Result(columns=columns, rows=rows)
+
+
+class HTTPTransport:
+    """Runs the queries over the HTTP API of the SQL adapter."""
+
+    def __init__(self, endpoint: str, timeout: float | None) -> None:
+        self.endpoint = endpoint.rstrip("/")
+        self.timeout = timeout
+
+    def close(self) -> None:
+        pass
+
+    def ping(self) -> None:
+        self._post("/v1/status", None)
+
+    def query(self, database: str, sql: str, params: list[Any]) -> Result:
+        data = self._post("/v1/query", self._payload(database, sql, params)) or {}
+        columns = list(data.get("columns") or [])
+        rows = [tuple(row) for row in data.get("rows") or []]
+        return Result(columns=columns, rows=rows)
+
+    def execute(self, database: str, sql: str, params: list[Any]) -> Result:
+        data = self._post("/v1/exec", self._payload(database, sql, params)) or {}
+        return Result(
+            last_insert_id=int(data.get("last_insert_id") or 0),
+            rows_affected=int(data.get("affected_rows") or 0),
+        )
+
+    @staticmethod
+    def _payload(database: str, sql: str, params: list[Any]) -> dict[str, Any]:
+        for p in params:
+            if isinstance(p, (bytes, bytearray, memoryview)):
+                raise NotSupportedError("blob parameters require the binary protocol")
+            if isinstance(p, float) and not math.isfinite(p):
+                raise DataError(f"float {p} is not representable in JSON")
+        return {"database": database, "query": sql, "args": params}
+
+    def _post(self, path: str, payload: dict[str, Any] | None) -> Any:
+        request = urllib.request.Request(
+            self.endpoint + path,
+            data=None if payload is None else json.dumps(payload).encode("utf-8"),
+            headers={"Content-Type": "application/json"},
+            method="GET" if payload is None else "POST",
+        )
+        try:
+            with urllib.request.urlopen(request, timeout=self.timeout) as response:
+                body = json.loads(response.read() or b"{}")
+        except urllib.error.HTTPError as e:
+            try:
+                body = json.loads(e.read() or b"{}")
+            except ValueError:
+                raise OperationalError(f"request {path} failed: {e}") from e
+        except (OSError, ValueError) as e:
+            raise OperationalError(f"request {path} failed: {e}") from e
+        if not body.get("success"):
+            raise error_from_message(str(body.get("status") or "request failed"))
+        return body.get("data")
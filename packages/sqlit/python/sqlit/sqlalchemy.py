@@ -0,0 +1,70 @@
+"""SQLAlchemy dialect of SQLit, registered as sqlit://host:port/database.
+
+The dialect renders the SQL of SQLite and runs it through the DB-API driver of this package:
+
+    engine = create_engine("sqlit://127.0.0.1:4662/db-id")
+    engine = create_engine("sqlit:///db-id?transport=http&http_endpoint=http://127.0.0.1:11105")
+
+Every statement is committed by the server on its own, so the transactions of the engine have
+no effect and the isolation level is always AUTOCOMMIT.
+"""
+
+from __future__ import annotations
+
+from typing import Any
+
+from sqlalchemy.dialects.sqlite.base import SQLiteDialect
+from sqlalchemy.engine import URL
+
+from .connection import DEFAULT_PORT
+
+
+class SQLitDialect(SQLiteDialect):
+    name = "sqlit"
+    driver = "sqlit"
+    supports_statement_cache = True
+    default_paramstyle = "qmark"
+
+    @classmethod
+    def import_dbapi(cls) -> Any:
+        import sqlit
+
+        return sqlit
+
+    def create_connect_args(self, url: URL) -> tuple[list[Any], dict[str, Any]]:
+        if not url.database:
+            raise ValueError("database is required: sqlit://host:port/database")
+        kwargs: dict[str, Any] = {
+            "database": url.database,
+            "host": url.host or "127.0.0.1",
+            "port": url.port or DEFAULT_PORT,
+        }
+        query = dict(url.query)
+        for key in ("transport", "http_endpoint"):
+            if key in query:
+                kwargs[key] = query.pop(key)
+        if "timeout" in query:
+            kwargs["timeout"] = float(query.pop("timeout"))
+        if query:
+            raise ValueError(f"unknown parameters: {', '.join(sorted(query))}")
+        return [], kwargs
+
+    def get_isolation_level(self, dbapi_connection: Any) -> str:
+        return "AUTOCOMMIT"
+
+    def get_default_isolation_level(self, dbapi_conn: Any) -> str:
+        return "AUTOCOMMIT"
+
+    def set_isolation_level(self, dbapi_connection: Any, level: str) -> None:
+        if level != "AUTOCOMMIT":
+            raise NotImplementedError("SQLit supports the AUTOCOMMIT isolation level only")
+
+    def get_isolation_level_values(self, dbapi_conn: Any) -> list[str]:
+        return ["AUTOCOMMIT"]
+
+    def do_ping(self, dbapi_connection: Any) -> bool:
+        dbapi_connection.ping()
+        return True
+
+
+dialect = SQLitDialect
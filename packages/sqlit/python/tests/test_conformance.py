@@ -0,0 +1,186 @@
+"""DB-API 2.0 conformance tests against a live proto.Server.
+
+The tests run if SQLIT_BINARY_ADDR (host:port) and SQLIT_DATABASE are set, which the Go test
+TestDBAPIConformance of src/proto does with a server over an in-memory database.
+"""
+
+import os
+import unittest
+import uuid
+
+import sqlit
+
+ADDR = os.environ.get("SQLIT_BINARY_ADDR", "")
+DATABASE = os.environ.get("SQLIT_DATABASE", "")
+
+
+def _connect():
+    host, _, port = ADDR.rpartition(":")
+    return sqlit.connect(DATABASE, host=host, port=int(port), transport="binary", timeout=10)
+
+
+@unittest.skipUnless(ADDR and DATABASE, "SQLIT_BINARY_ADDR and SQLIT_DATABASE are not set")
+class TestConformance(unittest.TestCase):
+    def setUp(self):
+        self.conn = _connect()
+        self.addCleanup(self.conn.close)
+        self.table = "t_" + uuid.uuid4().hex[:12]
+        self.conn.execute(
+            f"CREATE TABLE {self.table} (id INTEGER PRIMARY KEY, name TEXT UNIQUE, "
+            "score REAL, data BLOB, flag INTEGER)"
+        )
+        self.addCleanup(self.conn.execute, f"DROP TABLE IF EXISTS {self.table}")
+
+    def test_module(self):
+        self.assertEqual(sqlit.apilevel, "2.0")
+        self.assertIn(sqlit.threadsafety, (0, 1, 2, 3))
+        self.assertEqual(sqlit.paramstyle, "qmark")
+        for name in ("Warning", "InterfaceError", "DataError", "OperationalError",
+                     "IntegrityError", "InternalError", "ProgrammingError", "NotSupportedError"):
+            self.assertTrue(issubclass(getattr(sqlit, name), Exception))
+        self.assertTrue(issubclass(sqlit.ProgrammingError, sqlit.DatabaseError))
+        self.assertTrue(issubclass(sqlit.DatabaseError, sqlit.Error))
+
+    def test_ping(self):
+        self.conn.ping()
+
+    def test_roundtrip(self):
+        cur = self.conn.cursor()
+        cur.execute(
+            f"INSERT INTO {self.table} (name, score, data, flag) VALUES (?, ?, ?, ?)",
+            ("alice", 1.5, b"\x00\xff", True),
+        )
+        self.assertEqual(cur.rowcount, 1)
+        self.assertEqual(cur.lastrowid, 1)
+        cur.execute(f"INSERT INTO {self.table} (name) VALUES (?)", ("bob",))
+        self.assertEqual(cur.lastrowid, 2)
+
+        cur.execute(f"SELECT id, name, score, data, flag FROM {self.table} ORDER BY id")
+        self.assertEqual(
+            [d[0] for d in cur.description], ["id", "name", "score", "data", "flag"]
+        )
+        self.assertTrue(all(len(d) == 7 for d in cur.description))
+        self.assertEqual(cur.rowcount, 2)
+        self.assertEqual(cur.fetchone(), (1, "alice", 1.5, b"\x00\xff", 1))
+        self.assertEqual(cur.fetchone(), (2, "bob", None, None, None))
+        self.assertIsNone(cur.fetchone())
+
+    def test_fetch(self):
+        cur = self.conn.cursor()
+        cur.executemany(
+            f"INSERT INTO {self.table} (name) VALUES (?)", [(f"n{i}",) for i in range(6)]
+        )
+        self.assertEqual(cur.rowcount, 6)
+
+        cur.execute(f"SELECT name FROM {self.table} ORDER BY id")
+        self.assertEqual(cur.fetchmany(), [("n0",)])
+        cur.arraysize = 2
+        self.assertEqual(cur.fetchmany(), [("n1",), ("n2",)])
+        self.assertEqual(cur.fetchmany(10), [("n3",), ("n4",), ("n5",)])
+        self.assertEqual(cur.fetchmany(), [])
+        self.assertEqual(cur.fetchall(), [])
+
+        cur.execute(f"SELECT name FROM {self.table} WHERE name IN (?, ?) ORDER BY id",
+                    ["n1", "n4"])
+        self.assertEqual([r[0] for r in cur], ["n1", "n4"])
+
+        cur.execute(f"SELECT name FROM {self.table} WHERE 0")
+        self.assertEqual(cur.fetchall(), [])
+        self.assertEqual(cur.rowcount, 0)
+        self.assertEqual(len(cur.description), 1)
+
+    def test_update(self):
+        cur = self.conn.cursor()
+        cur.executemany(f"INSERT INTO {self.table} (name, score) VALUES (?, ?)",
+                        [("a", 1), ("b", 2), ("c", 3)])
+        cur.execute(f"UPDATE {self.table} SET score = score * 2 WHERE score > ?", (1,))
+        self.assertEqual(cur.rowcount, 2)
+        self.assertIsNone(cur.description)
+        cur.execute(f"DELETE FROM {self.table}")
+        self.assertEqual(cur.rowcount, 3)
+
+    def test_values(self):
+        cur = self.conn.cursor()
+        cur.execute("SELECT ?, ?, ?, ?, ?", (-(2**63), 2**63 - 1, "é✓", b"", None))
+        self.assertEqual(cur.fetchone(), (-(2**63), 2**63 - 1, "é✓", None, None))
+        with self.assertRaises(sqlit.DataError):
+            cur.execute("SELECT ?", (2**64,))
+
+    def test_errors(self):
+        cur = self.conn.cursor()
+        cur.execute(f"INSERT INTO {self.table} (name) VALUES (?)", ("dup",))
+        with self.assertRaises(sqlit.IntegrityError):
+            cur.execute(f"INSERT INTO {self.table} (name) VALUES (?)", ("dup",))
+        with self.assertRaises(sqlit.OperationalError):
+            cur.execute("SELEC 1")
+        with self.assertRaises(sqlit.OperationalError):
+            cur.execute("SELECT * FROM no_such_table")
+        with self.assertRaises(sqlit.ProgrammingError):
+            cur.execute("SELECT ?", {"a": 1})
+        with self.assertRaises(sqlit.ProgrammingError):
+            cur.fetchall()
+        # the connection stays usable after the errors
+        cur.execute("SELECT 1")
+        self.assertEqual(cur.fetchall(), [(1,)])
+
+    def test_unknown_database(self):
+        conn = sqlit.connect("unknown", host=ADDR.rpartition(":")[0],
+                             port=int(ADDR.rpartition(":")[2]), transport="binary")
+        self.addCleanup(conn.close)
+        with self.assertRaises(sqlit.OperationalError):
+            conn.execute("SELECT 1")
+
+    def test_transactions(self):
+        self.assertTrue(self.conn.autocommit)
+        self.conn.commit()
+        self.conn.rollback()
+        with self.assertRaises(sqlit.NotSupportedError):
+            self.conn.autocommit = False
+
+    def test_close(self):
+        conn = _connect()
+        cur = conn.cursor()
+        conn.close()
+        conn.close()
+        with self.assertRaises(sqlit.Error):
+            cur.execute("SELECT 1")
+        with self.assertRaises(sqlit.Error):
+            conn.commit()
+
+    def test_pandas(self):
+        try:
+            import pandas
+        except ImportError:
+            self.skipTest("pandas is not installed")
+        self.conn.cursor().executemany(
+            f"INSERT INTO {self.table} (name, score) VALUES (?, ?)", [("a", 1.0), ("b", 2.0)]
+        )
+        frame = pandas.read_sql_query(
+            f"SELECT name, score FROM {self.table} ORDER BY id", self.conn
+        )
+        self.assertEqual(list(frame["name"]), ["a", "b"])
+        self.assertEqual(frame["score"].sum(), 3.0)
+
+    def test_sqlalchemy(self):
+        try:
+            import sqlalchemy
+        except ImportError:
+            self.skipTest("sqlalchemy is not installed")
+        from sqlalchemy.dialects import registry
+
+        registry.register("sqlit", "sqlit.sqlalchemy", "SQLitDialect")
+        engine = sqlalchemy.create_engine(f"sqlit://{ADDR}/{DATABASE}")
+        self.addCleanup(engine.dispose)
+        with engine.connect() as conn:
+            conn.execute(
+                sqlalchemy.text(f"INSERT INTO {self.table} (name) VALUES (:name)"),
+                [{"name": "a"}, {"name": "b"}],
+            )
+            names = conn.execute(
+                sqlalchemy.text(f"SELECT name FROM {self.table} ORDER BY id")
+            ).scalars().all()
+        self.assertEqual(names, ["a", "b"])
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,263 @@
+"""Tests of the encoding and the transports against the stub servers."""
+
+import datetime
+import decimal
+import json
+import socket
+import struct
+import threading
+import unittest
+from http.server import BaseHTTPRequestHandler, HTTPServer
+
+import sqlit
+from sqlit import protocol
+from sqlit.connection import convert_param, is_query
+
+
+def _string(s: str) -> bytes:
+    data = s.encode()
+    return struct.pack("<I", len(data)) + data
+
+
+def _header(msg_type: int, request_id: int) -> bytes:
+    return protocol.HEADER.pack(protocol.MAGIC, 1, msg_type, 0, request_id)
+
+
+class StubServer:
+    """Serves the canned responses of the binary protocol, and records the requests."""
+
+    def __init__(self, responses):
+        self.responses = list(responses)
+        self.requests = []
+        self.listener = socket.create_server(("127.0.0.1", 0))
+        self.port = self.listener.getsockname()[1]
+        self.thread = threading.Thread(target=self.serve, daemon=True)
+        self.thread.start()
+
+    def serve(self):
+        try:
+            conn, _ = self.listener.accept()
+        except OSError:
+            # closed by the test before the connection is accepted
+            return
+        with conn:
+            for response in self.responses:
+                header = self.recv(conn, protocol.HEADER.size)
+                if not header:
+                    return
+                _, _, msg_type, _, request_id = protocol.HEADER.unpack(header)
+                (length,) = struct.unpack("<I", self.recv(conn, 4))
+                self.requests.append((msg_type, self.recv(conn, length)))
+                conn.sendall(response(request_id))
+
+    @staticmethod
+    def recv(conn, n):
+        data = b""
+        while len(data) < n:
+            chunk = conn.recv(n - len(data))
+            if not chunk:
+                return data
+            data += chunk
+        return data
+
+    def close(self):
+        self.listener.close()
+
+
+class TestEncoding(unittest.TestCase):
+    def test_encode_request(self):
+        message = protocol.encode_request(protocol.TYPE_QUERY, 7, "db", "SELECT ?", [1, None])
+        self.assertEqual(message[:12], _header(protocol.TYPE_QUERY, 7))
+        body = (
+            _string("db")
+            + _string("SELECT ?")
+            + struct.pack("<H", 2)
+            + struct.pack("<BIq", protocol.VALUE_INT64, 8, 1)
+            + bytes([protocol.VALUE_NULL])
+        )
+        self.assertEqual(message[12:], struct.pack("<I", len(body)) + body)
+
+    def test_encode_values(self):
+        self.assertEqual(protocol.encode_value(True), b"\x05\x01\x00\x00\x00\x01")
+        self.assertEqual(protocol.encode_value(1.5), struct.pack("<BId", 2, 8, 1.5))
+        self.assertEqual(protocol.encode_value("é"), b"\x03\x02\x00\x00\x00\xc3\xa9")
+        self.assertEqual(protocol.encode_value(b"\x00"), b"\x04\x01\x00\x00\x00\x00")
+        with self.assertRaises(sqlit.DataError):
+            protocol.encode_value(2**63)
+
+    def test_convert_params(self):
+        self.assertEqual(convert_param(datetime.date(2024, 1, 2)), "2024-01-02")
+        self.assertEqual(
+            convert_param(datetime.datetime(2024, 1, 2, 3, 4, 5)), "2024-01-02 03:04:05"
+        )
+        self.assertEqual(convert_param(decimal.Decimal("1.10")), "1.10")
+        self.assertEqual(convert_param(bytearray(b"ab")), b"ab")
+        with self.assertRaises(sqlit.ProgrammingError):
+            convert_param(object())
+
+    def test_is_query(self):
+        for sql in ("SELECT 1", "  with t as (select 1) select * from t", "-- c\nPRAGMA x",
+                    "/* c */ values (1)", "(select 1)"):
+            self.assertTrue(is_query(sql), sql)
+        for sql in ("INSERT INTO t VALUES (1)", "create table t (a)", "", "-- select"):
+            self.assertFalse(is_query(sql), sql)
+
+    def test_errors(self):
+        cases = {
+            "UNIQUE constraint failed: t.id": sqlit.IntegrityError,
+            'near "SELEC": syntax error': sqlit.OperationalError,
+            "no such table: t": sqlit.OperationalError,
+            "unknown request type: 3": sqlit.NotSupportedError,
+            "sql: expected 1 arguments, got 0": sqlit.ProgrammingError,
+            "disk I/O error": sqlit.DatabaseError,
+        }
+        for message, cls in cases.items():
+            self.assertIs(type(sqlit.exceptions.error_from_message(message)), cls, message)
+
+
+class TestBinaryTransport(unittest.TestCase):
+    def test_roundtrip(self):
+        def rows(request_id):
+            return (
+                _header(protocol.TYPE_RESULT, request_id)
+                + b"\x01\x02"
+                + _string("id")
+                + _string("name")
+                + struct.pack("<I", 2)
+                + struct.pack("<BIq", protocol.VALUE_INT64, 8, 1)
+                + struct.pack("<BI", protocol.VALUE_STRING, 1)
+                + b"a"
+                + struct.pack("<BIq", protocol.VALUE_INT64, 8, 2)
+                + bytes([protocol.VALUE_NULL])
+            )
+
+        def exec_result(request_id):
+            return _header(protocol.TYPE_RESULT, request_id) + b"\x01" + struct.pack("<qq", 9, 3)
+
+        def error(request_id):
+            return _header(protocol.TYPE_ERROR, request_id) + _string("no such table: x")
+
+        def pong(request_id):
+            return _header(protocol.TYPE_PONG, request_id)
+
+        server = StubServer([pong, rows, exec_result, error])
+        self.addCleanup(server.close)
+        conn = sqlit.connect("db", port=server.port, transport="binary", timeout=5)
+        self.addCleanup(conn.close)
+
+        conn.ping()
+        cur = conn.execute("SELECT id, name FROM t WHERE id > ?", (0,))
+        self.assertEqual([d[0] for d in cur.description], ["id", "name"])
+        self.assertEqual(cur.description[0][1], sqlit.NUMBER)
+        self.assertEqual(cur.description[1][1], sqlit.STRING)
+        self.assertEqual(cur.fetchone(), (1, "a"))
+        self.assertEqual(cur.fetchall(), [(2, None)])
+        self.assertIsNone(cur.fetchone())
+
+        cur.execute("UPDATE t SET name = ?", ("b",))
+        self.assertEqual((cur.rowcount, cur.lastrowid), (3, 9))
+        with self.assertRaises(sqlit.ProgrammingError):
+            cur.fetchone()
+
+        with self.assertRaises(sqlit.OperationalError):
+            cur.execute("SELECT * FROM x")
+        server.thread.join(5)
+        self.assertEqual([t for t, _ in server.requests], [6, 1, 2, 1])
+
+    def test_mismatched_response(self):
+        server = StubServer([lambda request_id: _header(protocol.TYPE_PONG, request_id + 1)])
+        self.addCleanup(server.close)
+        conn = sqlit.connect("db", port=server.port, transport="binary", timeout=5)
+        self.addCleanup(conn.close)
+        with self.assertRaises(sqlit.InterfaceError):
+            conn.ping()
+        with self.assertRaises(sqlit.OperationalError):
+            conn.ping()
+
+    def test_closed(self):
+        server = StubServer([])
+        self.addCleanup(server.close)
+        conn = sqlit.connect("db", port=server.port, transport="binary", timeout=5)
+        cur = conn.cursor()
+        conn.close()
+        with self.assertRaises(sqlit.InterfaceError):
+            cur.execute("SELECT 1")
+        with self.assertRaises(sqlit.InterfaceError):
+            conn.cursor().execute("SELECT 1")
+        with self.assertRaises(sqlit.InterfaceError):
+            conn.commit()
+
+
+class AdapterHandler(BaseHTTPRequestHandler):
+    """Stub of the query API of the SQL adapter."""
+
+    requests = []
+
+    def do_POST(self):  # noqa: N802
+        payload = json.loads(self.rfile.read(int(self.headers["Content-Length"])))
+        self.requests.append((self.path, payload))
+        if payload["query"].startswith("FAIL"):
+            self.reply(500, {"status": "UNIQUE constraint failed: t.id", "success": False})
+        elif self.path == "/v1/query":
+            data = {"types": ["INTEGER"], "columns": ["n"], "rows": [[1], [2]]}
+            self.reply(200, {"status": "ok", "success": True, "data": data})
+        else:
+            data = {"last_insert_id": 5, "affected_rows": 1}
+            self.reply(200, {"status": "ok", "success": True, "data": data})
+
+    def reply(self, code, body):
+        data = json.dumps(body).encode()
+        self.send_response(code)
+        self.send_header("Content-Type", "application/json")
+        self.send_header("Content-Length", str(len(data)))
+        self.end_headers()
+        self.wfile.write(data)
+
+    def log_message(self, *args):
+        pass
+
+
+class TestHTTPTransport(unittest.TestCase):
+    def setUp(self):
+        AdapterHandler.requests = []
+        self.server = HTTPServer(("127.0.0.1", 0), AdapterHandler)
+        threading.Thread(target=self.server.serve_forever, daemon=True).start()
+        self.addCleanup(self.server.server_close)
+        self.addCleanup(self.server.shutdown)
+        self.endpoint = f"http://127.0.0.1:{self.server.server_port}"
+
+    def test_fallback(self):
+        # nothing listens on the binary port, so auto falls back to the http endpoint
+        closed = socket.create_server(("127.0.0.1", 0))
+        port = closed.getsockname()[1]
+        closed.close()
+        conn = sqlit.connect("db", port=port, http_endpoint=self.endpoint, timeout=5)
+        self.addCleanup(conn.close)
+
+        cur = conn.cursor()
+        cur.execute("SELECT n FROM t WHERE d = ?", (datetime.date(2024, 1, 2),))
+        self.assertEqual(cur.fetchmany(5), [(1,), (2,)])
+        cur.executemany("INSERT INTO t VALUES (?)", [(1,), (2,)])
+        self.assertEqual((cur.rowcount, cur.lastrowid), (2, 5))
+        with self.assertRaises(sqlit.IntegrityError):
+            cur.execute("FAIL")
+        with self.assertRaises(sqlit.NotSupportedError):
+            cur.execute("INSERT INTO t VALUES (?)", (b"blob",))
+
+        self.assertEqual(
+            AdapterHandler.requests[0],
+            ("/v1/query", {"database": "db", "query": "SELECT n FROM t WHERE d = ?",
+                           "args": ["2024-01-02"]}),
+        )
+        self.assertEqual([p for p, _ in AdapterHandler.requests[1:3]], ["/v1/exec"] * 2)
+
+    def test_binary_required(self):
+        closed = socket.create_server(("127.0.0.1", 0))
+        port = closed.getsockname()[1]
+        closed.close()
+        with self.assertRaises(sqlit.OperationalError):
+            sqlit.connect("db", port=port, http_endpoint=self.endpoint, transport="binary")
+
+
+if __name__ == "__main__":
+    unittest.main()
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"sqlit/src/rpc/jsonrpc"
+)
+
+func init() {
+	rpc.RegisterMethod("api_version", apiVersion, apiVersionParams{})
+	rpc.RegisterMethod("api_batch", apiBatch, apiBatchParams{})
+}
+
+type apiVersionParams struct{}
+
+// APIVersionResponse is the response for method api_version.
+type APIVersionResponse struct {
+	Version string   `json:"version"`
+	Methods []string `json:"methods"`
+}
+
+func apiVersion(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	result = &APIVersionResponse{
+		Version: Version,
+		Methods: rpc.Methods(),
+	}
+	return result, nil
+}
+
+// BatchCall is a method call in an api_batch request.
+type BatchCall struct {
+	Method string           `json:"method"`
+	Params *json.RawMessage `json:"params"`
+}
+
+type apiBatchParams struct {
+	Calls []*BatchCall `json:"calls"`
+}
+
+func (params *apiBatchParams) Validate() error {
+	if len(params.Calls) > 100 {
+		return errors.New("max batch size is 100")
+	}
+	for _, v := range params.Calls {
+		if v == nil {
+			return errors.New("invalid nil call")
+		}
+		if v.Method == "api_batch" {
+			return errors.New("nested batch is not allowed")
+		}
+	}
+	return nil
+}
+
+// BatchResult is the result of a method call in an api_batch request.
+type BatchResult struct {
+	Result interface{}     `json:"result,omitempty"`
+	Error  *jsonrpc2.Error `json:"error,omitempty"`
+}
+
+// apiBatch runs the calls in order and returns the results in the same order, a failed call
+// doesn't abort the batch.
+func apiBatch(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*apiBatchParams)
+	results := make([]*BatchResult, len(params.Calls))
+	for i, v := range params.Calls {
+		r, err := rpc.Call(ctx, conn, v.Method, v.Params)
+		results[i] = &BatchResult{Result: r}
+		if err != nil {
+			if e, ok := err.(*jsonrpc2.Error); ok {
+				results[i].Error = e
+			} else {
+				results[i].Error = &jsonrpc2.Error{Message: err.Error()}
+			}
+			results[i].Result = nil
+		}
+	}
+	return results, nil
+}
@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/hex"
+
+	"github.com/pkg/errors"
+	"github.com/sourcegraph/jsonrpc2"
+
+	"sqlit/src/api/models"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/rpc/jsonrpc"
+	"sqlit/src/utils"
+)
+
+func init() {
+	rpc.RegisterMethod("bp_getHead", bpGetHead, bpGetHeadParams{})
+	rpc.RegisterMethod("bp_getAccount", bpGetAccount, bpGetAccountParams{})
+	rpc.RegisterMethod("bp_getDatabase", bpGetDatabase, bpGetDatabaseParams{})
+	rpc.RegisterMethod("bp_getTransactionState", bpGetTransactionState, bpGetTransactionStateParams{})
+	rpc.RegisterMethod("bp_sendTransaction", bpSendTransaction, bpSendTransactionParams{})
+}
+
+type bpGetHeadParams struct{}
+
+func bpGetHead(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	model := models.BlocksModel{}
+	return model.GetHead()
+}
+
+type bpGetAccountParams struct {
+	Address string `json:"address"`
+}
+
+func bpGetAccount(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpGetAccountParams)
+	model := models.AccountsModel{}
+	return model.GetAccount(params.Address)
+}
+
+type bpGetDatabaseParams struct {
+	ID string `json:"id"`
+}
+
+func bpGetDatabase(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpGetDatabaseParams)
+	model := models.DatabasesModel{}
+	return model.GetDatabase(params.ID)
+}
+
+type bpGetTransactionStateParams struct {
+	Hash string `json:"hash"`
+}
+
+// BPGetTransactionStateResponse is the response for method bp_getTransactionState.
+type BPGetTransactionStateResponse struct {
+	Hash  string `json:"hash"`
+	State string `json:"state"`
+}
+
+func bpGetTransactionState(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpGetTransactionStateParams)
+	model := models.TransactionsModel{}
+	state, err := model.GetTransactionState(params.Hash)
+	if err != nil {
+		return nil, err
+	}
+	result = &BPGetTransactionStateResponse{
+		Hash:  params.Hash,
+		State: state.String(),
+	}
+	return result, nil
+}
+
+type bpSendTransactionParams struct {
+	// Raw is the hex encoded msgpack of the signed transaction.
+	Raw string `json:"raw"`
+}
+
+// decodeTransaction decodes and verifies the signed transaction in raw.
+func decodeTransaction(raw string) (tx pi.Transaction, err error) {
+	enc, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid hex encoding")
+	}
+	if err = utils.DecodeMsgPack(enc, &tx); err != nil {
+		return nil, errors.Wrap(err, "decode transaction failed")
+	}
+	if w, ok := tx.(*pi.TransactionWrapper); ok {
+		tx = w.Unwrap()
+	}
+	if tx == nil {
+		return nil, errors.New("missing transaction")
+	}
+	if err = tx.Verify(); err != nil {
+		return nil, errors.Wrap(err, "verify transaction failed")
+	}
+	return tx, nil
+}
+
+// BPSendTransactionResponse is the response for method bp_sendTransaction.
+type BPSendTransactionResponse struct {
+	Hash string `json:"hash"`
+}
+
+func bpSendTransaction(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpSendTransactionParams)
+	tx, err := decodeTransaction(params.Raw)
+	if err != nil {
+		return nil, &jsonrpc2.Error{
+			Code:    jsonrpc2.CodeInvalidParams,
+			Message: err.Error(),
+		}
+	}
+	if err = submitTx(tx); err != nil {
+		return nil, err
+	}
+	result = &BPSendTransactionResponse{
+		Hash: tx.Hash().String(),
+	}
+	return result, nil
+}
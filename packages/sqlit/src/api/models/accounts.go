@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql"
+
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+// AccountsModel groups operations on Accounts.
+type AccountsModel struct{}
+
+// GetAccount get an account by its address.
+func (m *AccountsModel) GetAccount(address string) (account *types.Account, err error) {
+	var encoded []byte
+	query := `SELECT encoded FROM accounts WHERE address = ?`
+	err = chaindb.Db.QueryRow(query, address).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	account = &types.Account{}
+	err = utils.DecodeMsgPack(encoded, account)
+	return account, err
+}
@@ -99,3 +99,24 @@ func (m *BlocksModel) GetBlockByHash(hash string) (block *Block, err error) {
 	}
 	return block, err
 }
+
+// GetHead get the block of the max height.
+func (m *BlocksModel) GetHead() (block *Block, err error) {
+	block = &Block{}
+	query := `SELECT height, hash, timestamp, version, producer, merkle_root, parent, tx_count
+	FROM indexed_blocks ORDER BY height DESC LIMIT 1`
+	err = chaindb.SelectOne(block, query)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return block, err
+}
+
+// GetBlocksSince get at most limit blocks with height greater than since in ascending order.
+func (m *BlocksModel) GetBlocksSince(since, limit int) (blocks []*Block, err error) {
+	query := `SELECT height, hash, timestamp, version, producer, merkle_root, parent, tx_count
+	FROM indexed_blocks WHERE height > ? ORDER BY height ASC LIMIT ?`
+	blocks = make([]*Block, 0)
+	_, err = chaindb.Select(&blocks, query, since, limit)
+	return blocks, err
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"database/sql"
+
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+// DatabasesModel groups operations on Databases.
+type DatabasesModel struct{}
+
+// GetDatabase get a database profile by its id.
+func (m *DatabasesModel) GetDatabase(id string) (profile *types.SQLChainProfile, err error) {
+	var encoded []byte
+	query := `SELECT encoded FROM shardChain WHERE id = ?`
+	err = chaindb.Db.QueryRow(query, id).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	profile = &types.SQLChainProfile{}
+	err = utils.DecodeMsgPack(encoded, profile)
+	return profile, err
+}
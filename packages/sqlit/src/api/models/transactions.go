@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/go-gorp/gorp"
+
+	pi "sqlit/src/blockproducer/interfaces"
 )
 
 // TransactionsModel groups operations on Transactions.
@@ -89,6 +91,51 @@ func (m *TransactionsModel) GetTransactionListOfBlock(ofBlockHeight int, page, s
 	return txs, pagination, err
 }
 
+// GetTransactionListOfAccount get a transaction list sent by an account, newest first.
+func (m *TransactionsModel) GetTransactionListOfAccount(address string, page, size int) (
+	txs []*Transaction, pagination *Pagination, err error,
+) {
+	var (
+		querySQL = `
+		SELECT
+			block_height,
+			tx_index,
+			hash,
+			block_hash,
+			timestamp,
+			tx_type,
+			address,
+			raw
+		FROM
+			indexed_transactions
+		`
+		countSQL = buildCountSQL(querySQL)
+		conds    []string
+		args     []interface{}
+	)
+
+	pagination = NewPagination(page, size)
+	conds = append(conds, "address = ?")
+	args = append(args, address)
+
+	querySQL, countSQL = buildSQLWithConds(querySQL, countSQL, conds)
+	count, err := chaindb.SelectInt(countSQL, args...)
+	if err != nil {
+		return nil, pagination, err
+	}
+	pagination.SetTotal(int(count))
+	if pagination.Offset() > pagination.Total {
+		return txs, pagination, nil
+	}
+
+	querySQL += " ORDER BY block_height DESC, tx_index DESC"
+	querySQL += " LIMIT ? OFFSET ?"
+	args = append(args, pagination.Limit(), pagination.Offset())
+
+	_, err = chaindb.Select(&txs, querySQL, args...)
+	return txs, pagination, err
+}
+
 // GetTransactionList get a transaction list by hash marker.
 func (m *TransactionsModel) GetTransactionList(since string, page, size int) (
 	txs []*Transaction, pagination *Pagination, err error,
@@ -149,3 +196,22 @@ func (m *TransactionsModel) GetTransactionList(since string, page, size int) (
 	_, err = chaindb.Select(&txs, querySQL, args...)
 	return txs, pagination, err
 }
+
+// GetTransactionState get the state of a transaction by its hash, a transaction is confirmed if
+// it's indexed, or pending if it's still in the transaction pool.
+func (m *TransactionsModel) GetTransactionState(hash string) (state pi.TransactionState, err error) {
+	count, err := chaindb.SelectInt(`SELECT count(*) FROM indexed_transactions WHERE hash = ?`, hash)
+	if err != nil {
+		return pi.TransactionStateNotFound, err
+	}
+	if count > 0 {
+		return pi.TransactionStateConfirmed, nil
+	}
+	if count, err = chaindb.SelectInt(`SELECT count(*) FROM txPool WHERE hash = ?`, hash); err != nil {
+		return pi.TransactionStateNotFound, err
+	}
+	if count > 0 {
+		return pi.TransactionStatePending, nil
+	}
+	return pi.TransactionStateNotFound, nil
+}
@@ -7,12 +7,30 @@ import (
 	"github.com/pkg/errors"
 
 	"sqlit/src/api/models"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/route"
 	"sqlit/src/rpc/jsonrpc"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/types"
 )
 
+// Version is the version of the JSON-RPC API. The minor version is increased when methods or
+// result fields are added. The major version is increased on an incompatible change, in which
+// case the changed method is registered under a new name and the old one is kept until the next
+// major version, so that a client can check the version by api_version before calling.
+const Version = "1.1.0"
+
+// TxSubmitter submits a transaction to the block producers.
+type TxSubmitter func(tx pi.Transaction) error
+
 var (
 	rpc    = jsonrpc.NewHandler()
 	server *jsonrpc.WebsocketServer
+
+	submitTx TxSubmitter = func(tx pi.Transaction) error {
+		return mux.RequestBP(
+			route.MCCAddTx.String(), &types.AddTxReq{TTL: 1, Tx: tx}, &types.AddTxResp{})
+	}
 )
 
 func init() {
@@ -24,6 +42,12 @@ func init() {
 	}
 }
 
+// SetTxSubmitter replaces the submitter of bp_sendTransaction, which requests the block
+// producers by default.
+func SetTxSubmitter(fn TxSubmitter) {
+	submitTx = fn
+}
+
 // Serve runs an API server on the specified address and database file.
 func Serve(addr, dbFile string) error {
 	// setup database
@@ -32,10 +56,12 @@ func Serve(addr, dbFile string) error {
 	}
 	server.Addr = addr
 	server.RPCHandler = rpc
+	subs.start()
 	return server.Serve()
 }
 
 // StopService stops the API server.
 func StopService() {
 	server.Stop()
+	subs.stop()
 }
@@ -2,6 +2,8 @@ package api_test
 
 import (
 	"context"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -16,6 +18,12 @@ import (
 
 	"sqlit/src/api"
 	"sqlit/src/api/models"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
 )
 
 const (
@@ -60,6 +68,41 @@ var (
 		`CREATE INDEX IF NOT EXISTS "idx__indexed_transactions__timestamp" ON "indexed_transactions" ("timestamp" DESC);`,
 		`CREATE INDEX IF NOT EXISTS "idx__indexed_transactions__tx_type__timestamp" ON "indexed_transactions" ("tx_type", "timestamp" DESC);`,
 		`CREATE INDEX IF NOT EXISTS "idx__indexed_transactions__address__timestamp" ON "indexed_transactions" ("address", "timestamp" DESC);`,
+
+		`CREATE TABLE IF NOT EXISTS "txPool" (
+			"type"		INT,
+			"hash"		TEXT,
+			"encoded"	BLOB,
+			UNIQUE ("hash")
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS "accounts" (
+			"address"	TEXT,
+			"encoded"	BLOB,
+			UNIQUE ("address")
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS "shardChain" (
+			"address"	TEXT,
+			"id"		TEXT,
+			"encoded"	BLOB,
+			UNIQUE ("address", "id")
+		);`,
+	}
+
+	pendingTxHash = "Wq1cS5Ap1TDYuvdsQgD3Ew"
+
+	testAccount = &types.Account{
+		Address:   proto.AccountAddress(hash.THashH([]byte("account"))),
+		Rating:    1.5,
+		NextNonce: 10,
+	}
+
+	testProfile = &types.SQLChainProfile{
+		ID:      "db",
+		Address: proto.AccountAddress(hash.THashH([]byte("db"))),
+		Period:  60,
+		Owner:   testAccount.Address,
 	}
 
 	blocksMockData = [][]interface{}{
@@ -129,6 +172,64 @@ func mockData(t *testing.T) {
 	); err != nil {
 		t.Errorf("mock data for indexed_transactions failed: %v", err)
 	}
+
+	account, err := utils.EncodeMsgPack(testAccount)
+	if err != nil {
+		t.Errorf("encode account failed: %v", err)
+		return
+	}
+	profile, err := utils.EncodeMsgPack(testProfile)
+	if err != nil {
+		t.Errorf("encode profile failed: %v", err)
+		return
+	}
+	if err := insertRows("insert into accounts values (?,?)", [][]interface{}{
+		{testAccount.Address.String(), account.Bytes()},
+	}); err != nil {
+		t.Errorf("mock data for accounts failed: %v", err)
+	}
+	if err := insertRows("insert into shardChain values (?,?,?)", [][]interface{}{
+		{testProfile.Address.String(), string(testProfile.ID), profile.Bytes()},
+	}); err != nil {
+		t.Errorf("mock data for shardChain failed: %v", err)
+	}
+	if err := insertRows("insert into txPool values (?,?,?)", [][]interface{}{
+		{1, pendingTxHash, []byte{}},
+	}); err != nil {
+		t.Errorf("mock data for txPool failed: %v", err)
+	}
+}
+
+// notificationHandler collects the subscription notifications received by a client.
+type notificationHandler chan *api.SubscriptionNotification
+
+func (h notificationHandler) Handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) {
+	var n = new(api.SubscriptionNotification)
+	if req.Method == "bp_subscription" && req.Params != nil && json.Unmarshal(*req.Params, n) == nil {
+		h <- n
+	}
+}
+
+func setupSubscriptionClient(addr string) (client *jsonrpc2.Conn, ch notificationHandler, err error) {
+	conn, _, err := websocket.DefaultDialer.Dial(addr, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	ch = make(notificationHandler, 16)
+	return jsonrpc2.NewConn(context.Background(), wsstream.NewObjectStream(conn), ch), ch, nil
+}
+
+func waitNotification(ch notificationHandler, id string) (n *api.SubscriptionNotification) {
+	for {
+		select {
+		case n = <-ch:
+			if n.Subscription == id {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}
 }
 
 func setupWebsocketClient(addr string) (client *jsonrpc2.Conn, err error) {
@@ -530,6 +631,45 @@ func TestJSONRPCService(t *testing.T) {
 			}
 		})
 
+		Convey("bp_getTransactionListOfAccount should list the transactions of the account newest first", func(c C) {
+			var (
+				result    = new(api.BPGetTransactionListResponse)
+				testCases = []struct {
+					page, size int
+					expected   [][]interface{}
+					pagination *models.Pagination
+				}{
+					{1, 3, [][]interface{}{
+						transactionsMockData[6], transactionsMockData[4], transactionsMockData[1],
+					}, &models.Pagination{Page: 1, Size: 3, Total: 4, Pages: 2}},
+					{2, 3, [][]interface{}{
+						transactionsMockData[0],
+					}, &models.Pagination{Page: 2, Size: 3, Total: 4, Pages: 2}},
+				}
+			)
+
+			for i, testCase := range testCases {
+				Convey(fmt.Sprintf("case#%d: page %d", i, testCase.page), func() {
+					err := rpc.Call(
+						context.Background(),
+						"bp_getTransactionListOfAccount",
+						[]interface{}{addrA, testCase.page, testCase.size},
+						&result,
+					)
+					So(err, ShouldBeNil)
+					So(result.Pagination, ShouldResemble, testCase.pagination)
+					So(len(result.Transactions), ShouldEqual, len(testCase.expected))
+					for i, item := range result.Transactions {
+						conveyTransaction(c, item, testCase.expected[i])
+					}
+				})
+			}
+
+			err := rpc.Call(context.Background(), "bp_getTransactionListOfAccount",
+				[]interface{}{"", 1, 10}, &result)
+			So(err, ShouldNotBeNil)
+		})
+
 		Convey("bp_getTransactionByHash should fetch transactions on existed hash and nothing for an non-existed hash", func(c C) {
 			var (
 				result = new(models.Transaction)
@@ -558,4 +698,208 @@ func TestJSONRPCService(t *testing.T) {
 			rpc.Close()
 		})
 	})
+
+	Convey("chain state API", t, func() {
+		rpc, err := setupWebsocketClient(addr)
+		if err != nil {
+			t.Errorf("failed to connect to wsapi server: %v", err)
+			return
+		}
+
+		Convey("api_version should return the version and the methods", func() {
+			var result = new(api.APIVersionResponse)
+			err := rpc.Call(context.Background(), "api_version", []interface{}{}, &result)
+			So(err, ShouldBeNil)
+			So(result.Version, ShouldEqual, api.Version)
+			So(result.Methods, ShouldContain, "bp_getHead")
+			So(result.Methods, ShouldContain, "api_batch")
+		})
+
+		Convey("bp_getHead should fetch the block of the max height", func(c C) {
+			var result = new(models.Block)
+			err := rpc.Call(context.Background(), "bp_getHead", []interface{}{}, &result)
+			So(err, ShouldBeNil)
+			conveyBlock(c, result, blocksMockData[13])
+		})
+
+		Convey("bp_getAccount should fetch an existed account and nothing for an non-existed one", func() {
+			var result *types.Account
+			err := rpc.Call(context.Background(), "bp_getAccount",
+				[]interface{}{testAccount.Address.String()}, &result)
+			So(err, ShouldBeNil)
+			So(result, ShouldResemble, testAccount)
+			result = nil
+			err = rpc.Call(context.Background(), "bp_getAccount", []interface{}{addrA}, &result)
+			So(err, ShouldBeNil)
+			So(result, ShouldBeNil)
+		})
+
+		Convey("bp_getDatabase should fetch an existed database and nothing for an non-existed one", func() {
+			var result *types.SQLChainProfile
+			err := rpc.Call(context.Background(), "bp_getDatabase",
+				[]interface{}{string(testProfile.ID)}, &result)
+			So(err, ShouldBeNil)
+			So(result, ShouldNotBeNil)
+			So(result.Address, ShouldResemble, testProfile.Address)
+			So(result.Owner, ShouldResemble, testProfile.Owner)
+			So(result.Period, ShouldEqual, testProfile.Period)
+			result = nil
+			err = rpc.Call(context.Background(), "bp_getDatabase", []interface{}{"none"}, &result)
+			So(err, ShouldBeNil)
+			So(result, ShouldBeNil)
+		})
+
+		Convey("bp_getTransactionState should return the state of transactions", func() {
+			var (
+				result    = new(api.BPGetTransactionStateResponse)
+				testCases = map[string]pi.TransactionState{
+					"o362ksNHl8gIL4cbXjkMEQ": pi.TransactionStateConfirmed,
+					pendingTxHash:            pi.TransactionStatePending,
+					"HGGcDJqO7tuZWwJyFxRl9g": pi.TransactionStateNotFound,
+				}
+			)
+			for hash, state := range testCases {
+				err := rpc.Call(context.Background(), "bp_getTransactionState",
+					[]interface{}{hash}, &result)
+				So(err, ShouldBeNil)
+				So(result.Hash, ShouldEqual, hash)
+				So(result.State, ShouldEqual, state.String())
+			}
+		})
+
+		Convey("bp_sendTransaction should verify and submit transactions", func() {
+			var submitted []pi.Transaction
+			api.SetTxSubmitter(func(tx pi.Transaction) error {
+				submitted = append(submitted, tx)
+				return nil
+			})
+			priv, _, err := asymmetric.GenSecp256k1KeyPair()
+			So(err, ShouldBeNil)
+			tx := types.NewUpdatePermission(&types.UpdatePermissionHeader{
+				TargetSQLChain: testProfile.Address,
+				TargetUser:     testAccount.Address,
+				Permission:     types.UserPermissionFromRole(types.Read),
+				Nonce:          testAccount.NextNonce,
+			})
+			So(tx.Sign(priv), ShouldBeNil)
+
+			var (
+				result = new(api.BPSendTransactionResponse)
+				encode = func(tx pi.Transaction) string {
+					buf, err := utils.EncodeMsgPack(pi.WrapTransaction(tx))
+					So(err, ShouldBeNil)
+					return hex.EncodeToString(buf.Bytes())
+				}
+			)
+			err = rpc.Call(context.Background(), "bp_sendTransaction",
+				[]interface{}{encode(tx)}, &result)
+			So(err, ShouldBeNil)
+			So(result.Hash, ShouldEqual, tx.Hash().String())
+			So(submitted, ShouldHaveLength, 1)
+			So(submitted[0].Hash(), ShouldResemble, tx.Hash())
+
+			// Tampered transaction
+			tx.Nonce++
+			err = rpc.Call(context.Background(), "bp_sendTransaction",
+				[]interface{}{encode(tx)}, &result)
+			So(err, ShouldNotBeNil)
+			err = rpc.Call(context.Background(), "bp_sendTransaction",
+				[]interface{}{"not hex"}, &result)
+			So(err, ShouldNotBeNil)
+			So(submitted, ShouldHaveLength, 1)
+		})
+
+		Convey("api_batch should run the calls in order", func(c C) {
+			var result []*struct {
+				Result json.RawMessage `json:"result"`
+				Error  *jsonrpc2.Error `json:"error"`
+			}
+			err := rpc.Call(context.Background(), "api_batch", []interface{}{[]interface{}{
+				map[string]interface{}{"method": "bp_getHead", "params": []interface{}{}},
+				map[string]interface{}{"method": "method_NotFound", "params": []interface{}{}},
+				map[string]interface{}{"method": "bp_getBlockByHeight", "params": []interface{}{1}},
+			}}, &result)
+			So(err, ShouldBeNil)
+			So(result, ShouldHaveLength, 3)
+			var block = new(models.Block)
+			So(result[0].Error, ShouldBeNil)
+			So(json.Unmarshal(result[0].Result, block), ShouldBeNil)
+			conveyBlock(c, block, blocksMockData[13])
+			So(result[1].Error, ShouldNotBeNil)
+			So(result[1].Error.Code, ShouldEqual, jsonrpc2.CodeMethodNotFound)
+			So(result[2].Error, ShouldBeNil)
+			So(json.Unmarshal(result[2].Result, block), ShouldBeNil)
+			conveyBlock(c, block, blocksMockData[0])
+
+			err = rpc.Call(context.Background(), "api_batch", []interface{}{[]interface{}{
+				map[string]interface{}{"method": "api_batch", "params": []interface{}{}},
+			}}, &result)
+			So(err, ShouldNotBeNil)
+		})
+
+		Reset(func() {
+			rpc.Close()
+		})
+	})
+
+	Convey("subscription API", t, func() {
+		client, ch, err := setupSubscriptionClient(addr)
+		if err != nil {
+			t.Errorf("failed to connect to wsapi server: %v", err)
+			return
+		}
+		db, err := models.OpenSQLiteDBAsGorp(testdb, "rw", 1, 1)
+		if err != nil {
+			t.Errorf("open testdb failed: %v", err)
+			return
+		}
+
+		Convey("bp_subscribeNewBlocks should notify the new blocks", func() {
+			var id string
+			err := client.Call(context.Background(), "bp_subscribeNewBlocks", []interface{}{}, &id)
+			So(err, ShouldBeNil)
+			So(id, ShouldNotBeEmpty)
+			// Wait for the first polling, which starts from the current head
+			time.Sleep(1500 * time.Millisecond)
+			_, err = db.Exec("insert into indexed_blocks values (?,?,?,?,?,?,?,?)",
+				15, "Fq3pI3E3RAhuTp8aYh0fJw", 1546590200058583819, 1, bpB, "google",
+				"niLUTZpEpOWpPx011bZGlg", 0)
+			So(err, ShouldBeNil)
+			n := waitNotification(ch, id)
+			So(n, ShouldNotBeNil)
+			So(n.Result.(map[string]interface{})["height"], ShouldEqual, 15)
+
+			var ok bool
+			err = client.Call(context.Background(), "bp_unsubscribe", []interface{}{id}, &ok)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			err = client.Call(context.Background(), "bp_unsubscribe", []interface{}{id}, &ok)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Convey("bp_subscribeTransaction should notify the transaction confirmation", func() {
+			var id string
+			err := client.Call(context.Background(), "bp_subscribeTransaction",
+				[]interface{}{pendingTxHash}, &id)
+			So(err, ShouldBeNil)
+			_, err = db.Exec("insert into indexed_transactions values (?,?,?,?,?,?,?,?)",
+				14, 0, pendingTxHash, "niLUTZpEpOWpPx011bZGlg", 1546591421909181775, 1, addrA, `{}`)
+			So(err, ShouldBeNil)
+			n := waitNotification(ch, id)
+			So(n, ShouldNotBeNil)
+			So(n.Result.(map[string]interface{})["hash"], ShouldEqual, pendingTxHash)
+
+			// The subscription is removed after the confirmation
+			var ok bool
+			err = client.Call(context.Background(), "bp_unsubscribe", []interface{}{id}, &ok)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+		})
+
+		Reset(func() {
+			client.Close()
+			db.Db.Close()
+		})
+	})
 }
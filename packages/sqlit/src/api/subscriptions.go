@@ -0,0 +1,255 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sourcegraph/jsonrpc2"
+
+	"sqlit/src/api/models"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/rpc/jsonrpc"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// subscriptionNotifyMethod is the method of the notifications sent to the subscribers.
+	subscriptionNotifyMethod = "bp_subscription"
+	// subscriptionPollInterval is the interval of polling the chain database for notifications.
+	subscriptionPollInterval = time.Second
+	// subscriptionPollBlocks is the max count of blocks notified in a polling.
+	subscriptionPollBlocks = 100
+	// subscriptionNotifyTimeout is the timeout of sending a notification.
+	subscriptionNotifyTimeout = 5 * time.Second
+)
+
+var subs = newSubscriptions()
+
+func init() {
+	rpc.RegisterMethod("bp_subscribeNewBlocks", bpSubscribeNewBlocks, bpSubscribeNewBlocksParams{})
+	rpc.RegisterMethod("bp_subscribeTransaction", bpSubscribeTransaction, bpSubscribeTransactionParams{})
+	rpc.RegisterMethod("bp_unsubscribe", bpUnsubscribe, bpUnsubscribeParams{})
+}
+
+// SubscriptionNotification is the params of a notification sent to the subscribers.
+type SubscriptionNotification struct {
+	Subscription string      `json:"subscription"`
+	Result       interface{} `json:"result"`
+}
+
+type subscription struct {
+	id   string
+	conn *jsonrpc2.Conn
+	// hash is the transaction hash of a transaction subscription, or empty for a block one.
+	hash string
+}
+
+// subscriptions notifies the subscribers of the new blocks and the transaction confirmations by
+// polling the chain database.
+type subscriptions struct {
+	sync.Mutex
+	seq    uint64
+	subs   map[string]*subscription
+	conns  map[*jsonrpc2.Conn]struct{}
+	height int
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{
+		subs:   make(map[string]*subscription),
+		conns:  make(map[*jsonrpc2.Conn]struct{}),
+		height: -1,
+	}
+}
+
+func (s *subscriptions) start() {
+	s.Lock()
+	defer s.Unlock()
+	if s.stopCh != nil {
+		return
+	}
+	s.stopCh = make(chan struct{})
+	s.wg.Add(1)
+	go s.run(s.stopCh)
+}
+
+func (s *subscriptions) stop() {
+	s.Lock()
+	if s.stopCh == nil {
+		s.Unlock()
+		return
+	}
+	close(s.stopCh)
+	s.stopCh = nil
+	s.Unlock()
+	s.wg.Wait()
+}
+
+func (s *subscriptions) run(stopCh chan struct{}) {
+	defer s.wg.Done()
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-time.After(subscriptionPollInterval):
+			s.poll()
+		}
+	}
+}
+
+// add adds a subscription of the connection, and removes the subscriptions of the connection
+// when it's disconnected.
+func (s *subscriptions) add(conn *jsonrpc2.Conn, hash string) (id string) {
+	s.Lock()
+	defer s.Unlock()
+	s.seq++
+	id = fmt.Sprintf("0x%x", s.seq)
+	s.subs[id] = &subscription{id: id, conn: conn, hash: hash}
+	if _, ok := s.conns[conn]; !ok {
+		s.conns[conn] = struct{}{}
+		go func() {
+			<-conn.DisconnectNotify()
+			s.removeConn(conn)
+		}()
+	}
+	return
+}
+
+func (s *subscriptions) remove(conn *jsonrpc2.Conn, id string) (ok bool) {
+	s.Lock()
+	defer s.Unlock()
+	var sub *subscription
+	if sub, ok = s.subs[id]; ok && sub.conn == conn {
+		delete(s.subs, id)
+		return true
+	}
+	return false
+}
+
+func (s *subscriptions) removeConn(conn *jsonrpc2.Conn) {
+	s.Lock()
+	defer s.Unlock()
+	for k, v := range s.subs {
+		if v.conn == conn {
+			delete(s.subs, k)
+		}
+	}
+	delete(s.conns, conn)
+}
+
+func (s *subscriptions) list() (blockSubs, txSubs []*subscription) {
+	s.Lock()
+	defer s.Unlock()
+	for _, v := range s.subs {
+		if v.hash == "" {
+			blockSubs = append(blockSubs, v)
+		} else {
+			txSubs = append(txSubs, v)
+		}
+	}
+	return
+}
+
+func (s *subscriptions) poll() {
+	var (
+		blockSubs, txSubs = s.list()
+		blocksModel       = models.BlocksModel{}
+		txsModel          = models.TransactionsModel{}
+	)
+
+	// Start from the current head, the blocks before the first polling are not notified
+	if s.height < 0 {
+		head, err := blocksModel.GetHead()
+		if err != nil {
+			log.WithError(err).Warning("api: get head block failed")
+			return
+		}
+		s.height = 0
+		if head != nil {
+			s.height = head.Height
+		}
+	}
+	blocks, err := blocksModel.GetBlocksSince(s.height, subscriptionPollBlocks)
+	if err != nil {
+		log.WithError(err).Warning("api: get new blocks failed")
+		return
+	}
+	for _, b := range blocks {
+		for _, v := range blockSubs {
+			s.notify(v, b)
+		}
+		s.height = b.Height
+	}
+
+	for _, v := range txSubs {
+		state, err := txsModel.GetTransactionState(v.hash)
+		if err != nil {
+			log.WithError(err).Warning("api: get transaction state failed")
+			continue
+		}
+		if state != pi.TransactionStateConfirmed {
+			continue
+		}
+		tx, err := txsModel.GetTransactionByHash(v.hash)
+		if err != nil {
+			log.WithError(err).Warning("api: get transaction failed")
+			continue
+		}
+		// A transaction subscription is done once the transaction is confirmed
+		s.notify(v, tx)
+		s.remove(v.conn, v.id)
+	}
+}
+
+func (s *subscriptions) notify(sub *subscription, result interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), subscriptionNotifyTimeout)
+	defer cancel()
+	if err := sub.conn.Notify(ctx, subscriptionNotifyMethod, &SubscriptionNotification{
+		Subscription: sub.id,
+		Result:       result,
+	}); err != nil {
+		log.WithError(err).WithField("subscription", sub.id).Debug("api: notify subscriber failed")
+	}
+}
+
+type bpSubscribeNewBlocksParams struct{}
+
+func bpSubscribeNewBlocks(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	return subs.add(conn, ""), nil
+}
+
+type bpSubscribeTransactionParams struct {
+	Hash string `json:"hash"`
+}
+
+func (params *bpSubscribeTransactionParams) Validate() error {
+	if params.Hash == "" {
+		return fmt.Errorf("missing transaction hash")
+	}
+	return nil
+}
+
+func bpSubscribeTransaction(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpSubscribeTransactionParams)
+	return subs.add(conn, params.Hash), nil
+}
+
+type bpUnsubscribeParams struct {
+	ID string `json:"id"`
+}
+
+func bpUnsubscribe(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpUnsubscribeParams)
+	return subs.remove(conn, params.ID), nil
+}
@@ -15,6 +15,7 @@ func init() {
 	rpc.RegisterMethod("bp_getTransactionList", bpGetTransactionList, bpGetTransactionListParams{})
 	rpc.RegisterMethod("bp_getTransactionByHash", bpGetTransactionByHash, bpGetTransactionByHashParams{})
 	rpc.RegisterMethod("bp_getTransactionListOfBlock", bpGetTransactionListOfBlock, bpGetTransactionListOfBlockParams{})
+	rpc.RegisterMethod("bp_getTransactionListOfAccount", bpGetTransactionListOfAccount, bpGetTransactionListOfAccountParams{})
 }
 
 type bpGetTransactionListParams struct {
@@ -84,6 +85,38 @@ func bpGetTransactionListOfBlock(ctx context.Context, conn *jsonrpc2.Conn, req *
 	return result, nil
 }
 
+type bpGetTransactionListOfAccountParams struct {
+	Address string `json:"address"`
+	Page    int    `json:"page"`
+	Size    int    `json:"size"`
+}
+
+func (params *bpGetTransactionListOfAccountParams) Validate() error {
+	if params.Address == "" {
+		return errors.New("address is required")
+	}
+	if params.Size > 1000 {
+		return errors.New("max size is 1000")
+	}
+	return nil
+}
+
+func bpGetTransactionListOfAccount(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2.Request) (
+	result interface{}, err error,
+) {
+	params := jsonrpc.GetParams(ctx).(*bpGetTransactionListOfAccountParams)
+	model := models.TransactionsModel{}
+	transactions, pagination, err := model.GetTransactionListOfAccount(params.Address, params.Page, params.Size)
+	if err != nil {
+		return nil, err
+	}
+	result = &BPGetTransactionListResponse{
+		Transactions: transactions,
+		Pagination:   pagination,
+	}
+	return result, nil
+}
+
 type bpGetTransactionByHashParams struct {
 	Hash string `json:"hash"`
 }
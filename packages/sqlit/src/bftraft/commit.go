@@ -228,6 +228,10 @@ func (r *Runtime) getPrepareLog(ctx context.Context, l *kt.Log) (lastCommitIndex
 	if len(l.Data) >= 16 {
 		lastCommitIndex, _ = r.bytesToUint64(l.Data[8:])
 
+		if r.adoptLastCommit(lastCommitIndex) {
+			return
+		}
+
 		if _, err = r.waitForLog(ctx, lastCommitIndex); err != nil {
 			err = errors.Wrap(err, "wait for last commit log failed")
 			return
@@ -237,6 +241,20 @@ func (r *Runtime) getPrepareLog(ctx context.Context, l *kt.Log) (lastCommitIndex
 	return
 }
 
+// adoptLastCommit takes lastCommit as the last commit of a joining node once, the previous logs
+// are not fetched as they are already applied to the state synced by the handler.
+func (r *Runtime) adoptLastCommit(lastCommit uint64) bool {
+	if !atomic.CompareAndSwapUint32(&r.joining, 1, 0) {
+		return false
+	}
+	atomic.StoreUint64(&r.lastCommit, lastCommit)
+	log.WithFields(log.Fields{
+		"instance":   r.instanceID,
+		"lastCommit": lastCommit,
+	}).Info("bftraft joined with last commit")
+	return true
+}
+
 func (r *Runtime) doCommitCycle(req *commitReq) {
 	r.peersLock.RLock()
 	defer r.peersLock.RUnlock()
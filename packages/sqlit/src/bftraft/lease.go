@@ -0,0 +1,185 @@
+package bftraft
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	kt "sqlit/src/bftraft/types"
+	"sqlit/src/proto"
+	"sqlit/src/utils/log"
+)
+
+// checkLogTerm fences the log sent by a leader with the current peers, a log produced in an older
+// term or by another node in the same term is refused. A log of a newer term is accepted, the
+// peers update of the new leader may arrive later than its first logs.
+func (r *Runtime) checkLogTerm(l *kt.Log) (err error) {
+	if l.Term < r.peers.Term || (l.Term == r.peers.Term && l.Producer != r.peers.Leader) {
+		err = errors.Wrapf(kt.ErrStaleTerm, "log of term %d from %s, current term %d of leader %s",
+			l.Term, l.Producer, r.peers.Term, r.peers.Leader)
+	}
+	return
+}
+
+// checkDeposed marks the leader deposed if any follower refuses its logs by a newer term, the
+// deposed leader refuses the new requests until the peers are updated.
+func (r *Runtime) checkDeposed(errs map[proto.NodeID]error) {
+	for node, err := range errs {
+		// the errors are passed as the strings through rpc
+		if err == nil || !strings.Contains(err.Error(), kt.ErrStaleTerm.Error()) {
+			continue
+		}
+		if atomic.CompareAndSwapUint32(&r.deposed, 0, 1) {
+			atomic.StoreInt64(&r.leaseExpiry, 0)
+			log.WithFields(log.Fields{
+				"instance": r.instanceID,
+				"term":     r.peers.Term,
+				"follower": node,
+			}).Warning("bftraft leader deposed by a newer term")
+		}
+		return
+	}
+}
+
+// renewLease extends the leader lease to LeaseDuration after start, which is the time the logs
+// acknowledged by the followers are sent.
+func (r *Runtime) renewLease(start time.Time) {
+	var expiry = start.Add(r.leaseDuration).UnixNano()
+	for {
+		current := atomic.LoadInt64(&r.leaseExpiry)
+		if expiry <= current || atomic.CompareAndSwapInt64(&r.leaseExpiry, current, expiry) {
+			return
+		}
+	}
+}
+
+// HasLease returns whether this node is the leader of the current term with a valid lease, the
+// lease is always valid if it's disabled or if no follower is required.
+func (r *Runtime) HasLease() bool {
+	r.peersLock.RLock()
+	defer r.peersLock.RUnlock()
+	return r.hasLease()
+}
+
+func (r *Runtime) hasLease() bool {
+	if r.role != proto.Leader || atomic.LoadUint32(&r.deposed) == 1 {
+		return false
+	}
+	if r.leaseDuration <= 0 || r.minPreparedFollowers == 0 {
+		return true
+	}
+	return time.Now().UnixNano() < atomic.LoadInt64(&r.leaseExpiry)
+}
+
+// Lease returns the current term and the expiry of the leader lease, the expiry is zero if this
+// node doesn't hold the lease.
+func (r *Runtime) Lease() (term uint64, expiry time.Time) {
+	r.peersLock.RLock()
+	defer r.peersLock.RUnlock()
+	term = r.peers.Term
+	if r.hasLease() {
+		expiry = time.Unix(0, atomic.LoadInt64(&r.leaseExpiry))
+	}
+	return
+}
+
+// LastContact returns the time this follower last applied a log or a lease heartbeat of the
+// leader, the state of the follower is stale by the time since then at most as long as the leader
+// sends the heartbeats. It's zero if no log of the leader is applied since the runtime starts.
+func (r *Runtime) LastContact() (t time.Time) {
+	if nanos := atomic.LoadInt64(&r.lastContact); nanos > 0 {
+		t = time.Unix(0, nanos)
+	}
+	return
+}
+
+// leaseCycle renews the leader lease by the noop logs if no request renews it in the last third
+// of the lease duration.
+func (r *Runtime) leaseCycle() {
+	var ticker = time.NewTicker(r.leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stopCh:
+			return
+		case <-ticker.C:
+		case <-r.leaseCh:
+		}
+		r.heartbeat()
+	}
+}
+
+// heartbeat sends a noop log to the followers to renew the leader lease.
+func (r *Runtime) heartbeat() {
+	r.peersLock.RLock()
+	defer r.peersLock.RUnlock()
+
+	if r.role != proto.Leader || atomic.LoadUint32(&r.draining) == 1 ||
+		atomic.LoadUint32(&r.deposed) == 1 || r.minPreparedFollowers == 0 {
+		return
+	}
+	if time.Until(time.Unix(0, atomic.LoadInt64(&r.leaseExpiry))) > r.leaseDuration*2/3 {
+		// renewed by the requests recently
+		return
+	}
+
+	var (
+		start = time.Now()
+		l     = &kt.Log{
+			LogHeader: kt.LogHeader{
+				Type:     kt.LogNoop,
+				Producer: r.nodeID,
+				Term:     r.peers.Term,
+			},
+		}
+		ctx, cancel = context.WithTimeout(context.Background(), r.prepareTimeout)
+	)
+	defer cancel()
+	errs, _, _ := r.applyRPC(l, r.minPreparedFollowers).get(ctx)
+	r.checkDeposed(errs)
+	var acks int
+	for _, err := range errs {
+		if err == nil {
+			acks++
+		}
+	}
+	if acks >= r.minPreparedFollowers {
+		r.renewLease(start)
+		return
+	}
+	log.WithFields(log.Fields{
+		"instance": r.instanceID,
+		"acks":     acks,
+		"required": r.minPreparedFollowers,
+	}).Warning("bftraft renew leader lease failed")
+}
+
+// WaitLease waits for this leader to hold a valid lease, the lease is renewed immediately if it's
+// expired. It returns ErrNotLeader if this node is not the leader of the current term, and
+// ErrLeaseExpired if the lease is not renewed before ctx is done.
+func (r *Runtime) WaitLease(ctx context.Context) (err error) {
+	for {
+		r.peersLock.RLock()
+		var (
+			isLeader = r.role == proto.Leader && atomic.LoadUint32(&r.deposed) == 0
+			ok       = r.hasLease()
+			term     = r.peers.Term
+		)
+		r.peersLock.RUnlock()
+		if ok {
+			return
+		}
+		if !isLeader {
+			return errors.Wrapf(kt.ErrNotLeader, "in term %d", term)
+		}
+		r.acquireLease()
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(kt.ErrLeaseExpired, "in term %d", term)
+		case <-time.After(r.leaseDuration / 100):
+		}
+	}
+}
@@ -25,6 +25,7 @@ func (r *Runtime) newLog(ctx context.Context, logType kt.LogType, data []byte) (
 			Index:    i,
 			Type:     logType,
 			Producer: r.nodeID,
+			Term:     r.peers.Term,
 		},
 		Data: data,
 	}
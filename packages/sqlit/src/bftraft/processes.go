@@ -3,6 +3,7 @@ package bftraft
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -45,10 +46,12 @@ func (r *Runtime) doLeaderPrepare(ctx context.Context, tm *timer.Timer, req inte
 	tm.Add("leader_prepare")
 
 	// send prepare to all nodes
+	prepareStart := time.Now()
 	prepareTracker := r.applyRPC(prepareLog, r.minPreparedFollowers)
 	prepareCtx, prepareCtxCancelFunc := context.WithTimeout(ctx, r.prepareTimeout)
 	defer prepareCtxCancelFunc()
 	prepareErrors, prepareDone, _ := prepareTracker.get(prepareCtx)
+	r.checkDeposed(prepareErrors)
 	if !prepareDone {
 		// timeout, rollback
 		err = kt.ErrPrepareTimeout
@@ -58,7 +61,9 @@ func (r *Runtime) doLeaderPrepare(ctx context.Context, tm *timer.Timer, req inte
 	tm.Add("follower_prepare")
 
 	// collect errors
-	err = r.errorSummary(prepareErrors)
+	if err = r.errorSummary(prepareErrors); err == nil {
+		r.renewLease(prepareStart)
+	}
 
 	return
 }
@@ -58,6 +58,22 @@ type Runtime struct {
 	minPreparedFollowers int
 	// calculated min follower nodes for commit.
 	minCommitFollowers int
+	// draining rejects the new requests on leader during leadership handoff.
+	draining uint32
+	// joining adopts the last commit of the first commit log instead of fetching the previous logs.
+	joining uint32
+	// deposed rejects the new requests on leader once a follower refuses its logs by a newer term.
+	deposed uint32
+
+	/// Leader lease
+	// lease duration, the lease is disabled if it's zero.
+	leaseDuration time.Duration
+	// lease expiry in unix nanoseconds.
+	leaseExpiry int64
+	// channel to renew the lease immediately.
+	leaseCh chan struct{}
+	// time of the last log of the leader applied by this follower in unix nanoseconds.
+	lastContact int64
 
 	/// RPC related
 	// new caller functions: wrap for mocking testable purpose.
@@ -163,34 +179,11 @@ func NewRuntime(cfg *kt.RuntimeConfig) (rt *Runtime, err error) {
 		return
 	}
 
-	followers := make([]proto.NodeID, 0, len(peers.Servers))
-	exists := false
-	var role proto.ServerRole
-
-	for _, v := range peers.Servers {
-		if !v.IsEqual(&peers.Leader) {
-			followers = append(followers, v)
-		}
-
-		if v.IsEqual(&cfg.NodeID) {
-			exists = true
-			if v.IsEqual(&peers.Leader) {
-				role = proto.Leader
-			} else {
-				role = proto.Follower
-			}
-		}
-	}
-
-	if !exists {
-		err = errors.Wrapf(kt.ErrNotInPeer, "node %v not in peers %v", cfg.NodeID, peers)
+	role, followers, err := peersRole(peers, cfg.NodeID)
+	if err != nil {
 		return
 	}
 
-	// calculate fan-out count according to threshold and peers info
-	minPreparedFollowers := int(math.Max(math.Ceil(cfg.PrepareThreshold*float64(len(peers.Servers))), 1) - 1)
-	minCommitFollowers := int(math.Max(math.Ceil(cfg.CommitThreshold*float64(len(peers.Servers))), 1) - 1)
-
 	rt = &Runtime{
 		// indexes
 		pendingPrepares: make(map[uint64]bool, commitWindow*2),
@@ -205,8 +198,8 @@ func NewRuntime(cfg *kt.RuntimeConfig) (rt *Runtime, err error) {
 		nodeID:               cfg.NodeID,
 		followers:            followers,
 		role:                 role,
-		minPreparedFollowers: minPreparedFollowers,
-		minCommitFollowers:   minCommitFollowers,
+		minPreparedFollowers: minFollowers(cfg.PrepareThreshold, peers),
+		minCommitFollowers:   minFollowers(cfg.CommitThreshold, peers),
 
 		// rpc related
 		TrackerNewCallerFunc: defaultNewCallerFunc,
@@ -223,18 +216,61 @@ func NewRuntime(cfg *kt.RuntimeConfig) (rt *Runtime, err error) {
 		logWaitTimeout:   cfg.LogWaitTimeout,
 		commitCh:         make(chan *commitReq, commitWindow),
 
+		// leader lease
+		leaseDuration: cfg.LeaseDuration,
+		leaseCh:       make(chan struct{}, 1),
+
 		// stop coordinator
 		stopCh: make(chan struct{}),
 	}
 
-	// read from pool to rebuild uncommitted log map
+	// read from pool to rebuild uncommitted log map, the wal of a joined node starts from the
+	// first commit received
+	rt.joining = 1
 	if err = rt.readLogs(); err != nil {
 		return
 	}
+	if !cfg.Join {
+		rt.joining = 0
+	}
 
 	return
 }
 
+// peersRole returns the role of the node and the followers in peers.
+func peersRole(peers *proto.Peers, nodeID proto.NodeID) (
+	role proto.ServerRole, followers []proto.NodeID, err error,
+) {
+	followers = make([]proto.NodeID, 0, len(peers.Servers))
+	exists := false
+
+	for _, v := range peers.Servers {
+		if !v.IsEqual(&peers.Leader) {
+			followers = append(followers, v)
+		}
+
+		if v.IsEqual(&nodeID) {
+			exists = true
+			if v.IsEqual(&peers.Leader) {
+				role = proto.Leader
+			} else {
+				role = proto.Follower
+			}
+		}
+	}
+
+	if !exists {
+		err = errors.Wrapf(kt.ErrNotInPeer, "node %v not in peers %v", nodeID, peers)
+	}
+
+	return
+}
+
+// minFollowers calculates fan-out count according to threshold and peers info.
+func minFollowers(threshold float64, peers *proto.Peers) int {
+	return int(math.Max(math.Ceil(threshold*float64(len(peers.Servers))), 1) - 1)
+}
+
 // Start starts the Runtime.
 func (r *Runtime) Start() (err error) {
 	if !atomic.CompareAndSwapUint32(&r.started, 0, 1) {
@@ -243,6 +279,10 @@ func (r *Runtime) Start() (err error) {
 
 	// start commit cycle
 	r.goFunc(r.commitCycle)
+	if r.leaseDuration > 0 {
+		r.goFunc(r.leaseCycle)
+		r.acquireLease()
+	}
 
 	return
 }
@@ -287,11 +327,15 @@ func (r *Runtime) Apply(ctx context.Context, req interface{}) (result interface{
 
 	tm.Add("peers_lock")
 
-	if r.role != proto.Leader {
-		// not leader
+	if r.role != proto.Leader || atomic.LoadUint32(&r.draining) == 1 {
+		// not leader, or handing off the leadership
 		err = kt.ErrNotLeader
 		return
 	}
+	if atomic.LoadUint32(&r.deposed) == 1 {
+		err = errors.Wrapf(kt.ErrNotLeader, "deposed in term %d", r.peers.Term)
+		return
+	}
 
 	// prepare
 	prepareLog, err := r.doLeaderPrepare(ctx, tm, req)
@@ -344,16 +388,109 @@ func (r *Runtime) Fetch(ctx context.Context, index uint64) (l *kt.Log, err error
 	return r.wal.Get(index)
 }
 
-// FollowerApply defines entry for follower node.
+// FollowerApply defines entry for follower node, the prepare and noop logs are fenced by the
+// peers term of the leader.
 func (r *Runtime) FollowerApply(l *kt.Log) (err error) {
 	return r.followerApply(l, true)
 }
 
 // UpdatePeers defines entry for peers update logic.
 func (r *Runtime) UpdatePeers(peers *proto.Peers) (err error) {
+	if peers == nil {
+		return errors.Wrap(kt.ErrInvalidConfig, "nil peers")
+	}
+	if err = peers.Verify(); err != nil {
+		return errors.Wrap(err, "verify peers during bftraft update failed")
+	}
+	role, followers, err := peersRole(peers, r.nodeID)
+	if err != nil {
+		return
+	}
+
+	// wait for the in-flight requests to finish
+	r.peersLock.Lock()
+	defer r.peersLock.Unlock()
+
+	if peers.Term != r.peers.Term || peers.Leader != r.peers.Leader {
+		// the lease of the previous term is not inherited
+		atomic.StoreInt64(&r.leaseExpiry, 0)
+		atomic.StoreUint32(&r.deposed, 0)
+	}
+	r.peers = peers
+	r.role = role
+	r.followers = followers
+	r.minPreparedFollowers = minFollowers(r.prepareThreshold, peers)
+	r.minCommitFollowers = minFollowers(r.commitThreshold, peers)
+	atomic.StoreUint32(&r.draining, 0)
+	if role == proto.Leader {
+		r.acquireLease()
+	}
+
+	return
+}
+
+// acquireLease triggers the lease cycle to renew the lease immediately.
+func (r *Runtime) acquireLease() {
+	if r.leaseDuration <= 0 {
+		return
+	}
+	select {
+	case r.leaseCh <- struct{}{}:
+	default:
+	}
+}
+
+// Peers returns a copy of the current peers.
+func (r *Runtime) Peers() *proto.Peers {
+	r.peersLock.RLock()
+	defer r.peersLock.RUnlock()
+	peers := r.peers.Clone()
+	return &peers
+}
+
+// Drain rejects the new requests on leader and waits for the in-flight ones to finish before
+// handing off the leadership. It returns the last commit index and the next log index, which
+// should be caught up by the new leader.
+func (r *Runtime) Drain() (lastCommit uint64, nextIndex uint64) {
+	atomic.StoreUint32(&r.draining, 1)
+	// the new leader may serve before the lease of this leader expires
+	atomic.StoreInt64(&r.leaseExpiry, 0)
+
 	r.peersLock.Lock()
 	defer r.peersLock.Unlock()
 
+	r.nextIndexLock.Lock()
+	defer r.nextIndexLock.Unlock()
+
+	return atomic.LoadUint64(&r.lastCommit), r.nextIndex
+}
+
+// Resume accepts the new requests again if the leadership handoff fails.
+func (r *Runtime) Resume() {
+	atomic.StoreUint32(&r.draining, 0)
+}
+
+// CatchUp waits for the log at lastCommit to be committed on follower, and allocates the new
+// logs from nextIndex once it becomes the leader.
+func (r *Runtime) CatchUp(ctx context.Context, lastCommit uint64, nextIndex uint64) (err error) {
+	for atomic.LoadUint64(&r.lastCommit) < lastCommit {
+		if _, err = r.waitForLog(ctx, lastCommit); err != nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.logWaitTimeout / 100):
+		}
+	}
+
+	r.nextIndexLock.Lock()
+	defer r.nextIndexLock.Unlock()
+
+	if r.nextIndex < nextIndex {
+		r.nextIndex = nextIndex
+	}
+
 	return
 }
 
@@ -429,6 +566,13 @@ func (r *Runtime) followerApply(l *kt.Log, checkPrepare bool) (err error) {
 
 	tm.Add("peers_lock")
 
+	// the logs sent by leader are checked, the fetched ones may be produced in the previous terms
+	if checkPrepare && (l.Type == kt.LogPrepare || l.Type == kt.LogNoop) {
+		if err = r.checkLogTerm(l); err != nil {
+			return
+		}
+	}
+
 	if r.role == proto.Leader {
 		// not follower
 		err = kt.ErrNotFollower
@@ -437,6 +581,10 @@ func (r *Runtime) followerApply(l *kt.Log, checkPrepare bool) (err error) {
 
 	// verify log structure
 	switch l.Type {
+	case kt.LogNoop:
+		// lease heartbeat of the leader, not written to wal
+		atomic.StoreInt64(&r.lastContact, time.Now().UnixNano())
+		return
 	case kt.LogPrepare:
 		err = r.followerPrepare(ctx, tm, l, checkPrepare)
 	case kt.LogRollback:
@@ -448,6 +596,7 @@ func (r *Runtime) followerApply(l *kt.Log, checkPrepare bool) (err error) {
 	if err == nil {
 		r.updateNextIndex(ctx, l)
 		r.triggerLogAwaits(l)
+		atomic.StoreInt64(&r.lastContact, time.Now().UnixNano())
 	}
 
 	return
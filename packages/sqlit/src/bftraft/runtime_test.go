@@ -411,6 +411,153 @@ func TestRuntime(t *testing.T) {
 		So(d2, ShouldHaveLength, 1)
 		So(d2[0], ShouldHaveLength, 1)
 		So(fmt.Sprint(d2[0][0]), ShouldResemble, fmt.Sprint(total))
+
+		// test leadership handoff
+		lastCommit, nextIndex := rt1.Drain()
+		_, _, err = rt1.Apply(context.Background(), q)
+		So(errors.Cause(err), ShouldEqual, kt.ErrNotLeader)
+		err = rt2.CatchUp(context.Background(), lastCommit, nextIndex)
+		So(err, ShouldBeNil)
+
+		newPeers := rt1.Peers()
+		newPeers.Leader = node2
+		newPeers.Term++
+		err = newPeers.Sign(privKey)
+		So(err, ShouldBeNil)
+		err = rt1.UpdatePeers(newPeers)
+		So(err, ShouldBeNil)
+		err = rt2.UpdatePeers(newPeers)
+		So(err, ShouldBeNil)
+		So(rt2.Peers().Leader, ShouldEqual, node2)
+
+		_, _, err = rt1.Apply(context.Background(), q)
+		So(errors.Cause(err), ShouldEqual, kt.ErrNotLeader)
+		_, _, err = rt2.Apply(context.Background(), q)
+		So(err, ShouldBeNil)
+
+		_, _, d1, _ = db1.Query(context.Background(), []storage.Query{
+			{Pattern: "SELECT COUNT(1) FROM test"},
+		})
+		So(fmt.Sprint(d1[0][0]), ShouldEqual, fmt.Sprint(total+1))
+		_, _, d2, _ = db2.Query(context.Background(), []storage.Query{
+			{Pattern: "SELECT COUNT(1) FROM test"},
+		})
+		So(fmt.Sprint(d2[0][0]), ShouldEqual, fmt.Sprint(total+1))
+	})
+	Convey("test joining", t, func(c C) {
+		db1, err := newSQLiteStorage("testJoin1.db")
+		So(err, ShouldBeNil)
+		defer func() {
+			db1.Close()
+			os.Remove("testJoin1.db")
+		}()
+		db2, err := newSQLiteStorage("testJoin2.db")
+		So(err, ShouldBeNil)
+		defer func() {
+			db2.Close()
+			os.Remove("testJoin2.db")
+		}()
+
+		node1 := proto.NodeID("000005aa62048f85da4ae9698ed59c14ec0d48a88a07c15a32265634e7e64ade")
+		node2 := proto.NodeID("000005f4f22c06f76c43c4f48d5a7ec1309cc94030cbf9ebae814172884ac8b5")
+
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		peers := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Leader:  node1,
+				Servers: []proto.NodeID{node1},
+			},
+		}
+		err = peers.Sign(privKey)
+		So(err, ShouldBeNil)
+
+		wal1 := kl.NewMemWal()
+		defer wal1.Close()
+		rt1, err := bftraft.NewRuntime(&kt.RuntimeConfig{
+			Handler:          db1,
+			PrepareThreshold: 1.0,
+			CommitThreshold:  1.0,
+			PrepareTimeout:   time.Second,
+			CommitTimeout:    5 * time.Second,
+			LogWaitTimeout:   10 * time.Second,
+			Peers:            peers,
+			Wal:              wal1,
+			NodeID:           node1,
+			ServiceName:      "Test",
+			ApplyMethodName:  "Apply",
+		})
+		So(err, ShouldBeNil)
+		err = rt1.Start()
+		So(err, ShouldBeNil)
+		defer rt1.Shutdown()
+
+		// the history before joining is synced to db2 by the handler
+		create := &queryStructure{
+			Queries: []storage.Query{
+				{Pattern: "CREATE TABLE IF NOT EXISTS test (t1 text)"},
+			},
+		}
+		insert := &queryStructure{
+			Queries: []storage.Query{
+				{Pattern: "INSERT INTO test (t1) VALUES(?)", Args: []sql.NamedArg{sql.Named("", "v")}},
+			},
+		}
+		for _, q := range []*queryStructure{create, insert, insert} {
+			_, _, err = rt1.Apply(context.Background(), q)
+			So(err, ShouldBeNil)
+			_, err = db2.Commit(q, false)
+			So(err, ShouldBeNil)
+		}
+
+		newPeers := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Leader:  node1,
+				Servers: []proto.NodeID{node1, node2},
+			},
+		}
+		err = newPeers.Sign(privKey)
+		So(err, ShouldBeNil)
+		wal2 := kl.NewMemWal()
+		defer wal2.Close()
+		rt2, err := bftraft.NewRuntime(&kt.RuntimeConfig{
+			Handler:          db2,
+			PrepareThreshold: 1.0,
+			CommitThreshold:  1.0,
+			PrepareTimeout:   time.Second,
+			CommitTimeout:    5 * time.Second,
+			LogWaitTimeout:   10 * time.Second,
+			Peers:            newPeers,
+			Wal:              wal2,
+			NodeID:           node2,
+			ServiceName:      "Test",
+			ApplyMethodName:  "Apply",
+			Join:             true,
+		})
+		So(err, ShouldBeNil)
+
+		m := newFakeMux()
+		m.register(node1, newFakeService(rt1))
+		m.register(node2, newFakeService(rt2))
+		rt1.TrackerNewCallerFunc = func(proto.NodeID) bftraft.Caller {
+			return newFakeCaller(m, node2)
+		}
+		rt2.WaiterNewCallerFunc = func(proto.NodeID) bftraft.Caller {
+			return newFakeCaller(m, node1)
+		}
+		err = rt2.Start()
+		So(err, ShouldBeNil)
+		defer rt2.Shutdown()
+		err = rt1.UpdatePeers(newPeers)
+		So(err, ShouldBeNil)
+
+		// the joined follower commits without fetching the previous logs
+		_, _, err = rt1.Apply(context.Background(), insert)
+		So(err, ShouldBeNil)
+		_, _, d2, _ := db2.Query(context.Background(), []storage.Query{
+			{Pattern: "SELECT COUNT(1) FROM test"},
+		})
+		So(fmt.Sprint(d2[0][0]), ShouldEqual, "3")
 	})
 	Convey("trivial cases", t, func() {
 		node1 := proto.NodeID("000005aa62048f85da4ae9698ed59c14ec0d48a88a07c15a32265634e7e64ade")
@@ -719,3 +866,141 @@ func BenchmarkRuntime(b *testing.B) {
 		b.StartTimer()
 	})
 }
+
+func TestLeaderLease(t *testing.T) {
+	Convey("test leader lease and fencing", t, func(c C) {
+		db1, err := newSQLiteStorage("testLease1.db")
+		So(err, ShouldBeNil)
+		defer func() {
+			db1.Close()
+			os.Remove("testLease1.db")
+		}()
+		db2, err := newSQLiteStorage("testLease2.db")
+		So(err, ShouldBeNil)
+		defer func() {
+			db2.Close()
+			os.Remove("testLease2.db")
+		}()
+
+		node1 := proto.NodeID("000005aa62048f85da4ae9698ed59c14ec0d48a88a07c15a32265634e7e64ade")
+		node2 := proto.NodeID("000005f4f22c06f76c43c4f48d5a7ec1309cc94030cbf9ebae814172884ac8b5")
+
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		peers := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Term:    1,
+				Leader:  node1,
+				Servers: []proto.NodeID{node1, node2},
+			},
+		}
+		err = peers.Sign(privKey)
+		So(err, ShouldBeNil)
+
+		newRuntime := func(db *sqliteStorage, nodeID proto.NodeID) (rt *bftraft.Runtime, wal *kl.MemWal) {
+			wal = kl.NewMemWal()
+			rt, err := bftraft.NewRuntime(&kt.RuntimeConfig{
+				Handler:          db,
+				PrepareThreshold: 1.0,
+				CommitThreshold:  1.0,
+				PrepareTimeout:   time.Second,
+				CommitTimeout:    5 * time.Second,
+				LogWaitTimeout:   5 * time.Second,
+				LeaseDuration:    3 * time.Second,
+				Peers:            peers,
+				Wal:              wal,
+				NodeID:           nodeID,
+				ServiceName:      "Test",
+				ApplyMethodName:  "Apply",
+			})
+			So(err, ShouldBeNil)
+			return
+		}
+		rt1, wal1 := newRuntime(db1, node1)
+		defer wal1.Close()
+		rt2, wal2 := newRuntime(db2, node2)
+		defer wal2.Close()
+
+		m := newFakeMux()
+		m.register(node1, newFakeService(rt1))
+		m.register(node2, newFakeService(rt2))
+		caller2Node1 := newFakeCaller(m, node1)
+		caller2Node2 := newFakeCaller(m, node2)
+		rt1.WaiterNewCallerFunc = func(proto.NodeID) bftraft.Caller { return caller2Node2 }
+		rt1.TrackerNewCallerFunc = func(proto.NodeID) bftraft.Caller { return caller2Node2 }
+		rt2.WaiterNewCallerFunc = func(proto.NodeID) bftraft.Caller { return caller2Node1 }
+		rt2.TrackerNewCallerFunc = func(proto.NodeID) bftraft.Caller { return caller2Node1 }
+
+		err = rt2.Start()
+		So(err, ShouldBeNil)
+		defer rt2.Shutdown()
+		err = rt1.Start()
+		So(err, ShouldBeNil)
+		defer rt1.Shutdown()
+
+		// lease is acquired by the heartbeat on start
+		for i := 0; i != 100 && !rt1.HasLease(); i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		So(rt1.HasLease(), ShouldBeTrue)
+		So(rt2.HasLease(), ShouldBeFalse)
+		term, expiry := rt1.Lease()
+		So(term, ShouldEqual, 1)
+		So(expiry, ShouldHappenAfter, time.Now())
+
+		q := &queryStructure{
+			Queries: []storage.Query{
+				{Pattern: "CREATE TABLE IF NOT EXISTS test (t1 text)"},
+			},
+		}
+		_, _, err = rt1.Apply(context.Background(), q)
+		So(err, ShouldBeNil)
+
+		// the contact with the leader is tracked by the follower
+		So(rt1.LastContact().IsZero(), ShouldBeTrue)
+		So(rt2.LastContact(), ShouldHappenWithin, time.Minute, time.Now())
+
+		// logs of the previous term are refused by follower
+		err = rt2.FollowerApply(&kt.Log{
+			LogHeader: kt.LogHeader{Type: kt.LogNoop, Producer: node1, Term: 0},
+		})
+		So(errors.Cause(err), ShouldEqual, kt.ErrStaleTerm)
+		err = rt2.FollowerApply(&kt.Log{
+			LogHeader: kt.LogHeader{Type: kt.LogNoop, Producer: node2, Term: 1},
+		})
+		So(errors.Cause(err), ShouldEqual, kt.ErrStaleTerm)
+
+		// node2 is elected in a partition unseen by node1
+		newPeers := rt2.Peers()
+		newPeers.Leader = node2
+		newPeers.Term++
+		err = newPeers.Sign(privKey)
+		So(err, ShouldBeNil)
+		err = rt2.UpdatePeers(newPeers)
+		So(err, ShouldBeNil)
+
+		// writes of the stale leader are fenced and the stale leader is deposed
+		_, _, err = rt1.Apply(context.Background(), q)
+		So(err, ShouldNotBeNil)
+		So(rt1.HasLease(), ShouldBeFalse)
+		_, _, err = rt1.Apply(context.Background(), q)
+		So(errors.Cause(err), ShouldEqual, kt.ErrNotLeader)
+
+		// the new term is adopted by the deposed leader, the new leader skips its uncommitted logs
+		lastCommit, nextIndex := rt1.Drain()
+		err = rt2.CatchUp(context.Background(), lastCommit, nextIndex)
+		So(err, ShouldBeNil)
+		err = rt1.UpdatePeers(newPeers)
+		So(err, ShouldBeNil)
+		So(rt1.HasLease(), ShouldBeFalse)
+		term, expiry = rt1.Lease()
+		So(term, ShouldEqual, 2)
+		So(expiry.IsZero(), ShouldBeTrue)
+		_, _, err = rt2.Apply(context.Background(), q)
+		So(err, ShouldBeNil)
+		So(rt2.HasLease(), ShouldBeTrue)
+		So(rt2.WaitLease(context.Background()), ShouldBeNil)
+		err = rt1.WaitLease(context.Background())
+		So(errors.Cause(err), ShouldEqual, kt.ErrNotLeader)
+	})
+}
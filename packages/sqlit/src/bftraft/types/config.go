@@ -35,4 +35,9 @@ type RuntimeConfig struct {
 	FetchMethodName string
 	// fetch timeout.
 	LogWaitTimeout time.Duration
+	// leader lease duration, the leader lease is disabled if it's zero.
+	LeaseDuration time.Duration
+	// join an existing instance with an empty wal, the state before the first commit received is
+	// synced by the handler instead of the previous logs.
+	Join bool
 }
@@ -20,4 +20,8 @@ var (
 	ErrInvalidConfig = errors.New("invalid runtime config")
 	// ErrStopped represents runtime not started.
 	ErrStopped = errors.New("stopped")
+	// ErrStaleTerm represents log from a leader of a stale peers term.
+	ErrStaleTerm = errors.New("stale leader term")
+	// ErrLeaseExpired represents leader which fails to renew its lease from the followers.
+	ErrLeaseExpired = errors.New("leader lease expired")
 )
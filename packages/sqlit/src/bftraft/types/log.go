@@ -19,7 +19,7 @@ const (
 	LogCheckpoint
 	// LogBarrier defines barrier log, all open windows should be waiting this operations to complete.
 	LogBarrier
-	// LogNoop defines noop log.
+	// LogNoop defines noop log, it's sent by leader to renew the leader lease and never written to wal.
 	LogNoop
 )
 
@@ -48,6 +48,7 @@ type LogHeader struct {
 	Version    uint64       // log version
 	Type       LogType      // log type
 	Producer   proto.NodeID // producer node
+	Term       uint64       // peers term of the producer, the fencing token of the leader
 	DataLength uint64       // data length
 }
 
@@ -0,0 +1,125 @@
+package blockproducer
+
+import (
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto/hash"
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+// Block storage backends of the chain.
+const (
+	// SQLiteBlockStorage keeps the blocks in the chain database, it's the default backend.
+	SQLiteBlockStorage = "sqlite"
+	// LevelDBBlockStorage keeps the blocks in a LevelDB next to the chain database, which suits
+	// the block append and scan workload better at high tx rates.
+	LevelDBBlockStorage = "leveldb"
+)
+
+// blockStore is the storage of the encoded blocks, which are only appended and scanned on
+// startup. The chain state and the block indexes are always kept in the chain database.
+type blockStore interface {
+	// putBlock returns the procedure storing block b at height, storing a block again is a no-op.
+	putBlock(height uint32, b *types.BPBlock) storageProcedure
+	getBlock(h hash.Hash) (*types.BPBlock, error)
+	// scanBlocks iterates the blocks in the order they are stored, so that a parent is always
+	// visited before its children.
+	scanBlocks(fn func(height uint32, bh, ph hash.Hash, enc []byte) error) error
+	close() error
+}
+
+// openBlockStore opens the block storage backend kind, empty means SQLiteBlockStorage. The
+// LevelDB backend is placed at dataFile with suffix ".blocks", and takes over the blocks in the
+// chain database st if it's empty. A non-empty LevelDB backend is refused for a new chain
+// database.
+func openBlockStore(
+	kind string, st xi.Storage, dataFile string, newChain bool,
+) (bs blockStore, err error) {
+	switch kind {
+	case "", SQLiteBlockStorage:
+		return &sqliteBlockStore{st: st}, nil
+	case LevelDBBlockStorage:
+		var lbs *leveldbBlockStore
+		if lbs, err = openLevelDBBlockStore(dataFile + ".blocks"); err != nil {
+			return
+		}
+		if newChain && lbs.seq > 0 {
+			_ = lbs.close()
+			return nil, errors.Errorf("block storage %s.blocks is not empty", dataFile)
+		}
+		if err = lbs.migrate(&sqliteBlockStore{st: st}); err != nil {
+			_ = lbs.close()
+			return
+		}
+		return lbs, nil
+	default:
+		return nil, errors.Errorf("unknown block storage: %s", kind)
+	}
+}
+
+// sqliteBlockStore keeps the blocks in the "blocks" table of the chain database, the blocks are
+// written in the same transaction as the chain state.
+type sqliteBlockStore struct {
+	st xi.Storage
+}
+
+func (s *sqliteBlockStore) putBlock(height uint32, b *types.BPBlock) storageProcedure {
+	return addBlock(height, b)
+}
+
+func (s *sqliteBlockStore) getBlock(h hash.Hash) (*types.BPBlock, error) {
+	return loadBlock(s.st, h)
+}
+
+func (s *sqliteBlockStore) scanBlocks(
+	fn func(height uint32, bh, ph hash.Hash, enc []byte) error,
+) (err error) {
+	var (
+		rows *sql.Rows
+
+		height       uint32
+		bnHex, pnHex string
+		enc          []byte
+		bh, ph       hash.Hash
+	)
+	if rows, err = s.st.Reader().Query(
+		`SELECT "height", "hash", "parent", "encoded" FROM "blocks" ORDER BY "rowid"`,
+	); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err = rows.Scan(&height, &bnHex, &pnHex, &enc); err != nil {
+			return
+		}
+		if err = hash.Decode(&bh, bnHex); err != nil {
+			return
+		}
+		if err = hash.Decode(&ph, pnHex); err != nil {
+			return
+		}
+		if err = fn(height, bh, ph, enc); err != nil {
+			return
+		}
+	}
+	return rows.Err()
+}
+
+// close is a no-op, the chain database is closed by the chain.
+func (s *sqliteBlockStore) close() error {
+	return nil
+}
+
+func decodeBlock(enc []byte) (block *types.BPBlock, err error) {
+	var dec = &types.BPBlock{}
+	if err = utils.DecodeMsgPack(enc, dec); err != nil {
+		return
+	}
+	block = dec
+	return
+}
@@ -0,0 +1,170 @@
+package blockproducer
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/binary"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+
+	"sqlit/src/crypto/hash"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+var (
+	// block key: "b" + block hash -> height (4 bytes) + parent hash + encoded block
+	leveldbBlockPrefix = []byte("b")
+	// order key: "o" + sequence (8 bytes) -> block hash
+	leveldbOrderPrefix = []byte("o")
+)
+
+// leveldbBlockStore keeps the blocks in a LevelDB, the blocks are written ahead of the chain
+// state transaction. A block left without the state by a failed transaction is harmless, as it's
+// valid and reloaded as a reversible block.
+type leveldbBlockStore struct {
+	sync.Mutex
+	db  *leveldb.DB
+	seq uint64 // sequence of the last stored block
+}
+
+func openLevelDBBlockStore(path string) (s *leveldbBlockStore, err error) {
+	var db *leveldb.DB
+	if db, err = leveldb.OpenFile(path, &opt.Options{}); err != nil {
+		err = errors.Wrapf(err, "open block storage %s", path)
+		return
+	}
+	s = &leveldbBlockStore{db: db}
+
+	// restore the sequence from the last order key
+	var it = db.NewIterator(util.BytesPrefix(leveldbOrderPrefix), nil)
+	defer it.Release()
+	if it.Last() {
+		s.seq = binary.BigEndian.Uint64(it.Key()[len(leveldbOrderPrefix):])
+	}
+	if err = it.Error(); err != nil {
+		_ = db.Close()
+		s = nil
+	}
+	return
+}
+
+func leveldbBlockKey(h hash.Hash) []byte {
+	return append(append([]byte{}, leveldbBlockPrefix...), h[:]...)
+}
+
+func leveldbOrderKey(seq uint64) []byte {
+	var key = make([]byte, len(leveldbOrderPrefix)+8)
+	copy(key, leveldbOrderPrefix)
+	binary.BigEndian.PutUint64(key[len(leveldbOrderPrefix):], seq)
+	return key
+}
+
+func (s *leveldbBlockStore) put(height uint32, bh, ph hash.Hash, enc []byte) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	var key = leveldbBlockKey(bh)
+	if ok, err := s.db.Has(key, nil); err != nil || ok {
+		return err
+	}
+	var (
+		val   = bytes.NewBuffer(make([]byte, 0, 4+hash.HashSize+len(enc)))
+		batch = new(leveldb.Batch)
+	)
+	_ = binary.Write(val, binary.BigEndian, height)
+	val.Write(ph[:])
+	val.Write(enc)
+	batch.Put(key, val.Bytes())
+	batch.Put(leveldbOrderKey(s.seq+1), bh[:])
+	if err = s.db.Write(batch, &opt.WriteOptions{Sync: true}); err != nil {
+		return
+	}
+	s.seq++
+	return
+}
+
+func (s *leveldbBlockStore) putBlock(height uint32, b *types.BPBlock) storageProcedure {
+	var (
+		enc *bytes.Buffer
+		err error
+	)
+	if enc, err = utils.EncodeMsgPack(b); err != nil {
+		return errPass(err)
+	}
+	return func(_ *sql.Tx) error {
+		return s.put(height, *b.BlockHash(), *b.ParentHash(), enc.Bytes())
+	}
+}
+
+func (s *leveldbBlockStore) get(h hash.Hash) (height uint32, ph hash.Hash, enc []byte, err error) {
+	var val []byte
+	if val, err = s.db.Get(leveldbBlockKey(h), nil); err != nil {
+		return
+	}
+	if len(val) < 4+hash.HashSize {
+		err = errors.Errorf("malformed block %s in storage", h.Short(4))
+		return
+	}
+	height = binary.BigEndian.Uint32(val)
+	copy(ph[:], val[4:4+hash.HashSize])
+	enc = val[4+hash.HashSize:]
+	return
+}
+
+func (s *leveldbBlockStore) getBlock(h hash.Hash) (block *types.BPBlock, err error) {
+	var enc []byte
+	if _, _, enc, err = s.get(h); err != nil {
+		return
+	}
+	return decodeBlock(enc)
+}
+
+func (s *leveldbBlockStore) scanBlocks(
+	fn func(height uint32, bh, ph hash.Hash, enc []byte) error,
+) (err error) {
+	var it = s.db.NewIterator(util.BytesPrefix(leveldbOrderPrefix), nil)
+	defer it.Release()
+	for it.Next() {
+		var (
+			bh     hash.Hash
+			ph     hash.Hash
+			height uint32
+			enc    []byte
+		)
+		if err = bh.SetBytes(it.Value()); err != nil {
+			return
+		}
+		if height, ph, enc, err = s.get(bh); err != nil {
+			return
+		}
+		if err = fn(height, bh, ph, enc); err != nil {
+			return
+		}
+	}
+	return it.Error()
+}
+
+// migrate copies the blocks from src in order if the storage is empty.
+func (s *leveldbBlockStore) migrate(src blockStore) (err error) {
+	if s.seq > 0 {
+		return
+	}
+	if err = src.scanBlocks(func(height uint32, bh, ph hash.Hash, enc []byte) error {
+		return s.put(height, bh, ph, enc)
+	}); err != nil {
+		return errors.Wrap(err, "migrate blocks")
+	}
+	if s.seq > 0 {
+		log.WithField("count", s.seq).Info("migrated blocks to leveldb block storage")
+	}
+	return
+}
+
+func (s *leveldbBlockStore) close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,118 @@
+package blockproducer
+
+import (
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/hash"
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/types"
+	"sqlit/src/types/fixtures"
+)
+
+func newTestingBlock(parent *types.BPBlock) (b *types.BPBlock, err error) {
+	b = &types.BPBlock{
+		SignedHeader: types.BPSignedHeader{
+			BPHeader: types.BPHeader{
+				Timestamp: time.Now().UTC(),
+			},
+		},
+	}
+	if parent != nil {
+		b.SignedHeader.ParentHash = *parent.BlockHash()
+		b.SignedHeader.Timestamp = parent.Timestamp().Add(time.Second)
+	}
+	err = b.SetHash()
+	return
+}
+
+func scannedHashes(bs blockStore) (hs []hash.Hash, err error) {
+	err = bs.scanBlocks(func(_ uint32, bh, _ hash.Hash, _ []byte) error {
+		hs = append(hs, bh)
+		return nil
+	})
+	return
+}
+
+func TestBlockStore(t *testing.T) {
+	Convey("Given a chain database with blocks", t, func() {
+		var (
+			dataFile = path.Join(testingDataDir, t.Name())
+			st       xi.Storage
+			blocks   = make([]*types.BPBlock, 3)
+			err      error
+		)
+		for i := range blocks {
+			var parent *types.BPBlock
+			if i > 0 {
+				parent = blocks[i-1]
+			}
+			blocks[i], err = newTestingBlock(parent)
+			So(err, ShouldBeNil)
+		}
+		// the last block packs all the transaction types to cover their encodings in storage
+		blocks[2] = fixtures.New(1).BPBlock(*blocks[1].BlockHash())
+		st, err = openStorage("file:" + dataFile)
+		So(err, ShouldBeNil)
+		Reset(func() { st.Close() })
+
+		sqlite, err := openBlockStore("", st, dataFile, true)
+		So(err, ShouldBeNil)
+		So(store(st, []storageProcedure{
+			sqlite.putBlock(0, blocks[0]),
+			sqlite.putBlock(1, blocks[1]),
+		}, nil), ShouldBeNil)
+
+		_, err = openBlockStore("unknown", st, dataFile, false)
+		So(err, ShouldNotBeNil)
+
+		Convey("The blocks should be migrated to the leveldb block storage", func() {
+			bs, err := openBlockStore(LevelDBBlockStorage, st, dataFile, false)
+			So(err, ShouldBeNil)
+			hs, err := scannedHashes(bs)
+			So(err, ShouldBeNil)
+			So(hs, ShouldResemble, []hash.Hash{*blocks[0].BlockHash(), *blocks[1].BlockHash()})
+
+			// storing a block again is a no-op
+			So(store(st, []storageProcedure{
+				bs.putBlock(2, blocks[2]),
+				bs.putBlock(1, blocks[1]),
+			}, nil), ShouldBeNil)
+			b, err := bs.getBlock(*blocks[2].BlockHash())
+			So(err, ShouldBeNil)
+			So(b.BlockHash(), ShouldResemble, blocks[2].BlockHash())
+			So(b.Verify(), ShouldBeNil)
+			So(b.Transactions, ShouldHaveLength, len(blocks[2].Transactions))
+			_, err = bs.getBlock(hash.Hash{})
+			So(err, ShouldNotBeNil)
+			So(bs.close(), ShouldBeNil)
+
+			// the stale blocks should not be taken by a new chain
+			_, err = openBlockStore(LevelDBBlockStorage, st, dataFile, true)
+			So(err, ShouldNotBeNil)
+
+			// the order is kept after reopening, and the migration is not repeated
+			bs, err = openBlockStore(LevelDBBlockStorage, st, dataFile, false)
+			So(err, ShouldBeNil)
+			defer bs.close()
+			hs, err = scannedHashes(bs)
+			So(err, ShouldBeNil)
+			So(len(hs), ShouldEqual, 3)
+			So(hs[2], ShouldResemble, *blocks[2].BlockHash())
+
+			irre, heads, err := loadBlocks(bs, *blocks[1].BlockHash())
+			So(err, ShouldBeNil)
+			So(irre.hash, ShouldResemble, *blocks[1].BlockHash())
+			So(len(heads), ShouldEqual, 1)
+			So(heads[0].hash, ShouldResemble, *blocks[2].BlockHash())
+
+			// the sqlite backend is not changed by the leveldb backend
+			hs, err = scannedHashes(sqlite)
+			So(err, ShouldBeNil)
+			So(len(hs), ShouldEqual, 2)
+		})
+	})
+}
@@ -49,14 +49,13 @@ func newBranch(
 		}
 
 		var block = bn.load()
+		// Verify the txs not found in tx pool
+		if err = verifyTxs(block.Transactions, inst.inPool); err != nil {
+			return
+		}
 		for _, v := range block.Transactions {
 			var k = v.Hash()
-			// Check in tx pool
-			if _, ok := inst.unpacked[k]; ok {
-				delete(inst.unpacked, k)
-			} else if err = v.Verify(); err != nil {
-				return
-			}
+			delete(inst.unpacked, k)
 			if _, ok := inst.packed[k]; ok {
 				err = ErrExistedTx
 				return
@@ -113,6 +112,12 @@ func (b *branch) addTx(tx pi.Transaction) {
 	}
 }
 
+// inPool reports whether the tx is in the unpacked tx pool, which is verified on adding.
+func (b *branch) inPool(k hash.Hash) (ok bool) {
+	_, ok = b.unpacked[k]
+	return
+}
+
 func (b *branch) applyBlock(n *blockNode) (br *branch, err error) {
 	var block = n.load()
 	if !b.head.hash.IsEqual(block.ParentHash()) {
@@ -125,14 +130,13 @@ func (b *branch) applyBlock(n *blockNode) (br *branch, err error) {
 		return nil, ErrTooManyTransactionsInBlock
 	}
 
+	// Verify the txs not found in tx pool
+	if err = verifyTxs(block.Transactions, cpy.inPool); err != nil {
+		return
+	}
 	for _, v := range block.Transactions {
 		var k = v.Hash()
-		// Check in tx pool
-		if _, ok := cpy.unpacked[k]; ok {
-			delete(cpy.unpacked, k)
-		} else if err = v.Verify(); err != nil {
-			return
-		}
+		delete(cpy.unpacked, k)
 		if _, ok := cpy.packed[k]; ok {
 			err = ErrExistedTx
 			return
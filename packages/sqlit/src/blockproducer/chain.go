@@ -20,6 +20,7 @@ import (
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/crypto/kms"
+	"sqlit/src/diag"
 	"sqlit/src/proto"
 	"sqlit/src/route"
 	rpc "sqlit/src/rpc/mux"
@@ -53,9 +54,14 @@ type Chain struct {
 
 	// Other components
 	storage xi.Storage
+	blocks  blockStore
 	// NOTE(leventeliu): this LRU object is only used for block cache control,
 	// do NOT read it in any case.
 	blockCache *lru.Cache
+	// database loads reported by the miners
+	loads *loadRegistry
+	// progress of the hole blocks synchronization on startup
+	holeSync *syncProgress
 
 	// Channels for incoming blocks and transactions
 	pendingBlocks    chan *types.BPBlock
@@ -94,6 +100,7 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 		ierr error
 
 		st        xi.Storage
+		bs        blockStore
 		cache     *lru.Cache
 		lastIrre  *blockNode
 		heads     []*blockNode
@@ -133,6 +140,15 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 			st.Close()
 		}
 	}()
+	if bs, ierr = openBlockStore(cfg.BlockStorage, st, cfg.DataFile, !existed); ierr != nil {
+		err = errors.Wrap(ierr, "failed to open block storage")
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = bs.close()
+		}
+	}()
 
 	// Create block cache
 	if cfg.BlockCacheSize > conf.MaxCachedBlock {
@@ -159,7 +175,7 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 			}
 		}
 		var sps = init.compileChanges(nil)
-		sps = append(sps, addBlock(0, cfg.Genesis))
+		sps = append(sps, bs.putBlock(0, cfg.Genesis))
 		sps = append(sps, updateIrreversible(cfg.Genesis.SignedHeader.DataHash))
 		if ierr = store(st, sps, nil); ierr != nil {
 			err = errors.Wrap(ierr, "failed to initialize storage")
@@ -168,7 +184,7 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 	}
 
 	// Load from database
-	if lastIrre, heads, immutable, txPool, ierr = loadDatabase(st); ierr != nil {
+	if lastIrre, heads, immutable, txPool, ierr = loadDatabase(st, bs); ierr != nil {
 		err = errors.Wrap(ierr, "failed to load data from storage")
 		return
 	}
@@ -196,7 +212,7 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 					continue
 				}
 				var block *types.BPBlock
-				if block, ierr = loadBlock(st, r.hash); ierr != nil {
+				if block, ierr = bs.getBlock(r.hash); ierr != nil {
 					err = errors.Wrapf(
 						ierr, "failed to load block %s from database", r.hash.Short(4))
 					return
@@ -265,7 +281,10 @@ func NewChainWithContext(ctx context.Context, cfg *Config) (c *Chain, err error)
 		caller: rpc.NewCaller(),
 
 		storage:    st,
+		blocks:     bs,
 		blockCache: cache,
+		loads:      newLoadRegistry(),
+		holeSync:   newSyncProgress(),
 
 		pendingBlocks:    make(chan *types.BPBlock),
 		pendingAddTxReqs: make(chan *types.AddTxReq),
@@ -314,13 +333,17 @@ func (c *Chain) Start() {
 	// Start blocks/txs processing goroutines
 	c.goFunc(c.processBlocks)
 	c.goFunc(c.processTxs)
-	// Synchronize heads to current block period
+	// Serve the synchronized blocks and states while synchronizing heads to current block period
+	diag.Register(diagChain, c.writeDiag)
+	diag.RegisterHealth(diagHealthSync, c.holeSync.check)
+	c.holeSync.begin(c.getNextHeight())
+	c.startService(c)
 	c.syncHeads()
+	c.holeSync.finish()
 	// TODO(leventeliu): subscribe ChainBus.
 	// ...
-	// Start main cycle and service
+	// Start main cycle
 	c.goFunc(c.mainCycle)
-	c.startService(c)
 }
 
 // Stop stops the main process of the sql-chain.
@@ -330,8 +353,13 @@ func (c *Chain) Stop() (err error) {
 		"local": c.getLocalBPInfo(),
 	})
 	le.Debug("stopping chain")
+	diag.Unregister(diagChain)
+	diag.UnregisterHealth(diagHealthSync)
 	c.stop()
 	le.Debug("chain service stopped")
+	if cerr := c.blocks.close(); cerr != nil {
+		le.WithError(cerr).Error("failed to close block storage")
+	}
 	c.storage.Close()
 	le.Debug("chain database closed")
 
@@ -436,18 +464,17 @@ func (c *Chain) syncHeads() {
 		if nowHeight = c.heightOfTime(c.now()); c.getNextHeight() > nowHeight {
 			break
 		}
-		for c.getNextHeight() <= nowHeight {
-			// TODO(leventeliu): use the test mode flag to bypass the long-running synchronizing
-			// on startup by now, need better solution here.
-			if conf.GConf.StartupSyncHoles {
-				log.WithFields(log.Fields{
-					"local":       c.getLocalBPInfo(),
-					"next_height": c.getNextHeight(),
-					"now_height":  nowHeight,
-				}).Debug("synchronizing head blocks")
-				c.blockingSyncCurrentHead(c.ctx, conf.BPStartupRequiredReachableCount)
+		// TODO(leventeliu): use the test mode flag to bypass the long-running synchronizing
+		// on startup by now, need better solution here.
+		if !conf.GConf.StartupSyncHoles {
+			for c.getNextHeight() <= nowHeight {
+				c.increaseNextHeight()
 			}
-			c.increaseNextHeight()
+			continue
+		}
+		if err := c.syncHoles(c.ctx, nowHeight, conf.BPStartupRequiredReachableCount); err != nil {
+			log.WithError(err).Info("abort synchronizing head blocks")
+			return
 		}
 	}
 }
@@ -521,6 +548,10 @@ func (c *Chain) processAddTxReq(addTxReq *types.AddTxReq) {
 		le.WithError(err).Warn("failed to verify transaction")
 		return
 	}
+	if err = checkTxFeature(tx, c.getNextHeight()); err != nil {
+		le.WithError(err).Warn("transaction feature is not activated")
+		return
+	}
 	if base, err = c.immutableNextNonce(addr); err != nil {
 		le.WithError(err).Warn("failed to load base nonce of transaction account")
 		return
@@ -539,7 +570,17 @@ func (c *Chain) processAddTxReq(addTxReq *types.AddTxReq) {
 		ttl = conf.MaxTxBroadcastTTL
 	}
 	if ttl > 0 {
-		c.nonblockingBroadcastTx(ttl-1, tx)
+		c.nonblockingBroadcastTx(ttl-1, tx, addTxReq.Replaces)
+	}
+
+	// Replace the pending transaction
+	if addTxReq.Replaces != nil {
+		if err = c.replaceTx(*addTxReq.Replaces, tx); err != nil {
+			le.WithError(err).Warn("failed to replace transaction")
+			return
+		}
+		expvar.Get(mwKeyTxPooled).(mw.Metric).Add(1)
+		return
 	}
 
 	// Add to tx pool
@@ -636,11 +677,21 @@ func (c *Chain) syncCurrentHead(ctx context.Context, requiredReachable uint32) (
 
 	// Initiate blocking gossip calls to fetch block of the current height,
 	// with timeout of one tick.
-	var (
-		unreachable = c.blockingFetchBlock(ctx, currentHeight)
-		serversNum  = c.getLocalBPInfo().total
-	)
+	var unreachable = c.blockingFetchBlock(ctx, currentHeight)
+	if ok = c.enoughReachable(unreachable, requiredReachable); !ok {
+		log.WithFields(log.Fields{
+			"peer":              c.getLocalBPInfo(),
+			"sync_head_height":  currentHeight,
+			"unreachable_count": unreachable,
+		}).Warn("one or more block producers are currently unreachable")
+	}
+	return
+}
 
+// enoughReachable returns whether the block producers are enough reachable to synchronize a
+// block with unreachable ones.
+func (c *Chain) enoughReachable(unreachable, requiredReachable uint32) (ok bool) {
+	var serversNum = c.getLocalBPInfo().total
 	switch c.mode {
 	case BPMode:
 		ok = unreachable+requiredReachable <= serversNum
@@ -650,14 +701,6 @@ func (c *Chain) syncCurrentHead(ctx context.Context, requiredReachable uint32) (
 		ok = false
 		log.Fatalf("unknown run mode: %v", c.mode)
 	}
-
-	if !ok {
-		log.WithFields(log.Fields{
-			"peer":              c.getLocalBPInfo(),
-			"sync_head_height":  currentHeight,
-			"unreachable_count": unreachable,
-		}).Warn("one or more block producers are currently unreachable")
-	}
 	return
 }
 
@@ -678,6 +721,51 @@ func (c *Chain) storeTx(tx pi.Transaction) (err error) {
 	})
 }
 
+// checkReplaceTx checks whether the pending transaction old can be replaced by tx, the caller
+// should hold the read lock of the chain.
+func (c *Chain) checkReplaceTx(old hash.Hash, tx pi.Transaction) (prev pi.Transaction, err error) {
+	var ok bool
+	if prev, ok = c.txPool[old]; !ok {
+		err = errors.Wrapf(ErrTxNotFound, "replace %s", old.Short(4))
+		return
+	}
+	if _, ok = c.txPool[tx.Hash()]; ok {
+		err = ErrExistedTx
+		return
+	}
+	if prev.GetAccountAddress() != tx.GetAccountAddress() ||
+		prev.GetAccountNonce() != tx.GetAccountNonce() {
+		err = errors.Wrapf(ErrInvalidReplacement, "replace %s", old.Short(4))
+		return
+	}
+	if state, _ := c.headBranch.queryTxState(old); state == pi.TransactionStatePacked {
+		err = errors.Wrapf(ErrTxAlreadyPacked, "replace %s", old.Short(4))
+		return
+	}
+	return
+}
+
+// replaceTx replaces the pending transaction old with tx of the same account nonce.
+func (c *Chain) replaceTx(old hash.Hash, tx pi.Transaction) (err error) {
+	var prev pi.Transaction
+	c.Lock()
+	defer c.Unlock()
+	if prev, err = c.checkReplaceTx(old, tx); err != nil {
+		return
+	}
+
+	return store(c.storage, []storageProcedure{
+		deleteTxs([]pi.Transaction{prev}), addTx(tx),
+	}, func() {
+		delete(c.txPool, old)
+		c.txPool[tx.Hash()] = tx
+		for _, v := range c.branches {
+			v.clearUnpackedTxs([]pi.Transaction{prev})
+			v.addTx(tx)
+		}
+	})
+}
+
 func (c *Chain) replaceAndSwitchToBranch(
 	newBlock *types.BPBlock, originBrIdx int, newBranch *branch) (err error,
 ) {
@@ -735,7 +823,7 @@ func (c *Chain) replaceAndSwitchToBranch(
 
 	// Prepare storage procedures to update immutable database
 	sps = c.immutable.compileChanges(sps)
-	sps = append(sps, addBlock(height, newBlock))
+	sps = append(sps, c.blocks.putBlock(height, newBlock))
 	sps = append(sps, buildBlockIndex(height, newBlock))
 	for _, n := range newIrres {
 		sps = append(sps, deleteTxs(n.load().Transactions))
@@ -851,7 +939,7 @@ func (c *Chain) applyBlock(bl *types.BPBlock) (err error) {
 			// Grow a branch while the current branch is not changed
 			if br.head.count <= c.headBranch.head.count {
 				return store(c.storage,
-					[]storageProcedure{addBlock(height, bl)},
+					[]storageProcedure{c.blocks.putBlock(height, bl)},
 					func() {
 						br.preview.commit()
 						c.branches[i] = br
@@ -881,7 +969,7 @@ func (c *Chain) applyBlock(bl *types.BPBlock) (err error) {
 				return
 			}
 			return store(c.storage,
-				[]storageProcedure{addBlock(height, bl)},
+				[]storageProcedure{c.blocks.putBlock(height, bl)},
 				func() { c.branches = append(c.branches, br) },
 			)
 		}
@@ -928,6 +1016,12 @@ func (c *Chain) now() time.Time {
 	return time.Now().Add(c.offset).UTC()
 }
 
+// RPCService returns a main chain RPC service over the chain, for the gateways serving the RPC
+// methods in other protocols.
+func (c *Chain) RPCService() *ChainRPCService {
+	return &ChainRPCService{chain: c}
+}
+
 func (c *Chain) startService(chain *Chain) {
 	c.server.RegisterService(route.BlockProducerRPCName, &ChainRPCService{chain: chain})
 }
@@ -7,6 +7,7 @@ import (
 	"sync/atomic"
 
 	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/hash"
 	"sqlit/src/proto"
 	"sqlit/src/route"
 	"sqlit/src/types"
@@ -39,7 +40,7 @@ func (c *Chain) nonblockingBroadcastBlock(block *types.BPBlock) {
 	}
 }
 
-func (c *Chain) nonblockingBroadcastTx(ttl uint32, tx pi.Transaction) {
+func (c *Chain) nonblockingBroadcastTx(ttl uint32, tx pi.Transaction, replaces *hash.Hash) {
 	for _, info := range c.getRemoteBPInfos() {
 		func(remote *blockProducerInfo) {
 			c.goFuncWithTimeout(func(ctx context.Context) {
@@ -48,8 +49,9 @@ func (c *Chain) nonblockingBroadcastTx(ttl uint32, tx pi.Transaction) {
 						Envelope: proto.Envelope{
 							// TODO(lambda): Add fields.
 						},
-						TTL: ttl,
-						Tx:  tx,
+						TTL:      ttl,
+						Tx:       tx,
+						Replaces: replaces,
 					}
 					err = c.caller.CallNodeWithContext(
 						ctx, remote.nodeID, route.MCCAddTx.String(), req, nil)
@@ -67,14 +69,34 @@ func (c *Chain) nonblockingBroadcastTx(ttl uint32, tx pi.Transaction) {
 }
 
 func (c *Chain) blockingFetchBlock(ctx context.Context, h uint32) (unreachable uint32) {
+	var blocks []*types.BPBlock
+	blocks, unreachable = c.fetchBlocks(ctx, h)
+	c.pushPendingBlocks(ctx, blocks)
+	return
+}
+
+// pushPendingBlocks adds the fetched blocks to the pending blocks in order.
+func (c *Chain) pushPendingBlocks(ctx context.Context, blocks []*types.BPBlock) {
+	for _, b := range blocks {
+		select {
+		case c.pendingBlocks <- b:
+		case <-ctx.Done():
+			log.WithError(ctx.Err()).Warn("add pending block aborted")
+			return
+		}
+	}
+}
+
+// fetchBlocks fetches the blocks at height h from the remote peers with timeout of one tick, the
+// distinct blocks replied by the peers are returned with the count of the unreachable peers.
+func (c *Chain) fetchBlocks(ctx context.Context, h uint32) (blocks []*types.BPBlock, unreachable uint32) {
 	var (
 		cld, ccl = context.WithTimeout(ctx, c.tick)
 		wg       = &sync.WaitGroup{}
+		lock     sync.Mutex
+		seen     = make(map[hash.Hash]bool)
 	)
-	defer func() {
-		wg.Wait()
-		ccl()
-	}()
+	defer ccl()
 	for _, info := range c.getRemoteBPInfos() {
 		wg.Add(1)
 		go func(remote *blockProducerInfo) {
@@ -105,17 +127,18 @@ func (c *Chain) blockingFetchBlock(ctx context.Context, h uint32) (unreachable u
 				le.Debug("fetch block request reply: no such block")
 				return
 			}
-			// Push new block from other peers
 			le.WithFields(log.Fields{
 				"parent": resp.Block.ParentHash().Short(4),
 				"hash":   resp.Block.BlockHash().Short(4),
 			}).Debug("fetch block request reply: found block")
-			select {
-			case c.pendingBlocks <- resp.Block:
-			case <-cld.Done():
-				log.WithError(cld.Err()).Warn("add pending block aborted")
+			lock.Lock()
+			defer lock.Unlock()
+			if k := resp.Block.BlockHash(); !seen[*k] {
+				seen[*k] = true
+				blocks = append(blocks, resp.Block)
 			}
 		}(info)
 	}
+	wg.Wait()
 	return
 }
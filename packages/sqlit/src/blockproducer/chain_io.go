@@ -2,7 +2,11 @@
 package blockproducer
 
 import (
+	"bytes"
 	"database/sql"
+	"encoding/json"
+	"sort"
+	"time"
 
 	pi "sqlit/src/blockproducer/interfaces"
 	"sqlit/src/crypto/hash"
@@ -15,7 +19,7 @@ import (
 
 // loadBlock loads a BPBlock from chain storage.
 func (c *Chain) loadBlock(h hash.Hash) (b *types.BPBlock, err error) {
-	return loadBlock(c.storage, h)
+	return c.blocks.getBlock(h)
 }
 
 func (c *Chain) fetchLastIrreversibleBlock() (
@@ -122,6 +126,119 @@ func (c *Chain) queryTxState(hash hash.Hash) (state pi.TransactionState, err err
 	return pi.TransactionStateNotFound, nil
 }
 
+func (c *Chain) queryAccountPendingTxs(addr proto.AccountAddress) (txs []*types.PendingTx) {
+	c.RLock()
+	defer c.RUnlock()
+	for k, v := range c.txPool {
+		if v.GetAccountAddress() != addr {
+			continue
+		}
+		state, ok := c.headBranch.queryTxState(k)
+		if !ok {
+			// packed in another branch only, still pending on the head branch
+			state = pi.TransactionStatePending
+		}
+		txs = append(txs, &types.PendingTx{Tx: v, State: state})
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if ni, nj := txs[i].Tx.GetAccountNonce(), txs[j].Tx.GetAccountNonce(); ni != nj {
+			return ni < nj
+		}
+		return bytes.Compare(txs[i].Tx.Hash().AsBytes(), txs[j].Tx.Hash().AsBytes()) < 0
+	})
+	return
+}
+
+// indexedTxNonce decodes the nonce of an indexed transaction from its json encoding, the nonce is
+// 0 if the transaction type has no nonce field.
+func indexedTxNonce(raw string) pi.AccountNonce {
+	var v struct{ Nonce pi.AccountNonce }
+	_ = json.Unmarshal([]byte(raw), &v)
+	return v.Nonce
+}
+
+// queryAccountHistory returns the transactions of account addr: the ones packed into the blocks
+// of the given page starting from 1 with the count of all of them, and the pending ones which are not packed into
+// the head branch yet.
+func (c *Chain) queryAccountHistory(addr proto.AccountAddress, page, size uint32) (
+	pending, txs []*types.AccountTx, total uint32, err error,
+) {
+	c.RLock()
+	defer c.RUnlock()
+	if page == 1 {
+		for k, v := range c.txPool {
+			if v.GetAccountAddress() != addr {
+				continue
+			}
+			state, ok := c.headBranch.queryTxState(k)
+			if state == pi.TransactionStatePacked {
+				// indexed with the head block
+				continue
+			} else if !ok {
+				// packed in another branch only, still pending on the head branch
+				state = pi.TransactionStatePending
+			}
+			pending = append(pending, &types.AccountTx{
+				Hash:      k,
+				Type:      v.GetTransactionType(),
+				Nonce:     v.GetAccountNonce(),
+				Timestamp: v.GetTimestamp(),
+				State:     state,
+			})
+		}
+		sort.Slice(pending, func(i, j int) bool {
+			if ni, nj := pending[i].Nonce, pending[j].Nonce; ni != nj {
+				return ni < nj
+			}
+			return bytes.Compare(pending[i].Hash.AsBytes(), pending[j].Hash.AsBytes()) < 0
+		})
+	}
+
+	var (
+		countSQL = `SELECT COUNT(*) FROM "indexed_transactions" WHERE "address" = ?`
+		querySQL = `SELECT "block_height", "hash", "timestamp", "tx_type", "raw"
+	FROM "indexed_transactions" WHERE "address" = ?
+	ORDER BY "block_height" DESC, "tx_index" DESC LIMIT ? OFFSET ?`
+		rows *sql.Rows
+	)
+	if err = c.storage.Reader().QueryRow(countSQL, addr.String()).Scan(&total); err != nil {
+		return
+	}
+	if rows, err = c.storage.Reader().Query(
+		querySQL, addr.String(), size, uint64(page-1)*uint64(size),
+	); err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			height uint32
+			h, raw string
+			ts     int64
+			tt     uint32
+			tx     = &types.AccountTx{}
+		)
+		if err = rows.Scan(&height, &h, &ts, &tt, &raw); err != nil {
+			return
+		}
+		if err = hash.Decode(&tx.Hash, h); err != nil {
+			return
+		}
+		tx.Type = pi.TransactionType(tt)
+		tx.Nonce = indexedTxNonce(raw)
+		tx.Timestamp = time.Unix(0, ts).UTC()
+		tx.Height = height
+		if state, ok := c.headBranch.queryTxState(tx.Hash); ok {
+			tx.State = state
+		} else {
+			tx.State = pi.TransactionStateConfirmed
+		}
+		txs = append(txs, tx)
+	}
+	err = rows.Err()
+	return
+}
+
 func (c *Chain) queryAccountSQLChainProfiles(account proto.AccountAddress) (profiles []*types.SQLChainProfile, err error) {
 	var dbs []proto.DatabaseID
 
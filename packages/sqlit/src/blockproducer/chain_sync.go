@@ -0,0 +1,221 @@
+package blockproducer
+
+import (
+	"context"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultStartupSyncConcurrency defines the default max count of the hole heights
+	// synchronized concurrently on startup.
+	DefaultStartupSyncConcurrency = 8
+
+	mwKeySync       = "service:bp:sync"
+	diagHealthSync  = "bp_sync"
+	syncLogInterval = 10 * time.Second
+)
+
+// syncProgress tracks the synchronization of the hole blocks on startup, the heights from from
+// to next-1 are synchronized and the ones from next to to are being synchronized.
+type syncProgress struct {
+	syncing uint32
+	start   int64
+	from    uint32
+	next    uint32
+	to      uint32
+	vars    *expvar.Map
+}
+
+func newSyncProgress() (p *syncProgress) {
+	p = &syncProgress{}
+	// NOTE: the chain is a singleton, see mwKeyHeight
+	if v, ok := expvar.Get(mwKeySync).(*expvar.Map); ok {
+		p.vars = v
+	} else {
+		p.vars = expvar.NewMap(mwKeySync)
+	}
+	return
+}
+
+func (p *syncProgress) setVar(key string, v uint32) {
+	var i = new(expvar.Int)
+	i.Set(int64(v))
+	p.vars.Set(key, i)
+}
+
+// begin marks the chain synchronizing from height next.
+func (p *syncProgress) begin(next uint32) {
+	atomic.StoreInt64(&p.start, time.Now().UnixNano())
+	atomic.StoreUint32(&p.from, next)
+	atomic.StoreUint32(&p.next, next)
+	atomic.StoreUint32(&p.to, next)
+	atomic.StoreUint32(&p.syncing, 1)
+	p.setVar("syncing", 1)
+	p.setVar("from", next)
+	p.setVar("next", next)
+}
+
+// target sets the height to synchronize to.
+func (p *syncProgress) target(to uint32) {
+	atomic.StoreUint32(&p.to, to)
+	p.setVar("to", to)
+}
+
+// advance marks the heights before next synchronized.
+func (p *syncProgress) advance(next uint32) {
+	atomic.StoreUint32(&p.next, next)
+	p.setVar("next", next)
+}
+
+// finish marks the synchronization finished.
+func (p *syncProgress) finish() {
+	atomic.StoreUint32(&p.syncing, 0)
+	p.setVar("syncing", 0)
+	log.WithFields(log.Fields{
+		"synced":  p.synced(),
+		"elapsed": p.elapsed().String(),
+	}).Info("synchronized head blocks")
+}
+
+func (p *syncProgress) isSyncing() bool {
+	return atomic.LoadUint32(&p.syncing) == 1
+}
+
+func (p *syncProgress) synced() uint32 {
+	return atomic.LoadUint32(&p.next) - atomic.LoadUint32(&p.from)
+}
+
+func (p *syncProgress) total() uint32 {
+	return atomic.LoadUint32(&p.to) + 1 - atomic.LoadUint32(&p.from)
+}
+
+func (p *syncProgress) elapsed() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&p.start)))
+}
+
+// check returns ErrChainSyncing with the progress if the chain is synchronizing.
+func (p *syncProgress) check() error {
+	if !p.isSyncing() {
+		return nil
+	}
+	return errors.Wrapf(ErrChainSyncing, "%d/%d heights synchronized in %s",
+		p.synced(), p.total(), p.elapsed().Round(time.Second))
+}
+
+// checkSynced returns ErrChainSyncing if the block at height h may be not synchronized yet.
+func (c *Chain) checkSynced(h uint32) error {
+	if c.holeSync.isSyncing() && h >= c.getNextHeight() {
+		return c.holeSync.check()
+	}
+	return nil
+}
+
+// syncHoles synchronizes the blocks from the next height to height to. The heights are fetched
+// from the remote peers concurrently, and the fetched blocks are pushed in the order of heights.
+func (c *Chain) syncHoles(ctx context.Context, to uint32, requiredReachable uint32) (err error) {
+	var (
+		from        = c.getNextHeight()
+		concurrency = conf.GConf.StartupSyncConcurrency
+	)
+	if from > to {
+		return
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultStartupSyncConcurrency
+	}
+	c.holeSync.target(to)
+
+	var (
+		cld, ccl = context.WithCancel(ctx)
+		sem      = make(chan struct{}, concurrency)
+		// the fetched blocks waiting for the lower heights are bounded by the buffer
+		pending = make(chan chan []*types.BPBlock, 4*concurrency)
+		lastLog = time.Now()
+	)
+	defer ccl()
+	go func() {
+		defer close(pending)
+		for h := from; h <= to; h++ {
+			var ch = make(chan []*types.BPBlock, 1)
+			select {
+			case sem <- struct{}{}:
+			case <-cld.Done():
+				return
+			}
+			select {
+			case pending <- ch:
+			case <-cld.Done():
+				return
+			}
+			go func(h uint32) {
+				defer func() { <-sem }()
+				if blocks, err := c.blockingFetchHole(cld, h, requiredReachable); err != nil {
+					close(ch)
+				} else {
+					ch <- blocks
+				}
+			}(h)
+		}
+	}()
+
+	for ch := range pending {
+		blocks, ok := <-ch
+		if !ok {
+			break
+		}
+		c.pushPendingBlocks(cld, blocks)
+		c.increaseNextHeight()
+		c.holeSync.advance(c.getNextHeight())
+		if time.Since(lastLog) >= syncLogInterval {
+			lastLog = time.Now()
+			var synced, total, elapsed = c.holeSync.synced(), c.holeSync.total(), c.holeSync.elapsed()
+			log.WithFields(log.Fields{
+				"local":       c.getLocalBPInfo(),
+				"synced":      synced,
+				"total":       total,
+				"next_height": c.getNextHeight(),
+				"rate":        float64(synced) / elapsed.Seconds(),
+			}).Info("synchronizing head blocks")
+		}
+	}
+	return ctx.Err()
+}
+
+// blockingFetchHole fetches the blocks at height h until enough peers are reachable, nothing is
+// fetched if the height is already synchronized.
+func (c *Chain) blockingFetchHole(
+	ctx context.Context, h, requiredReachable uint32) (blocks []*types.BPBlock, err error,
+) {
+	if c.head().height >= h {
+		return
+	}
+	var interval = 1 * time.Second
+	if c.tick < interval {
+		interval = c.tick
+	}
+	for {
+		var unreachable uint32
+		if blocks, unreachable = c.fetchBlocks(ctx, h); c.enoughReachable(unreachable, requiredReachable) {
+			return
+		}
+		log.WithFields(log.Fields{
+			"peer":              c.getLocalBPInfo(),
+			"sync_hole_height":  h,
+			"unreachable_count": unreachable,
+		}).Warn("one or more block producers are currently unreachable")
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package blockproducer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSyncProgress(t *testing.T) {
+	Convey("Given a startup synchronization progress", t, func() {
+		var p = newSyncProgress()
+		So(p.check(), ShouldBeNil)
+		So(newSyncProgress().vars, ShouldEqual, p.vars)
+
+		Convey("The progress should be reported while synchronizing", func() {
+			p.begin(10)
+			p.target(29)
+			p.advance(15)
+			So(p.synced(), ShouldEqual, 5)
+			So(p.total(), ShouldEqual, 20)
+			var err = p.check()
+			So(errors.Cause(err), ShouldEqual, ErrChainSyncing)
+			So(err.Error(), ShouldContainSubstring, "5/20")
+			So(p.vars.Get("next").String(), ShouldEqual, "15")
+
+			p.finish()
+			So(p.check(), ShouldBeNil)
+			So(p.vars.Get("syncing").String(), ShouldEqual, "0")
+		})
+	})
+}
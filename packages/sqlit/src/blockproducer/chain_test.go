@@ -309,6 +309,43 @@ func TestChain(t *testing.T) {
 			So(po2 == po1, ShouldBeFalse)
 		})
 
+		Convey("When a pending transaction is replaced", func() {
+			var (
+				nonce      pi.AccountNonce
+				t1, t2, t3 pi.Transaction
+				txs        []*types.PendingTx
+			)
+			nonce, err = chain.nextNonce(addr1)
+			So(err, ShouldBeNil)
+			t1, err = newTransaction(nonce, priv1, addr1)
+			So(err, ShouldBeNil)
+			t2, err = newProvideService(nonce, priv1, addr1)
+			So(err, ShouldBeNil)
+			t3, err = newProvideService(nonce+1, priv1, addr1)
+			So(err, ShouldBeNil)
+
+			err = chain.storeTx(t1)
+			So(err, ShouldBeNil)
+			err = chain.replaceTx(t1.Hash(), t3)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidReplacement)
+			err = chain.replaceTx(t2.Hash(), t1)
+			So(errors.Cause(err), ShouldEqual, ErrTxNotFound)
+			err = chain.replaceTx(t1.Hash(), t2)
+			So(err, ShouldBeNil)
+
+			txs = chain.queryAccountPendingTxs(addr1)
+			So(len(txs), ShouldEqual, 1)
+			So(txs[0].Tx.Hash(), ShouldResemble, t2.Hash())
+			So(txs[0].State, ShouldEqual, pi.TransactionStatePending)
+
+			err = chain.produceBlock(begin.Add(chain.period).UTC())
+			So(err, ShouldBeNil)
+			txs = chain.queryAccountPendingTxs(addr1)
+			So(len(txs), ShouldEqual, 1)
+			So(txs[0].State, ShouldEqual, pi.TransactionStatePacked)
+			err = chain.replaceTx(t2.Hash(), t1)
+			So(errors.Cause(err), ShouldEqual, ErrTxAlreadyPacked)
+		})
 		Convey("When transfer transactions are added", func() {
 			var (
 				nonce          pi.AccountNonce
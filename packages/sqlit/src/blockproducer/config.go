@@ -27,6 +27,8 @@ type Config struct {
 	Genesis *types.BPBlock
 
 	DataFile string
+	// BlockStorage is the block storage backend, empty means SQLiteBlockStorage
+	BlockStorage string
 
 	Server *rpc.Server
 
@@ -0,0 +1,41 @@
+package blockproducer
+
+import (
+	"io"
+
+	"sqlit/src/diag"
+)
+
+const diagChain = "chain.json"
+
+// chainState is the state of the main chain in the diagnostic bundles.
+type chainState struct {
+	NextHeight         uint32
+	HeadHeight         uint32
+	HeadCount          uint32
+	HeadHash           string
+	IrreversibleHeight uint32
+	IrreversibleHash   string
+	Branches           int
+	PooledTxs          int
+}
+
+// writeDiag writes the heights of the chain heads and the pending state of the chain.
+func (c *Chain) writeDiag(w io.Writer) error {
+	c.RLock()
+	var (
+		head  = c.headBranch.head
+		state = chainState{
+			NextHeight:         c.nextHeight,
+			HeadHeight:         head.height,
+			HeadCount:          head.count,
+			HeadHash:           head.hash.String(),
+			IrreversibleHeight: c.lastIrre.height,
+			IrreversibleHash:   c.lastIrre.hash.String(),
+			Branches:           len(c.branches),
+			PooledTxs:          len(c.txPool),
+		}
+	)
+	c.RUnlock()
+	return diag.WriteJSON(w, state)
+}
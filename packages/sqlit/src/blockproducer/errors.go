@@ -6,6 +6,9 @@ import "errors"
 var (
 	// ErrNoSuchDatabase defines database meta not exists error.
 	ErrNoSuchDatabase = errors.New("no such database")
+	// ErrChainSyncing indicates that the chain is synchronizing the blocks on startup, the
+	// requests depending on the head state are refused.
+	ErrChainSyncing = errors.New("chain is synchronizing")
 	// ErrParentNotFound defines that the parent block cannot be found.
 	ErrParentNotFound = errors.New("previous block cannot be found")
 	// ErrInvalidHash defines invalid hash error.
@@ -37,6 +40,9 @@ var (
 	// ErrUnknownTransactionType indicates that a transaction has a unknown type and cannot be
 	// further processed.
 	ErrUnknownTransactionType = errors.New("unknown transaction type")
+	// ErrInactiveFeature indicates that a transaction requires a protocol feature which is not
+	// activated at the current height.
+	ErrInactiveFeature = errors.New("inactive protocol feature")
 	// ErrInvalidSender indicates that tx.Signee != tx.Sender.
 	ErrInvalidSender = errors.New("invalid sender")
 	// ErrInvalidRange indicates that the billing range is invalid.
@@ -70,6 +76,24 @@ var (
 	ErrLocalNodeNotFound = errors.New("local node id not found in peer list")
 	// ErrNoAvailableBranch indicates that there is no available branch from the state storage.
 	ErrNoAvailableBranch = errors.New("no available branch from state storage")
+	// ErrTxNotFound indicates that the transaction is not found in the transaction pool.
+	ErrTxNotFound = errors.New("transaction not found in pool")
+	// ErrTxAlreadyPacked indicates that the transaction is already packed into the head block
+	// and cannot be replaced.
+	ErrTxAlreadyPacked = errors.New("transaction already packed")
+	// ErrInvalidReplacement indicates that the replacing transaction is not from the same account
+	// or with a different account nonce.
+	ErrInvalidReplacement = errors.New("invalid replacement transaction")
+	// ErrInvalidCloneSource indicates that the source database cannot be cloned.
+	ErrInvalidCloneSource = errors.New("invalid clone source database")
+	// ErrInvalidMinerReplacement indicates that the miner cannot be replaced by or added as the
+	// standby miner.
+	ErrInvalidMinerReplacement = errors.New("invalid miner replacement")
+	// ErrInvalidDatabaseExpansion indicates that the miners cannot be added to the database.
+	ErrInvalidDatabaseExpansion = errors.New("invalid database expansion")
+	// ErrPlacementUnsatisfied indicates that the miners cannot satisfy the placement constraints of
+	// the database.
+	ErrPlacementUnsatisfied = errors.New("placement constraints unsatisfied")
 	// ErrWrongTokenType indicates that token type in transfer is wrong.
 	ErrWrongTokenType = errors.New("wrong token type")
 )
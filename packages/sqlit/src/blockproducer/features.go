@@ -0,0 +1,45 @@
+package blockproducer
+
+import (
+	"github.com/pkg/errors"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+// txFeatures maps the transaction types to the protocol features they require, transaction types
+// absent here are always accepted.
+var txFeatures = map[pi.TransactionType]conf.Feature{
+	pi.TransactionTypeUpdatePermission: conf.FeatureUpdatePermission,
+	pi.TransactionTypeIssueKeys:        conf.FeatureIssueKeys,
+	pi.TransactionTypeReplaceMiner:     conf.FeatureReplaceMiner,
+	pi.TransactionTypeExpandDatabase:   conf.FeatureExpandDatabase,
+}
+
+// checkTxFeature checks whether the features required by tx are activated at the given height.
+func checkTxFeature(tx pi.Transaction, height uint32) (err error) {
+	if w, ok := tx.(*pi.TransactionWrapper); ok {
+		tx = w.Unwrap()
+	}
+	var ttype = tx.GetTransactionType()
+	if f, ok := txFeatures[ttype]; ok && !conf.IsFeatureActive(f, height) {
+		return errors.Wrapf(ErrInactiveFeature, "%s requires feature %s at height %d", ttype, f, height)
+	}
+	if t, ok := tx.(*types.CreateDatabase); ok && t.ResourceMeta.Source != "" &&
+		!conf.IsFeatureActive(conf.FeatureCloneDatabase, height) {
+		return errors.Wrapf(ErrInactiveFeature, "clone requires feature %s at height %d",
+			conf.FeatureCloneDatabase, height)
+	}
+	if t, ok := tx.(*types.CreateDatabase); ok &&
+		t.ResourceMeta.Durability != types.NormalDurability &&
+		!conf.IsFeatureActive(conf.FeatureDurabilityLevel, height) {
+		return errors.Wrapf(ErrInactiveFeature, "durability level requires feature %s at height %d",
+			conf.FeatureDurabilityLevel, height)
+	}
+	if isPlacementTx(tx) && !conf.IsFeatureActive(conf.FeatureReplicaPlacement, height) {
+		return errors.Wrapf(ErrInactiveFeature, "placement requires feature %s at height %d",
+			conf.FeatureReplicaPlacement, height)
+	}
+	return
+}
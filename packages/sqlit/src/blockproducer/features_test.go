@@ -0,0 +1,65 @@
+package blockproducer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+func TestCheckTxFeature(t *testing.T) {
+	Convey("Given a feature scheduled at height 100", t, func() {
+		var origin = conf.GConf
+		conf.GConf = &conf.Config{
+			FeatureActivations: map[conf.Feature]uint32{conf.FeatureIssueKeys: 100},
+		}
+		defer func() { conf.GConf = origin }()
+
+		Convey("The transaction should be rejected before activation", func() {
+			var tx = types.NewIssueKeys(&types.IssueKeysHeader{})
+			err := checkTxFeature(tx, 99)
+			So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+			So(checkTxFeature(tx, 100), ShouldBeNil)
+		})
+		Convey("The transaction without required feature should always be accepted", func() {
+			So(checkTxFeature(types.NewCreateDatabase(&types.CreateDatabaseHeader{}), 0), ShouldBeNil)
+			So(checkTxFeature(types.NewUpdatePermission(&types.UpdatePermissionHeader{}), 0), ShouldBeNil)
+		})
+		Convey("The clone should be rejected until the feature is scheduled", func() {
+			var tx = types.NewCreateDatabase(&types.CreateDatabaseHeader{
+				ResourceMeta: types.ResourceMeta{Source: "source"},
+			})
+			err := checkTxFeature(tx, 0)
+			So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+			conf.GConf.FeatureActivations[conf.FeatureCloneDatabase] = 10
+			So(checkTxFeature(tx, 10), ShouldBeNil)
+			So(checkTxFeature(pi.WrapTransaction(tx), 9), ShouldNotBeNil)
+		})
+		Convey("The placement should be rejected until the feature is scheduled", func() {
+			var (
+				ps = types.NewProvideService(&types.ProvideServiceHeader{Region: "eu-west"})
+				cd = types.NewCreateDatabase(&types.CreateDatabaseHeader{
+					ResourceMeta: types.ResourceMeta{Placement: types.PlacementMeta{MinRegions: 2}},
+				})
+			)
+			So(errors.Cause(checkTxFeature(ps, 0)), ShouldEqual, ErrInactiveFeature)
+			So(errors.Cause(checkTxFeature(cd, 0)), ShouldEqual, ErrInactiveFeature)
+			So(checkTxFeature(types.NewProvideService(&types.ProvideServiceHeader{}), 0), ShouldBeNil)
+			conf.GConf.FeatureActivations[conf.FeatureReplicaPlacement] = 10
+			So(checkTxFeature(ps, 10), ShouldBeNil)
+			So(checkTxFeature(cd, 10), ShouldBeNil)
+		})
+		Convey("The durability level should be rejected until the feature is scheduled", func() {
+			var tx = types.NewCreateDatabase(&types.CreateDatabaseHeader{
+				ResourceMeta: types.ResourceMeta{Durability: types.StrictDurability},
+			})
+			So(errors.Cause(checkTxFeature(tx, 0)), ShouldEqual, ErrInactiveFeature)
+			conf.GConf.FeatureActivations[conf.FeatureDurabilityLevel] = 10
+			So(checkTxFeature(tx, 10), ShouldBeNil)
+		})
+	})
+}
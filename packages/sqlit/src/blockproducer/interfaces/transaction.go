@@ -57,6 +57,10 @@ const (
 	TransactionTypeIssueKeys
 	// TransactionTypeUpdateBilling defines SQLChain update billing information.
 	TransactionTypeUpdateBilling
+	// TransactionTypeReplaceMiner defines SQLChain standby miner addition or promotion.
+	TransactionTypeReplaceMiner
+	// TransactionTypeExpandDatabase defines SQLChain miners addition.
+	TransactionTypeExpandDatabase
 	// TransactionTypeNumber defines transaction types number.
 	TransactionTypeNumber
 )
@@ -87,6 +91,10 @@ func (t TransactionType) String() string {
 		return "IssueKeys"
 	case TransactionTypeUpdateBilling:
 		return "UpdateBilling"
+	case TransactionTypeReplaceMiner:
+		return "ReplaceMiner"
+	case TransactionTypeExpandDatabase:
+		return "ExpandDatabase"
 	default:
 		return "Unknown"
 	}
@@ -136,7 +144,7 @@ type Transaction interface {
 	GetAccountNonce() AccountNonce
 	GetTimestamp() time.Time
 	Hash() hash.Hash
-	Sign(signer *asymmetric.PrivateKey) error
+	Sign(signer asymmetric.Signer) error
 	Verify() error
 	MarshalHash() ([]byte, error)
 	Msgsize() int
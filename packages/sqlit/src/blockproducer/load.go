@@ -0,0 +1,87 @@
+package blockproducer
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// LoadReportExpiration defines the max age of a database load report, the older ones are
+// dropped as the miner may have stopped serving the database.
+const LoadReportExpiration = 10 * time.Minute
+
+// loadRegistry keeps the latest database loads reported by the miners, which are not part of the
+// chain state and are lost on restart.
+type loadRegistry struct {
+	sync.Mutex
+	loads map[proto.DatabaseID]map[proto.NodeID]*types.DatabaseLoad
+}
+
+func newLoadRegistry() *loadRegistry {
+	return &loadRegistry{
+		loads: make(map[proto.DatabaseID]map[proto.NodeID]*types.DatabaseLoad),
+	}
+}
+
+// report stores the load reported by the miner node.
+func (r *loadRegistry) report(node proto.NodeID, load *types.DatabaseLoad) {
+	r.Lock()
+	defer r.Unlock()
+	m, ok := r.loads[load.DatabaseID]
+	if !ok {
+		m = make(map[proto.NodeID]*types.DatabaseLoad)
+		r.loads[load.DatabaseID] = m
+	}
+	load.NodeID = node
+	m[node] = load
+}
+
+// query returns the unexpired loads of the database sorted by node id, the expired ones are
+// removed.
+func (r *loadRegistry) query(dbID proto.DatabaseID, now time.Time) (loads []*types.DatabaseLoad) {
+	r.Lock()
+	defer r.Unlock()
+	m := r.loads[dbID]
+	for k, v := range m {
+		if now.Sub(v.Timestamp) > LoadReportExpiration {
+			delete(m, k)
+			continue
+		}
+		loads = append(loads, v)
+	}
+	if len(m) == 0 {
+		delete(r.loads, dbID)
+	}
+	sort.Slice(loads, func(i, j int) bool { return loads[i].NodeID < loads[j].NodeID })
+	return
+}
+
+// reportDatabaseLoads stores the database loads reported by the miner node, the loads of the
+// databases not served by node are rejected.
+func (c *Chain) reportDatabaseLoads(node proto.NodeID, loads []*types.DatabaseLoad) (err error) {
+	for _, v := range loads {
+		profile, ok := c.loadSQLChainProfile(v.DatabaseID)
+		if !ok {
+			return errors.Wrapf(ErrDatabaseNotFound, "report load of %s", v.DatabaseID)
+		}
+		var found bool
+		for _, m := range profile.Miners {
+			if m.NodeID == node {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Wrapf(ErrNoSuchMiner, "node %s is not a miner of %s", node, v.DatabaseID)
+		}
+	}
+	for _, v := range loads {
+		c.loads.report(node, v)
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package blockproducer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestDatabaseLoads(t *testing.T) {
+	Convey("Given a chain with a database served by node1 and node2", t, func() {
+		var (
+			now = time.Now()
+			c   = &Chain{immutable: newMetaState(), loads: newLoadRegistry()}
+		)
+		c.immutable.readonly.databases["db"] = &types.SQLChainProfile{
+			ID: "db",
+			Miners: []*types.MinerInfo{
+				{NodeID: "node2"},
+				{NodeID: "node1"},
+			},
+		}
+
+		Convey("The loads reported by the miners should be queried", func() {
+			So(c.reportDatabaseLoads("node2", []*types.DatabaseLoad{
+				{DatabaseID: "db", Timestamp: now, Period: time.Minute, Queries: 60},
+			}), ShouldBeNil)
+			So(c.reportDatabaseLoads("node1", []*types.DatabaseLoad{
+				{DatabaseID: "db", NodeID: "node3", Timestamp: now, Period: time.Minute, Queries: 120},
+			}), ShouldBeNil)
+			loads := c.loads.query("db", now)
+			So(len(loads), ShouldEqual, 2)
+			So(loads[0].NodeID, ShouldEqual, proto.NodeID("node1"))
+			So(loads[0].Queries, ShouldEqual, 120)
+			So(types.AggregateDatabaseLoad(loads).QPS(), ShouldEqual, 3)
+
+			Convey("The expired loads should be removed", func() {
+				So(len(c.loads.query("db", now.Add(LoadReportExpiration+time.Second))), ShouldEqual, 0)
+				So(len(c.loads.loads), ShouldEqual, 0)
+			})
+		})
+		Convey("The loads reported by the other nodes should be rejected", func() {
+			err := c.reportDatabaseLoads("node3", []*types.DatabaseLoad{{DatabaseID: "db"}})
+			So(errors.Cause(err), ShouldEqual, ErrNoSuchMiner)
+			err = c.reportDatabaseLoads("node1", []*types.DatabaseLoad{{DatabaseID: "unknown"}})
+			So(errors.Cause(err), ShouldEqual, ErrDatabaseNotFound)
+			So(len(c.loads.query("db", now)), ShouldEqual, 0)
+		})
+	})
+}
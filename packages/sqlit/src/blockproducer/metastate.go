@@ -346,6 +346,8 @@ func (s *metaState) updateProviderList(tx *types.ProvideService, height uint32)
 		LoadAvgPerCPU: tx.LoadAvgPerCPU,
 		TargetUser:    tx.TargetUser,
 		NodeID:        tx.NodeID,
+		Region:        tx.Region,
+		Zone:          tx.Zone,
 	}
 	s.dirty.provider[sender] = &pp
 	return
@@ -369,11 +371,30 @@ func (s *metaState) matchProvidersWithUser(tx *types.CreateDatabase) (err error)
 		err = ErrInvalidMinerCount
 		return
 	}
+	if !tx.ResourceMeta.Durability.Valid() {
+		err = errors.Wrapf(types.ErrInvalidDurabilityLevel, "durability level %d",
+			tx.ResourceMeta.Durability)
+		return
+	}
+	if tx.ResourceMeta.Source != "" {
+		if err = s.checkCloneSource(&tx.ResourceMeta, sender); err != nil {
+			return
+		}
+	}
 	minerCount := uint64(tx.ResourceMeta.Node)
 
 	miners := make(MinerInfos, 0, minerCount)
 
+	if !tx.ResourceMeta.Placement.IsZero() {
+		if miners, err = s.placeMiners(tx, sender, int(minerCount)); err != nil {
+			return
+		}
+	}
+
 	for _, m := range tx.ResourceMeta.TargetMiners {
+		if uint64(miners.Len()) == minerCount {
+			break
+		}
 		if po, loaded := s.loadProviderObject(m); !loaded {
 			log.WithFields(log.Fields{
 				"miner_addr": m,
@@ -469,12 +490,46 @@ func (s *metaState) matchProvidersWithUser(tx *types.CreateDatabase) (err error)
 	return
 }
 
+// checkCloneSource checks whether user is permitted to clone the source database of meta. The
+// encrypted databases are not supported as the snapshot is copied as plain pages.
+func (s *metaState) checkCloneSource(meta *types.ResourceMeta, user proto.AccountAddress) (err error) {
+	src, loaded := s.loadSQLChainObject(meta.Source)
+	if !loaded {
+		return errors.Wrapf(ErrDatabaseNotFound, "clone source: %s", meta.Source)
+	}
+	if meta.EncryptionKey != "" || src.Meta.EncryptionKey != "" {
+		return errors.Wrap(ErrInvalidCloneSource, "encrypted database cannot be cloned")
+	}
+	for _, m := range src.Miners {
+		if m.EncryptionKey != "" {
+			return errors.Wrap(ErrInvalidCloneSource, "encrypted database cannot be cloned")
+		}
+	}
+	for _, u := range src.Users {
+		if u.Address == user && u.Status == types.Normal && u.Permission.HasReadPermission() {
+			return
+		}
+	}
+	return errors.Wrapf(ErrAccountPermissionDeny, "user %s cannot read clone source %s",
+		user, meta.Source)
+}
+
 func (s *metaState) filterNMiners(
 	tx *types.CreateDatabase,
 	user proto.AccountAddress,
 	minerCount int) (
 	m MinerInfos, err error,
 ) {
+	newMiners := s.matchedProviders(tx, user)
+	if newMiners.Len() < minerCount {
+		err = ErrNoEnoughMiner
+		return
+	}
+	return newMiners[:minerCount], nil
+}
+
+// matchedProviders returns the sorted providers matching tx except the target miners.
+func (s *metaState) matchedProviders(tx *types.CreateDatabase, user proto.AccountAddress) MinerInfos {
 	// create new merged map
 	allProviderMap := make(map[proto.AccountAddress]*types.ProviderProfile)
 	for k, v := range s.readonly.provider {
@@ -499,13 +554,23 @@ func (s *metaState) filterNMiners(
 	for _, po := range allProviderMap {
 		newMiners, _ = filterAndAppendMiner(newMiners, po, tx, user)
 	}
-	if newMiners.Len() < minerCount {
-		err = ErrNoEnoughMiner
-		return
-	}
-
 	sort.Slice(newMiners, newMiners.Less)
-	return newMiners[:minerCount], nil
+	return newMiners
+}
+
+// placeMiners selects the miners satisfying the placement constraints of tx from the matched
+// target miners followed by the other matched providers.
+func (s *metaState) placeMiners(
+	tx *types.CreateDatabase, user proto.AccountAddress, minerCount int) (m MinerInfos, err error,
+) {
+	var candidates MinerInfos
+	for _, addr := range tx.ResourceMeta.TargetMiners {
+		if po, loaded := s.loadProviderObject(addr); loaded {
+			candidates, _ = filterAndAppendMiner(candidates, po, tx, user)
+		}
+	}
+	candidates = append(candidates, s.matchedProviders(tx, user)...)
+	return selectPlacement(candidates, minerCount, &tx.ResourceMeta.Placement)
 }
 
 func filterAndAppendMiner(
@@ -526,6 +591,8 @@ func filterAndAppendMiner(
 	newMiners = append(miners, &types.MinerInfo{
 		Address: po.Provider,
 		NodeID:  po.NodeID,
+		Region:  po.Region,
+		Zone:    po.Zone,
 	})
 	return
 }
@@ -588,47 +655,9 @@ func (s *metaState) updatePermission(tx *types.UpdatePermission) (err error) {
 		return ErrDatabaseNotFound
 	}
 
-	// check whether sender has super privilege and find targetUser
-	numOfSuperUsers := 0
-	targetUserIndex := -1
-	for i, u := range so.Users {
-		if sender == u.Address && !u.Permission.HasSuperPermission() {
-			log.WithFields(log.Fields{
-				"sender": sender,
-				"dbID":   tx.TargetSQLChain,
-			}).WithError(ErrAccountPermissionDeny).Error("unexpected error in updatePermission")
-			return ErrAccountPermissionDeny
-		}
-		if u.Permission.HasSuperPermission() {
-			numOfSuperUsers++
-		}
-		if tx.TargetUser == u.Address {
-			targetUserIndex = i
-		}
-	}
-
-	// return error if number of Admin <= 1 and Admin want to revoke permission of itself
-	if numOfSuperUsers <= 1 && tx.TargetUser == sender && !tx.Permission.HasSuperPermission() {
-		err = ErrNoSuperUserLeft
-		log.WithFields(log.Fields{
-			"sender":     sender,
-			"dbID":       tx.TargetSQLChain,
-			"targetUser": tx.TargetUser,
-		}).WithError(err).Warning("in updatePermission")
+	if err = applyUpdatePermission(so, sender, &tx.UpdatePermissionHeader); err != nil {
 		return
 	}
-
-	// update targetUser's permission
-	if targetUserIndex == -1 {
-		u := types.SQLChainUser{
-			Address:    tx.TargetUser,
-			Permission: tx.Permission,
-			Status:     types.UnknownStatus,
-		}
-		so.Users = append(so.Users, &u)
-	} else {
-		so.Users[targetUserIndex].Permission = tx.Permission
-	}
 	s.dirty.databases[tx.TargetSQLChain.DatabaseID()] = so
 	return
 }
@@ -671,18 +700,197 @@ func (s *metaState) updateKeys(tx *types.IssueKeys) (err error) {
 	return
 }
 
+// replaceMiner adds a registered provider as a standby miner of the sqlchain, or promotes a standby
+// miner in place of an active miner, keeping the position of the replaced one in the miner list.
+func (s *metaState) replaceMiner(tx *types.ReplaceMiner) (err error) {
+	var (
+		sender = tx.GetAccountAddress()
+		dbID   = tx.TargetSQLChain.DatabaseID()
+		le     = log.WithFields(log.Fields{
+			"sender":  sender,
+			"dbID":    dbID,
+			"miner":   tx.Miner,
+			"standby": tx.Standby,
+		})
+	)
+	so, loaded := s.loadSQLChainObject(dbID)
+	if !loaded {
+		le.WithError(ErrDatabaseNotFound).Error("unexpected error in replaceMiner")
+		return ErrDatabaseNotFound
+	}
+
+	// check sender's permission
+	var isSuper bool
+	for _, user := range so.Users {
+		if sender == user.Address {
+			isSuper = user.Permission.HasSuperPermission()
+			break
+		}
+	}
+	if !isSuper {
+		le.WithError(ErrAccountPermissionDeny).Error("unexpected error in replaceMiner")
+		return ErrAccountPermissionDeny
+	}
+
+	var minerIndex, standbyIndex = -1, -1
+	for i, m := range so.Miners {
+		if m.Address == tx.Miner {
+			minerIndex = i
+		}
+		if m.Address == tx.Standby {
+			return errors.Wrapf(ErrInvalidMinerReplacement, "%s is already a miner", tx.Standby)
+		}
+	}
+	for i, m := range so.Standby {
+		if m.Address == tx.Standby {
+			standbyIndex = i
+		}
+	}
+
+	if !tx.IsPromotion() {
+		if standbyIndex >= 0 {
+			return errors.Wrapf(ErrInvalidMinerReplacement, "%s is already a standby", tx.Standby)
+		}
+		po, loaded := s.loadProviderObject(tx.Standby)
+		if !loaded {
+			return errors.Wrapf(ErrNoSuchMiner, "standby provider: %s", tx.Standby)
+		}
+		if !isProviderUserMatch(po.TargetUser, so.Owner) {
+			return errors.Wrapf(ErrMinerUserNotMatch, "standby provider: %s", tx.Standby)
+		}
+		so.Standby = append(so.Standby, &types.MinerInfo{
+			Address: po.Provider,
+			NodeID:  po.NodeID,
+			Region:  po.Region,
+			Zone:    po.Zone,
+		})
+		s.deleteProviderObject(po.Provider)
+	} else {
+		if minerIndex < 0 {
+			return errors.Wrapf(ErrNoSuchMiner, "miner: %s", tx.Miner)
+		}
+		if standbyIndex < 0 {
+			return errors.Wrapf(ErrInvalidMinerReplacement, "%s is not a standby", tx.Standby)
+		}
+		so.Miners[minerIndex] = so.Standby[standbyIndex]
+		so.Standby = append(so.Standby[:standbyIndex], so.Standby[standbyIndex+1:]...)
+		if err = checkPlacement(so.Miners, &so.Meta.Placement); err != nil {
+			return
+		}
+	}
+	s.dirty.databases[dbID] = so
+	le.Info("success replace sqlchain miner")
+	return
+}
+
+// expandDatabase adds more miners to the sqlchain, the target miners are preferred and the others are
+// matched with the resource meta of the sqlchain like the database creation.
+func (s *metaState) expandDatabase(tx *types.ExpandDatabase) (err error) {
+	var (
+		sender = tx.GetAccountAddress()
+		dbID   = tx.TargetSQLChain.DatabaseID()
+		le     = log.WithFields(log.Fields{
+			"sender": sender,
+			"dbID":   dbID,
+			"node":   tx.Node,
+		})
+	)
+	so, loaded := s.loadSQLChainObject(dbID)
+	if !loaded {
+		le.WithError(ErrDatabaseNotFound).Error("unexpected error in expandDatabase")
+		return ErrDatabaseNotFound
+	}
+
+	// check sender's permission
+	var isSuper bool
+	for _, user := range so.Users {
+		if sender == user.Address {
+			isSuper = user.Permission.HasSuperPermission()
+			break
+		}
+	}
+	if !isSuper {
+		le.WithError(ErrAccountPermissionDeny).Error("unexpected error in expandDatabase")
+		return ErrAccountPermissionDeny
+	}
+
+	if tx.Node == 0 {
+		return ErrInvalidMinerCount
+	}
+	// the new miners can't rebuild the seeded state of a clone from its blocks
+	if so.Meta.Source != "" {
+		return errors.Wrap(ErrInvalidDatabaseExpansion, "cloned database cannot be expanded")
+	}
+
+	var (
+		minerCount = int(tx.Node)
+		miners     = make(MinerInfos, 0, minerCount)
+		req        = &types.CreateDatabase{
+			CreateDatabaseHeader: types.CreateDatabaseHeader{
+				Owner:        so.Owner,
+				ResourceMeta: so.Meta,
+			},
+		}
+	)
+	req.ResourceMeta.TargetMiners = tx.TargetMiners
+	for _, addr := range tx.TargetMiners {
+		if containsMiner(so.Miners, addr) || containsMiner(so.Standby, addr) {
+			return errors.Wrapf(ErrInvalidDatabaseExpansion, "%s is already a miner", addr)
+		}
+		po, loaded := s.loadProviderObject(addr)
+		if !loaded {
+			return errors.Wrapf(ErrNoSuchMiner, "target miner: %s", addr)
+		}
+		if miners, err = filterAndAppendMiner(miners, po, req, so.Owner); err != nil {
+			return errors.Wrapf(err, "target miner: %s", addr)
+		}
+	}
+	if miners.Len() > minerCount {
+		miners = miners[:minerCount]
+	}
+
+	// not enough, find more miner(s)
+	for _, m := range s.matchedProviders(req, so.Owner) {
+		if miners.Len() == minerCount {
+			break
+		}
+		if !containsMiner(so.Miners, m.Address) && !containsMiner(so.Standby, m.Address) {
+			miners = append(miners, m)
+		}
+	}
+	if miners.Len() < minerCount {
+		return errors.Wrapf(ErrNoEnoughMiner, "matched %d miners, require %d", miners.Len(), minerCount)
+	}
+
+	so.Miners = append(so.Miners, miners...)
+	so.Meta.Node = uint16(len(so.Miners))
+	for _, m := range miners {
+		s.deleteProviderObject(m.Address)
+	}
+	s.dirty.databases[dbID] = so
+	le.Info("success expand sqlchain")
+	return
+}
+
 func (s *metaState) loadROSQLChains(addr proto.AccountAddress) (dbs []*types.SQLChainProfile) {
 	for _, db := range s.readonly.databases {
-		for _, miner := range db.Miners {
-			if miner.Address == addr {
-				var dst = deepcopy.Copy(db).(*types.SQLChainProfile)
-				dbs = append(dbs, dst)
-			}
+		if containsMiner(db.Miners, addr) || containsMiner(db.Standby, addr) {
+			var dst = deepcopy.Copy(db).(*types.SQLChainProfile)
+			dbs = append(dbs, dst)
 		}
 	}
 	return
 }
 
+func containsMiner(miners []*types.MinerInfo, addr proto.AccountAddress) bool {
+	for _, miner := range miners {
+		if miner.Address == addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *metaState) applyTransaction(tx pi.Transaction, height uint32) (err error) {
 	switch t := tx.(type) {
 	case *types.BaseAccount:
@@ -695,6 +903,10 @@ func (s *metaState) applyTransaction(tx pi.Transaction, height uint32) (err erro
 		err = s.updatePermission(t)
 	case *types.IssueKeys:
 		err = s.updateKeys(t)
+	case *types.ReplaceMiner:
+		err = s.replaceMiner(t)
+	case *types.ExpandDatabase:
+		err = s.expandDatabase(t)
 	case *pi.TransactionWrapper:
 		// call again using unwrapped transaction
 		err = s.applyTransaction(t.Unwrap(), height)
@@ -751,6 +963,10 @@ func (s *metaState) apply(t pi.Transaction, height uint32) (err error) {
 		}).WithError(err).Debug("nonce not match during transaction apply")
 		return
 	}
+	// Check protocol feature activation
+	if err = checkTxFeature(t, height); err != nil {
+		return
+	}
 	// Try to apply transaction to metaState
 	if err = s.applyTransaction(t, height); err != nil {
 		log.WithError(err).Debug("apply transaction failed")
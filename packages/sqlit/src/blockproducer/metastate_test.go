@@ -5,6 +5,7 @@ import (
 	"os"
 	"testing"
 
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 
 	pi "sqlit/src/blockproducer/interfaces"
@@ -395,6 +396,247 @@ func TestMetaState(t *testing.T) {
 				So(len(co.Miners), ShouldEqual, 2)
 
 				log.Debugf("Created database: %s with %d miners", dbID, len(co.Miners))
+
+				Convey("The clone source should be checked", func() {
+					meta := &types.ResourceMeta{Source: dbID}
+					So(ms.checkCloneSource(meta, addr1), ShouldBeNil)
+					err = ms.checkCloneSource(meta, addr3)
+					So(errors.Cause(err), ShouldEqual, ErrAccountPermissionDeny)
+					err = ms.checkCloneSource(&types.ResourceMeta{Source: "unknown"}, addr1)
+					So(errors.Cause(err), ShouldEqual, ErrDatabaseNotFound)
+					meta.EncryptionKey = "key"
+					err = ms.checkCloneSource(meta, addr1)
+					So(errors.Cause(err), ShouldEqual, ErrInvalidCloneSource)
+				})
+				Convey("The database should be cloned after the feature is activated", func() {
+					clone := types.CreateDatabase{
+						CreateDatabaseHeader: types.CreateDatabaseHeader{
+							Owner: addr1,
+							ResourceMeta: types.ResourceMeta{
+								TargetMiners: cd.ResourceMeta.TargetMiners,
+								Node:         2,
+								Source:       dbID,
+							},
+							Nonce: 2,
+						},
+					}
+					err = clone.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(&clone, 0)
+					So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+
+					for _, v := range []string{"1", "2"} {
+						ms.dirty.provider[proto.AccountAddress(hash.HashH([]byte(v)))] = &types.ProviderProfile{
+							Provider:      proto.AccountAddress(hash.HashH([]byte(v))),
+							TargetUser:    []proto.AccountAddress{addr1},
+							Space:         100,
+							Memory:        100,
+							LoadAvgPerCPU: 0.001,
+							NodeID:        proto.NodeID("000000" + v),
+						}
+					}
+					ms.commit()
+					conf.GConf.FeatureActivations = map[conf.Feature]uint32{
+						conf.FeatureCloneDatabase: 0,
+					}
+					defer func() { conf.GConf.FeatureActivations = nil }()
+					err = ms.apply(&clone, 0)
+					So(err, ShouldBeNil)
+					ms.commit()
+					co, loaded = ms.loadSQLChainObject(
+						proto.FromAccountAndNonce(addr1, uint32(clone.Nonce)))
+					So(loaded, ShouldBeTrue)
+					So(co.Meta.Source, ShouldEqual, dbID)
+				})
+				Convey("The miners should be placed across regions after the feature is activated", func() {
+					for _, v := range []struct{ id, region string }{
+						{"4", "us-east"}, {"5", "us-east"}, {"6", "eu-west"},
+					} {
+						ms.dirty.provider[proto.AccountAddress(hash.HashH([]byte(v.id)))] = &types.ProviderProfile{
+							Provider:      proto.AccountAddress(hash.HashH([]byte(v.id))),
+							TargetUser:    []proto.AccountAddress{addr1},
+							Space:         100,
+							Memory:        100,
+							LoadAvgPerCPU: 0.001,
+							NodeID:        proto.NodeID("000000" + v.id),
+							Region:        v.region,
+						}
+					}
+					ms.commit()
+					placed := types.CreateDatabase{
+						CreateDatabaseHeader: types.CreateDatabaseHeader{
+							Owner: addr1,
+							ResourceMeta: types.ResourceMeta{
+								Node:      2,
+								Placement: types.PlacementMeta{MinRegions: 2},
+							},
+							Nonce: 2,
+						},
+					}
+					err = placed.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(&placed, 0)
+					So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+
+					conf.GConf.FeatureActivations = map[conf.Feature]uint32{
+						conf.FeatureReplicaPlacement: 0,
+					}
+					defer func() { conf.GConf.FeatureActivations = nil }()
+					placed.ResourceMeta.Placement.MinRegions = 3
+					err = placed.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(&placed, 0)
+					So(errors.Cause(err), ShouldEqual, ErrPlacementUnsatisfied)
+					placed.ResourceMeta.Placement.MinRegions = 2
+					err = placed.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(&placed, 0)
+					So(err, ShouldBeNil)
+					ms.commit()
+					co, loaded = ms.loadSQLChainObject(
+						proto.FromAccountAndNonce(addr1, uint32(placed.Nonce)))
+					So(loaded, ShouldBeTrue)
+					So(len(co.Miners), ShouldEqual, 2)
+					So(co.Miners[0].NodeID, ShouldEqual, proto.NodeID("0000004"))
+					So(co.Miners[1].Region, ShouldEqual, "eu-west")
+				})
+				Convey("The miner should be replaced by a standby after the feature is activated", func() {
+					var (
+						standby = proto.AccountAddress(hash.HashH([]byte("3")))
+						miner   = co.Miners[0].Address
+						dbAddr  = co.Address
+					)
+					ms.dirty.provider[standby] = &types.ProviderProfile{
+						Provider:      standby,
+						TargetUser:    []proto.AccountAddress{addr1},
+						Space:         100,
+						Memory:        100,
+						LoadAvgPerCPU: 0.001,
+						NodeID:        "0000003",
+					}
+					ms.commit()
+					add := types.NewReplaceMiner(&types.ReplaceMinerHeader{
+						TargetSQLChain: dbAddr,
+						Standby:        standby,
+						Nonce:          2,
+					})
+					err = add.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(add, 0)
+					So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+
+					conf.GConf.FeatureActivations = map[conf.Feature]uint32{
+						conf.FeatureReplaceMiner: 0,
+					}
+					defer func() { conf.GConf.FeatureActivations = nil }()
+					denied := types.NewReplaceMiner(&types.ReplaceMinerHeader{
+						TargetSQLChain: dbAddr,
+						Standby:        standby,
+						Nonce:          1,
+					})
+					err = denied.Sign(privKey3)
+					So(err, ShouldBeNil)
+					err = ms.apply(denied, 0)
+					So(errors.Cause(err), ShouldEqual, ErrAccountPermissionDeny)
+					err = ms.apply(add, 0)
+					So(err, ShouldBeNil)
+					ms.commit()
+					co, loaded = ms.loadSQLChainObject(dbID)
+					So(loaded, ShouldBeTrue)
+					So(len(co.Miners), ShouldEqual, 2)
+					So(len(co.Standby), ShouldEqual, 1)
+					So(co.Standby[0].NodeID, ShouldEqual, proto.NodeID("0000003"))
+					So(len(ms.loadROSQLChains(standby)), ShouldEqual, 1)
+					_, loaded = ms.loadProviderObject(standby)
+					So(loaded, ShouldBeFalse)
+
+					promote := types.NewReplaceMiner(&types.ReplaceMinerHeader{
+						TargetSQLChain: dbAddr,
+						Miner:          standby,
+						Standby:        standby,
+						Nonce:          3,
+					})
+					err = promote.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(promote, 0)
+					So(errors.Cause(err), ShouldEqual, ErrNoSuchMiner)
+					promote.Miner = miner
+					err = promote.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(promote, 0)
+					So(err, ShouldBeNil)
+					ms.commit()
+					co, loaded = ms.loadSQLChainObject(dbID)
+					So(loaded, ShouldBeTrue)
+					So(co.Miners[0].Address, ShouldEqual, standby)
+					So(len(co.Standby), ShouldEqual, 0)
+					So(len(ms.loadROSQLChains(miner)), ShouldEqual, 0)
+				})
+				Convey("The database should be expanded with the matched providers after the feature is activated", func() {
+					var (
+						target = proto.AccountAddress(hash.HashH([]byte("3")))
+						dbAddr = co.Address
+					)
+					for _, v := range []string{"3", "4"} {
+						ms.dirty.provider[proto.AccountAddress(hash.HashH([]byte(v)))] = &types.ProviderProfile{
+							Provider:      proto.AccountAddress(hash.HashH([]byte(v))),
+							TargetUser:    []proto.AccountAddress{addr1},
+							Space:         100,
+							Memory:        100,
+							LoadAvgPerCPU: 0.001,
+							NodeID:        proto.NodeID("000000" + v),
+						}
+					}
+					ms.commit()
+					expand := types.NewExpandDatabase(&types.ExpandDatabaseHeader{
+						TargetSQLChain: dbAddr,
+						Node:           10,
+						TargetMiners:   []proto.AccountAddress{target},
+						Nonce:          2,
+					})
+					err = expand.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(expand, 0)
+					So(errors.Cause(err), ShouldEqual, ErrInactiveFeature)
+
+					conf.GConf.FeatureActivations = map[conf.Feature]uint32{
+						conf.FeatureExpandDatabase: 0,
+					}
+					defer func() { conf.GConf.FeatureActivations = nil }()
+					denied := types.NewExpandDatabase(&types.ExpandDatabaseHeader{
+						TargetSQLChain: dbAddr,
+						Node:           1,
+						Nonce:          1,
+					})
+					err = denied.Sign(privKey3)
+					So(err, ShouldBeNil)
+					err = ms.apply(denied, 0)
+					So(errors.Cause(err), ShouldEqual, ErrAccountPermissionDeny)
+					err = ms.apply(expand, 0)
+					So(errors.Cause(err), ShouldEqual, ErrNoEnoughMiner)
+					expand.TargetMiners = []proto.AccountAddress{co.Miners[0].Address}
+					err = expand.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(expand, 0)
+					So(errors.Cause(err), ShouldEqual, ErrInvalidDatabaseExpansion)
+					expand.Node = 2
+					expand.TargetMiners = []proto.AccountAddress{target}
+					err = expand.Sign(privKey1)
+					So(err, ShouldBeNil)
+					err = ms.apply(expand, 0)
+					So(err, ShouldBeNil)
+					ms.commit()
+					co, loaded = ms.loadSQLChainObject(dbID)
+					So(loaded, ShouldBeTrue)
+					So(len(co.Miners), ShouldEqual, 4)
+					So(co.Meta.Node, ShouldEqual, 4)
+					So(co.Miners[2].Address, ShouldEqual, target)
+					So(co.Miners[3].Address, ShouldNotEqual, target)
+					for _, m := range co.Miners[2:] {
+						_, loaded = ms.loadProviderObject(m.Address)
+						So(loaded, ShouldBeFalse)
+					}
+				})
 			})
 		})
 	})
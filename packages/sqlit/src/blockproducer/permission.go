@@ -0,0 +1,137 @@
+package blockproducer
+
+import (
+	"fmt"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// applyUpdatePermission applies the permission update h sent by sender to the users of so, it's
+// shared by the transaction processing and the preview of the transaction.
+func applyUpdatePermission(
+	so *types.SQLChainProfile, sender proto.AccountAddress, h *types.UpdatePermissionHeader,
+) (err error) {
+	// check whether sender has super privilege and find targetUser
+	numOfSuperUsers := 0
+	targetUserIndex := -1
+	for i, u := range so.Users {
+		if sender == u.Address && !u.Permission.HasSuperPermission() {
+			log.WithFields(log.Fields{
+				"sender": sender,
+				"dbID":   h.TargetSQLChain,
+			}).WithError(ErrAccountPermissionDeny).Error("unexpected error in updatePermission")
+			return ErrAccountPermissionDeny
+		}
+		if u.Permission.HasSuperPermission() {
+			numOfSuperUsers++
+		}
+		if h.TargetUser == u.Address {
+			targetUserIndex = i
+		}
+	}
+
+	// return error if number of Admin <= 1 and Admin want to revoke permission of itself
+	if numOfSuperUsers <= 1 && h.TargetUser == sender && !h.Permission.HasSuperPermission() {
+		err = ErrNoSuperUserLeft
+		log.WithFields(log.Fields{
+			"sender":     sender,
+			"dbID":       h.TargetSQLChain,
+			"targetUser": h.TargetUser,
+		}).WithError(err).Warning("in updatePermission")
+		return
+	}
+
+	// update targetUser's permission
+	if targetUserIndex == -1 {
+		u := types.SQLChainUser{
+			Address:    h.TargetUser,
+			Permission: h.Permission,
+			Status:     types.UnknownStatus,
+		}
+		so.Users = append(so.Users, &u)
+	} else {
+		so.Users[targetUserIndex].Permission = h.Permission
+	}
+	return
+}
+
+// permissionWarnings returns the warnings of the accepted permission changes which are likely
+// mistakes.
+func permissionWarnings(
+	sender proto.AccountAddress, before []*types.SQLChainUser, changes []*types.PermissionChange,
+) (warnings []string) {
+	var isUser = false
+	for _, u := range before {
+		if u.Address == sender {
+			isUser = true
+			break
+		}
+	}
+	if !isUser {
+		warnings = append(warnings, fmt.Sprintf(
+			"sender %s is not a user of the database", sender))
+	}
+	for _, c := range changes {
+		switch {
+		case !c.After.IsValid():
+			warnings = append(warnings, fmt.Sprintf(
+				"invalid permission %v of %s", c.After, c.Addr))
+		case c.Before == nil:
+			warnings = append(warnings, fmt.Sprintf(
+				"%s is added as a new user, the user status is set by the next billing", c.Addr))
+		case c.Before.HasSuperPermission() && !c.After.HasSuperPermission():
+			warnings = append(warnings, fmt.Sprintf("revokes the admin permission of %s", c.Addr))
+		}
+		if c.After.IsValid() && c.After.Role == types.Void {
+			warnings = append(warnings, fmt.Sprintf("revokes all the permissions of %s", c.Addr))
+		}
+		if c.After != nil && len(c.After.Patterns) > 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"%s is restricted to %d query patterns", c.Addr, len(c.After.Patterns)))
+		}
+	}
+	return
+}
+
+// previewUpdatePermission returns the resulting users of the database and the changes if the
+// permission update h sent by sender is applied on the head, without applying it.
+func (c *Chain) previewUpdatePermission(sender proto.AccountAddress, h *types.UpdatePermissionHeader) (
+	users []*types.SQLChainUser, changes []*types.PermissionChange, warnings []string, err error,
+) {
+	c.RLock()
+	defer c.RUnlock()
+	var so, loaded = c.headBranch.preview.loadSQLChainObject(h.TargetSQLChain.DatabaseID())
+	if !loaded {
+		err = ErrDatabaseNotFound
+		return
+	}
+	// the users of the loaded copy are updated in place
+	var before = make([]*types.SQLChainUser, len(so.Users))
+	for i, u := range so.Users {
+		var v = *u
+		before[i] = &v
+	}
+	if err = applyUpdatePermission(so, sender, h); err != nil {
+		return
+	}
+	users = so.Users
+	changes = types.DiffUsers(before, users)
+	warnings = permissionWarnings(sender, before, changes)
+	return
+}
+
+// queryEffectivePermission returns the effective permission of account addr on database dbID,
+// with the query patterns evaluated.
+func (c *Chain) queryEffectivePermission(
+	dbID proto.DatabaseID, addr proto.AccountAddress, patterns []string,
+) (ep *types.EffectivePermission, err error) {
+	var profile, ok = c.loadSQLChainProfile(dbID)
+	if !ok {
+		err = ErrDatabaseNotFound
+		return
+	}
+	ep = types.NewEffectivePermission(profile, addr, patterns...)
+	return
+}
@@ -0,0 +1,142 @@
+package blockproducer
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/types"
+)
+
+// isPlacementTx returns whether tx uses the location labels or the placement constraints.
+func isPlacementTx(tx pi.Transaction) bool {
+	switch t := tx.(type) {
+	case *types.ProvideService:
+		return t.Region != "" || t.Zone != ""
+	case *types.CreateDatabase:
+		return !t.ResourceMeta.Placement.IsZero()
+	}
+	return false
+}
+
+// placement tracks the regions and zones covered by a set of miners.
+type placement struct {
+	regions map[string]struct{}
+	zones   map[string]struct{}
+}
+
+func newPlacement() *placement {
+	return &placement{
+		regions: make(map[string]struct{}),
+		zones:   make(map[string]struct{}),
+	}
+}
+
+func (p *placement) add(m *types.MinerInfo) {
+	if m.Region != "" {
+		p.regions[m.Region] = struct{}{}
+	}
+	if m.Zone != "" {
+		// zones are only distinct in the same region
+		p.zones[m.Region+"/"+m.Zone] = struct{}{}
+	}
+}
+
+func (p *placement) hasRegion(region string) bool {
+	_, ok := p.regions[region]
+	return ok
+}
+
+func (p *placement) hasZone(m *types.MinerInfo) bool {
+	_, ok := p.zones[m.Region+"/"+m.Zone]
+	return ok
+}
+
+// check returns an error if the covered regions and zones don't satisfy meta.
+func (p *placement) check(meta *types.PlacementMeta) error {
+	for _, r := range meta.Regions {
+		if !p.hasRegion(r) {
+			return errors.Wrapf(ErrPlacementUnsatisfied, "no miner in region %s", r)
+		}
+	}
+	if len(p.regions) < int(meta.MinRegions) {
+		return errors.Wrapf(ErrPlacementUnsatisfied, "miners span %d regions, require %d",
+			len(p.regions), meta.MinRegions)
+	}
+	if len(p.zones) < int(meta.MinZones) {
+		return errors.Wrapf(ErrPlacementUnsatisfied, "miners span %d zones, require %d",
+			len(p.zones), meta.MinZones)
+	}
+	return nil
+}
+
+// checkPlacement returns an error if miners don't satisfy the placement constraints of meta.
+func checkPlacement(miners []*types.MinerInfo, meta *types.PlacementMeta) error {
+	var p = newPlacement()
+	for _, m := range miners {
+		p.add(m)
+	}
+	return p.check(meta)
+}
+
+// selectPlacement selects n miners from the ordered candidates which satisfy the placement
+// constraints of meta. The candidates covering the required regions are selected first, then the
+// ones spreading to new regions and zones, and the earlier candidates are preferred at each step.
+// The selected miners keep the candidate order.
+func selectPlacement(
+	candidates MinerInfos, n int, meta *types.PlacementMeta) (miners MinerInfos, err error,
+) {
+	var (
+		p        = newPlacement()
+		selected []int
+		used     = make([]bool, len(candidates))
+		pick     = func(match func(m *types.MinerInfo) bool) bool {
+			for i, m := range candidates {
+				if !used[i] && match(m) {
+					used[i] = true
+					selected = append(selected, i)
+					p.add(m)
+					return true
+				}
+			}
+			return false
+		}
+	)
+	for _, r := range meta.Regions {
+		if p.hasRegion(r) {
+			continue
+		}
+		if !pick(func(m *types.MinerInfo) bool { return m.Region == r }) {
+			return nil, errors.Wrapf(ErrPlacementUnsatisfied, "no miner in region %s", r)
+		}
+	}
+	for len(p.regions) < int(meta.MinRegions) {
+		if !pick(func(m *types.MinerInfo) bool { return m.Region != "" && !p.hasRegion(m.Region) }) {
+			return nil, errors.Wrapf(ErrPlacementUnsatisfied, "miners span %d regions, require %d",
+				len(p.regions), meta.MinRegions)
+		}
+	}
+	for len(p.zones) < int(meta.MinZones) {
+		if !pick(func(m *types.MinerInfo) bool { return m.Zone != "" && !p.hasZone(m) }) {
+			return nil, errors.Wrapf(ErrPlacementUnsatisfied, "miners span %d zones, require %d",
+				len(p.zones), meta.MinZones)
+		}
+	}
+	if len(selected) > n {
+		return nil, errors.Wrapf(ErrPlacementUnsatisfied,
+			"placement requires %d miners, more than %d", len(selected), n)
+	}
+	for len(selected) < n {
+		if !pick(func(*types.MinerInfo) bool { return true }) {
+			return nil, ErrNoEnoughMiner
+		}
+	}
+
+	sort.Ints(selected)
+	miners = make(MinerInfos, 0, n)
+	for _, i := range selected {
+		miners = append(miners, candidates[i])
+	}
+	return
+}
@@ -0,0 +1,65 @@
+package blockproducer
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestSelectPlacement(t *testing.T) {
+	Convey("Given miners in several regions and zones", t, func() {
+		var (
+			miner = func(node, region, zone string) *types.MinerInfo {
+				return &types.MinerInfo{NodeID: proto.NodeID(node), Region: region, Zone: zone}
+			}
+			candidates = MinerInfos{
+				miner("0", "us-east", "a"),
+				miner("1", "us-east", "b"),
+				miner("2", "us-east", "a"),
+				miner("3", "ap-south", "a"),
+				miner("4", "eu-west", "a"),
+				miner("5", "", ""),
+			}
+			nodes = func(miners MinerInfos) (ids []string) {
+				for _, m := range miners {
+					ids = append(ids, string(m.NodeID))
+				}
+				return
+			}
+		)
+		Convey("The earlier candidates should be selected without constraints", func() {
+			miners, err := selectPlacement(candidates, 3, &types.PlacementMeta{})
+			So(err, ShouldBeNil)
+			So(nodes(miners), ShouldResemble, []string{"0", "1", "2"})
+		})
+		Convey("The miners should spread across the required regions", func() {
+			meta := &types.PlacementMeta{MinRegions: 3}
+			miners, err := selectPlacement(candidates, 3, meta)
+			So(err, ShouldBeNil)
+			So(nodes(miners), ShouldResemble, []string{"0", "3", "4"})
+			So(checkPlacement(miners, meta), ShouldBeNil)
+		})
+		Convey("The miners should include the named region", func() {
+			meta := &types.PlacementMeta{Regions: []string{"eu-west"}, MinZones: 2}
+			miners, err := selectPlacement(candidates, 3, meta)
+			So(err, ShouldBeNil)
+			So(nodes(miners), ShouldResemble, []string{"0", "1", "4"})
+			So(checkPlacement(miners, meta), ShouldBeNil)
+			So(errors.Cause(checkPlacement(miners[:2], meta)), ShouldEqual, ErrPlacementUnsatisfied)
+		})
+		Convey("The unsatisfiable constraints should be rejected", func() {
+			_, err := selectPlacement(candidates, 3, &types.PlacementMeta{Regions: []string{"sa-east"}})
+			So(errors.Cause(err), ShouldEqual, ErrPlacementUnsatisfied)
+			_, err = selectPlacement(candidates, 3, &types.PlacementMeta{MinRegions: 4})
+			So(errors.Cause(err), ShouldEqual, ErrPlacementUnsatisfied)
+			_, err = selectPlacement(candidates, 2, &types.PlacementMeta{MinRegions: 3})
+			So(errors.Cause(err), ShouldEqual, ErrPlacementUnsatisfied)
+			_, err = selectPlacement(candidates, 7, &types.PlacementMeta{MinRegions: 1})
+			So(errors.Cause(err), ShouldEqual, ErrNoEnoughMiner)
+		})
+	})
+}
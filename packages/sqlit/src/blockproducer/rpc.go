@@ -2,12 +2,23 @@
 package blockproducer
 
 import (
+	"time"
+
 	"github.com/pkg/errors"
 
 	pi "sqlit/src/blockproducer/interfaces"
 	"sqlit/src/types"
 )
 
+const (
+	// DefaultAccountHistorySize defines the default count of the transactions on a page of the
+	// account history.
+	DefaultAccountHistorySize = 20
+	// MaxAccountHistorySize defines the max count of the transactions on a page of the account
+	// history.
+	MaxAccountHistorySize = 100
+)
+
 // ChainRPCService defines a main chain RPC server.
 type ChainRPCService struct {
 	chain *Chain
@@ -15,6 +26,10 @@ type ChainRPCService struct {
 
 // AdviseNewBlock is the RPC method to advise a new block to target server.
 func (s *ChainRPCService) AdviseNewBlock(req *types.AdviseNewBlockReq, resp *types.AdviseNewBlockResp) error {
+	// the new blocks are fetched after the synchronized heights
+	if err := s.chain.holeSync.check(); err != nil {
+		return err
+	}
 	s.chain.pendingBlocks <- req.Block
 	return nil
 }
@@ -22,6 +37,10 @@ func (s *ChainRPCService) AdviseNewBlock(req *types.AdviseNewBlockReq, resp *typ
 // FetchBlock is the RPC method to fetch a known block from the target server.
 func (s *ChainRPCService) FetchBlock(req *types.FetchBlockReq, resp *types.FetchBlockResp) error {
 	resp.Height = req.Height
+	// a height not synchronized yet is not reported as a hole to the peers
+	if err := s.chain.checkSynced(req.Height); err != nil {
+		return err
+	}
 	block, count, err := s.chain.fetchBlockByHeight(req.Height)
 	if err != nil {
 		return err
@@ -52,6 +71,11 @@ func (s *ChainRPCService) FetchBlockByCount(req *types.FetchBlockByCountReq, res
 	if err != nil {
 		return err
 	}
+	if block == nil {
+		if err = s.chain.holeSync.check(); err != nil {
+			return err
+		}
+	}
 	resp.Block = block
 	resp.Height = height
 	return err
@@ -66,6 +90,9 @@ func (s *ChainRPCService) FetchTxBilling(req *types.FetchTxBillingReq, resp *typ
 func (s *ChainRPCService) NextAccountNonce(
 	req *types.NextAccountNonceReq, resp *types.NextAccountNonceResp) (err error,
 ) {
+	if err = s.chain.holeSync.check(); err != nil {
+		return
+	}
 	if resp.Nonce, err = s.chain.nextNonce(req.Addr); err != nil {
 		return
 	}
@@ -75,6 +102,20 @@ func (s *ChainRPCService) NextAccountNonce(
 
 // AddTx is the RPC method to add a transaction.
 func (s *ChainRPCService) AddTx(req *types.AddTxReq, _ *types.AddTxResp) (err error) {
+	if err = s.chain.holeSync.check(); err != nil {
+		return
+	}
+	if req.Tx != nil && req.Replaces != nil {
+		// Check replacement in advance to report error to the client
+		if err = func() (err error) {
+			s.chain.RLock()
+			defer s.chain.RUnlock()
+			_, err = s.chain.checkReplaceTx(*req.Replaces, req.Tx)
+			return
+		}(); err != nil {
+			return
+		}
+	}
 	s.chain.addTx(req)
 	return
 }
@@ -126,3 +167,83 @@ func (s *ChainRPCService) QueryAccountSQLChainProfiles(
 	resp.Profiles = profiles
 	return
 }
+
+// QueryAccountPendingTxs is the RPC method to query the pending transactions of an account.
+func (s *ChainRPCService) QueryAccountPendingTxs(
+	req *types.QueryAccountPendingTxsReq, resp *types.QueryAccountPendingTxsResp) (err error,
+) {
+	resp.Addr = req.Addr
+	resp.Txs = s.chain.queryAccountPendingTxs(req.Addr)
+	return
+}
+
+// QueryAccountHistory is the RPC method to query the transactions of an account.
+func (s *ChainRPCService) QueryAccountHistory(
+	req *types.QueryAccountHistoryReq, resp *types.QueryAccountHistoryResp) (err error,
+) {
+	resp.Addr, resp.Page, resp.Size = req.Addr, req.Page, req.Size
+	if resp.Page == 0 {
+		resp.Page = 1
+	}
+	if resp.Size == 0 {
+		resp.Size = DefaultAccountHistorySize
+	} else if resp.Size > MaxAccountHistorySize {
+		resp.Size = MaxAccountHistorySize
+	}
+	if resp.Pending, resp.Txs, resp.Total, err = s.chain.queryAccountHistory(
+		req.Addr, resp.Page, resp.Size,
+	); err != nil {
+		err = errors.Wrap(err, "rpc query account history failed")
+		return
+	}
+	// the account may be not created yet
+	resp.NextNonce, _ = s.chain.nextNonce(req.Addr)
+	return
+}
+
+// PreviewUpdatePermission is the RPC method to preview the result of a permission update of a
+// database on the head, the update is not applied.
+func (s *ChainRPCService) PreviewUpdatePermission(
+	req *types.PreviewUpdatePermissionReq, resp *types.PreviewUpdatePermissionResp) (err error,
+) {
+	if resp.Users, resp.Changes, resp.Warnings, err = s.chain.previewUpdatePermission(
+		req.Sender, &req.Header,
+	); err != nil {
+		err = errors.Wrap(err, "rpc preview update permission failed")
+	}
+	return
+}
+
+// QueryEffectivePermission is the RPC method to query the effective permission of an account on
+// a database.
+func (s *ChainRPCService) QueryEffectivePermission(
+	req *types.QueryEffectivePermissionReq, resp *types.QueryEffectivePermissionResp) (err error,
+) {
+	if resp.Permission, err = s.chain.queryEffectivePermission(
+		req.DBID, req.Addr, req.Patterns,
+	); err != nil {
+		err = errors.Wrap(err, "rpc query effective permission failed")
+	}
+	return
+}
+
+// ReportDatabaseLoad is the RPC method for a miner to report the loads of its databases.
+func (s *ChainRPCService) ReportDatabaseLoad(
+	req *types.ReportDatabaseLoadReq, _ *types.ReportDatabaseLoadResp) (err error,
+) {
+	return s.chain.reportDatabaseLoads(req.GetNodeID().ToNodeID(), req.Loads)
+}
+
+// QueryDatabaseLoad is the RPC method to query the load of a database reported by its miners.
+func (s *ChainRPCService) QueryDatabaseLoad(
+	req *types.QueryDatabaseLoadReq, resp *types.QueryDatabaseLoadResp) (err error,
+) {
+	if _, ok := s.chain.loadSQLChainProfile(req.DBID); !ok {
+		err = errors.Wrap(ErrDatabaseNotFound, "rpc query database load failed")
+		return
+	}
+	resp.DBID = req.DBID
+	resp.Miners = s.chain.loads.query(req.DBID, time.Now())
+	resp.Total = types.AggregateDatabaseLoad(resp.Miners)
+	return
+}
@@ -453,46 +453,25 @@ func loadBlock(st xi.Storage, hash hash.Hash) (block *types.BPBlock, err error)
 }
 
 func loadBlocks(
-	st xi.Storage, irreHash hash.Hash) (lastIrre *blockNode, heads []*blockNode, err error,
+	bs blockStore, irreHash hash.Hash) (lastIrre *blockNode, heads []*blockNode, err error,
 ) {
 	var (
-		rows *sql.Rows
-
 		index      = make(map[hash.Hash]*blockNode)
 		headsIndex = make(map[hash.Hash]*blockNode)
 
-		// Scan buffer
-		id           uint32
-		height       uint32
-		bnHex, pnHex string
-		enc          []byte
-
-		ok     bool
-		bh, ph hash.Hash
-		bn, pn *blockNode
+		id uint32
+		ok bool
 	)
 
 	// Load blocks
-	if rows, err = st.Reader().Query(
-		`SELECT "rowid", "height", "hash", "parent", "encoded" FROM "blocks" ORDER BY "rowid"`,
-	); err != nil {
-		return
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		// Scan and decode block
-		if err = rows.Scan(&id, &height, &bnHex, &pnHex, &enc); err != nil {
-			return
-		}
-		if err = hash.Decode(&bh, bnHex); err != nil {
-			return
-		}
-		if err = hash.Decode(&ph, pnHex); err != nil {
-			return
-		}
-		var dec = &types.BPBlock{}
-		if err = utils.DecodeMsgPack(enc, dec); err != nil {
+	if err = bs.scanBlocks(func(height uint32, bh, ph hash.Hash, enc []byte) (err error) {
+		var (
+			dec    *types.BPBlock
+			bn, pn *blockNode
+		)
+		id++
+		// Decode block
+		if dec, err = decodeBlock(enc); err != nil {
 			return
 		}
 		log.WithFields(log.Fields{
@@ -504,8 +483,7 @@ func loadBlocks(
 		// Add genesis block
 		if height == 0 {
 			if len(index) != 0 {
-				err = ErrMultipleGenesis
-				return
+				return ErrMultipleGenesis
 			}
 			bn = newNonCacheBlockNode(0, dec, nil)
 			index[bh] = bn
@@ -516,12 +494,11 @@ func loadBlocks(
 				"hash":   bh.Short(4),
 				"parent": ph.Short(4),
 			}).Debug("set genesis block")
-			continue
+			return
 		}
 		// Add normal block
 		if pn, ok = index[ph]; !ok {
-			err = errors.Wrapf(ErrParentNotFound, "parent %s not found", ph.Short(4))
-			return
+			return errors.Wrapf(ErrParentNotFound, "parent %s not found", ph.Short(4))
 		}
 		bn = newNonCacheBlockNode(height, dec, pn)
 		index[bh] = bn
@@ -529,10 +506,13 @@ func loadBlocks(
 			delete(headsIndex, ph)
 		}
 		headsIndex[bh] = bn
+		return
+	}); err != nil {
+		return
 	}
 
 	if lastIrre, ok = index[irreHash]; !ok {
-		err = errors.Wrapf(ErrParentNotFound, "irreversible block %s not found", ph.Short(4))
+		err = errors.Wrapf(ErrParentNotFound, "irreversible block %s not found", irreHash.Short(4))
 		return
 	}
 
@@ -643,7 +623,7 @@ func loadImmutableState(st xi.Storage) (immutable *metaState, err error) {
 	return
 }
 
-func loadDatabase(st xi.Storage) (
+func loadDatabase(st xi.Storage, bs blockStore) (
 	irre *blockNode,
 	heads []*blockNode,
 	immutable *metaState,
@@ -656,7 +636,7 @@ func loadDatabase(st xi.Storage) (
 		return
 	}
 	// Load blocks
-	if irre, heads, err = loadBlocks(st, irreHash); err != nil {
+	if irre, heads, err = loadBlocks(bs, irreHash); err != nil {
 		return
 	}
 	// Load immutable state
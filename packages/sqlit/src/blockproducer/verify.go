@@ -0,0 +1,76 @@
+package blockproducer
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/hash"
+)
+
+// minParallelVerifyTxs is the transaction count below which the verification is not worth
+// spreading across the workers.
+const minParallelVerifyTxs = 16
+
+// verifyTxs runs the signature and stateless checks of the transactions, except the ones
+// verified reports true for, across a pool of GOMAXPROCS workers. The checks have no side effect
+// on the chain state, so they are done ahead of the sequential apply phase. The error of the
+// first failed transaction in order is returned, which is the same one a sequential verification
+// would return.
+func verifyTxs(txs []pi.Transaction, verified func(hash.Hash) bool) (err error) {
+	var pending = make([]pi.Transaction, 0, len(txs))
+	for _, v := range txs {
+		if verified == nil || !verified(v.Hash()) {
+			pending = append(pending, v)
+		}
+	}
+	return verifyTxsWithWorkers(pending, runtime.GOMAXPROCS(0))
+}
+
+func verifyTxsWithWorkers(txs []pi.Transaction, workers int) (err error) {
+	if workers > len(txs) {
+		workers = len(txs)
+	}
+	if workers <= 1 || len(txs) < minParallelVerifyTxs {
+		for _, v := range txs {
+			if err = v.Verify(); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	var (
+		errs   = make([]error, len(txs))
+		next   int64
+		failed int64 = int64(len(txs)) // lowest index failed so far
+		wg     sync.WaitGroup
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var j = atomic.AddInt64(&next, 1) - 1
+				// the transactions after a failed one are not needed for the result
+				if j >= atomic.LoadInt64(&failed) {
+					return
+				}
+				if errs[j] = txs[j].Verify(); errs[j] != nil {
+					for {
+						var f = atomic.LoadInt64(&failed)
+						if j >= f || atomic.CompareAndSwapInt64(&failed, f, j) {
+							break
+						}
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if failed < int64(len(txs)) {
+		err = errs[failed]
+	}
+	return
+}
@@ -0,0 +1,83 @@
+package blockproducer
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/crypto/verifier"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func newVerifyingTxs(n int) (txs []pi.Transaction, err error) {
+	var priv *asymmetric.PrivateKey
+	if priv, _, err = asymmetric.GenSecp256k1KeyPair(); err != nil {
+		return
+	}
+	txs = make([]pi.Transaction, n)
+	for i := range txs {
+		var tx *types.CreateDatabase
+		if tx, err = newTransaction(pi.AccountNonce(i), priv, proto.AccountAddress{}); err != nil {
+			return
+		}
+		txs[i] = tx
+	}
+	return
+}
+
+func TestVerifyTxs(t *testing.T) {
+	Convey("Given a list of signed transactions", t, func() {
+		txs, err := newVerifyingTxs(4 * minParallelVerifyTxs)
+		So(err, ShouldBeNil)
+		So(verifyTxs(txs, nil), ShouldBeNil)
+		So(verifyTxsWithWorkers(txs, 4), ShouldBeNil)
+
+		Convey("The first failed transaction in order should be reported", func() {
+			_, pub, err := asymmetric.GenSecp256k1KeyPair()
+			So(err, ShouldBeNil)
+			txs[10].(*types.CreateDatabase).Nonce = 1000
+			txs[40].(*types.CreateDatabase).Signee = pub
+			So(errors.Cause(verifyTxsWithWorkers(txs, 1)), ShouldEqual, verifier.ErrHashValueNotMatch)
+			for i := 0; i < 10; i++ {
+				So(errors.Cause(verifyTxsWithWorkers(txs, 4)), ShouldEqual, verifier.ErrHashValueNotMatch)
+			}
+			So(errors.Cause(verifyTxsWithWorkers(txs[11:], 4)), ShouldEqual, verifier.ErrSignatureNotMatch)
+			Convey("The transactions reported as verified should be skipped", func() {
+				var tampered = map[hash.Hash]bool{txs[10].Hash(): true, txs[40].Hash(): true}
+				So(verifyTxs(txs, func(k hash.Hash) bool { return tampered[k] }), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func BenchmarkVerifyTxs(b *testing.B) {
+	txs, err := newVerifyingTxs(1000)
+	if err != nil {
+		b.Fatal(err)
+	}
+	// run with -cpu to compare the throughput on different core counts
+	for _, parallel := range []bool{false, true} {
+		var name = "Sequential"
+		if parallel {
+			name = "Parallel"
+		}
+		b.Run(name, func(b *testing.B) {
+			var workers = 1
+			if parallel {
+				workers = runtime.GOMAXPROCS(0)
+			}
+			for i := 0; i < b.N; i++ {
+				if err := verifyTxsWithWorkers(txs, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.N*len(txs))/b.Elapsed().Seconds(), "txs/s")
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/types"
+)
+
+// GetAccountNonce returns the next nonce of the account addr, including the pending transactions
+// on the block producers.
+func GetAccountNonce(addr proto.AccountAddress) (nonce interfaces.AccountNonce, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+	return getNonce(addr)
+}
+
+// ListPendingTxs returns the transactions of the account addr which are not yet confirmed by the
+// block producers, sorted by nonce.
+func ListPendingTxs(addr proto.AccountAddress) (txs []*types.PendingTx, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var (
+		req  = &types.QueryAccountPendingTxsReq{Addr: addr}
+		resp = &types.QueryAccountPendingTxsResp{}
+	)
+	if err = requestBP(route.MCCQueryAccountPendingTxs, req, resp); err != nil {
+		err = errors.Wrap(err, "query account pending transactions failed")
+		return
+	}
+	txs = resp.Txs
+	return
+}
+
+// GetAccountHistory returns the transactions of the account addr packed into the blocks on page
+// (starting from 1) of size, the pending transactions are also returned on the first page.
+func GetAccountHistory(addr proto.AccountAddress, page, size uint32) (
+	history *types.QueryAccountHistoryResp, err error,
+) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var req = &types.QueryAccountHistoryReq{Addr: addr, Page: page, Size: size}
+	history = &types.QueryAccountHistoryResp{}
+	if err = requestBP(route.MCCQueryAccountHistory, req, history); err != nil {
+		err = errors.Wrap(err, "query account history failed")
+		history = nil
+	}
+	return
+}
+
+// ReplaceTx replaces the stuck pending transaction oldHash with the signed transaction newTx,
+// which must be from the same account with the same nonce. The replacement is rejected if the
+// old transaction is already packed into the head block.
+func ReplaceTx(oldHash hash.Hash, newTx interfaces.Transaction) (txHash hash.Hash, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var (
+		req = &types.AddTxReq{
+			TTL:      1,
+			Tx:       newTx,
+			Replaces: &oldHash,
+		}
+		resp = &types.AddTxResp{}
+	)
+	if err = requestBP(route.MCCAddTx, req, resp); err != nil {
+		err = errors.Wrapf(err, "replace transaction %s failed", oldHash.Short(4))
+		return
+	}
+	txHash = newTx.Hash()
+	return
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
+
+var (
+	ctxColumnarKey = "_sqlit_columnar"
+)
+
+// WithColumnar returns a context which sends the read queries accepting the columnar encoding of
+// the result rows, which packs the values column-wise with the run-length or dictionary encoding.
+// It saves the bandwidth of the large analytic results with few distinct or repeated values, the
+// rows are restored by the driver before the verification.
+func WithColumnar(ctx context.Context) context.Context {
+	return context.WithValue(ctx, &ctxColumnarKey, true)
+}
+
+// IsColumnar returns whether the context accepts the columnar encoding of the result rows.
+func IsColumnar(ctx context.Context) bool {
+	columnar, _ := ctx.Value(&ctxColumnarKey).(bool)
+	return columnar
+}
+
+// QueryArrow runs the read query with the columnar encoding of the result rows, and returns the
+// result as a column batch in the layout of an Arrow record batch: a vector per column with a
+// validity bitmap and the dense values typed by the column. The vectors can be handed to an Arrow
+// library without conversion of the values, the batch itself is not an Arrow IPC message.
+func QueryArrow(ctx context.Context, db *sql.DB, query string, args ...interface{}) (
+	batch *types.ColumnBatch, err error,
+) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(WithColumnar(ctx), query, args...); err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	var p = &types.ResponsePayload{}
+	if p.Columns, err = rows.Columns(); err != nil {
+		return nil, errors.Wrap(err, "read result columns failed")
+	}
+	cts, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, errors.Wrap(err, "read result column types failed")
+	}
+	p.DeclTypes = make([]string, len(cts))
+	for i, ct := range cts {
+		p.DeclTypes[i] = ct.DatabaseTypeName()
+	}
+	// the rows are scanned to resolve the blob references and fetch the spilled pages
+	for rows.Next() {
+		var (
+			values = make([]interface{}, len(p.Columns))
+			dest   = make([]interface{}, len(p.Columns))
+		)
+		for i := range dest {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return nil, errors.Wrap(err, "scan result row failed")
+		}
+		p.Rows = append(p.Rows, types.ResponseRow{Values: values})
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	return types.NewColumnBatch(p), nil
+}
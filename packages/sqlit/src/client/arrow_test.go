@@ -0,0 +1,68 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
+)
+
+func TestQueryArrow(t *testing.T) {
+	Convey("Given a development database", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit_arrow")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		var dsn = DevDSN(filepath.Join(dir, "dev.db3"))
+		So(WaitDBCreation(context.Background(), dsn), ShouldBeNil)
+
+		db, err := sql.Open(DBScheme, dsn)
+		So(err, ShouldBeNil)
+		defer db.Close()
+		_, err = db.Exec("CREATE TABLE test (id INT PRIMARY KEY, v TEXT, score REAL)")
+		So(err, ShouldBeNil)
+		for i := 0; i < 10; i++ {
+			var v interface{} = "even"
+			if i%2 == 1 {
+				v = nil
+			}
+			_, err = db.Exec("INSERT INTO test VALUES (?, ?, ?)", i, v, float64(i)/2)
+			So(err, ShouldBeNil)
+		}
+
+		Convey("The context should accept the columnar encoding", func() {
+			So(IsColumnar(context.Background()), ShouldBeFalse)
+			So(IsColumnar(WithColumnar(context.Background())), ShouldBeTrue)
+		})
+		Convey("The result should be returned as a column batch", func() {
+			b, err := QueryArrow(context.Background(), db,
+				"SELECT id, v, score FROM test WHERE id >= ? ORDER BY id", 2)
+			So(err, ShouldBeNil)
+			So(b.Columns, ShouldResemble, []string{"id", "v", "score"})
+			So(b.NumRows, ShouldEqual, 8)
+			So(b.Vectors[0].Type, ShouldEqual, types.ColumnInt64)
+			So(b.Vectors[0].Int64s, ShouldResemble, []int64{2, 3, 4, 5, 6, 7, 8, 9})
+			So(b.Vectors[1].Type, ShouldEqual, types.ColumnString)
+			So(b.Vectors[1].IsNull(0), ShouldBeFalse)
+			So(b.Vectors[1].IsNull(1), ShouldBeTrue)
+			So(b.Vectors[1].Value(2), ShouldEqual, "even")
+			So(b.Vectors[2].Type, ShouldEqual, types.ColumnFloat64)
+			So(b.Vectors[2].Float64s[1], ShouldEqual, 1.5)
+		})
+		Convey("The empty result should keep the columns", func() {
+			b, err := QueryArrow(context.Background(), db, "SELECT id, v FROM test WHERE id < 0")
+			So(err, ShouldBeNil)
+			So(b.Columns, ShouldResemble, []string{"id", "v"})
+			So(b.NumRows, ShouldEqual, 0)
+			So(b.Vectors[0].Type, ShouldEqual, types.ColumnNull)
+		})
+		Convey("The invalid query should fail", func() {
+			_, err := QueryArrow(context.Background(), db, "SELECT * FROM missing")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,36 @@
+package client
+
+import (
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
+
+// asOfClause matches the trailing "AS OF HEIGHT n" clause of a read query.
+var asOfClause = regexp.MustCompile(`(?is)^(.*\S)\s+AS\s+OF\s+HEIGHT\s+(\d+)\s*;?\s*$`)
+
+// stripAsOfHeight removes the "AS OF HEIGHT n" clauses from the read queries, and returns the
+// sqlchain height of the historical state to query. All the clauses must be at the same height.
+func stripAsOfHeight(queries []types.Query) (height int32, ok bool, err error) {
+	for i := range queries {
+		m := asOfClause.FindStringSubmatch(queries[i].Pattern)
+		if m == nil {
+			continue
+		}
+		var h int64
+		if h, err = strconv.ParseInt(m[2], 10, 32); err != nil {
+			err = errors.Wrapf(err, "invalid as-of height in query #%d", i)
+			return
+		}
+		if ok && int32(h) != height {
+			err = errors.Errorf("conflicting as-of heights %d and %d", height, h)
+			return
+		}
+		queries[i].Pattern = m[1]
+		height, ok = int32(h), true
+	}
+	return
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
+)
+
+func TestStripAsOfHeight(t *testing.T) {
+	Convey("test strip as-of height clauses", t, func() {
+		queries := []types.Query{
+			{Pattern: "SELECT * FROM t1 WHERE k = ? as of height 10;"},
+			{Pattern: "SELECT 1\n  AS OF HEIGHT 10"},
+		}
+		height, ok, err := stripAsOfHeight(queries)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeTrue)
+		So(height, ShouldEqual, 10)
+		So(queries[0].Pattern, ShouldEqual, "SELECT * FROM t1 WHERE k = ?")
+		So(queries[1].Pattern, ShouldEqual, "SELECT 1")
+
+		queries = []types.Query{{Pattern: "SELECT height AS of_height FROM t1"}}
+		_, ok, err = stripAsOfHeight(queries)
+		So(err, ShouldBeNil)
+		So(ok, ShouldBeFalse)
+		So(queries[0].Pattern, ShouldEqual, "SELECT height AS of_height FROM t1")
+
+		queries = []types.Query{
+			{Pattern: "SELECT 1 AS OF HEIGHT 1"},
+			{Pattern: "SELECT 1 AS OF HEIGHT 2"},
+		}
+		_, _, err = stripAsOfHeight(queries)
+		So(err, ShouldNotBeNil)
+		_, _, err = stripAsOfHeight([]types.Query{{Pattern: "SELECT 1 AS OF HEIGHT 99999999999"}})
+		So(err, ShouldNotBeNil)
+	})
+}
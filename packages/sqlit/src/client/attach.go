@@ -0,0 +1,279 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3" // the in-memory database of the federated queries
+	"github.com/pkg/errors"
+	"github.com/xwb1989/sqlparser"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// MaxAttachedDatabases defines the max count of databases attached to a connection, which is the
+// default attach limit of sqlite.
+const MaxAttachedDatabases = 10
+
+var (
+	// attachStatement matches "ATTACH [DATABASE] 'dsn' AS alias".
+	attachStatement = regexp.MustCompile(
+		`(?is)^\s*ATTACH\s+(?:DATABASE\s+)?'([^']+)'\s+AS\s+"?([a-z_][a-z0-9_]*)"?\s*;?\s*$`)
+	// detachStatement matches "DETACH [DATABASE] alias".
+	detachStatement = regexp.MustCompile(
+		`(?is)^\s*DETACH\s+(?:DATABASE\s+)?"?([a-z_][a-z0-9_]*)"?\s*;?\s*$`)
+)
+
+// parseAttachStatement returns the lower-cased alias and the database dsn of the ATTACH statement
+// query, or the alias and an empty dsn of the DETACH statement query. ok is false if query is
+// neither of them.
+func parseAttachStatement(query string) (alias, dsn string, ok bool) {
+	if m := attachStatement.FindStringSubmatch(query); m != nil {
+		return strings.ToLower(m[2]), m[1], true
+	}
+	if m := detachStatement.FindStringSubmatch(query); m != nil {
+		return strings.ToLower(m[1]), "", true
+	}
+	return
+}
+
+// attach attaches the database of dsn, which may also be a bare database id, to the connection
+// as alias, or detaches the alias if dsn is empty. The attached databases are joined in the read
+// queries of the connection.
+func (c *conn) attach(alias, dsn string) (err error) {
+	if dsn == "" {
+		if _, ok := c.attached[alias]; !ok {
+			return errors.Errorf("no database attached as %s", alias)
+		}
+		delete(c.attached, alias)
+		return
+	}
+	if c.cfg.Dev != "" {
+		return errors.Wrap(ErrDevUnsupported, "attached database")
+	}
+	if !xs.ValidAttachAlias(alias) {
+		return errors.Wrapf(xs.ErrInvalidAttachAlias, "%q", alias)
+	}
+	if _, ok := c.attached[alias]; ok {
+		return errors.Errorf("database already attached as %s", alias)
+	}
+	if len(c.attached) >= MaxAttachedDatabases {
+		return errors.Errorf("too many attached databases, max %d", MaxAttachedDatabases)
+	}
+	cfg, err := ParseDSN(dsn)
+	if err != nil {
+		return
+	}
+	if c.attached == nil {
+		c.attached = make(map[string]proto.DatabaseID)
+	}
+	c.attached[alias] = proto.DatabaseID(cfg.DatabaseID)
+	return
+}
+
+// attachedTables returns the names of the tables referenced by the read query, grouped by the
+// aliases of the attached databases, the tables of the connection database are grouped by
+// "main".
+func attachedTables(query string, attached map[string]proto.DatabaseID) (
+	tables map[string][]string, err error,
+) {
+	stmt, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse federated query failed")
+	}
+	switch stmt.(type) {
+	case *sqlparser.Select, *sqlparser.Union, *sqlparser.ParenSelect:
+	default:
+		return nil, errors.Wrap(ErrAttachUnsupported, "federated query is not a select statement")
+	}
+	var seen = make(map[string]bool)
+	tables = make(map[string][]string)
+	err = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		// the qualifiers of the columns are table names too, only the table expressions count
+		te, ok := node.(*sqlparser.AliasedTableExpr)
+		if !ok {
+			return true, nil
+		}
+		tn, ok := te.Expr.(sqlparser.TableName)
+		if !ok || tn.IsEmpty() {
+			return true, nil
+		}
+		var alias, name = strings.ToLower(tn.Qualifier.String()), strings.ToLower(tn.Name.String())
+		if alias == "" {
+			alias = "main"
+		} else if _, ok := attached[alias]; !ok && alias != "main" {
+			return false, errors.Errorf("unknown database %s", alias)
+		}
+		if key := alias + "." + name; !seen[key] {
+			seen[key] = true
+			tables[alias] = append(tables[alias], name)
+		}
+		return true, nil
+	}, stmt)
+	return
+}
+
+// quoteIdent quotes the sqlite identifier s.
+func quoteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// copyTable reads all the rows of the table name from the database of src, and writes them to
+// the same table of the alias schema in the in-memory database mem.
+func copyTable(ctx context.Context, mem *sql.DB, src *conn, alias, name string) (err error) {
+	_, _, dr, err := src.sendAttachedQuery(ctx, types.ReadQuery, []types.Query{
+		{Pattern: "SELECT * FROM " + quoteIdent(name)},
+	}, nil)
+	if err != nil {
+		return errors.Wrapf(err, "read table %s.%s failed", alias, name)
+	}
+	defer func() { _ = dr.Close() }()
+	var (
+		r      = dr.(*rows)
+		table  = quoteIdent(alias) + "." + quoteIdent(name)
+		cols   = make([]string, len(r.columns))
+		params = make([]string, len(r.columns))
+	)
+	for i, v := range r.columns {
+		cols[i] = quoteIdent(v) + " " + r.types[i]
+		params[i] = "?"
+	}
+	if _, err = mem.ExecContext(ctx, "CREATE TABLE "+table+
+		" ("+strings.Join(cols, ", ")+")"); err != nil {
+		return
+	}
+	tx, err := mem.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO "+table+
+		" VALUES ("+strings.Join(params, ", ")+")")
+	if err != nil {
+		return
+	}
+	defer func() { _ = stmt.Close() }()
+	var (
+		dest = make([]driver.Value, len(r.columns))
+		args = make([]interface{}, len(r.columns))
+	)
+	for {
+		if err = r.Next(dest); err == io.EOF {
+			break
+		} else if err != nil {
+			return
+		}
+		for i := range dest {
+			args[i] = dest[i]
+		}
+		if _, err = stmt.ExecContext(ctx, args...); err != nil {
+			return
+		}
+	}
+	return tx.Commit()
+}
+
+// federatedQuery joins the attached databases on the client when they are not co-located with
+// the connection database: the tables referenced by the query are copied to an in-memory
+// database from their own databases, and the query runs there. The tables are read in separate
+// requests, so the result is not a consistent snapshot across the databases.
+func (c *conn) federatedQuery(ctx context.Context, query string, args []driver.NamedValue) (
+	dr driver.Rows, err error,
+) {
+	tables, err := attachedTables(query, c.attached)
+	if err != nil {
+		return
+	}
+	mem, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return
+	}
+	defer func() { _ = mem.Close() }()
+	// the attached in-memory databases are private to a sqlite connection
+	mem.SetMaxOpenConns(1)
+
+	var aliases = make([]string, 0, len(tables))
+	for alias := range tables {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		if err = func() (err error) {
+			var src = c
+			if alias != "main" {
+				if _, err = mem.ExecContext(ctx,
+					"ATTACH DATABASE ':memory:' AS "+quoteIdent(alias)); err != nil {
+					return
+				}
+				var cfg = *c.cfg
+				cfg.DatabaseID = string(c.attached[alias])
+				cfg.AsOfHeight = 0
+				if src, err = newConn(&cfg); err != nil {
+					return
+				}
+				defer func() { _ = src.Close() }()
+			}
+			for _, name := range tables[alias] {
+				if err = copyTable(ctx, mem, src, alias, name); err != nil {
+					return
+				}
+			}
+			return
+		}(); err != nil {
+			return
+		}
+	}
+
+	var values = make([]interface{}, len(args))
+	for i, v := range args {
+		if v.Name != "" {
+			values[i] = sql.Named(v.Name, v.Value)
+		} else {
+			values[i] = v.Value
+		}
+	}
+	sr, err := mem.QueryContext(ctx, query, values...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sr.Close() }()
+	var resp types.Response
+	if resp.Payload.Columns, err = sr.Columns(); err != nil {
+		return
+	}
+	cts, err := sr.ColumnTypes()
+	if err != nil {
+		return
+	}
+	for _, ct := range cts {
+		resp.Payload.DeclTypes = append(resp.Payload.DeclTypes, ct.DatabaseTypeName())
+	}
+	for sr.Next() {
+		var (
+			row  = make([]interface{}, len(cts))
+			dest = make([]interface{}, len(cts))
+		)
+		for i := range row {
+			dest[i] = &row[i]
+		}
+		if err = sr.Scan(dest...); err != nil {
+			return
+		}
+		if c.maxRows > 0 && uint64(len(resp.Payload.Rows)) >= c.maxRows {
+			return nil, errors.Errorf("%s: federated result exceeds %d rows",
+				types.ErrCodeResultTooLarge, c.maxRows)
+		}
+		resp.Payload.Rows = append(resp.Payload.Rows, types.ResponseRow{Values: row})
+	}
+	if err = sr.Err(); err != nil {
+		return
+	}
+	return newRows(&resp), nil
+}
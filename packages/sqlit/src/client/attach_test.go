@@ -0,0 +1,50 @@
+package client
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+)
+
+func TestParseAttachStatement(t *testing.T) {
+	Convey("test parse attach statements", t, func() {
+		for _, c := range []struct {
+			query      string
+			alias, dsn string
+			ok         bool
+		}{
+			{"ATTACH DATABASE 'sqlit://db2' AS Orders", "orders", "sqlit://db2", true},
+			{"attach 'db2' as \"o\";", "o", "db2", true},
+			{"DETACH DATABASE orders", "orders", "", true},
+			{" detach o ", "o", "", true},
+			{"ATTACH DATABASE db2 AS o", "", "", false},
+			{"SELECT 'ATTACH'", "", "", false},
+		} {
+			alias, dsn, ok := parseAttachStatement(c.query)
+			So(ok, ShouldEqual, c.ok)
+			So(alias, ShouldEqual, c.alias)
+			So(dsn, ShouldEqual, c.dsn)
+		}
+	})
+}
+
+func TestAttachedTables(t *testing.T) {
+	Convey("test collect tables of federated queries", t, func() {
+		var attached = map[string]proto.DatabaseID{"o": "db2"}
+		tables, err := attachedTables("SELECT u.name, SUM(x.amount) FROM users u "+
+			"JOIN O.orders x ON x.user = u.id JOIN main.users v ON v.id = u.id "+
+			"WHERE u.id IN (SELECT user FROM o.refunds) GROUP BY u.id", attached)
+		So(err, ShouldBeNil)
+		So(tables, ShouldResemble, map[string][]string{
+			"main": {"users"},
+			"o":    {"orders", "refunds"},
+		})
+
+		_, err = attachedTables("SELECT * FROM x.orders", attached)
+		So(err, ShouldNotBeNil)
+		_, err = attachedTables("DELETE FROM o.orders", attached)
+		So(err, ShouldNotBeNil)
+	})
+}
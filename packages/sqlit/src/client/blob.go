@@ -0,0 +1,160 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/storage/objstore"
+)
+
+const (
+	// DefaultBlobThreshold is the default min size of the blob arguments offloaded to the blob
+	// store.
+	DefaultBlobThreshold = 1 << 20
+
+	// blobRefMagic is the leading bytes of the blob references stored in place of the offloaded
+	// blobs, followed by the sha256 of the content. The reference carries no location, the object
+	// is always looked up under the configured blob store location.
+	blobRefMagic = "\x00sqlit-blob\x01"
+	// blobRefSize is the fixed size of the blob references.
+	blobRefSize = len(blobRefMagic) + sha256.Size
+)
+
+// blobStore offloads the large blob arguments of the write queries to an S3-compatible object
+// store, keeping the content-addressed references in the database, and resolves the references
+// in the read results.
+type blobStore struct {
+	client    *objstore.Client
+	bucket    string
+	prefix    string
+	threshold int
+}
+
+// newBlobStore returns the blob store of cfg, or nil if no blob store is configured.
+func newBlobStore(cfg *Config) (s *blobStore, err error) {
+	if cfg.BlobStore == "" {
+		return
+	}
+	var bucket, prefix string
+	if bucket, prefix, err = objstore.ParseLocation(cfg.BlobStore); err != nil {
+		return
+	}
+	if cfg.BlobEndpoint == "" {
+		err = errors.Errorf("%s is required by %s", paramBlobEndpoint, paramBlobStore)
+		return
+	}
+	s = &blobStore{
+		bucket:    bucket,
+		prefix:    strings.Trim(prefix, "/"),
+		threshold: cfg.BlobThreshold,
+	}
+	if s.threshold <= 0 {
+		s.threshold = DefaultBlobThreshold
+	}
+	if s.client, err = objstore.NewClient(
+		cfg.BlobEndpoint, cfg.BlobRegion, cfg.BlobAccessKey, cfg.BlobSecretKey,
+	); err != nil {
+		return nil, err
+	}
+	return
+}
+
+// offload uploads the blob arguments not smaller than the threshold and replaces them with their
+// references. The objects are keyed by the sha256 of the content, so the same blob is stored once,
+// and the objects of the rolled back or failed writes are left in the store.
+func (s *blobStore) offload(ctx context.Context, args []driver.NamedValue) (
+	result []driver.NamedValue, err error,
+) {
+	if s == nil {
+		return args, nil
+	}
+	for i, arg := range args {
+		data, ok := arg.Value.([]byte)
+		if !ok || len(data) < s.threshold {
+			continue
+		}
+		if result == nil {
+			result = make([]driver.NamedValue, len(args))
+			copy(result, args)
+		}
+		sum := sha256.Sum256(data)
+		if err = s.client.PutObject(
+			ctx, s.bucket, s.key(sum[:]), bytes.NewReader(data), int64(len(data)),
+		); err != nil {
+			err = errors.Wrapf(err, "offload blob argument %d", arg.Ordinal)
+			return
+		}
+		result[i].Value = append([]byte(blobRefMagic), sum[:]...)
+	}
+	if result == nil {
+		result = args
+	}
+	return
+}
+
+// key returns the object key of the blob with content hash sum.
+func (s *blobStore) key(sum []byte) string {
+	return path.Join(s.prefix, hex.EncodeToString(sum))
+}
+
+// parseBlobRef returns the content hash of value if it is a blob reference, which is a BLOB
+// value of the fixed size starting with the magic bytes.
+func parseBlobRef(value driver.Value) (sum []byte, ok bool) {
+	v, ok := value.([]byte)
+	if !ok || len(v) != blobRefSize || !bytes.HasPrefix(v, []byte(blobRefMagic)) {
+		return nil, false
+	}
+	return v[len(blobRefMagic):], true
+}
+
+// resolve downloads the blob with content hash sum and verifies its content.
+func (s *blobStore) resolve(ctx context.Context, sum []byte) (data []byte, err error) {
+	var (
+		key  = s.key(sum)
+		body io.ReadCloser
+	)
+	if body, err = s.client.GetObject(ctx, s.bucket, key); err != nil {
+		err = errors.Wrapf(err, "resolve blob %s", key)
+		return
+	}
+	defer func() { _ = body.Close() }()
+	if data, err = io.ReadAll(body); err != nil {
+		err = errors.Wrapf(err, "resolve blob %s", key)
+		return
+	}
+	if actual := sha256.Sum256(data); !bytes.Equal(actual[:], sum) {
+		data = nil
+		err = errors.Errorf("resolve blob %s: content hash mismatch", key)
+	}
+	return
+}
+
+// attachRows makes the rows resolve the blob references with the query context.
+func (s *blobStore) attachRows(ctx context.Context, dr driver.Rows) {
+	if r, ok := dr.(*rows); ok && s != nil {
+		r.ctx, r.blobs = ctx, s
+	}
+}
+
+// resolveRow replaces the blob references in the row values with their contents.
+func (s *blobStore) resolveRow(ctx context.Context, values []driver.Value) (err error) {
+	if s == nil {
+		return
+	}
+	for i, v := range values {
+		if sum, ok := parseBlobRef(v); ok {
+			if values[i], err = s.resolve(ctx, sum); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
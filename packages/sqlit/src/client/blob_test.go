@@ -0,0 +1,148 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type blobTestStore struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *blobTestStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	}
+}
+
+func TestBlobStore(t *testing.T) {
+	Convey("Given a development database with a blob store", t, func() {
+		store := &blobTestStore{objects: make(map[string][]byte)}
+		server := httptest.NewServer(store)
+		defer server.Close()
+
+		dir, err := os.MkdirTemp("", "sqlit_blob")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		cfg, err := ParseDSN(DevDSN(filepath.Join(dir, "dev.db3")))
+		So(err, ShouldBeNil)
+		cfg.BlobStore = "s3://blobs/db"
+		cfg.BlobEndpoint = server.URL
+		cfg.BlobAccessKey = "ak"
+		cfg.BlobSecretKey = "sk"
+		cfg.BlobThreshold = 16
+
+		recoveredCfg, err := ParseDSN(cfg.FormatDSN())
+		So(err, ShouldBeNil)
+		So(recoveredCfg, ShouldResemble, cfg)
+
+		var dsn = cfg.FormatDSN()
+		So(WaitDBCreation(context.Background(), dsn), ShouldBeNil)
+		db, err := sql.Open(DBScheme, dsn)
+		So(err, ShouldBeNil)
+		defer db.Close()
+		_, err = db.Exec("CREATE TABLE test (id INT PRIMARY KEY, v BLOB)")
+		So(err, ShouldBeNil)
+
+		Convey("The large blobs should be offloaded and resolved transparently", func() {
+			large := bytes.Repeat([]byte("x"), 1024)
+			_, err = db.Exec("INSERT INTO test VALUES (?, ?), (?, ?), (?, ?)",
+				1, []byte("small"), 2, large, 3, large)
+			So(err, ShouldBeNil)
+			// the same content is stored once
+			So(store.objects, ShouldHaveLength, 1)
+			for key := range store.objects {
+				So(strings.HasPrefix(key, "/blobs/db/"), ShouldBeTrue)
+			}
+
+			var v []byte
+			So(db.QueryRow("SELECT v FROM test WHERE id = ?", 1).Scan(&v), ShouldBeNil)
+			So(v, ShouldResemble, []byte("small"))
+			So(db.QueryRow("SELECT v FROM test WHERE id = ?", 2).Scan(&v), ShouldBeNil)
+			So(v, ShouldResemble, large)
+
+			// the database keeps the reference only
+			var n int
+			So(db.QueryRow("SELECT length(v) FROM test WHERE id = ?", 3).Scan(&n), ShouldBeNil)
+			So(n, ShouldEqual, blobRefSize)
+
+			Convey("The values looking like the old references should be kept", func() {
+				var text = "sqlit-blob:s3://other/key"
+				_, err = db.Exec("INSERT INTO test VALUES (?, ?), (?, ?)", 4, text, 5, []byte(text))
+				So(err, ShouldBeNil)
+				var v string
+				So(db.QueryRow("SELECT v FROM test WHERE id = ?", 4).Scan(&v), ShouldBeNil)
+				So(v, ShouldEqual, text)
+				So(db.QueryRow("SELECT v FROM test WHERE id = ?", 5).Scan(&v), ShouldBeNil)
+				So(v, ShouldEqual, text)
+			})
+			Convey("The tampered objects should be refused", func() {
+				for key := range store.objects {
+					store.objects[key] = []byte("tampered")
+				}
+				err = db.QueryRow("SELECT v FROM test WHERE id = ?", 2).Scan(&v)
+				So(err, ShouldNotBeNil)
+			})
+		})
+	})
+
+	Convey("Only the fixed size BLOB values with the magic should be blob references", t, func() {
+		var (
+			sum = sha256.Sum256([]byte("blob"))
+			ref = append([]byte(blobRefMagic), sum[:]...)
+		)
+		got, ok := parseBlobRef(ref)
+		So(ok, ShouldBeTrue)
+		So(got, ShouldResemble, sum[:])
+		for _, v := range []interface{}{
+			string(ref),
+			ref[:len(ref)-1],
+			append(ref, 0),
+			append([]byte("\x00sqlit-blob\x02"), sum[:]...),
+			[]byte("sqlit-blob:s3://other/" + hex.EncodeToString(sum[:])),
+			"sqlit-blob:s3://other/" + hex.EncodeToString(sum[:]),
+			int64(1),
+			nil,
+		} {
+			_, ok = parseBlobRef(v)
+			So(ok, ShouldBeFalse)
+		}
+
+		var s = &blobStore{bucket: "blobs", prefix: "db"}
+		So(s.key(sum[:]), ShouldEqual, "db/"+hex.EncodeToString(sum[:]))
+	})
+
+	Convey("The invalid blob store options should be refused", t, func() {
+		_, err := ParseDSN("sqlit://db?blob_store=bucket")
+		So(err, ShouldNotBeNil)
+		_, err = ParseDSN("sqlit://db?blob_store=s3://bucket&blob_threshold=-1")
+		So(err, ShouldNotBeNil)
+		_, err = newBlobStore(&Config{BlobStore: "s3://bucket"})
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"os"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
+
+// decompressors caches the result decompressors by their dictionary files, which are shared by
+// the connections.
+var decompressors sync.Map
+
+// newResultDecompressor returns the result decompressor of cfg, nil if the compression is not
+// accepted.
+func newResultDecompressor(cfg *Config) (d *types.ResultDecompressor, err error) {
+	switch cfg.ResultCompression {
+	case "":
+		return
+	case types.ResultCompressionZstd:
+	default:
+		err = errors.Errorf("invalid %s: %s", paramCompress, cfg.ResultCompression)
+		return
+	}
+	if v, ok := decompressors.Load(cfg.CompressionDict); ok {
+		return v.(*types.ResultDecompressor), nil
+	}
+	var dict []byte
+	if cfg.CompressionDict != "" {
+		if dict, err = os.ReadFile(cfg.CompressionDict); err != nil {
+			err = errors.Wrapf(err, "read %s failed", paramCompressDict)
+			return
+		}
+	}
+	if d, err = types.NewResultDecompressor(dict); err != nil {
+		return
+	}
+	v, _ := decompressors.LoadOrStore(cfg.CompressionDict, d)
+	return v.(*types.ResultDecompressor), nil
+}
@@ -5,6 +5,12 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/storage/objstore"
+	"sqlit/src/types"
 )
 
 const (
@@ -12,6 +18,25 @@ const (
 	paramUseFollower  = "use_follower"
 	paramUseDirectRPC = "use_direct_rpc"
 	paramMirror       = "mirror"
+	paramStandby      = "standby"
+	paramStandbyNode  = "standby_node"
+	paramAsOfHeight   = "as_of_height"
+	paramPriority     = "priority"
+	paramMaxExecTime  = "max_execution_time"
+	paramMaxCost      = "max_cost"
+	paramResultCursor = "result_cursor"
+	paramDegradedRead = "degraded_reads"
+	paramSkipVerify   = "skip_verify"
+	paramCompress     = "compress"
+	paramCompressDict = "compress_dict"
+	paramDev          = "dev"
+
+	paramBlobStore     = "blob_store"
+	paramBlobEndpoint  = "blob_endpoint"
+	paramBlobRegion    = "blob_region"
+	paramBlobAccessKey = "blob_access_key"
+	paramBlobSecretKey = "blob_secret_key"
+	paramBlobThreshold = "blob_threshold"
 )
 
 // Config is a configuration parsed from a DSN string.
@@ -33,6 +58,72 @@ type Config struct {
 
 	// Mirror option forces client to query from mirror server
 	Mirror string
+
+	// Standby option reads a possibly stale snapshot of the database in read-only mode, from a
+	// miner which is catching up or has been removed from the database
+	Standby bool
+
+	// StandbyNode is the miner to query in standby mode, empty means a random peer
+	StandbyNode string
+
+	// AsOfHeight reads the historical state of the database at the sqlchain height in read-only
+	// mode, 0 means the current state
+	AsOfHeight int32
+
+	// Priority is the priority class of the queries, the batch queries may be queued or shed by
+	// the miners to keep the latency of the interactive ones
+	Priority types.QueryPriority
+
+	// MaxExecutionTime is the time limit of each query on the miners, the query exceeding it is
+	// interrupted, 0 means no limit
+	MaxExecutionTime time.Duration
+
+	// MaxCost is the max cost of each query in the rows estimated by the miners from the query
+	// plan, the query exceeding it is refused before it's executed, 0 means no limit
+	MaxCost uint64
+
+	// ResultCursor makes the miners spill a read query result exceeding their limit into a
+	// server-side cursor, which is paged through by the rows, instead of failing the query
+	ResultCursor bool
+
+	// DegradedReads serves the read queries from a follower marked as possibly stale, while the
+	// leader is restarting or unreachable, instead of failing them
+	DegradedReads bool
+
+	// SkipVerify skips the verification of the responses of the miners, which checks the hashes of
+	// the payload and the request, and the signature of the responder
+	SkipVerify bool
+
+	// ResultCompression is the compression of the read query results accepted from the miners,
+	// only "zstd" is supported, empty means uncompressed
+	ResultCompression string
+
+	// CompressionDict is the zstd dictionary file trained on the repetitive column values, which
+	// is used by the miners holding the same dictionary
+	CompressionDict string
+
+	// Dev is the local SQLite file of a development database, the queries are run by the driver
+	// itself without any block producer or miner
+	Dev string
+
+	// BlobStore is the object store location "s3://bucket/prefix" where the blob arguments of the
+	// write queries not smaller than BlobThreshold are offloaded, the database keeps references to
+	// the objects which are resolved in the read results
+	BlobStore string
+
+	// BlobEndpoint is the endpoint of the S3-compatible service of BlobStore, such as
+	// "https://s3.amazonaws.com" or "http://127.0.0.1:9000"
+	BlobEndpoint string
+
+	// BlobRegion is the signing region of BlobEndpoint, empty means the default region
+	BlobRegion string
+
+	// BlobAccessKey and BlobSecretKey are the credentials of BlobEndpoint
+	BlobAccessKey string
+	BlobSecretKey string
+
+	// BlobThreshold is the min size of the offloaded blobs, 0 means DefaultBlobThreshold
+	BlobThreshold int
 }
 
 // NewConfig creates a new config with default value.
@@ -63,6 +154,56 @@ func (cfg *Config) FormatDSN() string {
 	if cfg.UseDirectRPC {
 		newQuery.Add(paramUseDirectRPC, strconv.FormatBool(cfg.UseDirectRPC))
 	}
+	if cfg.Standby {
+		newQuery.Add(paramStandby, strconv.FormatBool(cfg.Standby))
+		if cfg.StandbyNode != "" {
+			newQuery.Add(paramStandbyNode, cfg.StandbyNode)
+		}
+	}
+	if cfg.AsOfHeight > 0 {
+		newQuery.Add(paramAsOfHeight, strconv.FormatInt(int64(cfg.AsOfHeight), 10))
+	}
+	if cfg.Priority != types.InteractivePriority {
+		newQuery.Add(paramPriority, cfg.Priority.String())
+	}
+	if cfg.MaxExecutionTime > 0 {
+		newQuery.Add(paramMaxExecTime, cfg.MaxExecutionTime.String())
+	}
+	if cfg.MaxCost > 0 {
+		newQuery.Add(paramMaxCost, strconv.FormatUint(cfg.MaxCost, 10))
+	}
+	if cfg.ResultCursor {
+		newQuery.Add(paramResultCursor, strconv.FormatBool(cfg.ResultCursor))
+	}
+	if cfg.DegradedReads {
+		newQuery.Add(paramDegradedRead, strconv.FormatBool(cfg.DegradedReads))
+	}
+	if cfg.SkipVerify {
+		newQuery.Add(paramSkipVerify, strconv.FormatBool(cfg.SkipVerify))
+	}
+	if cfg.ResultCompression != "" {
+		newQuery.Add(paramCompress, cfg.ResultCompression)
+		if cfg.CompressionDict != "" {
+			newQuery.Add(paramCompressDict, cfg.CompressionDict)
+		}
+	}
+	if cfg.Dev != "" {
+		newQuery.Add(paramDev, cfg.Dev)
+	}
+	if cfg.BlobStore != "" {
+		newQuery.Add(paramBlobStore, cfg.BlobStore)
+		newQuery.Add(paramBlobEndpoint, cfg.BlobEndpoint)
+		if cfg.BlobRegion != "" {
+			newQuery.Add(paramBlobRegion, cfg.BlobRegion)
+		}
+		if cfg.BlobAccessKey != "" {
+			newQuery.Add(paramBlobAccessKey, cfg.BlobAccessKey)
+			newQuery.Add(paramBlobSecretKey, cfg.BlobSecretKey)
+		}
+		if cfg.BlobThreshold > 0 {
+			newQuery.Add(paramBlobThreshold, strconv.Itoa(cfg.BlobThreshold))
+		}
+	}
 	u.RawQuery = newQuery.Encode()
 
 	return u.String()
@@ -91,6 +232,57 @@ func ParseDSN(dsn string) (cfg *Config, err error) {
 	}
 	cfg.Mirror = q.Get(paramMirror)
 	cfg.UseDirectRPC, _ = strconv.ParseBool(q.Get(paramUseDirectRPC))
+	cfg.Standby, _ = strconv.ParseBool(q.Get(paramStandby))
+	cfg.StandbyNode = q.Get(paramStandbyNode)
+	if v := q.Get(paramAsOfHeight); v != "" {
+		var height int64
+		if height, err = strconv.ParseInt(v, 10, 32); err != nil || height < 0 {
+			return nil, errors.Errorf("invalid %s: %s", paramAsOfHeight, v)
+		}
+		cfg.AsOfHeight = int32(height)
+	}
+	switch v := q.Get(paramPriority); v {
+	case "", types.InteractivePriority.String():
+	case types.BatchPriority.String():
+		cfg.Priority = types.BatchPriority
+	default:
+		return nil, errors.Errorf("invalid %s: %s", paramPriority, v)
+	}
+	if v := q.Get(paramMaxExecTime); v != "" {
+		if cfg.MaxExecutionTime, err = time.ParseDuration(v); err != nil ||
+			cfg.MaxExecutionTime < 0 {
+			return nil, errors.Errorf("invalid %s: %s", paramMaxExecTime, v)
+		}
+	}
+	if v := q.Get(paramMaxCost); v != "" {
+		if cfg.MaxCost, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return nil, errors.Errorf("invalid %s: %s", paramMaxCost, v)
+		}
+	}
+	cfg.ResultCursor, _ = strconv.ParseBool(q.Get(paramResultCursor))
+	cfg.DegradedReads, _ = strconv.ParseBool(q.Get(paramDegradedRead))
+	cfg.SkipVerify, _ = strconv.ParseBool(q.Get(paramSkipVerify))
+	switch cfg.ResultCompression = q.Get(paramCompress); cfg.ResultCompression {
+	case "", types.ResultCompressionZstd:
+	default:
+		return nil, errors.Errorf("invalid %s: %s", paramCompress, cfg.ResultCompression)
+	}
+	cfg.CompressionDict = q.Get(paramCompressDict)
+	cfg.Dev = q.Get(paramDev)
+	if cfg.BlobStore = q.Get(paramBlobStore); cfg.BlobStore != "" {
+		if !objstore.IsLocation(cfg.BlobStore) {
+			return nil, errors.Errorf("invalid %s: %s", paramBlobStore, cfg.BlobStore)
+		}
+		cfg.BlobEndpoint = q.Get(paramBlobEndpoint)
+		cfg.BlobRegion = q.Get(paramBlobRegion)
+		cfg.BlobAccessKey = q.Get(paramBlobAccessKey)
+		cfg.BlobSecretKey = q.Get(paramBlobSecretKey)
+		if v := q.Get(paramBlobThreshold); v != "" {
+			if cfg.BlobThreshold, err = strconv.Atoi(v); err != nil || cfg.BlobThreshold < 0 {
+				return nil, errors.Errorf("invalid %s: %s", paramBlobThreshold, v)
+			}
+		}
+	}
 
 	return cfg, nil
 }
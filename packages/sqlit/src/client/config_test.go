@@ -3,8 +3,11 @@ package client
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
 )
 
 func TestConfig(t *testing.T) {
@@ -85,4 +88,109 @@ func TestConfig(t *testing.T) {
 		cfg.Mirror = ""
 		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db")
 	})
+
+	Convey("test format and parse dsn with standby option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?standby=true&standby_node=node1")
+		So(err, ShouldBeNil)
+		So(cfg.Standby, ShouldBeTrue)
+		So(cfg.StandbyNode, ShouldEqual, "node1")
+		recoveredCfg, err := ParseDSN(cfg.FormatDSN())
+		So(err, ShouldBeNil)
+		So(recoveredCfg, ShouldResemble, cfg)
+
+		cfg.Standby = false
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db")
+	})
+
+	Convey("test format and parse dsn with as-of height option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?as_of_height=10")
+		So(err, ShouldBeNil)
+		So(cfg.AsOfHeight, ShouldEqual, 10)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?as_of_height=10")
+		cfg.AsOfHeight = 0
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db")
+
+		_, err = ParseDSN("sqlit://db?as_of_height=-1")
+		So(err, ShouldNotBeNil)
+		_, err = ParseDSN("sqlit://db?as_of_height=x")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("test format and parse dsn with priority option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?priority=batch")
+		So(err, ShouldBeNil)
+		So(cfg.Priority, ShouldEqual, types.BatchPriority)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?priority=batch")
+		cfg, err = ParseDSN("sqlit://db?priority=interactive")
+		So(err, ShouldBeNil)
+		So(cfg.Priority, ShouldEqual, types.InteractivePriority)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db")
+
+		_, err = ParseDSN("sqlit://db?priority=x")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("test format and parse dsn with max execution time option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?max_execution_time=1.5s")
+		So(err, ShouldBeNil)
+		So(cfg.MaxExecutionTime, ShouldEqual, 1500*time.Millisecond)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?max_execution_time=1.5s")
+
+		_, err = ParseDSN("sqlit://db?max_execution_time=-1s")
+		So(err, ShouldNotBeNil)
+		_, err = ParseDSN("sqlit://db?max_execution_time=x")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("test format and parse dsn with max cost option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?max_cost=1000")
+		So(err, ShouldBeNil)
+		So(cfg.MaxCost, ShouldEqual, 1000)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?max_cost=1000")
+
+		_, err = ParseDSN("sqlit://db?max_cost=-1")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("test format and parse dsn with result cursor option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?result_cursor=true")
+		So(err, ShouldBeNil)
+		So(cfg.ResultCursor, ShouldBeTrue)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?result_cursor=true")
+		cfg, err = ParseDSN("sqlit://db?result_cursor=false")
+		So(err, ShouldBeNil)
+		So(cfg.ResultCursor, ShouldBeFalse)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db")
+	})
+
+	Convey("test format and parse dsn with degraded reads option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?degraded_reads=true")
+		So(err, ShouldBeNil)
+		So(cfg.DegradedReads, ShouldBeTrue)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?degraded_reads=true")
+		cfg, err = ParseDSN("sqlit://db")
+		So(err, ShouldBeNil)
+		So(cfg.DegradedReads, ShouldBeFalse)
+	})
+
+	Convey("test format and parse dsn with skip verify option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?skip_verify=true")
+		So(err, ShouldBeNil)
+		So(cfg.SkipVerify, ShouldBeTrue)
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?skip_verify=true")
+		cfg, err = ParseDSN("sqlit://db")
+		So(err, ShouldBeNil)
+		So(cfg.SkipVerify, ShouldBeFalse)
+	})
+
+	Convey("test format and parse dsn with result compression option", t, func() {
+		cfg, err := ParseDSN("sqlit://db?compress=zstd&compress_dict=%2Ftmp%2Frows.dict")
+		So(err, ShouldBeNil)
+		So(cfg.ResultCompression, ShouldEqual, "zstd")
+		So(cfg.CompressionDict, ShouldEqual, "/tmp/rows.dict")
+		So(cfg.FormatDSN(), ShouldEqual, "sqlit://db?compress=zstd&compress_dict=%2Ftmp%2Frows.dict")
+
+		_, err = ParseDSN("sqlit://db?compress=gzip")
+		So(err, ShouldNotBeNil)
+	})
 }
@@ -29,12 +29,37 @@ type conn struct {
 	queries     []types.Query
 	localNodeID proto.NodeID
 	privKey     *asymmetric.PrivateKey
+	signer      Signer
 
 	inTransaction bool
+	txCtx         context.Context // context of the current transaction
 	closed        int32
 
 	leader   *pconn
 	follower *pconn
+	fallback *pconn // follower serving the degraded reads, connected on the leader failure
+	standby  bool
+
+	asOfHeight int32 // sqlchain height of the historical state to read, 0 means current
+	priority   types.QueryPriority
+	maxExec    time.Duration // max execution time of each query on the miners, 0 means no limit
+	maxCost    uint64        // max estimated cost of each query in rows, 0 means no limit
+	cursor     bool          // spill the large read results into the server-side cursors
+	verify     bool          // verify the hashes and signatures of the responses
+
+	// decompressor of the read results, nil if the compression is not accepted
+	decompressor *types.ResultDecompressor
+
+	// session variables set by the SET statements, the dsn values are kept in cfg
+	cfg        *Config
+	readLeader bool   // read from the leader even if the connection has a follower
+	maxRows    uint64 // max count of rows in each read result, 0 means the limit of miners
+
+	// databases attached by the ATTACH statements by their aliases, joined in the read queries
+	attached map[string]proto.DatabaseID
+
+	// blob store of the large blob arguments, nil if not configured
+	blobs *blobStore
 }
 
 // pconn represents a connection to a peer.
@@ -60,11 +85,48 @@ func newConn(cfg *Config) (c *conn, err error) {
 		return
 	}
 
+	// get the signer of the requests and acks
+	var signer Signer
+	if signer, _, err = getSigner(); err != nil {
+		return
+	}
+
 	c = &conn{
 		dbID:        proto.DatabaseID(cfg.DatabaseID),
 		localNodeID: localNodeID,
 		privKey:     privKey,
+		signer:      signer,
 		queries:     make([]types.Query, 0),
+		asOfHeight:  cfg.AsOfHeight,
+		priority:    cfg.Priority,
+		maxExec:     cfg.MaxExecutionTime,
+		maxCost:     cfg.MaxCost,
+		cursor:      cfg.ResultCursor,
+		verify:      !cfg.SkipVerify && cfg.Mirror == "",
+		cfg:         cfg,
+	}
+	if c.blobs, err = newBlobStore(cfg); err != nil {
+		return nil, err
+	}
+	if c.decompressor, err = newResultDecompressor(cfg); err != nil {
+		return nil, err
+	}
+
+	// fail fast on a dropped database, a revoked one is confirmed again
+	if err = databaseError(c.dbID); err != nil {
+		if IsDatabaseGone(err) {
+			return nil, err
+		}
+		if err = confirmDatabase(c.dbID); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Standby {
+		if err = c.initStandby(cfg); err != nil {
+			return nil, err
+		}
+		return
 	}
 
 	// get peers from BP
@@ -83,17 +145,11 @@ func newConn(cfg *Config) (c *conn, err error) {
 		// no ack workers required, mirror mode does not support ack worker
 	} else {
 		if cfg.UseLeader {
-			var caller rpc.PCaller
-			if cfg.UseDirectRPC {
-				caller = rpc.NewPersistentCaller(peers.Leader)
-			} else {
-				caller = mux.NewPersistentCaller(peers.Leader)
-			}
 			c.leader = &pconn{
 				wg:      &sync.WaitGroup{},
 				ackCh:   make(chan *types.Ack, workerCount*4),
 				parent:  c,
-				pCaller: caller,
+				pCaller: newPeerCaller(cfg, peers.Leader),
 			}
 		}
 
@@ -102,17 +158,11 @@ func newConn(cfg *Config) (c *conn, err error) {
 			for {
 				node := peers.Servers[randSource.Intn(len(peers.Servers))]
 				if node != peers.Leader {
-					var caller rpc.PCaller
-					if cfg.UseDirectRPC {
-						caller = rpc.NewPersistentCaller(node)
-					} else {
-						caller = mux.NewPersistentCaller(node)
-					}
 					c.follower = &pconn{
 						wg:      &sync.WaitGroup{},
 						ackCh:   make(chan *types.Ack, workerCount*4),
 						parent:  c,
-						pCaller: caller,
+						pCaller: newPeerCaller(cfg, node),
 					}
 					break
 				}
@@ -139,6 +189,14 @@ func newConn(cfg *Config) (c *conn, err error) {
 	return
 }
 
+// newPeerCaller returns a persistent caller to node in the rpc mode of cfg.
+func newPeerCaller(cfg *Config, node proto.NodeID) rpc.PCaller {
+	if cfg.UseDirectRPC {
+		return rpc.NewPersistentCaller(node)
+	}
+	return mux.NewPersistentCaller(node)
+}
+
 func (c *pconn) startAckWorkers() (err error) {
 	for i := 0; i < workerCount; i++ {
 		c.wg.Add(1)
@@ -171,7 +229,7 @@ ackWorkerLoop:
 		oneTime.Do(func() {
 			pc = c.pCaller.New()
 		})
-		if err = ack.Sign(c.parent.privKey); err != nil {
+		if err = ack.Sign(c.parent.signer); err != nil {
 			log.WithField("target", pc.Target()).WithError(err).Error("failed to sign ack")
 			continue
 		}
@@ -217,6 +275,24 @@ func (c *conn) Close() error {
 	if c.follower != nil {
 		c.follower.close()
 	}
+	if c.fallback != nil {
+		c.fallback.close()
+	}
+	return nil
+}
+
+// IsValid implements the driver.Validator.IsValid method, the connections of a dropped or revoked
+// database are closed by the connection pool.
+func (c *conn) IsValid() bool {
+	return atomic.LoadInt32(&c.closed) == 0 && databaseError(c.dbID) == nil
+}
+
+// ResetSession implements the driver.SessionResetter.ResetSession method, the idle connections of
+// a dropped or revoked database are closed before reuse.
+func (c *conn) ResetSession(ctx context.Context) error {
+	if !c.IsValid() {
+		return driver.ErrBadConn
+	}
 	return nil
 }
 
@@ -230,6 +306,9 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 	if atomic.LoadInt32(&c.closed) != 0 {
 		return nil, driver.ErrBadConn
 	}
+	if err := databaseError(c.dbID); err != nil {
+		return nil, err
+	}
 
 	// start transaction
 	log.WithField("inTx", c.inTransaction).Debug("begin transaction")
@@ -240,6 +319,7 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 
 	// TODO(xq262144): make use of the ctx argument
 	c.inTransaction = true
+	c.txCtx = ctx
 	c.queries = c.queries[:0]
 
 	return c, nil
@@ -265,6 +345,26 @@ func (c *conn) ExecContext(ctx context.Context, query string, args []driver.Name
 		err = driver.ErrBadConn
 		return
 	}
+	if err = databaseError(c.dbID); err != nil {
+		return
+	}
+
+	if name, value, ok := parseSetStatement(query); ok {
+		if err = c.setSessionVar(name, value); err == nil {
+			result = &execResult{}
+		}
+		return
+	}
+	if alias, dsn, ok := parseAttachStatement(query); ok {
+		if err = c.attach(alias, dsn); err == nil {
+			result = &execResult{}
+		}
+		return
+	}
+
+	if args, err = c.blobs.offload(ctx, args); err != nil {
+		return
+	}
 
 	// TODO(xq262144): make use of the ctx argument
 	sq := convertQuery(query, args)
@@ -289,10 +389,33 @@ func (c *conn) QueryContext(ctx context.Context, query string, args []driver.Nam
 		err = driver.ErrBadConn
 		return
 	}
+	if err = databaseError(c.dbID); err != nil {
+		return
+	}
+
+	if name, value, ok := parseSetStatement(query); ok {
+		if err = c.setSessionVar(name, value); err == nil {
+			rows = newRows(&types.Response{})
+		}
+		return
+	}
+	if alias, dsn, ok := parseAttachStatement(query); ok {
+		if err = c.attach(alias, dsn); err == nil {
+			rows = newRows(&types.Response{})
+		}
+		return
+	}
 
 	// TODO(xq262144): make use of the ctx argument
 	sq := convertQuery(query, args)
 	_, _, rows, err = c.addQuery(ctx, types.ReadQuery, sq)
+	if IsAttachNotLocal(err) && !c.inTransaction {
+		// the attached databases are served by other miners, join them on the client instead
+		rows, err = c.federatedQuery(ctx, query, args)
+	}
+	if err == nil {
+		c.blobs.attachRows(ctx, rows)
+	}
 
 	return
 }
@@ -310,11 +433,16 @@ func (c *conn) Commit() (err error) {
 	defer func() {
 		c.queries = c.queries[:0]
 		c.inTransaction = false
+		c.txCtx = nil
 	}()
 
 	if len(c.queries) > 0 {
-		// send query
-		if _, _, _, err = c.sendQuery(context.Background(), types.WriteQuery, c.queries); err != nil {
+		// send query, the idempotency key of the transaction context is kept
+		ctx := c.txCtx
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if _, _, _, err = c.sendQuery(ctx, types.WriteQuery, c.queries); err != nil {
 			return
 		}
 	}
@@ -335,6 +463,7 @@ func (c *conn) Rollback() error {
 	defer func() {
 		c.queries = c.queries[:0]
 		c.inTransaction = false
+		c.txCtx = nil
 	}()
 
 	if len(c.queries) == 0 {
@@ -372,21 +501,136 @@ func (c *conn) addQuery(ctx context.Context, queryType types.QueryType, query *t
 	return c.sendQuery(ctx, queryType, []types.Query{*query})
 }
 
+// initStandby connects to the standby node in cfg or a random peer of the database, queries are
+// served from the local snapshot of the node without acknowledgement.
+func (c *conn) initStandby(cfg *Config) (err error) {
+	var node = proto.NodeID(cfg.StandbyNode)
+	if node == "" {
+		var peers *proto.Peers
+		if peers, err = cacheGetPeers(c.dbID, c.privKey); err != nil {
+			return errors.WithMessage(err, "cacheGetPeers failed")
+		}
+		if len(peers.Servers) == 0 {
+			return errors.New("no standby peers found")
+		}
+		node = peers.Servers[randSource.Intn(len(peers.Servers))]
+	}
+
+	c.standby = true
+	c.follower = &pconn{
+		wg:      &sync.WaitGroup{},
+		parent:  c,
+		pCaller: newPeerCaller(cfg, node),
+	}
+	log.WithFields(log.Fields{
+		"db":   c.dbID,
+		"node": node,
+	}).Debug("new standby connection to database")
+	return
+}
+
 func (c *conn) sendQuery(ctx context.Context, queryType types.QueryType, queries []types.Query) (affectedRows int64, lastInsertID int64, rows driver.Rows, err error) {
-	var uc *pconn // peer connection used to execute the queries
+	defer func() {
+		// the database dropped or revoked is confirmed with the block producers
+		err = confirmQueryError(c.dbID, err)
+	}()
+	affectedRows, lastInsertID, rows, err = c.sendAttachedQuery(ctx, queryType, queries, c.attached)
+	// the queries refused by a stale leader are not executed, retry once with the current leader
+	if IsLeaderChanged(err) {
+		if changed, lerr := c.switchLeader(); lerr == nil && changed {
+			affectedRows, lastInsertID, rows, err = c.sendAttachedQuery(
+				ctx, queryType, queries, c.attached)
+		}
+	}
+	// the reads failed by a restarting leader are served by a follower if they may be stale
+	if c.acceptDegradedRead(ctx, queryType, err) {
+		return c.sendDegradedRead(ctx, queries, err)
+	}
+	return
+}
 
+// queryPeer returns the peer connection serving the queries of queryType.
+func (c *conn) queryPeer(queryType types.QueryType) (uc *pconn) {
 	uc = c.leader
-	// use follower pconn only when the query is readonly
-	if queryType == types.ReadQuery && c.follower != nil {
+	// use follower pconn only when the query is readonly and not required to be consistent
+	if queryType == types.ReadQuery && c.follower != nil && !c.readLeader {
 		uc = c.follower
 	}
 	if uc == nil {
 		uc = c.follower
 	}
+	return
+}
+
+// sendAttachedQuery sends the queries like sendQuery, the read queries are joined with the
+// attached databases if any.
+func (c *conn) sendAttachedQuery(ctx context.Context, queryType types.QueryType, queries []types.Query,
+	attached map[string]proto.DatabaseID) (affectedRows int64, lastInsertID int64, rows driver.Rows, err error) {
+	var (
+		uc     *pconn // peer connection used to execute the queries
+		method = route.DBSQuery
+	)
+
+	if c.standby {
+		if queryType != types.ReadQuery {
+			err = ErrStandbyReadOnly
+			return
+		}
+		method = route.DBSStandbyQuery
+	}
+
+	// read the historical state if the queries or the connection is set with an as-of height
+	var asOfHeight, asOf = c.asOfHeight, c.asOfHeight > 0
+	if queryType == types.ReadQuery {
+		var (
+			height int32
+			ok     bool
+		)
+		if height, ok, err = stripAsOfHeight(queries); err != nil {
+			return
+		}
+		if ok {
+			asOfHeight, asOf = height, true
+		}
+	}
+	if asOf {
+		if queryType != types.ReadQuery {
+			err = ErrAsOfReadOnly
+			return
+		}
+		method = route.DBSAsOfQuery
+	}
+
+	// the attached databases are joined by the miner serving the queries without tracking
+	var attach = queryType == types.ReadQuery && len(attached) > 0
+	if attach {
+		if asOf || c.standby {
+			err = errors.Wrap(ErrAttachUnsupported, "historical or standby query")
+			return
+		}
+		method = route.DBSAttachedQuery
+	}
+
+	// the degraded reads are sent to the fallback follower, which marks them as possibly stale
+	var stale = queryType == types.ReadQuery && isStaleRead(ctx) && c.fallback != nil
+	if uc = c.queryPeer(queryType); stale {
+		uc = c.fallback
+	}
+
+	// allocate sequence, an idempotent write is resumable after the driver restarts
+	var connID, seqNo uint64
+	if key, ok := GetIdempotencyKey(ctx); ok && queryType == types.WriteQuery {
+		connID, seqNo = allocateWrite(key)
+		defer finishWrite(key, connID)
+	} else {
+		connID, seqNo = allocateConnAndSeq()
+		defer putBackConn(connID)
+	}
 
-	// allocate sequence
-	connID, seqNo := allocateConnAndSeq()
-	defer putBackConn(connID)
+	traceID, ok := GetTraceID(ctx)
+	if !ok || traceID == "" {
+		traceID = NewTraceID()
+	}
 
 	defer func() {
 		log.WithFields(log.Fields{
@@ -396,6 +640,7 @@ func (c *conn) sendQuery(ctx context.Context, queryType types.QueryType, queries
 			"seqNo":  seqNo,
 			"target": uc.pCaller.Target(),
 			"source": c.localNodeID,
+			"trace":  traceID,
 		}).WithError(err).Debug("send query")
 	}()
 
@@ -416,22 +661,147 @@ func (c *conn) sendQuery(ctx context.Context, queryType types.QueryType, queries
 		},
 	}
 
-	if err = req.Sign(c.privKey); err != nil {
+	if key, ok := GetIdempotencyKey(ctx); ok && queryType == types.WriteQuery {
+		if err = req.Header.SetIdempotencyKey(key); err != nil {
+			return
+		}
+	}
+
+	if asOf {
+		if err = req.Header.SetAsOfHeight(asOfHeight); err != nil {
+			return
+		}
+	}
+
+	priority := c.priority
+	if p, ok := GetPriority(ctx); ok {
+		priority = p
+	}
+	if priority != types.InteractivePriority {
+		if err = req.Header.SetPriority(priority); err != nil {
+			return
+		}
+	}
+
+	maxExec := c.maxExec
+	if d, ok := GetMaxExecutionTime(ctx); ok {
+		maxExec = d
+	}
+	if maxExec > 0 {
+		if err = req.Header.SetMaxExecutionTime(maxExec); err != nil {
+			return
+		}
+	}
+
+	maxCost := c.maxCost
+	if n, ok := GetMaxCost(ctx); ok {
+		maxCost = n
+	}
+	if maxCost > 0 {
+		if err = req.Header.SetMaxCost(maxCost); err != nil {
+			return
+		}
+	}
+
+	if stale {
+		if err = req.Header.SetStaleRead(true); err != nil {
+			return
+		}
+	}
+
+	if c.maxRows > 0 && queryType == types.ReadQuery {
+		if err = req.Header.SetResultMaxRows(c.maxRows); err != nil {
+			return
+		}
+	}
+
+	if attach {
+		if err = req.Header.SetAttachedDatabases(attached); err != nil {
+			return
+		}
+	}
+
+	// the historical, standby and attached reads are not spilled, they fail on a large result
+	if c.cursor && queryType == types.ReadQuery && method == route.DBSQuery {
+		if err = req.Header.SetResultCursor(true); err != nil {
+			return
+		}
+	}
+
+	// the historical, standby and attached reads are not compressed either
+	if c.decompressor != nil && queryType == types.ReadQuery && method == route.DBSQuery {
+		if err = req.Header.SetResultCompression(
+			types.ResultCompressionZstd, c.decompressor.DictID(),
+		); err != nil {
+			return
+		}
+	}
+
+	if IsColumnar(ctx) && queryType == types.ReadQuery && method == route.DBSQuery {
+		if err = req.Header.SetResultEncoding(types.ResultEncodingColumnar); err != nil {
+			return
+		}
+	}
+
+	if err = req.Header.SetTraceID(traceID); err != nil {
+		return
+	}
+
+	if err = req.Sign(c.signer); err != nil {
 		return
 	}
 
 	// set receipt if key exists in context
+	var receipt *Receipt
 	if val := ctx.Value(&ctxReceiptKey); val != nil {
-		val.(*atomic.Value).Store(&Receipt{
+		receipt = &Receipt{
 			RequestHash: req.Header.Hash(),
-		})
+			TraceID:     traceID,
+		}
+		val.(*atomic.Value).Store(receipt)
 	}
 
 	var response types.Response
-	if err = uc.pCaller.Call(route.DBSQuery.String(), req, &response); err != nil {
+	if err = uc.pCaller.Call(method.String(), req, &response); err != nil {
 		return
 	}
-	rows = newRows(&response)
+	if response.Compression != "" {
+		if c.decompressor == nil {
+			err = errors.Errorf("unexpected result compression: %s", response.Compression)
+			return
+		}
+		if err = c.decompressor.Decompress(&response); err != nil {
+			return
+		}
+	}
+	if _, err = types.DecodeColumnar(&response); err != nil {
+		return
+	}
+	if c.verify {
+		if err = verifyResponse(req, &response); err != nil {
+			return
+		}
+	}
+	if bound, ok := response.Header.Staleness(); ok && receipt != nil {
+		receipt.Stale, receipt.Staleness = true, bound
+	}
+	r := newRows(&response)
+	if response.Cursor != "" {
+		// the cursor is kept by the miner which served the query
+		r.fetch = func(cursor string, close bool) (res *types.Response, err error) {
+			res = new(types.Response)
+			err = uc.pCaller.Call(route.DBSFetchCursor.String(), &types.FetchCursorReq{
+				DatabaseID: c.dbID,
+				Cursor:     cursor,
+				Close:      close,
+			}, res)
+			if err == nil && !close && c.verify {
+				err = verifyResponse(req, res)
+			}
+			return
+		}
+	}
+	rows = r
 
 	if queryType == types.WriteQuery {
 		affectedRows = response.Header.AffectedRows
@@ -441,7 +811,9 @@ func (c *conn) sendQuery(ctx context.Context, queryType types.QueryType, queries
 	// build ack
 	func() {
 		defer trace.StartRegion(ctx, "ackEnqueue").End()
-		if uc.ackCh != nil {
+		// the historical and attached queries are not tracked by the miner, so they are never
+		// acknowledged
+		if uc.ackCh != nil && !asOf && !attach {
 			uc.ackCh <- &types.Ack{
 				Header: types.SignedAckHeader{
 					AckHeader: types.AckHeader{
@@ -458,6 +830,26 @@ func (c *conn) sendQuery(ctx context.Context, queryType types.QueryType, queries
 	return
 }
 
+// verifyResponse verifies the hashes and the responder signature of the response, and that the
+// response answers the request. The cached response of an idempotent write answers the first
+// request of the same node with the idempotency key.
+func verifyResponse(req *types.Request, res *types.Response) (err error) {
+	if err = res.Verify(); err != nil {
+		return errors.Wrap(ErrResponseTampered, err.Error())
+	}
+	var rh = &res.Header.Request
+	if res.Header.RequestHash == req.Header.Hash() &&
+		rh.GetQueryKey() == req.Header.GetQueryKey() {
+		return
+	}
+	if key := req.Header.IdempotencyKey(); key != "" && rh.IdempotencyKey() == key &&
+		rh.NodeID == req.Header.NodeID && rh.DatabaseID == req.Header.DatabaseID {
+		return
+	}
+	return errors.Wrapf(ErrResponseTampered,
+		"response of request %s doesn't answer request %s", res.Header.RequestHash, req.Header.Hash())
+}
+
 func getLocalTime() time.Time {
 	return time.Now().UTC()
 }
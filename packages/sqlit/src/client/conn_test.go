@@ -7,8 +7,13 @@ import (
 	"sync"
 	"testing"
 
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
 	"sqlit/src/utils/log"
 )
 
@@ -46,6 +51,16 @@ func TestConn(t *testing.T) {
 		So(ok, ShouldBeTrue)
 		So(rec2, ShouldNotBeNil)
 		So(rec, ShouldNotEqual, rec2) // receipt should be reset
+		So(rec2.TraceID, ShouldHaveLength, 32)
+		So(rec2.TraceID, ShouldNotEqual, rec.TraceID) // trace id is generated for each query
+
+		_, err = db.ExecContext(WithTraceID(ctx, "user-trace"), "insert into test values (1)")
+		So(err, ShouldBeNil)
+		rec2, ok = GetReceipt(ctx)
+		So(ok, ShouldBeTrue)
+		So(rec2.TraceID, ShouldEqual, "user-trace")
+		_, err = db.ExecContext(ctx, "delete from test where rowid > 1")
+		So(err, ShouldBeNil)
 
 		// test with query
 		var rows *sql.Rows
@@ -303,3 +318,71 @@ func TestConnAndSeqAllocation(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+func TestVerifyResponse(t *testing.T) {
+	Convey("Given a signed response of a request", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		addr, err := crypto.PubKeyHash(priv.PubKey())
+		So(err, ShouldBeNil)
+		var (
+			nodeID = proto.NodeID(
+				"00000000000000000000000000000000000000000000000000000000000000aa")
+			newRequest = func(seqNo uint64) *types.Request {
+				req := &types.Request{
+					Header: types.SignedRequestHeader{
+						RequestHeader: types.RequestHeader{
+							QueryType:  types.WriteQuery,
+							NodeID:     nodeID,
+							DatabaseID: proto.DatabaseID("db"),
+							SeqNo:      seqNo,
+							Timestamp:  getLocalTime(),
+						},
+					},
+					Payload: types.RequestPayload{Queries: []types.Query{
+						{Pattern: "INSERT INTO t VALUES (1)"},
+					}},
+				}
+				So(req.Header.SetIdempotencyKey("key"), ShouldBeNil)
+				So(req.Sign(priv), ShouldBeNil)
+				return req
+			}
+			req = newRequest(1)
+			res = &types.Response{
+				Header: types.SignedResponseHeader{
+					ResponseHeader: types.ResponseHeader{
+						Request:         req.Header.RequestHeader,
+						RequestHash:     req.Header.Hash(),
+						NodeID:          nodeID,
+						Timestamp:       getLocalTime(),
+						AffectedRows:    1,
+						ResponseAccount: addr,
+					},
+				},
+			}
+		)
+		So(res.BuildHash(), ShouldBeNil)
+		So(res.Sign(priv), ShouldBeNil)
+
+		Convey("The response should be verified", func() {
+			So(verifyResponse(req, res), ShouldBeNil)
+		})
+		Convey("The cached response of the idempotent write should be verified", func() {
+			So(verifyResponse(newRequest(2), res), ShouldBeNil)
+		})
+		Convey("The tampered response should be refused", func() {
+			res.Header.AffectedRows = 2
+			So(errors.Cause(verifyResponse(req, res)), ShouldEqual, ErrResponseTampered)
+		})
+		Convey("The response of another request should be refused", func() {
+			var other = newRequest(3)
+			So(other.Header.SetIdempotencyKey("other"), ShouldBeNil)
+			So(other.Sign(priv), ShouldBeNil)
+			So(errors.Cause(verifyResponse(other, res)), ShouldEqual, ErrResponseTampered)
+		})
+		Convey("The unsigned response should be refused", func() {
+			res.Signature = nil
+			So(errors.Cause(verifyResponse(req, res)), ShouldEqual, ErrResponseTampered)
+		})
+	})
+}
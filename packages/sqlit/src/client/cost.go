@@ -0,0 +1,119 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/route"
+	"sqlit/src/types"
+)
+
+var (
+	ctxMaxCostKey = "_sqlit_max_cost"
+)
+
+// WithMaxCost returns a context which sends the queries with the max cost in rows, it overrides
+// the max_cost option of the connection. The miners refuse a query estimated by its query plan to
+// visit more rows, before it's executed.
+func WithMaxCost(ctx context.Context, rows uint64) context.Context {
+	return context.WithValue(ctx, &ctxMaxCostKey, rows)
+}
+
+// GetMaxCost tries to get the max cost from context.
+func GetMaxCost(ctx context.Context) (rows uint64, ok bool) {
+	rows, ok = ctx.Value(&ctxMaxCostKey).(uint64)
+	return
+}
+
+// EstimateQuery returns the cost of the query estimated by the miner from its query plan on the
+// current state of the database, the query is not executed. The cost is a rough count of the rows
+// and pages visited, which is meant to tell the full scans of the large tables from the index
+// lookups before running the query.
+func EstimateQuery(ctx context.Context, db *sql.DB, query string, args ...interface{}) (
+	cost *types.QueryCost, err error,
+) {
+	var sc *sql.Conn
+	if sc, err = db.Conn(ctx); err != nil {
+		return
+	}
+	defer func() { _ = sc.Close() }()
+	err = sc.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*conn)
+		if !ok {
+			return errors.Errorf("unexpected driver connection: %T", driverConn)
+		}
+		var nvs = make([]driver.NamedValue, len(args))
+		for i, v := range args {
+			nvs[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+			if na, ok := v.(sql.NamedArg); ok {
+				nvs[i].Name, nvs[i].Value = na.Name, na.Value
+			}
+		}
+		var costs []types.QueryCost
+		if costs, err = c.estimate(ctx, []types.Query{*convertQuery(query, nvs)}); err != nil {
+			return err
+		}
+		if len(costs) != 1 {
+			return errors.Errorf("unexpected estimated costs count: %d", len(costs))
+		}
+		cost = &costs[0]
+		return nil
+	})
+	return
+}
+
+// estimate sends the queries to estimate their costs on the peer serving the read queries.
+func (c *conn) estimate(ctx context.Context, queries []types.Query) (
+	costs []types.QueryCost, err error,
+) {
+	if c.standby || c.cfg.Mirror != "" {
+		err = errors.New("cost estimation is unsupported by standby or mirror connection")
+		return
+	}
+	var uc = c.leader
+	if (c.follower != nil && !c.readLeader) || uc == nil {
+		uc = c.follower
+	}
+
+	connID, seqNo := allocateConnAndSeq()
+	defer putBackConn(connID)
+
+	// the queries are estimated as read queries, so the read-only accounts can't learn the plans
+	// of the writes they can't run
+	req := &types.Request{
+		Header: types.SignedRequestHeader{
+			RequestHeader: types.RequestHeader{
+				QueryType:    types.ReadQuery,
+				NodeID:       c.localNodeID,
+				DatabaseID:   c.dbID,
+				ConnectionID: connID,
+				SeqNo:        seqNo,
+				Timestamp:    getLocalTime(),
+			},
+		},
+		Payload: types.RequestPayload{
+			Queries: queries,
+		},
+	}
+	maxExec := c.maxExec
+	if d, ok := GetMaxExecutionTime(ctx); ok {
+		maxExec = d
+	}
+	if maxExec > 0 {
+		if err = req.Header.SetMaxExecutionTime(maxExec); err != nil {
+			return
+		}
+	}
+	if err = req.Sign(c.signer); err != nil {
+		return
+	}
+
+	var resp types.EstimateQueryResp
+	if err = uc.pCaller.Call(route.DBSEstimateQuery.String(), req, &resp); err != nil {
+		return
+	}
+	return resp.Costs, nil
+}
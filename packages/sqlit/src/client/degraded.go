@@ -0,0 +1,106 @@
+package client
+
+import (
+	"context"
+	"database/sql/driver"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+var (
+	ctxDegradedReadsKey = "_sqlit_degraded_reads"
+	ctxStaleReadKey     = "_sqlit_stale_read"
+)
+
+// WithDegradedReads returns a context which accepts the degraded reads like the degraded_reads
+// option of the connection: a read query failed by the leader restarting or refusing the
+// connections is served by a follower instead, the result is marked as possibly stale in the
+// Receipt of the query with the staleness bound of the follower.
+func WithDegradedReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, &ctxDegradedReadsKey, true)
+}
+
+// IsDegradedReads returns whether the context accepts the degraded reads.
+func IsDegradedReads(ctx context.Context) bool {
+	degraded, _ := ctx.Value(&ctxDegradedReadsKey).(bool)
+	return degraded
+}
+
+// withStaleRead returns a context which sends the read queries to the fallback follower.
+func withStaleRead(ctx context.Context) context.Context {
+	return context.WithValue(ctx, &ctxStaleReadKey, true)
+}
+
+func isStaleRead(ctx context.Context) bool {
+	stale, _ := ctx.Value(&ctxStaleReadKey).(bool)
+	return stale
+}
+
+// acceptDegradedRead returns whether the read query failed by err may be served by a follower,
+// the reads already served by a follower are not retried.
+func (c *conn) acceptDegradedRead(ctx context.Context, queryType types.QueryType, err error) bool {
+	// the attached databases are joined by the follower without the staleness bound
+	if queryType != types.ReadQuery || c.standby || c.cfg.Mirror != "" || len(c.attached) > 0 ||
+		!(c.cfg.DegradedReads || IsDegradedReads(ctx)) {
+		return false
+	}
+	return c.leader != nil && c.queryPeer(queryType) == c.leader && IsLeaderUnavailable(err)
+}
+
+// sendDegradedRead sends the read queries failed by the unavailable leader to a follower, the
+// response is marked by the follower with the staleness bound of its state.
+func (c *conn) sendDegradedRead(ctx context.Context, queries []types.Query, leaderErr error) (
+	affectedRows int64, lastInsertID int64, rows driver.Rows, err error,
+) {
+	if err = c.connectFallback(); err != nil {
+		log.WithField("db", c.dbID).WithError(err).Debug("connect fallback follower failed")
+		err = leaderErr
+		return
+	}
+	log.WithFields(log.Fields{
+		"db":       c.dbID,
+		"leader":   c.leader.pCaller.Target(),
+		"follower": c.fallback.pCaller.Target(),
+	}).WithError(leaderErr).Info("leader unavailable, serving degraded read from follower")
+	return c.sendAttachedQuery(withStaleRead(ctx), types.ReadQuery, queries, c.attached)
+}
+
+// connectFallback connects a random follower of the database to serve the degraded reads, the
+// connection is kept until the conn is closed.
+func (c *conn) connectFallback() (err error) {
+	if c.fallback != nil {
+		return
+	}
+	var peers *proto.Peers
+	if peers, err = cacheGetPeers(c.dbID, c.privKey); err != nil {
+		return errors.WithMessage(err, "cacheGetPeers failed")
+	}
+	var (
+		leader    = proto.NodeID(c.leader.pCaller.Target())
+		followers = make([]proto.NodeID, 0, len(peers.Servers))
+	)
+	for _, s := range peers.Servers {
+		if s != leader && s != peers.Leader {
+			followers = append(followers, s)
+		}
+	}
+	if len(followers) == 0 {
+		return errors.New("no follower peers found")
+	}
+	var fallback = &pconn{
+		wg:      &sync.WaitGroup{},
+		ackCh:   make(chan *types.Ack, workerCount*4),
+		parent:  c,
+		pCaller: newPeerCaller(c.cfg, followers[randSource.Intn(len(followers))]),
+	}
+	if err = fallback.startAckWorkers(); err != nil {
+		return errors.WithMessage(err, "fallback startAckWorkers failed")
+	}
+	c.fallback = fallback
+	return
+}
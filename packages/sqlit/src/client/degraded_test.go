@@ -0,0 +1,91 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/types"
+)
+
+func TestDegradedReads(t *testing.T) {
+	Convey("Given a connection to the leader accepting the degraded reads", t, func() {
+		var (
+			dbID  = proto.DatabaseID("degraded-test")
+			peers = &proto.Peers{
+				PeersHeader: proto.PeersHeader{
+					Leader:  "node1",
+					Servers: []proto.NodeID{"node1", "node2"},
+				},
+			}
+			c = &conn{
+				dbID: dbID,
+				cfg:  &Config{UseLeader: true, DegradedReads: true},
+				leader: &pconn{
+					wg:      &sync.WaitGroup{},
+					pCaller: mux.NewPersistentCaller("node1"),
+				},
+			}
+			ctx         = context.Background()
+			refused     = errors.Wrap(syscall.ECONNREFUSED, "dial node1")
+			shuttingErr = errors.New(types.ErrCodeShuttingDown + ": miner is shutting down")
+		)
+		peerList.Store(dbID, peers)
+		defer peerList.Delete(dbID)
+		defer c.Close()
+
+		Convey("The restarting leader should be detected by the errors", func() {
+			So(IsLeaderUnavailable(refused), ShouldBeTrue)
+			So(IsLeaderUnavailable(errors.New("call DBS.Query failed: connection refused")),
+				ShouldBeTrue)
+			So(IsLeaderUnavailable(shuttingErr), ShouldBeTrue)
+			So(IsLeaderUnavailable(errors.New(types.ErrCodeOverloaded)), ShouldBeFalse)
+			So(IsLeaderUnavailable(nil), ShouldBeFalse)
+		})
+		Convey("The reads failed by the restarting leader should be degraded", func() {
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, refused), ShouldBeTrue)
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, shuttingErr), ShouldBeTrue)
+			So(c.acceptDegradedRead(ctx, types.WriteQuery, refused), ShouldBeFalse)
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, errors.New("syntax error")),
+				ShouldBeFalse)
+			c.attached = map[string]proto.DatabaseID{"other": "other-db"}
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, refused), ShouldBeFalse)
+		})
+		Convey("The degraded reads should be accepted by the context only", func() {
+			c.cfg.DegradedReads = false
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, refused), ShouldBeFalse)
+			So(IsDegradedReads(ctx), ShouldBeFalse)
+			ctx = WithDegradedReads(ctx)
+			So(IsDegradedReads(ctx), ShouldBeTrue)
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, refused), ShouldBeTrue)
+		})
+		Convey("The reads served by a follower should not be degraded again", func() {
+			c.follower = &pconn{
+				wg:      &sync.WaitGroup{},
+				pCaller: mux.NewPersistentCaller("node2"),
+			}
+			So(c.acceptDegradedRead(ctx, types.ReadQuery, refused), ShouldBeFalse)
+		})
+		Convey("The fallback follower should be connected once", func() {
+			So(c.connectFallback(), ShouldBeNil)
+			So(c.fallback, ShouldNotBeNil)
+			So(c.fallback.pCaller.Target(), ShouldEqual, "node2")
+			var fallback = c.fallback
+			So(c.connectFallback(), ShouldBeNil)
+			So(c.fallback, ShouldEqual, fallback)
+		})
+		Convey("The fallback should fail without any follower", func() {
+			peers.Servers = []proto.NodeID{"node1"}
+			So(c.connectFallback(), ShouldNotBeNil)
+			So(c.fallback, ShouldBeNil)
+			_, _, _, err := c.sendDegradedRead(ctx, nil, refused)
+			So(err, ShouldEqual, refused)
+		})
+	})
+}
@@ -0,0 +1,206 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto/asymmetric"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc"
+	"sqlit/src/types"
+)
+
+// DevDatabaseID is the database id of the dsn returned by DevDSN.
+const DevDatabaseID = "dev"
+
+var (
+	// devNodeID is the request node of the development connections.
+	devNodeID = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000000")
+
+	devKeyOnce sync.Once
+	devKey     *asymmetric.PrivateKey
+	devKeyErr  error
+
+	devDatabasesLock sync.Mutex
+	devDatabases     = make(map[string]*devDatabase)
+)
+
+// DevDSN returns the dsn of a local-only development database kept in the SQLite file. The
+// queries are run by the driver itself through the same sanitizer as the miners, without any
+// block producer or miner, and the driver needs no initialization.
+func DevDSN(file string) string {
+	var cfg = NewConfig()
+	cfg.DatabaseID = DevDatabaseID
+	cfg.Dev = file
+	return cfg.FormatDSN()
+}
+
+// getDevKey returns the ephemeral key signing the requests of the development connections.
+func getDevKey() (*asymmetric.PrivateKey, error) {
+	devKeyOnce.Do(func() {
+		devKey, _, devKeyErr = asymmetric.GenSecp256k1KeyPair()
+	})
+	return devKey, devKeyErr
+}
+
+// devDatabase is a development database shared by the connections to the same file.
+type devDatabase struct {
+	file string
+	refs int
+	st   *x.State
+}
+
+func openDevDatabase(file string) (db *devDatabase, err error) {
+	if file, err = filepath.Abs(file); err != nil {
+		return
+	}
+	devDatabasesLock.Lock()
+	defer devDatabasesLock.Unlock()
+	if db = devDatabases[file]; db != nil {
+		db.refs++
+		return
+	}
+	var strg *xs.SQLite3
+	if strg, err = xs.NewSqlite(file); err != nil {
+		err = errors.Wrapf(err, "open development database %s", file)
+		return
+	}
+	db = &devDatabase{
+		file: file,
+		refs: 1,
+		st:   x.NewState(sql.LevelDefault, devNodeID, strg),
+	}
+	devDatabases[file] = db
+	return
+}
+
+func (db *devDatabase) acquire() *devDatabase {
+	devDatabasesLock.Lock()
+	defer devDatabasesLock.Unlock()
+	db.refs++
+	return db
+}
+
+func (db *devDatabase) release() (err error) {
+	devDatabasesLock.Lock()
+	defer devDatabasesLock.Unlock()
+	if db.refs--; db.refs > 0 {
+		return
+	}
+	delete(devDatabases, db.file)
+	return db.st.Close(true)
+}
+
+func (db *devDatabase) query(req *types.Request) (resp *types.Response, err error) {
+	var (
+		ctx    = context.Background()
+		cancel context.CancelFunc
+	)
+	if d := req.Header.MaxExecutionTime(); d > 0 {
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	if err = db.st.CheckCost(ctx, req); err != nil {
+		return
+	}
+	if _, resp, err = db.st.QueryWithContext(ctx, req, true); err != nil {
+		return
+	}
+	if req.Header.QueryType == types.WriteQuery {
+		// no block is produced locally, commit and drop the pooled queries right away
+		if _, _, err = db.st.CommitEx(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// devCaller serves the requests of a development connection with the local database, instead of
+// calling the miners.
+type devCaller struct {
+	db   *devDatabase
+	once sync.Once
+}
+
+var _ rpc.PCaller = (*devCaller)(nil)
+
+// Call implements rpc.PCaller.Call.
+func (c *devCaller) Call(method string, request interface{}, reply interface{}) (err error) {
+	switch method {
+	case route.DBSQuery.String():
+		var resp *types.Response
+		if resp, err = c.db.query(request.(*types.Request)); err != nil {
+			return
+		}
+		*reply.(*types.Response) = *resp
+	case route.DBSEstimateQuery.String():
+		var req = request.(*types.Request)
+		reply.(*types.EstimateQueryResp).Costs, err = c.db.st.Estimate(context.Background(), req)
+	case route.DBSAck.String():
+		// the queries are not tracked locally
+	default:
+		err = errors.Wrapf(ErrDevUnsupported, "method %s", method)
+	}
+	return
+}
+
+// Close implements rpc.PCaller.Close.
+func (c *devCaller) Close() {
+	c.once.Do(func() { _ = c.db.release() })
+}
+
+// Target implements rpc.PCaller.Target.
+func (c *devCaller) Target() string {
+	return "dev:" + c.db.file
+}
+
+// New implements rpc.PCaller.New.
+func (c *devCaller) New() rpc.PCaller {
+	return &devCaller{db: c.db.acquire()}
+}
+
+// newDevConn creates a connection to the development database of cfg.
+func newDevConn(cfg *Config) (c *conn, err error) {
+	if cfg.Standby || cfg.Mirror != "" {
+		err = errors.Wrap(ErrDevUnsupported, "standby or mirror connection")
+		return
+	}
+	var privKey *asymmetric.PrivateKey
+	if privKey, err = getDevKey(); err != nil {
+		return
+	}
+	var db *devDatabase
+	if db, err = openDevDatabase(cfg.Dev); err != nil {
+		return
+	}
+	c = &conn{
+		dbID:        proto.DatabaseID(cfg.DatabaseID),
+		localNodeID: devNodeID,
+		privKey:     privKey,
+		signer:      privKey,
+		queries:     make([]types.Query, 0),
+		asOfHeight:  cfg.AsOfHeight,
+		priority:    cfg.Priority,
+		maxExec:     cfg.MaxExecutionTime,
+		maxCost:     cfg.MaxCost,
+		cursor:      cfg.ResultCursor,
+		cfg:         cfg,
+		leader: &pconn{
+			wg:      &sync.WaitGroup{},
+			pCaller: &devCaller{db: db},
+		},
+	}
+	c.leader.parent = c
+	if c.blobs, err = newBlobStore(cfg); err != nil {
+		_ = db.release()
+		return nil, err
+	}
+	return
+}
@@ -0,0 +1,109 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDevDatabase(t *testing.T) {
+	Convey("Given a development database", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit_dev")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		var dsn = DevDSN(filepath.Join(dir, "dev.db3"))
+		cfg, err := ParseDSN(dsn)
+		So(err, ShouldBeNil)
+		So(cfg.DatabaseID, ShouldEqual, DevDatabaseID)
+		So(cfg.Dev, ShouldEqual, filepath.Join(dir, "dev.db3"))
+		So(WaitDBCreation(context.Background(), dsn), ShouldBeNil)
+
+		db, err := sql.Open(DBScheme, dsn)
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		Convey("The queries should be run locally", func() {
+			_, err = db.Exec("CREATE TABLE test (id INT PRIMARY KEY, v TEXT)")
+			So(err, ShouldBeNil)
+			res, err := db.Exec("INSERT INTO test VALUES (?, ?)", 1, "a")
+			So(err, ShouldBeNil)
+			n, err := res.RowsAffected()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+
+			tx, err := db.Begin()
+			So(err, ShouldBeNil)
+			_, err = tx.Exec("INSERT INTO test VALUES (?, ?)", 2, "b")
+			So(err, ShouldBeNil)
+			_, err = tx.Exec("INSERT INTO test VALUES (?, ?)", 3, "c")
+			So(err, ShouldBeNil)
+			So(tx.Commit(), ShouldBeNil)
+
+			var count int
+			So(db.QueryRow("SELECT COUNT(1) FROM test").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			// the changes are kept in the file after reopening
+			So(db.Close(), ShouldBeNil)
+			db, err = sql.Open(DBScheme, dsn)
+			So(err, ShouldBeNil)
+			var v string
+			So(db.QueryRow("SELECT v FROM test WHERE id = ?", 3).Scan(&v), ShouldBeNil)
+			So(v, ShouldEqual, "c")
+		})
+		Convey("The queries should be sanitized as on the miners", func() {
+			_, err = db.Exec("CREATE TABLE test (id INT, v BLOB)")
+			So(err, ShouldBeNil)
+			_, err = db.Exec("INSERT INTO test VALUES (1, randomblob(16))")
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The queries exceeding the max cost should be refused", func() {
+			_, err = db.Exec("CREATE TABLE test (id INTEGER PRIMARY KEY, v TEXT)")
+			So(err, ShouldBeNil)
+			for i := 1; i <= 100; i++ {
+				_, err = db.Exec("INSERT INTO test VALUES (?, ?)", i, "a")
+				So(err, ShouldBeNil)
+			}
+			cost, err := EstimateQuery(context.Background(), db, "SELECT * FROM test WHERE v = ?", "a")
+			So(err, ShouldBeNil)
+			So(cost.Rows, ShouldEqual, 100)
+			So(cost.FullScans, ShouldResemble, []string{"test"})
+			cost, err = EstimateQuery(context.Background(), db, "SELECT * FROM test WHERE id = ?", 1)
+			So(err, ShouldBeNil)
+			So(cost.Rows, ShouldEqual, 1)
+
+			var ctx = WithMaxCost(context.Background(), 10)
+			var v string
+			err = db.QueryRowContext(ctx, "SELECT v FROM test WHERE v = 'a' LIMIT 1").Scan(&v)
+			So(IsCostExceeded(err), ShouldBeTrue)
+			So(db.QueryRowContext(ctx, "SELECT v FROM test WHERE id = 1").Scan(&v), ShouldBeNil)
+			_, err = db.ExecContext(ctx, "UPDATE test SET v = 'b'")
+			So(IsCostExceeded(err), ShouldBeTrue)
+
+			// the budget is kept by the connection
+			sc, err := db.Conn(context.Background())
+			So(err, ShouldBeNil)
+			defer sc.Close()
+			_, err = sc.ExecContext(context.Background(), "SET max_cost = 10")
+			So(err, ShouldBeNil)
+			_, err = sc.ExecContext(context.Background(), "DELETE FROM test WHERE v = 'a'")
+			So(IsCostExceeded(err), ShouldBeTrue)
+			_, err = sc.ExecContext(context.Background(), "SET max_cost = DEFAULT")
+			So(err, ShouldBeNil)
+			_, err = sc.ExecContext(context.Background(), "DELETE FROM test WHERE v = 'a'")
+			So(err, ShouldBeNil)
+		})
+		Convey("The features needing the miners should be refused", func() {
+			standby, err := sql.Open(DBScheme, dsn+"&standby=true")
+			So(err, ShouldBeNil)
+			defer standby.Close()
+			_, err = standby.Exec("CREATE TABLE test (id INT)")
+			So(errors.Cause(err), ShouldEqual, ErrDevUnsupported)
+		})
+	})
+}
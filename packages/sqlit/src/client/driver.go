@@ -16,11 +16,13 @@ import (
 
 	bp "sqlit/src/blockproducer"
 	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/chainbus"
 	"sqlit/src/conf"
-	"sqlit/src/crypto"
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/crypto/kms"
+	"sqlit/src/jeju"
+	"sqlit/src/naconn"
 	"sqlit/src/proto"
 	"sqlit/src/route"
 	rpc "sqlit/src/rpc/mux"
@@ -43,6 +45,7 @@ var (
 	driverInitialized   uint32
 	peersUpdaterRunning uint32
 	peerList            sync.Map // map[proto.DatabaseID]*proto.Peers
+	profileCache        = route.NewProfileCache(route.DefaultProfileTTL, loadProfile)
 	connIDLock          sync.Mutex
 	connIDAvail         []uint64
 	globalSeqNo         uint64
@@ -86,6 +89,10 @@ func (d *sqlitDriver) Open(dsn string) (conn driver.Conn, err error) {
 		return
 	}
 
+	if cfg.Dev != "" {
+		return newDevConn(cfg)
+	}
+
 	if atomic.LoadUint32(&driverInitialized) == 0 {
 		err = defaultInit()
 		if err != nil && err != ErrAlreadyInitialized {
@@ -108,6 +115,33 @@ type ResourceMeta struct {
 	UseEventualConsistency bool                   `json:"eventual-consistency,omitempty"` // use eventual consistency replication if enabled
 	ConsistencyLevel       float64                `json:"consistency-level,omitempty"`    // customized strong consistency level
 	IsolationLevel         int                    `json:"isolation-level,omitempty"`      // customized isolation level
+	MaxReaders             int                    `json:"max-readers,omitempty"`          // max open read connections on miners
+	BusyTimeout            int64                  `json:"busy-timeout,omitempty"`         // busy timeout in milliseconds
+	CacheSize              int                    `json:"cache-size,omitempty"`           // page cache size, in pages if positive or in KiB if negative
+	MMapSize               int64                  `json:"mmap-size,omitempty"`            // max bytes of memory-mapped I/O
+	MinRegions             uint16                 `json:"min-regions,omitempty"`          // min distinct regions of the miners
+	MinZones               uint16                 `json:"min-zones,omitempty"`            // min distinct zones of the miners
+	Regions                []string               `json:"regions,omitempty"`              // regions which must include a miner
+	Durability             string                 `json:"durability,omitempty"`           // durability level of the writes: strict, normal or relaxed
+}
+
+// pool returns the sqlite connection pool settings of the resource meta.
+func (m *ResourceMeta) pool() types.PoolMeta {
+	return types.PoolMeta{
+		MaxReaders:  m.MaxReaders,
+		BusyTimeout: m.BusyTimeout,
+		CacheSize:   m.CacheSize,
+		MMapSize:    m.MMapSize,
+	}
+}
+
+// placement returns the miner placement constraints of the resource meta.
+func (m *ResourceMeta) placement() types.PlacementMeta {
+	return types.PlacementMeta{
+		MinRegions: m.MinRegions,
+		MinZones:   m.MinZones,
+		Regions:    m.Regions,
+	}
 }
 
 func defaultInit() (err error) {
@@ -134,8 +168,26 @@ func Init(configFile string, masterKey []byte) (err error) {
 		return
 	}
 
+	// the client always uses the given master key, only the test flags are checked
+	if err = conf.CheckInsecureDevMode(
+		conf.GConf, conf.UsedInsecureFeatures(false, kms.Unittest)...,
+	); err != nil {
+		return
+	}
+
 	route.InitKMS(conf.GConf.PubKeyStoreFile)
 
+	if _, err = jeju.InitRegistryResolver(conf.GConf); err != nil {
+		return
+	}
+	if err = naconn.InitResolvers(conf.GConf); err != nil {
+		return
+	}
+
+	if err = naconn.InitTransport(conf.GConf); err != nil {
+		return
+	}
+
 	// Initialize BP info - find first Leader/Follower node from config
 	if conf.GConf.KnownNodes != nil {
 		for _, n := range conf.GConf.KnownNodes {
@@ -150,11 +202,26 @@ func Init(configFile string, masterKey []byte) (err error) {
 		}
 	}
 	kms.InitBP() // Initialize BP struct from conf.GConf.BP
+	profileCache.Purge()
 
 	if err = kms.InitLocalKeyPair(conf.GConf.PrivateKeyFile, masterKey); err != nil {
 		return
 	}
 
+	var s Signer
+	if s, err = newConfigSigner(conf.GConf.RemoteSigner); err != nil {
+		return
+	}
+	if s != nil {
+		SetSigner(s)
+	}
+
+	if conf.GConf.SequenceStateFile != "" {
+		if err = SetSequenceStore(NewFileSequenceStore(conf.GConf.SequenceStateFile)); err != nil {
+			return
+		}
+	}
+
 	// ping block producer to register node
 	if err = registerNode(); err != nil {
 		return
@@ -170,6 +237,26 @@ func Init(configFile string, masterKey []byte) (err error) {
 
 // Create sends create database operation to block producer.
 func Create(meta ResourceMeta) (txHash hash.Hash, dsn string, err error) {
+	return create(meta, "")
+}
+
+// Clone sends create database operation to block producer, the new database is allocated with a
+// new miner set and starts from a snapshot of the source database srcDSN taken by its first miner
+// once the creation is confirmed. The caller must have read permission on the source database,
+// and the encrypted databases cannot be cloned.
+func Clone(srcDSN string, meta ResourceMeta) (txHash hash.Hash, dsn string, err error) {
+	var cfg *Config
+	if cfg, err = ParseDSN(srcDSN); err != nil {
+		return
+	}
+	if cfg.DatabaseID == "" {
+		err = errors.New("clone source database id is empty")
+		return
+	}
+	return create(meta, proto.DatabaseID(cfg.DatabaseID))
+}
+
+func create(meta ResourceMeta, source proto.DatabaseID) (txHash hash.Hash, dsn string, err error) {
 	if atomic.LoadUint32(&driverInitialized) == 0 {
 		err = ErrNotInitialized
 		return
@@ -180,15 +267,15 @@ func Create(meta ResourceMeta) (txHash hash.Hash, dsn string, err error) {
 		nonceResp  = new(types.NextAccountNonceResp)
 		req        = new(types.AddTxReq)
 		resp       = new(types.AddTxResp)
-		privateKey *asymmetric.PrivateKey
+		signer     Signer
 		clientAddr proto.AccountAddress
+		durability types.DurabilityLevel
 	)
-	if privateKey, err = kms.GetLocalPrivateKey(); err != nil {
-		err = errors.Wrap(err, "get local private key failed")
+	if durability, err = types.ParseDurabilityLevel(meta.Durability); err != nil {
 		return
 	}
-	if clientAddr, err = crypto.PubKeyHash(privateKey.PubKey()); err != nil {
-		err = errors.Wrap(err, "get local account address failed")
+	if signer, clientAddr, err = getSigner(); err != nil {
+		err = errors.Wrap(err, "get signer failed")
 		return
 	}
 	// allocate nonce
@@ -212,11 +299,15 @@ func Create(meta ResourceMeta) (txHash hash.Hash, dsn string, err error) {
 			UseEventualConsistency: meta.UseEventualConsistency,
 			ConsistencyLevel:       meta.ConsistencyLevel,
 			IsolationLevel:         meta.IsolationLevel,
+			Pool:                   meta.pool(),
+			Source:                 source,
+			Placement:              meta.placement(),
+			Durability:             durability,
 		},
 		Nonce: nonceResp.Nonce,
 	})
 
-	if err = req.Tx.Sign(privateKey); err != nil {
+	if err = req.Tx.Sign(signer); err != nil {
 		err = errors.Wrap(err, "sign request failed")
 		return
 	}
@@ -240,6 +331,10 @@ func WaitDBCreation(ctx context.Context, dsn string) (err error) {
 	if err != nil {
 		return
 	}
+	if dsnCfg.Dev != "" {
+		// the development database is created on open
+		return
+	}
 
 	db, err := sql.Open("sqlit", dsn)
 	if err != nil {
@@ -313,6 +408,7 @@ func Drop(dsn string) (txHash hash.Hash, err error) {
 	}
 
 	peerList.Delete(cfg.DatabaseID)
+	profileCache.Invalidate(proto.DatabaseID(cfg.DatabaseID))
 
 	//TODO(laodouya) currently not supported
 	//err = errors.New("drop db current not support")
@@ -329,18 +425,11 @@ func UpdatePermission(targetUser proto.AccountAddress,
 	}
 
 	var (
-		pubKey  *asymmetric.PublicKey
-		privKey *asymmetric.PrivateKey
-		addr    proto.AccountAddress
-		nonce   interfaces.AccountNonce
+		signer Signer
+		addr   proto.AccountAddress
+		nonce  interfaces.AccountNonce
 	)
-	if pubKey, err = kms.GetLocalPublicKey(); err != nil {
-		return
-	}
-	if privKey, err = kms.GetLocalPrivateKey(); err != nil {
-		return
-	}
-	if addr, err = crypto.PubKeyHash(pubKey); err != nil {
+	if signer, addr, err = getSigner(); err != nil {
 		return
 	}
 
@@ -355,7 +444,7 @@ func UpdatePermission(targetUser proto.AccountAddress,
 		Permission:     perm,
 		Nonce:          nonce,
 	})
-	err = up.Sign(privKey)
+	err = up.Sign(signer)
 	if err != nil {
 		log.WithError(err).Warning("sign failed")
 		return
@@ -373,6 +462,96 @@ func UpdatePermission(targetUser proto.AccountAddress,
 	return
 }
 
+// ReplaceMiner sends ReplaceMiner transaction to chain. An empty miner adds the standby provider
+// as a warm standby of the target chain, otherwise the standby miner is promoted in place of miner.
+func ReplaceMiner(targetChain proto.AccountAddress,
+	miner proto.AccountAddress, standby proto.AccountAddress) (txHash hash.Hash, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var (
+		signer Signer
+		addr   proto.AccountAddress
+		nonce  interfaces.AccountNonce
+	)
+	if signer, addr, err = getSigner(); err != nil {
+		return
+	}
+
+	nonce, err = getNonce(addr)
+	if err != nil {
+		return
+	}
+
+	rm := types.NewReplaceMiner(&types.ReplaceMinerHeader{
+		TargetSQLChain: targetChain,
+		Miner:          miner,
+		Standby:        standby,
+		Nonce:          nonce,
+	})
+	if err = rm.Sign(signer); err != nil {
+		log.WithError(err).Warning("sign failed")
+		return
+	}
+	addTxReq := new(types.AddTxReq)
+	addTxResp := new(types.AddTxResp)
+	addTxReq.Tx = rm
+	if err = requestBP(route.MCCAddTx, addTxReq, addTxResp); err != nil {
+		log.WithError(err).Warning("send tx failed")
+		return
+	}
+
+	txHash = rm.Hash()
+	return
+}
+
+// ExpandDatabase sends ExpandDatabase transaction to chain, which adds node more miners to the
+// target chain. The target miners are preferred, and the others are matched from the providers.
+func ExpandDatabase(targetChain proto.AccountAddress,
+	node uint16, targetMiners []proto.AccountAddress) (txHash hash.Hash, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var (
+		signer Signer
+		addr   proto.AccountAddress
+		nonce  interfaces.AccountNonce
+	)
+	if signer, addr, err = getSigner(); err != nil {
+		return
+	}
+
+	nonce, err = getNonce(addr)
+	if err != nil {
+		return
+	}
+
+	ed := types.NewExpandDatabase(&types.ExpandDatabaseHeader{
+		TargetSQLChain: targetChain,
+		Node:           node,
+		TargetMiners:   targetMiners,
+		Nonce:          nonce,
+	})
+	if err = ed.Sign(signer); err != nil {
+		log.WithError(err).Warning("sign failed")
+		return
+	}
+	addTxReq := new(types.AddTxReq)
+	addTxResp := new(types.AddTxResp)
+	addTxReq.Tx = ed
+	if err = requestBP(route.MCCAddTx, addTxReq, addTxResp); err != nil {
+		log.WithError(err).Warning("send tx failed")
+		return
+	}
+
+	txHash = ed.Hash()
+	return
+}
+
 // WaitTxConfirmation waits for the transaction with target hash txHash to be confirmed. It also
 // returns if any error occurs or a final state is returned from BP.
 func WaitTxConfirmation(
@@ -495,14 +674,14 @@ func runPeerListUpdater() (err error) {
 						log.WithField("db", dbID).
 							WithError(err).
 							Debug("update peers failed")
-
-						// TODO(xq262144), better rpc remote error judgement
-						if strings.Contains(err.Error(), bp.ErrNoSuchDatabase.Error()) {
-							log.WithField("db", dbID).
-								Warning("database no longer exists, stopping peers update")
-							peerList.Delete(dbID)
-						}
 					}
+					// the dropped databases stop the peers update, and the revoked ones close
+					// their connections
+					var profile *types.SQLChainProfile
+					if err == nil {
+						profile, err = profileCache.Get(dbID)
+					}
+					_ = updateDatabaseState(dbID, profile, err)
 				}(dbID)
 
 				return true
@@ -552,21 +731,18 @@ func getPeers(dbID proto.DatabaseID, privKey *asymmetric.PrivateKey) (peers *pro
 		}).WithError(err).Debug("get peers for database")
 	}()
 
-	profileReq := &types.QuerySQLChainProfileReq{}
-	profileResp := &types.QuerySQLChainProfileResp{}
-	profileReq.DBID = dbID
-	err = rpc.RequestBP(route.MCCQuerySQLChainProfile.String(), profileReq, profileResp)
+	profile, err := profileCache.Get(dbID)
 	if err != nil {
 		err = errors.Wrap(err, "get sqlchain profile failed in getPeers")
 		return
 	}
 
-	nodeIDs := make([]proto.NodeID, len(profileResp.Profile.Miners))
-	if len(profileResp.Profile.Miners) <= 0 {
+	nodeIDs := make([]proto.NodeID, len(profile.Miners))
+	if len(profile.Miners) <= 0 {
 		err = errors.Wrap(ErrInvalidProfile, "unexpected error in getPeers")
 		return
 	}
-	for i, mi := range profileResp.Profile.Miners {
+	for i, mi := range profile.Miners {
 		nodeIDs[i] = mi.NodeID
 	}
 	peers = &proto.Peers{
@@ -581,28 +757,66 @@ func getPeers(dbID proto.DatabaseID, privKey *asymmetric.PrivateKey) (peers *pro
 		return
 	}
 
+	// keep the leader of a newer term learned from the miners
+	if rawPeers, ok := peerList.Load(dbID); ok {
+		if cached, ok := rawPeers.(*proto.Peers); ok && cached.Term > peers.Term && cached.SameServers(peers) {
+			peers = cached
+			return
+		}
+	}
+
 	// set peers in the updater cache
 	peerList.Store(dbID, peers)
 
 	return
 }
 
+// loadProfile queries the sqlchain profile of database dbID from the block producers, the
+// profiles are cached by profileCache.
+func loadProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
+	var (
+		req  = &types.QuerySQLChainProfileReq{DBID: dbID}
+		resp = &types.QuerySQLChainProfileResp{}
+	)
+	if err = rpc.RequestBP(route.MCCQuerySQLChainProfile.String(), req, resp); err != nil {
+		return
+	}
+	profile = &resp.Profile
+	return
+}
+
+// WatchProfiles invalidates the cached sqlchain profiles changed by the transactions published on
+// bus, e.g. by a block producer event subscription, so that the peers are updated before the
+// cache expires.
+func WatchProfiles(bus chainbus.ChainSuber) (err error) {
+	if err = profileCache.Watch(bus); err != nil {
+		return
+	}
+	return watchPermissions(bus)
+}
+
 func allocateConnAndSeq() (connID uint64, seqNo uint64) {
 	connIDLock.Lock()
 	defer connIDLock.Unlock()
 
+	var newConn bool
 	if len(connIDAvail) == 0 {
 		// generate one
 		connID = randSource.Uint64()
-		seqNo = atomic.AddUint64(&globalSeqNo, 1)
-		return
+		connIDs = append(connIDs, connID)
+		newConn = true
+	} else {
+		// pop one conn
+		connID = connIDAvail[0]
+		connIDAvail = connIDAvail[1:]
 	}
-
-	// pop one conn
-	connID = connIDAvail[0]
-	connIDAvail = connIDAvail[1:]
 	seqNo = atomic.AddUint64(&globalSeqNo, 1)
 
+	// save the new connection or the next reserved sequence numbers
+	if seqStore != nil && (newConn || seqNo > seqReserved) {
+		_ = saveSequenceState()
+	}
+
 	return
 }
 
@@ -1,7 +1,14 @@
 
 package client
 
-import "github.com/pkg/errors"
+import (
+	"strings"
+	"syscall"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
 
 // Various errors the driver might returns.
 var (
@@ -15,4 +22,105 @@ var (
 	ErrInvalidRequestSeq = errors.New("invalid request sequence applied")
 	// ErrInvalidProfile indicates the SQLChain profile is invalid.
 	ErrInvalidProfile = errors.New("invalid sqlchain profile")
+	// ErrStandbyReadOnly indicates a write query is sent on a standby connection.
+	ErrStandbyReadOnly = errors.New("standby connection is read-only")
+	// ErrAsOfReadOnly indicates a write query is sent to read the historical state.
+	ErrAsOfReadOnly = errors.New("historical query is read-only")
+	// ErrDevUnsupported indicates a feature which needs the miners is used on a development
+	// connection.
+	ErrDevUnsupported = errors.New("unsupported by development database")
+	// ErrAttachUnsupported indicates a query cannot be joined with the attached databases.
+	ErrAttachUnsupported = errors.New("unsupported by attached query")
+	// ErrResponseTampered indicates that a response of the miner fails the verification of its
+	// hashes or the signature of the responder, or doesn't answer the request.
+	ErrResponseTampered = errors.New("response is tampered")
+	// ErrRemoteSignature indicates that the remote signer fails to sign or returns a signature
+	// which doesn't match its public key.
+	ErrRemoteSignature = errors.New("invalid remote signature")
+	// ErrDatabaseGone indicates that the database is dropped, the connections to it are closed.
+	ErrDatabaseGone = errors.New("database gone")
+	// ErrPermissionRevoked indicates that the permission of the client on the database is revoked,
+	// the connections to it are closed until the permission is granted again.
+	ErrPermissionRevoked = errors.New("permission revoked")
 )
+
+// IsQuotaExceeded returns whether err indicates that the database has exceeded its storage
+// quota, the error code is kept in the error message returned by the miner.
+func IsQuotaExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeQuotaExceeded)
+}
+
+// IsExecutionTimeout returns whether err indicates that a query is interrupted by the miner after
+// its max execution time.
+func IsExecutionTimeout(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeExecutionTimeout)
+}
+
+// IsResultTooLarge returns whether err indicates that a read query result exceeds the limit of the
+// miner, the query may be sent with the result_cursor option to page through the result.
+func IsResultTooLarge(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeResultTooLarge)
+}
+
+// IsCostExceeded returns whether err indicates that a query is refused by the miner as its cost
+// estimated from the query plan exceeds the max cost of the request.
+func IsCostExceeded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeCostExceeded)
+}
+
+// IsOverloaded returns whether err indicates that a batch priority query is shed by the miner to
+// keep the interactive latency, the query may be retried later.
+func IsOverloaded(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeOverloaded)
+}
+
+// IsAttachNotLocal returns whether err indicates that an attached database is not co-located with
+// the queried database on the miner, the query is then federated by the client.
+func IsAttachNotLocal(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeAttachNotLocal)
+}
+
+// IsLeaderChanged returns whether err indicates that a query is refused by a miner which is no
+// longer the leader of the database, the driver learns the current leader from the miners.
+func IsLeaderChanged(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeLeaderChanged)
+}
+
+// IsDatabaseNotFound returns whether err indicates that the database is not served by the miner,
+// the driver confirms whether the database is dropped with the block producers.
+func IsDatabaseNotFound(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeDatabaseNotFound)
+}
+
+// IsPermissionDenied returns whether err indicates that the query is refused by the miner for the
+// permission of the client.
+func IsPermissionDenied(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodePermissionDenied)
+}
+
+// IsDuplicateRequest returns whether err indicates that the sequence number of a request is applied
+// on its connection, which means a write retried with the resumed sequence is already executed.
+func IsDuplicateRequest(err error) bool {
+	return err != nil && strings.Contains(err.Error(), types.ErrCodeDuplicateRequest)
+}
+
+// IsLeaderUnavailable returns whether err indicates that the miner serving the query is shutting
+// down or refuses the connections, e.g. while it's restarting. The read queries may be served by a
+// follower meanwhile if the degraded reads are accepted.
+func IsLeaderUnavailable(err error) bool {
+	return err != nil && (strings.Contains(err.Error(), types.ErrCodeShuttingDown) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		// the dial errors may be flattened into the messages by the rpc layers
+		strings.Contains(err.Error(), syscall.ECONNREFUSED.Error()))
+}
+
+// IsDatabaseGone returns whether err indicates that the database is confirmed dropped.
+func IsDatabaseGone(err error) bool {
+	return errors.Cause(err) == ErrDatabaseGone
+}
+
+// IsPermissionRevoked returns whether err indicates that the permission of the client on the
+// database is confirmed revoked.
+func IsPermissionRevoked(err error) bool {
+	return errors.Cause(err) == ErrPermissionRevoked
+}
@@ -0,0 +1,144 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FTSIndex defines a full-text search index, which is an fts5 virtual table over the text columns.
+type FTSIndex struct {
+	// Name is the name of the index table.
+	Name string
+	// Columns are the indexed columns.
+	Columns []string
+	// Content is the table holding the indexed rows, empty means the index keeps its own copy of
+	// the rows. The index of a content table keeps the tokens only and is synchronized by
+	// triggers, its rowids are the rowids of the content table.
+	Content string
+	// ContentRowID is the integer primary key column of the content table, empty means rowid.
+	ContentRowID string
+	// Tokenizer is the fts5 tokenizer, such as "porter unicode61" or "trigram", empty means the
+	// default "unicode61 remove_diacritics 1". The tokenizers depending on the locale or
+	// libraries of the miners are refused.
+	Tokenizer string
+	// Prefix are the lengths of the prefix indexes, which speed up the prefix queries.
+	Prefix []int
+}
+
+// FTSMatch defines a row matching a full-text search query.
+type FTSMatch struct {
+	RowID int64
+	// Rank is the bm25 score of the row, the better matches have the lower ranks.
+	Rank float64
+	// Snippet is the text around the matched terms, which are enclosed in brackets.
+	Snippet string
+}
+
+// CreateFTSIndex creates the full-text search index, and indexes the existing rows of its content
+// table.
+func CreateFTSIndex(ctx context.Context, db *sql.DB, index *FTSIndex) (err error) {
+	if index.Name == "" || len(index.Columns) == 0 {
+		return errors.New("full-text search index requires a name and columns")
+	}
+
+	var (
+		name   = quoteIdent(index.Name)
+		args   = make([]string, 0, len(index.Columns)+4)
+		cols   = make([]string, len(index.Columns))
+		rowID  = "rowid"
+		stmts  []string
+		prefix []string
+	)
+	for i, c := range index.Columns {
+		cols[i] = quoteIdent(c)
+		args = append(args, cols[i])
+	}
+	if index.Content != "" {
+		args = append(args, "content="+quoteString(index.Content))
+		if index.ContentRowID != "" {
+			rowID = index.ContentRowID
+			args = append(args, "content_rowid="+quoteString(rowID))
+		}
+	}
+	if index.Tokenizer != "" {
+		args = append(args, "tokenize="+quoteString(index.Tokenizer))
+	}
+	for _, p := range index.Prefix {
+		prefix = append(prefix, strconv.Itoa(p))
+	}
+	if len(prefix) > 0 {
+		args = append(args, "prefix="+quoteString(strings.Join(prefix, " ")))
+	}
+	stmts = append(stmts, "CREATE VIRTUAL TABLE "+name+" USING fts5("+strings.Join(args, ", ")+")")
+
+	if index.Content != "" {
+		var (
+			content = quoteIdent(index.Content)
+			rid     = quoteIdent(rowID)
+			list    = strings.Join(cols, ", ")
+			newVals = "new." + rid + ", new." + strings.Join(cols, ", new.")
+			oldVals = "old." + rid + ", old." + strings.Join(cols, ", old.")
+			insert  = "INSERT INTO " + name + "(rowid, " + list + ") VALUES (" + newVals + ");"
+			remove  = "INSERT INTO " + name + "(" + name + ", rowid, " + list + ") VALUES ('delete', " +
+				oldVals + ");"
+		)
+		stmts = append(stmts,
+			"CREATE TRIGGER "+quoteIdent(index.Name+"_ai")+" AFTER INSERT ON "+content+
+				" BEGIN "+insert+" END",
+			"CREATE TRIGGER "+quoteIdent(index.Name+"_ad")+" AFTER DELETE ON "+content+
+				" BEGIN "+remove+" END",
+			"CREATE TRIGGER "+quoteIdent(index.Name+"_au")+" AFTER UPDATE ON "+content+
+				" BEGIN "+remove+" "+insert+" END",
+			"INSERT INTO "+name+"("+name+") VALUES ('rebuild')",
+		)
+	}
+
+	for _, stmt := range stmts {
+		if _, err = db.ExecContext(ctx, stmt); err != nil {
+			return errors.Wrapf(err, "create full-text search index %s", index.Name)
+		}
+	}
+	return
+}
+
+// SearchFTS returns at most limit rows of the full-text search index matching query, which is in
+// the fts5 query syntax, such as `sqlit AND (chain OR block*)`, the best matches are returned first.
+// Use QuoteFTSPhrase to search the user input as a phrase.
+func SearchFTS(ctx context.Context, db *sql.DB, index string, query string, limit int) (
+	matches []*FTSMatch, err error,
+) {
+	var (
+		name = quoteIdent(index)
+		rows *sql.Rows
+	)
+	if rows, err = db.QueryContext(ctx, "SELECT rowid, rank, snippet("+name+", -1, '[', ']', '...', 16) "+
+		"FROM "+name+" WHERE "+name+" MATCH ? ORDER BY rank, rowid LIMIT ?", query, limit); err != nil {
+		return nil, errors.Wrapf(err, "search full-text search index %s", index)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var m = &FTSMatch{}
+		if err = rows.Scan(&m.RowID, &m.Rank, &m.Snippet); err != nil {
+			return nil, errors.Wrapf(err, "search full-text search index %s", index)
+		}
+		matches = append(matches, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrapf(err, "search full-text search index %s", index)
+	}
+	return
+}
+
+// QuoteFTSPhrase quotes text as an fts5 phrase, so that the operators and special characters in
+// text are matched as plain terms.
+func QuoteFTSPhrase(text string) string {
+	return `"` + strings.Replace(text, `"`, `""`, -1) + `"`
+}
+
+func quoteString(s string) string {
+	return `'` + strings.Replace(s, `'`, `''`, -1) + `'`
+}
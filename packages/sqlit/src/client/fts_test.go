@@ -0,0 +1,77 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFTS(t *testing.T) {
+	Convey("Given a development database", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit_fts")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		var (
+			ctx = context.Background()
+			dsn = DevDSN(filepath.Join(dir, "dev.db3"))
+		)
+		So(WaitDBCreation(ctx, dsn), ShouldBeNil)
+		db, err := sql.Open(DBScheme, dsn)
+		So(err, ShouldBeNil)
+		defer db.Close()
+
+		_, err = db.Exec("CREATE TABLE posts (id INTEGER PRIMARY KEY, title TEXT, body TEXT)")
+		So(err, ShouldBeNil)
+		_, err = db.Exec("INSERT INTO posts VALUES (1, 'Hello', 'the quick brown fox'), " +
+			"(2, 'Chains', 'blocks are chained by hashes')")
+		So(err, ShouldBeNil)
+
+		err = CreateFTSIndex(ctx, db, &FTSIndex{
+			Name:         "posts_fts",
+			Columns:      []string{"title", "body"},
+			Content:      "posts",
+			ContentRowID: "id",
+			Prefix:       []int{2},
+		})
+		if err != nil && strings.Contains(err.Error(), "no such module: fts5") {
+			SkipConvey("fts5 requires the sqlite_fts5 build tag", func() {})
+			return
+		}
+		So(err, ShouldBeNil)
+
+		Convey("The existing and new rows should be searchable", func() {
+			matches, err := SearchFTS(ctx, db, "posts_fts", "fox", 10)
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 1)
+			So(matches[0].RowID, ShouldEqual, 1)
+			So(matches[0].Snippet, ShouldContainSubstring, "[fox]")
+
+			_, err = db.Exec("INSERT INTO posts VALUES (3, 'Foxes', 'a fox and a chain')")
+			So(err, ShouldBeNil)
+			_, err = db.Exec("DELETE FROM posts WHERE id = 1")
+			So(err, ShouldBeNil)
+			matches, err = SearchFTS(ctx, db, "posts_fts", "fox OR ch*", 10)
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 2)
+
+			matches, err = SearchFTS(ctx, db, "posts_fts", QuoteFTSPhrase(`chained "by`), 10)
+			So(err, ShouldBeNil)
+			So(matches, ShouldHaveLength, 1)
+			So(matches[0].RowID, ShouldEqual, 2)
+		})
+		Convey("The nondeterministic tokenizers should be refused", func() {
+			err = CreateFTSIndex(ctx, db, &FTSIndex{
+				Name:      "posts_icu",
+				Columns:   []string{"body"},
+				Tokenizer: "icu",
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "nondeterministic")
+		})
+	})
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"sqlit/src/types"
+)
+
+var (
+	ctxIdempotencyKey = "_sqlit_idempotency_key"
+	ctxPriorityKey    = "_sqlit_priority"
+	ctxMaxExecTimeKey = "_sqlit_max_execution_time"
+)
+
+// WithIdempotencyKey returns a context which sends the write queries with the idempotency key.
+// The database leader executes the write queries with the same key from this node only once
+// within a time window, and returns the original response to the retries. So a write can be
+// safely retried with the same context after a timeout.
+//
+// The key should be unique for each logical write, such as a random UUID.
+func WithIdempotencyKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, &ctxIdempotencyKey, key)
+}
+
+// GetIdempotencyKey tries to get the idempotency key from context.
+func GetIdempotencyKey(ctx context.Context) (key string, ok bool) {
+	key, ok = ctx.Value(&ctxIdempotencyKey).(string)
+	return
+}
+
+// WithPriority returns a context which sends the queries with the priority class, it overrides the
+// priority option of the connection.
+func WithPriority(ctx context.Context, priority types.QueryPriority) context.Context {
+	return context.WithValue(ctx, &ctxPriorityKey, priority)
+}
+
+// GetPriority tries to get the priority class from context.
+func GetPriority(ctx context.Context) (priority types.QueryPriority, ok bool) {
+	priority, ok = ctx.Value(&ctxPriorityKey).(types.QueryPriority)
+	return
+}
+
+// WithMaxExecutionTime returns a context which sends the queries with the max execution time, it
+// overrides the max_execution_time option of the connection.
+func WithMaxExecutionTime(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, &ctxMaxExecTimeKey, d)
+}
+
+// GetMaxExecutionTime tries to get the max execution time from context.
+func GetMaxExecutionTime(ctx context.Context) (d time.Duration, ok bool) {
+	d, ok = ctx.Value(&ctxMaxExecTimeKey).(time.Duration)
+	return
+}
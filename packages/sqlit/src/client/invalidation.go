@@ -0,0 +1,124 @@
+package client
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	bp "sqlit/src/blockproducer"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/chainbus"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// invalidDatabases keeps the databases which are dropped or on which the permission of the client
+// is revoked, the connections to them fail with the kept errors and are closed by the connection
+// pools instead of retrying with the cached peers.
+var invalidDatabases sync.Map // map[proto.DatabaseID]error
+
+// invalidateDatabase marks database dbID invalid by cause, the cached profile is dropped, and the
+// cached peers of a dropped database are dropped too. The peers of a revoked database are kept
+// updated, so that the permission granted again is learned.
+func invalidateDatabase(dbID proto.DatabaseID, cause error) {
+	if _, loaded := invalidDatabases.LoadOrStore(dbID, cause); !loaded {
+		log.WithField("db", dbID).WithError(cause).Warning("database invalidated")
+	}
+	profileCache.Invalidate(dbID)
+	if errors.Cause(cause) == ErrDatabaseGone {
+		peerList.Delete(dbID)
+	}
+}
+
+// databaseError returns the error of database dbID if it's invalidated.
+func databaseError(dbID proto.DatabaseID) error {
+	if v, ok := invalidDatabases.Load(dbID); ok {
+		return v.(error)
+	}
+	return nil
+}
+
+// checkProfile returns an ErrPermissionRevoked error if account addr is not permitted to query
+// the database of profile.
+func checkProfile(profile *types.SQLChainProfile, addr proto.AccountAddress) error {
+	if profile.Owner == addr {
+		return nil
+	}
+	for _, u := range profile.Users {
+		if u.Address != addr {
+			continue
+		}
+		if u.Status.EnableQuery() &&
+			(u.Permission.HasReadPermission() || u.Permission.HasWritePermission()) {
+			return nil
+		}
+		return errors.Wrapf(ErrPermissionRevoked, "account %s, status: %d", addr, u.Status)
+	}
+	return errors.Wrapf(ErrPermissionRevoked, "account %s is not a user", addr)
+}
+
+// updateDatabaseState checks the loaded profile of database dbID or the error loading it, and
+// invalidates or restores the database. It returns the error of the invalidated database.
+func updateDatabaseState(
+	dbID proto.DatabaseID, profile *types.SQLChainProfile, loadErr error,
+) (err error) {
+	if loadErr != nil {
+		// TODO(xq262144), better rpc remote error judgement
+		if strings.Contains(loadErr.Error(), bp.ErrNoSuchDatabase.Error()) {
+			err = errors.Wrapf(ErrDatabaseGone, "%s", dbID)
+			invalidateDatabase(dbID, err)
+		}
+		// the other errors such as the unreachable block producers don't change the state
+		return
+	}
+	_, addr, serr := getSigner()
+	if serr != nil {
+		return
+	}
+	if err = checkProfile(profile, addr); err != nil {
+		invalidateDatabase(dbID, err)
+		return
+	}
+	if _, ok := invalidDatabases.Load(dbID); ok {
+		log.WithField("db", dbID).Info("database permission restored")
+		invalidDatabases.Delete(dbID)
+	}
+	return
+}
+
+// confirmDatabase reloads the profile of database dbID from the block producers after a miner
+// reports that the database is not found or the permission is denied, which may also be caused by
+// the stale peers. It returns the error of the database if it's confirmed invalid.
+func confirmDatabase(dbID proto.DatabaseID) error {
+	profileCache.Invalidate(dbID)
+	profile, err := profileCache.Get(dbID)
+	return updateDatabaseState(dbID, profile, err)
+}
+
+// confirmQueryError maps the error of a query to the error of the database if the database is
+// confirmed dropped or revoked, the other errors are returned as is.
+func confirmQueryError(dbID proto.DatabaseID, err error) error {
+	if !IsDatabaseNotFound(err) && !IsPermissionDenied(err) {
+		return err
+	}
+	if cerr := confirmDatabase(dbID); cerr != nil {
+		return errors.WithMessage(cerr, err.Error())
+	}
+	return err
+}
+
+// watchPermissions confirms the databases of the permission updates published on bus, so that
+// the connections of a revoked database are closed before the next peers update.
+func watchPermissions(bus chainbus.ChainSuber) error {
+	return bus.Subscribe("/"+pi.TransactionTypeUpdatePermission.String()+"/",
+		func(tx pi.Transaction, count uint32) {
+			if up, ok := tx.(*types.UpdatePermission); ok {
+				var dbID = up.TargetSQLChain.DatabaseID()
+				if _, ok := peerList.Load(dbID); ok {
+					go func() { _ = confirmDatabase(dbID) }()
+				}
+			}
+		})
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	bp "sqlit/src/blockproducer"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestDatabaseInvalidation(t *testing.T) {
+	Convey("Given a client signing with its private key", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		addr, err := crypto.PubKeyHash(priv.PubKey())
+		So(err, ShouldBeNil)
+		SetSigner(priv)
+		defer SetSigner(nil)
+
+		var (
+			dbID    = proto.DatabaseID("invalidation-test")
+			c       = &conn{dbID: dbID}
+			user    = &types.SQLChainUser{Address: addr, Status: types.Normal}
+			profile = &types.SQLChainProfile{ID: dbID, Users: []*types.SQLChainUser{user}}
+		)
+		defer invalidDatabases.Delete(dbID)
+		peerList.Store(dbID, &proto.Peers{})
+		defer peerList.Delete(dbID)
+
+		Convey("The permission of the client should be checked by the profile", func() {
+			user.Permission = types.UserPermissionFromRole(types.Read)
+			So(checkProfile(profile, addr), ShouldBeNil)
+			So(checkProfile(&types.SQLChainProfile{Owner: addr}, addr), ShouldBeNil)
+			So(IsPermissionRevoked(checkProfile(&types.SQLChainProfile{}, addr)), ShouldBeTrue)
+			user.Permission = types.UserPermissionFromRole(types.Void)
+			So(IsPermissionRevoked(checkProfile(profile, addr)), ShouldBeTrue)
+			user.Permission, user.Status = types.UserPermissionFromRole(types.Admin), types.Arrears
+			So(IsPermissionRevoked(checkProfile(profile, addr)), ShouldBeTrue)
+		})
+		Convey("The revoked database should be restored with the permission", func() {
+			user.Status = types.Arrears
+			So(IsPermissionRevoked(updateDatabaseState(dbID, profile, nil)), ShouldBeTrue)
+			So(c.IsValid(), ShouldBeFalse)
+			So(c.ResetSession(context.Background()), ShouldNotBeNil)
+			_, err := c.ExecContext(context.Background(), "DELETE FROM t", nil)
+			So(IsPermissionRevoked(err), ShouldBeTrue)
+			_, ok := peerList.Load(dbID)
+			So(ok, ShouldBeTrue)
+
+			user.Status, user.Permission = types.Normal, types.UserPermissionFromRole(types.Write)
+			So(updateDatabaseState(dbID, profile, nil), ShouldBeNil)
+			So(c.IsValid(), ShouldBeTrue)
+		})
+		Convey("The dropped database should stop the peers update", func() {
+			So(updateDatabaseState(dbID, nil, errors.New("rpc timeout")), ShouldBeNil)
+			So(c.IsValid(), ShouldBeTrue)
+			err := updateDatabaseState(dbID, nil, errors.Wrap(bp.ErrNoSuchDatabase, "remote"))
+			So(IsDatabaseGone(err), ShouldBeTrue)
+			So(IsDatabaseGone(databaseError(dbID)), ShouldBeTrue)
+			So(c.IsValid(), ShouldBeFalse)
+			_, ok := peerList.Load(dbID)
+			So(ok, ShouldBeFalse)
+		})
+		Convey("The other query errors should be returned as is", func() {
+			var err = errors.New(types.ErrCodeOverloaded + ": shed")
+			So(confirmQueryError(dbID, err), ShouldEqual, err)
+			So(confirmQueryError(dbID, nil), ShouldBeNil)
+			So(IsDatabaseNotFound(errors.New(types.ErrCodeDatabaseNotFound+": x")), ShouldBeTrue)
+			So(IsPermissionDenied(errors.New(types.ErrCodePermissionDenied+": x")), ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// QueryPeersTimeout defines the timeout of learning the current peers of a database from a miner.
+var QueryPeersTimeout = 5 * time.Second
+
+// newNodeCaller returns a caller of the one-off rpc calls in the rpc mode of cfg.
+func newNodeCaller(cfg *Config) *rpc.Caller {
+	if cfg.UseDirectRPC {
+		return rpc.NewCaller()
+	}
+	return mux.NewCaller().Caller
+}
+
+// queryPeers learns the current peers of database dbID from the servers of peers. The peers of
+// the highest term verified by the signature is returned, the servers of which must be the same
+// as peers, since they are assigned by block producer.
+func queryPeers(cfg *Config, dbID proto.DatabaseID, peers *proto.Peers) (latest *proto.Peers) {
+	var (
+		wg   sync.WaitGroup
+		lock sync.Mutex
+	)
+	latest = peers
+	for _, s := range peers.Servers {
+		wg.Add(1)
+		go func(s proto.NodeID) {
+			defer wg.Done()
+			var (
+				ctx, cancel = context.WithTimeout(context.Background(), QueryPeersTimeout)
+				resp        types.QueryPeersResp
+			)
+			defer cancel()
+			if err := newNodeCaller(cfg).CallNodeWithContext(ctx, s, route.DBSQueryPeers.String(),
+				&types.QueryPeersReq{DatabaseID: dbID}, &resp,
+			); err != nil {
+				log.WithFields(log.Fields{
+					"db":   dbID,
+					"node": s,
+				}).WithError(err).Debug("query peers failed")
+				return
+			}
+			if resp.Peers == nil || resp.Peers.Verify() != nil || !resp.Peers.SameServers(peers) {
+				return
+			}
+			lock.Lock()
+			defer lock.Unlock()
+			if resp.Peers.Term > latest.Term {
+				latest = resp.Peers
+			}
+		}(s)
+	}
+	wg.Wait()
+	return
+}
+
+// switchLeader learns the current leader after a query is refused by a stale leader, and
+// reconnects the leader peer connection if the leader is changed.
+func (c *conn) switchLeader() (changed bool, err error) {
+	if c.leader == nil || c.standby || c.cfg.Mirror != "" {
+		return
+	}
+	var peers *proto.Peers
+	if peers, err = cacheGetPeers(c.dbID, c.privKey); err != nil {
+		return
+	}
+	var latest = queryPeers(c.cfg, c.dbID, peers)
+	if latest != peers {
+		// shared by the other connections
+		peerList.Store(c.dbID, latest)
+	}
+	if proto.NodeID(c.leader.pCaller.Target()) == latest.Leader {
+		return
+	}
+
+	var leader = &pconn{
+		wg:      &sync.WaitGroup{},
+		ackCh:   make(chan *types.Ack, workerCount*4),
+		parent:  c,
+		pCaller: newPeerCaller(c.cfg, latest.Leader),
+	}
+	if err = leader.startAckWorkers(); err != nil {
+		err = errors.WithMessage(err, "leader startAckWorkers failed")
+		return
+	}
+	log.WithFields(log.Fields{
+		"db":     c.dbID,
+		"term":   latest.Term,
+		"leader": latest.Leader,
+	}).Info("database leader changed")
+	// the pending acks are still sent to the previous leader
+	go c.leader.close()
+	c.leader, changed = leader, true
+	return
+}
@@ -0,0 +1,33 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/types"
+)
+
+// QueryDatabaseLoad returns the load of the database dsn reported by its miners to the block
+// producers, the per miner loads and the aggregated one, for the capacity planning.
+func QueryDatabaseLoad(dsn string) (resp *types.QueryDatabaseLoadResp, err error) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var cfg *Config
+	if cfg, err = ParseDSN(dsn); err != nil {
+		return
+	}
+
+	var req = &types.QueryDatabaseLoadReq{DBID: proto.DatabaseID(cfg.DatabaseID)}
+	resp = &types.QueryDatabaseLoadResp{}
+	if err = requestBP(route.MCCQueryDatabaseLoad, req, resp); err != nil {
+		err = errors.Wrap(err, "query database load failed")
+		return
+	}
+	return
+}
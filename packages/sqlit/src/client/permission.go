@@ -0,0 +1,67 @@
+package client
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/types"
+)
+
+// PreviewUpdatePermission returns the resulting users of the database targetChain and the
+// changes if the permission of targetUser is updated to perm by the local account, without
+// sending the transaction. The errors of the transaction processing, such as revoking the last
+// admin, are returned as is.
+func PreviewUpdatePermission(targetUser proto.AccountAddress,
+	targetChain proto.AccountAddress, perm *types.UserPermission) (
+	resp *types.PreviewUpdatePermissionResp, err error,
+) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var addr proto.AccountAddress
+	if _, addr, err = getSigner(); err != nil {
+		return
+	}
+
+	var req = &types.PreviewUpdatePermissionReq{
+		Sender: addr,
+		Header: types.UpdatePermissionHeader{
+			TargetSQLChain: targetChain,
+			TargetUser:     targetUser,
+			Permission:     perm,
+		},
+	}
+	resp = &types.PreviewUpdatePermissionResp{}
+	if err = requestBP(route.MCCPreviewUpdatePermission, req, resp); err != nil {
+		err = errors.Wrap(err, "preview update permission failed")
+		resp = nil
+	}
+	return
+}
+
+// GetEffectivePermission returns the effective permission of account addr on database dbID, the
+// query patterns are evaluated against the permission of the account.
+func GetEffectivePermission(dbID proto.DatabaseID, addr proto.AccountAddress, patterns ...string) (
+	ep *types.EffectivePermission, err error,
+) {
+	if atomic.LoadUint32(&driverInitialized) == 0 {
+		err = ErrNotInitialized
+		return
+	}
+
+	var (
+		req  = &types.QueryEffectivePermissionReq{DBID: dbID, Addr: addr, Patterns: patterns}
+		resp = &types.QueryEffectivePermissionResp{}
+	)
+	if err = requestBP(route.MCCQueryEffectivePermission, req, resp); err != nil {
+		err = errors.Wrap(err, "query effective permission failed")
+		return
+	}
+	ep = resp.Permission
+	return
+}
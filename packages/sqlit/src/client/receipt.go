@@ -4,6 +4,7 @@ package client
 import (
 	"context"
 	"sync/atomic"
+	"time"
 
 	"sqlit/src/crypto/hash"
 )
@@ -15,10 +16,15 @@ var (
 // Receipt defines a receipt of SQLIT query request.
 type Receipt struct {
 	RequestHash hash.Hash
+	TraceID     string
+	// Stale is set if the read query is served by a follower as a degraded read, the follower
+	// state may miss the writes committed by the leader in the last Staleness at most.
+	Stale     bool
+	Staleness time.Duration
 }
 
 // WithReceipt returns a context who holds a *atomic.Value. A *Receipt will be set to this value
-// after the query succeeds.
+// after the query is signed, so the trace id of a failed query can be read too.
 //
 // Note that this context is safe for concurrent queries, but the value may be reset in another
 // goroutines. So if you want to make use of Receipt in several goroutines, you should call this
@@ -2,6 +2,7 @@
 package client
 
 import (
+	"context"
 	"database/sql/driver"
 	"io"
 	"strings"
@@ -13,6 +14,15 @@ type rows struct {
 	columns []string
 	types   []string
 	data    []types.ResponseRow
+
+	// cursor is the server-side cursor holding the rest rows of a spilled result, fetch fetches
+	// its next page or closes it.
+	cursor string
+	fetch  func(cursor string, close bool) (*types.Response, error)
+
+	// blobs resolves the blob references in the rows with the query context
+	ctx   context.Context
+	blobs *blobStore
 }
 
 func newRows(res *types.Response) *rows {
@@ -20,6 +30,7 @@ func newRows(res *types.Response) *rows {
 		columns: res.Payload.Columns,
 		types:   res.Payload.DeclTypes,
 		data:    res.Payload.Rows,
+		cursor:  res.Cursor,
 	}
 }
 
@@ -29,15 +40,28 @@ func (r *rows) Columns() []string {
 }
 
 // Close implements driver.Rows.Close method.
-func (r *rows) Close() error {
+func (r *rows) Close() (err error) {
 	r.data = nil
-	return nil
+	if r.cursor != "" && r.fetch != nil {
+		// release the cursor if the rows are not read to the end
+		_, err = r.fetch(r.cursor, true)
+	}
+	r.cursor = ""
+	return
 }
 
 // Next implements driver.Rows.Next method.
 func (r *rows) Next(dest []driver.Value) error {
-	if len(r.data) == 0 {
-		return io.EOF
+	for len(r.data) == 0 {
+		if r.cursor == "" || r.fetch == nil {
+			return io.EOF
+		}
+		res, err := r.fetch(r.cursor, false)
+		if err != nil {
+			r.cursor = ""
+			return err
+		}
+		r.data, r.cursor = res.Payload.Rows, res.Cursor
 	}
 
 	for i, d := range r.data[0].Values {
@@ -47,7 +71,7 @@ func (r *rows) Next(dest []driver.Value) error {
 	// unshift data
 	r.data = r.data[1:]
 
-	return nil
+	return r.blobs.resolveRow(r.ctx, dest)
 }
 
 // ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.ColumnTypeDatabaseTypeName method.
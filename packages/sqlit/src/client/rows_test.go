@@ -42,4 +42,53 @@ func TestRowsStructure(t *testing.T) {
 		So(err, ShouldBeNil)
 		So(r.data, ShouldBeNil)
 	})
+
+	Convey("test rows with cursor", t, func() {
+		var (
+			pages = [][]types.ResponseRow{
+				{{Values: []interface{}{2}}, {Values: []interface{}{3}}},
+				{{Values: []interface{}{4}}},
+			}
+			closed bool
+		)
+		r := newRows(&types.Response{
+			Payload: types.ResponsePayload{
+				Columns:   []string{"a"},
+				DeclTypes: []string{"int"},
+				Rows:      []types.ResponseRow{{Values: []interface{}{1}}},
+			},
+			Cursor: "c",
+		})
+		r.fetch = func(cursor string, close bool) (res *types.Response, err error) {
+			So(cursor, ShouldEqual, "c")
+			if close {
+				closed = true
+				return &types.Response{}, nil
+			}
+			res = &types.Response{Payload: types.ResponsePayload{Rows: pages[0]}}
+			if pages = pages[1:]; len(pages) > 0 {
+				res.Cursor = cursor
+			}
+			return
+		}
+
+		dest := make([]driver.Value, 1)
+		var values []interface{}
+		for r.Next(dest) == nil {
+			values = append(values, dest[0])
+		}
+		So(values, ShouldResemble, []interface{}{1, 2, 3, 4})
+		So(r.Close(), ShouldBeNil)
+		So(closed, ShouldBeFalse)
+
+		Convey("The cursor should be closed if the rows are not read to the end", func() {
+			r = newRows(&types.Response{Cursor: "c"})
+			r.fetch = func(cursor string, close bool) (*types.Response, error) {
+				closed = close
+				return &types.Response{}, nil
+			}
+			So(r.Close(), ShouldBeNil)
+			So(closed, ShouldBeTrue)
+		})
+	})
 }
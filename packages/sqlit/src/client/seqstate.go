@@ -0,0 +1,230 @@
+package client
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/utils/log"
+)
+
+const (
+	// SeqNoReservation is the count of the sequence numbers reserved by each save of the sequence
+	// state, a restarted driver resumes from the reserved ones instead of saving each allocation.
+	SeqNoReservation = 1 << 16
+	// MaxPendingWrites is the max count of the in-flight idempotent writes kept in the sequence
+	// state, the exceeding ones are sent without being resumable.
+	MaxPendingWrites = 1024
+)
+
+// PendingWrite is an in-flight write query with an idempotency key. If the driver restarts before
+// the write returns, the retry of the write with the same key is sent with the same connection id
+// and sequence number, so that it's recognized by the leader as a duplicate.
+type PendingWrite struct {
+	Key    string `json:"key"`
+	ConnID uint64 `json:"conn_id"`
+	SeqNo  uint64 `json:"seq_no"`
+}
+
+// SequenceState is the persisted connection ids and sequence numbers of the driver.
+type SequenceState struct {
+	ConnIDs []uint64       `json:"conn_ids"`
+	SeqNo   uint64         `json:"seq_no"` // the sequence numbers up to it may have been used
+	Pending []PendingWrite `json:"pending,omitempty"`
+}
+
+// SequenceStore persists the sequence state of the driver.
+type SequenceStore interface {
+	// Load returns the saved state, or nil if nothing is saved.
+	Load() (*SequenceState, error)
+	Save(state *SequenceState) error
+}
+
+type fileSequenceStore struct {
+	path string
+}
+
+// NewFileSequenceStore returns a sequence store which saves the state as json to the file of path.
+func NewFileSequenceStore(path string) SequenceStore {
+	return &fileSequenceStore{path: path}
+}
+
+func (s *fileSequenceStore) Load() (state *SequenceState, err error) {
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "read sequence state failed")
+	}
+	state = new(SequenceState)
+	if err = json.Unmarshal(data, state); err != nil {
+		return nil, errors.Wrapf(err, "decode sequence state %s failed", s.path)
+	}
+	return
+}
+
+func (s *fileSequenceStore) Save(state *SequenceState) (err error) {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return errors.Wrap(err, "encode sequence state failed")
+	}
+	// write a temporary file and rename it, the state file is never left partially written
+	f, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "create sequence state failed")
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+	if _, err = f.Write(data); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	return errors.Wrap(err, "save sequence state failed")
+}
+
+var (
+	// the sequence state is guarded by connIDLock
+	seqStore      SequenceStore
+	seqReserved   uint64                  // the max sequence number saved in the store
+	connIDs       []uint64                // all the allocated connection ids
+	pendingWrites map[string]PendingWrite // the in-flight idempotent writes of this process
+	resumedWrites map[string]PendingWrite // the pending writes of the previous process
+)
+
+// SetSequenceStore resumes the connection ids and sequence numbers from store, and keeps them
+// saved in store, so that the sequence numbers of the connections keep increasing after the
+// driver restarts. A nil store stops saving the state.
+//
+// The sequence numbers are reserved by SeqNoReservation on each save, while the state is saved
+// twice by each write query with an idempotency key to make the write resumable.
+func SetSequenceStore(store SequenceStore) (err error) {
+	connIDLock.Lock()
+	defer connIDLock.Unlock()
+
+	if store == nil {
+		seqStore, resumedWrites = nil, nil
+		return
+	}
+	state, err := store.Load()
+	if err != nil {
+		return
+	}
+	if state != nil {
+		if seqNo := atomic.LoadUint64(&globalSeqNo); state.SeqNo > seqNo {
+			atomic.StoreUint64(&globalSeqNo, state.SeqNo)
+		}
+		resumedWrites = make(map[string]PendingWrite, len(state.Pending))
+		var busy = make(map[uint64]bool, len(state.Pending))
+		for _, p := range state.Pending {
+			resumedWrites[p.Key] = p
+			busy[p.ConnID] = true
+		}
+		// the connections of the pending writes are kept until the writes are retried
+		connIDAvail = connIDAvail[:0]
+		for _, id := range state.ConnIDs {
+			if !busy[id] {
+				connIDAvail = append(connIDAvail, id)
+			}
+		}
+		connIDs = append(connIDs[:0], state.ConnIDs...)
+		log.WithFields(log.Fields{
+			"conns":   len(state.ConnIDs),
+			"seqNo":   state.SeqNo,
+			"pending": len(state.Pending),
+		}).Info("sequence state resumed")
+	}
+	if pendingWrites == nil {
+		pendingWrites = make(map[string]PendingWrite)
+	}
+	seqStore = store
+	seqReserved = 0
+	return saveSequenceState()
+}
+
+// saveSequenceState saves the sequence state with the sequence numbers reserved, connIDLock must
+// be held.
+func saveSequenceState() (err error) {
+	if seqStore == nil {
+		return
+	}
+	var (
+		reserved = atomic.LoadUint64(&globalSeqNo) + SeqNoReservation
+		state    = &SequenceState{
+			ConnIDs: connIDs,
+			SeqNo:   reserved,
+			Pending: make([]PendingWrite, 0, len(pendingWrites)+len(resumedWrites)),
+		}
+	)
+	for _, p := range resumedWrites {
+		state.Pending = append(state.Pending, p)
+	}
+	for _, p := range pendingWrites {
+		state.Pending = append(state.Pending, p)
+	}
+	if err = seqStore.Save(state); err != nil {
+		log.WithError(err).Warning("save sequence state failed")
+		return
+	}
+	seqReserved = reserved
+	return
+}
+
+// allocateWrite allocates the connection id and sequence number of the write query with the
+// idempotency key, the ones of the pending write with the same key before the driver restarts
+// are reused.
+func allocateWrite(key string) (connID uint64, seqNo uint64) {
+	connIDLock.Lock()
+	if seqStore == nil {
+		connIDLock.Unlock()
+		return allocateConnAndSeq()
+	}
+	if _, ok := pendingWrites[key]; !ok {
+		if p, ok := resumedWrites[key]; ok {
+			delete(resumedWrites, key)
+			pendingWrites[key] = p
+			connIDLock.Unlock()
+			log.WithFields(log.Fields{
+				"connID": p.ConnID,
+				"seqNo":  p.SeqNo,
+			}).Info("resume pending write")
+			return p.ConnID, p.SeqNo
+		}
+	}
+	connIDLock.Unlock()
+
+	connID, seqNo = allocateConnAndSeq()
+
+	connIDLock.Lock()
+	defer connIDLock.Unlock()
+	if _, ok := pendingWrites[key]; ok || len(pendingWrites) >= MaxPendingWrites {
+		// the write is deduplicated by the idempotency key only
+		return
+	}
+	pendingWrites[key] = PendingWrite{Key: key, ConnID: connID, SeqNo: seqNo}
+	_ = saveSequenceState()
+	return
+}
+
+// finishWrite puts back the connection of the write query with the idempotency key after it
+// returns.
+func finishWrite(key string, connID uint64) {
+	connIDLock.Lock()
+	if p, ok := pendingWrites[key]; ok && p.ConnID == connID {
+		delete(pendingWrites, key)
+		_ = saveSequenceState()
+	}
+	connIDLock.Unlock()
+	putBackConn(connID)
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSequenceStore(t *testing.T) {
+	Convey("The sequence state should be resumed after the driver restarts", t, func() {
+		dir, err := os.MkdirTemp("", "seqstate")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		var store = NewFileSequenceStore(filepath.Join(dir, "seq.json"))
+
+		// simulates a restart of the driver by dropping the in-memory state
+		var restart = func() {
+			So(SetSequenceStore(nil), ShouldBeNil)
+			connIDLock.Lock()
+			connIDAvail, connIDs, pendingWrites = nil, nil, nil
+			connIDLock.Unlock()
+			atomic.StoreUint64(&globalSeqNo, 0)
+			So(SetSequenceStore(store), ShouldBeNil)
+		}
+		defer func() {
+			So(SetSequenceStore(nil), ShouldBeNil)
+		}()
+
+		state, err := store.Load()
+		So(err, ShouldBeNil)
+		So(state, ShouldBeNil)
+		restart()
+
+		connID, seqNo := allocateConnAndSeq()
+		wConnID, wSeqNo := allocateWrite("k1")
+		putBackConn(connID)
+		So(wConnID, ShouldNotEqual, connID)
+		So(wSeqNo, ShouldBeGreaterThan, seqNo)
+		// the write is in flight when the driver restarts
+		restart()
+
+		state, err = store.Load()
+		So(err, ShouldBeNil)
+		So(state.ConnIDs, ShouldResemble, []uint64{connID, wConnID})
+		So(state.SeqNo, ShouldBeGreaterThanOrEqualTo, wSeqNo+SeqNoReservation)
+		So(state.Pending, ShouldResemble, []PendingWrite{{Key: "k1", ConnID: wConnID, SeqNo: wSeqNo}})
+
+		// the connection of the pending write is not reused by the other queries
+		c, s := allocateConnAndSeq()
+		So(c, ShouldEqual, connID)
+		So(s, ShouldBeGreaterThan, wSeqNo)
+		putBackConn(c)
+
+		// the retried write is sent with the same connection and sequence once
+		c, s = allocateWrite("k1")
+		So(c, ShouldEqual, wConnID)
+		So(s, ShouldEqual, wSeqNo)
+		finishWrite("k1", c)
+		c, s = allocateWrite("k1")
+		So(s, ShouldBeGreaterThan, wSeqNo)
+		finishWrite("k1", c)
+
+		state, err = store.Load()
+		So(err, ShouldBeNil)
+		So(state.Pending, ShouldBeEmpty)
+		So(state.ConnIDs, ShouldHaveLength, 2)
+
+		// the corrupted state is refused
+		So(os.WriteFile(filepath.Join(dir, "seq.json"), []byte("{"), 0600), ShouldBeNil)
+		So(SetSequenceStore(store), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Session variables set by the SET statements on a connection.
+const (
+	// SessionQueryTimeout is the max execution time of each query on the miners, as a duration
+	// like '500ms' or in milliseconds, 0 means no limit.
+	SessionQueryTimeout = "query_timeout"
+	// SessionReadConsistency is 'strong' to read from the leader, or 'eventual' to read from the
+	// follower of the connection if any.
+	SessionReadConsistency = "read_consistency"
+	// SessionResultLimit is the max count of rows in the result of each read query, the stricter
+	// one of it and the limit of miners applies, 0 means the limit of miners only.
+	SessionResultLimit = "result_limit"
+	// SessionMaxCost is the max cost of each query in the rows estimated by the miners from the
+	// query plan, the query exceeding it is refused before it's executed, 0 means no limit.
+	SessionMaxCost = "max_cost"
+
+	readConsistencyStrong   = "strong"
+	readConsistencyEventual = "eventual"
+	sessionDefault          = "default"
+)
+
+// setStatement matches "SET [SESSION] name {= | TO} value".
+var setStatement = regexp.MustCompile(
+	`(?is)^\s*SET\s+(?:SESSION\s+)?([a-z_][a-z0-9_]*)(?:\s*=\s*|\s+TO\s+)(.*?)\s*;?\s*$`)
+
+// parseSetStatement returns the lower-cased variable name and the unquoted value of the SET
+// statement query, ok is false if query is not a SET statement.
+func parseSetStatement(query string) (name, value string, ok bool) {
+	m := setStatement.FindStringSubmatch(query)
+	if m == nil {
+		return
+	}
+	name, value = strings.ToLower(m[1]), m[2]
+	if l := len(value); l >= 2 && (value[0] == '\'' || value[0] == '"') && value[l-1] == value[0] {
+		value = value[1 : l-1]
+	}
+	return name, value, true
+}
+
+// setSessionVar sets the session variable of the connection, the DEFAULT value restores the one
+// of the dsn. The variables are kept by the connection and sent with each request.
+func (c *conn) setSessionVar(name, value string) (err error) {
+	var reset = strings.EqualFold(value, sessionDefault)
+	switch name {
+	case SessionQueryTimeout:
+		var d = c.cfg.MaxExecutionTime
+		if !reset {
+			if d, err = parseQueryTimeout(value); err != nil {
+				return
+			}
+		}
+		c.maxExec = d
+	case SessionReadConsistency:
+		switch v := strings.ToLower(value); {
+		case reset, v == readConsistencyEventual:
+			c.readLeader = false
+		case v == readConsistencyStrong:
+			if c.leader == nil {
+				return errors.Errorf("%s %s requires a leader connection", name, v)
+			}
+			c.readLeader = true
+		default:
+			return errors.Errorf("invalid %s: %s", name, value)
+		}
+	case SessionResultLimit:
+		var n uint64
+		if !reset {
+			if n, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid %s", name)
+			}
+		}
+		c.maxRows = n
+	case SessionMaxCost:
+		var n = c.cfg.MaxCost
+		if !reset {
+			if n, err = strconv.ParseUint(value, 10, 64); err != nil {
+				return errors.Wrapf(err, "invalid %s", name)
+			}
+		}
+		c.maxCost = n
+	default:
+		return errors.Errorf("unknown session variable: %s", name)
+	}
+	return
+}
+
+// parseQueryTimeout parses a duration like '500ms', or an integer in milliseconds.
+func parseQueryTimeout(value string) (d time.Duration, err error) {
+	if ms, perr := strconv.ParseInt(value, 10, 64); perr == nil {
+		d = time.Duration(ms) * time.Millisecond
+	} else if d, err = time.ParseDuration(value); err != nil {
+		return 0, errors.Wrapf(err, "invalid %s", SessionQueryTimeout)
+	}
+	if d < 0 {
+		err = errors.Errorf("invalid %s: %s", SessionQueryTimeout, value)
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseSetStatement(t *testing.T) {
+	Convey("test parse set statements", t, func() {
+		for _, c := range []struct {
+			query       string
+			name, value string
+			ok          bool
+		}{
+			{"SET query_timeout = '5s'", SessionQueryTimeout, "5s", true},
+			{"set session Result_Limit to 100;", SessionResultLimit, "100", true},
+			{" SET read_consistency=\"strong\" ", SessionReadConsistency, "strong", true},
+			{"SET result_limit = DEFAULT", SessionResultLimit, "DEFAULT", true},
+			{"UPDATE t SET a = 1", "", "", false},
+			{"SETTINGS", "", "", false},
+		} {
+			name, value, ok := parseSetStatement(c.query)
+			So(ok, ShouldEqual, c.ok)
+			So(name, ShouldEqual, c.name)
+			So(value, ShouldEqual, c.value)
+		}
+
+		d, err := parseQueryTimeout("1500")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 1500*time.Millisecond)
+		d, err = parseQueryTimeout("2s")
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 2*time.Second)
+		_, err = parseQueryTimeout("-1s")
+		So(err, ShouldNotBeNil)
+		_, err = parseQueryTimeout("soon")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestSessionVariables(t *testing.T) {
+	Convey("Given a connection to a development database", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit_session")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		db, err := sql.Open(DBScheme, DevDSN(filepath.Join(dir, "dev.db3"))+"&max_execution_time=10s")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		var ctx = context.Background()
+		sc, err := db.Conn(ctx)
+		So(err, ShouldBeNil)
+		defer sc.Close()
+		var c *conn
+		So(sc.Raw(func(driverConn interface{}) error {
+			c = driverConn.(*conn)
+			return nil
+		}), ShouldBeNil)
+
+		_, err = sc.ExecContext(ctx, "CREATE TABLE test (id INT)")
+		So(err, ShouldBeNil)
+		_, err = sc.ExecContext(ctx, "INSERT INTO test VALUES (1), (2), (3)")
+		So(err, ShouldBeNil)
+
+		Convey("The session variables should be kept by the connection", func() {
+			_, err = sc.ExecContext(ctx, "SET query_timeout = '1s'")
+			So(err, ShouldBeNil)
+			So(c.maxExec, ShouldEqual, time.Second)
+			_, err = sc.ExecContext(ctx, "SET query_timeout = DEFAULT")
+			So(err, ShouldBeNil)
+			So(c.maxExec, ShouldEqual, 10*time.Second)
+
+			rows, err := sc.QueryContext(ctx, "SET read_consistency = 'strong'")
+			So(err, ShouldBeNil)
+			So(rows.Next(), ShouldBeFalse)
+			So(rows.Close(), ShouldBeNil)
+			So(c.readLeader, ShouldBeTrue)
+		})
+		Convey("The result limit should be sent with the read queries", func() {
+			_, err = sc.ExecContext(ctx, "SET result_limit = 2")
+			So(err, ShouldBeNil)
+			_, err = sc.QueryContext(ctx, "SELECT * FROM test")
+			So(IsResultTooLarge(err), ShouldBeTrue)
+			var count int
+			So(sc.QueryRowContext(ctx, "SELECT COUNT(1) FROM test").Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 3)
+
+			_, err = sc.ExecContext(ctx, "SET result_limit TO default")
+			So(err, ShouldBeNil)
+			rows, err := sc.QueryContext(ctx, "SELECT * FROM test")
+			So(err, ShouldBeNil)
+			So(rows.Close(), ShouldBeNil)
+		})
+		Convey("The invalid session variables should be refused", func() {
+			_, err = sc.ExecContext(ctx, "SET autocommit = 1")
+			So(err, ShouldNotBeNil)
+			_, err = sc.ExecContext(ctx, "SET read_consistency = 'weak'")
+			So(err, ShouldNotBeNil)
+			_, err = sc.ExecContext(ctx, "SET result_limit = -1")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,189 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+)
+
+// DefaultRemoteSignTimeout defines the default timeout of a remote signing call.
+const DefaultRemoteSignTimeout = 10 * time.Second
+
+// Signer signs the requests, acks and transactions of the client. The local private key is used
+// by default, a remote signer keeps the key in an external service instead, such as a HSM, a TEE
+// enclave or a wallet bridge. The private key file is still needed for the node identity of the
+// connections, which isn't the account of the signed requests.
+type Signer = asymmetric.Signer
+
+// SignFunc asks an external service to sign the 32 bytes hash, the signature is returned in the
+// DER encoding or as the 64 bytes R || S.
+type SignFunc func(ctx context.Context, hash []byte) (sig []byte, err error)
+
+var (
+	signerLock sync.RWMutex
+	signer     Signer
+)
+
+// SetSigner replaces the signer of the client, nil restores the local private key. The
+// connections opened before keep their signer.
+func SetSigner(s Signer) {
+	signerLock.Lock()
+	defer signerLock.Unlock()
+	signer = s
+}
+
+// getSigner returns the signer of the client and its account address.
+func getSigner() (s Signer, addr proto.AccountAddress, err error) {
+	signerLock.RLock()
+	s = signer
+	signerLock.RUnlock()
+	if s == nil {
+		var privKey *asymmetric.PrivateKey
+		if privKey, err = kms.GetLocalPrivateKey(); err != nil {
+			err = errors.Wrap(err, "get local private key failed")
+			return
+		}
+		s = privKey
+	}
+	addr, err = crypto.PubKeyHash(s.PubKey())
+	return
+}
+
+// RemoteSigner signs the hashes with an external service holding the private key of pubKey.
+type RemoteSigner struct {
+	pubKey  *asymmetric.PublicKey
+	sign    SignFunc
+	timeout time.Duration
+}
+
+// NewRemoteSigner returns a remote signer of pubKey, timeout 0 means DefaultRemoteSignTimeout.
+func NewRemoteSigner(
+	pubKey *asymmetric.PublicKey, sign SignFunc, timeout time.Duration) *RemoteSigner {
+	if timeout <= 0 {
+		timeout = DefaultRemoteSignTimeout
+	}
+	return &RemoteSigner{
+		pubKey:  pubKey,
+		sign:    sign,
+		timeout: timeout,
+	}
+}
+
+// PubKey implements Signer.PubKey.
+func (s *RemoteSigner) PubKey() *asymmetric.PublicKey {
+	return s.pubKey
+}
+
+// Sign implements Signer.Sign, the signature is verified before it's used so that a service
+// signing with another key is found at once.
+func (s *RemoteSigner) Sign(hash []byte) (sig *asymmetric.Signature, err error) {
+	if len(hash) != 32 {
+		err = errors.New("only hash can be signed")
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+	var raw []byte
+	if raw, err = s.sign(ctx, hash); err != nil {
+		err = errors.Wrap(err, "remote sign failed")
+		return
+	}
+	if len(raw) == 64 {
+		sig = &asymmetric.Signature{
+			R: new(big.Int).SetBytes(raw[:32]),
+			S: new(big.Int).SetBytes(raw[32:]),
+		}
+	} else if sig, err = asymmetric.ParseSignature(raw); err != nil {
+		err = errors.Wrap(err, "parse remote signature failed")
+		return
+	}
+	if !sig.Verify(hash, s.pubKey) {
+		sig, err = nil, errors.Wrap(ErrRemoteSignature, "signature doesn't match the public key")
+	}
+	return
+}
+
+type httpSignRequest struct {
+	PublicKey string `json:"publicKey"`
+	Hash      string `json:"hash"`
+}
+
+type httpSignResponse struct {
+	Signature string `json:"signature"`
+	Error     string `json:"error,omitempty"`
+}
+
+// NewHTTPSignFunc returns a SignFunc posting the hex encoded public key and hash as JSON
+// {"publicKey","hash"} to endpoint, which answers the hex encoded signature as {"signature"}.
+// A nil client means http.DefaultClient.
+func NewHTTPSignFunc(endpoint string, pubKey *asymmetric.PublicKey, client *http.Client) SignFunc {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	pub := hex.EncodeToString(pubKey.Serialize())
+	return func(ctx context.Context, hash []byte) (sig []byte, err error) {
+		var body []byte
+		if body, err = json.Marshal(&httpSignRequest{
+			PublicKey: pub,
+			Hash:      hex.EncodeToString(hash),
+		}); err != nil {
+			return
+		}
+		var req *http.Request
+		if req, err = http.NewRequestWithContext(
+			ctx, http.MethodPost, endpoint, bytes.NewReader(body),
+		); err != nil {
+			return
+		}
+		req.Header.Set("Content-Type", "application/json")
+		var resp *http.Response
+		if resp, err = client.Do(req); err != nil {
+			return
+		}
+		defer func() { _ = resp.Body.Close() }()
+		var out httpSignResponse
+		if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&out); err != nil &&
+			resp.StatusCode == http.StatusOK {
+			err = errors.Wrap(err, "decode sign response failed")
+			return
+		}
+		if resp.StatusCode != http.StatusOK || out.Error != "" {
+			err = errors.Wrapf(ErrRemoteSignature, "signer responds %s: %s", resp.Status, out.Error)
+			return
+		}
+		return hex.DecodeString(out.Signature)
+	}
+}
+
+// newConfigSigner returns the remote signer of the config, nil if it's not configured.
+func newConfigSigner(cfg *conf.RemoteSignerInfo) (s Signer, err error) {
+	if cfg == nil || cfg.Endpoint == "" {
+		return
+	}
+	var raw []byte
+	if raw, err = hex.DecodeString(cfg.PublicKey); err != nil {
+		err = errors.Wrap(err, "decode remote signer public key failed")
+		return
+	}
+	var pubKey *asymmetric.PublicKey
+	if pubKey, err = asymmetric.ParsePubKey(raw); err != nil {
+		err = errors.Wrap(err, "parse remote signer public key failed")
+		return
+	}
+	s = NewRemoteSigner(pubKey, NewHTTPSignFunc(cfg.Endpoint, pubKey, nil), cfg.Timeout)
+	return
+}
@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/types"
+)
+
+// localSignFunc returns a SignFunc of the key as a remote signer would.
+func localSignFunc(key *asymmetric.PrivateKey, compact bool) SignFunc {
+	return func(_ context.Context, h []byte) (sig []byte, err error) {
+		var s *asymmetric.Signature
+		if s, err = key.Sign(h); err != nil {
+			return
+		}
+		if compact {
+			sig = make([]byte, 64)
+			s.R.FillBytes(sig[:32])
+			s.S.FillBytes(sig[32:])
+			return
+		}
+		return s.Serialize(), nil
+	}
+}
+
+func TestRemoteSigner(t *testing.T) {
+	Convey("Given a remote signing key", t, func() {
+		key, pub, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		other, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		var h = hash.THashB([]byte("request"))
+
+		Convey("The DER and compact signatures should be accepted", func() {
+			for _, compact := range []bool{false, true} {
+				s := NewRemoteSigner(pub, localSignFunc(key, compact), 0)
+				So(s.PubKey(), ShouldEqual, pub)
+				sig, err := s.Sign(h)
+				So(err, ShouldBeNil)
+				So(sig.Verify(h, pub), ShouldBeTrue)
+			}
+		})
+		Convey("A signature of another key should be refused", func() {
+			s := NewRemoteSigner(pub, localSignFunc(other, false), 0)
+			_, err := s.Sign(h)
+			So(errors.Cause(err), ShouldEqual, ErrRemoteSignature)
+			_, err = s.Sign(h[:16])
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The requests should be signed by the remote signer", func() {
+			var (
+				s   = NewRemoteSigner(pub, localSignFunc(key, false), 0)
+				req = &types.Request{}
+			)
+			req.Header.QueryType = types.WriteQuery
+			So(req.Sign(s), ShouldBeNil)
+			So(req.Verify(), ShouldBeNil)
+			So(req.Header.Signee, ShouldResemble, pub)
+
+			SetSigner(s)
+			defer SetSigner(nil)
+			signer, addr, err := getSigner()
+			So(err, ShouldBeNil)
+			So(signer, ShouldEqual, s)
+			expected, err := crypto.PubKeyHash(pub)
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, expected)
+		})
+		Convey("The http signer should sign with the service", func() {
+			var status = http.StatusOK
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req httpSignRequest
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				var resp httpSignResponse
+				if req.PublicKey != hex.EncodeToString(pub.Serialize()) {
+					status, resp.Error = http.StatusForbidden, "unknown key"
+				} else {
+					raw, _ := hex.DecodeString(req.Hash)
+					sig, _ := key.Sign(raw)
+					resp.Signature = hex.EncodeToString(sig.Serialize())
+				}
+				w.WriteHeader(status)
+				_ = json.NewEncoder(w).Encode(&resp)
+			}))
+			defer srv.Close()
+
+			s, err := newConfigSigner(&conf.RemoteSignerInfo{
+				Endpoint:  srv.URL,
+				PublicKey: hex.EncodeToString(pub.Serialize()),
+			})
+			So(err, ShouldBeNil)
+			sig, err := s.Sign(h)
+			So(err, ShouldBeNil)
+			So(sig.Verify(h, pub), ShouldBeTrue)
+
+			s = NewRemoteSigner(other.PubKey(), NewHTTPSignFunc(srv.URL, other.PubKey(), nil), 0)
+			_, err = s.Sign(h)
+			So(errors.Cause(err), ShouldEqual, ErrRemoteSignature)
+			So(err.Error(), ShouldContainSubstring, "unknown key")
+
+			s, err = newConfigSigner(nil)
+			So(err, ShouldBeNil)
+			So(s, ShouldBeNil)
+			_, err = newConfigSigner(&conf.RemoteSignerInfo{Endpoint: srv.URL, PublicKey: "zz"})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
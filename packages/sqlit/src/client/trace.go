@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+var (
+	ctxTraceIDKey = "_sqlit_trace_id"
+)
+
+// NewTraceID returns a random trace id as 32 hex digits.
+func NewTraceID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// WithTraceID returns a context which sends the queries with the trace id. The id is logged by
+// the driver and the miners and kept in the blocks with the request, so a failure reported by a
+// user can be correlated across the logs of all nodes. A random id is generated for each query
+// sent without it, which can be read from the Receipt.
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, &ctxTraceIDKey, id)
+}
+
+// GetTraceID tries to get the trace id from context.
+func GetTraceID(ctx context.Context) (id string, ok bool) {
+	id, ok = ctx.Value(&ctxTraceIDKey).(string)
+	return
+}
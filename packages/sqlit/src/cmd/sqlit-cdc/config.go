@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+// Sink types.
+const (
+	SinkKafka = "kafka"
+	SinkNATS  = "nats"
+)
+
+const (
+	defaultTopicPrefix   = "sqlit.cdc."
+	defaultBatchSize     = 100
+	defaultWait          = 30 * time.Second
+	defaultRetryInterval = 5 * time.Second
+)
+
+var (
+	// ErrInvalidConfig represents an invalid connector config.
+	ErrInvalidConfig = errors.New("invalid cdc connector config")
+)
+
+// KafkaConfig defines the options of the kafka sink, the events are produced through the kafka
+// REST proxy.
+type KafkaConfig struct {
+	RESTProxy string `yaml:"RESTProxy"`
+}
+
+// NATSConfig defines the options of the nats sink.
+type NATSConfig struct {
+	Addr     string `yaml:"Addr"`
+	User     string `yaml:"User"`
+	Password string `yaml:"Password"`
+	Token    string `yaml:"Token"`
+}
+
+// SinkConfig defines the target of the change events.
+type SinkConfig struct {
+	Type  string       `yaml:"Type"`
+	Kafka *KafkaConfig `yaml:"Kafka"`
+	NATS  *NATSConfig  `yaml:"NATS"`
+}
+
+// DatabaseConfig defines a database to publish the changes of.
+type DatabaseConfig struct {
+	ID proto.DatabaseID `yaml:"ID"`
+	// Topic is the kafka topic or the nats subject, defaults to TopicPrefix + ID
+	Topic string `yaml:"Topic"`
+}
+
+// Config defines the configurable options of the cdc connector.
+type Config struct {
+	// Explorer is the base url of the sqlit explorer serving the change feeds
+	Explorer string `yaml:"Explorer"`
+	// CheckpointDir stores the last published cursor of each database
+	CheckpointDir string           `yaml:"CheckpointDir"`
+	TopicPrefix   string           `yaml:"TopicPrefix"`
+	BatchSize     int              `yaml:"BatchSize"`
+	Wait          time.Duration    `yaml:"Wait"`
+	RetryInterval time.Duration    `yaml:"RetryInterval"`
+	Sink          *SinkConfig      `yaml:"Sink"`
+	Databases     []DatabaseConfig `yaml:"Databases"`
+}
+
+// Validate checks the config validity and fills the defaults.
+func (c *Config) Validate() (err error) {
+	if c.Explorer == "" {
+		return errors.Wrap(ErrInvalidConfig, "explorer is required")
+	}
+	if c.CheckpointDir == "" {
+		return errors.Wrap(ErrInvalidConfig, "checkpoint dir is required")
+	}
+	c.CheckpointDir = utils.HomeDirExpand(c.CheckpointDir)
+	if c.TopicPrefix == "" {
+		c.TopicPrefix = defaultTopicPrefix
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = defaultBatchSize
+	}
+	if c.Wait <= 0 {
+		c.Wait = defaultWait
+	}
+	if c.RetryInterval <= 0 {
+		c.RetryInterval = defaultRetryInterval
+	}
+	if c.Sink == nil {
+		return errors.Wrap(ErrInvalidConfig, "sink is required")
+	}
+	switch c.Sink.Type {
+	case SinkKafka:
+		if c.Sink.Kafka == nil || c.Sink.Kafka.RESTProxy == "" {
+			return errors.Wrap(ErrInvalidConfig, "kafka rest proxy is required")
+		}
+	case SinkNATS:
+		if c.Sink.NATS == nil || c.Sink.NATS.Addr == "" {
+			return errors.Wrap(ErrInvalidConfig, "nats addr is required")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidConfig, "unknown sink type %q", c.Sink.Type)
+	}
+	if len(c.Databases) == 0 {
+		return errors.Wrap(ErrInvalidConfig, "no database to publish")
+	}
+	var seen = make(map[proto.DatabaseID]bool, len(c.Databases))
+	for i := range c.Databases {
+		db := &c.Databases[i]
+		if db.ID == "" {
+			return errors.Wrapf(ErrInvalidConfig, "database %d has no id", i)
+		}
+		if seen[db.ID] {
+			return errors.Wrapf(ErrInvalidConfig, "duplicate database %s", db.ID)
+		}
+		seen[db.ID] = true
+		if db.Topic == "" {
+			db.Topic = c.TopicPrefix + string(db.ID)
+		}
+	}
+	return
+}
+
+// LoadConfig loads the connector config from the yaml file.
+func LoadConfig(path string) (c *Config, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	c = &Config{}
+	if err = yaml.Unmarshal(data, c); err != nil {
+		return
+	}
+	err = c.Validate()
+	return
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/sqlchain/observer"
+	"sqlit/src/utils/log"
+)
+
+const (
+	changesAPI        = "/apiproxy.sqlit/v4/dbs/%s/changes"
+	checkpointFileExt = ".cursor"
+)
+
+// Event defines a row change event published to the sink.
+type Event struct {
+	DB proto.DatabaseID `json:"db"`
+	*observer.RowChange
+}
+
+// Key returns the key of the event, the changes of the same row share the same key so that they
+// are kept in order in a kafka partition.
+func (e *Event) Key() string {
+	return string(e.DB) + "/" + e.Table + "/" + string(e.PK)
+}
+
+type changesResp struct {
+	Success bool   `json:"success"`
+	Status  string `json:"status"`
+	Data    struct {
+		Changes []*observer.RowChange `json:"changes"`
+		Cursor  int64                 `json:"cursor"`
+	} `json:"data"`
+}
+
+// checkpoint stores the last published cursor of a database in a file.
+type checkpoint struct {
+	path string
+}
+
+func newCheckpoint(dir string, dbID proto.DatabaseID) *checkpoint {
+	return &checkpoint{path: filepath.Join(dir, string(dbID)+checkpointFileExt)}
+}
+
+// load returns the saved cursor, or 0 if there is none.
+func (c *checkpoint) load() (cursor int64, err error) {
+	data, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// save replaces the saved cursor atomically.
+func (c *checkpoint) save(cursor int64) (err error) {
+	tmp := c.path + ".tmp"
+	if err = os.WriteFile(tmp, []byte(strconv.FormatInt(cursor, 10)), 0644); err != nil {
+		return
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Connector publishes the row changes of the databases from the explorer change feeds to the sink.
+// A batch of changes is checkpointed after it is published, so an event may be published again
+// after a failure, the consumers should deduplicate the events by db and cursor.
+type Connector struct {
+	cfg    *Config
+	sink   Sink
+	client *http.Client
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewConnector returns a new connector of the config.
+func NewConnector(cfg *Config) (c *Connector, err error) {
+	if err = os.MkdirAll(cfg.CheckpointDir, 0755); err != nil {
+		return
+	}
+	var sink Sink
+	if sink, err = NewSink(cfg.Sink); err != nil {
+		return
+	}
+	c = &Connector{
+		cfg:  cfg,
+		sink: sink,
+		// allow the long polling of the change feeds
+		client: &http.Client{Timeout: cfg.Wait + 30*time.Second},
+	}
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	return
+}
+
+// Start starts publishing the changes of each database.
+func (c *Connector) Start() {
+	for _, db := range c.cfg.Databases {
+		c.wg.Add(1)
+		go c.run(db)
+	}
+}
+
+// Stop stops the connector and waits for the in-flight batches.
+func (c *Connector) Stop() {
+	c.cancel()
+	c.wg.Wait()
+	if err := c.sink.Close(); err != nil {
+		log.WithError(err).Warning("close sink failed")
+	}
+}
+
+func (c *Connector) run(db DatabaseConfig) {
+	defer c.wg.Done()
+	var (
+		le     = log.WithFields(log.Fields{"db": db.ID, "topic": db.Topic})
+		cp     = newCheckpoint(c.cfg.CheckpointDir, db.ID)
+		cursor int64
+		err    error
+	)
+	for {
+		if cursor, err = cp.load(); err == nil {
+			break
+		}
+		le.WithError(err).Error("load checkpoint failed")
+		if !c.sleep() {
+			return
+		}
+	}
+	le.WithField("cursor", cursor).Info("start publishing changes")
+	for c.ctx.Err() == nil {
+		var next int64
+		if next, err = c.publish(db, cursor); err != nil {
+			if c.ctx.Err() != nil {
+				return
+			}
+			le.WithField("cursor", cursor).WithError(err).Warning("publish changes failed, will retry")
+			if !c.sleep() {
+				return
+			}
+			continue
+		}
+		if next == cursor {
+			continue
+		}
+		if err = cp.save(next); err != nil {
+			le.WithField("cursor", next).WithError(err).Error("save checkpoint failed")
+		}
+		cursor = next
+	}
+}
+
+// publish publishes a batch of changes after cursor, and returns the cursor of the batch.
+func (c *Connector) publish(db DatabaseConfig, cursor int64) (next int64, err error) {
+	var changes []*observer.RowChange
+	if changes, next, err = c.fetch(db.ID, cursor); err != nil || len(changes) == 0 {
+		return
+	}
+	var events = make([]*Event, len(changes))
+	for i, v := range changes {
+		events[i] = &Event{DB: db.ID, RowChange: v}
+	}
+	if err = c.sink.Publish(c.ctx, db.Topic, events); err != nil {
+		next = cursor
+	}
+	return
+}
+
+// fetch long polls the change feed of the database from the explorer.
+func (c *Connector) fetch(dbID proto.DatabaseID, cursor int64) (
+	changes []*observer.RowChange, next int64, err error,
+) {
+	var (
+		params = url.Values{}
+		req    *http.Request
+		resp   *http.Response
+		result changesResp
+	)
+	params.Set("cursor", strconv.FormatInt(cursor, 10))
+	params.Set("limit", strconv.Itoa(c.cfg.BatchSize))
+	params.Set("wait", strconv.Itoa(int(c.cfg.Wait/time.Second)))
+	u := strings.TrimRight(c.cfg.Explorer, "/") +
+		fmt.Sprintf(changesAPI, url.PathEscape(string(dbID))) + "?" + params.Encode()
+	if req, err = http.NewRequestWithContext(c.ctx, http.MethodGet, u, nil); err != nil {
+		return
+	}
+	if resp, err = c.client.Do(req); err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		err = errors.Wrapf(err, "decode changes response of status %d", resp.StatusCode)
+		return
+	}
+	if !result.Success {
+		err = errors.Errorf("fetch changes failed: %s", result.Status)
+		return
+	}
+	return result.Data.Changes, result.Data.Cursor, nil
+}
+
+// sleep waits for the retry interval, it returns false if the connector is stopped.
+func (c *Connector) sleep() bool {
+	select {
+	case <-c.ctx.Done():
+		return false
+	case <-time.After(c.cfg.RetryInterval):
+		return true
+	}
+}
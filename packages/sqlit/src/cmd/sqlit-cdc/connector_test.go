@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/sqlchain/observer"
+)
+
+const testDB = "db"
+
+// testExplorer serves a change feed of total changes.
+func testExplorer(total int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var (
+			cursor, _ = strconv.ParseInt(r.URL.Query().Get("cursor"), 10, 64)
+			limit, _  = strconv.ParseInt(r.URL.Query().Get("limit"), 10, 64)
+			changes   = make([]*observer.RowChange, 0)
+			next      = cursor
+		)
+		if r.URL.Path != fmt.Sprintf(changesAPI, testDB) {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for i := cursor + 1; i <= total && int64(len(changes)) < limit; i++ {
+			changes = append(changes, &observer.RowChange{
+				Cursor: i,
+				Table:  "t",
+				Op:     observer.ChangeInsert,
+				PK:     json.RawMessage(fmt.Sprintf(`{"id":%d}`, i)),
+				After:  json.RawMessage(fmt.Sprintf(`{"id":%d}`, i)),
+			})
+			next = i
+		}
+		_ = json.NewEncoder(rw).Encode(map[string]interface{}{
+			"status":  "ok",
+			"success": true,
+			"data":    map[string]interface{}{"changes": changes, "cursor": next},
+		})
+	}))
+}
+
+func TestConfig(t *testing.T) {
+	Convey("Given a cdc connector config file", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit-cdc")
+		So(err, ShouldBeNil)
+		Reset(func() { _ = os.RemoveAll(dir) })
+		path := filepath.Join(dir, "cdc.yaml")
+
+		Convey("The defaults should be filled", func() {
+			So(os.WriteFile(path, []byte(`
+Explorer: http://127.0.0.1:8546
+CheckpointDir: `+dir+`
+Wait: 10s
+Sink:
+  Type: nats
+  NATS:
+    Addr: 127.0.0.1:4222
+Databases:
+  - ID: db1
+  - ID: db2
+    Topic: orders
+`), 0644), ShouldBeNil)
+			cfg, err := LoadConfig(path)
+			So(err, ShouldBeNil)
+			So(cfg.Wait, ShouldEqual, 10*time.Second)
+			So(cfg.BatchSize, ShouldEqual, defaultBatchSize)
+			So(cfg.Databases[0].Topic, ShouldEqual, defaultTopicPrefix+"db1")
+			So(cfg.Databases[1].Topic, ShouldEqual, "orders")
+		})
+		Convey("The invalid sinks should be rejected", func() {
+			So(os.WriteFile(path, []byte(`
+Explorer: http://127.0.0.1:8546
+CheckpointDir: `+dir+`
+Sink:
+  Type: kafka
+Databases:
+  - ID: db1
+`), 0644), ShouldBeNil)
+			_, err := LoadConfig(path)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidConfig)
+		})
+	})
+}
+
+func TestConnector(t *testing.T) {
+	Convey("Given a connector publishing to a kafka rest proxy", t, func() {
+		dir, err := os.MkdirTemp("", "sqlit-cdc")
+		So(err, ShouldBeNil)
+		explorer := testExplorer(5)
+		Reset(func() {
+			explorer.Close()
+			_ = os.RemoveAll(dir)
+		})
+
+		var (
+			l       sync.Mutex
+			fail    = true
+			records []kafkaRecord
+		)
+		proxy := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+			l.Lock()
+			defer l.Unlock()
+			// fail the first batch to retry
+			if fail {
+				fail = false
+				rw.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			var req kafkaProduceReq
+			if r.URL.Path != "/topics/orders" || json.NewDecoder(r.Body).Decode(&req) != nil {
+				rw.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			resp := map[string]interface{}{}
+			var offsets []map[string]interface{}
+			for _, v := range req.Records {
+				offsets = append(offsets, map[string]interface{}{"partition": 0, "offset": len(records)})
+				records = append(records, v)
+			}
+			resp["offsets"] = offsets
+			_ = json.NewEncoder(rw).Encode(resp)
+		}))
+		Reset(proxy.Close)
+
+		cfg := &Config{
+			Explorer:      explorer.URL,
+			CheckpointDir: dir,
+			BatchSize:     2,
+			RetryInterval: 10 * time.Millisecond,
+			Sink:          &SinkConfig{Type: SinkKafka, Kafka: &KafkaConfig{RESTProxy: proxy.URL}},
+			Databases:     []DatabaseConfig{{ID: testDB, Topic: "orders"}},
+		}
+		So(cfg.Validate(), ShouldBeNil)
+		c, err := NewConnector(cfg)
+		So(err, ShouldBeNil)
+
+		Convey("The changes should be published in order and checkpointed", func() {
+			c.Start()
+			cp := newCheckpoint(dir, testDB)
+			var cursor int64
+			for i := 0; i < 100 && cursor < 5; i++ {
+				time.Sleep(20 * time.Millisecond)
+				cursor, err = cp.load()
+				So(err, ShouldBeNil)
+			}
+			c.Stop()
+			So(cursor, ShouldEqual, 5)
+			So(records, ShouldHaveLength, 5)
+			for i, v := range records {
+				So(v.Value.Cursor, ShouldEqual, i+1)
+				So(v.Key, ShouldEqual, fmt.Sprintf(`db/t/{"id":%d}`, i+1))
+			}
+		})
+	})
+}
+
+func TestNATSSink(t *testing.T) {
+	Convey("Given a nats server", t, func() {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		Reset(func() { _ = ln.Close() })
+
+		var (
+			msgs    = make(chan string, 10)
+			connect = make(chan string, 1)
+		)
+		go func() {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			_, _ = conn.Write([]byte(`INFO {"max_payload":1048576}` + "\r\n"))
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				line = strings.TrimRight(line, "\r\n")
+				switch {
+				case strings.HasPrefix(line, "CONNECT "):
+					connect <- line[8:]
+				case line == "PING":
+					_, _ = conn.Write([]byte("PONG\r\n"))
+				case strings.HasPrefix(line, "PUB "):
+					payload, _ := r.ReadString('\n')
+					msgs <- strings.Fields(line)[1] + " " + strings.TrimRight(payload, "\r\n")
+				}
+			}
+		}()
+
+		s := newNATSSink(&NATSConfig{Addr: ln.Addr().String(), Token: "secret"})
+		Reset(func() { _ = s.Close() })
+
+		Convey("The events should be published with the auth", func() {
+			err := s.Publish(context.Background(), "sqlit.cdc.db", []*Event{
+				{DB: testDB, RowChange: &observer.RowChange{Cursor: 1, Table: "t", PK: json.RawMessage(`{"id":1}`)}},
+				{DB: testDB, RowChange: &observer.RowChange{Cursor: 2, Table: "t", PK: json.RawMessage(`{"id":2}`)}},
+			})
+			So(err, ShouldBeNil)
+			So(<-connect, ShouldContainSubstring, `"auth_token":"secret"`)
+			So(msgs, ShouldHaveLength, 2)
+			for i := 1; i <= 2; i++ {
+				msg := <-msgs
+				So(msg, ShouldStartWith, "sqlit.cdc.db ")
+				var e map[string]interface{}
+				So(json.Unmarshal([]byte(strings.TrimPrefix(msg, "sqlit.cdc.db ")), &e), ShouldBeNil)
+				So(e["db"], ShouldEqual, testDB)
+				So(e["cursor"], ShouldEqual, i)
+			}
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	kafkaContentType = "application/vnd.kafka.json.v2+json"
+	kafkaAccept      = "application/vnd.kafka.v2+json"
+	kafkaTimeout     = 30 * time.Second
+)
+
+type kafkaRecord struct {
+	Key   string `json:"key"`
+	Value *Event `json:"value"`
+}
+
+type kafkaProduceReq struct {
+	Records []kafkaRecord `json:"records"`
+}
+
+type kafkaProduceResp struct {
+	Offsets []struct {
+		Partition int32   `json:"partition"`
+		Offset    int64   `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+// kafkaSink produces the events to kafka through the REST proxy, the records are keyed by rows.
+type kafkaSink struct {
+	proxy  string
+	client *http.Client
+}
+
+func newKafkaSink(cfg *KafkaConfig) *kafkaSink {
+	return &kafkaSink{
+		proxy:  strings.TrimRight(cfg.RESTProxy, "/"),
+		client: &http.Client{Timeout: kafkaTimeout},
+	}
+}
+
+func (s *kafkaSink) Publish(ctx context.Context, topic string, events []*Event) (err error) {
+	var (
+		body = &kafkaProduceReq{Records: make([]kafkaRecord, len(events))}
+		data []byte
+		req  *http.Request
+		resp *http.Response
+	)
+	for i, e := range events {
+		body.Records[i] = kafkaRecord{Key: e.Key(), Value: e}
+	}
+	if data, err = json.Marshal(body); err != nil {
+		return
+	}
+	if req, err = http.NewRequestWithContext(ctx, http.MethodPost,
+		s.proxy+"/topics/"+url.PathEscape(topic), bytes.NewReader(data),
+	); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", kafkaContentType)
+	req.Header.Set("Accept", kafkaAccept)
+	if resp, err = s.client.Do(req); err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("produce to %s failed with status %d: %s", topic, resp.StatusCode, msg)
+	}
+	var result kafkaProduceResp
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return
+	}
+	if len(result.Offsets) != len(events) {
+		return errors.Errorf("produce to %s returned %d offsets of %d records",
+			topic, len(result.Offsets), len(events))
+	}
+	for i, o := range result.Offsets {
+		if o.ErrorCode != nil || o.Error != nil {
+			var msg string
+			if o.Error != nil {
+				msg = *o.Error
+			}
+			return errors.Errorf("produce record %d to %s failed: %s", i, topic, msg)
+		}
+	}
+	return
+}
+
+func (s *kafkaSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+const name = "sqlit-cdc"
+
+var (
+	version     = "unknown"
+	configFile  string
+	showVersion bool
+	logLevel    string
+)
+
+func init() {
+	flag.StringVar(&configFile, "config", "~/.sqlit/cdc.yaml", "Config file for cdc connector")
+	flag.BoolVar(&showVersion, "version", false, "Show version information and exit")
+	flag.StringVar(&logLevel, "log-level", "", "Service log level")
+}
+
+func main() {
+	flag.Parse()
+	log.SetStringLevel(logLevel, log.InfoLevel)
+	if showVersion {
+		fmt.Printf("%v %v %v %v %v\n",
+			name, version, runtime.GOOS, runtime.GOARCH, runtime.Version())
+		os.Exit(0)
+	}
+
+	configFile = utils.HomeDirExpand(configFile)
+
+	flag.Visit(func(f *flag.Flag) {
+		log.Infof("args %#v : %s", f.Name, f.Value)
+	})
+
+	cfg, err := LoadConfig(configFile)
+	if err != nil {
+		log.WithError(err).Fatal("load config failed")
+		return
+	}
+
+	connector, err := NewConnector(cfg)
+	if err != nil {
+		log.WithError(err).Fatal("init connector failed")
+		return
+	}
+
+	connector.Start()
+
+	log.Info("started cdc connector")
+
+	<-utils.WaitForExit()
+
+	connector.Stop()
+
+	log.Info("stopped cdc connector")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	natsDialTimeout = 10 * time.Second
+	natsTimeout     = 30 * time.Second
+)
+
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+type natsConnect struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Version  string `json:"version"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+	Token    string `json:"auth_token,omitempty"`
+}
+
+// natsSink publishes the events to a nats subject with the plain text protocol. A batch is flushed
+// with a PING, and the PONG confirms that the server has processed all the messages before it.
+type natsSink struct {
+	sync.Mutex
+	cfg  *NATSConfig
+	conn net.Conn
+	r    *bufio.Reader
+	info natsInfo
+}
+
+func newNATSSink(cfg *NATSConfig) *natsSink {
+	return &natsSink{cfg: cfg}
+}
+
+func (s *natsSink) connect() (err error) {
+	var (
+		conn net.Conn
+		line string
+	)
+	if conn, err = net.DialTimeout("tcp", s.cfg.Addr, natsDialTimeout); err != nil {
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(natsTimeout))
+	s.conn, s.r = conn, bufio.NewReader(conn)
+	defer func() {
+		if err != nil {
+			s.close()
+		}
+	}()
+
+	if line, err = s.readLine(); err != nil {
+		return
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return errors.Errorf("unexpected nats greeting: %s", line)
+	}
+	if err = json.Unmarshal([]byte(line[5:]), &s.info); err != nil {
+		return
+	}
+	if s.info.TLSRequired {
+		return errors.New("nats server requires tls, which is not supported")
+	}
+	var connect []byte
+	if connect, err = json.Marshal(&natsConnect{
+		Name:    name,
+		Lang:    "go",
+		Version: version,
+		User:    s.cfg.User,
+		Pass:    s.cfg.Password,
+		Token:   s.cfg.Token,
+	}); err != nil {
+		return
+	}
+	if _, err = fmt.Fprintf(s.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return
+	}
+	return s.waitPong()
+}
+
+func (s *natsSink) readLine() (line string, err error) {
+	if line, err = s.r.ReadString('\n'); err != nil {
+		return
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// waitPong reads the server messages until the PONG, it fails on any error from the server.
+func (s *natsSink) waitPong() (err error) {
+	for {
+		var line string
+		if line, err = s.readLine(); err != nil {
+			return
+		}
+		switch {
+		case line == "PONG":
+			return
+		case line == "PING":
+			if _, err = s.conn.Write([]byte("PONG\r\n")); err != nil {
+				return
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return errors.Errorf("nats error: %s", strings.TrimSpace(line[4:]))
+		}
+		// ignore +OK and INFO updates
+	}
+}
+
+func (s *natsSink) Publish(ctx context.Context, topic string, events []*Event) (err error) {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn == nil {
+		if err = s.connect(); err != nil {
+			return
+		}
+	}
+	defer func() {
+		if err != nil {
+			// the connection state is unknown, reconnect in the next batch
+			s.close()
+		}
+	}()
+	var deadline = time.Now().Add(natsTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = s.conn.SetDeadline(deadline)
+
+	w := bufio.NewWriter(s.conn)
+	for _, e := range events {
+		var payload []byte
+		if payload, err = json.Marshal(e); err != nil {
+			return
+		}
+		if s.info.MaxPayload > 0 && len(payload) > s.info.MaxPayload {
+			return errors.Errorf("event %d of %d bytes exceeds nats max payload %d",
+				e.Cursor, len(payload), s.info.MaxPayload)
+		}
+		if _, err = fmt.Fprintf(w, "PUB %s %d\r\n%s\r\n", topic, len(payload), payload); err != nil {
+			return
+		}
+	}
+	if _, err = w.WriteString("PING\r\n"); err != nil {
+		return
+	}
+	if err = w.Flush(); err != nil {
+		return
+	}
+	return s.waitPong()
+}
+
+func (s *natsSink) close() {
+	if s.conn != nil {
+		_ = s.conn.Close()
+		s.conn, s.r = nil, nil
+	}
+}
+
+func (s *natsSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.close()
+	return nil
+}
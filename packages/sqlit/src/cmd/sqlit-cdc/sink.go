@@ -0,0 +1,27 @@
+package main
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// Sink defines the target of the change events.
+type Sink interface {
+	// Publish publishes the events to the topic, it returns nil only after all the events are
+	// accepted by the target.
+	Publish(ctx context.Context, topic string, events []*Event) error
+	Close() error
+}
+
+// NewSink returns the sink of the config.
+func NewSink(cfg *SinkConfig) (s Sink, err error) {
+	switch cfg.Type {
+	case SinkKafka:
+		return newKafkaSink(cfg.Kafka), nil
+	case SinkNATS:
+		return newNATSSink(cfg.NATS), nil
+	default:
+		return nil, errors.Wrapf(ErrInvalidConfig, "unknown sink type %q", cfg.Type)
+	}
+}
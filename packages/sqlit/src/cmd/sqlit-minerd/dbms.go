@@ -22,6 +22,32 @@ func startDBMS(server *mux.Server, direct *rpc.Server, onCreateDB func()) (dbms
 		DirectServer:     direct,
 		MaxReqTimeGap:    conf.GConf.Miner.MaxReqTimeGap,
 		OnCreateDatabase: onCreateDB,
+
+		SyncReadBandwidth:  conf.GConf.Miner.SyncReadBandwidth,
+		SyncWriteBandwidth: conf.GConf.Miner.SyncWriteBandwidth,
+		ApplyConcurrency:   conf.GConf.Miner.ApplyConcurrency,
+		GroupCommitDelay:   conf.GConf.Miner.GroupCommitDelay,
+		SnapshotReads:      conf.GConf.Miner.SnapshotReads,
+		AllowUnencrypted:   conf.GConf.Miner.AllowUnencryptedStorage,
+		StandbyRetention:   conf.GConf.Miner.StandbyRetention,
+		IdempotencyWindow:  conf.GConf.Miner.IdempotencyWindow,
+		Backup:             conf.GConf.Miner.Backup,
+		Maintenance:        conf.GConf.Miner.Maintenance,
+		Admission:          conf.GConf.Miner.Admission,
+		ResultLimit:        conf.GConf.Miner.ResultLimit,
+		ResultCompression:  conf.GConf.Miner.ResultCompression,
+		Audit:              conf.GConf.Miner.Audit,
+		PayloadAlert:       conf.GConf.Miner.PayloadAlert,
+		BlockPacking:       conf.GConf.Miner.BlockPacking,
+
+		QuotaWarningThresholds: conf.GConf.Miner.QuotaWarningThresholds,
+		LatencyProbeInterval:   conf.GConf.Miner.LatencyProbeInterval,
+		TTLCheckInterval:       conf.GConf.Miner.TTLCheckInterval,
+		JobCheckInterval:       conf.GConf.Miner.JobCheckInterval,
+		ForeignDataHosts:       conf.GConf.Miner.ForeignDataHosts,
+		LoadReportInterval:     conf.GConf.Miner.LoadReportInterval,
+		AckReconcileWindow:     conf.GConf.Miner.AckReconcileWindow,
+		LeaderTimestamp:        conf.GConf.Miner.LeaderTimestamp,
 	}
 
 	if dbms, err = worker.NewDBMS(cfg); err != nil {
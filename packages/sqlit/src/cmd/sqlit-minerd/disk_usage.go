@@ -6,19 +6,108 @@ import (
 	"io"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 
 	mw "github.com/zserge/metric"
 
 	"sqlit/src/conf"
+	"sqlit/src/utils"
 	"sqlit/src/utils/log"
 )
 
 var (
 	diskUsageMetric = mw.NewGauge("5m1m")
+
+	// defaultDiskAlertThresholds defines the default filesystem utilization ratios to emit alerts.
+	defaultDiskAlertThresholds = []float64{0.8, 0.9, 0.95}
+
+	diskUtilizationVar = new(expvar.Float)
+	diskAlertLevelVar  = new(expvar.Int)
+	diskAlerts         diskAlert
 )
 
+// diskAlert tracks the utilization of the filesystem containing the miner root dir.
+type diskAlert struct {
+	sync.Mutex
+	level int // count of thresholds crossed
+}
+
+// thresholds returns the sorted alert thresholds of the miner config.
+func (d *diskAlert) thresholds() (thresholds []float64) {
+	if conf.GConf != nil && conf.GConf.Miner != nil && conf.GConf.Miner.DiskAlertThresholds != nil {
+		thresholds = append(thresholds, conf.GConf.Miner.DiskAlertThresholds...)
+	} else {
+		thresholds = append(thresholds, defaultDiskAlertThresholds...)
+	}
+	sort.Float64s(thresholds)
+	return
+}
+
+// update updates the alert level with the filesystem stats, and returns the max space to be
+// advertised to keep the utilization under the highest threshold once any threshold is crossed.
+func (d *diskAlert) update(thresholds []float64, total, avail uint64) (space uint64, limited bool) {
+	if total == 0 || len(thresholds) == 0 {
+		return
+	}
+	if avail > total {
+		avail = total
+	}
+	var (
+		used  = total - avail
+		ratio = float64(used) / float64(total)
+		level int
+	)
+	for _, t := range thresholds {
+		if ratio >= t {
+			level++
+		}
+	}
+	diskUtilizationVar.Set(ratio)
+	diskAlertLevelVar.Set(int64(level))
+
+	d.Lock()
+	defer d.Unlock()
+	fields := log.Fields{
+		"total": total,
+		"avail": avail,
+		"ratio": ratio,
+	}
+	if level > d.level {
+		fields["threshold"] = thresholds[level-1]
+		log.WithFields(fields).Warning("miner disk utilization crossed alert threshold, " +
+			"reducing advertised space")
+	} else if level == 0 && d.level > 0 {
+		log.WithFields(fields).Info("miner disk utilization recovered below alert thresholds")
+	}
+	d.level = level
+	if level == 0 {
+		return
+	}
+
+	limited = true
+	if max := uint64(float64(total) * thresholds[len(thresholds)-1]); max > used {
+		space = max - used
+	}
+	return
+}
+
+// checkDiskAlert checks the utilization of the filesystem of miner root dir, and returns the max
+// space to be advertised if it is limited by the alert thresholds.
+func checkDiskAlert() (space uint64, limited bool) {
+	if conf.GConf == nil || conf.GConf.Miner == nil || conf.GConf.Miner.RootDir == "" {
+		return
+	}
+	total, avail, err := utils.FilesystemStat(conf.GConf.Miner.RootDir)
+	if err != nil {
+		log.WithError(err).Debug("get miner filesystem stat failed")
+		return
+	}
+	return diskAlerts.update(diskAlerts.thresholds(), total, avail)
+}
+
 func collectDiskUsage() (err error) {
 	// run du on linux and mac
 	if runtime.GOOS != "linux" && runtime.GOOS != "darwin" {
@@ -74,10 +163,13 @@ func collectDiskUsage() (err error) {
 	}
 
 	diskUsageMetric.Add(float64(usedKiloBytes))
+	checkDiskAlert()
 
 	return
 }
 
 func init() {
 	expvar.Publish("service:miner:disk:usage", diskUsageMetric)
+	expvar.Publish("service:miner:disk:utilization", diskUtilizationVar)
+	expvar.Publish("service:miner:disk:alert", diskAlertLevelVar)
 }
@@ -0,0 +1,41 @@
+//go:build !testbinary
+// +build !testbinary
+
+package main
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDiskAlert(t *testing.T) {
+	Convey("Advertised space should be limited once disk utilization crosses thresholds", t, func() {
+		var (
+			d          diskAlert
+			thresholds = []float64{0.8, 0.9}
+		)
+		space, limited := d.update(thresholds, 1000, 500)
+		So(limited, ShouldBeFalse)
+		So(d.level, ShouldEqual, 0)
+
+		space, limited = d.update(thresholds, 1000, 150)
+		So(limited, ShouldBeTrue)
+		So(d.level, ShouldEqual, 1)
+		So(space, ShouldEqual, 50)
+		So(diskAlertLevelVar.Value(), ShouldEqual, 1)
+
+		space, limited = d.update(thresholds, 1000, 50)
+		So(limited, ShouldBeTrue)
+		So(d.level, ShouldEqual, 2)
+		So(space, ShouldEqual, 0)
+
+		_, limited = d.update(thresholds, 1000, 900)
+		So(limited, ShouldBeFalse)
+		So(d.level, ShouldEqual, 0)
+		So(diskUtilizationVar.Value(), ShouldAlmostEqual, 0.1)
+
+		_, limited = d.update(nil, 1000, 0)
+		So(limited, ShouldBeFalse)
+	})
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net"
@@ -16,8 +17,11 @@ import (
 
 	"sqlit/src/conf"
 	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/diag"
 	"sqlit/src/metric"
+	"sqlit/src/route"
 	"sqlit/src/rpc"
+	"sqlit/src/rpc/grpcgw"
 	"sqlit/src/rpc/mux"
 	"sqlit/src/utils"
 	"sqlit/src/utils/log"
@@ -123,6 +127,14 @@ func main() {
 	if err != nil {
 		log.WithField("config", configFile).WithError(err).Fatal("load config failed")
 	}
+	if err = conf.CheckInsecureDevMode(
+		conf.GConf, conf.UsedInsecureFeatures(conf.GConf.UseTestMasterKey, false)...,
+	); err != nil {
+		log.WithError(err).Fatal("check insecure dev mode failed")
+	}
+	if err = conf.InitLog(conf.GConf.Log); err != nil {
+		log.WithError(err).Fatal("init log file failed")
+	}
 
 	if conf.GConf.Miner == nil {
 		log.Fatal("miner config does not exists")
@@ -138,6 +150,12 @@ func main() {
 		log.Warning("miner disk usage interval not provided, set to default 10 minutes")
 		conf.GConf.Miner.DiskUsageInterval = time.Minute * 10
 	}
+	if conf.GConf.Miner.AckReconcileWindow <= 0 {
+		conf.GConf.Miner.AckReconcileWindow = worker.DefaultAckReconcileWindow
+	}
+	if conf.GConf.Miner.LoadReportInterval <= 0 {
+		conf.GConf.Miner.LoadReportInterval = worker.DefaultLoadReportInterval
+	}
 
 	log.Debugf("config:\n%#v", conf.GConf)
 
@@ -218,6 +236,11 @@ func main() {
 		}
 	}()
 
+	// register diagnostic service rpc
+	if err = server.RegisterService(route.DiagRPCName, diag.NewService()); err != nil {
+		log.WithError(err).Fatal("register diagnostic service failed")
+	}
+
 	// start rpc server
 	go func() {
 		server.Serve()
@@ -245,6 +268,23 @@ func main() {
 
 	defer dbms.Shutdown()
 
+	if conf.GConf.GRPC != nil {
+		var gw *grpcgw.Server
+		if gw, err = grpcgw.NewServer(&grpcgw.Config{
+			ListenAddr: conf.GConf.GRPC.ListenAddr,
+			CertFile:   conf.GConf.GRPC.CertFile,
+			KeyFile:    conf.GConf.GRPC.KeyFile,
+			NodeID:     conf.GConf.ThisNodeID,
+			DBMS:       dbms.RPCService(),
+		}); err != nil {
+			log.WithError(err).Fatal("init grpc gateway failed")
+		}
+		if err = gw.Start(); err != nil {
+			log.WithError(err).Fatal("start grpc gateway failed")
+		}
+		defer gw.Stop()
+	}
+
 	if metricLog {
 		go metrics.Log(metrics.DefaultRegistry, 5*time.Second, log.StandardLogger())
 	}
@@ -277,6 +317,18 @@ func main() {
 	}
 
 	<-utils.WaitForExit()
+
+	// hand off the database leadership and flush pending blocks before the deferred shutdown
+	if timeout := conf.GConf.Miner.ShutdownTimeout; timeout >= 0 {
+		if timeout == 0 {
+			timeout = worker.DefaultShutdownTimeout
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		if err = dbms.Drain(ctx); err != nil {
+			log.WithError(err).Error("drain dbms failed")
+		}
+		cancel()
+	}
 	utils.StopProfile()
 
 	log.Info("miner stopped")
@@ -11,6 +11,8 @@ import (
 
 	"sqlit/src/conf"
 	"sqlit/src/crypto/kms"
+	"sqlit/src/jeju"
+	"sqlit/src/naconn"
 	"sqlit/src/route"
 	"sqlit/src/rpc"
 	"sqlit/src/rpc/mux"
@@ -48,6 +50,22 @@ func initNode() (server *mux.Server, direct *rpc.Server, err error) {
 	// init kms routing
 	route.InitKMS(conf.GConf.PubKeyStoreFile)
 
+	// init node id resolver chain
+	if _, err = jeju.InitRegistryResolver(conf.GConf); err != nil {
+		log.WithError(err).Error("init registry resolver failed")
+		return
+	}
+	if err = naconn.InitResolvers(conf.GConf); err != nil {
+		log.WithError(err).Error("init node resolvers failed")
+		return
+	}
+
+	// init mutual tls of the node connections
+	if err = naconn.InitTransport(conf.GConf); err != nil {
+		log.WithError(err).Error("init mtls transport failed")
+		return
+	}
+
 	err = mux.RegisterNodeToBP(30 * time.Second)
 	if err != nil {
 		log.Fatalf("register node to BP failed: %v", err)
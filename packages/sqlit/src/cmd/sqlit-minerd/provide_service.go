@@ -106,6 +106,15 @@ func sendProvideService(reg *prometheus.Registry) {
 		loadAvg = loadAvg / cpuCount
 	}
 
+	// avoid being matched to databases which can't be hosted on a nearly full disk
+	if limit, limited := checkDiskAlert(); limited && limit < keySpace {
+		log.WithFields(log.Fields{
+			"space": keySpace,
+			"limit": limit,
+		}).Warning("advertised space reduced by disk utilization alert")
+		keySpace = limit
+	}
+
 	log.WithFields(log.Fields{
 		"memory":  memoryBytes,
 		"loadAvg": loadAvg,
@@ -139,6 +148,10 @@ func sendProvideService(reg *prometheus.Registry) {
 	if conf.GConf.Miner != nil && len(conf.GConf.Miner.TargetUsers) > 0 {
 		tx.ProvideServiceHeader.TargetUser = conf.GConf.Miner.TargetUsers
 	}
+	if conf.GConf.Miner != nil {
+		tx.ProvideServiceHeader.Region = conf.GConf.Miner.Region
+		tx.ProvideServiceHeader.Zone = conf.GConf.Miner.Zone
+	}
 
 	tx.Nonce = nonceResp.Nonce
 
@@ -8,6 +8,7 @@ import (
 	"runtime"
 
 	"sqlit/src/client"
+	"sqlit/src/conf"
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/utils"
 	"sqlit/src/utils/log"
@@ -60,6 +61,10 @@ func main() {
 		log.WithError(err).Fatal("init sqlit client failed")
 		return
 	}
+	if err := conf.InitLog(conf.GConf.Log); err != nil {
+		log.WithError(err).Fatal("init log file failed")
+		return
+	}
 
 	server, err := NewServer(listenAddr, mysqlUser, mysqlPassword)
 	if err != nil {
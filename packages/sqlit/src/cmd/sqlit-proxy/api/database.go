@@ -106,6 +106,83 @@ func databaseBalance(c *gin.Context) {
 	abortWithError(c, http.StatusForbidden, ErrNotAuthorizedAdmin)
 }
 
+func formatDatabaseLoad(l *types.DatabaseLoad) gin.H {
+	return gin.H{
+		"node":             l.NodeID,
+		"timestamp":        l.Timestamp,
+		"qps":              l.QPS(),
+		"p95_latency_ms":   float64(l.P95Latency) / float64(time.Millisecond),
+		"cache_hit_rate":   l.CacheHitRate(),
+		"storage_bytes":    l.StorageBytes,
+		"growth_bytes_day": l.StorageGrowthPerDay(),
+	}
+}
+
+func databaseLoad(c *gin.Context) {
+	r := struct {
+		Database proto.DatabaseID `json:"db" form:"db" uri:"db" binding:"required,len=64"`
+	}{}
+
+	_ = c.ShouldBindUri(&r)
+
+	if err := c.ShouldBind(&r); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	developer := getDeveloperID(c)
+	p, err := model.GetMainAccount(model.GetDB(c), developer)
+	if err != nil {
+		_ = c.Error(err)
+		abortWithError(c, http.StatusForbidden, ErrNoMainAccount)
+		return
+	}
+
+	var profile *types.SQLChainProfile
+	if profile, err = getDatabaseProfile(r.Database); err != nil {
+		_ = c.Error(err)
+		abortWithError(c, http.StatusInternalServerError, ErrSendETLSRPCFailed)
+		return
+	}
+
+	accountAddr, err := p.Account.Get()
+	if err != nil {
+		_ = c.Error(err)
+		abortWithError(c, http.StatusBadRequest, ErrParseAccountFailed)
+		return
+	}
+
+	var authorized bool
+	for _, user := range profile.Users {
+		if user.Address == accountAddr && user.Permission.HasSuperPermission() {
+			authorized = true
+			break
+		}
+	}
+	if !authorized {
+		abortWithError(c, http.StatusForbidden, ErrNotAuthorizedAdmin)
+		return
+	}
+
+	req := &types.QueryDatabaseLoadReq{DBID: r.Database}
+	resp := new(types.QueryDatabaseLoadResp)
+	if err = rpc.RequestBP(route.MCCQueryDatabaseLoad.String(), req, resp); err != nil {
+		_ = c.Error(err)
+		abortWithError(c, http.StatusInternalServerError, ErrSendETLSRPCFailed)
+		return
+	}
+
+	var miners = make([]gin.H, 0, len(resp.Miners))
+	for _, l := range resp.Miners {
+		miners = append(miners, formatDatabaseLoad(l))
+	}
+
+	responseWithData(c, http.StatusOK, gin.H{
+		"miners": miners,
+		"total":  formatDatabaseLoad(resp.Total),
+	})
+}
+
 func databasePricing(c *gin.Context) {
 
 }
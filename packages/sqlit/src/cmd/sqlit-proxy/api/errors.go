@@ -128,4 +128,6 @@ var (
 	ErrProjectIsDisabled = errors.New("ERR_PROJECT_IS_DISABLED")
 	// ErrLogoutFailed defines error on failure session logout.
 	ErrLogoutFailed = errors.New("ERR_LOGOUT_FAILED")
+	// ErrGraphQLDisabled defines error on accessing graphql api which is not enabled in proxy config.
+	ErrGraphQLDisabled = errors.New("ERR_GRAPHQL_DISABLED")
 )
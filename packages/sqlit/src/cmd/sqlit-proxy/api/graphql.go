@@ -0,0 +1,363 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	gorp "gopkg.in/gorp.v2"
+
+	"sqlit/src/cmd/sqlit-proxy/graphql"
+	"sqlit/src/cmd/sqlit-proxy/model"
+	"sqlit/src/cmd/sqlit-proxy/resolver"
+)
+
+const (
+	// defaultGraphQLMaxQueryLength defines the default max length of a graphql request document.
+	defaultGraphQLMaxQueryLength = 64 * 1024
+	// graphQLMaxDepth defines the max depth of selections, the generated schema has only the
+	// root fields and the column fields.
+	graphQLMaxDepth = 2
+)
+
+// graphQLResolver serves the graphql root fields with the data api query builders, enforcing
+// the project rules and the field permissions of the requester.
+type graphQLResolver struct {
+	c         *gin.Context
+	db        *gorp.DbMap
+	uid       string
+	userState string
+	vars      map[string]interface{}
+	rules     *resolver.Rules
+	adminMode bool
+	fields    map[string]resolver.FieldMap
+}
+
+func (r *graphQLResolver) enforceFields(table string, fields resolver.FieldMap, write bool) (err error) {
+	if r.adminMode {
+		return
+	}
+	return r.rules.EnforceRulesOnFields(fields, table, r.uid, r.userState, write)
+}
+
+func (r *graphQLResolver) enforceFilter(table string, filter map[string]interface{}, qt resolver.RuleQueryType) (
+	result map[string]interface{}, err error) {
+	// the fields in filter are read by the requester
+	fields, _, _, err := resolver.ResolveFilter(filter, r.fields[table])
+	if err != nil {
+		return
+	}
+	if err = r.enforceFields(table, fields, false); err != nil {
+		return
+	}
+
+	if r.adminMode {
+		return filter, nil
+	}
+
+	if result, err = r.rules.EnforceRulesOnFilter(filter, table, r.uid, r.userState, r.vars, qt); err != nil {
+		_ = r.c.Error(err)
+		err = ErrEnforceRuleOnQueryFailed
+	}
+
+	return
+}
+
+func (r *graphQLResolver) exec(stmt string, args []interface{}) (result *graphql.MutationResult, err error) {
+	res, err := r.db.Exec(stmt, args...)
+	if err != nil {
+		_ = r.c.Error(err)
+		err = ErrExecuteQueryFailed
+		return
+	}
+
+	result = &graphql.MutationResult{
+		AffectedRows: mustGetInt64Var(res.RowsAffected()),
+		LastInsertID: mustGetInt64Var(res.LastInsertId()),
+	}
+
+	return
+}
+
+// Find implements graphql.Resolver.Find.
+func (r *graphQLResolver) Find(table string, fields []string, filter map[string]interface{},
+	order map[string]interface{}, skip *int64, limit *int64) (result []map[string]interface{}, err error) {
+	projection := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		projection[f] = true
+	}
+
+	// the projected and ordering fields are read by the requester
+	projectionFields, _, err := resolver.ResolveProjection(projection, r.fields[table])
+	if err != nil {
+		return
+	}
+	orderByFields, _, err := resolver.ResolveOrderBy(order, r.fields[table])
+	if err != nil {
+		return
+	}
+	projectionFields.Merge(orderByFields)
+	if err = r.enforceFields(table, projectionFields, false); err != nil {
+		return
+	}
+
+	if filter, err = r.enforceFilter(table, filter, resolver.RuleQueryFind); err != nil {
+		return
+	}
+
+	stmt, args, _, err := resolver.Find(table, r.fields[table], filter, projection, order, skip, limit)
+	if err != nil {
+		return
+	}
+
+	var rows *sql.Rows
+	if rows, err = r.db.Query(stmt, args...); err != nil {
+		_ = r.c.Error(err)
+		err = ErrExecuteQueryFailed
+		return
+	}
+
+	var data []gin.H
+	if data, err = scanRows(rows); err != nil {
+		_ = r.c.Error(err)
+		err = ErrScanRowsFailed
+		return
+	}
+
+	result = make([]map[string]interface{}, len(data))
+	for i, row := range data {
+		result[i] = row
+	}
+
+	return
+}
+
+// Count implements graphql.Resolver.Count.
+func (r *graphQLResolver) Count(table string, filter map[string]interface{}) (count int64, err error) {
+	if filter, err = r.enforceFilter(table, filter, resolver.RuleQueryCount); err != nil {
+		return
+	}
+
+	stmt, args, _, err := resolver.Count(table, r.fields[table], filter)
+	if err != nil {
+		return
+	}
+
+	if count, err = r.db.SelectInt(stmt, args...); err != nil {
+		_ = r.c.Error(err)
+		err = ErrExecuteQueryFailed
+	}
+
+	return
+}
+
+// Insert implements graphql.Resolver.Insert.
+func (r *graphQLResolver) Insert(table string, data map[string]interface{}) (
+	result *graphql.MutationResult, err error) {
+	fields, _, _, err := resolver.ResolveInsert(data, r.fields[table])
+	if err != nil {
+		return
+	}
+	if err = r.enforceFields(table, fields, true); err != nil {
+		return
+	}
+
+	if !r.adminMode {
+		if data, err = r.rules.EnforceRulesOnInsert(data, table, r.uid, r.userState, r.vars); err != nil {
+			_ = r.c.Error(err)
+			err = ErrEnforceRuleOnQueryFailed
+			return
+		}
+	}
+
+	stmt, args, _, err := resolver.Insert(table, r.fields[table], data)
+	if err != nil {
+		return
+	}
+
+	return r.exec(stmt, args)
+}
+
+// Update implements graphql.Resolver.Update.
+func (r *graphQLResolver) Update(table string, filter map[string]interface{}, update map[string]interface{},
+	one bool) (result *graphql.MutationResult, err error) {
+	fields, _, _, err := resolver.ResolveUpdate(update, r.fields[table])
+	if err != nil {
+		return
+	}
+	if err = r.enforceFields(table, fields, true); err != nil {
+		return
+	}
+
+	if filter, err = r.enforceFilter(table, filter, resolver.RuleQueryUpdate); err != nil {
+		return
+	}
+	if !r.adminMode {
+		if update, err = r.rules.EnforceRulesOnUpdate(update, table, r.uid, r.userState, r.vars); err != nil {
+			_ = r.c.Error(err)
+			err = ErrEnforceRuleOnQueryFailed
+			return
+		}
+	}
+
+	stmt, args, _, err := resolver.Update(table, r.fields[table], filter, update, one)
+	if err != nil {
+		return
+	}
+
+	return r.exec(stmt, args)
+}
+
+// Remove implements graphql.Resolver.Remove.
+func (r *graphQLResolver) Remove(table string, filter map[string]interface{}, one bool) (
+	result *graphql.MutationResult, err error) {
+	if filter, err = r.enforceFilter(table, filter, resolver.RuleQueryRemove); err != nil {
+		return
+	}
+
+	stmt, args, _, err := resolver.Remove(table, r.fields[table], filter, one)
+	if err != nil {
+		return
+	}
+
+	return r.exec(stmt, args)
+}
+
+// buildGraphQLContext loads the schema generated from the project tables and the resolver of the
+// requester.
+func buildGraphQLContext(c *gin.Context) (schema *graphql.Schema, r *graphQLResolver, err error) {
+	cfg := getConfig(c).GraphQL
+	if cfg == nil || !cfg.Enabled {
+		err = ErrGraphQLDisabled
+		return
+	}
+
+	r = &graphQLResolver{
+		c:      c,
+		fields: map[string]resolver.FieldMap{},
+	}
+	r.db, r.uid, r.userState, r.vars, r.rules, r.adminMode, err = buildUserContext(c)
+	if err != nil {
+		return
+	}
+
+	configs, err := model.GetAllProjectConfig(r.db)
+	if err != nil {
+		err = errors.Wrapf(err, "get project config failed")
+		return
+	}
+
+	var tables []*graphql.Table
+
+	for _, pc := range configs {
+		if pc.Type != model.ProjectConfigTable {
+			continue
+		}
+
+		ptc := pc.Value.(*model.ProjectTableConfig)
+		if ptc.IsDeleted {
+			continue
+		}
+
+		fields := resolver.FieldMap{}
+		for _, col := range ptc.Columns {
+			fields[col] = true
+		}
+		r.fields[pc.Key] = fields
+
+		tables = append(tables, &graphql.Table{
+			Name:    pc.Key,
+			Columns: ptc.Columns,
+			Types:   ptc.Types,
+		})
+	}
+
+	schema = graphql.NewSchema(tables)
+
+	return
+}
+
+func abortWithGraphQLContextError(c *gin.Context, err error) {
+	switch err {
+	case ErrGraphQLDisabled:
+		abortWithError(c, http.StatusNotFound, err)
+	case ErrProjectIsDisabled:
+		abortWithError(c, http.StatusInternalServerError, err)
+	default:
+		_ = c.Error(err)
+		abortWithError(c, http.StatusInternalServerError, ErrPrepareExecutionContextFailed)
+	}
+}
+
+func userGraphQL(c *gin.Context) {
+	r := struct {
+		Query         string                 `json:"query" form:"query" binding:"required"`
+		OperationName string                 `json:"operationName" form:"operationName"`
+		Variables     map[string]interface{} `json:"variables" form:"-"`
+	}{}
+
+	if err := c.ShouldBind(&r); err != nil {
+		abortWithError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	if c.Request.Method == http.MethodGet {
+		if v := c.Query("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &r.Variables); err != nil {
+				abortWithError(c, http.StatusBadRequest, errors.Wrap(err, "invalid variables"))
+				return
+			}
+		}
+	}
+
+	schema, gr, err := buildGraphQLContext(c)
+	if err != nil {
+		abortWithGraphQLContextError(c, err)
+		return
+	}
+
+	maxLength := getConfig(c).GraphQL.MaxQueryLength
+	if maxLength == 0 {
+		maxLength = defaultGraphQLMaxQueryLength
+	}
+
+	doc, err := graphql.Parse(r.Query, maxLength, graphQLMaxDepth)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, &graphql.Response{Errors: []*graphql.Error{{
+			Message: err.Error(),
+		}}})
+		return
+	}
+
+	if c.Request.Method == http.MethodGet {
+		// mutations are not allowed on the safe method
+		for _, op := range doc.Operations {
+			if op.Type == graphql.OperationMutation {
+				c.AbortWithStatusJSON(http.StatusMethodNotAllowed, &graphql.Response{Errors: []*graphql.Error{{
+					Message: "mutation is not allowed with GET method",
+				}}})
+				return
+			}
+		}
+	}
+
+	resp := graphql.Execute(schema, doc, r.OperationName, r.Variables, gr)
+	if resp.Data == nil {
+		c.JSON(http.StatusBadRequest, resp)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+func userGraphQLSchema(c *gin.Context) {
+	schema, _, err := buildGraphQLContext(c)
+	if err != nil {
+		abortWithGraphQLContextError(c, err)
+		return
+	}
+
+	c.String(http.StatusOK, schema.SDL())
+}
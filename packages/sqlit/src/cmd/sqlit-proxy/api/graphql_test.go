@@ -0,0 +1,216 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+	gorp "gopkg.in/gorp.v2"
+
+	"sqlit/src/cmd/sqlit-proxy/graphql"
+	"sqlit/src/cmd/sqlit-proxy/resolver"
+)
+
+func newTestGraphQLResolver(uid string, adminMode bool) (schema *graphql.Schema, r *graphQLResolver) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	So(err, ShouldBeNil)
+	// the memory database lives as long as the connection
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec(`CREATE TABLE "group" ("id" INTEGER PRIMARY KEY, "select" TEXT, "order" INTEGER)`)
+	So(err, ShouldBeNil)
+	_, err = db.Exec(`INSERT INTO "group" ("id", "select", "order") VALUES (1, 'a', 10), (2, 'b', 20)`)
+	So(err, ShouldBeNil)
+
+	rules, err := resolver.CompileRules(map[string]interface{}{
+		"groups": map[string]interface{}{
+			"admins": []string{"1"},
+		},
+		"rules": map[string]interface{}{
+			"group": map[string]interface{}{
+				"fields": map[string]interface{}{
+					"select": map[string]interface{}{"read": []string{"g:admins"}, "write": []string{"u:2"}},
+					"order":  map[string]interface{}{"write": []string{}},
+				},
+			},
+		},
+	})
+	So(err, ShouldBeNil)
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	r = &graphQLResolver{
+		c:         c,
+		db:        &gorp.DbMap{Db: db, Dialect: gorp.SqliteDialect{}},
+		uid:       uid,
+		userState: resolver.UserStateLoggedIn,
+		vars:      map[string]interface{}{},
+		rules:     rules,
+		adminMode: adminMode,
+		fields: map[string]resolver.FieldMap{
+			"group": {"id": true, "select": true, "order": true},
+		},
+	}
+	schema = graphql.NewSchema([]*graphql.Table{{
+		Name:    "group",
+		Columns: []string{"id", "select", "order"},
+		Types:   []string{"INTEGER", "TEXT", "INTEGER"},
+	}})
+
+	Reset(func() {
+		_ = db.Close()
+	})
+
+	return
+}
+
+func executeGraphQL(schema *graphql.Schema, r *graphQLResolver, src string, vars map[string]interface{}) (
+	resp *graphql.Response, data string) {
+	doc, err := graphql.Parse(src, defaultGraphQLMaxQueryLength, graphQLMaxDepth)
+	So(err, ShouldBeNil)
+	resp = graphql.Execute(schema, doc, "", vars, r)
+	So(resp.Data, ShouldNotBeNil)
+	out, err := json.Marshal(resp.Data)
+	So(err, ShouldBeNil)
+	return resp, string(out)
+}
+
+func TestGraphQLIdentifierQuoting(t *testing.T) {
+	Convey("Given the tables and columns named by the sql keywords", t, func() {
+		var (
+			fields = resolver.FieldMap{"id": true, "select": true, "order": true}
+			one    = int64(1)
+		)
+
+		Convey("The generated sql should quote the identifiers", func() {
+			for _, c := range []struct {
+				name string
+				stmt func() (string, []interface{}, resolver.FieldMap, error)
+				sql  string
+				args []interface{}
+			}{
+				{"find", func() (string, []interface{}, resolver.FieldMap, error) {
+					return resolver.Find("group", fields, map[string]interface{}{"order": 10.0},
+						map[string]interface{}{"select": true}, map[string]interface{}{"order": -1.0}, nil, &one)
+				}, `SELECT "select" FROM "group"  WHERE ("order" = ?) ORDER BY "order" DESC LIMIT 1`,
+					[]interface{}{10.0}},
+				{"count", func() (string, []interface{}, resolver.FieldMap, error) {
+					return resolver.Count("group", fields, map[string]interface{}{"select": "a"})
+				}, `SELECT COUNT(1) AS "cnt" FROM "group"  WHERE ("select" = ?)`, []interface{}{"a"}},
+				{"insert", func() (string, []interface{}, resolver.FieldMap, error) {
+					return resolver.Insert("group", fields, map[string]interface{}{"select": "c"})
+				}, `INSERT INTO "group" ("select") VALUES(?)`, []interface{}{"c"}},
+				{"update", func() (string, []interface{}, resolver.FieldMap, error) {
+					return resolver.Update("group", fields, map[string]interface{}{"id": 1.0},
+						map[string]interface{}{"$inc": map[string]interface{}{"order": 1.0}}, true)
+				}, `UPDATE "group" SET "order" = "order" + ? WHERE ("id" = ?) LIMIT 1`, []interface{}{1.0, 1.0}},
+				{"remove", func() (string, []interface{}, resolver.FieldMap, error) {
+					return resolver.Remove("group", fields, map[string]interface{}{"select": "a"}, false)
+				}, `DELETE FROM "group"  WHERE ("select" = ?)`, []interface{}{"a"}},
+			} {
+				Convey("The "+c.name+" statement should be generated", func() {
+					stmt, args, _, err := c.stmt()
+					So(err, ShouldBeNil)
+					So(stmt, ShouldEqual, c.sql)
+					So(args, ShouldResemble, c.args)
+				})
+			}
+		})
+		Convey("The unknown columns should be refused instead of quoted", func() {
+			_, _, _, err := resolver.Find("group", fields, nil,
+				map[string]interface{}{`id" FROM "secrets" --`: true}, nil, nil, nil)
+			So(err, ShouldNotBeNil)
+			_, _, _, err = resolver.Count("group", fields, map[string]interface{}{`"id"`: 1.0})
+			So(err, ShouldNotBeNil)
+			_, _, _, err = resolver.Insert("group", fields, map[string]interface{}{`x"`: 1.0})
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The graphql requests should be served on the keyword identifiers", func() {
+			schema, r := newTestGraphQLResolver("", true)
+
+			resp, data := executeGraphQL(schema, r, `mutation {
+				insert_group(data: {id: 3, select: "c", order: 30}) { affected_rows last_insert_id }
+				update_group(filter: {select: "a"}, update: {order: 11}) { affected_rows }
+				remove_group(filter: {order: 20}) { affected_rows }
+			}`, nil)
+			So(resp.Errors, ShouldBeNil)
+			So(data, ShouldEqual, `{`+
+				`"insert_group":{"affected_rows":1,"last_insert_id":3},`+
+				`"update_group":{"affected_rows":1},`+
+				`"remove_group":{"affected_rows":1}}`)
+
+			// the operators are not valid graphql names, they are passed by the variables
+			resp, data = executeGraphQL(schema, r, `query ($f: JSON) {
+				group(order: {order: -1}, limit: 5) { id select order }
+				group_count(filter: $f)
+			}`, map[string]interface{}{
+				"f": map[string]interface{}{"select": map[string]interface{}{"$in": []interface{}{"a", "b", "c"}}},
+			})
+			So(resp.Errors, ShouldBeNil)
+			So(data, ShouldEqual, `{`+
+				`"group":[{"id":3,"select":"c","order":30},{"id":1,"select":"a","order":11}],`+
+				`"group_count":2}`)
+		})
+	})
+}
+
+func TestGraphQLFieldPermissions(t *testing.T) {
+	Convey("Given the field rules of a table", t, func() {
+		for _, c := range []struct {
+			name  string
+			uid   string
+			admin bool
+			src   string
+			err   string
+		}{
+			{"read the denied field", "2", false,
+				`{ group { id select } }`, "permission denied to read field select"},
+			{"order by the denied field", "2", false,
+				`{ group(order: {select: 1}) { id } }`, "permission denied to read field select"},
+			{"filter by the denied field", "2", false,
+				`{ group_count(filter: {select: "a"}) }`, "permission denied to read field select"},
+			{"insert the denied field", "1", false,
+				`mutation { insert_group(data: {select: "c"}) { affected_rows } }`,
+				"permission denied to write field select"},
+			{"update the denied field", "1", false,
+				`mutation { update_group(update: {select: "c"}) { affected_rows } }`,
+				"permission denied to write field select"},
+			{"update the read only field", "2", false,
+				`mutation { update_group(update: {order: 1}) { affected_rows } }`,
+				"permission denied to write field order"},
+			{"filter the removed rows by the denied field", "2", false,
+				`mutation { remove_group(filter: {select: "a"}) { affected_rows } }`,
+				"permission denied to read field select"},
+			{"write the read only field by the admin", "", true,
+				`mutation { update_group(update: {order: 1}) { affected_rows } }`, ""},
+		} {
+			Convey("The request to "+c.name+" should be checked", func() {
+				schema, r := newTestGraphQLResolver(c.uid, c.admin)
+				resp, _ := executeGraphQL(schema, r, c.src, nil)
+				if c.err == "" {
+					So(resp.Errors, ShouldBeNil)
+					return
+				}
+				So(resp.Errors, ShouldHaveLength, 1)
+				So(resp.Errors[0].Message, ShouldEqual, c.err)
+
+				// the denied requests should not be executed
+				var rows []struct {
+					ID     int64  `db:"id"`
+					Select string `db:"select"`
+					Order  int64  `db:"order"`
+				}
+				_, err := r.db.Select(&rows, `SELECT "id", "select", "order" FROM "group" ORDER BY "id"`)
+				So(err, ShouldBeNil)
+				So(rows, ShouldHaveLength, 2)
+				So(rows[0].Select, ShouldEqual, "a")
+				So(rows[0].Order, ShouldEqual, 10)
+				So(rows[1].Select, ShouldEqual, "b")
+				So(rows[1].Order, ShouldEqual, 20)
+			})
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 // AddRoutes init the gin engine with all proxy api routes.
 func AddRoutes(e *gin.Engine) {
 	v3 := e.Group("/v3")
+	v3.GET("/openapi.yaml", getOpenAPISpec)
 
 	// admin login
 	v3Admin := v3.Group("/admin")
@@ -40,6 +41,7 @@ func AddRoutes(e *gin.Engine) {
 			v3AdminLogin.POST("/database", createDB)
 			v3AdminLogin.POST("/database/:db/topup", topUp)
 			v3AdminLogin.GET("/database/:db/pricing", databasePricing)
+			v3AdminLogin.GET("/database/:db/load", databaseLoad)
 			v3AdminLogin.GET("/database/:db", databaseBalance)
 
 			v3AdminLogin.GET("/task", listTasks)
@@ -104,6 +106,10 @@ func AddRoutes(e *gin.Engine) {
 		v3UserPermissive.POST("/data/:table/remove", userDataRemove)
 		v3UserPermissive.GET("/data/:table/count", userDataCount)
 		v3UserPermissive.POST("/data/:table/count", userDataCount)
+
+		v3UserPermissive.GET("/graphql", userGraphQL)
+		v3UserPermissive.POST("/graphql", userGraphQL)
+		v3UserPermissive.GET("/graphql/schema", userGraphQLSchema)
 	}
 
 	// alias
@@ -0,0 +1,17 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec describes the project user api, the typescript client follows it.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+func getOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", openAPISpec)
+}
@@ -321,6 +321,34 @@ func userDataCount(c *gin.Context) {
 
 func buildExecuteContext(c *gin.Context, tableName string) (projectDB *gorp.DbMap, uid string, userState string,
 	vars map[string]interface{}, r *resolver.Rules, fields resolver.FieldMap, adminMode bool, err error) {
+	projectDB, uid, userState, vars, r, adminMode, err = buildUserContext(c)
+	if err != nil {
+		return
+	}
+
+	// load table fields
+	_, ptc, err := model.GetProjectTableConfig(projectDB, tableName)
+	if err != nil {
+		err = errors.Wrapf(err, "get project table config failed")
+		return
+	}
+
+	if ptc.IsDeleted {
+		err = errors.New("table does not exists")
+		return
+	}
+
+	fields = resolver.FieldMap{}
+
+	for _, c := range ptc.Columns {
+		fields[c] = true
+	}
+
+	return
+}
+
+func buildUserContext(c *gin.Context) (projectDB *gorp.DbMap, uid string, userState string,
+	vars map[string]interface{}, r *resolver.Rules, adminMode bool, err error) {
 	project := getCurrentProject(c)
 	projectDB, err = getCurrentProjectDB(c)
 	if err != nil {
@@ -396,24 +424,6 @@ func buildExecuteContext(c *gin.Context, tableName string) (projectDB *gorp.DbMa
 		return
 	}
 
-	// load table fields
-	_, ptc, err := model.GetProjectTableConfig(projectDB, tableName)
-	if err != nil {
-		err = errors.Wrapf(err, "get project table config failed")
-		return
-	}
-
-	if ptc.IsDeleted {
-		err = errors.New("table does not exists")
-		return
-	}
-
-	fields = resolver.FieldMap{}
-
-	for _, c := range ptc.Columns {
-		fields[c] = true
-	}
-
 	return
 }
 
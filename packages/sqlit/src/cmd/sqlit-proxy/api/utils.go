@@ -64,7 +64,9 @@ func getCurrentProject(c *gin.Context) *model.Project {
 	return c.MustGet("project").(*model.Project)
 }
 
-func getDatabaseProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
+var profileCache = route.NewProfileCache(route.DefaultProfileTTL, loadDatabaseProfile)
+
+func loadDatabaseProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
 	req := &types.QuerySQLChainProfileReq{
 		DBID: dbID,
 	}
@@ -81,6 +83,10 @@ func getDatabaseProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile,
 	return
 }
 
+func getDatabaseProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
+	return profileCache.Get(dbID)
+}
+
 func getDatabaseLeaderNodeID(dbID proto.DatabaseID) (nodeID proto.NodeID, err error) {
 	profile, err := getDatabaseProfile(dbID)
 	if err != nil {
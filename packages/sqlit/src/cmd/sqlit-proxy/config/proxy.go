@@ -44,9 +44,37 @@ type UserAuthConfig struct {
 	Extra map[string]gin.H `yaml:"Extra"`
 }
 
+// GraphQLConfig defines the graphql api feature config for proxy service.
+type GraphQLConfig struct {
+	// serve the graphql api generated from the project tables or not.
+	Enabled bool `yaml:"Enabled"`
+	// max length of a graphql request document, 0 means the default length.
+	MaxQueryLength int `yaml:"MaxQueryLength" validate:"gte=0"`
+}
+
+// MinTaskLease defines the min lease of the tasks run by a proxy replica, the lease is renewed
+// every third of it and expires in seconds.
+const MinTaskLease = 3 * time.Second
+
+// ReplicaConfig defines the options of running multiple proxy replicas against the shared storage.
+type ReplicaConfig struct {
+	// unique id of the replica, a random one is used if empty.
+	InstanceID string `yaml:"InstanceID"`
+	// lease of the tasks run by the replica, renewed until the task is finished, the running tasks
+	// of a replica are aborted by the others after the leases expire, 0 means the default lease,
+	// otherwise it should not be shorter than MinTaskLease.
+	TaskLease time.Duration `yaml:"TaskLease" validate:"gte=0"`
+	// max age of the cached project rules, so that the rules updated on the other replicas are
+	// reloaded, 0 means the default age.
+	RulesCacheTTL time.Duration `yaml:"RulesCacheTTL" validate:"gte=0"`
+}
+
 // Config defines the configurable options for proxy service.
 type Config struct {
 	ListenAddr string `yaml:"ListenAddr" validate:"required"`
+	// require the PROXY protocol v2 header on the connections, for the proxy behind an L4 load
+	// balancer.
+	ProxyProtocol bool `yaml:"ProxyProtocol"`
 	// platform wildcard hosts for proxy to accept and dispatch requests.
 	// project specific hosts is defined in project admin settings.
 	Hosts []string `yaml:"Hosts" validate:"dive,required"`
@@ -59,6 +87,13 @@ type Config struct {
 
 	// user auth config for proxy service.
 	UserAuth *UserAuthConfig `yaml:"UserAuth" validate:"required"`
+
+	// optional graphql api config for proxy service.
+	GraphQL *GraphQLConfig `yaml:"GraphQL"`
+
+	// optional replica config for running multiple proxy instances with the same storage, the
+	// project rules are cached forever if it's not set.
+	Replica *ReplicaConfig `yaml:"Replica"`
 }
 
 type confWrapper struct {
@@ -91,6 +126,21 @@ func (c *Config) Validate() (err error) {
 			return
 		}
 	}
+	if c.GraphQL != nil {
+		if err = validate.Struct(*c.GraphQL); err != nil {
+			return
+		}
+	}
+	if c.Replica != nil {
+		if err = validate.Struct(*c.Replica); err != nil {
+			return
+		}
+
+		if c.Replica.TaskLease != 0 && c.Replica.TaskLease < MinTaskLease {
+			err = errors.Wrapf(ErrInvalidProxyConfig, "task lease shorter than %s", MinTaskLease)
+			return
+		}
+	}
 
 	return
 }
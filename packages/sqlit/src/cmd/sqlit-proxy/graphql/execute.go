@@ -0,0 +1,445 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// MutationResult defines the result of a mutation root field.
+type MutationResult struct {
+	AffectedRows int64
+	LastInsertID int64
+}
+
+// Resolver serves the root fields of a schema with parameterized sql, the row rules and field
+// permissions of the requester are enforced by the resolver.
+type Resolver interface {
+	// Find returns the rows of table, fields are the selected columns.
+	Find(table string, fields []string, filter map[string]interface{}, order map[string]interface{},
+		skip *int64, limit *int64) (rows []map[string]interface{}, err error)
+	// Count returns the count of rows of table matching filter.
+	Count(table string, filter map[string]interface{}) (count int64, err error)
+	// Insert inserts a row of data into table.
+	Insert(table string, data map[string]interface{}) (result *MutationResult, err error)
+	// Update updates the rows of table matching filter, or the first one only.
+	Update(table string, filter map[string]interface{}, update map[string]interface{}, one bool) (
+		result *MutationResult, err error)
+	// Remove removes the rows of table matching filter, or the first one only.
+	Remove(table string, filter map[string]interface{}, one bool) (result *MutationResult, err error)
+}
+
+// Error defines an error entry of the response.
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Response defines the response of a graphql request, data is nil if the request is refused
+// before the execution.
+type Response struct {
+	Data   *Object  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+// Object defines a response object which keeps the order of the selected fields.
+type Object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// Set sets the field value of the object.
+func (o *Object) Set(key string, value interface{}) {
+	if o.values == nil {
+		o.values = make(map[string]interface{})
+	}
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// Get returns the field value of the object.
+func (o *Object) Get(key string) (value interface{}, ok bool) {
+	value, ok = o.values[key]
+	return
+}
+
+// MarshalJSON implements json.Marshaler interface.
+func (o *Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		kb, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		vb, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(kb)
+		buf.WriteByte(':')
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func errorResponse(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+// Execute executes the operation of doc named operationName, which may be empty if doc has only
+// one operation. The root fields are resolved in order by r, a failed root field is set to null
+// in the response data with its error.
+func Execute(s *Schema, doc *Document, operationName string, variables map[string]interface{},
+	r Resolver) *Response {
+	var op *Operation
+
+	for _, o := range doc.Operations {
+		if operationName == "" && len(doc.Operations) > 1 {
+			return errorResponse(errors.New("operation name is required for multiple operations"))
+		}
+		if operationName == "" || o.Name == operationName {
+			op = o
+			break
+		}
+	}
+
+	if op == nil {
+		return errorResponse(errors.Errorf("unknown operation %s", operationName))
+	}
+
+	vars, err := coerceVariables(op, variables)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	roots := s.queries
+	if op.Type == OperationMutation {
+		roots = s.mutations
+	}
+
+	if err = validateSelections(roots, op.Selections); err != nil {
+		return errorResponse(err)
+	}
+
+	var (
+		resp = &Response{Data: &Object{}}
+		e    = &executor{vars: vars, resolver: r}
+	)
+
+	for _, f := range op.Selections {
+		var value interface{}
+
+		if f.Name == typenameField {
+			value = "Query"
+			if op.Type == OperationMutation {
+				value = "Mutation"
+			}
+		} else if value, err = e.executeRoot(roots[f.Name], f); err != nil {
+			value = nil
+			resp.Errors = append(resp.Errors, &Error{
+				Message: err.Error(),
+				Path:    []interface{}{f.ResponseKey()},
+			})
+		}
+
+		resp.Data.Set(f.ResponseKey(), value)
+	}
+
+	return resp
+}
+
+func coerceVariables(op *Operation, variables map[string]interface{}) (
+	vars map[string]interface{}, err error) {
+	vars = make(map[string]interface{}, len(op.Variables))
+
+	for _, def := range op.Variables {
+		v, ok := variables[def.Name]
+		if !ok {
+			v = def.Default
+		}
+		if v == nil && def.Required {
+			err = errors.Errorf("variable $%s of type %s! is required", def.Name, def.Type)
+			return
+		}
+		vars[def.Name] = v
+	}
+
+	return
+}
+
+var rootArguments = map[rootKind]map[string]bool{
+	rootFind:   {"filter": false, "order": false, "skip": false, "limit": false},
+	rootCount:  {"filter": false},
+	rootInsert: {"data": true},
+	rootUpdate: {"filter": false, "update": true, "one": false},
+	rootRemove: {"filter": false, "one": false},
+}
+
+func validateSelections(roots map[string]*rootField, fields []*Field) (err error) {
+	for _, f := range fields {
+		if f.Name == typenameField {
+			if len(f.Arguments) > 0 || len(f.Selections) > 0 {
+				return errors.Errorf("field %s takes no arguments or selections", f.Name)
+			}
+			continue
+		}
+
+		rf, ok := roots[f.Name]
+		if !ok {
+			if f.Name == "__schema" || f.Name == "__type" {
+				return errors.New("introspection is not supported, fetch the schema definition instead")
+			}
+			return errors.Errorf("cannot query field %s", f.Name)
+		}
+
+		var (
+			allowed = rootArguments[rf.kind]
+			given   = make(map[string]bool, len(f.Arguments))
+		)
+		for _, arg := range f.Arguments {
+			if _, ok := allowed[arg.Name]; !ok {
+				return errors.Errorf("unknown argument %s of field %s", arg.Name, f.Name)
+			}
+			if given[arg.Name] {
+				return errors.Errorf("duplicate argument %s of field %s", arg.Name, f.Name)
+			}
+			given[arg.Name] = true
+		}
+		for name, required := range allowed {
+			if required && !given[name] {
+				return errors.Errorf("argument %s of field %s is required", name, f.Name)
+			}
+		}
+
+		switch rf.kind {
+		case rootCount:
+			if len(f.Selections) > 0 {
+				return errors.Errorf("field %s of type Int! takes no selections", f.Name)
+			}
+		case rootFind:
+			if err = validateObject(rf.table.name, rf.table.types, f); err != nil {
+				return
+			}
+		default:
+			if err = validateObject(mutationResult, map[string]string{
+				"affected_rows":  "Int",
+				"last_insert_id": "Int",
+			}, f); err != nil {
+				return
+			}
+		}
+	}
+
+	return
+}
+
+func validateObject(typeName string, fields map[string]string, f *Field) (err error) {
+	if len(f.Selections) == 0 {
+		return errors.Errorf("field %s of type %s requires selections", f.Name, typeName)
+	}
+
+	for _, sf := range f.Selections {
+		if _, ok := fields[sf.Name]; !ok && sf.Name != typenameField {
+			return errors.Errorf("cannot query field %s on type %s", sf.Name, typeName)
+		}
+		if len(sf.Arguments) > 0 || len(sf.Selections) > 0 {
+			return errors.Errorf("field %s on type %s takes no arguments or selections", sf.Name, typeName)
+		}
+	}
+
+	return
+}
+
+type executor struct {
+	vars     map[string]interface{}
+	resolver Resolver
+}
+
+// resolveValue replaces the variables in the argument value v.
+func (e *executor) resolveValue(v interface{}) interface{} {
+	switch tv := v.(type) {
+	case Variable:
+		return e.vars[string(tv)]
+	case Enum:
+		return string(tv)
+	case []interface{}:
+		list := make([]interface{}, len(tv))
+		for i, item := range tv {
+			list[i] = e.resolveValue(item)
+		}
+		return list
+	case map[string]interface{}:
+		obj := make(map[string]interface{}, len(tv))
+		for k, item := range tv {
+			obj[k] = e.resolveValue(item)
+		}
+		return obj
+	default:
+		return v
+	}
+}
+
+func (e *executor) arguments(f *Field) (args map[string]interface{}) {
+	args = make(map[string]interface{}, len(f.Arguments))
+	for _, arg := range f.Arguments {
+		args[arg.Name] = e.resolveValue(arg.Value)
+	}
+	return
+}
+
+func objectArgument(args map[string]interface{}, name string) (obj map[string]interface{}, err error) {
+	switch v := args[name].(type) {
+	case nil:
+	case map[string]interface{}:
+		obj = v
+	default:
+		err = errors.Errorf("argument %s must be an object", name)
+	}
+	return
+}
+
+func intArgument(args map[string]interface{}, name string) (i *int64, err error) {
+	var n int64
+
+	switch v := args[name].(type) {
+	case nil:
+		return
+	case int64:
+		n = v
+	case float64:
+		if v != math.Trunc(v) {
+			return nil, errors.Errorf("argument %s must be an integer", name)
+		}
+		n = int64(v)
+	case json.Number:
+		if n, err = v.Int64(); err != nil {
+			return nil, errors.Errorf("argument %s must be an integer", name)
+		}
+	default:
+		return nil, errors.Errorf("argument %s must be an integer", name)
+	}
+
+	if n < 0 {
+		return nil, errors.Errorf("argument %s must not be negative", name)
+	}
+
+	return &n, nil
+}
+
+func boolArgument(args map[string]interface{}, name string) (b bool, err error) {
+	switch v := args[name].(type) {
+	case nil:
+	case bool:
+		b = v
+	default:
+		err = errors.Errorf("argument %s must be a boolean", name)
+	}
+	return
+}
+
+func (e *executor) executeRoot(rf *rootField, f *Field) (value interface{}, err error) {
+	var (
+		args   = e.arguments(f)
+		table  = rf.table.name
+		filter map[string]interface{}
+		result *MutationResult
+	)
+
+	if filter, err = objectArgument(args, "filter"); err != nil {
+		return
+	}
+
+	switch rf.kind {
+	case rootFind:
+		var (
+			order       map[string]interface{}
+			skip, limit *int64
+			rows        []map[string]interface{}
+			fields      []string
+			selected    = map[string]bool{}
+		)
+		if order, err = objectArgument(args, "order"); err != nil {
+			return
+		}
+		if skip, err = intArgument(args, "skip"); err != nil {
+			return
+		}
+		if limit, err = intArgument(args, "limit"); err != nil {
+			return
+		}
+		for _, sf := range f.Selections {
+			if sf.Name != typenameField && !selected[sf.Name] {
+				selected[sf.Name] = true
+				fields = append(fields, sf.Name)
+			}
+		}
+		if rows, err = e.resolver.Find(table, fields, filter, order, skip, limit); err != nil {
+			return
+		}
+		list := make([]interface{}, 0, len(rows))
+		for _, row := range rows {
+			obj := &Object{}
+			for _, sf := range f.Selections {
+				if sf.Name == typenameField {
+					obj.Set(sf.ResponseKey(), table)
+				} else {
+					obj.Set(sf.ResponseKey(), row[sf.Name])
+				}
+			}
+			list = append(list, obj)
+		}
+		return list, nil
+	case rootCount:
+		return e.resolver.Count(table, filter)
+	case rootInsert:
+		var data map[string]interface{}
+		if data, err = objectArgument(args, "data"); err != nil {
+			return
+		}
+		if result, err = e.resolver.Insert(table, data); err != nil {
+			return
+		}
+	case rootUpdate, rootRemove:
+		var one bool
+		if one, err = boolArgument(args, "one"); err != nil {
+			return
+		}
+		if rf.kind == rootRemove {
+			result, err = e.resolver.Remove(table, filter, one)
+		} else {
+			var update map[string]interface{}
+			if update, err = objectArgument(args, "update"); err != nil {
+				return
+			}
+			result, err = e.resolver.Update(table, filter, update, one)
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	obj := &Object{}
+	for _, sf := range f.Selections {
+		switch sf.Name {
+		case "affected_rows":
+			obj.Set(sf.ResponseKey(), result.AffectedRows)
+		case "last_insert_id":
+			obj.Set(sf.ResponseKey(), result.LastInsertID)
+		case typenameField:
+			obj.Set(sf.ResponseKey(), mutationResult)
+		}
+	}
+
+	return obj, nil
+}
@@ -0,0 +1,237 @@
+package graphql
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type call struct {
+	method string
+	table  string
+	fields []string
+	filter map[string]interface{}
+	order  map[string]interface{}
+	data   map[string]interface{}
+	skip   *int64
+	limit  *int64
+	one    bool
+}
+
+type fakeResolver struct {
+	calls []*call
+	rows  []map[string]interface{}
+	err   error
+}
+
+func (r *fakeResolver) Find(table string, fields []string, filter map[string]interface{},
+	order map[string]interface{}, skip *int64, limit *int64) (rows []map[string]interface{}, err error) {
+	r.calls = append(r.calls, &call{method: "find", table: table, fields: fields, filter: filter,
+		order: order, skip: skip, limit: limit})
+	return r.rows, r.err
+}
+
+func (r *fakeResolver) Count(table string, filter map[string]interface{}) (count int64, err error) {
+	r.calls = append(r.calls, &call{method: "count", table: table, filter: filter})
+	return int64(len(r.rows)), r.err
+}
+
+func (r *fakeResolver) Insert(table string, data map[string]interface{}) (result *MutationResult, err error) {
+	r.calls = append(r.calls, &call{method: "insert", table: table, data: data})
+	return &MutationResult{AffectedRows: 1, LastInsertID: 7}, r.err
+}
+
+func (r *fakeResolver) Update(table string, filter map[string]interface{}, update map[string]interface{},
+	one bool) (result *MutationResult, err error) {
+	r.calls = append(r.calls, &call{method: "update", table: table, filter: filter, data: update, one: one})
+	return &MutationResult{AffectedRows: 2}, r.err
+}
+
+func (r *fakeResolver) Remove(table string, filter map[string]interface{}, one bool) (
+	result *MutationResult, err error) {
+	r.calls = append(r.calls, &call{method: "remove", table: table, filter: filter, one: one})
+	return &MutationResult{AffectedRows: 3}, r.err
+}
+
+func testSchema() *Schema {
+	return NewSchema([]*Table{
+		{Name: "users", Columns: []string{"id", "name", "score", "order"},
+			Types: []string{"INTEGER", "VARCHAR(64)", "REAL", "TEXT"}},
+		{Name: "group", Columns: []string{"id", "select"}, Types: []string{"INT"}},
+	})
+}
+
+func mustParse(src string) *Document {
+	doc, err := Parse(src, 0, 0)
+	So(err, ShouldBeNil)
+	return doc
+}
+
+func TestNewSchema(t *testing.T) {
+	Convey("Given the project tables", t, func() {
+		s := NewSchema([]*Table{
+			{Name: "users", Columns: []string{"id", "name", "bad-col", "__hidden"},
+				Types: []string{"INTEGER", "NVARCHAR(10)", "TEXT", "TEXT"}},
+			{Name: `bad"name`, Columns: []string{"id"}},
+			{Name: "__meta", Columns: []string{"id"}},
+			{Name: "Query", Columns: []string{"id"}},
+			{Name: "no_columns", Columns: []string{"bad col"}},
+			{Name: "users_count", Columns: []string{"id"}},
+			{Name: "scores", Columns: []string{"a", "b", "c", "d", "e", "f"},
+				Types: []string{"BIGINT", "CLOB", "BOOLEAN", "DOUBLE PRECISION", "DECIMAL(10,2)", "BLOB"}},
+		})
+
+		Convey("The tables and columns with invalid or reserved names should be left out", func() {
+			So(s.types, ShouldHaveLength, 2)
+			So(s.types["users"].columns, ShouldResemble, []string{"id", "name"})
+			for _, name := range []string{`bad"name`, "__meta", "Query", "no_columns", "users_count"} {
+				So(s.types, ShouldNotContainKey, name)
+				So(s.mutations, ShouldNotContainKey, insertPrefix+name)
+			}
+			So(s.queries["users_count"].kind, ShouldEqual, rootCount)
+			So(s.queries["users_count"].table.name, ShouldEqual, "users")
+		})
+		Convey("The columns should be typed by the sqlite type affinity", func() {
+			So(s.types["users"].types, ShouldResemble, map[string]string{"id": "Int", "name": "String"})
+			So(s.types["scores"].types, ShouldResemble, map[string]string{
+				"a": "Int", "b": "String", "c": "Boolean", "d": "Float", "e": "Float", "f": "String",
+			})
+		})
+		Convey("The schema definition should list the root fields and types", func() {
+			sdl := s.SDL()
+			So(sdl, ShouldContainSubstring,
+				"  users(filter: JSON, order: JSON, skip: Int, limit: Int): [users!]!\n")
+			So(sdl, ShouldContainSubstring, "  users_count(filter: JSON): Int!\n")
+			So(sdl, ShouldContainSubstring, "  insert_users(data: JSON!): MutationResult!\n")
+			So(sdl, ShouldContainSubstring, "  remove_scores(filter: JSON, one: Boolean): MutationResult!\n")
+			So(sdl, ShouldContainSubstring, "type users {\n  id: Int\n  name: String\n}\n")
+			So(sdl, ShouldNotContainSubstring, "bad")
+		})
+	})
+}
+
+func TestExecute(t *testing.T) {
+	Convey("Given a schema and a resolver", t, func() {
+		var (
+			s = testSchema()
+			r = &fakeResolver{rows: []map[string]interface{}{
+				{"id": int64(1), "name": "a", "order": "x"},
+			}}
+		)
+
+		Convey("The invalid requests should be refused before the execution", func() {
+			for _, c := range []struct {
+				src       string
+				operation string
+				variables map[string]interface{}
+				err       string
+			}{
+				{`{ accounts { id } }`, "", nil, "cannot query field accounts"},
+				{`{ __schema { types { name } } }`, "", nil, "introspection is not supported"},
+				{`{ insert_users(data: {}) { affected_rows } }`, "", nil, "cannot query field insert_users"},
+				{`mutation { users { id } }`, "", nil, "cannot query field users"},
+				{`{ users(where: {}) { id } }`, "", nil, "unknown argument where"},
+				{`{ users(limit: 1, limit: 2) { id } }`, "", nil, "duplicate argument limit"},
+				{`mutation { update_users(filter: {}) { affected_rows } }`, "", nil,
+					"argument update of field update_users is required"},
+				{`{ users }`, "", nil, "requires selections"},
+				{`{ users { password } }`, "", nil, "cannot query field password on type users"},
+				{`{ users { id { value } } }`, "", nil, "takes no arguments or selections"},
+				{`{ users_count { id } }`, "", nil, "takes no selections"},
+				{`{ __typename(a: 1) }`, "", nil, "takes no arguments"},
+				{`mutation { remove_users { rows } }`, "", nil, "cannot query field rows on type MutationResult"},
+				{`query ($f: JSON!) { users(filter: $f) { id } }`, "", nil, "variable $f of type JSON! is required"},
+				{`query A { users { id } } query B { users { id } }`, "", nil, "operation name is required"},
+				{`query A { users { id } }`, "B", nil, "unknown operation B"},
+			} {
+				resp := Execute(s, mustParse(c.src), c.operation, c.variables, r)
+				So(resp.Data, ShouldBeNil)
+				So(resp.Errors, ShouldHaveLength, 1)
+				So(resp.Errors[0].Message, ShouldContainSubstring, c.err)
+			}
+			So(r.calls, ShouldBeEmpty)
+		})
+		Convey("The queries should be resolved with the selected fields and arguments", func() {
+			resp := Execute(s, mustParse(`
+				query Find($f: JSON, $limit: Int = 5) {
+					list: users(filter: $f, order: {id: -1}, skip: 1, limit: $limit) { name id name __typename }
+					users_count
+					__typename
+				}
+			`), "", map[string]interface{}{"f": map[string]interface{}{"id": 1.0}}, r)
+			So(resp.Errors, ShouldBeNil)
+			So(r.calls, ShouldHaveLength, 2)
+			var (
+				skip, limit = int64(1), int64(5)
+				filter      = map[string]interface{}{"id": 1.0}
+			)
+			So(r.calls[0], ShouldResemble, &call{
+				method: "find", table: "users", fields: []string{"name", "id"}, filter: filter,
+				order: map[string]interface{}{"id": int64(-1)}, skip: &skip, limit: &limit,
+			})
+			So(r.calls[1], ShouldResemble, &call{method: "count", table: "users"})
+
+			data, err := json.Marshal(resp)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual,
+				`{"data":{"list":[{"name":"a","id":1,"__typename":"users"}],"users_count":1,"__typename":"Query"}}`)
+		})
+		Convey("The mutations should be resolved in order", func() {
+			var update = map[string]interface{}{"$set": map[string]interface{}{"name": "c"}}
+			resp := Execute(s, mustParse(`
+				mutation ($u: JSON!) {
+					insert_users(data: {name: "b", kind: ADMIN}) { affected_rows last_insert_id }
+					update_users(filter: {id: 1}, update: $u, one: true) { n: affected_rows }
+					remove_group(filter: {id: 2}) { affected_rows __typename }
+				}
+			`), "", map[string]interface{}{"u": update}, r)
+			So(resp.Errors, ShouldBeNil)
+			So(r.calls, ShouldResemble, []*call{
+				{method: "insert", table: "users", data: map[string]interface{}{"name": "b", "kind": "ADMIN"}},
+				{method: "update", table: "users", filter: map[string]interface{}{"id": int64(1)},
+					data: update, one: true},
+				{method: "remove", table: "group", filter: map[string]interface{}{"id": int64(2)}},
+			})
+
+			data, err := json.Marshal(resp)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `{"data":{`+
+				`"insert_users":{"affected_rows":1,"last_insert_id":7},`+
+				`"update_users":{"n":2},`+
+				`"remove_group":{"affected_rows":3,"__typename":"MutationResult"}}}`)
+		})
+		Convey("The invalid argument values should fail the root field only", func() {
+			for _, c := range []struct {
+				src string
+				err string
+			}{
+				{`{ users(filter: 1) { id } }`, "argument filter must be an object"},
+				{`{ users(limit: -1) { id } }`, "argument limit must not be negative"},
+				{`{ users(skip: 1.5) { id } }`, "argument skip must be an integer"},
+				{`{ users(limit: "1") { id } }`, "argument limit must be an integer"},
+				{`mutation { remove_users(one: 1) { affected_rows } }`, "argument one must be a boolean"},
+				{`mutation { insert_users(data: [1]) { affected_rows } }`, "argument data must be an object"},
+			} {
+				resp := Execute(s, mustParse(c.src), "", nil, r)
+				So(resp.Data, ShouldNotBeNil)
+				So(resp.Errors, ShouldHaveLength, 1)
+				So(resp.Errors[0].Message, ShouldEqual, c.err)
+			}
+			So(r.calls, ShouldBeEmpty)
+		})
+		Convey("The resolver errors should be reported with the field path", func() {
+			r.err = errors.New("permission denied to read field name")
+			resp := Execute(s, mustParse(`{ a: users { name } b: users_count }`), "", nil, r)
+			So(resp.Data, ShouldNotBeNil)
+			So(resp.Errors, ShouldResemble, []*Error{
+				{Message: r.err.Error(), Path: []interface{}{"a"}},
+				{Message: r.err.Error(), Path: []interface{}{"b"}},
+			})
+			data, err := json.Marshal(resp.Data)
+			So(err, ShouldBeNil)
+			So(string(data), ShouldEqual, `{"a":null,"b":null}`)
+		})
+	})
+}
@@ -0,0 +1,592 @@
+package graphql
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// OperationType defines the graphql operation type enum.
+type OperationType string
+
+const (
+	// OperationQuery defines the read-only query operation.
+	OperationQuery OperationType = "query"
+	// OperationMutation defines the write mutation operation.
+	OperationMutation OperationType = "mutation"
+)
+
+// Document defines a parsed graphql request document.
+type Document struct {
+	Operations []*Operation
+}
+
+// Operation defines a query or mutation operation of a document.
+type Operation struct {
+	Type       OperationType
+	Name       string
+	Variables  []*VariableDefinition
+	Selections []*Field
+}
+
+// VariableDefinition defines a variable declared by an operation.
+type VariableDefinition struct {
+	Name     string
+	Type     string
+	Default  interface{}
+	Required bool
+}
+
+// Field defines a selected field with its arguments and sub selections.
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  []*Argument
+	Selections []*Field
+}
+
+// ResponseKey returns the key of the field in the response object.
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// Argument defines a named argument of a field.
+type Argument struct {
+	Name  string
+	Value interface{}
+}
+
+// Variable defines a variable reference in argument values.
+type Variable string
+
+// Enum defines an enum value in argument values.
+type Enum string
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+type parser struct {
+	src      string
+	pos      int
+	tok      token
+	maxDepth int
+}
+
+// Parse parses the graphql request document src, the documents longer than maxLength bytes or
+// the selections nested deeper than maxDepth are refused, 0 means no limit. Fragments and
+// directives are not supported.
+func Parse(src string, maxLength int, maxDepth int) (doc *Document, err error) {
+	if maxLength > 0 && len(src) > maxLength {
+		return nil, errors.New("query document is too long")
+	}
+
+	p := &parser{src: src, maxDepth: maxDepth}
+	if err = p.next(); err != nil {
+		return
+	}
+
+	doc = &Document{}
+
+	for p.tok.kind != tokenEOF {
+		var op *Operation
+		if op, err = p.parseOperation(); err != nil {
+			return nil, err
+		}
+		doc.Operations = append(doc.Operations, op)
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, errors.New("empty graphql document")
+	}
+
+	return
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("syntax error at %d: "+format, append([]interface{}{p.tok.pos}, args...)...)
+}
+
+func (p *parser) next() (err error) {
+	// skip ignored tokens
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+		} else if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+		} else if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+			p.pos += len("\ufeff")
+		} else {
+			break
+		}
+	}
+
+	p.tok = token{pos: p.pos}
+
+	if p.pos >= len(p.src) {
+		p.tok.kind = tokenEOF
+		return
+	}
+
+	var c = p.src[p.pos]
+
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.tok.kind, p.tok.value = tokenPunct, "..."
+		p.pos += 3
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.tok.kind, p.tok.value = tokenPunct, string(c)
+		p.pos++
+	case c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z'):
+		start := p.pos
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+		p.tok.kind, p.tok.value = tokenName, p.src[start:p.pos]
+	case c == '-' || (c >= '0' && c <= '9'):
+		err = p.lexNumber()
+	case c == '"':
+		err = p.lexString()
+	default:
+		err = p.errorf("unexpected character %q", c)
+	}
+
+	return
+}
+
+func isNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func (p *parser) lexNumber() (err error) {
+	start := p.pos
+	digits := func() int {
+		n := 0
+		for p.pos < len(p.src) && p.src[p.pos] >= '0' && p.src[p.pos] <= '9' {
+			p.pos++
+			n++
+		}
+		return n
+	}
+
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	if digits() == 0 {
+		return p.errorf("invalid number")
+	}
+
+	p.tok.kind = tokenInt
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		p.pos++
+		if digits() == 0 {
+			return p.errorf("invalid number")
+		}
+		p.tok.kind = tokenFloat
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		if digits() == 0 {
+			return p.errorf("invalid number")
+		}
+		p.tok.kind = tokenFloat
+	}
+	if p.pos < len(p.src) && (isNameChar(p.src[p.pos]) || p.src[p.pos] == '.') {
+		return p.errorf("invalid number")
+	}
+
+	p.tok.value = p.src[start:p.pos]
+	return
+}
+
+func (p *parser) lexString() (err error) {
+	p.tok.kind = tokenString
+
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		// block string, kept as is except the escaped triple quotes
+		for i := p.pos + 3; ; {
+			j := strings.Index(p.src[i:], `"""`)
+			if j < 0 {
+				return p.errorf("unterminated string")
+			}
+			if p.src[i+j-1] == '\\' {
+				i += j + 3
+				continue
+			}
+			p.tok.value = strings.Replace(p.src[p.pos+3:i+j], `\"""`, `"""`, -1)
+			p.pos = i + j + 3
+			return
+		}
+	}
+
+	var sb strings.Builder
+	p.pos++
+
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			return p.errorf("unterminated string")
+		}
+
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+			continue
+		}
+
+		if p.pos+1 >= len(p.src) {
+			return p.errorf("unterminated string")
+		}
+		switch e := p.src[p.pos+1]; e {
+		case '"', '\\', '/':
+			sb.WriteByte(e)
+		case 'b':
+			sb.WriteByte('\b')
+		case 'f':
+			sb.WriteByte('\f')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 't':
+			sb.WriteByte('\t')
+		case 'u':
+			if p.pos+6 > len(p.src) {
+				return p.errorf("invalid unicode escape")
+			}
+			r, perr := strconv.ParseUint(p.src[p.pos+2:p.pos+6], 16, 32)
+			if perr != nil {
+				return p.errorf("invalid unicode escape")
+			}
+			sb.WriteRune(rune(r))
+			p.pos += 4
+		default:
+			return p.errorf("invalid escape \\%c", e)
+		}
+		p.pos += 2
+	}
+
+	p.tok.value = sb.String()
+	return
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+func (p *parser) expect(kind tokenKind, value string) (err error) {
+	if !p.peek(kind, value) {
+		return p.errorf("expected %q, got %q", value, p.tok.value)
+	}
+	return p.next()
+}
+
+func (p *parser) expectName() (name string, err error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected name, got %q", p.tok.value)
+	}
+	name = p.tok.value
+	err = p.next()
+	return
+}
+
+func (p *parser) parseOperation() (op *Operation, err error) {
+	op = &Operation{Type: OperationQuery}
+
+	if p.tok.kind == tokenName {
+		switch p.tok.value {
+		case string(OperationQuery), string(OperationMutation):
+			op.Type = OperationType(p.tok.value)
+		case "fragment":
+			return nil, p.errorf("fragments are not supported")
+		default:
+			return nil, p.errorf("unsupported operation %q", p.tok.value)
+		}
+		if err = p.next(); err != nil {
+			return
+		}
+		if p.tok.kind == tokenName {
+			op.Name = p.tok.value
+			if err = p.next(); err != nil {
+				return
+			}
+		}
+		if p.peek(tokenPunct, "(") {
+			if op.Variables, err = p.parseVariableDefinitions(); err != nil {
+				return
+			}
+		}
+		if p.peek(tokenPunct, "@") {
+			return nil, p.errorf("directives are not supported")
+		}
+	}
+
+	op.Selections, err = p.parseSelectionSet(1)
+	return
+}
+
+func (p *parser) parseVariableDefinitions() (defs []*VariableDefinition, err error) {
+	if err = p.expect(tokenPunct, "("); err != nil {
+		return
+	}
+
+	for !p.peek(tokenPunct, ")") {
+		def := &VariableDefinition{}
+
+		if err = p.expect(tokenPunct, "$"); err != nil {
+			return
+		}
+		if def.Name, err = p.expectName(); err != nil {
+			return
+		}
+		if err = p.expect(tokenPunct, ":"); err != nil {
+			return
+		}
+		if def.Type, def.Required, err = p.parseType(); err != nil {
+			return
+		}
+		if p.peek(tokenPunct, "=") {
+			if err = p.next(); err != nil {
+				return
+			}
+			if def.Default, err = p.parseValue(true); err != nil {
+				return
+			}
+		}
+
+		defs = append(defs, def)
+	}
+
+	err = p.next()
+	return
+}
+
+func (p *parser) parseType() (typ string, required bool, err error) {
+	if p.peek(tokenPunct, "[") {
+		if err = p.next(); err != nil {
+			return
+		}
+		var elem string
+		if elem, _, err = p.parseType(); err != nil {
+			return
+		}
+		// keep the element nullability in the type name
+		typ = "[" + elem + "]"
+		if err = p.expect(tokenPunct, "]"); err != nil {
+			return
+		}
+	} else if typ, err = p.expectName(); err != nil {
+		return
+	}
+
+	if p.peek(tokenPunct, "!") {
+		required = true
+		err = p.next()
+	}
+
+	return
+}
+
+func (p *parser) parseSelectionSet(depth int) (fields []*Field, err error) {
+	if p.maxDepth > 0 && depth > p.maxDepth {
+		return nil, p.errorf("selections nested deeper than %d", p.maxDepth)
+	}
+	if err = p.expect(tokenPunct, "{"); err != nil {
+		return
+	}
+
+	for !p.peek(tokenPunct, "}") {
+		if p.tok.kind == tokenEOF {
+			return nil, p.errorf("unterminated selection set")
+		}
+		if p.peek(tokenPunct, "...") {
+			return nil, p.errorf("fragments are not supported")
+		}
+
+		var f *Field
+		if f, err = p.parseField(depth); err != nil {
+			return
+		}
+		fields = append(fields, f)
+	}
+
+	if len(fields) == 0 {
+		return nil, p.errorf("empty selection set")
+	}
+
+	err = p.next()
+	return
+}
+
+func (p *parser) parseField(depth int) (f *Field, err error) {
+	f = &Field{}
+
+	if f.Name, err = p.expectName(); err != nil {
+		return
+	}
+	if p.peek(tokenPunct, ":") {
+		if err = p.next(); err != nil {
+			return
+		}
+		f.Alias = f.Name
+		if f.Name, err = p.expectName(); err != nil {
+			return
+		}
+	}
+
+	if p.peek(tokenPunct, "(") {
+		if err = p.next(); err != nil {
+			return
+		}
+		for !p.peek(tokenPunct, ")") {
+			arg := &Argument{}
+			if arg.Name, err = p.expectName(); err != nil {
+				return
+			}
+			if err = p.expect(tokenPunct, ":"); err != nil {
+				return
+			}
+			if arg.Value, err = p.parseValue(false); err != nil {
+				return
+			}
+			f.Arguments = append(f.Arguments, arg)
+		}
+		if err = p.next(); err != nil {
+			return
+		}
+	}
+
+	if p.peek(tokenPunct, "@") {
+		return nil, p.errorf("directives are not supported")
+	}
+
+	if p.peek(tokenPunct, "{") {
+		f.Selections, err = p.parseSelectionSet(depth + 1)
+	}
+
+	return
+}
+
+func (p *parser) parseValue(constant bool) (v interface{}, err error) {
+	switch p.tok.kind {
+	case tokenInt:
+		if v, err = strconv.ParseInt(p.tok.value, 10, 64); err != nil {
+			return nil, p.errorf("invalid int %s", p.tok.value)
+		}
+	case tokenFloat:
+		if v, err = strconv.ParseFloat(p.tok.value, 64); err != nil {
+			return nil, p.errorf("invalid float %s", p.tok.value)
+		}
+	case tokenString:
+		v = p.tok.value
+	case tokenName:
+		switch p.tok.value {
+		case "true":
+			v = true
+		case "false":
+			v = false
+		case "null":
+			v = nil
+		default:
+			v = Enum(p.tok.value)
+		}
+	case tokenPunct:
+		switch p.tok.value {
+		case "$":
+			if constant {
+				return nil, p.errorf("unexpected variable")
+			}
+			if err = p.next(); err != nil {
+				return
+			}
+			var name string
+			if name, err = p.expectName(); err != nil {
+				return
+			}
+			return Variable(name), nil
+		case "[":
+			return p.parseList(constant)
+		case "{":
+			return p.parseObject(constant)
+		}
+		fallthrough
+	default:
+		return nil, p.errorf("unexpected %q", p.tok.value)
+	}
+
+	err = p.next()
+	return
+}
+
+func (p *parser) parseList(constant bool) (v interface{}, err error) {
+	if err = p.next(); err != nil {
+		return
+	}
+
+	list := []interface{}{}
+	for !p.peek(tokenPunct, "]") {
+		if p.tok.kind == tokenEOF {
+			return nil, p.errorf("unterminated list")
+		}
+		var item interface{}
+		if item, err = p.parseValue(constant); err != nil {
+			return
+		}
+		list = append(list, item)
+	}
+
+	err = p.next()
+	return list, err
+}
+
+func (p *parser) parseObject(constant bool) (v interface{}, err error) {
+	if err = p.next(); err != nil {
+		return
+	}
+
+	obj := map[string]interface{}{}
+	for !p.peek(tokenPunct, "}") {
+		var name string
+		if name, err = p.expectName(); err != nil {
+			return
+		}
+		if err = p.expect(tokenPunct, ":"); err != nil {
+			return
+		}
+		if obj[name], err = p.parseValue(constant); err != nil {
+			return
+		}
+	}
+
+	err = p.next()
+	return obj, err
+}
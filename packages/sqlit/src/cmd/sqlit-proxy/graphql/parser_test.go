@@ -0,0 +1,162 @@
+package graphql
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParse(t *testing.T) {
+	Convey("Given the valid graphql documents", t, func() {
+		Convey("The shorthand query should be parsed", func() {
+			doc, err := Parse(`{ users { id name } }`, 0, 0)
+			So(err, ShouldBeNil)
+			So(doc.Operations, ShouldHaveLength, 1)
+			op := doc.Operations[0]
+			So(op.Type, ShouldEqual, OperationQuery)
+			So(op.Name, ShouldBeEmpty)
+			So(op.Selections, ShouldHaveLength, 1)
+			So(op.Selections[0].Name, ShouldEqual, "users")
+			So(op.Selections[0].Selections, ShouldHaveLength, 2)
+			So(op.Selections[0].Selections[1].Name, ShouldEqual, "name")
+		})
+		Convey("The operations with variables, aliases and arguments should be parsed", func() {
+			doc, err := Parse(`
+				# comments and commas are ignored
+				query Find($filter: JSON!, $limit: Int = 10, $ids: [Int!]) {
+					top: users(filter: $filter, limit: $limit, order: {id: -1}) { id, name }
+				}
+				mutation Add {
+					insert_users(data: {name: "a\"bé", score: 1.5e2, ok: true, tag: null, kind: ADMIN}) {
+						affected_rows
+					}
+				}
+			`, 0, 0)
+			So(err, ShouldBeNil)
+			So(doc.Operations, ShouldHaveLength, 2)
+
+			find := doc.Operations[0]
+			So(find.Type, ShouldEqual, OperationQuery)
+			So(find.Name, ShouldEqual, "Find")
+			So(find.Variables, ShouldResemble, []*VariableDefinition{
+				{Name: "filter", Type: "JSON", Required: true},
+				{Name: "limit", Type: "Int", Default: int64(10)},
+				{Name: "ids", Type: "[Int]"},
+			})
+			users := find.Selections[0]
+			So(users.Alias, ShouldEqual, "top")
+			So(users.Name, ShouldEqual, "users")
+			So(users.ResponseKey(), ShouldEqual, "top")
+			So(users.Arguments, ShouldResemble, []*Argument{
+				{Name: "filter", Value: Variable("filter")},
+				{Name: "limit", Value: Variable("limit")},
+				{Name: "order", Value: map[string]interface{}{"id": int64(-1)}},
+			})
+
+			add := doc.Operations[1]
+			So(add.Type, ShouldEqual, OperationMutation)
+			So(add.Name, ShouldEqual, "Add")
+			So(add.Selections[0].Arguments, ShouldResemble, []*Argument{
+				{Name: "data", Value: map[string]interface{}{
+					"name":  "a\"bé",
+					"score": float64(150),
+					"ok":    true,
+					"tag":   nil,
+					"kind":  Enum("ADMIN"),
+				}},
+			})
+		})
+		Convey("The block strings should be kept as is", func() {
+			doc, err := Parse(`{ users(filter: {name: """a "quoted" \n \"""name"""}) { id } }`, 0, 0)
+			So(err, ShouldBeNil)
+			So(doc.Operations[0].Selections[0].Arguments[0].Value, ShouldResemble, map[string]interface{}{
+				"name": `a "quoted" \n """name`,
+			})
+		})
+	})
+	Convey("Given the malformed or unsupported graphql documents", t, func() {
+		for _, c := range []struct {
+			name string
+			src  string
+			err  string
+		}{
+			{"empty document", ``, "empty graphql document"},
+			{"blank document", " \n# comment only\n", "empty graphql document"},
+			{"empty selection set", `{ }`, "empty selection set"},
+			{"unterminated selection set", `{ users { id }`, "unterminated selection set"},
+			{"missing selection set", `query Find`, `expected "{"`},
+			{"unexpected character", `{ users { id; } }`, "unexpected character"},
+			{"unterminated string", `{ users(filter: {name: "abc}) { id } }`, "unterminated string"},
+			{"multiline string", "{ users(filter: {name: \"a\nb\"}) { id } }", "unterminated string"},
+			{"unterminated block string", `{ users(filter: {name: """abc}) { id } }`, "unterminated string"},
+			{"invalid escape", `{ users(filter: {name: "\x41"}) { id } }`, "invalid escape"},
+			{"invalid unicode escape", `{ users(filter: {name: "\u00zz"}) { id } }`, "invalid unicode escape"},
+			{"invalid number", `{ users(limit: 1.) { id } }`, "invalid number"},
+			{"number followed by name", `{ users(limit: 10abc) { id } }`, "invalid number"},
+			{"int overflow", `{ users(limit: 99999999999999999999) { id } }`, "invalid int"},
+			{"unterminated list", `{ users(filter: {id: [1, 2 }) { id } }`, "unexpected"},
+			{"missing argument value", `{ users(limit: ) { id } }`, "unexpected"},
+			{"variable in default value", `query ($a: Int = $b) { users { id } }`, "unexpected variable"},
+			{"missing variable type", `query ($a) { users { id } }`, `expected ":"`},
+			{"subscription", `subscription { users { id } }`, "unsupported operation"},
+			{"fragment definition", `fragment f on users { id }`, "fragments are not supported"},
+			{"fragment spread", `{ users { ...f } }`, "fragments are not supported"},
+			{"inline fragment", `{ users { ... on users { id } } }`, "fragments are not supported"},
+			{"operation directive", `query Find @cached { users { id } }`, "directives are not supported"},
+			{"field directive", `{ users @include(if: true) { id } }`, "directives are not supported"},
+		} {
+			Convey("The document with "+c.name+" should be refused", func() {
+				doc, err := Parse(c.src, 0, 0)
+				So(doc, ShouldBeNil)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, c.err)
+			})
+		}
+	})
+	Convey("Given the graphql documents exceeding the limits", t, func() {
+		var (
+			src    = `{ users { id } }`
+			nested = `{ a { b { c { id } } } }`
+		)
+
+		Convey("The documents longer than the max length should be refused", func() {
+			doc, err := Parse(src, len(src)-1, 0)
+			So(doc, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "too long")
+			doc, err = Parse(src+strings.Repeat(" ", 1<<20), 64*1024, 0)
+			So(doc, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			_, err = Parse(src, len(src), 0)
+			So(err, ShouldBeNil)
+		})
+		Convey("The selections nested deeper than the max depth should be refused", func() {
+			for _, c := range []struct {
+				maxDepth int
+				refused  bool
+			}{
+				{0, false},
+				{2, true},
+				{3, true},
+				{4, false},
+			} {
+				doc, err := Parse(nested, 0, c.maxDepth)
+				if c.refused {
+					So(doc, ShouldBeNil)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "nested deeper")
+				} else {
+					So(err, ShouldBeNil)
+				}
+			}
+		})
+		Convey("The deeply nested documents should be refused before recursing", func() {
+			deep := strings.Repeat("{ a ", 100000) + strings.Repeat("}", 100000)
+			doc, err := Parse(deep, 0, 2)
+			So(doc, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "nested deeper")
+		})
+	})
+}
@@ -0,0 +1,213 @@
+package graphql
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Root field name suffixes and prefixes of the generated schema.
+const (
+	countSuffix    = "_count"
+	insertPrefix   = "insert_"
+	updatePrefix   = "update_"
+	removePrefix   = "remove_"
+	typenameField  = "__typename"
+	mutationResult = "MutationResult"
+)
+
+var (
+	nameRe = regexp.MustCompile(`^[_A-Za-z][_0-9A-Za-z]*$`)
+
+	// type names used by the schema itself
+	reservedTypes = map[string]bool{
+		"Query": true, "Mutation": true, "Subscription": true, mutationResult: true,
+		"JSON": true, "Int": true, "Float": true, "String": true, "Boolean": true, "ID": true,
+	}
+)
+
+// Table defines a project table exposed by the schema, the types are the declared column types.
+type Table struct {
+	Name    string
+	Columns []string
+	Types   []string
+}
+
+type rootKind int
+
+const (
+	rootFind rootKind = iota
+	rootCount
+	rootInsert
+	rootUpdate
+	rootRemove
+)
+
+type objectType struct {
+	name    string
+	columns []string
+	types   map[string]string // column name to graphql scalar type
+}
+
+type rootField struct {
+	kind  rootKind
+	table *objectType
+}
+
+// Schema defines the graphql schema generated from the project tables. Each table is an object
+// type with a field per column, and is served by the root fields:
+//
+//	query    <table>(filter: JSON, order: JSON, skip: Int, limit: Int): [<table>!]!
+//	query    <table>_count(filter: JSON): Int!
+//	mutation insert_<table>(data: JSON!): MutationResult!
+//	mutation update_<table>(filter: JSON, update: JSON!, one: Boolean): MutationResult!
+//	mutation remove_<table>(filter: JSON, one: Boolean): MutationResult!
+//
+// The JSON arguments take the same objects as the data api of the proxy. The tables or columns
+// whose names are not valid graphql names are left out.
+type Schema struct {
+	types     map[string]*objectType
+	queries   map[string]*rootField
+	mutations map[string]*rootField
+}
+
+// NewSchema generates the schema of tables.
+func NewSchema(tables []*Table) (s *Schema) {
+	s = &Schema{
+		types:     make(map[string]*objectType),
+		queries:   make(map[string]*rootField),
+		mutations: make(map[string]*rootField),
+	}
+
+	sorted := make([]*Table, len(tables))
+	copy(sorted, tables)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for _, t := range sorted {
+		if !nameRe.MatchString(t.Name) || strings.HasPrefix(t.Name, "__") || reservedTypes[t.Name] {
+			continue
+		}
+
+		ot := &objectType{
+			name:  t.Name,
+			types: make(map[string]string),
+		}
+		for i, c := range t.Columns {
+			if !nameRe.MatchString(c) || strings.HasPrefix(c, "__") {
+				continue
+			}
+			var declType string
+			if i < len(t.Types) {
+				declType = t.Types[i]
+			}
+			ot.columns = append(ot.columns, c)
+			ot.types[c] = scalarType(declType)
+		}
+		if len(ot.columns) == 0 {
+			continue
+		}
+
+		// the earlier table keeps the name on conflicts
+		var (
+			queries = map[string]*rootField{
+				t.Name:               {kind: rootFind, table: ot},
+				t.Name + countSuffix: {kind: rootCount, table: ot},
+			}
+			mutations = map[string]*rootField{
+				insertPrefix + t.Name: {kind: rootInsert, table: ot},
+				updatePrefix + t.Name: {kind: rootUpdate, table: ot},
+				removePrefix + t.Name: {kind: rootRemove, table: ot},
+			}
+			conflict bool
+		)
+		for name := range queries {
+			if _, ok := s.queries[name]; ok {
+				conflict = true
+			}
+		}
+		for name := range mutations {
+			if _, ok := s.mutations[name]; ok {
+				conflict = true
+			}
+		}
+		if _, ok := s.types[t.Name]; ok || conflict {
+			continue
+		}
+
+		s.types[t.Name] = ot
+		for name, f := range queries {
+			s.queries[name] = f
+		}
+		for name, f := range mutations {
+			s.mutations[name] = f
+		}
+	}
+
+	return
+}
+
+// scalarType maps the declared column type to a graphql scalar type with the sqlite type
+// affinity rules.
+func scalarType(declType string) string {
+	t := strings.ToUpper(declType)
+
+	switch {
+	case strings.Contains(t, "INT"):
+		return "Int"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "String"
+	case strings.Contains(t, "BOOL"):
+		return "Boolean"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"),
+		strings.Contains(t, "NUMERIC"), strings.Contains(t, "DECIMAL"):
+		return "Float"
+	default:
+		return "String"
+	}
+}
+
+// SDL returns the schema definition of the schema.
+func (s *Schema) SDL() string {
+	var (
+		sb    strings.Builder
+		names = make([]string, 0, len(s.types))
+	)
+
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	sb.WriteString("scalar JSON\n\n")
+
+	sb.WriteString("type Query {\n")
+	for _, name := range names {
+		sb.WriteString("  " + name + "(filter: JSON, order: JSON, skip: Int, limit: Int): [" + name + "!]!\n")
+		sb.WriteString("  " + name + countSuffix + "(filter: JSON): Int!\n")
+	}
+	sb.WriteString("}\n\n")
+
+	if len(names) > 0 {
+		sb.WriteString("type Mutation {\n")
+		for _, name := range names {
+			sb.WriteString("  " + insertPrefix + name + "(data: JSON!): " + mutationResult + "!\n")
+			sb.WriteString("  " + updatePrefix + name +
+				"(filter: JSON, update: JSON!, one: Boolean): " + mutationResult + "!\n")
+			sb.WriteString("  " + removePrefix + name + "(filter: JSON, one: Boolean): " + mutationResult + "!\n")
+		}
+		sb.WriteString("}\n\n")
+	}
+
+	sb.WriteString("type " + mutationResult + " {\n  affected_rows: Int!\n  last_insert_id: Int!\n}\n")
+
+	for _, name := range names {
+		ot := s.types[name]
+		sb.WriteString("\ntype " + name + " {\n")
+		for _, c := range ot.columns {
+			sb.WriteString("  " + c + ": " + ot.types[c] + "\n")
+		}
+		sb.WriteString("}\n")
+	}
+
+	return sb.String()
+}
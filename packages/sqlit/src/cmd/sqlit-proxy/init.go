@@ -40,7 +40,7 @@ func initServer(cfg *config.Config) (server *http.Server, afterShutdown func(),
 	tm := initTaskManager(e, cfg, db)
 
 	// init rules manager
-	initRulesManager(e)
+	initRulesManager(e, cfg)
 
 	api.AddRoutes(e)
 
@@ -77,6 +77,11 @@ func initDB(e *gin.Engine, cfg *config.Config) (st *gorp.DbMap, err error) {
 		return
 	}
 
+	// add columns to tables created by former versions
+	if err = model.MigrateTables(st); err != nil {
+		return
+	}
+
 	e.Use(func(c *gin.Context) {
 		c.Set("db", st)
 		c.Next()
@@ -112,9 +117,17 @@ func initTaskManager(e *gin.Engine, cfg *config.Config, db *gorp.DbMap) (tm *tas
 	return
 }
 
-func initRulesManager(e *gin.Engine) (rm *resolver.RulesManager) {
+func initRulesManager(e *gin.Engine, cfg *config.Config) (rm *resolver.RulesManager) {
 	rm = &resolver.RulesManager{}
 
+	// the rules may be updated by another replica
+	if cfg.Replica != nil {
+		rm.TTL = resolver.DefaultRulesCacheTTL
+		if cfg.Replica.RulesCacheTTL > 0 {
+			rm.TTL = cfg.Replica.RulesCacheTTL
+		}
+	}
+
 	e.Use(func(c *gin.Context) {
 		c.Set("rules", rm)
 		c.Next()
@@ -5,6 +5,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"runtime"
@@ -12,10 +13,12 @@ import (
 
 	"sqlit/src/client"
 	"sqlit/src/cmd/sqlit-proxy/config"
+	"sqlit/src/conf"
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/kms"
 	"sqlit/src/utils"
 	"sqlit/src/utils/log"
+	"sqlit/src/utils/proxyproto"
 )
 
 const name = "sqlit-proxy"
@@ -68,6 +71,11 @@ func main() {
 		os.Exit(-1)
 		return
 	}
+	if err = conf.InitLog(conf.GConf.Log); err != nil {
+		log.WithError(err).Error("init log file failed")
+		os.Exit(-1)
+		return
+	}
 
 	// load proxy config from same config file
 	var cfg *config.Config
@@ -89,8 +97,14 @@ func main() {
 		return
 	}
 
+	var listener net.Listener
+	if listener, err = net.Listen("tcp", server.Addr); err != nil {
+		log.WithError(err).Error("listen failed")
+		os.Exit(-1)
+		return
+	}
 	go func() {
-		_ = server.ListenAndServe()
+		_ = server.Serve(proxyproto.Wrap(listener, cfg.ProxyProtocol))
 	}()
 
 	log.Info("started proxy")
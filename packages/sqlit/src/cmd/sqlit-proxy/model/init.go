@@ -1,7 +1,12 @@
 
 package model
 
-import gorp "gopkg.in/gorp.v2"
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	gorp "gopkg.in/gorp.v2"
+)
 
 // AddTables register tables to gorp database map.
 func AddTables(dbMap *gorp.DbMap) {
@@ -19,3 +24,21 @@ func AddTables(dbMap *gorp.DbMap) {
 	tblProject.ColMap("Alias").SetUnique(true)
 	tblProject.ColMap("DB").SetUnique(true)
 }
+
+// MigrateTables adds the columns missing in the tables created by the former versions.
+func MigrateTables(dbMap *gorp.DbMap) (err error) {
+	for _, c := range []struct {
+		table, column, def string
+	}{
+		{"task", "owner", `VARCHAR(255) NOT NULL DEFAULT ''`},
+		{"task", "lease_expire", `INTEGER NOT NULL DEFAULT 0`},
+		{"task", "killed", `INTEGER NOT NULL DEFAULT 0`},
+	} {
+		_, err = dbMap.Exec(`ALTER TABLE "` + c.table + `" ADD COLUMN "` + c.column + `" ` + c.def)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column") {
+			return errors.Wrapf(err, "add column %s of table %s failed", c.column, c.table)
+		}
+	}
+	err = nil
+	return
+}
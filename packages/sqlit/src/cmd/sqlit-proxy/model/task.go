@@ -2,6 +2,7 @@
 package model
 
 import (
+	"database/sql"
 	"encoding/json"
 	"time"
 
@@ -74,8 +75,13 @@ type Task struct {
 	Created   int64     `db:"created"`
 	Updated   int64     `db:"updated"`
 	Finished  int64     `db:"finished"`
-	Args      gin.H     `db:"-"`
-	Result    gin.H     `db:"-"`
+	// the proxy replica running the task until the lease expires
+	Owner       string `db:"owner"`
+	LeaseExpire int64  `db:"lease_expire"`
+	// the task is killed, it's aborted by the owner on renewing the lease
+	Killed bool  `db:"killed"`
+	Args   gin.H `db:"-"`
+	Result gin.H `db:"-"`
 }
 
 // PostGet implements gorp.HasPostGet interface.
@@ -242,3 +248,117 @@ func ListIncompleteTask(db *gorp.DbMap, limit int64) (tasks []*Task, err error)
 	}
 	return
 }
+
+// ListSchedulableTask fetches the waiting tasks and the running tasks whose leases are expired for
+// scheduling with limits.
+func ListSchedulableTask(db *gorp.DbMap, limit int64) (tasks []*Task, err error) {
+	_, err = db.Select(&tasks,
+		`SELECT * FROM "task" WHERE "state" = ? OR ("state" = ? AND "lease_expire" < ?)
+ORDER BY "id" ASC LIMIT ?`,
+		TaskWaiting, TaskRunning, time.Now().Unix(), limit)
+	if err != nil {
+		err = errors.Wrapf(err, "get schedulable task list failed")
+	}
+	return
+}
+
+// GetTaskState returns the state of task with specified id.
+func GetTaskState(db *gorp.DbMap, id int64) (state TaskState, err error) {
+	var v int64
+	if v, err = db.SelectInt(`SELECT "state" FROM "task" WHERE "id" = ? LIMIT 1`, id); err != nil {
+		err = errors.Wrapf(err, "get task state failed")
+	}
+	state = TaskState(v)
+	return
+}
+
+// ClaimTask sets the waiting task running by owner until the lease expires, it returns false if
+// the task is killed or claimed by another proxy replica.
+func ClaimTask(db *gorp.DbMap, t *Task, owner string, expire int64) (ok bool, err error) {
+	now := time.Now().Unix()
+	res, err := db.Exec(`UPDATE "task" SET "state" = ?, "owner" = ?, "lease_expire" = ?, "updated" = ?
+WHERE "id" = ? AND "state" = ? AND "killed" = 0`,
+		TaskRunning, owner, expire, now, t.ID, TaskWaiting)
+	if err != nil {
+		err = errors.Wrapf(err, "claim task failed")
+		return
+	}
+	if ok = affectedOne(res); ok {
+		t.State, t.Owner, t.LeaseExpire, t.Updated = TaskRunning, owner, expire, now
+	}
+	return
+}
+
+// RenewTaskLease extends the lease of the running task owned by owner, it returns false if the
+// task is killed or not owned by owner any more.
+func RenewTaskLease(db *gorp.DbMap, t *Task, owner string, expire int64) (ok bool, err error) {
+	res, err := db.Exec(`UPDATE "task" SET "lease_expire" = ?
+WHERE "id" = ? AND "state" = ? AND "owner" = ? AND "killed" = 0`,
+		expire, t.ID, TaskRunning, owner)
+	if err != nil {
+		err = errors.Wrapf(err, "renew task lease failed")
+		return
+	}
+	if ok = affectedOne(res); ok {
+		t.LeaseExpire = expire
+	}
+	return
+}
+
+// FinishTask saves the state and result of the task finished by owner, it returns false if the
+// task is not owned by owner any more.
+func FinishTask(db *gorp.DbMap, t *Task, owner string) (ok bool, err error) {
+	if err = t.Serialize(); err != nil {
+		return
+	}
+	t.Updated = time.Now().Unix()
+	res, err := db.Exec(`UPDATE "task" SET "state" = ?, "result" = ?, "updated" = ?, "finished" = ?
+WHERE "id" = ? AND "state" = ? AND "owner" = ?`,
+		t.State, t.RawResult, t.Updated, t.Finished, t.ID, TaskRunning, owner)
+	if err != nil {
+		err = errors.Wrapf(err, "finish task failed")
+		return
+	}
+	ok = affectedOne(res)
+	return
+}
+
+// AbortTask fails the killed waiting task or the running task whose lease is expired, it returns
+// false if the task is changed by another proxy replica since it's fetched.
+func AbortTask(db *gorp.DbMap, t *Task, reason error) (ok bool, err error) {
+	now := time.Now().Unix()
+	t.Result = gin.H{
+		"error":  reason.Error(),
+		"result": nil,
+	}
+	if err = t.Serialize(); err != nil {
+		return
+	}
+	res, err := db.Exec(`UPDATE "task" SET "state" = ?, "result" = ?, "updated" = ?, "finished" = ?
+WHERE "id" = ? AND "state" = ? AND "lease_expire" = ? AND "lease_expire" < ?`,
+		TaskFailed, t.RawResult, now, now, t.ID, t.State, t.LeaseExpire, now)
+	if err != nil {
+		err = errors.Wrapf(err, "abort task failed")
+		return
+	}
+	if ok = affectedOne(res); ok {
+		t.State, t.Updated, t.Finished = TaskFailed, now, now
+	}
+	return
+}
+
+// KillTask marks the incomplete task killed, the owner of the running task aborts it on renewing
+// the lease.
+func KillTask(db *gorp.DbMap, id int64) (err error) {
+	_, err = db.Exec(`UPDATE "task" SET "killed" = 1 WHERE "id" = ? AND "state" IN (?, ?)`,
+		id, TaskWaiting, TaskRunning)
+	if err != nil {
+		err = errors.Wrapf(err, "kill task failed")
+	}
+	return
+}
+
+func affectedOne(res sql.Result) bool {
+	n, err := res.RowsAffected()
+	return err == nil && n == 1
+}
@@ -0,0 +1,167 @@
+package model
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	gorp "gopkg.in/gorp.v2"
+)
+
+func newTestDB() (db *gorp.DbMap) {
+	raw, err := sql.Open("sqlite3", ":memory:")
+	So(err, ShouldBeNil)
+	// the memory database lives as long as the connection
+	raw.SetMaxOpenConns(1)
+	db = &gorp.DbMap{Db: raw, Dialect: gorp.SqliteDialect{}}
+	AddTables(db)
+	So(db.CreateTablesIfNotExists(), ShouldBeNil)
+	So(MigrateTables(db), ShouldBeNil)
+	Reset(func() {
+		_ = raw.Close()
+	})
+	return
+}
+
+func reloadTask(db *gorp.DbMap, t *Task) (r *Task) {
+	r, err := GetTask(db, t.Developer, t.ID)
+	So(err, ShouldBeNil)
+	return
+}
+
+func TestTaskLease(t *testing.T) {
+	Convey("Given a waiting task in the shared storage", t, func() {
+		var (
+			db          = newTestDB()
+			now         = time.Now().Unix()
+			expire      = now + 30
+			task, err   = NewTask(db, TaskCreateDB, 1, 2, nil)
+			other, err2 = GetTask(db, 1, task.ID)
+		)
+		So(err, ShouldBeNil)
+		So(err2, ShouldBeNil)
+
+		Convey("The task should be claimed by a single replica", func() {
+			ok, err := ClaimTask(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(task.State, ShouldEqual, TaskRunning)
+			ok, err = ClaimTask(db, other, "b", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+
+			r := reloadTask(db, task)
+			So(r.State, ShouldEqual, TaskRunning)
+			So(r.Owner, ShouldEqual, "a")
+			So(r.LeaseExpire, ShouldEqual, expire)
+
+			tasks, err := ListSchedulableTask(db, 10)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldBeEmpty)
+		})
+		Convey("The killed task should not be claimed", func() {
+			So(KillTask(db, task.ID), ShouldBeNil)
+			ok, err := ClaimTask(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(task.State, ShouldEqual, TaskWaiting)
+		})
+		Convey("The lease should be renewed by the owner only", func() {
+			ok, err := ClaimTask(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			ok, err = RenewTaskLease(db, task, "a", expire+30)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			So(reloadTask(db, task).LeaseExpire, ShouldEqual, expire+30)
+
+			ok, err = RenewTaskLease(db, other, "b", expire+60)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(reloadTask(db, task).LeaseExpire, ShouldEqual, expire+30)
+
+			Convey("The killed task should not be renewed", func() {
+				So(KillTask(db, task.ID), ShouldBeNil)
+				ok, err = RenewTaskLease(db, task, "a", expire+60)
+				So(err, ShouldBeNil)
+				So(ok, ShouldBeFalse)
+			})
+		})
+		Convey("The task should be finished by the owner only", func() {
+			ok, err := ClaimTask(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			other.State, other.Finished = TaskSuccess, now
+			ok, err = FinishTask(db, other, "b")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(reloadTask(db, task).State, ShouldEqual, TaskRunning)
+
+			task.State, task.Finished = TaskSuccess, now
+			task.Result = map[string]interface{}{"db": "x"}
+			ok, err = FinishTask(db, task, "a")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			r := reloadTask(db, task)
+			So(r.State, ShouldEqual, TaskSuccess)
+			So(r.Result["db"], ShouldEqual, "x")
+
+			So(KillTask(db, task.ID), ShouldBeNil)
+			So(reloadTask(db, task).Killed, ShouldBeFalse)
+		})
+		Convey("The running task should be aborted after the lease expires", func() {
+			ok, err := ClaimTask(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			ok, err = AbortTask(db, other, errors.New("killed"))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(reloadTask(db, task).State, ShouldEqual, TaskRunning)
+
+			// the owner is gone and the lease expires
+			_, err = db.Exec(`UPDATE "task" SET "lease_expire" = ? WHERE "id" = ?`, now-1, task.ID)
+			So(err, ShouldBeNil)
+			tasks, err := ListSchedulableTask(db, 10)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+			expired := tasks[0]
+			So(expired.LeaseExpire, ShouldEqual, now-1)
+
+			ok, err = AbortTask(db, expired, errors.New("lease expired"))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			r := reloadTask(db, task)
+			So(r.State, ShouldEqual, TaskFailed)
+			So(r.Result["error"], ShouldEqual, "lease expired")
+
+			// the result of the late owner is dropped
+			task.State, task.Finished = TaskSuccess, now
+			ok, err = FinishTask(db, task, "a")
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(reloadTask(db, task).State, ShouldEqual, TaskFailed)
+		})
+		Convey("The renewed lease should not be aborted with the stale lease", func() {
+			ok, err := ClaimTask(db, task, "a", now-1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			tasks, err := ListSchedulableTask(db, 10)
+			So(err, ShouldBeNil)
+			So(tasks, ShouldHaveLength, 1)
+
+			ok, err = RenewTaskLease(db, task, "a", expire)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+			ok, err = AbortTask(db, tasks[0], errors.New("lease expired"))
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeFalse)
+			So(reloadTask(db, task).State, ShouldEqual, TaskRunning)
+		})
+	})
+}
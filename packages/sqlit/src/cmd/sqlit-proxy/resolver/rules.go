@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	validator "gopkg.in/go-playground/validator.v9"
@@ -41,15 +42,29 @@ const (
 	UserStateDisabled = "disabled"
 )
 
+// DefaultRulesCacheTTL defines the default max age of the cached rules shared by proxy replicas.
+const DefaultRulesCacheTTL = 30 * time.Second
+
 // RulesManager defines the rules manger object for project rules cache.
 type RulesManager struct {
-	rules sync.Map // map[proto.DatabaseID]*Rules
+	// TTL is the max age of the cached rules, 0 means the rules are cached until updated.
+	TTL   time.Duration
+	rules sync.Map // map[proto.DatabaseID]*cachedRules
+}
+
+type cachedRules struct {
+	rules  *Rules
+	loaded time.Time
 }
 
 // Get returns the rules object of specified database.
 func (m *RulesManager) Get(dbID proto.DatabaseID) *Rules {
 	if v, ok := m.rules.Load(dbID); ok && v != nil {
-		return v.(*Rules)
+		c := v.(*cachedRules)
+		if m.TTL > 0 && time.Since(c.loaded) > m.TTL {
+			return nil
+		}
+		return c.rules
 	}
 
 	return nil
@@ -57,7 +72,7 @@ func (m *RulesManager) Get(dbID proto.DatabaseID) *Rules {
 
 // Set update the global rules cache with new rules object for specified database.
 func (m *RulesManager) Set(dbID proto.DatabaseID, rules *Rules) {
-	m.rules.Store(dbID, rules)
+	m.rules.Store(dbID, &cachedRules{rules: rules, loaded: time.Now()})
 }
 
 // use various helper types
@@ -67,12 +82,17 @@ type updateQueryEnforces struct {
 	Filter queryEnforces `json:"filter"`
 	Update queryEnforces `json:"update"`
 }
+type fieldEnforces struct {
+	Read  []string `json:"read"`  // subjects allowed to read the field, nil means all
+	Write []string `json:"write"` // subjects allowed to write the field, nil means all
+}
 type tableEnforces struct {
-	Find   queryEnforces       `json:"find"`
-	Count  queryEnforces       `json:"count"`
-	Remove queryEnforces       `json:"remove"`
-	Update updateQueryEnforces `json:"update"`
-	Insert queryEnforces       `json:"insert"`
+	Find   queryEnforces            `json:"find"`
+	Count  queryEnforces            `json:"count"`
+	Remove queryEnforces            `json:"remove"`
+	Update updateQueryEnforces      `json:"update"`
+	Insert queryEnforces            `json:"insert"`
+	Fields map[string]fieldEnforces `json:"fields"`
 }
 
 // RulesConfig defines raw rules config wrapper.
@@ -92,6 +112,20 @@ type Rules struct {
 type TableRules struct {
 	rules       map[RuleQueryType]*QueryRules
 	updateRules *QueryRules
+	fieldRules  map[string]*FieldRules
+}
+
+// FieldRules defines the subjects allowed to read or write a single field, nil means all.
+type FieldRules struct {
+	read  *fieldSubjects
+	write *fieldSubjects
+}
+
+type fieldSubjects struct {
+	all    bool
+	groups map[string]bool
+	users  map[string]bool
+	states map[string]bool
 }
 
 // QueryRules defines rules for specified query type.
@@ -168,6 +202,11 @@ func CompileRawRules(rules json.RawMessage) (r *Rules, err error) {
 			return
 		}
 
+		tableRules.fieldRules, err = compileFieldEnforces(cfg, tableEnforces.Fields)
+		if err != nil {
+			return
+		}
+
 		r.rules[tableName] = tableRules
 	}
 
@@ -245,6 +284,138 @@ func compileQueryEnforces(cfg *RulesConfig, enforces queryEnforces) (queryRules
 	return
 }
 
+func compileFieldEnforces(cfg *RulesConfig, enforces map[string]fieldEnforces) (
+	fieldRules map[string]*FieldRules, err error) {
+	fieldRules = make(map[string]*FieldRules, len(enforces))
+
+	for fieldName, fieldEnforces := range enforces {
+		rules := &FieldRules{}
+
+		if rules.read, err = compileFieldSubjects(cfg, fieldEnforces.Read); err != nil {
+			err = errors.Wrapf(err, "%s: invalid read rules", fieldName)
+			return
+		}
+		if rules.write, err = compileFieldSubjects(cfg, fieldEnforces.Write); err != nil {
+			err = errors.Wrapf(err, "%s: invalid write rules", fieldName)
+			return
+		}
+
+		fieldRules[fieldName] = rules
+	}
+
+	return
+}
+
+func compileFieldSubjects(cfg *RulesConfig, subjects []string) (fs *fieldSubjects, err error) {
+	if subjects == nil {
+		// open privilege
+		return
+	}
+
+	fs = &fieldSubjects{
+		groups: make(map[string]bool),
+		users:  make(map[string]bool),
+		states: make(map[string]bool),
+	}
+
+	for _, subject := range subjects {
+		switch {
+		case strings.HasPrefix(subject, "g:"):
+			groupName := subject[2:]
+
+			if _, ok := cfg.Groups[groupName]; !ok {
+				err = errors.Errorf("%s: unknown group", groupName)
+				return
+			}
+
+			fs.groups[groupName] = true
+		case strings.HasPrefix(subject, "u:"):
+			userName := subject[2:]
+
+			if userName == "" {
+				err = errors.New("invalid empty user name")
+				return
+			}
+
+			fs.users[userName] = true
+		case strings.HasPrefix(subject, "s:"):
+			userState := strings.ToLower(subject[2:])
+
+			switch userState {
+			case UserStateAnonymous:
+			case UserStateLoggedIn:
+			case UserStateWaitSignUpConfirm:
+			case UserStatePreRegistered:
+			case UserStateDisabled:
+			default:
+				err = errors.Errorf("invalid user state %s", userState)
+				return
+			}
+
+			fs.states[userState] = true
+		case subject == "default":
+			fs.all = true
+		default:
+			err = errors.Errorf("%s: invalid enforce type", subject)
+			return
+		}
+	}
+
+	return
+}
+
+// EnforceRulesOnFields checks the read or write permission of the user on the table fields.
+func (r *Rules) EnforceRulesOnFields(fields FieldMap, table string,
+	uid string, userState string, write bool) (err error) {
+	var (
+		tableRules *TableRules
+		ok         bool
+	)
+
+	if tableRules, ok = r.rules[table]; !ok || tableRules == nil {
+		// open privilege
+		return
+	}
+
+	for field := range fields {
+		var fieldRules *FieldRules
+		if fieldRules, ok = tableRules.fieldRules[field]; !ok || fieldRules == nil {
+			// open privilege
+			continue
+		}
+
+		var fs = fieldRules.read
+		if write {
+			fs = fieldRules.write
+		}
+
+		if !r.matchFieldSubjects(fs, uid, userState) {
+			if write {
+				err = errors.Errorf("permission denied to write field %s", field)
+			} else {
+				err = errors.Errorf("permission denied to read field %s", field)
+			}
+			return
+		}
+	}
+
+	return
+}
+
+func (r *Rules) matchFieldSubjects(fs *fieldSubjects, uid string, userState string) bool {
+	if fs == nil || fs.all || fs.states[userState] || fs.users[uid] {
+		return true
+	}
+
+	for _, g := range r.userGroups[uid] {
+		if fs.groups[g] {
+			return true
+		}
+	}
+
+	return false
+}
+
 // EnforceRulesOnFilter combines filter and rules to new filter object.
 func (r *Rules) EnforceRulesOnFilter(f map[string]interface{}, table string,
 	uid string, userState string, vars map[string]interface{}, qt RuleQueryType) (
@@ -3,6 +3,8 @@ package task
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"sync"
 	"time"
@@ -16,8 +18,19 @@ import (
 	"sqlit/src/utils/log"
 )
 
-// MaxTaskPerRound defines the max task to be schedule per round.
-const MaxTaskPerRound = 10
+const (
+	// MaxTaskPerRound defines the max task to be schedule per round.
+	MaxTaskPerRound = 10
+	// DefaultTaskLease defines the default lease of the tasks run by a proxy replica.
+	DefaultTaskLease = 30 * time.Second
+	// taskWaitInterval defines the interval of polling the task run by another proxy replica.
+	taskWaitInterval = time.Second
+	// taskPollInterval defines the interval of polling the database for the schedulable tasks.
+	taskPollInterval = 10 * time.Second
+)
+
+// minTaskLease is the shortest lease renewed in time, the lease expirations are in seconds.
+var minTaskLease = config.MinTaskLease
 
 type waitItem struct {
 	id int64
@@ -32,10 +45,15 @@ type taskItem struct {
 	result gin.H
 }
 
-// Manager defines the task manager object for task management.
+// Manager defines the task manager object for task management. The tasks are claimed by the
+// managers of the proxy replicas sharing the database with the leases, so that a task is run by
+// only one replica and is aborted if the replica fails.
 type Manager struct {
 	config    *config.Config
 	db        *gorp.DbMap
+	instance  string
+	lease     time.Duration
+	poll      time.Duration
 	ctx       context.Context
 	cancel    context.CancelFunc
 	killCh    chan int64
@@ -53,9 +71,30 @@ type HandleFunc func(c context.Context, config *config.Config, db *gorp.DbMap, t
 
 // NewManager returns the new manager object.
 func NewManager(config *config.Config, db *gorp.DbMap) *Manager {
+	var (
+		instance string
+		lease    = DefaultTaskLease
+	)
+	if r := config.Replica; r != nil {
+		instance = r.InstanceID
+		if r.TaskLease > 0 {
+			lease = r.TaskLease
+		}
+	}
+	if lease < minTaskLease {
+		lease = minTaskLease
+	}
+	if instance == "" {
+		var buf [8]byte
+		_, _ = rand.Read(buf[:])
+		instance = hex.EncodeToString(buf[:])
+	}
 	return &Manager{
 		config:    config,
 		db:        db,
+		instance:  instance,
+		lease:     lease,
+		poll:      taskPollInterval,
 		killCh:    make(chan int64),
 		waitCh:    make(chan *waitItem),
 		waitMap:   make(map[int64][]*waitItem),
@@ -72,7 +111,7 @@ func (m *Manager) Start() {
 	m.wg = sync.WaitGroup{}
 	m.wg.Add(1)
 	go m.run()
-	log.Debug("task manager started")
+	log.WithField("instance", m.instance).Debug("task manager started")
 }
 
 // Stop terminate running tasks and end the manager scheduling cycle.
@@ -93,8 +132,13 @@ func (m *Manager) Stop() {
 	log.Debug("task manager stopped")
 }
 
-// Kill terminated specified task.
+// Kill terminated specified task, the task run by another proxy replica is terminated by the
+// replica on renewing the lease.
 func (m *Manager) Kill(id int64) {
+	if err := model.KillTask(m.db, id); err != nil {
+		log.WithError(err).WithField("task", id).Warning("mark task killed failed")
+	}
+
 	select {
 	case m.killCh <- id:
 	case <-m.ctx.Done():
@@ -112,7 +156,6 @@ func (m *Manager) Wait(ctx context.Context, id int64) (err error) {
 
 	select {
 	case <-i.ch:
-		return
 	case <-ctx.Done():
 		err = ctx.Err()
 		return
@@ -120,6 +163,30 @@ func (m *Manager) Wait(ctx context.Context, id int64) (err error) {
 		err = m.ctx.Err()
 		return
 	}
+
+	// the task not run by this manager may be run by another proxy replica
+	ticker := time.NewTicker(taskWaitInterval)
+	defer ticker.Stop()
+
+	for {
+		var state model.TaskState
+		if state, err = model.GetTaskState(m.db, id); err != nil {
+			return
+		}
+		if state == model.TaskSuccess || state == model.TaskFailed {
+			return
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-m.ctx.Done():
+			err = m.ctx.Err()
+			return
+		}
+	}
 }
 
 // New pushes new task to scheduling pool.
@@ -153,6 +220,11 @@ func (m *Manager) Register(tt model.TaskType, f HandleFunc) {
 func (m *Manager) run() {
 	defer m.wg.Done()
 
+	renew := time.NewTicker(m.lease / 3)
+	defer renew.Stop()
+	poll := time.NewTicker(m.poll)
+	defer poll.Stop()
+
 	for {
 		select {
 		case <-m.ctx.Done():
@@ -190,37 +262,31 @@ func (m *Manager) run() {
 			if _, ok := m.taskMap[tsk.ID]; !ok {
 				m.runTask(tsk)
 			}
-		case <-time.After(10 * time.Second):
+		case <-renew.C:
+			m.renewLeases()
+		case <-poll.C:
 			// poll database for existing task
-			tasks, err := model.ListIncompleteTask(m.db, MaxTaskPerRound)
+			tasks, err := model.ListSchedulableTask(m.db, MaxTaskPerRound)
 
 			if err != nil {
 				continue
 			}
 
 			for _, t := range tasks {
-				switch t.State {
-				case model.TaskWaiting:
+				if _, ok := m.taskMap[t.ID]; ok {
+					// the lease of the local task is renewed later
+					continue
+				}
+
+				switch {
+				case t.State == model.TaskWaiting && !t.Killed:
 					// start job
-					if _, ok := m.taskMap[t.ID]; !ok {
-						m.runTask(t)
-					}
-				case model.TaskRunning:
-					// check running state
-					if _, ok := m.taskMap[t.ID]; !ok {
-						// not exists
-						// set to killed
-						m.cleanupTask(&taskItem{
-							task: t,
-							err:  errors.New("killed"),
-						})
-					}
+					m.runTask(t)
+				case t.State == model.TaskWaiting:
+					m.abortTask(t, errors.New("killed"))
 				default:
-					// invalid type or completed
-					m.cleanupTask(&taskItem{
-						task: t,
-						err:  errors.New("invalid task"),
-					})
+					// the replica running the task is gone
+					m.abortTask(t, errors.Errorf("killed, lease of %s expired", t.Owner))
 				}
 			}
 
@@ -233,8 +299,42 @@ func (m *Manager) run() {
 	}
 }
 
+// renewLeases extends the leases of the running tasks, the tasks killed or taken over by another
+// proxy replica are canceled.
+func (m *Manager) renewLeases() {
+	expire := time.Now().Add(m.lease).Unix()
+	for _, t := range m.taskMap {
+		ok, err := model.RenewTaskLease(m.db, t.task, m.instance, expire)
+		if err != nil {
+			// the lease may be renewed in the next round
+			log.WithError(err).Warningf("renew task lease failed: %v", t.task.LogData())
+			continue
+		}
+		if !ok {
+			log.Infof("task killed or lease lost: %v", t.task.LogData())
+			t.cancel()
+		}
+	}
+}
+
+// abortTask fails the task which is killed or whose owner is gone.
+func (m *Manager) abortTask(t *model.Task, reason error) {
+	ok, err := model.AbortTask(m.db, t, reason)
+	if err != nil || !ok {
+		return
+	}
+
+	log.Debugf("task aborted: %v", t.LogData())
+}
+
 func (m *Manager) runTask(t *model.Task) {
-	// update task state
+	// claim the task to set task state to running
+	ok, err := model.ClaimTask(m.db, t, m.instance, time.Now().Add(m.lease).Unix())
+	if err != nil || !ok {
+		// claim task failed, try start again in next round or it's run by another replica
+		return
+	}
+
 	tCtx, tc := context.WithCancel(m.ctx)
 	ti := &taskItem{
 		ctx:    tCtx,
@@ -243,16 +343,6 @@ func (m *Manager) runTask(t *model.Task) {
 	}
 	m.taskMap[t.ID] = ti
 
-	// set task state to running
-	t.Updated = time.Now().Unix()
-	t.State = model.TaskRunning
-
-	err := model.UpdateTask(m.db, t)
-	if err != nil {
-		// update task failed, try start again in next round
-		return
-	}
-
 	log.Debugf("task scheduled to run: %v", t.LogData())
 
 	m.wg.Add(1)
@@ -306,13 +396,17 @@ func (m *Manager) cleanupTask(t *taskItem) {
 		t.task.State = model.TaskSuccess
 	}
 
-	err := model.UpdateTask(m.db, t.task)
+	ok, err := model.FinishTask(m.db, t.task, m.instance)
 	if err != nil {
-		return
+		// stop renewing the lease, the task is aborted after the lease expires
+		log.WithError(err).Warningf("finish task failed: %v", t.task.LogData())
+	} else if ok {
+		log.Debugf("task cleanup: %v", t.task.LogData())
+	} else {
+		// the result is dropped, the task is aborted by another replica
+		log.Infof("task lease lost: %v", t.task.LogData())
 	}
 
-	log.Debugf("task cleanup: %v", t.task.LogData())
-
 	// trigger wait
 	if waits, ok := m.waitMap[t.task.ID]; ok {
 		// trigger waits
@@ -0,0 +1,158 @@
+package task
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	_ "github.com/mattn/go-sqlite3"
+	. "github.com/smartystreets/goconvey/convey"
+	gorp "gopkg.in/gorp.v2"
+
+	"sqlit/src/cmd/sqlit-proxy/config"
+	"sqlit/src/cmd/sqlit-proxy/model"
+)
+
+func newTestDB() (db *gorp.DbMap) {
+	raw, err := sql.Open("sqlite3", ":memory:")
+	So(err, ShouldBeNil)
+	// the memory database lives as long as the connection
+	raw.SetMaxOpenConns(1)
+	db = &gorp.DbMap{Db: raw, Dialect: gorp.SqliteDialect{}}
+	model.AddTables(db)
+	So(db.CreateTablesIfNotExists(), ShouldBeNil)
+	So(model.MigrateTables(db), ShouldBeNil)
+	return
+}
+
+// newTestManager starts a manager of the replica sharing db, the tasks block until released.
+func newTestManager(db *gorp.DbMap, instance string, release chan struct{}) (m *Manager) {
+	m = NewManager(&config.Config{Replica: &config.ReplicaConfig{
+		InstanceID: instance,
+		TaskLease:  config.MinTaskLease,
+	}}, db)
+	m.poll = 100 * time.Millisecond
+	m.Register(model.TaskCreateDB, func(
+		ctx context.Context, _ *config.Config, _ *gorp.DbMap, _ *model.Task) (r gin.H, err error) {
+		select {
+		case <-release:
+			return gin.H{"instance": instance}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	})
+	m.Start()
+	return
+}
+
+func getTask(db *gorp.DbMap, id int64) (t *model.Task) {
+	t, err := model.GetTask(db, 1, id)
+	So(err, ShouldBeNil)
+	return
+}
+
+func waitTaskState(db *gorp.DbMap, id int64, state model.TaskState) (t *model.Task) {
+	for deadline := time.Now().Add(2 * minTaskLease); time.Now().Before(deadline); {
+		if t = getTask(db, id); t.State == state {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	So(t.State, ShouldEqual, state)
+	return
+}
+
+func TestNewManager(t *testing.T) {
+	Convey("Given the replica configs", t, func() {
+		Convey("The lease should be the default one if not set", func() {
+			So(NewManager(&config.Config{}, nil).lease, ShouldEqual, DefaultTaskLease)
+			So(NewManager(&config.Config{Replica: &config.ReplicaConfig{}}, nil).lease,
+				ShouldEqual, DefaultTaskLease)
+		})
+		Convey("The lease should not be shorter than the min lease", func() {
+			for _, lease := range []time.Duration{1, 2 * time.Second} {
+				m := NewManager(&config.Config{Replica: &config.ReplicaConfig{TaskLease: lease}}, nil)
+				So(m.lease, ShouldEqual, minTaskLease)
+			}
+			m := NewManager(&config.Config{Replica: &config.ReplicaConfig{TaskLease: time.Minute}}, nil)
+			So(m.lease, ShouldEqual, time.Minute)
+		})
+	})
+}
+
+func TestManagerLease(t *testing.T) {
+	Convey("Given the managers of two replicas sharing the storage", t, func() {
+		var (
+			db      = newTestDB()
+			release = make(chan struct{})
+			m1      = newTestManager(db, "m1", release)
+			m2      = newTestManager(db, "m2", release)
+		)
+
+		Reset(func() {
+			m1.Stop()
+			m2.Stop()
+			_ = db.Db.Close()
+		})
+
+		Convey("The lease of the running task should be renewed until it's finished", func() {
+			id, err := m1.New(model.TaskCreateDB, 1, 0, nil)
+			So(err, ShouldBeNil)
+			task := waitTaskState(db, id, model.TaskRunning)
+			So(task.Owner, ShouldEqual, "m1")
+
+			// run longer than the lease, the task is not aborted by m2
+			time.Sleep(minTaskLease + time.Second)
+			renewed := getTask(db, id)
+			So(renewed.State, ShouldEqual, model.TaskRunning)
+			So(renewed.Owner, ShouldEqual, "m1")
+			So(renewed.LeaseExpire, ShouldBeGreaterThan, task.LeaseExpire)
+			So(renewed.LeaseExpire, ShouldBeGreaterThan, time.Now().Unix())
+
+			close(release)
+			ctx, cancel := context.WithTimeout(context.Background(), minTaskLease)
+			defer cancel()
+			So(m1.Wait(ctx, id), ShouldBeNil)
+			task = getTask(db, id)
+			So(task.State, ShouldEqual, model.TaskSuccess)
+			So(task.Result["instance"], ShouldEqual, "m1")
+		})
+		Convey("The tasks of the gone replica should be aborted or run by another replica", func() {
+			now := time.Now().Unix()
+
+			// the task run by the gone replica whose lease expires
+			expired, err := model.NewTask(db, model.TaskCreateDB, 1, 0, nil)
+			So(err, ShouldBeNil)
+			ok, err := model.ClaimTask(db, expired, "gone", now-1)
+			So(err, ShouldBeNil)
+			So(ok, ShouldBeTrue)
+
+			// the task created by the gone replica before running it
+			waiting, err := model.NewTask(db, model.TaskCreateDB, 1, 0, nil)
+			So(err, ShouldBeNil)
+
+			task := waitTaskState(db, expired.ID, model.TaskFailed)
+			So(task.Result["error"], ShouldEqual, "killed, lease of gone expired")
+
+			task = waitTaskState(db, waiting.ID, model.TaskRunning)
+			So(task.Owner, ShouldBeIn, []string{"m1", "m2"})
+
+			close(release)
+			task = waitTaskState(db, waiting.ID, model.TaskSuccess)
+			So(task.Result["instance"], ShouldEqual, task.Owner)
+		})
+		Convey("The task killed by another replica should be canceled on renewing the lease", func() {
+			id, err := m1.New(model.TaskCreateDB, 1, 0, nil)
+			So(err, ShouldBeNil)
+			waitTaskState(db, id, model.TaskRunning)
+
+			m2.Kill(id)
+			task := waitTaskState(db, id, model.TaskFailed)
+			So(task.Owner, ShouldEqual, "m1")
+			So(task.Killed, ShouldBeTrue)
+			So(task.Result["error"], ShouldContainSubstring, context.Canceled.Error())
+		})
+	})
+}
@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	rpc "sqlit/src/rpc/mux"
+	"sqlit/src/worker"
+)
+
+var backupMiner string // miner node id to verify the backup
+
+// CmdBackup is sqlit backup command.
+var CmdBackup = &Command{
+	UsageLine: "sqlit backup [common params] -miner node_id verify backup_location",
+	Short:     "verify a database backup",
+	Long: `
+Backup verify asks a miner of the database to restore the backup into a temporary directory,
+replay the tail of the SQLChain since the backup, and compare the state hashes with a live
+snapshot of the database. The backup location is a path on the miner which took the backup, or
+an object store location like s3://bucket/prefix/file.db3. The database admin permission is
+required.
+e.g.
+    sqlit backup -miner 000005aa62048f85da4ae9698ed59c14ec0d48a88a07c15a32265634e7e64ade \
+            verify s3://backups/db/4119ef997dedc585bfbcfae00ab6b87b8486fab323a8e107ea1fd4fc4f7eba5c-120-4096-20260101T000000Z.db3
+
+The exit status is 1 if the backup doesn't match the database.
+`,
+	Flag:       flag.NewFlagSet("Backup params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+func init() {
+	CmdBackup.Run = runBackup
+
+	addCommonFlags(CmdBackup)
+	addConfigFlag(CmdBackup)
+	CmdBackup.Flag.StringVar(&backupMiner, "miner", "", "Miner node id to verify the backup")
+}
+
+func runBackup(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	if len(args) != 2 || args[0] != "verify" || backupMiner == "" {
+		ConsoleLog.Error("backup command need a miner and verify backup_location as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+
+	configInit()
+
+	var (
+		req  = &worker.BackupVerifyReq{Location: args[1]}
+		resp = &worker.BackupVerifyResp{}
+	)
+	if err := rpc.NewCaller().CallNode(
+		proto.NodeID(backupMiner), route.DBSBackupVerify.String(), req, resp,
+	); err != nil {
+		ConsoleLog.WithError(err).Error("verify backup failed")
+		SetExitStatus(1)
+		return
+	}
+
+	r := &resp.Report
+	fmt.Printf("database:    %s\n", r.DatabaseID)
+	fmt.Printf("backup:      height %d, offset %d\n", r.Height, r.Offset)
+	fmt.Printf("live:        height %d, offset %d\n", r.LiveHeight, r.LiveOffset)
+	fmt.Printf("replayed:    %d blocks, %d queries\n", r.Blocks, r.Queries)
+	fmt.Printf("backup hash: %s\n", r.BackupHash.String())
+	fmt.Printf("live hash:   %s\n", r.LiveHash.String())
+	fmt.Printf("elapsed:     %s\n", r.Elapsed)
+	if !r.Verified {
+		ConsoleLog.Error("backup doesn't match the database")
+		SetExitStatus(1)
+		return
+	}
+	ConsoleLog.Info("backup verified")
+}
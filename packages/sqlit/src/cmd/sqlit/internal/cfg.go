@@ -123,13 +123,26 @@ func bgServerInit() {
 	if tmpPath == "" {
 		tmpPath = os.TempDir()
 	}
-	logPath := filepath.Join(tmpPath, "sqlit_service.log")
-	bgLog, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	var (
+		logPath = filepath.Join(tmpPath, "sqlit_service.log")
+		logInfo *conf.LogInfo
+	)
+	if conf.GConf != nil && conf.GConf.Log != nil {
+		logInfo = conf.GConf.Log
+		if logInfo.File != "" {
+			logPath = logInfo.File
+		}
+	}
+	bgLog, err := log.NewRotateWriter(logPath, logInfo.RotateConfig())
 	if err != nil {
 		ConsoleLog.Errorf("open log file failed: %s, %v", logPath, err)
 		SetExitStatus(1)
 		Exit()
 	}
+	// the log of the previous run is kept as a rotated file
+	if err = bgLog.Rotate(); err != nil {
+		ConsoleLog.WithError(err).Warning("rotate log file failed")
+	}
 
 	log.SetOutput(bgLog)
 	log.SetStringLevel(bgLogLevel, log.InfoLevel)
@@ -219,6 +219,8 @@ func usqlRegister() {
 	// register sqlit:// scheme to dburl
 	dburl.Register(dburl.Scheme{
 		Driver: "sqlit",
+		// the default short alias "sq" is taken by sqlite3
+		Aliases: []string{"sl"},
 		Generator: func(url *dburl.URL) (string, error) {
 			return url.String(), nil
 		},
@@ -299,6 +301,26 @@ func run(u *user.User) (err error) {
 	return nil
 }
 
+// consoleUser returns the user of the console, which is faked in docker.
+func consoleUser() (curUser *user.User, err error) {
+	if st, serr := os.Stat("/.dockerenv"); serr == nil && !st.IsDir() {
+		// in docker, fake user
+		var wd string
+		if wd, err = os.Getwd(); err != nil {
+			return
+		}
+		curUser = &user.User{
+			Uid:      "0",
+			Gid:      "0",
+			Username: "docker",
+			Name:     "docker",
+			HomeDir:  wd,
+		}
+		return
+	}
+	return user.Current()
+}
+
 // runConsole runs a console for sql operation in command line.
 func runConsole(cmd *Command, args []string) {
 	configFile = utils.HomeDirExpand(configFile)
@@ -367,27 +389,10 @@ func runConsole(cmd *Command, args []string) {
 		curUser   *user.User
 		available = drivers.Available()
 	)
-	if st, err := os.Stat("/.dockerenv"); err == nil && !st.IsDir() {
-		// in docker, fake user
-		var wd string
-		if wd, err = os.Getwd(); err != nil {
-			ConsoleLog.WithError(err).Error("get working directory failed")
-			SetExitStatus(1)
-			return
-		}
-		curUser = &user.User{
-			Uid:      "0",
-			Gid:      "0",
-			Username: "docker",
-			Name:     "docker",
-			HomeDir:  wd,
-		}
-	} else {
-		if curUser, err = user.Current(); err != nil {
-			ConsoleLog.WithError(err).Error("get current user failed")
-			SetExitStatus(1)
-			return
-		}
+	if curUser, err = consoleUser(); err != nil {
+		ConsoleLog.WithError(err).Error("get current user failed")
+		SetExitStatus(1)
+		return
 	}
 
 	if adapterAddr != "" {
@@ -67,6 +67,8 @@ func (l *List) Get() interface{} { return List(*l) }
 
 var targetMiners List
 var node32 uint
+var regions List
+var minRegions, minZones uint
 
 func addCreateFlags(cmd *Command) {
 	cmd.Flag.Var(&targetMiners, "db-target-miners", "List of target miner addresses(separated by ',')")
@@ -78,6 +80,14 @@ func addCreateFlags(cmd *Command) {
 	cmd.Flag.BoolVar(&meta.UseEventualConsistency, "db-eventual-consistency", false, "Use eventual consistency to sync among miner nodes")
 	cmd.Flag.Float64Var(&meta.ConsistencyLevel, "db-consistency-level", 0, "Consistency level, node*consistency_level is the node count to perform strong consistency")
 	cmd.Flag.IntVar(&meta.IsolationLevel, "db-isolation-level", 0, "Isolation level in a single node")
+	cmd.Flag.IntVar(&meta.MaxReaders, "db-max-readers", 0, "Max open read connections on each miner, 0 for unlimited")
+	cmd.Flag.Int64Var(&meta.BusyTimeout, "db-busy-timeout", 0, "Busy timeout in milliseconds, 0 for miner default")
+	cmd.Flag.IntVar(&meta.CacheSize, "db-cache-size", 0, "Page cache size, in pages if positive or in KiB if negative, 0 for miner default")
+	cmd.Flag.Int64Var(&meta.MMapSize, "db-mmap-size", 0, "Max bytes of memory-mapped I/O, 0 for miner default")
+	cmd.Flag.UintVar(&minRegions, "db-min-regions", 0, "Min distinct regions of the miners, 0 for none")
+	cmd.Flag.UintVar(&minZones, "db-min-zones", 0, "Min distinct zones of the miners, 0 for none")
+	cmd.Flag.Var(&regions, "db-regions", "List of regions which must include a miner(separated by ',')")
+	cmd.Flag.StringVar(&meta.Durability, "db-durability", "", "Durability level of the writes on miners: strict, normal or relaxed")
 }
 
 func runCreate(cmd *Command, args []string) {
@@ -107,6 +117,15 @@ func runCreate(cmd *Command, args []string) {
 	}
 	meta.Node = uint16(node32)
 
+	if minRegions > math.MaxUint16 || minZones > math.MaxUint16 {
+		ConsoleLog.Error("create min-regions and min-zones params should not greater than uint16")
+		SetExitStatus(1)
+		return
+	}
+	meta.MinRegions = uint16(minRegions)
+	meta.MinZones = uint16(minZones)
+	meta.Regions = regions.Values
+
 	if len(args) == 1 && args[0] != "" {
 		// fill the meta with params
 		if err := json.Unmarshal([]byte(args[0]), &meta); err != nil {
@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"flag"
+	"io"
+	"os/user"
+
+	"github.com/xo/usql/rline"
+
+	"sqlit/src/client"
+)
+
+// CmdDev is sqlit dev command entity.
+var CmdDev = &Command{
+	UsageLine: "sqlit dev [common params] [-command sqlcommand] [-out outputfile] [-no-rc true/false] [-single-transaction] [-variable variables] [file]",
+	Short:     "run a console on a local-only development database",
+	Long: `
+Dev runs an interactive SQL console on a local SQLite file, the queries are checked by the
+same sanitizer as the miners, but no block producer or miner, config or private key is needed.
+The file is created if not exists, default is ./sqlit-dev.db3.
+e.g.
+    sqlit dev ./app.db3
+
+Applications may use the same development database with the dsn of the sqlit driver:
+    sqlit://dev?dev=./app.db3
+`,
+	Flag:       flag.NewFlagSet("Dev params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+// DefaultDevFile is the default file of the development database.
+const DefaultDevFile = "./sqlit-dev.db3"
+
+func init() {
+	CmdDev.Run = runDev
+
+	addCommonFlags(CmdDev)
+	CmdDev.Flag.Var(&variables, "variable", "Set variable")
+	CmdDev.Flag.StringVar(&outFile, "out", "", "Record stdout to file")
+	CmdDev.Flag.BoolVar(&noRC, "no-rc", false, "Do not read start up file")
+	CmdDev.Flag.BoolVar(&singleTransaction, "single-transaction", false, "Execute as a single transaction (if non-interactive)")
+	CmdDev.Flag.StringVar(&command, "command", "", "Run only single command (SQL or usql internal command) and exit")
+}
+
+// runDev runs a console on a development database in command line.
+func runDev(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	var file = DefaultDevFile
+	switch len(args) {
+	case 0:
+	case 1:
+		file = args[0]
+	default:
+		ConsoleLog.Error("dev command takes at most one database file as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+	dsn = client.DevDSN(file)
+
+	usqlRegister()
+
+	var (
+		curUser *user.User
+		err     error
+	)
+	if curUser, err = consoleUser(); err != nil {
+		ConsoleLog.WithError(err).Error("get current user failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if err = run(curUser); err != nil && err != io.EOF && err != rline.ErrInterrupt {
+		ConsoleLog.WithError(err).Error("run cli error")
+		SetExitStatus(1)
+	}
+}
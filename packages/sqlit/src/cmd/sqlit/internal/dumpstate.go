@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"sqlit/src/diag"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	rpc "sqlit/src/rpc/mux"
+)
+
+const dumpStateCommand = "dump-state"
+
+// runDumpState saves the diagnostic bundle of the rpc endpoint node to the file of args, or to
+// sqlit-<node>-<time>.tar by default.
+func runDumpState(args []string) {
+	if rpcEndpoint == "" {
+		ConsoleLog.Error("rpc endpoint is required to dump state")
+		SetExitStatus(1)
+		return
+	}
+	var (
+		nodeID = proto.NodeID(rpcEndpoint)
+		short  = string(nodeID)
+		file   string
+		req    = &diag.DumpStateReq{}
+		resp   = &diag.DumpStateResp{}
+	)
+	if len(short) > 16 {
+		short = short[:16]
+	}
+	file = fmt.Sprintf("sqlit-%s-%s.tar", short, time.Now().UTC().Format("20060102T150405Z"))
+	if len(args) > 0 && args[0] != "" {
+		file = args[0]
+	}
+	if err := rpc.NewCaller().CallNode(nodeID, route.DiagDumpState.String(), req, resp); err != nil {
+		ConsoleLog.WithError(err).Error("dump state failed")
+		SetExitStatus(1)
+		return
+	}
+	if err := os.WriteFile(file, resp.Bundle, 0600); err != nil {
+		ConsoleLog.WithError(err).Error("write bundle failed")
+		SetExitStatus(1)
+		return
+	}
+	fmt.Printf("Diagnostic bundle of node %s saved to %s (%d bytes)\n", nodeID, file, len(resp.Bundle))
+}
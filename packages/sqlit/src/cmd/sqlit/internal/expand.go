@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"flag"
+	"math"
+	"strings"
+
+	"sqlit/src/client"
+	"sqlit/src/proto"
+)
+
+var (
+	expandDSN    string
+	expandNode   uint
+	expandMiners List
+)
+
+// CmdExpand is sqlit expand command entity.
+var CmdExpand = &Command{
+	UsageLine: "sqlit expand [common params] [-wait-tx-confirm] [-dsn dsn] [-node count] [-miners wallet,...]",
+	Short:     "add miners to specific sqlchain",
+	Long: `
+Expand adds more miners to the target dsn, which are matched from the registered miners with the
+database meta, the target miners are preferred. The new miners sync the database from the blocks
+of the existing miners and serve queries once they join the peers.
+e.g.
+    sqlit expand -wait-tx-confirm -dsn="sqlit://xxxx" -node 2
+    sqlit expand -dsn="sqlit://xxxx" -node 1 -miners=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40
+`,
+	Flag:       flag.NewFlagSet("Expand params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+func init() {
+	CmdExpand.Run = runExpand
+
+	addCommonFlags(CmdExpand)
+	addConfigFlag(CmdExpand)
+	addWaitFlag(CmdExpand)
+	CmdExpand.Flag.StringVar(&expandDSN, "dsn", "", "Target database dsn.")
+	CmdExpand.Flag.UintVar(&expandNode, "node", 0, "Count of the miners to add.")
+	CmdExpand.Flag.Var(&expandMiners, "miners", "List of target miner addresses(separated by ',')")
+}
+
+func runExpand(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	if len(args) > 0 || expandDSN == "" || expandNode == 0 {
+		ConsoleLog.Error("expand command need dsn and node count as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+	if expandNode > math.MaxUint16 {
+		ConsoleLog.Error("expand node param should not greater than uint16")
+		SetExitStatus(1)
+		return
+	}
+
+	if !strings.HasPrefix(expandDSN, client.DBScheme) && !strings.HasPrefix(expandDSN, client.DBSchemeAlias) {
+		ConsoleLog.Error("expand failed: invalid dsn provided, use address start with 'sqlit://'")
+		SetExitStatus(1)
+		return
+	}
+	expandDSN = strings.TrimPrefix(expandDSN, client.DBScheme+"://")
+	expandDSN = strings.TrimPrefix(expandDSN, client.DBSchemeAlias+"://")
+
+	targetChain, err := parseAccountAddress(expandDSN)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("target dsn address is not valid")
+		SetExitStatus(1)
+		return
+	}
+	var miners []proto.AccountAddress
+	for _, v := range expandMiners.Values {
+		miner, err := parseAccountAddress(v)
+		if err != nil {
+			ConsoleLog.WithError(err).Error("target miner address is not valid: ", v)
+			SetExitStatus(1)
+			return
+		}
+		miners = append(miners, miner)
+	}
+
+	configInit()
+
+	txHash, err := client.ExpandDatabase(targetChain, uint16(expandNode), miners)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("expand database failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if waitTxConfirmation {
+		err = wait(txHash)
+		if err != nil {
+			ConsoleLog.WithError(err).Error("expand database failed")
+			SetExitStatus(1)
+			return
+		}
+	}
+
+	ConsoleLog.Info("succeed in adding the miners on target database")
+}
@@ -22,9 +22,26 @@ var CmdExplorer = &Command{
 	UsageLine: "sqlit explorer [common params] [-tmp-path path] [-bg-log-level level] listen_address",
 	Short:     "start a SQLChain explorer server",
 	Long: `
-Explorer serves a SQLChain web explorer.
+Explorer serves a SQLChain web explorer and the JSON API of the observed databases.
 e.g.
     sqlit explorer 127.0.0.1:8546
+
+The paginated API (page, size <= 100) is served under /apiproxy.sqlit/v4:
+    GET /dbs                       observed databases and their heads
+    GET /dbs/{db}                  database profile from the block producers
+    GET /dbs/{db}/blocks           blocks, filtered by from and to heights
+    GET /dbs/{db}/transactions     query history, filtered by type (read or write), node,
+                                   account, sql text, since and until (unix milliseconds)
+    GET /dbs/{db}/accounts         query statistics of the accounts
+    GET /dbs/{db}/changes          row changes (table, op, pk, before and after) after cursor,
+                                   at most limit (<= 1000), waits up to wait (<= 60) seconds
+    GET /dbs/{db}/history          changes of a table, or a row of the pk json, after cursor
+                                   with the signer account, time, sql and block of each change
+    GET /dbs/{db}/tail             websocket live tail of the committed queries, filtered by
+                                   type (read or write) and account, with the payloads redacted
+                                   by redact (none, args or all)
+    GET /search?q=term[&db=id]     blocks, acks, queries and accounts of a hash, databases of
+                                   the id, or queries containing the sql text
 `,
 	Flag:       flag.NewFlagSet("Explorer params", flag.ExitOnError),
 	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
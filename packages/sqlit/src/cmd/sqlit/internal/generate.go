@@ -7,6 +7,7 @@ import (
 	"encoding/hex"
 	"flag"
 	"fmt"
+	"net"
 	"os"
 	"path"
 	"path/filepath"
@@ -146,15 +147,13 @@ func runGenerate(cmd *Command, args []string) {
 		}
 	}
 
-	var port string
+	var host, port string
 	if minerListenAddr != "" {
-		minerListenAddrSplit := strings.Split(minerListenAddr, ":")
-		if len(minerListenAddrSplit) != 2 {
-			ConsoleLog.Error("-miner only accepts listen address in ip:port format. e.g. 127.0.0.1:7458")
+		if host, port, err = net.SplitHostPort(minerListenAddr); err != nil {
+			ConsoleLog.Error("-miner only accepts listen address in ip:port format. e.g. 127.0.0.1:7458 or [::1]:7458")
 			SetExitStatus(1)
 			return
 		}
-		port = minerListenAddrSplit[1]
 	}
 
 	var rawConfig *conf.Config
@@ -166,7 +165,12 @@ func runGenerate(cmd *Command, args []string) {
 		rawConfig = testnet.GetTestNetConfig()
 		if minerListenAddr != "" {
 			testnet.SetMinerConfig(rawConfig)
-			rawConfig.ListenAddr = "0.0.0.0:" + port
+			// listen on all the addresses of the family of the miner address, [::] is dual-stack
+			if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+				rawConfig.ListenAddr = net.JoinHostPort("::", port)
+			} else {
+				rawConfig.ListenAddr = net.JoinHostPort("0.0.0.0", port)
+			}
 		}
 
 		if testnetRegion == testnetW {
@@ -5,6 +5,7 @@ package internal
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"strings"
 
 	"sqlit/src/client"
@@ -17,17 +18,31 @@ var (
 	toUser string
 	toDSN  string
 	perm   string
+	role   string
+
+	grantDryRun bool
 )
 
 // CmdGrant is sqlit grant command entity.
 var CmdGrant = &Command{
-	UsageLine: "sqlit grant [common params] [-wait-tx-confirm] [-to-user wallet] [-to-dsn dsn] [-perm perm_struct]",
+	UsageLine: "sqlit grant [common params] [-wait-tx-confirm] [-dry-run] [-to-user wallet] [-to-dsn dsn] [-perm perm_struct | -role role]",
 	Short:     "grant a user's permissions on specific sqlchain",
 	Long: `
 Grant grants specific permissions for the target user on target dsn.
 e.g.
     sqlit grant -to-user=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40 -to-dsn="sqlit://xxxx" -perm perm_struct
 
+The -role flag grants a predefined role instead of the permission struct, one of readonly,
+readwrite and admin, or void to revoke the permissions. The readonly role is refused to write or
+change the schema by the miners, which is the common grant for the analytics credentials.
+e.g.
+    sqlit grant -to-user=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40 -to-dsn="sqlit://xxxx" -role readonly
+
+The -dry-run flag previews the resulting permission changes of the database and the warnings,
+such as revoking an admin or all the permissions of a user, without sending the transaction.
+e.g.
+    sqlit grant -dry-run -to-user=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40 -to-dsn="sqlit://xxxx" -role void
+
 Since SQLIT is built on top of blockchains, you may want to wait for the transaction
 confirmation before the permission takes effect.
 e.g.
@@ -47,6 +62,8 @@ func init() {
 	CmdGrant.Flag.StringVar(&toUser, "to-user", "", "Target address of an user account to grant permission.")
 	CmdGrant.Flag.StringVar(&toDSN, "to-dsn", "", "Target database dsn to grant permission.")
 	CmdGrant.Flag.StringVar(&perm, "perm", "", "Permission type struct for grant.")
+	CmdGrant.Flag.StringVar(&role, "role", "", "Predefined role for grant: readonly, readwrite, admin or void.")
+	CmdGrant.Flag.BoolVar(&grantDryRun, "dry-run", false, "Preview the permission changes without sending the transaction.")
 }
 
 type userPermPayload struct {
@@ -60,8 +77,8 @@ type userPermPayload struct {
 func runGrant(cmd *Command, args []string) {
 	commonFlagsInit(cmd)
 
-	if len(args) > 0 || toUser == "" || toDSN == "" || perm == "" {
-		ConsoleLog.Error("grant command need to-user, to-dsn address and permission struct as param")
+	if len(args) > 0 || toUser == "" || toDSN == "" || (perm == "") == (role == "") {
+		ConsoleLog.Error("grant command need to-user, to-dsn address and either permission struct or role as param")
 		SetExitStatus(1)
 		printCommandHelp(cmd)
 		Exit()
@@ -93,7 +110,14 @@ func runGrant(cmd *Command, args []string) {
 
 	var permPayload userPermPayload
 
-	if err := json.Unmarshal([]byte(perm), &permPayload); err != nil {
+	if role != "" {
+		var ok bool
+		if permPayload.Role, ok = types.ParseUserPermissionRole(role); !ok {
+			ConsoleLog.Errorf("update permission failed: invalid role %s", role)
+			SetExitStatus(1)
+			return
+		}
+	} else if err := json.Unmarshal([]byte(perm), &permPayload); err != nil {
 		// try again using role string representation
 		permPayload.Role.FromString(perm)
 		// invalid struct will set to void
@@ -117,6 +141,11 @@ func runGrant(cmd *Command, args []string) {
 
 	configInit()
 
+	if grantDryRun {
+		previewGrant(targetUser, targetChain, p)
+		return
+	}
+
 	txHash, err := client.UpdatePermission(targetUser, targetChain, p)
 	if err != nil {
 		ConsoleLog.WithError(err).Error("update permission failed")
@@ -135,3 +164,39 @@ func runGrant(cmd *Command, args []string) {
 
 	ConsoleLog.Info("succeed in grant permission on target database")
 }
+
+func previewGrant(targetUser, targetChain proto.AccountAddress, p *types.UserPermission) {
+	resp, err := client.PreviewUpdatePermission(targetUser, targetChain, p)
+	if err != nil {
+		if strings.Contains(err.Error(), "can't find method") {
+			// old version block producer
+			ConsoleLog.WithError(err).Warning("preview permission is not supported in old version block producer")
+			return
+		}
+
+		ConsoleLog.WithError(err).Error("preview permission failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if len(resp.Changes) == 0 {
+		fmt.Println("No permission changes.")
+	} else {
+		fmt.Printf("Permission changes:\n\n")
+		fmt.Printf("%-64s\tBefore\tAfter\n", "User")
+		for _, c := range resp.Changes {
+			var before = "-"
+			if c.Before != nil {
+				before = c.Before.Role.String()
+			}
+			fmt.Printf("%s\t%s\t%s\n", c.Addr, before, c.After.Role.String())
+		}
+	}
+
+	if len(resp.Warnings) > 0 {
+		fmt.Printf("\nWarnings:\n\n")
+		for _, w := range resp.Warnings {
+			fmt.Printf("  %s\n", w)
+		}
+	}
+}
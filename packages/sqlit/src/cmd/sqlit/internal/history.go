@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/client"
+	"sqlit/src/proto"
+	"sqlit/src/sqlchain/observer"
+)
+
+const historyAPI = "/apiproxy.sqlit/v4/dbs/%s/history"
+
+var (
+	historyExplorer string
+	historyTable    string
+	historyPK       string
+	historyLimit    int
+	historyJSON     bool
+)
+
+// CmdHistory is sqlit history command.
+var CmdHistory = &Command{
+	UsageLine: "sqlit history [common params] [-explorer url] -table table [-pk json] [-limit count] [-json] dsn",
+	Short:     "list the modification history of a table or a row",
+	Long: `
+History lists who changed a table or a row, when, with what SQL and in which block, as derived
+from the SQLChain blocks by an explorer observing the database.
+e.g.
+    sqlit history -explorer http://127.0.0.1:8546 -table users sqlit://4119ef997dedc585bfbcfae00ab6b87b8486fab323a8e107ea1fd4fc4f7eba5c
+
+The -pk flag filters the changes of a row by the JSON object of its primary key in the key
+column order, or {"rowid": n} for a table without the primary key.
+e.g.
+    sqlit history -table users -pk '{"id": 1}' -json sqlit://4119ef997dedc585bfbcfae00ab6b87b8486fab323a8e107ea1fd4fc4f7eba5c
+`,
+	Flag:       flag.NewFlagSet("History params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+func init() {
+	CmdHistory.Run = runHistory
+
+	addCommonFlags(CmdHistory)
+	CmdHistory.Flag.StringVar(&historyExplorer, "explorer", "http://127.0.0.1:8546",
+		"Address of the explorer observing the database")
+	CmdHistory.Flag.StringVar(&historyTable, "table", "", "Table to list the history of")
+	CmdHistory.Flag.StringVar(&historyPK, "pk", "", "JSON object of the primary key of the row")
+	CmdHistory.Flag.IntVar(&historyLimit, "limit", 0, "Max count of the changes, 0 for all")
+	CmdHistory.Flag.BoolVar(&historyJSON, "json", false, "Print the changes as JSON lines")
+}
+
+type historyResp struct {
+	Status  string `json:"status"`
+	Success bool   `json:"success"`
+	Data    struct {
+		History []*observer.HistoryRecord `json:"history"`
+		Cursor  int64                     `json:"cursor"`
+	} `json:"data"`
+}
+
+func fetchHistory(dbID proto.DatabaseID, cursor int64) (
+	records []*observer.HistoryRecord, next int64, err error,
+) {
+	var (
+		params = url.Values{}
+		resp   *http.Response
+		result historyResp
+	)
+	params.Set("table", historyTable)
+	params.Set("cursor", strconv.FormatInt(cursor, 10))
+	if historyPK != "" {
+		params.Set("pk", historyPK)
+	}
+	u := strings.TrimSuffix(historyExplorer, "/") +
+		fmt.Sprintf(historyAPI, url.PathEscape(string(dbID))) + "?" + params.Encode()
+	if resp, err = http.Get(u); err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if err = json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		err = errors.Wrapf(err, "decode history response of status %d", resp.StatusCode)
+		return
+	}
+	if !result.Success {
+		err = errors.Errorf("fetch history failed: %s", result.Status)
+		return
+	}
+	return result.Data.History, result.Data.Cursor, nil
+}
+
+func runHistory(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	if len(args) != 1 || historyTable == "" {
+		ConsoleLog.Error("history command need a table and a dsn as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+
+	cfg, err := client.ParseDSN(args[0])
+	if err != nil {
+		ConsoleLog.WithField("db", args[0]).WithError(err).Error("not a valid dsn")
+		SetExitStatus(1)
+		return
+	}
+	dbID := proto.DatabaseID(cfg.DatabaseID)
+
+	var (
+		w      = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		enc    = json.NewEncoder(os.Stdout)
+		cursor int64
+		total  int
+	)
+	if !historyJSON {
+		fmt.Fprintln(w, "HEIGHT\tBLOCK\tTIME\tACCOUNT\tOP\tPK\tSQL")
+	}
+	defer func() { _ = w.Flush() }()
+	for historyLimit <= 0 || total < historyLimit {
+		records, next, err := fetchHistory(dbID, cursor)
+		if err != nil {
+			ConsoleLog.WithField("db", dbID).WithError(err).Error("list history failed")
+			SetExitStatus(1)
+			return
+		}
+		if len(records) == 0 {
+			return
+		}
+		for _, r := range records {
+			if historyLimit > 0 && total >= historyLimit {
+				return
+			}
+			total++
+			if historyJSON {
+				_ = enc.Encode(r)
+				continue
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", r.Height, r.Block,
+				r.Timestamp.Format(time.RFC3339), r.Account, r.Op, r.PK, r.SQL)
+		}
+		cursor = next
+	}
+}
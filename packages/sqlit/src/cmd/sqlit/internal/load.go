@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"sqlit/src/client"
+	"sqlit/src/types"
+)
+
+var loadTargetDSN string
+
+// CmdLoad is sqlit load command entity.
+var CmdLoad = &Command{
+	UsageLine: "sqlit load [common params] [-dsn dsn]",
+	Short:     "show the load of specific database",
+	Long: `
+Load shows the load of the target dsn reported by its miners to the block producers, including the
+QPS, the p95 query latency, the cache hit rate and the storage growth, for each miner and in total.
+e.g.
+    sqlit load -dsn="sqlit://xxxx"
+`,
+	Flag:       flag.NewFlagSet("Load params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+func init() {
+	CmdLoad.Run = runLoad
+
+	addCommonFlags(CmdLoad)
+	addConfigFlag(CmdLoad)
+	CmdLoad.Flag.StringVar(&loadTargetDSN, "dsn", "", "Target database dsn.")
+}
+
+func printLoad(name string, l *types.DatabaseLoad) {
+	fmt.Printf("%-64s\t%8.2f\t%10s\t%6.1f%%\t%12d\t%12.0f\n",
+		name, l.QPS(), l.P95Latency.Round(time.Microsecond), l.CacheHitRate()*100,
+		l.StorageBytes, l.StorageGrowthPerDay())
+}
+
+func runLoad(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	if len(args) > 0 || loadTargetDSN == "" {
+		ConsoleLog.Error("load command need dsn as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+
+	if !strings.HasPrefix(loadTargetDSN, client.DBScheme) && !strings.HasPrefix(loadTargetDSN, client.DBSchemeAlias) {
+		ConsoleLog.Error("load failed: invalid dsn provided, use address start with 'sqlit://'")
+		SetExitStatus(1)
+		return
+	}
+
+	configInit()
+
+	resp, err := client.QueryDatabaseLoad(loadTargetDSN)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("query database load failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if len(resp.Miners) == 0 {
+		fmt.Println("Found no load reported by the miners.")
+		return
+	}
+
+	fmt.Printf("%-64s\t%8s\t%10s\t%7s\t%12s\t%12s\n",
+		"Miner", "QPS", "P95", "Cache", "Storage", "Growth/Day")
+	for _, l := range resp.Miners {
+		printLoad(string(l.NodeID), l)
+	}
+	printLoad("Total", resp.Total)
+}
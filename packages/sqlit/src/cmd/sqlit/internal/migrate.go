@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/utils"
+)
+
+// CmdMigrateConfig is sqlit migrate-config command entity.
+var CmdMigrateConfig = &Command{
+	UsageLine: "sqlit migrate-config [common params] [-legacy-config legacy_config_file] [dest_path]",
+	Short:     "migrate a legacy CovenantSQL config and private key",
+	Long: `
+Migrate-config converts a legacy CovenantSQL config.yaml to a config of this version, and
+re-encodes the private key and copies the public keystore of the legacy deployment to dest_path.
+The keys which are no longer supported are dropped and reported. The private key keeps its
+passphrase, set -with-password if it's encrypted by one.
+e.g.
+    sqlit migrate-config -legacy-config ~/.cql/config.yaml ~/.sqlit
+`,
+	Flag:       flag.NewFlagSet("Migrate-config params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+var legacyConfigFile string
+
+func init() {
+	CmdMigrateConfig.Run = runMigrateConfig
+	CmdMigrateConfig.Flag.StringVar(&legacyConfigFile, "legacy-config", "~/.cql/config.yaml",
+		"Legacy CovenantSQL config file to migrate")
+
+	addCommonFlags(CmdMigrateConfig)
+}
+
+// legacyPath returns the path of file p of the legacy config in dir, or def if p is empty.
+func legacyPath(dir, p, def string) string {
+	if p == "" {
+		p = def
+	}
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+func runMigrateConfig(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	var (
+		legacyFile  = utils.HomeDirExpand(legacyConfigFile)
+		legacyDir   = filepath.Dir(legacyFile)
+		workingRoot = utils.HomeDirExpand("~/.sqlit")
+	)
+	if len(args) > 0 && args[0] != "" {
+		workingRoot = utils.HomeDirExpand(args[0])
+	}
+	if strings.HasSuffix(workingRoot, "config.yaml") {
+		workingRoot = filepath.Dir(workingRoot)
+	}
+
+	data, err := os.ReadFile(legacyFile)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("read legacy config failed")
+		SetExitStatus(1)
+		return
+	}
+	out, notes, err := conf.MigrateLegacyConfig(data)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("migrate legacy config failed")
+		SetExitStatus(1)
+		return
+	}
+	for _, v := range notes {
+		ConsoleLog.Warn(v)
+	}
+	var cfg = &conf.Config{}
+	if err = yaml.Unmarshal(out, cfg); err != nil {
+		ConsoleLog.WithError(err).Error("load migrated config failed")
+		SetExitStatus(1)
+		return
+	}
+
+	// load the legacy private key before writing anything
+	if password == "" {
+		fmt.Println("Please enter the passphrase of the legacy private key")
+		password = readMasterKey(!withPassword)
+	}
+	privateKey, err := kms.LoadPrivateKey(
+		legacyPath(legacyDir, cfg.PrivateKeyFile, "private.key"), []byte(password))
+	if err != nil {
+		ConsoleLog.WithError(err).Error("load legacy private key failed")
+		SetExitStatus(1)
+		return
+	}
+	if err = checkNodePublicKey(cfg, privateKey); err != nil {
+		ConsoleLog.WithError(err).Error("legacy private key mismatch")
+		SetExitStatus(1)
+		return
+	}
+
+	if err = os.MkdirAll(workingRoot, 0755); err != nil {
+		ConsoleLog.WithError(err).Error("unexpected error")
+		SetExitStatus(1)
+		return
+	}
+	var (
+		configFilePath = filepath.Join(workingRoot, "config.yaml")
+		privateKeyFile = legacyPath(workingRoot, cfg.PrivateKeyFile, "private.key")
+	)
+	askDeleteFile(configFilePath)
+	askDeleteFile(privateKeyFile)
+	if err = kms.SavePrivateKey(privateKeyFile, privateKey, []byte(password)); err != nil {
+		ConsoleLog.WithError(err).Error("save private key failed")
+		SetExitStatus(1)
+		return
+	}
+	// the public keystore and the dht file share the format of the legacy versions
+	for _, v := range []struct{ file, def string }{
+		{cfg.PubKeyStoreFile, "public.keystore"},
+		{cfg.DHTFileName, "dht.db"},
+	} {
+		var (
+			src = legacyPath(legacyDir, v.file, v.def)
+			dst = legacyPath(workingRoot, v.file, v.def)
+		)
+		if src == dst || !utils.Exist(src) {
+			continue
+		}
+		askDeleteFile(dst)
+		if _, err = utils.CopyFile(src, dst); err != nil {
+			ConsoleLog.WithError(err).Errorf("copy %s failed", src)
+			SetExitStatus(1)
+			return
+		}
+	}
+	if err = os.WriteFile(configFilePath, out, 0644); err != nil {
+		ConsoleLog.WithError(err).Error("write config failed")
+		SetExitStatus(1)
+		return
+	}
+
+	fmt.Printf("\nConfig file:      %s\n", configFilePath)
+	fmt.Printf("Private key file: %s\n", privateKeyFile)
+	if len(notes) > 0 {
+		fmt.Println("Review the warnings above before starting the node with the migrated config")
+	}
+}
+
+// checkNodePublicKey checks the private key against the public key of this node in the known
+// nodes of cfg, if any.
+func checkNodePublicKey(cfg *conf.Config, key *asymmetric.PrivateKey) error {
+	for _, node := range cfg.KnownNodes {
+		if node.ID != cfg.ThisNodeID || node.PublicKey == nil {
+			continue
+		}
+		if !node.PublicKey.IsEqual(key.PubKey()) {
+			return fmt.Errorf("private key doesn't match the public key of node %s", node.ID)
+		}
+	}
+	return nil
+}
@@ -41,7 +41,8 @@ var (
 
 // CmdRPC is sqlit rpc command entity.
 var CmdRPC = &Command{
-	UsageLine: "sqlit rpc [common params] [-wait-tx-confirm] [-endpoint rpc_endpoint | -bp] -name rpc_name -req rpc_request",
+	UsageLine: "sqlit rpc [common params] [-wait-tx-confirm] [-endpoint rpc_endpoint | -bp] -name rpc_name -req rpc_request\n" +
+		"       sqlit rpc [common params] [-endpoint rpc_endpoint | -bp] dump-state [bundle_file]",
 	Short:     "make a rpc request",
 	Long: `
 RPC makes a RPC request to the target endpoint.
@@ -49,6 +50,14 @@ e.g.
     sqlit rpc -name 'MCC.QuerySQLChainProfile' \
             -endpoint 000000fd2c8f68d54d55d97d0ad06c6c0d91104e4e51a7247f3629cc2a0127cf \
             -req '{"DBID": "c8328272ba9377acdf1ee8e73b17f2b0f7430c798141080d0282195507eb94e7"}'
+
+The dump-state sub command captures a diagnostic bundle of the endpoint node for support: the
+goroutine dump, metric snapshot, peer table, recent slow queries and chain heights in a tar file.
+It must be run with the config and private key of the endpoint node.
+e.g.
+    sqlit rpc -config ~/.sqlit/config.yaml \
+            -endpoint 000000fd2c8f68d54d55d97d0ad06c6c0d91104e4e51a7247f3629cc2a0127cf \
+            dump-state node.tar
 `,
 	Flag:       flag.NewFlagSet("RPC params", flag.ExitOnError),
 	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
@@ -56,7 +65,7 @@ e.g.
 }
 
 type canSign interface {
-	Sign(signer *asymmetric.PrivateKey) error
+	Sign(signer asymmetric.Signer) error
 }
 
 func init() {
@@ -80,6 +89,11 @@ func runRPC(cmd *Command, args []string) {
 		rpcEndpoint = string(conf.GConf.BP.NodeID)
 	}
 
+	if len(args) > 0 && args[0] == dumpStateCommand {
+		runDumpState(args[1:])
+		return
+	}
+
 	if rpcEndpoint == "" || rpcName == "" || rpcReq == "" {
 		// error
 		ConsoleLog.Error("rpc endpoint/name/request payload is required for rpc tool")
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"flag"
+	"strings"
+
+	"sqlit/src/client"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+)
+
+var (
+	standbyDSN    string
+	standbyMiner  string
+	replacedMiner string
+)
+
+// CmdStandby is sqlit standby command entity.
+var CmdStandby = &Command{
+	UsageLine: "sqlit standby [common params] [-wait-tx-confirm] [-dsn dsn] [-miner wallet] [-replace wallet]",
+	Short:     "add or promote a warm standby miner of specific sqlchain",
+	Long: `
+Standby adds a registered miner as a warm standby of the target dsn, which replicates the
+database continuously but doesn't serve queries.
+e.g.
+    sqlit standby -dsn="sqlit://xxxx" -miner=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40
+
+With the -replace param, the standby miner is promoted in place of the replaced miner instantly,
+e.g. on the loss of the replaced miner.
+e.g.
+    sqlit standby -wait-tx-confirm -dsn="sqlit://xxxx" -miner=43602c17adcc96acf2f68964830bb6ebfbca6834961c0eca0915fcc5270e0b40 -replace=xxxx
+`,
+	Flag:       flag.NewFlagSet("Standby params", flag.ExitOnError),
+	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
+	DebugFlag:  flag.NewFlagSet("Debug params", flag.ExitOnError),
+}
+
+func init() {
+	CmdStandby.Run = runStandby
+
+	addCommonFlags(CmdStandby)
+	addConfigFlag(CmdStandby)
+	addWaitFlag(CmdStandby)
+	CmdStandby.Flag.StringVar(&standbyDSN, "dsn", "", "Target database dsn.")
+	CmdStandby.Flag.StringVar(&standbyMiner, "miner", "", "Wallet address of the standby miner.")
+	CmdStandby.Flag.StringVar(&replacedMiner, "replace", "",
+		"Wallet address of the miner replaced by the standby miner.")
+}
+
+func parseAccountAddress(addr string) (a proto.AccountAddress, err error) {
+	h, err := hash.NewHashFromStr(addr)
+	if err != nil {
+		return
+	}
+	return proto.AccountAddress(*h), nil
+}
+
+func runStandby(cmd *Command, args []string) {
+	commonFlagsInit(cmd)
+
+	if len(args) > 0 || standbyDSN == "" || standbyMiner == "" {
+		ConsoleLog.Error("standby command need dsn and miner address as param")
+		SetExitStatus(1)
+		printCommandHelp(cmd)
+		Exit()
+	}
+
+	if !strings.HasPrefix(standbyDSN, client.DBScheme) && !strings.HasPrefix(standbyDSN, client.DBSchemeAlias) {
+		ConsoleLog.Error("standby failed: invalid dsn provided, use address start with 'sqlit://'")
+		SetExitStatus(1)
+		return
+	}
+	standbyDSN = strings.TrimPrefix(standbyDSN, client.DBScheme+"://")
+	standbyDSN = strings.TrimPrefix(standbyDSN, client.DBSchemeAlias+"://")
+
+	targetChain, err := parseAccountAddress(standbyDSN)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("target dsn address is not valid")
+		SetExitStatus(1)
+		return
+	}
+	standby, err := parseAccountAddress(standbyMiner)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("standby miner address is not valid")
+		SetExitStatus(1)
+		return
+	}
+	var miner proto.AccountAddress
+	if replacedMiner != "" {
+		if miner, err = parseAccountAddress(replacedMiner); err != nil {
+			ConsoleLog.WithError(err).Error("replaced miner address is not valid")
+			SetExitStatus(1)
+			return
+		}
+	}
+
+	configInit()
+
+	txHash, err := client.ReplaceMiner(targetChain, miner, standby)
+	if err != nil {
+		ConsoleLog.WithError(err).Error("replace miner failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if waitTxConfirmation {
+		err = wait(txHash)
+		if err != nil {
+			ConsoleLog.WithError(err).Error("replace miner failed")
+			SetExitStatus(1)
+			return
+		}
+	}
+
+	if replacedMiner != "" {
+		ConsoleLog.Info("succeed in promoting the standby miner on target database")
+	} else {
+		ConsoleLog.Info("succeed in adding the standby miner on target database")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"strings"
+	"time"
 
 	"sqlit/src/client"
 	"sqlit/src/conf"
@@ -19,13 +20,18 @@ import (
 )
 
 var (
-	databaseID string
+	databaseID  string
+	historyPage uint
+	historySize uint
 )
 
+const walletHistoryCommand = "history"
+
 // CmdWallet is sqlit wallet command entity.
 var CmdWallet = &Command{
-	UsageLine: "sqlit wallet [common params] [-dsn dsn]",
-	Short:     "get the wallet address and database info of current account",
+	UsageLine: "sqlit wallet [common params] [-dsn dsn]\n" +
+		"       sqlit wallet [common params] [-page page] [-size size] history",
+	Short: "get the wallet address and database info of current account",
 	Long: `
 Wallet gets the SQLIT wallet address and database information of the current account.
 Note: Token balances are now managed by the SqlitRegistry smart contract on Ethereum.
@@ -33,6 +39,11 @@ e.g.
     sqlit wallet
 
     sqlit wallet -dsn "sqlit://4119ef997dedc585bfbcfae00ab6b87b8486fab323a8e107ea1fd4fc4f7eba5c"
+
+The history sub command lists the transactions of the current account with their heights and
+states, newest first, and the pending ones which are not packed into a block yet.
+e.g.
+    sqlit wallet -page 2 history
 `,
 	Flag:       flag.NewFlagSet("Wallet params", flag.ExitOnError),
 	CommonFlag: flag.NewFlagSet("Common params", flag.ExitOnError),
@@ -46,6 +57,8 @@ func init() {
 	addConfigFlag(CmdWallet)
 
 	CmdWallet.Flag.StringVar(&databaseID, "dsn", "", "Show specified database info")
+	CmdWallet.Flag.UintVar(&historyPage, "page", 1, "Page of the transaction history")
+	CmdWallet.Flag.UintVar(&historySize, "size", 20, "Transactions per page of the history")
 }
 
 func showDatabaseInfo(dsn string) {
@@ -150,10 +163,75 @@ func showAllDatabaseInfo() {
 	fmt.Println("\nNote: Token balances and staking are managed by the SqlitRegistry smart contract.")
 }
 
+func showHistory() {
+	var (
+		req = &types.QueryAccountHistoryReq{
+			Page: uint32(historyPage),
+			Size: uint32(historySize),
+		}
+		resp   = &types.QueryAccountHistoryResp{}
+		pubKey *asymmetric.PublicKey
+		err    error
+	)
+
+	if pubKey, err = kms.GetLocalPublicKey(); err != nil {
+		ConsoleLog.WithError(err).Error("query account history failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if req.Addr, err = crypto.PubKeyHash(pubKey); err != nil {
+		ConsoleLog.WithError(err).Error("query account history failed")
+		SetExitStatus(1)
+		return
+	}
+
+	if err = mux.RequestBP(route.MCCQueryAccountHistory.String(), req, resp); err != nil {
+		if strings.Contains(err.Error(), "can't find method") {
+			// old version block producer
+			ConsoleLog.WithError(err).Warning("query account history is not supported in old version block producer")
+			return
+		}
+
+		ConsoleLog.WithError(err).Error("query account history failed")
+		SetExitStatus(1)
+		return
+	}
+
+	fmt.Printf("Next nonce: %d\n\n", resp.NextNonce)
+
+	if len(resp.Pending) > 0 {
+		fmt.Printf("Pending Transactions:\n\n")
+		fmt.Printf("%-64s\tType\tNonce\tState\n", "Hash")
+		for _, tx := range resp.Pending {
+			fmt.Printf("%s\t%s\t%d\t%s\n", tx.Hash, tx.Type, tx.Nonce, tx.State)
+		}
+		fmt.Println()
+	}
+
+	if resp.Total == 0 {
+		fmt.Println("Found no packed transactions.")
+		return
+	}
+
+	var pages = (resp.Total + resp.Size - 1) / resp.Size
+	fmt.Printf("Transactions (page %d of %d, %d in total):\n\n", resp.Page, pages, resp.Total)
+	fmt.Printf("Height\t%-64s\tType\tNonce\tState\tTime\n", "Hash")
+	for _, tx := range resp.Txs {
+		fmt.Printf("%d\t%s\t%s\t%d\t%s\t%s\n",
+			tx.Height, tx.Hash, tx.Type, tx.Nonce, tx.State, tx.Timestamp.Format(time.RFC3339))
+	}
+}
+
 func runWallet(cmd *Command, args []string) {
 	commonFlagsInit(cmd)
 	configInit()
 
+	if len(args) > 0 && args[0] == walletHistoryCommand {
+		showHistory()
+		return
+	}
+
 	fmt.Printf("\n\nWallet address: %s\n", conf.GConf.WalletAddress)
 	fmt.Println("\nNote: Token balances are managed by the SqlitRegistry smart contract on Ethereum.")
 	fmt.Println("Use the Jeju Network explorer or contract interface to check your token balance.")
@@ -17,13 +17,20 @@ var (
 func init() {
 	internal.SqlitCommands = []*internal.Command{
 		internal.CmdGenerate,
+		internal.CmdMigrateConfig,
 		internal.CmdWallet,
 		internal.CmdCreate,
 		internal.CmdConsole,
+		internal.CmdDev,
 		internal.CmdDrop,
 		internal.CmdGrant,
+		internal.CmdStandby,
+		internal.CmdExpand,
+		internal.CmdLoad,
 		internal.CmdMirror,
+		internal.CmdBackup,
 		internal.CmdExplorer,
+		internal.CmdHistory,
 		internal.CmdAdapter,
 		internal.CmdIDMiner,
 		internal.CmdRPC,
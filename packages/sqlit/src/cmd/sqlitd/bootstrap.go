@@ -13,8 +13,12 @@ import (
 	bp "sqlit/src/blockproducer"
 	"sqlit/src/conf"
 	"sqlit/src/crypto/kms"
+	"sqlit/src/diag"
+	"sqlit/src/jeju"
+	"sqlit/src/naconn"
 	"sqlit/src/proto"
 	"sqlit/src/route"
+	"sqlit/src/rpc/grpcgw"
 	rpc "sqlit/src/rpc/mux"
 	"sqlit/src/types"
 	"sqlit/src/utils"
@@ -48,6 +52,18 @@ func runNode(nodeID proto.NodeID, listenAddr string) (err error) {
 		return
 	}
 
+	// check the registry at startup instead of on the first lookup
+	if _, err = jeju.InitRegistryResolver(conf.GConf); err != nil {
+		log.WithError(err).Error("init registry resolver failed")
+		return
+	}
+
+	// init mutual tls of the node connections
+	if err = naconn.InitTransport(conf.GConf); err != nil {
+		log.WithError(err).Error("init mtls transport failed")
+		return
+	}
+
 	// init nodes
 	log.WithField("node", nodeID).Info("init peers")
 	_, peers, thisNode, err := initNodePeers(nodeID, conf.GConf.PubKeyStoreFile)
@@ -69,6 +85,12 @@ func runNode(nodeID proto.NodeID, listenAddr string) (err error) {
 		return
 	}
 
+	// register diagnostic service rpc
+	if err = server.RegisterService(route.DiagRPCName, diag.NewService()); err != nil {
+		log.WithError(err).Error("register diagnostic service failed")
+		return
+	}
+
 	// start server
 	go func() {
 		server.Serve()
@@ -124,6 +146,7 @@ func runNode(nodeID proto.NodeID, listenAddr string) (err error) {
 		Mode:           mode,
 		Genesis:        genesis,
 		DataFile:       conf.GConf.BP.ChainFileName,
+		BlockStorage:   conf.GConf.BP.BlockStorage,
 		Server:         server,
 		Peers:          peers,
 		NodeID:         nodeID,
@@ -143,6 +166,25 @@ func runNode(nodeID proto.NodeID, listenAddr string) (err error) {
 		}
 	}()
 
+	if conf.GConf.GRPC != nil {
+		var gw *grpcgw.Server
+		if gw, err = grpcgw.NewServer(&grpcgw.Config{
+			ListenAddr: conf.GConf.GRPC.ListenAddr,
+			CertFile:   conf.GConf.GRPC.CertFile,
+			KeyFile:    conf.GConf.GRPC.KeyFile,
+			NodeID:     conf.GConf.ThisNodeID,
+			Chain:      chain.RPCService(),
+		}); err != nil {
+			log.WithError(err).Error("init grpc gateway failed")
+			return err
+		}
+		if err = gw.Start(); err != nil {
+			log.WithError(err).Error("start grpc gateway failed")
+			return err
+		}
+		defer gw.Stop()
+	}
+
 	log.Info(conf.StartSucceedMessage)
 
 	// start json-rpc server
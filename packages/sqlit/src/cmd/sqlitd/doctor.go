@@ -0,0 +1,424 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/jeju"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/utils"
+)
+
+const (
+	doctorCommand = "doctor"
+
+	// ntpEpochOffset is the seconds from the NTP epoch 1900 to the unix epoch 1970.
+	ntpEpochOffset = 2208988800
+	ntpPacketSize  = 48
+)
+
+// doctorOptions defines the options of the doctor command.
+type doctorOptions struct {
+	configFile   string
+	jejuConfig   string
+	ntpServer    string
+	timeout      time.Duration
+	maxClockSkew time.Duration
+	minDiskFree  uint64
+}
+
+func parseDoctorOptions(args []string) (opts *doctorOptions, err error) {
+	var minDiskFreeMB uint64
+	opts = &doctorOptions{}
+	fs := flag.NewFlagSet(name+" "+doctorCommand, flag.ContinueOnError)
+	fs.StringVar(&opts.configFile, "config", configFile, "Config file path")
+	fs.StringVar(&opts.jejuConfig, "jeju-config", "",
+		"Jeju config file to check the registry, default from the env if JEJU_NETWORK is set")
+	fs.StringVar(&opts.ntpServer, "ntp-server", "pool.ntp.org",
+		"NTP server to check the clock skew, empty to skip")
+	fs.DurationVar(&opts.timeout, "timeout", 5*time.Second, "Time limit of each network check")
+	fs.DurationVar(&opts.maxClockSkew, "max-clock-skew", time.Second, "Max allowed clock skew")
+	fs.Uint64Var(&minDiskFreeMB, "min-disk-free", 1024, "Min free disk space in MB")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if opts.timeout <= 0 {
+		err = errors.Errorf("invalid timeout: %s", opts.timeout)
+		return
+	}
+	opts.configFile = utils.HomeDirExpand(opts.configFile)
+	opts.minDiskFree = minDiskFreeMB << 20
+	return
+}
+
+// checkStatus is the result status of a doctor check.
+type checkStatus int
+
+const (
+	checkPass checkStatus = iota
+	checkWarn
+	checkFail
+	checkSkip
+)
+
+func (s checkStatus) String() string {
+	switch s {
+	case checkPass:
+		return "PASS"
+	case checkWarn:
+		return "WARN"
+	case checkFail:
+		return "FAIL"
+	case checkSkip:
+		return "SKIP"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+type checkResult struct {
+	name   string
+	status checkStatus
+	detail string
+}
+
+// doctor runs the checks of a node config and collects the results.
+type doctor struct {
+	opts     *doctorOptions
+	out      io.Writer
+	cfg      *conf.Config
+	registry *jeju.RegistryClient
+	results  []checkResult
+}
+
+func (d *doctor) report(name string, status checkStatus, format string, args ...interface{}) {
+	var r = checkResult{name: name, status: status, detail: fmt.Sprintf(format, args...)}
+	d.results = append(d.results, r)
+	_, _ = fmt.Fprintf(d.out, "[%s] %-16s %s\n", r.status, r.name, r.detail)
+}
+
+// failed returns the count of the failed checks.
+func (d *doctor) failed() (n int) {
+	for _, r := range d.results {
+		if r.status == checkFail {
+			n++
+		}
+	}
+	return
+}
+
+// runDoctor checks the config, key, network and host of the node and prints a pass/fail report,
+// it fails if any check fails. The checks never start the node.
+func runDoctor(args []string) (err error) {
+	var opts *doctorOptions
+	if opts, err = parseDoctorOptions(args); err != nil {
+		return
+	}
+	var d = &doctor{opts: opts, out: os.Stdout}
+	defer func() {
+		if d.registry != nil {
+			d.registry.Close()
+		}
+	}()
+	d.run()
+
+	var counts = make(map[checkStatus]int)
+	for _, r := range d.results {
+		counts[r.status]++
+	}
+	_, _ = fmt.Fprintf(d.out, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		counts[checkPass], counts[checkWarn], counts[checkFail], counts[checkSkip])
+	if n := d.failed(); n > 0 {
+		err = errors.Errorf("%d checks failed", n)
+	}
+	return
+}
+
+func (d *doctor) run() {
+	if !d.checkConfig() {
+		return
+	}
+	d.checkKey()
+	d.checkPorts()
+	d.checkBlockProducers()
+	d.checkRegistry()
+	d.checkSeeds()
+	d.checkDisk()
+	d.checkClock()
+}
+
+func (d *doctor) checkConfig() bool {
+	var err error
+	if d.cfg, err = conf.LoadConfig(d.opts.configFile); err != nil {
+		d.report("config", checkFail, "load %s failed: %v", d.opts.configFile, err)
+		return false
+	}
+	if err = conf.CheckInsecureDevMode(
+		d.cfg, conf.UsedInsecureFeatures(d.cfg.UseTestMasterKey, false)...,
+	); err != nil {
+		d.report("config", checkFail, "%v", err)
+		return true
+	}
+	d.report("config", checkPass, "loaded %s, node %s", d.opts.configFile, d.cfg.ThisNodeID)
+	return true
+}
+
+// readMasterKey returns the master key of the private key, it's prompted on a terminal.
+func (d *doctor) readMasterKey() (masterKey []byte, err error) {
+	if d.cfg.UseTestMasterKey || !term.IsTerminal(int(syscall.Stdin)) {
+		return
+	}
+	_, _ = fmt.Fprint(d.out, "Type in Master key to continue: ")
+	masterKey, err = term.ReadPassword(int(syscall.Stdin))
+	_, _ = fmt.Fprintln(d.out)
+	return
+}
+
+func (d *doctor) checkKey() {
+	masterKey, err := d.readMasterKey()
+	if err != nil {
+		d.report("key", checkFail, "read master key failed: %v", err)
+		return
+	}
+	priv, err := kms.LoadPrivateKey(d.cfg.PrivateKeyFile, masterKey)
+	if err != nil {
+		d.report("key", checkFail, "load %s failed, wrong master key or broken file: %v",
+			d.cfg.PrivateKeyFile, err)
+		return
+	}
+	var (
+		pub  = priv.PubKey()
+		node *proto.Node
+	)
+	for i := range d.cfg.KnownNodes {
+		if d.cfg.KnownNodes[i].ID == d.cfg.ThisNodeID {
+			node = &d.cfg.KnownNodes[i]
+			break
+		}
+	}
+	if node == nil {
+		d.report("key", checkWarn, "node %s is not in KnownNodes, its nonce is not checked",
+			d.cfg.ThisNodeID)
+		return
+	}
+	if node.PublicKey != nil && !node.PublicKey.IsEqual(pub) {
+		d.report("key", checkFail, "private key does not match the public key of node %s", node.ID)
+		return
+	}
+	if !kms.IsIDPubNonceValid(node.ID.ToRawNodeID(), &node.Nonce, pub) {
+		d.report("key", checkFail, "node id %s does not match the key and nonce", node.ID)
+		return
+	}
+	if difficulty := node.ID.Difficulty(); difficulty < d.cfg.MinNodeIDDifficulty {
+		d.report("key", checkFail, "node id difficulty %d is less than %d",
+			difficulty, d.cfg.MinNodeIDDifficulty)
+		return
+	}
+	d.report("key", checkPass, "private key matches node %s", node.ID)
+}
+
+func (d *doctor) checkPorts() {
+	for _, v := range []struct {
+		name, addr string
+	}{
+		{"listen", d.cfg.ListenAddr},
+		{"direct listen", d.cfg.ListenDirectAddr},
+	} {
+		if v.addr == "" {
+			continue
+		}
+		if err := checkBind(v.addr); err != nil {
+			d.report(v.name, checkFail, "bind %s failed, is the node already running? %v", v.addr, err)
+			continue
+		}
+		d.report(v.name, checkPass, "%s is available", v.addr)
+	}
+}
+
+// checkBind checks that the address can be listened on.
+func checkBind(addr string) (err error) {
+	var l net.Listener
+	if l, err = net.Listen("tcp", addr); err != nil {
+		return
+	}
+	return l.Close()
+}
+
+func (d *doctor) checkBlockProducers() {
+	var checked int
+	for _, n := range d.cfg.KnownNodes {
+		if (n.Role != proto.Leader && n.Role != proto.Follower) || n.ID == d.cfg.ThisNodeID {
+			continue
+		}
+		checked++
+		var start = time.Now()
+		conn, err := net.DialTimeout("tcp", n.Addr, d.opts.timeout)
+		if err != nil {
+			d.report("block producer", checkFail, "%s at %s is unreachable: %v", n.ID, n.Addr, err)
+			continue
+		}
+		_ = conn.Close()
+		d.report("block producer", checkPass, "%s at %s is reachable in %s",
+			n.ID, n.Addr, time.Since(start).Round(time.Millisecond))
+	}
+	if checked == 0 {
+		d.report("block producer", checkSkip, "no other block producer in KnownNodes")
+	}
+}
+
+func (d *doctor) checkRegistry() {
+	if d.opts.jejuConfig == "" && os.Getenv(conf.NetworkEnv) == "" {
+		d.report("registry", checkSkip, "no jeju config, set -jeju-config or %s", conf.NetworkEnv)
+		return
+	}
+	jc, err := jeju.LoadJejuConfig(d.opts.jejuConfig)
+	if err != nil {
+		d.report("registry", checkFail, "%v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.opts.timeout)
+	defer cancel()
+	if d.registry, err = jeju.DialRegistry(ctx, jc); err != nil {
+		d.report("registry", checkFail, "%v", err)
+		return
+	}
+	// the registry seed source is only available with a registry client
+	jeju.RegisterRegistrySeed(d.registry)
+	d.report("registry", checkPass, "%s on %s", jc.RegistryAddress, jc.Network)
+}
+
+func (d *doctor) checkSeeds() {
+	var seed = d.cfg.DNSSeed
+	if len(seed.Bootstrap) == 0 && seed.Domain == "" {
+		d.report("seeds", checkSkip, "no seed source configured")
+		return
+	}
+	if seed.Timeout <= 0 {
+		seed.Timeout = d.opts.timeout
+	}
+	nodes, err := route.GetBPFromSeeds(&seed)
+	if err != nil {
+		d.report("seeds", checkFail, "%v", err)
+		return
+	}
+	d.report("seeds", checkPass, "found %d block producers", len(nodes))
+}
+
+func (d *doctor) checkDisk() {
+	var paths = []string{d.cfg.WorkingRoot}
+	if d.cfg.BP != nil {
+		paths = append(paths, filepath.Dir(d.cfg.BP.ChainFileName))
+	}
+	if d.cfg.Miner != nil {
+		paths = append(paths, d.cfg.Miner.RootDir)
+	}
+	var checked = make(map[string]bool)
+	for _, p := range paths {
+		if p = existingParent(p); checked[p] {
+			continue
+		}
+		checked[p] = true
+		total, avail, err := utils.FilesystemStat(p)
+		if err != nil {
+			d.report("disk", checkFail, "stat %s failed: %v", p, err)
+			continue
+		}
+		var status = checkPass
+		if avail < d.opts.minDiskFree {
+			status = checkFail
+		}
+		d.report("disk", status, "%s has %d MB free of %d MB", p, avail>>20, total>>20)
+	}
+}
+
+// existingParent returns the path or its nearest existing parent, as the data directories may
+// not be created before the first start.
+func existingParent(p string) string {
+	p = filepath.Clean(p)
+	for {
+		if _, err := os.Stat(p); err == nil {
+			return p
+		}
+		parent := filepath.Dir(p)
+		if parent == p {
+			return p
+		}
+		p = parent
+	}
+}
+
+func (d *doctor) checkClock() {
+	if d.opts.ntpServer == "" {
+		d.report("clock", checkSkip, "no ntp server")
+		return
+	}
+	offset, err := queryNTPOffset(d.opts.ntpServer, d.opts.timeout)
+	if err != nil {
+		d.report("clock", checkFail, "query %s failed: %v", d.opts.ntpServer, err)
+		return
+	}
+	var status = checkPass
+	if offset > d.opts.maxClockSkew || offset < -d.opts.maxClockSkew {
+		status = checkFail
+	}
+	d.report("clock", status, "local clock is off by %s from %s", offset, d.opts.ntpServer)
+}
+
+// queryNTPOffset returns the offset of the server clock from the local clock by SNTP.
+func queryNTPOffset(server string, timeout time.Duration) (offset time.Duration, err error) {
+	if _, _, serr := net.SplitHostPort(server); serr != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return
+	}
+	var req = make([]byte, ntpPacketSize)
+	req[0] = 0x23 // version 4, client mode
+	var sent = time.Now()
+	if _, err = conn.Write(req); err != nil {
+		return
+	}
+	var resp = make([]byte, ntpPacketSize)
+	if _, err = io.ReadFull(conn, resp); err != nil {
+		return
+	}
+	var received = time.Now()
+	if mode, stratum := resp[0]&0x07, resp[1]; mode != 4 || stratum == 0 {
+		err = errors.Errorf("invalid ntp response: mode %d, stratum %d", mode, stratum)
+		return
+	}
+	var (
+		serverReceived = ntpTime(resp[32:40])
+		serverSent     = ntpTime(resp[40:48])
+	)
+	offset = (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2
+	return
+}
+
+// ntpTime decodes a 64-bit NTP timestamp.
+func ntpTime(b []byte) time.Time {
+	var (
+		sec  = int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+		frac = int64(binary.BigEndian.Uint32(b[4:8]))
+	)
+	return time.Unix(sec, (frac*int64(time.Second))>>32)
+}
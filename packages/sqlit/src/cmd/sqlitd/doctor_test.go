@@ -0,0 +1,120 @@
+//go:build !testbinary
+// +build !testbinary
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/test/testnet"
+)
+
+// fakeNTPServer answers the SNTP requests with the local clock shifted by offset.
+func fakeNTPServer(offset time.Duration) (addr string, stop func(), err error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return
+	}
+	go func() {
+		var buf = make([]byte, ntpPacketSize)
+		for {
+			_, peer, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var (
+				resp = make([]byte, ntpPacketSize)
+				now  = time.Now().Add(offset)
+				sec  = uint32(now.Unix() + ntpEpochOffset)
+				frac = uint32((uint64(now.Nanosecond()) << 32) / uint64(time.Second))
+			)
+			resp[0], resp[1] = 0x24, 1 // version 4, server mode, stratum 1
+			for _, off := range []int{32, 40} {
+				binary.BigEndian.PutUint32(resp[off:], sec)
+				binary.BigEndian.PutUint32(resp[off+4:], frac)
+			}
+			_, _ = conn.WriteTo(resp, peer)
+		}
+	}()
+	return conn.LocalAddr().String(), func() { _ = conn.Close() }, nil
+}
+
+func TestDoctor(t *testing.T) {
+	Convey("Given the doctor command arguments", t, func() {
+		opts, err := parseDoctorOptions([]string{"-ntp-server", "", "-min-disk-free", "1"})
+		So(err, ShouldBeNil)
+		So(opts.ntpServer, ShouldBeEmpty)
+		So(opts.minDiskFree, ShouldEqual, 1<<20)
+		So(opts.maxClockSkew, ShouldEqual, time.Second)
+		_, err = parseDoctorOptions([]string{"-timeout", "0"})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Given a fake ntp server", t, func() {
+		addr, stop, err := fakeNTPServer(3 * time.Second)
+		So(err, ShouldBeNil)
+		defer stop()
+		offset, err := queryNTPOffset(addr, time.Second)
+		So(err, ShouldBeNil)
+		So(offset, ShouldAlmostEqual, 3*time.Second, 100*time.Millisecond)
+	})
+	Convey("Given a listening address", t, func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer func() { _ = l.Close() }()
+		So(checkBind(l.Addr().String()), ShouldNotBeNil)
+		So(checkBind("127.0.0.1:0"), ShouldBeNil)
+	})
+	Convey("Given the node configs of a network", t, func() {
+		t.Setenv(conf.InsecureDevModeEnv, "1")
+		t.Setenv(conf.NetworkEnv, "")
+		nw, err := testnet.New(testnet.Options{MinerCount: 1, Dir: t.TempDir()})
+		So(err, ShouldBeNil)
+		var run = func(configFile string) (d *doctor) {
+			d = &doctor{
+				opts: &doctorOptions{configFile: configFile, timeout: time.Second},
+				out:  &bytes.Buffer{},
+			}
+			d.run()
+			return
+		}
+		var status = func(d *doctor) map[string]checkStatus {
+			var m = make(map[string]checkStatus)
+			for _, r := range d.results {
+				m[r.name] = r.status
+			}
+			return m
+		}
+
+		Convey("The block producer config should pass the checks", func() {
+			d := run(nw.BPs[0].ConfigFile)
+			So(d.failed(), ShouldEqual, 0)
+			So(status(d), ShouldResemble, map[string]checkStatus{
+				"config":         checkPass,
+				"key":            checkPass,
+				"listen":         checkPass,
+				"block producer": checkSkip,
+				"registry":       checkSkip,
+				"seeds":          checkSkip,
+				"disk":           checkPass,
+				"clock":          checkSkip,
+			})
+		})
+		Convey("The miner should fail to reach the stopped block producer", func() {
+			d := run(nw.Miners[0].ConfigFile)
+			So(d.failed(), ShouldEqual, 1)
+			So(status(d)["block producer"], ShouldEqual, checkFail)
+		})
+		Convey("The config without the insecure dev mode should fail", func() {
+			t.Setenv(conf.InsecureDevModeEnv, "")
+			d := run(nw.BPs[0].ConfigFile)
+			So(status(d)["config"], ShouldEqual, checkFail)
+		})
+	})
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/client"
+	"sqlit/src/conf"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+	"sqlit/test/testnet"
+)
+
+const (
+	localnetCommand = "localnet"
+	minerBinaryName = "sqlit-minerd"
+
+	// localnetStartTimeout limits the time to start the nodes and create the database.
+	localnetStartTimeout = 2 * time.Minute
+)
+
+// localnetOptions defines the options of the localnet command.
+type localnetOptions struct {
+	nodes       int
+	dir         string
+	host        string
+	minerBinary string
+	noDatabase  bool
+}
+
+func parseLocalnetOptions(args []string) (opts *localnetOptions, err error) {
+	opts = &localnetOptions{}
+	fs := flag.NewFlagSet(name+" "+localnetCommand, flag.ContinueOnError)
+	fs.IntVar(&opts.nodes, "nodes", 3, "Count of miners, which is also the node count of the database")
+	fs.StringVar(&opts.dir, "dir", "", "Working directory of the nodes, default a temporary one removed on exit")
+	fs.StringVar(&opts.host, "host", "127.0.0.1", "Listen host of the nodes")
+	fs.StringVar(&opts.minerBinary, "miner-binary", "",
+		"Miner binary, default "+minerBinaryName+" next to this binary or in PATH")
+	fs.BoolVar(&opts.noDatabase, "no-database", false, "Do not create a database on the localnet")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+	if opts.nodes <= 0 {
+		err = errors.Errorf("invalid node count: %d", opts.nodes)
+	}
+	return
+}
+
+// findMinerBinary returns the miner binary next to the executable bin, or the one in PATH.
+func findMinerBinary(bin string) (string, error) {
+	sibling := filepath.Join(filepath.Dir(bin), minerBinaryName)
+	if st, err := os.Stat(sibling); err == nil && !st.IsDir() {
+		return sibling, nil
+	}
+	return exec.LookPath(minerBinaryName)
+}
+
+// runLocalnet launches a local network of a block producer and miners with the generated configs,
+// creates a database on it and prints the dsn, then waits for the exit signal. The nodes run as the
+// subprocesses of this binary and the miner binary, and this process acts as the client.
+func runLocalnet(args []string) (err error) {
+	var opts *localnetOptions
+	if opts, err = parseLocalnetOptions(args); err != nil {
+		return
+	}
+	var self string
+	if self, err = os.Executable(); err != nil {
+		return
+	}
+	if opts.minerBinary == "" {
+		if opts.minerBinary, err = findMinerBinary(self); err != nil {
+			return errors.Wrapf(err, "%s not found, set it by -miner-binary", minerBinaryName)
+		}
+	}
+
+	nw, err := testnet.New(testnet.Options{
+		MinerCount:   opts.nodes,
+		Dir:          opts.dir,
+		Host:         opts.host,
+		BPBinary:     self,
+		MinerBinary:  opts.minerBinary,
+		StartTimeout: localnetStartTimeout,
+	})
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := nw.Close(); cerr != nil {
+			log.WithError(cerr).Warning("stop localnet failed")
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), localnetStartTimeout)
+	defer cancel()
+	if err = nw.Start(ctx); err != nil {
+		return
+	}
+
+	var dsn string
+	if !opts.noDatabase {
+		if dsn, err = createLocalnetDatabase(ctx, nw.Client.ConfigFile, opts.nodes); err != nil {
+			return
+		}
+	}
+
+	fmt.Printf("\nlocalnet is ready in %s, press Ctrl+C to stop\n", nw.Dir())
+	for _, n := range nw.BPs {
+		fmt.Printf("  %-8s %s  log: %s\n", n.Name(), n.Addr, n.LogPath())
+	}
+	for _, n := range nw.Miners {
+		fmt.Printf("  %-8s %s  log: %s\n", n.Name(), n.Addr, n.LogPath())
+	}
+	fmt.Printf("client config: %s\n", nw.Client.ConfigFile)
+	if dsn != "" {
+		fmt.Printf("dsn: %s\n", dsn)
+	}
+
+	<-utils.WaitForExit()
+	return
+}
+
+// createLocalnetDatabase creates a database of nodes miners as the client of the localnet. The
+// creation is packed once the miners have provided their service.
+func createLocalnetDatabase(ctx context.Context, configFile string, nodes int) (dsn string, err error) {
+	conf.RoleTag = conf.ClientBuildTag
+	if err = client.Init(configFile, nil); err != nil {
+		err = errors.Wrap(err, "init localnet client failed")
+		return
+	}
+	if _, dsn, err = client.Create(client.ResourceMeta{Node: uint16(nodes)}); err != nil {
+		err = errors.Wrap(err, "create localnet database failed")
+		return
+	}
+	if err = client.WaitDBCreation(ctx, dsn); err != nil {
+		err = errors.Wrap(err, "wait localnet database creation failed")
+	}
+	return
+}
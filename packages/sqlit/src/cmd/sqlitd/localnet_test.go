@@ -0,0 +1,39 @@
+//go:build !testbinary
+// +build !testbinary
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLocalnetOptions(t *testing.T) {
+	Convey("Given the localnet command arguments", t, func() {
+		opts, err := parseLocalnetOptions(nil)
+		So(err, ShouldBeNil)
+		So(opts.nodes, ShouldEqual, 3)
+		So(opts.host, ShouldEqual, "127.0.0.1")
+		So(opts.noDatabase, ShouldBeFalse)
+
+		opts, err = parseLocalnetOptions([]string{"--nodes", "2", "-no-database", "-dir", "net"})
+		So(err, ShouldBeNil)
+		So(opts.nodes, ShouldEqual, 2)
+		So(opts.dir, ShouldEqual, "net")
+		So(opts.noDatabase, ShouldBeTrue)
+
+		_, err = parseLocalnetOptions([]string{"-nodes", "0"})
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Given a miner binary next to the executable", t, func() {
+		dir := t.TempDir()
+		miner := filepath.Join(dir, minerBinaryName)
+		So(os.WriteFile(miner, []byte{}, 0755), ShouldBeNil)
+		found, err := findMinerBinary(filepath.Join(dir, name))
+		So(err, ShouldBeNil)
+		So(found, ShouldEqual, miner)
+	})
+}
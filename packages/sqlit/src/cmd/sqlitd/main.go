@@ -71,6 +71,10 @@ func init() {
 	flag.Usage = func() {
 		_, _ = fmt.Fprintf(os.Stderr, "\n%s\n\n", desc)
 		_, _ = fmt.Fprintf(os.Stderr, "Usage: %s [arguments]\n", name)
+		_, _ = fmt.Fprintf(os.Stderr, "       %s %s [-nodes 3] [-dir dir] [-miner-binary path]\n",
+			name, localnetCommand)
+		_, _ = fmt.Fprintf(os.Stderr, "       %s %s [-config path] [-jeju-config path] [-ntp-server host]\n",
+			name, doctorCommand)
 		flag.PrintDefaults()
 	}
 }
@@ -86,6 +90,19 @@ func main() {
 	flag.Parse()
 	log.SetStringLevel(logLevel, log.InfoLevel)
 
+	if flag.Arg(0) == localnetCommand {
+		if err := runLocalnet(flag.Args()[1:]); err != nil {
+			log.WithError(err).Fatal("run localnet failed")
+		}
+		return
+	}
+	if flag.Arg(0) == doctorCommand {
+		if err := runDoctor(flag.Args()[1:]); err != nil {
+			log.WithError(err).Fatal("run doctor failed")
+		}
+		return
+	}
+
 	if showVersion {
 		fmt.Printf("%v %v %v %v %v\n",
 			name, version, runtime.GOOS, runtime.GOARCH, runtime.Version())
@@ -98,17 +115,25 @@ func main() {
 		log.Infof("args %#v : %s", f.Name, f.Value)
 	})
 
-	// Enable test mode if requested
-	if testMode {
-		kms.Unittest = true
-		log.Info("Test mode enabled - bypassing node ID validation")
-	}
-
 	var err error
 	conf.GConf, err = conf.LoadConfig(configFile)
 	if err != nil {
 		log.WithField("config", configFile).WithError(err).Fatal("load config failed")
 	}
+	if err = conf.CheckInsecureDevMode(
+		conf.GConf, conf.UsedInsecureFeatures(conf.GConf.UseTestMasterKey, testMode)...,
+	); err != nil {
+		log.WithError(err).Fatal("check insecure dev mode failed")
+	}
+	if err = conf.InitLog(conf.GConf.Log); err != nil {
+		log.WithError(err).Fatal("init log file failed")
+	}
+
+	// Enable test mode if requested
+	if testMode {
+		kms.Unittest = true
+		log.Info("Test mode enabled - bypassing node ID validation")
+	}
 
 	kms.InitBP()
 	log.Debugf("config:\n%#v", conf.GConf)
@@ -4,6 +4,7 @@ package conf
 import (
 	"os"
 	"path"
+	"strings"
 	"time"
 
 	yaml "gopkg.in/yaml.v2"
@@ -57,6 +58,9 @@ type BPInfo struct {
 	Nonce cpuminer.Uint256 `yaml:"Nonce"`
 	// ChainFileName is the chain db's name
 	ChainFileName string `yaml:"ChainFileName"`
+	// BlockStorage is the storage backend of the chain blocks, "sqlite" or "leveldb", empty means
+	// "sqlite"
+	BlockStorage string `yaml:"BlockStorage,omitempty"`
 	// BPGenesis is the genesis block filed
 	BPGenesis BPGenesisInfo `yaml:"BPGenesisInfo,omitempty"`
 }
@@ -71,6 +75,108 @@ type MinerDatabaseFixture struct {
 	AutoGenerateGenesisBlock bool             `yaml:"AutoGenerateGenesisBlock,omitempty"`
 }
 
+// ObjectStoreInfo defines an S3-compatible object store.
+type ObjectStoreInfo struct {
+	Endpoint  string `yaml:"Endpoint"`
+	Region    string `yaml:"Region,omitempty"`
+	AccessKey string `yaml:"AccessKey"`
+	SecretKey string `yaml:"SecretKey"`
+}
+
+// BackupInfo defines the scheduled database backup config of miner.
+type BackupInfo struct {
+	// Interval is the period of scheduled backups, 0 disables scheduling
+	Interval time.Duration `yaml:"Interval,omitempty"`
+	// Target is a local directory or an object store prefix like "s3://bucket/prefix"
+	Target string `yaml:"Target"`
+	// ObjectStore is required if Target is an object store location
+	ObjectStore *ObjectStoreInfo `yaml:"ObjectStore,omitempty"`
+}
+
+// MaintenanceInfo defines the background database maintenance config of miner.
+type MaintenanceInfo struct {
+	// Interval is the period of scheduled maintenance, 0 disables periodic runs
+	Interval time.Duration `yaml:"Interval,omitempty"`
+	// WALSizeTrigger runs maintenance once the write-ahead log exceeds the size in bytes, 0
+	// disables the size trigger
+	WALSizeTrigger int64 `yaml:"WALSizeTrigger,omitempty"`
+	// IncrementalVacuum releases free pages to the file system
+	IncrementalVacuum bool `yaml:"IncrementalVacuum,omitempty"`
+	// Analyze refreshes the query planner statistics
+	Analyze bool `yaml:"Analyze,omitempty"`
+	// MaxLeaderInflight skips maintenance on the leader while it has more in-flight queries
+	MaxLeaderInflight int32 `yaml:"MaxLeaderInflight,omitempty"`
+}
+
+// AdmissionInfo defines the admission control of the batch priority queries of each database.
+type AdmissionInfo struct {
+	// LatencyTarget throttles the batch queries while the interactive latency is above it
+	LatencyTarget time.Duration `yaml:"LatencyTarget,omitempty"`
+	// BatchConcurrency is the max count of running batch queries while throttled
+	BatchConcurrency int `yaml:"BatchConcurrency,omitempty"`
+	// BatchQueueSize is the max count of waiting batch queries, the others are shed
+	BatchQueueSize int `yaml:"BatchQueueSize,omitempty"`
+	// BatchQueueTimeout sheds a waiting batch query after it
+	BatchQueueTimeout time.Duration `yaml:"BatchQueueTimeout,omitempty"`
+}
+
+// ResultLimitInfo defines the limit of the read query results of each database.
+type ResultLimitInfo struct {
+	// MaxRows is the max count of rows in a result or a cursor page, 0 means unlimited
+	MaxRows uint64 `yaml:"MaxRows,omitempty"`
+	// MaxBytes is the max estimated size of a result or a cursor page, 0 means unlimited
+	MaxBytes uint64 `yaml:"MaxBytes,omitempty"`
+	// CursorTTL closes an idle result cursor after it
+	CursorTTL time.Duration `yaml:"CursorTTL,omitempty"`
+	// MaxCursors is the max count of open result cursors of a database
+	MaxCursors int `yaml:"MaxCursors,omitempty"`
+}
+
+// PayloadAlertInfo defines the alerts on the sudden growth of the request and response payload
+// sizes of the query fingerprints of each database.
+type PayloadAlertInfo struct {
+	// GrowthRatio is the ratio of the mean payload size of a fingerprint in a window to its
+	// baseline to raise an alert, 0 disables the alerts
+	GrowthRatio float64 `yaml:"GrowthRatio,omitempty"`
+	// MinSize is the min mean payload size in bytes to raise an alert, 4KB if not set
+	MinSize int `yaml:"MinSize,omitempty"`
+	// Window is the interval of comparing the mean payload sizes with the baselines, 1 minute if
+	// not set
+	Window time.Duration `yaml:"Window,omitempty"`
+}
+
+// ResultCompressionInfo defines the compression of the read query results sent to the clients
+// accepting it.
+type ResultCompressionInfo struct {
+	// MinSize is the min size of the encoded rows to compress, 0 means DefaultMinSize of the
+	// miner
+	MinSize int `yaml:"MinSize,omitempty"`
+	// DictFile is the zstd dictionary trained on the repetitive column values, which is used for
+	// the clients holding the same dictionary
+	DictFile string `yaml:"DictFile,omitempty"`
+}
+
+// BlockPackingInfo defines the limits of the adaptive block packing of each database, the max
+// count of queries in a block and the tick of the main cycle are adapted to the arrival rate.
+type BlockPackingInfo struct {
+	// MinQueries and MaxQueries bound the max count of queries packed in a block, the queries
+	// beyond it are packed in the following blocks
+	MinQueries int `yaml:"MinQueries,omitempty"`
+	MaxQueries int `yaml:"MaxQueries,omitempty"`
+	// MinTick and MaxTick bound the tick of the main cycle, MaxTick is SQLChainTick if not set
+	MinTick time.Duration `yaml:"MinTick,omitempty"`
+	MaxTick time.Duration `yaml:"MaxTick,omitempty"`
+}
+
+// AuditInfo defines the write determinism audit of the databases led by the miner.
+type AuditInfo struct {
+	// SampleRate is the ratio of the write requests re-executed on a shadow copy, 0 disables the
+	// audit
+	SampleRate float64 `yaml:"SampleRate,omitempty"`
+	// MaxPending is the max count of sampled writes waiting for the audit, the others are skipped
+	MaxPending int `yaml:"MaxPending,omitempty"`
+}
+
 // MinerInfo for miner config.
 type MinerInfo struct {
 	// node basic config.
@@ -79,23 +185,268 @@ type MinerInfo struct {
 	ProvideServiceInterval time.Duration          `yaml:"ProvideServiceInterval,omitempty"`
 	DiskUsageInterval      time.Duration          `yaml:"DiskUsageInterval,omitempty"`
 	TargetUsers            []proto.AccountAddress `yaml:"TargetUsers,omitempty"`
+	// Region and Zone label the location of the miner for the placement constraints of databases.
+	Region string `yaml:"Region,omitempty"`
+	Zone   string `yaml:"Zone,omitempty"`
+
+	// DiskAlertThresholds defines the utilization ratios of the filesystem of RootDir to emit
+	// alerts and reduce the advertised space, nil means defaults.
+	DiskAlertThresholds []float64 `yaml:"DiskAlertThresholds,omitempty"`
+
+	// state sync config, bandwidth limits are in bytes per second and 0 means unlimited.
+	SyncReadBandwidth  int64 `yaml:"SyncReadBandwidth,omitempty"`
+	SyncWriteBandwidth int64 `yaml:"SyncWriteBandwidth,omitempty"`
+	// ApplyConcurrency is the max count of requests prepared in parallel for replaying, 0 means
+	// the count of cpus and 1 disables parallel preparing.
+	ApplyConcurrency int `yaml:"ApplyConcurrency,omitempty"`
+	// GroupCommitDelay is the max delay of committing the concurrent write requests of a database
+	// in a single transaction on the leader, 0 disables the group commit.
+	GroupCommitDelay time.Duration `yaml:"GroupCommitDelay,omitempty"`
+	// SnapshotReads runs the read queries of the read uncommitted databases on the last committed
+	// snapshot, so that the reads never wait for the writes or the block production.
+	SnapshotReads bool `yaml:"SnapshotReads,omitempty"`
+	// LatencyProbeInterval is the interval of pinging the peers of the databases to measure the
+	// round-trip times, which rank the followers taking over the leadership, 0 disables it.
+	LatencyProbeInterval time.Duration `yaml:"LatencyProbeInterval,omitempty"`
+	// TTLCheckInterval is the interval of deleting the expired rows of the tables with a row ttl
+	// on the leaders, 0 means the default interval and a negative value disables it.
+	TTLCheckInterval time.Duration `yaml:"TTLCheckInterval,omitempty"`
+	// JobCheckInterval is the interval of checking the due runs of the scheduled jobs on the
+	// leaders, 0 means the default interval and a negative value disables it.
+	JobCheckInterval time.Duration `yaml:"JobCheckInterval,omitempty"`
+	// ForeignDataHosts are the hosts of the https endpoints the leaders fetch for the foreign
+	// tables, a host prefixed with "*." allows its subdomains, empty disables the fetches.
+	ForeignDataHosts []string `yaml:"ForeignDataHosts,omitempty"`
+	// AckReconcileWindow is the age of the responses without acks, of which the leader re-requests
+	// the acks from the responders or expires them, 5 minutes if not set.
+	AckReconcileWindow time.Duration `yaml:"AckReconcileWindow,omitempty"`
+	// LeaderTimestamp makes the request time of the clients advisory, the requests with skewed
+	// clocks are accepted with warnings instead of rejected by MaxReqTimeGap, and the queries are
+	// indexed by the response time of the miners. It should be set on all miners of a database.
+	LeaderTimestamp bool `yaml:"LeaderTimestamp,omitempty"`
+	// LoadReportInterval is the interval of reporting the loads of the databases to the block
+	// producers for the capacity planning, 1 minute if not set.
+	LoadReportInterval time.Duration `yaml:"LoadReportInterval,omitempty"`
+
+	// QuotaWarningThresholds defines the database storage quota usage ratios to emit warnings.
+	QuotaWarningThresholds []float64 `yaml:"QuotaWarningThresholds,omitempty"`
+
+	// AllowUnencryptedStorage serves the databases with encryption key in plain text if the
+	// sqlite library is not built with page encryption support, such as SQLCipher. They are
+	// refused by default.
+	AllowUnencryptedStorage bool `yaml:"AllowUnencryptedStorage,omitempty"`
+
+	// StandbyRetention keeps a read-only snapshot of the databases removed from this miner for
+	// standby queries, 0 disables snapshot retention.
+	StandbyRetention time.Duration `yaml:"StandbyRetention,omitempty"`
+
+	// IdempotencyWindow defines how long the responses of the write requests with idempotency
+	// keys are kept to deduplicate client retries, 0 means the default window.
+	IdempotencyWindow time.Duration `yaml:"IdempotencyWindow,omitempty"`
+
+	// online backup config.
+	Backup *BackupInfo `yaml:"Backup,omitempty"`
+
+	// background maintenance config.
+	Maintenance *MaintenanceInfo `yaml:"Maintenance,omitempty"`
+
+	// admission control config of the batch priority queries, nil means defaults.
+	Admission *AdmissionInfo `yaml:"Admission,omitempty"`
+
+	// limit of the read query results, nil means unlimited.
+	ResultLimit *ResultLimitInfo `yaml:"ResultLimit,omitempty"`
+
+	// compression of the read query results, nil disables it.
+	ResultCompression *ResultCompressionInfo `yaml:"ResultCompression,omitempty"`
+
+	// write determinism audit config, nil disables the audit.
+	Audit *AuditInfo `yaml:"Audit,omitempty"`
+
+	// payload size growth alerts config, nil disables the alerts.
+	PayloadAlert *PayloadAlertInfo `yaml:"PayloadAlert,omitempty"`
+
+	// adaptive block packing config, nil or empty packs all pooled queries in each block at
+	// SQLChainTick.
+	BlockPacking *BlockPackingInfo `yaml:"BlockPacking,omitempty"`
+
+	// ShutdownTimeout bounds the leadership handoff and block flushing on graceful shutdown, 0
+	// means the default timeout and a negative value disables the handoff.
+	ShutdownTimeout time.Duration `yaml:"ShutdownTimeout,omitempty"`
 }
 
 // DNSSeed defines seed DNS info.
 type DNSSeed struct {
+	// EnforcedDNSSEC requires the seed records to be validated by the DNSServers, which must be
+	// trusted validating resolvers
 	EnforcedDNSSEC bool     `yaml:"EnforcedDNSSEC"`
 	DNSServers     []string `yaml:"DNSServers"`
 	Domain         string   `yaml:"Domain"`
 	BPCount        int      `yaml:"BPCount"`
+
+	// Bootstrap lists the seed sources tried in order until one succeeds, such as "dns", "https"
+	// or "registry", empty means "dns" only
+	Bootstrap []string `yaml:"Bootstrap,omitempty"`
+	// HTTPSURL is the node list url of the "https" source, empty means
+	// https://<Domain>/.well-known/eqlite-nodes.json
+	HTTPSURL string `yaml:"HTTPSURL,omitempty"`
+	// Timeout is the time limit of each seed source, 0 means the default
+	Timeout time.Duration `yaml:"Timeout,omitempty"`
+}
+
+// ResolverInfo defines a node ID resolver in the resolver chain.
+type ResolverInfo struct {
+	// Name is the registered resolver name, such as "dht", "static", "jeju" or "dnsseed"
+	Name string `yaml:"Name"`
+	// Priority orders the resolvers in chain, higher priority is tried first
+	Priority int `yaml:"Priority,omitempty"`
+}
+
+// RegistryInfo defines the Jeju on-chain node registry.
+type RegistryInfo struct {
+	// Address is the SqlitRegistry contract address
+	Address string `yaml:"Address"`
+	// RPCEndpoint is the L2 RPC endpoint serving the contract
+	RPCEndpoint string `yaml:"RPCEndpoint"`
+	// Network is the Jeju network of which the RPC endpoint must serve the chain, it's the network
+	// of the JEJU_NETWORK env or localnet if empty
+	Network string `yaml:"Network,omitempty"`
+}
+
+// MTLSInfo defines the mutual TLS of the node connections, which runs under the ETLS layer.
+type MTLSInfo struct {
+	// CertFile, KeyFile and CAFile are the PEM files of the certificate, its private key and the
+	// CA certificates verifying the peers, they are reloaded once rotated
+	CertFile string `yaml:"CertFile,omitempty"`
+	KeyFile  string `yaml:"KeyFile,omitempty"`
+	CAFile   string `yaml:"CAFile,omitempty"`
+	// SPIFFEDir is the directory of the X.509 SVID kept by a SPIFFE helper, with the svid.pem,
+	// svid_key.pem and svid_bundle.pem files, it replaces the files above if set
+	SPIFFEDir string `yaml:"SPIFFEDir,omitempty"`
+	// SPIFFEIDs are the SPIFFE IDs or trust domains like "spiffe://example.org" of the allowed
+	// peers, empty means any ID of the trust bundle
+	SPIFFEIDs []string `yaml:"SPIFFEIDs,omitempty"`
+	// ServerName is the name verified in the certificates of the dialed peers, empty means the
+	// host of the peer address, it's not used with SPIFFE
+	ServerName string `yaml:"ServerName,omitempty"`
+	// Outbound dials the peers with mutual TLS
+	Outbound bool `yaml:"Outbound,omitempty"`
+	// Require refuses the inbound connections without mutual TLS
+	Require bool `yaml:"Require,omitempty"`
+	// ReloadInterval is the min interval of checking the rotated files, 1 minute if not set
+	ReloadInterval time.Duration `yaml:"ReloadInterval,omitempty"`
+}
+
+// LogInfo defines the log file of a daemon with its rotation and retention.
+type LogInfo struct {
+	// File is the log file, empty means logging to stderr without rotation
+	File string `yaml:"File,omitempty"`
+	// MaxSize rotates the file once it exceeds the size in bytes, 100MB if not set and a negative
+	// value disables the size rotation
+	MaxSize int64 `yaml:"MaxSize,omitempty"`
+	// RotateInterval rotates the file once it's been written for the interval, such as 24h, 0
+	// disables the time rotation
+	RotateInterval time.Duration `yaml:"RotateInterval,omitempty"`
+	// MaxBackups is the max count of the rotated files kept, 7 if not set and a negative value
+	// keeps all of them
+	MaxBackups int `yaml:"MaxBackups,omitempty"`
+	// MaxAge removes the rotated files older than it, 0 keeps them by MaxBackups only
+	MaxAge time.Duration `yaml:"MaxAge,omitempty"`
+	// Compress compresses the rotated files with gzip
+	Compress bool `yaml:"Compress,omitempty"`
+}
+
+// RotateConfig returns the rotation of the log file, nil means the defaults.
+func (l *LogInfo) RotateConfig() (cfg log.RotateConfig) {
+	if l == nil {
+		return log.DefaultRotateConfig()
+	}
+	cfg = log.RotateConfig{
+		MaxSize:    l.MaxSize,
+		Interval:   l.RotateInterval,
+		MaxBackups: l.MaxBackups,
+		MaxAge:     l.MaxAge,
+		Compress:   l.Compress,
+	}
+	if cfg.MaxSize == 0 {
+		cfg.MaxSize = log.DefaultMaxLogSize
+	} else if cfg.MaxSize < 0 {
+		cfg.MaxSize = 0
+	}
+	if cfg.MaxBackups == 0 {
+		cfg.MaxBackups = log.DefaultMaxLogBackups
+	} else if cfg.MaxBackups < 0 {
+		cfg.MaxBackups = 0
+	}
+	return
+}
+
+// InitLog sets the log output of the daemon to the rotated file of info, the log is kept on
+// stderr if no file is set.
+func InitLog(info *LogInfo) (err error) {
+	if info == nil || info.File == "" {
+		return
+	}
+	_, err = log.SetRotatedOutput(info.File, info.RotateConfig())
+	return
+}
+
+// RemoteSignerInfo defines the external service signing the client requests and transactions,
+// instead of the local private key.
+type RemoteSignerInfo struct {
+	// Endpoint is the HTTP URL posted with the hex encoded {"publicKey","hash"} JSON, which
+	// answers the hex encoded {"signature"}
+	Endpoint string `yaml:"Endpoint"`
+	// PublicKey is the hex encoded compressed public key of the signing key
+	PublicKey string `yaml:"PublicKey"`
+	// Timeout is the timeout of a signing call, 10 seconds if not set
+	Timeout time.Duration `yaml:"Timeout,omitempty"`
+}
+
+// RPCPoolLimits defines the limits of the pooled connections to a remote node.
+type RPCPoolLimits struct {
+	// MaxSessions is the max physical connections to a node, 2 if not set
+	MaxSessions int `yaml:"MaxSessions,omitempty"`
+	// MaxStreams is the max concurrent calls to a node, the calls over the limit wait for the
+	// running ones, 0 means unlimited
+	MaxStreams int `yaml:"MaxStreams,omitempty"`
+	// WaitTimeout is the max time a call waits for the running ones, 10 seconds if not set
+	WaitTimeout time.Duration `yaml:"WaitTimeout,omitempty"`
+}
+
+// RPCPoolInfo defines the pool of the connections to the other nodes.
+type RPCPoolInfo struct {
+	// the limits of the nodes not listed in Nodes
+	RPCPoolLimits `yaml:",inline"`
+	// Nodes are the limits of the specific nodes, which replace the limits above
+	Nodes map[proto.NodeID]RPCPoolLimits `yaml:"Nodes,omitempty"`
+	// ProbeInterval is the interval of the health probes evicting the broken connections, 30
+	// seconds if not set, negative to disable
+	ProbeInterval time.Duration `yaml:"ProbeInterval,omitempty"`
+}
+
+// GRPCInfo defines the gRPC gateway of the node APIs, served alongside the native RPC.
+type GRPCInfo struct {
+	// ListenAddr is the address of the gRPC listener
+	ListenAddr string `yaml:"ListenAddr"`
+	// CertFile and KeyFile are the PEM files of the TLS certificate and its private key, the
+	// listener serves plaintext if not set
+	CertFile string `yaml:"CertFile,omitempty"`
+	KeyFile  string `yaml:"KeyFile,omitempty"`
 }
 
 // Config holds all the config read from yaml config file.
 type Config struct {
 	UseTestMasterKey bool `yaml:"UseTestMasterKey,omitempty"` // when UseTestMasterKey use default empty masterKey
+	// InsecureDevMode allows the insecure features like UseTestMasterKey along with the
+	// SQLIT_INSECURE_DEV_MODE env, it's refused on the mainnet
+	InsecureDevMode bool `yaml:"InsecureDevMode,omitempty"`
 	// StartupSyncHoles indicates synchronizing hole blocks from other peers on BP
 	// startup/reloading.
 	StartupSyncHoles bool `yaml:"StartupSyncHoles,omitempty"`
-	GenerateKeyPair  bool `yaml:"-"`
+	// StartupSyncConcurrency is the max count of the hole heights synchronized concurrently on
+	// BP startup, the default one is used if it's not positive.
+	StartupSyncConcurrency int  `yaml:"StartupSyncConcurrency,omitempty"`
+	GenerateKeyPair        bool `yaml:"-"`
 	//TODO(auxten): set yaml key for config
 	WorkingRoot        string            `yaml:"WorkingRoot"`
 	PubKeyStoreFile    string            `yaml:"PubKeyStoreFile"`
@@ -104,6 +455,9 @@ type Config struct {
 	DHTFileName        string            `yaml:"DHTFileName"`
 	ListenAddr         string            `yaml:"ListenAddr"`
 	ListenDirectAddr   string            `yaml:"ListenDirectAddr,omitempty"`
+	// ProxyProtocol requires the PROXY protocol v2 header on the connections of the RPC
+	// listeners, for the nodes behind an L4 load balancer
+	ProxyProtocol bool `yaml:"ProxyProtocol,omitempty"`
 	ExternalListenAddr string            `yaml:"-"` // for metric purpose
 	ThisNodeID         proto.NodeID      `yaml:"ThisNodeID"`
 	ValidDNSKeys       map[string]string `yaml:"ValidDNSKeys"` // map[DNSKEY]domain
@@ -111,6 +465,23 @@ type Config struct {
 	MinNodeIDDifficulty int `yaml:"MinNodeIDDifficulty"`
 
 	DNSSeed DNSSeed `yaml:"DNSSeed"`
+	// NodeResolvers defines the node ID resolver chain, empty means using the BP DHT only
+	NodeResolvers []ResolverInfo `yaml:"NodeResolvers,omitempty"`
+	// Registry defines the node registry served by the "jeju" resolver, nil disables the resolver
+	Registry *RegistryInfo `yaml:"Registry,omitempty"`
+	// MTLS defines the mutual TLS of the node connections, nil means ETLS only
+	MTLS *MTLSInfo `yaml:"MTLS,omitempty"`
+	// RemoteSigner delegates the client signing to an external service, nil means the local key
+	RemoteSigner *RemoteSignerInfo `yaml:"RemoteSigner,omitempty"`
+	// SequenceStateFile persists the connection ids and sequence numbers of the client, so that a
+	// restarted client resumes them, empty means they start from random per process
+	SequenceStateFile string `yaml:"SequenceStateFile,omitempty"`
+	// RPCPool defines the limits of the connections to the other nodes, nil means the defaults
+	RPCPool *RPCPoolInfo `yaml:"RPCPool,omitempty"`
+	// GRPC enables the gRPC gateway of the block producer or miner APIs, nil means disabled
+	GRPC *GRPCInfo `yaml:"GRPC,omitempty"`
+	// Log defines the log file of the daemon with its rotation, nil means logging to stderr
+	Log *LogInfo `yaml:"Log,omitempty"`
 
 	BP    *BPInfo    `yaml:"BlockProducer"`
 	Miner *MinerInfo `yaml:"Miner,omitempty"`
@@ -127,6 +498,10 @@ type Config struct {
 	SQLChainTick       time.Duration `yaml:"SQLChainTick"`
 	SQLChainTTL        int32         `yaml:"SQLChainTTL"`
 	MinProviderDeposit uint64        `yaml:"MinProviderDeposit"`
+
+	// FeatureActivations overrides the activation heights of the protocol features, all the
+	// block producers should share the same overrides
+	FeatureActivations map[Feature]uint32 `yaml:"FeatureActivations,omitempty"`
 }
 
 // GConf is the global config pointer.
@@ -174,6 +549,14 @@ func LoadConfig(configPath string) (config *Config, err error) {
 		config.PrivateKeyFile = path.Join(configDir, config.PrivateKeyFile)
 	}
 
+	if config.SequenceStateFile != "" && !path.IsAbs(config.SequenceStateFile) {
+		config.SequenceStateFile = path.Join(configDir, config.SequenceStateFile)
+	}
+
+	if config.Log != nil && config.Log.File != "" && !path.IsAbs(config.Log.File) {
+		config.Log.File = path.Join(configDir, config.Log.File)
+	}
+
 	if !path.IsAbs(config.DHTFileName) {
 		config.DHTFileName = path.Join(configDir, config.DHTFileName)
 	}
@@ -190,6 +573,11 @@ func LoadConfig(configPath string) (config *Config, err error) {
 		config.Miner.RootDir = path.Join(configDir, config.Miner.RootDir)
 	}
 
+	if config.Miner != nil && config.Miner.Backup != nil && config.Miner.Backup.Target != "" &&
+		!strings.Contains(config.Miner.Backup.Target, "://") && !path.IsAbs(config.Miner.Backup.Target) {
+		config.Miner.Backup.Target = path.Join(configDir, config.Miner.Backup.Target)
+	}
+
 	if len(config.KnownNodes) > 0 {
 		for _, node := range config.KnownNodes {
 			if node.ID == config.ThisNodeID {
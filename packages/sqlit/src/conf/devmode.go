@@ -0,0 +1,86 @@
+package conf
+
+import (
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/utils/log"
+)
+
+// InsecureFeature defines a feature which skips the security checks for the local development
+// and the tests, it's only allowed in the insecure dev mode.
+type InsecureFeature string
+
+const (
+	// InsecureTestMasterKey uses the empty master key of the private key file.
+	InsecureTestMasterKey InsecureFeature = "test-master-key"
+	// InsecureBypassSignature bypasses the signature sign and verify.
+	InsecureBypassSignature InsecureFeature = "bypass-signature"
+	// InsecureTestMode bypasses the node ID validation.
+	InsecureTestMode InsecureFeature = "test-mode"
+)
+
+const (
+	// InsecureDevModeEnv is the env which must be set to "1" or "true" along with the
+	// InsecureDevMode config to enable the insecure dev mode.
+	InsecureDevModeEnv = "SQLIT_INSECURE_DEV_MODE"
+	// NetworkEnv is the env of the network which the node runs in.
+	NetworkEnv = "JEJU_NETWORK"
+	// MainnetNetwork is the network name of the mainnet.
+	MainnetNetwork = "mainnet"
+)
+
+var (
+	// ErrInsecureDevModeDisabled indicates that an insecure feature is used without the insecure
+	// dev mode.
+	ErrInsecureDevModeDisabled = errors.New("insecure dev mode is not enabled")
+	// ErrInsecureDevModeOnMainnet indicates that the insecure dev mode is used on the mainnet.
+	ErrInsecureDevModeOnMainnet = errors.New("insecure dev mode is refused on mainnet")
+)
+
+// UsedInsecureFeatures returns the insecure features used by the process, the signature bypass
+// is read from its global flag.
+func UsedInsecureFeatures(testMasterKey, testMode bool) (features []InsecureFeature) {
+	if testMasterKey {
+		features = append(features, InsecureTestMasterKey)
+	}
+	if asymmetric.BypassSignature {
+		features = append(features, InsecureBypassSignature)
+	}
+	if testMode {
+		features = append(features, InsecureTestMode)
+	}
+	return
+}
+
+// CheckInsecureDevMode checks the insecure dev mode before using the insecure features. The dev
+// mode must be enabled by both the InsecureDevMode config and the SQLIT_INSECURE_DEV_MODE env,
+// and it's always refused on the mainnet, so that a development config or a test flag can't be
+// shipped to production by accident.
+func CheckInsecureDevMode(cfg *Config, features ...InsecureFeature) (err error) {
+	if len(features) == 0 {
+		return
+	}
+	var names = make([]string, len(features))
+	for i, v := range features {
+		names[i] = string(v)
+	}
+	var list = strings.Join(names, ",")
+	if strings.EqualFold(strings.TrimSpace(os.Getenv(NetworkEnv)), MainnetNetwork) {
+		return errors.Wrapf(ErrInsecureDevModeOnMainnet, "insecure features %s are used", list)
+	}
+	if cfg == nil || !cfg.InsecureDevMode {
+		return errors.Wrapf(ErrInsecureDevModeDisabled,
+			"insecure features %s require the InsecureDevMode config", list)
+	}
+	if env := strings.ToLower(strings.TrimSpace(os.Getenv(InsecureDevModeEnv))); env != "1" && env != "true" {
+		return errors.Wrapf(ErrInsecureDevModeDisabled,
+			"insecure features %s require the %s=1 env", list, InsecureDevModeEnv)
+	}
+	log.WithField("features", list).Warning(
+		"INSECURE DEV MODE ENABLED: the security checks are skipped, NEVER use it in production")
+	return
+}
@@ -0,0 +1,55 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/asymmetric"
+)
+
+func TestInsecureDevMode(t *testing.T) {
+	Convey("Given the insecure features", t, func() {
+		t.Setenv(InsecureDevModeEnv, "")
+		t.Setenv(NetworkEnv, "")
+		var cfg = &Config{UseTestMasterKey: true}
+
+		So(UsedInsecureFeatures(false, false), ShouldBeEmpty)
+		So(UsedInsecureFeatures(true, true), ShouldResemble,
+			[]InsecureFeature{InsecureTestMasterKey, InsecureTestMode})
+		asymmetric.BypassSignature = true
+		So(UsedInsecureFeatures(false, false), ShouldResemble,
+			[]InsecureFeature{InsecureBypassSignature})
+		asymmetric.BypassSignature = false
+
+		Convey("The check should pass without any feature", func() {
+			So(CheckInsecureDevMode(nil), ShouldBeNil)
+		})
+		Convey("The features should require both the config and the env", func() {
+			err := CheckInsecureDevMode(cfg, InsecureTestMasterKey)
+			So(errors.Cause(err), ShouldEqual, ErrInsecureDevModeDisabled)
+			err = CheckInsecureDevMode(nil, InsecureTestMode)
+			So(errors.Cause(err), ShouldEqual, ErrInsecureDevModeDisabled)
+			cfg.InsecureDevMode = true
+			err = CheckInsecureDevMode(cfg, InsecureTestMasterKey)
+			So(errors.Cause(err), ShouldEqual, ErrInsecureDevModeDisabled)
+			t.Setenv(InsecureDevModeEnv, "1")
+			So(CheckInsecureDevMode(cfg, InsecureTestMasterKey), ShouldBeNil)
+			t.Setenv(InsecureDevModeEnv, "true")
+			So(CheckInsecureDevMode(cfg, InsecureTestMasterKey), ShouldBeNil)
+			cfg.InsecureDevMode = false
+			err = CheckInsecureDevMode(cfg, InsecureTestMasterKey)
+			So(errors.Cause(err), ShouldEqual, ErrInsecureDevModeDisabled)
+		})
+		Convey("The dev mode should be refused on the mainnet", func() {
+			cfg.InsecureDevMode = true
+			t.Setenv(InsecureDevModeEnv, "1")
+			t.Setenv(NetworkEnv, "Mainnet")
+			err := CheckInsecureDevMode(cfg, InsecureBypassSignature)
+			So(errors.Cause(err), ShouldEqual, ErrInsecureDevModeOnMainnet)
+			t.Setenv(NetworkEnv, "testnet")
+			So(CheckInsecureDevMode(cfg, InsecureBypassSignature), ShouldBeNil)
+		})
+	})
+}
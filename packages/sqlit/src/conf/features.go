@@ -0,0 +1,95 @@
+package conf
+
+import (
+	"math"
+	"sort"
+)
+
+// Feature defines a protocol-affecting change, such as a new transaction type or an encoding
+// change, which must be activated at the same block height on all block producers.
+type Feature string
+
+// These features are activated at their heights on the main chain. A new feature should be added
+// with the UnscheduledHeight first, and be scheduled to a future height after all the nodes are
+// upgraded, so that a mixed-version cluster stays consistent during a rolling upgrade.
+const (
+	// FeatureUpdatePermission enables the UpdatePermission transaction.
+	FeatureUpdatePermission Feature = "update-permission"
+	// FeatureIssueKeys enables the IssueKeys transaction.
+	FeatureIssueKeys Feature = "issue-keys"
+	// FeatureCloneDatabase enables the CreateDatabase transaction with a clone source.
+	FeatureCloneDatabase Feature = "clone-database"
+	// FeatureReplaceMiner enables the ReplaceMiner transaction.
+	FeatureReplaceMiner Feature = "replace-miner"
+	// FeatureReplicaPlacement enables the location labels of the ProvideService transaction and
+	// the placement constraints of the CreateDatabase transaction.
+	FeatureReplicaPlacement Feature = "replica-placement"
+	// FeatureExpandDatabase enables the ExpandDatabase transaction.
+	FeatureExpandDatabase Feature = "expand-database"
+	// FeatureDurabilityLevel enables the CreateDatabase transaction with a durability level.
+	FeatureDurabilityLevel Feature = "durability-level"
+)
+
+// UnscheduledHeight is the activation height of a supported but not yet scheduled feature.
+const UnscheduledHeight uint32 = math.MaxUint32
+
+// featureHeights defines the default activation heights of the features supported by this build.
+var featureHeights = map[Feature]uint32{
+	FeatureUpdatePermission: 0,
+	FeatureIssueKeys:        0,
+	FeatureCloneDatabase:    UnscheduledHeight,
+	FeatureReplaceMiner:     UnscheduledHeight,
+	FeatureReplicaPlacement: UnscheduledHeight,
+	FeatureExpandDatabase:   UnscheduledHeight,
+	FeatureDurabilityLevel:  UnscheduledHeight,
+}
+
+// ActivationHeight returns the activation height of feature f, which may be overridden by the
+// FeatureActivations in the global config. It returns ok=false if f is unsupported.
+func ActivationHeight(f Feature) (height uint32, ok bool) {
+	if height, ok = featureHeights[f]; !ok {
+		return
+	}
+	if GConf != nil {
+		if h, overridden := GConf.FeatureActivations[f]; overridden {
+			height = h
+		}
+	}
+	return
+}
+
+// IsFeatureActive returns whether feature f is active at the given block height.
+func IsFeatureActive(f Feature, height uint32) bool {
+	h, ok := ActivationHeight(f)
+	return ok && h != UnscheduledHeight && height >= h
+}
+
+// SupportedFeatures returns the sorted names of the features supported by this build, which are
+// advertised to the block producers in Ping.
+func SupportedFeatures() (features []string) {
+	features = make([]string, 0, len(featureHeights))
+	for f := range featureHeights {
+		features = append(features, string(f))
+	}
+	sort.Strings(features)
+	return
+}
+
+// MissingFeatures returns the sorted scheduled features which are absent in the advertised
+// features of a remote node, such node will diverge from this one after the activation heights.
+func MissingFeatures(advertised []string) (missing []string) {
+	var set = make(map[string]struct{}, len(advertised))
+	for _, v := range advertised {
+		set[v] = struct{}{}
+	}
+	for f := range featureHeights {
+		if h, _ := ActivationHeight(f); h == UnscheduledHeight {
+			continue
+		}
+		if _, ok := set[string(f)]; !ok {
+			missing = append(missing, string(f))
+		}
+	}
+	sort.Strings(missing)
+	return
+}
@@ -0,0 +1,49 @@
+package conf
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFeatures(t *testing.T) {
+	Convey("Given the supported features", t, func() {
+		var origin = GConf
+		GConf = &Config{}
+		defer func() { GConf = origin }()
+		featureHeights["test-feature"] = UnscheduledHeight
+		defer delete(featureHeights, "test-feature")
+
+		So(SupportedFeatures(), ShouldResemble, []string{
+			string(FeatureCloneDatabase), string(FeatureDurabilityLevel), string(FeatureExpandDatabase),
+			string(FeatureIssueKeys), string(FeatureReplaceMiner), string(FeatureReplicaPlacement), "test-feature",
+			string(FeatureUpdatePermission),
+		})
+		Convey("The features should be activated at their heights", func() {
+			So(IsFeatureActive(FeatureIssueKeys, 0), ShouldBeTrue)
+			So(IsFeatureActive("test-feature", 0), ShouldBeFalse)
+			So(IsFeatureActive("test-feature", UnscheduledHeight), ShouldBeFalse)
+			So(IsFeatureActive("unknown-feature", 0), ShouldBeFalse)
+			_, ok := ActivationHeight("unknown-feature")
+			So(ok, ShouldBeFalse)
+		})
+		Convey("The activation heights should be overridden by config", func() {
+			GConf.FeatureActivations = map[Feature]uint32{"test-feature": 100}
+			h, ok := ActivationHeight("test-feature")
+			So(ok, ShouldBeTrue)
+			So(h, ShouldEqual, 100)
+			So(IsFeatureActive("test-feature", 99), ShouldBeFalse)
+			So(IsFeatureActive("test-feature", 100), ShouldBeTrue)
+		})
+		Convey("The missing scheduled features should be reported", func() {
+			So(MissingFeatures(SupportedFeatures()), ShouldBeEmpty)
+			So(MissingFeatures(nil), ShouldResemble, []string{
+				string(FeatureIssueKeys), string(FeatureUpdatePermission),
+			})
+			GConf.FeatureActivations = map[Feature]uint32{"test-feature": 100}
+			So(MissingFeatures([]string{
+				string(FeatureIssueKeys), string(FeatureUpdatePermission),
+			}), ShouldResemble, []string{"test-feature"})
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// ErrInvalidLegacyConfig indicates a legacy config which can't be migrated without editing.
+var ErrInvalidLegacyConfig = errors.New("invalid legacy config")
+
+// legacyKeys are the keys of the legacy CovenantSQL configs which are no longer supported, the
+// "*" element matches all the elements of a list.
+var legacyKeys = [][]string{
+	{"IsTestMode"},
+	{"Miner", "IsTestMode"},
+	{"Miner", "TestFixtures"},
+	{"Miner", "MetricCollectInterval"},
+	{"BlockProducer", "BPGenesisInfo", "BaseAccounts", "*", "StableCoinBalance"},
+	{"BlockProducer", "BPGenesisInfo", "BaseAccounts", "*", "CovenantCoinBalance"},
+}
+
+// legacyValues are the values of the legacy configs which are renamed.
+var legacyValues = []struct {
+	path     []string
+	from, to string
+}{
+	{[]string{"Adapter", "StorageDriver"}, "covenantsql", "sqlit"},
+}
+
+// migratedConfig is the layout of a migrated config file, the adapter section is kept as is.
+type migratedConfig struct {
+	Config  `yaml:",inline"`
+	Adapter yaml.MapSlice `yaml:"Adapter,omitempty"`
+}
+
+// MigrateLegacyConfig converts the data of a legacy CovenantSQL config.yaml to a config of this
+// version. The unsupported keys are dropped and the renamed values are rewritten, both are
+// reported in notes, the order and the relative paths of the rest keys are kept. The result is
+// verified to be loaded without unknown keys.
+func MigrateLegacyConfig(data []byte) (out []byte, notes []string, err error) {
+	var root yaml.MapSlice
+	if err = yaml.Unmarshal(data, &root); err != nil {
+		err = errors.Wrap(err, "unmarshal legacy config failed")
+		return
+	}
+	for _, path := range legacyKeys {
+		var dropped []string
+		root = dropKey(root, path, "", &dropped)
+		for _, v := range dropped {
+			notes = append(notes, fmt.Sprintf("dropped unsupported key %s", v))
+		}
+	}
+	for _, v := range legacyValues {
+		if item := lookupKey(root, v.path); item != nil {
+			if s, ok := item.Value.(string); ok && strings.EqualFold(s, v.from) {
+				item.Value = v.to
+				notes = append(notes, fmt.Sprintf("renamed %s from %s to %s",
+					strings.Join(v.path, "."), s, v.to))
+			}
+		}
+	}
+	if v := lookupKey(root, []string{"UseTestMasterKey"}); v != nil && v.Value == true {
+		notes = append(notes, "UseTestMasterKey requires the InsecureDevMode config now")
+	}
+	if out, err = yaml.Marshal(root); err != nil {
+		return
+	}
+	var cfg migratedConfig
+	if err = yaml.UnmarshalStrict(out, &cfg); err != nil {
+		err = errors.Wrapf(ErrInvalidLegacyConfig, "%v", err)
+		out = nil
+	}
+	return
+}
+
+// dropKey removes the key at path from ms, the dotted paths of the removed keys are appended to
+// dropped.
+func dropKey(ms yaml.MapSlice, path []string, prefix string, dropped *[]string) yaml.MapSlice {
+	var kept = ms[:0]
+	for _, item := range ms {
+		key := fmt.Sprint(item.Key)
+		if key != path[0] {
+			kept = append(kept, item)
+			continue
+		}
+		if len(path) == 1 {
+			*dropped = append(*dropped, prefix+key)
+			continue
+		}
+		item.Value = dropValueKey(item.Value, path[1:], prefix+key+".", dropped)
+		kept = append(kept, item)
+	}
+	return kept
+}
+
+func dropValueKey(v interface{}, path []string, prefix string, dropped *[]string) interface{} {
+	switch v := v.(type) {
+	case yaml.MapSlice:
+		return dropKey(v, path, prefix, dropped)
+	case []interface{}:
+		if path[0] != "*" || len(path) == 1 {
+			return v
+		}
+		for i := range v {
+			v[i] = dropValueKey(v[i], path[1:], fmt.Sprintf("%s%d.", prefix, i), dropped)
+		}
+	}
+	return v
+}
+
+// lookupKey returns the item at path of ms, or nil if not found.
+func lookupKey(ms yaml.MapSlice, path []string) *yaml.MapItem {
+	for i := range ms {
+		if fmt.Sprint(ms[i].Key) != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			return &ms[i]
+		}
+		if sub, ok := ms[i].Value.(yaml.MapSlice); ok {
+			return lookupKey(sub, path[1:])
+		}
+		return nil
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package conf
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const legacyConfig = `IsTestMode: true
+UseTestMasterKey: true
+WorkingRoot: "./"
+PrivateKeyFile: "private.key"
+ListenAddr: "0.0.0.0:15151"
+ThisNodeID: "00000f3b43288fe99831eb533ab77ec455d13e11fc38ec35a42d4edd17aa320d"
+Adapter:
+  ListenAddr: "127.0.0.1:4661"
+  StorageDriver: covenantsql
+BlockProducer:
+  PublicKey: "02c1db96f2ba7e1cb4e9822d12de0f63fb666feb828c7f509e81fab9bd7a34039c"
+  NodeID: 00000000000589366268c274fdc11ec8bdb17e668d2f619555a2e9c1a29c91d8
+  ChainFileName: chain.db
+  BPGenesisInfo:
+    Version: 1
+    BaseAccounts:
+    - Address: ba0ba731c7a76ccef2c1170f42038f7e228dfb474ef0190dfe35d9a37911ed37
+      StableCoinBalance: 100000000
+      CovenantCoinBalance: 100000000
+Miner:
+  IsTestMode: true
+  RootDir: "./data"
+  MetricCollectInterval: 60s
+KnownNodes:
+- ID: 00000000000589366268c274fdc11ec8bdb17e668d2f619555a2e9c1a29c91d8
+  Addr: 127.0.0.1:15151
+  Role: Leader
+`
+
+func TestMigrateLegacyConfig(t *testing.T) {
+	Convey("Given a legacy config", t, func() {
+		out, notes, err := MigrateLegacyConfig([]byte(legacyConfig))
+		So(err, ShouldBeNil)
+		So(notes, ShouldResemble, []string{
+			"dropped unsupported key IsTestMode",
+			"dropped unsupported key Miner.IsTestMode",
+			"dropped unsupported key Miner.MetricCollectInterval",
+			"dropped unsupported key BlockProducer.BPGenesisInfo.BaseAccounts.0.StableCoinBalance",
+			"dropped unsupported key BlockProducer.BPGenesisInfo.BaseAccounts.0.CovenantCoinBalance",
+			"renamed Adapter.StorageDriver from covenantsql to sqlit",
+			"UseTestMasterKey requires the InsecureDevMode config now",
+		})
+
+		var cfg migratedConfig
+		So(yaml.UnmarshalStrict(out, &cfg), ShouldBeNil)
+		So(cfg.PrivateKeyFile, ShouldEqual, "private.key")
+		So(cfg.Miner.RootDir, ShouldEqual, "./data")
+		So(cfg.BP.BPGenesis.BaseAccounts, ShouldHaveLength, 1)
+		So(cfg.KnownNodes, ShouldHaveLength, 1)
+		So(cfg.Adapter, ShouldResemble, yaml.MapSlice{
+			{Key: "ListenAddr", Value: "127.0.0.1:4661"},
+			{Key: "StorageDriver", Value: "sqlit"},
+		})
+
+		Convey("The migrated config should be migrated unchanged", func() {
+			again, notes, err := MigrateLegacyConfig(out)
+			So(err, ShouldBeNil)
+			So(notes, ShouldResemble, []string{
+				"UseTestMasterKey requires the InsecureDevMode config now",
+			})
+			So(string(again), ShouldEqual, string(out))
+		})
+	})
+	Convey("A legacy config with unknown keys should be refused", t, func() {
+		_, _, err := MigrateLegacyConfig([]byte("ListenAddr: 0.0.0.0:15151\nUnknownKey: 1\n"))
+		So(errors.Cause(err), ShouldEqual, ErrInvalidLegacyConfig)
+	})
+}
@@ -78,6 +78,15 @@ func (private *PrivateKey) Sign(hash []byte) (*Signature, error) {
 	return (*Signature)(s), e
 }
 
+// Signer is the interface implemented by the keys signing the hashes, such as a PrivateKey in
+// process or a remote signer which keeps the key in an external service.
+type Signer interface {
+	// PubKey returns the public key verifying the signatures.
+	PubKey() *PublicKey
+	// Sign generates the signature of the 32 bytes hash.
+	Sign(hash []byte) (*Signature, error)
+}
+
 // Verify calls ecdsa.Verify to verify the signature of hash using the public key. It returns true
 // if the signature is valid, false otherwise.
 func (s *Signature) Verify(hash []byte, signee *PublicKey) bool {
@@ -0,0 +1,232 @@
+// Package mtls implements the mutual TLS of the node connections under the ETLS layer, with the
+// certificates rotated from the files or the X.509 SVID of SPIFFE. The ETLS layer authenticates
+// the node IDs, while the mutual TLS enforces the transport policy of the deployment, such as
+// between the proxies and the miners in different trust zones.
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+)
+
+const (
+	// DefaultReloadInterval is the default min interval of checking the rotated files.
+	DefaultReloadInterval = time.Minute
+
+	// SVIDFileName, SVIDKeyFileName and SVIDBundleFileName are the files of the X.509 SVID in
+	// the SPIFFE directory.
+	SVIDFileName       = "svid.pem"
+	SVIDKeyFileName    = "svid_key.pem"
+	SVIDBundleFileName = "svid_bundle.pem"
+
+	// recordTypeHandshake is the first byte of a TLS connection.
+	recordTypeHandshake = 0x16
+)
+
+var (
+	// ErrNoCertificate indicates that no certificate is found in the CA file.
+	ErrNoCertificate = errors.New("no certificate found")
+	// ErrMTLSRequired indicates that an inbound connection is refused without mutual TLS.
+	ErrMTLSRequired = errors.New("mutual tls is required")
+	// ErrInvalidSPIFFEID indicates that the peer certificate has no allowed SPIFFE ID.
+	ErrInvalidSPIFFEID = errors.New("invalid spiffe id")
+)
+
+// Transport wraps the raw connections of the nodes with mutual TLS.
+type Transport struct {
+	src        *source
+	spiffe     bool
+	ids        []string
+	serverName string
+	outbound   bool
+	require    bool
+	timeout    time.Duration
+}
+
+// NewTransport returns a new Transport of the config.
+func NewTransport(cfg *conf.MTLSInfo) (t *Transport, err error) {
+	var (
+		certFile, keyFile, caFile = cfg.CertFile, cfg.KeyFile, cfg.CAFile
+		interval                  = cfg.ReloadInterval
+	)
+	if cfg.SPIFFEDir != "" {
+		certFile = filepath.Join(cfg.SPIFFEDir, SVIDFileName)
+		keyFile = filepath.Join(cfg.SPIFFEDir, SVIDKeyFileName)
+		caFile = filepath.Join(cfg.SPIFFEDir, SVIDBundleFileName)
+	}
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+	t = &Transport{
+		spiffe:     cfg.SPIFFEDir != "",
+		ids:        cfg.SPIFFEIDs,
+		serverName: cfg.ServerName,
+		outbound:   cfg.Outbound,
+		require:    cfg.Require,
+		timeout:    conf.TCPDialTimeout,
+	}
+	if t.src, err = newSource(certFile, keyFile, caFile, interval); err != nil {
+		return nil, errors.Wrap(err, "init mtls transport failed")
+	}
+	return
+}
+
+// Client wraps the connection dialed to the address with mutual TLS if the outbound connections
+// are enabled.
+func (t *Transport) Client(conn net.Conn, addr string) (c net.Conn, err error) {
+	if !t.outbound {
+		return conn, nil
+	}
+	var name = t.serverName
+	if name == "" {
+		if name, _, err = net.SplitHostPort(addr); err != nil {
+			return
+		}
+	}
+	t.src.refresh()
+	var tc = tls.Client(conn, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// The peer is verified with the rotated CA pool by VerifyPeerCertificate
+		InsecureSkipVerify: true,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			return t.src.certificate(), nil
+		},
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return t.verify(raw, name, x509.ExtKeyUsageServerAuth)
+		},
+	})
+	if err = t.handshake(conn, tc); err != nil {
+		return nil, errors.Wrapf(err, "mtls handshake with %s failed", addr)
+	}
+	return tc, nil
+}
+
+// Server accepts the connection with mutual TLS if the peer starts a TLS handshake, the other
+// connections are accepted as is unless the mutual TLS is required.
+func (t *Transport) Server(conn net.Conn) (c net.Conn, err error) {
+	var first [1]byte
+	if err = conn.SetReadDeadline(time.Now().Add(t.timeout)); err != nil {
+		return
+	}
+	if _, err = io.ReadFull(conn, first[:]); err != nil {
+		return
+	}
+	if err = conn.SetReadDeadline(time.Time{}); err != nil {
+		return
+	}
+	var pc = &prefixConn{Conn: conn, prefix: first[:]}
+	if first[0] != recordTypeHandshake {
+		if t.require {
+			return nil, errors.Wrapf(ErrMTLSRequired, "connection from %s", conn.RemoteAddr())
+		}
+		return pc, nil
+	}
+	t.src.refresh()
+	var tc = tls.Server(pc, &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return t.src.certificate(), nil
+		},
+		VerifyPeerCertificate: func(raw [][]byte, _ [][]*x509.Certificate) error {
+			return t.verify(raw, "", x509.ExtKeyUsageClientAuth)
+		},
+	})
+	if err = t.handshake(conn, tc); err != nil {
+		return nil, errors.Wrapf(err, "mtls handshake from %s failed", conn.RemoteAddr())
+	}
+	return tc, nil
+}
+
+func (t *Transport) handshake(conn net.Conn, tc *tls.Conn) (err error) {
+	if err = conn.SetDeadline(time.Now().Add(t.timeout)); err != nil {
+		return
+	}
+	if err = tc.Handshake(); err != nil {
+		return
+	}
+	return conn.SetDeadline(time.Time{})
+}
+
+// verify verifies the peer certificates with the CA pool, and the name or the SPIFFE ID.
+func (t *Transport) verify(raw [][]byte, name string, usage x509.ExtKeyUsage) (err error) {
+	var certs = make([]*x509.Certificate, len(raw))
+	for i, v := range raw {
+		if certs[i], err = x509.ParseCertificate(v); err != nil {
+			return
+		}
+	}
+	if len(certs) == 0 {
+		return errors.Wrap(ErrNoCertificate, "verify peer certificates")
+	}
+	var opts = x509.VerifyOptions{
+		Roots:         t.src.pool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	}
+	for _, v := range certs[1:] {
+		opts.Intermediates.AddCert(v)
+	}
+	if t.spiffe {
+		opts.KeyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	} else {
+		opts.DNSName = name
+	}
+	if _, err = certs[0].Verify(opts); err != nil {
+		return
+	}
+	if t.spiffe {
+		return t.verifySPIFFEID(certs[0])
+	}
+	return
+}
+
+// verifySPIFFEID verifies that the SVID has a single SPIFFE ID allowed by the config.
+func (t *Transport) verifySPIFFEID(cert *x509.Certificate) (err error) {
+	if len(cert.URIs) != 1 || cert.URIs[0].Scheme != "spiffe" {
+		return errors.Wrap(ErrInvalidSPIFFEID, "svid must have a single spiffe uri")
+	}
+	var id = cert.URIs[0]
+	if len(t.ids) == 0 {
+		return
+	}
+	for _, v := range t.ids {
+		if allowed, perr := url.Parse(v); perr == nil && allowed.Host == id.Host &&
+			(strings.Trim(allowed.Path, "/") == "" || allowed.Path == id.Path) {
+			return
+		}
+	}
+	return errors.Wrapf(ErrInvalidSPIFFEID, "spiffe id %s is not allowed", id)
+}
+
+// prefixConn returns the bytes read ahead before the rest of the connection.
+type prefixConn struct {
+	net.Conn
+	prefix []byte
+}
+
+// Read implements net.Conn.Read, the prefix is returned along with a single read of the
+// connection, so that a message sent in a single write is still read at once.
+func (c *prefixConn) Read(b []byte) (n int, err error) {
+	if len(c.prefix) == 0 {
+		return c.Conn.Read(b)
+	}
+	n = copy(b, c.prefix)
+	c.prefix = c.prefix[n:]
+	if n < len(b) {
+		var m int
+		m, err = c.Conn.Read(b[n:])
+		n += m
+	}
+	return
+}
@@ -0,0 +1,271 @@
+package mtls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+var testSerial int64
+
+func newTestCA() (ca *testCA, err error) {
+	ca = &testCA{}
+	if ca.key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		return
+	}
+	testSerial++
+	var tmpl = &x509.Certificate{
+		SerialNumber:          big.NewInt(testSerial),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ca.key.PublicKey, ca.key)
+	if err != nil {
+		return
+	}
+	if ca.cert, err = x509.ParseCertificate(der); err != nil {
+		return
+	}
+	ca.pem = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	return
+}
+
+// issue writes a certificate of the CA, its key and the CA certificate into the files.
+func (ca *testCA) issue(certFile, keyFile, caFile string, uri string) (err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return
+	}
+	testSerial++
+	var tmpl = &x509.Certificate{
+		SerialNumber: big.NewInt(testSerial),
+		Subject:      pkix.Name{CommonName: "test node"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if uri != "" {
+		var u *url.URL
+		if u, err = url.Parse(uri); err != nil {
+			return
+		}
+		tmpl.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		return
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return
+	}
+	// write with a later modification time to be found by the reloading
+	var modTime = time.Now().Add(time.Duration(testSerial) * time.Second)
+	for _, v := range []struct {
+		file string
+		data []byte
+	}{
+		{certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+		{keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})},
+		{caFile, ca.pem},
+	} {
+		if err = os.WriteFile(v.file, v.data, 0600); err != nil {
+			return
+		}
+		if err = os.Chtimes(v.file, modTime, modTime); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func fileConfig(dir string, ca *testCA) (cfg *conf.MTLSInfo, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	cfg = &conf.MTLSInfo{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+		Outbound: true,
+	}
+	err = ca.issue(cfg.CertFile, cfg.KeyFile, cfg.CAFile, "")
+	return
+}
+
+func spiffeConfig(dir string, ca *testCA, id string) (cfg *conf.MTLSInfo, err error) {
+	if err = os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+	cfg = &conf.MTLSInfo{SPIFFEDir: dir, Outbound: true}
+	err = ca.issue(filepath.Join(dir, SVIDFileName), filepath.Join(dir, SVIDKeyFileName),
+		filepath.Join(dir, SVIDBundleFileName), id)
+	return
+}
+
+// exchange sends a message through the transports and returns the errors of both sides.
+func exchange(client, server *Transport) (cerr, serr error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err, err
+	}
+	defer func() { _ = ln.Close() }()
+	var done = make(chan error, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			done <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		var c net.Conn
+		if c, err = server.Server(conn); err != nil {
+			done <- err
+			return
+		}
+		var buf = make([]byte, 5)
+		if _, err = io.ReadFull(c, buf); err == nil && string(buf) != "hello" {
+			err = errors.Errorf("unexpected message %s", buf)
+		}
+		if err == nil {
+			_, err = c.Write(buf)
+		}
+		done <- err
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		return err, <-done
+	}
+	defer func() { _ = conn.Close() }()
+	func() {
+		var c net.Conn
+		if c, cerr = client.Client(conn, ln.Addr().String()); cerr != nil {
+			return
+		}
+		if _, cerr = c.Write([]byte("hello")); cerr != nil {
+			return
+		}
+		var buf = make([]byte, 5)
+		_, cerr = io.ReadFull(c, buf)
+	}()
+	if cerr != nil {
+		_ = conn.Close()
+	}
+	serr = <-done
+	return
+}
+
+func TestTransport(t *testing.T) {
+	Convey("Given the transports with the certificates of a CA", t, func() {
+		var dir = t.TempDir()
+		ca, err := newTestCA()
+		So(err, ShouldBeNil)
+		ccfg, err := fileConfig(filepath.Join(dir, "client"), ca)
+		So(err, ShouldBeNil)
+		scfg, err := fileConfig(filepath.Join(dir, "server"), ca)
+		So(err, ShouldBeNil)
+		client, err := NewTransport(ccfg)
+		So(err, ShouldBeNil)
+		server, err := NewTransport(scfg)
+		So(err, ShouldBeNil)
+
+		Convey("The peers should talk over mutual TLS", func() {
+			cerr, serr := exchange(client, server)
+			So(cerr, ShouldBeNil)
+			So(serr, ShouldBeNil)
+		})
+		Convey("The plain connections should be accepted unless required", func() {
+			client.outbound = false
+			cerr, serr := exchange(client, server)
+			So(cerr, ShouldBeNil)
+			So(serr, ShouldBeNil)
+			server.require = true
+			_, serr = exchange(client, server)
+			So(errors.Cause(serr), ShouldEqual, ErrMTLSRequired)
+		})
+		Convey("The peer of another CA should be refused", func() {
+			other, err := newTestCA()
+			So(err, ShouldBeNil)
+			ocfg, err := fileConfig(filepath.Join(dir, "other"), other)
+			So(err, ShouldBeNil)
+			oclient, err := NewTransport(ocfg)
+			So(err, ShouldBeNil)
+			cerr, serr := exchange(oclient, server)
+			So(cerr, ShouldNotBeNil)
+			So(serr, ShouldNotBeNil)
+		})
+		Convey("The rotated certificates should be reloaded", func() {
+			rotated, err := newTestCA()
+			So(err, ShouldBeNil)
+			err = rotated.issue(ccfg.CertFile, ccfg.KeyFile, ccfg.CAFile, "")
+			So(err, ShouldBeNil)
+			client.src.interval = 0
+			cerr, _ := exchange(client, server)
+			So(cerr, ShouldNotBeNil)
+
+			err = rotated.issue(scfg.CertFile, scfg.KeyFile, scfg.CAFile, "")
+			So(err, ShouldBeNil)
+			server.src.interval = 0
+			cerr, serr := exchange(client, server)
+			So(cerr, ShouldBeNil)
+			So(serr, ShouldBeNil)
+		})
+	})
+	Convey("Given the transports with the SPIFFE SVIDs", t, func() {
+		var dir = t.TempDir()
+		ca, err := newTestCA()
+		So(err, ShouldBeNil)
+		ccfg, err := spiffeConfig(filepath.Join(dir, "proxy"), ca, "spiffe://example.org/proxy")
+		So(err, ShouldBeNil)
+		scfg, err := spiffeConfig(filepath.Join(dir, "miner"), ca, "spiffe://example.org/miner")
+		So(err, ShouldBeNil)
+		ccfg.SPIFFEIDs = []string{"spiffe://example.org/miner"}
+		scfg.SPIFFEIDs = []string{"spiffe://example.org"}
+		client, err := NewTransport(ccfg)
+		So(err, ShouldBeNil)
+		server, err := NewTransport(scfg)
+		So(err, ShouldBeNil)
+
+		Convey("The allowed SPIFFE IDs should be accepted", func() {
+			cerr, serr := exchange(client, server)
+			So(cerr, ShouldBeNil)
+			So(serr, ShouldBeNil)
+		})
+		Convey("The other SPIFFE IDs should be refused", func() {
+			server.ids = []string{"spiffe://other.org"}
+			_, serr := exchange(client, server)
+			So(errors.Cause(serr), ShouldNotBeNil)
+			client.ids = []string{"spiffe://example.org/db"}
+			cerr, _ := exchange(client, server)
+			So(cerr, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,117 @@
+package mtls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/utils/log"
+)
+
+// source keeps the certificate and the CA pool loaded from the files, and reloads them once the
+// files are rotated.
+type source struct {
+	certFile, keyFile, caFile string
+	interval                  time.Duration
+
+	sync.RWMutex
+	cert    *tls.Certificate
+	roots   *x509.CertPool
+	modTime time.Time
+	checked time.Time
+}
+
+func newSource(certFile, keyFile, caFile string, interval time.Duration) (s *source, err error) {
+	s = &source{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		interval: interval,
+	}
+	var modTime time.Time
+	if modTime, err = s.stat(); err != nil {
+		return
+	}
+	if err = s.load(modTime); err != nil {
+		return
+	}
+	s.checked = time.Now()
+	return
+}
+
+// stat returns the latest modification time of the files.
+func (s *source) stat() (modTime time.Time, err error) {
+	for _, v := range []string{s.certFile, s.keyFile, s.caFile} {
+		var fi os.FileInfo
+		if fi, err = os.Stat(v); err != nil {
+			return
+		}
+		if fi.ModTime().After(modTime) {
+			modTime = fi.ModTime()
+		}
+	}
+	return
+}
+
+func (s *source) load(modTime time.Time) (err error) {
+	var (
+		cert  tls.Certificate
+		ca    []byte
+		roots = x509.NewCertPool()
+	)
+	if cert, err = tls.LoadX509KeyPair(s.certFile, s.keyFile); err != nil {
+		return errors.Wrap(err, "load certificate failed")
+	}
+	if ca, err = os.ReadFile(s.caFile); err != nil {
+		return errors.Wrap(err, "load ca certificates failed")
+	}
+	if !roots.AppendCertsFromPEM(ca) {
+		return errors.Wrapf(ErrNoCertificate, "load ca certificates from %s", s.caFile)
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.cert, s.roots, s.modTime = &cert, roots, modTime
+	return
+}
+
+// refresh reloads the files if they are modified since the last load, at most once per interval.
+// A failed reload keeps the loaded ones, as the files may be partially written.
+func (s *source) refresh() {
+	s.Lock()
+	if time.Since(s.checked) < s.interval {
+		s.Unlock()
+		return
+	}
+	s.checked = time.Now()
+	var last = s.modTime
+	s.Unlock()
+
+	modTime, err := s.stat()
+	if err == nil && !modTime.After(last) {
+		return
+	}
+	if err == nil {
+		err = s.load(modTime)
+	}
+	if err != nil {
+		log.WithError(err).Warning("failed to reload mtls certificates")
+		return
+	}
+	log.WithField("cert", s.certFile).Info("reloaded mtls certificates")
+}
+
+func (s *source) certificate() *tls.Certificate {
+	s.RLock()
+	defer s.RUnlock()
+	return s.cert
+}
+
+func (s *source) pool() *x509.CertPool {
+	s.RLock()
+	defer s.RUnlock()
+	return s.roots
+}
@@ -20,8 +20,8 @@ type MarshalHasher interface {
 type HashSignVerifier interface {
 	Hash() hash.Hash
 	SetHash(MarshalHasher) error
-	SignHash(*ca.PrivateKey) error
-	Sign(MarshalHasher, *ca.PrivateKey) error
+	SignHash(ca.Signer) error
+	Sign(MarshalHasher, ca.Signer) error
 	VerifyHash(MarshalHasher) error
 	VerifySignature() error
 	Verify(MarshalHasher) error
@@ -50,7 +50,7 @@ func (i *DefaultHashSignVerifierImpl) SetHash(mh MarshalHasher) (err error) {
 }
 
 // SignHash implements HashSignVerifier.SignHash.
-func (i *DefaultHashSignVerifierImpl) SignHash(signer *ca.PrivateKey) (err error) {
+func (i *DefaultHashSignVerifierImpl) SignHash(signer ca.Signer) (err error) {
 	if i.Signature, err = signer.Sign(i.DataHash[:]); err != nil {
 		return
 	}
@@ -59,7 +59,7 @@ func (i *DefaultHashSignVerifierImpl) SignHash(signer *ca.PrivateKey) (err error
 }
 
 // Sign implements HashSignVerifier.Sign.
-func (i *DefaultHashSignVerifierImpl) Sign(mh MarshalHasher, signer *ca.PrivateKey) (err error) {
+func (i *DefaultHashSignVerifierImpl) Sign(mh MarshalHasher, signer ca.Signer) (err error) {
 	// Set hash
 	if err = i.SetHash(mh); err != nil {
 		return
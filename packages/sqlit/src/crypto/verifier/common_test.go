@@ -32,7 +32,7 @@ type MockObject struct {
 	HSV DefaultHashSignVerifierImpl
 }
 
-func (o *MockObject) Sign(signer *asymmetric.PrivateKey) error {
+func (o *MockObject) Sign(signer asymmetric.Signer) error {
 	return o.HSV.Sign(&o.MockHeader, signer)
 }
 
@@ -1,12 +1,17 @@
 package verifier
 
 import (
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
 	"sqlit/src/marshalhash"
 )
 
+// maxSignatureSize is the max length of a DER encoded signature.
+const maxSignatureSize = 72
+
 // MarshalHash marshals DefaultHashSignVerifierImpl for hash computation
 func (i *DefaultHashSignVerifierImpl) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, i.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 3)
 	b = marshalhash.AppendBytes(b, i.DataHash[:])
 	// Signee (public key)
@@ -24,8 +29,8 @@ func (i *DefaultHashSignVerifierImpl) MarshalHash() ([]byte, error) {
 	return b, nil
 }
 
-// Msgsize returns the estimated size for msgpack encoding
-func (i *DefaultHashSignVerifierImpl) Msgsize() int { return 256 }
-
-
-
+// Msgsize returns an upper bound of the hash encoding size of DefaultHashSignVerifierImpl.
+func (i *DefaultHashSignVerifierImpl) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.BytesSize(hash.HashSize) +
+		marshalhash.BytesSize(asymmetric.PublicKeyBytesLen) + marshalhash.BytesSize(maxSignatureSize)
+}
@@ -0,0 +1,200 @@
+// Package diag captures the diagnostic bundles of a node for the incident support. A bundle is a
+// tar of the sections registered by the node components, besides the built-in goroutine dump,
+// metric snapshot, peer table and health checks.
+//
+// All the sections are collected into the memory before any of them is written, so that a slow
+// reader of the bundle can't stretch the window the snapshot is taken in.
+package diag
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"io"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	rpc "sqlit/src/rpc/mux"
+)
+
+const (
+	// ManifestName is the name of the bundle manifest section.
+	ManifestName = "manifest.json"
+
+	// GoroutinesName is the name of the goroutine dump section.
+	GoroutinesName = "goroutines.txt"
+	// MetricsName is the name of the metric snapshot section.
+	MetricsName = "metrics.json"
+	// PeersName is the name of the peer table section.
+	PeersName = "peers.json"
+	// HealthName is the name of the health check section.
+	HealthName = "health.json"
+)
+
+// Section writes the content of a bundle section to w.
+type Section func(w io.Writer) error
+
+var (
+	sectionsLock sync.RWMutex
+	sections     = map[string]Section{
+		GoroutinesName: writeGoroutines,
+		MetricsName:    writeMetrics,
+		PeersName:      writePeers,
+		HealthName:     writeHealth,
+	}
+)
+
+// Register registers the section name of the bundles, a registered section of the same name is
+// replaced.
+func Register(name string, s Section) {
+	sectionsLock.Lock()
+	defer sectionsLock.Unlock()
+	sections[name] = s
+}
+
+// Unregister removes the section name of the bundles.
+func Unregister(name string) {
+	sectionsLock.Lock()
+	defer sectionsLock.Unlock()
+	delete(sections, name)
+}
+
+// Manifest describes a bundle.
+type Manifest struct {
+	NodeID    proto.NodeID
+	Timestamp time.Time
+	Elapsed   time.Duration
+	Sections  []string
+	// Errors maps the names of the sections failed to be collected to the errors.
+	Errors map[string]string `json:",omitempty"`
+}
+
+// WriteBundle collects the registered sections and writes them as a tar to w. A section failed
+// to be collected is reported in the manifest instead of failing the bundle.
+func WriteBundle(w io.Writer) (err error) {
+	sectionsLock.RLock()
+	var names = make([]string, 0, len(sections))
+	for k := range sections {
+		names = append(names, k)
+	}
+	var collect = make(map[string]Section, len(sections))
+	for k, v := range sections {
+		collect[k] = v
+	}
+	sectionsLock.RUnlock()
+	sort.Strings(names)
+
+	var (
+		start    = time.Now()
+		manifest = &Manifest{Timestamp: start.UTC()}
+		contents = make([][]byte, 0, len(names))
+	)
+	if conf.GConf != nil {
+		manifest.NodeID = conf.GConf.ThisNodeID
+	}
+	for _, name := range names {
+		var buf bytes.Buffer
+		if cerr := collect[name](&buf); cerr != nil {
+			if manifest.Errors == nil {
+				manifest.Errors = make(map[string]string)
+			}
+			manifest.Errors[name] = cerr.Error()
+			continue
+		}
+		manifest.Sections = append(manifest.Sections, name)
+		contents = append(contents, buf.Bytes())
+	}
+	manifest.Elapsed = time.Since(start)
+
+	var tw = tar.NewWriter(w)
+	if err = writeJSONFile(tw, start, ManifestName, manifest); err != nil {
+		return
+	}
+	for i, name := range manifest.Sections {
+		if err = writeFile(tw, start, name, contents[i]); err != nil {
+			return
+		}
+	}
+	err = errors.Wrap(tw.Close(), "close bundle failed")
+	return
+}
+
+func writeFile(tw *tar.Writer, t time.Time, name string, data []byte) (err error) {
+	if err = tw.WriteHeader(&tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(data)),
+		ModTime: t,
+	}); err != nil {
+		return errors.Wrapf(err, "write header of %s failed", name)
+	}
+	_, err = tw.Write(data)
+	return errors.Wrapf(err, "write %s failed", name)
+}
+
+func writeJSONFile(tw *tar.Writer, t time.Time, name string, v interface{}) (err error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return errors.Wrapf(err, "marshal %s failed", name)
+	}
+	return writeFile(tw, t, name, data)
+}
+
+// WriteJSON writes v as the indented JSON to w, it's a helper of the JSON sections.
+func WriteJSON(w io.Writer, v interface{}) error {
+	var enc = json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func writeGoroutines(w io.Writer) error {
+	return pprof.Lookup("goroutine").WriteTo(w, 2)
+}
+
+func writeMetrics(w io.Writer) error {
+	var metrics = make(map[string]json.RawMessage)
+	expvar.Do(func(kv expvar.KeyValue) {
+		metrics[kv.Key] = json.RawMessage(kv.Value.String())
+	})
+	return WriteJSON(w, metrics)
+}
+
+// Peer is an entry of the peer table.
+type Peer struct {
+	NodeID proto.NodeID
+	Addr   string `json:",omitempty"`
+	// Session is the stats of the rpc session pool to the peer, nil if not connected.
+	Session *rpc.NodeStats `json:",omitempty"`
+}
+
+func writePeers(w io.Writer) error {
+	var peers = make(map[proto.NodeID]*Peer)
+	if conf.GConf != nil {
+		// the resolver is initialized from the config
+		for id, addr := range route.GetNodeAddrCacheAll() {
+			peers[id] = &Peer{NodeID: id, Addr: addr}
+		}
+	}
+	for _, v := range rpc.GetSessionPoolInstance().Stats() {
+		var stats = v
+		if p, ok := peers[v.NodeID]; ok {
+			p.Session = &stats
+		} else {
+			peers[v.NodeID] = &Peer{NodeID: v.NodeID, Session: &stats}
+		}
+	}
+	var table = make([]*Peer, 0, len(peers))
+	for _, v := range peers {
+		table = append(table, v)
+	}
+	sort.Slice(table, func(i, j int) bool { return table[i].NodeID < table[j].NodeID })
+	return WriteJSON(w, table)
+}
@@ -0,0 +1,88 @@
+package diag
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+)
+
+func readBundle(data []byte) (files map[string][]byte, err error) {
+	files = make(map[string][]byte)
+	var tr = tar.NewReader(bytes.NewReader(data))
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err == io.EOF {
+			return files, nil
+		} else if err != nil {
+			return
+		}
+		if files[hdr.Name], err = io.ReadAll(tr); err != nil {
+			return
+		}
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	Convey("Given the registered sections", t, func() {
+		Register("test.json", func(w io.Writer) error {
+			return WriteJSON(w, map[string]int{"height": 1})
+		})
+		Register("broken.txt", func(w io.Writer) error {
+			_, _ = io.WriteString(w, "partial")
+			return errors.New("collect failed")
+		})
+		defer Unregister("test.json")
+		defer Unregister("broken.txt")
+
+		var buf bytes.Buffer
+		So(WriteBundle(&buf), ShouldBeNil)
+		files, err := readBundle(buf.Bytes())
+		So(err, ShouldBeNil)
+
+		Convey("The bundle should carry the manifest and the collected sections", func() {
+			var manifest Manifest
+			So(json.Unmarshal(files[ManifestName], &manifest), ShouldBeNil)
+			So(manifest.Sections, ShouldResemble, []string{
+				GoroutinesName, HealthName, MetricsName, PeersName, "test.json",
+			})
+			So(manifest.Errors, ShouldResemble, map[string]string{"broken.txt": "collect failed"})
+			So(files, ShouldNotContainKey, "broken.txt")
+			So(string(files[GoroutinesName]), ShouldContainSubstring, "TestWriteBundle")
+
+			var heights map[string]int
+			So(json.Unmarshal(files["test.json"], &heights), ShouldBeNil)
+			So(heights["height"], ShouldEqual, 1)
+			var metrics map[string]json.RawMessage
+			So(json.Unmarshal(files[MetricsName], &metrics), ShouldBeNil)
+			So(metrics, ShouldContainKey, "memstats")
+			var peers []Peer
+			So(json.Unmarshal(files[PeersName], &peers), ShouldBeNil)
+		})
+		Convey("The unregistered sections should be left out", func() {
+			Unregister("test.json")
+			buf.Reset()
+			So(WriteBundle(&buf), ShouldBeNil)
+			files, err := readBundle(buf.Bytes())
+			So(err, ShouldBeNil)
+			So(files, ShouldNotContainKey, "test.json")
+		})
+	})
+	Convey("The dump requests from the other nodes should be refused", t, func() {
+		var (
+			req  = &DumpStateReq{}
+			resp = &DumpStateResp{}
+		)
+		var caller = proto.NodeID("0000")
+		req.NodeID = caller.ToRawNodeID()
+		err := NewService().DumpState(req, resp)
+		So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+		So(resp.Bundle, ShouldBeNil)
+	})
+}
@@ -0,0 +1,80 @@
+package diag
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// HealthCheck returns nil if the checked component is ready to serve.
+type HealthCheck func() error
+
+// HealthReport is the result of the health checks.
+type HealthReport struct {
+	Healthy bool
+	// Checks maps the names of the checks to "ok" or the errors of the failed checks.
+	Checks map[string]string
+}
+
+var (
+	healthLock   sync.RWMutex
+	healthChecks = make(map[string]HealthCheck)
+)
+
+func writeHealth(w io.Writer) error {
+	return WriteJSON(w, Health())
+}
+
+// RegisterHealth registers the health check name, a registered check of the same name is
+// replaced.
+func RegisterHealth(name string, check HealthCheck) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	healthChecks[name] = check
+}
+
+// UnregisterHealth removes the health check name.
+func UnregisterHealth(name string) {
+	healthLock.Lock()
+	defer healthLock.Unlock()
+	delete(healthChecks, name)
+}
+
+// Health runs the registered health checks, the node is healthy if all of them pass.
+func Health() (report *HealthReport) {
+	healthLock.RLock()
+	var names = make([]string, 0, len(healthChecks))
+	for k := range healthChecks {
+		names = append(names, k)
+	}
+	var checks = make(map[string]HealthCheck, len(healthChecks))
+	for k, v := range healthChecks {
+		checks[k] = v
+	}
+	healthLock.RUnlock()
+	sort.Strings(names)
+
+	report = &HealthReport{Healthy: true, Checks: make(map[string]string, len(names))}
+	for _, name := range names {
+		if err := checks[name](); err != nil {
+			report.Healthy = false
+			report.Checks[name] = err.Error()
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+	return
+}
+
+// HealthHandler serves the health report as JSON, with the status 503 if the node is unhealthy.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report = Health()
+		w.Header().Set("Content-Type", "application/json")
+		if !report.Healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = WriteJSON(w, report)
+	})
+}
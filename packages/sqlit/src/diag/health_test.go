@@ -0,0 +1,46 @@
+package diag
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHealth(t *testing.T) {
+	Convey("Given the registered health checks", t, func() {
+		var syncing = true
+		RegisterHealth("ready", func() error { return nil })
+		RegisterHealth("sync", func() error {
+			if syncing {
+				return errors.New("3/10 synchronized")
+			}
+			return nil
+		})
+		defer UnregisterHealth("ready")
+		defer UnregisterHealth("sync")
+
+		Convey("The node should be unhealthy if any check fails", func() {
+			var rec = httptest.NewRecorder()
+			HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			So(rec.Code, ShouldEqual, http.StatusServiceUnavailable)
+			var report HealthReport
+			So(json.Unmarshal(rec.Body.Bytes(), &report), ShouldBeNil)
+			So(report.Healthy, ShouldBeFalse)
+			So(report.Checks, ShouldResemble, map[string]string{
+				"ready": "ok",
+				"sync":  "3/10 synchronized",
+			})
+		})
+		Convey("The node should be healthy if all checks pass", func() {
+			syncing = false
+			var rec = httptest.NewRecorder()
+			HealthHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+			So(rec.Code, ShouldEqual, http.StatusOK)
+			So(Health().Healthy, ShouldBeTrue)
+		})
+	})
+}
@@ -0,0 +1,48 @@
+package diag
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+)
+
+// ErrPermissionDeny indicates a dump request not from the node itself.
+var ErrPermissionDeny = errors.New("permission deny")
+
+// DumpStateReq defines the request to capture a diagnostic bundle of a node.
+type DumpStateReq struct {
+	proto.Envelope
+}
+
+// DumpStateResp defines the response of a dump state request.
+type DumpStateResp struct {
+	// Bundle is the tar of the diagnostic bundle.
+	Bundle []byte
+}
+
+// Service is the diagnostic rpc service of a node.
+type Service struct{}
+
+// NewService returns a new diagnostic rpc service.
+func NewService() *Service {
+	return &Service{}
+}
+
+// DumpState rpc, called by the node operator with the private key of the node to capture a
+// diagnostic bundle, the bundle may carry the queries of the databases so it's never served to
+// the other nodes.
+func (s *Service) DumpState(req *DumpStateReq, resp *DumpStateResp) (err error) {
+	var caller = req.GetNodeID().ToNodeID()
+	if localID, lerr := kms.GetLocalNodeID(); lerr != nil || caller != localID {
+		return errors.Wrapf(ErrPermissionDeny, "calling from node %s is not permitted", caller)
+	}
+	var buf bytes.Buffer
+	if err = WriteBundle(&buf); err != nil {
+		return
+	}
+	resp.Bundle = buf.Bytes()
+	return
+}
@@ -0,0 +1,178 @@
+package dpos
+
+import (
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"github.com/xwb1989/sqlparser"
+
+	"sqlit/src/types"
+)
+
+// preparedQuery is a write query converted and ready to execute.
+type preparedQuery struct {
+	containsDDL bool
+	pattern     string
+	args        []interface{}
+	insert      *singleRowInsert // the single row insert form if it may be coalesced
+}
+
+// replayTx is a write request of a block to replay.
+type replayTx struct {
+	index   int
+	offset  uint64
+	tracker *QueryTracker
+	queries []*preparedQuery
+	// barrier indicates the request may change the schema, e.g. for DDL or unparsed statements
+	barrier bool
+	err     error
+}
+
+// prepare converts the queries of t.
+func (t *replayTx) prepare() {
+	var (
+		req    = t.tracker.Req
+		header = &t.tracker.Resp.Header.ResponseHeader
+	)
+	t.queries = make([]*preparedQuery, len(req.Payload.Queries))
+	for i, v := range req.Payload.Queries {
+		var (
+			p   = &preparedQuery{}
+			ids = newIDSource(header.RequestHash, header.LogOffset, header.Timestamp, i)
+		)
+		if p.containsDDL, p.pattern, p.args, t.err = convertQueryAndBuildArgs(
+			substituteIDFunctions(v.Pattern, ids), v.Args,
+		); t.err != nil {
+			t.err = errors.Wrapf(t.err, "execute at %d:%d failed", t.index, i)
+			return
+		}
+		p.insert = parseSingleRowInsert(p)
+		t.queries[i] = p
+		if p.containsDDL || !isPlainWrite(v.Pattern) {
+			t.barrier = true
+		}
+	}
+}
+
+// isPlainWrite returns whether the statements in pattern are all plain data reads or writes, it
+// returns false if any statement cannot be analyzed.
+func isPlainWrite(pattern string) bool {
+	for _, q := range strings.Split(pattern, ";") {
+		if q = strings.TrimSpace(q); q == "" {
+			continue
+		}
+		stmt, err := sqlparser.Parse(q)
+		if err != nil {
+			return false
+		}
+		switch stmt.(type) {
+		case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete, *sqlparser.Select:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// SetApplyConcurrency sets the max count of requests prepared in parallel for replaying, values
+// less than 2 disable parallel preparing.
+func (s *State) SetApplyConcurrency(n int) {
+	s.Lock()
+	defer s.Unlock()
+	s.applyConcurrency = n
+}
+
+// hasHiddenDependencies returns whether writes may touch tables not referenced by the statements,
+// through triggers or foreign key actions.
+func hasHiddenDependencies(tx *sql.Tx) bool {
+	var count, fk int
+	if err := tx.QueryRow(
+		`SELECT COUNT(1) FROM "sqlite_master" WHERE "type" = 'trigger'`,
+	).Scan(&count); err != nil || count > 0 {
+		return true
+	}
+	if err := tx.QueryRow(`PRAGMA foreign_keys`).Scan(&fk); err != nil || fk != 0 {
+		return true
+	}
+	return false
+}
+
+func (s *State) execPrepared(p *preparedQuery) (res sql.Result, err error) {
+	if res, err = s.handler.Exec(p.pattern, p.args...); err == nil {
+		if p.containsDDL {
+			atomic.StoreUint32(&s.hasSchemaChange, 1)
+		}
+		s.incSeq()
+	}
+	return
+}
+
+func (s *State) applyTx(t *replayTx) (err error) {
+	for j, p := range t.queries {
+		if _, err = s.execPrepared(p); err != nil {
+			return errors.Wrapf(err, "execute at %d:%d failed", t.index, j)
+		}
+	}
+	return
+}
+
+// applyReplay prepares the requests in parallel and applies them in order. The caller must hold
+// the state lock.
+//
+// NOTE: sqlite allows a single writer, so only the query conversion and the argument decoding
+// are parallelized, the statements are executed serially by the underlying connection.
+func (s *State) applyReplay(txs []*replayTx) (err error) {
+	var n = s.applyConcurrency
+	if n > len(txs) {
+		n = len(txs)
+	}
+	if n > 1 {
+		var (
+			wg sync.WaitGroup
+			ch = make(chan *replayTx)
+		)
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for t := range ch {
+					t.prepare()
+				}
+			}()
+		}
+		for _, t := range txs {
+			ch <- t
+		}
+		close(ch)
+		wg.Wait()
+	}
+
+	var c = s.newInsertCoalescer()
+	for _, t := range txs {
+		if n <= 1 {
+			t.prepare()
+		}
+		if t.err != nil {
+			if err = c.flush(); err != nil {
+				return
+			}
+			return t.err
+		}
+		if err = c.apply(t); err != nil {
+			return
+		}
+	}
+	return c.flush()
+}
+
+// newReplayTx returns a replayTx for the write request q at index of a block.
+func newReplayTx(index int, offset uint64, q *types.QueryAsTx) *replayTx {
+	return &replayTx{
+		index:   index,
+		offset:  offset,
+		tracker: &QueryTracker{Req: q.Request, Resp: &types.Response{Header: *q.Response}},
+	}
+}
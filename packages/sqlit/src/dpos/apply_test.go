@@ -0,0 +1,91 @@
+package dpos
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+func TestIsPlainWrite(t *testing.T) {
+	Convey("Plain data writes should be detected", t, func() {
+		So(isPlainWrite("INSERT INTO t1 (k, v) VALUES (?, ?); UPDATE T2 SET v = 1"), ShouldBeTrue)
+		So(isPlainWrite("DELETE FROM t3 WHERE k IN (SELECT k FROM t4)"), ShouldBeTrue)
+		So(isPlainWrite("CREATE TABLE t5 (k INT)"), ShouldBeFalse)
+		So(isPlainWrite("PRAGMA foreign_keys = ON"), ShouldBeFalse)
+		So(isPlainWrite("not a statement"), ShouldBeFalse)
+	})
+}
+
+func TestParallelReplayBlock(t *testing.T) {
+	Convey("Given a leader and a follower state", t, func() {
+		var (
+			fl1 = path.Join(testingDataDir, t.Name()+"x1")
+			fl2 = path.Join(testingDataDir, t.Name()+"x2")
+			st  [2]*State
+		)
+		for i, fl := range []string{fl1, fl2} {
+			strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+			So(err, ShouldBeNil)
+			st[i] = NewState(sql.LevelReadUncommitted, nodeID, strg)
+		}
+		st[1].SetApplyConcurrency(4)
+		Reset(func() {
+			for i, fl := range []string{fl1, fl2} {
+				So(st[i].Close(false), ShouldBeNil)
+				for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+					err := os.Remove(f)
+					So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+				}
+			}
+		})
+
+		var reqs = []*types.Request{
+			buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`CREATE TABLE t1 (k INT, v TEXT, PRIMARY KEY(k))`),
+				buildQuery(`CREATE TABLE t2 (k INT, v TEXT, PRIMARY KEY(k))`),
+			}),
+		}
+		for i := 0; i < 20; i++ {
+			reqs = append(reqs, buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(fmt.Sprintf(`INSERT INTO t%d (k, v) VALUES (?, ?)`, i%2+1), i, fmt.Sprint("v", i)),
+			}))
+		}
+		reqs = append(reqs, buildRequest(types.WriteQuery, []types.Query{
+			buildQuery(`UPDATE t1 SET v = (SELECT COUNT(1) FROM t2) WHERE k = 0`),
+		}))
+
+		var block = &types.Block{}
+		for _, req := range reqs {
+			qt, resp, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			qt.UpdateResp(resp)
+			block.QueryTxs = append(block.QueryTxs, &types.QueryAsTx{
+				Request:  req,
+				Response: &resp.Header,
+			})
+		}
+
+		Convey("The follower should reach the same state with parallel preparing", func() {
+			So(st[1].ReplayBlock(block), ShouldBeNil)
+			So(st[1].getSeq(), ShouldEqual, st[0].getSeq())
+			for _, q := range []string{
+				`SELECT k, v FROM t1 ORDER BY k`, `SELECT k, v FROM t2 ORDER BY k`,
+			} {
+				var req = buildRequest(types.ReadQuery, []types.Query{buildQuery(q)})
+				_, resp1, err := st[0].Query(req, true)
+				So(err, ShouldBeNil)
+				_, resp2, err := st[1].Query(req, true)
+				So(err, ShouldBeNil)
+				So(resp2.Payload, ShouldResemble, resp1.Payload)
+				So(resp2.Header.RowCount, ShouldEqual, 10)
+			}
+		})
+	})
+}
@@ -0,0 +1,275 @@
+package dpos
+
+import (
+	"database/sql"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xwb1989/sqlparser"
+
+	"sqlit/src/utils/log"
+)
+
+const (
+	// maxCoalescedRows is the max count of rows of a coalesced INSERT statement.
+	maxCoalescedRows = 500
+	// maxCoalescedArgs is the max count of arguments of a coalesced INSERT statement, which is the
+	// conservative SQLITE_MAX_VARIABLE_NUMBER of the older sqlite builds.
+	maxCoalescedArgs = 999
+)
+
+// singleRowInsert is a write query inserting a single row of literals and positional arguments,
+// which may be coalesced with the following ones sharing the same prefix.
+type singleRowInsert struct {
+	// prefix is the statement up to and including the VALUES keyword
+	prefix string
+	// row is the parenthesized values of the row
+	row string
+}
+
+// sqlName matches a plain or quoted identifier.
+const sqlName = "(?:\"[^\"]*\"|`[^`]*`|\\[[^\\]]*\\]|[a-z_][a-z0-9_]*)"
+
+// insertPrefix matches the prefix of a plain INSERT or REPLACE statement up to the VALUES keyword.
+var insertPrefix = regexp.MustCompile(`(?is)^(?:insert|replace)\s+into\s+` +
+	sqlName + `(?:\s*\.\s*` + sqlName + `)?\s*` +
+	`(?:\(\s*` + sqlName + `(?:\s*,\s*` + sqlName + `)*\s*\)\s*)?values$`)
+
+// parseSingleRowInsert returns the single row insert form of the converted query p, or nil if p
+// may not be coalesced.
+func parseSingleRowInsert(p *preparedQuery) *singleRowInsert {
+	if p.containsDDL {
+		return nil
+	}
+	for _, v := range p.args {
+		if arg, ok := v.(sql.NamedArg); ok && arg.Name != "" {
+			return nil
+		}
+	}
+	prefix, row, nargs, ok := splitValues(p.pattern)
+	if !ok || nargs != len(p.args) || nargs > maxCoalescedArgs || !insertPrefix.MatchString(prefix) {
+		return nil
+	}
+	// the row must be made of literals, so that the coalesced statement evaluates the rows the
+	// same way as the original statements
+	stmt, err := sqlparser.Parse("INSERT INTO t VALUES " + row)
+	if err != nil {
+		return nil
+	}
+	ins, ok := stmt.(*sqlparser.Insert)
+	if !ok {
+		return nil
+	}
+	rows, ok := ins.Rows.(sqlparser.Values)
+	if !ok || len(rows) != 1 {
+		return nil
+	}
+	for _, expr := range rows[0] {
+		if !isLiteral(expr) {
+			return nil
+		}
+	}
+	return &singleRowInsert{prefix: prefix, row: row}
+}
+
+func isLiteral(expr sqlparser.Expr) bool {
+	switch e := expr.(type) {
+	case *sqlparser.SQLVal, *sqlparser.NullVal, sqlparser.BoolVal:
+		return true
+	case *sqlparser.UnaryExpr:
+		_, ok := e.Expr.(*sqlparser.SQLVal)
+		return ok && (e.Operator == sqlparser.UMinusStr || e.Operator == sqlparser.UPlusStr)
+	default:
+		return false
+	}
+}
+
+// splitValues splits the single INSERT statement pattern at its VALUES keyword, it returns the
+// prefix up to and including the keyword, the parenthesized row and the count of the positional
+// arguments of the row. The statements with comments, backslashes, numbered or named parameters,
+// or anything after the row are refused, so that the sqlite and mysql dialects agree on them.
+func splitValues(pattern string) (prefix, row string, nargs int, ok bool) {
+	var (
+		valuesAt = -1
+		rowEnd   = -1
+		depth    int
+	)
+	pattern = strings.TrimSpace(pattern)
+	for i := 0; i < len(pattern); {
+		if c := pattern[i]; rowEnd >= 0 {
+			// nothing but the trailing spaces may follow the row
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				return
+			}
+			i++
+			continue
+		}
+		switch c := pattern[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			for i++; i < len(pattern) && pattern[i] != end; i++ {
+				if pattern[i] == '\\' {
+					return
+				}
+			}
+			if i >= len(pattern) {
+				return
+			}
+			i++
+		case c == '\\' || c == ';' || c == ':' || c == '@' || c == '$' ||
+			c == '-' && strings.HasPrefix(pattern[i:], "--") ||
+			c == '/' && strings.HasPrefix(pattern[i:], "/*"):
+			return
+		case c == '?':
+			if valuesAt < 0 || i+1 < len(pattern) && pattern[i+1] >= '0' && pattern[i+1] <= '9' {
+				return
+			}
+			nargs++
+			i++
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth--; depth < 0 {
+				return
+			}
+			if depth == 0 && valuesAt >= 0 {
+				rowEnd = i + 1
+			}
+			i++
+		case isIdentChar(c) && (c < '0' || c > '9'):
+			start := i
+			for i < len(pattern) && isIdentChar(pattern[i]) {
+				i++
+			}
+			if depth == 0 && strings.EqualFold(pattern[start:i], "values") {
+				if valuesAt >= 0 {
+					return
+				}
+				valuesAt = i
+			}
+		default:
+			i++
+		}
+	}
+	if valuesAt < 0 || rowEnd < 0 || depth != 0 {
+		return
+	}
+	row = strings.TrimSpace(pattern[valuesAt:rowEnd])
+	if !strings.HasPrefix(row, "(") {
+		return
+	}
+	return strings.TrimSpace(pattern[:valuesAt]), row, nargs, true
+}
+
+// pendingInsert is a single row insert waiting to be coalesced.
+type pendingInsert struct {
+	index, offset int // position of the query in the block for error reporting
+	query         *preparedQuery
+	insert        *singleRowInsert
+}
+
+// insertCoalescer applies the write queries of a block in order, the consecutive single row
+// inserts sharing the same prefix are applied as a multi-row statement to reduce the per-statement
+// overhead. The state sequence is still advanced for each coalesced query.
+type insertCoalescer struct {
+	s       *State
+	enabled bool
+	pending []*pendingInsert
+	nargs   int
+}
+
+// newInsertCoalescer returns the coalescer of s. The coalescing is disabled if the writes may have
+// hidden dependencies, in which case the multi-row statements may behave differently, e.g. for
+// the foreign key constraints checked at the end of each statement.
+func (s *State) newInsertCoalescer() (c *insertCoalescer) {
+	c = &insertCoalescer{s: s}
+	c.refresh()
+	return
+}
+
+func (c *insertCoalescer) refresh() {
+	tx, ok := c.s.handler.(*sql.Tx)
+	c.enabled = ok && !hasHiddenDependencies(tx)
+}
+
+// apply applies or pends the queries of t.
+func (c *insertCoalescer) apply(t *replayTx) (err error) {
+	if !c.enabled || t.barrier {
+		if err = c.flush(); err != nil {
+			return
+		}
+		if err = c.s.applyTx(t); err != nil {
+			return
+		}
+		if t.barrier {
+			// the schema may have been changed
+			c.refresh()
+		}
+		return
+	}
+	for j, p := range t.queries {
+		var ins = p.insert
+		if ins == nil {
+			if err = c.flush(); err != nil {
+				return
+			}
+			if _, err = c.s.execPrepared(p); err != nil {
+				return errors.Wrapf(err, "execute at %d:%d failed", t.index, j)
+			}
+			continue
+		}
+		if len(c.pending) > 0 && (c.pending[0].insert.prefix != ins.prefix ||
+			len(c.pending) >= maxCoalescedRows || c.nargs+len(p.args) > maxCoalescedArgs) {
+			if err = c.flush(); err != nil {
+				return
+			}
+		}
+		c.pending = append(c.pending, &pendingInsert{index: t.index, offset: j, query: p, insert: ins})
+		c.nargs += len(p.args)
+	}
+	return
+}
+
+// flush applies the pending inserts. If the coalesced statement fails, which is rolled back as a
+// whole, the inserts are applied one by one to reproduce the original result.
+func (c *insertCoalescer) flush() (err error) {
+	var pending = c.pending
+	c.pending, c.nargs = nil, 0
+	switch len(pending) {
+	case 0:
+		return
+	case 1:
+	default:
+		var (
+			rows = make([]string, len(pending))
+			args = make([]interface{}, 0, len(pending))
+		)
+		for i, v := range pending {
+			rows[i] = v.insert.row
+			args = append(args, v.query.args...)
+		}
+		var (
+			pattern = pending[0].insert.prefix + " " + strings.Join(rows, ", ")
+			ierr    error
+		)
+		if _, ierr = c.s.handler.Exec(pattern, args...); ierr == nil {
+			for range pending {
+				c.s.incSeq()
+			}
+			return
+		}
+		log.WithError(ierr).WithField("count", len(pending)).Debug(
+			"coalesced insert failed, applying one by one")
+	}
+	for _, v := range pending {
+		if _, err = c.s.execPrepared(v.query); err != nil {
+			return errors.Wrapf(err, "execute at %d:%d failed", v.index, v.offset)
+		}
+	}
+	return
+}
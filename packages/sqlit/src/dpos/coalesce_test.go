@@ -0,0 +1,130 @@
+package dpos
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+func TestParseSingleRowInsert(t *testing.T) {
+	Convey("The single row inserts of literals should be split at the VALUES keyword", t, func() {
+		var newQuery = func(pattern string, args ...interface{}) *preparedQuery {
+			var p = &preparedQuery{pattern: pattern}
+			for _, v := range args {
+				p.args = append(p.args, sql.NamedArg{Value: v})
+			}
+			return p
+		}
+		ins := parseSingleRowInsert(newQuery(`INSERT INTO "t1" ("k", "values") VALUES (?, 'a)?') `, 1))
+		So(ins, ShouldNotBeNil)
+		So(ins.prefix, ShouldEqual, `INSERT INTO "t1" ("k", "values") VALUES`)
+		So(ins.row, ShouldEqual, `(?, 'a)?')`)
+		ins = parseSingleRowInsert(newQuery(`replace into t1 values (-1, NULL, 2.5, ?, ?)`, 1, "x"))
+		So(ins, ShouldNotBeNil)
+		So(ins.row, ShouldEqual, `(-1, NULL, 2.5, ?, ?)`)
+
+		for _, p := range []*preparedQuery{
+			newQuery(`INSERT INTO t1 (k) VALUES (1), (2)`),
+			newQuery(`INSERT INTO t1 (k) VALUES (abs(?))`, 1),
+			newQuery(`INSERT INTO t1 (k) VALUES ((SELECT MAX(k) FROM t1))`),
+			newQuery(`INSERT INTO t1 (k) SELECT k FROM t2`),
+			newQuery(`INSERT INTO t1 (k) VALUES (?1)`, 1),
+			newQuery(`INSERT INTO t1 (k) VALUES (:k)`, 1),
+			newQuery(`INSERT INTO t1 (k) VALUES (?)`, 1, 2),
+			newQuery(`INSERT INTO t1 (k) VALUES ('a\'), (1) -- ')`),
+			newQuery(`INSERT INTO t1 (k) VALUES (1) ON DUPLICATE KEY UPDATE k = 2`),
+			newQuery(`INSERT INTO t1 (k) VALUES (1); DELETE FROM t1`),
+			newQuery(`INSERT OR IGNORE INTO t1 (k) VALUES (1)`),
+			newQuery(`UPDATE t1 SET k = 1`),
+			{pattern: `INSERT INTO t1 (k) VALUES (?)`, args: []interface{}{sql.Named("k", 1)}},
+			{pattern: `INSERT INTO t1 (k) VALUES (1)`, containsDDL: true},
+		} {
+			So(parseSingleRowInsert(p), ShouldBeNil)
+		}
+	})
+}
+
+func TestCoalescedReplayBlock(t *testing.T) {
+	Convey("Given a leader and a follower state", t, func() {
+		var (
+			fl1 = path.Join(testingDataDir, t.Name()+"x1")
+			fl2 = path.Join(testingDataDir, t.Name()+"x2")
+			st  [2]*State
+		)
+		for i, fl := range []string{fl1, fl2} {
+			strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+			So(err, ShouldBeNil)
+			st[i] = NewState(sql.LevelReadUncommitted, nodeID, strg)
+		}
+		st[1].SetApplyConcurrency(1)
+		Reset(func() {
+			for i, fl := range []string{fl1, fl2} {
+				So(st[i].Close(false), ShouldBeNil)
+				for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+					err := os.Remove(f)
+					So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+				}
+			}
+		})
+
+		var reqs = []*types.Request{
+			buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`CREATE TABLE t1 (k INTEGER PRIMARY KEY AUTOINCREMENT, v TEXT UNIQUE)`),
+			}),
+		}
+		for i := 0; i < 600; i++ {
+			var q = buildQuery(`INSERT INTO t1 (v) VALUES (?)`, fmt.Sprint("v", i))
+			if i%100 == 99 {
+				q = buildQuery(`REPLACE INTO t1 (v) VALUES (?)`, fmt.Sprint("v", i-1))
+			}
+			reqs = append(reqs, buildRequest(types.WriteQuery, []types.Query{q}))
+		}
+		reqs = append(reqs, buildRequest(types.WriteQuery, []types.Query{
+			buildQuery(`UPDATE t1 SET v = 'x' WHERE k = 1`),
+			buildQuery(`INSERT INTO t1 (v) VALUES ('y')`),
+		}))
+
+		var block = &types.Block{}
+		for _, req := range reqs {
+			qt, resp, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			qt.UpdateResp(resp)
+			block.QueryTxs = append(block.QueryTxs, &types.QueryAsTx{
+				Request:  req,
+				Response: &resp.Header,
+			})
+		}
+
+		Convey("The follower should reach the same state by coalesced replaying", func() {
+			So(st[1].ReplayBlock(block), ShouldBeNil)
+			So(st[1].getSeq(), ShouldEqual, st[0].getSeq())
+			var req = buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`SELECT k, v FROM t1 ORDER BY k`),
+			})
+			_, resp1, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			_, resp2, err := st[1].Query(req, true)
+			So(err, ShouldBeNil)
+			So(resp2.Payload, ShouldResemble, resp1.Payload)
+			So(resp2.Header.RowCount, ShouldEqual, 595)
+		})
+		Convey("The failed coalesced inserts should be applied one by one", func() {
+			var req = buildRequest(types.WriteQuery, []types.Query{block.QueryTxs[0].Request.Payload.Queries[0]})
+			_, _, err := st[1].Query(req, true)
+			So(err, ShouldBeNil)
+			_, err = st[1].handler.Exec(`INSERT INTO t1 (v) VALUES ('v3')`)
+			So(err, ShouldBeNil)
+			err = st[1].ReplayBlock(&types.Block{QueryTxs: block.QueryTxs[1:]})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "execute at 3:0 failed")
+			So(st[1].getSeq(), ShouldEqual, 4)
+		})
+	})
+}
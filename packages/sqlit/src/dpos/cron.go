@@ -0,0 +1,166 @@
+package dpos
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// maxCronSearch bounds the search of the next time of a schedule, which covers the leap days.
+const maxCronSearch = 5 // years
+
+var (
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 1 1 *",
+		"@annually": "0 0 1 1 *",
+		"@monthly":  "0 0 1 * *",
+		"@weekly":   "0 0 * * 0",
+		"@daily":    "0 0 * * *",
+		"@midnight": "0 0 * * *",
+		"@hourly":   "0 * * * *",
+	}
+	cronMonths = []string{
+		"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec",
+	}
+	cronWeekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// cronField defines the value range and the names of a field of the cron expressions.
+type cronField struct {
+	name     string
+	min, max uint
+	names    []string // the names of the values from min
+}
+
+var cronFields = [5]cronField{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: cronMonths},
+	// 7 is sunday too
+	{name: "day of week", min: 0, max: 7, names: cronWeekdays},
+}
+
+// CronSchedule is a parsed cron expression with the fields minute, hour, day of month, month and
+// day of week, which is evaluated in UTC so that every miner computes the same times.
+type CronSchedule struct {
+	fields [5]uint64 // the bits of the matched values
+
+	// a day matches either of the restricted day of month and day of week like the cron does
+	domStar, dowStar bool
+}
+
+// ParseCron parses the five fields cron expression, the fields accept *, the lists, the ranges
+// and the steps such as "*/15 9-17 * * mon-fri", and the months and the days of week accept the
+// names. The descriptors @yearly, @monthly, @weekly, @daily and @hourly are accepted too.
+func ParseCron(spec string) (s *CronSchedule, err error) {
+	var expr = strings.ToLower(strings.TrimSpace(spec))
+	if d, ok := cronDescriptors[expr]; ok {
+		expr = d
+	}
+	var parts = strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, errors.Wrapf(ErrInvalidSchedule, "%s: expected %d fields", spec, len(cronFields))
+	}
+	s = &CronSchedule{domStar: parts[2] == "*", dowStar: parts[4] == "*"}
+	for i, f := range cronFields {
+		if s.fields[i], err = f.parse(parts[i]); err != nil {
+			return nil, errors.Wrapf(err, "%s", spec)
+		}
+	}
+	if s.fields[4]&(1<<7) != 0 {
+		s.fields[4] |= 1
+	}
+	if s.Next(time.Unix(0, 0)).IsZero() {
+		return nil, errors.Wrapf(ErrInvalidSchedule, "%s: never matches", spec)
+	}
+	return
+}
+
+func (f *cronField) parse(s string) (bits uint64, err error) {
+	for _, item := range strings.Split(s, ",") {
+		var (
+			lo, hi        = f.min, f.max
+			step   uint64 = 1
+			rng           = item
+		)
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			if step, err = strconv.ParseUint(item[i+1:], 10, 8); err != nil || step == 0 {
+				return 0, errors.Wrapf(ErrInvalidSchedule, "invalid %s step: %s", f.name, item)
+			}
+			rng = item[:i]
+		}
+		if rng != "*" {
+			var bounds = strings.SplitN(rng, "-", 2)
+			if lo, err = f.value(bounds[0]); err != nil {
+				return
+			}
+			switch {
+			case len(bounds) == 2:
+				if hi, err = f.value(bounds[1]); err != nil {
+					return
+				}
+			case step == 1:
+				hi = lo
+			}
+			if lo > hi {
+				return 0, errors.Wrapf(ErrInvalidSchedule, "invalid %s range: %s", f.name, item)
+			}
+		}
+		for v := lo; v <= hi; v += uint(step) {
+			bits |= 1 << v
+		}
+	}
+	return
+}
+
+func (f *cronField) value(s string) (uint, error) {
+	for i, n := range f.names {
+		if s == n {
+			return f.min + uint(i), nil
+		}
+	}
+	v, err := strconv.ParseUint(s, 10, 8)
+	if err != nil || uint(v) < f.min || uint(v) > f.max {
+		return 0, errors.Wrapf(ErrInvalidSchedule, "invalid %s: %s", f.name, s)
+	}
+	return uint(v), nil
+}
+
+func (s *CronSchedule) match(field int, v int) bool {
+	return s.fields[field]&(1<<uint(v)) != 0
+}
+
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	var (
+		dom = s.match(2, t.Day())
+		dow = s.match(4, int(t.Weekday()))
+	)
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time matching the schedule after t in UTC, or the zero time if there is
+// none in the following years.
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	for limit := t.AddDate(maxCronSearch, 0, 0); t.Before(limit); {
+		switch {
+		case !s.match(3, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !s.matchDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !s.match(1, t.Hour()):
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case !s.match(0, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,212 @@
+package dpos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"sqlit/src/types"
+)
+
+// ResultLimit defines the max size of the result of a read query, 0 means unlimited.
+type ResultLimit struct {
+	MaxRows  uint64
+	MaxBytes uint64
+}
+
+func (l ResultLimit) String() string {
+	return fmt.Sprintf("%d rows/%d bytes", l.MaxRows, l.MaxBytes)
+}
+
+func (l ResultLimit) exceeded(rows, bytes uint64) bool {
+	return (l.MaxRows > 0 && rows > l.MaxRows) || (l.MaxBytes > 0 && bytes > l.MaxBytes)
+}
+
+// forRequest returns the limit of the read request req, the max rows set by the request applies
+// if it's stricter.
+func (l ResultLimit) forRequest(req *types.Request) ResultLimit {
+	if n := req.Header.ResultMaxRows(); n > 0 && (l.MaxRows == 0 || n < l.MaxRows) {
+		l.MaxRows = n
+	}
+	return l
+}
+
+// valueSize estimates the memory size of a value scanned from sqlite.
+func valueSize(v interface{}) uint64 {
+	switch v := v.(type) {
+	case []byte:
+		return uint64(len(v))
+	case string:
+		return uint64(len(v))
+	default:
+		return 8
+	}
+}
+
+// canonicalValue returns the canonical form of a value scanned from sqlite, so that the miners
+// encode an identical result into an identical response hash regardless of the connection
+// location or the floating point unit: times are moved to UTC and all NaNs share one bit pattern.
+func canonicalValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case time.Time:
+		return v.UTC()
+	case float64:
+		if math.IsNaN(v) {
+			return math.NaN()
+		}
+	}
+	return v
+}
+
+func rowSize(row []interface{}) (n uint64) {
+	for _, v := range row {
+		n += valueSize(v)
+	}
+	return
+}
+
+// scanRows scans rows with n columns until the result exceeds limit, the first row beyond the
+// limit is returned as next. The pending row read ahead by the previous scan is put first, and a
+// page always contains at least one row if atLeastOne is set, so that a cursor makes progress.
+func scanRows(
+	ctx context.Context, rows *sql.Rows, n int, limit ResultLimit, pending []interface{},
+	atLeastOne bool,
+) (
+	data [][]interface{}, next []interface{}, err error,
+) {
+	var size uint64
+	data = make([][]interface{}, 0)
+	for {
+		var row = pending
+		pending = nil
+		if row == nil {
+			if !rows.Next() {
+				break
+			}
+			var dest = make([]interface{}, n)
+			row = make([]interface{}, n)
+			for i := range row {
+				dest[i] = &row[i]
+			}
+			if err = rows.Scan(dest...); err != nil {
+				return
+			}
+			for i := range row {
+				row[i] = canonicalValue(row[i])
+			}
+		}
+		size += rowSize(row)
+		if limit.exceeded(uint64(len(data)+1), size) && (len(data) > 0 || !atLeastOne) {
+			next = row
+			return
+		}
+		data = append(data, row)
+	}
+	// the iteration is stopped early by an interrupt on the context
+	if ierr := rows.Err(); ierr != nil && ctx.Err() != nil {
+		err = ierr
+	}
+	return
+}
+
+// Cursor is a server-side cursor of a read query which result exceeds the limit, it keeps the
+// read transaction of the query open until it's exhausted or closed.
+type Cursor struct {
+	sync.Mutex
+	tx     *sql.Tx
+	rows   *sql.Rows
+	cancel context.CancelFunc
+	names  []string
+	types  []string
+	limit  ResultLimit
+	next   []interface{} // the row read ahead by the previous page
+}
+
+// openCursor runs the read query q in tx and returns the first page of the result. If the result
+// exceeds limit, the rest rows are kept in the returned cursor which takes over tx. The query is
+// interrupted if ctx is done before the first page is read.
+func openCursor(
+	ctx context.Context, tx *sql.Tx, q *types.Query, limit ResultLimit,
+) (
+	names []string, types []string, data [][]interface{}, cur *Cursor, err error,
+) {
+	var (
+		rows *sql.Rows
+		next []interface{}
+	)
+	// the rows outlive the request, so they are bound to the cursor instead
+	cctx, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+	if rows, names, types, err = querySingle(cctx, tx, q); err != nil {
+		cancel()
+		return
+	}
+	if data, next, err = scanRows(cctx, rows, len(names), limit, nil, true); err != nil || next == nil {
+		_ = rows.Close()
+		cancel()
+		return
+	}
+	cur = &Cursor{
+		tx:     tx,
+		rows:   rows,
+		cancel: cancel,
+		names:  names,
+		types:  types,
+		limit:  limit,
+		next:   next,
+	}
+	return
+}
+
+// Columns returns the column names and declared types of the result.
+func (c *Cursor) Columns() (names []string, types []string) {
+	return c.names, c.types
+}
+
+// Next returns the next page of the result within the limit, the cursor is closed after the last
+// page or an error. The scan is interrupted if ctx is done.
+func (c *Cursor) Next(ctx context.Context) (data [][]interface{}, last bool, err error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.rows == nil {
+		err = ErrCursorClosed
+		return
+	}
+	stop := context.AfterFunc(ctx, c.cancel)
+	defer stop()
+	if data, c.next, err = scanRows(
+		ctx, c.rows, len(c.names), c.limit, c.next, true,
+	); err != nil || c.next == nil {
+		last = true
+		c.close()
+	}
+	return
+}
+
+// Close closes the cursor and its read transaction.
+func (c *Cursor) Close() {
+	c.Lock()
+	defer c.Unlock()
+	c.close()
+}
+
+func (c *Cursor) close() {
+	if c.rows == nil {
+		return
+	}
+	_ = c.rows.Close()
+	c.cancel()
+	_ = c.tx.Rollback()
+	c.rows, c.next = nil, nil
+}
+
+// SetResultLimit sets the max size of the result of each read query, the default is unlimited.
+func (s *State) SetResultLimit(limit ResultLimit) {
+	s.Lock()
+	defer s.Unlock()
+	s.resultLimit = limit
+}
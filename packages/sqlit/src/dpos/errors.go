@@ -3,6 +3,8 @@ package dpos
 
 import (
 	"errors"
+
+	"sqlit/src/types"
 )
 
 var (
@@ -18,4 +20,29 @@ var (
 	ErrStatefulQueryParts = errors.New("query contains stateful query parts")
 	// ErrInvalidTableName indicates query contains invalid table name in ddl statement.
 	ErrInvalidTableName = errors.New("invalid table name in ddl")
+	// ErrNondeterministicTokenizer indicates the full-text search table uses a tokenizer which may
+	// tokenize differently on the miners.
+	ErrNondeterministicTokenizer = errors.New("nondeterministic full-text search tokenizer")
+	// ErrInvalidTTL indicates the row ttl options of a table are invalid.
+	ErrInvalidTTL = errors.New("invalid table ttl")
+	// ErrInvalidSchedule indicates the cron schedule of a job is invalid.
+	ErrInvalidSchedule = errors.New("invalid job schedule")
+	// ErrInvalidJob indicates the statement creating or dropping a scheduled job is invalid.
+	ErrInvalidJob = errors.New("invalid job statement")
+	// ErrInvalidForeignTable indicates the module arguments of a foreign table are invalid.
+	ErrInvalidForeignTable = errors.New("invalid foreign table")
+	// ErrBackupNotSupported indicates the underlying storage does not support online backup.
+	ErrBackupNotSupported = errors.New("backup not supported by storage")
+	// ErrMaintenanceNotSupported indicates that the underlying storage does not support online
+	// maintenance.
+	ErrMaintenanceNotSupported = errors.New("storage does not support online maintenance")
+	// ErrResultTooLarge indicates that the result of a read query exceeds the limit.
+	ErrResultTooLarge = errors.New(types.ErrCodeResultTooLarge + ": query result exceeds the limit")
+	// ErrCostExceeded indicates that the estimated cost of a query exceeds the budget of the request.
+	ErrCostExceeded = errors.New(types.ErrCodeCostExceeded + ": query cost exceeds the budget")
+	// ErrCursorClosed indicates that the result cursor is exhausted or closed.
+	ErrCursorClosed = errors.New("result cursor closed")
+	// ErrGroupCommitAborted indicates that the transaction shared by the grouped write requests is
+	// rolled back, e.g. when the state is closed without commit.
+	ErrGroupCommitAborted = errors.New("group commit aborted")
 )
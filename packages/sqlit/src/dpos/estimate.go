@@ -0,0 +1,407 @@
+package dpos
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// defaultEqualityRows is the rows assumed to match an equality lookup of an index without the
+	// statistics, which is also assumed by the SQLite planner.
+	defaultEqualityRows = 10
+	// rangeSelectivity is the fraction of rows assumed to match a range lookup of an index.
+	rangeSelectivity = 4
+)
+
+var (
+	// searchCondRe matches the index lookup condition of a plan step, such as "(a=? AND b>?)".
+	searchCondRe = regexp.MustCompile(`\(([^()]*)\)\s*$`)
+	// searchIndexRe matches the index name of a plan step, such as "USING COVERING INDEX ia".
+	searchIndexRe = regexp.MustCompile(`USING (?:COVERING )?INDEX (\S+)`)
+)
+
+// tableSize defines the estimated size of a table.
+type tableSize struct {
+	name  string
+	rows  float64
+	pages float64
+}
+
+// estimator estimates the costs of the queries by their query plans on a read transaction, the
+// sizes of the tables are loaded once by the first query.
+type estimator struct {
+	ctx    context.Context
+	qer    sqlQuerier
+	loaded bool
+	tables map[string]*tableSize // by the lower-cased names
+	stats  map[string][]float64  // the sqlite_stat1 statistics by the lower-cased index names
+}
+
+func newEstimator(ctx context.Context, qer sqlQuerier) *estimator {
+	return &estimator{
+		ctx:    ctx,
+		qer:    qer,
+		tables: make(map[string]*tableSize),
+		stats:  make(map[string][]float64),
+	}
+}
+
+// load loads the row counts of the tables from the sqlite_stat1 statistics, or the max rowids if
+// the tables are not analyzed, which are cheap lookups of the last rows. The pages of the database
+// are shared by the tables in proportion to their rows.
+func (e *estimator) load() (err error) {
+	if e.loaded {
+		return
+	}
+	var names []string
+	if names, err = e.strings(`SELECT "name" FROM "sqlite_master" WHERE "type" = 'table'`); err != nil {
+		return
+	}
+	for _, name := range names {
+		e.tables[strings.ToLower(name)] = &tableSize{name: name, rows: -1}
+	}
+	// the statistics are available after ANALYZE only
+	if rows, serr := e.qer.QueryContext(
+		e.ctx, `SELECT "tbl", "idx", "stat" FROM "sqlite_stat1"`,
+	); serr == nil {
+		for rows.Next() {
+			var tbl, idx, stat sql.NullString
+			if err = rows.Scan(&tbl, &idx, &stat); err != nil {
+				_ = rows.Close()
+				return
+			}
+			var values []float64
+			for _, f := range strings.Fields(stat.String) {
+				if v, perr := strconv.ParseFloat(f, 64); perr == nil {
+					values = append(values, v)
+				}
+			}
+			if t, ok := e.tables[strings.ToLower(tbl.String)]; ok && len(values) > 0 && t.rows < 0 {
+				t.rows = values[0]
+			}
+			if idx.Valid && len(values) > 0 {
+				e.stats[strings.ToLower(idx.String)] = values
+			}
+		}
+		_ = rows.Close()
+	}
+
+	var pageCount, totalRows float64
+	if err = e.scalar(`PRAGMA page_count`, &pageCount); err != nil {
+		return
+	}
+	for _, t := range e.tables {
+		if t.rows < 0 {
+			var maxID sql.NullFloat64
+			if e.scalar(`SELECT MAX(_rowid_) FROM `+QuoteIdent(t.name), &maxID) == nil {
+				t.rows = maxID.Float64
+			} else {
+				// a WITHOUT ROWID table, which has at most as many rows as pages in the worst case
+				t.rows = pageCount
+			}
+		}
+		totalRows += t.rows
+	}
+	for _, t := range e.tables {
+		if totalRows > 0 {
+			t.pages = math.Ceil(pageCount * t.rows / totalRows)
+		}
+	}
+	e.loaded = true
+	return
+}
+
+func (e *estimator) scalar(query string, dest interface{}) error {
+	rows, err := e.qer.QueryContext(e.ctx, query)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = sql.ErrNoRows
+		}
+		return err
+	}
+	return rows.Scan(dest)
+}
+
+func (e *estimator) strings(query string) (values []string, err error) {
+	rows, err := e.qer.QueryContext(e.ctx, query)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var v string
+		if err = rows.Scan(&v); err != nil {
+			return
+		}
+		values = append(values, v)
+	}
+	err = rows.Err()
+	return
+}
+
+// planStep defines a step of a query plan.
+type planStep struct {
+	id, parent int
+	detail     string
+}
+
+// estimate returns the estimated cost of the query, the arguments are bound as the plans may
+// depend on them.
+func (e *estimator) estimate(q types.Query) (cost types.QueryCost, err error) {
+	_, p, args, err := convertQueryAndBuildArgs(q.Pattern, q.Args)
+	if err != nil {
+		return
+	}
+	if err = e.load(); err != nil {
+		return
+	}
+	var rows, pages float64
+	for _, stmt := range strings.Split(p, ";") {
+		if stmt = strings.TrimSpace(stmt); stmt == "" {
+			continue
+		}
+		// the driver binds the leading positional arguments to each statement
+		plan, perr := e.plan(stmt, args)
+		if n := countParams(stmt); n < len(args) {
+			args = args[n:]
+		} else {
+			args = nil
+		}
+		if perr != nil {
+			// the statement can't be planned before the execution
+			log.WithError(perr).Debug("skip estimating statement")
+			continue
+		}
+		r, pg, scans := e.cost(stmt, plan)
+		rows, pages = rows+r, pages+pg
+		for _, s := range scans {
+			if !containsString(cost.FullScans, s) {
+				cost.FullScans = append(cost.FullScans, s)
+			}
+		}
+	}
+	cost.Rows, cost.Pages = uint64(math.Ceil(rows)), uint64(math.Ceil(pages))
+	return
+}
+
+func (e *estimator) plan(stmt string, args []interface{}) (plan []planStep, err error) {
+	rows, err := e.qer.QueryContext(e.ctx, "EXPLAIN QUERY PLAN "+stmt, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var (
+			s       planStep
+			notused int
+		)
+		if err = rows.Scan(&s.id, &s.parent, &notused, &s.detail); err != nil {
+			return
+		}
+		plan = append(plan, s)
+	}
+	err = rows.Err()
+	return
+}
+
+// cost sums the costs of the loops of the plan, the nested loops under the same parent step visit
+// the rows of the inner tables for each row of the outer ones, and a correlated subquery runs for
+// each row of its enclosing loops.
+func (e *estimator) cost(stmt string, plan []planStep) (rows, pages float64, scans []string) {
+	var (
+		steps    = make(map[int]planStep, len(plan))
+		products = make(map[int]float64)
+		product  = func(parent int) float64 {
+			if v, ok := products[parent]; ok {
+				return v
+			}
+			return 1
+		}
+	)
+	for _, s := range plan {
+		steps[s.id] = s
+		var outer = product(s.parent)
+		if p, ok := steps[s.parent]; ok && strings.Contains(p.detail, "CORRELATED") {
+			outer *= product(p.parent)
+		}
+		switch {
+		case strings.HasPrefix(s.detail, "SCAN "), strings.HasPrefix(s.detail, "SEARCH "):
+			r, pg, full := e.loop(stmt, s.detail)
+			rows += outer * r
+			pages += outer * pg
+			if full != "" && !containsString(scans, full) {
+				scans = append(scans, full)
+			}
+			products[s.parent] = product(s.parent) * r
+		case strings.HasPrefix(s.detail, "USE TEMP B-TREE"):
+			// the rows are sorted or deduplicated once more
+			rows += outer
+		}
+	}
+	return
+}
+
+// loop returns the estimated rows and pages visited by a loop of the plan, and the table name if
+// the loop is a full scan.
+func (e *estimator) loop(stmt, detail string) (rows, pages float64, full string) {
+	var (
+		fields = strings.SplitN(detail, " ", 2)
+		name   = fields[1]
+	)
+	if i := strings.Index(name, " USING "); i >= 0 {
+		name = name[:i]
+	}
+	t := e.table(stmt, name)
+	if t == nil {
+		// a subquery, a common table expression or a constant row
+		return 1, 0, ""
+	}
+	if fields[0] == "SCAN" {
+		return t.rows, t.pages, t.name
+	}
+
+	rows = t.rows
+	var eq, ranges int
+	if m := searchCondRe.FindStringSubmatch(detail); m != nil {
+		for _, term := range strings.Split(m[1], " AND ") {
+			if strings.Contains(term, "=") && !strings.ContainsAny(term, "<>") {
+				eq++
+			} else {
+				ranges++
+			}
+		}
+	}
+	switch {
+	case strings.Contains(detail, "AUTOMATIC"):
+		// the automatic index is built from the full table for the query
+	case eq > 0 && ranges == 0 && strings.Contains(detail, "INTEGER PRIMARY KEY"):
+		rows = 1
+	case eq > 0:
+		rows = defaultEqualityRows
+		if m := searchIndexRe.FindStringSubmatch(detail); m != nil {
+			if s := e.stats[strings.ToLower(m[1])]; len(s) > eq {
+				rows = s[eq]
+			}
+		}
+	}
+	if ranges > 0 {
+		rows /= rangeSelectivity
+	}
+	rows = math.Min(rows, t.rows)
+	if t.rows > 0 {
+		pages = math.Ceil(t.pages * rows / t.rows)
+	}
+	return
+}
+
+// table returns the size of the table or the alias of a table in the statement, or nil if name is
+// not a table.
+func (e *estimator) table(stmt, name string) *tableSize {
+	if t, ok := e.tables[strings.ToLower(name)]; ok {
+		return t
+	}
+	re, err := regexp.Compile(`(?i)["` + "`" + `\[]?([\w$]+)["` + "`" + `\]]?\s+(?:as\s+)?["` +
+		"`" + `\[]?` + regexp.QuoteMeta(name) + `\b`)
+	if err != nil {
+		return nil
+	}
+	for _, m := range re.FindAllStringSubmatch(stmt, -1) {
+		if t, ok := e.tables[strings.ToLower(m[1])]; ok {
+			return t
+		}
+	}
+	return nil
+}
+
+// countParams returns the count of the positional parameters "?" of the statement, the quoted
+// strings and identifiers are skipped.
+func countParams(stmt string) (n int) {
+	var quote rune
+	for _, r := range stmt {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'', r == '"', r == '`':
+			quote = r
+		case r == '[':
+			quote = ']'
+		case r == '?':
+			n++
+		}
+	}
+	return
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// EstimateQueries returns the estimated costs of the queries on db by their query plans, the
+// queries are not executed.
+func EstimateQueries(ctx context.Context, db *sql.DB, queries []types.Query) (
+	costs []types.QueryCost, err error,
+) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		err = errors.Wrap(err, "open tx failed")
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	var e = newEstimator(ctx, tx)
+	costs = make([]types.QueryCost, len(queries))
+	for i, q := range queries {
+		if costs[i], err = e.estimate(q); err != nil {
+			err = errors.Wrapf(err, "estimate query at #%d failed", i)
+			return
+		}
+	}
+	return
+}
+
+// Estimate returns the estimated costs of the queries of req on the state seen by the reads.
+func (s *State) Estimate(ctx context.Context, req *types.Request) ([]types.QueryCost, error) {
+	return EstimateQueries(ctx, s.reader(), req.Payload.Queries)
+}
+
+// CheckCost refuses the request if a query of it is estimated to exceed the max cost of the
+// request. The request is not refused if the costs can't be estimated, the queries fail on the
+// execution in that case.
+func (s *State) CheckCost(ctx context.Context, req *types.Request) (err error) {
+	var maxCost = req.Header.MaxCost()
+	if maxCost == 0 {
+		return
+	}
+	costs, err := s.Estimate(ctx, req)
+	if err != nil {
+		log.WithError(err).Debug("failed to estimate the request cost")
+		return nil
+	}
+	for i, c := range costs {
+		if c.Rows > maxCost {
+			return errors.Wrapf(ErrCostExceeded,
+				"query at #%d is estimated to visit %d rows, max %d, full scans: %v",
+				i, c.Rows, maxCost, c.FullScans)
+		}
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package dpos
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+func TestEstimate(t *testing.T) {
+	Convey("Given a state with the tables of 1000 and 10 rows", t, func() {
+		var fl = path.Join(testingDataDir, t.Name())
+		strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		var st = NewState(sql.LevelDefault, nodeID, strg)
+		Reset(func() {
+			So(st.Close(true), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err := os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+
+		var qs = []types.Query{
+			buildQuery(`CREATE TABLE big (id INTEGER PRIMARY KEY, k INT, v TEXT)`),
+			buildQuery(`CREATE INDEX big_k ON big (k)`),
+			buildQuery(`CREATE TABLE small (id INTEGER PRIMARY KEY, name TEXT)`),
+		}
+		for i := 1; i <= 1000; i++ {
+			qs = append(qs, buildQuery(`INSERT INTO big (id, k, v) VALUES (?, ?, ?)`, i, i%10, "v"))
+		}
+		for i := 1; i <= 10; i++ {
+			qs = append(qs, buildQuery(`INSERT INTO small (id, name) VALUES (?, ?)`, i, "n"))
+		}
+		_, _, err = st.Query(buildRequest(types.WriteQuery, qs), true)
+		So(err, ShouldBeNil)
+
+		var estimate = func(query string) types.QueryCost {
+			costs, err := st.Estimate(context.Background(), buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(query),
+			}))
+			So(err, ShouldBeNil)
+			So(costs, ShouldHaveLength, 1)
+			return costs[0]
+		}
+
+		Convey("The full scans should cost the rows of the tables", func() {
+			var c = estimate(`SELECT * FROM big WHERE v = 'v'`)
+			So(c.Rows, ShouldEqual, 1000)
+			So(c.Pages, ShouldBeGreaterThan, 0)
+			So(c.FullScans, ShouldResemble, []string{"big"})
+		})
+		Convey("The index lookups should cost less than the full scans", func() {
+			var c = estimate(`SELECT * FROM big WHERE id = 1`)
+			So(c.Rows, ShouldEqual, 1)
+			So(c.FullScans, ShouldBeEmpty)
+			c = estimate(`SELECT * FROM big WHERE k = 1`)
+			So(c.Rows, ShouldEqual, defaultEqualityRows)
+			So(c.FullScans, ShouldBeEmpty)
+			c = estimate(`SELECT * FROM big WHERE id > 500`)
+			So(c.Rows, ShouldEqual, 250)
+		})
+		Convey("The nested loops should multiply the rows by the aliases", func() {
+			var c = estimate(`SELECT * FROM small AS s JOIN big b ON b.k = s.id WHERE s.name = 'n'`)
+			So(c.Rows, ShouldEqual, 10+10*defaultEqualityRows)
+			So(c.FullScans, ShouldResemble, []string{"small"})
+		})
+		Convey("The statistics should be used after analyzing", func() {
+			_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`ANALYZE`),
+			}), true)
+			So(err, ShouldBeNil)
+			var c = estimate(`SELECT * FROM big WHERE k = 1`)
+			So(c.Rows, ShouldEqual, 100)
+		})
+		Convey("The request exceeding the max cost should be refused", func() {
+			var req = buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`SELECT * FROM big WHERE id = 1`),
+				buildQuery(`SELECT * FROM big`),
+			})
+			So(st.CheckCost(context.Background(), req), ShouldBeNil)
+			So(req.Header.SetMaxCost(100), ShouldBeNil)
+			err = st.CheckCost(context.Background(), req)
+			So(errors.Cause(err), ShouldEqual, ErrCostExceeded)
+			So(err.Error(), ShouldContainSubstring, "query at #1")
+			So(req.Header.SetMaxCost(1000), ShouldBeNil)
+			So(st.CheckCost(context.Background(), req), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,142 @@
+package dpos
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ForeignTableName is the table of the foreign tables declared by the https_json virtual
+	// tables, the leader fetches the endpoints of the tables and records the fetched rows and the
+	// fetch results by the write queries, which are replayed by the followers as is.
+	ForeignTableName = "__sqlit_fdw"
+	// ForeignDataPrefix is the name prefix of the data tables of the foreign tables, a foreign
+	// table is declared as a read-only view of its data table.
+	ForeignDataPrefix = "__sqlit_fdw_"
+	// HTTPSJSONModule is the virtual table module of the foreign tables mapping the https json
+	// endpoints to rows.
+	HTTPSJSONModule = "https_json"
+
+	// DefaultForeignRefresh defines the default interval of fetching the endpoint of a foreign
+	// table.
+	DefaultForeignRefresh = time.Hour
+	// MinForeignRefresh defines the min interval of fetching the endpoint of a foreign table.
+	MinForeignRefresh = time.Minute
+)
+
+var (
+	// foreignTableRe matches the statement declaring a foreign table, such as
+	// CREATE VIRTUAL TABLE rates USING https_json(url='https://...', columns='code TEXT, rate REAL').
+	foreignTableRe = regexp.MustCompile(`(?is)^create\s+virtual\s+table\s+(if\s+not\s+exists\s+)?` +
+		"(\"(?:[^\"]|\"\")+\"|`[^`]+`|\\[[^\\]]+\\]|[^\\s(]+)" +
+		`\s+using\s+` + HTTPSJSONModule + `\s*\((.*)\)\s*$`)
+
+	createForeignTable = `CREATE TABLE IF NOT EXISTS "` + ForeignTableName + `" (` +
+		`"table" TEXT PRIMARY KEY, "url" TEXT NOT NULL, "path" TEXT NOT NULL, ` +
+		`"columns" TEXT NOT NULL, "refresh" INTEGER NOT NULL, "refreshed_at" INTEGER, ` +
+		`"rows" INTEGER, "error" TEXT)`
+)
+
+// ForeignTable defines a foreign table mapping the json array at the path of the response of an
+// https endpoint to rows, the members of the array elements are mapped to the columns by names.
+type ForeignTable struct {
+	Table       string
+	URL         string
+	Path        string // the dot separated members and indexes of the array, empty for the root
+	Columns     string // the column definitions of the table
+	Refresh     time.Duration
+	RefreshedAt time.Time // zero if the endpoint is not fetched yet
+}
+
+// DataTable returns the name of the data table of the foreign table.
+func (t *ForeignTable) DataTable() string {
+	return ForeignDataPrefix + t.Table
+}
+
+// ParseForeignColumns returns the column names of the column definitions of a foreign table.
+func ParseForeignColumns(columns string) (names []string, err error) {
+	for _, def := range splitModuleArgs(columns) {
+		var fields = strings.Fields(def)
+		if len(fields) == 0 {
+			return nil, errors.Wrapf(ErrInvalidForeignTable, "empty column in %s", columns)
+		}
+		var name = unquoteModuleArg(fields[0])
+		if strings.ContainsAny(name, "\"'`[]();") {
+			return nil, errors.Wrapf(ErrInvalidForeignTable, "invalid column name %s", fields[0])
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.Wrap(ErrInvalidForeignTable, "no columns")
+	}
+	return
+}
+
+// sanitizeForeignTable rewrites the statement declaring a foreign table to the data table, the
+// read-only view of the data table by the declared name and the definition in the foreign table
+// list. The other statements are returned as is.
+func sanitizeForeignTable(query string) (queries []string, err error) {
+	m := foreignTableRe.FindStringSubmatch(query)
+	if m == nil {
+		return []string{query}, nil
+	}
+	var t = &ForeignTable{Table: unquoteModuleArg(m[2]), Refresh: DefaultForeignRefresh}
+	if strings.HasPrefix(strings.ToLower(t.Table), "__sqlit") {
+		return nil, errors.Wrapf(ErrInvalidTableName, "%s", t.Table)
+	}
+	for _, arg := range splitModuleArgs(m[3]) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Wrapf(ErrInvalidForeignTable, "invalid module argument: %s", arg)
+		}
+		var value = unquoteModuleArg(strings.TrimSpace(kv[1]))
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "url":
+			t.URL = value
+		case "path":
+			t.Path = value
+		case "columns":
+			t.Columns = value
+		case "refresh":
+			if t.Refresh, err = time.ParseDuration(value); err != nil {
+				return nil, errors.Wrapf(ErrInvalidForeignTable, "refresh %s: %v", value, err)
+			}
+		default:
+			return nil, errors.Wrapf(ErrInvalidForeignTable, "unknown module argument: %s", kv[0])
+		}
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return nil, errors.Wrapf(ErrInvalidForeignTable, "url must be https: %s", t.URL)
+	}
+	if _, err = ParseForeignColumns(t.Columns); err != nil {
+		return
+	}
+	if t.Refresh < MinForeignRefresh || t.Refresh%time.Second != 0 {
+		return nil, errors.Wrapf(ErrInvalidForeignTable,
+			"refresh %s must be whole seconds at least %s", t.Refresh, MinForeignRefresh)
+	}
+
+	var (
+		ifNotExists = ""
+		insert      = `INSERT OR REPLACE`
+		data        = QuoteIdent(t.DataTable())
+	)
+	if m[1] != "" {
+		ifNotExists, insert = `IF NOT EXISTS `, `INSERT OR IGNORE`
+	}
+	return []string{
+		`CREATE TABLE ` + ifNotExists + data + ` (` + t.Columns + `)`,
+		`CREATE VIEW ` + ifNotExists + QuoteIdent(t.Table) + ` AS SELECT * FROM ` + data,
+		createForeignTable,
+		insert + ` INTO "` + ForeignTableName + `" ("table", "url", "path", "columns", "refresh") ` +
+			`VALUES (` + QuoteString(t.Table) + `, ` + QuoteString(t.URL) + `, ` +
+			QuoteString(t.Path) + `, ` + QuoteString(t.Columns) + `, ` +
+			strconv.FormatInt(int64(t.Refresh/time.Second), 10) + `)`,
+	}, nil
+}
@@ -0,0 +1,79 @@
+package dpos
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSanitizeForeignTable(t *testing.T) {
+	Convey("The foreign table should be rewritten to the data table and the view", t, func() {
+		q, err := sanitizeForeignTable(`CREATE VIRTUAL TABLE rates USING https_json(` +
+			`url='https://api.example.com/rates?base=usd', path='data.items', ` +
+			`columns='code TEXT PRIMARY KEY, rate REAL', refresh='10m')`)
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, []string{
+			`CREATE TABLE "__sqlit_fdw_rates" (code TEXT PRIMARY KEY, rate REAL)`,
+			`CREATE VIEW "rates" AS SELECT * FROM "__sqlit_fdw_rates"`,
+			createForeignTable,
+			`INSERT OR REPLACE INTO "__sqlit_fdw" ("table", "url", "path", "columns", "refresh") ` +
+				`VALUES ('rates', 'https://api.example.com/rates?base=usd', 'data.items', ` +
+				`'code TEXT PRIMARY KEY, rate REAL', 600)`,
+		})
+		q, err = sanitizeForeignTable(`create virtual table if not exists "fx rates" using ` +
+			`https_json(url = "https://api.example.com", columns = 'v')`)
+		So(err, ShouldBeNil)
+		So(q[0], ShouldEqual, `CREATE TABLE IF NOT EXISTS "__sqlit_fdw_fx rates" (v)`)
+		So(q[3], ShouldStartWith, `INSERT OR IGNORE`)
+		So(q[3], ShouldEndWith, `'v', 3600)`)
+
+		var s = `CREATE VIRTUAL TABLE docs USING fts5(body)`
+		q, err = sanitizeForeignTable(s)
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, []string{s})
+	})
+	Convey("The invalid foreign tables should be refused", t, func() {
+		for _, s := range []string{
+			`CREATE VIRTUAL TABLE t USING https_json(url='http://example.com', columns='v')`,
+			`CREATE VIRTUAL TABLE t USING https_json(url='https:///x', columns='v')`,
+			`CREATE VIRTUAL TABLE t USING https_json(url='https://example.com')`,
+			`CREATE VIRTUAL TABLE t USING https_json(url='https://example.com', columns='v', refresh='1s')`,
+			`CREATE VIRTUAL TABLE t USING https_json(url='https://example.com', columns='v', method='post')`,
+			`CREATE VIRTUAL TABLE t USING https_json(url='https://example.com', columns='"a(" INT')`,
+		} {
+			_, err := sanitizeForeignTable(s)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidForeignTable)
+		}
+		_, err := sanitizeForeignTable(
+			`CREATE VIRTUAL TABLE __sqlit_x USING https_json(url='https://example.com', columns='v')`)
+		So(errors.Cause(err), ShouldEqual, ErrInvalidTableName)
+	})
+	Convey("The foreign table should be read-only by the declared name", t, func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		containsDDL, p, _, err := convertQueryAndBuildArgs(`CREATE VIRTUAL TABLE rates USING `+
+			`https_json(url='https://api.example.com/rates', columns='code TEXT, rate REAL')`, nil)
+		So(err, ShouldBeNil)
+		So(containsDDL, ShouldBeTrue)
+		_, err = db.Exec(p)
+		So(err, ShouldBeNil)
+
+		_, err = db.Exec(`INSERT INTO "__sqlit_fdw_rates" VALUES ('eur', 0.9)`)
+		So(err, ShouldBeNil)
+		var rate float64
+		So(db.QueryRow(`SELECT rate FROM rates WHERE code = 'eur'`).Scan(&rate), ShouldBeNil)
+		So(rate, ShouldEqual, 0.9)
+		_, err = db.Exec(`INSERT INTO rates VALUES ('gbp', 0.8)`)
+		So(err, ShouldNotBeNil)
+
+		var refresh int64
+		So(db.QueryRow(`SELECT "refresh" FROM "__sqlit_fdw" WHERE "table" = 'rates'`).
+			Scan(&refresh), ShouldBeNil)
+		So(refresh, ShouldEqual, int64(DefaultForeignRefresh/time.Second))
+	})
+}
@@ -0,0 +1,140 @@
+package dpos
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// pinnedFTS5Tokenizer is the tokenizer of the fts5 tables declared without one, the options
+	// of the default tokenizer are written out so that the table is tokenized the same way by
+	// every miner replaying the ddl regardless of the defaults of its sqlite build.
+	pinnedFTS5Tokenizer = "unicode61 remove_diacritics 1"
+)
+
+var (
+	virtualTableRe = regexp.MustCompile(
+		`(?is)^create\s+virtual\s+table\s+(?:if\s+not\s+exists\s+)?\S+\s+using\s+([_0-9a-z]+)\s*(?:\((.*)\))?\s*$`)
+
+	// the built-in tokenizers of the fts modules which do not depend on the locale or the
+	// libraries of the host, icu and the custom tokenizers are refused
+	deterministicTokenizers = map[string]map[string]bool{
+		"fts3": {"simple": true, "porter": true, "unicode61": true},
+		"fts4": {"simple": true, "porter": true, "unicode61": true},
+		"fts5": {"unicode61": true, "ascii": true, "porter": true, "trigram": true},
+	}
+)
+
+// sanitizeVirtualTable checks the tokenizers of the full-text search tables and pins the
+// tokenizer of the fts5 tables declared without one, the other virtual tables are returned as
+// is.
+func sanitizeVirtualTable(query string) (result string, err error) {
+	m := virtualTableRe.FindStringSubmatchIndex(query)
+	if m == nil {
+		return query, nil
+	}
+	module := strings.ToLower(query[m[2]:m[3]])
+	allowed, ok := deterministicTokenizers[module]
+	if !ok || m[4] < 0 {
+		return query, nil
+	}
+
+	var (
+		args      = splitModuleArgs(query[m[4]:m[5]])
+		tokenizer []string
+		found     bool
+	)
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || !strings.EqualFold(strings.TrimSpace(kv[0]), "tokenize") {
+			continue
+		}
+		found = true
+		if module == "fts5" {
+			tokenizer = strings.Fields(unquoteModuleArg(strings.TrimSpace(kv[1])))
+		} else {
+			// the fts3/4 tokenizer arguments are quoted one by one
+			tokenizer = strings.Fields(kv[1])
+			if len(tokenizer) > 0 {
+				tokenizer[0] = unquoteModuleArg(tokenizer[0])
+			}
+		}
+	}
+	if !found {
+		if module != "fts5" {
+			// the default simple tokenizer of fts3/4 handles ascii only
+			return query, nil
+		}
+		args = append(args, "tokenize = '"+pinnedFTS5Tokenizer+"'")
+		return query[:m[4]] + strings.Join(args, ", ") + query[m[5]:], nil
+	}
+
+	if len(tokenizer) == 0 {
+		err = errors.Wrapf(ErrNondeterministicTokenizer, "empty tokenizer of %s table", module)
+		return
+	}
+	names := tokenizer[:1]
+	if module == "fts5" && strings.EqualFold(tokenizer[0], "porter") && len(tokenizer) > 1 {
+		// the fts5 porter tokenizer wraps the tokenizer following it
+		names = tokenizer[:2]
+	}
+	for _, name := range names {
+		if !allowed[strings.ToLower(name)] {
+			err = errors.Wrapf(ErrNondeterministicTokenizer, "%s tokenizer of %s table", name, module)
+			return
+		}
+	}
+
+	return query, nil
+}
+
+// splitModuleArgs splits the module arguments at the commas outside the quotes and parentheses.
+func splitModuleArgs(s string) (args []string) {
+	var (
+		depth int
+		quote rune
+		start int
+	)
+	for i, c := range s {
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			args = append(args, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(s[start:]); last != "" || len(args) > 0 {
+		args = append(args, last)
+	}
+	return
+}
+
+// unquoteModuleArg removes the sql quotes of a module argument.
+func unquoteModuleArg(s string) string {
+	if len(s) >= 2 {
+		switch q := s[0]; q {
+		case '\'', '"', '`':
+			if s[len(s)-1] == q {
+				return strings.ReplaceAll(s[1:len(s)-1], string([]byte{q, q}), string(q))
+			}
+		case '[':
+			if s[len(s)-1] == ']' {
+				return s[1 : len(s)-1]
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,55 @@
+package dpos
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSanitizeVirtualTable(t *testing.T) {
+	Convey("The fts5 tables without a tokenizer should be pinned to the default one", t, func() {
+		q, err := sanitizeVirtualTable("CREATE VIRTUAL TABLE docs USING fts5(title, body)")
+		So(err, ShouldBeNil)
+		So(q, ShouldEqual,
+			"CREATE VIRTUAL TABLE docs USING fts5(title, body, tokenize = 'unicode61 remove_diacritics 1')")
+		// the pinned ddl is kept as is when replayed
+		q2, err := sanitizeVirtualTable(q)
+		So(err, ShouldBeNil)
+		So(q2, ShouldEqual, q)
+
+		q, err = sanitizeVirtualTable(
+			"create virtual table if not exists docs using FTS5(body, content='posts', prefix='2 3') ")
+		So(err, ShouldBeNil)
+		So(q, ShouldEqual, "create virtual table if not exists docs using FTS5("+
+			"body, content='posts', prefix='2 3', tokenize = 'unicode61 remove_diacritics 1') ")
+	})
+	Convey("The built-in tokenizers should be accepted", t, func() {
+		for _, q := range []string{
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'porter ascii')`,
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize="trigram case_sensitive 1")`,
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'unicode61 remove_diacritics 2')`,
+			`CREATE VIRTUAL TABLE docs USING fts4(body, tokenize=unicode61 "remove_diacritics=0")`,
+			`CREATE VIRTUAL TABLE docs USING fts3(body)`,
+			`CREATE VIRTUAL TABLE docs USING rtree(id, minX, maxX)`,
+		} {
+			r, err := sanitizeVirtualTable(q)
+			So(err, ShouldBeNil)
+			So(r, ShouldEqual, q)
+		}
+	})
+	Convey("The nondeterministic tokenizers should be refused", t, func() {
+		for _, q := range []string{
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'icu')`,
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'porter custom')`,
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = '')`,
+			`CREATE VIRTUAL TABLE docs USING fts4(body, tokenize=icu zh_CN)`,
+		} {
+			_, err := sanitizeVirtualTable(q)
+			So(errors.Cause(err), ShouldEqual, ErrNondeterministicTokenizer)
+		}
+		_, _, _, err := convertQueryAndBuildArgs(
+			`CREATE VIRTUAL TABLE docs USING fts5(body, tokenize = 'icu')`, nil)
+		So(errors.Cause(err), ShouldEqual, ErrNondeterministicTokenizer)
+	})
+}
@@ -0,0 +1,70 @@
+package dpos
+
+import (
+	"database/sql"
+	"sync/atomic"
+	"time"
+)
+
+// commitGroup is the set of write requests executed in the ongoing transaction and waiting for
+// its commit, done is closed once the transaction is committed or rolled back.
+type commitGroup struct {
+	done chan struct{}
+	err  error
+}
+
+// SetGroupCommit enables the group commit of the write requests with the max delay of a commit.
+// The write requests are executed in a shared transaction, which is committed once per delay or
+// once it grows too large, and each request returns after its writes are committed. A delay of 0
+// disables the group commit, the write requests are then committed one by one unless the state
+// uses the read uncommitted isolation level.
+func (s *State) SetGroupCommit(delay time.Duration) {
+	s.Lock()
+	defer s.Unlock()
+	if delay < 0 {
+		delay = 0
+	}
+	if delay == s.getGroupCommitDelay() {
+		return
+	}
+	s.commitHandler()
+	atomic.StoreInt64(&s.groupCommitDelay, int64(delay))
+	s.openHandler()
+}
+
+// isGrouped returns whether the write requests of the state are committed by groups, it is
+// always false with the read uncommitted isolation level which commits on the block production.
+func (s *State) isGrouped() bool {
+	return s.level != sql.LevelReadUncommitted && s.getGroupCommitDelay() > 0
+}
+
+func (s *State) getGroupCommitDelay() time.Duration {
+	return time.Duration(atomic.LoadInt64(&s.groupCommitDelay))
+}
+
+// joinGroup adds the current write request to the ongoing commit group and returns it, the commit
+// of a new group is scheduled after the group commit delay. The caller must hold the state lock.
+func (s *State) joinGroup() (g *commitGroup) {
+	if s.group == nil {
+		g = &commitGroup{done: make(chan struct{})}
+		s.group = g
+		time.AfterFunc(s.getGroupCommitDelay(), func() {
+			s.Lock()
+			defer s.Unlock()
+			if s.group == g {
+				s.flushHandler()
+			}
+		})
+	}
+	return s.group
+}
+
+// releaseGroup notifies the requests of the ongoing commit group with err. The caller must hold the
+// state lock.
+func (s *State) releaseGroup(err error) {
+	if g := s.group; g != nil {
+		s.group = nil
+		g.err = err
+		close(g.done)
+	}
+}
@@ -0,0 +1,110 @@
+package dpos
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+func TestGroupCommit(t *testing.T) {
+	Convey("Given a state with group commit", t, func() {
+		var fl = path.Join(testingDataDir, t.Name())
+		strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		var st = NewState(sql.LevelDefault, nodeID, strg)
+		st.SetGroupCommit(50 * time.Millisecond)
+		So(st.isGrouped(), ShouldBeTrue)
+		Reset(func() {
+			So(st.Close(true), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err := os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+
+		var start = time.Now()
+		_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+			buildQuery(`CREATE TABLE t1 (k INT, v TEXT, PRIMARY KEY(k))`),
+		}), true)
+		So(err, ShouldBeNil)
+		// the schema changes are committed at once
+		So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+
+		Convey("The concurrent writes should be committed together", func() {
+			var (
+				wg   sync.WaitGroup
+				errs = make([]error, 20)
+			)
+			start = time.Now()
+			for i := range errs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					_, _, errs[i] = st.Query(buildRequest(types.WriteQuery, []types.Query{
+						buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, i, fmt.Sprint("v", i)),
+						buildQuery(`UPDATE t1 SET v = v || '!' WHERE k = ?`, i),
+					}), true)
+				}(i)
+			}
+			wg.Wait()
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+			for _, err := range errs {
+				So(err, ShouldBeNil)
+			}
+			// the responded writes are committed and visible to the committed reads
+			var count int
+			err = strg.Reader().QueryRow(`SELECT COUNT(1) FROM t1 WHERE v LIKE '%!'`).Scan(&count)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, len(errs))
+			So(st.getLastCommitPoint(), ShouldEqual, st.getSeq())
+		})
+		Convey("The failed write should not affect the group", func() {
+			var (
+				wg   sync.WaitGroup
+				werr error
+			)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, _, werr = st.Query(buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`INSERT INTO t1 (k, v) VALUES (1, 'a')`),
+				}), true)
+			}()
+			_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`INSERT INTO t1 (k, v) VALUES (2, 'b')`),
+				buildQuery(`INSERT INTO t1 (k, v) VALUES (2, 'c')`),
+			}), true)
+			So(err, ShouldNotBeNil)
+			wg.Wait()
+			So(werr, ShouldBeNil)
+			var count int
+			err = strg.Reader().QueryRow(`SELECT COUNT(1) FROM t1`).Scan(&count)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+		})
+		Convey("The waiting writes should fail if the state is closed without commit", func() {
+			st.SetGroupCommit(time.Hour)
+			var errCh = make(chan error, 1)
+			go func() {
+				_, _, err := st.Query(buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`INSERT INTO t1 (k, v) VALUES (1, 'a')`),
+				}), true)
+				errCh <- err
+			}()
+			for st.getSeq() < 2 {
+				time.Sleep(time.Millisecond)
+			}
+			So(st.Close(false), ShouldBeNil)
+			So(<-errCh, ShouldEqual, ErrGroupCommitAborted)
+		})
+	})
+}
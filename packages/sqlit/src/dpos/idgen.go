@@ -0,0 +1,189 @@
+package dpos
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"sqlit/src/crypto/hash"
+)
+
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// idSource derives the values of the uuid_v7() and ulid() functions of a query. The values of a
+// write query are derived from the leader timestamp and the request identity recorded in the
+// response header, so that the followers replaying the query substitute the same values.
+type idSource struct {
+	ms   uint64 // unix time in milliseconds
+	seed [sha256.Size]byte
+	n    uint16 // count of the generated values, which keeps the values of a query ordered
+}
+
+// newIDSource returns the id source of the query at index of the write request logged at offset.
+func newIDSource(reqHash hash.Hash, offset uint64, ts time.Time, index int) *idSource {
+	var (
+		s = &idSource{ms: uint64(ts.UnixNano() / int64(time.Millisecond))}
+		b [12]byte
+		h = sha256.New()
+	)
+	binary.BigEndian.PutUint64(b[:8], offset)
+	binary.BigEndian.PutUint32(b[8:], uint32(index))
+	_, _ = h.Write(reqHash[:])
+	_, _ = h.Write(b[:])
+	copy(s.seed[:], h.Sum(nil))
+	return s
+}
+
+// newLocalIDSource returns a random id source of the read queries, which are not replayed.
+func newLocalIDSource() *idSource {
+	var s = &idSource{ms: uint64(time.Now().UnixNano() / int64(time.Millisecond))}
+	_, _ = rand.Read(s.seed[:])
+	return s
+}
+
+// next returns the timestamp, the sequence number and the random bits of the next value.
+func (s *idSource) next() (ms uint64, seq uint16, random [sha256.Size]byte) {
+	var b [2]byte
+	seq = s.n
+	s.n++
+	binary.BigEndian.PutUint16(b[:], seq)
+	return s.ms, seq, sha256.Sum256(append(s.seed[:], b[:]...))
+}
+
+// uuidV7 returns a version 7 uuid in the canonical text form, the sequence number takes the 12
+// bits of rand_a.
+func (s *idSource) uuidV7() string {
+	var (
+		ms, seq, random = s.next()
+		u               [16]byte
+		t               [8]byte
+	)
+	binary.BigEndian.PutUint64(t[:], ms)
+	copy(u[:6], t[2:])
+	u[6] = 0x70 | byte(seq>>8)&0x0f
+	u[7] = byte(seq)
+	copy(u[8:], random[:8])
+	u[8] = 0x80 | u[8]&0x3f
+	x := hex.EncodeToString(u[:])
+	return x[:8] + "-" + x[8:12] + "-" + x[12:16] + "-" + x[16:20] + "-" + x[20:]
+}
+
+// ulid returns a ulid in the canonical crockford base32 form, the sequence number takes the
+// leading 16 bits of the random part.
+func (s *idSource) ulid() string {
+	var (
+		ms, seq, random = s.next()
+		u               [16]byte
+		t               [8]byte
+		out             [26]byte
+	)
+	binary.BigEndian.PutUint64(t[:], ms)
+	copy(u[:6], t[2:])
+	binary.BigEndian.PutUint16(u[6:8], seq)
+	copy(u[8:], random[:8])
+	// 26 characters of 5 bits encode the 128 bits with 2 leading zero bits
+	for i := range out {
+		var v byte
+		for j := 0; j < 5; j++ {
+			v <<= 1
+			if k := i*5 + j - 2; k >= 0 && u[k/8]&(0x80>>uint(k%8)) != 0 {
+				v |= 1
+			}
+		}
+		out[i] = crockfordAlphabet[v]
+	}
+	return string(out[:])
+}
+
+// substituteIDFunctions replaces the uuid_v7() and ulid() calls in pattern with the string
+// literals of the values of src, the string literals, quoted identifiers and comments of pattern
+// are left untouched. Each call is substituted once, so a call evaluated for multiple rows, such
+// as in INSERT ... SELECT, returns the same value for all the rows.
+func substituteIDFunctions(pattern string, src *idSource) string {
+	var (
+		lower = strings.ToLower(pattern)
+		b     strings.Builder
+		last  int
+	)
+	if !strings.Contains(lower, "uuid_v7") && !strings.Contains(lower, "ulid") {
+		return pattern
+	}
+	for i := 0; i < len(pattern); {
+		switch c := pattern[i]; {
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			end := c
+			if c == '[' {
+				end = ']'
+			}
+			for i++; i < len(pattern); i++ {
+				if pattern[i] == end {
+					if end != ']' && i+1 < len(pattern) && pattern[i+1] == end {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+		case c == '-' && strings.HasPrefix(pattern[i:], "--"):
+			if j := strings.IndexByte(pattern[i:], '\n'); j >= 0 {
+				i += j + 1
+			} else {
+				i = len(pattern)
+			}
+		case c == '/' && strings.HasPrefix(pattern[i:], "/*"):
+			if j := strings.Index(pattern[i+2:], "*/"); j >= 0 {
+				i += j + 4
+			} else {
+				i = len(pattern)
+			}
+		case isIdentChar(c) && (c < '0' || c > '9'):
+			start := i
+			for i < len(pattern) && isIdentChar(pattern[i]) {
+				i++
+			}
+			name := lower[start:i]
+			if name != "uuid_v7" && name != "ulid" || start > 0 && pattern[start-1] == '.' {
+				continue
+			}
+			j := skipSpaces(pattern, i)
+			if j >= len(pattern) || pattern[j] != '(' {
+				continue
+			}
+			if j = skipSpaces(pattern, j+1); j >= len(pattern) || pattern[j] != ')' {
+				continue
+			}
+			var value string
+			if name == "ulid" {
+				value = src.ulid()
+			} else {
+				value = src.uuidV7()
+			}
+			b.WriteString(pattern[last:start])
+			b.WriteString("'" + value + "'")
+			i, last = j+1, j+1
+		default:
+			i++
+		}
+	}
+	if last == 0 {
+		return pattern
+	}
+	b.WriteString(pattern[last:])
+	return b.String()
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+		c >= 0x80
+}
+
+func skipSpaces(s string, i int) int {
+	for i < len(s) && (s[i] == ' ' || s[i] == '\t' || s[i] == '\n' || s[i] == '\r') {
+		i++
+	}
+	return i
+}
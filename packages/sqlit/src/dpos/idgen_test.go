@@ -0,0 +1,145 @@
+package dpos
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/hash"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+var (
+	uuidV7Re = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	ulidRe   = regexp.MustCompile(`^[0-7][0-9A-HJKMNP-TV-Z]{25}$`)
+)
+
+func TestIDSource(t *testing.T) {
+	Convey("The generated ids should be well-formed and ordered", t, func() {
+		var (
+			ts  = time.Date(2026, 1, 2, 3, 4, 5, 6e6, time.UTC)
+			src = newIDSource(hash.Hash{1}, 10, ts, 0)
+		)
+		u1, u2 := src.uuidV7(), src.uuidV7()
+		So(u1, ShouldHaveLength, 36)
+		So(uuidV7Re.MatchString(u1), ShouldBeTrue)
+		So(uuidV7Re.MatchString(u2), ShouldBeTrue)
+		So(u1, ShouldBeLessThan, u2)
+		// the leading 48 bits are the unix milliseconds
+		So(u1[:13], ShouldEqual, fmt.Sprintf("%08x-%04x", ts.UnixNano()/1e6>>16, ts.UnixNano()/1e6&0xffff))
+
+		l1, l2 := src.ulid(), src.ulid()
+		So(ulidRe.MatchString(l1), ShouldBeTrue)
+		So(ulidRe.MatchString(l2), ShouldBeTrue)
+		So(l1, ShouldBeLessThan, l2)
+		So(l1[:10], ShouldEqual, l2[:10])
+		// the unix milliseconds 1469918176385 are encoded as 01ARYZ6S41
+		So(newIDSource(hash.Hash{}, 0, time.Unix(0, 1469918176385*1e6), 0).ulid()[:10],
+			ShouldEqual, "01ARYZ6S41")
+	})
+	Convey("The same request identity should derive the same ids", t, func() {
+		var ts = time.Now()
+		So(newIDSource(hash.Hash{1}, 10, ts, 0).uuidV7(), ShouldEqual,
+			newIDSource(hash.Hash{1}, 10, ts, 0).uuidV7())
+		So(newIDSource(hash.Hash{1}, 10, ts, 0).uuidV7(), ShouldNotEqual,
+			newIDSource(hash.Hash{1}, 10, ts, 1).uuidV7())
+		So(newIDSource(hash.Hash{1}, 10, ts, 0).ulid(), ShouldNotEqual,
+			newIDSource(hash.Hash{2}, 10, ts, 0).ulid())
+		So(newLocalIDSource().ulid(), ShouldNotEqual, newLocalIDSource().ulid())
+	})
+}
+
+func TestSubstituteIDFunctions(t *testing.T) {
+	Convey("The id function calls should be substituted with string literals", t, func() {
+		var src = newIDSource(hash.Hash{1}, 10, time.Now(), 0)
+		q := substituteIDFunctions(
+			`INSERT INTO t (id, k, v) VALUES (UUID_V7(), ulid ( ), 'ulid()') -- uuid_v7()`, src)
+		So(q, ShouldStartWith, `INSERT INTO t (id, k, v) VALUES ('`)
+		So(q, ShouldEndWith, `', 'ulid()') -- uuid_v7()`)
+		So(regexp.MustCompile(`'[^']{36}', '[^']{26}'`).MatchString(q), ShouldBeTrue)
+
+		for _, q := range []string{
+			`SELECT "ulid()", [uuid_v7()], t.ulid() FROM t /* ulid() */`,
+			`SELECT ulid, uuid_v7 FROM t WHERE myulid() = 1`,
+			`SELECT 1`,
+		} {
+			So(substituteIDFunctions(q, src), ShouldEqual, q)
+		}
+	})
+}
+
+func TestIDFunctionsReplay(t *testing.T) {
+	Convey("Given a leader and a follower state", t, func() {
+		var (
+			fl1 = path.Join(testingDataDir, t.Name()+"x1")
+			fl2 = path.Join(testingDataDir, t.Name()+"x2")
+			st  [2]*State
+		)
+		for i, fl := range []string{fl1, fl2} {
+			strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+			So(err, ShouldBeNil)
+			st[i] = NewState(sql.LevelReadUncommitted, nodeID, strg)
+		}
+		Reset(func() {
+			for i, fl := range []string{fl1, fl2} {
+				So(st[i].Close(false), ShouldBeNil)
+				for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+					err := os.Remove(f)
+					So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+				}
+			}
+		})
+
+		var (
+			reqs = []*types.Request{
+				buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`CREATE TABLE t1 (id TEXT PRIMARY KEY, k INT)`),
+				}),
+			}
+			block = &types.Block{}
+		)
+		for i := 0; i < 5; i++ {
+			reqs = append(reqs, buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`INSERT INTO t1 (id, k) VALUES (uuid_v7(), ?), (ulid(), ?)`, i, i),
+				buildQuery(`INSERT INTO t1 (id, k) VALUES (uuid_v7(), ?)`, i),
+			}))
+		}
+		for _, req := range reqs {
+			qt, resp, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			qt.UpdateResp(resp)
+			block.QueryTxs = append(block.QueryTxs, &types.QueryAsTx{
+				Request:  req,
+				Response: &resp.Header,
+			})
+		}
+
+		Convey("The follower should replay the same ids", func() {
+			So(st[1].ReplayBlock(block), ShouldBeNil)
+			var req = buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`SELECT id, k FROM t1 ORDER BY id`),
+			})
+			_, resp1, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			_, resp2, err := st[1].Query(req, true)
+			So(err, ShouldBeNil)
+			So(resp1.Header.RowCount, ShouldEqual, 15)
+			So(resp2.Payload, ShouldResemble, resp1.Payload)
+		})
+		Convey("The read queries should generate new ids", func() {
+			var req = buildRequest(types.ReadQuery, []types.Query{buildQuery(`SELECT uuid_v7()`)})
+			_, resp1, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			_, resp2, err := st[0].Query(req, true)
+			So(err, ShouldBeNil)
+			So(resp1.Payload.Rows[0].Values[0], ShouldNotEqual, resp2.Payload.Rows[0].Values[0])
+		})
+	})
+}
@@ -2,7 +2,9 @@
 package interfaces
 
 import (
+	"context"
 	"database/sql"
+	"time"
 )
 
 // Storage is the interface implemented by an object that returns standard *sql.DB as DirtyReader,
@@ -13,3 +15,48 @@ type Storage interface {
 	Writer() *sql.DB
 	Close() error
 }
+
+// Backuper is the interface implemented by a Storage which supports online backup of its
+// committed data to a file.
+type Backuper interface {
+	Backup(ctx context.Context, dst string) error
+}
+
+// MaintenanceOptions defines the maintenance tasks to run on a Storage.
+type MaintenanceOptions struct {
+	// Checkpoint copies the write-ahead log back to the database file and truncates it
+	Checkpoint bool
+	// IncrementalVacuum releases free pages of the database file to the file system
+	IncrementalVacuum bool
+	// Analyze refreshes the statistics used by the query planner
+	Analyze bool
+}
+
+// MaintenanceResult defines the result of a maintenance run.
+type MaintenanceResult struct {
+	// CheckpointedFrames is the count of write-ahead log frames checkpointed
+	CheckpointedFrames int64
+	// WALBytes is the size of write-ahead log truncated by the checkpoint
+	WALBytes int64
+	// ReclaimedBytes is the size of database file released by the incremental vacuum
+	ReclaimedBytes int64
+}
+
+// Maintainer is the interface implemented by a Storage which supports online maintenance, such
+// as write-ahead log checkpointing and vacuuming.
+type Maintainer interface {
+	Maintain(ctx context.Context, opts MaintenanceOptions) (MaintenanceResult, error)
+}
+
+// PoolOptions defines the connection pool settings of a Storage. The storage has a single writer
+// connection, the settings except MaxReaders apply to all connections.
+type PoolOptions struct {
+	// MaxReaders is the max count of open read connections, 0 for unlimited
+	MaxReaders int
+	// BusyTimeout is the max time to wait for a locked database before failing
+	BusyTimeout time.Duration
+	// CacheSize is the page cache size of a connection, in pages if positive or in KiB if negative
+	CacheSize int
+	// MMapSize is the max bytes of the database file accessed with memory-mapped I/O
+	MMapSize int64
+}
@@ -0,0 +1,142 @@
+package dpos
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// JobTableName is the table of the scheduled jobs declared by the CREATE JOB statements, which
+	// is kept in the state and replicated like the ttl table. The next_run column is the unix time
+	// of the next run, which is null until the leader schedules the job.
+	JobTableName = "__sqlit_jobs"
+	// JobRunTableName is the table of the run history of the scheduled jobs.
+	JobRunTableName = "__sqlit_job_runs"
+
+	// MaxJobRunHistory defines the max count of the runs kept in the history of a job.
+	MaxJobRunHistory = 100
+)
+
+var (
+	// createJobRe matches the statement declaring a scheduled job, such as
+	// CREATE JOB rollup SCHEDULE '*/5 * * * *' AS INSERT INTO stats SELECT ... .
+	createJobRe = regexp.MustCompile(`(?is)^create\s+job\s+(if\s+not\s+exists\s+)?` +
+		"(\"(?:[^\"]|\"\")+\"|`[^`]+`|\\[[^\\]]+\\]|[^\\s]+)" +
+		`\s+schedule\s+('(?:[^']|'')*')\s+as\s+(.+)$`)
+	// dropJobRe matches the statement dropping a scheduled job.
+	dropJobRe = regexp.MustCompile(`(?is)^drop\s+job\s+(?:if\s+exists\s+)?` +
+		"(\"(?:[^\"]|\"\")+\"|`[^`]+`|\\[[^\\]]+\\]|[^\\s]+)\\s*$")
+
+	createJobTable = `CREATE TABLE IF NOT EXISTS "` + JobTableName + `" (` +
+		`"name" TEXT PRIMARY KEY, "schedule" TEXT NOT NULL, "query" TEXT NOT NULL, ` +
+		`"next_run" INTEGER)`
+	createJobRunTable = `CREATE TABLE IF NOT EXISTS "` + JobRunTableName + `" (` +
+		`"id" INTEGER PRIMARY KEY, "job" TEXT NOT NULL, "scheduled_at" INTEGER NOT NULL, ` +
+		`"started_at" INTEGER NOT NULL, "affected_rows" INTEGER, "error" TEXT)`
+)
+
+// Job defines a scheduled job of a database, the query of the job is sent as a write query by the
+// leader at the times matching the cron schedule.
+type Job struct {
+	Name     string
+	Schedule string
+	Query    string
+	NextRun  time.Time // zero if the job is not scheduled yet
+}
+
+// isJobByString checks if a query is a statement creating or dropping a scheduled job.
+func isJobByString(lower string) bool {
+	return strings.HasPrefix(lower, "create job") || strings.HasPrefix(lower, "drop job")
+}
+
+// sanitizeJob rewrites the statement creating or dropping a scheduled job to the statements of
+// the job tables. The schedule and the query of the job are checked beforehand, the query is a
+// single statement as the statements of a request are split by semicolons. Note that the id
+// functions in the query are substituted once when the job is created.
+func sanitizeJob(query string) (queries []string, err error) {
+	if m := dropJobRe.FindStringSubmatch(query); m != nil {
+		return []string{
+			createJobTable,
+			`DELETE FROM "` + JobTableName + `" WHERE "name" = ` + QuoteString(unquoteModuleArg(m[1])),
+		}, nil
+	}
+	m := createJobRe.FindStringSubmatch(query)
+	if m == nil {
+		return nil, errors.Wrapf(ErrInvalidJob, "%s", query)
+	}
+	var j = &Job{
+		Name:     unquoteModuleArg(m[2]),
+		Schedule: unquoteModuleArg(m[3]),
+		Query:    strings.TrimSpace(m[4]),
+	}
+	if j.Name == "" {
+		return nil, errors.Wrap(ErrInvalidJob, "empty job name")
+	}
+	if _, err = ParseCron(j.Schedule); err != nil {
+		return
+	}
+	if isJobByString(strings.ToLower(j.Query)) {
+		return nil, errors.Wrapf(ErrInvalidJob, "nested job statement: %s", j.Query)
+	}
+	if err = CheckQuery(j.Query); err != nil {
+		return nil, errors.Wrapf(err, "check query of job %s failed", j.Name)
+	}
+	var insert = `INSERT OR REPLACE`
+	if m[1] != "" {
+		insert = `INSERT OR IGNORE`
+	}
+	return []string{
+		createJobTable,
+		createJobRunTable,
+		insert + ` INTO "` + JobTableName + `" ("name", "schedule", "query", "next_run") VALUES (` +
+			QuoteString(j.Name) + `, ` + QuoteString(j.Schedule) + `, ` + QuoteString(j.Query) +
+			`, NULL)`,
+	}, nil
+}
+
+// jobCond returns the condition matching the job as read by the leader.
+func jobCond(j *Job) string {
+	var cond = `"next_run" IS NULL`
+	if !j.NextRun.IsZero() {
+		cond = `"next_run" = ` + strconv.FormatInt(j.NextRun.Unix(), 10)
+	}
+	return `"name" = ` + QuoteString(j.Name) + ` AND ` + cond
+}
+
+// JobScheduleQuery returns the query scheduling the next run of the job, which only applies to
+// the job still scheduled at the time read by the leader.
+func JobScheduleQuery(j *Job, next time.Time) string {
+	return `UPDATE "` + JobTableName + `" SET "next_run" = ` + strconv.FormatInt(next.Unix(), 10) +
+		` WHERE ` + jobCond(j)
+}
+
+// JobRunQueries returns the queries recording the run of the job started at now, which follow the
+// query of the job in the same request and precede the schedule query of the next run. The
+// affected rows are the changes of the job query if it succeeds, or the error of the failed run
+// is recorded instead. The run is only recorded once for a scheduled time, and the history of the
+// job is trimmed to MaxJobRunHistory runs.
+func JobRunQueries(j *Job, now time.Time, runErr error) []string {
+	var (
+		name     = QuoteString(j.Name)
+		affected = "changes()"
+		errText  = "NULL"
+	)
+	if runErr != nil {
+		// the statements of a request are split by semicolons
+		affected, errText = "0", QuoteString(strings.ReplaceAll(runErr.Error(), ";", ","))
+	}
+	return []string{
+		`INSERT INTO "` + JobRunTableName + `" ("job", "scheduled_at", "started_at", ` +
+			`"affected_rows", "error") SELECT ` + name + `, ` +
+			strconv.FormatInt(j.NextRun.Unix(), 10) + `, ` + strconv.FormatInt(now.Unix(), 10) +
+			`, ` + affected + `, ` + errText + ` WHERE EXISTS (SELECT 1 FROM "` + JobTableName +
+			`" WHERE ` + jobCond(j) + `)`,
+		`DELETE FROM "` + JobRunTableName + `" WHERE "job" = ` + name + ` AND "id" NOT IN (` +
+			`SELECT "id" FROM "` + JobRunTableName + `" WHERE "job" = ` + name +
+			` ORDER BY "id" DESC LIMIT ` + strconv.Itoa(MaxJobRunHistory) + `)`,
+	}
+}
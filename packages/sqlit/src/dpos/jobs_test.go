@@ -0,0 +1,163 @@
+package dpos
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseCron(t *testing.T) {
+	var at = func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04", s)
+		So(err, ShouldBeNil)
+		return v
+	}
+	Convey("The next times should match the cron schedules in UTC", t, func() {
+		// 2024-02-28 is a wednesday
+		var from = at("2024-02-28 10:07")
+		for spec, next := range map[string]string{
+			"* * * * *":            "2024-02-28 10:08",
+			"*/15 * * * *":         "2024-02-28 10:15",
+			"5/20 * * * *":         "2024-02-28 10:25",
+			"0 9-17/4 * * *":       "2024-02-28 13:00",
+			"30 2 * * *":           "2024-02-29 02:30",
+			"0 0 * * mon-fri":      "2024-02-29 00:00",
+			"0 0 * * 7":            "2024-03-03 00:00",
+			"0 0 29 feb *":         "2024-02-29 00:00",
+			"0 0 30 2,4 *":         "2024-04-30 00:00",
+			"0 0 1,15 * 5":         "2024-03-01 00:00",
+			"0 12 31 * *":          "2024-03-31 12:00",
+			"@hourly":              "2024-02-28 11:00",
+			" @Daily ":             "2024-02-29 00:00",
+			"@weekly":              "2024-03-03 00:00",
+			"@monthly":             "2024-03-01 00:00",
+			"@yearly":              "2025-01-01 00:00",
+			"0,30 8 1 jan,jul mon": "2024-07-01 08:00",
+		} {
+			s, err := ParseCron(spec)
+			So(err, ShouldBeNil)
+			So(s.Next(from), ShouldEqual, at(next))
+		}
+		s, err := ParseCron("0 0 29 2 *")
+		So(err, ShouldBeNil)
+		So(s.Next(at("2024-03-01 00:00")), ShouldEqual, at("2028-02-29 00:00"))
+		// the seconds are truncated
+		s, err = ParseCron("* * * * *")
+		So(err, ShouldBeNil)
+		So(s.Next(at("2024-02-28 10:07").Add(59*time.Second)), ShouldEqual, at("2024-02-28 10:08"))
+	})
+	Convey("The invalid cron schedules should be refused", t, func() {
+		for _, spec := range []string{
+			"", "* * * *", "* * * * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *",
+			"* * * * 8", "*/0 * * * *", "5-1 * * * *", "a * * * *", "* * * foo *", "0 0 30 2 *",
+			"@every 5m",
+		} {
+			_, err := ParseCron(spec)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidSchedule)
+		}
+	})
+}
+
+func TestSanitizeJob(t *testing.T) {
+	Convey("The job statements should be rewritten to the job tables", t, func() {
+		q, err := sanitizeJob(`CREATE JOB rollup SCHEDULE '*/5 * * * *' AS ` +
+			`INSERT INTO stats SELECT 'it''s', COUNT(1) FROM events`)
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, []string{
+			createJobTable,
+			createJobRunTable,
+			`INSERT OR REPLACE INTO "__sqlit_jobs" ("name", "schedule", "query", "next_run") ` +
+				`VALUES ('rollup', '*/5 * * * *', 'INSERT INTO stats SELECT ''it''''s'', ` +
+				`COUNT(1) FROM events', NULL)`,
+		})
+		q, err = sanitizeJob("create job if not exists \"daily cleanup\" schedule '@daily' as\n" +
+			`DELETE FROM events WHERE at < 0`)
+		So(err, ShouldBeNil)
+		So(q[2], ShouldStartWith, `INSERT OR IGNORE INTO "__sqlit_jobs"`)
+		So(q[2], ShouldContainSubstring, `'daily cleanup', '@daily'`)
+
+		q, err = sanitizeJob(`DROP JOB IF EXISTS "daily cleanup"`)
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, []string{
+			createJobTable, `DELETE FROM "__sqlit_jobs" WHERE "name" = 'daily cleanup'`,
+		})
+	})
+	Convey("The invalid job statements should be refused", t, func() {
+		for s, e := range map[string]error{
+			`CREATE JOB x AS DELETE FROM t`:                                     ErrInvalidJob,
+			`CREATE JOB x SCHEDULE '* * *' AS DELETE FROM t`:                    ErrInvalidSchedule,
+			`CREATE JOB x SCHEDULE '* * * * *' AS CREATE JOB y SCHEDULE '' AS`:  ErrInvalidJob,
+			`CREATE JOB x SCHEDULE '* * * * *' AS DELETE FROM t WHERE random()`: ErrStatefulQueryParts,
+			`DROP JOB`: ErrInvalidJob,
+		} {
+			_, err := sanitizeJob(s)
+			So(errors.Cause(err), ShouldEqual, e)
+		}
+	})
+	Convey("The runs of a job should be recorded in the run history", t, func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		var exec = func(s string) {
+			_, p, _, err := convertQueryAndBuildArgs(s, nil)
+			So(err, ShouldBeNil)
+			_, err = db.Exec(p)
+			So(err, ShouldBeNil)
+		}
+		exec(`CREATE TABLE events (at INT)`)
+		exec(`INSERT INTO events VALUES (1), (2), (3)`)
+		exec(`CREATE JOB cleanup SCHEDULE '@hourly' AS DELETE FROM events WHERE at < 3`)
+
+		var (
+			j    = &Job{Name: "cleanup", Query: `DELETE FROM events WHERE at < 3`}
+			now  = time.Date(2024, 2, 28, 10, 7, 0, 0, time.UTC)
+			next = now.Truncate(time.Hour).Add(time.Hour)
+		)
+		exec(JobScheduleQuery(j, next))
+		var nextRun int64
+		So(db.QueryRow(`SELECT "next_run" FROM "__sqlit_jobs"`).Scan(&nextRun), ShouldBeNil)
+		So(nextRun, ShouldEqual, next.Unix())
+
+		j.NextRun, now = next, next.Add(time.Second)
+		exec(j.Query)
+		for _, q := range JobRunQueries(j, now, nil) {
+			exec(q)
+		}
+		exec(JobScheduleQuery(j, next.Add(time.Hour)))
+		// the failed run of the same scheduled time is not recorded again
+		for _, q := range JobRunQueries(j, now, errors.New("x; y")) {
+			exec(q)
+		}
+
+		var (
+			scheduled, started, affected int64
+			runErr                       sql.NullString
+			count                        int
+		)
+		So(db.QueryRow(`SELECT COUNT(1) FROM "__sqlit_job_runs"`).Scan(&count), ShouldBeNil)
+		So(count, ShouldEqual, 1)
+		So(db.QueryRow(`SELECT "scheduled_at", "started_at", "affected_rows", "error" FROM `+
+			`"__sqlit_job_runs"`).Scan(&scheduled, &started, &affected, &runErr), ShouldBeNil)
+		So(scheduled, ShouldEqual, next.Unix())
+		So(started, ShouldEqual, now.Unix())
+		So(affected, ShouldEqual, 2)
+		So(runErr.Valid, ShouldBeFalse)
+
+		j.NextRun = next.Add(time.Hour)
+		for _, q := range JobRunQueries(j, j.NextRun, errors.New("x; y")) {
+			exec(q)
+		}
+		So(db.QueryRow(`SELECT "affected_rows", "error" FROM "__sqlit_job_runs" `+
+			`ORDER BY "id" DESC LIMIT 1`).Scan(&affected, &runErr), ShouldBeNil)
+		So(affected, ShouldEqual, 0)
+		So(runErr.String, ShouldEqual, "x, y")
+
+		exec(`DROP JOB cleanup`)
+		So(db.QueryRow(`SELECT COUNT(1) FROM "__sqlit_jobs"`).Scan(&count), ShouldBeNil)
+		So(count, ShouldEqual, 0)
+	})
+}
@@ -14,6 +14,9 @@ type QueryTracker struct {
 	sync.RWMutex
 	Req  *types.Request
 	Resp *types.Response
+	// Cursor keeps the rest rows of a read query which result is spilled, the receiver of the
+	// tracker should close it.
+	Cursor *Cursor
 }
 
 // UpdateResp updates response of the QueryTracker within locking scope.
@@ -102,3 +105,32 @@ func (p *pool) truncate(sp uint64) {
 	p.queries = p.queries[pos+1:]
 	atomic.StoreInt32(&p.trackerCount, int32(len(p.queries)))
 }
+
+// split returns the first n pooled queries, the writes in order before the reads, and a new pool
+// keeping the rest ones with their offsets. The failed requests are not kept.
+func (p *pool) split(n int) (head []*QueryTracker, rest *pool) {
+	rest = newPool()
+	if n < len(p.queries) {
+		head = append(head, p.queries[:n]...)
+		rest.queries = append(rest.queries, p.queries[n:]...)
+		for sp, pos := range p.index {
+			if pos >= n {
+				rest.index[sp] = pos - n
+			}
+		}
+		n = 0
+	} else {
+		head = append(head, p.queries...)
+		n -= len(p.queries)
+	}
+	for h, v := range p.reads {
+		if n > 0 {
+			head = append(head, v)
+			n--
+		} else {
+			rest.reads[h] = v
+		}
+	}
+	rest.trackerCount = int32(len(rest.queries))
+	return
+}
@@ -45,6 +45,13 @@ var (
 	}
 )
 
+// CheckQuery runs the sanitizer of the miners over the query pattern without executing it, which
+// tells the clients beforehand if the pattern would be refused.
+func CheckQuery(pattern string) (err error) {
+	_, _, _, err = convertQueryAndBuildArgs(pattern, nil)
+	return
+}
+
 func convertQueryAndBuildArgs(pattern string, args []types.NamedArg) (containsDDL bool, p string, ifs []interface{}, err error) {
 	if lower := strings.ToLower(pattern); strings.Contains(lower, "begin") ||
 		strings.Contains(lower, "rollback") || strings.Contains(lower, "commit") {
@@ -90,6 +97,17 @@ func convertQueryAndBuildArgs(pattern string, args []types.NamedArg) (containsDD
 			}
 		}
 
+		// Declare or drop the scheduled jobs in the job tables
+		if isJobByString(lower) {
+			containsDDL = true
+			var stmts []string
+			if stmts, err = sanitizeJob(query); err != nil {
+				return
+			}
+			resultQueries = append(resultQueries, stmts...)
+			continue
+		}
+
 		// Check for DDL statements by string pattern (for cases parser doesn't handle)
 		if isDDLByString(lower) {
 			containsDDL = true
@@ -106,7 +124,24 @@ func convertQueryAndBuildArgs(pattern string, args []types.NamedArg) (containsDD
 			if err = checkStatefulFunctions(query); err != nil {
 				return
 			}
-			resultQueries = append(resultQueries, query)
+			// Rewrite the foreign tables to the data tables refreshed by the leader
+			var stmts []string
+			if stmts, err = sanitizeForeignTable(query); err != nil {
+				return
+			}
+			if len(stmts) > 1 {
+				resultQueries = append(resultQueries, stmts...)
+				continue
+			}
+			// Check and pin the tokenizers of full-text search tables
+			if query, err = sanitizeVirtualTable(query); err != nil {
+				return
+			}
+			// Strip the ttl options of tables and declare the row ttl policies
+			if stmts, err = sanitizeTTLTable(query); err != nil {
+				return
+			}
+			resultQueries = append(resultQueries, stmts...)
 			continue
 		}
 
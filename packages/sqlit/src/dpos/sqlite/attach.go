@@ -0,0 +1,72 @@
+package sqlite
+
+import (
+	"database/sql"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrInvalidAttachAlias indicates that the alias of an attached database is not a plain
+	// identifier or is reserved by sqlite.
+	ErrInvalidAttachAlias = errors.New("invalid attached database alias")
+
+	attachAlias = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// ValidAttachAlias returns whether alias can name an attached database.
+func ValidAttachAlias(alias string) bool {
+	if !attachAlias.MatchString(alias) {
+		return false
+	}
+	switch strings.ToLower(alias) {
+	case "main", "temp":
+		return false
+	}
+	return true
+}
+
+// readOnlyURI returns the uri opening the sqlite file at path in read-only mode.
+func readOnlyURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: path, RawQuery: "mode=ro"}).String()
+}
+
+// OpenAttached opens the sqlite file at path in read-only mode with the files in attached
+// attached under their aliases, also read-only. The returned handle reads the committed data of
+// all the files, and the caller must close it after use. The encrypted files are not supported.
+func OpenAttached(path string, attached map[string]string) (db *sql.DB, err error) {
+	var (
+		aliases = make([]string, 0, len(attached))
+		stmts   []string
+	)
+	for alias := range attached {
+		if !ValidAttachAlias(alias) {
+			return nil, errors.Wrapf(ErrInvalidAttachAlias, "%q", alias)
+		}
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+	for _, alias := range aliases {
+		stmts = append(stmts, `ATTACH DATABASE '`+
+			strings.Replace(readOnlyURI(attached[alias]), `'`, `''`, -1)+`' AS "`+alias+`"`)
+	}
+	db = sql.OpenDB(&connector{
+		dsn: readOnlyURI(path) + "&_query_only=on",
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(c *sqlite3.SQLiteConn) (err error) {
+				for _, s := range stmts {
+					if _, err = c.Exec(s, nil); err != nil {
+						return errors.Wrapf(err, "exec %s", s)
+					}
+				}
+				return regCustomFunc(c)
+			},
+		},
+	})
+	return
+}
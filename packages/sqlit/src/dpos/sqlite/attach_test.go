@@ -0,0 +1,67 @@
+package sqlite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestOpenAttached(t *testing.T) {
+	Convey("Given two sqlite files", t, func() {
+		dir, err := os.MkdirTemp("", "attach")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		var (
+			users  = filepath.Join(dir, "users 'a'.db3")
+			orders = filepath.Join(dir, "orders 1.db3")
+		)
+		for path, stmts := range map[string][]string{
+			users: {
+				`CREATE TABLE "users" ("id" INT, "name" TEXT)`,
+				`INSERT INTO "users" VALUES (1, 'alice'), (2, 'bob')`,
+			},
+			orders: {
+				`CREATE TABLE "orders" ("user" INT, "amount" INT)`,
+				`INSERT INTO "orders" VALUES (1, 10), (1, 20), (2, 5)`,
+			},
+		} {
+			strg, err := NewSqlite(path)
+			So(err, ShouldBeNil)
+			for _, s := range stmts {
+				_, err = strg.Writer().Exec(s)
+				So(err, ShouldBeNil)
+			}
+			// keep the files open as the miners do
+			defer strg.Close()
+		}
+
+		Convey("The tables should be joined across the files", func() {
+			db, err := OpenAttached(users, map[string]string{"o": orders})
+			So(err, ShouldBeNil)
+			defer db.Close()
+			var (
+				name  string
+				total int
+			)
+			So(db.QueryRow(`SELECT "u"."name", SUM("o"."amount") FROM "users" "u"
+				JOIN "o"."orders" "o" ON "o"."user" = "u"."id"
+				GROUP BY "u"."id" ORDER BY 2 DESC LIMIT 1`).Scan(&name, &total), ShouldBeNil)
+			So(name, ShouldEqual, "alice")
+			So(total, ShouldEqual, 30)
+
+			_, err = db.Exec(`INSERT INTO "o"."orders" VALUES (3, 1)`)
+			So(err, ShouldNotBeNil)
+			_, err = db.Exec(`DELETE FROM "users"`)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The reserved aliases should be refused", func() {
+			So(ValidAttachAlias("tenant_2"), ShouldBeTrue)
+			So(ValidAttachAlias("MAIN"), ShouldBeFalse)
+			So(ValidAttachAlias("a-b"), ShouldBeFalse)
+			_, err := OpenAttached(users, map[string]string{"temp": orders})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
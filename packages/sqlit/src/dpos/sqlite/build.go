@@ -0,0 +1,144 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+)
+
+// BuildInfo describes the SQLite build linked into the node, replicas of a database must have
+// identical ones, or the same statements may silently produce different states.
+type BuildInfo struct {
+	Version        string
+	SourceID       string
+	CompileOptions []string
+	Collations     []string
+}
+
+var (
+	localBuildOnce sync.Once
+	localBuild     *BuildInfo
+	localBuildErr  error
+)
+
+// LocalBuildInfo returns the build info of the linked SQLite library, read from a connection with
+// the custom functions registered as the storage connections.
+func LocalBuildInfo() (*BuildInfo, error) {
+	localBuildOnce.Do(func() {
+		localBuild, localBuildErr = readBuildInfo()
+	})
+	return localBuild, localBuildErr
+}
+
+func readBuildInfo() (info *BuildInfo, err error) {
+	var (
+		c = &connector{
+			dsn: "file::memory:",
+			driver: &sqlite3.SQLiteDriver{
+				ConnectHook: func(c *sqlite3.SQLiteConn) error { return regCustomFunc(c) },
+			},
+		}
+		db   = sql.OpenDB(c)
+		conn *sql.Conn
+	)
+	defer db.Close()
+	// pin a single connection, as each in-memory connection is a distinct database
+	if conn, err = db.Conn(context.Background()); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var b = &BuildInfo{}
+	if err = conn.QueryRowContext(context.Background(),
+		`SELECT sqlite_version(), sqlite_source_id()`,
+	).Scan(&b.Version, &b.SourceID); err != nil {
+		err = errors.Wrap(err, "read sqlite version")
+		return
+	}
+	if b.CompileOptions, err = queryStrings(conn, `PRAGMA compile_options`, 0); err != nil {
+		err = errors.Wrap(err, "read sqlite compile options")
+		return
+	}
+	// collation_list returns (seq, name)
+	if b.Collations, err = queryStrings(conn, `PRAGMA collation_list`, 1); err != nil {
+		err = errors.Wrap(err, "read sqlite collations")
+		return
+	}
+	sort.Strings(b.CompileOptions)
+	sort.Strings(b.Collations)
+	info = b
+	return
+}
+
+// queryStrings returns column col of each row of the pragma query q.
+func queryStrings(conn *sql.Conn, q string, col int) (out []string, err error) {
+	var rows *sql.Rows
+	if rows, err = conn.QueryContext(context.Background(), q); err != nil {
+		return
+	}
+	defer rows.Close()
+	var columns []string
+	if columns, err = rows.Columns(); err != nil {
+		return
+	}
+	for rows.Next() {
+		var (
+			values = make([]sql.RawBytes, len(columns))
+			dest   = make([]interface{}, len(columns))
+		)
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		out = append(out, string(values[col]))
+	}
+	err = rows.Err()
+	return
+}
+
+// Diff returns a readable description of the differences between the builds, empty if they
+// are identical.
+func (b *BuildInfo) Diff(other *BuildInfo) string {
+	var diffs []string
+	if b.Version != other.Version || b.SourceID != other.SourceID {
+		diffs = append(diffs, fmt.Sprintf("version %s (%s) != %s (%s)",
+			b.Version, b.SourceID, other.Version, other.SourceID))
+	}
+	if d := diffStrings(b.CompileOptions, other.CompileOptions); d != "" {
+		diffs = append(diffs, "compile options "+d)
+	}
+	if d := diffStrings(b.Collations, other.Collations); d != "" {
+		diffs = append(diffs, "collations "+d)
+	}
+	return strings.Join(diffs, "; ")
+}
+
+// diffStrings returns the items only in a and the ones only in b, both are sorted.
+func diffStrings(a, b []string) string {
+	var onlyA, onlyB []string
+	for i, j := 0, 0; i < len(a) || j < len(b); {
+		switch {
+		case j >= len(b) || (i < len(a) && a[i] < b[j]):
+			onlyA = append(onlyA, a[i])
+			i++
+		case i >= len(a) || b[j] < a[i]:
+			onlyB = append(onlyB, b[j])
+			j++
+		default:
+			i++
+			j++
+		}
+	}
+	if len(onlyA) == 0 && len(onlyB) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("+%v -%v", onlyA, onlyB)
+}
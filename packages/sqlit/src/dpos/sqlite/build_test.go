@@ -0,0 +1,29 @@
+package sqlite
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestBuildInfo(t *testing.T) {
+	Convey("Given the local sqlite build", t, func() {
+		b, err := LocalBuildInfo()
+		So(err, ShouldBeNil)
+		So(b.Version, ShouldNotBeEmpty)
+		So(b.CompileOptions, ShouldNotBeEmpty)
+		So(b.Collations, ShouldContain, "BINARY")
+		So(b.Collations, ShouldContain, "NOCASE")
+		So(b.Diff(b), ShouldBeEmpty)
+
+		Convey("The differences should be described", func() {
+			var other = *b
+			other.Version = "0.0.0"
+			other.CompileOptions = append([]string{"A_OPTION"}, b.CompileOptions[1:]...)
+			var diff = b.Diff(&other)
+			So(diff, ShouldContainSubstring, "version")
+			So(diff, ShouldContainSubstring, "compile options +["+b.CompileOptions[0]+"] -[A_OPTION]")
+			So(diff, ShouldNotContainSubstring, "collations")
+		})
+	})
+}
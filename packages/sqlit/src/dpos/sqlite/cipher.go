@@ -0,0 +1,115 @@
+package sqlite
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/storage"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// CryptoKeyParam is the DSN parameter of the page encryption key of the database file. A key
+	// of 64 hex characters is used as the raw 256-bit key, otherwise it's used as a passphrase.
+	CryptoKeyParam = "_crypto_key"
+	// CryptoRequireParam is the DSN parameter to refuse opening the database with a key if the
+	// linked sqlite library has no page encryption support, it's true by default. Set it to false
+	// to store the database in plain text on such a library.
+	CryptoRequireParam = "_crypto_require"
+)
+
+var (
+	// ErrEncryptionNotSupported indicates that the linked sqlite library is not built with page
+	// encryption support, such as SQLCipher.
+	ErrEncryptionNotSupported = errors.New("sqlite library does not support page encryption")
+
+	warnUnencryptedOnce sync.Once
+)
+
+// keyLiteral returns the value of the key pragma for key.
+func keyLiteral(key string) string {
+	if raw, err := hex.DecodeString(key); err == nil && len(raw) == 32 {
+		return fmt.Sprintf(`"x'%s'"`, key)
+	}
+	return fmt.Sprintf("'%s'", strings.Replace(key, "'", "''", -1))
+}
+
+// cipherSupported returns whether page encryption is available on connection c.
+func cipherSupported(c *sqlite3.SQLiteConn) (ok bool, err error) {
+	var rows driver.Rows
+	if rows, err = c.Query("PRAGMA cipher_version", nil); err != nil {
+		return
+	}
+	defer rows.Close()
+	dest := make([]driver.Value, len(rows.Columns()))
+	if len(dest) == 0 {
+		return
+	}
+	if err = rows.Next(dest); err == io.EOF {
+		err = nil
+		return
+	} else if err != nil {
+		return
+	}
+	ok = dest[0] != nil
+	return
+}
+
+// applyKey sets the page encryption key of connection c, it must be called before any access
+// to the database file.
+func applyKey(c *sqlite3.SQLiteConn, key string, require bool) (err error) {
+	if _, err = c.Exec("PRAGMA key = "+keyLiteral(key), nil); err != nil {
+		return errors.Wrap(err, "set encryption key")
+	}
+	var ok bool
+	if ok, err = cipherSupported(c); err != nil {
+		return errors.Wrap(err, "check encryption support")
+	}
+	if !ok {
+		if require {
+			return ErrEncryptionNotSupported
+		}
+		warnUnencryptedOnce.Do(func() {
+			log.Warning("sqlite library does not support page encryption, database files " +
+				"with encryption key are stored in plain text as the encryption is not required")
+		})
+	}
+	return
+}
+
+// newEncryptedSqlite returns a new SQLite3 instance with the database file encrypted by key. The
+// journal mode and other pragmas are set in connect hooks instead of DSN, since they access the
+// database file and must be executed after the key is set.
+func newEncryptedSqlite(
+	filename string, dsn *storage.DSN, pool xi.PoolOptions, key string, require bool,
+) (s *SQLite3, err error) {
+	var (
+		instance  = &SQLite3{filename: filename, key: key, pool: pool}
+		dsnShared = dsn.Clone()
+	)
+	dsnShared.AddParam("cache", "shared")
+	instance.dirtyReader = sql.OpenDB(instance.newConnector(dsnShared.Format(), require,
+		"PRAGMA journal_mode=WAL", "PRAGMA query_only=1", "PRAGMA read_uncommitted=1"))
+	instance.reader = sql.OpenDB(instance.newConnector(dsn.Format(), require,
+		"PRAGMA journal_mode=WAL", "PRAGMA query_only=1"))
+	instance.writer = sql.OpenDB(instance.newConnector(dsnShared.Format(), require,
+		"PRAGMA auto_vacuum=INCREMENTAL", "PRAGMA journal_mode=WAL"))
+	instance.applyPoolLimits()
+
+	// fail fast on wrong key or missing encryption support
+	if err = instance.writer.Ping(); err != nil {
+		_ = instance.Close()
+		return
+	}
+	s = instance
+	return
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestKeyLiteral(t *testing.T) {
+	Convey("Raw keys and passphrases should be quoted as pragma values", t, func() {
+		raw := strings.Repeat("ab", 32)
+		So(keyLiteral(raw), ShouldEqual, `"x'`+raw+`'"`)
+		So(keyLiteral("ab"), ShouldEqual, "'ab'")
+		So(keyLiteral("it's"), ShouldEqual, "'it''s'")
+	})
+}
+
+func TestEncryptedStorage(t *testing.T) {
+	Convey("Given a database file with encryption key", t, func() {
+		var (
+			fl  = path.Join(testingDataDir, t.Name())
+			key = strings.Repeat("0f", 32)
+			st  *SQLite3
+			err error
+		)
+		Reset(func() {
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err = os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+		Convey("The storage should fail by default if encryption is not supported", func() {
+			st, err = NewSqlite(fmt.Sprintf("file:%s?%s=%s", fl, CryptoKeyParam, key))
+			if err != nil {
+				So(errors.Cause(err), ShouldEqual, ErrEncryptionNotSupported)
+				return
+			}
+			// linked with a sqlite library supporting page encryption
+			defer st.Close()
+			_, err = st.Writer().Exec(`CREATE TABLE "t1" ("k" INT, "v" TEXT, PRIMARY KEY("k"))`)
+			So(err, ShouldBeNil)
+			_, err = st.Writer().Exec(`INSERT INTO "t1" VALUES (1, 'secret')`)
+			So(err, ShouldBeNil)
+			_, err = st.Writer().Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+			So(err, ShouldBeNil)
+			data, err := os.ReadFile(fl)
+			So(err, ShouldBeNil)
+			So(strings.HasPrefix(string(data), "SQLite format 3"), ShouldBeFalse)
+			So(strings.Contains(string(data), "secret"), ShouldBeFalse)
+		})
+		Convey("The storage should follow the encryption support if not required", func() {
+			st, err = NewSqlite(fmt.Sprintf("file:%s?%s=%s&%s=false",
+				fl, CryptoKeyParam, key, CryptoRequireParam))
+			So(err, ShouldBeNil)
+			defer st.Close()
+			So(st.filename, ShouldEqual, fl)
+			So(st.key, ShouldEqual, key)
+
+			_, err = st.Writer().Exec(`CREATE TABLE "t1" ("k" INT, "v" TEXT, PRIMARY KEY("k"))`)
+			So(err, ShouldBeNil)
+			_, err = st.Writer().Exec(`INSERT INTO "t1" VALUES (1, 'secret')`)
+			So(err, ShouldBeNil)
+			var v string
+			err = st.Reader().QueryRow(`SELECT "v" FROM "t1" WHERE "k" = 1`).Scan(&v)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "secret")
+			err = st.DirtyReader().QueryRow(`SELECT "v" FROM "t1" WHERE "k" = 1`).Scan(&v)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, "secret")
+			_, err = st.Reader().Exec(`INSERT INTO "t1" VALUES (2, 'readonly')`)
+			So(err, ShouldNotBeNil)
+
+			var mode string
+			err = st.Writer().QueryRow("PRAGMA journal_mode").Scan(&mode)
+			So(err, ShouldBeNil)
+			So(mode, ShouldEqual, "wal")
+
+			conn, err := st.Writer().Conn(context.Background())
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			err = conn.Raw(func(dc interface{}) (err error) {
+				supported, err := cipherSupported(dc.(*sqlite3.SQLiteConn))
+				if err != nil {
+					return
+				}
+				data, err := os.ReadFile(fl)
+				if err != nil {
+					return
+				}
+				// plain sqlite files start with the magic header string
+				So(strings.HasPrefix(string(data), "SQLite format 3"), ShouldEqual, !supported)
+				return
+			})
+			So(err, ShouldBeNil)
+		})
+		Convey("The storage should fail if encryption is required explicitly", func() {
+			st, err = NewSqlite(fmt.Sprintf("file:%s?%s=%s&%s=true",
+				fl, CryptoKeyParam, key, CryptoRequireParam))
+			if err == nil {
+				// linked with a sqlite library supporting page encryption
+				So(st.Close(), ShouldBeNil)
+				return
+			}
+			So(errors.Cause(err), ShouldEqual, ErrEncryptionNotSupported)
+		})
+		Convey("The storage should reject invalid require flag", func() {
+			_, err = NewSqlite(fmt.Sprintf("file:%s?%s=%s&%s=maybe",
+				fl, CryptoKeyParam, key, CryptoRequireParam))
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,35 @@
+package sqlite
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/storage"
+)
+
+const (
+	// SynchronousParam is the DSN parameter of the synchronous setting of the connections, which
+	// decides how often the write-ahead log is synced: FULL at every commit, NORMAL at checkpoints
+	// only, or OFF to leave it to the operating system. It's NORMAL if not set.
+	SynchronousParam = "_synchronous"
+)
+
+var (
+	// ErrInvalidSynchronous indicates an unknown synchronous setting.
+	ErrInvalidSynchronous = errors.New("invalid synchronous setting")
+)
+
+// validateSynchronousParam returns an error if the synchronous setting in dsn is unknown, so that
+// the storage fails on opening instead of on the first connection.
+func validateSynchronousParam(dsn *storage.DSN) error {
+	v, ok := dsn.GetParam(SynchronousParam)
+	if !ok {
+		return nil
+	}
+	switch strings.ToUpper(v) {
+	case "OFF", "NORMAL", "FULL", "EXTRA":
+		return nil
+	}
+	return errors.Wrapf(ErrInvalidSynchronous, "%s=%s", SynchronousParam, v)
+}
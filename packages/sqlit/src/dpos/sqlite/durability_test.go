@@ -0,0 +1,48 @@
+package sqlite
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSynchronousParam(t *testing.T) {
+	Convey("Given a storage with synchronous setting in DSN", t, func() {
+		var fl = path.Join(testingDataDir, t.Name())
+		Reset(func() {
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				var err = os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+
+		for _, c := range []struct {
+			value string
+			level int
+		}{
+			{"", 1},
+			{"OFF", 0},
+			{"normal", 1},
+			{"FULL", 2},
+		} {
+			var dsn = fmt.Sprintf("file:%s", fl)
+			if c.value != "" {
+				dsn = fmt.Sprintf("%s?%s=%s", dsn, SynchronousParam, c.value)
+			}
+			st, err := NewSqlite(dsn)
+			So(err, ShouldBeNil)
+			var level int
+			err = st.Writer().QueryRow("PRAGMA synchronous").Scan(&level)
+			So(err, ShouldBeNil)
+			So(level, ShouldEqual, c.level)
+			So(st.Close(), ShouldBeNil)
+		}
+
+		_, err := NewSqlite(fmt.Sprintf("file:%s?%s=SOMETIMES", fl, SynchronousParam))
+		So(errors.Cause(err), ShouldEqual, ErrInvalidSynchronous)
+	})
+}
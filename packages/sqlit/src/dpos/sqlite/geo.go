@@ -0,0 +1,228 @@
+package sqlite
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Geospatial helper functions, the coordinates are WGS84 latitudes and longitudes in degrees.
+// The products are rounded by explicit conversions to keep the compiler from fusing them into
+// multiply-add instructions, so that the results are the same on the miners of all platforms.
+
+const (
+	// earthRadius is the mean radius of the earth in meters.
+	earthRadius = 6371008.8
+
+	geohashAlphabet     = "0123456789bcdefghjkmnpqrstuvwxyz"
+	geohashMaxPrecision = 12
+)
+
+// isNull returns whether the function argument is NULL, which is passed as a nil byte slice.
+func isNull(arg interface{}) bool {
+	b, ok := arg.([]byte)
+	return arg == nil || ok && b == nil
+}
+
+// geoFloatArgs converts the numeric arguments to float64, ok is false if any of them is NULL.
+func geoFloatArgs(args ...interface{}) (values []float64, ok bool, err error) {
+	values = make([]float64, len(args))
+	for i, arg := range args {
+		if isNull(arg) {
+			return nil, false, nil
+		}
+		switch v := arg.(type) {
+		case int64:
+			values[i] = float64(v)
+		case float64:
+			values[i] = v
+		case string:
+			if values[i], err = strconv.ParseFloat(strings.TrimSpace(v), 64); err != nil {
+				return nil, false, errors.Errorf("argument %d is not a number", i+1)
+			}
+		default:
+			return nil, false, errors.Errorf("argument %d is not a number", i+1)
+		}
+		if math.IsNaN(values[i]) || math.IsInf(values[i], 0) {
+			return nil, false, errors.Errorf("argument %d is not a finite number", i+1)
+		}
+	}
+	return values, true, nil
+}
+
+func checkCoordinate(lat, lon float64) error {
+	if lat < -90 || lat > 90 {
+		return errors.Errorf("latitude %v out of range", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return errors.Errorf("longitude %v out of range", lon)
+	}
+	return nil
+}
+
+func radians(deg float64) float64 {
+	return float64(deg * math.Pi / 180)
+}
+
+// haversine returns the great-circle distance between two points in meters.
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	var (
+		dLat = float64(math.Sin(radians(lat2-lat1) / 2))
+		dLon = float64(math.Sin(radians(lon2-lon1) / 2))
+		a    = float64(dLat*dLat) + float64(float64(math.Cos(radians(lat1))*math.Cos(radians(lat2)))*
+			float64(dLon*dLon))
+	)
+	if a > 1 {
+		a = 1
+	}
+	return float64(2 * earthRadius * math.Asin(math.Sqrt(a)))
+}
+
+// geoDistance implements geo_distance(lat1, lon1, lat2, lon2), which returns the great-circle
+// distance in meters.
+func geoDistance(lat1, lon1, lat2, lon2 interface{}) (result interface{}, err error) {
+	v, ok, err := geoFloatArgs(lat1, lon1, lat2, lon2)
+	if !ok || err != nil {
+		return
+	}
+	if err = checkCoordinate(v[0], v[1]); err != nil {
+		return
+	}
+	if err = checkCoordinate(v[2], v[3]); err != nil {
+		return
+	}
+	return haversine(v[0], v[1], v[2], v[3]), nil
+}
+
+// geoInBBox implements geo_in_bbox(lat, lon, min_lat, min_lon, max_lat, max_lon), which returns
+// whether the point is in the bounding box. The box crosses the antimeridian if min_lon is
+// greater than max_lon.
+func geoInBBox(lat, lon, minLat, minLon, maxLat, maxLon interface{}) (result interface{}, err error) {
+	v, ok, err := geoFloatArgs(lat, lon, minLat, minLon, maxLat, maxLon)
+	if !ok || err != nil {
+		return
+	}
+	for i := 0; i < len(v); i += 2 {
+		if err = checkCoordinate(v[i], v[i+1]); err != nil {
+			return
+		}
+	}
+	if v[0] < v[2] || v[0] > v[4] {
+		return false, nil
+	}
+	if v[3] <= v[5] {
+		return v[1] >= v[3] && v[1] <= v[5], nil
+	}
+	return v[1] >= v[3] || v[1] <= v[5], nil
+}
+
+// geohashEncode implements geohash_encode(lat, lon, precision), which returns the geohash of the
+// point with precision characters.
+func geohashEncode(lat, lon, precision interface{}) (result interface{}, err error) {
+	v, ok, err := geoFloatArgs(lat, lon)
+	if !ok || err != nil {
+		return
+	}
+	if err = checkCoordinate(v[0], v[1]); err != nil {
+		return
+	}
+	p, ok := precision.(int64)
+	if !ok || p < 1 || p > geohashMaxPrecision {
+		err = errors.Errorf("geohash precision must be an integer between 1 and %d", geohashMaxPrecision)
+		return
+	}
+
+	var (
+		hash     = make([]byte, 0, p)
+		latRange = [2]float64{-90, 90}
+		lonRange = [2]float64{-180, 180}
+		even     = true
+		bit      uint
+		ch       int
+	)
+	for int64(len(hash)) < p {
+		rng, value := &latRange, v[0]
+		if even {
+			rng, value = &lonRange, v[1]
+		}
+		mid := float64((rng[0] + rng[1]) / 2)
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			rng[0] = mid
+		} else {
+			rng[1] = mid
+		}
+		even = !even
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash), nil
+}
+
+// geohashCell returns the latitude and longitude ranges of the geohash cell.
+func geohashCell(hash interface{}) (latRange, lonRange [2]float64, ok bool, err error) {
+	var h string
+	if isNull(hash) {
+		return
+	}
+	switch v := hash.(type) {
+	case string:
+		h = v
+	case []byte:
+		h = string(v)
+	default:
+		err = errors.New("geohash must be a string")
+		return
+	}
+	if h == "" || len(h) > geohashMaxPrecision {
+		err = errors.Errorf("invalid geohash %q", h)
+		return
+	}
+
+	latRange, lonRange = [2]float64{-90, 90}, [2]float64{-180, 180}
+	even := true
+	for _, c := range strings.ToLower(h) {
+		idx := strings.IndexRune(geohashAlphabet, c)
+		if idx < 0 {
+			err = errors.Errorf("invalid geohash %q", h)
+			return
+		}
+		for bit := 4; bit >= 0; bit-- {
+			rng := &latRange
+			if even {
+				rng = &lonRange
+			}
+			mid := float64((rng[0] + rng[1]) / 2)
+			if idx&(1<<uint(bit)) != 0 {
+				rng[0] = mid
+			} else {
+				rng[1] = mid
+			}
+			even = !even
+		}
+	}
+	return latRange, lonRange, true, nil
+}
+
+// geohashLat implements geohash_lat(hash), which returns the latitude of the geohash cell center.
+func geohashLat(hash interface{}) (result interface{}, err error) {
+	latRange, _, ok, err := geohashCell(hash)
+	if !ok || err != nil {
+		return
+	}
+	return float64((latRange[0] + latRange[1]) / 2), nil
+}
+
+// geohashLon implements geohash_lon(hash), which returns the longitude of the geohash cell center.
+func geohashLon(hash interface{}) (result interface{}, err error) {
+	_, lonRange, ok, err := geohashCell(hash)
+	if !ok || err != nil {
+		return
+	}
+	return float64((lonRange[0] + lonRange[1]) / 2), nil
+}
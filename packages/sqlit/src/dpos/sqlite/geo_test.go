@@ -0,0 +1,90 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGeoFunctions(t *testing.T) {
+	Convey("Given a sqlite storage with geospatial functions", t, func() {
+		var fl = path.Join(testingDataDir, t.Name())
+		st, err := NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		defer func() {
+			_ = st.Close()
+			_ = os.Remove(fl)
+			_ = os.Remove(fl + "-shm")
+			_ = os.Remove(fl + "-wal")
+		}()
+
+		Convey("The haversine distance should be computed in meters", func() {
+			var d float64
+			// Paris to London
+			err = st.Reader().QueryRow(`SELECT geo_distance(48.8566, 2.3522, 51.5074, -0.1278)`).Scan(&d)
+			So(err, ShouldBeNil)
+			So(d, ShouldAlmostEqual, 343556, 100)
+			err = st.Reader().QueryRow(`SELECT geo_distance(0, 0, 0, 180)`).Scan(&d)
+			So(err, ShouldBeNil)
+			So(d, ShouldAlmostEqual, 20015115, 1)
+
+			var n sql.NullFloat64
+			err = st.Reader().QueryRow(`SELECT geo_distance(NULL, 0, 0, 0)`).Scan(&n)
+			So(err, ShouldBeNil)
+			So(n.Valid, ShouldBeFalse)
+			err = st.Reader().QueryRow(`SELECT geo_distance(91, 0, 0, 0)`).Scan(&d)
+			So(err, ShouldNotBeNil)
+			err = st.Reader().QueryRow(`SELECT geo_distance('x', 0, 0, 0)`).Scan(&d)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The bounding box checks should handle the antimeridian", func() {
+			var in bool
+			err = st.Reader().QueryRow(`SELECT geo_in_bbox(10, 20, 0, 0, 30, 30)`).Scan(&in)
+			So(err, ShouldBeNil)
+			So(in, ShouldBeTrue)
+			err = st.Reader().QueryRow(`SELECT geo_in_bbox(10, 40, 0, 0, 30, 30)`).Scan(&in)
+			So(err, ShouldBeNil)
+			So(in, ShouldBeFalse)
+			err = st.Reader().QueryRow(`SELECT geo_in_bbox(10, -179.5, 0, 170, 30, -170)`).Scan(&in)
+			So(err, ShouldBeNil)
+			So(in, ShouldBeTrue)
+			err = st.Reader().QueryRow(`SELECT geo_in_bbox(10, 0, 0, 170, 30, -170)`).Scan(&in)
+			So(err, ShouldBeNil)
+			So(in, ShouldBeFalse)
+		})
+		Convey("The geohashes should be encoded and decoded", func() {
+			var h string
+			err = st.Reader().QueryRow(`SELECT geohash_encode(57.64911, 10.40744, 11)`).Scan(&h)
+			So(err, ShouldBeNil)
+			So(h, ShouldEqual, "u4pruydqqvj")
+			err = st.Reader().QueryRow(`SELECT geohash_encode(0, 0, 13)`).Scan(&h)
+			So(err, ShouldNotBeNil)
+
+			var lat, lon float64
+			err = st.Reader().QueryRow(`SELECT geohash_lat('u4pruydqqvj'), geohash_lon('U4PRUYDQQVJ')`).
+				Scan(&lat, &lon)
+			So(err, ShouldBeNil)
+			So(lat, ShouldAlmostEqual, 57.64911, 1e-5)
+			So(lon, ShouldAlmostEqual, 10.40744, 1e-5)
+			err = st.Reader().QueryRow(`SELECT geohash_lat('u4a')`).Scan(&lat)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The functions should be usable in the indexes", func() {
+			_, err = st.Writer().Exec(`CREATE TABLE "places" ("id" INT, "lat" REAL, "lon" REAL)`)
+			So(err, ShouldBeNil)
+			_, err = st.Writer().Exec(`CREATE INDEX "places_geohash" ON "places" (geohash_encode("lat", "lon", 5))`)
+			So(err, ShouldBeNil)
+			_, err = st.Writer().Exec(`INSERT INTO "places" VALUES (1, 48.8566, 2.3522), (2, 51.5074, -0.1278)`)
+			So(err, ShouldBeNil)
+			var id int
+			err = st.Writer().QueryRow(`SELECT "id" FROM "places"
+				WHERE geo_distance("lat", "lon", 48.85, 2.35) < 1000`).Scan(&id)
+			So(err, ShouldBeNil)
+			So(id, ShouldEqual, 1)
+		})
+	})
+}
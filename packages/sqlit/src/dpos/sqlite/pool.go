@@ -0,0 +1,151 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"strconv"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/storage"
+)
+
+const (
+	// PoolMaxReadersParam is the DSN parameter of the max count of open read connections.
+	PoolMaxReadersParam = "_max_readers"
+	// PoolBusyTimeoutParam is the DSN parameter of the busy timeout in milliseconds.
+	PoolBusyTimeoutParam = "_busy_timeout"
+	// PoolCacheSizeParam is the DSN parameter of the page cache size of a connection.
+	PoolCacheSizeParam = "_cache_size"
+	// PoolMMapSizeParam is the DSN parameter of the max bytes of memory-mapped I/O.
+	PoolMMapSizeParam = "_mmap_size"
+)
+
+var (
+	// DefaultPoolOptions defines the connection pool settings used if not specified in DSN, which
+	// are the defaults of the sqlite driver.
+	DefaultPoolOptions = xi.PoolOptions{
+		MaxReaders:  0,
+		BusyTimeout: 5 * time.Second,
+		CacheSize:   -2000,
+		MMapSize:    0,
+	}
+
+	// ErrInvalidPoolOptions indicates the connection pool settings are out of range.
+	ErrInvalidPoolOptions = errors.New("invalid connection pool options")
+)
+
+// AddPoolParams sets the DSN parameters of the connection pool settings opts.
+func AddPoolParams(dsn *storage.DSN, opts xi.PoolOptions) {
+	dsn.AddParam(PoolMaxReadersParam, strconv.Itoa(opts.MaxReaders))
+	dsn.AddParam(PoolBusyTimeoutParam, strconv.FormatInt(int64(opts.BusyTimeout/time.Millisecond), 10))
+	dsn.AddParam(PoolCacheSizeParam, strconv.Itoa(opts.CacheSize))
+	dsn.AddParam(PoolMMapSizeParam, strconv.FormatInt(opts.MMapSize, 10))
+}
+
+// parsePoolParams returns the connection pool settings in dsn and removes the parameters from it.
+func parsePoolParams(dsn *storage.DSN) (opts xi.PoolOptions, err error) {
+	opts = DefaultPoolOptions
+	var parsers = []struct {
+		param string
+		parse func(v string) error
+	}{
+		{PoolMaxReadersParam, func(v string) (err error) {
+			opts.MaxReaders, err = strconv.Atoi(v)
+			return
+		}},
+		{PoolBusyTimeoutParam, func(v string) (err error) {
+			var ms int64
+			ms, err = strconv.ParseInt(v, 10, 64)
+			opts.BusyTimeout = time.Duration(ms) * time.Millisecond
+			return
+		}},
+		{PoolCacheSizeParam, func(v string) (err error) {
+			opts.CacheSize, err = strconv.Atoi(v)
+			return
+		}},
+		{PoolMMapSizeParam, func(v string) (err error) {
+			opts.MMapSize, err = strconv.ParseInt(v, 10, 64)
+			return
+		}},
+	}
+	for _, p := range parsers {
+		v, ok := dsn.GetParam(p.param)
+		if !ok {
+			continue
+		}
+		if err = p.parse(v); err != nil {
+			err = errors.Wrapf(err, "invalid %s", p.param)
+			return
+		}
+		dsn.AddParam(p.param, "")
+	}
+	err = validatePoolOptions(opts)
+	return
+}
+
+func validatePoolOptions(opts xi.PoolOptions) error {
+	if opts.MaxReaders < 0 || opts.BusyTimeout < 0 || opts.MMapSize < 0 {
+		return errors.Wrapf(ErrInvalidPoolOptions, "%+v", opts)
+	}
+	return nil
+}
+
+// poolPragmas returns the pragmas to apply the connection pool settings opts to a connection.
+func poolPragmas(opts xi.PoolOptions) []string {
+	return []string{
+		fmt.Sprintf("PRAGMA busy_timeout=%d", opts.BusyTimeout/time.Millisecond),
+		fmt.Sprintf("PRAGMA cache_size=%d", opts.CacheSize),
+		fmt.Sprintf("PRAGMA mmap_size=%d", opts.MMapSize),
+	}
+}
+
+// connector opens connections of a SQLite3 instance with the page encryption key, the role
+// pragmas and the connection pool settings of the instance.
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (s *SQLite3) newConnector(dsn string, require bool, pragmas ...string) *connector {
+	return &connector{
+		dsn: dsn,
+		driver: &sqlite3.SQLiteDriver{
+			ConnectHook: func(c *sqlite3.SQLiteConn) (err error) {
+				if s.key != "" {
+					if err = applyKey(c, s.key, require); err != nil {
+						return
+					}
+				}
+				for _, ps := range [][]string{pragmas, poolPragmas(s.pool)} {
+					for _, p := range ps {
+						if _, err = c.Exec(p, nil); err != nil {
+							return errors.Wrapf(err, "exec %s", p)
+						}
+					}
+				}
+				return regCustomFunc(c)
+			},
+		},
+	}
+}
+
+// Connect implements driver.Connector.Connect.
+func (c *connector) Connect(_ context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements driver.Connector.Driver.
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// applyPoolLimits applies the connection count limits to the readers.
+func (s *SQLite3) applyPoolLimits() {
+	s.reader.SetMaxOpenConns(s.pool.MaxReaders)
+	s.dirtyReader.SetMaxOpenConns(s.pool.MaxReaders)
+}
@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/storage"
+)
+
+func queryPragmas(db *sql.DB) (busyTimeout, cacheSize int, mmapSize int64, err error) {
+	if err = db.QueryRow("PRAGMA busy_timeout").Scan(&busyTimeout); err != nil {
+		return
+	}
+	if err = db.QueryRow("PRAGMA cache_size").Scan(&cacheSize); err != nil {
+		return
+	}
+	// mmap_size returns no row if memory-mapped I/O is not supported
+	if err = db.QueryRow("PRAGMA mmap_size").Scan(&mmapSize); err == sql.ErrNoRows {
+		err = nil
+	}
+	return
+}
+
+func TestPoolParams(t *testing.T) {
+	Convey("Pool options should be formatted to and parsed from DSN", t, func() {
+		var opts = xi.PoolOptions{
+			MaxReaders:  4,
+			BusyTimeout: 1500 * time.Millisecond,
+			CacheSize:   -4096,
+			MMapSize:    1 << 20,
+		}
+		dsn, err := storage.NewDSN("file:test.db3?cache=shared")
+		So(err, ShouldBeNil)
+		AddPoolParams(dsn, opts)
+		dsn, err = storage.NewDSN(dsn.Format())
+		So(err, ShouldBeNil)
+		parsed, err := parsePoolParams(dsn)
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, opts)
+		So(dsn.Format(), ShouldEqual, "file:test.db3?cache=shared")
+
+		dsn, err = storage.NewDSN("file:test.db3")
+		So(err, ShouldBeNil)
+		parsed, err = parsePoolParams(dsn)
+		So(err, ShouldBeNil)
+		So(parsed, ShouldResemble, DefaultPoolOptions)
+
+		for _, v := range []string{
+			PoolMaxReadersParam + "=-1",
+			PoolMMapSizeParam + "=big",
+		} {
+			dsn, err = storage.NewDSN("file:test.db3?" + v)
+			So(err, ShouldBeNil)
+			_, err = parsePoolParams(dsn)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestPoolPragmas(t *testing.T) {
+	Convey("Given a storage with pool options in DSN", t, func() {
+		var (
+			fl  = path.Join(testingDataDir, t.Name())
+			st  *SQLite3
+			err error
+		)
+		st, err = NewSqlite(fmt.Sprintf("file:%s?%s=2&%s=1000&%s=-1024",
+			fl, PoolMaxReadersParam, PoolBusyTimeoutParam, PoolCacheSizeParam))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			So(st.Close(), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err = os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+
+		Convey("The pragmas should be applied to all connections", func() {
+			for _, db := range []*sql.DB{st.DirtyReader(), st.Reader(), st.Writer()} {
+				busyTimeout, cacheSize, _, err := queryPragmas(db)
+				So(err, ShouldBeNil)
+				So(busyTimeout, ShouldEqual, 1000)
+				So(cacheSize, ShouldEqual, -1024)
+			}
+			So(st.Reader().Stats().MaxOpenConnections, ShouldEqual, 2)
+			So(st.DirtyReader().Stats().MaxOpenConnections, ShouldEqual, 2)
+			So(st.Writer().Stats().MaxOpenConnections, ShouldEqual, 0)
+		})
+	})
+}
@@ -2,21 +2,26 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"encoding/binary"
 	"math"
+	"os"
+	"strconv"
 	"time"
 
 	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
 
 	"sqlit/src/crypto/symmetric"
+	xi "sqlit/src/dpos/interfaces"
 	"sqlit/src/storage"
 	"sqlit/src/utils/log"
 )
 
 const (
-	serializableDriver = "sqlite3-custom"
-	dirtyReadDriver    = "sqlite3-dirty-reader"
+	// backupPagesPerStep is the page count copied in each online backup step.
+	backupPagesPerStep = 1024
 )
 
 // Vector helper functions for sqlite-vec compatible operations.
@@ -108,6 +113,9 @@ func vecLength(data []byte) int {
 	return len(data) / 4
 }
 
+// regCustomFunc registers the custom sql functions to a new connection.
+var regCustomFunc func(c *sqlite3.SQLiteConn) error
+
 func init() {
 	encryptFunc := func(in, pass, salt []byte) (out []byte, err error) {
 		out, err = symmetric.EncryptWithPassword(in, pass, salt)
@@ -126,7 +134,7 @@ func init() {
 		return t
 	}
 
-	regCustomFunc := func(c *sqlite3.SQLiteConn) (err error) {
+	regCustomFunc = func(c *sqlite3.SQLiteConn) (err error) {
 		if err = c.RegisterFunc("sleep", sleepFunc, true); err != nil {
 			return
 		}
@@ -149,33 +157,31 @@ func init() {
 		if err = c.RegisterFunc("vec_length", vecLength, true); err != nil {
 			return
 		}
-		return
-	}
-
-	sql.Register(dirtyReadDriver, &sqlite3.SQLiteDriver{
-		ConnectHook: func(c *sqlite3.SQLiteConn) (err error) {
-			if _, err = c.Exec("PRAGMA read_uncommitted=1", nil); err != nil {
-				return
-			}
-			if err = regCustomFunc(c); err != nil {
-				return
-			}
+		// Register geospatial functions
+		if err = c.RegisterFunc("geo_distance", geoDistance, true); err != nil {
 			return
-		},
-	})
-	sql.Register(serializableDriver, &sqlite3.SQLiteDriver{
-		ConnectHook: func(c *sqlite3.SQLiteConn) (err error) {
-			if err = regCustomFunc(c); err != nil {
-				return
-			}
+		}
+		if err = c.RegisterFunc("geo_in_bbox", geoInBBox, true); err != nil {
 			return
-		},
-	})
+		}
+		if err = c.RegisterFunc("geohash_encode", geohashEncode, true); err != nil {
+			return
+		}
+		if err = c.RegisterFunc("geohash_lat", geohashLat, true); err != nil {
+			return
+		}
+		if err = c.RegisterFunc("geohash_lon", geohashLon, true); err != nil {
+			return
+		}
+		return
+	}
 }
 
 // SQLite3 is the sqlite3 implementation of the dpos/interfaces.Storage interface.
 type SQLite3 struct {
 	filename    string
+	key         string
+	pool        xi.PoolOptions
 	dirtyReader *sql.DB
 	reader      *sql.DB
 	writer      *sql.DB
@@ -194,6 +200,24 @@ func NewSqlite(filename string) (s *SQLite3, err error) {
 	if dsn, err = storage.NewDSN(filename); err != nil {
 		return
 	}
+	if instance.pool, err = parsePoolParams(dsn); err != nil {
+		return
+	}
+	if err = validateSynchronousParam(dsn); err != nil {
+		return
+	}
+	if key, ok := dsn.GetParam(CryptoKeyParam); ok {
+		var require = true
+		if v, ok := dsn.GetParam(CryptoRequireParam); ok {
+			if require, err = strconv.ParseBool(v); err != nil {
+				err = errors.Wrapf(err, "invalid %s", CryptoRequireParam)
+				return
+			}
+		}
+		dsn.AddParam(CryptoKeyParam, "")
+		dsn.AddParam(CryptoRequireParam, "")
+		return newEncryptedSqlite(dsn.GetFileName(), dsn, instance.pool, key, require)
+	}
 
 	dsnRO := dsn.Clone()
 	dsnRO.AddParam("_journal_mode", "WAL")
@@ -209,17 +233,14 @@ func NewSqlite(filename string) (s *SQLite3, err error) {
 	dsnSHMRW := dsn.Clone()
 	dsnSHMRW.AddParam("_journal_mode", "WAL")
 	dsnSHMRW.AddParam("cache", "shared")
+	// allow free pages to be released by online maintenance, takes effect on new databases only
+	dsnSHMRW.AddParam("_auto_vacuum", "incremental")
 	shmRWDSN = dsnSHMRW.Format()
 
-	if instance.dirtyReader, err = sql.Open(dirtyReadDriver, shmRODSN); err != nil {
-		return
-	}
-	if instance.reader, err = sql.Open(serializableDriver, privRODSN); err != nil {
-		return
-	}
-	if instance.writer, err = sql.Open(serializableDriver, shmRWDSN); err != nil {
-		return
-	}
+	instance.dirtyReader = sql.OpenDB(instance.newConnector(shmRODSN, false, "PRAGMA read_uncommitted=1"))
+	instance.reader = sql.OpenDB(instance.newConnector(privRODSN, false))
+	instance.writer = sql.OpenDB(instance.newConnector(shmRWDSN, false))
+	instance.applyPoolLimits()
 	s = instance
 	return
 }
@@ -252,3 +273,164 @@ func (s *SQLite3) Close() (err error) {
 	}
 	return
 }
+
+// Backup implements Backup method of the dpos/interfaces.Backuper interface. It copies the
+// committed data to dst with the SQLite online backup API.
+func (s *SQLite3) Backup(ctx context.Context, dst string) (err error) {
+	var conn *sql.Conn
+	if conn, err = s.reader.Conn(ctx); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) (err error) {
+		src, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return errors.New("unexpected sqlite driver connection type")
+		}
+		var (
+			drv     = &sqlite3.SQLiteDriver{}
+			rawDest interface{}
+		)
+		if rawDest, err = drv.Open(dst); err != nil {
+			return errors.Wrapf(err, "open backup destination %s", dst)
+		}
+		dest := rawDest.(*sqlite3.SQLiteConn)
+		defer dest.Close()
+		// keep the backup encrypted with the same key
+		if s.key != "" {
+			if err = applyKey(dest, s.key, false); err != nil {
+				return
+			}
+		}
+
+		var backup *sqlite3.SQLiteBackup
+		if backup, err = dest.Backup("main", src, "main"); err != nil {
+			return errors.Wrap(err, "init backup")
+		}
+		var done bool
+		for !done {
+			select {
+			case <-ctx.Done():
+				_ = backup.Finish()
+				return ctx.Err()
+			default:
+			}
+			if done, err = backup.Step(backupPagesPerStep); err != nil {
+				_ = backup.Finish()
+				return errors.Wrap(err, "backup step")
+			}
+		}
+		return backup.Finish()
+	})
+}
+
+// Maintain implements Maintain method of the dpos/interfaces.Maintainer interface. The tasks run
+// on a single writer connection, so the caller should commit any pending write transaction first.
+func (s *SQLite3) Maintain(
+	ctx context.Context, opts xi.MaintenanceOptions) (result xi.MaintenanceResult, err error,
+) {
+	var (
+		conn                  *sql.Conn
+		pageSize, pagesBefore int64
+		pagesAfter            int64
+	)
+	if conn, err = s.writer.Conn(ctx); err != nil {
+		return
+	}
+	defer conn.Close()
+
+	if err = conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		err = errors.Wrap(err, "query page size")
+		return
+	}
+	if err = conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pagesBefore); err != nil {
+		err = errors.Wrap(err, "query page count")
+		return
+	}
+	if opts.IncrementalVacuum {
+		if err = incrementalVacuum(ctx, conn); err != nil {
+			return
+		}
+		if err = conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pagesAfter); err != nil {
+			err = errors.Wrap(err, "query page count")
+			return
+		}
+		if pagesAfter < pagesBefore {
+			result.ReclaimedBytes = (pagesBefore - pagesAfter) * pageSize
+		}
+	}
+	if opts.Analyze {
+		if _, err = conn.ExecContext(ctx, "ANALYZE"); err != nil {
+			err = errors.Wrap(err, "analyze")
+			return
+		}
+	}
+	if opts.Checkpoint {
+		// the truncate mode reports the counters of the reset log, so measure the log file instead
+		var (
+			walFile          string
+			before           int64
+			busy, frames, cp int64
+		)
+		if walFile, err = mainFileName(ctx, conn); err != nil {
+			return
+		}
+		walFile += "-wal"
+		if fi, serr := os.Stat(walFile); serr == nil {
+			before = fi.Size()
+		}
+		if err = conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(PASSIVE)").Scan(
+			&busy, &frames, &cp,
+		); err != nil {
+			err = errors.Wrap(err, "checkpoint")
+			return
+		}
+		result.CheckpointedFrames = cp
+		if err = conn.QueryRowContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)").Scan(
+			&busy, &frames, &cp,
+		); err != nil {
+			err = errors.Wrap(err, "checkpoint")
+			return
+		}
+		if fi, serr := os.Stat(walFile); serr == nil && fi.Size() < before {
+			result.WALBytes = before - fi.Size()
+		}
+	}
+	return
+}
+
+// incrementalVacuum releases all free pages, the pragma frees one page per step of the statement.
+func incrementalVacuum(ctx context.Context, conn *sql.Conn) (err error) {
+	var rows *sql.Rows
+	if rows, err = conn.QueryContext(ctx, "PRAGMA incremental_vacuum"); err != nil {
+		return errors.Wrap(err, "incremental vacuum")
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return errors.Wrap(rows.Err(), "incremental vacuum")
+}
+
+// mainFileName returns the file name of the main database of conn.
+func mainFileName(ctx context.Context, conn *sql.Conn) (name string, err error) {
+	var (
+		rows       *sql.Rows
+		seq        int
+		schema, fn string
+	)
+	if rows, err = conn.QueryContext(ctx, "PRAGMA database_list"); err != nil {
+		return "", errors.Wrap(err, "query database list")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err = rows.Scan(&seq, &schema, &fn); err != nil {
+			return "", errors.Wrap(err, "query database list")
+		}
+		if schema == "main" {
+			name = fn
+		}
+	}
+	err = errors.Wrap(rows.Err(), "query database list")
+	return
+}
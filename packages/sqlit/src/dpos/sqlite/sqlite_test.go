@@ -2,6 +2,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"math/rand"
@@ -1160,3 +1161,102 @@ func idleWriteTx(
 //	})
 //	teardownBenchmarkStorage(b, st)
 //}
+
+func TestBackup(t *testing.T) {
+	Convey("Given a sqlite storage with some data", t, func() {
+		var (
+			fl  = path.Join(testingDataDir, t.Name())
+			bk  = path.Join(testingDataDir, t.Name()+".bak")
+			st  *SQLite3
+			err error
+		)
+		st, err = NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			So(st.Close(), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal", bk} {
+				err = os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+		_, err = st.Writer().Exec(`CREATE TABLE "t1" ("k" INT, "v" TEXT, PRIMARY KEY("k"))`)
+		So(err, ShouldBeNil)
+		for i := 0; i < 100; i++ {
+			_, err = st.Writer().Exec(`INSERT INTO "t1" ("k", "v") VALUES (?, ?)`, i, fmt.Sprint("v", i))
+			So(err, ShouldBeNil)
+		}
+		Convey("The backup should contain all committed data", func() {
+			var b xi.Backuper = st
+			err = b.Backup(context.Background(), bk)
+			So(err, ShouldBeNil)
+
+			restored, err := NewSqlite(fmt.Sprint("file:", bk))
+			So(err, ShouldBeNil)
+			defer restored.Close()
+			var count int
+			err = restored.Reader().QueryRow(`SELECT COUNT(1) FROM "t1"`).Scan(&count)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 100)
+		})
+		Convey("The backup should be aborted with cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			err = st.Backup(ctx, bk)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestMaintain(t *testing.T) {
+	Convey("Given a sqlite storage with deleted data", t, func() {
+		var (
+			fl  = path.Join(testingDataDir, t.Name())
+			st  *SQLite3
+			err error
+		)
+		st, err = NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			So(st.Close(), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err = os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+		_, err = st.Writer().Exec(`CREATE TABLE "t1" ("k" INT, "v" TEXT, PRIMARY KEY("k"))`)
+		So(err, ShouldBeNil)
+		for i := 0; i < 1000; i++ {
+			_, err = st.Writer().Exec(
+				`INSERT INTO "t1" ("k", "v") VALUES (?, ?)`, i, strings.Repeat("v", 512))
+			So(err, ShouldBeNil)
+		}
+		_, err = st.Writer().Exec(`DELETE FROM "t1"`)
+		So(err, ShouldBeNil)
+
+		Convey("The maintenance should reclaim free pages and truncate the wal", func() {
+			var (
+				m      xi.Maintainer = st
+				result xi.MaintenanceResult
+				fi     os.FileInfo
+			)
+			result, err = m.Maintain(context.Background(), xi.MaintenanceOptions{
+				Checkpoint:        true,
+				IncrementalVacuum: true,
+				Analyze:           true,
+			})
+			So(err, ShouldBeNil)
+			So(result.ReclaimedBytes, ShouldBeGreaterThan, 0)
+			So(result.CheckpointedFrames, ShouldBeGreaterThan, 0)
+			So(result.WALBytes, ShouldBeGreaterThan, 0)
+			fi, err = os.Stat(fl + "-wal")
+			So(err, ShouldBeNil)
+			So(fi.Size(), ShouldEqual, 0)
+		})
+		Convey("The maintenance should be aborted with cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err = st.Maintain(ctx, xi.MaintenanceOptions{Checkpoint: true})
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -4,6 +4,7 @@ package dpos
 import (
 	"context"
 	"database/sql"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -43,6 +44,7 @@ type State struct {
 	sync.RWMutex
 	strg   xi.Storage
 	pool   *pool
+	poolMu sync.Mutex // guards pool, the writers must hold the state lock first
 	closed bool
 	nodeID proto.NodeID
 
@@ -51,6 +53,14 @@ type State struct {
 	lastCommitPoint uint64
 	current         uint64 // current is the current lastSeq of the current transaction
 	hasSchemaChange uint32 // indicates schema change happens in this uncommitted transaction
+
+	applyConcurrency int // max count of requests prepared in parallel for replaying
+	resultLimit      ResultLimit
+	clock            func() time.Time // clock of the leader timestamps, nil means the local time
+
+	groupCommitDelay int64        // max delay of the group commit, 0 disables the group commit
+	group            *commitGroup // the write requests waiting for the ongoing transaction
+	snapshotReads    uint32       // reads the last committed snapshot with any isolation level
 }
 
 // NewState returns a new State bound to strg.
@@ -61,13 +71,15 @@ func NewState(level sql.IsolationLevel, nodeID proto.NodeID, strg xi.Storage) (s
 		strg:   strg,
 		pool:   newPool(),
 		maxTx:  100,
+
+		applyConcurrency: runtime.NumCPU(),
 	}
 	s.openHandler()
 	return
 }
 
 func (s *State) openHandler() {
-	if s.level == sql.LevelReadUncommitted {
+	if s.level == sql.LevelReadUncommitted || s.isGrouped() {
 		var err error
 		if s.handler, err = s.strg.Writer().Begin(); err != nil {
 			log.WithError(err).Fatal("failed to open transaction")
@@ -78,12 +90,34 @@ func (s *State) openHandler() {
 }
 
 func (s *State) reader() *sql.DB {
-	if s.level == sql.LevelReadUncommitted {
+	if s.isDirtyRead() {
 		return s.strg.DirtyReader()
 	}
 	return s.strg.Reader()
 }
 
+// isDirtyRead returns whether the reads see the uncommitted writes of the ongoing transaction.
+func (s *State) isDirtyRead() bool {
+	return s.level == sql.LevelReadUncommitted && atomic.LoadUint32(&s.snapshotReads) == 0
+}
+
+// readOffset returns the log offset of the state seen by a read starting now. The committed
+// snapshot reads may see some writes committed after the returned offset.
+func (s *State) readOffset() uint64 {
+	if s.isDirtyRead() || !s.isGrouped() && s.level != sql.LevelReadUncommitted {
+		// the writes are visible at once
+		return s.getSeq()
+	}
+	return s.getLastCommitPoint()
+}
+
+// withPool calls fn with the pool of the state.
+func (s *State) withPool(fn func(p *pool)) {
+	s.poolMu.Lock()
+	defer s.poolMu.Unlock()
+	fn(s.pool)
+}
+
 func (s *State) incSeq() {
 	atomic.AddUint64(&s.current, 1)
 }
@@ -130,26 +164,30 @@ func buildTypeNamesFromSQLColumnTypes(types []*sql.ColumnType) (names []string)
 	return
 }
 
-func readSingle(
+// querySingle runs the read query q and returns the open rows with the column names and types.
+func querySingle(
 	ctx context.Context, qer sqlQuerier, q *types.Query,
 ) (
-	names []string, types []string, data [][]interface{}, err error,
+	rows *sql.Rows, names []string, types []string, err error,
 ) {
 	var (
-		rows    *sql.Rows
 		cols    []*sql.ColumnType
 		pattern string
 		args    []interface{}
 	)
 
-	if _, pattern, args, err = convertQueryAndBuildArgs(q.Pattern, q.Args); err != nil {
+	if _, pattern, args, err = convertQueryAndBuildArgs(
+		substituteIDFunctions(q.Pattern, newLocalIDSource()), q.Args,
+	); err != nil {
 		return
 	}
 	if rows, err = qer.QueryContext(ctx, pattern, args...); err != nil {
 		return
 	}
 	defer func() {
-		_ = rows.Close()
+		if err != nil {
+			_ = rows.Close()
+		}
 	}()
 	// Fetch column names and types
 	if names, err = rows.Columns(); err != nil {
@@ -159,20 +197,30 @@ func readSingle(
 		return
 	}
 	types = buildTypeNamesFromSQLColumnTypes(cols)
+	return
+}
+
+func readSingle(
+	ctx context.Context, qer sqlQuerier, q *types.Query, limit ResultLimit,
+) (
+	names []string, types []string, data [][]interface{}, err error,
+) {
+	var (
+		rows *sql.Rows
+		next []interface{}
+	)
+	if rows, names, types, err = querySingle(ctx, qer, q); err != nil {
+		return
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
 	// Scan data row by row
-	data = make([][]interface{}, 0)
-	for rows.Next() {
-		var (
-			row  = make([]interface{}, len(cols))
-			dest = make([]interface{}, len(cols))
-		)
-		for i := range row {
-			dest[i] = &row[i]
-		}
-		if err = rows.Scan(dest...); err != nil {
-			return
-		}
-		data = append(data, row)
+	if data, next, err = scanRows(ctx, rows, len(names), limit, nil, false); err != nil {
+		return
+	}
+	if next != nil {
+		err = errors.Wrapf(ErrResultTooLarge, "limit %s", limit)
 	}
 	return
 }
@@ -193,24 +241,24 @@ func (s *State) readWithContext(
 	ctx context.Context, req *types.Request) (ref *QueryTracker, resp *types.Response, err error,
 ) {
 	var (
+		id             = s.readOffset()
 		ierr           error
 		cnames, ctypes []string
 		data           [][]interface{}
 	)
 	// TODO(leventeliu): no need to run every read query here.
+	limit := s.resultLimit.forRequest(req)
 	for i, v := range req.Payload.Queries {
-		if cnames, ctypes, data, ierr = readSingle(ctx, s.reader(), &v); ierr != nil {
+		if cnames, ctypes, data, ierr = readSingle(ctx, s.reader(), &v, limit); ierr != nil {
 			err = errors.Wrapf(ierr, "query at #%d failed", i)
 			// Add to failed pool list
-			s.pool.setFailed(req)
+			s.withPool(func(p *pool) { p.setFailed(req) })
 			return
 		}
 	}
 	// Build query response
 	ref = &QueryTracker{Req: req}
-	s.Lock()
-	s.pool.enqueueRead(ref)
-	s.Unlock()
+	s.withPool(func(p *pool) { p.enqueueRead(ref) })
 	resp = &types.Response{
 		Header: types.SignedResponseHeader{
 			ResponseHeader: types.ResponseHeader{
@@ -219,7 +267,70 @@ func (s *State) readWithContext(
 				NodeID:      s.nodeID,
 				Timestamp:   s.getLocalTime(),
 				RowCount:    uint64(len(data)),
-				LogOffset:   s.getSeq(),
+				LogOffset:   id,
+			},
+		},
+		Payload: types.ResponsePayload{
+			Columns:   cnames,
+			DeclTypes: ctypes,
+			Rows:      buildRowsFromNativeData(data),
+		},
+	}
+	return
+}
+
+// QuerySnapshot runs the read queries of req on a read-only snapshot db in a single transaction.
+// The response is not tracked by any pool, so it is never acknowledged or packed into blocks.
+func QuerySnapshot(
+	ctx context.Context, db *sql.DB, nodeID proto.NodeID, req *types.Request, limit ResultLimit,
+) (
+	resp *types.Response, err error,
+) {
+	return querySnapshot(ctx, db, nodeID, 0, req, limit)
+}
+
+// ReadOnlyQuery runs the read queries of req on the committed state without tracking, it may
+// be served while the state is still catching up.
+func (s *State) ReadOnlyQuery(ctx context.Context, req *types.Request) (resp *types.Response, err error) {
+	return querySnapshot(ctx, s.strg.Reader(), s.nodeID, s.getLastCommitPoint(), req, s.resultLimit)
+}
+
+func querySnapshot(
+	ctx context.Context, db *sql.DB, nodeID proto.NodeID, offset uint64, req *types.Request,
+	limit ResultLimit,
+) (
+	resp *types.Response, err error,
+) {
+	if req.Header.QueryType != types.ReadQuery {
+		err = errors.Wrap(ErrInvalidRequest, "snapshot is read-only")
+		return
+	}
+	var (
+		tx             *sql.Tx
+		cnames, ctypes []string
+		data           [][]interface{}
+	)
+	if tx, err = db.BeginTx(ctx, nil); err != nil {
+		err = errors.Wrap(err, "open tx failed")
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	limit = limit.forRequest(req)
+	for i, v := range req.Payload.Queries {
+		if cnames, ctypes, data, err = readSingle(ctx, tx, &v, limit); err != nil {
+			err = errors.Wrapf(err, "query at #%d failed", i)
+			return
+		}
+	}
+	resp = &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:     req.Header.RequestHeader,
+				RequestHash: req.Header.Hash(),
+				NodeID:      nodeID,
+				Timestamp:   time.Now().UTC(),
+				RowCount:    uint64(len(data)),
+				LogOffset:   offset,
 			},
 		},
 		Payload: types.ResponsePayload{
@@ -235,26 +346,41 @@ func (s *State) readTx(
 	ctx context.Context, req *types.Request) (ref *QueryTracker, resp *types.Response, err error,
 ) {
 	var (
-		id             = s.getSeq()
+		id             = s.readOffset()
 		ierr           error
 		cnames, ctypes []string
 		data           [][]interface{}
 		querier        sqlQuerier
+		tx             *sql.Tx
+		cur            *Cursor
 	)
-	if s.level == sql.LevelReadUncommitted && atomic.LoadUint32(&s.hasSchemaChange) == 1 {
+	if s.isDirtyRead() && atomic.LoadUint32(&s.hasSchemaChange) == 1 {
 		// lock transaction
 		s.Lock()
 		defer s.Unlock()
+		// the reads see the uncommitted schema in the write transaction, which must not be written
+		// by them like the query only readers
+		if _, ierr = s.handler.ExecContext(ctx, "PRAGMA query_only = 1"); ierr != nil {
+			err = errors.Wrap(ierr, "set query only failed")
+			return
+		}
+		defer func() {
+			if _, ierr := s.handler.Exec("PRAGMA query_only = 0"); ierr != nil {
+				log.WithError(ierr).Error("failed to reset query only of the write transaction")
+			}
+		}()
 		querier = s.handler
 	} else {
-		var tx *sql.Tx
 		if tx, ierr = s.reader().Begin(); ierr != nil {
 			err = errors.Wrap(ierr, "open tx failed")
 			return
 		}
 		querier = tx
 		defer func() {
-			_ = tx.Rollback()
+			// the transaction is taken over by the cursor if the result is spilled
+			if cur == nil {
+				_ = tx.Rollback()
+			}
 		}()
 	}
 
@@ -268,21 +394,25 @@ func (s *State) readTx(
 		}
 	}()
 
+	// the result of a single query may be spilled to a cursor, except for the dirty reads
+	spill := tx != nil && len(req.Payload.Queries) == 1 && req.Header.ResultCursor()
+	limit := s.resultLimit.forRequest(req)
 	for i, v := range req.Payload.Queries {
-		if cnames, ctypes, data, ierr = readSingle(ctx, querier, &v); ierr != nil {
+		if spill {
+			cnames, ctypes, data, cur, ierr = openCursor(ctx, tx, &v, limit)
+		} else {
+			cnames, ctypes, data, ierr = readSingle(ctx, querier, &v, limit)
+		}
+		if ierr != nil {
 			err = errors.Wrapf(ierr, "query at #%d failed", i)
 			// Add to failed pool list
-			s.Lock()
-			s.pool.setFailed(req)
-			s.Unlock()
+			s.withPool(func(p *pool) { p.setFailed(req) })
 			return
 		}
 	}
 	// Build query response
-	ref = &QueryTracker{Req: req}
-	s.Lock()
-	s.pool.enqueueRead(ref)
-	s.Unlock()
+	ref = &QueryTracker{Req: req, Cursor: cur}
+	s.withPool(func(p *pool) { p.enqueueRead(ref) })
 	resp = &types.Response{
 		Header: types.SignedResponseHeader{
 			ResponseHeader: types.ResponseHeader{
@@ -304,7 +434,7 @@ func (s *State) readTx(
 }
 
 func (s *State) writeSingle(
-	ctx context.Context, q *types.Query) (res sql.Result, err error,
+	ctx context.Context, q *types.Query, ids *idSource) (res sql.Result, err error,
 ) {
 	var (
 		containsDDL bool
@@ -326,11 +456,13 @@ func (s *State) writeSingle(
 	//	}
 	//	log.WithFields(fields).Debug("writeSingle duration stat (us)")
 	//}()
-	if containsDDL, pattern, args, err = convertQueryAndBuildArgs(q.Pattern, q.Args); err != nil {
+	if containsDDL, pattern, args, err = convertQueryAndBuildArgs(
+		substituteIDFunctions(q.Pattern, ids), q.Args,
+	); err != nil {
 		return
 	}
 	//parsed = time.Since(start)
-	if res, err = s.handler.Exec(pattern, args...); err == nil {
+	if res, err = s.handler.ExecContext(ctx, pattern, args...); err == nil {
 		if containsDDL {
 			atomic.StoreUint32(&s.hasSchemaChange, 1)
 		}
@@ -340,18 +472,41 @@ func (s *State) writeSingle(
 	return
 }
 
+// ExecWrite executes the query at index of the write request logged by header on tx with the same
+// query conversion as the state, so that the queries of a block can be replayed out of a state.
+// It returns whether the query changes the schema.
+func ExecWrite(
+	ctx context.Context, tx *sql.Tx, header *types.ResponseHeader, index int, q *types.Query,
+) (containsDDL bool, err error) {
+	var (
+		pattern string
+		args    []interface{}
+		ids     = newIDSource(header.RequestHash, header.LogOffset, header.Timestamp, index)
+	)
+	if containsDDL, pattern, args, err = convertQueryAndBuildArgs(
+		substituteIDFunctions(q.Pattern, ids), q.Args,
+	); err != nil {
+		return
+	}
+	_, err = tx.ExecContext(ctx, pattern, args...)
+	return
+}
+
 func (s *State) write(
 	ctx context.Context, req *types.Request, isLeader bool) (ref *QueryTracker, resp *types.Response, err error,
 ) {
 	var (
 		lastSeq           uint64
 		query             = &QueryTracker{Req: req}
+		group             *commitGroup
+		reqHash           = req.Header.Hash()
+		now               = s.getLocalTime() // the leader timestamp of the generated ids
 		totalAffectedRows int64
 		curAffectedRows   int64
 		lastInsertID      int64
 		start             = time.Now()
 
-		lockAcquired, writeDone, enqueued, lockReleased, respBuilt time.Duration
+		lockAcquired, writeDone, enqueued, lockReleased, grouped, respBuilt time.Duration
 	)
 
 	defer func() {
@@ -367,8 +522,13 @@ func (s *State) write(
 		if lockReleased > 0 {
 			fields["4#lockReleased"] = float64((lockReleased - enqueued).Nanoseconds()) / 1000
 		}
+		if grouped > 0 {
+			fields["5#grouped"] = float64((grouped - lockReleased).Nanoseconds()) / 1000
+		} else {
+			grouped = lockReleased
+		}
 		if respBuilt > 0 {
-			fields["5#respBuilt"] = float64((respBuilt - lockReleased).Nanoseconds()) / 1000
+			fields["6#respBuilt"] = float64((respBuilt - grouped).Nanoseconds()) / 1000
 		}
 		log.WithFields(fields).Debug("Write duration stat (us)")
 		if ctx.Err() != nil {
@@ -380,6 +540,10 @@ func (s *State) write(
 		var (
 			ierr error
 			qcnt = len(req.Payload.Queries)
+			inTx = s.level == sql.LevelReadUncommitted || s.isGrouped()
+			// only the write with a deadline is interruptible
+			execCtx    = context.Background()
+			_, limited = ctx.Deadline()
 		)
 		s.Lock()
 		lockAcquired = time.Since(start)
@@ -387,8 +551,16 @@ func (s *State) write(
 			s.Unlock()
 			lockReleased = time.Since(start)
 		}()
+		if limited {
+			// an interrupted statement rolls back the whole ongoing transaction, commit the
+			// preceding writes first
+			execCtx = ctx
+			if s.getSeq() != s.getLastCommitPoint() {
+				s.flushHandler()
+			}
+		}
 		lastSeq = s.getSeq()
-		if qcnt > 1 && s.level == sql.LevelReadUncommitted {
+		if qcnt > 1 && inTx {
 			// Set savepoint
 			if _, ierr = s.handler.Exec(`SAVEPOINT "?"`, lastSeq); ierr != nil {
 				err = errors.Wrapf(ierr, "failed to create savepoint %d", lastSeq)
@@ -398,7 +570,7 @@ func (s *State) write(
 				_, _ = s.handler.Exec(`ROLLBACK TO "?"`, lastSeq)
 			}()
 		}
-		if s.level != sql.LevelReadUncommitted {
+		if !inTx {
 			// NOTE(leventeliu): this will cancel any uncommitted transaction, and do not harm to
 			// committed ones.
 			defer func() {
@@ -407,11 +579,16 @@ func (s *State) write(
 		}
 		for i, v := range req.Payload.Queries {
 			var res sql.Result
-			if res, ierr = s.writeSingle(ctx, &v); ierr != nil {
+			if res, ierr = s.writeSingle(
+				execCtx, &v, newIDSource(reqHash, lastSeq, now, i),
+			); ierr != nil {
 				err = errors.Wrapf(ierr, "execute at #%d failed", i)
+				if execCtx.Err() != nil {
+					s.discardHandler()
+				}
 				// TODO(leventeliu): request may actually be partial succeed without
 				// rolling back.
-				s.pool.setFailed(req)
+				s.withPool(func(p *pool) { p.setFailed(req) })
 				return
 			}
 
@@ -419,7 +596,7 @@ func (s *State) write(
 			lastInsertID, _ = res.LastInsertId()
 			totalAffectedRows += curAffectedRows
 		}
-		if s.level == sql.LevelReadUncommitted {
+		if inTx {
 			if qcnt > 1 {
 				// Release savepoint
 				if _, ierr = s.handler.Exec(`RELEASE SAVEPOINT "?"`, lastSeq); ierr != nil {
@@ -428,6 +605,9 @@ func (s *State) write(
 				}
 			}
 		}
+		if s.isGrouped() {
+			group = s.joinGroup()
+		}
 		// Try to commit if the ongoing tx is too large or schema is changed
 		if s.getSeq()-s.getLastCommitPoint() > s.maxTx ||
 			atomic.LoadUint32(&s.hasSchemaChange) != 0 {
@@ -435,22 +615,30 @@ func (s *State) write(
 		}
 		writeDone = time.Since(start)
 		if isLeader {
-			s.pool.enqueue(lastSeq, query)
+			s.withPool(func(p *pool) { p.enqueue(lastSeq, query) })
 		}
 		enqueued = time.Since(start)
 		return
 	}(); err != nil {
 		return
 	}
+	if group != nil {
+		// wait for the commit of the group, the writes are not interruptible any more
+		<-group.done
+		grouped = time.Since(start)
+		if err = group.err; err != nil {
+			return
+		}
+	}
 	// Build query response
 	ref = query
 	resp = &types.Response{
 		Header: types.SignedResponseHeader{
 			ResponseHeader: types.ResponseHeader{
 				Request:      req.Header.RequestHeader,
-				RequestHash:  req.Header.Hash(),
+				RequestHash:  reqHash,
 				NodeID:       s.nodeID,
-				Timestamp:    s.getLocalTime(),
+				Timestamp:    now,
 				RowCount:     0,
 				LogOffset:    lastSeq,
 				AffectedRows: totalAffectedRows,
@@ -479,7 +667,10 @@ func (s *State) replay(ctx context.Context, req *types.Request, resp *types.Resp
 		return
 	}
 	for i, v := range req.Payload.Queries {
-		if _, ierr = s.writeSingle(ctx, &v); ierr != nil {
+		// the replayed writes are never interrupted
+		if _, ierr = s.writeSingle(context.Background(), &v, newIDSource(
+			resp.Header.RequestHash, resp.Header.LogOffset, resp.Header.Timestamp, i,
+		)); ierr != nil {
 			err = errors.Wrapf(ierr, "execute at #%d failed", i)
 			return
 		}
@@ -489,7 +680,7 @@ func (s *State) replay(ctx context.Context, req *types.Request, resp *types.Resp
 		atomic.LoadUint32(&s.hasSchemaChange) != 0 {
 		s.flushHandler()
 	}
-	s.pool.enqueue(lastSeq, query)
+	s.withPool(func(p *pool) { p.enqueue(lastSeq, query) })
 	return
 }
 
@@ -503,17 +694,18 @@ func (s *State) ReplayBlock(block *types.Block) (err error) {
 // skips some preceding pooled queries.
 func (s *State) ReplayBlockWithContext(ctx context.Context, block *types.Block) (err error) {
 	var (
-		ierr   error
 		lastsp uint64 // Last lastSeq
+		seq    uint64
+		txs    []*replayTx
 	)
 	s.Lock()
 	defer s.Unlock()
+	seq = s.getSeq()
 	for i, q := range block.QueryTxs {
 		if q.Request.Header.QueryType == types.ReadQuery {
 			continue
 		}
-		var query = &QueryTracker{Req: q.Request, Resp: &types.Response{Header: *q.Response}}
-		lastsp = s.getSeq()
+		lastsp = seq
 		if q.Response.ResponseHeader.LogOffset > lastsp {
 			err = ErrMissingParent
 			return
@@ -523,27 +715,32 @@ func (s *State) ReplayBlockWithContext(ctx context.Context, block *types.Block)
 			// TODO(), recover logic after sqlchain forks by multiple write point
 			continue
 		}
-		// Replay query
-		for j, v := range q.Request.Payload.Queries {
-			if q.Request.Header.QueryType != types.WriteQuery {
-				err = errors.Wrapf(ErrInvalidRequest, "replay block at %d:%d", i, j)
-				return
-			}
-			if _, ierr = s.writeSingle(ctx, &v); ierr != nil {
-				err = errors.Wrapf(ierr, "execute at %d:%d failed", i, j)
-				return
-			}
+		if q.Request.Header.QueryType != types.WriteQuery && len(q.Request.Payload.Queries) > 0 {
+			err = errors.Wrapf(ErrInvalidRequest, "replay block at %d:%d", i, 0)
+			return
 		}
-		s.pool.enqueue(lastsp, query)
+		txs = append(txs, newReplayTx(i, lastsp, q))
+		seq += uint64(len(q.Request.Payload.Queries))
 	}
+	// Replay queries, the requests are prepared in parallel and applied in order
+	if err = s.applyReplay(txs); err != nil {
+		return
+	}
+	s.withPool(func(p *pool) {
+		for _, t := range txs {
+			p.enqueue(t.offset, t.tracker)
+		}
+	})
 	// Always try to commit after a block is successfully replayed
 	s.flushHandler()
-	// Remove duplicate failed queries from local pool
-	for _, r := range block.FailedReqs {
-		s.pool.removeFailed(r)
-	}
-	// Truncate pooled queries
-	s.pool.truncate(lastsp)
+	s.withPool(func(p *pool) {
+		// Remove duplicate failed queries from local pool
+		for _, r := range block.FailedReqs {
+			p.removeFailed(r)
+		}
+		// Truncate pooled queries
+		p.truncate(lastsp)
+	})
 	return
 }
 
@@ -577,8 +774,9 @@ func (s *State) commit() (err error) {
 	lockAcquired = time.Since(start)
 	s.flushHandler()
 	committed = time.Since(start)
-	_ = s.pool.queries
+	s.poolMu.Lock()
 	s.pool = newPool()
+	s.poolMu.Unlock()
 	poolCleaned = time.Since(start)
 	return
 }
@@ -592,6 +790,15 @@ func (s *State) CommitEx() (failed []*types.Request, queries []*QueryTracker, er
 // with context.
 func (s *State) CommitExWithContext(
 	ctx context.Context) (failed []*types.Request, queries []*QueryTracker, err error,
+) {
+	return s.CommitExWithLimit(ctx, 0)
+}
+
+// CommitExWithLimit commits the current transaction and returns at most limit pooled queries
+// with all the failed requests, the rest queries are kept in the pool in order for the following
+// blocks. A non-positive limit returns all the pooled queries.
+func (s *State) CommitExWithLimit(
+	ctx context.Context, limit int) (failed []*types.Request, queries []*QueryTracker, err error,
 ) {
 	var (
 		start = time.Now()
@@ -623,13 +830,20 @@ func (s *State) CommitExWithContext(
 	// Always try to commit before the block is produced
 	s.flushHandler()
 	committed = time.Since(start)
-	// Return pooled items and reset
-	failed = s.pool.failedList()
-	queries = s.pool.queries
-	for _, v := range s.pool.reads {
-		queries = append(queries, v)
+	// Return pooled items and reset, the reads are never blocked by the commit above
+	s.poolMu.Lock()
+	p := s.pool
+	if limit > 0 {
+		queries, s.pool = p.split(limit)
+	} else {
+		s.pool = newPool()
+		queries = p.queries
+		for _, v := range p.reads {
+			queries = append(queries, v)
+		}
 	}
-	s.pool = newPool()
+	s.poolMu.Unlock()
+	failed = p.failedList()
 	poolCleaned = time.Since(start)
 	return
 }
@@ -648,6 +862,7 @@ func (s *State) commitHandler() {
 	// reset schema change flag
 	atomic.StoreUint32(&s.hasSchemaChange, 0)
 	atomic.StoreUint64(&s.lastCommitPoint, s.getSeq())
+	s.releaseGroup(nil)
 }
 
 func (s *State) rollbackHandler() {
@@ -658,12 +873,47 @@ func (s *State) rollbackHandler() {
 	}
 	// reset schema change flag
 	atomic.StoreUint32(&s.hasSchemaChange, 0)
+	s.releaseGroup(ErrGroupCommitAborted)
+}
+
+// discardHandler drops the ongoing transaction which is already rolled back by an interrupted
+// statement, and opens a new one.
+func (s *State) discardHandler() {
+	if tx, ok := s.handler.(sqlTransaction); ok {
+		_ = tx.Rollback()
+		s.openHandler()
+	}
+	// reset schema change flag
+	atomic.StoreUint32(&s.hasSchemaChange, 0)
+	atomic.StoreUint64(&s.lastCommitPoint, s.getSeq())
+	s.releaseGroup(ErrGroupCommitAborted)
 }
 
 func (s *State) getLocalTime() time.Time {
+	if s.clock != nil {
+		return s.clock().UTC()
+	}
 	return time.Now().UTC()
 }
 
+// SetClock sets the clock of the leader timestamps of the state, which are also the timestamps of
+// the ids generated by the write queries, nil means the local time.
+func (s *State) SetClock(now func() time.Time) {
+	s.clock = now
+}
+
+// SetSnapshotReads sets whether the read queries of the read uncommitted isolation level run on
+// the last committed snapshot instead of the ongoing transaction. The snapshot reads never wait
+// for the writes or the block production, but do not see the writes until the ongoing
+// transaction is committed, which happens at least once per block.
+func (s *State) SetSnapshotReads(enabled bool) {
+	var v uint32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreUint32(&s.snapshotReads, v)
+}
+
 // Query does the query(ies) in req, pools the request and persists any change to
 // the underlying storage.
 func (s *State) Query(req *types.Request, isLeader bool) (ref *QueryTracker, resp *types.Response, err error) {
@@ -712,12 +962,51 @@ func (s *State) ReplayWithContext(
 	return
 }
 
+// Backup flushes any pending change and copies the committed data to dst while holding
+// the state lock, so that the backup reflects a consistent state at the returned sequence.
+// The snapshot function, if not nil, is called with the lock held before the data is copied, to
+// capture the other states consistent with the backup, such as the chain head.
+func (s *State) Backup(ctx context.Context, dst string, snapshot func()) (seq uint64, err error) {
+	b, ok := s.strg.(xi.Backuper)
+	if !ok {
+		err = ErrBackupNotSupported
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.flushHandler()
+	seq = s.getLastCommitPoint()
+	if snapshot != nil {
+		snapshot()
+	}
+	err = b.Backup(ctx, dst)
+	return
+}
+
+// Maintain commits the pending write transaction and runs the maintenance tasks in opts on the
+// underlying storage. Writes are blocked until it returns.
+func (s *State) Maintain(
+	ctx context.Context, opts xi.MaintenanceOptions) (result xi.MaintenanceResult, err error,
+) {
+	m, ok := s.strg.(xi.Maintainer)
+	if !ok {
+		err = ErrMaintenanceNotSupported
+		return
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.commitHandler()
+	defer s.openHandler()
+	result, err = m.Maintain(ctx, opts)
+	return
+}
+
 // Stat prints the statistic message of the State object.
 func (s *State) Stat(id proto.DatabaseID) {
 	var (
 		p = func() *pool {
-			s.RLock()
-			defer s.RUnlock()
+			s.poolMu.Lock()
+			defer s.poolMu.Unlock()
 			return s.pool
 		}()
 		fc = atomic.LoadInt32(&p.failedRequestCount)
@@ -9,7 +9,9 @@ import (
 	"path"
 	"reflect"
 	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
@@ -96,6 +98,22 @@ func TestState(t *testing.T) {
 			}), true)
 			// any schema change query will trigger performance degradation mode in current block
 			So(err, ShouldBeNil)
+			// the read queries in the write transaction should not write
+			atomic.StoreUint32(&st1.hasSchemaChange, 1)
+			_, _, _ = st1.Query(buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?) RETURNING k`, 1, "v1"),
+			}), true)
+			atomic.StoreUint32(&st1.hasSchemaChange, 0)
+			_, resp, err = st1.Query(buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`SELECT v FROM t1 WHERE k=?`, 1),
+			}), true)
+			So(err, ShouldBeNil)
+			So(resp.Header.RowCount, ShouldEqual, 0)
+			_, resp, err = st1.Query(buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, 2, "v2"),
+			}), true)
+			So(err, ShouldBeNil)
+			So(resp.Header.AffectedRows, ShouldEqual, 1)
 		})
 		Convey("When a basic KV table is created", func() {
 			var (
@@ -291,6 +309,75 @@ INSERT INTO t1 (k, v) VALUES (?, ?)`, concat(values[2:4])...),
 				})
 				st1.Stat(id1)
 			})
+			Convey("The state should interrupt the write query on deadline", func() {
+				_, resp, err = st1.Query(buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, values[0]...),
+				}), true)
+				So(err, ShouldBeNil)
+				ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+				defer cancel()
+				_, _, err = st1.QueryWithContext(ctx, buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, values[1]...),
+					buildQuery(`INSERT INTO t1 (k, v) WITH RECURSIVE c(x) AS (
+SELECT 10 UNION ALL SELECT x + 1 FROM c) SELECT x, 'v' FROM c`),
+				}), true)
+				So(err, ShouldNotBeNil)
+				So(ctx.Err(), ShouldResemble, context.DeadlineExceeded)
+				// the preceding write is kept and the interrupted one is rolled back
+				_, resp, err = st1.Query(buildRequest(types.ReadQuery, []types.Query{
+					buildQuery(`SELECT v FROM t1`),
+				}), true)
+				So(err, ShouldBeNil)
+				So(resp.Payload.Rows, ShouldResemble, []types.ResponseRow{{Values: values[0][1:]}})
+				_, resp, err = st1.Query(buildRequest(types.WriteQuery, []types.Query{
+					buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, values[1]...),
+				}), true)
+				So(err, ShouldBeNil)
+				So(st1.commit(), ShouldBeNil)
+			})
+			Convey("The state should limit the read query result or spill it to a cursor", func() {
+				for _, v := range values {
+					_, _, err = st1.Query(buildRequest(types.WriteQuery, []types.Query{
+						buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, v...),
+					}), true)
+					So(err, ShouldBeNil)
+				}
+				So(st1.commit(), ShouldBeNil)
+				st1.SetResultLimit(ResultLimit{MaxRows: 3})
+				defer st1.SetResultLimit(ResultLimit{})
+				_, _, err = st1.Query(buildRequest(types.ReadQuery, []types.Query{
+					buildQuery(`SELECT v FROM t1 ORDER BY k`),
+				}), true)
+				So(errors.Cause(err), ShouldEqual, ErrResultTooLarge)
+
+				st1.SetResultLimit(ResultLimit{MaxRows: 1})
+				req = buildRequest(types.ReadQuery, []types.Query{
+					buildQuery(`SELECT v FROM t1 ORDER BY k`),
+				})
+				So(req.Header.SetResultCursor(true), ShouldBeNil)
+				var tracker *QueryTracker
+				tracker, resp, err = st1.Query(req, true)
+				So(err, ShouldBeNil)
+				So(resp.Payload.Rows, ShouldResemble, []types.ResponseRow{{Values: values[0][1:]}})
+				So(tracker.Cursor, ShouldNotBeNil)
+				names, _ := tracker.Cursor.Columns()
+				So(names, ShouldResemble, []string{"v"})
+				for i := 1; i < len(values); i++ {
+					data, last, err := tracker.Cursor.Next(context.Background())
+					So(err, ShouldBeNil)
+					So(data, ShouldResemble, [][]interface{}{values[i][1:]})
+					So(last, ShouldEqual, i == len(values)-1)
+				}
+				_, _, err = tracker.Cursor.Next(context.Background())
+				So(err, ShouldEqual, ErrCursorClosed)
+
+				// the result within the limit is not spilled
+				st1.SetResultLimit(ResultLimit{})
+				tracker, resp, err = st1.Query(req, true)
+				So(err, ShouldBeNil)
+				So(tracker.Cursor, ShouldBeNil)
+				So(len(resp.Payload.Rows), ShouldEqual, len(values))
+			})
 			Convey("The state should skip read query while replaying", func() {
 				err = st1.Replay(buildRequest(types.ReadQuery, []types.Query{
 					buildQuery(`SELECT * FROM t1`),
@@ -509,6 +596,50 @@ INSERT INTO t1 (k, v) VALUES (?, ?)`, concat(values[2:4])...),
 					},
 				)
 			})
+			Convey("When queries are committed to blocks with a limit on state instance #1", func() {
+				var blocks []*types.Block
+				for i := 0; i < 3; i++ {
+					qt, resp, err := st1.Query(buildRequest(types.WriteQuery, []types.Query{
+						buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, values[i]...),
+					}), true)
+					So(err, ShouldBeNil)
+					qt.UpdateResp(resp)
+				}
+				for {
+					_, qts, err := st1.CommitExWithLimit(context.Background(), 2)
+					So(err, ShouldBeNil)
+					So(len(qts), ShouldBeLessThanOrEqualTo, 2)
+					if len(qts) == 0 {
+						break
+					}
+					var block = &types.Block{QueryTxs: make([]*types.QueryAsTx, len(qts))}
+					for i, v := range qts {
+						block.QueryTxs[i] = &types.QueryAsTx{
+							Request:  v.Req,
+							Response: &v.Resp.Header,
+						}
+					}
+					blocks = append(blocks, block)
+				}
+				So(blocks, ShouldHaveLength, 2)
+				So(blocks[1].QueryTxs[0].Response.LogOffset, ShouldBeGreaterThan,
+					blocks[0].QueryTxs[1].Response.LogOffset)
+				Convey("The state should be reproducible with the blocks in order", func() {
+					for i := range blocks {
+						So(st2.ReplayBlock(blocks[i]), ShouldBeNil)
+					}
+					for i := 0; i < 3; i++ {
+						req := buildRequest(types.ReadQuery, []types.Query{
+							buildQuery(`SELECT v FROM t1 WHERE k=?`, values[i][0]),
+						})
+						_, resp1, err := st1.Query(req, true)
+						So(err, ShouldBeNil)
+						_, resp2, err := st2.Query(req, true)
+						So(err, ShouldBeNil)
+						So(resp2.Payload, ShouldResemble, resp1.Payload)
+					}
+				})
+			})
 		})
 	})
 }
@@ -923,3 +1054,104 @@ func TestSerializableState(t *testing.T) {
 		})
 	})
 }
+
+func TestSnapshotReads(t *testing.T) {
+	Convey("Given a read uncommitted state with snapshot reads", t, func() {
+		var fl = path.Join(testingDataDir, t.Name())
+		strg, err := xs.NewSqlite(fmt.Sprint("file:", fl))
+		So(err, ShouldBeNil)
+		var st = NewState(sql.LevelReadUncommitted, nodeID, strg)
+		st.SetSnapshotReads(true)
+		Reset(func() {
+			So(st.Close(true), ShouldBeNil)
+			for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+				err := os.Remove(f)
+				So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+			}
+		})
+
+		_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+			buildQuery(`CREATE TABLE t1 (k INT, v TEXT, PRIMARY KEY(k))`),
+		}), true)
+		So(err, ShouldBeNil)
+		_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+			buildQuery(`INSERT INTO t1 (k, v) VALUES (1, 'a')`),
+		}), true)
+		So(err, ShouldBeNil)
+
+		var count = buildRequest(types.ReadQuery, []types.Query{buildQuery(`SELECT COUNT(1) FROM t1`)})
+		Convey("The reads should see the last committed snapshot only", func() {
+			_, resp, err := st.Query(count, true)
+			So(err, ShouldBeNil)
+			So(resp.Payload.Rows[0].Values[0], ShouldEqual, 0)
+			So(resp.Header.LogOffset, ShouldEqual, 1)
+
+			st.SetSnapshotReads(false)
+			_, resp, err = st.Query(count, true)
+			So(err, ShouldBeNil)
+			So(resp.Payload.Rows[0].Values[0], ShouldEqual, 1)
+			So(resp.Header.LogOffset, ShouldEqual, 2)
+			st.SetSnapshotReads(true)
+
+			_, _, err = st.CommitEx()
+			So(err, ShouldBeNil)
+			_, resp, err = st.Query(count, true)
+			So(err, ShouldBeNil)
+			So(resp.Payload.Rows[0].Values[0], ShouldEqual, 1)
+			So(resp.Header.LogOffset, ShouldEqual, 2)
+		})
+		Convey("The reads should not wait for the state lock", func() {
+			st.Lock()
+			defer st.Unlock()
+			var done = make(chan error, 1)
+			go func() {
+				_, _, err := st.Query(count, true)
+				done <- err
+			}()
+			select {
+			case err = <-done:
+				So(err, ShouldBeNil)
+			case <-time.After(10 * time.Second):
+				So("read blocked by the state lock", ShouldBeEmpty)
+			}
+		})
+	})
+}
+
+func TestCanonicalResults(t *testing.T) {
+	Convey("Given two states opened in different locations", t, func() {
+		var payloads []types.ResponsePayload
+		for _, loc := range []string{"UTC", "Asia/Shanghai"} {
+			var fl = path.Join(testingDataDir, t.Name()+loc[:3])
+			strg, err := xs.NewSqlite(fmt.Sprintf("file:%s?_loc=%s", fl, loc))
+			So(err, ShouldBeNil)
+			var st = NewState(sql.LevelReadUncommitted, nodeID, strg)
+			Reset(func() {
+				So(st.Close(true), ShouldBeNil)
+				for _, f := range []string{fl, fl + "-shm", fl + "-wal"} {
+					err := os.Remove(f)
+					So(err == nil || os.IsNotExist(err), ShouldBeTrue)
+				}
+			})
+			_, _, err = st.Query(buildRequest(types.WriteQuery, []types.Query{
+				buildQuery(`CREATE TABLE t1 (k INT, t DATETIME, f REAL, PRIMARY KEY(k))`),
+				buildQuery(`INSERT INTO t1 VALUES (1, '2019-01-01 08:00:00.5+08:00', 0.1)`),
+			}), true)
+			So(err, ShouldBeNil)
+			_, resp, err := st.Query(buildRequest(types.ReadQuery, []types.Query{
+				buildQuery(`SELECT t, f, f * 3 FROM t1`),
+			}), true)
+			So(err, ShouldBeNil)
+			payloads = append(payloads, resp.Payload)
+		}
+		Convey("The results should be identical and hashed identically", func() {
+			So(payloads[1], ShouldResemble, payloads[0])
+			So(payloads[0].Rows[0].Values[0].(time.Time).Location(), ShouldEqual, time.UTC)
+			h0, err := payloads[0].MarshalHash()
+			So(err, ShouldBeNil)
+			h1, err := payloads[1].MarshalHash()
+			So(err, ShouldBeNil)
+			So(h1, ShouldResemble, h0)
+		})
+	})
+}
@@ -0,0 +1,119 @@
+package dpos
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// TTLTableName is the table of the row ttl policies declared by the tables, which is kept in the
+	// state with the tables and replicated by the same write queries. The policies may be listed
+	// or removed by querying the table.
+	TTLTableName = "__sqlit_ttl"
+
+	// MinTableTTL defines the min row ttl of a table.
+	MinTableTTL = time.Second
+)
+
+var (
+	// ttlTableRe matches a create table statement with the trailing ttl options, such as
+	// CREATE TABLE logs (...) WITH (ttl='30d', ttl_column='created_at').
+	ttlTableRe = regexp.MustCompile(`(?is)^(create\s+table\s+(?:if\s+not\s+exists\s+)?` +
+		"(\"(?:[^\"]|\"\")+\"|`[^`]+`|\\[[^\\]]+\\]|[^\\s(]+)" +
+		`\s*\(.*\)(?:\s*,?\s*(?:without\s+rowid|strict))*)\s*with\s*\(([^()]*)\)\s*$`)
+
+	createTTLTable = `CREATE TABLE IF NOT EXISTS "` + TTLTableName + `" (` +
+		`"table" TEXT PRIMARY KEY, "column" TEXT NOT NULL, "ttl" INTEGER NOT NULL)`
+)
+
+// TTLPolicy defines the row ttl of a table, the rows with the ttl column older than the ttl are
+// deleted by the leader in background.
+type TTLPolicy struct {
+	Table  string
+	Column string
+	TTL    time.Duration
+}
+
+// ParseTTL parses the row ttl of a table, which is a positive integer with one of the units s, m,
+// h, d and w, or a duration like "36h30m".
+func ParseTTL(s string) (ttl time.Duration, err error) {
+	var (
+		v    = strings.ToLower(strings.TrimSpace(s))
+		unit time.Duration
+	)
+	switch {
+	case strings.HasSuffix(v, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(v, "w"):
+		unit = 7 * 24 * time.Hour
+	}
+	if unit > 0 {
+		var n int64
+		if n, err = strconv.ParseInt(v[:len(v)-1], 10, 32); err == nil {
+			ttl = time.Duration(n) * unit
+		}
+	} else {
+		ttl, err = time.ParseDuration(v)
+	}
+	if err != nil {
+		return 0, errors.Wrapf(ErrInvalidTTL, "%s: %v", s, err)
+	}
+	if ttl < MinTableTTL || ttl%time.Second != 0 {
+		return 0, errors.Wrapf(ErrInvalidTTL, "%s: must be whole seconds at least %s", s, MinTableTTL)
+	}
+	return
+}
+
+// sanitizeTTLTable strips the ttl options of a create table statement, and declares the policy in
+// the ttl table by the statements following the ddl. The other statements are returned as is.
+func sanitizeTTLTable(query string) (queries []string, err error) {
+	m := ttlTableRe.FindStringSubmatch(query)
+	if m == nil {
+		return []string{query}, nil
+	}
+	var p = &TTLPolicy{Table: unquoteModuleArg(m[2])}
+	if strings.HasPrefix(strings.ToLower(p.Table), "__sqlit") {
+		return nil, errors.Wrapf(ErrInvalidTableName, "%s", p.Table)
+	}
+	for _, arg := range splitModuleArgs(m[3]) {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Wrapf(ErrInvalidTTL, "invalid table option: %s", arg)
+		}
+		var value = unquoteModuleArg(strings.TrimSpace(kv[1]))
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "ttl":
+			if p.TTL, err = ParseTTL(value); err != nil {
+				return
+			}
+		case "ttl_column":
+			p.Column = value
+		default:
+			return nil, errors.Wrapf(ErrInvalidTTL, "unknown table option: %s", kv[0])
+		}
+	}
+	if p.TTL == 0 || p.Column == "" {
+		return nil, errors.Wrap(ErrInvalidTTL, "both ttl and ttl_column are required")
+	}
+	return []string{
+		strings.TrimSpace(m[1]),
+		createTTLTable,
+		`INSERT OR REPLACE INTO "` + TTLTableName + `" ("table", "column", "ttl") VALUES (` +
+			QuoteString(p.Table) + `, ` + QuoteString(p.Column) + `, ` +
+			strconv.FormatInt(int64(p.TTL/time.Second), 10) + `)`,
+	}, nil
+}
+
+// QuoteIdent quotes s as an sql identifier.
+func QuoteIdent(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+// QuoteString quotes s as an sql string literal.
+func QuoteString(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `''`) + `'`
+}
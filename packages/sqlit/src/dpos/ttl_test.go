@@ -0,0 +1,101 @@
+package dpos
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestParseTTL(t *testing.T) {
+	Convey("The row ttl should be parsed with the units", t, func() {
+		for s, d := range map[string]time.Duration{
+			"30d":    30 * 24 * time.Hour,
+			"2w":     14 * 24 * time.Hour,
+			"12h":    12 * time.Hour,
+			"90s":    90 * time.Second,
+			" 1H30m": 90 * time.Minute,
+		} {
+			ttl, err := ParseTTL(s)
+			So(err, ShouldBeNil)
+			So(ttl, ShouldEqual, d)
+		}
+		for _, s := range []string{"", "0d", "-1d", "500ms", "1.5d", "forever"} {
+			_, err := ParseTTL(s)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidTTL)
+		}
+	})
+}
+
+func TestSanitizeTTLTable(t *testing.T) {
+	Convey("The ttl options should be stripped and declared as a policy", t, func() {
+		q, err := sanitizeTTLTable(`CREATE TABLE logs (id INTEGER PRIMARY KEY, created_at INT) ` +
+			`WITH (ttl='30d', ttl_column='created_at')`)
+		So(err, ShouldBeNil)
+		So(q, ShouldResemble, []string{
+			`CREATE TABLE logs (id INTEGER PRIMARY KEY, created_at INT)`,
+			createTTLTable,
+			`INSERT OR REPLACE INTO "__sqlit_ttl" ("table", "column", "ttl") ` +
+				`VALUES ('logs', 'created_at', 2592000)`,
+		})
+
+		q, err = sanitizeTTLTable(`create table if not exists "event log" (k TEXT PRIMARY KEY, ` +
+			`"at" TEXT) without rowid with ( TTL = 1h , ttl_column = "at" )`)
+		So(err, ShouldBeNil)
+		So(q, ShouldHaveLength, 3)
+		So(q[0], ShouldEqual,
+			`create table if not exists "event log" (k TEXT PRIMARY KEY, "at" TEXT) without rowid`)
+		So(q[2], ShouldEndWith, `VALUES ('event log', 'at', 3600)`)
+	})
+	Convey("The tables without the ttl options should be kept as is", t, func() {
+		for _, s := range []string{
+			`CREATE TABLE logs (id INT, created_at INT)`,
+			`CREATE TABLE logs (id INT) WITHOUT ROWID`,
+			`CREATE TABLE logs AS SELECT * FROM events`,
+		} {
+			q, err := sanitizeTTLTable(s)
+			So(err, ShouldBeNil)
+			So(q, ShouldResemble, []string{s})
+		}
+	})
+	Convey("The invalid ttl options should be refused", t, func() {
+		for _, s := range []string{
+			`CREATE TABLE logs (id INT, at INT) WITH (ttl='30d')`,
+			`CREATE TABLE logs (id INT, at INT) WITH (ttl_column='at')`,
+			`CREATE TABLE logs (id INT, at INT) WITH (ttl='1ms', ttl_column='at')`,
+			`CREATE TABLE logs (id INT, at INT) WITH (ttl='1d', ttl_column='at', mode='x')`,
+			`CREATE TABLE logs (id INT, at INT) WITH (ttl)`,
+		} {
+			_, err := sanitizeTTLTable(s)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidTTL)
+		}
+		_, err := sanitizeTTLTable(`CREATE TABLE __sqlit_x (at INT) WITH (ttl='1d', ttl_column='at')`)
+		So(errors.Cause(err), ShouldEqual, ErrInvalidTableName)
+	})
+	Convey("The converted ddl should create the table and the policy", t, func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+		for _, s := range []string{
+			`CREATE TABLE a (at INT) WITH (ttl='1d', ttl_column='at')`,
+			`CREATE TABLE b (at TEXT) WITH (ttl='2d', ttl_column='at')`,
+			// the policy is replaced by the table declared again
+			`CREATE TABLE IF NOT EXISTS a (at INT) WITH (ttl='3d', ttl_column='at')`,
+		} {
+			containsDDL, p, _, err := convertQueryAndBuildArgs(s, nil)
+			So(err, ShouldBeNil)
+			So(containsDDL, ShouldBeTrue)
+			_, err = db.Exec(p)
+			So(err, ShouldBeNil)
+		}
+		var ttl int64
+		So(db.QueryRow(`SELECT "ttl" FROM "__sqlit_ttl" WHERE "table" = 'a'`).Scan(&ttl), ShouldBeNil)
+		So(ttl, ShouldEqual, 3*24*3600)
+		var count int
+		So(db.QueryRow(`SELECT COUNT(1) FROM "__sqlit_ttl"`).Scan(&count), ShouldBeNil)
+		So(count, ShouldEqual, 2)
+	})
+}
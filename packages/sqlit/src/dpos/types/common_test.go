@@ -31,7 +31,7 @@ type DummyObject struct {
 	DefaultHashSignVerifierImpl
 }
 
-func (o *DummyObject) Sign(signer *asymmetric.PrivateKey) error {
+func (o *DummyObject) Sign(signer asymmetric.Signer) error {
 	return o.DefaultHashSignVerifierImpl.Sign(&o.DummyHeader, signer)
 }
 
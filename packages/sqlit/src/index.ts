@@ -53,6 +53,24 @@ export { SQLitClient, type SQLitClientConfig } from './client'
 // Node
 export { SQLitNode } from './node'
 
+// Proxy client
+export {
+  type ExecResult,
+  type FieldOperators,
+  type FindOptions,
+  type GraphQLResult,
+  type PaginateOptions,
+  type ProxyFilter,
+  type ProxyRow,
+  type ProxyUpdate,
+  type ProxyUserInfo,
+  type ProxyValue,
+  type RetryConfig,
+  SQLitProxyClient,
+  type SQLitProxyClientConfig,
+  type WriteOp,
+} from './proxy'
+
 // Server
 export { createSQLitServer, type SQLitServerConfig } from './server'
 
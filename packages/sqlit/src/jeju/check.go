@@ -0,0 +1,114 @@
+/*
+ * Copyright 2024-2025 Jeju Network.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jeju
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+
+	"sqlit/src/utils/log"
+)
+
+// DefaultCheckTimeout is the default timeout of the startup checks of the registry.
+const DefaultCheckTimeout = 10 * time.Second
+
+var (
+	// ErrUnknownNetwork indicates that the network has no known chain ID.
+	ErrUnknownNetwork = errors.New("unknown jeju network")
+	// ErrChainIDMismatch indicates that the RPC endpoint serves another chain than the network.
+	ErrChainIDMismatch = errors.New("rpc chain id mismatch")
+	// ErrNoRegistryAddress indicates that the registry address is not configured.
+	ErrNoRegistryAddress = errors.New("registry address is not configured")
+	// ErrNoRegistryCode indicates that no contract is deployed at the registry address.
+	ErrNoRegistryCode = errors.New("no contract code at registry address")
+	// ErrRegistryInterface indicates that the contract does not implement the registry interface.
+	ErrRegistryInterface = errors.New("contract does not implement SqlitRegistry")
+)
+
+// DialRegistry creates a registry client of the config and checks it, so that a misconfigured
+// node fails at startup instead of on its first heartbeat.
+func DialRegistry(ctx context.Context, cfg *JejuConfig) (*RegistryClient, error) {
+	if !common.IsHexAddress(cfg.RegistryAddress) ||
+		common.HexToAddress(cfg.RegistryAddress) == (common.Address{}) {
+		return nil, fmt.Errorf("%w: set registryAddress or SQLIT_REGISTRY_ADDRESS for %s, got %q",
+			ErrNoRegistryAddress, cfg.Network, cfg.RegistryAddress)
+	}
+	client, err := NewRegistryClient(cfg.L2RPCEndpoint, cfg.RegistryAddress)
+	if err != nil {
+		return nil, err
+	}
+	if err = client.Check(ctx, cfg.Network); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// Check verifies that the RPC endpoint serves the chain of the network, and that the registry
+// address holds a contract answering the SqlitRegistry calls.
+func (r *RegistryClient) Check(ctx context.Context, network Network) error {
+	endpoints, ok := DefaultEndpoints[network]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownNetwork, network)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultCheckTimeout)
+		defer cancel()
+	}
+
+	var result hexutil.Big
+	if err := r.rpc.CallContext(ctx, &result, "eth_chainId"); err != nil {
+		return fmt.Errorf("failed to get chain id of ETH RPC: %w", err)
+	}
+	chainID := result.ToInt()
+	if !chainID.IsUint64() || chainID.Uint64() != endpoints.ChainID {
+		return fmt.Errorf("%w: %s expects chain id %d, but the rpc serves chain id %s",
+			ErrChainIDMismatch, network, endpoints.ChainID, chainID)
+	}
+
+	code, err := r.client.CodeAt(ctx, r.registryAddress, nil)
+	if err != nil {
+		return fmt.Errorf("failed to get registry code: %w", err)
+	}
+	if len(code) == 0 {
+		return fmt.Errorf("%w: %s on chain id %s",
+			ErrNoRegistryCode, r.registryAddress.Hex(), chainID)
+	}
+
+	// SqlitRegistry has no ERC-165 support, so its view methods are probed instead
+	opts := &bind.CallOpts{Context: ctx}
+	if _, err = r.registry.GetActiveBlockProducers(opts); err == nil {
+		_, err = r.registry.GetActiveMiners(opts)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrRegistryInterface, r.registryAddress.Hex(), err)
+	}
+
+	log.WithFields(log.Fields{
+		"network":  network,
+		"chainID":  chainID,
+		"registry": r.registryAddress.Hex(),
+	}).Info("checked jeju registry")
+	return nil
+}
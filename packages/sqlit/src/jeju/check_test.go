@@ -0,0 +1,62 @@
+package jeju
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDialRegistry(t *testing.T) {
+	Convey("Given a registry served by a fake json rpc server", t, func() {
+		var (
+			registry = newFakeRegistry(nil)
+			url      = serveFakeRegistry(registry)
+			cfg      = &JejuConfig{
+				Network:         Localnet,
+				L2RPCEndpoint:   url,
+				RegistryAddress: "0x00000000000000000000000000000000000000aa",
+			}
+			ctx = context.Background()
+		)
+
+		Convey("The valid registry should be checked", func() {
+			client, err := DialRegistry(ctx, cfg)
+			So(err, ShouldBeNil)
+			So(client, ShouldNotBeNil)
+			client.Close()
+		})
+		Convey("The misconfigured registry should be refused", func() {
+			for _, c := range []struct {
+				name  string
+				setup func()
+				err   error
+			}{
+				{"empty address", func() { cfg.RegistryAddress = "" }, ErrNoRegistryAddress},
+				{"invalid address", func() { cfg.RegistryAddress = "0x1234" }, ErrNoRegistryAddress},
+				{"zero address", func() {
+					cfg.RegistryAddress = "0x0000000000000000000000000000000000000000"
+				}, ErrNoRegistryAddress},
+				{"unknown network", func() { cfg.Network = "devnet" }, ErrUnknownNetwork},
+				{"wrong chain id", func() { cfg.Network = Mainnet }, ErrChainIDMismatch},
+				{"rpc serving another chain", func() { registry.chainID = 1 }, ErrChainIDMismatch},
+				{"missing contract code", func() { registry.code = nil }, ErrNoRegistryCode},
+				{"bad probe output", func() { registry.badProbe = true }, ErrRegistryInterface},
+			} {
+				Convey("The registry with "+c.name+" should be refused", func() {
+					c.setup()
+					client, err := DialRegistry(ctx, cfg)
+					So(client, ShouldBeNil)
+					So(errors.Is(err, c.err), ShouldBeTrue)
+				})
+			}
+		})
+		Convey("The unreachable rpc endpoint should be refused", func() {
+			cfg.L2RPCEndpoint = "http://127.0.0.1:1"
+			client, err := DialRegistry(ctx, cfg)
+			So(client, ShouldBeNil)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
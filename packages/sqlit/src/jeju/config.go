@@ -77,6 +77,7 @@ type NetworkEndpoints struct {
 	MinerEndpoint         string `json:"minerEndpoint"`
 	RegistryAddress       string `json:"registryAddress"`
 	L2RPCEndpoint         string `json:"l2RpcEndpoint"`
+	ChainID               uint64 `json:"chainId"`
 }
 
 // DefaultEndpoints returns the default endpoints for each network.
@@ -86,18 +87,21 @@ var DefaultEndpoints = map[Network]NetworkEndpoints{
 		MinerEndpoint:         "http://localhost:4661",
 		RegistryAddress:       "0x0000000000000000000000000000000000000000",
 		L2RPCEndpoint:         "http://localhost:9545",
+		ChainID:               31337,
 	},
 	Testnet: {
 		BlockProducerEndpoint: "https://sqlit-bp.testnet.jejunetwork.org",
 		MinerEndpoint:         "https://sqlit-miner.testnet.jejunetwork.org",
 		RegistryAddress:       "", // To be deployed
 		L2RPCEndpoint:         "https://rpc.testnet.jejunetwork.org",
+		ChainID:               420690,
 	},
 	Mainnet: {
 		BlockProducerEndpoint: "https://sqlit-bp.jejunetwork.org",
 		MinerEndpoint:         "https://sqlit-miner.jejunetwork.org",
 		RegistryAddress:       "", // To be deployed
 		L2RPCEndpoint:         "https://rpc.jejunetwork.org",
+		ChainID:               420691,
 	},
 }
 
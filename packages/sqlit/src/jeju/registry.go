@@ -24,6 +24,7 @@ import (
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 
 	"sqlit/src/proto"
 	"sqlit/src/utils/log"
@@ -81,6 +82,7 @@ type DatabaseInfo struct {
 
 // RegistryClient provides interface to the SqlitRegistry contract.
 type RegistryClient struct {
+	rpc             *rpc.Client
 	client          *ethclient.Client
 	registryAddress common.Address
 	registry        *SqlitRegistry
@@ -88,10 +90,11 @@ type RegistryClient struct {
 
 // NewRegistryClient creates a new registry client.
 func NewRegistryClient(rpcEndpoint string, registryAddress string) (*RegistryClient, error) {
-	client, err := ethclient.Dial(rpcEndpoint)
+	rpcClient, err := rpc.Dial(rpcEndpoint)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to ETH RPC: %w", err)
 	}
+	client := ethclient.NewClient(rpcClient)
 
 	addr := common.HexToAddress(registryAddress)
 	registry, err := NewSqlitRegistry(addr, client)
@@ -101,6 +104,7 @@ func NewRegistryClient(rpcEndpoint string, registryAddress string) (*RegistryCli
 	}
 
 	return &RegistryClient{
+		rpc:             rpcClient,
 		client:          client,
 		registryAddress: addr,
 		registry:        registry,
@@ -0,0 +1,144 @@
+/*
+ * Copyright 2024-2025 Jeju Network.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jeju
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/naconn"
+	"sqlit/src/proto"
+)
+
+// DefaultResolveTimeout is the default timeout of a registry lookup.
+const DefaultResolveTimeout = 5 * time.Second
+
+// RegistryResolver resolves node IDs with the endpoints registered in SqlitRegistry.
+type RegistryResolver struct {
+	client  *RegistryClient
+	timeout time.Duration
+}
+
+// NewRegistryResolver creates a new node ID resolver backed by the registry client.
+func NewRegistryResolver(client *RegistryClient) *RegistryResolver {
+	return &RegistryResolver{
+		client:  client,
+		timeout: DefaultResolveTimeout,
+	}
+}
+
+// RegisterRegistryResolver registers the registry resolver so that it can be used in the
+// node resolver chain config.
+func RegisterRegistryResolver(client *RegistryClient) {
+	naconn.RegisterNamedResolver(naconn.JejuRegistryResolverName, NewRegistryResolver(client))
+}
+
+// InitRegistryResolver checks the registry in config with DialRegistry and registers its resolver,
+// so that it can be used in the node resolver chain config. It must be called before
+// naconn.InitResolvers, and does nothing if the registry is not configured.
+func InitRegistryResolver(cfg *conf.Config) (client *RegistryClient, err error) {
+	if cfg == nil || cfg.Registry == nil || cfg.Registry.Address == "" {
+		return
+	}
+	var network = Network(cfg.Registry.Network)
+	if network == "" {
+		if network = Network(os.Getenv(conf.NetworkEnv)); network == "" {
+			network = Localnet
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCheckTimeout)
+	defer cancel()
+	if client, err = DialRegistry(ctx, &JejuConfig{
+		Network:         network,
+		L2RPCEndpoint:   cfg.Registry.RPCEndpoint,
+		RegistryAddress: cfg.Registry.Address,
+	}); err != nil {
+		return
+	}
+	RegisterRegistryResolver(client)
+	return
+}
+
+// Resolve implements naconn.Resolver.Resolve.
+func (r *RegistryResolver) Resolve(id *proto.RawNodeID) (addr string, err error) {
+	var node *SqlitNode
+	if node, err = r.getActiveNode(id); err != nil {
+		return
+	}
+	return endpointToAddr(node.Endpoint)
+}
+
+// ResolveEx implements naconn.Resolver.ResolveEx. The registry does not store public keys, so
+// the key must be known locally.
+func (r *RegistryResolver) ResolveEx(id *proto.RawNodeID) (node *proto.Node, err error) {
+	var (
+		nodeID = proto.NodeID(id.String())
+		sn     *SqlitNode
+		addr   string
+	)
+	if sn, err = r.getActiveNode(id); err != nil {
+		return
+	}
+	if addr, err = endpointToAddr(sn.Endpoint); err != nil {
+		return
+	}
+	node = &proto.Node{
+		ID:   nodeID,
+		Addr: addr,
+		Role: proto.Miner,
+	}
+	if sn.Role == RoleBlockProducer {
+		node.Role = proto.Follower
+	}
+	if node.PublicKey, err = kms.GetPublicKey(nodeID); err != nil {
+		err = fmt.Errorf("public key of node %s is unknown: %w", nodeID, err)
+		node = nil
+	}
+	return
+}
+
+func (r *RegistryResolver) getActiveNode(id *proto.RawNodeID) (node *SqlitNode, err error) {
+	if id == nil {
+		return nil, fmt.Errorf("nil node id")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), r.timeout)
+	defer cancel()
+	if node, err = r.client.GetNode(ctx, NodeIDToBytes32(proto.NodeID(id.String()))); err != nil {
+		return
+	}
+	if node.Status != StatusActive {
+		err = fmt.Errorf("node %s is not active, status: %d", id.String(), node.Status)
+		node = nil
+	}
+	return
+}
+
+// endpointToAddr converts a registered endpoint, either "host:port" or an URL, to "host:port".
+func endpointToAddr(endpoint string) (addr string, err error) {
+	if endpoint == "" {
+		return "", fmt.Errorf("empty endpoint")
+	}
+	if u, perr := url.Parse(endpoint); perr == nil && u.Host != "" {
+		return u.Host, nil
+	}
+	return endpoint, nil
+}
@@ -0,0 +1,197 @@
+package jeju
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/naconn"
+	"sqlit/src/proto"
+)
+
+// registryNode is the getNode result of SqlitRegistry.
+type registryNode struct {
+	Operator       common.Address
+	NodeId         [32]byte
+	Role           uint8
+	Status         uint8
+	StakedAmount   *big.Int
+	RegisteredAt   *big.Int
+	LastHeartbeat  *big.Int
+	Endpoint       string
+	TeeAttestation []byte
+	MrEnclave      [32]byte
+	DatabaseCount  *big.Int
+	TotalQueries   *big.Int
+	SlashedAmount  *big.Int
+}
+
+// FakeRegistry serves the chain id, the contract code and the calls of SqlitRegistry as the eth
+// rpc service, it is exported to be registered in the rpc server.
+type FakeRegistry struct {
+	abi     abi.ABI
+	chainID uint64
+	code    []byte
+	nodes   map[[32]byte]*registryNode
+	// badProbe returns the outputs not matching the registry interface
+	badProbe bool
+}
+
+func newFakeRegistry(nodes map[[32]byte]*registryNode) (r *FakeRegistry) {
+	r = &FakeRegistry{
+		chainID: DefaultEndpoints[Localnet].ChainID,
+		code:    []byte{0x60, 0x80},
+		nodes:   nodes,
+	}
+	var err error
+	r.abi, err = abi.JSON(strings.NewReader(SqlitRegistryABI))
+	So(err, ShouldBeNil)
+	return
+}
+
+func serveFakeRegistry(r *FakeRegistry) (url string) {
+	var srv = rpc.NewServer()
+	So(srv.RegisterName("eth", r), ShouldBeNil)
+	var hs = httptest.NewServer(srv)
+	Reset(hs.Close)
+	return hs.URL
+}
+
+func (r *FakeRegistry) ChainId() *hexutil.Big {
+	return (*hexutil.Big)(new(big.Int).SetUint64(r.chainID))
+}
+
+func (r *FakeRegistry) GetCode(_ common.Address, _ string) hexutil.Bytes {
+	return r.code
+}
+
+func (r *FakeRegistry) Call(args map[string]interface{}, _ string) (out hexutil.Bytes, err error) {
+	var data hexutil.Bytes
+	if raw, ok := args["data"].(string); !ok {
+		return nil, fmt.Errorf("missing call data")
+	} else if err = json.Unmarshal([]byte(`"`+raw+`"`), &data); err != nil {
+		return
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("unexpected call data %x", data)
+	}
+	for _, name := range []string{"getActiveBlockProducers", "getActiveMiners"} {
+		var method = r.abi.Methods[name]
+		if string(data[:4]) != string(method.Id()) {
+			continue
+		}
+		if r.badProbe {
+			return hexutil.Bytes{0x01}, nil
+		}
+		var ids [][32]byte
+		for id := range r.nodes {
+			ids = append(ids, id)
+		}
+		return method.Outputs.Pack(ids)
+	}
+	var method = r.abi.Methods["getNode"]
+	if len(data) != 36 || string(data[:4]) != string(method.Id()) {
+		return nil, fmt.Errorf("unexpected call data %x", data)
+	}
+	var id [32]byte
+	copy(id[:], data[4:])
+	node, ok := r.nodes[id]
+	if !ok {
+		node = &registryNode{}
+	}
+	return method.Outputs.Pack(node)
+}
+
+func TestRegistryResolver(t *testing.T) {
+	Convey("Given a registry with an active and a suspended node", t, func() {
+		var (
+			active    = proto.NodeID(strings.Repeat("a", 64))
+			suspended = proto.NodeID(strings.Repeat("b", 64))
+			newNode   = func(id proto.NodeID, status NodeStatus, endpoint string) *registryNode {
+				return &registryNode{
+					NodeId:        NodeIDToBytes32(id),
+					Role:          uint8(RoleMiner),
+					Status:        uint8(status),
+					StakedAmount:  big.NewInt(0),
+					RegisteredAt:  big.NewInt(0),
+					LastHeartbeat: big.NewInt(0),
+					Endpoint:      endpoint,
+					DatabaseCount: big.NewInt(0),
+					TotalQueries:  big.NewInt(0),
+					SlashedAmount: big.NewInt(0),
+				}
+			}
+			registry = newFakeRegistry(map[[32]byte]*registryNode{
+				NodeIDToBytes32(active):    newNode(active, StatusActive, "https://miner.example.org:4661"),
+				NodeIDToBytes32(suspended): newNode(suspended, StatusSuspended, "miner2.example.org:4661"),
+			})
+			url = serveFakeRegistry(registry)
+		)
+
+		Convey("The registry resolver should not be registered without registry", func() {
+			client, err := InitRegistryResolver(&conf.Config{})
+			So(err, ShouldBeNil)
+			So(client, ShouldBeNil)
+			_, err = InitRegistryResolver(&conf.Config{
+				Registry: &conf.RegistryInfo{Address: "not an address", RPCEndpoint: url},
+			})
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The registry resolver should not be registered with a misconfigured registry", func() {
+			registry.chainID = DefaultEndpoints[Testnet].ChainID
+			client, err := InitRegistryResolver(&conf.Config{
+				Registry: &conf.RegistryInfo{
+					Address:     "0x00000000000000000000000000000000000000aa",
+					RPCEndpoint: url,
+				},
+			})
+			So(errors.Is(err, ErrChainIDMismatch), ShouldBeTrue)
+			So(client, ShouldBeNil)
+			client, err = InitRegistryResolver(&conf.Config{
+				Registry: &conf.RegistryInfo{
+					Address:     "0x00000000000000000000000000000000000000aa",
+					RPCEndpoint: url,
+					Network:     string(Testnet),
+				},
+			})
+			So(err, ShouldBeNil)
+			So(client, ShouldNotBeNil)
+			client.Close()
+		})
+		Convey("The nodes should be resolved through the resolver chain", func() {
+			client, err := InitRegistryResolver(&conf.Config{
+				Registry: &conf.RegistryInfo{
+					Address:     "0x00000000000000000000000000000000000000aa",
+					RPCEndpoint: url,
+				},
+			})
+			So(err, ShouldBeNil)
+			So(client, ShouldNotBeNil)
+			defer client.Close()
+
+			c, err := naconn.NewChainResolverWithConfig([]conf.ResolverInfo{
+				{Name: naconn.JejuRegistryResolverName},
+			})
+			So(err, ShouldBeNil)
+			addr, err := c.Resolve(active.ToRawNodeID())
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "miner.example.org:4661")
+			_, err = c.Resolve(suspended.ToRawNodeID())
+			So(err, ShouldNotBeNil)
+			var unknown = proto.NodeID(strings.Repeat("c", 64))
+			_, err = c.Resolve(unknown.ToRawNodeID())
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
@@ -0,0 +1,100 @@
+/*
+ * Copyright 2024-2025 Jeju Network.
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package jeju
+
+import (
+	"context"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/utils/log"
+)
+
+// RegistrySeed bootstraps the block producers from the active ones in SqlitRegistry. The
+// registry keeps no node keys, so only the producers whose public key and nonce are known
+// locally, in the known nodes of config or the public key store, are seeded with their
+// registered endpoints.
+type RegistrySeed struct {
+	client *RegistryClient
+}
+
+// NewRegistrySeed creates a new seed source backed by the registry client.
+func NewRegistrySeed(client *RegistryClient) *RegistrySeed {
+	return &RegistrySeed{client: client}
+}
+
+// RegisterRegistrySeed registers the registry seed source so that it can be used in the
+// bootstrap config, it must be called before the route is initialized.
+func RegisterRegistrySeed(client *RegistryClient) {
+	route.RegisterSeedSource(route.RegistrySeedName, NewRegistrySeed(client))
+}
+
+// GetBPNodes implements route.SeedSource.GetBPNodes.
+func (s *RegistrySeed) GetBPNodes(ctx context.Context, _ *conf.DNSSeed) (nodes route.IDNodeMap, err error) {
+	var ids [][32]byte
+	if ids, err = s.client.GetActiveBlockProducers(ctx); err != nil {
+		return
+	}
+	nodes = make(route.IDNodeMap)
+	for _, id := range ids {
+		var (
+			nodeID = Bytes32ToNodeID(id)
+			rawID  = nodeID.ToRawNodeID()
+			sn     *SqlitNode
+			node   *proto.Node
+			addr   string
+			gerr   error
+		)
+		if rawID == nil {
+			continue
+		}
+		if sn, gerr = s.client.GetNode(ctx, id); gerr != nil || sn.Status != StatusActive {
+			log.WithField("node", nodeID).WithError(gerr).Debug("skip inactive registry node")
+			continue
+		}
+		if addr, gerr = endpointToAddr(sn.Endpoint); gerr != nil {
+			log.WithField("node", nodeID).WithError(gerr).Debug("skip registry node")
+			continue
+		}
+		if node = knownNode(nodeID); node == nil {
+			log.WithField("node", nodeID).Debug("skip registry node with unknown key")
+			continue
+		}
+		node.Addr = addr
+		node.Role = proto.Follower
+		nodes[*rawID] = *node
+	}
+	return
+}
+
+// knownNode returns a copy of the node identity known locally.
+func knownNode(id proto.NodeID) *proto.Node {
+	if conf.GConf != nil {
+		for _, n := range conf.GConf.KnownNodes {
+			if n.ID == id && n.PublicKey != nil {
+				return &n
+			}
+		}
+	}
+	if n, err := kms.GetNodeInfo(id); err == nil && n.PublicKey != nil {
+		var c = *n
+		return &c
+	}
+	return nil
+}
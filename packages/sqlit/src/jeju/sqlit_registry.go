@@ -17,9 +17,13 @@
 package jeju
 
 import (
+	"context"
+	"fmt"
 	"math/big"
+	"reflect"
 	"strings"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
@@ -145,6 +149,9 @@ const SqlitRegistryABI = `[
 // SqlitRegistryCaller is an auto generated read-only Go binding around an Ethereum contract.
 type SqlitRegistryCaller struct {
 	contract *bind.BoundContract
+	abi      abi.ABI
+	address  common.Address
+	caller   bind.ContractCaller
 }
 
 // SqlitRegistryTransactor is an auto generated write-only Go binding around an Ethereum contract.
@@ -176,9 +183,14 @@ func NewSqlitRegistry(address common.Address, backend bind.ContractBackend) (*Sq
 	contract := bind.NewBoundContract(address, parsed, backend, backend, backend)
 
 	return &SqlitRegistry{
-		SqlitRegistryCaller:     SqlitRegistryCaller{contract: contract},
+		SqlitRegistryCaller: SqlitRegistryCaller{
+			contract: contract,
+			abi:      parsed,
+			address:  address,
+			caller:   backend,
+		},
 		SqlitRegistryTransactor: SqlitRegistryTransactor{contract: contract},
-		address:                  address,
+		address:                 address,
 	}, nil
 }
 
@@ -187,16 +199,67 @@ func (e *SqlitRegistry) Address() common.Address {
 	return e.address
 }
 
-// GetNode retrieves node information from the registry.
-func (c *SqlitRegistryCaller) GetNode(opts *bind.CallOpts, nodeId [32]byte) (*SqlitNode, error) {
-	var out []interface{}
-	err := c.contract.Call(opts, &out, "getNode", nodeId)
+// callTuple calls the view method returning a single tuple and unpacks the tuple into result,
+// which can't be done by the bound contract of this go-ethereum version.
+func (c *SqlitRegistryCaller) callTuple(
+	opts *bind.CallOpts, result interface{}, method string, params ...interface{},
+) error {
+	if opts == nil {
+		opts = new(bind.CallOpts)
+	}
+	input, err := c.abi.Pack(method, params...)
 	if err != nil {
-		return nil, err
+		return err
+	}
+	var ctx = opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	output, err := c.caller.CallContract(ctx, ethereum.CallMsg{
+		From: opts.From,
+		To:   &c.address,
+		Data: input,
+	}, opts.BlockNumber)
+	if err != nil {
+		return err
+	}
+	if len(output) == 0 {
+		return bind.ErrNoCode
+	}
+	out, err := c.abi.Methods[method].Outputs.UnpackValues(output)
+	if err != nil {
+		return err
+	}
+	if len(out) != 1 {
+		return fmt.Errorf("%w: %s returns %d values instead of a tuple",
+			ErrRegistryInterface, method, len(out))
+	}
+	// the tuple is unpacked as a struct with the camel case names of its components
+	var (
+		src = reflect.ValueOf(out[0])
+		dst = reflect.ValueOf(result).Elem()
+	)
+	if src.Kind() != reflect.Struct || src.NumField() != dst.NumField() {
+		return fmt.Errorf("%w: %s returns %s instead of %s",
+			ErrRegistryInterface, method, src.Type(), dst.Type())
+	}
+	for i := 0; i < dst.NumField(); i++ {
+		var (
+			f = dst.Type().Field(i)
+			v = src.FieldByName(f.Name)
+		)
+		if !v.IsValid() || !v.Type().AssignableTo(f.Type) {
+			return fmt.Errorf("%w: %s returns %s instead of %s",
+				ErrRegistryInterface, method, src.Type(), dst.Type())
+		}
+		dst.Field(i).Set(v)
 	}
+	return nil
+}
 
-	// Parse the result struct
-	result := out[0].(struct {
+// GetNode retrieves node information from the registry.
+func (c *SqlitRegistryCaller) GetNode(opts *bind.CallOpts, nodeId [32]byte) (*SqlitNode, error) {
+	var result struct {
 		Operator       common.Address
 		NodeId         [32]byte
 		Role           uint8
@@ -210,7 +273,10 @@ func (c *SqlitRegistryCaller) GetNode(opts *bind.CallOpts, nodeId [32]byte) (*Sq
 		DatabaseCount  *big.Int
 		TotalQueries   *big.Int
 		SlashedAmount  *big.Int
-	})
+	}
+	if err := c.callTuple(opts, &result, "getNode", nodeId); err != nil {
+		return nil, err
+	}
 
 	return &SqlitNode{
 		Operator:      result.Operator,
@@ -229,49 +295,46 @@ func (c *SqlitRegistryCaller) GetNode(opts *bind.CallOpts, nodeId [32]byte) (*Sq
 
 // IsNodeHealthy checks if a node is healthy based on heartbeat.
 func (c *SqlitRegistryCaller) IsNodeHealthy(opts *bind.CallOpts, nodeId [32]byte) (bool, error) {
-	var out []interface{}
+	var out bool
 	err := c.contract.Call(opts, &out, "isNodeHealthy", nodeId)
 	if err != nil {
 		return false, err
 	}
-	return out[0].(bool), nil
+	return out, nil
 }
 
 // GetActiveMiners returns all active miner node IDs.
 func (c *SqlitRegistryCaller) GetActiveMiners(opts *bind.CallOpts) ([][32]byte, error) {
-	var out []interface{}
+	var out [][32]byte
 	err := c.contract.Call(opts, &out, "getActiveMiners")
 	if err != nil {
 		return nil, err
 	}
-	return out[0].([][32]byte), nil
+	return out, nil
 }
 
 // GetActiveBlockProducers returns all active block producer node IDs.
 func (c *SqlitRegistryCaller) GetActiveBlockProducers(opts *bind.CallOpts) ([][32]byte, error) {
-	var out []interface{}
+	var out [][32]byte
 	err := c.contract.Call(opts, &out, "getActiveBlockProducers")
 	if err != nil {
 		return nil, err
 	}
-	return out[0].([][32]byte), nil
+	return out, nil
 }
 
 // GetDatabaseInfo retrieves database information from the registry.
 func (c *SqlitRegistryCaller) GetDatabaseInfo(opts *bind.CallOpts, databaseId [32]byte) (*DatabaseInfo, error) {
-	var out []interface{}
-	err := c.contract.Call(opts, &out, "getDatabaseInfo", databaseId)
-	if err != nil {
-		return nil, err
-	}
-
-	result := out[0].(struct {
+	var result struct {
 		DatabaseId   [32]byte
 		Owner        common.Address
 		MinerNodeIds [][32]byte
 		CreatedAt    *big.Int
 		Active       bool
-	})
+	}
+	if err := c.callTuple(opts, &result, "getDatabaseInfo", databaseId); err != nil {
+		return nil, err
+	}
 
 	return &DatabaseInfo{
 		DatabaseID:   result.DatabaseId,
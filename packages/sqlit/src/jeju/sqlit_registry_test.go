@@ -0,0 +1,91 @@
+package jeju
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/common"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// stubCaller returns the same output for all the contract calls.
+type stubCaller struct {
+	output []byte
+}
+
+func (c *stubCaller) CodeAt(context.Context, common.Address, *big.Int) ([]byte, error) {
+	return []byte{0x60, 0x80}, nil
+}
+
+func (c *stubCaller) CallContract(context.Context, ethereum.CallMsg, *big.Int) ([]byte, error) {
+	return c.output, nil
+}
+
+func TestCallTuple(t *testing.T) {
+	Convey("Given the getNode output of a registry", t, func() {
+		registry, err := NewSqlitRegistry(common.Address{0xaa}, nil)
+		So(err, ShouldBeNil)
+		output, err := registry.abi.Methods["getNode"].Outputs.Pack(&registryNode{
+			NodeId:        [32]byte{1},
+			Role:          uint8(RoleMiner),
+			Status:        uint8(StatusActive),
+			StakedAmount:  big.NewInt(1),
+			RegisteredAt:  big.NewInt(2),
+			LastHeartbeat: big.NewInt(3),
+			Endpoint:      "miner.example.org:4661",
+			DatabaseCount: big.NewInt(4),
+			TotalQueries:  big.NewInt(5),
+			SlashedAmount: big.NewInt(6),
+		})
+		So(err, ShouldBeNil)
+		var caller = registry.SqlitRegistryCaller
+		caller.caller = &stubCaller{output: output}
+
+		Convey("The tuple should be unpacked into the matching struct", func() {
+			node, err := caller.GetNode(nil, [32]byte{1})
+			So(err, ShouldBeNil)
+			So(node.NodeID, ShouldEqual, [32]byte{1})
+			So(node.Role, ShouldEqual, RoleMiner)
+			So(node.Endpoint, ShouldEqual, "miner.example.org:4661")
+			So(node.SlashedAmount.Int64(), ShouldEqual, 6)
+		})
+		Convey("The struct not matching the tuple should be refused without panic", func() {
+			type roleAsBig struct {
+				Operator       common.Address
+				NodeId         [32]byte
+				Role           *big.Int
+				Status         uint8
+				StakedAmount   *big.Int
+				RegisteredAt   *big.Int
+				LastHeartbeat  *big.Int
+				Endpoint       string
+				TeeAttestation []byte
+				MrEnclave      [32]byte
+				DatabaseCount  *big.Int
+				TotalQueries   *big.Int
+				SlashedAmount  *big.Int
+			}
+			var (
+				role  roleAsBig
+				fewer struct{ Operator common.Address }
+			)
+			for _, result := range []interface{}{&role, &fewer} {
+				var err error
+				So(func() { err = caller.callTuple(nil, result, "getNode", [32]byte{1}) }, ShouldNotPanic)
+				So(errors.Is(err, ErrRegistryInterface), ShouldBeTrue)
+			}
+		})
+		Convey("The output not of a single tuple should be refused", func() {
+			var ids [][32]byte
+			output, err := registry.abi.Methods["getActiveMiners"].Outputs.Pack(ids)
+			So(err, ShouldBeNil)
+			caller.caller = &stubCaller{output: output}
+			var result struct{ Ids [][32]byte }
+			err = caller.callTuple(nil, &result, "getActiveMiners")
+			So(errors.Is(err, ErrRegistryInterface), ShouldBeTrue)
+		})
+	})
+}
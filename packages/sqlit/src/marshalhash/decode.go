@@ -0,0 +1,504 @@
+package marshalhash
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrShortBuffer indicates the encoding ends before a complete value is read.
+	ErrShortBuffer = errors.New("marshalhash: short buffer")
+	// ErrUnexpectedType indicates the next value is not of the requested type.
+	ErrUnexpectedType = errors.New("marshalhash: unexpected type")
+	// ErrTrailingBytes indicates there are extra bytes after the encoded values.
+	ErrTrailingBytes = errors.New("marshalhash: trailing bytes")
+	// ErrTooDeep indicates the arrays or maps are nested deeper than MaxDepth.
+	ErrTooDeep = errors.New("marshalhash: nested too deep")
+)
+
+// MaxDepth is the max nesting depth of arrays and maps accepted by Reader.Skip.
+const MaxDepth = 64
+
+// Reader reads values from the canonical encoding.
+type Reader struct {
+	b []byte
+}
+
+// NewReader returns a new Reader reading from b.
+func NewReader(b []byte) *Reader {
+	return &Reader{b: b}
+}
+
+// Len returns the count of unread bytes.
+func (r *Reader) Len() int {
+	return len(r.b)
+}
+
+func (r *Reader) next(n int) (o []byte, err error) {
+	if len(r.b) < n {
+		err = ErrShortBuffer
+		return
+	}
+	o, r.b = r.b[:n], r.b[n:]
+	return
+}
+
+func (r *Reader) peek() (byte, error) {
+	if len(r.b) == 0 {
+		return 0, ErrShortBuffer
+	}
+	return r.b[0], nil
+}
+
+func (r *Reader) readLength(size int) (n int, err error) {
+	var o []byte
+	if o, err = r.next(size); err != nil {
+		return
+	}
+	switch size {
+	case 1:
+		n = int(o[0])
+	case 2:
+		n = int(binary.BigEndian.Uint16(o))
+	default:
+		n = int(binary.BigEndian.Uint32(o))
+	}
+	return
+}
+
+// IsNil returns whether the next value is nil, and consumes it if it is.
+func (r *Reader) IsNil() bool {
+	if c, err := r.peek(); err == nil && c == mnil {
+		r.b = r.b[1:]
+		return true
+	}
+	return false
+}
+
+// ReadArrayHeader reads an array header and returns the count of elements.
+func (r *Reader) ReadArrayHeader() (n uint32, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var l int
+	switch {
+	case c&0xf0 == mfixarray:
+		r.b = r.b[1:]
+		l = int(c & 0x0f)
+	case c == marray16:
+		r.b = r.b[1:]
+		l, err = r.readLength(2)
+	case c == marray32:
+		r.b = r.b[1:]
+		l, err = r.readLength(4)
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read array header: 0x%02x", c)
+	}
+	n = uint32(l)
+	return
+}
+
+func (r *Reader) readMapHeader() (n int, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	switch {
+	case c&0xf0 == mfixmap:
+		r.b = r.b[1:]
+		n = int(c & 0x0f)
+	case c == mmap16:
+		r.b = r.b[1:]
+		n, err = r.readLength(2)
+	case c == mmap32:
+		r.b = r.b[1:]
+		n, err = r.readLength(4)
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read map header: 0x%02x", c)
+	}
+	return
+}
+
+// ReadBool reads a boolean value.
+func (r *Reader) ReadBool() (v bool, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	switch c {
+	case mtrue:
+		v = true
+	case mfalse:
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read bool: 0x%02x", c)
+		return
+	}
+	r.b = r.b[1:]
+	return
+}
+
+// ReadInt64 reads a signed or unsigned integer fitting in an int64.
+func (r *Reader) ReadInt64() (v int64, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var o []byte
+	switch {
+	case c <= 0x7f || c >= 0xe0:
+		r.b = r.b[1:]
+		v = int64(int8(c))
+	case c == mint8:
+		if o, err = r.next(2); err == nil {
+			v = int64(int8(o[1]))
+		}
+	case c == mint16:
+		if o, err = r.next(3); err == nil {
+			v = int64(int16(binary.BigEndian.Uint16(o[1:])))
+		}
+	case c == mint32:
+		if o, err = r.next(5); err == nil {
+			v = int64(int32(binary.BigEndian.Uint32(o[1:])))
+		}
+	case c == mint64:
+		if o, err = r.next(9); err == nil {
+			v = int64(binary.BigEndian.Uint64(o[1:]))
+		}
+	case c >= muint8 && c <= muint64:
+		var u uint64
+		if u, err = r.ReadUint64(); err != nil {
+			return
+		}
+		if u > math.MaxInt64 {
+			err = errors.Wrapf(ErrUnexpectedType, "read int64: %d overflows", u)
+			return
+		}
+		v = int64(u)
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read int64: 0x%02x", c)
+	}
+	return
+}
+
+// ReadUint64 reads a non-negative integer.
+func (r *Reader) ReadUint64() (v uint64, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var o []byte
+	switch {
+	case c <= 0x7f:
+		r.b = r.b[1:]
+		v = uint64(c)
+	case c == muint8:
+		if o, err = r.next(2); err == nil {
+			v = uint64(o[1])
+		}
+	case c == muint16:
+		if o, err = r.next(3); err == nil {
+			v = uint64(binary.BigEndian.Uint16(o[1:]))
+		}
+	case c == muint32:
+		if o, err = r.next(5); err == nil {
+			v = uint64(binary.BigEndian.Uint32(o[1:]))
+		}
+	case c == muint64:
+		if o, err = r.next(9); err == nil {
+			v = binary.BigEndian.Uint64(o[1:])
+		}
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read uint64: 0x%02x", c)
+	}
+	return
+}
+
+// ReadFloat64 reads a float value.
+func (r *Reader) ReadFloat64() (v float64, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var o []byte
+	switch c {
+	case mfloat32:
+		if o, err = r.next(5); err == nil {
+			v = float64(math.Float32frombits(binary.BigEndian.Uint32(o[1:])))
+		}
+	case mfloat64:
+		if o, err = r.next(9); err == nil {
+			v = math.Float64frombits(binary.BigEndian.Uint64(o[1:]))
+		}
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read float64: 0x%02x", c)
+	}
+	return
+}
+
+// ReadString reads a string value.
+func (r *Reader) ReadString() (v string, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var n int
+	switch {
+	case c&0xe0 == mfixstr:
+		r.b = r.b[1:]
+		n = int(c & 0x1f)
+	case c == mstr8:
+		r.b = r.b[1:]
+		n, err = r.readLength(1)
+	case c == mstr16:
+		r.b = r.b[1:]
+		n, err = r.readLength(2)
+	case c == mstr32:
+		r.b = r.b[1:]
+		n, err = r.readLength(4)
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read string: 0x%02x", c)
+	}
+	if err != nil {
+		return
+	}
+	var o []byte
+	if o, err = r.next(n); err != nil {
+		return
+	}
+	v = string(o)
+	return
+}
+
+// ReadBytes reads a byte slice value, the returned slice is a copy.
+func (r *Reader) ReadBytes() (v []byte, err error) {
+	var o []byte
+	if o, err = r.readBin(); err != nil {
+		return
+	}
+	v = append([]byte{}, o...)
+	return
+}
+
+func (r *Reader) readBin() (o []byte, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var n int
+	switch c {
+	case mbin8:
+		r.b = r.b[1:]
+		n, err = r.readLength(1)
+	case mbin16:
+		r.b = r.b[1:]
+		n, err = r.readLength(2)
+	case mbin32:
+		r.b = r.b[1:]
+		n, err = r.readLength(4)
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "read bytes: 0x%02x", c)
+	}
+	if err != nil {
+		return
+	}
+	return r.next(n)
+}
+
+// ReadTime reads a time value encoded by AppendTime, the returned time is in UTC.
+func (r *Reader) ReadTime() (v time.Time, err error) {
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	if c != mfixext8 {
+		err = errors.Wrapf(ErrUnexpectedType, "read time: 0x%02x", c)
+		return
+	}
+	var o []byte
+	if o, err = r.next(10); err != nil {
+		return
+	}
+	if o[1] != TimeExtensionByte {
+		err = errors.Wrapf(ErrUnexpectedType, "read time: extension type 0x%02x", o[1])
+		return
+	}
+	data64 := binary.BigEndian.Uint64(o[2:])
+	v = time.Unix(int64(data64&0x3ffffffff), int64(data64>>34)).UTC()
+	return
+}
+
+// Skip skips the next value, including all elements of an array or map.
+func (r *Reader) Skip() (err error) {
+	return r.skip(0)
+}
+
+func (r *Reader) skip(depth int) (err error) {
+	if depth > MaxDepth {
+		return ErrTooDeep
+	}
+	var c byte
+	if c, err = r.peek(); err != nil {
+		return
+	}
+	var n int
+	switch {
+	case c <= 0x7f || c >= 0xe0, c == mnil, c == mtrue, c == mfalse:
+		_, err = r.next(1)
+	case c == muint8, c == mint8:
+		_, err = r.next(2)
+	case c == muint16, c == mint16:
+		_, err = r.next(3)
+	case c == muint32, c == mint32, c == mfloat32:
+		_, err = r.next(5)
+	case c == muint64, c == mint64, c == mfloat64:
+		_, err = r.next(9)
+	case c == mfixext8:
+		_, err = r.next(10)
+	case c&0xe0 == mfixstr, c == mstr8, c == mstr16, c == mstr32:
+		_, err = r.ReadString()
+	case c == mbin8, c == mbin16, c == mbin32:
+		_, err = r.readBin()
+	case c&0xf0 == mfixarray, c == marray16, c == marray32:
+		var l uint32
+		if l, err = r.ReadArrayHeader(); err != nil {
+			return
+		}
+		for i := uint32(0); i < l && err == nil; i++ {
+			err = r.skip(depth + 1)
+		}
+	case c&0xf0 == mfixmap, c == mmap16, c == mmap32:
+		if n, err = r.readMapHeader(); err != nil {
+			return
+		}
+		for i := 0; i < 2*n && err == nil; i++ {
+			err = r.skip(depth + 1)
+		}
+	default:
+		err = errors.Wrapf(ErrUnexpectedType, "skip: 0x%02x", c)
+	}
+	return
+}
+
+// Fields is the canonical encoding of an array of trailing fields. Decoders unaware of some of
+// the fields keep the encoding as is, so they compute the same hash as the encoder.
+type Fields []byte
+
+// NewFields returns the Fields of values.
+func NewFields(values ...interface{}) (f Fields, err error) {
+	var b = AppendArrayHeader(make([]byte, 0, GuessSize(values)), uint32(len(values)))
+	for _, v := range values {
+		if b, err = AppendIntf(b, v); err != nil {
+			return
+		}
+	}
+	f = Fields(b)
+	return
+}
+
+// Count returns the count of fields, it also verifies that f is a well-formed array.
+func (f Fields) Count() (n int, err error) {
+	if len(f) == 0 {
+		return
+	}
+	var (
+		r = NewReader(f)
+		l uint32
+	)
+	if l, err = r.ReadArrayHeader(); err != nil {
+		return
+	}
+	for i := uint32(0); i < l; i++ {
+		if err = r.Skip(); err != nil {
+			return
+		}
+	}
+	if r.Len() > 0 {
+		err = ErrTrailingBytes
+		return
+	}
+	n = int(l)
+	return
+}
+
+// AppendElements appends the encoded fields to b without the array header.
+func (f Fields) AppendElements(b []byte) ([]byte, error) {
+	if len(f) == 0 {
+		return b, nil
+	}
+	var r = NewReader(f)
+	if _, err := r.ReadArrayHeader(); err != nil {
+		return nil, err
+	}
+	return append(b, f[len(f)-r.Len():]...), nil
+}
+
+// Decode decodes the leading fields into out in order, which must be pointers of bool, int,
+// int32, int64, uint32, uint64, float64, string, []byte or time.Time. Fields beyond len(out)
+// are ignored, and out values are left unchanged if f has fewer fields.
+func (f Fields) Decode(out ...interface{}) (err error) {
+	if len(f) == 0 {
+		return
+	}
+	var (
+		r = NewReader(f)
+		l uint32
+	)
+	if l, err = r.ReadArrayHeader(); err != nil {
+		return
+	}
+	for i := 0; i < len(out) && i < int(l); i++ {
+		if err = readInto(r, out[i]); err != nil {
+			return errors.Wrapf(err, "decode field %d", i)
+		}
+	}
+	return
+}
+
+func readInto(r *Reader, out interface{}) (err error) {
+	if r.IsNil() {
+		return
+	}
+	switch v := out.(type) {
+	case *bool:
+		*v, err = r.ReadBool()
+	case *int:
+		var i int64
+		i, err = r.ReadInt64()
+		*v = int(i)
+	case *int32:
+		var i int64
+		if i, err = r.ReadInt64(); err == nil {
+			if i < math.MinInt32 || i > math.MaxInt32 {
+				return errors.Wrapf(ErrUnexpectedType, "read int32: %d overflows", i)
+			}
+			*v = int32(i)
+		}
+	case *int64:
+		*v, err = r.ReadInt64()
+	case *uint32:
+		var u uint64
+		if u, err = r.ReadUint64(); err == nil {
+			if u > math.MaxUint32 {
+				return errors.Wrapf(ErrUnexpectedType, "read uint32: %d overflows", u)
+			}
+			*v = uint32(u)
+		}
+	case *uint64:
+		*v, err = r.ReadUint64()
+	case *float64:
+		*v, err = r.ReadFloat64()
+	case *string:
+		*v, err = r.ReadString()
+	case *[]byte:
+		*v, err = r.ReadBytes()
+	case *time.Time:
+		*v, err = r.ReadTime()
+	default:
+		err = errors.Errorf("marshalhash: unsupported decode type %T", out)
+	}
+	return
+}
@@ -0,0 +1,61 @@
+package marshalhash
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func FuzzFields(f *testing.F) {
+	for _, values := range [][]interface{}{
+		nil,
+		{int32(100), "trace"},
+		{int64(-1), uint64(1 << 40), 0.5, true, []byte{1, 2}, time.Unix(1546300800, 1).UTC(), nil},
+		{[]interface{}{"nested", map[string]interface{}{"k": 1}}},
+	} {
+		fields, err := NewFields(values...)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add([]byte(fields))
+	}
+	f.Add([]byte{0xdd, 0xff, 0xff, 0xff, 0xff})
+	f.Add([]byte{0x91, 0xc6, 0xff, 0xff, 0xff, 0xff})
+	f.Add(bytes.Repeat([]byte{0x91}, 4096))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var fields = Fields(data)
+		n, err := fields.Count()
+		if err != nil {
+			return
+		}
+		enc, err := fields.AppendElements(nil)
+		if err != nil {
+			t.Fatalf("append elements of valid fields failed: %v", err)
+		}
+		if len(data) > 0 && !bytes.HasSuffix(data, enc) {
+			t.Fatalf("elements are not a suffix of the fields")
+		}
+
+		// decoding into any types must not panic
+		var (
+			i   int64
+			u   uint64
+			s   string
+			b   []byte
+			fl  float64
+			tm  time.Time
+			bl  bool
+			i32 int32
+		)
+		_ = fields.Decode(&i, &u, &s, &b, &fl, &tm, &bl, &i32)
+		r := NewReader(enc)
+		for k := 0; k < n; k++ {
+			if err = r.Skip(); err != nil {
+				t.Fatalf("skip field %d of valid fields failed: %v", k, err)
+			}
+		}
+		if r.Len() != 0 {
+			t.Fatalf("unexpected %d trailing bytes", r.Len())
+		}
+	})
+}
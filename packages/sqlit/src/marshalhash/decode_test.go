@@ -0,0 +1,150 @@
+package marshalhash
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReader(t *testing.T) {
+	Convey("Reader should read the values encoded by the append functions", t, func() {
+		var (
+			ts  = time.Unix(1546300800, 123456789).UTC()
+			str = strings.Repeat("s", 300)
+			b   []byte
+		)
+		b = AppendArrayHeader(b, 20)
+		for _, v := range []int64{0, 127, -1, -32, -33, math.MinInt8, math.MinInt16, math.MinInt32, math.MinInt64} {
+			b = AppendInt64(b, v)
+		}
+		for _, v := range []uint64{128, math.MaxUint16, math.MaxUint32, math.MaxUint64} {
+			b = AppendUint64(b, v)
+		}
+		b = AppendFloat64(b, 0.5)
+		b = AppendBool(b, true)
+		b = AppendString(b, "a")
+		b = AppendString(b, str)
+		b = AppendBytes(b, []byte{1, 2})
+		b = AppendTime(b, ts)
+		b = AppendNil(b)
+
+		r := NewReader(b)
+		n, err := r.ReadArrayHeader()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 20)
+		for _, v := range []int64{0, 127, -1, -32, -33, math.MinInt8, math.MinInt16, math.MinInt32, math.MinInt64} {
+			i, err := r.ReadInt64()
+			So(err, ShouldBeNil)
+			So(i, ShouldEqual, v)
+		}
+		for _, v := range []uint64{128, math.MaxUint16, math.MaxUint32, math.MaxUint64} {
+			u, err := r.ReadUint64()
+			So(err, ShouldBeNil)
+			So(u, ShouldEqual, v)
+		}
+		f, err := r.ReadFloat64()
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, 0.5)
+		bl, err := r.ReadBool()
+		So(err, ShouldBeNil)
+		So(bl, ShouldBeTrue)
+		s, err := r.ReadString()
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "a")
+		s, err = r.ReadString()
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, str)
+		bs, err := r.ReadBytes()
+		So(err, ShouldBeNil)
+		So(bs, ShouldResemble, []byte{1, 2})
+		tm, err := r.ReadTime()
+		So(err, ShouldBeNil)
+		So(tm.Equal(ts), ShouldBeTrue)
+		So(r.IsNil(), ShouldBeTrue)
+		So(r.Len(), ShouldEqual, 0)
+
+		_, err = r.ReadInt64()
+		So(err, ShouldEqual, ErrShortBuffer)
+	})
+	Convey("Reader should reject values of unexpected types", t, func() {
+		_, err := NewReader(AppendString(nil, "a")).ReadInt64()
+		So(err, ShouldNotBeNil)
+		_, err = NewReader(AppendUint64(nil, math.MaxUint64)).ReadInt64()
+		So(err, ShouldNotBeNil)
+		_, err = NewReader(AppendInt64(nil, -1)).ReadUint64()
+		So(err, ShouldNotBeNil)
+		_, err = NewReader(AppendBytes(nil, []byte{1})).ReadString()
+		So(err, ShouldNotBeNil)
+	})
+	Convey("Reader should skip nested values", t, func() {
+		b, err := AppendIntf(nil, []interface{}{
+			map[string]interface{}{"a": []byte{1}, "b": []interface{}{"c", time.Now()}},
+			-100, 1.5, nil,
+		})
+		So(err, ShouldBeNil)
+		b = AppendString(b, "next")
+		r := NewReader(b)
+		So(r.Skip(), ShouldBeNil)
+		s, err := r.ReadString()
+		So(err, ShouldBeNil)
+		So(s, ShouldEqual, "next")
+		So(NewReader(b[:len(b)-6]).Skip(), ShouldEqual, ErrShortBuffer)
+	})
+}
+
+func TestFields(t *testing.T) {
+	Convey("Fields should decode leading fields and ignore unknown trailing ones", t, func() {
+		ts := time.Unix(1546300800, 0).UTC()
+		f, err := NewFields(int32(100), "trace", ts, []interface{}{"unknown", 1}, 2.5)
+		So(err, ShouldBeNil)
+		n, err := f.Count()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 5)
+
+		var (
+			height int32
+			trace  string
+			tm     time.Time
+		)
+		So(f.Decode(&height, &trace, &tm), ShouldBeNil)
+		So(height, ShouldEqual, 100)
+		So(trace, ShouldEqual, "trace")
+		So(tm, ShouldResemble, ts)
+
+		var i int64
+		So(f.Decode(&i), ShouldBeNil)
+		So(i, ShouldEqual, 100)
+		So(f.Decode(&height, &i), ShouldNotBeNil)
+
+		enc, err := f.AppendElements(nil)
+		So(err, ShouldBeNil)
+		So(enc, ShouldResemble, []byte(f[1:]))
+	})
+	Convey("Fields should leave values unchanged if there are fewer fields", t, func() {
+		var (
+			trace = "default"
+			empty Fields
+		)
+		So(empty.Decode(&trace), ShouldBeNil)
+		So(trace, ShouldEqual, "default")
+		n, err := empty.Count()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 0)
+		enc, err := empty.AppendElements([]byte{1})
+		So(err, ShouldBeNil)
+		So(enc, ShouldResemble, []byte{1})
+	})
+	Convey("Malformed fields should be rejected", t, func() {
+		f, err := NewFields("a", int64(1))
+		So(err, ShouldBeNil)
+		_, err = Fields(f[:len(f)-1]).Count()
+		So(err, ShouldNotBeNil)
+		_, err = Fields(append(f, 0x01)).Count()
+		So(err, ShouldEqual, ErrTrailingBytes)
+		_, err = Fields(AppendString(nil, "a")).Count()
+		So(err, ShouldNotBeNil)
+	})
+}
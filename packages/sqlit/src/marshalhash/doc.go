@@ -0,0 +1,46 @@
+/*
+Package marshalhash provides msgpack encoding functions with deterministic ordering for use in
+hash computations. This is a drop-in replacement for HashStablePack/marshalhash.
+
+The canonical encoding, identified by CanonicalVersion, is the input of hash.THashH for every
+signed type, such as types.Header, types.RequestHeader and types.BPHeader. Two values which are
+logically identical must produce identical encodings, so any change to the rules below must bump
+CanonicalVersion and keep the previous rules available for the data already signed.
+
+Version 1 rules:
+
+  - structs are encoded as arrays of their hashed fields in declaration order, optional
+    trailing fields may be omitted when they hold zero values to keep earlier hashes stable
+  - integers use the shortest msgpack form of their value, regardless of the Go type width or
+    signedness, so int8(1), uint64(1) and a named integer type holding 1 are identical
+  - floats are always encoded as float64
+  - strings and named string types are encoded as str, byte slices and the byte array fields
+    of the signed types (such as hash.Hash and proto.AccountAddress) are encoded as bin
+  - byte arrays held by interface values are encoded as arrays of their elements
+  - time values are encoded as the 8-byte msgpack timestamp extension of type -1 with the
+    layout (nanoseconds << 34) | unix seconds, the location is ignored
+  - maps are encoded with their string keys sorted in ascending byte order
+  - nil pointers, interfaces, slices and maps are encoded as nil
+  - the signed block and request headers with a non-zero serialization version append the
+    version and then the elements of their extension Fields after the legacy fields, the
+    elements are kept as received, so decoders unaware of some of them hash them unchanged
+
+Known limitations of version 1, which are kept for the compatibility of signed data:
+
+  - types.RequestHeader only covers QueryType, NodeID, DatabaseID, ConnectionID and SeqNo, the
+    Timestamp, BatchCount and QueriesHash fields are not part of the signed hash
+  - strings and byte slices of the same content are encoded differently, so result values must
+    keep the types returned by the storage
+  - byte arrays held by interface values, such as the query arguments, are not encoded as bin,
+    so they differ from the byte slices of the same content
+  - NaN floats are encoded with their payload bits, which differ between the floating point
+    units, so the query results are canonicalized by the dpos package before they are hashed
+
+The named basic types, maps and the other values without an encoding above used to recurse
+without an end, so encoding the named basic types and string keyed maps and rejecting the
+others with an error change no hash of the data signed already.
+
+Golden vectors of the signed types are kept in types/testdata/hash_vectors.json for
+implementations in other languages and packages to verify against.
+*/
+package marshalhash
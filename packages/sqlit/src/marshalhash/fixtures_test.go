@@ -0,0 +1,44 @@
+package marshalhash_test
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/verifier"
+	"sqlit/src/marshalhash"
+	"sqlit/src/types/fixtures"
+)
+
+func TestFixturesEncoding(t *testing.T) {
+	Convey("The encodings of the fully-populated fixtures should be well-formed and stable", t, func() {
+		var (
+			g    = fixtures.New(1)
+			req  = g.Request()
+			resp = g.Response(req)
+			ack  = g.Ack(resp)
+			b    = g.Block(g.Hash())
+			bp   = g.BPBlock(g.Hash())
+			meta = g.ResourceMeta()
+		)
+		var values = []verifier.MarshalHasher{
+			&req.Header.RequestHeader, &req.Payload, &resp.Header.ResponseHeader, &resp.Payload,
+			&ack.Header.AckHeader, &b.SignedHeader.Header, &bp.SignedHeader.BPHeader, &meta,
+			g.UserPermission(),
+		}
+		for _, tx := range g.Transactions() {
+			values = append(values, tx)
+		}
+		for _, v := range values {
+			enc, err := v.MarshalHash()
+			So(err, ShouldBeNil)
+			var r = marshalhash.NewReader(enc)
+			So(r.Skip(), ShouldBeNil)
+			So(r.Len(), ShouldEqual, 0)
+
+			again, err := v.MarshalHash()
+			So(err, ShouldBeNil)
+			So(again, ShouldResemble, enc)
+		}
+	})
+}
@@ -1,5 +1,3 @@
-// Package marshalhash provides msgpack encoding functions with deterministic ordering
-// for use in hash computations. This is a drop-in replacement for HashStablePack/marshalhash.
 package marshalhash
 
 import (
@@ -8,8 +6,13 @@ import (
 	"reflect"
 	"sort"
 	"time"
+
+	"github.com/pkg/errors"
 )
 
+// CanonicalVersion is the version of the canonical encoding rules implemented by this package.
+const CanonicalVersion = 1
+
 // Msgpack format constants
 const (
 	mfixstr   = 0xa0
@@ -82,6 +85,16 @@ const (
 	TimeSize = 10
 )
 
+// StringSize returns an upper bound of the size needed to encode string s
+func StringSize(s string) int {
+	return StringPrefixSize + len(s)
+}
+
+// BytesSize returns an upper bound of the size needed to encode a byte slice of length n
+func BytesSize(n int) int {
+	return BytesPrefixSize + n
+}
+
 // ByteSize returns the size needed to encode a single byte
 func ByteSize(b byte) int {
 	if b < 128 {
@@ -112,6 +125,12 @@ func GuessSize(i interface{}) int {
 		return BytesPrefixSize + len(v)
 	case time.Time:
 		return TimeSize
+	case []interface{}:
+		s := ArrayHeaderSize
+		for _, e := range v {
+			s += GuessSize(e)
+		}
+		return s
 	default:
 		return 32 // conservative estimate
 	}
@@ -439,6 +458,7 @@ func appendReflect(b []byte, v reflect.Value) ([]byte, error) {
 		}
 		return b, nil
 	case reflect.Array:
+		// byte arrays are encoded as arrays of their elements, as in the data signed already
 		b = AppendArrayHeader(b, uint32(v.Len()))
 		var err error
 		for i := 0; i < v.Len(); i++ {
@@ -448,7 +468,54 @@ func appendReflect(b []byte, v reflect.Value) ([]byte, error) {
 			}
 		}
 		return b, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return AppendNil(b), nil
+		}
+		if v.Type().Key().Kind() != reflect.String {
+			return nil, errors.Errorf("unsupported map key type %s", v.Type().Key())
+		}
+		m := make(map[string]interface{}, v.Len())
+		for _, k := range v.MapKeys() {
+			m[k.String()] = v.MapIndex(k).Interface()
+		}
+		return appendMapSorted(b, m)
+	case reflect.Struct:
+		if t, ok := v.Interface().(time.Time); ok {
+			return AppendTime(b, t), nil
+		}
+		// copy to an addressable value for the methods with pointer receiver
+		p := reflect.New(v.Type())
+		p.Elem().Set(v)
+		if mh, ok := p.Interface().(hasher); ok {
+			return appendHasher(b, mh)
+		}
+		return nil, errors.Errorf("unsupported struct type %s", v.Type())
+	// named basic types are encoded as their underlying types
+	case reflect.Bool:
+		return AppendBool(b, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return AppendInt64(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return AppendUint64(b, v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return AppendFloat(b, v.Float()), nil
+	case reflect.String:
+		return AppendString(b, v.String()), nil
 	default:
-		return AppendIntf(b, v.Interface())
+		return nil, errors.Errorf("unsupported type %s", v.Type())
+	}
+}
+
+// hasher is implemented by the types with their own hash encoding.
+type hasher interface {
+	MarshalHash() ([]byte, error)
+}
+
+func appendHasher(b []byte, mh hasher) (o []byte, err error) {
+	var enc []byte
+	if enc, err = mh.MarshalHash(); err != nil {
+		return
 	}
+	return append(b, enc...), nil
 }
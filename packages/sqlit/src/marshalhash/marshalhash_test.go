@@ -0,0 +1,55 @@
+package marshalhash
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type (
+	testString string
+	testInt    int16
+	testArray  [4]byte
+	testStruct struct{ v uint32 }
+)
+
+func (s *testStruct) MarshalHash() ([]byte, error) {
+	return AppendUint32(AppendArrayHeader(nil, 1), s.v), nil
+}
+
+func mustAppendIntf(v interface{}) []byte {
+	b, err := AppendIntf(nil, v)
+	So(err, ShouldBeNil)
+	return b
+}
+
+func TestCanonicalEncoding(t *testing.T) {
+	Convey("Logically identical values should have identical encodings", t, func() {
+		So(mustAppendIntf(testString("node")), ShouldResemble, AppendString(nil, "node"))
+		So(mustAppendIntf(testInt(-5)), ShouldResemble, AppendInt64(nil, -5))
+		So(mustAppendIntf(int8(1)), ShouldResemble, mustAppendIntf(uint64(1)))
+		So(mustAppendIntf(float32(0.5)), ShouldResemble, AppendFloat64(nil, 0.5))
+		So(mustAppendIntf(testArray{1, 2, 3, 4}), ShouldResemble,
+			mustAppendIntf([]interface{}{1, 2, 3, 4}))
+		So(mustAppendIntf(&testArray{1, 2, 3, 4}), ShouldResemble, []byte{0x94, 0x01, 0x02, 0x03, 0x04})
+		So(mustAppendIntf(map[string]string{"b": "2", "a": "1"}), ShouldResemble,
+			mustAppendIntf(map[string]interface{}{"a": "1", "b": "2"}))
+		So(mustAppendIntf(testStruct{v: 3}), ShouldResemble, []byte{0x91, 0x03})
+
+		var (
+			ts  = time.Unix(1546300800, 123)
+			loc = time.FixedZone("UTC+8", 8*3600)
+		)
+		So(mustAppendIntf(ts.In(loc)), ShouldResemble, AppendTime(nil, ts.UTC()))
+		So(mustAppendIntf(&ts), ShouldResemble, AppendTime(nil, ts))
+	})
+	Convey("Types without canonical encoding should be rejected", t, func() {
+		_, err := AppendIntf(nil, map[int]string{1: "a"})
+		So(err, ShouldNotBeNil)
+		_, err = AppendIntf(nil, struct{ A int }{1})
+		So(err, ShouldNotBeNil)
+		_, err = AppendIntf(nil, make(chan int))
+		So(err, ShouldNotBeNil)
+	})
+}
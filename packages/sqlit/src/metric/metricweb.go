@@ -9,6 +9,7 @@ import (
 	"github.com/pkg/errors"
 	mw "github.com/zserge/metric"
 
+	"sqlit/src/diag"
 	"sqlit/src/utils"
 	"sqlit/src/utils/log"
 )
@@ -37,7 +38,7 @@ func collect(cc *CollectClient) (err error) {
 	return
 }
 
-// InitMetricWeb initializes the /debug/metrics web.
+// InitMetricWeb initializes the /debug/metrics web and the /healthz health checks.
 func InitMetricWeb(metricWeb string) (err error) {
 	// Some Go internal metrics
 	expvar.Publish("go:numgoroutine", mw.NewGauge("1m1s", "5m5s", "1h1m"))
@@ -70,6 +71,7 @@ func InitMetricWeb(metricWeb string) (err error) {
 		}
 	}()
 	http.Handle("/debug/metrics", mw.Handler(mw.Exposed))
+	http.Handle("/healthz", diag.HealthHandler())
 	go func() {
 		_ = http.ListenAndServe(metricWeb, nil)
 	}()
@@ -139,6 +139,12 @@ func (c *NAConn) clientHandshake() (err error) {
 
 // Accept takes the ownership of conn and accepts it as a NAConn.
 func Accept(conn net.Conn) (*NAConn, error) {
+	if t := defaultTransport; t != nil {
+		var err error
+		if conn, err = t.Server(conn); err != nil {
+			return nil, err
+		}
+	}
 	naconn := NewServerConn(conn)
 	if err := naconn.Handshake(); err != nil {
 		return nil, err
@@ -186,6 +192,15 @@ func DialEx(remote proto.NodeID, isAnonymous bool) (conn net.Conn, err error) {
 		err = errors.Wrapf(err, "connect to node %s failed", nodeAddr)
 		return
 	}
+	if t := defaultTransport; t != nil {
+		var tconn net.Conn
+		if tconn, err = t.Client(iconn, nodeAddr); err != nil {
+			_ = iconn.Close()
+			err = errors.Wrapf(err, "connect to node %s failed", nodeAddr)
+			return
+		}
+		iconn = tconn
+	}
 
 	naconn := &NAConn{
 		CryptoConn:  etls.NewConn(iconn, cipher),
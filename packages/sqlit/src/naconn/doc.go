@@ -1,6 +1,8 @@
-
 // Package naconn provides node-oriented connection based on ETLS crypto connection.
 //
 // This package requires a node ID resolver to work like a traditional DNS resolver,
-// except that it resolves node IDs into IP addresses (and ports).
+// except that it resolves node IDs into IP addresses (and ports). Resolver implementations
+// can be registered by name and chained with priorities through the NodeResolvers config,
+// so that deployments can mix discovery mechanisms such as the BP DHT, static known nodes,
+// the Jeju registry and DNS seed.
 package naconn
@@ -1,7 +1,33 @@
-
 package naconn
 
-import "sqlit/src/proto"
+import (
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/proto"
+)
+
+// Names of the built-in resolver implementations.
+const (
+	// DHTResolverName is the resolver querying the block producer DHT service.
+	DHTResolverName = "dht"
+	// StaticResolverName is the resolver serving the known nodes in config.
+	StaticResolverName = "static"
+	// JejuRegistryResolverName is the resolver querying the Jeju on-chain node registry.
+	JejuRegistryResolverName = "jeju"
+	// DNSSeedResolverName is the resolver serving block producers found by DNS seed.
+	DNSSeedResolverName = "dnsseed"
+)
+
+var (
+	// ErrUnknownResolver indicates that the resolver name is not registered.
+	ErrUnknownResolver = errors.New("unknown resolver")
+	// ErrNoResolver indicates that there is no resolver to resolve the node ID.
+	ErrNoResolver = errors.New("no resolver available")
+)
 
 // Resolver defines the node ID resolver interface for node-oriented connection.
 type Resolver interface {
@@ -11,9 +37,147 @@ type Resolver interface {
 
 var (
 	defaultResolver Resolver
+
+	namedResolversLock sync.RWMutex
+	namedResolvers     = make(map[string]Resolver)
 )
 
 // RegisterResolver registers the default resolver.
 func RegisterResolver(resolver Resolver) {
 	defaultResolver = resolver
 }
+
+// RegisterNamedResolver registers a resolver implementation by name, so that it can be
+// referenced in config to build a ChainResolver. Registering the same name again overwrites
+// the previous one.
+func RegisterNamedResolver(name string, resolver Resolver) {
+	namedResolversLock.Lock()
+	defer namedResolversLock.Unlock()
+	namedResolvers[name] = resolver
+}
+
+// GetNamedResolver returns the resolver registered with name.
+func GetNamedResolver(name string) (resolver Resolver, ok bool) {
+	namedResolversLock.RLock()
+	defer namedResolversLock.RUnlock()
+	resolver, ok = namedResolvers[name]
+	return
+}
+
+// NamedResolvers returns the sorted names of all registered resolvers.
+func NamedResolvers() (names []string) {
+	namedResolversLock.RLock()
+	defer namedResolversLock.RUnlock()
+	names = make([]string, 0, len(namedResolvers))
+	for name := range namedResolvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return
+}
+
+type chainedResolver struct {
+	name     string
+	priority int
+	resolver Resolver
+}
+
+// ChainResolver tries a list of resolvers in priority order, higher priority first, and returns
+// the first successful result.
+type ChainResolver struct {
+	sync.RWMutex
+	resolvers []*chainedResolver
+}
+
+// NewChainResolver returns a new empty ChainResolver.
+func NewChainResolver() *ChainResolver {
+	return &ChainResolver{}
+}
+
+// NewChainResolverWithConfig returns a ChainResolver built from the named resolvers in config.
+func NewChainResolverWithConfig(infos []conf.ResolverInfo) (c *ChainResolver, err error) {
+	c = NewChainResolver()
+	for _, info := range infos {
+		resolver, ok := GetNamedResolver(info.Name)
+		if !ok {
+			err = errors.Wrapf(ErrUnknownResolver, "resolver %s", info.Name)
+			return
+		}
+		c.Add(info.Name, info.Priority, resolver)
+	}
+	return
+}
+
+// Add appends a resolver with priority to the chain. Resolvers with the same priority are tried
+// in the order they are added.
+func (c *ChainResolver) Add(name string, priority int, resolver Resolver) {
+	c.Lock()
+	defer c.Unlock()
+	c.resolvers = append(c.resolvers, &chainedResolver{
+		name:     name,
+		priority: priority,
+		resolver: resolver,
+	})
+	sort.SliceStable(c.resolvers, func(i, j int) bool {
+		return c.resolvers[i].priority > c.resolvers[j].priority
+	})
+}
+
+// Len returns the count of chained resolvers.
+func (c *ChainResolver) Len() int {
+	c.RLock()
+	defer c.RUnlock()
+	return len(c.resolvers)
+}
+
+func (c *ChainResolver) snapshot() (resolvers []*chainedResolver) {
+	c.RLock()
+	defer c.RUnlock()
+	resolvers = make([]*chainedResolver, len(c.resolvers))
+	copy(resolvers, c.resolvers)
+	return
+}
+
+// Resolve implements Resolver.Resolve.
+func (c *ChainResolver) Resolve(id *proto.RawNodeID) (addr string, err error) {
+	err = ErrNoResolver
+	for _, r := range c.snapshot() {
+		var rerr error
+		if addr, rerr = r.resolver.Resolve(id); rerr == nil {
+			return addr, nil
+		}
+		err = errors.Wrapf(rerr, "resolver %s", r.name)
+	}
+	return
+}
+
+// ResolveEx implements Resolver.ResolveEx.
+func (c *ChainResolver) ResolveEx(id *proto.RawNodeID) (node *proto.Node, err error) {
+	err = ErrNoResolver
+	for _, r := range c.snapshot() {
+		var rerr error
+		if node, rerr = r.resolver.ResolveEx(id); rerr == nil {
+			return node, nil
+		}
+		err = errors.Wrapf(rerr, "resolver %s", r.name)
+	}
+	return
+}
+
+// InitResolvers registers the static resolver with the known nodes in config, and installs a
+// ChainResolver of the configured resolvers as the default resolver if any.
+func InitResolvers(cfg *conf.Config) (err error) {
+	if cfg == nil {
+		return
+	}
+	RegisterNamedResolver(StaticResolverName, NewStaticResolver(cfg.KnownNodes))
+	if len(cfg.NodeResolvers) == 0 {
+		return
+	}
+	var c *ChainResolver
+	if c, err = NewChainResolverWithConfig(cfg.NodeResolvers); err != nil {
+		return
+	}
+	RegisterResolver(c)
+	return
+}
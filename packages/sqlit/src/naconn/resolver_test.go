@@ -0,0 +1,71 @@
+package naconn
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+)
+
+var (
+	testNodeID1 = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000001")
+	testNodeID2 = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000002")
+)
+
+func TestChainResolver(t *testing.T) {
+	Convey("Given two static resolvers with overlapping nodes", t, func() {
+		var (
+			low = NewStaticResolver([]proto.Node{
+				{ID: testNodeID1, Addr: "low:1"},
+				{ID: testNodeID2, Addr: "low:2"},
+			})
+			high = NewStaticResolver([]proto.Node{
+				{ID: testNodeID1, Addr: "high:1"},
+			})
+			c = NewChainResolver()
+		)
+		Convey("An empty chain should fail to resolve", func() {
+			_, err := c.Resolve(testNodeID1.ToRawNodeID())
+			So(err, ShouldEqual, ErrNoResolver)
+			_, err = c.ResolveEx(testNodeID1.ToRawNodeID())
+			So(err, ShouldEqual, ErrNoResolver)
+		})
+		Convey("The resolver with higher priority should be tried first", func() {
+			c.Add("low", 1, low)
+			c.Add("high", 10, high)
+			So(c.Len(), ShouldEqual, 2)
+
+			addr, err := c.Resolve(testNodeID1.ToRawNodeID())
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "high:1")
+
+			node, err := c.ResolveEx(testNodeID2.ToRawNodeID())
+			So(err, ShouldBeNil)
+			So(node.Addr, ShouldEqual, "low:2")
+
+			_, err = c.Resolve((&proto.RawNodeID{}))
+			So(errors.Cause(err), ShouldEqual, route.ErrUnknownNodeID)
+		})
+		Convey("The chain should be built from registered resolvers", func() {
+			RegisterNamedResolver("test-low", low)
+			RegisterNamedResolver("test-high", high)
+			So(NamedResolvers(), ShouldContain, "test-low")
+
+			c, err := NewChainResolverWithConfig([]conf.ResolverInfo{
+				{Name: "test-low", Priority: 1},
+				{Name: "test-high", Priority: 2},
+			})
+			So(err, ShouldBeNil)
+			addr, err := c.Resolve(testNodeID1.ToRawNodeID())
+			So(err, ShouldBeNil)
+			So(addr, ShouldEqual, "high:1")
+
+			_, err = NewChainResolverWithConfig([]conf.ResolverInfo{{Name: "not-exists"}})
+			So(errors.Cause(err), ShouldEqual, ErrUnknownResolver)
+		})
+	})
+}
@@ -0,0 +1,72 @@
+package naconn
+
+import (
+	"sync"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+)
+
+// StaticResolver resolves node IDs from a fixed node list, such as the known nodes in config.
+type StaticResolver struct {
+	nodes sync.Map // proto.RawNodeID -> *proto.Node
+}
+
+// NewStaticResolver returns a new StaticResolver serving nodes.
+func NewStaticResolver(nodes []proto.Node) *StaticResolver {
+	r := &StaticResolver{}
+	for i := range nodes {
+		r.SetNode(&nodes[i])
+	}
+	return r
+}
+
+// SetNode adds or replaces a node in the resolver.
+func (r *StaticResolver) SetNode(node *proto.Node) {
+	if rawID := node.ID.ToRawNodeID(); rawID != nil {
+		r.nodes.Store(*rawID, node)
+	}
+}
+
+// Resolve implements Resolver.Resolve.
+func (r *StaticResolver) Resolve(id *proto.RawNodeID) (addr string, err error) {
+	var node *proto.Node
+	if node, err = r.ResolveEx(id); err != nil {
+		return
+	}
+	addr = node.Addr
+	return
+}
+
+// ResolveEx implements Resolver.ResolveEx.
+func (r *StaticResolver) ResolveEx(id *proto.RawNodeID) (*proto.Node, error) {
+	if id == nil {
+		return nil, route.ErrNilNodeID
+	}
+	if node, ok := r.nodes.Load(*id); ok {
+		return node.(*proto.Node), nil
+	}
+	return nil, route.ErrUnknownNodeID
+}
+
+// DNSSeedResolver resolves block producer node IDs found by DNS seed on startup.
+type DNSSeedResolver struct{}
+
+// Resolve implements Resolver.Resolve.
+func (r *DNSSeedResolver) Resolve(id *proto.RawNodeID) (addr string, err error) {
+	var node *proto.Node
+	if node, err = route.GetSeedBPNode(id); err != nil {
+		return
+	}
+	addr = node.Addr
+	return
+}
+
+// ResolveEx implements Resolver.ResolveEx.
+func (r *DNSSeedResolver) ResolveEx(id *proto.RawNodeID) (*proto.Node, error) {
+	return route.GetSeedBPNode(id)
+}
+
+func init() {
+	RegisterNamedResolver(DNSSeedResolverName, &DNSSeedResolver{})
+}
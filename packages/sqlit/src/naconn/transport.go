@@ -0,0 +1,34 @@
+package naconn
+
+import (
+	"net"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/mtls"
+)
+
+// Transport wraps the raw connections under the ETLS layer, such as with mutual TLS.
+type Transport interface {
+	Client(conn net.Conn, addr string) (net.Conn, error)
+	Server(conn net.Conn) (net.Conn, error)
+}
+
+var defaultTransport Transport
+
+// RegisterTransport registers the default transport, nil means the raw tcp connections.
+func RegisterTransport(t Transport) {
+	defaultTransport = t
+}
+
+// InitTransport installs the mutual TLS transport of the config if any.
+func InitTransport(cfg *conf.Config) (err error) {
+	if cfg == nil || cfg.MTLS == nil {
+		return
+	}
+	var t *mtls.Transport
+	if t, err = mtls.NewTransport(cfg.MTLS); err != nil {
+		return
+	}
+	RegisterTransport(t)
+	return
+}
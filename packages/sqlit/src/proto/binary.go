@@ -13,7 +13,7 @@
 //	├─────────────────────────────────────────────────────────────┤
 //	│ Body (variable)                                             │
 //	├─────────────────────────────────────────────────────────────┤
-//	│ BodyLength: uint32                                          │
+//	│ BodyLength: uint32 (upper bound of the remaining body)      │
 //	│ DatabaseID: string (length-prefixed)                        │
 //	│ SQL: string (length-prefixed)                               │
 //	│ BindingCount: uint16                                        │
@@ -36,6 +36,7 @@
 package proto
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"io"
@@ -123,15 +124,70 @@ type Response struct {
 
 // Errors
 var (
-	ErrInvalidMagic    = errors.New("invalid magic number")
-	ErrInvalidVersion  = errors.New("unsupported protocol version")
-	ErrInvalidMessage  = errors.New("invalid message format")
-	ErrMessageTooLarge = errors.New("message exceeds maximum size")
+	ErrInvalidMagic     = errors.New("invalid magic number")
+	ErrInvalidVersion   = errors.New("unsupported protocol version")
+	ErrInvalidMessage   = errors.New("invalid message format")
+	ErrMessageTooLarge  = errors.New("message exceeds maximum size")
+	ErrUnsupportedValue = errors.New("unsupported value type")
 )
 
 // MaxMessageSize is the maximum allowed message size (16MB)
 const MaxMessageSize = 16 * 1024 * 1024
 
+// readChunkSize is the max size allocated up front for a length-prefixed field, larger fields
+// grow with the bytes actually received so that a forged length cannot force a large allocation.
+const readChunkSize = 64 * 1024
+
+// readBytes reads a field of length bytes.
+func readBytes(r io.Reader, length uint32) ([]byte, error) {
+	if length > MaxMessageSize {
+		return nil, ErrMessageTooLarge
+	}
+	if b, ok := r.(*bodyReader); ok && int64(length) > b.N {
+		return nil, ErrInvalidMessage
+	}
+	if length <= readChunkSize {
+		buf := make([]byte, length)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		return buf, nil
+	}
+	var buf bytes.Buffer
+	buf.Grow(readChunkSize)
+	if n, err := io.CopyN(&buf, r, int64(length)); err != nil {
+		if err == io.EOF && n < int64(length) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unexpectedEOF converts io.EOF in the middle of a message to io.ErrUnexpectedEOF, so that it's
+// not taken as a closed connection.
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// bodyReader reads the body of a request and rejects fields exceeding the body length.
+type bodyReader struct {
+	io.LimitedReader
+}
+
+func (b *bodyReader) wrap(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		if b.N == 0 {
+			return ErrInvalidMessage
+		}
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
 // ReadHeader reads a message header from the reader
 func ReadHeader(r io.Reader) (*Header, error) {
 	buf := make([]byte, HeaderSize)
@@ -178,16 +234,12 @@ func ReadString(r io.Reader) (string, error) {
 	}
 	length := binary.LittleEndian.Uint32(lenBuf)
 
-	if length > MaxMessageSize {
-		return "", ErrMessageTooLarge
-	}
-
 	if length == 0 {
 		return "", nil
 	}
 
-	strBuf := make([]byte, length)
-	if _, err := io.ReadFull(r, strBuf); err != nil {
+	strBuf, err := readBytes(r, length)
+	if err != nil {
 		return "", err
 	}
 
@@ -223,17 +275,13 @@ func ReadValue(r io.Reader) (*Value, error) {
 
 	lenBuf := make([]byte, 4)
 	if _, err := io.ReadFull(r, lenBuf); err != nil {
-		return nil, err
+		return nil, unexpectedEOF(err)
 	}
 	length := binary.LittleEndian.Uint32(lenBuf)
 
-	if length > MaxMessageSize {
-		return nil, ErrMessageTooLarge
-	}
-
 	if length > 0 {
-		v.Data = make([]byte, length)
-		if _, err := io.ReadFull(r, v.Data); err != nil {
+		var err error
+		if v.Data, err = readBytes(r, length); err != nil {
 			return nil, err
 		}
 	}
@@ -265,7 +313,8 @@ func WriteValue(w io.Writer, v *Value) error {
 	return nil
 }
 
-// ReadRequest reads a complete request from the reader
+// ReadRequest reads a complete request from the reader. The body length is taken as an upper
+// bound of the body, fields exceeding it are rejected with ErrInvalidMessage.
 func ReadRequest(r io.Reader) (*Request, error) {
 	h, err := ReadHeader(r)
 	if err != nil {
@@ -277,39 +326,45 @@ func ReadRequest(r io.Reader) (*Request, error) {
 	// Read body length
 	lenBuf := make([]byte, 4)
 	if _, err := io.ReadFull(r, lenBuf); err != nil {
-		return nil, err
+		return nil, unexpectedEOF(err)
 	}
 	bodyLen := binary.LittleEndian.Uint32(lenBuf)
 
 	if bodyLen > MaxMessageSize {
 		return nil, ErrMessageTooLarge
 	}
+	body := &bodyReader{io.LimitedReader{R: r, N: int64(bodyLen)}}
 
 	// Read database ID
-	req.DatabaseID, err = ReadString(r)
+	req.DatabaseID, err = ReadString(body)
 	if err != nil {
-		return nil, err
+		return nil, body.wrap(err)
 	}
 
 	// Read SQL
-	req.SQL, err = ReadString(r)
+	req.SQL, err = ReadString(body)
 	if err != nil {
-		return nil, err
+		return nil, body.wrap(err)
 	}
 
 	// Read binding count
 	countBuf := make([]byte, 2)
-	if _, err := io.ReadFull(r, countBuf); err != nil {
-		return nil, err
+	if _, err := io.ReadFull(body, countBuf); err != nil {
+		return nil, body.wrap(err)
 	}
 	bindingCount := binary.LittleEndian.Uint16(countBuf)
 
+	// Each binding takes at least 1 byte
+	if int64(bindingCount) > body.N {
+		return nil, ErrInvalidMessage
+	}
+
 	// Read bindings
 	req.Bindings = make([]Value, bindingCount)
 	for i := uint16(0); i < bindingCount; i++ {
-		v, err := ReadValue(r)
+		v, err := ReadValue(body)
 		if err != nil {
-			return nil, err
+			return nil, body.wrap(err)
 		}
 		req.Bindings[i] = *v
 	}
@@ -319,19 +374,29 @@ func ReadRequest(r io.Reader) (*Request, error) {
 
 // WriteRequest writes a complete request to the writer
 func WriteRequest(w io.Writer, req *Request) error {
-	if err := WriteHeader(w, &req.Header); err != nil {
-		return err
+	if len(req.Bindings) > math.MaxUint16 {
+		return ErrMessageTooLarge
 	}
 
-	// Calculate body length (approximate, we'll write it properly)
-	bodyLen := uint32(4 + len(req.DatabaseID) + 4 + len(req.SQL) + 2)
+	// Calculate body length
+	bodyLen := uint64(4 + len(req.DatabaseID) + 4 + len(req.SQL) + 2)
 	for _, v := range req.Bindings {
-		bodyLen += 1 + 4 + uint32(len(v.Data))
+		bodyLen++
+		if v.Type != ValueNull {
+			bodyLen += 4 + uint64(len(v.Data))
+		}
+	}
+	if bodyLen > MaxMessageSize {
+		return ErrMessageTooLarge
+	}
+
+	if err := WriteHeader(w, &req.Header); err != nil {
+		return err
 	}
 
 	// Write body length
 	lenBuf := make([]byte, 4)
-	binary.LittleEndian.PutUint32(lenBuf, bodyLen)
+	binary.LittleEndian.PutUint32(lenBuf, uint32(bodyLen))
 	if _, err := w.Write(lenBuf); err != nil {
 		return err
 	}
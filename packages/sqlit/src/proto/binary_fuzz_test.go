@@ -0,0 +1,107 @@
+package proto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func fuzzRequestSeeds() [][]byte {
+	var seeds [][]byte
+	for _, req := range []*Request{
+		{Header: Header{Version: ProtocolVersion, Type: TypePing}},
+		{
+			Header:     Header{Version: ProtocolVersion, Type: TypeQuery, Flags: FlagStreaming, RequestID: 1},
+			DatabaseID: "db",
+			SQL:        "SELECT * FROM t WHERE a = ? AND b = ?",
+			Bindings: []Value{
+				ValueFromInt64(1), ValueFromFloat64(0.5), ValueFromString("s"),
+				ValueFromBlob([]byte{0xde, 0xad}), ValueFromBool(true), ValueNullV(),
+			},
+		},
+	} {
+		var buf bytes.Buffer
+		if err := WriteRequest(&buf, req); err != nil {
+			panic(err)
+		}
+		seeds = append(seeds, buf.Bytes())
+	}
+
+	// forged lengths: a huge string length and a huge binding count in a tiny body
+	var hdr bytes.Buffer
+	_ = WriteHeader(&hdr, &Header{Version: ProtocolVersion, Type: TypeExec})
+	forged := append([]byte{}, hdr.Bytes()...)
+	forged = binary.LittleEndian.AppendUint32(forged, 8)
+	forged = binary.LittleEndian.AppendUint32(forged, MaxMessageSize)
+	seeds = append(seeds, forged)
+	forged = append([]byte{}, hdr.Bytes()...)
+	forged = binary.LittleEndian.AppendUint32(forged, 10)
+	forged = binary.LittleEndian.AppendUint32(forged, 0)
+	forged = binary.LittleEndian.AppendUint32(forged, 0)
+	forged = binary.LittleEndian.AppendUint16(forged, 0xffff)
+	seeds = append(seeds, forged)
+	return seeds
+}
+
+func FuzzReadRequest(f *testing.F) {
+	for _, seed := range fuzzRequestSeeds() {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		req, err := ReadRequest(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		// a decoded request should survive a round trip
+		var buf bytes.Buffer
+		if err = WriteRequest(&buf, req); err != nil {
+			t.Fatalf("write decoded request failed: %v", err)
+		}
+		decoded, err := ReadRequest(&buf)
+		if err != nil {
+			t.Fatalf("read re-encoded request failed: %v", err)
+		}
+		if !reflect.DeepEqual(normalizeRequest(req), normalizeRequest(decoded)) {
+			t.Fatalf("round trip mismatch: %+v != %+v", req, decoded)
+		}
+	})
+}
+
+// normalizeRequest clears the fields not preserved by the encoding.
+func normalizeRequest(req *Request) *Request {
+	r := *req
+	r.Magic = MagicNumber
+	r.Bindings = make([]Value, len(req.Bindings))
+	for i, v := range req.Bindings {
+		if len(v.Data) == 0 {
+			v.Data = nil
+		}
+		r.Bindings[i] = v
+	}
+	return &r
+}
+
+func FuzzReadValue(f *testing.F) {
+	for _, v := range []Value{
+		ValueFromInt64(-1), ValueFromFloat64(1.5), ValueFromString("value"),
+		ValueFromBlob(nil), ValueFromBool(false), ValueNullV(),
+	} {
+		var buf bytes.Buffer
+		_ = WriteValue(&buf, &v)
+		f.Add(buf.Bytes())
+	}
+	f.Add([]byte{ValueBlob, 0xff, 0xff, 0xff, 0x00})
+	f.Add([]byte{ValueString, 0xff, 0xff, 0xff, 0xff})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v, err := ReadValue(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if len(v.Data) > len(data) {
+			t.Fatalf("value data exceeds input: %d > %d", len(v.Data), len(data))
+		}
+		// accessors must not panic on any decoded value
+		_, _, _, _, _, _ = v.AsInt64(), v.AsFloat64(), v.AsString(), v.AsBlob(), v.AsBool(), v.IsNull()
+	})
+}
@@ -2,7 +2,12 @@ package proto
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
 	"testing"
+	"time"
 )
 
 func TestMagicNumber(t *testing.T) {
@@ -121,6 +126,47 @@ func TestReadWriteValue(t *testing.T) {
 	}
 }
 
+func TestInterfaceToValue(t *testing.T) {
+	var (
+		loc = time.FixedZone("UTC+8", 8*3600)
+		ts  = time.Date(2019, 1, 1, 8, 0, 0, 500, loc)
+		// a NaN with a non-default payload, as produced by some floating point units
+		nan = math.Float64frombits(0x7ff8000000000123)
+	)
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected Value
+	}{
+		{"nil", nil, ValueNullV()},
+		{"int8", int8(-1), ValueFromInt64(-1)},
+		{"uint32", uint32(math.MaxUint32), ValueFromInt64(math.MaxUint32)},
+		{"uint64", uint64(math.MaxInt64), ValueFromInt64(math.MaxInt64)},
+		{"float32", float32(0.5), ValueFromFloat64(0.5)},
+		{"negative_zero", math.Copysign(0, -1), Value{Type: ValueFloat64, Data: []byte{0, 0, 0, 0, 0, 0, 0, 0x80}}},
+		{"nan", nan, Value{Type: ValueFloat64, Data: []byte{1, 0, 0, 0, 0, 0, 0xf8, 0x7f}}},
+		{"time", ts, ValueFromString("2019-01-01 00:00:00.0000005+00:00")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := interfaceToValue(tt.value)
+			if err != nil {
+				t.Fatalf("interfaceToValue failed: %v", err)
+			}
+			if v.Type != tt.expected.Type || !bytes.Equal(v.Data, tt.expected.Data) {
+				t.Errorf("value mismatch: expected %v, got %v", tt.expected, v)
+			}
+		})
+	}
+
+	for _, v := range []interface{}{uint64(math.MaxUint64), struct{}{}, []string{"a"}} {
+		if _, err := interfaceToValue(v); !errors.Is(err, ErrUnsupportedValue) {
+			t.Errorf("expected unsupported value error for %T, got %v", v, err)
+		}
+	}
+}
+
 func TestValueConversions(t *testing.T) {
 	// Int64
 	intVal := ValueFromInt64(-9223372036854775808)
@@ -278,6 +324,47 @@ func TestTruncatedHeader(t *testing.T) {
 	}
 }
 
+func TestForgedLengths(t *testing.T) {
+	seeds := fuzzRequestSeeds()
+	for i, data := range seeds[len(seeds)-2:] {
+		if _, err := ReadRequest(bytes.NewReader(data)); err != ErrInvalidMessage {
+			t.Errorf("forged request %d: expected ErrInvalidMessage, got %v", i, err)
+		}
+	}
+
+	// a truncated value must not allocate its declared length
+	buf := []byte{ValueBlob, 0x00, 0x00, 0x00, 0x01, 0x01}
+	allocs := testing.AllocsPerRun(10, func() {
+		if _, err := ReadValue(bytes.NewReader(buf)); err != io.ErrUnexpectedEOF {
+			t.Errorf("expected io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+	if allocs > 10 {
+		t.Errorf("unexpected %v allocations", allocs)
+	}
+}
+
+func TestWriteRequestBodyLength(t *testing.T) {
+	req := &Request{
+		Header:   Header{Version: ProtocolVersion, Type: TypeExec},
+		SQL:      "INSERT INTO t VALUES (?, ?)",
+		Bindings: []Value{ValueNullV(), ValueFromInt64(1)},
+	}
+	var buf bytes.Buffer
+	if err := WriteRequest(&buf, req); err != nil {
+		t.Fatalf("WriteRequest failed: %v", err)
+	}
+	bodyLen := binary.LittleEndian.Uint32(buf.Bytes()[HeaderSize:])
+	if int(bodyLen) != buf.Len()-HeaderSize-4 {
+		t.Errorf("expected body length %d, got %d", buf.Len()-HeaderSize-4, bodyLen)
+	}
+
+	req.Bindings = make([]Value, math.MaxUint16+1)
+	if err := WriteRequest(&bytes.Buffer{}, req); err != ErrMessageTooLarge {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
 func BenchmarkWriteRequest(b *testing.B) {
 	req := &Request{
 		Header: Header{
@@ -0,0 +1,74 @@
+package proto
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+const conformanceDatabase = "conformance"
+
+type memoryProvider struct {
+	db *sql.DB
+}
+
+func (p *memoryProvider) GetDatabase(dbID string) (*sql.DB, error) {
+	if dbID != conformanceDatabase {
+		return nil, fmt.Errorf("unknown database: %s", dbID)
+	}
+	return p.db, nil
+}
+
+// TestDBAPIConformance runs the DB-API conformance tests of the Python driver in
+// packages/sqlit/python against a server over an in-memory database.
+func TestDBAPIConformance(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not found")
+	}
+	pkgDir, err := filepath.Abs(filepath.Join("..", "..", "python"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(pkgDir, "sqlit", "__init__.py")); err != nil {
+		t.Skipf("python driver not found: %v", err)
+	}
+
+	db, err := sql.Open("sqlite3", "file::memory:?cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	// a single connection keeps the in-memory database alive
+	db.SetMaxOpenConns(1)
+
+	s := NewServer(&ServerConfig{
+		ListenAddr:     "127.0.0.1:0",
+		MaxConnections: 16,
+		ReadTimeout:    30 * time.Second,
+		WriteTimeout:   30 * time.Second,
+	}, &memoryProvider{db: db})
+	if err = s.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Stop()
+
+	cmd := exec.Command(python, "-m", "unittest", "discover", "-v", "-s", "tests")
+	cmd.Dir = pkgDir
+	cmd.Env = append(os.Environ(),
+		"SQLIT_BINARY_ADDR="+s.listener.Addr().String(),
+		"SQLIT_DATABASE="+conformanceDatabase,
+		"PYTHONDONTWRITEBYTECODE=1",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("python tests failed: %v\n%s", err, out)
+	}
+	t.Logf("%s", out)
+}
@@ -6,7 +6,7 @@ import (
 
 // MarshalHash marshals PeersHeader for hash computation
 func (ph *PeersHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, ph.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 4)
 	b = marshalhash.AppendUint64(b, ph.Version)
 	b = marshalhash.AppendUint64(b, ph.Term)
@@ -19,12 +19,18 @@ func (ph *PeersHeader) MarshalHash() ([]byte, error) {
 	return b, nil
 }
 
-// Msgsize returns the estimated size for msgpack encoding
-func (ph *PeersHeader) Msgsize() int { return 256 }
+// Msgsize returns an upper bound of the hash encoding size of PeersHeader.
+func (ph *PeersHeader) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + 2*marshalhash.Uint64Size + marshalhash.StringSize(string(ph.Leader))
+	for _, server := range ph.Servers {
+		s += marshalhash.StringSize(string(server))
+	}
+	return
+}
 
 // MarshalHash marshals Peers for hash computation
 func (p *Peers) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	b := make([]byte, 0, p.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// PeersHeader
 	hdrBytes, err := p.PeersHeader.MarshalHash()
@@ -41,5 +47,7 @@ func (p *Peers) MarshalHash() ([]byte, error) {
 	return b, nil
 }
 
-// Msgsize returns the estimated size for msgpack encoding
-func (p *Peers) Msgsize() int { return 512 }
+// Msgsize returns an upper bound of the hash encoding size of Peers.
+func (p *Peers) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + p.PeersHeader.Msgsize() + p.DefaultHashSignVerifierImpl.Msgsize()
+}
@@ -42,12 +42,16 @@ type Envelope struct {
 // PingReq is Ping RPC request.
 type PingReq struct {
 	Node Node
+	// Features are the protocol features supported by the node
+	Features []string
 	Envelope
 }
 
 // PingResp is Ping RPC response, i.e. Pong.
 type PingResp struct {
 	Msg string
+	// Features are the protocol features supported by the block producer
+	Features []string
 	Envelope
 }
 
@@ -10,12 +10,14 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"sqlit/src/utils/log"
+	"sqlit/src/utils/proxyproto"
 )
 
 // ServerConfig holds server configuration
@@ -34,6 +36,9 @@ type ServerConfig struct {
 
 	// IdleTimeout is the timeout for idle connections
 	IdleTimeout time.Duration
+
+	// ProxyProtocol requires the PROXY protocol v2 header on the connections
+	ProxyProtocol bool
 }
 
 // DefaultServerConfig returns a default server configuration
@@ -87,7 +92,7 @@ func (s *Server) Start() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", s.config.ListenAddr, err)
 	}
-	s.listener = l
+	s.listener = proxyproto.Wrap(l, s.config.ProxyProtocol)
 
 	log.WithField("addr", s.config.ListenAddr).Info("binary protocol server started")
 
@@ -301,7 +306,11 @@ func (s *Server) streamRows(conn net.Conn, req *Request, rows *sql.Rows, columns
 		// Send row
 		buf.Reset()
 		for _, v := range values {
-			val := interfaceToValue(v)
+			val, err := interfaceToValue(v)
+			if err != nil {
+				WriteErrorResponse(conn, req.RequestID, err.Error())
+				return
+			}
 			WriteValue(&buf, &val)
 		}
 		conn.Write(buf.Bytes())
@@ -331,7 +340,11 @@ func (s *Server) sendAllRows(conn net.Conn, req *Request, rows *sql.Rows, column
 
 		row := make([]Value, len(columns))
 		for i, v := range values {
-			row[i] = interfaceToValue(v)
+			var err error
+			if row[i], err = interfaceToValue(v); err != nil {
+				WriteErrorResponse(conn, req.RequestID, err.Error())
+				return
+			}
 		}
 		allRows = append(allRows, row)
 	}
@@ -428,31 +441,64 @@ func bindingToInterface(v *Value) interface{} {
 	}
 }
 
-// interfaceToValue converts an interface{} to Value
-func interfaceToValue(v interface{}) Value {
+// valueTimeFormat is the layout of the time values sent as strings, it's the first layout of the
+// sqlite3 driver so that a time value reads back unchanged when bound to a query.
+const valueTimeFormat = "2006-01-02 15:04:05.999999999-07:00"
+
+// interfaceToValue converts an interface{} scanned from sqlite to Value. Every supported type has
+// an explicit encoding which doesn't depend on the platform or the server location: times are
+// formatted in UTC and all NaNs share one bit pattern.
+func interfaceToValue(v interface{}) (Value, error) {
 	if v == nil {
-		return ValueNullV()
+		return ValueNullV(), nil
 	}
 
 	switch val := v.(type) {
 	case int64:
-		return ValueFromInt64(val)
+		return ValueFromInt64(val), nil
+	case int:
+		return ValueFromInt64(int64(val)), nil
+	case int32:
+		return ValueFromInt64(int64(val)), nil
+	case int16:
+		return ValueFromInt64(int64(val)), nil
+	case int8:
+		return ValueFromInt64(int64(val)), nil
+	case uint32:
+		return ValueFromInt64(int64(val)), nil
+	case uint16:
+		return ValueFromInt64(int64(val)), nil
+	case uint8:
+		return ValueFromInt64(int64(val)), nil
+	case uint64:
+		if val > math.MaxInt64 {
+			return Value{}, fmt.Errorf("%w: uint64 %d overflows int64", ErrUnsupportedValue, val)
+		}
+		return ValueFromInt64(int64(val)), nil
 	case float64:
-		return ValueFromFloat64(val)
+		return ValueFromFloat64(canonicalFloat(val)), nil
+	case float32:
+		return ValueFromFloat64(canonicalFloat(float64(val))), nil
 	case string:
-		return ValueFromString(val)
+		return ValueFromString(val), nil
 	case []byte:
-		return ValueFromBlob(val)
+		return ValueFromBlob(val), nil
 	case bool:
-		return ValueFromBool(val)
-	case int:
-		return ValueFromInt64(int64(val))
-	case int32:
-		return ValueFromInt64(int64(val))
+		return ValueFromBool(val), nil
+	case time.Time:
+		return ValueFromString(val.UTC().Format(valueTimeFormat)), nil
 	default:
-		// Convert to string for unknown types
-		return ValueFromString(fmt.Sprintf("%v", val))
+		return Value{}, fmt.Errorf("%w: %T", ErrUnsupportedValue, v)
+	}
+}
+
+// canonicalFloat returns v with the payload bits of a NaN dropped, which differ between the
+// floating point units.
+func canonicalFloat(v float64) float64 {
+	if math.IsNaN(v) {
+		return math.NaN()
 	}
+	return v
 }
 
 // Stats returns server statistics
@@ -33,7 +33,7 @@ func (p *Peers) Clone() (copy Peers) {
 }
 
 // Sign generates signature.
-func (p *Peers) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (p *Peers) Sign(signer asymmetric.Signer) (err error) {
 	return p.DefaultHashSignVerifierImpl.Sign(&p.PeersHeader, signer)
 }
 
@@ -56,3 +56,16 @@ func (p *Peers) Find(key NodeID) (index int32, found bool) {
 
 	return
 }
+
+// SameServers returns whether p has the same servers in the same order as other.
+func (p *Peers) SameServers(other *Peers) bool {
+	if len(p.Servers) != len(other.Servers) {
+		return false
+	}
+	for i, s := range p.Servers {
+		if s != other.Servers[i] {
+			return false
+		}
+	}
+	return true
+}
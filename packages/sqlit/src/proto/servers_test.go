@@ -48,6 +48,13 @@ func TestPeers(t *testing.T) {
 		i, found = peers.Find(NodeID("0000000000000000000000000000000000000000000000000000000000000001"))
 		So(found, ShouldBeFalse)
 
+		So(peers.SameServers(p), ShouldBeTrue)
+		peers3 := peers.Clone()
+		peers3.Servers[0], peers3.Servers[1] = peers3.Servers[1], peers3.Servers[0]
+		So(peers.SameServers(&peers3), ShouldBeFalse)
+		peers3.Servers = peers3.Servers[:1]
+		So(peers.SameServers(&peers3), ShouldBeFalse)
+
 		// verify hash failed
 		peers.Term = 2
 		err = peers.Verify()
@@ -0,0 +1,451 @@
+/**
+ * SQLit Proxy Client
+ *
+ * TypeScript client of the sqlit-proxy project user API, which follows
+ * the OpenAPI document served at /v3/openapi.yaml
+ * (src/cmd/sqlit-proxy/api/openapi.yaml). The queries are checked against
+ * the table rules of the project by the proxy.
+ */
+
+import { SQLitError, SQLitErrorCode } from './types'
+
+export type ProxyValue = string | number | boolean | null
+
+export type ProxyRow = Record<string, ProxyValue>
+
+export interface FieldOperators {
+  $eq?: ProxyValue
+  $ne?: ProxyValue
+  $gt?: ProxyValue
+  $gte?: ProxyValue
+  $lt?: ProxyValue
+  $lte?: ProxyValue
+  $in?: ProxyValue[]
+  $nin?: ProxyValue[]
+  $not?: FieldOperators
+}
+
+/** MongoDB style filter of the fields */
+export type ProxyFilter = {
+  $and?: ProxyFilter[]
+  $or?: ProxyFilter[]
+  $nor?: ProxyFilter[]
+} & {
+  [field: string]: ProxyValue | FieldOperators | ProxyFilter[] | undefined
+}
+
+/** Plain field values or the update operators */
+export type ProxyUpdate =
+  | Record<string, ProxyValue>
+  | {
+      $set?: Record<string, ProxyValue>
+      $inc?: Record<string, number>
+      $mul?: Record<string, number>
+      $min?: Record<string, ProxyValue>
+      $max?: Record<string, ProxyValue>
+      $currentDate?: Record<
+        string,
+        true | { $type: 'date' | 'timestamp' | 'datetime' }
+      >
+    }
+
+export interface FindOptions<T extends object = ProxyRow> {
+  filter?: ProxyFilter
+  /** Fields returned, all the fields by default */
+  projection?: Partial<Record<keyof T & string, 0 | 1>>
+  /**
+   * Sort directions, the order of multiple fields is not preserved by
+   * the proxy
+   */
+  order?: Partial<Record<keyof T & string, 1 | -1>>
+  skip?: number
+  limit?: number
+}
+
+export interface PaginateOptions<T extends object = ProxyRow>
+  extends Omit<FindOptions<T>, 'skip' | 'limit'> {
+  /** Rows of a page, 100 by default */
+  pageSize?: number
+}
+
+export interface ExecResult {
+  affectedRows: number
+  lastInsertId?: number
+}
+
+export type WriteOp =
+  | { op: 'insert'; table: string; data: ProxyRow }
+  | {
+      op: 'update'
+      table: string
+      filter?: ProxyFilter
+      update: ProxyUpdate
+      one?: boolean
+    }
+  | { op: 'remove'; table: string; filter?: ProxyFilter; one?: boolean }
+
+export interface ProxyUserInfo {
+  name: string
+  email: string
+  extra?: Record<string, unknown>
+  provider: string
+  provide_id: string
+}
+
+export interface GraphQLResult<T> {
+  data?: T | null
+  errors?: Array<{ message: string }>
+}
+
+export interface RetryConfig {
+  /** Retries after the first attempt, 3 by default */
+  retries?: number
+  /** Delay of the first retry in milliseconds, 200 by default */
+  minDelayMs?: number
+  /** Maximum delay in milliseconds, 5000 by default */
+  maxDelayMs?: number
+  /**
+   * Retry the writes too, which may apply a write twice if the response
+   * is lost, false by default
+   */
+  retryWrites?: boolean
+}
+
+export interface SQLitProxyClientConfig {
+  /** Proxy URL, like https://proxy.example.com */
+  endpoint: string
+  /** Project database id, not needed with a project host alias */
+  project?: string
+  /** Session token of the logged in user */
+  token?: string
+  /** Request timeout in milliseconds */
+  timeoutMs?: number
+  retry?: RetryConfig
+  /** fetch implementation, globalThis.fetch by default */
+  fetch?: typeof fetch
+}
+
+interface ProxyResponse<T> {
+  success: boolean
+  msg: string
+  data?: T
+}
+
+const RETRY_STATUS = new Set([429, 502, 503, 504])
+
+/**
+ * SQLit Proxy Client
+ */
+export class SQLitProxyClient {
+  private config: SQLitProxyClientConfig & {
+    timeoutMs: number
+    retry: Required<RetryConfig>
+  }
+
+  constructor(config: SQLitProxyClientConfig) {
+    this.config = {
+      ...config,
+      endpoint: config.endpoint.replace(/\/+$/, ''),
+      timeoutMs: config.timeoutMs ?? 30000,
+      retry: {
+        retries: 3,
+        minDelayMs: 200,
+        maxDelayMs: 5000,
+        retryWrites: false,
+        ...config.retry,
+      },
+    }
+  }
+
+  /** Set the session token, undefined for the anonymous requests */
+  setToken(token: string | undefined): void {
+    this.config.token = token
+  }
+
+  // ============ Query API ============
+
+  /**
+   * Find the rows of a table
+   */
+  async query<T extends object = ProxyRow>(
+    table: string,
+    options: FindOptions<T> = {},
+  ): Promise<T[]> {
+    const rows = await this.request<T[] | null>(
+      `/data/${encodeURIComponent(table)}/find`,
+      options,
+      true,
+    )
+    return rows ?? []
+  }
+
+  /**
+   * Find the first row of a table
+   */
+  async queryOne<T extends object = ProxyRow>(
+    table: string,
+    options: Omit<FindOptions<T>, 'limit'> = {},
+  ): Promise<T | null> {
+    const rows = await this.query<T>(table, { ...options, limit: 1 })
+    return rows[0] ?? null
+  }
+
+  /**
+   * Iterate over the pages of the rows by skip and limit. Sort by a single
+   * unique field for stable pages, the rows changed during the iteration
+   * may be skipped or repeated.
+   */
+  async *paginate<T extends object = ProxyRow>(
+    table: string,
+    options: PaginateOptions<T> = {},
+  ): AsyncGenerator<T[]> {
+    const { pageSize = 100, ...findOptions } = options
+    if (!Number.isInteger(pageSize) || pageSize <= 0) {
+      throw new SQLitError(
+        'pageSize must be a positive integer',
+        'ERR_INVALID_PAGE_SIZE',
+      )
+    }
+    for (let skip = 0; ; skip += pageSize) {
+      const rows = await this.query<T>(table, {
+        ...findOptions,
+        skip,
+        limit: pageSize,
+      })
+      if (rows.length > 0) {
+        yield rows
+      }
+      if (rows.length < pageSize) {
+        return
+      }
+    }
+  }
+
+  /**
+   * Find all the rows of a table page by page
+   */
+  async queryAll<T extends object = ProxyRow>(
+    table: string,
+    options: PaginateOptions<T> = {},
+  ): Promise<T[]> {
+    const all: T[] = []
+    for await (const rows of this.paginate<T>(table, options)) {
+      all.push(...rows)
+    }
+    return all
+  }
+
+  /**
+   * Count the rows of a table
+   */
+  async count(table: string, filter?: ProxyFilter): Promise<number> {
+    const data = await this.request<{ count: number }>(
+      `/data/${encodeURIComponent(table)}/count`,
+      { filter },
+      true,
+    )
+    return data.count
+  }
+
+  // ============ Write API ============
+
+  /**
+   * Run a write operation
+   */
+  async exec(op: WriteOp): Promise<ExecResult> {
+    const data = await this.request<{
+      affected_rows: number
+      last_insert_id?: number
+    }>(
+      `/data/${encodeURIComponent(op.table)}/${op.op}`,
+      writeBody(op),
+      this.config.retry.retryWrites,
+    )
+    return {
+      affectedRows: data.affected_rows,
+      lastInsertId: data.last_insert_id,
+    }
+  }
+
+  /**
+   * Insert a row into a table
+   */
+  insert(table: string, data: ProxyRow): Promise<ExecResult> {
+    return this.exec({ op: 'insert', table, data })
+  }
+
+  /**
+   * Update the rows of a table, or the first matched row only with one
+   */
+  update(
+    table: string,
+    filter: ProxyFilter,
+    update: ProxyUpdate,
+    one = false,
+  ): Promise<ExecResult> {
+    return this.exec({ op: 'update', table, filter, update, one })
+  }
+
+  /**
+   * Remove the rows of a table, or the first matched row only with one
+   */
+  remove(
+    table: string,
+    filter: ProxyFilter,
+    one = false,
+  ): Promise<ExecResult> {
+    return this.exec({ op: 'remove', table, filter, one })
+  }
+
+  /**
+   * Run the write operations in order, and stop at the first failure.
+   * Each operation is a separate request, so the operations before the
+   * failure stay applied.
+   */
+  async batch(ops: WriteOp[]): Promise<ExecResult[]> {
+    const results: ExecResult[] = []
+    for (const op of ops) {
+      results.push(await this.exec(op))
+    }
+    return results
+  }
+
+  // ============ GraphQL and User API ============
+
+  /**
+   * Run a GraphQL query or mutation, the GraphQL errors are thrown
+   */
+  async graphql<T = Record<string, unknown>>(
+    query: string,
+    variables?: Record<string, unknown>,
+    operationName?: string,
+  ): Promise<T> {
+    const isMutation = /^\s*mutation\b/.test(query)
+    const response = await this.fetchWithRetry(
+      '/graphql',
+      { query, variables, operationName },
+      !isMutation || this.config.retry.retryWrites,
+    )
+    const result = (await response.json()) as GraphQLResult<T>
+    if (result.errors?.length) {
+      throw new SQLitError(
+        result.errors.map((e) => e.message).join('; '),
+        'ERR_GRAPHQL',
+        { errors: result.errors, status: response.status },
+      )
+    }
+    return result.data as T
+  }
+
+  /**
+   * Get the logged in user
+   */
+  userInfo(): Promise<ProxyUserInfo> {
+    return this.request<ProxyUserInfo>('/userinfo', undefined, true)
+  }
+
+  /**
+   * Delete the session of the logged in user
+   */
+  async logout(): Promise<void> {
+    await this.request<unknown>('/auth/logout', {}, false)
+    this.config.token = undefined
+  }
+
+  // ============ Transport ============
+
+  private async request<T>(
+    path: string,
+    body: unknown,
+    retryable: boolean,
+  ): Promise<T> {
+    const response = await this.fetchWithRetry(path, body, retryable)
+    let result: ProxyResponse<T>
+    try {
+      result = (await response.json()) as ProxyResponse<T>
+    } catch {
+      throw new SQLitError(
+        `invalid response with status ${response.status}`,
+        SQLitErrorCode.DATABASE_UNAVAILABLE,
+        { status: response.status },
+      )
+    }
+    if (!response.ok || !result.success) {
+      throw new SQLitError(
+        result.msg || 'request failed',
+        result.msg || errorCode(response.status),
+        { status: response.status },
+      )
+    }
+    return result.data as T
+  }
+
+  private async fetchWithRetry(
+    path: string,
+    body: unknown,
+    retryable: boolean,
+  ): Promise<Response> {
+    const { retries, minDelayMs, maxDelayMs } = this.config.retry
+    const attempts = retryable ? retries + 1 : 1
+    for (let attempt = 1; ; attempt++) {
+      try {
+        const response = await this.send(path, body)
+        if (attempt >= attempts || !RETRY_STATUS.has(response.status)) {
+          return response
+        }
+      } catch (error) {
+        if (attempt >= attempts) {
+          throw error
+        }
+      }
+      // exponential backoff with full jitter
+      const delay = Math.min(maxDelayMs, minDelayMs * 2 ** (attempt - 1))
+      await new Promise((resolve) => setTimeout(resolve, Math.random() * delay))
+    }
+  }
+
+  private send(path: string, body: unknown): Promise<Response> {
+    const url = new URL(`${this.config.endpoint}/v3${path}`)
+    if (this.config.project) {
+      url.searchParams.set('project', this.config.project)
+    }
+    const headers: Record<string, string> = {
+      'Content-Type': 'application/json',
+    }
+    if (this.config.token) {
+      headers['X-SQLIT-Token'] = this.config.token
+    }
+    const doFetch = this.config.fetch ?? globalThis.fetch
+    return doFetch(url.toString(), {
+      method: body === undefined ? 'GET' : 'POST',
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+      signal: AbortSignal.timeout(this.config.timeoutMs),
+    })
+  }
+}
+
+function writeBody(op: WriteOp): Record<string, unknown> {
+  switch (op.op) {
+    case 'insert':
+      return { data: op.data }
+    case 'update':
+      return { filter: op.filter, update: op.update, one: op.one }
+    case 'remove':
+      return { filter: op.filter, one: op.one }
+  }
+}
+
+function errorCode(status: number): string {
+  switch (status) {
+    case 401:
+    case 403:
+      return SQLitErrorCode.UNAUTHORIZED
+    case 429:
+      return SQLitErrorCode.RATE_LIMITED
+    case 504:
+      return SQLitErrorCode.QUERY_TIMEOUT
+    default:
+      return SQLitErrorCode.DATABASE_UNAVAILABLE
+  }
+}
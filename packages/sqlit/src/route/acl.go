@@ -64,6 +64,34 @@ const (
 	DBSDeploy
 	// DBSObserverFetchBlock is used by observer to fetch block.
 	DBSObserverFetchBlock
+	// DBSBackup is used by database admin to trigger an online backup of database
+	DBSBackup
+	// DBSBackupStatus is used by database admin to query the backup status of database
+	DBSBackupStatus
+	// DBSStandbyQuery is used by client to read a possibly stale local snapshot of database
+	DBSStandbyQuery
+	// DBSTransferLeader is used by database leader to hand off the leadership to a follower
+	DBSTransferLeader
+	// DBSCloneSnapshot is used by miners of a clone database to fetch the source snapshot
+	DBSCloneSnapshot
+	// DBSAsOfQuery is used by client to read the historical state of database at a height
+	DBSAsOfQuery
+	// DBSFetchCursor is used by client to fetch the next page of a spilled read query result
+	DBSFetchCursor
+	// DBSPeerHandshake is used by miners of a database to exchange their SQLite builds on startup
+	DBSPeerHandshake
+	// DBSAuditWrite is used by database leader to compare the audited write results with a follower
+	DBSAuditWrite
+	// DBSAttachedQuery is used by client to read the database joined with the co-located databases
+	DBSAttachedQuery
+	// DBSPeerPing is used by miners of a database to measure the round-trip times to the peers
+	DBSPeerPing
+	// DBSBackupVerify is used by database admin to verify a backup against the live database
+	DBSBackupVerify
+	// DBSQueryPeers is used by client to learn the current peers and leader term of database
+	DBSQueryPeers
+	// DBSEstimateQuery is used by client to estimate the costs of the queries by the query plans
+	DBSEstimateQuery
 	// DBCCall is used by Miner for data consistency
 	DBCCall
 	// SQLCAdviseNewBlock is used by sqlchain to advise new block between adjacent node
@@ -74,6 +102,8 @@ const (
 	SQLCSignBilling
 	// SQLCLaunchBilling is used by blockproducer to trigger the billing process in sqlchain
 	SQLCLaunchBilling
+	// SQLCFetchAck is used by sqlchain leader to fetch the acks of the unacknowledged queries
+	SQLCFetchAck
 	// MCCAdviseNewBlock is used by block producer to push block to adjacent nodes
 	MCCAdviseNewBlock
 	// MCCAdviseTxBilling is used by block producer to push billing transaction to adjacent nodes
@@ -101,6 +131,20 @@ const (
 	MCCQueryTxState
 	// MCCQueryAccountSQLChainProfiles is used by client to query account databases.
 	MCCQueryAccountSQLChainProfiles
+	// MCCQueryAccountPendingTxs is used by client to query account pending transactions.
+	MCCQueryAccountPendingTxs
+	// MCCReportDatabaseLoad is used by miners to report the loads of their databases.
+	MCCReportDatabaseLoad
+	// MCCQueryDatabaseLoad is used by client to query the load of a database.
+	MCCQueryDatabaseLoad
+	// MCCQueryAccountHistory is used by client to query the transactions of an account.
+	MCCQueryAccountHistory
+	// MCCPreviewUpdatePermission is used by client to preview a permission update of a database.
+	MCCPreviewUpdatePermission
+	// MCCQueryEffectivePermission is used by client to query the effective permission of an account.
+	MCCQueryEffectivePermission
+	// DiagDumpState is used by node operator to capture a diagnostic bundle of the node
+	DiagDumpState
 	// MaxRPCOffset defines max rpc constant.
 	MaxRPCOffset
 
@@ -114,6 +158,8 @@ const (
 	SQLChainRPCName = "SQLC"
 	// DBRPCName defines the sql chain db service rpc name
 	DBRPCName = "DBS"
+	// DiagRPCName defines the node diagnostic service rpc name
+	DiagRPCName = "Diag"
 )
 
 // String returns the RemoteFunc string.
@@ -137,6 +183,34 @@ func (s RemoteFunc) String() string {
 		return "DBS.Deploy"
 	case DBSObserverFetchBlock:
 		return "DBS.ObserverFetchBlock"
+	case DBSBackup:
+		return "DBS.Backup"
+	case DBSBackupStatus:
+		return "DBS.BackupStatus"
+	case DBSStandbyQuery:
+		return "DBS.StandbyQuery"
+	case DBSTransferLeader:
+		return "DBS.TransferLeader"
+	case DBSCloneSnapshot:
+		return "DBS.CloneSnapshot"
+	case DBSAsOfQuery:
+		return "DBS.AsOfQuery"
+	case DBSFetchCursor:
+		return "DBS.FetchCursor"
+	case DBSPeerHandshake:
+		return "DBS.PeerHandshake"
+	case DBSAuditWrite:
+		return "DBS.AuditWrite"
+	case DBSAttachedQuery:
+		return "DBS.AttachedQuery"
+	case DBSPeerPing:
+		return "DBS.PeerPing"
+	case DBSBackupVerify:
+		return "DBS.BackupVerify"
+	case DBSQueryPeers:
+		return "DBS.QueryPeers"
+	case DBSEstimateQuery:
+		return "DBS.EstimateQuery"
 	case DBCCall:
 		return "DBC.Call"
 	case SQLCAdviseNewBlock:
@@ -147,6 +221,8 @@ func (s RemoteFunc) String() string {
 		return "SQLC.SignBilling"
 	case SQLCLaunchBilling:
 		return "SQLC.LaunchBilling"
+	case SQLCFetchAck:
+		return "SQLC.FetchAck"
 	case MCCAdviseNewBlock:
 		return "MCC.AdviseNewBlock"
 	case MCCAdviseTxBilling:
@@ -173,6 +249,20 @@ func (s RemoteFunc) String() string {
 		return "MCC.QueryTxState"
 	case MCCQueryAccountSQLChainProfiles:
 		return "MCC.QueryAccountSQLChainProfiles"
+	case MCCQueryAccountPendingTxs:
+		return "MCC.QueryAccountPendingTxs"
+	case MCCReportDatabaseLoad:
+		return "MCC.ReportDatabaseLoad"
+	case MCCQueryDatabaseLoad:
+		return "MCC.QueryDatabaseLoad"
+	case MCCQueryAccountHistory:
+		return "MCC.QueryAccountHistory"
+	case MCCPreviewUpdatePermission:
+		return "MCC.PreviewUpdatePermission"
+	case MCCQueryEffectivePermission:
+		return "MCC.QueryEffectivePermission"
+	case DiagDumpState:
+		return "Diag.DumpState"
 	}
 	return "Unknown"
 }
@@ -3,8 +3,6 @@ package route
 
 import (
 	"errors"
-	"fmt"
-	"math/rand"
 	"sync"
 
 	"sqlit/src/conf"
@@ -104,6 +102,19 @@ func SetNodeAddrCache(id *proto.RawNodeID, addr string) (err error) {
 	return setNodeAddrCache(id, addr)
 }
 
+// GetNodeAddrCacheAll returns a copy of the node id and addr cache.
+func GetNodeAddrCacheAll() (nodes map[proto.NodeID]string) {
+	initResolver()
+	resolver.RLock()
+	defer resolver.RUnlock()
+	nodes = make(map[proto.NodeID]string, len(resolver.cache))
+	for k, v := range resolver.cache {
+		id := k
+		nodes[id.ToNodeID()] = v
+	}
+	return
+}
+
 // initBPNodeIDs initializes BlockProducer route and map from config file and DNS Seed.
 func initBPNodeIDs() (bpNodeIDs NodeIDAddressMap) {
 	if conf.GConf == nil {
@@ -116,16 +127,11 @@ func initBPNodeIDs() (bpNodeIDs NodeIDAddressMap) {
 
 	var err error
 
-	if conf.GConf.DNSSeed.Domain != "" {
-		var bpIndex int
-		dc := IPv6SeedClient{}
-		bpIndex = rand.Intn(conf.GConf.DNSSeed.BPCount)
-		bpDomain := fmt.Sprintf("bp%02d.%s", bpIndex, conf.GConf.DNSSeed.Domain)
-		log.Infof("Geting bp address from dns: %v", bpDomain)
-		resolver.bpNodes, err = dc.GetBPFromDNSSeed(bpDomain)
+	if len(bootstrapSources(&conf.GConf.DNSSeed)) > 0 {
+		resolver.bpNodes, err = GetBPFromSeeds(&conf.GConf.DNSSeed)
 		if err != nil {
-			log.WithField("seed", bpDomain).WithError(err).Error(
-				"getting BP info from DNS failed")
+			log.WithField("seeds", bootstrapSources(&conf.GConf.DNSSeed)).WithError(err).Error(
+				"getting BP info from seeds failed")
 			return
 		}
 	}
@@ -200,3 +206,18 @@ func InitKMS(PubKeyStoreFile string) {
 	}
 	log.Debugf("AllNodes:\n %#v\n", conf.GConf.KnownNodes)
 }
+
+// GetSeedBPNode returns the block producer node info loaded from DNS seed or config.
+func GetSeedBPNode(id *proto.RawNodeID) (node *proto.Node, err error) {
+	initResolver()
+	if id == nil {
+		return nil, ErrNilNodeID
+	}
+	resolver.RLock()
+	defer resolver.RUnlock()
+	n, ok := resolver.bpNodes[*id]
+	if !ok {
+		return nil, ErrUnknownNodeID
+	}
+	return &n, nil
+}
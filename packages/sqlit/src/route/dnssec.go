@@ -0,0 +1,150 @@
+package route
+
+import (
+	"context"
+	"math/rand"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	dnsPort          = "53"
+	dnsUDPSize       = 4096
+	dnsLookupTimeout = 5 * time.Second
+)
+
+// dnsServerAddr appends the default port to a dns server address without one.
+func dnsServerAddr(server string) string {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		return net.JoinHostPort(server, dnsPort)
+	}
+	return server
+}
+
+// lookupValidatedAAAA queries the AAAA records of host from the servers in order, the answer is
+// accepted only if the server reports it authenticated by DNSSEC. The servers must be trusted
+// validating resolvers reached over a trusted path, since the AD bit is not signed itself.
+func lookupValidatedAAAA(ctx context.Context, servers []string, host string) (ips []net.IP, err error) {
+	err = errors.New("no dns server")
+	for _, server := range servers {
+		if ips, err = queryAAAA(ctx, dnsServerAddr(server), host); err == nil {
+			return
+		}
+	}
+	return nil, errors.Wrapf(err, "lookup %s", host)
+}
+
+func queryAAAA(ctx context.Context, server, host string) (ips []net.IP, err error) {
+	var name dnsmessage.Name
+	if !strings.HasSuffix(host, ".") {
+		host += "."
+	}
+	if name, err = dnsmessage.NewName(host); err != nil {
+		return
+	}
+
+	// ask for the DNSSEC validation by the AD bit and the DO bit of EDNS0
+	var (
+		id = uint16(rand.Uint32())
+		b  = dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{
+			ID:               id,
+			RecursionDesired: true,
+			AuthenticData:    true,
+		})
+		opt   dnsmessage.ResourceHeader
+		query []byte
+	)
+	b.EnableCompression()
+	if err = b.StartQuestions(); err != nil {
+		return
+	}
+	if err = b.Question(dnsmessage.Question{
+		Name:  name,
+		Type:  dnsmessage.TypeAAAA,
+		Class: dnsmessage.ClassINET,
+	}); err != nil {
+		return
+	}
+	if err = b.StartAdditionals(); err != nil {
+		return
+	}
+	if err = opt.SetEDNS0(dnsUDPSize, dnsmessage.RCodeSuccess, true); err != nil {
+		return
+	}
+	if err = b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return
+	}
+	if query, err = b.Finish(); err != nil {
+		return
+	}
+
+	var (
+		d    net.Dialer
+		conn net.Conn
+		buf  = make([]byte, dnsUDPSize)
+		n    int
+	)
+	if conn, err = d.DialContext(ctx, "udp", server); err != nil {
+		return
+	}
+	defer func() { _ = conn.Close() }()
+	var deadline = time.Now().Add(dnsLookupTimeout)
+	if dl, ok := ctx.Deadline(); ok && dl.Before(deadline) {
+		deadline = dl
+	}
+	if err = conn.SetDeadline(deadline); err != nil {
+		return
+	}
+	if _, err = conn.Write(query); err != nil {
+		return
+	}
+	if n, err = conn.Read(buf); err != nil {
+		return
+	}
+
+	var (
+		p dnsmessage.Parser
+		h dnsmessage.Header
+	)
+	if h, err = p.Start(buf[:n]); err != nil {
+		return
+	}
+	switch {
+	case h.ID != id || !h.Response:
+		return nil, errors.Errorf("unexpected dns response from %s", server)
+	case h.RCode != dnsmessage.RCodeSuccess:
+		return nil, errors.Errorf("dns response from %s: %s", server, h.RCode)
+	case h.Truncated:
+		return nil, errors.Errorf("truncated dns response from %s", server)
+	case !h.AuthenticData:
+		return nil, errors.Wrapf(ErrDNSSECNotValidated, "server %s", server)
+	}
+	if err = p.SkipAllQuestions(); err != nil {
+		return
+	}
+	for {
+		var ah dnsmessage.ResourceHeader
+		if ah, err = p.AnswerHeader(); err == dnsmessage.ErrSectionDone {
+			err = nil
+			break
+		} else if err != nil {
+			return
+		}
+		if ah.Type != dnsmessage.TypeAAAA {
+			if err = p.SkipAnswer(); err != nil {
+				return
+			}
+			continue
+		}
+		var r dnsmessage.AAAAResource
+		if r, err = p.AAAAResource(); err != nil {
+			return
+		}
+		ips = append(ips, net.IP(r.AAAA[:]))
+	}
+	return
+}
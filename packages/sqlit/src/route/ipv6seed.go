@@ -26,7 +26,10 @@ const (
 )
 
 // IPv6SeedClient is IPv6 DNS seed client
-type IPv6SeedClient struct{}
+type IPv6SeedClient struct {
+	// Lookup resolves the seed records, nil means net.LookupIP
+	Lookup ipv6.LookupFunc
+}
 
 // GetBPFromDNSSeed gets BP info from the IPv6 domain
 func (isc *IPv6SeedClient) GetBPFromDNSSeed(BPDomain string) (BPNodes IDNodeMap, err error) {
@@ -37,8 +40,9 @@ func (isc *IPv6SeedClient) GetBPFromDNSSeed(BPDomain string) (BPNodes IDNodeMap,
 	wg := new(sync.WaitGroup)
 	wg.Add(4)
 
-	f := func(host string) ([]net.IP, error) {
-		return net.LookupIP(host)
+	f := isc.Lookup
+	if f == nil {
+		f = net.LookupIP
 	}
 	// Public key
 	go func() {
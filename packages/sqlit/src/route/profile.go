@@ -0,0 +1,138 @@
+package route
+
+import (
+	"sync"
+	"time"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/chainbus"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// DefaultProfileTTL defines the default time a cached sqlchain profile is kept.
+const DefaultProfileTTL = 30 * time.Second
+
+// ProfileLoader loads the sqlchain profile of a database from the block producers.
+type ProfileLoader func(dbID proto.DatabaseID) (*types.SQLChainProfile, error)
+
+type profileEntry struct {
+	profile *types.SQLChainProfile
+	expire  time.Time
+}
+
+// profileCall is an in-flight load shared by the concurrent lookups of a database.
+type profileCall struct {
+	done    chan struct{}
+	profile *types.SQLChainProfile
+	err     error
+}
+
+// ProfileCache caches the sqlchain profiles queried from the block producers by
+// MCCQuerySQLChainProfile. The concurrent lookups of a missing profile share a single load, and
+// the cached profile is dropped after the ttl or on invalidation, e.g. by the transactions
+// changing it.
+type ProfileCache struct {
+	sync.Mutex
+	ttl     time.Duration
+	load    ProfileLoader
+	entries map[proto.DatabaseID]*profileEntry
+	calls   map[proto.DatabaseID]*profileCall
+}
+
+// NewProfileCache returns a new profile cache loading the missing profiles with load, ttl <= 0
+// means DefaultProfileTTL.
+func NewProfileCache(ttl time.Duration, load ProfileLoader) *ProfileCache {
+	if ttl <= 0 {
+		ttl = DefaultProfileTTL
+	}
+	return &ProfileCache{
+		ttl:     ttl,
+		load:    load,
+		entries: make(map[proto.DatabaseID]*profileEntry),
+		calls:   make(map[proto.DatabaseID]*profileCall),
+	}
+}
+
+// Get returns the profile of database dbID, it's loaded if not cached or expired. The returned
+// profile is shared and must not be modified.
+func (c *ProfileCache) Get(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
+	c.Lock()
+	if e, ok := c.entries[dbID]; ok && time.Now().Before(e.expire) {
+		c.Unlock()
+		return e.profile, nil
+	}
+	if call, ok := c.calls[dbID]; ok {
+		c.Unlock()
+		<-call.done
+		return call.profile, call.err
+	}
+	call := &profileCall{done: make(chan struct{})}
+	c.calls[dbID] = call
+	c.Unlock()
+
+	call.profile, call.err = c.load(dbID)
+
+	c.Lock()
+	// the result of a load started before an invalidation is not cached
+	if c.calls[dbID] == call {
+		delete(c.calls, dbID)
+		if call.err == nil {
+			c.entries[dbID] = &profileEntry{
+				profile: call.profile,
+				expire:  time.Now().Add(c.ttl),
+			}
+		}
+	}
+	c.Unlock()
+	close(call.done)
+	return call.profile, call.err
+}
+
+// Invalidate drops the cached profile of database dbID, the next lookup loads it again.
+func (c *ProfileCache) Invalidate(dbID proto.DatabaseID) {
+	c.Lock()
+	defer c.Unlock()
+	delete(c.entries, dbID)
+	delete(c.calls, dbID)
+}
+
+// Purge drops all the cached profiles.
+func (c *ProfileCache) Purge() {
+	c.Lock()
+	defer c.Unlock()
+	c.entries = make(map[proto.DatabaseID]*profileEntry)
+	c.calls = make(map[proto.DatabaseID]*profileCall)
+}
+
+// Watch subscribes to the transactions published by a block producer event subscription, the
+// profiles changed by them are invalidated.
+func (c *ProfileCache) Watch(bus chainbus.ChainSuber) (err error) {
+	for _, tt := range []pi.TransactionType{
+		pi.TransactionTypeUpdatePermission,
+		pi.TransactionTypeUpdateBilling,
+		pi.TransactionTypeIssueKeys,
+		pi.TransactionTypeReplaceMiner,
+		pi.TransactionTypeExpandDatabase,
+	} {
+		if err = bus.Subscribe("/"+tt.String()+"/", c.invalidateTx); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (c *ProfileCache) invalidateTx(tx pi.Transaction, count uint32) {
+	switch tx := tx.(type) {
+	case *types.UpdatePermission:
+		c.Invalidate(tx.TargetSQLChain.DatabaseID())
+	case *types.UpdateBilling:
+		c.Invalidate(tx.Receiver.DatabaseID())
+	case *types.IssueKeys:
+		c.Invalidate(tx.TargetSQLChain.DatabaseID())
+	case *types.ReplaceMiner:
+		c.Invalidate(tx.TargetSQLChain.DatabaseID())
+	case *types.ExpandDatabase:
+		c.Invalidate(tx.TargetSQLChain.DatabaseID())
+	}
+}
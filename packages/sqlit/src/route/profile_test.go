@@ -0,0 +1,101 @@
+package route
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/chainbus"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestProfileCache(t *testing.T) {
+	Convey("Given a profile cache", t, func() {
+		var (
+			loads   int32
+			release = make(chan struct{})
+			fail    int32
+			addr    = proto.AccountAddress{0x01}
+			dbID    = addr.DatabaseID()
+			c       = NewProfileCache(100*time.Millisecond, func(
+				id proto.DatabaseID,
+			) (*types.SQLChainProfile, error) {
+				atomic.AddInt32(&loads, 1)
+				<-release
+				if atomic.LoadInt32(&fail) != 0 {
+					return nil, errors.New("not found")
+				}
+				return &types.SQLChainProfile{ID: id}, nil
+			})
+		)
+		Convey("The concurrent lookups should share a single load", func() {
+			var wg sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					p, err := c.Get(dbID)
+					if err != nil || p.ID != dbID {
+						t.Error("unexpected profile")
+					}
+				}()
+			}
+			time.Sleep(50 * time.Millisecond)
+			close(release)
+			wg.Wait()
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+
+			_, err := c.Get(dbID)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 1)
+
+			Convey("The profile should be loaded again after the ttl", func() {
+				time.Sleep(150 * time.Millisecond)
+				_, err = c.Get(dbID)
+				So(err, ShouldBeNil)
+				So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+			})
+			Convey("The profile should be loaded again after the invalidation", func() {
+				bus := chainbus.New()
+				So(c.Watch(bus), ShouldBeNil)
+				bus.Publish("/"+pi.TransactionTypeUpdatePermission.String()+"/",
+					pi.Transaction(types.NewUpdatePermission(&types.UpdatePermissionHeader{
+						TargetSQLChain: addr,
+					})), uint32(1))
+				_, err = c.Get(dbID)
+				So(err, ShouldBeNil)
+				So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+			})
+		})
+		Convey("The failed load should not be cached", func() {
+			atomic.StoreInt32(&fail, 1)
+			close(release)
+			_, err := c.Get(dbID)
+			So(err, ShouldNotBeNil)
+			atomic.StoreInt32(&fail, 0)
+			_, err = c.Get(dbID)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+		})
+		Convey("The load started before the invalidation should not be cached", func() {
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				_, _ = c.Get(dbID)
+			}()
+			time.Sleep(50 * time.Millisecond)
+			c.Invalidate(dbID)
+			close(release)
+			<-done
+			_, err := c.Get(dbID)
+			So(err, ShouldBeNil)
+			So(atomic.LoadInt32(&loads), ShouldEqual, 2)
+		})
+	})
+}
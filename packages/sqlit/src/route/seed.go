@@ -0,0 +1,246 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+	"sqlit/src/utils/log"
+)
+
+// Names of the built-in seed sources.
+const (
+	// DNSSeedName is the seed source reading the block producers from the IPv6 DNS records.
+	DNSSeedName = "dns"
+	// HTTPSSeedName is the seed source reading the block producers from a well-known node list
+	// served over HTTPS.
+	HTTPSSeedName = "https"
+	// RegistrySeedName is the seed source reading the block producers from the on-chain node
+	// registry, it's registered by the registry client.
+	RegistrySeedName = "registry"
+)
+
+const (
+	// WellKnownNodesPath is the path of the node list of the HTTPS seed source.
+	WellKnownNodesPath = "/.well-known/eqlite-nodes.json"
+	// DefaultSeedTimeout is the default time limit of each seed source.
+	DefaultSeedTimeout = 10 * time.Second
+
+	maxNodeListSize = 1 << 20
+)
+
+var (
+	// ErrUnknownSeedSource indicates that the seed source name is not registered.
+	ErrUnknownSeedSource = errors.New("unknown seed source")
+	// ErrNoSeedNodes indicates that a seed source returns no valid block producer.
+	ErrNoSeedNodes = errors.New("no valid block producer found in seed")
+	// ErrDNSSECNotValidated indicates that a seed record is not authenticated by DNSSEC while it's
+	// enforced.
+	ErrDNSSECNotValidated = errors.New("dns record not validated by DNSSEC")
+)
+
+// SeedSource loads the block producer nodes to bootstrap from.
+type SeedSource interface {
+	GetBPNodes(ctx context.Context, cfg *conf.DNSSeed) (IDNodeMap, error)
+}
+
+var (
+	seedSourcesLock sync.RWMutex
+	seedSources     = map[string]SeedSource{
+		DNSSeedName:   &dnsSeed{},
+		HTTPSSeedName: &httpsSeed{client: http.DefaultClient},
+	}
+)
+
+// RegisterSeedSource registers a seed source by name, so that it can be selected in the
+// bootstrap config. Registering the same name again overwrites the previous one.
+func RegisterSeedSource(name string, src SeedSource) {
+	seedSourcesLock.Lock()
+	defer seedSourcesLock.Unlock()
+	seedSources[name] = src
+}
+
+func getSeedSource(name string) (src SeedSource, ok bool) {
+	seedSourcesLock.RLock()
+	defer seedSourcesLock.RUnlock()
+	src, ok = seedSources[name]
+	return
+}
+
+// bootstrapSources returns the seed sources configured in cfg.
+func bootstrapSources(cfg *conf.DNSSeed) []string {
+	if len(cfg.Bootstrap) > 0 {
+		return cfg.Bootstrap
+	}
+	if cfg.Domain != "" {
+		return []string{DNSSeedName}
+	}
+	return nil
+}
+
+// GetBPFromSeeds tries the seed sources configured in cfg in order and returns the block
+// producers of the first one succeeded. The nodes which ID doesn't match the public key and
+// nonce are dropped.
+func GetBPFromSeeds(cfg *conf.DNSSeed) (nodes IDNodeMap, err error) {
+	var timeout = cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultSeedTimeout
+	}
+	err = ErrNoSeedNodes
+	for _, name := range bootstrapSources(cfg) {
+		var (
+			src, ok = getSeedSource(name)
+			serr    error
+		)
+		if !ok {
+			err = errors.Wrapf(ErrUnknownSeedSource, "seed %s", name)
+			log.WithField("seed", name).WithError(err).Warning("skip seed source")
+			continue
+		}
+		if nodes, serr = loadSeed(src, cfg, timeout); serr == nil {
+			return nodes, nil
+		}
+		err = errors.Wrapf(serr, "seed %s", name)
+		log.WithField("seed", name).WithError(serr).Warning("load seed source failed")
+	}
+	return nil, err
+}
+
+func loadSeed(src SeedSource, cfg *conf.DNSSeed, timeout time.Duration) (nodes IDNodeMap, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var loaded IDNodeMap
+	if loaded, err = src.GetBPNodes(ctx, cfg); err != nil {
+		return
+	}
+	nodes = make(IDNodeMap)
+	for id, n := range loaded {
+		if !kms.IsIDPubNonceValid(&id, &n.Nonce, n.PublicKey) || n.Addr == "" {
+			log.WithField("node", id.String()).Warning("drop invalid seed node")
+			continue
+		}
+		nodes[id] = n
+	}
+	if len(nodes) == 0 {
+		err = ErrNoSeedNodes
+		nodes = nil
+	}
+	return
+}
+
+// dnsSeed reads a random block producer from the IPv6 DNS records of the seed domain.
+type dnsSeed struct{}
+
+func (s *dnsSeed) GetBPNodes(ctx context.Context, cfg *conf.DNSSeed) (nodes IDNodeMap, err error) {
+	if cfg.Domain == "" {
+		return nil, errors.New("no seed domain")
+	}
+	var bpCount = cfg.BPCount
+	if bpCount <= 0 {
+		bpCount = 1
+	}
+	var (
+		bpDomain = fmt.Sprintf("bp%02d.%s", rand.Intn(bpCount), cfg.Domain)
+		dc       = IPv6SeedClient{}
+	)
+	switch {
+	case cfg.EnforcedDNSSEC:
+		if len(cfg.DNSServers) == 0 {
+			return nil, errors.Wrap(ErrDNSSECNotValidated, "no validating dns server")
+		}
+		dc.Lookup = func(host string) ([]net.IP, error) {
+			return lookupValidatedAAAA(ctx, cfg.DNSServers, host)
+		}
+	case len(cfg.DNSServers) > 0:
+		var (
+			d = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, network, dnsServerAddr(
+						cfg.DNSServers[rand.Intn(len(cfg.DNSServers))]))
+				},
+			}
+		)
+		dc.Lookup = func(host string) ([]net.IP, error) {
+			return d.LookupIP(ctx, "ip6", host)
+		}
+	}
+	log.Infof("Geting bp address from dns: %v", bpDomain)
+	return dc.GetBPFromDNSSeed(bpDomain)
+}
+
+// httpsSeed reads the block producers from the node list served over HTTPS, the list has the
+// same format as the KnownNodes in config:
+//
+//	{"Nodes": [{"ID": "...", "Role": "Leader", "Addr": "host:port", "PublicKey": "...",
+//	  "Nonce": {"a": 0, "b": 0, "c": 0, "d": 0}}]}
+type httpsSeed struct {
+	client *http.Client
+}
+
+func (s *httpsSeed) GetBPNodes(ctx context.Context, cfg *conf.DNSSeed) (nodes IDNodeMap, err error) {
+	var raw = cfg.HTTPSURL
+	if raw == "" {
+		if cfg.Domain == "" {
+			return nil, errors.New("no seed url or domain")
+		}
+		raw = "https://" + cfg.Domain + WellKnownNodesPath
+	}
+	var u *url.URL
+	if u, err = url.Parse(raw); err != nil {
+		return
+	}
+	if u.Scheme != "https" {
+		return nil, errors.Errorf("seed url %s is not https", raw)
+	}
+
+	var (
+		req  *http.Request
+		resp *http.Response
+		body []byte
+	)
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil); err != nil {
+		return
+	}
+	if resp, err = s.client.Do(req); err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("get seed %s failed: %s", raw, resp.Status)
+	}
+	if body, err = io.ReadAll(io.LimitReader(resp.Body, maxNodeListSize)); err != nil {
+		return
+	}
+
+	// JSON is decoded as YAML to reuse the node format of config
+	var list struct {
+		Nodes []proto.Node `yaml:"Nodes"`
+	}
+	if err = yaml.Unmarshal(body, &list); err != nil {
+		return nil, errors.Wrapf(err, "decode seed %s failed", raw)
+	}
+	nodes = make(IDNodeMap)
+	for _, n := range list.Nodes {
+		if n.Role != proto.Leader && n.Role != proto.Follower {
+			continue
+		}
+		if rawID := n.ID.ToRawNodeID(); rawID != nil {
+			nodes[*rawID] = n
+		}
+	}
+	return
+}
@@ -0,0 +1,182 @@
+package route
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/pow/cpuminer"
+	"sqlit/src/proto"
+)
+
+func newSeedNode(addr string) (node proto.Node, err error) {
+	var pub *asymmetric.PublicKey
+	if _, pub, err = asymmetric.GenSecp256k1KeyPair(); err != nil {
+		return
+	}
+	nonce := cpuminer.Uint256{A: 1}
+	node = proto.Node{
+		ID:        proto.NodeID(cpuminer.HashBlock(pub.Serialize(), nonce).String()),
+		Role:      proto.Leader,
+		Addr:      addr,
+		PublicKey: pub,
+		Nonce:     nonce,
+	}
+	return
+}
+
+func nodeListJSON(nodes ...proto.Node) (out string) {
+	out = `{"Nodes": [`
+	for i, n := range nodes {
+		if i > 0 {
+			out += ","
+		}
+		out += fmt.Sprintf(`{"ID": %q, "Role": %q, "Addr": %q, "PublicKey": "%x", `+
+			`"Nonce": {"a": %d, "b": 0, "c": 0, "d": 0}}`,
+			n.ID, n.Role.String(), n.Addr, n.PublicKey.Serialize(), n.Nonce.A)
+	}
+	return out + `]}`
+}
+
+type failedSeed struct{}
+
+func (failedSeed) GetBPNodes(context.Context, *conf.DNSSeed) (IDNodeMap, error) {
+	return nil, ErrNoSeedNodes
+}
+
+func TestSeedSources(t *testing.T) {
+	Convey("Given a node list served over HTTPS", t, func() {
+		valid, err := newSeedNode("127.0.0.1:1")
+		So(err, ShouldBeNil)
+		invalid, err := newSeedNode("127.0.0.1:2")
+		So(err, ShouldBeNil)
+		invalid.Nonce.A = 2
+		miner, err := newSeedNode("127.0.0.1:3")
+		So(err, ShouldBeNil)
+		miner.Role = proto.Miner
+
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != WellKnownNodesPath {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = w.Write([]byte(nodeListJSON(valid, invalid, miner)))
+		}))
+		defer server.Close()
+		RegisterSeedSource(HTTPSSeedName, &httpsSeed{client: server.Client()})
+		defer RegisterSeedSource(HTTPSSeedName, &httpsSeed{client: http.DefaultClient})
+		RegisterSeedSource("failed", failedSeed{})
+
+		cfg := &conf.DNSSeed{
+			Bootstrap: []string{"unknown", "failed", HTTPSSeedName},
+			HTTPSURL:  server.URL + WellKnownNodesPath,
+		}
+
+		Convey("The valid block producers should be seeded by the first source succeeded", func() {
+			nodes, err := GetBPFromSeeds(cfg)
+			So(err, ShouldBeNil)
+			So(len(nodes), ShouldEqual, 1)
+			n, ok := nodes[*valid.ID.ToRawNodeID()]
+			So(ok, ShouldBeTrue)
+			So(n.Addr, ShouldEqual, valid.Addr)
+			So(n.PublicKey.IsEqual(valid.PublicKey), ShouldBeTrue)
+		})
+		Convey("The node list should only be read over HTTPS", func() {
+			cfg.HTTPSURL = "http" + server.URL[len("https"):] + WellKnownNodesPath
+			_, err := GetBPFromSeeds(cfg)
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The seeding should fail without any valid node", func() {
+			cfg.HTTPSURL = server.URL + "/missing"
+			_, err := GetBPFromSeeds(cfg)
+			So(err, ShouldNotBeNil)
+			So(bootstrapSources(&conf.DNSSeed{}), ShouldBeEmpty)
+			So(bootstrapSources(&conf.DNSSeed{Domain: "seed"}), ShouldResemble, []string{DNSSeedName})
+		})
+	})
+}
+
+// serveDNS answers each AAAA query with ip, the AD bit is set as authenticated.
+func serveDNS(conn net.PacketConn, ip net.IP, authenticated bool) {
+	buf := make([]byte, 512)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var p dnsmessage.Parser
+		h, err := p.Start(buf[:n])
+		if err != nil {
+			continue
+		}
+		q, err := p.Question()
+		if err != nil {
+			continue
+		}
+		b := dnsmessage.NewBuilder(nil, dnsmessage.Header{
+			ID:            h.ID,
+			Response:      true,
+			AuthenticData: authenticated,
+		})
+		_ = b.StartQuestions()
+		_ = b.Question(q)
+		_ = b.StartAnswers()
+		var aaaa dnsmessage.AAAAResource
+		copy(aaaa.AAAA[:], ip.To16())
+		_ = b.AAAAResource(dnsmessage.ResourceHeader{
+			Name:  q.Name,
+			Class: dnsmessage.ClassINET,
+			TTL:   1,
+		}, aaaa)
+		resp, _ := b.Finish()
+		_, _ = conn.WriteTo(resp, addr)
+	}
+}
+
+func TestValidatedLookup(t *testing.T) {
+	Convey("Given the dns servers", t, func() {
+		ip := net.ParseIP("2001:db8::1")
+		validating, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer validating.Close()
+		go serveDNS(validating, ip, true)
+		plain, err := net.ListenPacket("udp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		defer plain.Close()
+		go serveDNS(plain, ip, false)
+
+		Convey("The answer should only be accepted if authenticated", func() {
+			ctx := context.Background()
+			ips, err := lookupValidatedAAAA(ctx, []string{validating.LocalAddr().String()}, "bp00.seed")
+			So(err, ShouldBeNil)
+			So(len(ips), ShouldEqual, 1)
+			So(ips[0].Equal(ip), ShouldBeTrue)
+
+			_, err = lookupValidatedAAAA(ctx, []string{plain.LocalAddr().String()}, "bp00.seed")
+			So(err, ShouldNotBeNil)
+
+			ips, err = lookupValidatedAAAA(ctx, []string{
+				plain.LocalAddr().String(), validating.LocalAddr().String(),
+			}, "bp00.seed")
+			So(err, ShouldBeNil)
+			So(len(ips), ShouldEqual, 1)
+		})
+		Convey("The DNSSEC enforcement should require the dns servers", func() {
+			_, err := (&dnsSeed{}).GetBPNodes(context.Background(), &conf.DNSSeed{
+				Domain:         "seed",
+				EnforcedDNSSEC: true,
+			})
+			So(err, ShouldNotBeNil)
+			So(dnsServerAddr("127.0.0.1"), ShouldEqual, "127.0.0.1:53")
+			So(dnsServerAddr("[::1]:5353"), ShouldEqual, "[::1]:5353")
+		})
+	})
+}
@@ -3,6 +3,7 @@ package route
 
 import (
 	"fmt"
+	"sync"
 
 	"sqlit/src/conf"
 	"sqlit/src/consistent"
@@ -14,6 +15,8 @@ import (
 // DHTService is server side RPC implementation.
 type DHTService struct {
 	Consistent *consistent.Consistent
+	// features caches the protocol features advertised by the nodes in Ping
+	features sync.Map // map[proto.NodeID][]string
 }
 
 // NewDHTServiceWithRing will return a new DHTService and set an existing hash ring.
@@ -115,6 +118,29 @@ func (DHT *DHTService) Ping(req *proto.PingReq, resp *proto.PingResp) (err error
 		err = fmt.Errorf("DHT.Consistent.Add %v failed: %s", req.Node, err)
 	} else {
 		resp.Msg = "Pong"
+		resp.Features = conf.SupportedFeatures()
+		DHT.recordFeatures(req.Node.ID, req.Features)
+	}
+	return
+}
+
+// recordFeatures caches the protocol features advertised by node, and warns if the node doesn't
+// support any of the scheduled features, which means it should be upgraded before activation.
+func (DHT *DHTService) recordFeatures(node proto.NodeID, features []string) {
+	DHT.features.Store(node, append([]string(nil), features...))
+	if missing := conf.MissingFeatures(features); len(missing) > 0 {
+		log.WithFields(log.Fields{
+			"node":    node,
+			"missing": missing,
+		}).Warning("node does not support scheduled protocol features")
+	}
+}
+
+// NodeFeatures returns the protocol features advertised by node in its last Ping.
+func (DHT *DHTService) NodeFeatures(node proto.NodeID) (features []string, ok bool) {
+	var v interface{}
+	if v, ok = DHT.features.Load(node); ok {
+		features = append([]string(nil), v.([]string)...)
 	}
 	return
 }
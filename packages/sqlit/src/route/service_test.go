@@ -86,7 +86,8 @@ func TestDHTService_FindNeighbor_FindNode(t *testing.T) {
 	node1.Role = Miner
 
 	reqA := &PingReq{
-		Node: *node1,
+		Node:     *node1,
+		Features: conf.SupportedFeatures(),
 	}
 	respA := new(PingResp)
 	err = client.Call("DHT.Ping", reqA, respA)
@@ -104,6 +105,12 @@ func TestDHTService_FindNeighbor_FindNode(t *testing.T) {
 		log.Error(err)
 	}
 	log.Debugf("respFN1: %v", respFN2)
+	Convey("test Ping features", t, func() {
+		So(respA.Features, ShouldResemble, conf.SupportedFeatures())
+		features, ok := dht.NodeFeatures(node1.ID)
+		So(ok, ShouldBeTrue)
+		So(features, ShouldResemble, reqA.Features)
+	})
 	Convey("test FindNode", t, func() {
 		So(respFN2.Node.ID, ShouldEqual, node1.ID)
 		So(respFN2.Node.Nonce == node1.Nonce, ShouldBeTrue)
@@ -13,6 +13,7 @@ import (
 
 	"sqlit/src/proto"
 	"sqlit/src/route"
+	"sqlit/src/utils/fault"
 )
 
 var (
@@ -67,6 +68,20 @@ func NewCallerWithPool(pool NOClientPool) *Caller {
 // CallNodeWithContext calls node method with context.
 func (c *Caller) CallNodeWithContext(
 	ctx context.Context, node proto.NodeID, method string, args, reply interface{}) (err error,
+) {
+	dup, err := fault.Apply(ctx, fault.RPCCall, fault.Key(string(node), method))
+	if err != nil {
+		err = errors.Wrapf(err, "call %s to node %s failed", method, node)
+		return
+	}
+	for i := 0; i < dup; i++ {
+		_ = c.callNode(ctx, node, method, args, reply)
+	}
+	return c.callNode(ctx, node, method, args, reply)
+}
+
+func (c *Caller) callNode(
+	ctx context.Context, node proto.NodeID, method string, args, reply interface{}) (err error,
 ) {
 	startTime := time.Now()
 	defer func() {
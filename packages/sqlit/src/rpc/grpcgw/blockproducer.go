@@ -0,0 +1,132 @@
+package grpcgw
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+type blockProducerServer struct {
+	backend ChainBackend
+}
+
+// decodeTx decodes the JSON encoding of a transaction, detecting its type from the TxType field.
+func decodeTx(in *TxRequest) (tx pi.Transaction, err error) {
+	var w = &pi.TransactionWrapper{}
+	if err = json.Unmarshal([]byte(in.GetTx()), w); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "decode transaction failed: %v", err)
+		return
+	}
+	if tx = w.Unwrap(); tx == nil {
+		err = status.Error(codes.InvalidArgument, "empty transaction")
+	}
+	return
+}
+
+func decodeHash(b []byte, name string) (h *hash.Hash, err error) {
+	if h, err = hash.NewHash(b); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "invalid %s: %v", name, err)
+	}
+	return
+}
+
+// PrepareTx implements BlockProducerServer.PrepareTx.
+func (s *blockProducerServer) PrepareTx(ctx context.Context, in *TxRequest) (
+	out *PrepareResponse, err error,
+) {
+	var tx pi.Transaction
+	if tx, err = decodeTx(in); err != nil {
+		return
+	}
+	var h []byte
+	if h, err = prepareHash(tx.Sign); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "hash transaction failed: %v", err)
+		return
+	}
+	out = &PrepareResponse{Hash: h}
+	return
+}
+
+// AddTx implements BlockProducerServer.AddTx.
+func (s *blockProducerServer) AddTx(ctx context.Context, in *TxRequest) (
+	out *AddTxResponse, err error,
+) {
+	var (
+		tx     pi.Transaction
+		signer asymmetric.Signer
+		req    = &types.AddTxReq{TTL: 1}
+	)
+	if tx, err = decodeTx(in); err != nil {
+		return
+	}
+	if len(in.GetReplaces()) > 0 {
+		if req.Replaces, err = decodeHash(in.GetReplaces(), "replaced hash"); err != nil {
+			return
+		}
+	}
+	if signer, err = presignedSigner(in.GetSignee(), in.GetSignature()); err != nil {
+		return
+	}
+	if err = tx.Sign(signer); err != nil {
+		err = signStatus(err)
+		return
+	}
+	req.Tx = tx
+	if err = s.backend.AddTx(req, &types.AddTxResp{}); err != nil {
+		err = backendStatus(errors.Wrap(err, "add tx failed"))
+		return
+	}
+	var h = tx.Hash()
+	out = &AddTxResponse{Hash: h.CloneBytes()}
+	return
+}
+
+// QueryTxState implements BlockProducerServer.QueryTxState.
+func (s *blockProducerServer) QueryTxState(ctx context.Context, in *QueryTxStateRequest) (
+	out *QueryTxStateResponse, err error,
+) {
+	var (
+		h    *hash.Hash
+		resp = &types.QueryTxStateResp{}
+	)
+	if h, err = decodeHash(in.GetHash(), "transaction hash"); err != nil {
+		return
+	}
+	if err = s.backend.QueryTxState(&types.QueryTxStateReq{Hash: *h}, resp); err != nil {
+		err = backendStatus(errors.Wrap(err, "query tx state failed"))
+		return
+	}
+	out = &QueryTxStateResponse{
+		Hash:  resp.Hash.CloneBytes(),
+		State: TxState(resp.State),
+	}
+	return
+}
+
+// QuerySQLChainProfile implements BlockProducerServer.QuerySQLChainProfile.
+func (s *blockProducerServer) QuerySQLChainProfile(
+	ctx context.Context, in *QuerySQLChainProfileRequest) (out *SQLChainProfile, err error,
+) {
+	if in.GetDatabaseId() == "" {
+		err = status.Error(codes.InvalidArgument, "empty database id")
+		return
+	}
+	var resp = &types.QuerySQLChainProfileResp{}
+	if err = s.backend.QuerySQLChainProfile(&types.QuerySQLChainProfileReq{
+		DBID: proto.DatabaseID(in.GetDatabaseId()),
+	}, resp); err != nil {
+		err = backendStatus(errors.Wrap(err, "query sqlchain profile failed"))
+		return
+	}
+	out = convertProfile(&resp.Profile)
+	return
+}
@@ -0,0 +1,158 @@
+package grpcgw
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
+
+// nativeValue returns the native query argument of v, nil for NULL.
+func nativeValue(v *Value) interface{} {
+	switch k := v.GetKind().(type) {
+	case *Value_IntValue:
+		return k.IntValue
+	case *Value_FloatValue:
+		return k.FloatValue
+	case *Value_StringValue:
+		return k.StringValue
+	case *Value_BytesValue:
+		return k.BytesValue
+	case *Value_BoolValue:
+		return k.BoolValue
+	case *Value_TimeValue:
+		return time.Unix(0, k.TimeValue).UTC()
+	}
+	return nil
+}
+
+// convertValue returns the value of a native result value.
+func convertValue(v interface{}) (out *Value, err error) {
+	out = &Value{}
+	switch v := v.(type) {
+	case nil:
+	case int64:
+		out.Kind = &Value_IntValue{IntValue: v}
+	case int:
+		out.Kind = &Value_IntValue{IntValue: int64(v)}
+	case int32:
+		out.Kind = &Value_IntValue{IntValue: int64(v)}
+	case uint32:
+		out.Kind = &Value_IntValue{IntValue: int64(v)}
+	case float64:
+		out.Kind = &Value_FloatValue{FloatValue: v}
+	case float32:
+		out.Kind = &Value_FloatValue{FloatValue: float64(v)}
+	case string:
+		out.Kind = &Value_StringValue{StringValue: v}
+	case []byte:
+		out.Kind = &Value_BytesValue{BytesValue: v}
+	case bool:
+		out.Kind = &Value_BoolValue{BoolValue: v}
+	case time.Time:
+		out.Kind = &Value_TimeValue{TimeValue: v.UnixNano()}
+	default:
+		err = errors.Errorf("unsupported value type %T", v)
+	}
+	return
+}
+
+func convertQueries(in []*Query) (out []types.Query, err error) {
+	out = make([]types.Query, len(in))
+	for i, q := range in {
+		if q.GetPattern() == "" {
+			err = errors.Errorf("empty pattern of query %d", i)
+			return
+		}
+		out[i].Pattern = q.GetPattern()
+		if len(q.GetArgs()) == 0 {
+			continue
+		}
+		out[i].Args = make([]types.NamedArg, len(q.GetArgs()))
+		for j, arg := range q.GetArgs() {
+			out[i].Args[j] = types.NamedArg{
+				Name:  arg.GetName(),
+				Value: nativeValue(arg.GetValue()),
+			}
+		}
+	}
+	return
+}
+
+func convertResponse(res *types.Response) (out *QueryResponse, err error) {
+	out = &QueryResponse{
+		Columns:      res.Payload.Columns,
+		DeclTypes:    res.Payload.DeclTypes,
+		Rows:         make([]*Row, len(res.Payload.Rows)),
+		LastInsertId: res.Header.LastInsertID,
+		AffectedRows: res.Header.AffectedRows,
+		NodeId:       string(res.Header.NodeID),
+		LogOffset:    res.Header.LogOffset,
+		ResponseHash: res.Header.ResponseHash.CloneBytes(),
+	}
+	for i, row := range res.Payload.Rows {
+		var r = &Row{Values: make([]*Value, len(row.Values))}
+		for j, v := range row.Values {
+			if r.Values[j], err = convertValue(v); err != nil {
+				err = errors.Wrapf(err, "convert column %d of row %d failed", j, i)
+				return
+			}
+		}
+		out.Rows[i] = r
+	}
+	if res.Signee != nil {
+		out.Signee = res.Signee.Serialize()
+	}
+	if res.Signature != nil {
+		out.Signature = res.Signature.Serialize()
+	}
+	return
+}
+
+func convertMiners(in []*types.MinerInfo) (out []*MinerInfo) {
+	out = make([]*MinerInfo, 0, len(in))
+	for _, m := range in {
+		if m == nil {
+			continue
+		}
+		// the encryption key is kept for the peers
+		out = append(out, &MinerInfo{
+			Address: m.Address.String(),
+			NodeId:  string(m.NodeID),
+			Name:    m.Name,
+			Status:  Status(m.Status),
+			Region:  m.Region,
+			Zone:    m.Zone,
+		})
+	}
+	return
+}
+
+func convertProfile(p *types.SQLChainProfile) (out *SQLChainProfile) {
+	out = &SQLChainProfile{
+		DatabaseId:        string(p.ID),
+		Address:           p.Address.String(),
+		Period:            p.Period,
+		LastUpdatedHeight: p.LastUpdatedHeight,
+		Owner:             p.Owner.String(),
+		Miners:            convertMiners(p.Miners),
+		Standby:           convertMiners(p.Standby),
+		Users:             make([]*SQLChainUser, 0, len(p.Users)),
+	}
+	for _, u := range p.Users {
+		if u == nil {
+			continue
+		}
+		var user = &SQLChainUser{
+			Address: u.Address.String(),
+			Status:  Status(u.Status),
+		}
+		if u.Permission != nil {
+			user.Role = int32(u.Permission.Role)
+			user.Patterns = u.Permission.Patterns
+		}
+		out.Users = append(out.Users, user)
+	}
+	return
+}
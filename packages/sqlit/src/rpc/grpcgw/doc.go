@@ -0,0 +1,16 @@
+// Package grpcgw implements the gRPC gateway of the main block producer and miner RPC methods,
+// for the clients in other ecosystems which don't speak the ETLS and msgpack framing of the
+// native RPC.
+//
+// The native messages are signed over the hashes of their msgpack encodings, which a client of
+// another language can't compute. So each signed method has a Prepare method answering the hash
+// of the same message: the client signs it with the secp256k1 key of its account and sends the
+// message again with the signee and the signature. The gateway rebuilds the native message, and
+// accepts the signature only if it's valid for the hash of the rebuilt message.
+//
+// The service glue in sqlit_grpc.go is written in the shape of the grpc plugin output for
+// google.golang.org/grpc v1.21, which the newer protoc-gen-go-grpc doesn't support, sqlit.pb.go
+// is generated by protoc-gen-go.
+package grpcgw
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative sqlit.proto
@@ -0,0 +1,11 @@
+package grpcgw
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrNoBackend indicates that the gateway serves neither the block producer nor the miner
+	// methods.
+	ErrNoBackend = errors.New("no backend to serve")
+	// ErrMissingSignature indicates that a signed message has no signee or signature.
+	ErrMissingSignature = errors.New("missing signee or signature")
+)
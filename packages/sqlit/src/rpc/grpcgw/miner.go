@@ -0,0 +1,111 @@
+package grpcgw
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+type minerServer struct {
+	backend DBMSBackend
+	nodeID  proto.NodeID
+}
+
+// buildRequest builds the native request of a query request, the request is signed later.
+func (s *minerServer) buildRequest(in *QueryRequest) (req *types.Request, err error) {
+	if in.GetDatabaseId() == "" {
+		err = status.Error(codes.InvalidArgument, "empty database id")
+		return
+	}
+	if in.GetTimestamp() == 0 {
+		err = status.Error(codes.InvalidArgument, "empty timestamp")
+		return
+	}
+	if len(in.GetQueries()) == 0 {
+		err = status.Error(codes.InvalidArgument, "empty queries")
+		return
+	}
+	var nodeID = proto.NodeID(in.GetNodeId())
+	if nodeID == "" {
+		nodeID = s.nodeID
+	}
+	var raw *hash.Hash
+	if raw, err = hash.NewHashFromStr(string(nodeID)); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "invalid node id: %v", err)
+		return
+	}
+	var queryType = types.ReadQuery
+	if in.GetType() == QueryType_QUERY_TYPE_WRITE {
+		queryType = types.WriteQuery
+	}
+	req = &types.Request{
+		Header: types.SignedRequestHeader{
+			RequestHeader: types.RequestHeader{
+				QueryType:    queryType,
+				NodeID:       nodeID,
+				DatabaseID:   proto.DatabaseID(in.GetDatabaseId()),
+				ConnectionID: in.GetConnectionId(),
+				SeqNo:        in.GetSeqNo(),
+				Timestamp:    time.Unix(0, in.GetTimestamp()).UTC(),
+			},
+		},
+	}
+	req.SetNodeID(&proto.RawNodeID{Hash: *raw})
+	if req.Payload.Queries, err = convertQueries(in.GetQueries()); err != nil {
+		err = status.Error(codes.InvalidArgument, err.Error())
+	}
+	return
+}
+
+// PrepareQuery implements MinerServer.PrepareQuery.
+func (s *minerServer) PrepareQuery(ctx context.Context, in *QueryRequest) (
+	out *PrepareResponse, err error,
+) {
+	var req *types.Request
+	if req, err = s.buildRequest(in); err != nil {
+		return
+	}
+	var h []byte
+	if h, err = prepareHash(req.Sign); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "hash request failed: %v", err)
+		return
+	}
+	out = &PrepareResponse{Hash: h}
+	return
+}
+
+// Query implements MinerServer.Query, the request runs on the local miner, which must be the
+// leader of the database for a write request.
+func (s *minerServer) Query(ctx context.Context, in *QueryRequest) (out *QueryResponse, err error) {
+	var (
+		req    *types.Request
+		signer asymmetric.Signer
+		res    = &types.Response{}
+	)
+	if req, err = s.buildRequest(in); err != nil {
+		return
+	}
+	if signer, err = presignedSigner(in.GetSignee(), in.GetSignature()); err != nil {
+		return
+	}
+	if err = req.Sign(signer); err != nil {
+		err = signStatus(err)
+		return
+	}
+	if err = s.backend.Query(req, res); err != nil {
+		err = backendStatus(errors.Wrap(err, "query failed"))
+		return
+	}
+	if out, err = convertResponse(res); err != nil {
+		err = status.Error(codes.Internal, err.Error())
+	}
+	return
+}
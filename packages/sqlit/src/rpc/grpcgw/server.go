@@ -0,0 +1,171 @@
+package grpcgw
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+
+	bp "sqlit/src/blockproducer"
+	"sqlit/src/client"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// ChainBackend is the main chain RPC methods served by the gateway, *bp.ChainRPCService
+// implements it.
+type ChainBackend interface {
+	AddTx(req *types.AddTxReq, resp *types.AddTxResp) error
+	QueryTxState(req *types.QueryTxStateReq, resp *types.QueryTxStateResp) error
+	QuerySQLChainProfile(req *types.QuerySQLChainProfileReq, resp *types.QuerySQLChainProfileResp) error
+}
+
+// DBMSBackend is the database RPC methods served by the gateway, *worker.DBMSRPCService
+// implements it.
+type DBMSBackend interface {
+	Query(req *types.Request, res *types.Response) error
+}
+
+// Config defines the gRPC gateway.
+type Config struct {
+	// ListenAddr is the address of the gRPC listener.
+	ListenAddr string
+	// CertFile and KeyFile are the PEM files of the TLS certificate and its private key, the
+	// listener serves plaintext if not set.
+	CertFile string
+	KeyFile  string
+	// NodeID is the node of the gateway, which sends the queries of the clients without a node.
+	NodeID proto.NodeID
+	// Chain serves the BlockProducer service if not nil.
+	Chain ChainBackend
+	// DBMS serves the Miner service if not nil.
+	DBMS DBMSBackend
+}
+
+// Server is the gRPC gateway of a block producer or miner.
+type Server struct {
+	cfg      *Config
+	server   *grpc.Server
+	listener net.Listener
+	wg       sync.WaitGroup
+}
+
+// NewServer returns a gateway serving the backends of cfg.
+func NewServer(cfg *Config) (s *Server, err error) {
+	if cfg.Chain == nil && cfg.DBMS == nil {
+		err = ErrNoBackend
+		return
+	}
+	var opts []grpc.ServerOption
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		var creds credentials.TransportCredentials
+		if creds, err = credentials.NewServerTLSFromFile(cfg.CertFile, cfg.KeyFile); err != nil {
+			err = errors.Wrap(err, "load grpc gateway certificate failed")
+			return
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	s = &Server{
+		cfg:    cfg,
+		server: grpc.NewServer(opts...),
+	}
+	if cfg.Chain != nil {
+		RegisterBlockProducerServer(s.server, &blockProducerServer{backend: cfg.Chain})
+	}
+	if cfg.DBMS != nil {
+		RegisterMinerServer(s.server, &minerServer{backend: cfg.DBMS, nodeID: cfg.NodeID})
+	}
+	return
+}
+
+// Start listens on the address of the config and serves in background.
+func (s *Server) Start() (err error) {
+	if s.listener, err = net.Listen("tcp", s.cfg.ListenAddr); err != nil {
+		err = errors.Wrapf(err, "listen grpc gateway on %s failed", s.cfg.ListenAddr)
+		return
+	}
+	log.WithField("addr", s.listener.Addr().String()).Info("grpc gateway started")
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.server.Serve(s.listener); err != nil {
+			log.WithError(err).Warning("grpc gateway stopped")
+		}
+	}()
+	return
+}
+
+// Addr returns the listening address, nil before Start.
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Stop stops the gateway after the running calls are finished.
+func (s *Server) Stop() {
+	s.server.GracefulStop()
+	s.wg.Wait()
+}
+
+// prepareHash runs sign with a signer recording the hash to sign, which fails the signing once
+// the hash is recorded.
+func prepareHash(sign func(asymmetric.Signer) error) (h []byte, err error) {
+	var signer = client.NewRemoteSigner(nil, func(_ context.Context, hash []byte) ([]byte, error) {
+		h = append([]byte(nil), hash...)
+		return nil, errors.New("hash recorded")
+	}, 0)
+	if err = sign(signer); h != nil {
+		err = nil
+	} else if err == nil {
+		err = errors.New("message signed without a hash")
+	}
+	return
+}
+
+// presignedSigner returns a signer answering the signature of the client, which is verified
+// against the hash and the signee before it's used.
+func presignedSigner(signee, signature []byte) (signer asymmetric.Signer, err error) {
+	if len(signee) == 0 || len(signature) == 0 {
+		err = status.Error(codes.Unauthenticated, ErrMissingSignature.Error())
+		return
+	}
+	var pub *asymmetric.PublicKey
+	if pub, err = asymmetric.ParsePubKey(signee); err != nil {
+		err = status.Errorf(codes.InvalidArgument, "invalid signee: %v", err)
+		return
+	}
+	signer = client.NewRemoteSigner(pub, func(context.Context, []byte) ([]byte, error) {
+		return signature, nil
+	}, 0)
+	return
+}
+
+// signStatus converts the error of signing with a presigned signer to a status error.
+func signStatus(err error) error {
+	if errors.Cause(err) == client.ErrRemoteSignature {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// backendStatus converts the error of a backend to a status error.
+func backendStatus(err error) error {
+	switch errors.Cause(err) {
+	case bp.ErrDatabaseNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
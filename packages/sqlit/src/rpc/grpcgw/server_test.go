@@ -0,0 +1,244 @@
+package grpcgw
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	bp "sqlit/src/blockproducer"
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+const testNodeID = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000a01")
+
+type fakeChain struct {
+	txs []*types.AddTxReq
+}
+
+func (c *fakeChain) AddTx(req *types.AddTxReq, _ *types.AddTxResp) error {
+	if err := req.Tx.Verify(); err != nil {
+		return err
+	}
+	c.txs = append(c.txs, req)
+	return nil
+}
+
+func (c *fakeChain) QueryTxState(req *types.QueryTxStateReq, resp *types.QueryTxStateResp) error {
+	resp.Hash = req.Hash
+	resp.State = pi.TransactionStateConfirmed
+	return nil
+}
+
+func (c *fakeChain) QuerySQLChainProfile(
+	req *types.QuerySQLChainProfileReq, resp *types.QuerySQLChainProfileResp) error {
+	if req.DBID != "db" {
+		return errors.Wrap(bp.ErrDatabaseNotFound, "query profile failed")
+	}
+	resp.Profile = types.SQLChainProfile{
+		ID:     "db",
+		Period: 60,
+		Owner:  proto.AccountAddress{1},
+		Miners: []*types.MinerInfo{{
+			NodeID: testNodeID, Name: "m0", Status: types.Normal, EncryptionKey: "secret",
+		}},
+		Users: []*types.SQLChainUser{{
+			Permission: types.UserPermissionFromRole(types.Write),
+			Status:     types.Arrears,
+		}},
+	}
+	return nil
+}
+
+type fakeDBMS struct {
+	reqs []*types.Request
+}
+
+func (d *fakeDBMS) Query(req *types.Request, res *types.Response) error {
+	if err := req.Verify(); err != nil {
+		return err
+	}
+	if req.Envelope.NodeID.String() != string(req.Header.NodeID) {
+		return errors.New("request node id mismatch")
+	}
+	d.reqs = append(d.reqs, req)
+	res.Header.NodeID = testNodeID
+	res.Header.LastInsertID = 7
+	res.Header.AffectedRows = 1
+	res.Payload = types.ResponsePayload{
+		Columns:   []string{"i", "s", "n"},
+		DeclTypes: []string{"INTEGER", "TEXT", ""},
+		Rows:      []types.ResponseRow{{Values: []interface{}{int64(1), "a", nil}}},
+	}
+	return nil
+}
+
+func signHash(key *asymmetric.PrivateKey, h []byte, compact bool) []byte {
+	sig, err := key.Sign(h)
+	So(err, ShouldBeNil)
+	if compact {
+		b := make([]byte, 64)
+		sig.R.FillBytes(b[:32])
+		sig.S.FillBytes(b[32:])
+		return b
+	}
+	return sig.Serialize()
+}
+
+func statusCode(err error) codes.Code {
+	s, _ := status.FromError(err)
+	return s.Code()
+}
+
+func TestServer(t *testing.T) {
+	Convey("Given a gateway over the fake backends", t, func() {
+		var (
+			chain = &fakeChain{}
+			dbms  = &fakeDBMS{}
+		)
+		_, err := NewServer(&Config{ListenAddr: "127.0.0.1:0"})
+		So(err, ShouldEqual, ErrNoBackend)
+		s, err := NewServer(&Config{
+			ListenAddr: "127.0.0.1:0",
+			NodeID:     testNodeID,
+			Chain:      chain,
+			DBMS:       dbms,
+		})
+		So(err, ShouldBeNil)
+		So(s.Start(), ShouldBeNil)
+		defer s.Stop()
+		conn, err := grpc.Dial(s.Addr().String(), grpc.WithInsecure())
+		So(err, ShouldBeNil)
+		defer conn.Close()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		key, pub, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		other, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+
+		Convey("The transactions should be signed over the prepared hash", func() {
+			var c = NewBlockProducerClient(conn)
+			tx, err := json.Marshal(types.NewUpdatePermission(&types.UpdatePermissionHeader{
+				TargetSQLChain: proto.AccountAddress{1},
+				TargetUser:     proto.AccountAddress{2},
+				Permission:     types.UserPermissionFromRole(types.Read),
+				Nonce:          1,
+			}))
+			So(err, ShouldBeNil)
+			prepared, err := c.PrepareTx(ctx, &TxRequest{Tx: string(tx)})
+			So(err, ShouldBeNil)
+			So(prepared.Hash, ShouldHaveLength, hash.HashSize)
+
+			for _, compact := range []bool{false, true} {
+				resp, err := c.AddTx(ctx, &TxRequest{
+					Tx:        string(tx),
+					Signee:    pub.Serialize(),
+					Signature: signHash(key, prepared.Hash, compact),
+				})
+				So(err, ShouldBeNil)
+				So(resp.Hash, ShouldResemble, prepared.Hash)
+			}
+			So(chain.txs, ShouldHaveLength, 2)
+			So(chain.txs[0].Tx.GetTransactionType(), ShouldEqual, pi.TransactionTypeUpdatePermission)
+
+			_, err = c.AddTx(ctx, &TxRequest{
+				Tx:        string(tx),
+				Signee:    pub.Serialize(),
+				Signature: signHash(other, prepared.Hash, false),
+			})
+			So(statusCode(err), ShouldEqual, codes.Unauthenticated)
+			_, err = c.AddTx(ctx, &TxRequest{Tx: string(tx)})
+			So(statusCode(err), ShouldEqual, codes.Unauthenticated)
+			_, err = c.AddTx(ctx, &TxRequest{Tx: `{"TxType":1000}`})
+			So(statusCode(err), ShouldEqual, codes.InvalidArgument)
+			So(chain.txs, ShouldHaveLength, 2)
+		})
+		Convey("The tx states and profiles should be converted", func() {
+			var c = NewBlockProducerClient(conn)
+			h := hash.THashH([]byte("tx"))
+			state, err := c.QueryTxState(ctx, &QueryTxStateRequest{Hash: h[:]})
+			So(err, ShouldBeNil)
+			So(state.State, ShouldEqual, TxState_TX_STATE_CONFIRMED)
+			So(state.Hash, ShouldResemble, h[:])
+			_, err = c.QueryTxState(ctx, &QueryTxStateRequest{Hash: []byte{1}})
+			So(statusCode(err), ShouldEqual, codes.InvalidArgument)
+
+			profile, err := c.QuerySQLChainProfile(ctx, &QuerySQLChainProfileRequest{DatabaseId: "db"})
+			So(err, ShouldBeNil)
+			So(profile.DatabaseId, ShouldEqual, "db")
+			So(profile.Period, ShouldEqual, 60)
+			So(profile.Miners, ShouldHaveLength, 1)
+			So(profile.Miners[0].NodeId, ShouldEqual, string(testNodeID))
+			So(profile.Miners[0].Status, ShouldEqual, Status_STATUS_NORMAL)
+			So(profile.Users, ShouldHaveLength, 1)
+			So(profile.Users[0].Role, ShouldEqual, int32(types.Write))
+			So(profile.Users[0].Status, ShouldEqual, Status_STATUS_ARREARS)
+			_, err = c.QuerySQLChainProfile(ctx, &QuerySQLChainProfileRequest{DatabaseId: "none"})
+			So(statusCode(err), ShouldEqual, codes.NotFound)
+		})
+		Convey("The queries should be signed over the prepared hash", func() {
+			var (
+				c   = NewMinerClient(conn)
+				now = time.Now()
+				req = &QueryRequest{
+					DatabaseId:   "db",
+					Type:         QueryType_QUERY_TYPE_WRITE,
+					ConnectionId: 1,
+					SeqNo:        2,
+					Timestamp:    now.UnixNano(),
+					Queries: []*Query{{
+						Pattern: "INSERT INTO t VALUES (?, ?, ?, ?)",
+						Args: []*NamedArg{
+							{Value: &Value{Kind: &Value_IntValue{IntValue: 1}}},
+							{Value: &Value{Kind: &Value_StringValue{StringValue: "a"}}},
+							{Value: &Value{Kind: &Value_TimeValue{TimeValue: now.UnixNano()}}},
+							{Value: &Value{}},
+						},
+					}},
+				}
+			)
+			prepared, err := c.PrepareQuery(ctx, req)
+			So(err, ShouldBeNil)
+			req.Signee = pub.Serialize()
+			req.Signature = signHash(key, prepared.Hash, false)
+			resp, err := c.Query(ctx, req)
+			So(err, ShouldBeNil)
+			So(resp.Columns, ShouldResemble, []string{"i", "s", "n"})
+			So(resp.LastInsertId, ShouldEqual, 7)
+			So(resp.Rows, ShouldHaveLength, 1)
+			So(resp.Rows[0].Values[0].GetIntValue(), ShouldEqual, 1)
+			So(resp.Rows[0].Values[1].GetStringValue(), ShouldEqual, "a")
+			So(resp.Rows[0].Values[2].GetKind(), ShouldBeNil)
+
+			So(dbms.reqs, ShouldHaveLength, 1)
+			var native = dbms.reqs[0]
+			So(native.Header.NodeID, ShouldEqual, testNodeID)
+			So(native.Header.QueryType, ShouldEqual, types.WriteQuery)
+			So(native.Header.Timestamp.Equal(now), ShouldBeTrue)
+			So(native.Payload.Queries[0].Args[0].Value, ShouldEqual, int64(1))
+			So(native.Payload.Queries[0].Args[2].Value.(time.Time).Equal(now), ShouldBeTrue)
+			So(native.Payload.Queries[0].Args[3].Value, ShouldBeNil)
+
+			// a modified request doesn't match the signature
+			req.SeqNo = 3
+			_, err = c.Query(ctx, req)
+			So(statusCode(err), ShouldEqual, codes.Unauthenticated)
+			req.Timestamp = 0
+			_, err = c.Query(ctx, req)
+			So(statusCode(err), ShouldEqual, codes.InvalidArgument)
+			So(dbms.reqs, ShouldHaveLength, 1)
+		})
+	})
+}
@@ -0,0 +1,290 @@
+// The service glue of sqlit.proto in the shape of the protoc-gen-go grpc plugin output for
+// google.golang.org/grpc v1.21, which the newer protoc-gen-go-grpc does not support.
+
+package grpcgw
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// This is a compile-time assertion to ensure that this file is compatible with the grpc package
+// it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// BlockProducerClient is the client API of the BlockProducer service.
+//
+// BlockProducer serves the main chain APIs of a block producer.
+type BlockProducerClient interface {
+	// PrepareTx returns the hash of a transaction to sign.
+	PrepareTx(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	// AddTx verifies a signed transaction and adds it to the transaction pool.
+	AddTx(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*AddTxResponse, error)
+	// QueryTxState returns the state of a transaction.
+	QueryTxState(ctx context.Context, in *QueryTxStateRequest, opts ...grpc.CallOption) (*QueryTxStateResponse, error)
+	// QuerySQLChainProfile returns the profile of a database.
+	QuerySQLChainProfile(ctx context.Context, in *QuerySQLChainProfileRequest, opts ...grpc.CallOption) (*SQLChainProfile, error)
+}
+
+type blockProducerClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewBlockProducerClient returns a BlockProducerClient over the connection.
+func NewBlockProducerClient(cc *grpc.ClientConn) BlockProducerClient {
+	return &blockProducerClient{cc}
+}
+
+func (c *blockProducerClient) PrepareTx(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.BlockProducer/PrepareTx", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockProducerClient) AddTx(ctx context.Context, in *TxRequest, opts ...grpc.CallOption) (*AddTxResponse, error) {
+	out := new(AddTxResponse)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.BlockProducer/AddTx", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockProducerClient) QueryTxState(ctx context.Context, in *QueryTxStateRequest, opts ...grpc.CallOption) (*QueryTxStateResponse, error) {
+	out := new(QueryTxStateResponse)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.BlockProducer/QueryTxState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *blockProducerClient) QuerySQLChainProfile(ctx context.Context, in *QuerySQLChainProfileRequest, opts ...grpc.CallOption) (*SQLChainProfile, error) {
+	out := new(SQLChainProfile)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.BlockProducer/QuerySQLChainProfile", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// BlockProducerServer is the server API of the BlockProducer service.
+type BlockProducerServer interface {
+	// PrepareTx returns the hash of a transaction to sign.
+	PrepareTx(context.Context, *TxRequest) (*PrepareResponse, error)
+	// AddTx verifies a signed transaction and adds it to the transaction pool.
+	AddTx(context.Context, *TxRequest) (*AddTxResponse, error)
+	// QueryTxState returns the state of a transaction.
+	QueryTxState(context.Context, *QueryTxStateRequest) (*QueryTxStateResponse, error)
+	// QuerySQLChainProfile returns the profile of a database.
+	QuerySQLChainProfile(context.Context, *QuerySQLChainProfileRequest) (*SQLChainProfile, error)
+}
+
+// RegisterBlockProducerServer registers the BlockProducer service implementation to the grpc server.
+func RegisterBlockProducerServer(s *grpc.Server, srv BlockProducerServer) {
+	s.RegisterService(&_BlockProducer_serviceDesc, srv)
+}
+
+func _BlockProducer_PrepareTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockProducerServer).PrepareTx(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.BlockProducer/PrepareTx",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockProducerServer).PrepareTx(ctx, req.(*TxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlockProducer_AddTx_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(TxRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockProducerServer).AddTx(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.BlockProducer/AddTx",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockProducerServer).AddTx(ctx, req.(*TxRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlockProducer_QueryTxState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryTxStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockProducerServer).QueryTxState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.BlockProducer/QueryTxState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockProducerServer).QueryTxState(ctx, req.(*QueryTxStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _BlockProducer_QuerySQLChainProfile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuerySQLChainProfileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(BlockProducerServer).QuerySQLChainProfile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.BlockProducer/QuerySQLChainProfile",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(BlockProducerServer).QuerySQLChainProfile(ctx, req.(*QuerySQLChainProfileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _BlockProducer_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "sqlit.v1.BlockProducer",
+	HandlerType: (*BlockProducerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PrepareTx",
+			Handler:    _BlockProducer_PrepareTx_Handler,
+		},
+		{
+			MethodName: "AddTx",
+			Handler:    _BlockProducer_AddTx_Handler,
+		},
+		{
+			MethodName: "QueryTxState",
+			Handler:    _BlockProducer_QueryTxState_Handler,
+		},
+		{
+			MethodName: "QuerySQLChainProfile",
+			Handler:    _BlockProducer_QuerySQLChainProfile_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sqlit.proto",
+}
+
+// MinerClient is the client API of the Miner service.
+//
+// Miner serves the database queries of a miner.
+type MinerClient interface {
+	// PrepareQuery returns the hash of a query request to sign.
+	PrepareQuery(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*PrepareResponse, error)
+	// Query verifies a signed query request and runs it on the miner.
+	Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error)
+}
+
+type minerClient struct {
+	cc *grpc.ClientConn
+}
+
+// NewMinerClient returns a MinerClient over the connection.
+func NewMinerClient(cc *grpc.ClientConn) MinerClient {
+	return &minerClient{cc}
+}
+
+func (c *minerClient) PrepareQuery(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*PrepareResponse, error) {
+	out := new(PrepareResponse)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.Miner/PrepareQuery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *minerClient) Query(ctx context.Context, in *QueryRequest, opts ...grpc.CallOption) (*QueryResponse, error) {
+	out := new(QueryResponse)
+	err := c.cc.Invoke(ctx, "/sqlit.v1.Miner/Query", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// MinerServer is the server API of the Miner service.
+type MinerServer interface {
+	// PrepareQuery returns the hash of a query request to sign.
+	PrepareQuery(context.Context, *QueryRequest) (*PrepareResponse, error)
+	// Query verifies a signed query request and runs it on the miner.
+	Query(context.Context, *QueryRequest) (*QueryResponse, error)
+}
+
+// RegisterMinerServer registers the Miner service implementation to the grpc server.
+func RegisterMinerServer(s *grpc.Server, srv MinerServer) {
+	s.RegisterService(&_Miner_serviceDesc, srv)
+}
+
+func _Miner_PrepareQuery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinerServer).PrepareQuery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.Miner/PrepareQuery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinerServer).PrepareQuery(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Miner_Query_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QueryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MinerServer).Query(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/sqlit.v1.Miner/Query",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MinerServer).Query(ctx, req.(*QueryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Miner_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "sqlit.v1.Miner",
+	HandlerType: (*MinerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "PrepareQuery",
+			Handler:    _Miner_PrepareQuery_Handler,
+		},
+		{
+			MethodName: "Query",
+			Handler:    _Miner_Query_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "sqlit.proto",
+}
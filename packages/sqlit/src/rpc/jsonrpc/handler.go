@@ -2,8 +2,10 @@ package jsonrpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
@@ -82,3 +84,21 @@ func (h *Handler) handle(ctx context.Context, conn *jsonrpc2.Conn, req *jsonrpc2
 
 	return fn(ctx, conn, req)
 }
+
+// Call dispatches a method call with the raw JSON params on the connection, it's used to serve
+// the calls embedded in another request such as a batch.
+func (h *Handler) Call(
+	ctx context.Context, conn *jsonrpc2.Conn, method string, params *json.RawMessage) (
+	result interface{}, err error,
+) {
+	return h.handle(ctx, conn, &jsonrpc2.Request{Method: method, Params: params})
+}
+
+// Methods returns the sorted names of the registered methods.
+func (h *Handler) Methods() (methods []string) {
+	for k := range h.methods {
+		methods = append(methods, k)
+	}
+	sort.Strings(methods)
+	return
+}
@@ -1,31 +1,116 @@
-
 package mux
 
 import (
+	"expvar"
 	"net"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	mux "github.com/xtaci/smux"
+	mw "github.com/zserge/metric"
 
 	"sqlit/src/conf"
 	"sqlit/src/proto"
 	"sqlit/src/rpc"
 )
 
+const (
+	// DefaultPoolWaitTimeout defines the default max time to wait for a free stream to a node
+	// reaching its stream limit.
+	DefaultPoolWaitTimeout = 10 * time.Second
+	// DefaultPoolProbeInterval defines the default interval of the session health probes.
+	DefaultPoolProbeInterval = 30 * time.Second
+
+	mwPoolState    = "service:rpc:mux:pool"
+	mwPoolWaitTime = "t_wait:rpc:mux:pool"
+)
+
+var (
+	// ErrPoolExhausted indicates that no stream to the node is freed in the wait timeout.
+	ErrPoolExhausted = errors.New("session pool exhausted")
+
+	poolWaitTime = mw.NewHistogram("10s1s", "1m5s", "1h1m")
+)
+
+func init() {
+	expvar.Publish(mwPoolState, expvar.Func(func() interface{} { return defaultPool.Stats() }))
+	expvar.Publish(mwPoolWaitTime, poolWaitTime)
+}
+
+// Limits defines the limits of the sessions to a remote node.
+type Limits struct {
+	// MaxSessions is the max physical connections to the node, 0 means
+	// conf.MaxRPCMuxPoolPhysicalConnection.
+	MaxSessions int
+	// MaxStreams is the max concurrent streams to the node, 0 means unlimited. A Get over the
+	// limit waits for a stream to be closed.
+	MaxStreams int
+	// WaitTimeout is the max time a Get waits for a free stream, 0 means DefaultPoolWaitTimeout.
+	WaitTimeout time.Duration
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxSessions <= 0 {
+		l.MaxSessions = conf.MaxRPCMuxPoolPhysicalConnection
+	}
+	if l.WaitTimeout <= 0 {
+		l.WaitTimeout = DefaultPoolWaitTimeout
+	}
+	return l
+}
+
+// NodeStats is the state of the sessions to a remote node.
+type NodeStats struct {
+	NodeID proto.NodeID
+	Limits
+	// Sessions is the count of the physical connections.
+	Sessions int
+	// ActiveStreams is the count of the streams not closed yet.
+	ActiveStreams int
+	// Waiting is the count of the Get calls waiting for a free stream.
+	Waiting int
+	// Waits, WaitTimeouts and Evictions are the total counts of the Get calls waited, the ones
+	// timed out, and the broken physical connections evicted.
+	Waits        uint64
+	WaitTimeouts uint64
+	Evictions    uint64
+}
+
 // Session is the Session type of SessionPool.
 type Session struct {
 	sync.RWMutex
 	target proto.NodeID
 	sess   []*mux.Session
 	offset int
+
+	// streamLock guards the stream accounting below, it's never held while dialing
+	streamLock sync.Mutex
+	limits     Limits
+	active     int
+	waiting    int
+	wake       chan struct{}
+
+	waits        uint64
+	waitTimeouts uint64
+	evictions    uint64
 }
 
 // SessionPool is the struct type of session pool.
 type SessionPool struct {
 	sync.RWMutex
 	sessions map[proto.NodeID]*Session
+
+	// limits and nodeLimits are loaded from conf.GConf.RPCPool once, and replaced by SetLimits
+	// and SetNodeLimits
+	confLoaded    bool
+	limits        *Limits
+	nodeLimits    map[proto.NodeID]Limits
+	probeInterval time.Duration
+	probeStop     chan struct{}
 }
 
 var (
@@ -39,6 +124,19 @@ func GetSessionPoolInstance() *SessionPool {
 	return defaultPool
 }
 
+// pooledStream releases the stream slot of the session once it's closed.
+type pooledStream struct {
+	*mux.Stream
+	once    sync.Once
+	release func()
+}
+
+// Close closes the stream and releases its slot.
+func (c *pooledStream) Close() error {
+	c.once.Do(c.release)
+	return c.Stream.Close()
+}
+
 // Close closes the session.
 func (s *Session) Close() error {
 	s.Lock()
@@ -56,12 +154,100 @@ func (s *Session) Close() error {
 	return nil
 }
 
+// acquire takes a stream slot of the session, it waits for a slot to be released if the
+// session reaches its stream limit.
+func (s *Session) acquire() (err error) {
+	var (
+		start   time.Time
+		timeout time.Duration
+		timer   *time.Timer
+	)
+	for {
+		s.streamLock.Lock()
+		if s.limits.MaxStreams <= 0 || s.active < s.limits.MaxStreams {
+			s.active++
+			if timer != nil {
+				s.waiting--
+			}
+			s.streamLock.Unlock()
+			break
+		}
+		if timer == nil {
+			s.waiting++
+			timeout = s.limits.withDefaults().WaitTimeout
+			start = time.Now()
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+			atomic.AddUint64(&s.waits, 1)
+		}
+		if s.wake == nil {
+			s.wake = make(chan struct{})
+		}
+		wake := s.wake
+		s.streamLock.Unlock()
+
+		select {
+		case <-wake:
+		case <-timer.C:
+			s.streamLock.Lock()
+			s.waiting--
+			s.streamLock.Unlock()
+			atomic.AddUint64(&s.waitTimeouts, 1)
+			poolWaitTime.Add(time.Since(start).Seconds())
+			err = errors.Wrapf(ErrPoolExhausted, "no free stream to %s in %s", s.target, timeout)
+			return
+		}
+	}
+	if timer != nil {
+		poolWaitTime.Add(time.Since(start).Seconds())
+	}
+	return
+}
+
+// release returns a stream slot of the session and wakes up the waiting calls.
+func (s *Session) release() {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	s.active--
+	s.notify()
+}
+
+// notify wakes up the waiting calls, the stream lock must be held.
+func (s *Session) notify() {
+	if s.wake != nil {
+		close(s.wake)
+		s.wake = nil
+	}
+}
+
+func (s *Session) setLimits(l Limits) {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	s.limits = l
+	s.notify()
+}
+
+func (s *Session) maxSessions() int {
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	return s.limits.withDefaults().MaxSessions
+}
+
 // Get returns new connection from session.
 func (s *Session) Get() (conn rpc.Client, err error) {
+	if err = s.acquire(); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			s.release()
+		}
+	}()
+
 	s.Lock()
 	defer s.Unlock()
 	s.offset++
-	s.offset %= conf.MaxRPCMuxPoolPhysicalConnection
+	s.offset %= s.maxSessions()
 
 	var (
 		sess     *mux.Session
@@ -90,11 +276,72 @@ func (s *Session) Get() (conn rpc.Client, err error) {
 			sessions = append(sessions, s.sess[0:s.offset]...)
 			sessions = append(sessions, s.sess[s.offset+1:]...)
 			s.sess = sessions
+			_ = sess.Close()
+			atomic.AddUint64(&s.evictions, 1)
+			continue
+		}
+
+		return rpc.NewClient(&pooledStream{Stream: stream, release: s.release}), nil
+	}
+}
+
+// probe evicts the closed physical connections and the idle ones failing to open a stream, it
+// returns the count of the evicted connections.
+func (s *Session) probe() (evicted int) {
+	s.RLock()
+	var sessions = append([]*mux.Session(nil), s.sess...)
+	s.RUnlock()
+
+	// probe without the lock, a stuck connection may block the stream opening
+	var broken = make(map[*mux.Session]bool)
+	for _, sess := range sessions {
+		if sess.IsClosed() {
+			broken[sess] = true
+			continue
+		}
+		if sess.NumStreams() > 0 {
+			continue
+		}
+		if stream, err := sess.OpenStream(); err != nil {
+			broken[sess] = true
+		} else {
+			_ = stream.Close()
+		}
+	}
+	if len(broken) == 0 {
+		return
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	var alive []*mux.Session
+	for _, sess := range s.sess {
+		if broken[sess] {
+			_ = sess.Close()
+			evicted++
 			continue
 		}
+		alive = append(alive, sess)
+	}
+	s.sess = alive
+	atomic.AddUint64(&s.evictions, uint64(evicted))
+	return
+}
 
-		return rpc.NewClient(stream), nil
+func (s *Session) stats() (stats NodeStats) {
+	stats = NodeStats{
+		NodeID:       s.target,
+		Sessions:     s.Len(),
+		Waits:        atomic.LoadUint64(&s.waits),
+		WaitTimeouts: atomic.LoadUint64(&s.waitTimeouts),
+		Evictions:    atomic.LoadUint64(&s.evictions),
 	}
+	s.streamLock.Lock()
+	defer s.streamLock.Unlock()
+	stats.Limits = s.limits.withDefaults()
+	stats.ActiveStreams = s.active
+	stats.Waiting = s.waiting
+	return
 }
 
 // Len returns physical connection count.
@@ -117,10 +364,104 @@ func (s *Session) newSession() (sess *mux.Session, err error) {
 	return newSession(s.target, false)
 }
 
+// loadConf loads the limits from conf.GConf.RPCPool once, the pool lock must be held.
+func (p *SessionPool) loadConf() {
+	if p.confLoaded {
+		return
+	}
+	p.confLoaded = true
+	if conf.GConf == nil || conf.GConf.RPCPool == nil {
+		return
+	}
+	var info = conf.GConf.RPCPool
+	p.limits = &Limits{
+		MaxSessions: info.MaxSessions,
+		MaxStreams:  info.MaxStreams,
+		WaitTimeout: info.WaitTimeout,
+	}
+	for id, l := range info.Nodes {
+		if p.nodeLimits == nil {
+			p.nodeLimits = make(map[proto.NodeID]Limits)
+		}
+		p.nodeLimits[id] = Limits{
+			MaxSessions: l.MaxSessions,
+			MaxStreams:  l.MaxStreams,
+			WaitTimeout: l.WaitTimeout,
+		}
+	}
+	p.probeInterval = info.ProbeInterval
+}
+
+// limitsOf returns the limits of node id, the pool lock must be held.
+func (p *SessionPool) limitsOf(id proto.NodeID) Limits {
+	if l, ok := p.nodeLimits[id]; ok {
+		return l
+	}
+	if p.limits != nil {
+		return *p.limits
+	}
+	return Limits{}
+}
+
+// SetLimits sets the limits of the nodes without their own limits, it applies to the existing
+// sessions at once.
+func (p *SessionPool) SetLimits(l Limits) {
+	p.Lock()
+	defer p.Unlock()
+	p.loadConf()
+	p.limits = &l
+	for id, sess := range p.sessions {
+		if _, ok := p.nodeLimits[id]; !ok {
+			sess.setLimits(l)
+		}
+	}
+}
+
+// SetNodeLimits sets the limits of node id, it applies to the existing session at once.
+func (p *SessionPool) SetNodeLimits(id proto.NodeID, l Limits) {
+	p.Lock()
+	defer p.Unlock()
+	p.loadConf()
+	if p.nodeLimits == nil {
+		p.nodeLimits = make(map[proto.NodeID]Limits)
+	}
+	p.nodeLimits[id] = l
+	if sess, ok := p.sessions[id]; ok {
+		sess.setLimits(l)
+	}
+}
+
+// startProbe starts the periodical session health probes, the pool lock must be held.
+func (p *SessionPool) startProbe() {
+	if p.probeStop != nil || p.probeInterval < 0 {
+		return
+	}
+	var interval = p.probeInterval
+	if interval == 0 {
+		interval = DefaultPoolProbeInterval
+	}
+	var stop = make(chan struct{})
+	p.probeStop = stop
+	go func() {
+		var ticker = time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.Probe()
+			}
+		}
+	}()
+}
+
 func (p *SessionPool) getSession(id proto.NodeID) (sess *Session, loaded bool) {
 	// NO Blocking operation in this function
 	p.Lock()
 	defer p.Unlock()
+	p.loadConf()
+	p.startProbe()
 	sess, exist := p.sessions[id]
 	if exist {
 		//log.WithField("node", id).Debug("load session for target node")
@@ -129,12 +470,44 @@ func (p *SessionPool) getSession(id proto.NodeID) (sess *Session, loaded bool) {
 		// new session
 		sess = &Session{
 			target: id,
+			limits: p.limitsOf(id),
 		}
 		p.sessions[id] = sess
 	}
 	return
 }
 
+// Probe evicts the broken physical connections in the pool, which is run periodically by the
+// pool, it returns the count of the evicted connections.
+func (p *SessionPool) Probe() (evicted int) {
+	p.RLock()
+	var sessions = make([]*Session, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.RUnlock()
+	for _, s := range sessions {
+		evicted += s.probe()
+	}
+	return
+}
+
+// Stats returns the state of the sessions to each node, sorted by the node id.
+func (p *SessionPool) Stats() (stats []NodeStats) {
+	p.RLock()
+	var sessions = make([]*Session, 0, len(p.sessions))
+	for _, s := range p.sessions {
+		sessions = append(sessions, s)
+	}
+	p.RUnlock()
+	stats = make([]NodeStats, 0, len(sessions))
+	for _, s := range sessions {
+		stats = append(stats, s.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].NodeID < stats[j].NodeID })
+	return
+}
+
 // Get returns existing session to the node, if not exist try best to create one.
 func (p *SessionPool) Get(id proto.NodeID) (conn rpc.Client, err error) {
 	var sess *Session
@@ -222,6 +595,10 @@ func (p *SessionPool) Close() error {
 		}
 	}
 	p.sessions = make(map[proto.NodeID]*Session)
+	if p.probeStop != nil {
+		close(p.probeStop)
+		p.probeStop = nil
+	}
 	if len(errmsgs) > 0 {
 		return errors.Wrap(errors.New(strings.Join(errmsgs, ", ")), "close session pool")
 	}
@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 	"sync"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"sqlit/src/conf"
@@ -174,3 +176,95 @@ func TestNewSessionPool(t *testing.T) {
 		So(GetSessionPoolInstance() == GetSessionPoolInstance(), ShouldBeTrue)
 	})
 }
+
+func TestSessionPoolLimits(t *testing.T) {
+	Convey("Given a session pool to a test server", t, func() {
+		log.SetLevel(log.FatalLevel)
+		p := &SessionPool{
+			sessions: make(map[proto.NodeID]*Session),
+		}
+		defer withTCPDialer()()
+		defer func() { _ = p.Close() }()
+
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		So(err, ShouldBeNil)
+		server, err := NewServerWithService(ServiceMap{"Test": NewTestService()})
+		So(err, ShouldBeNil)
+		server.SetListener(l)
+		go server.WithAcceptConnFunc(rpc.AcceptRawConn).Serve()
+		defer server.Stop()
+		var node = proto.NodeID(l.Addr().String())
+
+		Convey("The calls over the stream limit should wait for the running ones", func() {
+			p.SetNodeLimits(node, Limits{MaxStreams: 1, WaitTimeout: 100 * time.Millisecond})
+			c1, err := p.Get(node)
+			So(err, ShouldBeNil)
+			So(c1.Call("Test.IncCounter", &TestReq{Step: 1}, &TestRep{}), ShouldBeNil)
+
+			_, err = p.Get(node)
+			So(errors.Cause(err), ShouldEqual, ErrPoolExhausted)
+
+			var done = make(chan error, 1)
+			go func() {
+				c2, err := p.Get(node)
+				if err == nil {
+					err = c2.Call("Test.IncCounter", &TestReq{Step: 1}, &TestRep{})
+					_ = c2.Close()
+				}
+				done <- err
+			}()
+			time.Sleep(20 * time.Millisecond)
+			stats := p.Stats()
+			So(stats, ShouldHaveLength, 1)
+			So(stats[0].NodeID, ShouldEqual, node)
+			So(stats[0].ActiveStreams, ShouldEqual, 1)
+			So(stats[0].Waiting, ShouldEqual, 1)
+			So(c1.Close(), ShouldBeNil)
+			So(<-done, ShouldBeNil)
+
+			stats = p.Stats()
+			So(stats[0].ActiveStreams, ShouldEqual, 0)
+			So(stats[0].Waiting, ShouldEqual, 0)
+			So(stats[0].Waits, ShouldEqual, 2)
+			So(stats[0].WaitTimeouts, ShouldEqual, 1)
+			So(stats[0].MaxStreams, ShouldEqual, 1)
+			So(stats[0].MaxSessions, ShouldEqual, conf.MaxRPCMuxPoolPhysicalConnection)
+		})
+		Convey("The node limits should replace the pool limits", func() {
+			p.SetLimits(Limits{MaxStreams: 1})
+			p.SetNodeLimits(node, Limits{MaxSessions: 1})
+			var clients []rpc.Client
+			for i := 0; i < 3; i++ {
+				c, err := p.Get(node)
+				So(err, ShouldBeNil)
+				clients = append(clients, c)
+			}
+			So(p.Len(), ShouldEqual, 1)
+			So(p.Stats()[0].ActiveStreams, ShouldEqual, 3)
+			for _, c := range clients {
+				So(c.Close(), ShouldBeNil)
+			}
+		})
+		Convey("The broken sessions should be evicted by the probes", func() {
+			c, err := p.Get(node)
+			So(err, ShouldBeNil)
+			So(c.Call("Test.IncCounter", &TestReq{Step: 1}, &TestRep{}), ShouldBeNil)
+			So(c.Close(), ShouldBeNil)
+			So(p.Probe(), ShouldEqual, 0)
+			So(p.Len(), ShouldEqual, 1)
+
+			sess, _ := p.getSession(node)
+			sess.RLock()
+			_ = sess.sess[0].Close()
+			sess.RUnlock()
+			So(p.Probe(), ShouldEqual, 1)
+			So(p.Len(), ShouldEqual, 0)
+			So(p.Stats()[0].Evictions, ShouldEqual, 1)
+
+			c, err = p.Get(node)
+			So(err, ShouldBeNil)
+			So(c.Call("Test.IncCounter", &TestReq{Step: 1}, &TestRep{}), ShouldBeNil)
+			So(c.Close(), ShouldBeNil)
+		})
+	})
+}
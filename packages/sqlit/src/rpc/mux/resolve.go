@@ -8,6 +8,7 @@ import (
 
 	"github.com/pkg/errors"
 
+	"sqlit/src/conf"
 	"sqlit/src/crypto/kms"
 	"sqlit/src/naconn"
 	"sqlit/src/proto"
@@ -66,6 +67,7 @@ func (r *Resolver) ResolveEx(id *proto.RawNodeID) (*proto.Node, error) {
 
 func init() {
 	naconn.RegisterResolver(&Resolver{})
+	naconn.RegisterNamedResolver(naconn.DHTResolverName, &Resolver{})
 }
 
 // GetNodeAddr tries best to get node addr.
@@ -148,7 +150,8 @@ func PingBP(node *proto.Node, BPNodeID proto.NodeID) (err error) {
 	client := NewCaller()
 
 	req := &proto.PingReq{
-		Node: *node,
+		Node:     *node,
+		Features: conf.SupportedFeatures(),
 	}
 
 	resp := new(proto.PingResp)
@@ -157,6 +160,12 @@ func PingBP(node *proto.Node, BPNodeID proto.NodeID) (err error) {
 		err = errors.Wrap(err, "call DHT.Ping failed")
 		return
 	}
+	if missing := conf.MissingFeatures(resp.Features); resp.Features != nil && len(missing) > 0 {
+		log.WithFields(log.Fields{
+			"bp":      BPNodeID,
+			"missing": missing,
+		}).Warning("block producer does not support scheduled protocol features")
+	}
 	return
 }
 
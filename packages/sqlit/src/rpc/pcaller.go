@@ -2,6 +2,7 @@
 package rpc
 
 import (
+	"context"
 	"io"
 	"net/rpc"
 	"strings"
@@ -12,6 +13,7 @@ import (
 
 	"sqlit/src/proto"
 	"sqlit/src/route"
+	"sqlit/src/utils/fault"
 )
 
 // PersistentCaller is a wrapper for session pooling and RPC calling.
@@ -54,12 +56,21 @@ func (c *PersistentCaller) Call(method string, args interface{}, reply interface
 		recordRPCCost(startTime, method, err)
 	}()
 
+	dup, err := fault.Apply(context.Background(), fault.RPCCall, fault.Key(string(c.TargetID), method))
+	if err != nil {
+		err = errors.Wrapf(err, "call %s failed", method)
+		return
+	}
+
 	isAnonymous := (method == route.DHTPing.String())
 	err = c.initClient(isAnonymous)
 	if err != nil {
 		err = errors.Wrap(err, "init PersistentCaller client failed")
 		return
 	}
+	for i := 0; i < dup; i++ {
+		_ = c.client.Call(method, args, reply)
+	}
 	err = c.client.Call(method, args, reply)
 	if err != nil {
 		if err == io.EOF ||
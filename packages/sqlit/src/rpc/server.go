@@ -10,10 +10,12 @@ import (
 
 	"github.com/pkg/errors"
 
+	"sqlit/src/conf"
 	"sqlit/src/crypto/kms"
 	"sqlit/src/naconn"
 	"sqlit/src/proto"
 	"sqlit/src/utils/log"
+	"sqlit/src/utils/proxyproto"
 )
 
 // ServiceMap maps service name to service instance.
@@ -73,7 +75,7 @@ func (s *Server) InitRPCServer(
 		return
 	}
 
-	s.SetListener(l)
+	s.SetListener(proxyproto.Wrap(l, conf.GConf != nil && conf.GConf.ProxyProtocol))
 
 	return
 }
@@ -96,14 +98,15 @@ serverLoop:
 			if err != nil {
 				continue
 			}
-			log.WithField("remote", conn.RemoteAddr().String()).Info("accept")
 			go s.serveConn(conn)
 		}
 	}
 }
 
 func (s *Server) serveConn(conn net.Conn) {
+	// the remote address may be read from the PROXY header, keep it out of the accept loop
 	le := log.WithField("remote_addr", conn.RemoteAddr())
+	le.Info("accept")
 	stream, err := s.acceptConn(s.ctx, conn)
 	if err != nil {
 		le.WithError(err).Error("failed to accept conn")
@@ -0,0 +1,123 @@
+package sdk
+
+import (
+	"context"
+	"strconv"
+	"strings"
+)
+
+// SelectBuilder builds a select query of a table with the positional arguments.
+type SelectBuilder struct {
+	table   string
+	columns []string
+	where   []string
+	args    []interface{}
+	orderBy []string
+	limit   int
+	offset  int
+}
+
+// From starts a select query of table.
+func From(table string) *SelectBuilder {
+	return &SelectBuilder{table: table, limit: -1}
+}
+
+// Columns sets the selected columns, all the columns are selected by default.
+func (b *SelectBuilder) Columns(columns ...string) *SelectBuilder {
+	b.columns = append(b.columns, columns...)
+	return b
+}
+
+// ColumnsOf selects the mapped columns of the struct v.
+func (b *SelectBuilder) ColumnsOf(v interface{}) *SelectBuilder {
+	if columns, err := Columns(v); err == nil {
+		b.columns = append(b.columns, columns...)
+	}
+	return b
+}
+
+// Where adds a condition with its positional arguments, the conditions are joined by AND.
+func (b *SelectBuilder) Where(cond string, args ...interface{}) *SelectBuilder {
+	b.where = append(b.where, "("+cond+")")
+	b.args = append(b.args, args...)
+	return b
+}
+
+// WhereEq adds the condition that column equals value.
+func (b *SelectBuilder) WhereEq(column string, value interface{}) *SelectBuilder {
+	return b.Where(quoteIdent(column)+" = ?", value)
+}
+
+// WhereIn adds the condition that column is one of values, no row matches the empty values.
+func (b *SelectBuilder) WhereIn(column string, values ...interface{}) *SelectBuilder {
+	if len(values) == 0 {
+		return b.Where("0")
+	}
+	return b.Where(quoteIdent(column)+" IN (?"+strings.Repeat(", ?", len(values)-1)+")", values...)
+}
+
+// OrderBy adds the ordering terms like "id DESC".
+func (b *SelectBuilder) OrderBy(terms ...string) *SelectBuilder {
+	b.orderBy = append(b.orderBy, terms...)
+	return b
+}
+
+// Limit sets the maximum number of the rows, a negative limit means no limit.
+func (b *SelectBuilder) Limit(limit int) *SelectBuilder {
+	b.limit = limit
+	return b
+}
+
+// Offset sets the number of the rows skipped.
+func (b *SelectBuilder) Offset(offset int) *SelectBuilder {
+	b.offset = offset
+	return b
+}
+
+// Build returns the query and its arguments.
+func (b *SelectBuilder) Build() (query string, args []interface{}) {
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	if len(b.columns) == 0 {
+		sb.WriteString("*")
+	} else {
+		for i, c := range b.columns {
+			if i > 0 {
+				sb.WriteString(", ")
+			}
+			sb.WriteString(quoteIdent(c))
+		}
+	}
+	sb.WriteString(" FROM ")
+	sb.WriteString(quoteIdent(b.table))
+	if len(b.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(b.where, " AND "))
+	}
+	if len(b.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(b.orderBy, ", "))
+	}
+	if b.limit >= 0 || b.offset > 0 {
+		// sqlite requires a limit before the offset
+		sb.WriteString(" LIMIT ")
+		sb.WriteString(strconv.Itoa(b.limit))
+	}
+	if b.offset > 0 {
+		sb.WriteString(" OFFSET ")
+		sb.WriteString(strconv.Itoa(b.offset))
+	}
+	return sb.String(), append([]interface{}(nil), b.args...)
+}
+
+// Select runs the query and scans all the rows into the slice dst points to.
+func (b *SelectBuilder) Select(ctx context.Context, db Queryer, dst interface{}) error {
+	query, args := b.Build()
+	return Select(ctx, db, dst, query, args...)
+}
+
+// Get runs the query and scans the first row into the struct dst points to.
+func (b *SelectBuilder) Get(ctx context.Context, db Queryer, dst interface{}) error {
+	query, args := b.Limit(1).Build()
+	return Get(ctx, db, dst, query, args...)
+}
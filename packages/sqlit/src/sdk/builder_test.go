@@ -0,0 +1,26 @@
+package sdk
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSelectBuilder(t *testing.T) {
+	Convey("The select queries should be built", t, func() {
+		q, args := From("users").Build()
+		So(q, ShouldEqual, `SELECT * FROM "users"`)
+		So(args, ShouldBeEmpty)
+
+		q, args = From("users").Columns("id", "name").
+			Where("age > ? OR age < ?", 60, 18).WhereEq("city", "Jeju").WhereIn("role", "a", "b").
+			OrderBy("id DESC").Limit(10).Offset(20).Build()
+		So(q, ShouldEqual, `SELECT "id", "name" FROM "users" WHERE (age > ? OR age < ?) AND `+
+			`("city" = ?) AND ("role" IN (?, ?)) ORDER BY id DESC LIMIT 10 OFFSET 20`)
+		So(args, ShouldResemble, []interface{}{60, 18, "Jeju", "a", "b"})
+
+		q, _ = From("users").ColumnsOf(&testUser{}).WhereIn("id").Offset(5).Build()
+		So(q, ShouldEqual, `SELECT "id", "name", "email", "created_at", "score" FROM "users" `+
+			`WHERE (0) LIMIT -1 OFFSET 5`)
+	})
+}
@@ -0,0 +1,28 @@
+/*
+Package sdk implements a thin convenience layer over database/sql and the SQLit driver.
+
+Structs are mapped to the table columns by the `sqlit` field tags:
+
+	type User struct {
+		ID      int64  `sqlit:"id,pk,auto"`
+		Name    string `sqlit:"name"`
+		Email   string `sqlit:"email,omitempty"`
+		Ignored string `sqlit:"-"`
+	}
+
+The untagged exported fields are mapped to the snake case of their names, and the fields of the
+embedded structs are flattened. The pk option marks the primary key columns used by Update and
+Delete, the auto option marks the column filled by the database on insert, and the omitempty
+option leaves the column to its default on insert if the field is the zero value.
+
+	err = sdk.Insert(ctx, db, "users", &user)
+	err = sdk.From("users").Where("name LIKE ?", "a%").OrderBy("id").Limit(10).Select(ctx, db, &users)
+
+Migrate applies the versioned schema migrations in order. The miners replay every write
+deterministically, so the statements can't use the stateful functions like CURRENT_TIMESTAMP or
+random(). Each migration is sent with its record as a single request, which is applied as a whole
+by the databases with the read uncommitted isolation level or the group commit. With the default
+isolation level a failed migration may leave its preceding statements applied, so the statements
+should be idempotent like CREATE TABLE IF NOT EXISTS.
+*/
+package sdk
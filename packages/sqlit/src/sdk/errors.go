@@ -0,0 +1,16 @@
+package sdk
+
+import "github.com/pkg/errors"
+
+var (
+	// ErrInvalidDestination indicates the destination is not a pointer to a struct or a slice.
+	ErrInvalidDestination = errors.New("invalid destination")
+	// ErrInvalidStruct indicates the value is not a struct or its mapping is invalid.
+	ErrInvalidStruct = errors.New("invalid struct")
+	// ErrNoPrimaryKey indicates the struct has no primary key column.
+	ErrNoPrimaryKey = errors.New("struct has no primary key")
+	// ErrInvalidMigration indicates the migration list is invalid.
+	ErrInvalidMigration = errors.New("invalid migration")
+	// ErrMigrationChanged indicates an applied migration has been changed.
+	ErrMigrationChanged = errors.New("applied migration changed")
+)
@@ -0,0 +1,460 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// Execer runs the write queries, both *sql.DB and *sql.Tx are Execers.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Queryer runs the read queries, both *sql.DB and *sql.Tx are Queryers. Note that the read
+// queries are refused in the transactions of SQLit.
+type Queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// field is a struct field mapped to a column.
+type field struct {
+	column    string
+	index     []int
+	pk        bool
+	auto      bool
+	omitEmpty bool
+}
+
+// structMap is the column mapping of a struct type.
+type structMap struct {
+	fields   []*field
+	byColumn map[string]*field
+	pk       []*field
+}
+
+var structMaps sync.Map // reflect.Type -> *structMap
+
+// getStructMap returns the cached column mapping of the struct type t.
+func getStructMap(t reflect.Type) (m *structMap, err error) {
+	if v, ok := structMaps.Load(t); ok {
+		return v.(*structMap), nil
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, errors.Wrapf(ErrInvalidStruct, "%s is not a struct", t)
+	}
+	m = &structMap{byColumn: make(map[string]*field)}
+	if err = m.add(t, nil); err != nil {
+		return
+	}
+	if len(m.fields) == 0 {
+		return nil, errors.Wrapf(ErrInvalidStruct, "%s has no mapped field", t)
+	}
+	structMaps.Store(t, m)
+	return
+}
+
+func (m *structMap) add(t reflect.Type, index []int) (err error) {
+	for i := 0; i < t.NumField(); i++ {
+		var (
+			sf       = t.Field(i)
+			tag, ok  = sf.Tag.Lookup("sqlit")
+			opts     = strings.Split(tag, ",")
+			fieldIdx = append(append([]int{}, index...), i)
+		)
+		if tag == "-" {
+			continue
+		}
+		if sf.Anonymous && !ok && sf.Type.Kind() == reflect.Struct {
+			if err = m.add(sf.Type, fieldIdx); err != nil {
+				return
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			// unexported
+			continue
+		}
+		f := &field{column: opts[0], index: fieldIdx}
+		if f.column == "" {
+			f.column = snakeCase(sf.Name)
+		}
+		for _, o := range opts[1:] {
+			switch o {
+			case "pk":
+				f.pk = true
+			case "auto":
+				f.auto = true
+			case "omitempty":
+				f.omitEmpty = true
+			default:
+				return errors.Wrapf(ErrInvalidStruct, "unknown option %s of field %s", o, sf.Name)
+			}
+		}
+		if _, ok := m.byColumn[f.column]; ok {
+			return errors.Wrapf(ErrInvalidStruct, "duplicate column %s", f.column)
+		}
+		m.fields = append(m.fields, f)
+		m.byColumn[f.column] = f
+		if f.pk {
+			m.pk = append(m.pk, f)
+		}
+	}
+	return
+}
+
+// snakeCase converts the field name like UserID to user_id.
+func snakeCase(name string) string {
+	var (
+		b     strings.Builder
+		runes = []rune(name)
+	)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// quoteIdent quotes the sqlite identifier s.
+func quoteIdent(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
+
+// structValue returns the struct value v points to or is.
+func structValue(v interface{}) (rv reflect.Value, m *structMap, err error) {
+	rv = reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			err = errors.Wrap(ErrInvalidStruct, "nil pointer")
+			return
+		}
+		rv = rv.Elem()
+	}
+	m, err = getStructMap(rv.Type())
+	return
+}
+
+// Columns returns the mapped columns of the struct v, which is handy to build the select list.
+func Columns(v interface{}) (columns []string, err error) {
+	t := reflect.TypeOf(v)
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil {
+		return nil, errors.Wrap(ErrInvalidStruct, "nil value")
+	}
+	m, err := getStructMap(t)
+	if err != nil {
+		return
+	}
+	columns = make([]string, len(m.fields))
+	for i, f := range m.fields {
+		columns[i] = f.column
+	}
+	return
+}
+
+// insertColumns returns the columns inserted from the struct value rv.
+func (m *structMap) insertColumns(rv reflect.Value) (fields []*field) {
+	for _, f := range m.fields {
+		if (f.auto || f.omitEmpty) && rv.FieldByIndex(f.index).IsZero() {
+			continue
+		}
+		fields = append(fields, f)
+	}
+	return
+}
+
+// Insert inserts the struct v into table. If v is a pointer and the struct has an auto column,
+// the column is set to the last insert id, which is unknown in the transactions.
+func Insert(ctx context.Context, db Execer, table string, v interface{}) (err error) {
+	rv, m, err := structValue(v)
+	if err != nil {
+		return
+	}
+	var (
+		fields = m.insertColumns(rv)
+		cols   = make([]string, len(fields))
+		marks  = make([]string, len(fields))
+		args   = make([]interface{}, len(fields))
+	)
+	for i, f := range fields {
+		cols[i] = quoteIdent(f.column)
+		marks[i] = "?"
+		args[i] = rv.FieldByIndex(f.index).Interface()
+	}
+	var query = "INSERT INTO " + quoteIdent(table)
+	if len(fields) == 0 {
+		query += " DEFAULT VALUES"
+	} else {
+		query += " (" + strings.Join(cols, ", ") + ") VALUES (" + strings.Join(marks, ", ") + ")"
+	}
+	res, err := db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrapf(err, "insert into %s", table)
+	}
+	if !rv.CanSet() {
+		return
+	}
+	for _, f := range m.fields {
+		if !f.auto || !rv.FieldByIndex(f.index).IsZero() {
+			continue
+		}
+		if id, _ := res.LastInsertId(); id != 0 {
+			setInt(rv.FieldByIndex(f.index), id)
+		}
+		break
+	}
+	return
+}
+
+func setInt(v reflect.Value, i int64) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v.SetUint(uint64(i))
+	}
+}
+
+// InsertAll inserts the slice of structs v into table in a single statement, which is a single
+// request to the miners. All the mapped columns except the auto ones are inserted.
+func InsertAll(ctx context.Context, db Execer, table string, v interface{}) (err error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Slice {
+		return errors.Wrap(ErrInvalidStruct, "not a slice")
+	}
+	if rv.Len() == 0 {
+		return
+	}
+	et := rv.Type().Elem()
+	for et.Kind() == reflect.Ptr {
+		et = et.Elem()
+	}
+	m, err := getStructMap(et)
+	if err != nil {
+		return
+	}
+	var (
+		fields []*field
+		cols   []string
+		marks  []string
+		rowSQL string
+		rows   = make([]string, rv.Len())
+		args   = make([]interface{}, 0, rv.Len()*len(m.fields))
+	)
+	for _, f := range m.fields {
+		if !f.auto {
+			fields = append(fields, f)
+			cols = append(cols, quoteIdent(f.column))
+			marks = append(marks, "?")
+		}
+	}
+	if len(fields) == 0 {
+		return errors.Wrapf(ErrInvalidStruct, "%s has no inserted column", et)
+	}
+	rowSQL = "(" + strings.Join(marks, ", ") + ")"
+	for i := 0; i < rv.Len(); i++ {
+		ev := rv.Index(i)
+		for ev.Kind() == reflect.Ptr {
+			if ev.IsNil() {
+				return errors.Wrapf(ErrInvalidStruct, "nil element %d", i)
+			}
+			ev = ev.Elem()
+		}
+		for _, f := range fields {
+			args = append(args, ev.FieldByIndex(f.index).Interface())
+		}
+		rows[i] = rowSQL
+	}
+	if _, err = db.ExecContext(ctx, "INSERT INTO "+quoteIdent(table)+" ("+strings.Join(cols, ", ")+
+		") VALUES "+strings.Join(rows, ", "), args...); err != nil {
+		err = errors.Wrapf(err, "insert into %s", table)
+	}
+	return
+}
+
+// pkCondition returns the where condition and args of the primary key of the struct value rv.
+func (m *structMap) pkCondition(rv reflect.Value) (cond string, args []interface{}, err error) {
+	if len(m.pk) == 0 {
+		err = errors.Wrapf(ErrNoPrimaryKey, "%s", rv.Type())
+		return
+	}
+	conds := make([]string, len(m.pk))
+	for i, f := range m.pk {
+		conds[i] = quoteIdent(f.column) + " = ?"
+		args = append(args, rv.FieldByIndex(f.index).Interface())
+	}
+	cond = strings.Join(conds, " AND ")
+	return
+}
+
+// Update updates the row of the struct v in table by the primary key, and returns the number of
+// the affected rows.
+func Update(ctx context.Context, db Execer, table string, v interface{}) (affected int64, err error) {
+	rv, m, err := structValue(v)
+	if err != nil {
+		return
+	}
+	var (
+		sets []string
+		args []interface{}
+	)
+	for _, f := range m.fields {
+		if f.pk || f.auto {
+			continue
+		}
+		sets = append(sets, quoteIdent(f.column)+" = ?")
+		args = append(args, rv.FieldByIndex(f.index).Interface())
+	}
+	if len(sets) == 0 {
+		return 0, errors.Wrapf(ErrInvalidStruct, "%s has no updated column", rv.Type())
+	}
+	cond, pkArgs, err := m.pkCondition(rv)
+	if err != nil {
+		return
+	}
+	res, err := db.ExecContext(ctx, "UPDATE "+quoteIdent(table)+" SET "+strings.Join(sets, ", ")+
+		" WHERE "+cond, append(args, pkArgs...)...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "update %s", table)
+	}
+	affected, _ = res.RowsAffected()
+	return
+}
+
+// Delete deletes the row of the struct v from table by the primary key, and returns the number
+// of the affected rows.
+func Delete(ctx context.Context, db Execer, table string, v interface{}) (affected int64, err error) {
+	rv, m, err := structValue(v)
+	if err != nil {
+		return
+	}
+	cond, args, err := m.pkCondition(rv)
+	if err != nil {
+		return
+	}
+	res, err := db.ExecContext(ctx, "DELETE FROM "+quoteIdent(table)+" WHERE "+cond, args...)
+	if err != nil {
+		return 0, errors.Wrapf(err, "delete from %s", table)
+	}
+	affected, _ = res.RowsAffected()
+	return
+}
+
+// Get runs the query and scans the first row into the struct dst points to, it returns
+// sql.ErrNoRows if there is no row.
+func Get(ctx context.Context, db Queryer, dst interface{}, query string, args ...interface{}) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return errors.Wrap(ErrInvalidDestination, "not a pointer to struct")
+	}
+	m, err := getStructMap(rv.Elem().Type())
+	if err != nil {
+		return
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		if err = rows.Err(); err == nil {
+			err = sql.ErrNoRows
+		}
+		return
+	}
+	targets, err := m.scanTargets(rows)
+	if err != nil {
+		return
+	}
+	if err = rows.Scan(targets.bind(rv.Elem())...); err != nil {
+		return
+	}
+	return rows.Err()
+}
+
+// Select runs the query and scans all the rows into the slice of structs or struct pointers dst
+// points to.
+func Select(ctx context.Context, db Queryer, dst interface{}, query string, args ...interface{}) (err error) {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return errors.Wrap(ErrInvalidDestination, "not a pointer to slice")
+	}
+	var (
+		sv    = rv.Elem()
+		et    = sv.Type().Elem()
+		isPtr = et.Kind() == reflect.Ptr
+	)
+	if isPtr {
+		et = et.Elem()
+	}
+	m, err := getStructMap(et)
+	if err != nil {
+		return
+	}
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	targets, err := m.scanTargets(rows)
+	if err != nil {
+		return
+	}
+	for rows.Next() {
+		ev := reflect.New(et)
+		if err = rows.Scan(targets.bind(ev.Elem())...); err != nil {
+			return
+		}
+		if !isPtr {
+			ev = ev.Elem()
+		}
+		sv = reflect.Append(sv, ev)
+	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+	rv.Elem().Set(sv)
+	return
+}
+
+// scanTargets are the fields scanned from the result columns, nil for the unmapped columns.
+type scanTargets []*field
+
+func (m *structMap) scanTargets(rows *sql.Rows) (targets scanTargets, err error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return
+	}
+	targets = make(scanTargets, len(columns))
+	for i, c := range columns {
+		targets[i] = m.byColumn[c]
+	}
+	return
+}
+
+// bind returns the scan destinations in the struct value rv.
+func (t scanTargets) bind(rv reflect.Value) (dest []interface{}) {
+	dest = make([]interface{}, len(t))
+	for i, f := range t {
+		if f == nil {
+			dest[i] = new(interface{})
+			continue
+		}
+		dest[i] = rv.FieldByIndex(f.index).Addr().Interface()
+	}
+	return
+}
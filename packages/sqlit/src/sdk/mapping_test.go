@@ -0,0 +1,151 @@
+package sdk
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/client"
+)
+
+type testBase struct {
+	CreatedAt int64
+}
+
+type testUser struct {
+	ID    int64  `sqlit:"id,pk,auto"`
+	Name  string `sqlit:"name"`
+	Email string `sqlit:"email,omitempty"`
+	testBase
+	Score   *float64
+	Ignored string `sqlit:"-"`
+	ignored string
+}
+
+// openDevDB opens a development database in a temporary directory.
+func openDevDB(ctx context.Context) (db *sql.DB, cleanup func(), err error) {
+	dir, err := os.MkdirTemp("", "sqlit_sdk")
+	if err != nil {
+		return
+	}
+	cleanup = func() { _ = os.RemoveAll(dir) }
+	dsn := client.DevDSN(filepath.Join(dir, "dev.db3"))
+	if err = client.WaitDBCreation(ctx, dsn); err != nil {
+		return
+	}
+	if db, err = sql.Open(client.DBScheme, dsn); err != nil {
+		return
+	}
+	cleanup = func() {
+		_ = db.Close()
+		_ = os.RemoveAll(dir)
+	}
+	return
+}
+
+func TestStructMap(t *testing.T) {
+	Convey("The struct fields should be mapped to the columns", t, func() {
+		columns, err := Columns([]*testUser{})
+		So(err, ShouldBeNil)
+		So(columns, ShouldResemble, []string{"id", "name", "email", "created_at", "score"})
+		for in, out := range map[string]string{
+			"ID": "id", "UserID": "user_id", "HTTPServer": "http_server", "CreatedAt": "created_at",
+		} {
+			So(snakeCase(in), ShouldEqual, out)
+		}
+
+		_, err = Columns(1)
+		So(errors.Cause(err), ShouldEqual, ErrInvalidStruct)
+		_, err = Columns(struct {
+			A int `sqlit:"a,unique"`
+		}{})
+		So(errors.Cause(err), ShouldEqual, ErrInvalidStruct)
+		_, err = Columns(struct {
+			A int `sqlit:"a"`
+			B int `sqlit:"a"`
+		}{})
+		So(errors.Cause(err), ShouldEqual, ErrInvalidStruct)
+	})
+}
+
+func TestMapping(t *testing.T) {
+	Convey("Given a development database", t, func() {
+		var ctx = context.Background()
+		db, cleanup, err := openDevDB(ctx)
+		So(err, ShouldBeNil)
+		defer cleanup()
+		_, err = db.Exec(`CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, ` +
+			`email TEXT NOT NULL DEFAULT 'none', created_at INTEGER, score REAL)`)
+		So(err, ShouldBeNil)
+
+		Convey("The structs should be inserted and selected", func() {
+			score := 1.5
+			u := &testUser{Name: "alice", Score: &score, testBase: testBase{CreatedAt: 100}}
+			So(Insert(ctx, db, "users", u), ShouldBeNil)
+			So(u.ID, ShouldEqual, 1)
+			So(InsertAll(ctx, db, "users", []testUser{
+				{Name: "bob", Email: "bob@jeju"},
+				{Name: "carol", Email: "carol@jeju"},
+			}), ShouldBeNil)
+
+			var got testUser
+			So(Get(ctx, db, &got, `SELECT * FROM users WHERE id = ?`, 1), ShouldBeNil)
+			So(got.Name, ShouldEqual, "alice")
+			So(got.Email, ShouldEqual, "none")
+			So(got.CreatedAt, ShouldEqual, 100)
+			So(*got.Score, ShouldEqual, 1.5)
+			So(Get(ctx, db, &got, `SELECT * FROM users WHERE id = ?`, 9), ShouldEqual, sql.ErrNoRows)
+
+			var users []*testUser
+			So(From("users").ColumnsOf(users).WhereIn("name", "bob", "carol").OrderBy("id DESC").
+				Select(ctx, db, &users), ShouldBeNil)
+			So(users, ShouldHaveLength, 2)
+			So(users[0].Name, ShouldEqual, "carol")
+			So(users[0].Score, ShouldBeNil)
+			So(users[1].Email, ShouldEqual, "bob@jeju")
+
+			got.Name = "alice2"
+			affected, err := Update(ctx, db, "users", &got)
+			So(err, ShouldBeNil)
+			So(affected, ShouldEqual, 1)
+			var names []struct{ Name string }
+			So(Select(ctx, db, &names, `SELECT name, 1 AS unmapped FROM users ORDER BY id`), ShouldBeNil)
+			So(names, ShouldHaveLength, 3)
+			So(names[0].Name, ShouldEqual, "alice2")
+
+			affected, err = Delete(ctx, db, "users", users[0])
+			So(err, ShouldBeNil)
+			So(affected, ShouldEqual, 1)
+			So(From("users").WhereEq("name", "carol").Get(ctx, db, &got), ShouldEqual, sql.ErrNoRows)
+		})
+		Convey("The structs should be inserted in transactions", func() {
+			So(client.ExecuteTx(ctx, db, nil, func(tx *sql.Tx) error {
+				for _, n := range []string{"dave", "erin"} {
+					if err := Insert(ctx, tx, "users", &testUser{Name: n}); err != nil {
+						return err
+					}
+				}
+				return nil
+			}), ShouldBeNil)
+			var users []testUser
+			So(From("users").Select(ctx, db, &users), ShouldBeNil)
+			So(users, ShouldHaveLength, 2)
+		})
+		Convey("The invalid destinations should be refused", func() {
+			var u testUser
+			So(errors.Cause(Get(ctx, db, u, `SELECT * FROM users`)), ShouldEqual, ErrInvalidDestination)
+			So(errors.Cause(Select(ctx, db, &u, `SELECT * FROM users`)), ShouldEqual, ErrInvalidDestination)
+			_, err = Update(ctx, db, "users", &struct {
+				ID int64 `sqlit:"id,pk"`
+			}{})
+			So(errors.Cause(err), ShouldEqual, ErrInvalidStruct)
+			_, err = Delete(ctx, db, "users", &struct{ Name string }{})
+			So(errors.Cause(err), ShouldEqual, ErrNoPrimaryKey)
+		})
+	})
+}
@@ -0,0 +1,168 @@
+package sdk
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/client"
+	x "sqlit/src/dpos"
+)
+
+// MigrationsTable is the table recording the applied migrations.
+const MigrationsTable = "__sqlit_migrations"
+
+var (
+	createMigrationsSQL = `CREATE TABLE IF NOT EXISTS "` + MigrationsTable + `" (
+	"version" INTEGER PRIMARY KEY,
+	"name" TEXT NOT NULL,
+	"checksum" TEXT NOT NULL,
+	"applied_at" INTEGER NOT NULL)`
+	listMigrationsSQL  = `SELECT "version", "checksum" FROM "` + MigrationsTable + `" ORDER BY "version"`
+	insertMigrationSQL = `INSERT INTO "` + MigrationsTable +
+		`" ("version", "name", "checksum", "applied_at") VALUES (?, ?, ?, ?)`
+
+	// the transaction of a migration is the request itself
+	txKeywords = map[string]bool{
+		"begin": true, "commit": true, "end": true, "rollback": true, "savepoint": true, "release": true,
+	}
+)
+
+// Migration defines a versioned schema migration.
+type Migration struct {
+	// Version orders the migrations, it must be positive and unique.
+	Version int64
+	Name    string
+	// Statements are applied in a single request with the migration record.
+	Statements []string
+}
+
+// checksum returns the hash of the migration statements, which detects the edited migrations
+// after they are applied.
+func (m *Migration) checksum() string {
+	h := sha256.New()
+	for _, s := range m.Statements {
+		_, _ = h.Write([]byte(strings.TrimSpace(s)))
+		_, _ = h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// check refuses the statements which the miners would refuse, or which control the transaction.
+func (m *Migration) check() (err error) {
+	if m.Version <= 0 {
+		return errors.Wrapf(ErrInvalidMigration, "invalid version %d", m.Version)
+	}
+	if len(m.Statements) == 0 {
+		return errors.Wrapf(ErrInvalidMigration, "migration %d has no statement", m.Version)
+	}
+	for _, s := range m.Statements {
+		for _, q := range strings.Split(s, ";") {
+			if words := strings.Fields(strings.ToLower(q)); len(words) > 0 && txKeywords[words[0]] {
+				return errors.Wrapf(ErrInvalidMigration,
+					"migration %d controls the transaction: %s", m.Version, strings.TrimSpace(q))
+			}
+		}
+		if err = x.CheckQuery(s); err != nil {
+			return errors.Wrapf(ErrInvalidMigration, "migration %d: %v", m.Version, err)
+		}
+	}
+	return
+}
+
+// Migrate applies the migrations which are not applied yet in the version order, and returns the
+// versions applied. Each migration is applied with its record in a single request, so a failed
+// migration is not recorded and the concurrent migrators can't apply a version twice. The applied
+// migrations must not be changed.
+func Migrate(ctx context.Context, db *sql.DB, migrations []Migration) (applied []int64, err error) {
+	var pending = make([]*Migration, len(migrations))
+	for i := range migrations {
+		pending[i] = &migrations[i]
+		if err = pending[i].check(); err != nil {
+			return
+		}
+	}
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Version < pending[j].Version })
+	for i := 1; i < len(pending); i++ {
+		if pending[i].Version == pending[i-1].Version {
+			return nil, errors.Wrapf(ErrInvalidMigration, "duplicate version %d", pending[i].Version)
+		}
+	}
+
+	if _, err = db.ExecContext(ctx, createMigrationsSQL); err != nil {
+		return nil, errors.Wrap(err, "create migrations table")
+	}
+	done, err := appliedMigrations(ctx, db)
+	if err != nil {
+		return
+	}
+	for _, m := range pending {
+		checksum := m.checksum()
+		if c, ok := done[m.Version]; ok {
+			if c != checksum {
+				return applied, errors.Wrapf(ErrMigrationChanged, "migration %d", m.Version)
+			}
+			continue
+		}
+		// the time of the record is set by the client, as the miners refuse CURRENT_TIMESTAMP
+		if err = client.ExecuteTx(ctx, db, nil, func(tx *sql.Tx) (err error) {
+			for _, s := range m.Statements {
+				if _, err = tx.ExecContext(ctx, s); err != nil {
+					return
+				}
+			}
+			_, err = tx.ExecContext(ctx, insertMigrationSQL, m.Version, m.Name, checksum, time.Now().Unix())
+			return
+		}); err != nil {
+			return applied, errors.Wrapf(err, "apply migration %d", m.Version)
+		}
+		applied = append(applied, m.Version)
+	}
+	return
+}
+
+// MigrationVersion returns the latest applied migration version, 0 if there is none.
+func MigrationVersion(ctx context.Context, db Queryer) (version int64, err error) {
+	done, err := appliedMigrations(ctx, db)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			err = nil
+		}
+		return
+	}
+	for v := range done {
+		if v > version {
+			version = v
+		}
+	}
+	return
+}
+
+func appliedMigrations(ctx context.Context, db Queryer) (done map[int64]string, err error) {
+	rows, err := db.QueryContext(ctx, listMigrationsSQL)
+	if err != nil {
+		return nil, errors.Wrap(err, "list migrations")
+	}
+	defer func() { _ = rows.Close() }()
+	done = make(map[int64]string)
+	for rows.Next() {
+		var (
+			version  int64
+			checksum string
+		)
+		if err = rows.Scan(&version, &checksum); err != nil {
+			return nil, errors.Wrap(err, "list migrations")
+		}
+		done[version] = checksum
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "list migrations")
+	}
+	return
+}
@@ -0,0 +1,78 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMigrate(t *testing.T) {
+	Convey("Given a development database", t, func() {
+		var ctx = context.Background()
+		db, cleanup, err := openDevDB(ctx)
+		So(err, ShouldBeNil)
+		defer cleanup()
+		var migrations = []Migration{
+			{Version: 2, Name: "index", Statements: []string{`CREATE INDEX users_name ON users (name)`}},
+			{Version: 1, Name: "users", Statements: []string{
+				`CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)`,
+				`INSERT INTO users (name) VALUES ('root')`,
+			}},
+		}
+		version, err := MigrationVersion(ctx, db)
+		So(err, ShouldBeNil)
+		So(version, ShouldEqual, 0)
+
+		Convey("The migrations should be applied once in order", func() {
+			applied, err := Migrate(ctx, db, migrations)
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, []int64{1, 2})
+			applied, err = Migrate(ctx, db, migrations)
+			So(err, ShouldBeNil)
+			So(applied, ShouldBeEmpty)
+			version, err = MigrationVersion(ctx, db)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 2)
+
+			migrations[0].Statements = []string{`CREATE INDEX users_name2 ON users (name)`}
+			_, err = Migrate(ctx, db, migrations)
+			So(errors.Cause(err), ShouldEqual, ErrMigrationChanged)
+		})
+		Convey("The failed migration should not be recorded", func() {
+			migrations = append(migrations, Migration{Version: 3, Statements: []string{
+				`CREATE TABLE IF NOT EXISTS posts (id INTEGER PRIMARY KEY)`,
+				`INSERT INTO missing VALUES (1)`,
+			}})
+			applied, err := Migrate(ctx, db, migrations)
+			So(err, ShouldNotBeNil)
+			So(applied, ShouldResemble, []int64{1, 2})
+			version, err = MigrationVersion(ctx, db)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 2)
+
+			migrations[2].Statements[1] = `INSERT INTO posts VALUES (1)`
+			applied, err = Migrate(ctx, db, migrations)
+			So(err, ShouldBeNil)
+			So(applied, ShouldResemble, []int64{3})
+		})
+		Convey("The invalid migrations should be refused before applying", func() {
+			for _, m := range []Migration{
+				{Version: 0, Statements: []string{`CREATE TABLE t (id)`}},
+				{Version: 1},
+				{Version: 1, Statements: []string{`BEGIN; CREATE TABLE t (id); COMMIT`}},
+				{Version: 1, Statements: []string{`CREATE TABLE t (id, at DEFAULT CURRENT_TIMESTAMP)`}},
+				{Version: 1, Statements: []string{`CREATE TABLE sqlite_t (id)`}},
+			} {
+				_, err = Migrate(ctx, db, []Migration{m})
+				So(errors.Cause(err), ShouldEqual, ErrInvalidMigration)
+			}
+			_, err = Migrate(ctx, db, append(migrations, Migration{Version: 1, Statements: []string{`SELECT 1`}}))
+			So(errors.Cause(err), ShouldEqual, ErrInvalidMigration)
+			version, err = MigrationVersion(ctx, db)
+			So(err, ShouldBeNil)
+			So(version, ShouldEqual, 0)
+		})
+	})
+}
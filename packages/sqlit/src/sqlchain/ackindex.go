@@ -2,8 +2,11 @@
 package sqlchain
 
 import (
+	"bytes"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -95,6 +98,29 @@ func (i *multiAckIndex) acks() (ret []*types.SignedAckHeader) {
 	return
 }
 
+func (i *multiAckIndex) unacked(before time.Time) (ret []*types.SignedResponseHeader) {
+	i.RLock()
+	defer i.RUnlock()
+	for _, v := range i.respIndex {
+		if v.Timestamp.Before(before) {
+			ret = append(ret, v)
+		}
+	}
+	return
+}
+
+func (i *multiAckIndex) expireResponse(resp *types.SignedResponseHeader) (ok bool) {
+	var key = resp.Request.GetQueryKey()
+	i.Lock()
+	defer i.Unlock()
+	if oresp, exist := i.respIndex[key]; exist && oresp.Hash() == resp.Hash() {
+		delete(i.respIndex, key)
+		atomic.AddInt32(&i.owner.responseCount, -1)
+		ok = true
+	}
+	return
+}
+
 func (i *multiAckIndex) expire() {
 	i.RLock()
 	defer i.RUnlock()
@@ -221,3 +247,53 @@ func (i *ackIndex) acks(h int32) (ret []*types.SignedAckHeader) {
 	}
 	return
 }
+
+func (i *ackIndex) lookup(h int32) (mi *multiAckIndex, ok bool) {
+	i.RLock()
+	defer i.RUnlock()
+	mi, ok = i.hi[h]
+	return
+}
+
+// ack returns the registered ack of the query key at height h, or nil if it's not found.
+func (i *ackIndex) ack(h int32, key types.QueryKey) *types.SignedAckHeader {
+	if mi, ok := i.lookup(h); ok {
+		mi.RLock()
+		defer mi.RUnlock()
+		return mi.ackIndex[key]
+	}
+	return nil
+}
+
+// unacked returns the responses responded before the time without acks, in the order of the
+// response time.
+func (i *ackIndex) unacked(before time.Time) (ret []*types.SignedResponseHeader) {
+	var mis []*multiAckIndex
+	func() {
+		i.RLock()
+		defer i.RUnlock()
+		for _, v := range i.hi {
+			mis = append(mis, v)
+		}
+	}()
+	for _, v := range mis {
+		ret = append(ret, v.unacked(before)...)
+	}
+	sort.Slice(ret, func(x, y int) bool {
+		if !ret[x].Timestamp.Equal(ret[y].Timestamp) {
+			return ret[x].Timestamp.Before(ret[y].Timestamp)
+		}
+		hx, hy := ret[x].Hash(), ret[y].Hash()
+		return bytes.Compare(hx[:], hy[:]) < 0
+	})
+	return
+}
+
+// expireResponse removes the response at height h without an ack, it returns whether the
+// response is removed.
+func (i *ackIndex) expireResponse(h int32, resp *types.SignedResponseHeader) bool {
+	if mi, ok := i.lookup(h); ok {
+		return mi.expireResponse(resp)
+	}
+	return false
+}
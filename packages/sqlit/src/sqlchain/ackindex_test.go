@@ -3,6 +3,7 @@ package sqlchain
 
 import (
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 
@@ -40,5 +41,26 @@ func TestAckIndex(t *testing.T) {
 			err = ai.remove(0, ack)
 			So(err, ShouldBeNil)
 		})
+		Convey("Unacked responses should be listed and expired", func() {
+			var key = resp.Request.GetQueryKey()
+			err = ai.addResponse(0, resp)
+			So(err, ShouldBeNil)
+			So(ai.unacked(resp.Timestamp), ShouldBeEmpty)
+			So(ai.unacked(resp.Timestamp.Add(time.Second)), ShouldResemble,
+				[]*types.SignedResponseHeader{resp})
+			So(ai.expireResponse(1, resp), ShouldBeFalse)
+			So(ai.expireResponse(0, resp), ShouldBeTrue)
+			So(ai.unacked(resp.Timestamp.Add(time.Second)), ShouldBeEmpty)
+			So(ai.responseCount, ShouldEqual, 0)
+			So(ai.ack(0, key), ShouldBeNil)
+
+			err = ai.addResponse(0, resp)
+			So(err, ShouldBeNil)
+			err = ai.register(0, ack)
+			So(err, ShouldBeNil)
+			So(ai.ack(0, key), ShouldEqual, ack)
+			So(ai.unacked(resp.Timestamp.Add(time.Second)), ShouldBeEmpty)
+			So(ai.expireResponse(0, resp), ShouldBeFalse)
+		})
 	})
 }
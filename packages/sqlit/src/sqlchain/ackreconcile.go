@@ -0,0 +1,146 @@
+
+package sqlchain
+
+import (
+	"context"
+	"expvar"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// reconcileAcks reconciles the responses without acks older than the ack window on the leader.
+// The acks of the responses served by the other peers are re-requested from the responders and
+// packed into the next blocks of the leader, and the responses still without acks are expired, so
+// that the blocks only carry the acks of the responses packed before.
+func (c *Chain) reconcileAcks(now time.Time) {
+	if c.ackWindow <= 0 || !c.IsLeader() || now.Sub(c.lastAckReconcile) < c.ackWindow {
+		return
+	}
+	c.lastAckReconcile = now
+
+	var (
+		resps      = c.ai.unacked(now.Add(-c.ackWindow))
+		byNode     = make(map[proto.NodeID][]*types.SignedResponseHeader)
+		nodes      []proto.NodeID
+		refetched  int64
+		expired    int64
+		le         = c.logEntry()
+		self       = c.rt.getServer()
+		unresolved []*types.SignedResponseHeader
+	)
+	if len(resps) == 0 {
+		c.expVars.Get(mwMinerChainAcksUnacked).(*expvar.Int).Set(0)
+		return
+	}
+	for _, v := range resps {
+		if v.NodeID == self {
+			// The acks of the local responses are sent by the clients only
+			unresolved = append(unresolved, v)
+			continue
+		}
+		if _, ok := byNode[v.NodeID]; !ok {
+			nodes = append(nodes, v.NodeID)
+		}
+		byNode[v.NodeID] = append(byNode[v.NodeID], v)
+	}
+	for _, node := range nodes {
+		var (
+			list = byNode[node]
+			acks map[types.QueryKey]*types.SignedAckHeader
+			err  error
+		)
+		if acks, err = c.fetchAcks(node, list); err != nil {
+			le.WithField("node", node).WithError(err).Warn("failed to fetch acks from responder")
+		}
+		for _, v := range list {
+			if ack, ok := acks[v.Request.GetQueryKey()]; ok {
+				if err = c.pushFetchedAck(v, ack); err == nil {
+					refetched++
+					continue
+				}
+				le.WithField("node", node).WithError(err).Warn("failed to push fetched ack")
+			}
+			unresolved = append(unresolved, v)
+		}
+	}
+	for _, v := range unresolved {
+		if c.ai.expireResponse(c.responseHeight(&v.ResponseHeader), v) {
+			expired++
+			le.WithFields(log.Fields{
+				"request_hash":  v.GetRequestHash(),
+				"request_node":  v.Request.NodeID,
+				"response_hash": v.Hash(),
+				"response_node": v.NodeID,
+				"response_time": v.Timestamp,
+			}).Debug("expire response without acknowledgement")
+		}
+	}
+
+	c.expVars.Get(mwMinerChainAcksRefetched).(*expvar.Int).Add(refetched)
+	c.expVars.Get(mwMinerChainAcksExpired).(*expvar.Int).Add(expired)
+	c.expVars.Get(mwMinerChainAcksUnacked).(*expvar.Int).Set(int64(len(resps)))
+	le.WithFields(log.Fields{
+		"unacked":   len(resps),
+		"refetched": refetched,
+		"expired":   expired,
+	}).Info("reconciled unacknowledged responses")
+}
+
+// fetchAcks fetches the acks of the responses from the responder node.
+func (c *Chain) fetchAcks(node proto.NodeID, resps []*types.SignedResponseHeader) (
+	acks map[types.QueryKey]*types.SignedAckHeader, err error,
+) {
+	var (
+		req = &MuxFetchAckReq{
+			DatabaseID: c.databaseID,
+			FetchAckReq: FetchAckReq{
+				Queries: make([]AckQuery, len(resps)),
+			},
+		}
+		resp        = &MuxFetchAckResp{}
+		ctx, cancel = context.WithTimeout(c.rt.ctx, c.rt.getTick())
+	)
+	defer cancel()
+	for i, v := range resps {
+		req.Queries[i] = AckQuery{
+			Height: c.responseHeight(&v.ResponseHeader),
+			Key:    v.Request.GetQueryKey(),
+		}
+	}
+	if err = c.callPeer(ctx, node, route.SQLCFetchAck.String(), req, resp); err != nil {
+		return
+	}
+	acks = make(map[types.QueryKey]*types.SignedAckHeader, len(resp.Acks))
+	for _, v := range resp.Acks {
+		if v != nil {
+			acks[v.GetQueryKey()] = v
+		}
+	}
+	return
+}
+
+// pushFetchedAck verifies the ack fetched for the response and pushes it into the chain.
+func (c *Chain) pushFetchedAck(resp *types.SignedResponseHeader, ack *types.SignedAckHeader) (
+	err error,
+) {
+	if err = ack.Verify(); err != nil {
+		return
+	}
+	if ack.NodeID != resp.Request.NodeID {
+		err = errors.Wrapf(ErrInvalidAck,
+			"ack node %s of request node %s", ack.NodeID, resp.Request.NodeID)
+		return
+	}
+	if ack.GetResponseHash() != resp.Hash() {
+		err = errors.Wrapf(ErrResponseSeqNotMatch,
+			"ack %s of response %s", ack.Hash(), resp.Hash())
+		return
+	}
+	return c.pushAckedQuery(ack)
+}
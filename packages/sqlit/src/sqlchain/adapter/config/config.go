@@ -31,6 +31,7 @@ type Config struct {
 	CertificatePath   string          `yaml:"CertificatePath"`
 	PrivateKeyPath    string          `yaml:"PrivateKeyPath"`
 	ServerCertificate tls.Certificate `yaml:"-"`
+	ProxyProtocol     bool            `yaml:"ProxyProtocol"` // require the PROXY protocol v2 header
 	TLSConfig         *tls.Config     `yaml:"-"`
 
 	// client related
@@ -11,6 +11,7 @@ import (
 
 	"sqlit/src/sqlchain/adapter/api"
 	"sqlit/src/sqlchain/adapter/config"
+	"sqlit/src/utils/proxyproto"
 )
 
 // HTTPAdapter is a adapter for sqlit/alternative sqlite3 service.
@@ -59,6 +60,7 @@ func (adapter *HTTPAdapter) Serve() (err error) {
 		return
 	}
 
+	listener = proxyproto.Wrap(listener, cfg.ProxyProtocol)
 	if cfg.TLSConfig != nil {
 		listener = tls.NewListener(listener, cfg.TLSConfig)
 	}
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"sqlit/src/types"
+	"sqlit/src/types/fixtures"
 )
 
 var (
@@ -12,27 +13,19 @@ var (
 	testBlockNumber = 50
 )
 
-func generateTestBlocks() (err error) {
+func generateTestBlocks() {
 	testBlocks = make([]*types.Block, 0, testBlockNumber)
 
+	var g = fixtures.New(1)
 	for i, prev := 0, genesisHash; i < testBlockNumber; i++ {
-		b, err := createRandomBlock(prev, false)
-
-		if err != nil {
-			return err
-		}
-
+		b := g.Block(prev)
 		prev = *b.BlockHash()
 		testBlocks = append(testBlocks, b)
 	}
-
-	return
 }
 
 func init() {
-	if err := generateTestBlocks(); err != nil {
-		panic(err)
-	}
+	generateTestBlocks()
 }
 
 func TestNewBlockNode(t *testing.T) {
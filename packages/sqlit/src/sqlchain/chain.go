@@ -27,6 +27,7 @@ import (
 	rpc "sqlit/src/rpc/mux"
 	"sqlit/src/types"
 	"sqlit/src/utils"
+	"sqlit/src/utils/fault"
 	"sqlit/src/utils/log"
 	x "sqlit/src/dpos"
 	xi "sqlit/src/dpos/interfaces"
@@ -40,6 +41,15 @@ const (
 	mwMinerChainBlockHash      = "head:hash"
 	mwMinerChainBlockTimestamp = "head:timestamp"
 	mwMinerChainRequestsCount  = "requests:count"
+	mwMinerChainAcksRefetched  = "acks:refetched"
+	mwMinerChainAcksExpired    = "acks:expired"
+	mwMinerChainAcksUnacked    = "acks:unacked"
+
+	mwMinerChainBlockQueries      = "block:queries"
+	mwMinerChainPackingRate       = "packing:arrival_rate"
+	mwMinerChainPackingMaxQueries = "packing:max_queries"
+	mwMinerChainPackingTick       = "packing:tick"
+	mwMinerChainPackingFullBlocks = "packing:full_blocks"
 )
 
 var (
@@ -85,7 +95,7 @@ type Chain struct {
 	bi *blockIndex
 	ai *ackIndex
 	st *x.State
-	cl *rpc.Caller
+	cl Transport
 	rt *runtime
 
 	blocks    chan *types.Block
@@ -113,6 +123,22 @@ type Chain struct {
 	// Atomic counters for stats
 	cachedBlockCount int32
 
+	// stash holds the blocks newer than the current turn in deterministic mode, which are
+	// processed inline instead of by the block processing goroutine.
+	stash []*types.Block
+
+	// Bandwidth limiters of block catch-up traffic
+	syncReadLimiter  *utils.RateLimiter
+	syncWriteLimiter *utils.RateLimiter
+
+	// ackWindow is the age of the unacknowledged responses reconciled by the leader.
+	ackWindow        time.Duration
+	lastAckReconcile time.Time
+	// leaderTime indexes the queries by the response time instead of the request time.
+	leaderTime bool
+	// packer adapts the block packing to the load, nil packs all the pooled queries in each block.
+	packer *packer
+
 	// Metric vars to collect
 	expVars *expvar.Map
 }
@@ -152,7 +178,8 @@ func NewChainWithContext(ctx context.Context, c *Config) (chain *Chain, err erro
 	// Open storage
 	var strg xi.Storage
 	if strg, err = xs.NewSqlite(c.DataFile); err != nil {
-		err = errors.Wrapf(err, "open data file %s", c.DataFile)
+		// strip the dsn parameters which may contain the encryption key
+		err = errors.Wrapf(err, "open data file %s", strings.SplitN(c.DataFile, "?", 2)[0])
 		return
 	}
 
@@ -199,14 +226,40 @@ func NewChainWithContext(ctx context.Context, c *Config) (chain *Chain, err erro
 		metaResponseIndex: utils.ConcatAll(metaKeyPrefix[:], metaResponseIndex[:]),
 		metaAckIndex:      utils.ConcatAll(metaKeyPrefix[:], metaAckIndex[:]),
 
+		syncReadLimiter:  c.SyncReadLimiter,
+		syncWriteLimiter: c.SyncWriteLimiter,
+
+		ackWindow:  c.AckReconcileWindow,
+		leaderTime: c.LeaderTimestamp,
+		packer:     newPacker(c.Packing, c.Period, c.Tick),
+
 		expVars: new(expvar.Map).Init(),
 	}
 
+	if c.ApplyConcurrency > 0 {
+		chain.st.SetApplyConcurrency(c.ApplyConcurrency)
+	}
+	chain.st.SetGroupCommit(c.GroupCommitDelay)
+	chain.st.SetSnapshotReads(c.SnapshotReads)
+	chain.st.SetResultLimit(c.ResultLimit)
+	if c.Transport != nil {
+		chain.cl = c.Transport
+	}
+
 	chain.expVars.Set(mwMinerChainBlockCount, new(expvar.Int))
 	chain.expVars.Set(mwMinerChainBlockHeight, new(expvar.Int))
 	chain.expVars.Set(mwMinerChainBlockHash, new(expvar.String))
 	chain.expVars.Set(mwMinerChainBlockTimestamp, new(expvar.String))
 	chain.expVars.Set(mwMinerChainRequestsCount, mw.NewCounter("5m1m"))
+	chain.expVars.Set(mwMinerChainAcksRefetched, new(expvar.Int))
+	chain.expVars.Set(mwMinerChainAcksExpired, new(expvar.Int))
+	chain.expVars.Set(mwMinerChainAcksUnacked, new(expvar.Int))
+	chain.expVars.Set(mwMinerChainBlockQueries, mw.NewHistogram("5m1m"))
+	chain.expVars.Set(mwMinerChainPackingRate, new(expvar.Float))
+	chain.expVars.Set(mwMinerChainPackingMaxQueries, new(expvar.Int))
+	chain.expVars.Set(mwMinerChainPackingTick, new(expvar.String))
+	chain.expVars.Set(mwMinerChainPackingFullBlocks, new(expvar.Int))
+	chain.expVars.Get(mwMinerChainPackingTick).(*expvar.String).Set(c.Tick.String())
 
 	chainVars.Set(string(c.DatabaseID), chain.expVars)
 
@@ -368,6 +421,11 @@ func (c *Chain) pushBlock(b *types.Block) (err error) {
 	}
 
 	// Put block
+	if err = fault.Eval(fault.SQLChainBlockWrite, fault.Key(
+		string(c.rt.getServer()), string(c.databaseID))).Err; err != nil {
+		err = errors.Wrapf(err, "put %s", string(node.indexKey()))
+		return
+	}
 	err = blkDB.Put(blockKey, encBlock.Bytes(), nil)
 	if err != nil {
 		err = errors.Wrapf(err, "put %s", string(node.indexKey()))
@@ -441,6 +499,11 @@ func (c *Chain) pushAckedQuery(ack *types.SignedAckHeader) (err error) {
 		return
 	}
 
+	if err = fault.Eval(fault.SQLChainAckWrite, fault.Key(
+		string(c.rt.getServer()), string(c.databaseID))).Err; err != nil {
+		err = errors.Wrapf(err, "put ack %d %s", h, ack.Hash().String())
+		return
+	}
 	if err = txDB.Put(tdbKey, enc.Bytes(), nil); err != nil {
 		err = errors.Wrapf(err, "put ack %d %s", h, ack.Hash().String())
 		return
@@ -449,13 +512,14 @@ func (c *Chain) pushAckedQuery(ack *types.SignedAckHeader) (err error) {
 	return
 }
 
-// produceBlock prepares, signs and advises the pending block to the other peers.
-func (c *Chain) produceBlock(now time.Time) (err error) {
+// produceBlock prepares, signs and advises the pending block to the other peers, the block packs
+// at most maxQueries pooled queries if it's positive.
+func (c *Chain) produceBlock(now time.Time, maxQueries int) (err error) {
 	var (
 		frs []*types.Request
 		qts []*x.QueryTracker
 	)
-	if frs, qts, err = c.st.CommitEx(); err != nil {
+	if frs, qts, err = c.st.CommitExWithLimit(c.rt.ctx, maxQueries); err != nil {
 		err = errors.Wrap(err, "failed to fetch query list from db state")
 		return
 	}
@@ -463,6 +527,7 @@ func (c *Chain) produceBlock(now time.Time) (err error) {
 		c.logEntryWithHeadState().Debug("no query found in current period, skip block producing")
 		return
 	}
+	c.recordPacking(len(qts), maxQueries)
 	var block = &types.Block{
 		SignedHeader: types.SignedHeader{
 			Header: types.Header{
@@ -481,7 +546,7 @@ func (c *Chain) produceBlock(now time.Time) (err error) {
 	for i, v := range qts {
 		// TODO(leventeliu): maybe block waiting at a ready channel instead?
 		for !v.Ready() {
-			time.Sleep(c.rt.period / 10)
+			<-c.rt.clock.After(c.rt.period / 10)
 			if c.rt.ctx.Err() != nil {
 				err = c.rt.ctx.Err()
 				return
@@ -502,10 +567,7 @@ func (c *Chain) produceBlock(now time.Time) (err error) {
 		"using_timestamp": now.Format(time.RFC3339Nano),
 		"block_hash":      block.BlockHash().String(),
 	})
-	select {
-	case c.blocks <- block:
-	case <-c.rt.ctx.Done():
-		err = c.rt.ctx.Err()
+	if err = c.enqueueBlock(c.rt.ctx, block); err != nil {
 		le.WithError(err).Info("abort block producing")
 		return
 	}
@@ -532,12 +594,12 @@ func (c *Chain) produceBlock(now time.Time) (err error) {
 						},
 					}
 					resp := &MuxAdviseNewBlockResp{}
-					if err := c.cl.CallNodeWithContext(
+					if err := c.callPeer(
 						ctx, remote, route.SQLCAdviseNewBlock.String(), req, resp,
 					); err != nil {
 						le.WithError(err).Error("failed to advise new block")
 					}
-				}, c.rt.tick)
+				}, c.rt.getTick())
 			}(s)
 		}
 	}
@@ -545,7 +607,26 @@ func (c *Chain) produceBlock(now time.Time) (err error) {
 	return
 }
 
-func (c *Chain) syncHead() (err error) {
+// callPeer calls the method of the remote peer, the call is subject to the injected faults
+// between the local and the remote peers.
+func (c *Chain) callPeer(
+	ctx context.Context, remote proto.NodeID, method string, req, resp interface{},
+) (err error) {
+	dup, err := fault.Apply(ctx, fault.SQLChainCall, fault.Key(
+		string(c.rt.getServer()), string(remote), method))
+	if err != nil {
+		err = errors.Wrapf(err, "call %s to peer %s failed", method, remote)
+		return
+	}
+	for i := 0; i < dup; i++ {
+		_ = c.cl.CallNodeWithContext(ctx, remote, method, req, resp)
+	}
+	return c.cl.CallNodeWithContext(ctx, remote, method, req, resp)
+}
+
+// syncHead fetches the block of the current turn from the peers if it's not advised yet, the
+// fetches of a catching up peer are subject to the sync bandwidth limits.
+func (c *Chain) syncHead(catchUp bool) (err error) {
 	// Try to fetch if the block of the current turn is not advised yet
 	h := c.rt.getNextTurn() - 1
 	if c.rt.getHead().Height >= h {
@@ -557,13 +638,18 @@ func (c *Chain) syncHead() (err error) {
 		l     = len(peers.Servers)
 		le    = c.logEntryWithHeadState()
 
-		child, cancel = context.WithTimeout(c.rt.ctx, c.rt.tick)
+		child, cancel = context.WithTimeout(c.rt.ctx, c.rt.getTick())
 		wg            = &sync.WaitGroup{}
 
-		totalCount, succCount, initiatingCount uint32
+		totalCount, succCount, initiatingCount, fetchedCount uint32
 	)
 	defer func() {
 		wg.Wait()
+		if atomic.LoadUint32(&fetchedCount) > 0 && !c.rt.deterministic {
+			// Wait for the fetched block to be processed, or the turn may be advanced before
+			// and the block will be dropped as an outdated one
+			c.waitHead(child, h)
+		}
 		cancel()
 
 		if totalCount > 0 && succCount == 0 {
@@ -582,22 +668,22 @@ func (c *Chain) syncHead() (err error) {
 			continue
 		}
 
-		wg.Add(1)
-		go func(i int, node proto.NodeID) {
-			defer wg.Done()
+		i, node := i, s
+		c.rt.spawn(wg, func() {
 			var (
 				ile = le.WithFields(log.Fields{"remote": fmt.Sprintf("[%d/%d] %s", i, l, node)})
 				req = &MuxFetchBlockReq{
 					DatabaseID: c.databaseID,
 					FetchBlockReq: FetchBlockReq{
-						Height: h,
+						Height:  h,
+						CatchUp: catchUp,
 					},
 				}
 				resp = &MuxFetchBlockResp{}
 			)
 
 			atomic.AddUint32(&totalCount, 1)
-			if err := c.cl.CallNodeWithContext(
+			if err := c.callPeer(
 				child, node, route.SQLCFetchBlock.String(), req, resp,
 			); err != nil {
 				if !strings.Contains(err.Error(), ErrUnknownMuxRequest.Error()) {
@@ -623,19 +709,35 @@ func (c *Chain) syncHead() (err error) {
 				"parent": resp.Block.ParentHash().Short(4),
 				"hash":   resp.Block.BlockHash().Short(4),
 			}).Debug("fetch block request reply: found block")
-			select {
-			case c.blocks <- resp.Block:
-				atomic.AddUint32(&succCount, 1)
-			case <-child.Done():
-				le.WithError(child.Err()).Info("abort head block synchronizing")
+			if catchUp {
+				if err := c.syncReadLimiter.WaitN(child, resp.Size); err != nil {
+					le.WithError(err).Info("abort head block synchronizing")
+					return
+				}
+			}
+			if err := c.enqueueBlock(child, resp.Block); err != nil {
+				le.WithError(err).Info("abort head block synchronizing")
 				return
 			}
-		}(i, s)
+			atomic.AddUint32(&succCount, 1)
+			atomic.AddUint32(&fetchedCount, 1)
+		})
 	}
 
 	return
 }
 
+// waitHead waits until the head reaches the height or the context is done.
+func (c *Chain) waitHead(ctx context.Context, h int32) {
+	for c.rt.getHead().Height < h {
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.rt.clock.After(c.rt.getTick() / 10):
+		}
+	}
+}
+
 // runCurrentTurn does the check and runs block producing if its my turn.
 func (c *Chain) runCurrentTurn(now time.Time, d time.Duration) {
 	elapsed := -d
@@ -652,13 +754,12 @@ func (c *Chain) runCurrentTurn(now time.Time, d time.Duration) {
 		c.ai.advance(c.rt.getMinValidHeight())
 		// Info the block processing goroutine that the chain height has grown, so please return
 		// any stashed blocks for further check.
-		select {
-		case c.heights <- h:
-		case <-c.rt.ctx.Done():
+		if err := c.publishHeight(c.rt.ctx, h); err != nil {
 			le.Debug("abort publishing height")
 		}
 	}()
 
+	maxQueries := c.adaptPacking(now)
 	le.Debug("run current turn")
 	if c.rt.getHead().Height < c.rt.getNextTurn()-1 {
 		le.Debug("a block will be skipped")
@@ -666,15 +767,33 @@ func (c *Chain) runCurrentTurn(now time.Time, d time.Duration) {
 	if !c.rt.isMyTurn() {
 		return
 	}
-	if elapsed+c.rt.tick > c.rt.period {
+	if elapsed+c.rt.getTick() > c.rt.period {
 		le.Warn("too much time elapsed in the new period, skip this block")
 		return
 	}
-	if err := c.produceBlock(now); err != nil {
+	if err := c.produceBlock(now, maxQueries); err != nil {
 		le.WithError(err).Error("failed to produce block")
 	}
 }
 
+// cycle runs a single iteration of the main cycle: it synchronizes the head block, reconciles
+// the unacknowledged responses on the leader and runs the current turn if it's due. It returns the duration till the next turn, or the error of head
+// synchronizing.
+func (c *Chain) cycle() (d time.Duration, err error) {
+	if err = c.syncHead(false); err != nil {
+		if err != ErrInitiating {
+			return
+		}
+		err = nil
+	}
+	c.reconcileAcks(c.rt.now())
+	var t time.Time
+	if t, d = c.rt.nextTick(); d <= 0 {
+		c.runCurrentTurn(t, d)
+	}
+	return
+}
+
 // mainCycle runs main cycle of the sql-chain.
 func (c *Chain) mainCycle(ctx context.Context) {
 	for {
@@ -683,16 +802,13 @@ func (c *Chain) mainCycle(ctx context.Context) {
 			c.logEntry().WithError(ctx.Err()).Info("abort main cycle")
 			return
 		default:
-			if err := c.syncHead(); err != nil {
-				if err != ErrInitiating {
-					c.logEntry().WithError(err).Error("failed to sync head")
-					continue
-				}
+			d, err := c.cycle()
+			if err != nil {
+				c.logEntry().WithError(err).Error("failed to sync head")
+				continue
 			}
-			if t, d := c.rt.nextTick(); d > 0 {
-				time.Sleep(d)
-			} else {
-				c.runCurrentTurn(t, d)
+			if d > 0 {
+				<-c.rt.clock.After(d)
 			}
 		}
 	}
@@ -718,7 +834,7 @@ func (c *Chain) sync() (err error) {
 			break
 		}
 		for c.rt.getNextTurn() <= height {
-			if err = c.syncHead(); err != nil {
+			if err = c.syncHead(true); err != nil {
 				if err != ErrInitiating {
 					le.WithError(err).Errorf("failed to sync block at height %d", height)
 					return
@@ -734,6 +850,112 @@ func (c *Chain) sync() (err error) {
 	return
 }
 
+// enqueueBlock sends the block to the block processing goroutine, or processes it inline in
+// deterministic mode.
+func (c *Chain) enqueueBlock(ctx context.Context, block *types.Block) (err error) {
+	if c.rt.deterministic {
+		if c.processBlock(block) {
+			c.stash = append(c.stash, block)
+		}
+		return
+	}
+	select {
+	case c.blocks <- block:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// publishHeight informs the block processing goroutine of the new height, or processes it inline
+// in deterministic mode.
+func (c *Chain) publishHeight(ctx context.Context, h int32) (err error) {
+	if c.rt.deterministic {
+		c.processHeight(h)
+		var stash = c.stash
+		c.stash = nil
+		for _, block := range stash {
+			if c.processBlock(block) {
+				c.stash = append(c.stash, block)
+			}
+		}
+		return
+	}
+	select {
+	case c.heights <- h:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
+
+// processHeight triggers billing if it's the billing turn of the local peer at the height.
+func (c *Chain) processHeight(h int32) {
+	var (
+		le           = c.logEntryWithHeadState()
+		index, total = c.rt.getIndexTotal()
+		period       = int32(c.updatePeriod)
+
+		isBillingPeriod = (h%period == 0)
+		isMyTurnBilling = (h/period%total == index)
+	)
+	if !isBillingPeriod || !isMyTurnBilling {
+		return
+	}
+	ub, err := c.billing(h, c.rt.getHead().node)
+	if err != nil {
+		le.WithError(err).Error("billing failed")
+	}
+	// allocate nonce
+	nonceReq := &types.NextAccountNonceReq{}
+	nonceResp := &types.NextAccountNonceResp{}
+	nonceReq.Addr = *c.addr
+	if err = rpc.RequestBP(route.MCCNextAccountNonce.String(), nonceReq, nonceResp); err != nil {
+		// allocate nonce failed
+		le.WithError(err).Warning("allocate nonce for transaction failed")
+	}
+	ub.Nonce = nonceResp.Nonce
+	if err = ub.Sign(c.pk); err != nil {
+		le.WithError(err).Warning("sign tx failed")
+	}
+
+	addTxReq := &types.AddTxReq{TTL: 1}
+	addTxResp := &types.AddTxResp{}
+	addTxReq.Tx = ub
+	le.Debugf("nonce in processBlocks: %d, addr: %s",
+		addTxReq.Tx.GetAccountNonce(), addTxReq.Tx.GetAccountAddress())
+	if err = rpc.RequestBP(route.MCCAddTx.String(), addTxReq, addTxResp); err != nil {
+		le.WithError(err).Warning("send tx failed")
+	}
+}
+
+// processBlock checks and pushes the block of the current turn, it returns true if the block is
+// newer than the current turn and should be stashed for later check.
+func (c *Chain) processBlock(block *types.Block) (stash bool) {
+	var (
+		le     = c.logEntryWithHeadState()
+		height = c.rt.getHeightFromTime(block.Timestamp())
+	)
+	le.WithFields(log.Fields{
+		"block_height": height,
+		"block_hash":   block.BlockHash().String(),
+	}).Debug("processing new block")
+
+	if height > c.rt.getNextTurn()-1 {
+		// Stash newer blocks for later check
+		return true
+	}
+	// Process block
+	if height < c.rt.getNextTurn()-1 {
+		// TODO(leventeliu): check and add to fork list.
+	} else {
+		if err := c.CheckAndPushNewBlock(block); err != nil {
+			le.WithError(err).Error("failed to check and push new block")
+		}
+	}
+	return
+}
+
 func (c *Chain) processBlocks(ctx context.Context) {
 	var (
 		cld, ccl = context.WithCancel(ctx)
@@ -761,41 +983,9 @@ func (c *Chain) processBlocks(ctx context.Context) {
 
 	var stash []*types.Block
 	for {
-		le := c.logEntryWithHeadState()
 		select {
 		case h := <-c.heights:
-			// Trigger billing
-			index, total := c.rt.getIndexTotal()
-			period := int32(c.updatePeriod)
-			isBillingPeriod := (h%period == 0)
-			isMyTurnBilling := (h/period%total == index)
-			if isBillingPeriod && isMyTurnBilling {
-				ub, err := c.billing(h, c.rt.getHead().node)
-				if err != nil {
-					le.WithError(err).Error("billing failed")
-				}
-				// allocate nonce
-				nonceReq := &types.NextAccountNonceReq{}
-				nonceResp := &types.NextAccountNonceResp{}
-				nonceReq.Addr = *c.addr
-				if err = rpc.RequestBP(route.MCCNextAccountNonce.String(), nonceReq, nonceResp); err != nil {
-					// allocate nonce failed
-					le.WithError(err).Warning("allocate nonce for transaction failed")
-				}
-				ub.Nonce = nonceResp.Nonce
-				if err = ub.Sign(c.pk); err != nil {
-					le.WithError(err).Warning("sign tx failed")
-				}
-
-				addTxReq := &types.AddTxReq{TTL: 1}
-				addTxResp := &types.AddTxResp{}
-				addTxReq.Tx = ub
-				le.Debugf("nonce in processBlocks: %d, addr: %s",
-					addTxReq.Tx.GetAccountNonce(), addTxReq.Tx.GetAccountAddress())
-				if err = rpc.RequestBP(route.MCCAddTx.String(), addTxReq, addTxResp); err != nil {
-					le.WithError(err).Warning("send tx failed")
-				}
-			}
+			c.processHeight(h)
 			// Return all stashed blocks to pending channel
 			c.logEntryWithHeadState().WithFields(log.Fields{
 				"height": h,
@@ -807,24 +997,8 @@ func (c *Chain) processBlocks(ctx context.Context) {
 				stash = nil
 			}
 		case block := <-c.blocks:
-			height := c.rt.getHeightFromTime(block.Timestamp())
-			le.WithFields(log.Fields{
-				"block_height": height,
-				"block_hash":   block.BlockHash().String(),
-			}).Debug("processing new block")
-
-			if height > c.rt.getNextTurn()-1 {
-				// Stash newer blocks for later check
+			if c.processBlock(block) {
 				stash = append(stash, block)
-			} else {
-				// Process block
-				if height < c.rt.getNextTurn()-1 {
-					// TODO(leventeliu): check and add to fork list.
-				} else {
-					if err := c.CheckAndPushNewBlock(block); err != nil {
-						le.WithError(err).Error("failed to check and push new block")
-					}
-				}
 			}
 		case <-ctx.Done():
 			c.logEntryWithHeadState().WithError(ctx.Err()).Debug("abort block processing")
@@ -835,13 +1009,18 @@ func (c *Chain) processBlocks(ctx context.Context) {
 
 // Start starts the main process of the sql-chain.
 func (c *Chain) Start() (err error) {
-	c.rt.goFunc(c.processBlocks)
+	if !c.rt.deterministic {
+		c.rt.goFunc(c.processBlocks)
+	}
 	if err = c.sync(); err != nil {
 		c.logEntryWithHeadState().WithError(err).Error("failed to start, chain process terminated")
 		_ = c.Stop()
 		return
 	}
-	c.rt.goFunc(c.mainCycle)
+	if !c.rt.deterministic {
+		// The main cycle is driven by Simulation.Step in deterministic mode
+		c.rt.goFunc(c.mainCycle)
+	}
 	c.rt.startService(c)
 	c.logEntryWithHeadState().Info("started successfully")
 	return
@@ -865,6 +1044,12 @@ func (c *Chain) Stop() (err error) {
 
 // FetchBlock fetches the block at specified height from local cache.
 func (c *Chain) FetchBlock(height int32) (b *types.Block, err error) {
+	b, _, err = c.fetchBlock(height)
+	return
+}
+
+// fetchBlock fetches the block at specified height with its encoded size from local cache.
+func (c *Chain) fetchBlock(height int32) (b *types.Block, size int, err error) {
 	if n := c.rt.getHead().node.ancestor(height); n != nil {
 		return c.fetchBlockByIndexKey(n.indexKey())
 	}
@@ -882,7 +1067,7 @@ func (c *Chain) FetchBlockByCount(count int32) (b *types.Block, realCount int32,
 	}
 
 	if n != nil {
-		b, err = c.fetchBlockByIndexKey(n.indexKey())
+		b, _, err = c.fetchBlockByIndexKey(n.indexKey())
 		if err != nil {
 			return
 		}
@@ -894,7 +1079,7 @@ func (c *Chain) FetchBlockByCount(count int32) (b *types.Block, realCount int32,
 	return
 }
 
-func (c *Chain) fetchBlockByIndexKey(indexKey []byte) (b *types.Block, err error) {
+func (c *Chain) fetchBlockByIndexKey(indexKey []byte) (b *types.Block, size int, err error) {
 	k := utils.ConcatAll(c.metaBlockIndex, indexKey)
 	var v []byte
 	v, err = blkDB.Get(k, nil)
@@ -909,6 +1094,7 @@ func (c *Chain) fetchBlockByIndexKey(indexKey []byte) (b *types.Block, err error
 		err = errors.Wrapf(err, "fetch block %s", string(k))
 		return
 	}
+	size = len(v)
 
 	return
 }
@@ -1001,6 +1187,56 @@ func (c *Chain) VerifyAndPushAckedQuery(ack *types.SignedAckHeader) (err error)
 	return c.pushAckedQuery(ack)
 }
 
+// Backup copies the database state to dst and returns the chain head height at which the
+// backup is taken, and the log offset of the first query not included in the backup. The head is
+// read under the state lock with the snapshot, so that no block after it packs a query included
+// in the backup.
+func (c *Chain) Backup(ctx context.Context, dst string) (height int32, offset uint64, err error) {
+	if offset, err = c.st.Backup(ctx, dst, func() {
+		height = c.rt.getHead().Height
+	}); err != nil {
+		err = errors.Wrapf(err, "backup database %s", c.databaseID)
+	}
+	return
+}
+
+// Maintain runs the maintenance tasks in opts on the database state.
+func (c *Chain) Maintain(
+	ctx context.Context, opts xi.MaintenanceOptions) (result xi.MaintenanceResult, err error,
+) {
+	if result, err = c.st.Maintain(ctx, opts); err != nil {
+		err = errors.Wrapf(err, "maintain database %s", c.databaseID)
+	}
+	return
+}
+
+// IsLeader returns whether the current node is the leader of the sql-chain peers.
+func (c *Chain) IsLeader() bool {
+	return c.rt.getPeers().Leader == c.rt.getServer()
+}
+
+// Flush waits until the queries pooled so far are packed into a block at the next turn of this
+// peer, or the context is done. The queries beyond the max count of a block of the adaptive
+// packing are packed at the following turns.
+func (c *Chain) Flush(ctx context.Context) (err error) {
+	index, total := c.rt.getIndexTotal()
+	if index < 0 || total <= 0 {
+		return
+	}
+	turn := c.rt.getNextTurn()
+	for turn%total != index {
+		turn++
+	}
+	for c.rt.getNextTurn() <= turn {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.rt.clock.After(c.rt.getTick()):
+		}
+	}
+	return
+}
+
 // UpdatePeers updates peer list of the sql-chain.
 func (c *Chain) UpdatePeers(peers *proto.Peers) error {
 	return c.rt.updatePeers(peers)
@@ -1014,21 +1250,53 @@ func (c *Chain) Query(
 	// cancelling will be propagated to this context before chain instance stops.
 	// update metrics
 	c.expVars.Get(mwMinerChainRequestsCount).(mw.Metric).Add(1)
+	c.packer.arrive(1)
 
 	return c.st.QueryWithContext(req.GetContext(), req, isLeader)
 }
 
+// QueryReadOnly runs the read query req on the committed local state without tracking, and
+// returns the local head height, it's used to serve standby reads while catching up.
+func (c *Chain) QueryReadOnly(
+	ctx context.Context, req *types.Request) (resp *types.Response, height int32, err error,
+) {
+	height = c.rt.getHead().Height
+	resp, err = c.st.ReadOnlyQuery(ctx, req)
+	return
+}
+
+// EstimateQuery returns the estimated costs of the queries of req by their query plans on the
+// local state, the queries are not executed.
+func (c *Chain) EstimateQuery(ctx context.Context, req *types.Request) ([]types.QueryCost, error) {
+	return c.st.Estimate(ctx, req)
+}
+
+// CheckCost refuses req if a query of it is estimated to exceed the max cost of req.
+func (c *Chain) CheckCost(ctx context.Context, req *types.Request) error {
+	return c.st.CheckCost(ctx, req)
+}
+
 // AddResponse addes a response to the ackIndex, awaiting for acknowledgement.
 func (c *Chain) AddResponse(resp *types.SignedResponseHeader) (err error) {
-	return c.ai.addResponse(c.rt.getHeightFromTime(resp.GetRequestTimestamp()), resp)
+	return c.ai.addResponse(c.responseHeight(&resp.ResponseHeader), resp)
 }
 
 func (c *Chain) register(ack *types.SignedAckHeader) (err error) {
-	return c.ai.register(c.rt.getHeightFromTime(ack.GetRequestTimestamp()), ack)
+	return c.ai.register(c.responseHeight(&ack.Response), ack)
 }
 
 func (c *Chain) remove(ack *types.SignedAckHeader) (err error) {
-	return c.ai.remove(c.rt.getHeightFromTime(ack.GetRequestTimestamp()), ack)
+	return c.ai.remove(c.responseHeight(&ack.Response), ack)
+}
+
+// responseHeight returns the ack index height of the query of resp, which is derived from the
+// request time of the client, or the response time of the serving miner in leader timestamp
+// mode so that a skewed client clock can't place the query far from the current height.
+func (c *Chain) responseHeight(resp *types.ResponseHeader) int32 {
+	if c.leaderTime {
+		return c.rt.getHeightFromTime(resp.Timestamp)
+	}
+	return c.rt.getHeightFromTime(resp.GetRequestTimestamp())
 }
 
 func (c *Chain) pruneBlockCache() {
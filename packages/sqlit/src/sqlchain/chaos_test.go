@@ -0,0 +1,389 @@
+//go:build fault
+
+package sqlchain
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/consistent"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	rpc "sqlit/src/rpc/mux"
+	"sqlit/src/types"
+	"sqlit/src/utils/fault"
+)
+
+// testConvergeTimeout is the maximum time for the peers to converge after the faults are cleared.
+var testConvergeTimeout = 10 * testPeriod
+
+// settledNode returns the last block node of the chain at or below the height.
+func settledNode(c *Chain, height int32) (node *blockNode) {
+	for node = c.rt.getHead().node; node != nil && node.height > height; node = node.parent {
+	}
+	return
+}
+
+// waitConverge waits until all the chains agree on a block above the height, a block is agreed
+// on if it's the last block at or below the settled height, which is one period before the
+// lowest head of the chains, of all the chains.
+func waitConverge(chains []*chainParams, height int32, timeout time.Duration) (err error) {
+	var deadline = time.Now().Add(timeout)
+	for {
+		var settled = chains[0].chain.rt.getHead().Height
+		for _, v := range chains[1:] {
+			if h := v.chain.rt.getHead().Height; h < settled {
+				settled = h
+			}
+		}
+		settled--
+
+		var (
+			node      = settledNode(chains[0].chain, settled)
+			converged = node != nil && node.height > height
+		)
+		for _, v := range chains[1:] {
+			if !converged {
+				break
+			}
+			if n := settledNode(v.chain, settled); n == nil || n.hash != node.hash {
+				converged = false
+			}
+		}
+		if converged {
+			return
+		}
+		if time.Now().After(deadline) {
+			err = fmt.Errorf("peers did not converge above height %d in %s", height, timeout)
+			for _, v := range chains {
+				h := v.chain.rt.getHead()
+				err = fmt.Errorf("%v, %s: height = %d head = %s",
+					err, v.chain.rt.getPeerInfoString(), h.Height, h.Head.Short(4))
+			}
+			return
+		}
+		time.Sleep(testTick)
+	}
+}
+
+func nodeIDs(chains []*chainParams) (ids []string) {
+	for _, v := range chains {
+		ids = append(ids, string(v.config.Server))
+	}
+	return
+}
+
+func TestChaos(t *testing.T) {
+	if os.Getenv("SQLIT_INTEGRATION_TEST") != "1" {
+		t.Skip("Skipping: requires external block producer services. Set SQLIT_INTEGRATION_TEST=1 to run")
+	}
+	chains, stop := createTestChains(t, testPeersNumber)
+	defer stop()
+	defer fault.Clear()
+
+	for _, v := range chains {
+		// Disable billing, the test BP doesn't serve the billing requests and the slow failures
+		// stall the block processing
+		v.chain.updatePeriod = math.MaxInt32
+		if err := v.chain.Start(); err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+		defer func(c *Chain) {
+			_ = c.Stop()
+		}(v.chain)
+	}
+
+	// Keep the peers producing blocks with queries
+	cli, err := newRandomNode(chains[0].chain, true)
+	if err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+	req, err := cli.buildQuery(types.WriteQuery, []types.Query{
+		buildQuery(`CREATE TABLE t1 (k INT, v TEXT, PRIMARY KEY(k))`),
+		buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, 1, "v1"),
+	})
+	if err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+	var (
+		sC = make(chan struct{})
+		wg = &sync.WaitGroup{}
+	)
+	defer func() {
+		close(sC)
+		wg.Wait()
+	}()
+	for i, v := range chains {
+		cli, err := newRandomNode(v.chain, i == 0)
+		if err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+		if err = cli.sendQuery(req); err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-sC:
+					return
+				case <-time.After(testTick):
+					_ = cli.query(types.ReadQuery, []types.Query{
+						buildQuery(`SELECT v FROM t1 WHERE k=?`, 1),
+					}, true)
+				}
+			}
+		}()
+	}
+
+	Convey("Given a running sql-chain of 5 peers", t, func() {
+		So(waitConverge(chains, 0, testConvergeTimeout), ShouldBeNil)
+		Reset(fault.Clear)
+
+		var height = func() int32 { return chains[0].chain.rt.getHead().Height }
+
+		Convey("The peers should converge after an isolated peer is healed", func() {
+			// An isolated peer can't sync the head from any other peer, so it stops producing
+			// blocks and catches up the turns after the partition is healed.
+			var (
+				ids  = nodeIDs(chains)
+				heal = fault.Partition(ids[:4], ids[4:])
+			)
+			// Wait for the in-flight blocks to be processed
+			time.Sleep(testPeriod)
+			var from = chains[4].chain.rt.getHead().Height
+			time.Sleep(3 * testPeriod)
+			So(chains[4].chain.rt.getHead().Height, ShouldEqual, from)
+			heal()
+			So(waitConverge(chains, height(), testConvergeTimeout), ShouldBeNil)
+		})
+		Convey("The peers should converge after messages are dropped, delayed and duplicated", func() {
+			fault.Inject(fault.Rule{Point: fault.SQLChainCall, Drop: true, Probability: 0.3})
+			fault.Inject(fault.Rule{Point: fault.SQLChainCall, Delay: testTick / 2, Probability: 0.3})
+			fault.Inject(fault.Rule{Point: fault.SQLChainCall, Duplicate: 1, Probability: 0.3})
+			time.Sleep(3 * testPeriod)
+			fault.Clear()
+			So(waitConverge(chains, height(), testConvergeTimeout), ShouldBeNil)
+		})
+		Convey("The peers should converge after the clock of a peer is skewed", func() {
+			fault.SetClockSkew(string(chains[2].config.Server), testPeriod/2)
+			time.Sleep(3 * testPeriod)
+			fault.Clear()
+			So(waitConverge(chains, height(), testConvergeTimeout), ShouldBeNil)
+		})
+		Convey("The other peers should converge while the disk of a peer is full", func() {
+			var full = fault.Key(string(chains[4].config.Server), string(testDatabaseID))
+			fault.Inject(fault.Rule{
+				Point: fault.SQLChainBlockWrite,
+				Match: func(key string) bool { return key == full },
+				Err:   fault.ErrDiskFull,
+			})
+			var from = height()
+			time.Sleep(3 * testPeriod)
+			So(chains[4].chain.rt.getHead().Height, ShouldBeLessThanOrEqualTo, from)
+			So(waitConverge(chains[:4], height(), testConvergeTimeout), ShouldBeNil)
+		})
+	})
+}
+
+// createTestChains creates n sql-chain instances of the test database and a block producer with
+// in-process RPC servers, the returned function stops the RPC servers.
+func createTestChains(t *testing.T, n int) (chains []*chainParams, stop func()) {
+	var servers []*rpc.Server
+	stop = func() {
+		for _, v := range servers {
+			v.Stop()
+		}
+		// Drop the sessions to the stopped servers, the peers may be reused by the next test
+		_ = rpc.GetSessionPoolInstance().Close()
+	}
+	defer func() {
+		if t.Failed() {
+			stop()
+		}
+	}()
+
+	// Create genesis block
+	genesis, err := createRandomBlock(genesisHash, true)
+
+	if err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+
+	// Create peer list: `n` miners + 1 block producer
+	nis, peers, err := createTestPeers(n + 1)
+
+	if err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+
+	for i, p := range peers.Servers {
+		t.Logf("peer #%d: %s", i, p)
+	}
+
+	// Create config info from created nodes
+	bpinfo := &conf.BPInfo{
+		PublicKey: testPubKey,
+		NodeID:    peers.Servers[n],
+		Nonce:     nis[n].Nonce,
+	}
+	knownnodes := make([]proto.Node, 0, n+1)
+
+	for i, v := range peers.Servers {
+		knownnodes = append(knownnodes, proto.Node{
+			ID: v,
+			Role: func() proto.ServerRole {
+				if i < n {
+					return proto.Miner
+				}
+				return proto.Leader
+			}(),
+			Addr:      "",
+			PublicKey: testPubKey,
+			Nonce:     nis[i].Nonce,
+		})
+	}
+
+	// Rip BP from peer list
+	peers.Servers = peers.Servers[:n]
+
+	// Create sql-chain instances
+	chains = make([]*chainParams, n)
+
+	for i := range chains {
+		// Combine data file path
+		dbfile := path.Join(testDataDir, fmt.Sprintf("%s-%02d", t.Name(), i))
+
+		// Create new RPC server
+		server := rpc.NewServer()
+
+		if err = server.InitRPCServer("127.0.0.1:0", testPrivKeyFile, testMasterKey); err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+
+		go server.Serve()
+		servers = append(servers, server)
+
+		// Create multiplexing service from RPC server
+		mux, err := NewMuxService(route.SQLChainRPCName, server)
+
+		if err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+
+		// Create chain instance
+		config := &Config{
+			DatabaseID:      testDatabaseID,
+			ChainFilePrefix: dbfile,
+			DataFile:        dbfile,
+			Genesis:         genesis,
+			Period:          testPeriod,
+			Tick:            testTick,
+			MuxService:      mux,
+			Server:          peers.Servers[i],
+			Peers:           peers,
+			QueryTTL:        testQueryTTL,
+			UpdatePeriod:    testUpdatePeriod,
+		}
+		chain, err := NewChain(config)
+
+		if err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+
+		// Set chain parameters
+		chains[i] = &chainParams{
+			dbfile: dbfile,
+			server: server,
+			mux:    mux,
+			config: config,
+			chain:  chain,
+		}
+
+	}
+
+	// Create a master BP for RPC test
+	bpsvr := rpc.NewServer()
+
+	if err = bpsvr.InitRPCServer("127.0.0.1:0", testPrivKeyFile, testMasterKey); err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+
+	go bpsvr.Serve()
+	servers = append(servers, bpsvr)
+
+	// Create global config and initialize route table
+	knownnodes[n].Addr = bpsvr.Listener.Addr().String()
+
+	for i, v := range chains {
+		knownnodes[i].Addr = v.server.Listener.Addr().String()
+	}
+
+	conf.GConf = &conf.Config{
+		UseTestMasterKey: true,
+		GenerateKeyPair:  false,
+		WorkingRoot:      testDataDir,
+		PubKeyStoreFile:  "public.keystore",
+		PrivateKeyFile:   "private.key",
+		DHTFileName:      "dht.db",
+		ListenAddr:       bpsvr.Listener.Addr().String(),
+		ThisNodeID:       bpinfo.NodeID,
+		ValidDNSKeys: map[string]string{
+			"koPbw9wmYZ7ggcjnQ6ayHyhHaDNMYELKTqT+qRGrZpWSccr/lBcrm10Z1PuQHB3Azhii+sb0PYFkH1ruxLhe5g==": "cloudflare.com",
+			"mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==": "cloudflare.com",
+		},
+		MinNodeIDDifficulty: 2,
+		DNSSeed: conf.DNSSeed{
+			EnforcedDNSSEC: false,
+			DNSServers: []string{
+				"1.1.1.1",
+				"202.46.34.74",
+				"202.46.34.75",
+				"202.46.34.76",
+			},
+		},
+		BP:         bpinfo,
+		KnownNodes: knownnodes,
+	}
+
+	// Start BP
+	if dht, err := route.NewDHTService(testDHTStoreFile, new(consistent.KMSStorage), true); err != nil {
+		t.Fatalf("error occurred: %v", err)
+	} else if err = bpsvr.RegisterService(route.DHTRPCName, dht); err != nil {
+		t.Fatalf("error occurred: %v", err)
+	}
+
+	for _, n := range conf.GConf.KnownNodes {
+		rawNodeID := n.ID.ToRawNodeID()
+		if err = route.SetNodeAddrCache(rawNodeID, n.Addr); err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+		node := &proto.Node{
+			ID:        n.ID,
+			Addr:      n.Addr,
+			PublicKey: n.PublicKey,
+			Nonce:     n.Nonce,
+			Role:      n.Role,
+		}
+
+		if err = kms.SetNode(node); err != nil {
+			t.Fatalf("error occurred: %v", err)
+		}
+
+		if n.ID == conf.GConf.ThisNodeID {
+			kms.SetLocalNodeIDNonce(rawNodeID.CloneBytes(), &n.Nonce)
+		}
+	}
+
+	return
+}
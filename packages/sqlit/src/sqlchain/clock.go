@@ -0,0 +1,72 @@
+package sqlchain
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock defines the time source of the sql-chain runtime.
+type Clock interface {
+	// Now returns the current clock reading.
+	Now() time.Time
+	// After returns a channel which receives the clock reading after the duration d.
+	After(d time.Duration) <-chan time.Time
+}
+
+// systemClock is the Clock backed by the system time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time                         { return time.Now() }
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type simTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// SimClock is a simulated Clock which only moves forward by Advance, so that a sql-chain
+// simulation runs in virtual time regardless of the real time it takes.
+type SimClock struct {
+	sync.Mutex
+	now    time.Time
+	timers []*simTimer
+}
+
+// NewSimClock returns a new simulated clock starting at t.
+func NewSimClock(t time.Time) *SimClock {
+	return &SimClock{now: t}
+}
+
+// Now implements Clock.Now.
+func (c *SimClock) Now() time.Time {
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+// After implements Clock.After, the channel receives when the clock is advanced to the time.
+func (c *SimClock) After(d time.Duration) <-chan time.Time {
+	c.Lock()
+	defer c.Unlock()
+	var t = &simTimer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t.c
+	}
+	c.timers = append(c.timers, t)
+	return t.c
+}
+
+// Advance moves the clock forward by d and fires the timers expired in order.
+func (c *SimClock) Advance(d time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+	c.now = c.now.Add(d)
+	sort.SliceStable(c.timers, func(i, j int) bool { return c.timers[i].at.Before(c.timers[j].at) })
+	var i int
+	for ; i < len(c.timers) && !c.timers[i].at.After(c.now); i++ {
+		c.timers[i].c <- c.timers[i].at
+	}
+	c.timers = c.timers[i:]
+}
@@ -4,8 +4,10 @@ package sqlchain
 import (
 	"time"
 
+	x "sqlit/src/dpos"
 	"sqlit/src/proto"
 	"sqlit/src/types"
+	"sqlit/src/utils"
 )
 
 // Config represents a sql-chain config.
@@ -32,4 +34,42 @@ type Config struct {
 	UpdatePeriod      uint64
 	LastBillingHeight int32
 	IsolationLevel    int
+
+	// SyncReadLimiter and SyncWriteLimiter throttle block catch-up traffic fetched from and
+	// served to the other peers, nil means unlimited.
+	SyncReadLimiter  *utils.RateLimiter
+	SyncWriteLimiter *utils.RateLimiter
+
+	// ApplyConcurrency sets the max count of requests prepared in parallel for replaying, 0 means
+	// the default of the state.
+	ApplyConcurrency int
+
+	// GroupCommitDelay sets the max delay of committing the concurrent write requests in a single
+	// transaction, 0 disables the group commit.
+	GroupCommitDelay time.Duration
+
+	// SnapshotReads sets whether the read queries of the read uncommitted isolation level run on
+	// the last committed snapshot.
+	SnapshotReads bool
+
+	// ResultLimit sets the max size of the result of each read query, 0 means unlimited.
+	ResultLimit x.ResultLimit
+
+	// Packing sets the limits of adapting the max count of queries in a block and the tick to
+	// the load, the zero value packs all the pooled queries in each block at the fixed Tick.
+	Packing PackingLimits
+
+	// AckReconcileWindow sets the age of the responses without acks, of which the leader
+	// re-requests the acks from the responders or expires them, 0 disables the reconciliation.
+	AckReconcileWindow time.Duration
+
+	// LeaderTimestamp indexes the responses and acks by the response time of the serving miner
+	// instead of the request time of the client, which is advisory in this mode. All the peers
+	// of the database should use the same setting.
+	LeaderTimestamp bool
+
+	// Clock and Transport replace the system clock and the rpc caller of the chain, nil means
+	// the defaults. They are set by Simulation to run the chain in virtual time and in-memory.
+	Clock     Clock
+	Transport Transport
 }
@@ -0,0 +1,44 @@
+package sqlchain
+
+import (
+	"expvar"
+	"io"
+
+	"sqlit/src/diag"
+)
+
+const diagChains = "sqlchains.json"
+
+// chainHead is the head of a sql-chain in the diagnostic bundles.
+type chainHead struct {
+	Height    int64
+	Hash      string
+	Timestamp string
+}
+
+func init() {
+	diag.Register(diagChains, writeChainHeads)
+}
+
+// writeChainHeads writes the heads of the running sql-chains keyed by database id.
+func writeChainHeads(w io.Writer) error {
+	var heads = make(map[string]chainHead)
+	chainVars.Do(func(kv expvar.KeyValue) {
+		vars, ok := kv.Value.(*expvar.Map)
+		if !ok {
+			return
+		}
+		var head chainHead
+		if v, ok := vars.Get(mwMinerChainBlockHeight).(*expvar.Int); ok {
+			head.Height = v.Value()
+		}
+		if v, ok := vars.Get(mwMinerChainBlockHash).(*expvar.String); ok {
+			head.Hash = v.Value()
+		}
+		if v, ok := vars.Get(mwMinerChainBlockTimestamp).(*expvar.String); ok {
+			head.Timestamp = v.Value()
+		}
+		heads[kv.Key] = head
+	})
+	return diag.WriteJSON(w, heads)
+}
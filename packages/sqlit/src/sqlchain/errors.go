@@ -30,4 +30,8 @@ var (
 	// ErrInitiating indicates that a sqlchain is in initiate state and is not available for sync
 	// requests.
 	ErrInitiating = errors.New("sqlchain is in initiate")
+	// ErrInvalidAck indicates that an ack is not signed by the node of the acknowledged request.
+	ErrInvalidAck = errors.New("invalid acknowledgement")
+	// ErrUnreachablePeer indicates that the remote peer is not reachable in the simulated network.
+	ErrUnreachablePeer = errors.New("peer is unreachable")
 )
@@ -60,6 +60,20 @@ type MuxFetchBlockResp struct {
 	FetchBlockResp
 }
 
+// MuxFetchAckReq defines a request of the FetchAck RPC method.
+type MuxFetchAckReq struct {
+	proto.Envelope
+	proto.DatabaseID
+	FetchAckReq
+}
+
+// MuxFetchAckResp defines a response of the FetchAck RPC method.
+type MuxFetchAckResp struct {
+	proto.Envelope
+	proto.DatabaseID
+	FetchAckResp
+}
+
 // AdviseNewBlock is the RPC method to advise a new produced block to the target server.
 func (s *MuxService) AdviseNewBlock(req *MuxAdviseNewBlockReq, resp *MuxAdviseNewBlockResp) error {
 	if v, ok := s.serviceMap.Load(req.DatabaseID); ok {
@@ -81,3 +95,14 @@ func (s *MuxService) FetchBlock(req *MuxFetchBlockReq, resp *MuxFetchBlockResp)
 
 	return ErrUnknownMuxRequest
 }
+
+// FetchAck is the RPC method to fetch the acks of the queries responded by the target server.
+func (s *MuxService) FetchAck(req *MuxFetchAckReq, resp *MuxFetchAckResp) (err error) {
+	if v, ok := s.serviceMap.Load(req.DatabaseID); ok {
+		resp.Envelope = req.Envelope
+		resp.DatabaseID = req.DatabaseID
+		return v.(*ChainRPCService).FetchAck(&req.FetchAckReq, &resp.FetchAckResp)
+	}
+
+	return ErrUnknownMuxRequest
+}
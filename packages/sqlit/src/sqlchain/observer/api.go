@@ -1,4 +1,3 @@
-
 package observer
 
 import (
@@ -6,9 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gorilla/handlers"
@@ -430,6 +432,255 @@ func (a *explorerAPI) GetHighestBlockV3(rw http.ResponseWriter, r *http.Request)
 	sendResponse(200, true, "", a.formatBlockV3(count, height, block, op), rw)
 }
 
+func (a *explorerAPI) ListDatabases(rw http.ResponseWriter, r *http.Request) {
+	subscriptions, err := a.service.getAllSubscriptions()
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	dbIDs := make([]string, 0, len(subscriptions))
+	for dbID := range subscriptions {
+		dbIDs = append(dbIDs, string(dbID))
+	}
+	sort.Strings(dbIDs)
+
+	var (
+		op            = newPaginationFromReq(r)
+		limit, offset = limitOffset(op.page, op.size)
+		items         = make([]interface{}, 0, limit)
+	)
+	for i := offset; i < len(dbIDs) && i < offset+limit; i++ {
+		items = append(items, map[string]interface{}{
+			"db":    dbIDs[i],
+			"count": subscriptions[proto.DatabaseID(dbIDs[i])],
+		})
+	}
+
+	sendResponse(200, true, "", a.formatList(op, len(dbIDs), items), rw)
+}
+
+func (a *explorerAPI) GetDatabaseProfile(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	profile, err := a.service.getProfile(dbID)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	sendResponse(200, true, "", a.formatProfile(profile), rw)
+}
+
+func (a *explorerAPI) ListBlocks(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	filter := &blockFilter{fromHeight: -1, toHeight: -1}
+	if filter.fromHeight, err = a.getHeightParam(r, "from"); err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+	if filter.toHeight, err = a.getHeightParam(r, "to"); err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	op := newPaginationFromReq(r)
+	total, blocks, err := a.service.listBlocks(dbID, filter, op.page, op.size)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	items := make([]interface{}, 0, len(blocks))
+	for _, b := range blocks {
+		res := a.formatBlockV2(b.count, b.height, b.block)["block"].(map[string]interface{})
+		delete(res, "queries")
+		res["tx_count"] = len(b.block.QueryTxs)
+		res["failed_count"] = len(b.block.FailedReqs)
+		res["ack_count"] = len(b.block.Acks)
+		items = append(items, res)
+	}
+
+	sendResponse(200, true, "", a.formatList(op, total, items), rw)
+}
+
+func (a *explorerAPI) ListTransactions(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	var (
+		op     = newPaginationFromReq(r)
+		params = r.URL.Query()
+		filter = &queryFilter{
+			queryType: op.queryType,
+			node:      proto.NodeID(params.Get("node")),
+			account:   params.Get("account"),
+			sql:       params.Get("sql"),
+		}
+	)
+	if filter.since, err = a.getTimeParam(r, "since"); err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+	if filter.until, err = a.getTimeParam(r, "until"); err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	total, queries, err := a.service.listQueries(dbID, filter, op.page, op.size)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	items := make([]interface{}, 0, len(queries))
+	for _, q := range queries {
+		items = append(items, a.formatQueryRecord(q))
+	}
+
+	sendResponse(200, true, "", a.formatList(op, total, items), rw)
+}
+
+func (a *explorerAPI) Search(rw http.ResponseWriter, r *http.Request) {
+	var (
+		params = r.URL.Query()
+		term   = strings.TrimSpace(params.Get("q"))
+		dbID   = proto.DatabaseID(params.Get("db"))
+		op     = newPaginationFromReq(r)
+		filter = &queryFilter{queryType: op.queryType}
+		blocks []*locationRecord
+		acks   []*locationRecord
+		err    error
+	)
+	if term == "" {
+		sendResponse(400, false, "empty search term", nil, rw)
+		return
+	}
+
+	// a full length hash is looked up as a block, an ack, a query or an account, the other terms
+	// are searched in the sql of the queries
+	if h, hashErr := hash.NewHashFromStr(term); hashErr == nil && len(term) == hash.MaxHashStringSize {
+		filter.hash = h.String()
+		if blocks, err = a.service.searchBlocks(filter.hash); err != nil {
+			sendResponse(500, false, err, nil, rw)
+			return
+		}
+		if acks, err = a.service.searchAcks(filter.hash); err != nil {
+			sendResponse(500, false, err, nil, rw)
+			return
+		}
+	} else {
+		filter.sql = term
+	}
+
+	total, queries, err := a.service.listQueries(dbID, filter, op.page, op.size)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	subscriptions, err := a.service.getAllSubscriptions()
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	databases := make([]interface{}, 0, 1)
+	if count, ok := subscriptions[proto.DatabaseID(term)]; ok {
+		databases = append(databases, map[string]interface{}{
+			"db":    term,
+			"count": count,
+		})
+	}
+
+	blockItems := make([]interface{}, 0, len(blocks))
+	for _, b := range blocks {
+		if dbID != "" && b.db != dbID {
+			continue
+		}
+		blockItems = append(blockItems, map[string]interface{}{
+			"db":     b.db,
+			"hash":   filter.hash,
+			"count":  b.count,
+			"height": b.height,
+		})
+	}
+
+	ackItems := make([]interface{}, 0, len(acks))
+	for _, ack := range acks {
+		if dbID != "" && ack.db != dbID {
+			continue
+		}
+		ackItems = append(ackItems, map[string]interface{}{
+			"db":     ack.db,
+			"hash":   filter.hash,
+			"height": ack.height,
+			"offset": ack.offset,
+		})
+	}
+
+	items := make([]interface{}, 0, len(queries))
+	for _, q := range queries {
+		items = append(items, a.formatQueryRecord(q))
+	}
+
+	sendResponse(200, true, "", map[string]interface{}{
+		"term":         term,
+		"databases":    databases,
+		"blocks":       blockItems,
+		"acks":         ackItems,
+		"transactions": a.formatList(op, total, items),
+	}, rw)
+}
+
+func (a *explorerAPI) ListAccounts(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	op := newPaginationFromReq(r)
+	total, accounts, err := a.service.listAccounts(dbID, op.page, op.size)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	items := make([]interface{}, 0, len(accounts))
+	for _, acc := range accounts {
+		items = append(items, map[string]interface{}{
+			"account":    acc.account,
+			"reads":      acc.reads,
+			"writes":     acc.writes,
+			"first_seen": a.formatTime(acc.first),
+			"last_seen":  a.formatTime(acc.last),
+		})
+	}
+
+	sendResponse(200, true, "", a.formatList(op, total, items), rw)
+}
+
 func (a *explorerAPI) formatBlock(height int32, b *types.Block) (res map[string]interface{}) {
 	queries := make([]string, 0, len(b.Acks))
 
@@ -576,6 +827,7 @@ func (a *explorerAPI) formatRequest(req *types.Request) map[string]interface{} {
 			"node":      req.Header.NodeID,
 			"type":      req.Header.QueryType.String(),
 			"count":     req.Header.BatchCount,
+			"trace":     req.Header.TraceID(),
 			"queries":   queries,
 		},
 	}
@@ -598,6 +850,7 @@ func (a *explorerAPI) formatResponseHeader(resp *types.SignedResponseHeader) map
 			"node":      resp.Request.NodeID,
 			"type":      resp.Request.QueryType.String(),
 			"count":     resp.Request.BatchCount,
+			"trace":     resp.Request.TraceID(),
 		},
 	}
 }
@@ -611,6 +864,7 @@ func (a *explorerAPI) formatAck(ack *types.SignedAckHeader) map[string]interface
 				"node":      ack.Response.Request.NodeID,
 				"type":      ack.Response.Request.QueryType.String(),
 				"count":     ack.Response.Request.BatchCount,
+				"trace":     ack.Response.Request.TraceID(),
 			},
 			"response": map[string]interface{}{
 				"hash":           ack.GetResponseHash().String(),
@@ -627,6 +881,90 @@ func (a *explorerAPI) formatAck(ack *types.SignedAckHeader) map[string]interface
 	}
 }
 
+func (a *explorerAPI) formatList(op *paginationOps, total int, items []interface{}) map[string]interface{} {
+	limit, _ := limitOffset(op.page, op.size)
+	return map[string]interface{}{
+		"items": items,
+		"pagination": map[string]interface{}{
+			"page":  op.page,
+			"size":  limit,
+			"total": total,
+		},
+	}
+}
+
+func (a *explorerAPI) formatQueryRecord(q *queryRecord) map[string]interface{} {
+	return map[string]interface{}{
+		"db":             q.db,
+		"hash":           q.hash,
+		"response":       q.response,
+		"count":          q.count,
+		"height":         q.height,
+		"offset":         q.offset,
+		"type":           q.queryType.String(),
+		"node":           q.node,
+		"account":        q.account,
+		"timestamp":      a.formatTime(q.timestamp),
+		"queries":        q.queries,
+		"row_count":      q.rowCount,
+		"affected_rows":  q.affectedRows,
+		"last_insert_id": q.lastInsertID,
+		"sql":            q.sql,
+	}
+}
+
+func (a *explorerAPI) formatProfile(profile *types.SQLChainProfile) map[string]interface{} {
+	miners := make([]map[string]interface{}, 0, len(profile.Miners))
+	for _, m := range profile.Miners {
+		if m == nil {
+			continue
+		}
+		miners = append(miners, map[string]interface{}{
+			"address": m.Address.String(),
+			"node":    m.NodeID,
+			"name":    m.Name,
+			"status":  m.Status,
+		})
+	}
+
+	users := make([]map[string]interface{}, 0, len(profile.Users))
+	for _, u := range profile.Users {
+		if u == nil {
+			continue
+		}
+		user := map[string]interface{}{
+			"address": u.Address.String(),
+			"status":  u.Status,
+		}
+		if u.Permission != nil {
+			user["role"] = u.Permission.Role.String()
+			user["patterns"] = u.Permission.Patterns
+		}
+		users = append(users, user)
+	}
+
+	return map[string]interface{}{
+		"profile": map[string]interface{}{
+			"db":                  profile.ID,
+			"address":             profile.Address.String(),
+			"owner":               profile.Owner.String(),
+			"period":              profile.Period,
+			"last_updated_height": profile.LastUpdatedHeight,
+			"miners":              miners,
+			"users":               users,
+			"meta": map[string]interface{}{
+				"node":                     profile.Meta.Node,
+				"space":                    profile.Meta.Space,
+				"memory":                   profile.Meta.Memory,
+				"load_avg_per_cpu":         profile.Meta.LoadAvgPerCPU,
+				"use_eventual_consistency": profile.Meta.UseEventualConsistency,
+				"consistency_level":        profile.Meta.ConsistencyLevel,
+				"isolation_level":          profile.Meta.IsolationLevel,
+			},
+		},
+	}
+}
+
 func (a *explorerAPI) formatTime(t time.Time) float64 {
 	return float64(t.UnixNano()) / 1e6
 }
@@ -647,6 +985,164 @@ func (a *explorerAPI) getHash(vars map[string]string) (h *hash.Hash, err error)
 	return hash.NewHashFromStr(hStr)
 }
 
+// ListChanges returns the row changes of the database after the cursor, it waits at most wait
+// seconds for the new changes if there is none.
+func (a *explorerAPI) ListChanges(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	var (
+		params = r.URL.Query()
+		cursor int64
+		limit  int64
+		wait   int64
+	)
+	for _, v := range []struct {
+		name string
+		val  *int64
+		max  int64
+	}{
+		{"cursor", &cursor, math.MaxInt64},
+		{"limit", &limit, maxChangesLimit},
+		{"wait", &wait, maxChangesWait},
+	} {
+		str := params.Get(v.name)
+		if str == "" {
+			continue
+		}
+		if *v.val, err = strconv.ParseInt(str, 10, 64); err != nil || *v.val < 0 || *v.val > v.max {
+			sendResponse(400, false, fmt.Errorf("invalid %s: %s", v.name, str), nil, rw)
+			return
+		}
+	}
+
+	feed, err := a.service.getChangeFeed(dbID)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+	changes, err := feed.changes(r.Context(), cursor, int(limit), time.Duration(wait)*time.Second)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	next := cursor
+	if len(changes) > 0 {
+		next = changes[len(changes)-1].Cursor
+	}
+	sendResponse(200, true, "", map[string]interface{}{
+		"changes": changes,
+		"cursor":  next,
+	}, rw)
+}
+
+// ListHistory returns the modification history of a table after the cursor, or of a row if pk
+// is the JSON object of its primary key (or rowid), each change comes with the signer account,
+// the time, the SQL and the block of its request.
+func (a *explorerAPI) ListHistory(rw http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	dbID, err := a.getDBID(vars)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	var (
+		params = r.URL.Query()
+		table  = params.Get("table")
+		pk     json.RawMessage
+		cursor int64
+		limit  int64
+	)
+	if table == "" {
+		sendResponse(400, false, "table is required", nil, rw)
+		return
+	}
+	if str := params.Get("pk"); str != "" {
+		var obj map[string]interface{}
+		if err = json.Unmarshal([]byte(str), &obj); err != nil || obj == nil {
+			sendResponse(400, false, fmt.Errorf("invalid pk: %s", str), nil, rw)
+			return
+		}
+		pk = json.RawMessage(str)
+	}
+	for _, v := range []struct {
+		name string
+		val  *int64
+		max  int64
+	}{
+		{"cursor", &cursor, math.MaxInt64},
+		{"limit", &limit, maxChangesLimit},
+	} {
+		str := params.Get(v.name)
+		if str == "" {
+			continue
+		}
+		if *v.val, err = strconv.ParseInt(str, 10, 64); err != nil || *v.val < 0 || *v.val > v.max {
+			sendResponse(400, false, fmt.Errorf("invalid %s: %s", v.name, str), nil, rw)
+			return
+		}
+	}
+
+	feed, err := a.service.getChangeFeed(dbID)
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+	records, err := feed.history(table, pk, cursor, int(limit))
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+
+	next := cursor
+	if len(records) > 0 {
+		next = records[len(records)-1].Cursor
+	}
+	sendResponse(200, true, "", map[string]interface{}{
+		"history": records,
+		"cursor":  next,
+	}, rw)
+}
+
+// getHeightParam returns the non-negative height in the query parameter, or -1 if it is empty.
+func (a *explorerAPI) getHeightParam(r *http.Request, name string) (height int32, err error) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return -1, nil
+	}
+	v, err := strconv.ParseInt(str, 10, 32)
+	if err != nil || v < 0 {
+		err = fmt.Errorf("invalid %s height: %s", name, str)
+		return
+	}
+	height = int32(v)
+	return
+}
+
+// getTimeParam returns the time of the unix milliseconds in the query parameter, or the zero
+// time if it is empty.
+func (a *explorerAPI) getTimeParam(r *http.Request, name string) (t time.Time, err error) {
+	str := r.URL.Query().Get(name)
+	if str == "" {
+		return
+	}
+	ms, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || ms < 0 {
+		err = fmt.Errorf("invalid %s time: %s", name, str)
+		return
+	}
+	t = time.Unix(0, ms*int64(time.Millisecond)).UTC()
+	return
+}
+
 func startAPI(service *Service, listenAddr string, version string) (server *http.Server, err error) {
 	statikFS, err := fs.New()
 	if err != nil {
@@ -695,6 +1191,16 @@ func startAPI(service *Service, listenAddr string, version string) (server *http
 	v3Router.HandleFunc("/height/{db}/{height:[0-9]+}", api.GetBlockByHeightV3).Methods("GET")
 	v3Router.HandleFunc("/head/{db}", api.GetHighestBlockV3).Methods("GET")
 	v3Router.HandleFunc("/subscriptions", api.GetAllSubscriptions).Methods("GET")
+	v4Router := apiRouter.PathPrefix("/v4").Subrouter()
+	v4Router.HandleFunc("/search", api.Search).Methods("GET")
+	v4Router.HandleFunc("/dbs", api.ListDatabases).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}", api.GetDatabaseProfile).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/blocks", api.ListBlocks).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/transactions", api.ListTransactions).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/accounts", api.ListAccounts).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/changes", api.ListChanges).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/history", api.ListHistory).Methods("GET")
+	v4Router.HandleFunc("/dbs/{db}/tail", api.TailQueries).Methods("GET")
 
 	server = &http.Server{
 		Addr:         listenAddr,
@@ -0,0 +1,512 @@
+package observer
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+const (
+	cdcDirName = "cdc"
+	cdcFileExt = ".db3"
+
+	// the change log and the triggers are kept in the replica with the reserved prefix
+	cdcReservedPrefix = "__sqlit_"
+	cdcTriggerPrefix  = "__sqlit_cdc_"
+
+	// maxChangesLimit is the max count of the changes returned in a single request.
+	maxChangesLimit = 1000
+	// maxChangesWait is the max seconds to wait for the new changes in a single request.
+	maxChangesWait = 60
+
+	// cdcRetryInterval is the interval to retry the replay after a failure.
+	cdcRetryInterval = 10 * time.Second
+)
+
+// Row change operations.
+const (
+	ChangeInsert = "insert"
+	ChangeUpdate = "update"
+	ChangeDelete = "delete"
+)
+
+var (
+	initChangeFeedSQL = []string{
+		`CREATE TABLE IF NOT EXISTS "__sqlit_changes" (
+			"cursor"	INTEGER PRIMARY KEY AUTOINCREMENT,
+			"count"		INTEGER,
+			"height"	INTEGER,
+			"offset"	INTEGER,
+			"table"		TEXT,
+			"op"		TEXT,
+			"pk"		TEXT,
+			"before"	TEXT,
+			"after"		TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS "__sqlit_cdc_cursor" (
+			"id"		INTEGER PRIMARY KEY CHECK ("id" = 0),
+			"count"		INTEGER,
+			"seq"		INTEGER
+		)`,
+		`INSERT OR IGNORE INTO "__sqlit_cdc_cursor" ("id", "count", "seq") VALUES (0, 0, 0)`,
+		`CREATE INDEX IF NOT EXISTS "__sqlit_changes_table" ON "__sqlit_changes" ("table", "cursor")`,
+	}
+	getFeedCursorSQL    = `SELECT "count", "seq" FROM "__sqlit_cdc_cursor" WHERE "id" = 0`
+	setFeedCursorSQL    = `UPDATE "__sqlit_cdc_cursor" SET "count" = ?, "seq" = ? WHERE "id" = 0`
+	tagChangesSQL       = `UPDATE "__sqlit_changes" SET "count" = ?, "height" = ?, "offset" = ? WHERE "offset" IS NULL`
+	listFeedTablesSQL   = `SELECT "name" FROM "sqlite_master" WHERE "type" = 'table' AND substr("name", 1, 7) <> 'sqlite_' AND substr("name", 1, 8) <> '__sqlit_'`
+	listFeedTriggersSQL = `SELECT "name" FROM "sqlite_master" WHERE "type" = 'trigger' AND substr("name", 1, 12) = '__sqlit_cdc_'`
+	listChangesSQL      = `SELECT "cursor", "count", "height", "offset", "table", "op", "pk", "before", "after" FROM "__sqlit_changes" WHERE "cursor" > ? ORDER BY "cursor" LIMIT ?`
+)
+
+// RowChange defines a row-level change of a database derived from the committed blocks.
+type RowChange struct {
+	// Cursor is the position of the change in the feed, which increases monotonically
+	Cursor int64  `json:"cursor"`
+	Count  int32  `json:"count"`
+	Height int32  `json:"height"`
+	Offset uint64 `json:"offset"`
+	Table  string `json:"table"`
+	Op     string `json:"op"`
+	// PK, Before and After are the JSON objects of the primary key (or rowid) and the row values
+	// before and after the change, BLOB values are encoded as hex strings
+	PK     json.RawMessage `json:"pk"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+// changeFeed derives the row changes of a database by replaying its saved blocks in count order
+// into a local replica, in which the triggers record the changes of each query. The replica
+// starts from genesis, so the blocks must be saved from the oldest one.
+type changeFeed struct {
+	s    *Service
+	dbID proto.DatabaseID
+	strg *xs.SQLite3
+
+	l       sync.Mutex
+	updated chan struct{} // closed and replaced when new changes are committed
+	err     error         // the last replay error
+
+	notifyCh chan struct{}
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func cdcDir() string {
+	return filepath.Join(conf.GConf.WorkingRoot, cdcDirName)
+}
+
+func newChangeFeed(s *Service, dbID proto.DatabaseID, dir string) (f *changeFeed, err error) {
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	var strg *xs.SQLite3
+	if strg, err = xs.NewSqlite(filepath.Join(dir, string(dbID)+cdcFileExt)); err != nil {
+		return
+	}
+	for _, q := range initChangeFeedSQL {
+		if _, err = strg.Writer().Exec(q); err != nil {
+			_ = strg.Close()
+			err = errors.Wrap(err, "init change feed failed")
+			return
+		}
+	}
+	f = &changeFeed{
+		s:        s,
+		dbID:     dbID,
+		strg:     strg,
+		updated:  make(chan struct{}),
+		notifyCh: make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+	}
+	f.wg.Add(1)
+	go f.run()
+	f.notify()
+	return
+}
+
+// notify wakes up the feed to replay the newly saved blocks.
+func (f *changeFeed) notify() {
+	select {
+	case f.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (f *changeFeed) stop() {
+	close(f.stopCh)
+	f.wg.Wait()
+	_ = f.strg.Close()
+}
+
+func (f *changeFeed) run() {
+	defer f.wg.Done()
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		case <-f.notifyCh:
+		case <-time.After(cdcRetryInterval):
+		}
+		err := f.catchUp()
+		f.l.Lock()
+		f.err = err
+		f.l.Unlock()
+		if err != nil {
+			log.WithField("db", f.dbID).WithError(err).Warning("replay change feed failed")
+		}
+	}
+}
+
+// catchUp replays the saved blocks after the cursor until the next one is missing.
+func (f *changeFeed) catchUp() (err error) {
+	for {
+		select {
+		case <-f.stopCh:
+			return
+		default:
+		}
+		var (
+			count, height int32
+			seq           uint64
+			blockBytes    []byte
+			block         *types.Block
+		)
+		if err = f.strg.Writer().QueryRow(getFeedCursorSQL).Scan(&count, &seq); err != nil {
+			return
+		}
+		err = f.s.db.Writer().QueryRow(
+			getBlockByCountSQL, string(f.dbID), count).Scan(&height, &blockBytes)
+		if err == sql.ErrNoRows {
+			return nil
+		} else if err != nil {
+			return
+		}
+		if err = utils.DecodeMsgPack(blockBytes, &block); err != nil {
+			return
+		}
+		if err = f.apply(count, height, seq, block); err != nil {
+			return errors.Wrapf(err, "apply block %d", count)
+		}
+
+		f.l.Lock()
+		close(f.updated)
+		f.updated = make(chan struct{})
+		f.l.Unlock()
+	}
+}
+
+// apply replays the write queries of block in a single transaction as the state replays blocks,
+// and moves the cursor to the next block.
+func (f *changeFeed) apply(count, height int32, seq uint64, block *types.Block) (err error) {
+	var (
+		ctx    = context.Background()
+		tx     *sql.Tx
+		writes []*types.QueryAsTx
+	)
+	for _, q := range block.QueryTxs {
+		if q.Request.Header.QueryType == types.WriteQuery {
+			writes = append(writes, q)
+		}
+	}
+
+	if tx, err = f.strg.Writer().BeginTx(ctx, nil); err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+	for _, q := range writes {
+		if offset := q.Response.LogOffset; offset < seq {
+			// already replayed in the previous blocks
+			continue
+		} else if offset > seq {
+			return errors.Wrapf(ErrInconsistentData, "missing query %d before %d", seq, offset)
+		}
+		for i := range q.Request.Payload.Queries {
+			var ddl bool
+			if ddl, err = x.ExecWrite(
+				ctx, tx, &q.Response.ResponseHeader, i, &q.Request.Payload.Queries[i],
+			); err != nil {
+				return errors.Wrapf(err, "replay query %d", seq)
+			}
+			if ddl {
+				if err = syncChangeTriggers(ctx, tx); err != nil {
+					return
+				}
+			}
+			if _, err = tx.ExecContext(ctx, tagChangesSQL, count, height, seq); err != nil {
+				return
+			}
+			seq++
+		}
+	}
+	if _, err = tx.ExecContext(ctx, setFeedCursorSQL, count+1, seq); err != nil {
+		return
+	}
+	return tx.Commit()
+}
+
+// changes returns at most limit changes after cursor. If there is none, it waits for the new
+// changes until wait elapses.
+func (f *changeFeed) changes(
+	ctx context.Context, cursor int64, limit int, wait time.Duration) (
+	changes []*RowChange, err error,
+) {
+	if limit <= 0 || limit > maxChangesLimit {
+		limit = maxChangesLimit
+	}
+	deadline := time.After(wait)
+	for {
+		f.l.Lock()
+		updated, ferr := f.updated, f.err
+		f.l.Unlock()
+		if changes, err = f.list(cursor, limit); err != nil || len(changes) > 0 {
+			return
+		}
+		if ferr != nil {
+			err = ferr
+			return
+		}
+		select {
+		case <-updated:
+		case <-deadline:
+			return
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+func (f *changeFeed) list(cursor int64, limit int) (changes []*RowChange, err error) {
+	rows, err := f.strg.Reader().Query(listChangesSQL, cursor, limit)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	return scanChanges(rows)
+}
+
+func scanChanges(rows *sql.Rows) (changes []*RowChange, err error) {
+	changes = make([]*RowChange, 0)
+	for rows.Next() {
+		var (
+			c             = &RowChange{}
+			before, after sql.NullString
+			pk            string
+		)
+		if err = rows.Scan(
+			&c.Cursor, &c.Count, &c.Height, &c.Offset, &c.Table, &c.Op, &pk, &before, &after,
+		); err != nil {
+			return
+		}
+		c.PK = json.RawMessage(pk)
+		if before.Valid {
+			c.Before = json.RawMessage(before.String)
+		}
+		if after.Valid {
+			c.After = json.RawMessage(after.String)
+		}
+		changes = append(changes, c)
+	}
+	err = rows.Err()
+	return
+}
+
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+func quoteString(s string) string {
+	return `'` + strings.Replace(s, `'`, `''`, -1) + `'`
+}
+
+// rowJSON returns the json_object expression of the columns of the row ref (NEW or OLD).
+func rowJSON(ref string, columns []string) string {
+	var args = make([]string, 0, 2*len(columns))
+	for _, c := range columns {
+		col := ref + "." + quoteIdent(c)
+		args = append(args, quoteString(c), fmt.Sprintf(
+			"CASE WHEN typeof(%s) = 'blob' THEN hex(%s) ELSE %s END", col, col, col))
+	}
+	return "json_object(" + strings.Join(args, ", ") + ")"
+}
+
+// syncChangeTriggers recreates the triggers of all the tables after a schema change.
+func syncChangeTriggers(ctx context.Context, tx *sql.Tx) (err error) {
+	var triggers, tables []string
+	if triggers, err = queryNames(ctx, tx, listFeedTriggersSQL); err != nil {
+		return
+	}
+	for _, t := range triggers {
+		if _, err = tx.ExecContext(ctx, "DROP TRIGGER IF EXISTS "+quoteIdent(t)); err != nil {
+			return
+		}
+	}
+	if tables, err = queryNames(ctx, tx, listFeedTablesSQL); err != nil {
+		return
+	}
+	for _, t := range tables {
+		if err = createChangeTriggers(ctx, tx, t); err != nil {
+			return errors.Wrapf(err, "create change triggers of %s", t)
+		}
+	}
+	return
+}
+
+func createChangeTriggers(ctx context.Context, tx *sql.Tx, table string) (err error) {
+	var (
+		rows    *sql.Rows
+		columns []string
+		pks     = map[int]string{}
+	)
+	if rows, err = tx.QueryContext(ctx, "PRAGMA table_info("+quoteIdent(table)+")"); err != nil {
+		return
+	}
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, ctype      string
+			dflt             interface{}
+		)
+		if err = rows.Scan(&cid, &name, &ctype, &notNull, &dflt, &pk); err != nil {
+			_ = rows.Close()
+			return
+		}
+		columns = append(columns, name)
+		if pk > 0 {
+			pks[pk] = name
+		}
+	}
+	if err = rows.Close(); err != nil {
+		return
+	}
+
+	pkJSON := func(ref string) string {
+		if len(pks) == 0 {
+			return fmt.Sprintf("json_object('rowid', %s.rowid)", ref)
+		}
+		var names = make([]string, 0, len(pks))
+		for i := 1; i <= len(pks); i++ {
+			names = append(names, pks[i])
+		}
+		return rowJSON(ref, names)
+	}
+	for _, v := range []struct {
+		op, event, pk, before, after string
+	}{
+		{ChangeInsert, "INSERT", pkJSON("NEW"), "NULL", rowJSON("NEW", columns)},
+		{ChangeUpdate, "UPDATE", pkJSON("NEW"), rowJSON("OLD", columns), rowJSON("NEW", columns)},
+		{ChangeDelete, "DELETE", pkJSON("OLD"), rowJSON("OLD", columns), "NULL"},
+	} {
+		if _, err = tx.ExecContext(ctx, fmt.Sprintf(
+			`CREATE TRIGGER %s AFTER %s ON %s BEGIN `+
+				`INSERT INTO "__sqlit_changes" ("table", "op", "pk", "before", "after") `+
+				`VALUES (%s, %s, %s, %s, %s); END`,
+			quoteIdent(cdcTriggerPrefix+v.op+"_"+table), v.event, quoteIdent(table),
+			quoteString(table), quoteString(v.op), v.pk, v.before, v.after,
+		)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func queryNames(ctx context.Context, tx *sql.Tx, query string) (names []string, err error) {
+	rows, err := tx.QueryContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		names = append(names, name)
+	}
+	err = rows.Err()
+	return
+}
+
+// getChangeFeed returns the change feed of the database, it starts the feed and subscribes the
+// database from the oldest block if needed.
+func (s *Service) getChangeFeed(dbID proto.DatabaseID) (f *changeFeed, err error) {
+	s.feedLock.Lock()
+	defer s.feedLock.Unlock()
+	if f = s.feeds[dbID]; f != nil {
+		return
+	}
+	if _, ok := s.subscription.Load(dbID); !ok {
+		if err = s.subscribe(dbID, "oldest"); err != nil {
+			return
+		}
+	}
+	if f, err = newChangeFeed(s, dbID, cdcDir()); err != nil {
+		return
+	}
+	s.feeds[dbID] = f
+	return
+}
+
+// loadChangeFeeds restarts the change feeds of the previous run.
+func (s *Service) loadChangeFeeds() (err error) {
+	entries, err := os.ReadDir(cdcDir())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return
+	}
+	s.feedLock.Lock()
+	defer s.feedLock.Unlock()
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, cdcFileExt) {
+			continue
+		}
+		dbID := proto.DatabaseID(strings.TrimSuffix(name, cdcFileExt))
+		var f *changeFeed
+		if f, err = newChangeFeed(s, dbID, cdcDir()); err != nil {
+			return errors.Wrapf(err, "load change feed of %s", dbID)
+		}
+		s.feeds[dbID] = f
+	}
+	return
+}
+
+// notifyChangeFeed wakes up the change feed of the database after a block is saved.
+func (s *Service) notifyChangeFeed(dbID proto.DatabaseID) {
+	s.feedLock.Lock()
+	defer s.feedLock.Unlock()
+	if f := s.feeds[dbID]; f != nil {
+		f.notify()
+	}
+}
+
+func (s *Service) stopChangeFeeds() {
+	s.feedLock.Lock()
+	defer s.feedLock.Unlock()
+	for k, f := range s.feeds {
+		f.stop()
+		delete(s.feeds, k)
+	}
+}
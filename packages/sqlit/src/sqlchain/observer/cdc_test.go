@@ -0,0 +1,178 @@
+package observer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+func buildTestWriteBlock(
+	priv *asymmetric.PrivateKey, offset uint64, queries ...types.Query) (b *types.Block, err error,
+) {
+	b = &types.Block{SignedHeader: types.SignedHeader{Header: types.Header{
+		Producer:  testIndexNode,
+		Timestamp: testIndexEpoch.Add(time.Duration(offset) * time.Second),
+	}}}
+	for i, q := range queries {
+		qt := &types.QueryAsTx{
+			Request: &types.Request{
+				Header: types.SignedRequestHeader{RequestHeader: types.RequestHeader{
+					QueryType:  types.WriteQuery,
+					NodeID:     testIndexNode,
+					DatabaseID: testIndexDB,
+					Timestamp:  testIndexEpoch.Add(time.Duration(offset+uint64(i)) * time.Second),
+				}},
+				Payload: types.RequestPayload{Queries: []types.Query{q}},
+			},
+			Response: &types.SignedResponseHeader{ResponseHeader: types.ResponseHeader{
+				LogOffset: offset + uint64(i),
+			}},
+		}
+		if err = qt.Request.Sign(priv); err != nil {
+			return
+		}
+		b.QueryTxs = append(b.QueryTxs, qt)
+	}
+	err = b.PackAndSignBlock(priv)
+	return
+}
+
+func TestChangeFeed(t *testing.T) {
+	Convey("Given an observer service with saved write blocks", t, func() {
+		tmp, err := os.MkdirTemp("", "sqlit")
+		So(err, ShouldBeNil)
+		db, err := xs.NewSqlite(filepath.Join(tmp, dbFileName))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			_ = db.Close()
+			_ = os.RemoveAll(tmp)
+		})
+		s := &Service{db: db}
+		So(s.initTables(), ShouldBeNil)
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+
+		var offset uint64
+		for i, queries := range [][]types.Query{
+			{
+				{Pattern: `CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT, b BLOB)`},
+				{Pattern: `INSERT INTO t VALUES (1, 'a', x'0102')`},
+			},
+			{
+				{Pattern: `UPDATE t SET v = ? WHERE id = 1`, Args: []types.NamedArg{{Value: "b"}}},
+				{Pattern: `CREATE TABLE "no pk" (v)`},
+				{Pattern: `INSERT INTO "no pk" VALUES ('x')`},
+			},
+			{
+				{Pattern: `DELETE FROM t`},
+			},
+		} {
+			b, err := buildTestWriteBlock(priv, offset, queries...)
+			So(err, ShouldBeNil)
+			offset += uint64(len(queries))
+			enc, err := utils.EncodeMsgPack(b)
+			So(err, ShouldBeNil)
+			_, err = db.Writer().Exec(saveBlockSQL, string(testIndexDB), i*2, i, b.BlockHash().String(), enc.Bytes())
+			So(err, ShouldBeNil)
+		}
+
+		f, err := newChangeFeed(s, testIndexDB, filepath.Join(tmp, cdcDirName))
+		So(err, ShouldBeNil)
+		Reset(func() { f.stop() })
+
+		Convey("The row changes should be listed in order", func() {
+			changes, err := f.changes(context.Background(), 0, 0, 5*time.Second)
+			So(err, ShouldBeNil)
+			for len(changes) < 4 {
+				more, err := f.changes(context.Background(), changes[len(changes)-1].Cursor, 0, 5*time.Second)
+				So(err, ShouldBeNil)
+				So(more, ShouldNotBeEmpty)
+				changes = append(changes, more...)
+			}
+			So(changes, ShouldHaveLength, 4)
+
+			var (
+				ops      = []string{ChangeInsert, ChangeUpdate, ChangeInsert, ChangeDelete}
+				tables   = []string{"t", "t", "no pk", "t"}
+				counts   = []int32{0, 1, 1, 2}
+				offsets  = []uint64{1, 2, 4, 5}
+				row      map[string]interface{}
+				lastSeen int64
+			)
+			for i, c := range changes {
+				So(c.Cursor, ShouldBeGreaterThan, lastSeen)
+				lastSeen = c.Cursor
+				So(c.Op, ShouldEqual, ops[i])
+				So(c.Table, ShouldEqual, tables[i])
+				So(c.Count, ShouldEqual, counts[i])
+				So(c.Height, ShouldEqual, counts[i]*2)
+				So(c.Offset, ShouldEqual, offsets[i])
+			}
+			So(string(changes[0].PK), ShouldEqual, `{"id":1}`)
+			So(changes[0].Before, ShouldBeNil)
+			So(json.Unmarshal(changes[0].After, &row), ShouldBeNil)
+			So(row, ShouldResemble, map[string]interface{}{"id": 1.0, "v": "a", "b": "0102"})
+			So(json.Unmarshal(changes[1].Before, &row), ShouldBeNil)
+			So(row["v"], ShouldEqual, "a")
+			So(json.Unmarshal(changes[1].After, &row), ShouldBeNil)
+			So(row["v"], ShouldEqual, "b")
+			So(string(changes[2].PK), ShouldEqual, `{"rowid":1}`)
+			So(changes[3].After, ShouldBeNil)
+
+			// no more changes
+			more, err := f.changes(context.Background(), lastSeen, 0, 0)
+			So(err, ShouldBeNil)
+			So(more, ShouldBeEmpty)
+		})
+
+		Convey("The history should be listed with the requests", func() {
+			_, err := f.changes(context.Background(), 3, 0, 5*time.Second)
+			So(err, ShouldBeNil)
+			addr, err := crypto.PubKeyHash(priv.PubKey())
+			So(err, ShouldBeNil)
+
+			records, err := f.history("t", nil, 0, 0)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 3)
+			var sqls = []string{
+				`INSERT INTO t VALUES (1, 'a', x'0102')`,
+				`UPDATE t SET v = ? WHERE id = 1`,
+				`DELETE FROM t`,
+			}
+			for i, r := range records {
+				So(r.Table, ShouldEqual, "t")
+				So(r.SQL, ShouldEqual, sqls[i])
+				So(r.Account, ShouldEqual, addr.String())
+				So(r.Node, ShouldEqual, testIndexNode)
+				So(r.Timestamp.Equal(testIndexEpoch.Add(time.Duration(r.Offset)*time.Second)), ShouldBeTrue)
+				b, err := f.block(r.Count)
+				So(err, ShouldBeNil)
+				So(r.Block, ShouldEqual, b.BlockHash().String())
+			}
+			So(records[1].Args, ShouldResemble, []QueryArg{{Value: "b"}})
+
+			records, err = f.history("t", json.RawMessage(`{ "id": 1 }`), records[0].Cursor, 1)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			So(records[0].Op, ShouldEqual, ChangeUpdate)
+			records, err = f.history("t", json.RawMessage(`{"id":2}`), 0, 0)
+			So(err, ShouldBeNil)
+			So(records, ShouldBeEmpty)
+			records, err = f.history("no pk", json.RawMessage(`{"rowid":1}`), 0, 0)
+			So(err, ShouldBeNil)
+			So(records, ShouldHaveLength, 1)
+			So(records[0].SQL, ShouldEqual, `INSERT INTO "no pk" VALUES ('x')`)
+		})
+	})
+}
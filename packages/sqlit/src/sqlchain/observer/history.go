@@ -0,0 +1,137 @@
+package observer
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+var listHistorySQL = `SELECT "cursor", "count", "height", "offset", "table", "op", "pk", "before", "after"
+	FROM "__sqlit_changes" WHERE "table" = ? AND "cursor" > ? AND (? IS NULL OR json("pk") = json(?))
+	ORDER BY "cursor" LIMIT ?`
+
+// QueryArg defines an argument of a query pattern.
+type QueryArg struct {
+	Name  string      `json:"name,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// HistoryRecord defines a row change with the signed request it's derived from, which tells who
+// changed the row, when, with what SQL and in which block.
+type HistoryRecord struct {
+	RowChange
+	Block   string `json:"block"`
+	Request string `json:"request"`
+	// Account is the address of the request signer
+	Account string       `json:"account"`
+	Node    proto.NodeID `json:"node"`
+	// Timestamp is the time of the request signed by the client
+	Timestamp time.Time  `json:"timestamp"`
+	SQL       string     `json:"sql"`
+	Args      []QueryArg `json:"args,omitempty"`
+}
+
+// history returns at most limit changes of the table after cursor with their requests, pk filters
+// the changes of a row by the JSON object of its primary key (or rowid) in the key column order.
+func (f *changeFeed) history(table string, pk json.RawMessage, cursor int64, limit int) (
+	records []*HistoryRecord, err error,
+) {
+	if limit <= 0 || limit > maxChangesLimit {
+		limit = maxChangesLimit
+	}
+	var pkArg interface{}
+	if pk != nil {
+		pkArg = string(pk)
+	}
+	rows, err := f.strg.Reader().Query(listHistorySQL, table, cursor, pkArg, pkArg, limit)
+	if err != nil {
+		return
+	}
+	changes, err := scanChanges(rows)
+	_ = rows.Close()
+	if err != nil {
+		return
+	}
+
+	var blocks = map[int32]*types.Block{}
+	records = make([]*HistoryRecord, 0, len(changes))
+	for _, c := range changes {
+		block, ok := blocks[c.Count]
+		if !ok {
+			if block, err = f.block(c.Count); err != nil {
+				return
+			}
+			blocks[c.Count] = block
+		}
+		var r *HistoryRecord
+		if r, err = newHistoryRecord(c, block); err != nil {
+			return
+		}
+		records = append(records, r)
+	}
+	return
+}
+
+func (f *changeFeed) block(count int32) (block *types.Block, err error) {
+	var (
+		height     int32
+		blockBytes []byte
+	)
+	if err = f.s.db.Reader().QueryRow(
+		getBlockByCountSQL, string(f.dbID), count).Scan(&height, &blockBytes); err != nil {
+		if err == sql.ErrNoRows {
+			err = errors.Wrapf(ErrInconsistentData, "missing block %d", count)
+		}
+		return
+	}
+	err = utils.DecodeMsgPack(blockBytes, &block)
+	return
+}
+
+// newHistoryRecord finds the query of the change in block by the log offset.
+func newHistoryRecord(c *RowChange, block *types.Block) (r *HistoryRecord, err error) {
+	for _, q := range block.QueryTxs {
+		if q.Request.Header.QueryType != types.WriteQuery {
+			continue
+		}
+		var (
+			offset  = q.Response.LogOffset
+			queries = q.Request.Payload.Queries
+		)
+		if c.Offset < offset || c.Offset >= offset+uint64(len(queries)) {
+			continue
+		}
+		var (
+			req   = &q.Request.Header
+			query = &queries[c.Offset-offset]
+		)
+		r = &HistoryRecord{
+			RowChange: *c,
+			Block:     block.BlockHash().String(),
+			Request:   req.Hash().String(),
+			Node:      req.NodeID,
+			Timestamp: req.Timestamp,
+			SQL:       query.Pattern,
+		}
+		if req.Signee != nil {
+			var addr proto.AccountAddress
+			if addr, err = crypto.PubKeyHash(req.Signee); err != nil {
+				return
+			}
+			r.Account = addr.String()
+		}
+		for _, a := range query.Args {
+			r.Args = append(r.Args, QueryArg{Name: a.Name, Value: a.Value})
+		}
+		return
+	}
+	err = errors.Wrapf(ErrInconsistentData, "missing query %d in block %d", c.Offset, c.Count)
+	return
+}
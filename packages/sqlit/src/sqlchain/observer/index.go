@@ -0,0 +1,417 @@
+package observer
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// maxListSize is the maximum page size of the list queries.
+	maxListSize = 100
+)
+
+var (
+	saveQuerySQL = `INSERT OR REPLACE INTO "query" ("db", "hash", "response", "count", "height", "offset",
+		"type", "node", "account", "timestamp", "queries", "rows", "affected", "insert_id", "sql")
+		VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	getAllBlocksSQL = `SELECT "db", "height", "count", "block" FROM "block"`
+	countBlocksSQL  = `SELECT COUNT(*) FROM "block" WHERE `
+	listBlocksSQL   = `SELECT "height", "count", "block" FROM "block" WHERE `
+	countQueriesSQL = `SELECT COUNT(*) FROM "query" WHERE `
+	listQueriesSQL  = `SELECT "db", "hash", "response", "count", "height", "offset", "type", "node", "account",
+		"timestamp", "queries", "rows", "affected", "insert_id", "sql" FROM "query" WHERE `
+	countAccountsSQL = `SELECT COUNT(DISTINCT "account") FROM "query" WHERE "db" = ?`
+	listAccountsSQL  = `SELECT "account",
+		SUM(CASE WHEN "type" = ? THEN 1 ELSE 0 END), SUM(CASE WHEN "type" = ? THEN 1 ELSE 0 END),
+		MIN("timestamp"), MAX("timestamp") FROM "query" WHERE "db" = ?
+		GROUP BY "account" ORDER BY MAX("timestamp") DESC, "account" LIMIT ? OFFSET ?`
+	searchBlocksSQL = `SELECT "db", "height", "count", 0 FROM "block" WHERE "hash" = ? ORDER BY "db"`
+	searchAcksSQL   = `SELECT "db", "height", 0, "offset" FROM "ack" WHERE "hash" = ? ORDER BY "db"`
+
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// blockRecord defines a saved block with its position in the chain.
+type blockRecord struct {
+	count  int32
+	height int32
+	block  *types.Block
+}
+
+// queryRecord defines an indexed query of a database.
+type queryRecord struct {
+	db           proto.DatabaseID
+	hash         string
+	response     string
+	count        int32
+	height       int32
+	offset       int32
+	queryType    types.QueryType
+	node         proto.NodeID
+	account      string
+	timestamp    time.Time
+	queries      uint64
+	rowCount     uint64
+	affectedRows int64
+	lastInsertID int64
+	sql          string
+}
+
+// accountRecord defines the query statistics of an account on a database.
+type accountRecord struct {
+	account string
+	reads   int64
+	writes  int64
+	first   time.Time
+	last    time.Time
+}
+
+// locationRecord defines the position of a block or an ack in a database, the offset of a block
+// and the count of an ack are not kept.
+type locationRecord struct {
+	db     proto.DatabaseID
+	count  int32
+	height int32
+	offset int32
+}
+
+// blockFilter defines the filter of the block list, negative heights are unbounded.
+type blockFilter struct {
+	fromHeight int32
+	toHeight   int32
+}
+
+// queryFilter defines the filter of the query list, a query type other than read and write and
+// the other zero values match all queries.
+type queryFilter struct {
+	queryType types.QueryType
+	node      proto.NodeID
+	account   string
+	since     time.Time
+	until     time.Time
+	// hash matches the request hash, the response hash or the signer account of the queries
+	hash string
+	// sql matches the queries containing the text in their patterns
+	sql string
+}
+
+// whereClause builds the conditions of a list query.
+type whereClause struct {
+	conds []string
+	args  []interface{}
+}
+
+func (w *whereClause) add(cond string, args ...interface{}) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+func (w *whereClause) String() string {
+	if len(w.conds) == 0 {
+		return "1"
+	}
+	return strings.Join(w.conds, " AND ")
+}
+
+func (f *blockFilter) where(dbID proto.DatabaseID) (w *whereClause) {
+	w = &whereClause{}
+	w.add(`"db" = ?`, string(dbID))
+	if f.fromHeight >= 0 {
+		w.add(`"height" >= ?`, f.fromHeight)
+	}
+	if f.toHeight >= 0 {
+		w.add(`"height" <= ?`, f.toHeight)
+	}
+	return
+}
+
+// where returns the conditions of the filter, the queries of all databases match an empty dbID.
+func (f *queryFilter) where(dbID proto.DatabaseID) (w *whereClause) {
+	w = &whereClause{}
+	if dbID != "" {
+		w.add(`"db" = ?`, string(dbID))
+	}
+	if f.queryType == types.ReadQuery || f.queryType == types.WriteQuery {
+		w.add(`"type" = ?`, int32(f.queryType))
+	}
+	if f.node != "" {
+		w.add(`"node" = ?`, string(f.node))
+	}
+	if f.account != "" {
+		w.add(`"account" = ?`, f.account)
+	}
+	if !f.since.IsZero() {
+		w.add(`"timestamp" >= ?`, f.since.UnixNano())
+	}
+	if !f.until.IsZero() {
+		w.add(`"timestamp" < ?`, f.until.UnixNano())
+	}
+	if f.hash != "" {
+		w.add(`("hash" = ? OR "response" = ? OR "account" = ?)`, f.hash, f.hash, f.hash)
+	}
+	if f.sql != "" {
+		w.add(`"sql" LIKE ? ESCAPE '\'`, "%"+likeEscaper.Replace(f.sql)+"%")
+	}
+	return
+}
+
+func limitOffset(page, size int) (limit, offset int) {
+	if size <= 0 || size > maxListSize {
+		size = maxListSize
+	}
+	if page <= 0 {
+		page = 1
+	}
+	return size, (page - 1) * size
+}
+
+func (s *Service) indexQuery(dbID proto.DatabaseID, count, height, offset int32, qt *types.QueryAsTx) (err error) {
+	var (
+		req  = &qt.Request.Header
+		resp = qt.Response
+		addr proto.AccountAddress
+		sqls = make([]string, 0, len(qt.Request.Payload.Queries))
+	)
+	if addr, err = crypto.PubKeyHash(req.Signee); err != nil {
+		err = errors.Wrapf(err, "get request signer failed: %s, %s", dbID, req.Hash().String())
+		return
+	}
+	for _, q := range qt.Request.Payload.Queries {
+		sqls = append(sqls, q.Pattern)
+	}
+	_, err = s.db.Writer().Exec(saveQuerySQL, string(dbID), req.Hash().String(), resp.Hash().String(),
+		count, height, offset, int32(req.QueryType), string(req.NodeID), addr.String(),
+		req.Timestamp.UnixNano(), int64(req.BatchCount), int64(resp.RowCount), resp.AffectedRows,
+		resp.LastInsertID, strings.Join(sqls, "\n"))
+	if err != nil {
+		err = errors.Wrapf(err, "save query index failed: %s, %s, %d", dbID, req.Hash().String(), height)
+	}
+	return
+}
+
+// rebuildQueryIndex indexes the queries of all the saved blocks.
+func (s *Service) rebuildQueryIndex() (err error) {
+	rows, err := s.db.Writer().Query(getAllBlocksSQL)
+	if err != nil {
+		err = errors.Wrap(err, "query saved blocks failed")
+		return
+	}
+
+	// collect the blocks first, the writer connection is busy until the rows are closed
+	var records = map[proto.DatabaseID][]*blockRecord{}
+	for rows.Next() {
+		var (
+			rawDBID   string
+			blockData []byte
+			r         = &blockRecord{}
+		)
+		if err = rows.Scan(&rawDBID, &r.height, &r.count, &blockData); err != nil {
+			_ = rows.Close()
+			err = errors.Wrap(err, "scan saved blocks failed")
+			return
+		}
+		if err = utils.DecodeMsgPack(blockData, &r.block); err != nil || r.block == nil {
+			log.WithError(err).WithField("db", rawDBID).Warning("skip undecodable block in query index")
+			err = nil
+			continue
+		}
+		records[proto.DatabaseID(rawDBID)] = append(records[proto.DatabaseID(rawDBID)], r)
+	}
+	if err = rows.Err(); err != nil {
+		_ = rows.Close()
+		err = errors.Wrap(err, "scan saved blocks failed")
+		return
+	}
+	_ = rows.Close()
+
+	for dbID, blocks := range records {
+		for _, r := range blocks {
+			for i, q := range r.block.QueryTxs {
+				if q == nil || q.Request == nil || q.Request.Header.Signee == nil || q.Response == nil {
+					continue
+				}
+				if err = s.indexQuery(dbID, r.count, r.height, int32(i), q); err != nil {
+					return
+				}
+			}
+		}
+	}
+	return
+}
+
+func (s *Service) listBlocks(
+	dbID proto.DatabaseID, filter *blockFilter, page, size int) (total int, blocks []*blockRecord, err error,
+) {
+	var (
+		w             = filter.where(dbID)
+		limit, offset = limitOffset(page, size)
+	)
+	if err = s.db.Writer().QueryRow(countBlocksSQL+w.String(), w.args...).Scan(&total); err != nil {
+		err = errors.Wrapf(err, "count blocks failed: %s", dbID)
+		return
+	}
+
+	rows, err := s.db.Writer().Query(listBlocksSQL+w.String()+` ORDER BY "count" DESC LIMIT ? OFFSET ?`,
+		append(w.args, limit, offset)...)
+	if err != nil {
+		err = errors.Wrapf(err, "list blocks failed: %s", dbID)
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	blocks = make([]*blockRecord, 0, limit)
+	for rows.Next() {
+		var (
+			blockData []byte
+			r         = &blockRecord{}
+		)
+		if err = rows.Scan(&r.height, &r.count, &blockData); err != nil {
+			err = errors.Wrapf(err, "scan blocks failed: %s", dbID)
+			return
+		}
+		if err = utils.DecodeMsgPack(blockData, &r.block); err != nil {
+			err = errors.Wrapf(err, "decode block failed: %s", dbID)
+			return
+		}
+		blocks = append(blocks, r)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *Service) listQueries(
+	dbID proto.DatabaseID, filter *queryFilter, page, size int) (total int, queries []*queryRecord, err error,
+) {
+	var (
+		w             = filter.where(dbID)
+		limit, offset = limitOffset(page, size)
+	)
+	if err = s.db.Writer().QueryRow(countQueriesSQL+w.String(), w.args...).Scan(&total); err != nil {
+		err = errors.Wrapf(err, "count queries failed: %s", dbID)
+		return
+	}
+
+	rows, err := s.db.Writer().Query(
+		listQueriesSQL+w.String()+` ORDER BY "timestamp" DESC, "count" DESC, "offset" DESC LIMIT ? OFFSET ?`,
+		append(w.args, limit, offset)...)
+	if err != nil {
+		err = errors.Wrapf(err, "list queries failed: %s", dbID)
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	queries = make([]*queryRecord, 0, limit)
+	for rows.Next() {
+		var (
+			r         = &queryRecord{}
+			rawDBID   string
+			queryType int32
+			node      string
+			timestamp int64
+			batch     int64
+			rowCount  int64
+		)
+		if err = rows.Scan(&rawDBID, &r.hash, &r.response, &r.count, &r.height, &r.offset, &queryType, &node,
+			&r.account, &timestamp, &batch, &rowCount, &r.affectedRows, &r.lastInsertID, &r.sql); err != nil {
+			err = errors.Wrapf(err, "scan queries failed: %s", dbID)
+			return
+		}
+		r.db = proto.DatabaseID(rawDBID)
+		r.queryType = types.QueryType(queryType)
+		r.node = proto.NodeID(node)
+		r.timestamp = time.Unix(0, timestamp).UTC()
+		r.queries = uint64(batch)
+		r.rowCount = uint64(rowCount)
+		queries = append(queries, r)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *Service) listAccounts(
+	dbID proto.DatabaseID, page, size int) (total int, accounts []*accountRecord, err error,
+) {
+	limit, offset := limitOffset(page, size)
+	if err = s.db.Writer().QueryRow(countAccountsSQL, string(dbID)).Scan(&total); err != nil {
+		err = errors.Wrapf(err, "count accounts failed: %s", dbID)
+		return
+	}
+
+	rows, err := s.db.Writer().Query(listAccountsSQL,
+		int32(types.ReadQuery), int32(types.WriteQuery), string(dbID), limit, offset)
+	if err != nil {
+		err = errors.Wrapf(err, "list accounts failed: %s", dbID)
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	accounts = make([]*accountRecord, 0, limit)
+	for rows.Next() {
+		var (
+			r           = &accountRecord{}
+			first, last int64
+		)
+		if err = rows.Scan(&r.account, &r.reads, &r.writes, &first, &last); err != nil {
+			err = errors.Wrapf(err, "scan accounts failed: %s", dbID)
+			return
+		}
+		r.first = time.Unix(0, first).UTC()
+		r.last = time.Unix(0, last).UTC()
+		accounts = append(accounts, r)
+	}
+	err = rows.Err()
+	return
+}
+
+// searchLocations returns the positions of the blocks or the acks with the hash in all databases.
+func (s *Service) searchLocations(q string, h string) (locations []*locationRecord, err error) {
+	rows, err := s.db.Writer().Query(q, h)
+	if err != nil {
+		err = errors.Wrapf(err, "search hash failed: %s", h)
+		return
+	}
+
+	defer func() {
+		_ = rows.Close()
+	}()
+
+	for rows.Next() {
+		var (
+			rawDBID string
+			r       = &locationRecord{}
+		)
+		if err = rows.Scan(&rawDBID, &r.height, &r.count, &r.offset); err != nil {
+			err = errors.Wrapf(err, "scan search results failed: %s", h)
+			return
+		}
+		r.db = proto.DatabaseID(rawDBID)
+		locations = append(locations, r)
+	}
+	err = rows.Err()
+	return
+}
+
+func (s *Service) searchBlocks(h string) ([]*locationRecord, error) {
+	return s.searchLocations(searchBlocksSQL, h)
+}
+
+func (s *Service) searchAcks(h string) ([]*locationRecord, error) {
+	return s.searchLocations(searchAcksSQL, h)
+}
@@ -0,0 +1,303 @@
+package observer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+const (
+	testIndexDB   proto.DatabaseID = "db"
+	testIndexNode proto.NodeID     = "00000000000000000000000000000000000000000000000000000000000000aa"
+)
+
+var (
+	testIndexEpoch    = time.Unix(1546300800, 0).UTC()
+	testIndexPatterns = map[types.QueryType]string{
+		types.ReadQuery:  "SELECT * FROM t WHERE id = ?",
+		types.WriteQuery: "INSERT INTO t_log VALUES (?)",
+	}
+)
+
+func buildTestQuery(
+	priv *asymmetric.PrivateKey, queryType types.QueryType, seq uint64) (qt *types.QueryAsTx, err error,
+) {
+	req := &types.Request{
+		Header: types.SignedRequestHeader{RequestHeader: types.RequestHeader{
+			QueryType:  queryType,
+			NodeID:     testIndexNode,
+			DatabaseID: testIndexDB,
+			SeqNo:      seq,
+			Timestamp:  testIndexEpoch.Add(time.Duration(seq) * time.Second),
+			BatchCount: 1,
+		}},
+		Payload: types.RequestPayload{Queries: []types.Query{{Pattern: testIndexPatterns[queryType]}}},
+	}
+	if err = req.Sign(priv); err != nil {
+		return
+	}
+	resp := &types.SignedResponseHeader{ResponseHeader: types.ResponseHeader{
+		Request:      req.Header.RequestHeader,
+		RequestHash:  req.Header.Hash(),
+		NodeID:       testIndexNode,
+		Timestamp:    req.Header.Timestamp.Add(time.Millisecond),
+		RowCount:     1,
+		AffectedRows: int64(seq),
+	}}
+	if err = resp.BuildHash(); err != nil {
+		return
+	}
+	qt = &types.QueryAsTx{Request: req, Response: resp}
+	return
+}
+
+func TestQueryIndex(t *testing.T) {
+	Convey("Given an observer service with indexed queries", t, func() {
+		tmp, err := os.MkdirTemp("", "sqlit")
+		So(err, ShouldBeNil)
+		db, err := xs.NewSqlite(filepath.Join(tmp, dbFileName))
+		So(err, ShouldBeNil)
+		Reset(func() {
+			_ = db.Close()
+			_ = os.RemoveAll(tmp)
+		})
+		s := &Service{db: db}
+		So(s.initTables(), ShouldBeNil)
+
+		var (
+			keys   = make([]*asymmetric.PrivateKey, 2)
+			addrs  = make([]string, 2)
+			blocks = make([]*types.Block, 3)
+		)
+		for i := range keys {
+			var (
+				pub  *asymmetric.PublicKey
+				addr proto.AccountAddress
+			)
+			keys[i], pub, err = asymmetric.GenSecp256k1KeyPair()
+			So(err, ShouldBeNil)
+			addr, err = crypto.PubKeyHash(pub)
+			So(err, ShouldBeNil)
+			addrs[i] = addr.String()
+		}
+
+		// 3 blocks with 2 queries each, the second query of each block is a write of the second key
+		for i := 0; i < 3; i++ {
+			b := &types.Block{SignedHeader: types.SignedHeader{Header: types.Header{
+				Producer:  testIndexNode,
+				Timestamp: testIndexEpoch.Add(time.Duration(i) * time.Minute),
+			}}}
+			for j, qtype := range []types.QueryType{types.ReadQuery, types.WriteQuery} {
+				qt, err := buildTestQuery(keys[j], qtype, uint64(i*2+j))
+				So(err, ShouldBeNil)
+				b.QueryTxs = append(b.QueryTxs, qt)
+				So(s.addQueryTracker(testIndexDB, int32(i+1), int32(i*2), int32(j), qt), ShouldBeNil)
+			}
+			So(b.PackAndSignBlock(keys[0]), ShouldBeNil)
+			blocks[i] = b
+			enc, err := utils.EncodeMsgPack(b)
+			So(err, ShouldBeNil)
+			_, err = db.Writer().Exec(saveBlockSQL, string(testIndexDB), i*2, i+1, b.BlockHash().String(), enc.Bytes())
+			So(err, ShouldBeNil)
+		}
+
+		allQueries := &queryFilter{queryType: types.NumberOfQueryType}
+
+		Convey("The query list should be filtered and paginated", func() {
+			total, queries, err := s.listQueries(testIndexDB, allQueries, 1, 4)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 6)
+			So(queries, ShouldHaveLength, 4)
+			So(queries[0].count, ShouldEqual, 3)
+			So(queries[0].offset, ShouldEqual, 1)
+			So(queries[0].account, ShouldEqual, addrs[1])
+			So(queries[0].affectedRows, ShouldEqual, 5)
+
+			total, queries, err = s.listQueries(testIndexDB, allQueries, 2, 4)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 6)
+			So(queries, ShouldHaveLength, 2)
+
+			total, queries, err = s.listQueries(testIndexDB, &queryFilter{queryType: types.WriteQuery}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 3)
+			for _, q := range queries {
+				So(q.queryType, ShouldEqual, types.WriteQuery)
+			}
+
+			total, _, err = s.listQueries(testIndexDB, &queryFilter{
+				queryType: types.NumberOfQueryType,
+				account:   addrs[0],
+				since:     testIndexEpoch.Add(2 * time.Second),
+				until:     testIndexEpoch.Add(4 * time.Second),
+			}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 1)
+
+			total, _, err = s.listQueries("other", allQueries, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 0)
+		})
+		Convey("The account list should aggregate the queries of each signer", func() {
+			total, accounts, err := s.listAccounts(testIndexDB, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 2)
+			So(accounts, ShouldHaveLength, 2)
+			So(accounts[0].account, ShouldEqual, addrs[1])
+			So(accounts[0].reads, ShouldEqual, 0)
+			So(accounts[0].writes, ShouldEqual, 3)
+			So(accounts[1].account, ShouldEqual, addrs[0])
+			So(accounts[1].reads, ShouldEqual, 3)
+			So(accounts[1].first, ShouldEqual, testIndexEpoch)
+		})
+		Convey("The block list should be filtered by heights", func() {
+			total, blocks, err := s.listBlocks(testIndexDB, &blockFilter{fromHeight: 1, toHeight: -1}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 2)
+			So(blocks, ShouldHaveLength, 2)
+			So(blocks[0].count, ShouldEqual, 3)
+			So(blocks[0].height, ShouldEqual, 4)
+			So(blocks[0].block.QueryTxs, ShouldHaveLength, 2)
+		})
+		Convey("The query index should be rebuilt from the saved blocks", func() {
+			_, err = db.Writer().Exec(`DELETE FROM "query"`)
+			So(err, ShouldBeNil)
+			So(s.initTables(), ShouldBeNil)
+			total, _, err := s.listQueries(testIndexDB, allQueries, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 0)
+
+			_, err = db.Writer().Exec(fmt.Sprintf(setIndexVersionSQL, queryIndexVersion-1))
+			So(err, ShouldBeNil)
+			So(s.initTables(), ShouldBeNil)
+			total, queries, err := s.listQueries(testIndexDB, allQueries, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 6)
+			So(queries[5].sql, ShouldEqual, testIndexPatterns[types.ReadQuery])
+			So(queries[5].account, ShouldEqual, addrs[0])
+			So(queries[5].height, ShouldEqual, 0)
+		})
+		Convey("The queries should be searched by hashes, accounts and sql", func() {
+			_, queries, err := s.listQueries(testIndexDB, allQueries, 1, 1)
+			So(err, ShouldBeNil)
+			So(queries, ShouldHaveLength, 1)
+
+			for _, h := range []string{queries[0].hash, queries[0].response} {
+				total, found, err := s.listQueries("", &queryFilter{queryType: types.NumberOfQueryType, hash: h}, 1, 10)
+				So(err, ShouldBeNil)
+				So(total, ShouldEqual, 1)
+				So(found[0].db, ShouldEqual, testIndexDB)
+				So(found[0].hash, ShouldEqual, queries[0].hash)
+			}
+
+			total, _, err := s.listQueries("", &queryFilter{queryType: types.NumberOfQueryType, hash: addrs[0]}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 3)
+
+			total, _, err = s.listQueries("", &queryFilter{queryType: types.NumberOfQueryType, sql: "t_log"}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 3)
+			total, _, err = s.listQueries("", &queryFilter{queryType: types.NumberOfQueryType, sql: "t%log"}, 1, 10)
+			So(err, ShouldBeNil)
+			So(total, ShouldEqual, 0)
+
+			locations, err := s.searchBlocks(blocks[1].BlockHash().String())
+			So(err, ShouldBeNil)
+			So(locations, ShouldHaveLength, 1)
+			So(locations[0].db, ShouldEqual, testIndexDB)
+			So(locations[0].count, ShouldEqual, 2)
+			So(locations[0].height, ShouldEqual, 2)
+		})
+		Convey("The search API should return the matches of the term", func() {
+			var (
+				api = &explorerAPI{service: s}
+				res struct {
+					Success bool `json:"success"`
+					Data    struct {
+						Blocks []struct {
+							Count int32 `json:"count"`
+						} `json:"blocks"`
+						Transactions struct {
+							Items []struct {
+								SQL string `json:"sql"`
+							} `json:"items"`
+							Pagination struct {
+								Total int `json:"total"`
+							} `json:"pagination"`
+						} `json:"transactions"`
+					} `json:"data"`
+				}
+			)
+			rw := httptest.NewRecorder()
+			api.Search(rw, httptest.NewRequest("GET", "/v4/search?q="+blocks[2].BlockHash().String(), nil))
+			So(rw.Code, ShouldEqual, 200)
+			So(json.Unmarshal(rw.Body.Bytes(), &res), ShouldBeNil)
+			So(res.Data.Blocks, ShouldHaveLength, 1)
+			So(res.Data.Blocks[0].Count, ShouldEqual, 3)
+			So(res.Data.Transactions.Pagination.Total, ShouldEqual, 0)
+
+			rw = httptest.NewRecorder()
+			api.Search(rw, httptest.NewRequest("GET", "/v4/search?q=FROM+t+WHERE&db=db", nil))
+			So(rw.Code, ShouldEqual, 200)
+			So(json.Unmarshal(rw.Body.Bytes(), &res), ShouldBeNil)
+			So(res.Data.Transactions.Pagination.Total, ShouldEqual, 3)
+			So(res.Data.Transactions.Items[0].SQL, ShouldEqual, testIndexPatterns[types.ReadQuery])
+
+			rw = httptest.NewRecorder()
+			api.Search(rw, httptest.NewRequest("GET", "/v4/search?q=+", nil))
+			So(rw.Code, ShouldEqual, 400)
+		})
+		Convey("The transactions API should return a page of the query history", func() {
+			var (
+				api = &explorerAPI{service: s}
+				rw  = httptest.NewRecorder()
+				req = mux.SetURLVars(
+					httptest.NewRequest("GET", "/v4/dbs/db/transactions?type=write&size=2", nil),
+					map[string]string{"db": string(testIndexDB)})
+				res struct {
+					Success bool `json:"success"`
+					Data    struct {
+						Items []struct {
+							Hash    string `json:"hash"`
+							Type    string `json:"type"`
+							Account string `json:"account"`
+						} `json:"items"`
+						Pagination struct {
+							Page  int `json:"page"`
+							Size  int `json:"size"`
+							Total int `json:"total"`
+						} `json:"pagination"`
+					} `json:"data"`
+				}
+			)
+			api.ListTransactions(rw, req)
+			So(rw.Code, ShouldEqual, 200)
+			So(json.Unmarshal(rw.Body.Bytes(), &res), ShouldBeNil)
+			So(res.Success, ShouldBeTrue)
+			So(res.Data.Items, ShouldHaveLength, 2)
+			So(res.Data.Items[0].Type, ShouldEqual, types.WriteQuery.String())
+			So(res.Data.Items[0].Account, ShouldEqual, addrs[1])
+			So(res.Data.Pagination.Total, ShouldEqual, 3)
+
+			rw = httptest.NewRecorder()
+			api.ListTransactions(rw, mux.SetURLVars(
+				httptest.NewRequest("GET", "/v4/dbs/db/transactions?since=abc", nil),
+				map[string]string{"db": string(testIndexDB)}))
+			So(rw.Code, ShouldEqual, 400)
+		})
+	})
+}
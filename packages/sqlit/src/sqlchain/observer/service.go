@@ -3,6 +3,7 @@ package observer
 
 import (
 	"database/sql"
+	"fmt"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
@@ -24,6 +25,10 @@ import (
 
 const (
 	dbFileName = "observer.db3"
+
+	// queryIndexVersion is the layout version of the query index, the index is rebuilt from the
+	// saved blocks on a version change.
+	queryIndexVersion = 2
 )
 
 var (
@@ -72,6 +77,33 @@ var (
 			"count"	INTEGER
 		)`,
 	}
+	initQueryIndexSQL = []string{
+		`CREATE TABLE IF NOT EXISTS "query" (
+			"db"		TEXT,
+			"hash"		TEXT,
+			"response"	TEXT,
+			"count"		INTEGER,
+			"height"	INTEGER,
+			"offset"	INTEGER,
+			"type"		INTEGER,
+			"node"		TEXT,
+			"account"	TEXT,
+			"timestamp"	INTEGER,
+			"queries"	INTEGER,
+			"rows"		INTEGER,
+			"affected"	INTEGER,
+			"insert_id"	INTEGER,
+			"sql"		TEXT,
+			UNIQUE("db", "hash")
+		)`,
+		`CREATE INDEX IF NOT EXISTS "idx_query_db_timestamp" ON "query" ("db", "timestamp")`,
+		`CREATE INDEX IF NOT EXISTS "idx_query_db_account" ON "query" ("db", "account")`,
+		`CREATE INDEX IF NOT EXISTS "idx_query_hash" ON "query" ("hash")`,
+		`CREATE INDEX IF NOT EXISTS "idx_query_response" ON "query" ("response")`,
+		`CREATE INDEX IF NOT EXISTS "idx_query_account" ON "query" ("account", "timestamp")`,
+		`CREATE INDEX IF NOT EXISTS "idx_block_hash" ON "block" ("hash")`,
+		`CREATE INDEX IF NOT EXISTS "idx_ack_hash" ON "ack" ("hash")`,
+	}
 	getAllSubscriptionsSQL = `SELECT "db", "count" FROM "subscription"`
 	saveSubscriptionSQL    = `INSERT OR REPLACE INTO "subscription" ("db", "count") VALUES(?, ?)`
 	saveAckSQL             = `INSERT OR REPLACE INTO "ack" ("db", "hash", "height", "offset") VALUES(?, ?, ?, ?)`
@@ -85,6 +117,9 @@ var (
 	getBlockByHeightSQL    = `SELECT "count", "block" FROM "block" WHERE "db" = ? AND "height" = ? LIMIT 1`
 	getBlockByCountSQL     = `SELECT "height", "block" FROM "block" WHERE "db" = ? AND "count" = ? LIMIT 1`
 	getBlockByHashSQL      = `SELECT "height", "count", "block" FROM "block" WHERE "db" = ? AND "hash" = ? LIMIT 1`
+	getIndexVersionSQL     = `PRAGMA user_version`
+	setIndexVersionSQL     = `PRAGMA user_version = %d`
+	dropQueryIndexSQL      = `DROP TABLE IF EXISTS "query"`
 )
 
 // Service defines the observer service structure.
@@ -95,6 +130,11 @@ type Service struct {
 	db      *xs.SQLite3
 	caller  *rpc.Caller
 	stopped int32
+
+	feedLock sync.Mutex
+	feeds    map[proto.DatabaseID]*changeFeed
+
+	tails *queryTail
 }
 
 // NewService creates new observer service and load previous subscription from the meta database.
@@ -113,17 +153,16 @@ func NewService() (service *Service, err error) {
 		}
 	}()
 
-	for _, q := range initTableSQL {
-		if _, err = db.Writer().Exec(q); err != nil {
-			err = errors.Wrap(err, "init table failed")
-			return
-		}
-	}
-
 	// init service
 	service = &Service{
 		db:     db,
 		caller: rpc.NewCallerWithPool(mux.GetSessionPoolInstance()),
+		feeds:  make(map[proto.DatabaseID]*changeFeed),
+		tails:  newQueryTail(),
+	}
+
+	if err = service.initTables(); err != nil {
+		return
 	}
 
 	// load previous subscriptions
@@ -152,10 +191,52 @@ func NewService() (service *Service, err error) {
 
 		service.subscription.Store(dbID, newSubscribeWorker(dbID, count, service))
 	}
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	// restart the change feeds
+	err = service.loadChangeFeeds()
 
 	return
 }
 
+func (s *Service) initTables() (err error) {
+	for _, q := range initTableSQL {
+		if _, err = s.db.Writer().Exec(q); err != nil {
+			err = errors.Wrap(err, "init table failed")
+			return
+		}
+	}
+
+	// the query index is derived from the saved blocks, rebuild it when its layout is outdated
+	var version int
+	if err = s.db.Writer().QueryRow(getIndexVersionSQL).Scan(&version); err != nil {
+		err = errors.Wrap(err, "check query index version failed")
+		return
+	}
+	if version == queryIndexVersion {
+		return
+	}
+	if _, err = s.db.Writer().Exec(dropQueryIndexSQL); err != nil {
+		err = errors.Wrap(err, "drop outdated query index failed")
+		return
+	}
+	for _, q := range initQueryIndexSQL {
+		if _, err = s.db.Writer().Exec(q); err != nil {
+			err = errors.Wrap(err, "init query index failed")
+			return
+		}
+	}
+	if err = s.rebuildQueryIndex(); err != nil {
+		return
+	}
+	if _, err = s.db.Writer().Exec(fmt.Sprintf(setIndexVersionSQL, queryIndexVersion)); err != nil {
+		err = errors.Wrap(err, "save query index version failed")
+	}
+	return
+}
+
 func (s *Service) subscribe(dbID proto.DatabaseID, resetSubscribePosition string) (err error) {
 	if atomic.LoadInt32(&s.stopped) == 1 {
 		return ErrStopped
@@ -247,7 +328,9 @@ func (s *Service) addAck(dbID proto.DatabaseID, height int32, offset int32, ack
 	return
 }
 
-func (s *Service) addQueryTracker(dbID proto.DatabaseID, height int32, offset int32, qt *types.QueryAsTx) (err error) {
+func (s *Service) addQueryTracker(
+	dbID proto.DatabaseID, count, height, offset int32, qt *types.QueryAsTx) (err error,
+) {
 	log.WithFields(log.Fields{
 		"req":  qt.Request.Header.Hash(),
 		"resp": qt.Response.Hash(),
@@ -273,8 +356,9 @@ func (s *Service) addQueryTracker(dbID proto.DatabaseID, height int32, offset in
 	_, err = s.db.Writer().Exec(saveResponseSQL, string(dbID), qt.Response.Hash().String(), height, offset)
 	if err != nil {
 		err = errors.Wrapf(err, "save response failed: %s, %s, %d", dbID, qt.Response.Hash().String(), height)
+		return
 	}
-	return
+	return s.indexQuery(dbID, count, height, offset, qt)
 }
 
 func (s *Service) addBlock(dbID proto.DatabaseID, count int32, b *types.Block) (err error) {
@@ -314,11 +398,14 @@ func (s *Service) addBlock(dbID proto.DatabaseID, count int32, b *types.Block) (
 
 	// save queries
 	for i, q := range b.QueryTxs {
-		if err = s.addQueryTracker(dbID, h, int32(i), q); err != nil {
+		if err = s.addQueryTracker(dbID, count, h, int32(i), q); err != nil {
 			return
 		}
 	}
 
+	s.notifyChangeFeed(dbID)
+	s.publishQueries(dbID, count, h, b)
+
 	return
 }
 
@@ -336,6 +423,10 @@ func (s *Service) stop() (err error) {
 		return true
 	})
 
+	// stop the change feeds before the saved blocks are closed
+	s.stopChangeFeeds()
+	s.tails.close()
+
 	// close the subscription database
 	_ = s.db.Close()
 
@@ -359,32 +450,19 @@ func (s *Service) getUpstream(dbID proto.DatabaseID) (instance *types.ServiceIns
 		return
 	}
 
-	curBP, err := mux.GetCurrentBP()
-	if err != nil {
-		return
-	}
-
 	privateKey, err := kms.GetLocalPrivateKey()
 	if err != nil {
 		return
 	}
 
-	var (
-		req = &types.QuerySQLChainProfileReq{
-			DBID: dbID,
-		}
-		resp = &types.QuerySQLChainProfileResp{}
-	)
 	// get peers list from block producer
-	if err = s.caller.CallNode(
-		curBP, route.MCCQuerySQLChainProfile.String(), req, resp,
-	); err != nil {
+	profile, err := s.getProfile(dbID)
+	if err != nil {
 		return
 	}
 
 	// Build server instance from sqlchain profile
 	var (
-		profile = resp.Profile
 		nodeids = make([]proto.NodeID, len(profile.Miners))
 		peers   *proto.Peers
 		genesis = &types.Block{}
@@ -415,6 +493,28 @@ func (s *Service) getUpstream(dbID proto.DatabaseID) (instance *types.ServiceIns
 	return
 }
 
+func (s *Service) getProfile(dbID proto.DatabaseID) (profile *types.SQLChainProfile, err error) {
+	curBP, err := mux.GetCurrentBP()
+	if err != nil {
+		return
+	}
+
+	var (
+		req = &types.QuerySQLChainProfileReq{
+			DBID: dbID,
+		}
+		resp = &types.QuerySQLChainProfileResp{}
+	)
+	if err = s.caller.CallNode(
+		curBP, route.MCCQuerySQLChainProfile.String(), req, resp,
+	); err != nil {
+		return
+	}
+
+	profile = &resp.Profile
+	return
+}
+
 func (s *Service) getAck(dbID proto.DatabaseID, h *hash.Hash) (ack *types.SignedAckHeader, err error) {
 	var (
 		blockHeight int32
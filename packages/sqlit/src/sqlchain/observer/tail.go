@@ -0,0 +1,423 @@
+package observer
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"sqlit/src/crypto"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// tailBufferSize is the count of the queries buffered for a live tail subscriber, the queries
+	// are dropped if the subscriber can't keep up.
+	tailBufferSize = 256
+	// tailPingInterval is the interval to ping the live tail subscribers.
+	tailPingInterval = 30 * time.Second
+	// tailWriteTimeout is the timeout to write a message to a live tail subscriber.
+	tailWriteTimeout = 10 * time.Second
+	// tailReadLimit is the max size of the messages from a live tail subscriber, which only sends
+	// the control messages.
+	tailReadLimit = 512
+)
+
+// Payload redaction levels of the live tail.
+const (
+	// RedactNone streams the queries as they are.
+	RedactNone = "none"
+	// RedactArgs removes the argument values of the queries.
+	RedactArgs = "args"
+	// RedactAll also replaces the literals in the query patterns with "?".
+	RedactAll = "all"
+)
+
+var tailUpgrader = websocket.Upgrader{
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// TailQuery defines a committed query streamed to the live tail subscribers.
+type TailQuery struct {
+	Count  int32 `json:"count"`
+	Height int32 `json:"height"`
+	// Offset is the position of the query in the block
+	Offset   int32        `json:"offset"`
+	Hash     string       `json:"hash"`
+	Response string       `json:"response"`
+	Type     string       `json:"type"`
+	Node     proto.NodeID `json:"node"`
+	// Account is the address of the request signer
+	Account      string          `json:"account"`
+	Timestamp    time.Time       `json:"timestamp"`
+	Queries      []TailStatement `json:"queries"`
+	RowCount     uint64          `json:"row_count"`
+	AffectedRows int64           `json:"affected_rows"`
+	LastInsertID int64           `json:"last_insert_id"`
+}
+
+// TailStatement defines a statement of a committed query.
+type TailStatement struct {
+	Pattern string     `json:"pattern"`
+	Args    []QueryArg `json:"args,omitempty"`
+}
+
+// TailMessage defines a message of the live tail, which is either a query or the count of the
+// queries dropped since the last message as the subscriber falls behind.
+type TailMessage struct {
+	Query   *TailQuery `json:"query,omitempty"`
+	Dropped int64      `json:"dropped,omitempty"`
+}
+
+// tailFilter defines the queries streamed to a subscriber.
+type tailFilter struct {
+	queryType types.QueryType // -1 means any
+	account   string
+	redact    string
+}
+
+func (f *tailFilter) match(q *TailQuery) bool {
+	if f.queryType >= 0 && q.Type != f.queryType.String() {
+		return false
+	}
+	return f.account == "" || q.Account == f.account
+}
+
+// apply returns q with the payload redacted by the filter.
+func (f *tailFilter) apply(q *TailQuery) *TailQuery {
+	if f.redact == RedactNone {
+		return q
+	}
+	var r = *q
+	r.Queries = make([]TailStatement, len(q.Queries))
+	for i, s := range q.Queries {
+		r.Queries[i].Pattern = s.Pattern
+		if f.redact == RedactAll {
+			r.Queries[i].Pattern = redactLiterals(s.Pattern)
+		}
+		for _, a := range s.Args {
+			r.Queries[i].Args = append(r.Queries[i].Args, QueryArg{Name: a.Name})
+		}
+	}
+	return &r
+}
+
+type tailSubscriber struct {
+	filter  tailFilter
+	ch      chan *TailQuery
+	dropped int64
+}
+
+// queryTail dispatches the committed queries of the saved blocks to the live tail subscribers.
+type queryTail struct {
+	sync.Mutex
+	subs   map[proto.DatabaseID]map[*tailSubscriber]struct{}
+	closed bool
+}
+
+func newQueryTail() *queryTail {
+	return &queryTail{
+		subs: make(map[proto.DatabaseID]map[*tailSubscriber]struct{}),
+	}
+}
+
+func (t *queryTail) subscribe(dbID proto.DatabaseID, f tailFilter) (s *tailSubscriber, err error) {
+	t.Lock()
+	defer t.Unlock()
+	if t.closed {
+		err = ErrStopped
+		return
+	}
+	s = &tailSubscriber{
+		filter: f,
+		ch:     make(chan *TailQuery, tailBufferSize),
+	}
+	if t.subs[dbID] == nil {
+		t.subs[dbID] = make(map[*tailSubscriber]struct{})
+	}
+	t.subs[dbID][s] = struct{}{}
+	return
+}
+
+func (t *queryTail) unsubscribe(dbID proto.DatabaseID, s *tailSubscriber) {
+	t.Lock()
+	defer t.Unlock()
+	if _, ok := t.subs[dbID][s]; !ok {
+		return
+	}
+	delete(t.subs[dbID], s)
+	if len(t.subs[dbID]) == 0 {
+		delete(t.subs, dbID)
+	}
+	close(s.ch)
+}
+
+func (t *queryTail) has(dbID proto.DatabaseID) bool {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.subs[dbID]) > 0
+}
+
+// publish sends q to the matched subscribers of the database without blocking.
+func (t *queryTail) publish(dbID proto.DatabaseID, q *TailQuery) {
+	t.Lock()
+	defer t.Unlock()
+	for s := range t.subs[dbID] {
+		if !s.filter.match(q) {
+			continue
+		}
+		select {
+		case s.ch <- s.filter.apply(q):
+		default:
+			atomic.AddInt64(&s.dropped, 1)
+		}
+	}
+}
+
+// close disconnects all the subscribers.
+func (t *queryTail) close() {
+	t.Lock()
+	defer t.Unlock()
+	t.closed = true
+	for dbID, subs := range t.subs {
+		for s := range subs {
+			close(s.ch)
+		}
+		delete(t.subs, dbID)
+	}
+}
+
+// publishQueries streams the queries of the saved block to the live tail subscribers.
+func (s *Service) publishQueries(dbID proto.DatabaseID, count, height int32, b *types.Block) {
+	if !s.tails.has(dbID) {
+		return
+	}
+	for i, qt := range b.QueryTxs {
+		var (
+			req  = &qt.Request.Header
+			resp = qt.Response
+			q    = &TailQuery{
+				Count:        count,
+				Height:       height,
+				Offset:       int32(i),
+				Hash:         req.Hash().String(),
+				Response:     resp.Hash().String(),
+				Type:         req.QueryType.String(),
+				Node:         req.NodeID,
+				Timestamp:    req.Timestamp,
+				Queries:      make([]TailStatement, 0, len(qt.Request.Payload.Queries)),
+				RowCount:     resp.RowCount,
+				AffectedRows: resp.AffectedRows,
+				LastInsertID: resp.LastInsertID,
+			}
+		)
+		if req.Signee != nil {
+			if addr, err := crypto.PubKeyHash(req.Signee); err == nil {
+				q.Account = addr.String()
+			}
+		}
+		for _, v := range qt.Request.Payload.Queries {
+			var stmt = TailStatement{Pattern: v.Pattern}
+			for _, a := range v.Args {
+				stmt.Args = append(stmt.Args, QueryArg{Name: a.Name, Value: a.Value})
+			}
+			q.Queries = append(q.Queries, stmt)
+		}
+		s.tails.publish(dbID, q)
+	}
+}
+
+// TailQueries streams the committed queries of the database over a websocket as they are observed,
+// filtered by type (read or write) and account, the payloads are redacted by redact (none, args
+// or all).
+func (a *explorerAPI) TailQueries(rw http.ResponseWriter, r *http.Request) {
+	dbID, err := a.getDBID(mux.Vars(r))
+	if err != nil {
+		sendResponse(400, false, err, nil, rw)
+		return
+	}
+
+	var (
+		params = r.URL.Query()
+		filter = tailFilter{
+			queryType: -1,
+			account:   params.Get("account"),
+			redact:    params.Get("redact"),
+		}
+	)
+	switch params.Get("type") {
+	case "":
+	case "read":
+		filter.queryType = types.ReadQuery
+	case "write":
+		filter.queryType = types.WriteQuery
+	default:
+		sendResponse(400, false, fmt.Errorf("invalid type: %s", params.Get("type")), nil, rw)
+		return
+	}
+	switch filter.redact {
+	case "":
+		filter.redact = RedactNone
+	case RedactNone, RedactArgs, RedactAll:
+	default:
+		sendResponse(400, false, fmt.Errorf("invalid redact: %s", filter.redact), nil, rw)
+		return
+	}
+
+	if _, ok := a.service.subscription.Load(dbID); !ok {
+		if err = a.service.subscribe(dbID, "newest"); err != nil {
+			sendResponse(400, false, err, nil, rw)
+			return
+		}
+	}
+	sub, err := a.service.tails.subscribe(dbID, filter)
+	if err != nil {
+		sendResponse(500, false, err, nil, rw)
+		return
+	}
+	defer a.service.tails.unsubscribe(dbID, sub)
+
+	conn, err := tailUpgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		// the upgrader has responded the error
+		return
+	}
+	defer conn.Close()
+	le := log.WithFields(log.Fields{"db": dbID, "remote": r.RemoteAddr})
+	le.Debug("live tail connected")
+
+	// read the control messages until the subscriber is gone
+	var done = make(chan struct{})
+	conn.SetReadLimit(tailReadLimit)
+	go func() {
+		defer close(done)
+		_ = conn.SetReadDeadline(time.Now().Add(2 * tailPingInterval))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(2 * tailPingInterval))
+		})
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	var (
+		ticker = time.NewTicker(tailPingInterval)
+		write  = func(msg *TailMessage) error {
+			_ = conn.SetWriteDeadline(time.Now().Add(tailWriteTimeout))
+			return conn.WriteJSON(msg)
+		}
+	)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			le.Debug("live tail disconnected")
+			return
+		case <-ticker.C:
+			if err = conn.WriteControl(websocket.PingMessage, nil,
+				time.Now().Add(tailWriteTimeout)); err != nil {
+				return
+			}
+		case q, ok := <-sub.ch:
+			if !ok {
+				_ = conn.WriteControl(websocket.CloseMessage,
+					websocket.FormatCloseMessage(websocket.CloseGoingAway, ErrStopped.Error()),
+					time.Now().Add(tailWriteTimeout))
+				return
+			}
+			if dropped := atomic.SwapInt64(&sub.dropped, 0); dropped > 0 {
+				if err = write(&TailMessage{Dropped: dropped}); err != nil {
+					return
+				}
+			}
+			if err = write(&TailMessage{Query: q}); err != nil {
+				le.WithError(err).Debug("write live tail failed")
+				return
+			}
+		}
+	}
+}
+
+// redactLiterals replaces the string, blob and numeric literals of the query pattern with "?",
+// and removes the comments.
+func redactLiterals(pattern string) string {
+	var (
+		b strings.Builder
+		n = len(pattern)
+	)
+	for i := 0; i < n; {
+		c := pattern[i]
+		switch {
+		case c == '-' && i+1 < n && pattern[i+1] == '-':
+			for i < n && pattern[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && pattern[i+1] == '*':
+			if end := strings.Index(pattern[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = n
+			}
+		case c == '\'' || (c == 'x' || c == 'X') && i+1 < n && pattern[i+1] == '\'' &&
+			(i == 0 || !isIdentByte(pattern[i-1])):
+			if c != '\'' {
+				i++
+			}
+			for i++; i < n; i++ {
+				if pattern[i] == '\'' {
+					if i+1 < n && pattern[i+1] == '\'' {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+			b.WriteByte('?')
+		case c == '"' || c == '`' || c == '[':
+			// quoted identifiers are kept
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			start := i
+			for i++; i < n && pattern[i] != closing; i++ {
+			}
+			i++
+			if i > n {
+				i = n
+			}
+			b.WriteString(pattern[start:i])
+		case c >= '0' && c <= '9' && (i == 0 || !isIdentByte(pattern[i-1])):
+			for i < n && (isIdentByte(pattern[i]) || pattern[i] == '.' ||
+				(pattern[i] == '+' || pattern[i] == '-') && (pattern[i-1] == 'e' || pattern[i-1] == 'E')) {
+				i++
+			}
+			b.WriteByte('?')
+		case isIdentByte(c):
+			start := i
+			for i < n && isIdentByte(pattern[i]) {
+				i++
+			}
+			b.WriteString(pattern[start:i])
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return b.String()
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,135 @@
+package observer
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/types"
+)
+
+func TestRedactLiterals(t *testing.T) {
+	Convey("The literals of the query patterns should be redacted", t, func() {
+		for _, v := range []struct{ in, out string }{
+			{`SELECT * FROM t WHERE id = 1`, `SELECT * FROM t WHERE id = ?`},
+			{`INSERT INTO t VALUES ('it''s', x'0102', -1.5e+3, 0x1f, ?)`,
+				`INSERT INTO t VALUES (?, ?, -?, ?, ?)`},
+			{`UPDATE "t1" SET [v2] = 'a' -- secret`, `UPDATE "t1" SET [v2] = ? `},
+			{`SELECT x, t2.c3 FROM t2 /* secret */ WHERE c = :v`, `SELECT x, t2.c3 FROM t2  WHERE c = :v`},
+		} {
+			So(redactLiterals(v.in), ShouldEqual, v.out)
+		}
+	})
+}
+
+func TestQueryTail(t *testing.T) {
+	Convey("Given a live tail with subscribers", t, func() {
+		var (
+			tail = newQueryTail()
+			q    = &TailQuery{
+				Type:    types.WriteQuery.String(),
+				Account: "a",
+				Queries: []TailStatement{{
+					Pattern: `INSERT INTO t VALUES (1, ?)`,
+					Args:    []QueryArg{{Name: "v", Value: "secret"}},
+				}},
+			}
+		)
+		all, err := tail.subscribe(testIndexDB, tailFilter{queryType: -1, redact: RedactNone})
+		So(err, ShouldBeNil)
+		reads, err := tail.subscribe(testIndexDB, tailFilter{queryType: types.ReadQuery, redact: RedactNone})
+		So(err, ShouldBeNil)
+		redacted, err := tail.subscribe(testIndexDB, tailFilter{queryType: -1, account: "a", redact: RedactAll})
+		So(err, ShouldBeNil)
+		So(tail.has(testIndexDB), ShouldBeTrue)
+		So(tail.has("other"), ShouldBeFalse)
+
+		Convey("The queries should be filtered and redacted", func() {
+			tail.publish(testIndexDB, q)
+			So(<-all.ch, ShouldEqual, q)
+			So(reads.ch, ShouldBeEmpty)
+			r := <-redacted.ch
+			So(r.Queries[0].Pattern, ShouldEqual, `INSERT INTO t VALUES (?, ?)`)
+			So(r.Queries[0].Args, ShouldResemble, []QueryArg{{Name: "v"}})
+			So(q.Queries[0].Args[0].Value, ShouldEqual, "secret")
+		})
+		Convey("The queries should be dropped if the subscriber falls behind", func() {
+			for i := 0; i < tailBufferSize+3; i++ {
+				tail.publish(testIndexDB, q)
+			}
+			So(all.ch, ShouldHaveLength, tailBufferSize)
+			So(all.dropped, ShouldEqual, 3)
+		})
+		Convey("The subscribers should be closed", func() {
+			tail.unsubscribe(testIndexDB, reads)
+			_, ok := <-reads.ch
+			So(ok, ShouldBeFalse)
+			tail.close()
+			_, ok = <-all.ch
+			So(ok, ShouldBeFalse)
+			tail.unsubscribe(testIndexDB, all)
+			So(tail.has(testIndexDB), ShouldBeFalse)
+			_, err = tail.subscribe(testIndexDB, tailFilter{queryType: -1})
+			So(err, ShouldEqual, ErrStopped)
+		})
+	})
+}
+
+func TestTailQueries(t *testing.T) {
+	Convey("Given an explorer serving the live tail", t, func() {
+		var (
+			s      = &Service{tails: newQueryTail()}
+			router = mux.NewRouter()
+		)
+		s.subscription.Store(testIndexDB, (*subscribeWorker)(nil))
+		router.HandleFunc("/dbs/{db}/tail", (&explorerAPI{service: s}).TailQueries)
+		srv := httptest.NewServer(router)
+		Reset(srv.Close)
+		url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/dbs/" + string(testIndexDB) + "/tail"
+
+		Convey("The invalid parameters should be refused", func() {
+			_, resp, err := websocket.DefaultDialer.Dial(url+"?redact=some", nil)
+			So(err, ShouldNotBeNil)
+			So(resp.StatusCode, ShouldEqual, 400)
+		})
+		Convey("The committed queries should be streamed", func() {
+			conn, _, err := websocket.DefaultDialer.Dial(url+"?type=write&redact=args", nil)
+			So(err, ShouldBeNil)
+			defer conn.Close()
+			So(s.tails.has(testIndexDB), ShouldBeTrue)
+
+			priv, _, err := asymmetric.GenSecp256k1KeyPair()
+			So(err, ShouldBeNil)
+			b, err := buildTestWriteBlock(priv, 7, types.Query{
+				Pattern: `INSERT INTO t VALUES (?)`,
+				Args:    []types.NamedArg{{Name: "v", Value: "secret"}},
+			})
+			So(err, ShouldBeNil)
+			s.publishQueries(testIndexDB, 3, 6, b)
+
+			var msg TailMessage
+			So(conn.SetReadDeadline(time.Now().Add(5*time.Second)), ShouldBeNil)
+			So(conn.ReadJSON(&msg), ShouldBeNil)
+			So(msg.Query, ShouldNotBeNil)
+			So(msg.Query.Count, ShouldEqual, 3)
+			So(msg.Query.Height, ShouldEqual, 6)
+			So(msg.Query.Hash, ShouldEqual, b.QueryTxs[0].Request.Header.Hash().String())
+			So(msg.Query.Account, ShouldNotBeEmpty)
+			So(msg.Query.Queries, ShouldResemble, []TailStatement{{
+				Pattern: `INSERT INTO t VALUES (?)`,
+				Args:    []QueryArg{{Name: "v"}},
+			}})
+
+			// the subscribers are disconnected as the service stops
+			s.tails.close()
+			_, _, err = conn.ReadMessage()
+			So(websocket.IsCloseError(err, websocket.CloseGoingAway), ShouldBeTrue)
+		})
+	})
+}
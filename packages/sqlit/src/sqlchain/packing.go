@@ -0,0 +1,160 @@
+package sqlchain
+
+import (
+	"expvar"
+	"math"
+	"sync/atomic"
+	"time"
+
+	mw "github.com/zserge/metric"
+
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultMaxBlockQueries defines the default max count of queries packed in a block by the
+	// adaptive packing.
+	DefaultMaxBlockQueries = 10000
+	// DefaultMinBlockQueries defines the default floor of the adaptive max count of queries packed
+	// in a block, which holds the bursts at low load.
+	DefaultMinBlockQueries = 1000
+
+	// packingRateWeight is the weight of the latest period in the moving average arrival rate.
+	packingRateWeight = 0.3
+	// packingHeadroom is the ratio of the adaptive max count of queries in a block to the
+	// expected arrivals in a period.
+	packingHeadroom = 2
+)
+
+// PackingLimits defines the limits of the adaptive block packing. The period of the blocks is
+// fixed since the heights are derived from the time on all peers, so the max count of queries in
+// a block and the tick of the main cycle are adapted to the arrival rate of the queries instead.
+type PackingLimits struct {
+	// MinQueries and MaxQueries bound the max count of queries packed in a block, which grows
+	// with the arrival rate. The queries beyond it are packed in the following blocks in order.
+	MinQueries int
+	MaxQueries int
+	// MinTick and MaxTick bound the tick of the main cycle, which is the max interval of
+	// synchronizing the head block and reconciling the acks. A short tick at low load bounds the
+	// latency of catching up a missed block, a long tick at high load saves the overhead.
+	MinTick time.Duration
+	MaxTick time.Duration
+}
+
+// IsZero reports whether the limits are unset, which packs all the pooled queries in each block
+// at the fixed tick.
+func (l PackingLimits) IsZero() bool {
+	return l == PackingLimits{}
+}
+
+func (l PackingLimits) withDefaults(period, tick time.Duration) PackingLimits {
+	if l.MaxQueries <= 0 {
+		l.MaxQueries = DefaultMaxBlockQueries
+	}
+	if l.MinQueries <= 0 {
+		l.MinQueries = DefaultMinBlockQueries
+	}
+	if l.MinQueries > l.MaxQueries {
+		l.MinQueries = l.MaxQueries
+	}
+	if l.MaxTick <= 0 {
+		l.MaxTick = tick
+	}
+	// a tick close to the period skips the turns started late
+	if l.MaxTick > period/2 {
+		l.MaxTick = period / 2
+	}
+	if l.MinTick <= 0 {
+		l.MinTick = l.MaxTick / 4
+	}
+	if l.MinTick > l.MaxTick {
+		l.MinTick = l.MaxTick
+	}
+	return l
+}
+
+// packer adapts the block packing to the moving average arrival rate of the queries, it's adapted
+// by the main cycle only.
+type packer struct {
+	limits PackingLimits
+	period time.Duration
+
+	arrivals int64 // atomic, since the last adaption
+	rate     float64
+	last     time.Time
+}
+
+func newPacker(l PackingLimits, period, tick time.Duration) *packer {
+	if l.IsZero() {
+		return nil
+	}
+	return &packer{
+		limits: l.withDefaults(period, tick),
+		period: period,
+	}
+}
+
+// arrive records n arrived queries.
+func (p *packer) arrive(n int) {
+	if p != nil {
+		atomic.AddInt64(&p.arrivals, int64(n))
+	}
+}
+
+// adapt updates the arrival rate with the queries arrived till now, and returns the max count of
+// queries packed in the next block and the tick of the main cycle.
+func (p *packer) adapt(now time.Time) (maxQueries int, tick time.Duration) {
+	var n = atomic.SwapInt64(&p.arrivals, 0)
+	if !p.last.IsZero() && now.After(p.last) {
+		var rate = float64(n) / now.Sub(p.last).Seconds()
+		p.rate = packingRateWeight*rate + (1-packingRateWeight)*p.rate
+	}
+	p.last = now
+
+	var (
+		l        = p.limits
+		expected = p.rate * p.period.Seconds()
+		load     = math.Min(expected/float64(l.MaxQueries), 1)
+	)
+	maxQueries = int(math.Ceil(expected * packingHeadroom))
+	if maxQueries < l.MinQueries {
+		maxQueries = l.MinQueries
+	} else if maxQueries > l.MaxQueries {
+		maxQueries = l.MaxQueries
+	}
+	tick = l.MinTick + time.Duration(load*float64(l.MaxTick-l.MinTick))
+	return
+}
+
+// adaptPacking adapts the packing to the arrival rate at now, and returns the max count of queries
+// packed in the next block, 0 means unlimited.
+func (c *Chain) adaptPacking(now time.Time) (maxQueries int) {
+	if c.packer == nil {
+		return
+	}
+	var tick time.Duration
+	maxQueries, tick = c.packer.adapt(now)
+	if tick != c.rt.getTick() {
+		c.logEntry().WithFields(log.Fields{
+			"tick":         tick.String(),
+			"max_queries":  maxQueries,
+			"arrival_rate": c.packer.rate,
+		}).Debug("adapt block packing")
+		c.rt.setTick(tick)
+	}
+	c.expVars.Get(mwMinerChainPackingRate).(*expvar.Float).Set(c.packer.rate)
+	c.expVars.Get(mwMinerChainPackingMaxQueries).(*expvar.Int).Set(int64(maxQueries))
+	c.expVars.Get(mwMinerChainPackingTick).(*expvar.String).Set(tick.String())
+	return
+}
+
+// recordPacking records the count of queries packed in a block, which is full if it reaches
+// maxQueries.
+func (c *Chain) recordPacking(n, maxQueries int) {
+	c.expVars.Get(mwMinerChainBlockQueries).(mw.Metric).Add(float64(n))
+	if maxQueries > 0 && n >= maxQueries {
+		c.expVars.Get(mwMinerChainPackingFullBlocks).(*expvar.Int).Add(1)
+		c.logEntryWithHeadState().WithField("max_queries", maxQueries).Debug(
+			"block is full, the rest queries are packed in the following blocks")
+	}
+}
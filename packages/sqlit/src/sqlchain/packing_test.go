@@ -0,0 +1,68 @@
+package sqlchain
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPacker(t *testing.T) {
+	Convey("Given the packing limits", t, func() {
+		So(newPacker(PackingLimits{}, time.Minute, 10*time.Second), ShouldBeNil)
+		So(func() { (*packer)(nil).arrive(1) }, ShouldNotPanic)
+
+		var p = newPacker(PackingLimits{MaxQueries: 500}, time.Minute, 10*time.Second)
+		So(p, ShouldNotBeNil)
+		So(p.limits, ShouldResemble, PackingLimits{
+			MinQueries: 500,
+			MaxQueries: 500,
+			MinTick:    2500 * time.Millisecond,
+			MaxTick:    10 * time.Second,
+		})
+		So(newPacker(PackingLimits{MaxTick: time.Hour}, time.Minute, 0).limits.MaxTick,
+			ShouldEqual, 30*time.Second)
+	})
+	Convey("Given a packer", t, func() {
+		var (
+			now = time.Now()
+			p   = newPacker(PackingLimits{
+				MinQueries: 10,
+				MaxQueries: 1000,
+				MinTick:    time.Second,
+				MaxTick:    5 * time.Second,
+			}, 10*time.Second, 5*time.Second)
+		)
+		n, tick := p.adapt(now)
+		So(n, ShouldEqual, 10)
+		So(tick, ShouldEqual, time.Second)
+
+		Convey("The max queries and tick should grow with the arrival rate", func() {
+			var lastN, lastTick = n, tick
+			for i := 1; i <= 5; i++ {
+				p.arrive(200)
+				n, tick = p.adapt(now.Add(time.Duration(i) * 10 * time.Second))
+				So(n, ShouldBeGreaterThan, lastN)
+				So(tick, ShouldBeGreaterThan, lastTick)
+				lastN, lastTick = n, tick
+			}
+			So(n, ShouldBeLessThanOrEqualTo, 1000)
+			So(tick, ShouldBeLessThan, 5*time.Second)
+
+			for i := 6; i <= 20; i++ {
+				p.arrive(5000)
+				n, tick = p.adapt(now.Add(time.Duration(i) * 10 * time.Second))
+			}
+			So(n, ShouldEqual, 1000)
+			So(tick, ShouldEqual, 5*time.Second)
+
+			Convey("And shrink back at low load", func() {
+				for i := 21; i <= 60; i++ {
+					n, tick = p.adapt(now.Add(time.Duration(i) * 10 * time.Second))
+				}
+				So(n, ShouldEqual, 10)
+				So(tick, ShouldBeLessThan, 1100*time.Millisecond)
+			})
+		})
+	})
+}
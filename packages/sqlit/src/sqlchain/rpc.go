@@ -2,9 +2,18 @@
 package sqlchain
 
 import (
+	"context"
+
+	"sqlit/src/proto"
 	"sqlit/src/types"
 )
 
+// Transport defines the caller of the sql-chain RPC methods of the remote peers.
+type Transport interface {
+	CallNodeWithContext(
+		ctx context.Context, node proto.NodeID, method string, args, reply interface{}) error
+}
+
 // ChainRPCService defines a sql-chain RPC server.
 type ChainRPCService struct {
 	chain *Chain
@@ -23,27 +32,61 @@ type AdviseNewBlockResp struct {
 // FetchBlockReq defines a request of the FetchBlock RPC method.
 type FetchBlockReq struct {
 	Height int32
+	// CatchUp marks the fetches of a lagging peer, which are subject to the sync bandwidth limit
+	// of the target server.
+	CatchUp bool
 }
 
 // FetchBlockResp defines a response of the FetchBlock RPC method.
 type FetchBlockResp struct {
 	Height int32
 	Block  *types.Block
+	// Size is the encoded size of Block in the storage of the target server.
+	Size int
+}
+
+// AckQuery identifies the query of an ack by the height and the query key.
+type AckQuery struct {
+	Height int32
+	Key    types.QueryKey
+}
+
+// FetchAckReq defines a request of the FetchAck RPC method.
+type FetchAckReq struct {
+	Queries []AckQuery
+}
+
+// FetchAckResp defines a response of the FetchAck RPC method.
+type FetchAckResp struct {
+	Acks []*types.SignedAckHeader
 }
 
 // AdviseNewBlock is the RPC method to advise a new produced block to the target server.
 func (s *ChainRPCService) AdviseNewBlock(req *AdviseNewBlockReq, resp *AdviseNewBlockResp) (
 	err error) {
-	s.chain.blocks <- req.Block
-	return
+	return s.chain.enqueueBlock(s.chain.rt.ctx, req.Block)
 }
 
 // FetchBlock is the RPC method to fetch a known block from the target server.
 func (s *ChainRPCService) FetchBlock(req *FetchBlockReq, resp *FetchBlockResp) (err error) {
 	resp.Height = req.Height
-	resp.Block, err = s.chain.FetchBlock(req.Height)
+	resp.Block, resp.Size, err = s.chain.fetchBlock(req.Height)
 	if err == nil && resp.Block == nil {
 		resp.Height = s.chain.getCurrentHeight()
 	}
+	if err == nil && req.CatchUp {
+		err = s.chain.syncWriteLimiter.WaitN(s.chain.rt.ctx, resp.Size)
+	}
+	return
+}
+
+// FetchAck is the RPC method to fetch the acks of the queries responded by the target server,
+// the queries without acks are omitted.
+func (s *ChainRPCService) FetchAck(req *FetchAckReq, resp *FetchAckResp) (err error) {
+	for _, v := range req.Queries {
+		if ack := s.chain.ai.ack(v.Height, v.Key); ack != nil {
+			resp.Acks = append(resp.Acks, ack)
+		}
+	}
 	return
 }
@@ -5,11 +5,13 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"sqlit/src/crypto/hash"
 	"sqlit/src/proto"
 	"sqlit/src/types"
+	"sqlit/src/utils/fault"
 	"sqlit/src/utils/log"
 )
 
@@ -35,14 +37,20 @@ type runtime struct {
 
 	// period is the block producing cycle.
 	period time.Duration
-	// tick defines the maximum duration between each cycle.
-	tick time.Duration
+	// tick defines the maximum duration between each cycle in nanoseconds, it's accessed
+	// atomically since it's adapted to the load by the packer.
+	tick int64
 	// queryTTL sets the unacknowledged query TTL in block periods.
 	queryTTL int32
 	// blockCacheTTL sets the cached block numbers.
 	blockCacheTTL int32
 	// muxServer is the multiplexing service of sql-chain PRC.
 	muxService *MuxService
+	// clock is the time source of the runtime.
+	clock Clock
+	// deterministic runs the concurrent tasks inline, so that a simulation can drive the chain
+	// step by step in a reproducible order.
+	deterministic bool
 
 	// peersMutex protects following peers-relative fields.
 	peersMutex sync.Mutex
@@ -89,10 +97,11 @@ func newRunTime(ctx context.Context, c *Config) (r *runtime) {
 		cancel: ccl,
 
 		period:        c.Period,
-		tick:          c.Tick,
+		tick:          int64(c.Tick),
 		queryTTL:      c.QueryTTL,
 		blockCacheTTL: blockCacheTTLRequired(c),
 		muxService:    c.MuxService,
+		clock:         systemClock{},
 		peers:         c.Peers,
 		server:        c.Server,
 		index: func() int32 {
@@ -114,6 +123,9 @@ func newRunTime(ctx context.Context, c *Config) (r *runtime) {
 		offset:            time.Duration(0),
 	}
 
+	if c.Clock != nil {
+		r.clock = c.Clock
+	}
 	if c.Genesis != nil {
 		r.setGenesis(c.Genesis)
 	}
@@ -156,9 +168,10 @@ func (r *runtime) queryTimeIsExpired(t time.Time) bool {
 
 // now returns the current coodinated chain time.
 func (r *runtime) now() time.Time {
+	skew := fault.ClockSkew(string(r.getServer()))
 	r.timeMutex.Lock()
 	defer r.timeMutex.Unlock()
-	return time.Now().Add(r.offset)
+	return r.clock.Now().Add(r.offset + skew)
 }
 
 func (r *runtime) getChainTimeString() string {
@@ -209,13 +222,21 @@ func (r *runtime) nextTick() (t time.Time, d time.Duration) {
 	t = r.now()
 	d = r.chainInitTime.Add(time.Duration(r.nextTurn) * r.period).Sub(t)
 
-	if d > r.tick {
-		d = r.tick
+	if tick := r.getTick(); d > tick {
+		d = tick
 	}
 
 	return
 }
 
+func (r *runtime) getTick() time.Duration {
+	return time.Duration(atomic.LoadInt64(&r.tick))
+}
+
+func (r *runtime) setTick(tick time.Duration) {
+	atomic.StoreInt64(&r.tick, int64(tick))
+}
+
 func (r *runtime) updatePeers(peers *proto.Peers) (err error) {
 	r.peersMutex.Lock()
 	defer r.peersMutex.Unlock()
@@ -227,10 +248,11 @@ func (r *runtime) updatePeers(peers *proto.Peers) (err error) {
 		r.peers = peers
 		r.server = peers.Servers[index]
 	} else {
-		// Just clear the server list, and the database instance should call chain.Stop() later
+		// Keep following the peers without producing blocks as a standby, a removed node is
+		// stopped by the database instance later
 		r.index = -1
-		r.total = 0
-		r.peers.Servers = r.peers.Servers[:0]
+		r.total = int32(len(peers.Servers))
+		r.peers = peers
 	}
 
 	return
@@ -321,6 +343,12 @@ func (r *runtime) goFunc(f func(context.Context)) {
 }
 
 func (r *runtime) goFuncWithTimeout(f func(ctx context.Context), timeout time.Duration) {
+	if r.deterministic {
+		var ctx, ccl = context.WithTimeout(r.ctx, timeout)
+		defer ccl()
+		f(ctx)
+		return
+	}
 	r.wg.Add(1)
 	go func() {
 		var ctx, ccl = context.WithTimeout(r.ctx, timeout)
@@ -331,3 +359,16 @@ func (r *runtime) goFuncWithTimeout(f func(ctx context.Context), timeout time.Du
 		f(ctx)
 	}()
 }
+
+// spawn runs f in a new goroutine tracked by wg, or inline in deterministic mode.
+func (r *runtime) spawn(wg *sync.WaitGroup, f func()) {
+	if r.deterministic {
+		f()
+		return
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		f()
+	}()
+}
@@ -0,0 +1,203 @@
+package sqlchain
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/utils/log"
+)
+
+// SimNetwork is an in-memory Transport between the sql-chain peers of a Simulation. A call is
+// served inline by the multiplexing service of the remote peer, or dropped by the partition and
+// the drop rate with a seeded random source, so that the message delivery is reproducible.
+type SimNetwork struct {
+	sync.Mutex
+	rand     *rand.Rand
+	services map[proto.NodeID]*MuxService
+	groups   map[proto.NodeID]int
+	dropRate float64
+}
+
+// NewSimNetwork returns a new simulated network with the random seed.
+func NewSimNetwork(seed int64) *SimNetwork {
+	return &SimNetwork{
+		rand:     rand.New(rand.NewSource(seed)),
+		services: make(map[proto.NodeID]*MuxService),
+	}
+}
+
+// SetDropRate sets the probability of dropping a call.
+func (n *SimNetwork) SetDropRate(p float64) {
+	n.Lock()
+	defer n.Unlock()
+	n.dropRate = p
+}
+
+// Partition drops the calls between the nodes in different groups, the nodes not listed in any
+// group are not affected. It replaces the previous partition.
+func (n *SimNetwork) Partition(groups ...[]proto.NodeID) {
+	n.Lock()
+	defer n.Unlock()
+	n.groups = make(map[proto.NodeID]int)
+	for i, g := range groups {
+		for _, v := range g {
+			n.groups[v] = i
+		}
+	}
+}
+
+// Heal removes the partition.
+func (n *SimNetwork) Heal() {
+	n.Lock()
+	defer n.Unlock()
+	n.groups = nil
+}
+
+func (n *SimNetwork) register(node proto.NodeID, s *MuxService) {
+	n.Lock()
+	defer n.Unlock()
+	n.services[node] = s
+}
+
+// route returns the service of the remote node, or nil if the call is dropped.
+func (n *SimNetwork) route(from, to proto.NodeID) *MuxService {
+	n.Lock()
+	defer n.Unlock()
+	if g1, ok1 := n.groups[from]; ok1 {
+		if g2, ok2 := n.groups[to]; ok2 && g1 != g2 {
+			return nil
+		}
+	}
+	if n.dropRate > 0 && n.rand.Float64() < n.dropRate {
+		return nil
+	}
+	return n.services[to]
+}
+
+// simEndpoint is the Transport of a peer in the simulated network.
+type simEndpoint struct {
+	network *SimNetwork
+	node    proto.NodeID
+}
+
+// CallNodeWithContext implements Transport.CallNodeWithContext.
+func (e *simEndpoint) CallNodeWithContext(
+	ctx context.Context, node proto.NodeID, method string, args, reply interface{},
+) (err error) {
+	var s = e.network.route(e.node, node)
+	if s == nil {
+		err = errors.Wrapf(ErrUnreachablePeer, "call %s to peer %s failed", method, node)
+		return
+	}
+	switch method {
+	case route.SQLCAdviseNewBlock.String():
+		return s.AdviseNewBlock(args.(*MuxAdviseNewBlockReq), reply.(*MuxAdviseNewBlockResp))
+	case route.SQLCFetchBlock.String():
+		return s.FetchBlock(args.(*MuxFetchBlockReq), reply.(*MuxFetchBlockResp))
+	case route.SQLCFetchAck.String():
+		return s.FetchAck(args.(*MuxFetchAckReq), reply.(*MuxFetchAckResp))
+	default:
+		err = errors.Wrapf(ErrUnknownMuxRequest, "call %s to peer %s failed", method, node)
+		return
+	}
+}
+
+// Simulation runs the peers of a sql-chain in deterministic mode: the peers share a simulated
+// clock and an in-memory network, and each Step runs the main cycle of every peer inline in a
+// seeded random order. A same seed and a same sequence of operations reproduce a same run.
+type Simulation struct {
+	Clock   *SimClock
+	Network *SimNetwork
+
+	tick   time.Duration
+	rand   *rand.Rand
+	chains []*Chain
+}
+
+// NewSimulation returns a new simulation starting at the time, the clock is advanced by tick in
+// each step.
+func NewSimulation(seed int64, start time.Time, tick time.Duration) *Simulation {
+	return &Simulation{
+		Clock:   NewSimClock(start),
+		Network: NewSimNetwork(seed),
+		tick:    tick,
+		rand:    rand.New(rand.NewSource(seed)),
+	}
+}
+
+// NewChain creates a peer of the simulation with the config, the clock, transport and
+// multiplexing service of the config are replaced by the simulated ones.
+func (s *Simulation) NewChain(c *Config) (chain *Chain, err error) {
+	var mux = &MuxService{ServiceName: route.SQLChainRPCName}
+	c.Clock = s.Clock
+	c.Transport = &simEndpoint{network: s.Network, node: c.Server}
+	c.MuxService = mux
+	if chain, err = NewChain(c); err != nil {
+		return
+	}
+	chain.rt.deterministic = true
+	// Billing requests are sent to the block producers, which are out of the simulation
+	chain.updatePeriod = math.MaxInt32
+	s.Network.register(c.Server, mux)
+	s.chains = append(s.chains, chain)
+	return
+}
+
+// Chains returns the peers of the simulation.
+func (s *Simulation) Chains() []*Chain {
+	return s.chains
+}
+
+// Start starts all the peers of the simulation.
+func (s *Simulation) Start() (err error) {
+	for _, v := range s.chains {
+		if err = v.Start(); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Stop stops all the peers of the simulation.
+func (s *Simulation) Stop() (err error) {
+	for _, v := range s.chains {
+		if ierr := v.Stop(); ierr != nil && err == nil {
+			err = ierr
+		}
+	}
+	return
+}
+
+// Step advances the clock by a tick, and runs the main cycle of each peer until it waits for
+// the next turn or fails to synchronize the head block.
+func (s *Simulation) Step() {
+	s.Clock.Advance(s.tick)
+	for _, i := range s.rand.Perm(len(s.chains)) {
+		var c = s.chains[i]
+		for {
+			d, err := c.cycle()
+			if err != nil {
+				c.logEntry().WithError(err).Debug("failed to sync head")
+				break
+			}
+			if d > 0 {
+				break
+			}
+		}
+	}
+}
+
+// Run runs the steps for the duration of virtual time.
+func (s *Simulation) Run(d time.Duration) {
+	log.WithField("duration", d).Debug("running simulation")
+	for end := s.Clock.Now().Add(d); s.Clock.Now().Before(end); {
+		s.Step()
+	}
+}
@@ -0,0 +1,353 @@
+package sqlchain
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+var testSimulationSeq int32
+
+// newTestSimulation creates and starts a simulation of n peers with the seed, and a client of
+// each peer.
+func newTestSimulation(seed int64, n int) (sim *Simulation, clis []*nodeProfile, err error) {
+	genesis, err := createRandomBlock(genesisHash, true)
+	if err != nil {
+		return
+	}
+	_, peers, err := createTestPeers(n)
+	if err != nil {
+		return
+	}
+	var (
+		name = fmt.Sprintf("TestSimulation-%d-%d", seed, atomic.AddInt32(&testSimulationSeq, 1))
+		dbID = proto.DatabaseID(hash.THashH([]byte(name)).String())
+	)
+	sim = NewSimulation(seed, genesis.Timestamp(), testTick)
+	for i := range peers.Servers {
+		var (
+			dbfile = path.Join(testDataDir, fmt.Sprintf("%s-%02d", name, i))
+			chain  *Chain
+			cli    *nodeProfile
+		)
+		if chain, err = sim.NewChain(&Config{
+			DatabaseID:      dbID,
+			ChainFilePrefix: dbfile,
+			DataFile:        dbfile,
+			Genesis:         genesis,
+			Period:          testPeriod,
+			Tick:            testTick,
+			Server:          peers.Servers[i],
+			Peers:           peers,
+			QueryTTL:        testQueryTTL,
+		}); err != nil {
+			return
+		}
+		if cli, err = newRandomNode(chain, i == 0); err != nil {
+			return
+		}
+		clis = append(clis, cli)
+	}
+	if err = sim.Start(); err != nil {
+		return
+	}
+	req, err := clis[0].buildQuery(types.WriteQuery, []types.Query{
+		buildQuery(`CREATE TABLE t1 (k INT, v TEXT, PRIMARY KEY(k))`),
+		buildQuery(`INSERT INTO t1 (k, v) VALUES (?, ?)`, 1, "v1"),
+	})
+	if err != nil {
+		return
+	}
+	for _, v := range clis {
+		if err = v.sendQueryEx(req, false); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// runTestSimulation runs the simulation for the periods with a read query sent to a random peer
+// in each step, and returns the head heights of the peers after each step.
+func runTestSimulation(
+	sim *Simulation, clis []*nodeProfile, r *rand.Rand, periods int) (trace [][]int32, err error,
+) {
+	for i := 0; i < periods*int(testPeriod/testTick); i++ {
+		if err = clis[r.Intn(len(clis))].query(types.ReadQuery, []types.Query{
+			buildQuery(`SELECT v FROM t1 WHERE k=?`, 1),
+		}, false); err != nil {
+			return
+		}
+		sim.Step()
+		var heights = make([]int32, len(clis))
+		for j, v := range sim.Chains() {
+			heights[j] = v.rt.getHead().Height
+		}
+		trace = append(trace, heights)
+	}
+	return
+}
+
+// checkSafety returns an error if any two peers hold different blocks at a same height.
+func checkSafety(chains []*Chain) (err error) {
+	var blocks = make(map[int32]hash.Hash)
+	for _, c := range chains {
+		for n := c.rt.getHead().node; n != nil; n = n.parent {
+			if h, ok := blocks[n.height]; ok && !h.IsEqual(&n.hash) {
+				return fmt.Errorf("divergent blocks at height %d: %s and %s in peer %s",
+					n.height, h.Short(4), n.hash.Short(4), c.rt.getPeerInfoString())
+			}
+			blocks[n.height] = n.hash
+		}
+	}
+	return
+}
+
+// checkLiveness returns an error if the peers don't agree on a same head above the height.
+func checkLiveness(chains []*Chain, height int32) (err error) {
+	var head = chains[0].rt.getHead()
+	if head.Height <= height {
+		return fmt.Errorf("head height %d is not above %d", head.Height, height)
+	}
+	for _, c := range chains[1:] {
+		if h := c.rt.getHead(); !h.Head.IsEqual(&head.Head) {
+			return fmt.Errorf("peer %s has head %s at height %d, expected %s at height %d",
+				c.rt.getPeerInfoString(), h.Head.Short(4), h.Height, head.Head.Short(4), head.Height)
+		}
+	}
+	return
+}
+
+// runTestScenario runs a random fault scenario generated by the seed: the calls are dropped at
+// a random rate and a random peer is isolated for a while, then the faults are cleared.
+func runTestScenario(seed int64) (trace [][]int32, err error) {
+	var r = rand.New(rand.NewSource(seed))
+	sim, clis, err := newTestSimulation(seed, testPeersNumber)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sim.Stop() }()
+
+	var (
+		chains   = sim.Chains()
+		isolated = chains[r.Intn(len(chains))].rt.getServer()
+		others   []proto.NodeID
+		t        [][]int32
+	)
+	for _, v := range chains {
+		if s := v.rt.getServer(); s != isolated {
+			others = append(others, s)
+		}
+	}
+	var steps = []func(){
+		func() {},
+		func() { sim.Network.SetDropRate(r.Float64() * 0.3) },
+		func() { sim.Network.Partition(others, []proto.NodeID{isolated}) },
+		func() {
+			sim.Network.SetDropRate(0)
+			sim.Network.Heal()
+		},
+	}
+	for _, f := range steps {
+		f()
+		if t, err = runTestSimulation(sim, clis, r, 1+r.Intn(3)); err != nil {
+			return
+		}
+		trace = append(trace, t...)
+		if err = checkSafety(chains); err != nil {
+			return
+		}
+	}
+	var height = chains[0].rt.getHead().Height
+	if t, err = runTestSimulation(sim, clis, r, 3); err != nil {
+		return
+	}
+	trace = append(trace, t...)
+	if err = checkSafety(chains); err != nil {
+		return
+	}
+	err = checkLiveness(chains, height)
+	return
+}
+
+func TestSimulation(t *testing.T) {
+	Convey("Given a simulation of 5 peers", t, func() {
+		sim, clis, err := newTestSimulation(1, testPeersNumber)
+		So(err, ShouldBeNil)
+		defer func() { So(sim.Stop(), ShouldBeNil) }()
+
+		Convey("The peers should produce blocks in virtual time", func() {
+			var begin = time.Now()
+			_, err = runTestSimulation(sim, clis, rand.New(rand.NewSource(1)), 10)
+			So(err, ShouldBeNil)
+			So(sim.Clock.Now().Sub(sim.Chains()[0].rt.chainInitTime), ShouldEqual, 10*testPeriod)
+			So(time.Since(begin), ShouldBeLessThan, 10*testPeriod)
+			So(checkSafety(sim.Chains()), ShouldBeNil)
+			So(checkLiveness(sim.Chains(), 5), ShouldBeNil)
+		})
+		Convey("The peers should pack the blocks within the adaptive limits", func() {
+			for _, c := range sim.Chains() {
+				c.packer = newPacker(PackingLimits{MinQueries: 1, MaxQueries: 1}, testPeriod, testTick)
+			}
+			_, err = runTestSimulation(sim, clis, rand.New(rand.NewSource(1)), 10)
+			So(err, ShouldBeNil)
+			So(checkSafety(sim.Chains()), ShouldBeNil)
+			So(checkLiveness(sim.Chains(), 5), ShouldBeNil)
+			var (
+				chain = sim.Chains()[0]
+				full  int
+			)
+			for h := int32(1); h <= chain.rt.getHead().Height; h++ {
+				if b, err := chain.FetchBlock(h); err == nil && b != nil {
+					So(len(b.QueryTxs), ShouldBeLessThanOrEqualTo, 1)
+				}
+			}
+			for _, c := range sim.Chains() {
+				So(c.expVars.Get(mwMinerChainPackingMaxQueries).String(), ShouldEqual, "1")
+				if c.expVars.Get(mwMinerChainPackingFullBlocks).String() != "0" {
+					full++
+				}
+			}
+			So(full, ShouldBeGreaterThan, 0)
+		})
+		Convey("The block fetches should be throttled on the catch-up path only", func() {
+			_, err = runTestSimulation(sim, clis, rand.New(rand.NewSource(1)), 2)
+			So(err, ShouldBeNil)
+			var (
+				chain = sim.Chains()[1]
+				svc   = &ChainRPCService{chain: chain}
+				fetch = func(catchUp bool) (resp *FetchBlockResp, elapsed time.Duration) {
+					var begin = time.Now()
+					resp = &FetchBlockResp{}
+					So(svc.FetchBlock(&FetchBlockReq{Height: 1, CatchUp: catchUp}, resp), ShouldBeNil)
+					So(resp.Block, ShouldNotBeNil)
+					return resp, time.Since(begin)
+				}
+			)
+			resp, _ := fetch(false)
+			enc, err := utils.EncodeMsgPack(resp.Block)
+			So(err, ShouldBeNil)
+			So(resp.Size, ShouldEqual, enc.Len())
+
+			// the burst is taken by the first catch-up fetches, the next one waits for a quarter second
+			chain.syncWriteLimiter = utils.NewRateLimiter(int64(4 * resp.Size))
+			var elapsed time.Duration
+			for i := 0; i < 4; i++ {
+				_, elapsed = fetch(true)
+				So(elapsed, ShouldBeLessThan, 100*time.Millisecond)
+			}
+			for i := 0; i < 4; i++ {
+				_, elapsed = fetch(false)
+				So(elapsed, ShouldBeLessThan, 100*time.Millisecond)
+			}
+			_, elapsed = fetch(true)
+			So(elapsed, ShouldBeGreaterThanOrEqualTo, 150*time.Millisecond)
+		})
+		Convey("Flush should return after the next turn of the peer", func() {
+			var (
+				chain   = sim.Chains()[2]
+				turn    = chain.rt.getNextTurn()
+				done    = make(chan error, 1)
+				flushed bool
+			)
+			go func() { done <- chain.Flush(context.Background()) }()
+			for i := 0; !flushed && i < 2*testPeersNumber*int(testPeriod/testTick); i++ {
+				sim.Step()
+				select {
+				case err = <-done:
+					flushed = true
+				case <-time.After(time.Millisecond):
+				}
+			}
+			So(flushed, ShouldBeTrue)
+			So(err, ShouldBeNil)
+			So(chain.rt.getNextTurn(), ShouldBeGreaterThan, turn)
+		})
+		Convey("The leader should reconcile the responses without acks", func() {
+			var (
+				leader  = sim.Chains()[0]
+				reqs    = make([]*types.Request, 2)
+				resps   = make([]*types.SignedResponseHeader, 2)
+				unacked = func(req *types.Request) (ret *types.SignedResponseHeader) {
+					for _, v := range leader.ai.unacked(sim.Clock.Now().Add(time.Hour)) {
+						if v.Request.GetQueryKey() == req.Header.GetQueryKey() {
+							ret = v
+						}
+					}
+					return
+				}
+				steps = 2 * testPeersNumber * int(testPeriod/testTick)
+			)
+			// The response of peer 1 is acked after its block, and the one of peer 2 is never acked
+			for i := range reqs {
+				reqs[i], err = clis[i+1].buildQuery(types.ReadQuery, []types.Query{
+					buildQuery(`SELECT v FROM t1 WHERE k=?`, 1),
+				})
+				So(err, ShouldBeNil)
+				err = clis[i+1].sendQueryEx(reqs[i], false)
+				So(err, ShouldBeNil)
+			}
+			for i := 0; i < steps && (resps[0] == nil || resps[1] == nil); i++ {
+				sim.Step()
+				for j, v := range reqs {
+					if resps[j] == nil {
+						resps[j] = unacked(v)
+					}
+				}
+			}
+			So(resps[0], ShouldNotBeNil)
+			So(resps[1], ShouldNotBeNil)
+			ack, err := createRandomQueryAckWithResponse(resps[0], clis[1])
+			So(err, ShouldBeNil)
+			err = clis[1].Chain.VerifyAndPushAckedQuery(ack)
+			So(err, ShouldBeNil)
+
+			leader.ackWindow = testPeriod
+			sim.Run(2 * testPeriod)
+			So(unacked(reqs[0]), ShouldBeNil)
+			So(unacked(reqs[1]), ShouldBeNil)
+			So(leader.expVars.Get(mwMinerChainAcksRefetched).String(), ShouldEqual, "1")
+			So(leader.expVars.Get(mwMinerChainAcksExpired).String(), ShouldNotEqual, "0")
+
+			// The ack should be packed into a single block
+			_, err = runTestSimulation(sim, clis, rand.New(rand.NewSource(1)), 2*testPeersNumber)
+			So(err, ShouldBeNil)
+			var packed int
+			for h := int32(0); h <= leader.rt.getHead().Height; h++ {
+				if b, err := leader.FetchBlock(h); err == nil && b != nil {
+					for _, v := range b.Acks {
+						if v.Hash() == ack.Hash() {
+							packed++
+						}
+					}
+				}
+			}
+			So(packed, ShouldEqual, 1)
+		})
+	})
+	Convey("Given random fault scenarios", t, func() {
+		Convey("The peers should keep safety and liveness", func() {
+			for seed := int64(1); seed <= 3; seed++ {
+				_, err := runTestScenario(seed)
+				So(err, ShouldBeNil)
+			}
+		})
+		Convey("The scenario should be reproducible with a same seed", func() {
+			t1, err := runTestScenario(4)
+			So(err, ShouldBeNil)
+			t2, err := runTestScenario(4)
+			So(err, ShouldBeNil)
+			So(t2, ShouldResemble, t1)
+		})
+	})
+}
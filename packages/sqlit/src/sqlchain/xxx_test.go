@@ -290,7 +290,7 @@ func (p *nodeProfile) buildQuery(
 				DatabaseID:   p.Chain.databaseID,
 				ConnectionID: p.ConnectionID,
 				SeqNo:        atomic.AddUint64(&p.SeqNo, 1),
-				Timestamp:    time.Now().UTC(),
+				Timestamp:    p.Chain.rt.now().UTC(),
 				// BatchCount and QueriesHash will be set by req.Sign()
 			},
 		},
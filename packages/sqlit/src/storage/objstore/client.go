@@ -0,0 +1,287 @@
+package objstore
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Scheme is the URL scheme of object locations, such as "s3://bucket/key".
+	Scheme = "s3"
+
+	// DefaultRegion is the region used for signing if none is configured.
+	DefaultRegion = "us-east-1"
+
+	signAlgorithm   = "AWS4-HMAC-SHA256"
+	signService     = "s3"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	amzDateFormat   = "20060102T150405Z"
+	amzShortFormat  = "20060102"
+)
+
+var (
+	// ErrInvalidLocation indicates the object location is not a valid "s3://bucket/key" URL.
+	ErrInvalidLocation = errors.New("invalid object location")
+	// ErrObjectNotFound indicates the requested object does not exist.
+	ErrObjectNotFound = errors.New("object not found")
+)
+
+// Client defines an S3-compatible object store client.
+type Client struct {
+	endpoint  *url.URL
+	region    string
+	accessKey string
+	secretKey string
+	http      *http.Client
+	now       func() time.Time
+}
+
+// NewClient returns a new object store client with endpoint such as "https://s3.amazonaws.com"
+// or "http://127.0.0.1:9000".
+func NewClient(endpoint, region, accessKey, secretKey string) (c *Client, err error) {
+	var u *url.URL
+	if u, err = url.Parse(endpoint); err != nil {
+		err = errors.Wrapf(err, "parse endpoint %s", endpoint)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		err = errors.Errorf("invalid endpoint %s", endpoint)
+		return
+	}
+	if region == "" {
+		region = DefaultRegion
+	}
+	c = &Client{
+		endpoint:  u,
+		region:    region,
+		accessKey: accessKey,
+		secretKey: secretKey,
+		http:      &http.Client{},
+		now:       time.Now,
+	}
+	return
+}
+
+// ParseLocation splits an object location "s3://bucket/key" into bucket and key.
+func ParseLocation(location string) (bucket, key string, err error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != Scheme || u.Host == "" {
+		err = errors.Wrapf(ErrInvalidLocation, "location %s", location)
+		return
+	}
+	bucket = u.Host
+	key = strings.TrimPrefix(u.Path, "/")
+	return
+}
+
+// IsLocation returns whether target is an object location rather than a local path.
+func IsLocation(target string) bool {
+	return strings.HasPrefix(target, Scheme+"://")
+}
+
+// PutObject uploads size bytes from body to bucket/key.
+func (c *Client) PutObject(
+	ctx context.Context, bucket, key string, body io.Reader, size int64) (err error,
+) {
+	var req *http.Request
+	if req, err = c.newRequest(ctx, http.MethodPut, bucket, key, body); err != nil {
+		return
+	}
+	req.ContentLength = size
+	_, err = c.do(req)
+	return
+}
+
+// GetObject downloads bucket/key, the caller must close the returned reader.
+func (c *Client) GetObject(ctx context.Context, bucket, key string) (body io.ReadCloser, err error) {
+	var (
+		req  *http.Request
+		resp *http.Response
+	)
+	if req, err = c.newRequest(ctx, http.MethodGet, bucket, key, nil); err != nil {
+		return
+	}
+	if resp, err = c.do(req); err != nil {
+		return
+	}
+	body = resp.Body
+	return
+}
+
+// DeleteObject removes bucket/key.
+func (c *Client) DeleteObject(ctx context.Context, bucket, key string) (err error) {
+	var req *http.Request
+	if req, err = c.newRequest(ctx, http.MethodDelete, bucket, key, nil); err != nil {
+		return
+	}
+	_, err = c.do(req)
+	return
+}
+
+func (c *Client) newRequest(
+	ctx context.Context, method, bucket, key string, body io.Reader) (req *http.Request, err error,
+) {
+	u := *c.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + bucket + "/" + key
+	u.RawPath = strings.TrimSuffix(c.endpoint.EscapedPath(), "/") + "/" + escapePath(bucket+"/"+key)
+	if req, err = http.NewRequest(method, u.String(), body); err != nil {
+		return
+	}
+	req = req.WithContext(ctx)
+	c.sign(req)
+	return
+}
+
+func (c *Client) do(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = c.http.Do(req); err != nil {
+		return
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		if req.Method != http.MethodGet {
+			_ = resp.Body.Close()
+		}
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		err = errors.Wrapf(ErrObjectNotFound, "%s %s", req.Method, req.URL.Path)
+		return
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("%s %s: unexpected status %d: %s",
+		req.Method, req.URL.Path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	return
+}
+
+// sign adds the AWS Signature Version 4 authorization headers to req, the payload is not signed.
+func (c *Client) sign(req *http.Request) {
+	var (
+		now       = c.now().UTC()
+		amzDate   = now.Format(amzDateFormat)
+		shortDate = now.Format(amzShortFormat)
+		scope     = strings.Join([]string{shortDate, c.region, signService, "aws4_request"}, "/")
+	)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	var (
+		headers = map[string]string{
+			"host":                 req.URL.Host,
+			"x-amz-content-sha256": unsignedPayload,
+			"x-amz-date":           amzDate,
+		}
+		names = make([]string, 0, len(headers))
+	)
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+	stringToSign := strings.Join([]string{
+		signAlgorithm, amzDate, scope, hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+	signature := hex.EncodeToString(
+		hmacSHA256(signingKey(c.secretKey, shortDate, c.region, signService), []byte(stringToSign)))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signAlgorithm, c.accessKey, scope, signedHeaders, signature))
+}
+
+func signingKey(secret, shortDate, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(shortDate))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	h := sha256.Sum256(data)
+	return hex.EncodeToString(h[:])
+}
+
+func canonicalQuery(v url.Values) string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string(nil), v[k]...)
+		sort.Strings(vs)
+		for _, val := range vs {
+			parts = append(parts, escape(k)+"="+escape(val))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escapePath escapes each segment of p as required by Signature Version 4.
+func escapePath(p string) string {
+	segs := strings.Split(p, "/")
+	for i := range segs {
+		segs[i] = escape(segs[i])
+	}
+	return strings.Join(segs, "/")
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// PutFile uploads the local file at path to bucket/key.
+func (c *Client) PutFile(ctx context.Context, bucket, key, path string) (err error) {
+	var (
+		f  *os.File
+		fi os.FileInfo
+	)
+	if f, err = os.Open(path); err != nil {
+		return
+	}
+	defer f.Close()
+	if fi, err = f.Stat(); err != nil {
+		return
+	}
+	return c.PutObject(ctx, bucket, key, f, fi.Size())
+}
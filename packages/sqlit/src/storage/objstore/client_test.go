@@ -0,0 +1,96 @@
+package objstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type memStore struct {
+	sync.Mutex
+	objects map[string][]byte
+}
+
+func (s *memStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), signAlgorithm+" Credential=ak/") {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		s.objects[r.URL.Path] = data
+	case http.MethodGet:
+		data, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(s.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestSigningKey(t *testing.T) {
+	Convey("signing key should match the AWS documented example", t, func() {
+		key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+		So(hex.EncodeToString(key), ShouldEqual,
+			"f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d")
+	})
+}
+
+func TestParseLocation(t *testing.T) {
+	Convey("test parse object location", t, func() {
+		bucket, key, err := ParseLocation("s3://backup/db/1.db3")
+		So(err, ShouldBeNil)
+		So(bucket, ShouldEqual, "backup")
+		So(key, ShouldEqual, "db/1.db3")
+		So(IsLocation("s3://backup/db"), ShouldBeTrue)
+		So(IsLocation("/data/backup"), ShouldBeFalse)
+		_, _, err = ParseLocation("/data/backup")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestClient(t *testing.T) {
+	Convey("test object store client", t, func() {
+		store := &memStore{objects: make(map[string][]byte)}
+		server := httptest.NewServer(store)
+		defer server.Close()
+
+		c, err := NewClient(server.URL, "", "ak", "sk")
+		So(err, ShouldBeNil)
+		ctx := context.Background()
+
+		data := []byte("hello object")
+		err = c.PutObject(ctx, "bucket", "a b/c.txt", bytes.NewReader(data), int64(len(data)))
+		So(err, ShouldBeNil)
+		So(store.objects, ShouldContainKey, "/bucket/a b/c.txt")
+
+		body, err := c.GetObject(ctx, "bucket", "a b/c.txt")
+		So(err, ShouldBeNil)
+		got, err := io.ReadAll(body)
+		So(body.Close(), ShouldBeNil)
+		So(err, ShouldBeNil)
+		So(got, ShouldResemble, data)
+
+		So(c.DeleteObject(ctx, "bucket", "a b/c.txt"), ShouldBeNil)
+		_, err = c.GetObject(ctx, "bucket", "a b/c.txt")
+		So(err, ShouldNotBeNil)
+
+		_, err = NewClient("not a url", "", "ak", "sk")
+		So(err, ShouldNotBeNil)
+	})
+}
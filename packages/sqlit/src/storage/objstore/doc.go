@@ -0,0 +1,4 @@
+// Package objstore provides a minimal client of S3-compatible object stores, signing requests
+// with AWS Signature Version 4 and using path-style bucket addressing so that it works with
+// AWS S3, MinIO and most other compatible services.
+package objstore
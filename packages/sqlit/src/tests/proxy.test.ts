@@ -0,0 +1,193 @@
+/**
+ * SQLit Proxy Client Tests
+ *
+ * Tests against a mocked proxy covering:
+ * - Request encoding and authentication
+ * - Pagination
+ * - Retries
+ * - Error handling
+ */
+
+import { describe, expect, it } from 'bun:test'
+import { SQLitProxyClient } from '../proxy'
+import { SQLitError } from '../types'
+
+type Call = { url: URL; method: string; headers: Headers; body: unknown }
+
+function mockProxy(
+  handler: (call: Call) => { status?: number; body: unknown } | Error,
+): { fetch: typeof fetch; calls: Call[] } {
+  const calls: Call[] = []
+  const mocked = async (input: RequestInfo | URL, init?: RequestInit) => {
+    const call: Call = {
+      url: new URL(input.toString()),
+      method: init?.method ?? 'GET',
+      headers: new Headers(init?.headers),
+      body: init?.body ? JSON.parse(init.body as string) : undefined,
+    }
+    calls.push(call)
+    const result = handler(call)
+    if (result instanceof Error) {
+      throw result
+    }
+    return new Response(JSON.stringify(result.body), {
+      status: result.status ?? 200,
+      headers: { 'Content-Type': 'application/json' },
+    })
+  }
+  return { fetch: mocked as typeof fetch, calls }
+}
+
+const ok = (data: unknown) => ({ body: { success: true, msg: '', data } })
+
+const PROJECT = 'a'.repeat(64)
+
+interface User {
+  id: number
+  name: string
+}
+
+describe('SQLitProxyClient', () => {
+  it('should send the typed queries', async () => {
+    const proxy = mockProxy(() => ok([{ id: 1, name: 'alice' }]))
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy/',
+      project: PROJECT,
+      token: 'secret',
+      fetch: proxy.fetch,
+    })
+
+    const users = await client.query<User>('users', {
+      filter: { name: { $in: ['alice', 'bob'] }, $or: [{ id: 1 }] },
+      order: { id: -1 },
+      limit: 10,
+    })
+    expect(users[0].name).toBe('alice')
+
+    const call = proxy.calls[0]
+    expect(call.url.pathname).toBe('/v3/data/users/find')
+    expect(call.url.searchParams.get('project')).toBe(PROJECT)
+    expect(call.method).toBe('POST')
+    expect(call.headers.get('X-SQLIT-Token')).toBe('secret')
+    expect(call.body).toEqual({
+      filter: { name: { $in: ['alice', 'bob'] }, $or: [{ id: 1 }] },
+      order: { id: -1 },
+      limit: 10,
+    })
+  })
+
+  it('should run the writes', async () => {
+    const proxy = mockProxy((call) =>
+      call.url.pathname.endsWith('/insert')
+        ? ok({ last_insert_id: 7, affected_rows: 1 })
+        : ok({ affected_rows: 2 }),
+    )
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy',
+      fetch: proxy.fetch,
+    })
+
+    expect(await client.insert('users', { name: 'carol' })).toEqual({
+      affectedRows: 1,
+      lastInsertId: 7,
+    })
+    const results = await client.batch([
+      {
+        op: 'update',
+        table: 'users',
+        filter: { id: 7 },
+        update: { $inc: { n: 1 } },
+      },
+      { op: 'remove', table: 'users', filter: { id: { $lt: 3 } }, one: true },
+    ])
+    expect(results.map((r) => r.affectedRows)).toEqual([2, 2])
+    expect(proxy.calls[1].url.pathname).toBe('/v3/data/users/update')
+    expect(proxy.calls[1].body).toEqual({
+      filter: { id: 7 },
+      update: { $inc: { n: 1 } },
+    })
+    expect(proxy.calls[2].body).toEqual({
+      filter: { id: { $lt: 3 } },
+      one: true,
+    })
+  })
+
+  it('should paginate by skip and limit', async () => {
+    const rows = Array.from({ length: 5 }, (_, i) => ({ id: i + 1 }))
+    const proxy = mockProxy((call) => {
+      const { skip, limit } = call.body as { skip: number; limit: number }
+      return ok(rows.slice(skip, skip + limit))
+    })
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy',
+      fetch: proxy.fetch,
+    })
+
+    const pages: number[][] = []
+    for await (const page of client.paginate<{ id: number }>('t', {
+      order: { id: 1 },
+      pageSize: 2,
+    })) {
+      pages.push(page.map((r) => r.id))
+    }
+    expect(pages).toEqual([[1, 2], [3, 4], [5]])
+    expect(await client.queryAll('t', { pageSize: 5 })).toHaveLength(5)
+    // the full last page is followed by an empty page
+    expect(proxy.calls).toHaveLength(5)
+  })
+
+  it('should retry the reads only', async () => {
+    let failures = 2
+    const proxy = mockProxy(() => {
+      if (failures-- > 0) {
+        return { status: 503, body: { success: false, msg: 'unavailable' } }
+      }
+      return ok({ count: 3 })
+    })
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy',
+      fetch: proxy.fetch,
+      retry: { minDelayMs: 1, maxDelayMs: 2 },
+    })
+
+    expect(await client.count('t')).toBe(3)
+    expect(proxy.calls).toHaveLength(3)
+
+    failures = 1
+    await expect(client.remove('t', { id: 1 })).rejects.toBeInstanceOf(
+      SQLitError,
+    )
+    expect(proxy.calls).toHaveLength(4)
+  })
+
+  it('should surface the proxy errors', async () => {
+    const proxy = mockProxy(() => ({
+      status: 403,
+      body: { success: false, msg: 'ERR_ENFORCE_RULE_ON_QUERY_FAILED' },
+    }))
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy',
+      fetch: proxy.fetch,
+    })
+
+    const error = await client.query('t').catch((e: unknown) => e)
+    expect(error).toBeInstanceOf(SQLitError)
+    expect((error as SQLitError).code).toBe('ERR_ENFORCE_RULE_ON_QUERY_FAILED')
+    expect((error as SQLitError).details).toEqual({ status: 403 })
+  })
+
+  it('should throw the graphql errors', async () => {
+    const proxy = mockProxy(() => ({
+      status: 400,
+      body: { errors: [{ message: 'unknown field' }] },
+    }))
+    const client = new SQLitProxyClient({
+      endpoint: 'http://proxy',
+      fetch: proxy.fetch,
+    })
+
+    await expect(client.graphql('{ users { nope } }')).rejects.toThrow(
+      'unknown field',
+    )
+  })
+})
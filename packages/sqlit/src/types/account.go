@@ -126,6 +126,24 @@ func (r *UserPermissionRole) FromString(perm string) {
 	}
 }
 
+// ParseUserPermissionRole parses the role name case-insensitively, such as "readonly",
+// "readwrite", "writeonly", "admin" and "void", the dashes are ignored like "read-only".
+func ParseUserPermissionRole(name string) (r UserPermissionRole, ok bool) {
+	switch strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), "-", "")) {
+	case "readonly", "read":
+		return ReadOnly, true
+	case "writeonly":
+		return WriteOnly, true
+	case "readwrite":
+		return ReadWrite, true
+	case "admin":
+		return Admin, true
+	case "void", "none":
+		return Void, true
+	}
+	return
+}
+
 // UserPermissionFromRole construct a new user permission instance from primitive user permission role enum.
 func UserPermissionFromRole(role UserPermissionRole) *UserPermission {
 	return &UserPermission{
@@ -235,6 +253,8 @@ type MinerInfo struct {
 	Name          string
 	Status        Status
 	EncryptionKey string
+	Region        string
+	Zone          string
 }
 
 // SQLChainProfile defines a SQLChainProfile related to an account.
@@ -247,6 +267,8 @@ type SQLChainProfile struct {
 	Owner proto.AccountAddress
 	// first miner in the list is leader
 	Miners []*MinerInfo
+	// standby miners replicate the sqlchain without serving queries until promoted
+	Standby []*MinerInfo
 
 	Users []*SQLChainUser
 
@@ -263,6 +285,8 @@ type ProviderProfile struct {
 	LoadAvgPerCPU float64 // max loadAvg15 per CPU
 	TargetUser    []proto.AccountAddress
 	NodeID        proto.NodeID
+	Region        string // region label of the miner
+	Zone          string // zone label of the miner in the region
 }
 
 // Account stores account metadata.
@@ -40,6 +40,22 @@ func TestUserPermissionFromRole(t *testing.T) {
 		So(r, ShouldEqual, Write|Super)
 		So(r.String(), ShouldEqual, trickyStr)
 	})
+	Convey("test parse role name", t, func() {
+		for name, role := range map[string]UserPermissionRole{
+			"readonly":  ReadOnly,
+			"Read-Only": ReadOnly,
+			"readwrite": ReadWrite,
+			"writeonly": WriteOnly,
+			" ADMIN ":   Admin,
+			"void":      Void,
+		} {
+			r, ok := ParseUserPermissionRole(name)
+			So(ok, ShouldBeTrue)
+			So(r, ShouldEqual, role)
+		}
+		_, ok := ParseUserPermissionRole("super")
+		So(ok, ShouldBeFalse)
+	})
 }
 
 func TestUserPermission(t *testing.T) {
@@ -66,7 +66,7 @@ func (sh *SignedAckHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedAckHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedAckHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.AckHeader, signer)
 }
 
@@ -76,7 +76,7 @@ func (a *Ack) Verify() error {
 }
 
 // Sign the request.
-func (a *Ack) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (a *Ack) Sign(signer asymmetric.Signer) (err error) {
 	// sign
 	return a.Header.Sign(signer)
 }
@@ -23,6 +23,7 @@ type Header struct {
 	ParentHash  hash.Hash
 	MerkleRoot  hash.Hash
 	Timestamp   time.Time
+	HeaderExt
 }
 
 // SignedHeader is block header along with its producer signature.
@@ -32,7 +33,7 @@ type SignedHeader struct {
 }
 
 // Sign calls DefaultHashSignVerifierImpl to calculate header hash and sign it with signer.
-func (s *SignedHeader) Sign(signer *ca.PrivateKey) error {
+func (s *SignedHeader) Sign(signer ca.Signer) error {
 	return s.HSV.Sign(&s.Header, signer)
 }
 
@@ -72,6 +73,9 @@ type Block struct {
 // TODO(leventeliu): too tricky. Consider simply adding next id to each block header.
 func (b *Block) CalcNextID() (id uint64, ok bool) {
 	for _, v := range b.QueryTxs {
+		if v == nil || v.Request == nil || v.Response == nil {
+			continue
+		}
 		if v.Request.Header.QueryType == WriteQuery {
 			var nid = v.Response.LogOffset + uint64(len(v.Request.Payload.Queries))
 			if nid > id {
@@ -97,6 +101,9 @@ func (b *Block) PackAsGenesis() (err error) {
 
 // Verify verifies the merkle root and header signature of the block.
 func (b *Block) Verify() (err error) {
+	if err = b.checkNilTxs(); err != nil {
+		return
+	}
 	// Verify merkle root
 	if merkleRoot := b.computeMerkleRoot(); !merkleRoot.IsEqual(&b.SignedHeader.MerkleRoot) {
 		return ErrMerkleRootVerification
@@ -156,6 +163,27 @@ func (b *Block) Signee() *ca.PublicKey {
 	return b.SignedHeader.HSV.Signee
 }
 
+// checkNilTxs checks that the block has no nil transactions, which may come from a malformed
+// encoding of the block.
+func (b *Block) checkNilTxs() error {
+	for i, v := range b.FailedReqs {
+		if v == nil {
+			return errors.Wrapf(ErrNilBlockTx, "failed request %d", i)
+		}
+	}
+	for i, v := range b.QueryTxs {
+		if v == nil || v.Request == nil || v.Response == nil {
+			return errors.Wrapf(ErrNilBlockTx, "query tx %d", i)
+		}
+	}
+	for i, v := range b.Acks {
+		if v == nil {
+			return errors.Wrapf(ErrNilBlockTx, "ack %d", i)
+		}
+	}
+	return nil
+}
+
 func (b *Block) computeMerkleRoot() hash.Hash {
 	var hs = make([]*hash.Hash, 0, len(b.FailedReqs)+len(b.QueryTxs)+len(b.Acks))
 	for i := range b.FailedReqs {
@@ -0,0 +1,73 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/utils"
+)
+
+func FuzzDecodeBlock(f *testing.F) {
+	priv, _, err := asymmetric.GenSecp256k1KeyPair()
+	if err != nil {
+		f.Fatal(err)
+	}
+	var (
+		req = &Request{
+			Header: SignedRequestHeader{RequestHeader: RequestHeader{
+				QueryType:  WriteQuery,
+				NodeID:     testNodeID,
+				DatabaseID: "db",
+				Timestamp:  time.Unix(1546300800, 0).UTC(),
+			}},
+			Payload: RequestPayload{Queries: []Query{{
+				Pattern: "INSERT INTO t VALUES (?)",
+				Args:    []NamedArg{{Value: int64(1)}},
+			}}},
+		}
+		resp = &SignedResponseHeader{ResponseHeader: ResponseHeader{
+			Request:   req.Header.RequestHeader,
+			NodeID:    testNodeID,
+			Timestamp: time.Unix(1546300801, 0).UTC(),
+			RowCount:  1,
+		}}
+		blocks = []*Block{
+			{},
+			{
+				SignedHeader: SignedHeader{Header: Header{
+					Version:   0x01000000,
+					Producer:  testNodeID,
+					Timestamp: time.Unix(1546300802, 0).UTC(),
+				}},
+				QueryTxs: []*QueryAsTx{{Request: req, Response: resp}},
+			},
+		}
+	)
+	if err = req.Sign(priv); err != nil {
+		f.Fatal(err)
+	}
+	if err = blocks[1].SignedHeader.SetExt(CurrentSerialVersion, int32(1)); err != nil {
+		f.Fatal(err)
+	}
+	if err = blocks[1].PackAndSignBlock(priv); err != nil {
+		f.Fatal(err)
+	}
+	for _, b := range blocks {
+		buf, err := utils.EncodeMsgPack(b)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var b *Block
+		if err := utils.DecodeMsgPack(data, &b); err != nil || b == nil {
+			return
+		}
+		// hashing and verifying any decoded block must not panic
+		_, _ = b.MarshalHash()
+		_ = b.Verify()
+		_, _ = b.CalcNextID()
+	})
+}
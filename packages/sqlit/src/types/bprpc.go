@@ -3,6 +3,8 @@
 package types
 
 import (
+	"time"
+
 	"sqlit/src/blockproducer/interfaces"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/proto"
@@ -83,6 +85,9 @@ type AddTxReq struct {
 
 	TTL uint32 // defines the broadcast TTL on BP network.
 	Tx  interfaces.Transaction
+	// Replaces is the hash of a pending transaction with the same account nonce, which will be
+	// dropped from the transaction pool in favor of Tx.
+	Replaces *hash.Hash
 }
 
 // AddTxResp defines a response of the AddTx RPC method.
@@ -171,3 +176,94 @@ type QueryAccountSQLChainProfilesResp struct {
 	Addr     proto.AccountAddress
 	Profiles []*SQLChainProfile
 }
+
+// PendingTx defines a transaction which is not yet confirmed by the block producers.
+type PendingTx struct {
+	Tx    interfaces.Transaction
+	State interfaces.TransactionState
+}
+
+// QueryAccountPendingTxsReq defines a request of the QueryAccountPendingTxs RPC method.
+type QueryAccountPendingTxsReq struct {
+	proto.Envelope
+	Addr proto.AccountAddress
+}
+
+// QueryAccountPendingTxsResp defines a response of the QueryAccountPendingTxs RPC method.
+type QueryAccountPendingTxsResp struct {
+	proto.Envelope
+	Addr proto.AccountAddress
+	// Txs are the pending transactions of the account sorted by nonce
+	Txs []*PendingTx
+}
+
+// AccountTx defines a transaction in the history of an account.
+type AccountTx struct {
+	Hash      hash.Hash
+	Type      interfaces.TransactionType
+	Nonce     interfaces.AccountNonce
+	Timestamp time.Time
+	// Height is the height of the block packing the transaction, it's not set if the transaction
+	// is pending
+	Height uint32
+	State  interfaces.TransactionState
+}
+
+// QueryAccountHistoryReq defines a request of the QueryAccountHistory RPC method.
+type QueryAccountHistoryReq struct {
+	proto.Envelope
+	Addr proto.AccountAddress
+	// Page is the page of the packed transactions starting from 1, and Size is the max count of
+	// them on a page
+	Page uint32
+	Size uint32
+}
+
+// QueryAccountHistoryResp defines a response of the QueryAccountHistory RPC method.
+type QueryAccountHistoryResp struct {
+	proto.Envelope
+	Addr      proto.AccountAddress
+	NextNonce interfaces.AccountNonce
+	// Pending are the transactions not yet packed into the head branch sorted by nonce, they are
+	// only returned on the first page
+	Pending []*AccountTx
+	// Txs are the packed transactions on the page in the descending order of heights, Total is
+	// the count of all of them
+	Txs   []*AccountTx
+	Page  uint32
+	Size  uint32
+	Total uint32
+}
+
+// PreviewUpdatePermissionReq defines a request of the PreviewUpdatePermission RPC method.
+type PreviewUpdatePermissionReq struct {
+	proto.Envelope
+	// Sender is the account which would sign the transaction
+	Sender proto.AccountAddress
+	Header UpdatePermissionHeader
+}
+
+// PreviewUpdatePermissionResp defines a response of the PreviewUpdatePermission RPC method.
+type PreviewUpdatePermissionResp struct {
+	proto.Envelope
+	// Users are the resulting users of the database if the transaction is applied on the head
+	Users   []*SQLChainUser
+	Changes []*PermissionChange
+	// Warnings are the accepted changes which are likely mistakes
+	Warnings []string
+}
+
+// QueryEffectivePermissionReq defines a request of the QueryEffectivePermission RPC method.
+type QueryEffectivePermissionReq struct {
+	proto.Envelope
+	DBID proto.DatabaseID
+	Addr proto.AccountAddress
+	// Patterns are the query patterns to evaluate
+	Patterns []string
+}
+
+// QueryEffectivePermissionResp defines a response of the QueryEffectivePermission RPC method.
+type QueryEffectivePermissionResp struct {
+	proto.Envelope
+	Permission *EffectivePermission
+}
@@ -0,0 +1,525 @@
+package types
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/utils"
+)
+
+const (
+	// ResultEncodingColumnar defines the columnar encoding of the response rows, which packs the
+	// values column-wise with the run-length or dictionary encoding.
+	ResultEncodingColumnar = "columnar"
+
+	// MaxColumnarRows defines the max rows of a columnar encoded response.
+	MaxColumnarRows = 1 << 24
+)
+
+// ColumnType defines the type of the values of a column batch vector.
+type ColumnType uint8
+
+const (
+	// ColumnNull defines a column of null values only.
+	ColumnNull ColumnType = iota
+	// ColumnInt64 defines a column of int64 values.
+	ColumnInt64
+	// ColumnFloat64 defines a column of float64 values.
+	ColumnFloat64
+	// ColumnString defines a column of string values.
+	ColumnString
+	// ColumnBinary defines a column of []byte values.
+	ColumnBinary
+	// ColumnAny defines a column of mixed or other typed values, such as time.Time.
+	ColumnAny
+)
+
+// String implements fmt.Stringer.
+func (t ColumnType) String() string {
+	switch t {
+	case ColumnNull:
+		return "null"
+	case ColumnInt64:
+		return "int64"
+	case ColumnFloat64:
+		return "float64"
+	case ColumnString:
+		return "string"
+	case ColumnBinary:
+		return "binary"
+	case ColumnAny:
+		return "any"
+	default:
+		return "unknown"
+	}
+}
+
+// columnEncoding defines the encoding of the valid values of a column on the wire.
+type columnEncoding uint8
+
+const (
+	plainEncoding columnEncoding = iota
+	runLengthEncoding
+	dictionaryEncoding
+)
+
+// ColumnVector defines the values of a column in the layout of an Arrow array: a validity bitmap
+// and a dense array of the values typed by the column type, in which the null slots hold the zero
+// values.
+type ColumnVector struct {
+	Type ColumnType
+	// Valid is the validity bitmap in the least-significant bit order, the bit of a row is set if
+	// the value is not null. It's nil if all the values are valid.
+	Valid []byte
+
+	Int64s   []int64
+	Float64s []float64
+	Strings  []string
+	Binaries [][]byte
+	Values   []interface{}
+}
+
+// IsNull returns whether the value of row i is null.
+func (v *ColumnVector) IsNull(i int) bool {
+	if v.Type == ColumnNull {
+		return true
+	}
+	return v.Valid != nil && v.Valid[i>>3]&(1<<uint(i&7)) == 0
+}
+
+// Value returns the value of row i, or nil if it's null.
+func (v *ColumnVector) Value(i int) interface{} {
+	if v.IsNull(i) {
+		return nil
+	}
+	switch v.Type {
+	case ColumnInt64:
+		return v.Int64s[i]
+	case ColumnFloat64:
+		return v.Float64s[i]
+	case ColumnString:
+		return v.Strings[i]
+	case ColumnBinary:
+		return v.Binaries[i]
+	default:
+		return v.Values[i]
+	}
+}
+
+// ColumnBatch defines a column-oriented result set in the layout of an Arrow record batch, with a
+// vector per column of the schema. It's not an Arrow IPC message, the vectors are restored from
+// the columnar encoding of the response rows.
+type ColumnBatch struct {
+	Columns   []string
+	DeclTypes []string
+	NumRows   int
+	Vectors   []*ColumnVector
+}
+
+// NewColumnBatch returns the column batch of the payload rows.
+func NewColumnBatch(p *ResponsePayload) (b *ColumnBatch) {
+	b = &ColumnBatch{
+		Columns:   p.Columns,
+		DeclTypes: p.DeclTypes,
+		NumRows:   len(p.Rows),
+		Vectors:   make([]*ColumnVector, len(p.Columns)),
+	}
+	for i := range b.Vectors {
+		b.Vectors[i] = newColumnVector(p.Rows, i)
+	}
+	return
+}
+
+// Rows returns the payload rows of the batch.
+func (b *ColumnBatch) Rows() (rows []ResponseRow) {
+	if b.NumRows == 0 {
+		return
+	}
+	rows = make([]ResponseRow, b.NumRows)
+	for i := range rows {
+		rows[i].Values = make([]interface{}, len(b.Vectors))
+		for j, v := range b.Vectors {
+			rows[i].Values[j] = v.Value(i)
+		}
+	}
+	return
+}
+
+// columnType returns the type of value v, which is never ColumnNull for a non-nil value.
+func columnType(v interface{}) ColumnType {
+	switch v.(type) {
+	case int64:
+		return ColumnInt64
+	case float64:
+		return ColumnFloat64
+	case string:
+		return ColumnString
+	case []byte:
+		return ColumnBinary
+	default:
+		return ColumnAny
+	}
+}
+
+func newColumnVector(rows []ResponseRow, col int) (v *ColumnVector) {
+	v = &ColumnVector{Type: ColumnNull}
+	var nulls int
+	for _, r := range rows {
+		var x = r.Values[col]
+		if x == nil {
+			nulls++
+			continue
+		}
+		if t := columnType(x); v.Type == ColumnNull {
+			v.Type = t
+		} else if v.Type != t {
+			v.Type = ColumnAny
+		}
+	}
+	if v.Type == ColumnNull {
+		return
+	}
+	if nulls > 0 {
+		v.Valid = make([]byte, (len(rows)+7)/8)
+	}
+	switch v.Type {
+	case ColumnInt64:
+		v.Int64s = make([]int64, len(rows))
+	case ColumnFloat64:
+		v.Float64s = make([]float64, len(rows))
+	case ColumnString:
+		v.Strings = make([]string, len(rows))
+	case ColumnBinary:
+		v.Binaries = make([][]byte, len(rows))
+	default:
+		v.Values = make([]interface{}, len(rows))
+	}
+	for i, r := range rows {
+		var x = r.Values[col]
+		if x == nil {
+			continue
+		}
+		if v.Valid != nil {
+			v.Valid[i>>3] |= 1 << uint(i&7)
+		}
+		switch v.Type {
+		case ColumnInt64:
+			v.Int64s[i] = x.(int64)
+		case ColumnFloat64:
+			v.Float64s[i] = x.(float64)
+		case ColumnString:
+			v.Strings[i] = x.(string)
+		case ColumnBinary:
+			v.Binaries[i] = x.([]byte)
+		default:
+			v.Values[i] = x
+		}
+	}
+	return
+}
+
+// encodedBatch defines the columnar encoding of the response rows on the wire.
+type encodedBatch struct {
+	NumRows int              `json:"n"`
+	Columns []*encodedColumn `json:"c"`
+}
+
+// encodedColumn holds the valid values of a column only, which are encoded as:
+//
+//   - plain: the values in order;
+//   - run-length: the values of the runs and the lengths in Runs;
+//   - dictionary: the distinct values and the index of each value in Index.
+type encodedColumn struct {
+	Type     ColumnType     `json:"t"`
+	Encoding columnEncoding `json:"e"`
+	Valid    []byte         `json:"v,omitempty"`
+	Runs     []uint32       `json:"r,omitempty"`
+	Index    []uint32       `json:"x,omitempty"`
+
+	Int64s   []int64       `json:"i,omitempty"`
+	Float64s []float64     `json:"f,omitempty"`
+	Strings  []string      `json:"s,omitempty"`
+	Binaries [][]byte      `json:"b,omitempty"`
+	Values   []interface{} `json:"a,omitempty"`
+}
+
+// validValues returns the values of the valid rows.
+func validValues[T any](vs []T, valid []byte) []T {
+	if valid == nil {
+		return vs
+	}
+	var out = make([]T, 0, len(vs))
+	for i, v := range vs {
+		if valid[i>>3]&(1<<uint(i&7)) != 0 {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// encodeValues chooses the smallest of the plain, run-length and dictionary encodings of vs by
+// the number of the values to send, the values are compared by their keys.
+func encodeValues[T any, K comparable](vs []T, key func(T) K, dict bool) (
+	enc columnEncoding, out []T, runs, index []uint32,
+) {
+	var n = len(vs)
+	for i := 0; i < n; i++ {
+		if i == 0 || key(vs[i]) != key(vs[i-1]) {
+			out = append(out, vs[i])
+			runs = append(runs, 1)
+		} else {
+			runs[len(runs)-1]++
+		}
+	}
+	if len(runs)*2 <= n {
+		return runLengthEncoding, out, runs, nil
+	}
+	if dict {
+		var (
+			seen = make(map[K]uint32)
+			keys []T
+		)
+		index = make([]uint32, n)
+		for i, v := range vs {
+			id, ok := seen[key(v)]
+			if !ok {
+				if (len(keys)+1)*2 > n {
+					return plainEncoding, vs, nil, nil
+				}
+				id = uint32(len(keys))
+				seen[key(v)] = id
+				keys = append(keys, v)
+			}
+			index[i] = id
+		}
+		return dictionaryEncoding, keys, nil, index
+	}
+	return plainEncoding, vs, nil, nil
+}
+
+// decodeValues restores n values encoded by encodeValues.
+func decodeValues[T any](enc columnEncoding, vs []T, runs, index []uint32, n int) (
+	out []T, err error,
+) {
+	switch enc {
+	case plainEncoding:
+		if len(vs) != n {
+			return nil, errors.Errorf("%d plain values of %d rows", len(vs), n)
+		}
+		return vs, nil
+	case runLengthEncoding:
+		if len(runs) != len(vs) {
+			return nil, errors.Errorf("%d runs of %d values", len(runs), len(vs))
+		}
+		out = make([]T, 0, n)
+		for i, r := range runs {
+			if int(r) > n-len(out) {
+				return nil, errors.Errorf("runs exceed %d rows", n)
+			}
+			for j := uint32(0); j < r; j++ {
+				out = append(out, vs[i])
+			}
+		}
+	case dictionaryEncoding:
+		if len(index) != n {
+			return nil, errors.Errorf("%d indexes of %d rows", len(index), n)
+		}
+		out = make([]T, n)
+		for i, id := range index {
+			if int(id) >= len(vs) {
+				return nil, errors.Errorf("index %d out of %d dictionary values", id, len(vs))
+			}
+			out[i] = vs[id]
+		}
+	default:
+		return nil, errors.Errorf("unknown column encoding: %d", enc)
+	}
+	if len(out) != n {
+		return nil, errors.Errorf("%d run values of %d rows", len(out), n)
+	}
+	return
+}
+
+// expandValues places the valid values vs into a dense array of n rows.
+func expandValues[T any](vs []T, valid []byte, n int) []T {
+	if valid == nil {
+		return vs
+	}
+	var (
+		out = make([]T, n)
+		j   int
+	)
+	for i := range out {
+		if valid[i>>3]&(1<<uint(i&7)) != 0 {
+			out[i] = vs[j]
+			j++
+		}
+	}
+	return out
+}
+
+func identity[T comparable](v T) T { return v }
+
+func bytesKey(v []byte) string { return string(v) }
+
+// floatKey compares the floats by their bits, so that 0 and -0 are kept apart.
+func floatKey(v float64) uint64 { return math.Float64bits(v) }
+
+func encodeColumn(v *ColumnVector) (c *encodedColumn) {
+	c = &encodedColumn{Type: v.Type, Valid: v.Valid}
+	switch v.Type {
+	case ColumnInt64:
+		c.Encoding, c.Int64s, c.Runs, c.Index = encodeValues(
+			validValues(v.Int64s, v.Valid), identity[int64], false)
+	case ColumnFloat64:
+		c.Encoding, c.Float64s, c.Runs, c.Index = encodeValues(
+			validValues(v.Float64s, v.Valid), floatKey, false)
+	case ColumnString:
+		c.Encoding, c.Strings, c.Runs, c.Index = encodeValues(
+			validValues(v.Strings, v.Valid), identity[string], true)
+	case ColumnBinary:
+		c.Encoding, c.Binaries, c.Runs, c.Index = encodeValues(
+			validValues(v.Binaries, v.Valid), bytesKey, true)
+	case ColumnAny:
+		// the mixed values are sent as is with the nulls
+		c.Values, c.Valid = v.Values, nil
+	}
+	return
+}
+
+func decodeColumn(c *encodedColumn, n int) (v *ColumnVector, err error) {
+	v = &ColumnVector{Type: c.Type, Valid: c.Valid}
+	if c.Type == ColumnNull {
+		v.Valid = nil
+		return
+	}
+	var valid = n
+	if c.Valid != nil {
+		if len(c.Valid) != (n+7)/8 {
+			return nil, errors.Errorf("%d bytes validity bitmap of %d rows", len(c.Valid), n)
+		}
+		valid = 0
+		for i := 0; i < n; i++ {
+			if c.Valid[i>>3]&(1<<uint(i&7)) != 0 {
+				valid++
+			}
+		}
+	}
+	switch c.Type {
+	case ColumnInt64:
+		var vs []int64
+		if vs, err = decodeValues(c.Encoding, c.Int64s, c.Runs, c.Index, valid); err == nil {
+			v.Int64s = expandValues(vs, c.Valid, n)
+		}
+	case ColumnFloat64:
+		var vs []float64
+		if vs, err = decodeValues(c.Encoding, c.Float64s, c.Runs, c.Index, valid); err == nil {
+			v.Float64s = expandValues(vs, c.Valid, n)
+		}
+	case ColumnString:
+		var vs []string
+		if vs, err = decodeValues(c.Encoding, c.Strings, c.Runs, c.Index, valid); err == nil {
+			v.Strings = expandValues(vs, c.Valid, n)
+		}
+	case ColumnBinary:
+		var vs [][]byte
+		if vs, err = decodeValues(c.Encoding, c.Binaries, c.Runs, c.Index, valid); err == nil {
+			v.Binaries = expandValues(vs, c.Valid, n)
+		}
+	case ColumnAny:
+		if len(c.Values) != n {
+			err = errors.Errorf("%d values of %d rows", len(c.Values), n)
+		}
+		v.Values, v.Valid = c.Values, nil
+		for i, x := range c.Values {
+			if x == nil {
+				if v.Valid == nil {
+					v.Valid = make([]byte, (n+7)/8)
+					for j := 0; j < i; j++ {
+						v.Valid[j>>3] |= 1 << uint(j&7)
+					}
+				}
+			} else if v.Valid != nil {
+				v.Valid[i>>3] |= 1 << uint(i&7)
+			}
+		}
+	default:
+		err = errors.Errorf("unknown column type: %d", c.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// EncodeColumnar replaces the rows of response r with the columnar encoded ones if the request
+// accepts the encoding. Like the compression, it must be called after the response is hashed and
+// signed, the rows are restored by the request node for verification.
+func EncodeColumnar(r *Response) (err error) {
+	if r.Header.Request.ResultEncoding() != ResultEncodingColumnar ||
+		len(r.Payload.Rows) == 0 || len(r.Payload.Rows) > MaxColumnarRows {
+		return
+	}
+	for _, row := range r.Payload.Rows {
+		if len(row.Values) != len(r.Payload.Columns) {
+			// leave the malformed rows to the verification of the request node
+			return
+		}
+	}
+	var (
+		b   = NewColumnBatch(&r.Payload)
+		enc = &encodedBatch{NumRows: b.NumRows, Columns: make([]*encodedColumn, len(b.Vectors))}
+	)
+	for i, v := range b.Vectors {
+		enc.Columns[i] = encodeColumn(v)
+	}
+	buf, err := utils.EncodeMsgPack(enc)
+	if err != nil {
+		return errors.Wrap(err, "encode columnar rows failed")
+	}
+	r.Encoding = ResultEncodingColumnar
+	r.ColumnarRows = buf.Bytes()
+	r.Payload.Rows = nil
+	return
+}
+
+// DecodeColumnar restores the columnar encoded rows of response r and returns the column batch, a
+// response without columnar encoded rows is left unchanged and nil is returned.
+func DecodeColumnar(r *Response) (b *ColumnBatch, err error) {
+	if r.Encoding == "" {
+		return
+	}
+	if r.Encoding != ResultEncodingColumnar {
+		return nil, errors.Errorf("unsupported result encoding: %s", r.Encoding)
+	}
+	var enc encodedBatch
+	if err = utils.DecodeMsgPack(r.ColumnarRows, &enc); err != nil {
+		return nil, errors.Wrap(err, "decode columnar rows failed")
+	}
+	if enc.NumRows <= 0 || enc.NumRows > MaxColumnarRows {
+		return nil, errors.Errorf("invalid columnar rows count: %d", enc.NumRows)
+	}
+	if len(enc.Columns) != len(r.Payload.Columns) {
+		return nil, errors.Errorf(
+			"%d columnar vectors of %d columns", len(enc.Columns), len(r.Payload.Columns))
+	}
+	b = &ColumnBatch{
+		Columns:   r.Payload.Columns,
+		DeclTypes: r.Payload.DeclTypes,
+		NumRows:   enc.NumRows,
+		Vectors:   make([]*ColumnVector, len(enc.Columns)),
+	}
+	for i, c := range enc.Columns {
+		if c == nil {
+			return nil, errors.Errorf("missing columnar vector #%d", i)
+		}
+		if b.Vectors[i], err = decodeColumn(c, enc.NumRows); err != nil {
+			return nil, errors.Wrapf(err, "decode columnar vector #%d failed", i)
+		}
+	}
+	r.Payload.Rows = b.Rows()
+	r.Encoding = ""
+	r.ColumnarRows = nil
+	return
+}
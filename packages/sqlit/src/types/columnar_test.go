@@ -0,0 +1,178 @@
+package types
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+func newColumnarResponse(priv *asymmetric.PrivateKey, rows []ResponseRow) (r *Response, err error) {
+	addr, err := crypto.PubKeyHash(priv.PubKey())
+	if err != nil {
+		return
+	}
+	var nodeID = proto.NodeID("00000000000000000000000000000000000000000000000000000000000000aa")
+	r = &Response{
+		Header: SignedResponseHeader{
+			ResponseHeader: ResponseHeader{
+				Request: RequestHeader{
+					QueryType:  ReadQuery,
+					NodeID:     nodeID,
+					DatabaseID: proto.DatabaseID("db"),
+					Timestamp:  time.Now().UTC(),
+				},
+				NodeID:          nodeID,
+				Timestamp:       time.Now().UTC(),
+				ResponseAccount: addr,
+			},
+		},
+		Payload: ResponsePayload{
+			Columns:   []string{"id", "status", "payload", "score", "note", "empty"},
+			DeclTypes: []string{"INT", "TEXT", "BLOB", "REAL", "", ""},
+			Rows:      rows,
+		},
+	}
+	if err = r.Header.Request.SetResultEncoding(ResultEncodingColumnar); err != nil {
+		return
+	}
+	if err = r.BuildHash(); err != nil {
+		return
+	}
+	err = r.Sign(priv)
+	return
+}
+
+func newColumnarRows(n int) (rows []ResponseRow) {
+	for i := 0; i < n; i++ {
+		var (
+			payload interface{} = []byte(fmt.Sprintf("payload-%d", i%3))
+			score   interface{} = float64(i / 10)
+			note    interface{} = int64(i)
+		)
+		if i%5 == 0 {
+			payload = nil
+		}
+		if i == 1 {
+			score = math.Copysign(0, -1)
+		}
+		if i%2 == 0 {
+			note = fmt.Sprintf("note-%d", i)
+		}
+		rows = append(rows, ResponseRow{Values: []interface{}{
+			int64(i), "status-pending-confirmation", payload, score, note, nil,
+		}})
+	}
+	return
+}
+
+func TestColumnar(t *testing.T) {
+	Convey("Given a signed response accepting the columnar encoding", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		var rows = newColumnarRows(100)
+		resp, err := newColumnarResponse(priv, rows)
+		So(err, ShouldBeNil)
+
+		Convey("The column batch should be typed by the values", func() {
+			var b = NewColumnBatch(&resp.Payload)
+			So(b.NumRows, ShouldEqual, 100)
+			So(b.Vectors, ShouldHaveLength, 6)
+			So(b.Vectors[0].Type, ShouldEqual, ColumnInt64)
+			So(b.Vectors[0].Valid, ShouldBeNil)
+			So(b.Vectors[0].Int64s[42], ShouldEqual, 42)
+			So(b.Vectors[1].Type, ShouldEqual, ColumnString)
+			So(b.Vectors[2].Type, ShouldEqual, ColumnBinary)
+			So(b.Vectors[2].IsNull(5), ShouldBeTrue)
+			So(b.Vectors[2].IsNull(6), ShouldBeFalse)
+			So(b.Vectors[2].Value(6), ShouldResemble, []byte("payload-0"))
+			So(b.Vectors[3].Type, ShouldEqual, ColumnFloat64)
+			So(b.Vectors[4].Type, ShouldEqual, ColumnAny)
+			So(b.Vectors[5].Type, ShouldEqual, ColumnNull)
+			So(b.Vectors[5].IsNull(0), ShouldBeTrue)
+			So(b.Rows(), ShouldResemble, rows)
+		})
+		Convey("The columns should be encoded by the repetition of the values", func() {
+			var b = NewColumnBatch(&resp.Payload)
+			So(encodeColumn(b.Vectors[0]).Encoding, ShouldEqual, plainEncoding)
+			So(encodeColumn(b.Vectors[1]).Encoding, ShouldEqual, runLengthEncoding)
+			So(encodeColumn(b.Vectors[1]).Strings, ShouldHaveLength, 1)
+			So(encodeColumn(b.Vectors[2]).Encoding, ShouldEqual, dictionaryEncoding)
+			So(encodeColumn(b.Vectors[2]).Binaries, ShouldHaveLength, 3)
+			So(encodeColumn(b.Vectors[3]).Encoding, ShouldEqual, runLengthEncoding)
+		})
+		Convey("The rows should not be encoded if the request doesn't accept it", func() {
+			So(resp.Header.Request.SetResultEncoding(""), ShouldBeNil)
+			So(EncodeColumnar(resp), ShouldBeNil)
+			So(resp.Encoding, ShouldBeEmpty)
+			So(resp.Payload.Rows, ShouldResemble, rows)
+		})
+		Convey("The encoded rows should be restored and verified after the rpc encoding", func() {
+			raw, err := utils.EncodeMsgPack(resp.Payload.Rows)
+			So(err, ShouldBeNil)
+			So(EncodeColumnar(resp), ShouldBeNil)
+			So(resp.Encoding, ShouldEqual, ResultEncodingColumnar)
+			So(resp.Payload.Rows, ShouldBeNil)
+			So(len(resp.ColumnarRows), ShouldBeLessThan, raw.Len())
+
+			buf, err := utils.EncodeMsgPack(resp)
+			So(err, ShouldBeNil)
+			var decoded Response
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			b, err := DecodeColumnar(&decoded)
+			So(err, ShouldBeNil)
+			So(b.NumRows, ShouldEqual, 100)
+			So(b.Vectors[3].Float64s[1], ShouldEqual, 0)
+			So(math.Signbit(b.Vectors[3].Float64s[1]), ShouldBeTrue)
+			So(decoded.Encoding, ShouldBeEmpty)
+			So(decoded.ColumnarRows, ShouldBeNil)
+			So(decoded.Payload.Rows, ShouldHaveLength, len(rows))
+			So(decoded.Verify(), ShouldBeNil)
+		})
+		Convey("The encoded rows should be compressed as a whole", func() {
+			So(resp.Header.Request.SetResultCompression(ResultCompressionZstd, 0), ShouldBeNil)
+			So(resp.Header.Request.ResultEncoding(), ShouldEqual, ResultEncodingColumnar)
+			So(resp.BuildHash(), ShouldBeNil)
+			So(resp.Sign(priv), ShouldBeNil)
+			So(EncodeColumnar(resp), ShouldBeNil)
+
+			c, err := NewResultCompressor(nil)
+			So(err, ShouldBeNil)
+			d, err := NewResultDecompressor(nil)
+			So(err, ShouldBeNil)
+			raw, compressed, err := c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			So(compressed, ShouldBeLessThan, raw)
+			So(resp.ColumnarRows, ShouldBeNil)
+			So(resp.Encoding, ShouldEqual, ResultEncodingColumnar)
+
+			So(d.Decompress(resp), ShouldBeNil)
+			So(resp.ColumnarRows, ShouldHaveLength, raw)
+			_, err = DecodeColumnar(resp)
+			So(err, ShouldBeNil)
+			So(resp.Verify(), ShouldBeNil)
+		})
+		Convey("The malformed encoded rows should fail to decode", func() {
+			So(EncodeColumnar(resp), ShouldBeNil)
+			var enc encodedBatch
+			So(utils.DecodeMsgPack(resp.ColumnarRows, &enc), ShouldBeNil)
+			enc.Columns[1].Runs[0] = math.MaxUint32
+			buf, err := utils.EncodeMsgPack(&enc)
+			So(err, ShouldBeNil)
+			resp.ColumnarRows = buf.Bytes()
+			_, err = DecodeColumnar(resp)
+			So(err, ShouldNotBeNil)
+
+			resp.Encoding = "arrow"
+			_, err = DecodeColumnar(resp)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
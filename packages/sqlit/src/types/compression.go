@@ -0,0 +1,178 @@
+package types
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"sqlit/src/utils"
+)
+
+const (
+	// ResultCompressionZstd defines the zstd compression of the response rows.
+	ResultCompressionZstd = "zstd"
+
+	// MaxDecompressedRowsSize defines the max size of the decompressed rows of a response.
+	MaxDecompressedRowsSize = 1 << 30
+)
+
+// encodeCompression encodes the accepted compression of the response rows with the id of the
+// dictionary held by the client, e.g. "zstd" or "zstd:12345".
+func encodeCompression(algo string, dictID uint32) string {
+	if algo == "" || dictID == 0 {
+		return algo
+	}
+	return algo + ":" + strconv.FormatUint(uint64(dictID), 10)
+}
+
+func decodeCompression(s string) (algo string, dictID uint32) {
+	var i = strings.IndexByte(s, ':')
+	if i < 0 {
+		return s, 0
+	}
+	if id, err := strconv.ParseUint(s[i+1:], 10, 32); err == nil {
+		dictID = uint32(id)
+	}
+	return s[:i], dictID
+}
+
+// DictionaryID returns the id of the zstd dictionary dict, the raw content dictionaries without
+// the header have no id.
+func DictionaryID(dict []byte) (id uint32, err error) {
+	d, err := zstd.InspectDictionary(dict)
+	if err != nil {
+		err = errors.Wrap(err, "invalid zstd dictionary")
+		return
+	}
+	return d.ID(), nil
+}
+
+// ResultCompressor compresses the rows of the responses on the miners, with the dictionary if
+// the request node holds the same one.
+type ResultCompressor struct {
+	plain  *zstd.Encoder
+	dict   *zstd.Encoder
+	dictID uint32
+}
+
+// NewResultCompressor returns a new result compressor with the optional zstd dictionary dict.
+func NewResultCompressor(dict []byte) (c *ResultCompressor, err error) {
+	c = &ResultCompressor{}
+	if c.plain, err = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1)); err != nil {
+		return
+	}
+	if len(dict) > 0 {
+		if c.dictID, err = DictionaryID(dict); err != nil {
+			return
+		}
+		if c.dict, err = zstd.NewWriter(nil,
+			zstd.WithEncoderConcurrency(1), zstd.WithEncoderDict(dict),
+		); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Compress replaces the rows of response r, or the columnar encoded ones, with the compressed
+// ones if the request accepts the compression, and the encoded rows are at least minSize bytes
+// and shrink. It must be called after the response is hashed and signed, the rows are restored by
+// the request node for verification.
+// The sizes of the encoded rows before and after compression are returned, which are both 0 if
+// the rows are not compressed.
+func (c *ResultCompressor) Compress(r *Response, minSize int) (raw, compressed int, err error) {
+	var algo, dictID = r.Header.Request.ResultCompression()
+	if algo != ResultCompressionZstd {
+		return
+	}
+	var in []byte
+	if r.Encoding != "" {
+		// the columnar encoded rows are compressed as is
+		in = r.ColumnarRows
+	} else if len(r.Payload.Rows) > 0 {
+		buf, err := utils.EncodeMsgPack(r.Payload.Rows)
+		if err != nil {
+			return 0, 0, err
+		}
+		in = buf.Bytes()
+	}
+	if len(in) == 0 || len(in) < minSize {
+		return
+	}
+	var enc = c.plain
+	if c.dict != nil && dictID == c.dictID {
+		enc = c.dict
+	}
+	var out = enc.EncodeAll(in, nil)
+	if len(out) >= len(in) {
+		return
+	}
+	r.Compression = encodeCompression(algo, dictID)
+	r.CompressedRows = out
+	if r.Encoding != "" {
+		r.ColumnarRows = nil
+	} else {
+		r.Payload.Rows = nil
+	}
+	return len(in), len(out), nil
+}
+
+// ResultDecompressor decompresses the rows of the responses on the request node.
+type ResultDecompressor struct {
+	dec    *zstd.Decoder
+	dictID uint32
+}
+
+// NewResultDecompressor returns a new result decompressor with the optional zstd dictionary dict.
+func NewResultDecompressor(dict []byte) (d *ResultDecompressor, err error) {
+	var opts = []zstd.DOption{
+		zstd.WithDecoderConcurrency(1),
+		zstd.WithDecoderMaxMemory(MaxDecompressedRowsSize),
+	}
+	d = &ResultDecompressor{}
+	if len(dict) > 0 {
+		if d.dictID, err = DictionaryID(dict); err != nil {
+			return
+		}
+		opts = append(opts, zstd.WithDecoderDicts(dict))
+	}
+	if d.dec, err = zstd.NewReader(nil, opts...); err != nil {
+		return
+	}
+	return
+}
+
+// DictID returns the id of the dictionary of the decompressor, 0 if it has no dictionary.
+func (d *ResultDecompressor) DictID() uint32 {
+	return d.dictID
+}
+
+// Decompress restores the compressed rows of response r, a response without compressed rows is
+// left unchanged.
+func (d *ResultDecompressor) Decompress(r *Response) (err error) {
+	if r.Compression == "" {
+		return
+	}
+	if algo, _ := decodeCompression(r.Compression); algo != ResultCompressionZstd {
+		return errors.Errorf("unsupported result compression: %s", r.Compression)
+	}
+	var raw []byte
+	if raw, err = d.dec.DecodeAll(r.CompressedRows, nil); err != nil {
+		return errors.Wrap(err, "decompress result rows failed")
+	}
+	if r.Encoding != "" {
+		// the columnar encoded rows are decoded by DecodeColumnar
+		r.ColumnarRows = raw
+	} else {
+		var rows []ResponseRow
+		if err = utils.DecodeMsgPack(raw, &rows); err != nil {
+			return errors.Wrap(err, "decode result rows failed")
+		}
+		r.Payload.Rows = rows
+	}
+	r.Compression = ""
+	r.CompressedRows = nil
+	return
+}
@@ -0,0 +1,182 @@
+package types
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+func newCompressionResponse(priv *asymmetric.PrivateKey, rows int, dictID uint32) (r *Response, err error) {
+	addr, err := crypto.PubKeyHash(priv.PubKey())
+	if err != nil {
+		return
+	}
+	var nodeID = proto.NodeID("00000000000000000000000000000000000000000000000000000000000000aa")
+	r = &Response{
+		Header: SignedResponseHeader{
+			ResponseHeader: ResponseHeader{
+				Request: RequestHeader{
+					QueryType:  ReadQuery,
+					NodeID:     nodeID,
+					DatabaseID: proto.DatabaseID("db"),
+					Timestamp:  time.Now().UTC(),
+				},
+				NodeID:          nodeID,
+				Timestamp:       time.Now().UTC(),
+				ResponseAccount: addr,
+			},
+		},
+		Payload: ResponsePayload{
+			Columns:   []string{"id", "status", "payload"},
+			DeclTypes: []string{"INT", "TEXT", "BLOB"},
+		},
+	}
+	for i := 0; i < rows; i++ {
+		r.Payload.Rows = append(r.Payload.Rows, ResponseRow{Values: []interface{}{
+			int64(i), "status-pending-confirmation", []byte(fmt.Sprintf("payload-%d", i%7)),
+		}})
+	}
+	if err = r.Header.Request.SetResultCompression(ResultCompressionZstd, dictID); err != nil {
+		return
+	}
+	if err = r.BuildHash(); err != nil {
+		return
+	}
+	err = r.Sign(priv)
+	return
+}
+
+func TestResultCompression(t *testing.T) {
+	Convey("Given a signed response accepting the compression", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		resp, err := newCompressionResponse(priv, 100, 0)
+		So(err, ShouldBeNil)
+		var rows = resp.Payload.Rows
+
+		c, err := NewResultCompressor(nil)
+		So(err, ShouldBeNil)
+		d, err := NewResultDecompressor(nil)
+		So(err, ShouldBeNil)
+
+		Convey("The small rows should not be compressed", func() {
+			raw, compressed, err := c.Compress(resp, 1<<20)
+			So(err, ShouldBeNil)
+			So(raw, ShouldEqual, 0)
+			So(compressed, ShouldEqual, 0)
+			So(resp.Compression, ShouldBeEmpty)
+			So(resp.Payload.Rows, ShouldResemble, rows)
+		})
+		Convey("The rows should not be compressed if the request doesn't accept it", func() {
+			So(resp.Header.Request.SetResultCompression("", 0), ShouldBeNil)
+			_, compressed, err := c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			So(compressed, ShouldEqual, 0)
+			So(resp.Compression, ShouldBeEmpty)
+		})
+		Convey("The compressed rows should be restored and verified after the rpc encoding", func() {
+			raw, compressed, err := c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			So(compressed, ShouldBeLessThan, raw)
+			So(resp.Compression, ShouldEqual, ResultCompressionZstd)
+			So(resp.Payload.Rows, ShouldBeNil)
+			So(resp.CompressedRows, ShouldHaveLength, compressed)
+
+			buf, err := utils.EncodeMsgPack(resp)
+			So(err, ShouldBeNil)
+			var decoded Response
+			err = utils.DecodeMsgPack(buf.Bytes(), &decoded)
+			So(err, ShouldBeNil)
+			So(d.Decompress(&decoded), ShouldBeNil)
+			So(decoded.Compression, ShouldBeEmpty)
+			So(decoded.CompressedRows, ShouldBeNil)
+			So(decoded.Payload.Rows, ShouldHaveLength, len(rows))
+			So(decoded.Verify(), ShouldBeNil)
+		})
+		Convey("The corrupted rows should fail to decompress", func() {
+			_, _, err := c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			resp.CompressedRows = resp.CompressedRows[:len(resp.CompressedRows)/2]
+			So(d.Decompress(resp), ShouldNotBeNil)
+		})
+	})
+
+	Convey("Given the compressor and decompressor with a dictionary", t, func() {
+		var samples [][]byte
+		for i := 0; i < 64; i++ {
+			samples = append(samples, []byte(strings.Repeat(
+				fmt.Sprintf("status-pending-confirmation payload-%d ", i), 8)))
+		}
+		dict, err := zstd.BuildDict(zstd.BuildDictOptions{
+			ID:       7,
+			Contents: samples,
+			History:  []byte(strings.Repeat("status-pending-confirmation payload-", 64)),
+			Offsets:  [3]int{1, 4, 8},
+		})
+		So(err, ShouldBeNil)
+		id, err := DictionaryID(dict)
+		So(err, ShouldBeNil)
+		So(id, ShouldEqual, 7)
+
+		c, err := NewResultCompressor(dict)
+		So(err, ShouldBeNil)
+		d, err := NewResultDecompressor(dict)
+		So(err, ShouldBeNil)
+		So(d.DictID(), ShouldEqual, 7)
+		plain, err := NewResultDecompressor(nil)
+		So(err, ShouldBeNil)
+
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+
+		Convey("The dictionary should be used if the request node holds the same one", func() {
+			resp, err := newCompressionResponse(priv, 100, d.DictID())
+			So(err, ShouldBeNil)
+			_, _, err = c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			So(resp.Compression, ShouldEqual, "zstd:7")
+			So(plain.Decompress(resp), ShouldNotBeNil)
+			So(d.Decompress(resp), ShouldBeNil)
+			So(resp.Verify(), ShouldBeNil)
+		})
+		Convey("The dictionary should not be used if the request node holds another one", func() {
+			resp, err := newCompressionResponse(priv, 100, 8)
+			So(err, ShouldBeNil)
+			_, _, err = c.Compress(resp, 0)
+			So(err, ShouldBeNil)
+			So(resp.Compression, ShouldEqual, "zstd:8")
+			So(plain.Decompress(resp), ShouldBeNil)
+			So(resp.Verify(), ShouldBeNil)
+		})
+	})
+}
+
+func TestResultCompressionExt(t *testing.T) {
+	Convey("The result compression should be kept with the other extension fields", t, func() {
+		var h RequestHeader
+		So(h.SetTraceID("trace"), ShouldBeNil)
+		algo, dictID := h.ResultCompression()
+		So(algo, ShouldBeEmpty)
+		So(dictID, ShouldEqual, 0)
+
+		So(h.SetResultCompression(ResultCompressionZstd, 42), ShouldBeNil)
+		So(h.TraceID(), ShouldEqual, "trace")
+		algo, dictID = h.ResultCompression()
+		So(algo, ShouldEqual, ResultCompressionZstd)
+		So(dictID, ShouldEqual, 42)
+
+		So(h.SetResultCompression(ResultCompressionZstd, 0), ShouldBeNil)
+		algo, dictID = h.ResultCompression()
+		So(algo, ShouldEqual, ResultCompressionZstd)
+		So(dictID, ShouldEqual, 0)
+	})
+}
@@ -39,7 +39,7 @@ func NewCreateDatabase(header *CreateDatabaseHeader) *CreateDatabase {
 }
 
 // Sign implements interfaces/Transaction.Sign.
-func (cd *CreateDatabase) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (cd *CreateDatabase) Sign(signer asymmetric.Signer) (err error) {
 	return cd.DefaultHashSignVerifierImpl.Sign(&cd.CreateDatabaseHeader, signer)
 }
 
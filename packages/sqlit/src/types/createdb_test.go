@@ -2,8 +2,10 @@
 package types
 
 import (
+	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"sqlit/src/crypto"
@@ -38,3 +40,48 @@ func TestTxCreateDatabase(t *testing.T) {
 		So(cd.GetAccountAddress(), ShouldEqual, addr)
 	})
 }
+
+func TestResourceMetaPoolHash(t *testing.T) {
+	Convey("Pool settings should only affect the hash if customized", t, func() {
+		var meta = ResourceMeta{Node: 2, IsolationLevel: 1}
+		plain, err := meta.MarshalHash()
+		So(err, ShouldBeNil)
+		So(plain[0], ShouldEqual, 0x99)
+
+		meta.Pool = PoolMeta{MaxReaders: 4, BusyTimeout: 1000}
+		custom, err := meta.MarshalHash()
+		So(err, ShouldBeNil)
+		So(custom[0], ShouldEqual, 0x9a)
+		So(custom[:len(plain)][1:], ShouldResemble, plain[1:])
+		So(len(custom), ShouldBeGreaterThan, len(plain))
+	})
+}
+
+func TestResourceMetaDurability(t *testing.T) {
+	Convey("Durability levels should be parsed by name", t, func() {
+		for _, l := range []DurabilityLevel{NormalDurability, StrictDurability, RelaxedDurability} {
+			parsed, err := ParseDurabilityLevel(strings.ToUpper(l.String()))
+			So(err, ShouldBeNil)
+			So(parsed, ShouldEqual, l)
+		}
+		l, err := ParseDurabilityLevel("")
+		So(err, ShouldBeNil)
+		So(l, ShouldEqual, NormalDurability)
+		_, err = ParseDurabilityLevel("eventual")
+		So(errors.Cause(err), ShouldEqual, ErrInvalidDurabilityLevel)
+		So(NumberOfDurabilityLevel.Valid(), ShouldBeFalse)
+	})
+	Convey("The durability should only affect the hash if customized", t, func() {
+		var meta = ResourceMeta{Node: 2, Pool: PoolMeta{MaxReaders: 4}}
+		plain, err := meta.MarshalHash()
+		So(err, ShouldBeNil)
+		So(plain[0], ShouldEqual, 0x9a)
+
+		meta.Durability = StrictDurability
+		custom, err := meta.MarshalHash()
+		So(err, ShouldBeNil)
+		So(custom[0], ShouldEqual, 0x9d)
+		So(custom[1:len(plain)], ShouldResemble, plain[1:])
+		So(len(custom), ShouldBeLessThanOrEqualTo, meta.Msgsize())
+	})
+}
@@ -26,7 +26,7 @@ func (sh *SignedCreateDatabaseRequestHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedCreateDatabaseRequestHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedCreateDatabaseRequestHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.CreateDatabaseRequestHeader, signer)
 }
 
@@ -42,7 +42,7 @@ func (r *CreateDatabaseRequest) Verify() error {
 }
 
 // Sign the request.
-func (r *CreateDatabaseRequest) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (r *CreateDatabaseRequest) Sign(signer asymmetric.Signer) (err error) {
 	// sign
 	return r.Header.Sign(signer)
 }
@@ -64,7 +64,7 @@ func (sh *SignedCreateDatabaseResponseHeader) Verify() (err error) {
 }
 
 // Sign the response.
-func (sh *SignedCreateDatabaseResponseHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedCreateDatabaseResponseHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.CreateDatabaseResponseHeader, signer)
 }
 
@@ -80,7 +80,7 @@ func (r *CreateDatabaseResponse) Verify() error {
 }
 
 // Sign the response.
-func (r *CreateDatabaseResponse) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (r *CreateDatabaseResponse) Sign(signer asymmetric.Signer) (err error) {
 	// sign
 	return r.Header.Sign(signer)
 }
@@ -102,7 +102,7 @@ func (sh *SignedDropDatabaseRequestHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedDropDatabaseRequestHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedDropDatabaseRequestHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.DropDatabaseRequestHeader, signer)
 }
 
@@ -118,7 +118,7 @@ func (r *DropDatabaseRequest) Verify() error {
 }
 
 // Sign the request.
-func (r *DropDatabaseRequest) Sign(signer *asymmetric.PrivateKey) error {
+func (r *DropDatabaseRequest) Sign(signer asymmetric.Signer) error {
 	return r.Header.Sign(signer)
 }
 
@@ -142,7 +142,7 @@ func (sh *SignedGetDatabaseRequestHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedGetDatabaseRequestHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedGetDatabaseRequestHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.GetDatabaseRequestHeader, signer)
 }
 
@@ -158,7 +158,7 @@ func (r *GetDatabaseRequest) Verify() error {
 }
 
 // Sign the request.
-func (r *GetDatabaseRequest) Sign(signer *asymmetric.PrivateKey) error {
+func (r *GetDatabaseRequest) Sign(signer asymmetric.Signer) error {
 	return r.Header.Sign(signer)
 }
 
@@ -179,7 +179,7 @@ func (sh *SignedGetDatabaseResponseHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedGetDatabaseResponseHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedGetDatabaseResponseHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.GetDatabaseResponseHeader, signer)
 }
 
@@ -195,6 +195,6 @@ func (r *GetDatabaseResponse) Verify() (err error) {
 }
 
 // Sign the request.
-func (r *GetDatabaseResponse) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (r *GetDatabaseResponse) Sign(signer asymmetric.Signer) (err error) {
 	return r.Header.Sign(signer)
 }
@@ -0,0 +1,113 @@
+package types
+
+import (
+	"time"
+
+	"sqlit/src/proto"
+)
+
+// DatabaseLoad defines the load of a database served by a miner in a report period.
+type DatabaseLoad struct {
+	DatabaseID proto.DatabaseID
+	NodeID     proto.NodeID
+	// Timestamp is the end of the report period
+	Timestamp time.Time
+	Period    time.Duration
+	// Queries is the count of the queries served in the period, and P95Latency is the 95th
+	// percentile of their latencies
+	Queries    uint64
+	P95Latency time.Duration
+	// CacheHits and CacheMisses count the lookups of the miner side caches of the database, i.e.
+	// the deduplicated write retries and the historical states
+	CacheHits   uint64
+	CacheMisses uint64
+	// StorageBytes is the storage usage at the end of the period, and StorageGrowth is its change
+	// in the period
+	StorageBytes  uint64
+	StorageGrowth int64
+}
+
+// QPS returns the average queries per second in the report period.
+func (l *DatabaseLoad) QPS() float64 {
+	if l.Period <= 0 {
+		return 0
+	}
+	return float64(l.Queries) / l.Period.Seconds()
+}
+
+// CacheHitRate returns the ratio of the cache hits to the cache lookups, 0 if no lookup.
+func (l *DatabaseLoad) CacheHitRate() float64 {
+	if l.CacheHits+l.CacheMisses == 0 {
+		return 0
+	}
+	return float64(l.CacheHits) / float64(l.CacheHits+l.CacheMisses)
+}
+
+// StorageGrowthPerDay returns the storage growth in bytes per day at the rate of the period.
+func (l *DatabaseLoad) StorageGrowthPerDay() float64 {
+	if l.Period <= 0 {
+		return 0
+	}
+	return float64(l.StorageGrowth) * float64(24*time.Hour) / float64(l.Period)
+}
+
+// AggregateDatabaseLoad returns the load of a database aggregated from the loads reported by its
+// miners. The queries and cache lookups are summed up as the traffic to the database, the queries
+// are scaled to the longest period to keep the QPS. The latency, storage and growth take the
+// maximum of the miners, as the percentiles can't be merged and the miners store the same data.
+func AggregateDatabaseLoad(loads []*DatabaseLoad) (total *DatabaseLoad) {
+	total = &DatabaseLoad{}
+	var qps float64
+	for _, v := range loads {
+		if total.DatabaseID == "" {
+			total.DatabaseID = v.DatabaseID
+		}
+		if v.Timestamp.After(total.Timestamp) {
+			total.Timestamp = v.Timestamp
+		}
+		if v.Period > total.Period {
+			total.Period = v.Period
+		}
+		qps += v.QPS()
+		if v.P95Latency > total.P95Latency {
+			total.P95Latency = v.P95Latency
+		}
+		total.CacheHits += v.CacheHits
+		total.CacheMisses += v.CacheMisses
+		if v.StorageBytes > total.StorageBytes {
+			total.StorageBytes = v.StorageBytes
+		}
+		if v.StorageGrowth > total.StorageGrowth {
+			total.StorageGrowth = v.StorageGrowth
+		}
+	}
+	total.Queries = uint64(qps*total.Period.Seconds() + 0.5)
+	return
+}
+
+// ReportDatabaseLoadReq defines a request of the ReportDatabaseLoad RPC method.
+type ReportDatabaseLoadReq struct {
+	proto.Envelope
+	Loads []*DatabaseLoad
+}
+
+// ReportDatabaseLoadResp defines a response of the ReportDatabaseLoad RPC method.
+type ReportDatabaseLoadResp struct {
+	proto.Envelope
+}
+
+// QueryDatabaseLoadReq defines a request of the QueryDatabaseLoad RPC method.
+type QueryDatabaseLoadReq struct {
+	proto.Envelope
+	DBID proto.DatabaseID
+}
+
+// QueryDatabaseLoadResp defines a response of the QueryDatabaseLoad RPC method.
+type QueryDatabaseLoadResp struct {
+	proto.Envelope
+	DBID proto.DatabaseID
+	// Miners are the latest loads reported by the miners of the database sorted by node id
+	Miners []*DatabaseLoad
+	// Total is the load aggregated from Miners
+	Total *DatabaseLoad
+}
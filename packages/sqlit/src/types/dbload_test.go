@@ -0,0 +1,58 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestDatabaseLoad(t *testing.T) {
+	Convey("Given the loads reported by the miners of a database", t, func() {
+		var (
+			now   = time.Now()
+			loads = []*DatabaseLoad{
+				{
+					DatabaseID:    "db",
+					NodeID:        "node1",
+					Timestamp:     now,
+					Period:        time.Minute,
+					Queries:       600,
+					P95Latency:    20 * time.Millisecond,
+					CacheHits:     3,
+					CacheMisses:   1,
+					StorageBytes:  1 << 20,
+					StorageGrowth: 1 << 10,
+				}, {
+					DatabaseID:    "db",
+					NodeID:        "node2",
+					Timestamp:     now.Add(-time.Second),
+					Period:        30 * time.Second,
+					Queries:       60,
+					P95Latency:    50 * time.Millisecond,
+					CacheMisses:   4,
+					StorageBytes:  1 << 19,
+					StorageGrowth: 1 << 11,
+				},
+			}
+		)
+		So(loads[0].QPS(), ShouldEqual, 10)
+		So(loads[0].CacheHitRate(), ShouldEqual, 0.75)
+		So(loads[0].StorageGrowthPerDay(), ShouldEqual, 24*60*1024)
+		So((&DatabaseLoad{}).QPS(), ShouldEqual, 0)
+		So((&DatabaseLoad{}).CacheHitRate(), ShouldEqual, 0)
+
+		Convey("The aggregated load should sum up the traffic", func() {
+			total := AggregateDatabaseLoad(loads)
+			So(total.DatabaseID, ShouldEqual, "db")
+			So(total.Timestamp, ShouldEqual, now)
+			So(total.Period, ShouldEqual, time.Minute)
+			So(total.QPS(), ShouldEqual, 12)
+			So(total.P95Latency, ShouldEqual, 50*time.Millisecond)
+			So(total.CacheHitRate(), ShouldEqual, 0.375)
+			So(total.StorageBytes, ShouldEqual, 1<<20)
+			So(total.StorageGrowth, ShouldEqual, 1<<11)
+			So(AggregateDatabaseLoad(nil).QPS(), ShouldEqual, 0)
+		})
+	})
+}
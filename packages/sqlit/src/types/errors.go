@@ -5,6 +5,39 @@ import (
 	"errors"
 )
 
+// Error codes returned to clients in error messages, so that they can be recognized across RPC.
+const (
+	// ErrCodeQuotaExceeded indicates that the database has exceeded its storage quota.
+	ErrCodeQuotaExceeded = "ERR_DATABASE_QUOTA_EXCEEDED"
+	// ErrCodeOverloaded indicates that a batch priority request is shed by the admission control.
+	ErrCodeOverloaded = "ERR_DATABASE_OVERLOADED"
+	// ErrCodeExecutionTimeout indicates that a query is interrupted after its max execution time.
+	ErrCodeExecutionTimeout = "ERR_QUERY_EXECUTION_TIMEOUT"
+	// ErrCodeResultTooLarge indicates that the result of a query exceeds the limit of the miner.
+	ErrCodeResultTooLarge = "ERR_QUERY_RESULT_TOO_LARGE"
+	// ErrCodeAttachNotLocal indicates that an attached database is not co-located with the
+	// queried database on the miner.
+	ErrCodeAttachNotLocal = "ERR_ATTACHED_DATABASE_NOT_LOCAL"
+	// ErrCodeLeaderChanged indicates that a query is refused by a miner which is no longer the
+	// leader of the database, the current peers may be learned from the miners.
+	ErrCodeLeaderChanged = "ERR_DATABASE_LEADER_CHANGED"
+	// ErrCodeDatabaseNotFound indicates that the database is not served by the miner, e.g. it's
+	// dropped or its miners are replaced.
+	ErrCodeDatabaseNotFound = "ERR_DATABASE_NOT_FOUND"
+	// ErrCodePermissionDenied indicates that the account has no permission of the query on the
+	// database.
+	ErrCodePermissionDenied = "ERR_DATABASE_PERMISSION_DENIED"
+	// ErrCodeDuplicateRequest indicates that a request is refused for its sequence number which is
+	// applied on the connection, e.g. a write retried after the client restarts.
+	ErrCodeDuplicateRequest = "ERR_DUPLICATE_REQUEST"
+	// ErrCodeCostExceeded indicates that a query is refused for its estimated cost exceeding the
+	// max cost of the request.
+	ErrCodeCostExceeded = "ERR_QUERY_COST_EXCEEDED"
+	// ErrCodeShuttingDown indicates that a query is refused by a miner which is shutting down, e.g.
+	// to restart, the reads may be served by the followers meanwhile.
+	ErrCodeShuttingDown = "ERR_MINER_SHUTTING_DOWN"
+)
+
 var (
 	// ErrMerkleRootVerification indicates a failed merkle root verificatin.
 	ErrMerkleRootVerification = errors.New("merkle root verification failed")
@@ -19,4 +52,10 @@ var (
 	ErrHashVerification = errors.New("hash verification failed")
 	// ErrInvalidGenesis indicates a failed genesis block verification.
 	ErrInvalidGenesis = errors.New("invalid genesis block")
+	// ErrNilBlockTx indicates a block containing nil requests, responses or acks.
+	ErrNilBlockTx = errors.New("nil transaction in block")
+	// ErrInvalidDurabilityLevel indicates an unknown durability level of a database.
+	ErrInvalidDurabilityLevel = errors.New("invalid durability level")
+	// ErrTraceIDTooLong indicates a request trace id longer than MaxTraceIDLength.
+	ErrTraceIDTooLong = errors.New("trace id too long")
 )
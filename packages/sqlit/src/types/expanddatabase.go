@@ -0,0 +1,62 @@
+package types
+
+import (
+	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/verifier"
+	"sqlit/src/proto"
+)
+
+//go:generate hsp
+
+// ExpandDatabaseHeader defines the sqlchain expansion transaction header.
+//
+// Node more miners are added to the sqlchain, the TargetMiners are preferred and the others are
+// matched from the provider pool with the resource meta of the sqlchain.
+type ExpandDatabaseHeader struct {
+	TargetSQLChain proto.AccountAddress
+	Node           uint16
+	TargetMiners   []proto.AccountAddress
+	Nonce          interfaces.AccountNonce
+}
+
+// GetAccountNonce implements interfaces/Transaction.GetAccountNonce.
+func (h *ExpandDatabaseHeader) GetAccountNonce() interfaces.AccountNonce {
+	return h.Nonce
+}
+
+// ExpandDatabase defines the sqlchain expansion transaction.
+type ExpandDatabase struct {
+	ExpandDatabaseHeader
+	interfaces.TransactionTypeMixin
+	verifier.DefaultHashSignVerifierImpl
+}
+
+// NewExpandDatabase returns new instance.
+func NewExpandDatabase(header *ExpandDatabaseHeader) *ExpandDatabase {
+	return &ExpandDatabase{
+		ExpandDatabaseHeader: *header,
+		TransactionTypeMixin: *interfaces.NewTransactionTypeMixin(interfaces.TransactionTypeExpandDatabase),
+	}
+}
+
+// Sign implements interfaces/Transaction.Sign.
+func (ed *ExpandDatabase) Sign(signer asymmetric.Signer) (err error) {
+	return ed.DefaultHashSignVerifierImpl.Sign(&ed.ExpandDatabaseHeader, signer)
+}
+
+// Verify implements interfaces/Transaction.Verify.
+func (ed *ExpandDatabase) Verify() error {
+	return ed.DefaultHashSignVerifierImpl.Verify(&ed.ExpandDatabaseHeader)
+}
+
+// GetAccountAddress implements interfaces/Transaction.GetAccountAddress.
+func (ed *ExpandDatabase) GetAccountAddress() proto.AccountAddress {
+	addr, _ := crypto.PubKeyHash(ed.Signee)
+	return addr
+}
+
+func init() {
+	interfaces.RegisterTransaction(interfaces.TransactionTypeExpandDatabase, (*ExpandDatabase)(nil))
+}
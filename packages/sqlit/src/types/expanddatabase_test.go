@@ -0,0 +1,36 @@
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+)
+
+func TestExpandDatabase(t *testing.T) {
+	Convey("test ExpandDatabase", t, func() {
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		addr, err := crypto.PubKeyHash(privKey.PubKey())
+		So(err, ShouldBeNil)
+
+		ed := NewExpandDatabase(&ExpandDatabaseHeader{
+			TargetSQLChain: proto.AccountAddress(hash.HashH([]byte("db"))),
+			Node:           1,
+			Nonce:          3,
+		})
+		err = ed.Sign(privKey)
+		So(err, ShouldBeNil)
+		So(ed.Verify(), ShouldBeNil)
+		So(ed.GetAccountAddress(), ShouldEqual, addr)
+		So(ed.GetAccountNonce(), ShouldEqual, 3)
+
+		// the signature should cover the target miners
+		ed.TargetMiners = []proto.AccountAddress{proto.AccountAddress(hash.HashH([]byte("miner")))}
+		So(ed.Verify(), ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,395 @@
+// Package fixtures generates deterministic, fully-populated and signed instances of the
+// transaction and block types for the tests, so that the encodings of the optional fields are
+// covered too.
+package fixtures
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"time"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// baseTime is the time the generated timestamps start from.
+var baseTime = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Generator generates the fixtures from a seed, generators of the same seed generate the same
+// values in the same order of calls.
+type Generator struct {
+	rand    *rand.Rand
+	privKey *asymmetric.PrivateKey
+	addr    proto.AccountAddress
+	nodeID  proto.NodeID
+}
+
+// New returns a new generator of seed, the signing key of the generated values is derived from
+// the seed too.
+func New(seed int64) *Generator {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(seed))
+	var (
+		h          = hash.THashH(buf[:])
+		privKey, _ = asymmetric.PrivKeyFromBytes(h[:])
+		addr, err  = crypto.PubKeyHash(privKey.PubKey())
+	)
+	must(err)
+	return &Generator{
+		rand:    rand.New(rand.NewSource(seed)),
+		privKey: privKey,
+		addr:    addr,
+		nodeID:  proto.NodeID(hash.THashH(h[:]).String()),
+	}
+}
+
+// must panics on the errors which never happen on the generated values, e.g. signing them with a
+// valid key.
+func must(err error) {
+	if err != nil {
+		panic(fmt.Sprintf("fixtures: %v", err))
+	}
+}
+
+// PrivateKey returns the key signing the generated values.
+func (g *Generator) PrivateKey() *asymmetric.PrivateKey {
+	return g.privKey
+}
+
+// Address returns the account address of the signing key.
+func (g *Generator) Address() proto.AccountAddress {
+	return g.addr
+}
+
+// NodeID returns the node id producing the generated blocks and responses.
+func (g *Generator) NodeID() proto.NodeID {
+	return g.nodeID
+}
+
+// Hash returns a random hash.
+func (g *Generator) Hash() (h hash.Hash) {
+	g.rand.Read(h[:])
+	return
+}
+
+// AccountAddress returns a random account address.
+func (g *Generator) AccountAddress() proto.AccountAddress {
+	return proto.AccountAddress(g.Hash())
+}
+
+func (g *Generator) addresses(n int) (addrs []proto.AccountAddress) {
+	addrs = make([]proto.AccountAddress, n)
+	for i := range addrs {
+		addrs[i] = g.AccountAddress()
+	}
+	return
+}
+
+func (g *Generator) randNodeID() proto.NodeID {
+	var h = g.Hash()
+	return proto.NodeID(h.String())
+}
+
+func (g *Generator) str(prefix string) string {
+	return fmt.Sprintf("%s-%08x", prefix, g.rand.Uint32())
+}
+
+func (g *Generator) nonce() pi.AccountNonce {
+	return pi.AccountNonce(g.rand.Uint32() + 1)
+}
+
+// Timestamp returns a random timestamp in UTC with nanoseconds.
+func (g *Generator) Timestamp() time.Time {
+	return baseTime.Add(time.Duration(g.rand.Int63n(int64(365 * 24 * time.Hour))))
+}
+
+func (g *Generator) headerExt() (ext types.HeaderExt) {
+	must(ext.SetExt(types.SerialVersionExt, g.rand.Int63(), g.str("ext")))
+	return
+}
+
+// ResourceMeta returns a resource meta with all the optional settings set.
+func (g *Generator) ResourceMeta() types.ResourceMeta {
+	return types.ResourceMeta{
+		TargetMiners:           g.addresses(2),
+		Node:                   uint16(g.rand.Intn(8) + 1),
+		Space:                  g.rand.Uint64(),
+		Memory:                 g.rand.Uint64(),
+		LoadAvgPerCPU:          g.rand.Float64(),
+		EncryptionKey:          g.str("key"),
+		UseEventualConsistency: true,
+		ConsistencyLevel:       g.rand.Float64(),
+		IsolationLevel:         g.rand.Intn(4) + 1,
+		Pool: types.PoolMeta{
+			MaxReaders:  g.rand.Intn(16) + 1,
+			BusyTimeout: g.rand.Int63n(10000) + 1,
+			CacheSize:   -g.rand.Intn(10000) - 1,
+			MMapSize:    g.rand.Int63n(1<<30) + 1,
+		},
+		Source: proto.DatabaseID(g.str("source")),
+		Placement: types.PlacementMeta{
+			MinRegions: uint16(g.rand.Intn(3) + 1),
+			MinZones:   uint16(g.rand.Intn(3) + 1),
+			Regions:    []string{g.str("region"), g.str("region")},
+		},
+		Durability: types.DurabilityLevel(g.rand.Intn(int(types.NumberOfDurabilityLevel)-1) + 1),
+	}
+}
+
+// UserPermission returns a user permission with the query patterns.
+func (g *Generator) UserPermission() *types.UserPermission {
+	return &types.UserPermission{
+		Role:     types.UserPermissionRole(g.rand.Intn(int(types.Admin)) + 1),
+		Patterns: []string{g.str("SELECT"), g.str("INSERT")},
+	}
+}
+
+// BaseAccount returns a base account transaction.
+func (g *Generator) BaseAccount() *types.BaseAccount {
+	return types.NewBaseAccount(&types.Account{
+		Address:   g.AccountAddress(),
+		Rating:    g.rand.Float64(),
+		NextNonce: g.nonce(),
+	})
+}
+
+// CreateDatabase returns a signed create database transaction.
+func (g *Generator) CreateDatabase() (tx *types.CreateDatabase) {
+	tx = types.NewCreateDatabase(&types.CreateDatabaseHeader{
+		Owner:        g.addr,
+		ResourceMeta: g.ResourceMeta(),
+		Nonce:        g.nonce(),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// ExpandDatabase returns a signed expand database transaction.
+func (g *Generator) ExpandDatabase() (tx *types.ExpandDatabase) {
+	tx = types.NewExpandDatabase(&types.ExpandDatabaseHeader{
+		TargetSQLChain: g.AccountAddress(),
+		Node:           uint16(g.rand.Intn(8) + 1),
+		TargetMiners:   g.addresses(2),
+		Nonce:          g.nonce(),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// IssueKeys returns a signed issue keys transaction.
+func (g *Generator) IssueKeys() (tx *types.IssueKeys) {
+	tx = types.NewIssueKeys(&types.IssueKeysHeader{
+		TargetSQLChain: g.AccountAddress(),
+		MinerKeys: []types.MinerKey{
+			{Miner: g.AccountAddress(), EncryptionKey: g.str("key")},
+			{Miner: g.AccountAddress(), EncryptionKey: g.str("key")},
+		},
+		Nonce: g.nonce(),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// ProvideService returns a signed provide service transaction with the region and zone labels.
+func (g *Generator) ProvideService() (tx *types.ProvideService) {
+	tx = types.NewProvideService(&types.ProvideServiceHeader{
+		Space:         g.rand.Uint64(),
+		Memory:        g.rand.Uint64(),
+		LoadAvgPerCPU: g.rand.Float64(),
+		TargetUser:    g.addresses(2),
+		NodeID:        g.nodeID,
+		Nonce:         g.nonce(),
+		Region:        g.str("region"),
+		Zone:          g.str("zone"),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// ReplaceMiner returns a signed replace miner transaction.
+func (g *Generator) ReplaceMiner() (tx *types.ReplaceMiner) {
+	tx = types.NewReplaceMiner(&types.ReplaceMinerHeader{
+		TargetSQLChain: g.AccountAddress(),
+		Miner:          g.AccountAddress(),
+		Standby:        g.AccountAddress(),
+		Nonce:          g.nonce(),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// UpdateBilling returns a signed update billing transaction. It's not registered as a
+// transaction type, so it's not included in Transactions.
+func (g *Generator) UpdateBilling() (tx *types.UpdateBilling) {
+	var from = g.rand.Uint32() >> 1
+	tx = types.NewUpdateBilling(&types.UpdateBillingHeader{
+		Users: []*types.UserCost{{
+			User: g.AccountAddress(),
+			Cost: g.rand.Uint64(),
+			Miners: []*types.MinerIncome{
+				{Miner: g.AccountAddress(), Income: g.rand.Uint64()},
+				{Miner: g.AccountAddress(), Income: g.rand.Uint64()},
+			},
+		}},
+		Nonce:    g.nonce(),
+		Version:  g.rand.Int31n(8) + 1,
+		Receiver: g.addr,
+		Range:    types.BillingRange{From: from, To: from + g.rand.Uint32()>>1 + 1},
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// UpdatePermission returns a signed update permission transaction.
+func (g *Generator) UpdatePermission() (tx *types.UpdatePermission) {
+	tx = types.NewUpdatePermission(&types.UpdatePermissionHeader{
+		TargetSQLChain: g.AccountAddress(),
+		TargetUser:     g.AccountAddress(),
+		Permission:     g.UserPermission(),
+		Nonce:          g.nonce(),
+	})
+	must(tx.Sign(g.privKey))
+	return
+}
+
+// Transactions returns one transaction of each registered transaction type.
+func (g *Generator) Transactions() []pi.Transaction {
+	return []pi.Transaction{
+		g.BaseAccount(),
+		g.CreateDatabase(),
+		g.ExpandDatabase(),
+		g.IssueKeys(),
+		g.ProvideService(),
+		g.ReplaceMiner(),
+		g.UpdatePermission(),
+	}
+}
+
+// Request returns a signed write request with the extension fields, the queries have the
+// arguments of all the supported types.
+func (g *Generator) Request() (req *types.Request) {
+	req = &types.Request{
+		Header: types.SignedRequestHeader{
+			RequestHeader: types.RequestHeader{
+				QueryType:    types.WriteQuery,
+				NodeID:       g.nodeID,
+				DatabaseID:   proto.DatabaseID(g.str("db")),
+				ConnectionID: g.rand.Uint64(),
+				SeqNo:        g.rand.Uint64(),
+				Timestamp:    g.Timestamp(),
+				HeaderExt:    g.headerExt(),
+			},
+		},
+		Payload: types.RequestPayload{Queries: []types.Query{
+			{
+				Pattern: "INSERT INTO t1 (k, v, f, b, t, n) VALUES (?, ?, ?, ?, ?, ?)",
+				Args: []types.NamedArg{
+					{Value: g.rand.Int63()},
+					{Name: "v", Value: g.str("text")},
+					{Value: g.rand.Float64()},
+					{Value: []byte(g.str("blob"))},
+					{Value: g.Timestamp()},
+					{Value: nil},
+				},
+			},
+			{Pattern: "DELETE FROM t1 WHERE k = 0"},
+		}},
+	}
+	must(req.Sign(g.privKey))
+	return
+}
+
+// Response returns a response of req with the rows of all the supported column types, which is
+// hashed and signed by the generator.
+func (g *Generator) Response(req *types.Request) (resp *types.Response) {
+	resp = &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:         req.Header.RequestHeader,
+				RequestHash:     req.Header.Hash(),
+				NodeID:          g.nodeID,
+				Timestamp:       g.Timestamp(),
+				LogOffset:       g.rand.Uint64() >> 1,
+				LastInsertID:    g.rand.Int63(),
+				AffectedRows:    g.rand.Int63(),
+				ResponseAccount: g.addr,
+			},
+		},
+		Payload: types.ResponsePayload{
+			Columns:   []string{"k", "v", "f", "b", "t", "n"},
+			DeclTypes: []string{"INT", "TEXT", "REAL", "BLOB", "DATETIME", ""},
+			Rows: []types.ResponseRow{{Values: []interface{}{
+				g.rand.Int63(), g.str("text"), g.rand.Float64(), []byte(g.str("blob")),
+				g.Timestamp(), nil,
+			}}},
+		},
+	}
+	must(resp.BuildHash())
+	must(resp.Sign(g.privKey))
+	return
+}
+
+// Ack returns a signed ack of resp.
+func (g *Generator) Ack(resp *types.Response) (ack *types.Ack) {
+	ack = &types.Ack{
+		Header: types.SignedAckHeader{
+			AckHeader: types.AckHeader{
+				Response:     resp.Header.ResponseHeader,
+				ResponseHash: resp.Header.Hash(),
+				NodeID:       g.randNodeID(),
+				Timestamp:    g.Timestamp(),
+			},
+		},
+	}
+	must(ack.Sign(g.privKey))
+	return
+}
+
+// Block returns a signed sqlchain block following parent, which packs a failed request, a query
+// and an ack.
+func (g *Generator) Block(parent hash.Hash) (b *types.Block) {
+	var (
+		req  = g.Request()
+		resp = g.Response(req)
+	)
+	b = &types.Block{
+		SignedHeader: types.SignedHeader{
+			Header: types.Header{
+				Version:     0x01000000,
+				Producer:    g.nodeID,
+				GenesisHash: g.Hash(),
+				ParentHash:  parent,
+				Timestamp:   g.Timestamp(),
+				HeaderExt:   g.headerExt(),
+			},
+		},
+		FailedReqs: []*types.Request{g.Request()},
+		QueryTxs:   []*types.QueryAsTx{{Request: req, Response: &resp.Header}},
+		Acks:       []*types.SignedAckHeader{&g.Ack(resp).Header},
+	}
+	must(b.PackAndSignBlock(g.privKey))
+	return
+}
+
+// BPBlock returns a signed main chain block following parent, which packs the transactions of
+// Transactions.
+func (g *Generator) BPBlock(parent hash.Hash) (b *types.BPBlock) {
+	b = &types.BPBlock{
+		SignedHeader: types.BPSignedHeader{
+			BPHeader: types.BPHeader{
+				Version:    0x01000000,
+				Producer:   g.addr,
+				ParentHash: parent,
+				Timestamp:  g.Timestamp(),
+			},
+		},
+		Transactions: g.Transactions(),
+	}
+	must(b.PackAndSignBlock(g.privKey))
+	return
+}
@@ -0,0 +1,111 @@
+package fixtures
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	pi "sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+)
+
+func roundTrip(in, out interface{}) {
+	buf, err := utils.EncodeMsgPack(in)
+	So(err, ShouldBeNil)
+	So(utils.DecodeMsgPack(buf.Bytes(), out), ShouldBeNil)
+}
+
+func TestGenerator(t *testing.T) {
+	Convey("Given the generators of the same seed", t, func() {
+		var g1, g2 = New(1), New(1)
+		So(g1.Address(), ShouldEqual, g2.Address())
+		So(g1.NodeID(), ShouldEqual, g2.NodeID())
+		So(g1.Address(), ShouldNotEqual, New(2).Address())
+
+		Convey("The generated values should be identical", func() {
+			var b1, b2 = g1.BPBlock(hash.Hash{}), g2.BPBlock(hash.Hash{})
+			So(b1.BlockHash(), ShouldResemble, b2.BlockHash())
+			var s1, s2 = g1.Block(*b1.BlockHash()), g2.Block(*b2.BlockHash())
+			So(s1.BlockHash(), ShouldResemble, s2.BlockHash())
+			So(g1.UpdateBilling().Hash(), ShouldResemble, g2.UpdateBilling().Hash())
+			So(g1.Hash(), ShouldNotResemble, g1.Hash())
+		})
+	})
+	Convey("Given the generated values", t, func() {
+		var g = New(3)
+
+		Convey("Every registered transaction type should be generated", func() {
+			var seen = make(map[pi.TransactionType]bool)
+			for _, tx := range g.Transactions() {
+				So(tx.Verify(), ShouldBeNil)
+				seen[tx.GetTransactionType()] = true
+			}
+			for _, tt := range []pi.TransactionType{
+				pi.TransactionTypeBaseAccount,
+				pi.TransactionTypeCreateDatabase,
+				pi.TransactionTypeExpandDatabase,
+				pi.TransactionTypeIssueKeys,
+				pi.TransactionTypeProvideService,
+				pi.TransactionTypeReplaceMiner,
+				pi.TransactionTypeUpdatePermission,
+			} {
+				So(seen, ShouldContainKey, tt)
+			}
+		})
+		Convey("The transactions should survive the encoding round trip", func() {
+			for _, tx := range g.Transactions() {
+				var out pi.Transaction
+				roundTrip(pi.WrapTransaction(tx), &out)
+				So(out.GetTransactionType(), ShouldEqual, tx.GetTransactionType())
+				So(out.Hash(), ShouldResemble, tx.Hash())
+				So(out.Verify(), ShouldBeNil)
+			}
+			var (
+				ub  = g.UpdateBilling()
+				out *types.UpdateBilling
+			)
+			So(ub.Verify(), ShouldBeNil)
+			roundTrip(ub, &out)
+			So(out.Verify(), ShouldBeNil)
+			So(out.UpdateBillingHeader, ShouldResemble, ub.UpdateBillingHeader)
+		})
+		Convey("The queries should survive the encoding round trip", func() {
+			var (
+				req     = g.Request()
+				resp    = g.Response(req)
+				ack     = g.Ack(resp)
+				outReq  *types.Request
+				outResp *types.Response
+				outAck  *types.Ack
+			)
+			So(req.Header.SerialVersion, ShouldEqual, types.SerialVersionExt)
+			roundTrip(req, &outReq)
+			So(outReq.Verify(), ShouldBeNil)
+			So(outReq.Header.Hash(), ShouldResemble, req.Header.Hash())
+			roundTrip(resp, &outResp)
+			So(outResp.VerifyHash(), ShouldBeNil)
+			So(outResp.VerifySignature(), ShouldBeNil)
+			roundTrip(ack, &outAck)
+			So(outAck.Verify(), ShouldBeNil)
+		})
+		Convey("The blocks should survive the encoding round trip", func() {
+			var (
+				bp    = g.BPBlock(g.Hash())
+				b     = g.Block(*bp.BlockHash())
+				outBP *types.BPBlock
+				outB  *types.Block
+			)
+			So(bp.Verify(), ShouldBeNil)
+			So(b.Verify(), ShouldBeNil)
+			roundTrip(bp, &outBP)
+			So(outBP.Verify(), ShouldBeNil)
+			So(outBP.BlockHash(), ShouldResemble, bp.BlockHash())
+			So(outBP.Transactions, ShouldHaveLength, len(bp.Transactions))
+			roundTrip(b, &outB)
+			So(outB.Verify(), ShouldBeNil)
+			So(outB.BlockHash(), ShouldResemble, b.BlockHash())
+		})
+	})
+}
@@ -0,0 +1,283 @@
+package types
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/hash"
+	"sqlit/src/crypto/verifier"
+	"sqlit/src/marshalhash"
+	"sqlit/src/proto"
+)
+
+var updateHashVectors = flag.Bool("update-hash-vectors", false, "rewrite the golden hash vectors")
+
+const hashVectorsFile = "testdata/hash_vectors.json"
+
+// hashVector defines the canonical encoding and hash of a signed type.
+type hashVector struct {
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"`
+	Hash     string `json:"hash"`
+}
+
+// hashVectors defines the golden vectors of a canonical encoding version.
+type hashVectors struct {
+	Version int          `json:"version"`
+	Vectors []hashVector `json:"vectors"`
+}
+
+func vectorHash(s string) hash.Hash {
+	return hash.THashH([]byte(s))
+}
+
+func vectorAddress(s string) proto.AccountAddress {
+	return proto.AccountAddress(vectorHash(s))
+}
+
+// buildHashVectorValues returns fixed values of the signed types, any change of the values
+// invalidates the golden vectors.
+func buildHashVectorValues() []struct {
+	name  string
+	value verifier.MarshalHasher
+} {
+	var (
+		ts     = time.Date(2019, 1, 1, 0, 0, 0, 123456789, time.UTC)
+		nodeID = proto.NodeID("00000000000000000000000000000000000000000000000000000000000000aa")
+		reqHdr = RequestHeader{
+			QueryType:    WriteQuery,
+			NodeID:       nodeID,
+			DatabaseID:   proto.DatabaseID("db"),
+			ConnectionID: 1,
+			SeqNo:        2,
+			Timestamp:    ts,
+			BatchCount:   1,
+			QueriesHash:  vectorHash("queries"),
+		}
+		respHdr = ResponseHeader{
+			Request:         reqHdr,
+			RequestHash:     vectorHash("request"),
+			NodeID:          nodeID,
+			Timestamp:       ts,
+			RowCount:        3,
+			LogOffset:       4,
+			LastInsertID:    -1,
+			AffectedRows:    5,
+			PayloadHash:     vectorHash("payload"),
+			ResponseAccount: vectorAddress("miner"),
+		}
+		meta = ResourceMeta{
+			TargetMiners:           []proto.AccountAddress{vectorAddress("miner")},
+			Node:                   2,
+			Space:                  1 << 30,
+			Memory:                 1 << 20,
+			LoadAvgPerCPU:          0.5,
+			EncryptionKey:          "key",
+			UseEventualConsistency: true,
+			ConsistencyLevel:       1.0,
+			IsolationLevel:         1,
+		}
+		metaWithPool = meta
+		metaClone    = meta
+		metaPlaced   = meta
+		reqHdrExt    = reqHdr
+	)
+	metaWithPool.Pool = PoolMeta{MaxReaders: 4, BusyTimeout: 1000, CacheSize: -2000, MMapSize: 1 << 20}
+	metaClone.Source = "source"
+	metaPlaced.Placement = PlacementMeta{MinRegions: 2, MinZones: 3, Regions: []string{"eu-west"}}
+	// extension fields [100, "trace"]
+	reqHdrExt.HeaderExt = HeaderExt{
+		SerialVersion: SerialVersionExt,
+		Ext:           marshalhash.Fields{0x92, 0x64, 0xa5, 't', 'r', 'a', 'c', 'e'},
+	}
+
+	return []struct {
+		name  string
+		value verifier.MarshalHasher
+	}{
+		{"Header", &Header{
+			Version:     1,
+			Producer:    nodeID,
+			GenesisHash: vectorHash("genesis"),
+			ParentHash:  vectorHash("parent"),
+			MerkleRoot:  vectorHash("merkle"),
+			Timestamp:   ts,
+		}},
+		{"BPHeader", &BPHeader{
+			Version:    1,
+			Producer:   vectorAddress("producer"),
+			MerkleRoot: vectorHash("merkle"),
+			ParentHash: vectorHash("parent"),
+			Timestamp:  ts,
+		}},
+		{"RequestHeader", &reqHdr},
+		{"RequestPayload", &RequestPayload{Queries: []Query{{
+			Pattern: "INSERT INTO t1 (k, v) VALUES (?, ?)",
+			Args: []NamedArg{
+				{Name: "", Value: int64(1)},
+				{Name: "v", Value: "text"},
+			},
+		}}}},
+		{"ResponseHeader", &respHdr},
+		{"ResponsePayload", &ResponsePayload{
+			Columns:   []string{"k", "v", "f", "b", "t", "n"},
+			DeclTypes: []string{"INT", "TEXT", "REAL", "BLOB", "DATETIME", ""},
+			Rows: []ResponseRow{{Values: []interface{}{
+				int64(1), "text", 0.5, []byte{0xde, 0xad}, ts, nil,
+			}}},
+		}},
+		{"AckHeader", &AckHeader{
+			Response:     respHdr,
+			ResponseHash: vectorHash("response"),
+			NodeID:       nodeID,
+			Timestamp:    ts,
+		}},
+		{"ResourceMeta", &meta},
+		{"ResourceMeta/Pool", &metaWithPool},
+		{"CreateDatabaseHeader", &CreateDatabaseHeader{
+			Owner:        vectorAddress("owner"),
+			ResourceMeta: meta,
+			Nonce:        7,
+		}},
+		{"ProvideServiceHeader", &ProvideServiceHeader{
+			Space:         1 << 30,
+			Memory:        1 << 20,
+			LoadAvgPerCPU: 0.25,
+			TargetUser:    []proto.AccountAddress{vectorAddress("user")},
+			NodeID:        nodeID,
+			Nonce:         8,
+		}},
+		{"UpdatePermissionHeader", &UpdatePermissionHeader{
+			TargetSQLChain: vectorAddress("chain"),
+			TargetUser:     vectorAddress("user"),
+			Permission:     UserPermissionFromRole(Write),
+			Nonce:          9,
+		}},
+		{"IssueKeysHeader", &IssueKeysHeader{
+			TargetSQLChain: vectorAddress("chain"),
+			MinerKeys:      []MinerKey{{Miner: vectorAddress("miner"), EncryptionKey: "key"}},
+			Nonce:          10,
+		}},
+		{"UpdateServiceHeader", &UpdateServiceHeader{
+			Op: UpdateDB,
+			Instance: ServiceInstance{
+				DatabaseID: proto.DatabaseID("db"),
+				Peers: &proto.Peers{PeersHeader: proto.PeersHeader{
+					Version: 1,
+					Term:    2,
+					Leader:  nodeID,
+					Servers: []proto.NodeID{nodeID},
+				}},
+				ResourceMeta: meta,
+			},
+		}},
+		{"PeersHeader", &proto.PeersHeader{
+			Version: 1,
+			Term:    2,
+			Leader:  nodeID,
+			Servers: []proto.NodeID{nodeID},
+		}},
+		{"RequestHeader/Ext", &reqHdrExt},
+		{"ResourceMeta/Source", &metaClone},
+		{"ResourceMeta/Placement", &metaPlaced},
+		{"ProvideServiceHeader/Labels", &ProvideServiceHeader{
+			Space:         1 << 30,
+			Memory:        1 << 20,
+			LoadAvgPerCPU: 0.25,
+			TargetUser:    []proto.AccountAddress{vectorAddress("user")},
+			NodeID:        nodeID,
+			Nonce:         8,
+			Region:        "eu-west",
+			Zone:          "eu-west-1a",
+		}},
+		// the float results are encoded by their bits, any platform dependent formatting or
+		// rounding breaks the agreement of the response hashes
+		{"ResponsePayload/Numbers", &ResponsePayload{
+			Columns:   []string{"v"},
+			DeclTypes: []string{"REAL"},
+			Rows: []ResponseRow{
+				{Values: []interface{}{0.1}},
+				{Values: []interface{}{math.Copysign(0, -1)}},
+				{Values: []interface{}{math.SmallestNonzeroFloat64}},
+				{Values: []interface{}{math.MaxFloat64}},
+				{Values: []interface{}{math.Inf(-1)}},
+				{Values: []interface{}{float32(1) / 3}},
+				{Values: []interface{}{int64(math.MinInt64)}},
+				{Values: []interface{}{int64(math.MaxInt64)}},
+			},
+		}},
+	}
+}
+
+func buildHashVectors() (vectors *hashVectors) {
+	vectors = &hashVectors{Version: marshalhash.CanonicalVersion}
+	for _, v := range buildHashVectorValues() {
+		enc, err := v.value.MarshalHash()
+		So(err, ShouldBeNil)
+		h := hash.THashH(enc)
+		vectors.Vectors = append(vectors.Vectors, hashVector{
+			Type:     v.name,
+			Encoding: hex.EncodeToString(enc),
+			Hash:     hex.EncodeToString(h[:]),
+		})
+	}
+	return
+}
+
+func TestHashVectors(t *testing.T) {
+	Convey("The canonical encodings of signed types should match the golden vectors", t, func() {
+		var vectors = buildHashVectors()
+		if *updateHashVectors {
+			data, err := json.MarshalIndent(vectors, "", "  ")
+			So(err, ShouldBeNil)
+			So(os.MkdirAll(filepath.Dir(hashVectorsFile), 0755), ShouldBeNil)
+			So(os.WriteFile(hashVectorsFile, append(data, '\n'), 0644), ShouldBeNil)
+		}
+
+		data, err := os.ReadFile(hashVectorsFile)
+		So(err, ShouldBeNil)
+		var golden hashVectors
+		So(json.Unmarshal(data, &golden), ShouldBeNil)
+		So(golden.Version, ShouldEqual, marshalhash.CanonicalVersion)
+		So(len(vectors.Vectors), ShouldEqual, len(golden.Vectors))
+		for i, v := range golden.Vectors {
+			So(vectors.Vectors[i], ShouldResemble, v)
+		}
+	})
+	Convey("Logically identical values should have identical hashes", t, func() {
+		var (
+			ts  = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+			loc = time.FixedZone("UTC+8", 8*3600)
+			h1  = &Header{Timestamp: ts}
+			h2  = &Header{Timestamp: ts.In(loc)}
+		)
+		e1, err := h1.MarshalHash()
+		So(err, ShouldBeNil)
+		e2, err := h2.MarshalHash()
+		So(err, ShouldBeNil)
+		So(e2, ShouldResemble, e1)
+
+		// typed and reflected encodings of named types
+		r1, err := ResponseRow{Values: []interface{}{proto.NodeID("node"), int32(1)}}.MarshalHash()
+		So(err, ShouldBeNil)
+		r2, err := ResponseRow{Values: []interface{}{"node", uint8(1)}}.MarshalHash()
+		So(err, ShouldBeNil)
+		So(r1, ShouldResemble, r2)
+
+		// the byte arrays in interface values are not bin, as in the data signed already
+		addr := vectorAddress("miner")
+		r1, err = ResponseRow{Values: []interface{}{addr}}.MarshalHash()
+		So(err, ShouldBeNil)
+		r2, err = ResponseRow{Values: []interface{}{addr[:]}}.MarshalHash()
+		So(err, ShouldBeNil)
+		So(r1, ShouldNotResemble, r2)
+	})
+}
@@ -2,6 +2,11 @@
 package types
 
 import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/verifier"
 	"sqlit/src/proto"
@@ -25,6 +30,85 @@ type ResourceMeta struct {
 	UseEventualConsistency bool                   // use eventual consistency replication if enabled
 	ConsistencyLevel       float64                // customized strong consistency level
 	IsolationLevel         int                    // customized isolation level
+	Pool                   PoolMeta               // customized sqlite connection pool settings
+	Source                 proto.DatabaseID       // source database to clone the state from
+	Placement              PlacementMeta          // replica placement constraints
+	Durability             DurabilityLevel        // durability of the committed writes on miners
+}
+
+// PlacementMeta defines the constraints on the regions and zones of the miners of a database, so
+// that it survives the outage of a region or zone by construction.
+type PlacementMeta struct {
+	MinRegions uint16   // min count of distinct regions of the miners
+	MinZones   uint16   // min count of distinct zones of the miners
+	Regions    []string // regions which must host a miner
+}
+
+// IsZero returns whether the placement meta has no constraint.
+func (p *PlacementMeta) IsZero() bool {
+	return p.MinRegions == 0 && p.MinZones == 0 && len(p.Regions) == 0
+}
+
+// PoolMeta defines the sqlite connection pool settings of database instance, zero values use the
+// miner defaults.
+type PoolMeta struct {
+	MaxReaders  int   // max open read connections
+	BusyTimeout int64 // busy timeout in milliseconds
+	CacheSize   int   // page cache size per connection, in pages if positive or in KiB if negative
+	MMapSize    int64 // max bytes of memory-mapped I/O
+}
+
+// IsZero returns whether all settings of the pool meta use the miner defaults.
+func (p *PoolMeta) IsZero() bool {
+	return *p == PoolMeta{}
+}
+
+// DurabilityLevel defines the durability of the committed writes of a database on each miner,
+// which is traded for the write throughput by syncing the write-ahead log less often.
+type DurabilityLevel int32
+
+const (
+	// NormalDurability syncs the write-ahead log at checkpoints only, the last committed writes
+	// may be lost on a power failure of the miner but the database is never corrupted. It's the
+	// default.
+	NormalDurability DurabilityLevel = iota
+	// StrictDurability syncs the write-ahead log at every commit.
+	StrictDurability
+	// RelaxedDurability leaves syncing to the operating system, the database may be corrupted on
+	// a power failure of the miner and must be recovered from the peers.
+	RelaxedDurability
+	// NumberOfDurabilityLevel defines the number of durability levels.
+	NumberOfDurabilityLevel
+)
+
+var durabilityLevelNames = [...]string{"normal", "strict", "relaxed"}
+
+// String implements fmt.Stringer.String.
+func (l DurabilityLevel) String() string {
+	if l.Valid() {
+		return durabilityLevelNames[l]
+	}
+	return fmt.Sprintf("Unknown DurabilityLevel (%d)", int32(l))
+}
+
+// Valid returns whether the durability level is known.
+func (l DurabilityLevel) Valid() bool {
+	return l >= 0 && l < NumberOfDurabilityLevel
+}
+
+// ParseDurabilityLevel returns the durability level of name: strict, normal or relaxed, an empty
+// name is the default level.
+func ParseDurabilityLevel(name string) (l DurabilityLevel, err error) {
+	if name == "" {
+		return NormalDurability, nil
+	}
+	for i, v := range durabilityLevelNames {
+		if strings.EqualFold(name, v) {
+			return DurabilityLevel(i), nil
+		}
+	}
+	err = errors.Wrapf(ErrInvalidDurabilityLevel, "unknown durability level %s", name)
+	return
 }
 
 // ServiceInstance defines single instance to be initialized.
@@ -57,7 +141,7 @@ func (sh *SignedInitServiceResponseHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedInitServiceResponseHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedInitServiceResponseHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.InitServiceResponseHeader, signer)
 }
 
@@ -67,7 +151,7 @@ func (rs *InitServiceResponse) Verify() error {
 }
 
 // Sign the request.
-func (rs *InitServiceResponse) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (rs *InitServiceResponse) Sign(signer asymmetric.Signer) (err error) {
 	// sign
 	return rs.Header.Sign(signer)
 }
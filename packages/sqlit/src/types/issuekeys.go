@@ -46,7 +46,7 @@ func NewIssueKeys(header *IssueKeysHeader) *IssueKeys {
 }
 
 // Sign implements interfaces/Transaction.Sign.
-func (ik *IssueKeys) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (ik *IssueKeys) Sign(signer asymmetric.Signer) (err error) {
 	return ik.DefaultHashSignVerifierImpl.Sign(&ik.IssueKeysHeader, signer)
 }
 
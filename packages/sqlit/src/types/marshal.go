@@ -1,58 +1,102 @@
 package types
 
 import (
+	"sqlit/src/crypto/hash"
 	"sqlit/src/marshalhash"
 )
 
 // MarshalHash implementations for types that need verifier.MarshalHasher interface
 // These use msgpack encoding for compatibility with the original HashStablePack format.
+//
+// Each MarshalHash allocates the buffer once with the size returned by Msgsize, which is an upper
+// bound of the encoded size, and nested types of this package are appended to the same buffer by
+// their appendHash methods.
+
+// hashSize is the encoded size of a hash.Hash or proto.AccountAddress.
+var hashSize = marshalhash.BytesSize(hash.HashSize)
+
+// appendHasher appends the hash encoding of a type of other packages to b.
+func appendHasher(b []byte, mh interface{ MarshalHash() ([]byte, error) }) ([]byte, error) {
+	enc, err := mh.MarshalHash()
+	if err != nil {
+		return nil, err
+	}
+	return append(b, enc...), nil
+}
 
 // MarshalHash marshals AckHeader for hash computation
 func (h *AckHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *AckHeader) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 4)
 	// Response
-	respBytes, err := h.Response.MarshalHash()
-	if err != nil {
+	if b, err = h.Response.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, respBytes...)
 	b = marshalhash.AppendBytes(b, h.ResponseHash[:])
 	b = marshalhash.AppendString(b, string(h.NodeID))
 	b = marshalhash.AppendTime(b, h.Timestamp)
 	return b, nil
 }
-func (h *AckHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of AckHeader.
+func (h *AckHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + h.Response.Msgsize() + hashSize +
+		marshalhash.StringSize(string(h.NodeID)) + marshalhash.TimeSize
+}
 
 // MarshalHash marshals BaseAccount for hash computation
 func (a *BaseAccount) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 128)
+	b := make([]byte, 0, a.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 3)
 	b = marshalhash.AppendBytes(b, a.Address[:])
 	b = marshalhash.AppendFloat64(b, a.Rating)
 	b = marshalhash.AppendUint64(b, uint64(a.NextNonce))
 	return b, nil
 }
-func (a *BaseAccount) Msgsize() int { return 128 }
+
+// Msgsize returns an upper bound of the hash encoding size of BaseAccount.
+func (a *BaseAccount) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + hashSize + marshalhash.Float64Size + marshalhash.Uint64Size
+}
 
 // MarshalHash marshals Header for hash computation
 func (h *Header) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
-	// Encode as array with 6 elements matching struct field order
-	b = marshalhash.AppendArrayHeader(b, 6)
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *Header) appendHash(b []byte) ([]byte, error) {
+	ext, err := h.extCount()
+	if err != nil {
+		return nil, err
+	}
+	// Encode as array with 6 elements matching struct field order, and the extension fields
+	b = marshalhash.AppendArrayHeader(b, 6+ext)
 	b = marshalhash.AppendInt32(b, h.Version)
 	b = marshalhash.AppendString(b, string(h.Producer))
 	b = marshalhash.AppendBytes(b, h.GenesisHash[:])
 	b = marshalhash.AppendBytes(b, h.ParentHash[:])
 	b = marshalhash.AppendBytes(b, h.MerkleRoot[:])
 	b = marshalhash.AppendTime(b, h.Timestamp)
-	return b, nil
+	return h.HeaderExt.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of Header.
+func (h *Header) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.Int32Size +
+		marshalhash.StringSize(string(h.Producer)) + 3*hashSize + marshalhash.TimeSize +
+		h.HeaderExt.msgsize()
 }
-func (h *Header) Msgsize() int { return 256 }
 
 // MarshalHash marshals BPHeader for hash computation
 func (h *BPHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *BPHeader) appendHash(b []byte) ([]byte, error) {
 	b = marshalhash.AppendArrayHeader(b, 5)
 	b = marshalhash.AppendInt32(b, h.Version)
 	b = marshalhash.AppendBytes(b, h.Producer[:])
@@ -61,28 +105,59 @@ func (h *BPHeader) MarshalHash() ([]byte, error) {
 	b = marshalhash.AppendTime(b, h.Timestamp)
 	return b, nil
 }
-func (h *BPHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of BPHeader.
+func (h *BPHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.Int32Size + 3*hashSize + marshalhash.TimeSize
+}
 
 // MarshalHash marshals CreateDatabaseHeader for hash computation
 func (h *CreateDatabaseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	var (
+		b   = make([]byte, 0, h.Msgsize())
+		err error
+	)
 	b = marshalhash.AppendArrayHeader(b, 3)
 	b = marshalhash.AppendBytes(b, h.Owner[:])
 	// ResourceMeta
-	rmBytes, err := h.ResourceMeta.MarshalHash()
-	if err != nil {
+	if b, err = h.ResourceMeta.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, rmBytes...)
 	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
 	return b, nil
 }
-func (h *CreateDatabaseHeader) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of CreateDatabaseHeader.
+func (h *CreateDatabaseHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + hashSize + h.ResourceMeta.Msgsize() + marshalhash.Uint64Size
+}
 
 // MarshalHash marshals ResourceMeta for hash computation
 func (rm *ResourceMeta) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
-	b = marshalhash.AppendArrayHeader(b, 9)
+	return rm.appendHash(make([]byte, 0, rm.Msgsize()))
+}
+
+func (rm *ResourceMeta) appendHash(b []byte) ([]byte, error) {
+	// the pool settings, the clone source, the placement and the durability are appended only if
+	// set to keep the hash of existing resource metas
+	var (
+		withDurability = rm.Durability != NormalDurability
+		withPlacement  = withDurability || !rm.Placement.IsZero()
+		withSource     = withPlacement || rm.Source != ""
+		withPool       = withSource || !rm.Pool.IsZero()
+	)
+	switch {
+	case withDurability:
+		b = marshalhash.AppendArrayHeader(b, 13)
+	case withPlacement:
+		b = marshalhash.AppendArrayHeader(b, 12)
+	case withSource:
+		b = marshalhash.AppendArrayHeader(b, 11)
+	case withPool:
+		b = marshalhash.AppendArrayHeader(b, 10)
+	default:
+		b = marshalhash.AppendArrayHeader(b, 9)
+	}
 	// TargetMiners - array of AccountAddress
 	b = marshalhash.AppendArrayHeader(b, uint32(len(rm.TargetMiners)))
 	for _, addr := range rm.TargetMiners {
@@ -96,137 +171,217 @@ func (rm *ResourceMeta) MarshalHash() ([]byte, error) {
 	b = marshalhash.AppendBool(b, rm.UseEventualConsistency)
 	b = marshalhash.AppendFloat64(b, rm.ConsistencyLevel)
 	b = marshalhash.AppendInt(b, rm.IsolationLevel)
+	if withPool {
+		b = marshalhash.AppendArrayHeader(b, 4)
+		b = marshalhash.AppendInt(b, rm.Pool.MaxReaders)
+		b = marshalhash.AppendInt64(b, rm.Pool.BusyTimeout)
+		b = marshalhash.AppendInt(b, rm.Pool.CacheSize)
+		b = marshalhash.AppendInt64(b, rm.Pool.MMapSize)
+	}
+	if withSource {
+		b = marshalhash.AppendString(b, string(rm.Source))
+	}
+	if withPlacement {
+		b = marshalhash.AppendArrayHeader(b, 3)
+		b = marshalhash.AppendUint(b, uint64(rm.Placement.MinRegions))
+		b = marshalhash.AppendUint(b, uint64(rm.Placement.MinZones))
+		b = marshalhash.AppendArrayHeader(b, uint32(len(rm.Placement.Regions)))
+		for _, r := range rm.Placement.Regions {
+			b = marshalhash.AppendString(b, r)
+		}
+	}
+	if withDurability {
+		b = marshalhash.AppendInt(b, int(rm.Durability))
+	}
 	return b, nil
 }
-func (rm *ResourceMeta) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of ResourceMeta.
+func (rm *ResourceMeta) Msgsize() int {
+	return 2*marshalhash.ArrayHeaderSize + len(rm.TargetMiners)*hashSize +
+		marshalhash.Uint16Size + 2*marshalhash.Uint64Size + 2*marshalhash.Float64Size +
+		marshalhash.StringSize(rm.EncryptionKey) + marshalhash.BoolSize + marshalhash.IntSize +
+		marshalhash.ArrayHeaderSize + 4*marshalhash.Int64Size +
+		marshalhash.StringSize(string(rm.Source)) + rm.Placement.msgsize() + marshalhash.IntSize
+}
+
+func (p *PlacementMeta) msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + 2*marshalhash.Uint16Size
+	for _, r := range p.Regions {
+		s += marshalhash.StringSize(r)
+	}
+	return
+}
 
 // MarshalHash marshals CreateDatabase for hash computation
 func (h *CreateDatabase) MarshalHash() ([]byte, error) {
 	return h.CreateDatabaseHeader.MarshalHash()
 }
-func (h *CreateDatabase) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of CreateDatabase.
+func (h *CreateDatabase) Msgsize() int { return h.CreateDatabaseHeader.Msgsize() }
 
 // MarshalHash marshals CreateDatabaseRequestHeader for hash computation
 func (h *CreateDatabaseRequestHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 1)
 	// ResourceMeta
-	rmBytes, err := h.ResourceMeta.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, rmBytes...)
-	return b, nil
+	return h.ResourceMeta.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of CreateDatabaseRequestHeader.
+func (h *CreateDatabaseRequestHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + h.ResourceMeta.Msgsize()
 }
-func (h *CreateDatabaseRequestHeader) Msgsize() int { return 256 }
 
 // MarshalHash marshals CreateDatabaseResponseHeader for hash computation
 func (h *CreateDatabaseResponseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 1)
 	// InstanceMeta
-	instBytes, err := h.InstanceMeta.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, instBytes...)
-	return b, nil
+	return h.InstanceMeta.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of CreateDatabaseResponseHeader.
+func (h *CreateDatabaseResponseHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + h.InstanceMeta.Msgsize()
 }
-func (h *CreateDatabaseResponseHeader) Msgsize() int { return 256 }
 
 // MarshalHash marshals DropDatabaseRequestHeader for hash computation
 func (h *DropDatabaseRequestHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 1)
 	b = marshalhash.AppendString(b, string(h.DatabaseID))
 	return b, nil
 }
-func (h *DropDatabaseRequestHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of DropDatabaseRequestHeader.
+func (h *DropDatabaseRequestHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.StringSize(string(h.DatabaseID))
+}
 
 // MarshalHash marshals GetDatabaseRequestHeader for hash computation
 func (h *GetDatabaseRequestHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 1)
 	b = marshalhash.AppendString(b, string(h.DatabaseID))
 	return b, nil
 }
-func (h *GetDatabaseRequestHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of GetDatabaseRequestHeader.
+func (h *GetDatabaseRequestHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.StringSize(string(h.DatabaseID))
+}
 
 // MarshalHash marshals GetDatabaseResponseHeader for hash computation
 func (h *GetDatabaseResponseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 1)
 	// InstanceMeta
-	instBytes, err := h.InstanceMeta.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, instBytes...)
-	return b, nil
+	return h.InstanceMeta.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of GetDatabaseResponseHeader.
+func (h *GetDatabaseResponseHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + h.InstanceMeta.Msgsize()
 }
-func (h *GetDatabaseResponseHeader) Msgsize() int { return 256 }
 
 // MarshalHash marshals InitServiceResponseHeader for hash computation
 func (h *InitServiceResponseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	var (
+		b   = make([]byte, 0, h.Msgsize())
+		err error
+	)
 	// Instances array
 	b = marshalhash.AppendArrayHeader(b, uint32(len(h.Instances)))
-	for _, inst := range h.Instances {
-		instBytes, err := inst.MarshalHash()
-		if err != nil {
+	for i := range h.Instances {
+		if b, err = h.Instances[i].appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, instBytes...)
 	}
 	return b, nil
 }
-func (h *InitServiceResponseHeader) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of InitServiceResponseHeader.
+func (h *InitServiceResponseHeader) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize
+	for i := range h.Instances {
+		s += h.Instances[i].Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals IssueKeys for hash computation
 func (h *IssueKeys) MarshalHash() ([]byte, error) {
 	return h.IssueKeysHeader.MarshalHash()
 }
-func (h *IssueKeys) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of IssueKeys.
+func (h *IssueKeys) Msgsize() int { return h.IssueKeysHeader.Msgsize() }
 
 // MarshalHash marshals IssueKeysHeader for hash computation
 func (h *IssueKeysHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	var (
+		b   = make([]byte, 0, h.Msgsize())
+		err error
+	)
 	b = marshalhash.AppendArrayHeader(b, 3)
 	b = marshalhash.AppendBytes(b, h.TargetSQLChain[:])
 	// MinerKeys array
 	b = marshalhash.AppendArrayHeader(b, uint32(len(h.MinerKeys)))
-	for _, mk := range h.MinerKeys {
-		mkBytes, err := mk.MarshalHash()
-		if err != nil {
+	for i := range h.MinerKeys {
+		if b, err = h.MinerKeys[i].appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, mkBytes...)
 	}
 	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
 	return b, nil
 }
-func (h *IssueKeysHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of IssueKeysHeader.
+func (h *IssueKeysHeader) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + hashSize + marshalhash.Uint64Size
+	for i := range h.MinerKeys {
+		s += h.MinerKeys[i].Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals MinerKey for hash computation
 func (mk *MinerKey) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 128)
+	return mk.appendHash(make([]byte, 0, mk.Msgsize()))
+}
+
+func (mk *MinerKey) appendHash(b []byte) ([]byte, error) {
 	b = marshalhash.AppendArrayHeader(b, 2)
 	b = marshalhash.AppendBytes(b, mk.Miner[:])
 	b = marshalhash.AppendString(b, mk.EncryptionKey)
 	return b, nil
 }
-func (mk *MinerKey) Msgsize() int { return 128 }
+
+// Msgsize returns an upper bound of the hash encoding size of MinerKey.
+func (mk *MinerKey) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + hashSize + marshalhash.StringSize(mk.EncryptionKey)
+}
 
 // MarshalHash marshals ProvideService for hash computation
 func (h *ProvideService) MarshalHash() ([]byte, error) {
 	return h.ProvideServiceHeader.MarshalHash()
 }
-func (h *ProvideService) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of ProvideService.
+func (h *ProvideService) Msgsize() int { return h.ProvideServiceHeader.Msgsize() }
 
 // MarshalHash marshals ProvideServiceHeader for hash computation
 func (h *ProvideServiceHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
-	b = marshalhash.AppendArrayHeader(b, 6)
+	// the location labels are appended only if set to keep the hash of existing transactions
+	var withLabels = h.Region != "" || h.Zone != ""
+	b := make([]byte, 0, h.Msgsize())
+	if withLabels {
+		b = marshalhash.AppendArrayHeader(b, 8)
+	} else {
+		b = marshalhash.AppendArrayHeader(b, 6)
+	}
 	b = marshalhash.AppendUint64(b, h.Space)
 	b = marshalhash.AppendUint64(b, h.Memory)
 	b = marshalhash.AppendFloat64(b, h.LoadAvgPerCPU)
@@ -237,81 +392,184 @@ func (h *ProvideServiceHeader) MarshalHash() ([]byte, error) {
 	}
 	b = marshalhash.AppendString(b, string(h.NodeID))
 	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
+	if withLabels {
+		b = marshalhash.AppendString(b, h.Region)
+		b = marshalhash.AppendString(b, h.Zone)
+	}
+	return b, nil
+}
+
+// Msgsize returns an upper bound of the hash encoding size of ProvideServiceHeader.
+func (h *ProvideServiceHeader) Msgsize() int {
+	return 2*marshalhash.ArrayHeaderSize + 3*marshalhash.Uint64Size + marshalhash.Float64Size +
+		len(h.TargetUser)*hashSize + marshalhash.StringSize(string(h.NodeID)) +
+		marshalhash.StringSize(h.Region) + marshalhash.StringSize(h.Zone)
+}
+
+// MarshalHash marshals ReplaceMiner for hash computation
+func (h *ReplaceMiner) MarshalHash() ([]byte, error) {
+	return h.ReplaceMinerHeader.MarshalHash()
+}
+
+// Msgsize returns an upper bound of the hash encoding size of ReplaceMiner.
+func (h *ReplaceMiner) Msgsize() int { return h.ReplaceMinerHeader.Msgsize() }
+
+// MarshalHash marshals ReplaceMinerHeader for hash computation
+func (h *ReplaceMinerHeader) MarshalHash() ([]byte, error) {
+	b := make([]byte, 0, h.Msgsize())
+	b = marshalhash.AppendArrayHeader(b, 4)
+	b = marshalhash.AppendBytes(b, h.TargetSQLChain[:])
+	b = marshalhash.AppendBytes(b, h.Miner[:])
+	b = marshalhash.AppendBytes(b, h.Standby[:])
+	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
+	return b, nil
+}
+
+// Msgsize returns an upper bound of the hash encoding size of ReplaceMinerHeader.
+func (h *ReplaceMinerHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + 3*hashSize + marshalhash.Uint64Size
+}
+
+// MarshalHash marshals ExpandDatabase for hash computation
+func (h *ExpandDatabase) MarshalHash() ([]byte, error) {
+	return h.ExpandDatabaseHeader.MarshalHash()
+}
+
+// Msgsize returns an upper bound of the hash encoding size of ExpandDatabase.
+func (h *ExpandDatabase) Msgsize() int { return h.ExpandDatabaseHeader.Msgsize() }
+
+// MarshalHash marshals ExpandDatabaseHeader for hash computation
+func (h *ExpandDatabaseHeader) MarshalHash() ([]byte, error) {
+	b := make([]byte, 0, h.Msgsize())
+	b = marshalhash.AppendArrayHeader(b, 4)
+	b = marshalhash.AppendBytes(b, h.TargetSQLChain[:])
+	b = marshalhash.AppendUint(b, uint64(h.Node))
+	b = marshalhash.AppendArrayHeader(b, uint32(len(h.TargetMiners)))
+	for _, addr := range h.TargetMiners {
+		b = marshalhash.AppendBytes(b, addr[:])
+	}
+	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
 	return b, nil
 }
-func (h *ProvideServiceHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of ExpandDatabaseHeader.
+func (h *ExpandDatabaseHeader) Msgsize() int {
+	return 2*marshalhash.ArrayHeaderSize + (1+len(h.TargetMiners))*hashSize +
+		marshalhash.Uint16Size + marshalhash.Uint64Size
+}
 
 // MarshalHash marshals RequestHeader for hash computation
 func (h *RequestHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
-	b = marshalhash.AppendArrayHeader(b, 5)
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *RequestHeader) appendHash(b []byte) ([]byte, error) {
+	ext, err := h.extCount()
+	if err != nil {
+		return nil, err
+	}
+	b = marshalhash.AppendArrayHeader(b, 5+ext)
 	b = marshalhash.AppendInt32(b, int32(h.QueryType))
 	b = marshalhash.AppendString(b, string(h.NodeID))
 	b = marshalhash.AppendString(b, string(h.DatabaseID))
 	b = marshalhash.AppendUint64(b, h.ConnectionID)
 	b = marshalhash.AppendUint64(b, h.SeqNo)
-	return b, nil
+	return h.HeaderExt.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of RequestHeader.
+func (h *RequestHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.Int32Size +
+		marshalhash.StringSize(string(h.NodeID)) + marshalhash.StringSize(string(h.DatabaseID)) +
+		2*marshalhash.Uint64Size + h.HeaderExt.msgsize()
 }
-func (h *RequestHeader) Msgsize() int { return 256 }
 
 // MarshalHash marshals RequestPayload for hash computation
 func (h *RequestPayload) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 1024)
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *RequestPayload) appendHash(b []byte) ([]byte, error) {
+	var err error
 	// Queries as array
 	b = marshalhash.AppendArrayHeader(b, uint32(len(h.Queries)))
-	for _, q := range h.Queries {
-		qb, err := q.MarshalHash()
-		if err != nil {
+	for i := range h.Queries {
+		if b, err = h.Queries[i].appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, qb...)
 	}
 	return b, nil
 }
-func (h *RequestPayload) Msgsize() int { return 1024 }
+
+// Msgsize returns an upper bound of the hash encoding size of RequestPayload.
+func (h *RequestPayload) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize
+	for i := range h.Queries {
+		s += h.Queries[i].Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals Query for hash computation
 func (q *Query) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	return q.appendHash(make([]byte, 0, q.Msgsize()))
+}
+
+func (q *Query) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	b = marshalhash.AppendString(b, q.Pattern)
 	// Args
 	b = marshalhash.AppendArrayHeader(b, uint32(len(q.Args)))
-	for _, arg := range q.Args {
-		ab, err := arg.MarshalHash()
-		if err != nil {
+	for i := range q.Args {
+		if b, err = q.Args[i].appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, ab...)
 	}
 	return b, nil
 }
-func (q *Query) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of Query.
+func (q *Query) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + marshalhash.StringSize(q.Pattern)
+	for i := range q.Args {
+		s += q.Args[i].Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals NamedArg for hash computation
 func (na *NamedArg) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 64)
+	return na.appendHash(make([]byte, 0, na.Msgsize()))
+}
+
+func (na *NamedArg) appendHash(b []byte) ([]byte, error) {
 	b = marshalhash.AppendArrayHeader(b, 2)
 	b = marshalhash.AppendString(b, na.Name)
-	vb, err := marshalhash.AppendIntf(nil, na.Value)
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, vb...)
-	return b, nil
+	return marshalhash.AppendIntf(b, na.Value)
+}
+
+// Msgsize returns an estimation of the hash encoding size of NamedArg.
+func (na *NamedArg) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.StringSize(na.Name) +
+		marshalhash.GuessSize(na.Value)
 }
-func (na *NamedArg) Msgsize() int { return 64 }
 
 // MarshalHash marshals ResponseHeader for hash computation
 func (h *ResponseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
-	b = marshalhash.AppendArrayHeader(b, 10)
-	// Request header
-	reqBytes, err := h.Request.MarshalHash()
+	return h.appendHash(make([]byte, 0, h.Msgsize()))
+}
+
+func (h *ResponseHeader) appendHash(b []byte) ([]byte, error) {
+	ext, err := h.extCount()
 	if err != nil {
 		return nil, err
 	}
-	b = append(b, reqBytes...)
+	b = marshalhash.AppendArrayHeader(b, 10+ext)
+	// Request header
+	if b, err = h.Request.appendHash(b); err != nil {
+		return nil, err
+	}
 	b = marshalhash.AppendBytes(b, h.RequestHash[:])
 	b = marshalhash.AppendString(b, string(h.NodeID))
 	b = marshalhash.AppendTime(b, h.Timestamp)
@@ -321,13 +579,22 @@ func (h *ResponseHeader) MarshalHash() ([]byte, error) {
 	b = marshalhash.AppendInt64(b, h.AffectedRows)
 	b = marshalhash.AppendBytes(b, h.PayloadHash[:])
 	b = marshalhash.AppendBytes(b, h.ResponseAccount[:])
-	return b, nil
+	return h.HeaderExt.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of ResponseHeader.
+func (h *ResponseHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + h.Request.Msgsize() + 3*hashSize +
+		marshalhash.StringSize(string(h.NodeID)) + marshalhash.TimeSize +
+		2*marshalhash.Uint64Size + 2*marshalhash.Int64Size + h.HeaderExt.msgsize()
 }
-func (h *ResponseHeader) Msgsize() int { return 512 }
 
 // MarshalHash marshals ResponsePayload for hash computation
 func (h *ResponsePayload) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 1024)
+	var (
+		b   = make([]byte, 0, h.Msgsize())
+		err error
+	)
 	b = marshalhash.AppendArrayHeader(b, 3)
 	// Columns as array of strings
 	b = marshalhash.AppendArrayHeader(b, uint32(len(h.Columns)))
@@ -342,60 +609,98 @@ func (h *ResponsePayload) MarshalHash() ([]byte, error) {
 	// Rows as array of arrays
 	b = marshalhash.AppendArrayHeader(b, uint32(len(h.Rows)))
 	for _, row := range h.Rows {
-		rb, err := row.MarshalHash()
-		if err != nil {
+		if b, err = row.appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, rb...)
 	}
 	return b, nil
 }
-func (h *ResponsePayload) Msgsize() int { return 1024 }
+
+// Msgsize returns an estimation of the hash encoding size of ResponsePayload.
+func (h *ResponsePayload) Msgsize() (s int) {
+	s = 4 * marshalhash.ArrayHeaderSize
+	for _, c := range h.Columns {
+		s += marshalhash.StringSize(c)
+	}
+	for _, d := range h.DeclTypes {
+		s += marshalhash.StringSize(d)
+	}
+	for _, row := range h.Rows {
+		s += row.Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals ResponseRow for hash computation
 func (r ResponseRow) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	return r.appendHash(make([]byte, 0, r.Msgsize()))
+}
+
+func (r ResponseRow) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, uint32(len(r.Values)))
 	for _, v := range r.Values {
-		vb, err := marshalhash.AppendIntf(nil, v)
-		if err != nil {
+		if b, err = marshalhash.AppendIntf(b, v); err != nil {
 			return nil, err
 		}
-		b = append(b, vb...)
 	}
 	return b, nil
 }
-func (r ResponseRow) Msgsize() int { return 256 }
+
+// Msgsize returns an estimation of the hash encoding size of ResponseRow.
+func (r ResponseRow) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize
+	for _, v := range r.Values {
+		s += marshalhash.GuessSize(v)
+	}
+	return
+}
 
 // MarshalHash marshals UpdatePermission for hash computation
 func (h *UpdatePermission) MarshalHash() ([]byte, error) {
 	return h.UpdatePermissionHeader.MarshalHash()
 }
-func (h *UpdatePermission) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of UpdatePermission.
+func (h *UpdatePermission) Msgsize() int { return h.UpdatePermissionHeader.Msgsize() }
 
 // MarshalHash marshals UpdatePermissionHeader for hash computation
 func (h *UpdatePermissionHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	var (
+		b   = make([]byte, 0, h.Msgsize())
+		err error
+	)
 	b = marshalhash.AppendArrayHeader(b, 4)
 	b = marshalhash.AppendBytes(b, h.TargetSQLChain[:])
 	b = marshalhash.AppendBytes(b, h.TargetUser[:])
 	if h.Permission != nil {
-		permBytes, err := h.Permission.MarshalHash()
-		if err != nil {
+		if b, err = h.Permission.appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, permBytes...)
 	} else {
 		b = marshalhash.AppendNil(b)
 	}
 	b = marshalhash.AppendUint64(b, uint64(h.Nonce))
 	return b, nil
 }
-func (h *UpdatePermissionHeader) Msgsize() int { return 256 }
+
+// Msgsize returns an upper bound of the hash encoding size of UpdatePermissionHeader.
+func (h *UpdatePermissionHeader) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize + 2*hashSize + marshalhash.Uint64Size
+	if h.Permission != nil {
+		s += h.Permission.Msgsize()
+	} else {
+		s += marshalhash.NilSize
+	}
+	return
+}
 
 // MarshalHash marshals UserPermission for hash computation
 func (up *UserPermission) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 128)
+	return up.appendHash(make([]byte, 0, up.Msgsize()))
+}
+
+func (up *UserPermission) appendHash(b []byte) ([]byte, error) {
 	b = marshalhash.AppendArrayHeader(b, 2)
 	b = marshalhash.AppendInt32(b, int32(up.Role))
 	b = marshalhash.AppendArrayHeader(b, uint32(len(up.Patterns)))
@@ -404,238 +709,327 @@ func (up *UserPermission) MarshalHash() ([]byte, error) {
 	}
 	return b, nil
 }
-func (up *UserPermission) Msgsize() int { return 128 }
+
+// Msgsize returns an upper bound of the hash encoding size of UserPermission.
+func (up *UserPermission) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + marshalhash.Int32Size
+	for _, p := range up.Patterns {
+		s += marshalhash.StringSize(p)
+	}
+	return
+}
 
 // MarshalHash marshals UpdateServiceHeader for hash computation
 func (h *UpdateServiceHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 256)
+	b := make([]byte, 0, h.Msgsize())
 	b = marshalhash.AppendArrayHeader(b, 2)
 	b = marshalhash.AppendInt(b, int(h.Op))
 	// Instance
-	instBytes, err := h.Instance.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, instBytes...)
-	return b, nil
+	return h.Instance.appendHash(b)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of UpdateServiceHeader.
+func (h *UpdateServiceHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + marshalhash.IntSize + h.Instance.Msgsize()
 }
-func (h *UpdateServiceHeader) Msgsize() int { return 256 }
 
 // MarshalHash marshals ServiceInstance for hash computation
 func (si *ServiceInstance) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return si.appendHash(make([]byte, 0, si.Msgsize()))
+}
+
+func (si *ServiceInstance) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 4)
 	b = marshalhash.AppendString(b, string(si.DatabaseID))
 	// Peers
 	if si.Peers != nil {
-		peersBytes, err := si.Peers.MarshalHash()
-		if err != nil {
+		if b, err = appendHasher(b, si.Peers); err != nil {
 			return nil, err
 		}
-		b = append(b, peersBytes...)
 	} else {
 		b = marshalhash.AppendNil(b)
 	}
 	// ResourceMeta
-	rmBytes, err := si.ResourceMeta.MarshalHash()
-	if err != nil {
+	if b, err = si.ResourceMeta.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, rmBytes...)
 	// GenesisBlock
 	if si.GenesisBlock != nil {
-		gbBytes, err := si.GenesisBlock.MarshalHash()
-		if err != nil {
+		if b, err = si.GenesisBlock.appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, gbBytes...)
 	} else {
 		b = marshalhash.AppendNil(b)
 	}
 	return b, nil
 }
-func (si *ServiceInstance) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of ServiceInstance.
+func (si *ServiceInstance) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize + marshalhash.StringSize(string(si.DatabaseID)) +
+		si.ResourceMeta.Msgsize()
+	if si.Peers != nil {
+		s += si.Peers.Msgsize()
+	} else {
+		s += marshalhash.NilSize
+	}
+	if si.GenesisBlock != nil {
+		s += si.GenesisBlock.Msgsize()
+	} else {
+		s += marshalhash.NilSize
+	}
+	return
+}
 
 // MarshalHash marshals Block for hash computation
 func (b *Block) MarshalHash() ([]byte, error) {
-	buf := make([]byte, 0, 1024)
+	return b.appendHash(make([]byte, 0, b.Msgsize()))
+}
+
+func (b *Block) appendHash(buf []byte) ([]byte, error) {
+	var err error
 	buf = marshalhash.AppendArrayHeader(buf, 2)
 	// SignedHeader
-	shBytes, err := b.SignedHeader.MarshalHash()
-	if err != nil {
+	if buf, err = b.SignedHeader.appendHash(buf); err != nil {
 		return nil, err
 	}
-	buf = append(buf, shBytes...)
 	// QueryTxs
 	buf = marshalhash.AppendArrayHeader(buf, uint32(len(b.QueryTxs)))
 	for _, qtx := range b.QueryTxs {
-		qtxBytes, err := qtx.MarshalHash()
-		if err != nil {
+		if qtx == nil {
+			buf = marshalhash.AppendNil(buf)
+			continue
+		}
+		if buf, err = qtx.appendHash(buf); err != nil {
 			return nil, err
 		}
-		buf = append(buf, qtxBytes...)
 	}
 	return buf, nil
 }
-func (b *Block) Msgsize() int { return 1024 }
+
+// Msgsize returns an estimation of the hash encoding size of Block.
+func (b *Block) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + b.SignedHeader.Msgsize()
+	for _, qtx := range b.QueryTxs {
+		if qtx == nil {
+			s += marshalhash.NilSize
+			continue
+		}
+		s += qtx.Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals SignedHeader for hash computation
 func (sh *SignedHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return sh.appendHash(make([]byte, 0, sh.Msgsize()))
+}
+
+func (sh *SignedHeader) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Embedded Header
-	hdrBytes, err := sh.Header.MarshalHash()
-	if err != nil {
+	if b, err = sh.Header.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, hdrBytes...)
 	// HSV (hash signature verifier)
-	hsvBytes, err := sh.HSV.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, hsvBytes...)
-	return b, nil
+	return appendHasher(b, &sh.HSV)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of SignedHeader.
+func (sh *SignedHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + sh.Header.Msgsize() + sh.HSV.Msgsize()
 }
-func (sh *SignedHeader) Msgsize() int { return 512 }
 
 // MarshalHash marshals QueryAsTx for hash computation
 func (qtx *QueryAsTx) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return qtx.appendHash(make([]byte, 0, qtx.Msgsize()))
+}
+
+func (qtx *QueryAsTx) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Request
 	if qtx.Request != nil {
-		reqBytes, err := qtx.Request.MarshalHash()
-		if err != nil {
+		if b, err = qtx.Request.appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, reqBytes...)
 	} else {
 		b = marshalhash.AppendNil(b)
 	}
 	// Response
 	if qtx.Response != nil {
-		respBytes, err := qtx.Response.MarshalHash()
-		if err != nil {
+		if b, err = qtx.Response.appendHash(b); err != nil {
 			return nil, err
 		}
-		b = append(b, respBytes...)
 	} else {
 		b = marshalhash.AppendNil(b)
 	}
 	return b, nil
 }
-func (qtx *QueryAsTx) Msgsize() int { return 512 }
+
+// Msgsize returns an estimation of the hash encoding size of QueryAsTx.
+func (qtx *QueryAsTx) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize
+	if qtx.Request != nil {
+		s += qtx.Request.Msgsize()
+	} else {
+		s += marshalhash.NilSize
+	}
+	if qtx.Response != nil {
+		s += qtx.Response.Msgsize()
+	} else {
+		s += marshalhash.NilSize
+	}
+	return
+}
 
 // MarshalHash marshals Blocks for hash computation
 func (b Blocks) MarshalHash() ([]byte, error) {
-	buf := make([]byte, 0, 2048)
+	var (
+		buf = make([]byte, 0, b.Msgsize())
+		err error
+	)
 	buf = marshalhash.AppendArrayHeader(buf, uint32(len(b)))
 	for _, blk := range b {
-		blkBytes, err := blk.MarshalHash()
-		if err != nil {
+		if blk == nil {
+			buf = marshalhash.AppendNil(buf)
+			continue
+		}
+		if buf, err = blk.appendHash(buf); err != nil {
 			return nil, err
 		}
-		buf = append(buf, blkBytes...)
 	}
 	return buf, nil
 }
 
+// Msgsize returns an estimation of the hash encoding size of Blocks.
+func (b Blocks) Msgsize() (s int) {
+	s = marshalhash.ArrayHeaderSize
+	for _, blk := range b {
+		if blk == nil {
+			s += marshalhash.NilSize
+			continue
+		}
+		s += blk.Msgsize()
+	}
+	return
+}
+
 // MarshalHash marshals BPBlock for hash computation
 func (b *BPBlock) MarshalHash() ([]byte, error) {
-	buf := make([]byte, 0, 1024)
+	var (
+		buf = make([]byte, 0, b.Msgsize())
+		err error
+	)
 	buf = marshalhash.AppendArrayHeader(buf, 2)
 	// BPSignedHeader
-	shBytes, err := b.SignedHeader.MarshalHash()
-	if err != nil {
+	if buf, err = b.SignedHeader.appendHash(buf); err != nil {
 		return nil, err
 	}
-	buf = append(buf, shBytes...)
 	// Transactions
 	buf = marshalhash.AppendArrayHeader(buf, uint32(len(b.Transactions)))
 	for _, tx := range b.Transactions {
-		txBytes, err := tx.MarshalHash()
-		if err != nil {
+		if buf, err = appendHasher(buf, tx); err != nil {
 			return nil, err
 		}
-		buf = append(buf, txBytes...)
 	}
 	return buf, nil
 }
-func (b *BPBlock) Msgsize() int { return 1024 }
+
+// Msgsize returns an upper bound of the hash encoding size of BPBlock.
+func (b *BPBlock) Msgsize() (s int) {
+	s = 2*marshalhash.ArrayHeaderSize + b.SignedHeader.Msgsize()
+	for _, tx := range b.Transactions {
+		s += tx.Msgsize()
+	}
+	return
+}
 
 // MarshalHash marshals BPSignedHeader for hash computation
 func (sbh *BPSignedHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return sbh.appendHash(make([]byte, 0, sbh.Msgsize()))
+}
+
+func (sbh *BPSignedHeader) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Embedded BPHeader
-	hdrBytes, err := sbh.BPHeader.MarshalHash()
-	if err != nil {
+	if b, err = sbh.BPHeader.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, hdrBytes...)
 	// DefaultHashSignVerifierImpl
-	hsvBytes, err := sbh.DefaultHashSignVerifierImpl.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, hsvBytes...)
-	return b, nil
+	return appendHasher(b, &sbh.DefaultHashSignVerifierImpl)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of BPSignedHeader.
+func (sbh *BPSignedHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + sbh.BPHeader.Msgsize() +
+		sbh.DefaultHashSignVerifierImpl.Msgsize()
 }
-func (sbh *BPSignedHeader) Msgsize() int { return 512 }
 
 // MarshalHash marshals Request for hash computation
 func (r *Request) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 1024)
+	return r.appendHash(make([]byte, 0, r.Msgsize()))
+}
+
+func (r *Request) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Header
-	hdrBytes, err := r.Header.MarshalHash()
-	if err != nil {
+	if b, err = r.Header.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, hdrBytes...)
 	// Payload
-	payBytes, err := r.Payload.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, payBytes...)
-	return b, nil
+	return r.Payload.appendHash(b)
+}
+
+// Msgsize returns an estimation of the hash encoding size of Request.
+func (r *Request) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + r.Header.Msgsize() + r.Payload.Msgsize()
 }
-func (r *Request) Msgsize() int { return 1024 }
 
 // MarshalHash marshals SignedRequestHeader for hash computation
 func (srh *SignedRequestHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return srh.appendHash(make([]byte, 0, srh.Msgsize()))
+}
+
+func (srh *SignedRequestHeader) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Embedded RequestHeader
-	hdrBytes, err := srh.RequestHeader.MarshalHash()
-	if err != nil {
+	if b, err = srh.RequestHeader.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, hdrBytes...)
 	// DefaultHashSignVerifierImpl
-	hsvBytes, err := srh.DefaultHashSignVerifierImpl.MarshalHash()
-	if err != nil {
-		return nil, err
-	}
-	b = append(b, hsvBytes...)
-	return b, nil
+	return appendHasher(b, &srh.DefaultHashSignVerifierImpl)
+}
+
+// Msgsize returns an upper bound of the hash encoding size of SignedRequestHeader.
+func (srh *SignedRequestHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + srh.RequestHeader.Msgsize() +
+		srh.DefaultHashSignVerifierImpl.Msgsize()
 }
-func (srh *SignedRequestHeader) Msgsize() int { return 512 }
 
 // MarshalHash marshals SignedResponseHeader for hash computation
 func (srh *SignedResponseHeader) MarshalHash() ([]byte, error) {
-	b := make([]byte, 0, 512)
+	return srh.appendHash(make([]byte, 0, srh.Msgsize()))
+}
+
+func (srh *SignedResponseHeader) appendHash(b []byte) ([]byte, error) {
+	var err error
 	b = marshalhash.AppendArrayHeader(b, 2)
 	// Embedded ResponseHeader
-	hdrBytes, err := srh.ResponseHeader.MarshalHash()
-	if err != nil {
+	if b, err = srh.ResponseHeader.appendHash(b); err != nil {
 		return nil, err
 	}
-	b = append(b, hdrBytes...)
 	// ResponseHash
 	b = marshalhash.AppendBytes(b, srh.ResponseHash[:])
 	return b, nil
 }
-func (srh *SignedResponseHeader) Msgsize() int { return 512 }
+
+// Msgsize returns an upper bound of the hash encoding size of SignedResponseHeader.
+func (srh *SignedResponseHeader) Msgsize() int {
+	return marshalhash.ArrayHeaderSize + srh.ResponseHeader.Msgsize() + hashSize
+}
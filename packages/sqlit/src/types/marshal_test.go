@@ -0,0 +1,61 @@
+package types
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/asymmetric"
+)
+
+func TestMsgsize(t *testing.T) {
+	Convey("Msgsize should not underestimate the hash encoding size", t, func() {
+		for _, v := range buildHashVectorValues() {
+			enc, err := v.value.MarshalHash()
+			So(err, ShouldBeNil)
+			sizer, ok := v.value.(interface{ Msgsize() int })
+			So(ok, ShouldBeTrue)
+			So(sizer.Msgsize(), ShouldBeGreaterThanOrEqualTo, len(enc))
+		}
+	})
+	Convey("Msgsize should cover signed headers", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		var hdr = &SignedRequestHeader{RequestHeader: RequestHeader{DatabaseID: "db"}}
+		So(hdr.Sign(priv), ShouldBeNil)
+		enc, err := hdr.MarshalHash()
+		So(err, ShouldBeNil)
+		So(hdr.Msgsize(), ShouldBeGreaterThanOrEqualTo, len(enc))
+	})
+}
+
+func buildBenchmarkPayload(rows int) *ResponsePayload {
+	var p = &ResponsePayload{
+		Columns:   []string{"id", "name", "score", "blob", "created"},
+		DeclTypes: []string{"INTEGER", "TEXT", "REAL", "BLOB", "DATETIME"},
+		Rows:      make([]ResponseRow, rows),
+	}
+	for i := range p.Rows {
+		p.Rows[i].Values = []interface{}{
+			int64(i),
+			fmt.Sprintf("name-%d", i),
+			float64(i) / 3,
+			[]byte("0123456789abcdef"),
+			time.Unix(int64(i), 0),
+		}
+	}
+	return p
+}
+
+func BenchmarkMarshalHash(b *testing.B) {
+	var p = buildBenchmarkPayload(10000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.MarshalHash(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
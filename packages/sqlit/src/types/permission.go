@@ -0,0 +1,110 @@
+package types
+
+import (
+	"sort"
+
+	"sqlit/src/proto"
+)
+
+// PermissionChange defines the change of the permission of a user on a database.
+type PermissionChange struct {
+	Addr proto.AccountAddress
+	// Before is nil if the user is added
+	Before *UserPermission
+	After  *UserPermission
+}
+
+func samePermission(a, b *UserPermission) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Role != b.Role || len(a.Patterns) != len(b.Patterns) {
+		return false
+	}
+	for i := range a.Patterns {
+		if a.Patterns[i] != b.Patterns[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// DiffUsers returns the permission changes from the users before to the users after, sorted by
+// address.
+func DiffUsers(before, after []*SQLChainUser) (changes []*PermissionChange) {
+	var perms = make(map[proto.AccountAddress]*UserPermission, len(before))
+	for _, u := range before {
+		perms[u.Address] = u.Permission
+	}
+	for _, u := range after {
+		old, ok := perms[u.Address]
+		if ok && samePermission(old, u.Permission) {
+			continue
+		}
+		changes = append(changes, &PermissionChange{
+			Addr:   u.Address,
+			Before: old,
+			After:  u.Permission,
+		})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Addr.String() < changes[j].Addr.String()
+	})
+	return
+}
+
+// QueryPermission defines whether a query pattern is permitted for a user.
+type QueryPermission struct {
+	Pattern   string
+	Permitted bool
+}
+
+// EffectivePermission defines the permission of an account on a database, as it's checked by the
+// miners on the queries.
+type EffectivePermission struct {
+	Addr proto.AccountAddress
+	// IsUser is false if the account is not a user of the database, which is denied of everything
+	IsUser     bool
+	Permission *UserPermission
+	Status     Status
+	// CanQuery reports whether the status of the user enables queries
+	CanQuery bool
+	CanRead  bool
+	CanWrite bool
+	IsAdmin  bool
+	// Queries are the evaluated query patterns, a pattern is permitted if the user can query and
+	// it's allowed by the patterns of the permission. The read-only users are also refused to
+	// run the writes by the miners, which are not told apart here.
+	Queries []*QueryPermission
+}
+
+// NewEffectivePermission returns the effective permission of account addr on the database of
+// profile, with the query patterns evaluated.
+func NewEffectivePermission(
+	profile *SQLChainProfile, addr proto.AccountAddress, patterns ...string,
+) (ep *EffectivePermission) {
+	ep = &EffectivePermission{Addr: addr}
+	for _, u := range profile.Users {
+		if u.Address == addr {
+			ep.IsUser = true
+			ep.Permission = u.Permission
+			ep.Status = u.Status
+			break
+		}
+	}
+	if ep.IsUser {
+		ep.CanQuery = ep.Status.EnableQuery()
+		ep.CanRead = ep.CanQuery && ep.Permission.HasReadPermission()
+		ep.CanWrite = ep.CanQuery && ep.Permission.HasWritePermission()
+		ep.IsAdmin = ep.Permission.HasSuperPermission()
+	}
+	for _, p := range patterns {
+		var qp = &QueryPermission{Pattern: p}
+		if ep.CanRead || ep.CanWrite {
+			_, disallowed := ep.Permission.HasDisallowedQueryPatterns([]Query{{Pattern: p}})
+			qp.Permitted = !disallowed
+		}
+		ep.Queries = append(ep.Queries, qp)
+	}
+	return
+}
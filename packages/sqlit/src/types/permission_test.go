@@ -0,0 +1,104 @@
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+)
+
+func TestDiffUsers(t *testing.T) {
+	Convey("Given the users of a database", t, func() {
+		var (
+			a      = proto.AccountAddress{0x1}
+			b      = proto.AccountAddress{0x2}
+			c      = proto.AccountAddress{0x3}
+			before = []*SQLChainUser{
+				{Address: b, Permission: UserPermissionFromRole(Admin)},
+				{Address: a, Permission: &UserPermission{Role: Read, Patterns: []string{"SELECT 1"}}},
+			}
+		)
+		Convey("The unchanged users should produce no changes", func() {
+			var after = []*SQLChainUser{
+				{Address: b, Permission: UserPermissionFromRole(Admin)},
+				{Address: a, Permission: &UserPermission{Role: Read, Patterns: []string{"SELECT 1"}}},
+			}
+			So(DiffUsers(before, after), ShouldBeEmpty)
+		})
+		Convey("The updated and added users should be sorted by address", func() {
+			var after = []*SQLChainUser{
+				{Address: b, Permission: UserPermissionFromRole(Void)},
+				{Address: a, Permission: &UserPermission{Role: Read, Patterns: []string{"SELECT 2"}}},
+				{Address: c, Permission: UserPermissionFromRole(ReadOnly)},
+			}
+			var changes = DiffUsers(before, after)
+			So(changes, ShouldHaveLength, 3)
+			So(changes[0].Addr, ShouldEqual, a)
+			So(changes[0].Before.Patterns, ShouldResemble, []string{"SELECT 1"})
+			So(changes[0].After.Patterns, ShouldResemble, []string{"SELECT 2"})
+			So(changes[1].Addr, ShouldEqual, b)
+			So(changes[1].Before.Role, ShouldEqual, Admin)
+			So(changes[1].After.Role, ShouldEqual, Void)
+			So(changes[2].Addr, ShouldEqual, c)
+			So(changes[2].Before, ShouldBeNil)
+			So(changes[2].After.Role, ShouldEqual, ReadOnly)
+		})
+	})
+}
+
+func TestNewEffectivePermission(t *testing.T) {
+	Convey("Given the profile of a database", t, func() {
+		var (
+			admin    = proto.AccountAddress{0x1}
+			reader   = proto.AccountAddress{0x2}
+			arrears  = proto.AccountAddress{0x3}
+			stranger = proto.AccountAddress{0x4}
+			profile  = &SQLChainProfile{
+				Users: []*SQLChainUser{
+					{Address: admin, Permission: UserPermissionFromRole(Admin), Status: Normal},
+					{
+						Address:    reader,
+						Permission: &UserPermission{Role: ReadOnly, Patterns: []string{"SELECT 1"}},
+						Status:     Normal,
+					},
+					{Address: arrears, Permission: UserPermissionFromRole(Admin), Status: Arrears},
+				},
+			}
+		)
+		Convey("The admin should be permitted to run any query", func() {
+			var ep = NewEffectivePermission(profile, admin, "SELECT 1", "DELETE FROM t")
+			So(ep.IsUser, ShouldBeTrue)
+			So(ep.CanQuery, ShouldBeTrue)
+			So(ep.CanRead, ShouldBeTrue)
+			So(ep.CanWrite, ShouldBeTrue)
+			So(ep.IsAdmin, ShouldBeTrue)
+			So(ep.Queries, ShouldHaveLength, 2)
+			So(ep.Queries[0].Permitted, ShouldBeTrue)
+			So(ep.Queries[1].Permitted, ShouldBeTrue)
+		})
+		Convey("The reader should be restricted to its query patterns", func() {
+			var ep = NewEffectivePermission(profile, reader, "SELECT 1", "SELECT 2")
+			So(ep.CanRead, ShouldBeTrue)
+			So(ep.CanWrite, ShouldBeFalse)
+			So(ep.IsAdmin, ShouldBeFalse)
+			So(ep.Queries[0].Permitted, ShouldBeTrue)
+			So(ep.Queries[1].Permitted, ShouldBeFalse)
+		})
+		Convey("The user in arrears should be denied of queries", func() {
+			var ep = NewEffectivePermission(profile, arrears, "SELECT 1")
+			So(ep.IsUser, ShouldBeTrue)
+			So(ep.IsAdmin, ShouldBeTrue)
+			So(ep.CanQuery, ShouldBeFalse)
+			So(ep.CanRead, ShouldBeFalse)
+			So(ep.Queries[0].Permitted, ShouldBeFalse)
+		})
+		Convey("The stranger should be denied of everything", func() {
+			var ep = NewEffectivePermission(profile, stranger, "SELECT 1")
+			So(ep.IsUser, ShouldBeFalse)
+			So(ep.Permission, ShouldBeNil)
+			So(ep.CanQuery, ShouldBeFalse)
+			So(ep.Queries[0].Permitted, ShouldBeFalse)
+		})
+	})
+}
@@ -19,6 +19,8 @@ type ProvideServiceHeader struct {
 	TargetUser    []proto.AccountAddress
 	NodeID        proto.NodeID
 	Nonce         interfaces.AccountNonce
+	Region        string // region label of the miner, e.g. eu-west
+	Zone          string // zone label of the miner in the region
 }
 
 // GetAccountNonce implements interfaces/Transaction.GetAccountNonce.
@@ -42,7 +44,7 @@ func NewProvideService(h *ProvideServiceHeader) *ProvideService {
 }
 
 // Sign implements interfaces/Transaction.Sign.
-func (ps *ProvideService) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (ps *ProvideService) Sign(signer asymmetric.Signer) (err error) {
 	return ps.DefaultHashSignVerifierImpl.Sign(&ps.ProvideServiceHeader, signer)
 }
 
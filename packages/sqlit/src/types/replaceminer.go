@@ -0,0 +1,68 @@
+package types
+
+import (
+	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/verifier"
+	"sqlit/src/proto"
+)
+
+//go:generate hsp
+
+// ReplaceMinerHeader defines the sqlchain miner replacement transaction header.
+//
+// With an empty Miner, the Standby provider is added as a warm standby of the sqlchain, which
+// replicates the blocks without serving queries. Otherwise the Standby miner is promoted in place
+// of Miner, e.g. on the loss of Miner.
+type ReplaceMinerHeader struct {
+	TargetSQLChain proto.AccountAddress
+	Miner          proto.AccountAddress
+	Standby        proto.AccountAddress
+	Nonce          interfaces.AccountNonce
+}
+
+// GetAccountNonce implements interfaces/Transaction.GetAccountNonce.
+func (h *ReplaceMinerHeader) GetAccountNonce() interfaces.AccountNonce {
+	return h.Nonce
+}
+
+// ReplaceMiner defines the sqlchain miner replacement transaction.
+type ReplaceMiner struct {
+	ReplaceMinerHeader
+	interfaces.TransactionTypeMixin
+	verifier.DefaultHashSignVerifierImpl
+}
+
+// NewReplaceMiner returns new instance.
+func NewReplaceMiner(header *ReplaceMinerHeader) *ReplaceMiner {
+	return &ReplaceMiner{
+		ReplaceMinerHeader:   *header,
+		TransactionTypeMixin: *interfaces.NewTransactionTypeMixin(interfaces.TransactionTypeReplaceMiner),
+	}
+}
+
+// Sign implements interfaces/Transaction.Sign.
+func (rm *ReplaceMiner) Sign(signer asymmetric.Signer) (err error) {
+	return rm.DefaultHashSignVerifierImpl.Sign(&rm.ReplaceMinerHeader, signer)
+}
+
+// Verify implements interfaces/Transaction.Verify.
+func (rm *ReplaceMiner) Verify() error {
+	return rm.DefaultHashSignVerifierImpl.Verify(&rm.ReplaceMinerHeader)
+}
+
+// GetAccountAddress implements interfaces/Transaction.GetAccountAddress.
+func (rm *ReplaceMiner) GetAccountAddress() proto.AccountAddress {
+	addr, _ := crypto.PubKeyHash(rm.Signee)
+	return addr
+}
+
+// IsPromotion returns whether the transaction promotes the standby miner instead of adding it.
+func (rm *ReplaceMiner) IsPromotion() bool {
+	return rm.Miner != proto.AccountAddress{}
+}
+
+func init() {
+	interfaces.RegisterTransaction(interfaces.TransactionTypeReplaceMiner, (*ReplaceMiner)(nil))
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/proto"
+)
+
+func TestReplaceMiner(t *testing.T) {
+	Convey("test ReplaceMiner", t, func() {
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		addr, err := crypto.PubKeyHash(privKey.PubKey())
+		So(err, ShouldBeNil)
+
+		rm := NewReplaceMiner(&ReplaceMinerHeader{
+			TargetSQLChain: proto.AccountAddress(hash.HashH([]byte("db"))),
+			Standby:        proto.AccountAddress(hash.HashH([]byte("standby"))),
+			Nonce:          2,
+		})
+		So(rm.IsPromotion(), ShouldBeFalse)
+		err = rm.Sign(privKey)
+		So(err, ShouldBeNil)
+		So(rm.Verify(), ShouldBeNil)
+		So(rm.GetAccountAddress(), ShouldEqual, addr)
+		So(rm.GetAccountNonce(), ShouldEqual, 2)
+
+		// the signature should cover the replaced miner
+		rm.Miner = proto.AccountAddress(hash.HashH([]byte("miner")))
+		So(rm.IsPromotion(), ShouldBeTrue)
+		So(rm.Verify(), ShouldNotBeNil)
+	})
+}
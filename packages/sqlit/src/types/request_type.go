@@ -3,6 +3,8 @@ package types
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"sqlit/src/crypto/asymmetric"
@@ -25,6 +27,20 @@ const (
 	NumberOfQueryType
 )
 
+// QueryPriority defines the priority class of a request, miners shed the batch requests first to
+// keep the latency of the interactive ones.
+type QueryPriority int32
+
+const (
+	// InteractivePriority defines the latency sensitive requests, which is the default.
+	InteractivePriority QueryPriority = iota
+	// BatchPriority defines the bulk requests which may be queued or shed on overload.
+	BatchPriority
+)
+
+// MaxTraceIDLength defines the max length of the trace id of a request.
+const MaxTraceIDLength = 64
+
 // NamedArg defines the named argument structure for database.
 type NamedArg struct {
 	Name  string
@@ -52,6 +68,7 @@ type RequestHeader struct {
 	Timestamp    time.Time        `json:"t"`  // time in UTC zone
 	BatchCount   uint64           `json:"bc"` // query count in this request
 	QueriesHash  hash.Hash        `json:"qh"` // hash of query payload
+	HeaderExt
 }
 
 // GetQueryKey returns a unique query key of this request.
@@ -63,6 +80,299 @@ func (h *RequestHeader) GetQueryKey() QueryKey {
 	}
 }
 
+// requestExt defines the extension fields of a request header in order.
+type requestExt struct {
+	key      string
+	height   int32 // negative if the request queries the current state
+	priority QueryPriority
+	maxExec  time.Duration
+	cursor   bool
+	maxRows  uint64
+	attached string // encoded by encodeAttached
+	traceID  string
+	compress string // encoded by encodeCompression
+	encoding string
+	maxCost  uint64
+	stale    bool
+}
+
+// decodeRequestExt decodes the extension fields, the missing or malformed fields are decoded as
+// the default values.
+func (h *RequestHeader) decodeRequestExt() (e requestExt) {
+	var (
+		key      string
+		height   int32 = -1
+		priority int32
+		maxExec  int64
+		cursor   bool
+		maxRows  uint64
+		attached string
+		traceID  string
+		compress string
+		encoding string
+		maxCost  uint64
+		stale    bool
+	)
+	if h.DecodeExt(
+		&key, &height, &priority, &maxExec, &cursor, &maxRows, &attached, &traceID, &compress,
+		&encoding, &maxCost, &stale,
+	) != nil {
+		return requestExt{height: -1}
+	}
+	return requestExt{
+		key:      key,
+		height:   height,
+		priority: QueryPriority(priority),
+		maxExec:  time.Duration(maxExec),
+		cursor:   cursor,
+		maxRows:  maxRows,
+		attached: attached,
+		traceID:  traceID,
+		compress: compress,
+		encoding: encoding,
+		maxCost:  maxCost,
+		stale:    stale,
+	}
+}
+
+// setRequestExt sets the extension fields, the trailing fields with the default values are
+// omitted to keep the requests compact.
+func (h *RequestHeader) setRequestExt(e requestExt) error {
+	switch {
+	case e.stale:
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached, e.traceID, e.compress, e.encoding, e.maxCost, e.stale)
+	case e.maxCost > 0:
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached, e.traceID, e.compress, e.encoding, e.maxCost)
+	case e.encoding != "":
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached, e.traceID, e.compress, e.encoding)
+	case e.compress != "":
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached, e.traceID, e.compress)
+	case e.traceID != "":
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached, e.traceID)
+	case e.attached != "":
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec),
+			e.cursor, e.maxRows, e.attached)
+	case e.maxRows > 0:
+		return h.SetExt(SerialVersionExt,
+			e.key, e.height, int32(e.priority), int64(e.maxExec), e.cursor, e.maxRows)
+	case e.cursor:
+		return h.SetExt(
+			SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec), e.cursor)
+	case e.maxExec > 0:
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority), int64(e.maxExec))
+	case e.priority != InteractivePriority:
+		return h.SetExt(SerialVersionExt, e.key, e.height, int32(e.priority))
+	case e.height >= 0:
+		return h.SetExt(SerialVersionExt, e.key, e.height)
+	default:
+		return h.SetExt(SerialVersionExt, e.key)
+	}
+}
+
+// SetIdempotencyKey sets the client generated idempotency key of a write request as the first
+// extension field, the request must be signed after.
+func (h *RequestHeader) SetIdempotencyKey(key string) error {
+	e := h.decodeRequestExt()
+	e.key = key
+	return h.setRequestExt(e)
+}
+
+// IdempotencyKey returns the idempotency key of the request, or an empty string if not set.
+func (h *RequestHeader) IdempotencyKey() (key string) {
+	return h.decodeRequestExt().key
+}
+
+// SetAsOfHeight sets the sqlchain height of a read request to query the historical state at as
+// the second extension field, the request must be signed after.
+func (h *RequestHeader) SetAsOfHeight(height int32) error {
+	e := h.decodeRequestExt()
+	e.height = height
+	return h.setRequestExt(e)
+}
+
+// AsOfHeight returns the sqlchain height of the historical state to query, or ok=false if the
+// request queries the current state.
+func (h *RequestHeader) AsOfHeight() (height int32, ok bool) {
+	height = h.decodeRequestExt().height
+	return height, height >= 0
+}
+
+// SetPriority sets the priority class of the request as the third extension field, the request
+// must be signed after.
+func (h *RequestHeader) SetPriority(priority QueryPriority) error {
+	e := h.decodeRequestExt()
+	e.priority = priority
+	return h.setRequestExt(e)
+}
+
+// Priority returns the priority class of the request, which is interactive if not set.
+func (h *RequestHeader) Priority() QueryPriority {
+	return h.decodeRequestExt().priority
+}
+
+// SetMaxExecutionTime sets the max execution time of the queries of the request on miners as the
+// fourth extension field, the request must be signed after.
+func (h *RequestHeader) SetMaxExecutionTime(d time.Duration) error {
+	e := h.decodeRequestExt()
+	e.maxExec = d
+	return h.setRequestExt(e)
+}
+
+// MaxExecutionTime returns the max execution time of the queries of the request, 0 means
+// unlimited.
+func (h *RequestHeader) MaxExecutionTime() time.Duration {
+	if d := h.decodeRequestExt().maxExec; d > 0 {
+		return d
+	}
+	return 0
+}
+
+// SetResultCursor sets whether the result of the read request exceeding the limit of miners may be
+// spilled to a server-side cursor as the fifth extension field, the request must be signed after.
+func (h *RequestHeader) SetResultCursor(cursor bool) error {
+	e := h.decodeRequestExt()
+	e.cursor = cursor
+	return h.setRequestExt(e)
+}
+
+// ResultCursor returns whether the result of the read request may be spilled to a server-side
+// cursor, otherwise the request fails if its result exceeds the limit.
+func (h *RequestHeader) ResultCursor() bool {
+	return h.decodeRequestExt().cursor
+}
+
+// SetResultMaxRows sets the max count of rows in the result of each read query of the request as
+// the sixth extension field, the request must be signed after. The stricter one of it and the
+// limit of miners applies.
+func (h *RequestHeader) SetResultMaxRows(n uint64) error {
+	e := h.decodeRequestExt()
+	e.maxRows = n
+	return h.setRequestExt(e)
+}
+
+// ResultMaxRows returns the max count of rows in the result of each read query set by the
+// request, 0 means the limit of miners only.
+func (h *RequestHeader) ResultMaxRows() uint64 {
+	return h.decodeRequestExt().maxRows
+}
+
+// SetAttachedDatabases sets the databases attached to the read request by their aliases as the
+// seventh extension field, the request must be signed after.
+func (h *RequestHeader) SetAttachedDatabases(attached map[string]proto.DatabaseID) error {
+	e := h.decodeRequestExt()
+	e.attached = encodeAttached(attached)
+	return h.setRequestExt(e)
+}
+
+// AttachedDatabases returns the databases attached to the read request by their aliases, or nil
+// if none is attached.
+func (h *RequestHeader) AttachedDatabases() map[string]proto.DatabaseID {
+	return decodeAttached(h.decodeRequestExt().attached)
+}
+
+// SetTraceID sets the client generated trace id of the request as the eighth extension field, the
+// request must be signed after. The id is logged by the miners and kept in the blocks with the
+// request to correlate a failure across the nodes.
+func (h *RequestHeader) SetTraceID(id string) error {
+	if len(id) > MaxTraceIDLength {
+		return ErrTraceIDTooLong
+	}
+	e := h.decodeRequestExt()
+	e.traceID = id
+	return h.setRequestExt(e)
+}
+
+// TraceID returns the trace id of the request, or an empty string if not set.
+func (h *RequestHeader) TraceID() string {
+	return h.decodeRequestExt().traceID
+}
+
+// SetResultCompression sets the compression of the response rows accepted by the request with the
+// id of the dictionary held by the request node, 0 if none, as the ninth extension field, the
+// request must be signed after.
+func (h *RequestHeader) SetResultCompression(algo string, dictID uint32) error {
+	e := h.decodeRequestExt()
+	e.compress = encodeCompression(algo, dictID)
+	return h.setRequestExt(e)
+}
+
+// ResultCompression returns the compression of the response rows accepted by the request and the
+// id of the dictionary held by the request node, or an empty algo if the rows are not compressed.
+func (h *RequestHeader) ResultCompression() (algo string, dictID uint32) {
+	return decodeCompression(h.decodeRequestExt().compress)
+}
+
+// SetResultEncoding sets the alternative encoding of the response rows accepted by the request as
+// the tenth extension field, the request must be signed after.
+func (h *RequestHeader) SetResultEncoding(encoding string) error {
+	e := h.decodeRequestExt()
+	e.encoding = encoding
+	return h.setRequestExt(e)
+}
+
+// ResultEncoding returns the alternative encoding of the response rows accepted by the request,
+// or an empty string if the rows are sent as is.
+func (h *RequestHeader) ResultEncoding() string {
+	return h.decodeRequestExt().encoding
+}
+
+// SetMaxCost sets the max estimated cost of each query of the request as the eleventh extension
+// field, the request must be signed after. The query estimated to visit more rows is refused by
+// the miner before it's executed.
+func (h *RequestHeader) SetMaxCost(rows uint64) error {
+	e := h.decodeRequestExt()
+	e.maxCost = rows
+	return h.setRequestExt(e)
+}
+
+// MaxCost returns the max estimated cost of each query of the request in rows, 0 means unlimited.
+func (h *RequestHeader) MaxCost() uint64 {
+	return h.decodeRequestExt().maxCost
+}
+
+// SetStaleRead sets whether the read request accepts a possibly stale result of a follower as the
+// twelfth extension field, the request must be signed after. The follower serving such a request
+// sets the staleness bound of its state in the response header.
+func (h *RequestHeader) SetStaleRead(stale bool) error {
+	e := h.decodeRequestExt()
+	e.stale = stale
+	return h.setRequestExt(e)
+}
+
+// StaleRead returns whether the read request accepts a possibly stale result of a follower.
+func (h *RequestHeader) StaleRead() bool {
+	return h.decodeRequestExt().stale
+}
+
+// encodeAttached encodes the attached databases as "alias=id" pairs separated by ";" in the
+// order of the aliases.
+func encodeAttached(attached map[string]proto.DatabaseID) string {
+	var pairs = make([]string, 0, len(attached))
+	for alias, id := range attached {
+		pairs = append(pairs, alias+"="+string(id))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ";")
+}
+
+func decodeAttached(s string) (attached map[string]proto.DatabaseID) {
+	if s == "" {
+		return
+	}
+	attached = make(map[string]proto.DatabaseID)
+	for _, pair := range strings.Split(s, ";") {
+		if i := strings.IndexByte(pair, '='); i > 0 {
+			attached[pair[:i]] = proto.DatabaseID(pair[i+1:])
+		}
+	}
+	return
+}
+
 // QueryKey defines an unique query key of a request.
 type QueryKey struct {
 	NodeID       proto.NodeID `json:"id"`
@@ -101,13 +411,25 @@ func (t QueryType) String() string {
 	}
 }
 
+// String implements fmt.Stringer for logging purpose.
+func (p QueryPriority) String() string {
+	switch p {
+	case InteractivePriority:
+		return "interactive"
+	case BatchPriority:
+		return "batch"
+	default:
+		return "unknown"
+	}
+}
+
 // Verify checks hash and signature in request header.
 func (sh *SignedRequestHeader) Verify() (err error) {
 	return sh.DefaultHashSignVerifierImpl.Verify(&sh.RequestHeader)
 }
 
 // Sign the request.
-func (sh *SignedRequestHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedRequestHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.RequestHeader, signer)
 }
 
@@ -122,7 +444,7 @@ func (r *Request) Verify() (err error) {
 }
 
 // Sign the request.
-func (r *Request) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (r *Request) Sign(signer asymmetric.Signer) (err error) {
 	// set query count
 	r.Header.BatchCount = uint64(len(r.Payload.Queries))
 
@@ -6,6 +6,8 @@ import (
 
 	"github.com/pkg/errors"
 
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/proto"
 )
@@ -36,6 +38,24 @@ type ResponseHeader struct {
 	AffectedRows    int64                `json:"a"`  // affected rows
 	PayloadHash     hash.Hash            `json:"dh"` // hash of query response payload
 	ResponseAccount proto.AccountAddress `json:"aa"` // response account
+	HeaderExt
+}
+
+// SetStaleness sets the staleness bound of the follower state serving a stale read as the first
+// extension field, the response must be hashed and signed after. The state of the follower may
+// miss the writes committed by the leader in the last d at most.
+func (h *ResponseHeader) SetStaleness(d time.Duration) error {
+	return h.SetExt(SerialVersionExt, int64(d))
+}
+
+// Staleness returns the staleness bound of the follower state serving the read, ok is false if
+// the response is not marked as possibly stale.
+func (h *ResponseHeader) Staleness() (d time.Duration, ok bool) {
+	var bound = int64(-1)
+	if h.DecodeExt(&bound) != nil || bound < 0 {
+		return
+	}
+	return time.Duration(bound), true
 }
 
 // GetRequestHash returns the request hash.
@@ -75,6 +95,23 @@ func (sh *SignedResponseHeader) BuildHash() (err error) {
 type Response struct {
 	Header  SignedResponseHeader `json:"h"`
 	Payload ResponsePayload      `json:"p"`
+	// Cursor is the id of the server-side cursor holding the rest rows of the result, it's set if
+	// the result exceeds the limit of the miner and the request allows a cursor. It is not
+	// covered by the hash, the pages are fetched by the request node only.
+	Cursor string `json:"cur,omitempty"`
+	// Compression and CompressedRows are the compression of the payload rows accepted by the
+	// request, the rows are compressed after the response is hashed and signed, and are restored
+	// by the request node before the verification.
+	Compression    string `json:"cmp,omitempty"`
+	CompressedRows []byte `json:"cr,omitempty"`
+	// Encoding and ColumnarRows are the alternative encoding of the payload rows accepted by the
+	// request, which are encoded before the compression and restored after the decompression.
+	Encoding     string `json:"enc,omitempty"`
+	ColumnarRows []byte `json:"col,omitempty"`
+	// Signee and Signature are the signature of the responder on the response hash, they are not
+	// covered by the hash either, and are verified by the request node.
+	Signee    *asymmetric.PublicKey `json:"s,omitempty"`
+	Signature *asymmetric.Signature `json:"sig,omitempty"`
 }
 
 // BuildHash computes the hash of the response.
@@ -106,3 +143,80 @@ func (r *Response) VerifyHash() (err error) {
 func (r *Response) Hash() hash.Hash {
 	return r.Header.Hash()
 }
+
+// Sign signs the response hash with the private key of the responder, the hash should be built
+// before.
+func (r *Response) Sign(signer asymmetric.Signer) (err error) {
+	var h = r.Header.Hash()
+	if r.Signature, err = signer.Sign(h[:]); err != nil {
+		return
+	}
+	r.Signee = signer.PubKey()
+	return
+}
+
+// VerifySignature verifies the signature of the responder, which must be signed by the key of
+// the response account.
+func (r *Response) VerifySignature() (err error) {
+	var (
+		h    = r.Header.Hash()
+		addr proto.AccountAddress
+	)
+	if r.Signee == nil || r.Signature == nil || !r.Signature.Verify(h[:], r.Signee) {
+		return errors.Wrap(ErrSignVerification, "verify response signature failed")
+	}
+	if addr, err = crypto.PubKeyHash(r.Signee); err != nil {
+		return
+	}
+	if addr != r.Header.ResponseAccount {
+		return errors.Wrapf(ErrSignVerification,
+			"response signee %s is not the response account %s", addr, r.Header.ResponseAccount)
+	}
+	return
+}
+
+// Verify verifies the hashes and the signature of the response.
+func (r *Response) Verify() (err error) {
+	if err = r.VerifyHash(); err != nil {
+		return
+	}
+	return r.VerifySignature()
+}
+
+// FetchCursorReq defines a request of the FetchCursor RPC method, which fetches the next page of
+// a result cursor.
+type FetchCursorReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+	Cursor     string
+	// Close closes the cursor instead of fetching, e.g. when the client stops reading
+	Close bool
+}
+
+// QueryCost defines the cost of a query estimated by the query plan on the current state, the
+// statements which can't be planned before the execution, e.g. on the tables created by the same
+// request, are not counted.
+type QueryCost struct {
+	Rows      uint64   // estimated rows visited by the query
+	Pages     uint64   // estimated pages read by the query
+	FullScans []string // tables fully scanned by the query
+}
+
+// EstimateQueryResp defines a response of the EstimateQuery RPC method, the costs of the queries
+// of the request in order.
+type EstimateQueryResp struct {
+	Costs []QueryCost
+}
+
+// QueryPeersReq defines a request of the QueryPeers RPC method, which returns the current peers
+// of a database.
+type QueryPeersReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+}
+
+// QueryPeersResp defines a response of the QueryPeers RPC method, the term and the leader of the
+// peers are signed by the miner which issued them.
+type QueryPeersResp struct {
+	Peers *proto.Peers
+}
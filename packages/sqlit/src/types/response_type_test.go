@@ -0,0 +1,124 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+func TestResponseVerify(t *testing.T) {
+	Convey("Given a signed response", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		addr, err := crypto.PubKeyHash(priv.PubKey())
+		So(err, ShouldBeNil)
+		var (
+			nodeID = proto.NodeID(
+				"00000000000000000000000000000000000000000000000000000000000000aa")
+			resp = &Response{
+				Header: SignedResponseHeader{
+					ResponseHeader: ResponseHeader{
+						Request: RequestHeader{
+							QueryType:  ReadQuery,
+							NodeID:     nodeID,
+							DatabaseID: proto.DatabaseID("db"),
+							Timestamp:  time.Now().UTC(),
+						},
+						NodeID:          nodeID,
+						Timestamp:       time.Now().UTC(),
+						ResponseAccount: addr,
+					},
+				},
+				Payload: ResponsePayload{
+					Columns:   []string{"k", "v"},
+					DeclTypes: []string{"INT", "TEXT"},
+					Rows: []ResponseRow{
+						{Values: []interface{}{int64(1), "v1"}},
+						{Values: []interface{}{int64(2), []byte("v2")}},
+					},
+				},
+			}
+		)
+		err = resp.BuildHash()
+		So(err, ShouldBeNil)
+		err = resp.Sign(priv)
+		So(err, ShouldBeNil)
+
+		Convey("The response should be verified after the rpc encoding", func() {
+			buf, err := utils.EncodeMsgPack(resp)
+			So(err, ShouldBeNil)
+			var decoded Response
+			err = utils.DecodeMsgPack(buf.Bytes(), &decoded)
+			So(err, ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+		})
+		Convey("The tampered payload should fail the verification", func() {
+			resp.Payload.Rows[0].Values[1] = "v0"
+			So(resp.Verify(), ShouldNotBeNil)
+		})
+		Convey("The tampered header should fail the verification", func() {
+			resp.Header.AffectedRows = 1
+			So(resp.Verify(), ShouldNotBeNil)
+		})
+		Convey("The response signed by another key should fail the verification", func() {
+			other, _, err := asymmetric.GenSecp256k1KeyPair()
+			So(err, ShouldBeNil)
+			err = resp.Sign(other)
+			So(err, ShouldBeNil)
+			So(errors.Cause(resp.Verify()), ShouldEqual, ErrSignVerification)
+		})
+		Convey("The staleness bound should be kept with the signed response", func() {
+			_, ok := resp.Header.Staleness()
+			So(ok, ShouldBeFalse)
+			err = resp.Header.SetStaleness(3 * time.Second)
+			So(err, ShouldBeNil)
+			So(resp.Verify(), ShouldNotBeNil)
+			err = resp.BuildHash()
+			So(err, ShouldBeNil)
+			err = resp.Sign(priv)
+			So(err, ShouldBeNil)
+
+			buf, err := utils.EncodeMsgPack(resp)
+			So(err, ShouldBeNil)
+			var decoded Response
+			err = utils.DecodeMsgPack(buf.Bytes(), &decoded)
+			So(err, ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			bound, ok := decoded.Header.Staleness()
+			So(ok, ShouldBeTrue)
+			So(bound, ShouldEqual, 3*time.Second)
+
+			err = decoded.Header.SetStaleness(time.Second)
+			So(err, ShouldBeNil)
+			So(decoded.Verify(), ShouldNotBeNil)
+		})
+		Convey("The unsigned response should fail the verification", func() {
+			resp.Signee, resp.Signature = nil, nil
+			So(errors.Cause(resp.Verify()), ShouldEqual, ErrSignVerification)
+		})
+	})
+	Convey("The stale read flag should be kept in the twelfth extension field", t, func() {
+		var h = &RequestHeader{QueryType: ReadQuery}
+		So(h.StaleRead(), ShouldBeFalse)
+		So(h.SetTraceID("trace"), ShouldBeNil)
+		So(h.SetStaleRead(true), ShouldBeNil)
+		So(h.StaleRead(), ShouldBeTrue)
+		So(h.TraceID(), ShouldEqual, "trace")
+		n, err := h.Ext.Count()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 12)
+		So(h.SetStaleRead(false), ShouldBeNil)
+		So(h.StaleRead(), ShouldBeFalse)
+		So(h.TraceID(), ShouldEqual, "trace")
+		n, err = h.Ext.Count()
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 8)
+	})
+}
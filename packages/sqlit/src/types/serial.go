@@ -0,0 +1,85 @@
+package types
+
+import (
+	"github.com/pkg/errors"
+
+	"sqlit/src/marshalhash"
+)
+
+// Serialization versions of the signed block and request headers.
+const (
+	// SerialVersionLegacy is the original layout, which is hashed without the version and the
+	// extension fields to keep the hashes of existing blocks and requests.
+	SerialVersionLegacy int32 = iota
+	// SerialVersionExt is the layout with the version and the extension fields appended to the
+	// hashed fields.
+	SerialVersionExt
+
+	// CurrentSerialVersion is the latest serialization version known by this build.
+	CurrentSerialVersion = SerialVersionExt
+)
+
+// HeaderExt defines the serialization version and the extension fields of a signed header.
+//
+// New header fields are carried as the trailing extension fields instead of new struct fields,
+// so miners of older versions, which don't know the fields, still keep and hash them as is and
+// verify the signatures of headers produced by newer miners during a rolling upgrade.
+type HeaderExt struct {
+	SerialVersion int32              `json:"sv,omitempty"`
+	Ext           marshalhash.Fields `json:"x,omitempty"`
+}
+
+// SetExt sets the serialization version and the extension fields of the header, values are
+// appended in order and the header must be signed after.
+func (e *HeaderExt) SetExt(version int32, values ...interface{}) (err error) {
+	if version < SerialVersionExt && len(values) > 0 {
+		return errors.Errorf("extension fields require serialization version %d", SerialVersionExt)
+	}
+	var ext marshalhash.Fields
+	if len(values) > 0 {
+		if ext, err = marshalhash.NewFields(values...); err != nil {
+			return errors.Wrap(err, "encode extension fields")
+		}
+	}
+	e.SerialVersion, e.Ext = version, ext
+	return
+}
+
+// DecodeExt decodes the leading extension fields into out in order. Trailing fields unknown to
+// the caller are ignored, and out values are left unchanged if the header has fewer fields.
+func (e *HeaderExt) DecodeExt(out ...interface{}) error {
+	return e.Ext.Decode(out...)
+}
+
+func (e *HeaderExt) extended() bool {
+	return e.SerialVersion != SerialVersionLegacy || len(e.Ext) > 0
+}
+
+// extCount returns the count of the hashed fields in addition to the legacy ones.
+func (e *HeaderExt) extCount() (n uint32, err error) {
+	if !e.extended() {
+		return
+	}
+	var c int
+	if c, err = e.Ext.Count(); err != nil {
+		err = errors.Wrap(err, "invalid extension fields")
+		return
+	}
+	n = uint32(1 + c)
+	return
+}
+
+func (e *HeaderExt) appendHash(b []byte) ([]byte, error) {
+	if !e.extended() {
+		return b, nil
+	}
+	b = marshalhash.AppendInt32(b, e.SerialVersion)
+	return e.Ext.AppendElements(b)
+}
+
+func (e *HeaderExt) msgsize() int {
+	if !e.extended() {
+		return 0
+	}
+	return marshalhash.Int32Size + len(e.Ext)
+}
@@ -0,0 +1,237 @@
+package types
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+const testNodeID = "00000000000000000000000000000000000000000000000000000000000000aa"
+
+// futureRequest simulates a request of a newer version with an unknown top-level field.
+type futureRequest struct {
+	Request
+	TraceID string
+}
+
+func TestHeaderExt(t *testing.T) {
+	Convey("Given a request signed with extension fields", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		var req = &Request{
+			Header: SignedRequestHeader{RequestHeader: RequestHeader{
+				QueryType:  WriteQuery,
+				NodeID:     testNodeID,
+				DatabaseID: "db",
+				SeqNo:      1,
+				Timestamp:  time.Now().UTC(),
+			}},
+			Payload: RequestPayload{Queries: []Query{{Pattern: "INSERT INTO t VALUES (1)"}}},
+		}
+		legacy, err := req.Header.RequestHeader.MarshalHash()
+		So(err, ShouldBeNil)
+
+		// ExpireHeight, TraceID and a field unknown to this build
+		So(req.Header.SetExt(CurrentSerialVersion+1, int32(100), "trace", []interface{}{"future"}), ShouldBeNil)
+		So(req.Sign(priv), ShouldBeNil)
+		extended, err := req.Header.RequestHeader.MarshalHash()
+		So(err, ShouldBeNil)
+		So(extended, ShouldNotResemble, legacy)
+		So(len(extended), ShouldBeLessThanOrEqualTo, req.Header.RequestHeader.Msgsize())
+
+		Convey("The request should be verified after a wire round trip with unknown fields", func() {
+			buf, err := utils.EncodeMsgPack(&futureRequest{Request: *req, TraceID: "trace"})
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Header.SerialVersion, ShouldEqual, CurrentSerialVersion+1)
+			So(decoded.Verify(), ShouldBeNil)
+
+			var (
+				expireHeight int32
+				traceID      string
+			)
+			So(decoded.Header.DecodeExt(&expireHeight, &traceID), ShouldBeNil)
+			So(expireHeight, ShouldEqual, 100)
+			So(traceID, ShouldEqual, "trace")
+		})
+		Convey("Tampered extension fields should fail the verification", func() {
+			So(req.Header.SetExt(CurrentSerialVersion+1, int32(101), "trace", []interface{}{"future"}), ShouldBeNil)
+			So(req.Verify(), ShouldNotBeNil)
+		})
+		Convey("Malformed extension fields should fail the hashing", func() {
+			req.Header.Ext = req.Header.Ext[:len(req.Header.Ext)-1]
+			_, err := req.Header.RequestHeader.MarshalHash()
+			So(err, ShouldNotBeNil)
+		})
+		Convey("The idempotency key should be kept in the first extension field", func() {
+			So(req.Header.IdempotencyKey(), ShouldEqual, "")
+			So(req.Header.SetIdempotencyKey("key"), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.IdempotencyKey(), ShouldEqual, "key")
+		})
+		Convey("The as-of height should be kept in the second extension field", func() {
+			_, ok := req.Header.AsOfHeight()
+			So(ok, ShouldBeFalse)
+			So(req.Header.SetAsOfHeight(0), ShouldBeNil)
+			height, ok := req.Header.AsOfHeight()
+			So(ok, ShouldBeTrue)
+			So(height, ShouldEqual, 0)
+			So(req.Header.SetIdempotencyKey("key"), ShouldBeNil)
+			So(req.Header.SetAsOfHeight(10), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.IdempotencyKey(), ShouldEqual, "key")
+			height, ok = decoded.Header.AsOfHeight()
+			So(ok, ShouldBeTrue)
+			So(height, ShouldEqual, 10)
+		})
+		Convey("The priority should be kept in the third extension field", func() {
+			So(req.Header.Priority(), ShouldEqual, InteractivePriority)
+			So(req.Header.SetIdempotencyKey("key"), ShouldBeNil)
+			So(req.Header.SetPriority(BatchPriority), ShouldBeNil)
+			_, ok := req.Header.AsOfHeight()
+			So(ok, ShouldBeFalse)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.IdempotencyKey(), ShouldEqual, "key")
+			So(decoded.Header.Priority(), ShouldEqual, BatchPriority)
+			So(decoded.Header.SetPriority(InteractivePriority), ShouldBeNil)
+			n, err := decoded.Header.Ext.Count()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("The max execution time should be kept in the fourth extension field", func() {
+			So(req.Header.MaxExecutionTime(), ShouldEqual, 0)
+			So(req.Header.SetMaxExecutionTime(time.Second), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.MaxExecutionTime(), ShouldEqual, time.Second)
+			So(decoded.Header.Priority(), ShouldEqual, InteractivePriority)
+			_, ok := decoded.Header.AsOfHeight()
+			So(ok, ShouldBeFalse)
+		})
+		Convey("The result cursor flag should be kept in the fifth extension field", func() {
+			So(req.Header.ResultCursor(), ShouldBeFalse)
+			So(req.Header.SetPriority(BatchPriority), ShouldBeNil)
+			So(req.Header.SetResultCursor(true), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.ResultCursor(), ShouldBeTrue)
+			So(decoded.Header.Priority(), ShouldEqual, BatchPriority)
+			So(decoded.Header.MaxExecutionTime(), ShouldEqual, 0)
+			So(decoded.Header.SetResultCursor(false), ShouldBeNil)
+			n, err := decoded.Header.Ext.Count()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+		})
+		Convey("The result max rows should be kept in the sixth extension field", func() {
+			So(req.Header.ResultMaxRows(), ShouldEqual, 0)
+			So(req.Header.SetResultMaxRows(100), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.ResultMaxRows(), ShouldEqual, 100)
+			So(decoded.Header.ResultCursor(), ShouldBeFalse)
+			So(decoded.Header.SetResultMaxRows(0), ShouldBeNil)
+			n, err := decoded.Header.Ext.Count()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("The attached databases should be kept in the seventh extension field", func() {
+			So(req.Header.AttachedDatabases(), ShouldBeNil)
+			So(req.Header.SetAttachedDatabases(map[string]proto.DatabaseID{
+				"b": "db2", "a": "db1",
+			}), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.AttachedDatabases(), ShouldResemble, map[string]proto.DatabaseID{
+				"a": "db1", "b": "db2",
+			})
+			So(decoded.Header.ResultMaxRows(), ShouldEqual, 0)
+			So(decoded.Header.SetAttachedDatabases(nil), ShouldBeNil)
+			n, err := decoded.Header.Ext.Count()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("The trace id should be kept with the signed request", func() {
+			So(req.Header.SetTraceID(strings.Repeat("x", MaxTraceIDLength+1)),
+				ShouldEqual, ErrTraceIDTooLong)
+			So(req.Header.SetIdempotencyKey("key"), ShouldBeNil)
+			So(req.Header.SetTraceID("0123456789abcdef"), ShouldBeNil)
+			So(req.Sign(priv), ShouldBeNil)
+			buf, err := utils.EncodeMsgPack(req)
+			So(err, ShouldBeNil)
+			var decoded *Request
+			So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+			So(decoded.Verify(), ShouldBeNil)
+			So(decoded.Header.TraceID(), ShouldEqual, "0123456789abcdef")
+			So(decoded.Header.IdempotencyKey(), ShouldEqual, "key")
+			So(decoded.Header.AttachedDatabases(), ShouldBeNil)
+			So(decoded.Header.SetTraceID(""), ShouldBeNil)
+			So(decoded.Header.TraceID(), ShouldEqual, "")
+			n, err := decoded.Header.Ext.Count()
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 1)
+		})
+		Convey("Extension fields should require a serialization version", func() {
+			So(req.Header.SetExt(SerialVersionLegacy, int32(1)), ShouldNotBeNil)
+			So(req.Header.SetExt(SerialVersionLegacy), ShouldBeNil)
+			enc, err := req.Header.RequestHeader.MarshalHash()
+			So(err, ShouldBeNil)
+			So(enc, ShouldResemble, legacy)
+		})
+	})
+	Convey("Given a block signed with extension fields", t, func() {
+		priv, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		var block = &Block{SignedHeader: SignedHeader{Header: Header{
+			Version:   0x01000000,
+			Producer:  testNodeID,
+			Timestamp: time.Now().UTC(),
+		}}}
+		So(block.SignedHeader.SetExt(CurrentSerialVersion, "trace"), ShouldBeNil)
+		So(block.PackAndSignBlock(priv), ShouldBeNil)
+
+		buf, err := utils.EncodeMsgPack(block)
+		So(err, ShouldBeNil)
+		var decoded *Block
+		So(utils.DecodeMsgPack(buf.Bytes(), &decoded), ShouldBeNil)
+		So(decoded.Verify(), ShouldBeNil)
+		So(decoded.BlockHash(), ShouldResemble, block.BlockHash())
+	})
+}
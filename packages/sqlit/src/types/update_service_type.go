@@ -48,7 +48,7 @@ func (sh *SignedUpdateServiceHeader) Verify() (err error) {
 }
 
 // Sign the request.
-func (sh *SignedUpdateServiceHeader) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (sh *SignedUpdateServiceHeader) Sign(signer asymmetric.Signer) (err error) {
 	return sh.DefaultHashSignVerifierImpl.Sign(&sh.UpdateServiceHeader, signer)
 }
 
@@ -58,7 +58,7 @@ func (s *UpdateService) Verify() error {
 }
 
 // Sign the request.
-func (s *UpdateService) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (s *UpdateService) Sign(signer asymmetric.Signer) (err error) {
 	// sign
 	return s.Header.Sign(signer)
 }
@@ -72,7 +72,7 @@ func (ub *UpdateBilling) Msgsize() int {
 }
 
 // Sign signs the transaction.
-func (ub *UpdateBilling) Sign(signer *asymmetric.PrivateKey) error {
+func (ub *UpdateBilling) Sign(signer asymmetric.Signer) error {
 	return ub.DefaultHashSignVerifierImpl.Sign(&ub.UpdateBillingHeader, signer)
 }
 
@@ -41,7 +41,7 @@ func NewUpdatePermission(header *UpdatePermissionHeader) *UpdatePermission {
 }
 
 // Sign implements interfaces/Transaction.Sign.
-func (up *UpdatePermission) Sign(signer *asymmetric.PrivateKey) (err error) {
+func (up *UpdatePermission) Sign(signer asymmetric.Signer) (err error) {
 	return up.DefaultHashSignVerifierImpl.Sign(&up.UpdatePermissionHeader, signer)
 }
 
@@ -0,0 +1,14 @@
+//go:build !linux && !darwin && !freebsd
+// +build !linux,!darwin,!freebsd
+
+package utils
+
+import (
+	"github.com/pkg/errors"
+)
+
+// FilesystemStat returns the total and available bytes of the filesystem containing path.
+func FilesystemStat(path string) (total, avail uint64, err error) {
+	err = errors.New("filesystem stat is not supported on this platform")
+	return
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+// +build linux darwin freebsd
+
+package utils
+
+import (
+	"golang.org/x/sys/unix"
+)
+
+// FilesystemStat returns the total and available bytes of the filesystem containing path.
+func FilesystemStat(path string) (total, avail uint64, err error) {
+	var buf unix.Statfs_t
+	if err = unix.Statfs(path, &buf); err != nil {
+		return
+	}
+	total = uint64(buf.Blocks) * uint64(buf.Bsize)
+	avail = uint64(buf.Bavail) * uint64(buf.Bsize)
+	return
+}
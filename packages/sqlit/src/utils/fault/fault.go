@@ -0,0 +1,48 @@
+// Package fault provides injectable fault points for chaos testing.
+//
+// The fault points are evaluated by rpc and sqlchain at message sending, disk writing and clock
+// reading. The injection API is only built with the fault build tag, the points are empty
+// functions in the regular builds, and cost a single atomic load in the fault builds until a test
+// installs a rule or a clock skew.
+package fault
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Point defines a fault injection point.
+type Point string
+
+const (
+	// RPCCall is evaluated before each rpc call, the key is "<node>/<method>".
+	RPCCall Point = "rpc.call"
+	// SQLChainCall is evaluated before each sqlchain peer call, the key is "<from>/<to>/<method>".
+	SQLChainCall Point = "sqlchain.call"
+	// SQLChainBlockWrite is evaluated before a block is persisted, the key is "<node>/<database>".
+	SQLChainBlockWrite Point = "sqlchain.block.write"
+	// SQLChainAckWrite is evaluated before an ack is persisted, the key is "<node>/<database>".
+	SQLChainAckWrite Point = "sqlchain.ack.write"
+)
+
+var (
+	// ErrDropped indicates that the message is dropped by an injected fault.
+	ErrDropped = errors.New("message dropped by fault injection")
+	// ErrDiskFull indicates that the disk write is rejected by an injected fault.
+	ErrDiskFull = errors.New("no space left on device")
+)
+
+// Fault is the combined effect of the rules fired at a point.
+type Fault struct {
+	Drop      bool
+	Err       error
+	Delay     time.Duration
+	Duplicate int
+}
+
+// Key joins the parts into a fault point key.
+func Key(parts ...string) string {
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,96 @@
+//go:build fault
+
+package fault
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestFault(t *testing.T) {
+	Convey("Given an empty fault registry", t, func() {
+		Clear()
+		Reset(Clear)
+
+		Convey("The points should be no-ops", func() {
+			So(Eval(RPCCall, Key("node", "method")), ShouldResemble, Fault{})
+			So(ClockSkew("node"), ShouldEqual, 0)
+			dup, err := Apply(context.Background(), SQLChainBlockWrite, "db")
+			So(dup, ShouldEqual, 0)
+			So(err, ShouldBeNil)
+		})
+		Convey("The rules should be matched by point and key", func() {
+			remove := Inject(Rule{
+				Point: RPCCall,
+				Match: func(key string) bool { return key == Key("node", "method") },
+				Err:   ErrDropped,
+			})
+			So(Eval(RPCCall, Key("node", "method")).Err, ShouldEqual, ErrDropped)
+			So(Eval(RPCCall, Key("node", "other")).Err, ShouldBeNil)
+			So(Eval(SQLChainCall, Key("node", "method")).Err, ShouldBeNil)
+			remove()
+			So(Eval(RPCCall, Key("node", "method")).Err, ShouldBeNil)
+			So(atomic.LoadInt32(&active), ShouldEqual, 0)
+		})
+		Convey("The rules should be combined and expired by count", func() {
+			Inject(Rule{Point: SQLChainAckWrite, Err: ErrDiskFull, Count: 1})
+			Inject(Rule{Point: SQLChainAckWrite, Delay: time.Millisecond, Duplicate: 1})
+			Inject(Rule{Point: SQLChainAckWrite, Delay: time.Millisecond, Duplicate: 2})
+			So(Eval(SQLChainAckWrite, "db"), ShouldResemble, Fault{
+				Err: ErrDiskFull, Delay: 2 * time.Millisecond, Duplicate: 3,
+			})
+			So(Eval(SQLChainAckWrite, "db"), ShouldResemble, Fault{
+				Delay: 2 * time.Millisecond, Duplicate: 3,
+			})
+		})
+		Convey("The rules should be fired by probability", func() {
+			Inject(Rule{Point: RPCCall, Err: ErrDropped, Probability: 0.5})
+			var fired int
+			for i := 0; i < 1000; i++ {
+				if Eval(RPCCall, "key").Err != nil {
+					fired++
+				}
+			}
+			So(fired, ShouldBeBetween, 300, 700)
+		})
+		Convey("The delay should be aborted by context", func() {
+			Inject(Rule{Point: RPCCall, Delay: time.Minute})
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			_, err := Apply(ctx, RPCCall, "key")
+			So(err, ShouldResemble, context.DeadlineExceeded)
+		})
+		Convey("The dropped operation should wait for the deadline", func() {
+			Inject(Rule{Point: RPCCall, Drop: true})
+			_, err := Apply(context.Background(), RPCCall, "key")
+			So(err, ShouldEqual, ErrDropped)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			begin := time.Now()
+			_, err = Apply(ctx, RPCCall, "key")
+			So(err, ShouldEqual, ErrDropped)
+			So(time.Since(begin), ShouldBeGreaterThanOrEqualTo, 10*time.Millisecond)
+		})
+		Convey("The clock skew should be set and removed", func() {
+			SetClockSkew("node", time.Second)
+			So(ClockSkew("node"), ShouldEqual, time.Second)
+			So(ClockSkew("other"), ShouldEqual, 0)
+			SetClockSkew("node", 0)
+			So(ClockSkew("node"), ShouldEqual, 0)
+			So(atomic.LoadInt32(&active), ShouldEqual, 0)
+		})
+		Convey("The partition should drop the calls across groups", func() {
+			heal := Partition([]string{"a", "b"}, []string{"c"})
+			So(Eval(SQLChainCall, Key("a", "c", "method")).Drop, ShouldBeTrue)
+			So(Eval(SQLChainCall, Key("c", "b", "method")).Drop, ShouldBeTrue)
+			So(Eval(SQLChainCall, Key("a", "b", "method")).Drop, ShouldBeFalse)
+			So(Eval(SQLChainCall, Key("a", "d", "method")).Drop, ShouldBeFalse)
+			heal()
+			So(Eval(SQLChainCall, Key("a", "c", "method")).Drop, ShouldBeFalse)
+		})
+	})
+}
@@ -0,0 +1,188 @@
+//go:build fault
+
+package fault
+
+import (
+	"context"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rule defines a fault injected at a point.
+type Rule struct {
+	// Point is the fault point where the rule applies.
+	Point Point
+	// Match selects the keys of the point, a nil Match selects all keys.
+	Match func(key string) bool
+	// Drop drops the operation as a lost message, the caller waits until the deadline of the
+	// context and gets ErrDropped.
+	Drop bool
+	// Err is returned to the caller instead of performing the operation.
+	Err error
+	// Delay is slept before performing the operation.
+	Delay time.Duration
+	// Duplicate is the extra times of performing the operation.
+	Duplicate int
+	// Probability is the probability of firing the rule, the rule always fires if it's 0.
+	Probability float64
+	// Count is the maximum firing times of the rule, the rule never expires if it's 0.
+	Count int
+}
+
+type rule struct {
+	Rule
+	fired int
+}
+
+type registry struct {
+	sync.Mutex
+	rules []*rule
+	skews map[string]time.Duration
+}
+
+var (
+	active int32
+	reg    = &registry{skews: make(map[string]time.Duration)}
+)
+
+// must be called with the registry locked.
+func (r *registry) update() {
+	if len(r.rules) > 0 || len(r.skews) > 0 {
+		atomic.StoreInt32(&active, 1)
+	} else {
+		atomic.StoreInt32(&active, 0)
+	}
+}
+
+// Inject installs the rule and returns the function to remove it.
+func Inject(r Rule) (remove func()) {
+	var nr = &rule{Rule: r}
+	reg.Lock()
+	defer reg.Unlock()
+	reg.rules = append(reg.rules, nr)
+	reg.update()
+	return func() {
+		reg.Lock()
+		defer reg.Unlock()
+		for i, v := range reg.rules {
+			if v == nr {
+				reg.rules = append(reg.rules[:i], reg.rules[i+1:]...)
+				break
+			}
+		}
+		reg.update()
+	}
+}
+
+// Clear removes all the rules and clock skews.
+func Clear() {
+	reg.Lock()
+	defer reg.Unlock()
+	reg.rules = nil
+	reg.skews = make(map[string]time.Duration)
+	reg.update()
+}
+
+// Eval fires the rules matching the key at the point and returns their combined effect: drop if
+// any rule drops, the first error, the sum of delays and the sum of duplicates.
+func Eval(p Point, key string) (f Fault) {
+	if atomic.LoadInt32(&active) == 0 {
+		return
+	}
+	reg.Lock()
+	defer reg.Unlock()
+	for _, r := range reg.rules {
+		if r.Point != p || (r.Match != nil && !r.Match(key)) {
+			continue
+		}
+		if r.Count > 0 && r.fired >= r.Count {
+			continue
+		}
+		if r.Probability > 0 && rand.Float64() >= r.Probability {
+			continue
+		}
+		r.fired++
+		f.Drop = f.Drop || r.Drop
+		if f.Err == nil {
+			f.Err = r.Err
+		}
+		f.Delay += r.Delay
+		f.Duplicate += r.Duplicate
+	}
+	return
+}
+
+// Apply evaluates the point, sleeps the delay and returns the duplicate times and the error of
+// the fault. The sleep is aborted with the context error if the context is done. A dropped
+// operation returns ErrDropped when the context is done, or at once if it has no deadline.
+func Apply(ctx context.Context, p Point, key string) (dup int, err error) {
+	var f = Eval(p, key)
+	if f.Drop {
+		if _, ok := ctx.Deadline(); ok {
+			<-ctx.Done()
+		}
+		err = ErrDropped
+		return
+	}
+	if f.Delay > 0 {
+		var timer = time.NewTimer(f.Delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		case <-timer.C:
+		}
+	}
+	dup, err = f.Duplicate, f.Err
+	return
+}
+
+// SetClockSkew sets the clock skew of the key, a zero skew removes it.
+func SetClockSkew(key string, d time.Duration) {
+	reg.Lock()
+	defer reg.Unlock()
+	if d == 0 {
+		delete(reg.skews, key)
+	} else {
+		reg.skews[key] = d
+	}
+	reg.update()
+}
+
+// ClockSkew returns the clock skew of the key.
+func ClockSkew(key string) time.Duration {
+	if atomic.LoadInt32(&active) == 0 {
+		return 0
+	}
+	reg.Lock()
+	defer reg.Unlock()
+	return reg.skews[key]
+}
+
+// Partition drops the sqlchain peer calls between the nodes in different groups, the nodes not
+// listed in any group are not affected. It returns the function to heal the partition.
+func Partition(groups ...[]string) (heal func()) {
+	var group = make(map[string]int)
+	for i, g := range groups {
+		for _, n := range g {
+			group[n] = i
+		}
+	}
+	return Inject(Rule{
+		Point: SQLChainCall,
+		Match: func(key string) bool {
+			var parts = strings.SplitN(key, "/", 3)
+			if len(parts) < 2 {
+				return false
+			}
+			from, ok1 := group[parts[0]]
+			to, ok2 := group[parts[1]]
+			return ok1 && ok2 && from != to
+		},
+		Drop: true,
+	})
+}
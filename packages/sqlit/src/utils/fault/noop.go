@@ -0,0 +1,23 @@
+//go:build !fault
+
+package fault
+
+import (
+	"context"
+	"time"
+)
+
+// Eval returns no fault without the fault build tag.
+func Eval(p Point, key string) (f Fault) {
+	return
+}
+
+// Apply returns no fault without the fault build tag.
+func Apply(ctx context.Context, p Point, key string) (dup int, err error) {
+	return
+}
+
+// ClockSkew returns no clock skew without the fault build tag.
+func ClockSkew(key string) time.Duration {
+	return 0
+}
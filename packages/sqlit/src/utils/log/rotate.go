@@ -0,0 +1,289 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultMaxLogSize defines the default size of a log file to rotate it.
+	DefaultMaxLogSize = 100 << 20
+	// DefaultMaxLogBackups defines the default count of the rotated log files to keep.
+	DefaultMaxLogBackups = 7
+
+	backupTimeFormat = "20060102T150405.000"
+	compressSuffix   = ".gz"
+)
+
+// RotateConfig defines the rotation and retention of a log file.
+type RotateConfig struct {
+	// MaxSize rotates the file once it exceeds the size in bytes, 0 disables the size rotation.
+	MaxSize int64
+	// Interval rotates the file once it's been written for the interval, 0 disables the time
+	// rotation.
+	Interval time.Duration
+	// MaxBackups is the max count of the rotated files kept, 0 keeps all of them.
+	MaxBackups int
+	// MaxAge removes the rotated files older than it, 0 keeps all of them.
+	MaxAge time.Duration
+	// Compress compresses the rotated files with gzip.
+	Compress bool
+}
+
+// DefaultRotateConfig returns the default rotation of the log files written by the daemons.
+func DefaultRotateConfig() RotateConfig {
+	return RotateConfig{
+		MaxSize:    DefaultMaxLogSize,
+		MaxBackups: DefaultMaxLogBackups,
+		Compress:   true,
+	}
+}
+
+// RotateWriter writes a log file, which is renamed to a timestamped backup once it's too large
+// or too old. The backups are compressed and removed by the retention in background.
+type RotateWriter struct {
+	sync.Mutex
+	path   string
+	cfg    RotateConfig
+	file   *os.File
+	size   int64
+	opened time.Time
+	closed bool
+
+	millCh chan struct{}
+	millWg sync.WaitGroup
+}
+
+var _ io.WriteCloser = (*RotateWriter)(nil)
+
+// NewRotateWriter opens the log file at path for appending with the rotation cfg.
+func NewRotateWriter(path string, cfg RotateConfig) (w *RotateWriter, err error) {
+	w = &RotateWriter{
+		path:   path,
+		cfg:    cfg,
+		millCh: make(chan struct{}, 1),
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, errors.Wrap(err, "create log dir failed")
+	}
+	if err = w.open(); err != nil {
+		return nil, err
+	}
+	w.millWg.Add(1)
+	go w.mill()
+	return
+}
+
+func (w *RotateWriter) open() (err error) {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "open log file failed")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "stat log file failed")
+	}
+	w.file, w.size, w.opened = f, info.Size(), time.Now()
+	return
+}
+
+// Write implements io.Writer.Write, the file is rotated before the write if it's due.
+func (w *RotateWriter) Write(p []byte) (n int, err error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+	if w.file == nil {
+		// the reopening failed on the last rotation
+		if err = w.open(); err != nil {
+			return
+		}
+	}
+	if (w.cfg.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.cfg.MaxSize) ||
+		(w.cfg.Interval > 0 && time.Since(w.opened) >= w.cfg.Interval) {
+		if err = w.rotate(); err != nil {
+			return
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return
+}
+
+// Rotate rotates the file right away if it's not empty.
+func (w *RotateWriter) Rotate() (err error) {
+	w.Lock()
+	defer w.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if w.file == nil || w.size == 0 {
+		return
+	}
+	return w.rotate()
+}
+
+// rotate renames the file to a backup and opens a new one, the caller must hold the lock.
+func (w *RotateWriter) rotate() (err error) {
+	if err = w.file.Close(); err != nil {
+		return errors.Wrap(err, "close log file failed")
+	}
+	w.file = nil
+	if err = os.Rename(w.path, w.backupName(time.Now())); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "rename log file failed")
+	}
+	if err = w.open(); err != nil {
+		return
+	}
+	select {
+	case w.millCh <- struct{}{}:
+	default:
+	}
+	return
+}
+
+// backupName returns the name of the backup rotated at t, such as "app-20060102T150405.000.log".
+func (w *RotateWriter) backupName(t time.Time) string {
+	var (
+		dir  = filepath.Dir(w.path)
+		base = filepath.Base(w.path)
+		ext  = filepath.Ext(base)
+	)
+	return filepath.Join(dir, strings.TrimSuffix(base, ext)+"-"+t.Format(backupTimeFormat)+ext)
+}
+
+// Close implements io.Closer.Close, it waits for the compression of the rotated files.
+func (w *RotateWriter) Close() (err error) {
+	w.Lock()
+	if !w.closed {
+		w.closed = true
+		if w.file != nil {
+			err = w.file.Close()
+			w.file = nil
+		}
+		close(w.millCh)
+	}
+	w.Unlock()
+	w.millWg.Wait()
+	return
+}
+
+// mill compresses and removes the backups after each rotation.
+func (w *RotateWriter) mill() {
+	defer w.millWg.Done()
+	for range w.millCh {
+		if err := w.millOnce(); err != nil {
+			// the log can't be written to itself
+			_, _ = io.WriteString(os.Stderr, "rotate log file failed: "+err.Error()+"\n")
+		}
+	}
+}
+
+type logBackup struct {
+	path string
+	at   time.Time
+}
+
+// backups returns the backups of the file ordered by the rotation time descending.
+func (w *RotateWriter) backups() (backups []logBackup, err error) {
+	var (
+		dir    = filepath.Dir(w.path)
+		base   = filepath.Base(w.path)
+		ext    = filepath.Ext(base)
+		prefix = strings.TrimSuffix(base, ext) + "-"
+	)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		var name = e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		var ts = strings.TrimSuffix(strings.TrimSuffix(name, compressSuffix), ext)
+		at, perr := time.ParseInLocation(backupTimeFormat, strings.TrimPrefix(ts, prefix), time.Local)
+		if perr != nil {
+			continue
+		}
+		backups = append(backups, logBackup{path: filepath.Join(dir, name), at: at})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].at.After(backups[j].at) })
+	return
+}
+
+func (w *RotateWriter) millOnce() (err error) {
+	backups, err := w.backups()
+	if err != nil {
+		return
+	}
+	var (
+		cutoff = time.Now().Add(-w.cfg.MaxAge)
+		keep   = backups[:0]
+	)
+	for i, b := range backups {
+		if (w.cfg.MaxBackups > 0 && i >= w.cfg.MaxBackups) || (w.cfg.MaxAge > 0 && b.at.Before(cutoff)) {
+			if rerr := os.Remove(b.path); rerr != nil && !os.IsNotExist(rerr) {
+				err = rerr
+			}
+			continue
+		}
+		keep = append(keep, b)
+	}
+	if !w.cfg.Compress {
+		return
+	}
+	for _, b := range keep {
+		if strings.HasSuffix(b.path, compressSuffix) {
+			continue
+		}
+		if cerr := compressFile(b.path); cerr != nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// compressFile compresses the file at path into path.gz and removes it.
+func compressFile(path string) (err error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.OpenFile(path+compressSuffix, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return
+	}
+	var zw = gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(path + compressSuffix)
+		return errors.Wrapf(err, "compress log file %s failed", path)
+	}
+	return os.Remove(path)
+}
+
+// SetRotatedOutput sets the standard logger output to the file at path with the rotation cfg.
+func SetRotatedOutput(path string, cfg RotateConfig) (w *RotateWriter, err error) {
+	if w, err = NewRotateWriter(path, cfg); err != nil {
+		return
+	}
+	SetOutput(w)
+	return
+}
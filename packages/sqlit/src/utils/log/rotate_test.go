@@ -0,0 +1,115 @@
+package log
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var path = filepath.Join(dir, "app.log")
+	w, err := NewRotateWriter(path, RotateConfig{MaxSize: 100, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var line = strings.Repeat("x", 59) + "\n"
+	for i := 0; i < 4; i++ {
+		if _, err = w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+		// the backups are named by the rotation time in milliseconds
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte(line)); err == nil {
+		t.Error("write to closed writer should fail")
+	}
+
+	// each write exceeding the size is rotated, the oldest backup is removed
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != line {
+		t.Errorf("unexpected log file content: %q", data)
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("unexpected backups: %v", backups)
+	}
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err = io.ReadAll(zr); err != nil || string(data) != line {
+		t.Errorf("unexpected backup content: %q, %v", data, err)
+	}
+	if plain, _ := filepath.Glob(filepath.Join(dir, "app-*.log")); len(plain) != 0 {
+		t.Errorf("uncompressed backups left: %v", plain)
+	}
+}
+
+func TestRotateWriterRetention(t *testing.T) {
+	dir, err := os.MkdirTemp("", "rotate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var (
+		path = filepath.Join(dir, "app.log")
+		old  = filepath.Join(dir, "app-"+time.Now().Add(-48*time.Hour).Format(backupTimeFormat)+".log")
+	)
+	if err = os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewRotateWriter(path, RotateConfig{Interval: time.Millisecond, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	// the file written for the interval is rotated
+	if _, err = w.Write([]byte("second\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = os.Stat(old); !os.IsNotExist(err) {
+		t.Errorf("expired backup should be removed: %v", err)
+	}
+	backups, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Fatalf("unexpected backups: %v", backups)
+	}
+	if data, _ := os.ReadFile(backups[0]); string(data) != "first\n" {
+		t.Errorf("unexpected backup content: %q", data)
+	}
+}
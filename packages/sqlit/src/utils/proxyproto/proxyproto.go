@@ -0,0 +1,211 @@
+// Package proxyproto implements the PROXY protocol v2 on the listeners, so that the nodes and the
+// services deployed behind an L4 load balancer see the addresses of the original clients for the
+// rate limiting and the audit logs.
+//
+// A listener with the PROXY protocol enabled requires the header on every connection, the
+// connections without a valid header are refused. The header is read on the first Read or
+// RemoteAddr call of a connection instead of in Accept, so that a slow client can't stall the
+// accept loop.
+package proxyproto
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultHeaderTimeout is the default timeout to read the PROXY header of a connection.
+const DefaultHeaderTimeout = 5 * time.Second
+
+const (
+	headerLen = 16
+
+	cmdLocal = 0x0
+	cmdProxy = 0x1
+
+	famTCP4 = 0x11
+	famTCP6 = 0x21
+)
+
+// signature is the magic prefix of a PROXY protocol v2 header.
+var signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+var (
+	// ErrNoHeader indicates a connection without the PROXY protocol v2 header.
+	ErrNoHeader = errors.New("proxy protocol header missing")
+	// ErrInvalidHeader indicates a malformed PROXY protocol v2 header.
+	ErrInvalidHeader = errors.New("invalid proxy protocol header")
+)
+
+// Listener wraps a net.Listener to read the PROXY protocol v2 header of the accepted connections.
+type Listener struct {
+	net.Listener
+	// HeaderTimeout is the timeout to read the header of a connection.
+	HeaderTimeout time.Duration
+}
+
+// NewListener returns a new Listener wrapping l with the default header timeout.
+func NewListener(l net.Listener) *Listener {
+	return &Listener{Listener: l, HeaderTimeout: DefaultHeaderTimeout}
+}
+
+// Wrap returns l wrapped by a Listener if enabled is set, or l unchanged.
+func Wrap(l net.Listener, enabled bool) net.Listener {
+	if !enabled {
+		return l
+	}
+	return NewListener(l)
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *Listener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{Conn: c, timeout: l.HeaderTimeout}, nil
+}
+
+// Conn is a connection accepted by a Listener, its addresses are the ones carried by the PROXY
+// header.
+type Conn struct {
+	net.Conn
+	timeout time.Duration
+
+	once   sync.Once
+	err    error
+	local  net.Addr
+	remote net.Addr
+}
+
+// Read reads data from the connection after the PROXY header.
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.Conn.Read(b)
+}
+
+// RemoteAddr returns the source address of the PROXY header, or the address of the peer if the
+// header carries none.
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+// LocalAddr returns the destination address of the PROXY header, or the local address if the
+// header carries none.
+func (c *Conn) LocalAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+func (c *Conn) readHeader() {
+	if c.timeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+		defer func() { _ = c.Conn.SetReadDeadline(time.Time{}) }()
+	}
+	if c.local, c.remote, c.err = ReadHeader(c.Conn); c.err != nil {
+		_ = c.Conn.Close()
+	}
+}
+
+// ReadHeader reads a PROXY protocol v2 header from r and returns the addresses it carries, the
+// addresses are nil for a LOCAL command or an unsupported address family.
+func ReadHeader(r io.Reader) (local, remote net.Addr, err error) {
+	var hdr [headerLen]byte
+	if _, err = io.ReadFull(r, hdr[:]); err != nil {
+		err = errors.Wrap(ErrNoHeader, err.Error())
+		return
+	}
+	if !bytes.Equal(hdr[:len(signature)], signature) {
+		err = ErrNoHeader
+		return
+	}
+	if hdr[12]>>4 != 0x2 {
+		err = errors.Wrapf(ErrInvalidHeader, "version %d", hdr[12]>>4)
+		return
+	}
+	var (
+		cmd  = hdr[12] & 0xf
+		fam  = hdr[13]
+		size = int(binary.BigEndian.Uint16(hdr[14:]))
+		body = make([]byte, size)
+	)
+	if cmd != cmdLocal && cmd != cmdProxy {
+		err = errors.Wrapf(ErrInvalidHeader, "command %d", cmd)
+		return
+	}
+	if _, err = io.ReadFull(r, body); err != nil {
+		err = errors.Wrap(ErrInvalidHeader, err.Error())
+		return
+	}
+	if cmd == cmdLocal {
+		return
+	}
+	var ipLen int
+	switch fam {
+	case famTCP4:
+		ipLen = net.IPv4len
+	case famTCP6:
+		ipLen = net.IPv6len
+	default:
+		// the addresses of the other families are kept as the ones of the peer
+		return
+	}
+	if size < 2*ipLen+4 {
+		err = errors.Wrapf(ErrInvalidHeader, "address length %d", size)
+		return
+	}
+	remote = &net.TCPAddr{
+		IP:   net.IP(body[:ipLen]),
+		Port: int(binary.BigEndian.Uint16(body[2*ipLen:])),
+	}
+	local = &net.TCPAddr{
+		IP:   net.IP(body[ipLen : 2*ipLen]),
+		Port: int(binary.BigEndian.Uint16(body[2*ipLen+2:])),
+	}
+	return
+}
+
+// WriteHeader writes a PROXY protocol v2 header of the PROXY command with the TCP addresses
+// local and remote to w, it's used by the tests and the tools which forward the connections.
+func WriteHeader(w io.Writer, local, remote *net.TCPAddr) (err error) {
+	var (
+		fam     byte = famTCP6
+		srcIP        = remote.IP.To16()
+		dstIP        = local.IP.To16()
+		src4         = remote.IP.To4()
+		dst4         = local.IP.To4()
+		buf     bytes.Buffer
+		portBuf [2]byte
+	)
+	if src4 != nil && dst4 != nil {
+		fam, srcIP, dstIP = famTCP4, src4, dst4
+	}
+	buf.Write(signature)
+	buf.WriteByte(0x20 | cmdProxy)
+	buf.WriteByte(fam)
+	binary.BigEndian.PutUint16(portBuf[:], uint16(2*len(srcIP)+4))
+	buf.Write(portBuf[:])
+	buf.Write(srcIP)
+	buf.Write(dstIP)
+	binary.BigEndian.PutUint16(portBuf[:], uint16(remote.Port))
+	buf.Write(portBuf[:])
+	binary.BigEndian.PutUint16(portBuf[:], uint16(local.Port))
+	buf.Write(portBuf[:])
+	_, err = w.Write(buf.Bytes())
+	return
+}
@@ -0,0 +1,110 @@
+package proxyproto
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestReadHeader(t *testing.T) {
+	Convey("The header should be read back as written", t, func() {
+		for _, c := range []struct{ local, remote *net.TCPAddr }{
+			{
+				&net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4661},
+				&net.TCPAddr{IP: net.ParseIP("192.168.1.2"), Port: 50000},
+			},
+			{
+				&net.TCPAddr{IP: net.ParseIP("fd00::1"), Port: 4661},
+				&net.TCPAddr{IP: net.ParseIP("2001:db8::2"), Port: 50000},
+			},
+		} {
+			var buf bytes.Buffer
+			So(WriteHeader(&buf, c.local, c.remote), ShouldBeNil)
+			buf.WriteString("payload")
+			local, remote, err := ReadHeader(&buf)
+			So(err, ShouldBeNil)
+			So(local.String(), ShouldEqual, c.local.String())
+			So(remote.String(), ShouldEqual, c.remote.String())
+			So(buf.String(), ShouldEqual, "payload")
+		}
+	})
+	Convey("A LOCAL command should carry no address", t, func() {
+		var hdr = append(append([]byte{}, signature...), 0x20, 0x00, 0x00, 0x00)
+		local, remote, err := ReadHeader(bytes.NewReader(hdr))
+		So(err, ShouldBeNil)
+		So(local, ShouldBeNil)
+		So(remote, ShouldBeNil)
+	})
+	Convey("The malformed headers should be refused", t, func() {
+		_, _, err := ReadHeader(bytes.NewReader([]byte("GET / HTTP/1.1\r\nHost: x\r\n\r\n")))
+		So(errors.Cause(err), ShouldEqual, ErrNoHeader)
+		_, _, err = ReadHeader(bytes.NewReader(
+			append(append([]byte{}, signature...), 0x10, 0x11, 0x00, 0x00)))
+		So(errors.Cause(err), ShouldEqual, ErrInvalidHeader)
+		_, _, err = ReadHeader(bytes.NewReader(
+			append(append([]byte{}, signature...), 0x21, 0x11, 0x00, 0x04, 1, 2, 3, 4)))
+		So(errors.Cause(err), ShouldEqual, ErrInvalidHeader)
+	})
+}
+
+func TestListener(t *testing.T) {
+	Convey("Given a dual-stack listener with the PROXY protocol", t, func() {
+		l, err := net.Listen("tcp", "[::]:0")
+		if err != nil {
+			SkipConvey("ipv6 is not available", func() {})
+			return
+		}
+		var pl = NewListener(l)
+		pl.HeaderTimeout = time.Second
+		defer pl.Close()
+		var port = l.Addr().(*net.TCPAddr).Port
+
+		var accepted = make(chan net.Conn, 1)
+		go func() {
+			for {
+				c, err := pl.Accept()
+				if err != nil {
+					return
+				}
+				accepted <- c
+			}
+		}()
+
+		Convey("The conns should see the addresses of the header over ipv4", func() {
+			c, err := net.Dial("tcp", (&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}).String())
+			So(err, ShouldBeNil)
+			defer c.Close()
+			var client = &net.TCPAddr{IP: net.ParseIP("203.0.113.7"), Port: 1234}
+			So(WriteHeader(c, c.RemoteAddr().(*net.TCPAddr), client), ShouldBeNil)
+			_, err = c.Write([]byte("ping"))
+			So(err, ShouldBeNil)
+
+			var s = <-accepted
+			defer s.Close()
+			So(s.RemoteAddr().String(), ShouldEqual, client.String())
+			var buf = make([]byte, 4)
+			_, err = io.ReadFull(s, buf)
+			So(err, ShouldBeNil)
+			So(string(buf), ShouldEqual, "ping")
+		})
+		Convey("The conns without the header should be refused", func() {
+			c, err := net.Dial("tcp", (&net.TCPAddr{IP: net.IPv6loopback, Port: port}).String())
+			if err != nil {
+				SkipSo(err, ShouldBeNil)
+				return
+			}
+			defer c.Close()
+			_, err = c.Write([]byte("GET / HTTP/1.1\r\n\r\n"))
+			So(err, ShouldBeNil)
+
+			var s = <-accepted
+			_, err = s.Read(make([]byte, 1))
+			So(errors.Cause(err), ShouldEqual, ErrNoHeader)
+		})
+	})
+}
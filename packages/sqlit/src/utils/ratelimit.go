@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RateLimiter defines a token bucket limiting throughput to a fixed amount of units (usually
+// bytes) per second. A nil limiter imposes no limit.
+type RateLimiter struct {
+	sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter returns a new rate limiter allowing rate units per second with a burst of one
+// second worth of units. It returns nil if rate is not positive, which means unlimited.
+func NewRateLimiter(rate int64) *RateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		rate:   float64(rate),
+		burst:  float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+// Rate returns the configured rate of the limiter, 0 means unlimited.
+func (l *RateLimiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	return int64(l.rate)
+}
+
+// reserve takes n units from the bucket and returns the time to wait before they are available.
+func (l *RateLimiter) reserve(n int) (d time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := time.Now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens < 0 {
+		d = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	return
+}
+
+// WaitN blocks until n units are available or the context is done. Requests larger than the
+// burst size are allowed and paid back by subsequent callers.
+func (l *RateLimiter) WaitN(ctx context.Context, n int) (err error) {
+	if l == nil || n <= 0 {
+		return
+	}
+	d := l.reserve(n)
+	if d <= 0 {
+		return
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	return
+}
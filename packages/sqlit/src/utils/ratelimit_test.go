@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRateLimiter(t *testing.T) {
+	Convey("test nil rate limiter", t, func() {
+		var l = NewRateLimiter(0)
+		So(l, ShouldBeNil)
+		So(l.Rate(), ShouldEqual, 0)
+		So(l.WaitN(context.Background(), 1<<30), ShouldBeNil)
+	})
+	Convey("test rate limiter", t, func() {
+		var l = NewRateLimiter(1000)
+		So(l.Rate(), ShouldEqual, 1000)
+
+		// burst is available immediately
+		start := time.Now()
+		So(l.WaitN(context.Background(), 1000), ShouldBeNil)
+		So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+
+		// the next 200 units should take about 200ms
+		start = time.Now()
+		So(l.WaitN(context.Background(), 200), ShouldBeNil)
+		So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 150*time.Millisecond)
+
+		// context cancellation aborts waiting
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		So(l.WaitN(ctx, 10000), ShouldNotBeNil)
+	})
+}
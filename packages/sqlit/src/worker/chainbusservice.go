@@ -35,6 +35,8 @@ type BusService struct {
 	blockCount       uint32
 	sqlChainProfiles map[proto.DatabaseID]*types.SQLChainProfile
 	sqlChainState    map[proto.DatabaseID]map[proto.AccountAddress]*types.PermStat
+	// the last profiles of the databases removed from the chain, kept until they're dropped
+	removedProfiles map[proto.DatabaseID]*types.SQLChainProfile
 }
 
 // NewBusService creates a new chain bus instance.
@@ -50,6 +52,8 @@ func NewBusService(
 		cancel:        ccl,
 		checkInterval: checkInterval,
 		localAddress:  addr,
+
+		removedProfiles: make(map[proto.DatabaseID]*types.SQLChainProfile),
 	}
 	// State initialization: fetch last block and update fields `blockCount` and `sqlChainProfiles`
 	var _, profiles, count = bs.requestLastBlock()
@@ -85,6 +89,14 @@ func (bs *BusService) updateState(count uint32, profiles []*types.SQLChainProfil
 			}
 		}
 	}
+	for id, v := range bs.sqlChainProfiles {
+		if _, ok := rebuilt[id]; !ok {
+			bs.removedProfiles[id] = v
+		}
+	}
+	for id := range rebuilt {
+		delete(bs.removedProfiles, id)
+	}
 	atomic.StoreUint32(&bs.blockCount, count)
 	bs.sqlChainProfiles = rebuilt
 	bs.sqlChainState = sqlchainState
@@ -183,6 +195,24 @@ func (bs *BusService) RequestSQLProfile(dbID proto.DatabaseID) (p *types.SQLChai
 	return
 }
 
+// RequestLastSQLProfile gets specified database profile, or the last profile of the database if
+// it has been removed from the chain.
+func (bs *BusService) RequestLastSQLProfile(dbID proto.DatabaseID) (p *types.SQLChainProfile, ok bool) {
+	bs.lock.RLock()
+	defer bs.lock.RUnlock()
+	if p, ok = bs.sqlChainProfiles[dbID]; !ok {
+		p, ok = bs.removedProfiles[dbID]
+	}
+	return
+}
+
+// forgetSQLProfile forgets the last profile of the removed database once it's dropped.
+func (bs *BusService) forgetSQLProfile(dbID proto.DatabaseID) {
+	bs.lock.Lock()
+	defer bs.lock.Unlock()
+	delete(bs.removedProfiles, dbID)
+}
+
 // RequestPermStat fetches permission state from bus service.
 func (bs *BusService) RequestPermStat(
 	dbID proto.DatabaseID, user proto.AccountAddress) (permStat *types.PermStat, ok bool,
@@ -23,6 +23,7 @@ import (
 	"sqlit/src/proto"
 	"sqlit/src/route"
 	rpc "sqlit/src/rpc/mux"
+	"sqlit/src/types"
 	"sqlit/src/utils"
 )
 
@@ -189,3 +190,37 @@ func initNodeChainBusService() (cleanupFunc func(), server *rpc.Server, err erro
 
 	return
 }
+
+func TestBusServiceRemovedProfiles(t *testing.T) {
+	Convey("Given a bus service with the database profiles", t, func() {
+		var (
+			bs = &BusService{
+				removedProfiles: make(map[proto.DatabaseID]*types.SQLChainProfile),
+			}
+			db1 = &types.SQLChainProfile{ID: "db1"}
+			db2 = &types.SQLChainProfile{ID: "db2"}
+		)
+		bs.updateState(1, []*types.SQLChainProfile{db1, db2})
+
+		Convey("The last profile of the removed database should be kept until forgotten", func() {
+			bs.updateState(2, []*types.SQLChainProfile{db1})
+			_, ok := bs.RequestSQLProfile("db2")
+			So(ok, ShouldBeFalse)
+			p, ok := bs.RequestLastSQLProfile("db2")
+			So(ok, ShouldBeTrue)
+			So(p, ShouldEqual, db2)
+			p, ok = bs.RequestLastSQLProfile("db1")
+			So(ok, ShouldBeTrue)
+			So(p, ShouldEqual, db1)
+
+			bs.forgetSQLProfile("db2")
+			_, ok = bs.RequestLastSQLProfile("db2")
+			So(ok, ShouldBeFalse)
+		})
+		Convey("The profile of the database added back should be served", func() {
+			bs.updateState(2, []*types.SQLChainProfile{db1})
+			bs.updateState(3, []*types.SQLChainProfile{db1, db2})
+			So(bs.removedProfiles, ShouldBeEmpty)
+		})
+	})
+}
@@ -3,6 +3,7 @@ package worker
 
 import (
 	"context"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"sync"
@@ -15,6 +16,7 @@ import (
 	"sqlit/src/crypto"
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/kms"
+	"sqlit/src/crypto/symmetric"
 	"sqlit/src/bftraft"
 	kt "sqlit/src/bftraft/types"
 	kl "sqlit/src/bftraft/wal"
@@ -24,6 +26,8 @@ import (
 	"sqlit/src/types"
 	"sqlit/src/utils/log"
 	x "sqlit/src/dpos"
+	xi "sqlit/src/dpos/interfaces"
+	xs "sqlit/src/dpos/sqlite"
 )
 
 const (
@@ -54,6 +58,10 @@ const (
 	// LogWaitTimeout defines the missing log wait timeout config.
 	LogWaitTimeout = 10 * time.Second
 
+	// LeaderLeaseDuration defines the lease of the leader renewed by the followers, a leader
+	// failing to renew its lease refuses the queries.
+	LeaderLeaseDuration = 15 * time.Second
+
 	// SlowQuerySampleSize defines the maximum slow query log size (default: 1KB).
 	SlowQuerySampleSize = 1 << 10
 )
@@ -72,6 +80,28 @@ type Database struct {
 	mux            *DBBftRaftMuxService
 	privateKey     *asymmetric.PrivateKey
 	accountAddr    proto.AccountAddress
+	quota          *quotaTracker
+	idempotency    *idempotencyCache
+	admission      *admissionControl
+	asOf           *asOfCache
+	cursors        *cursorRegistry
+	stats          *queryStats
+	procs          *processList
+	compression    *compressionStats
+	payload        *payloadStats
+	audit          *writeAuditor
+	ttl            *ttlJob
+	jobs           *jobScheduler
+	fdw            *foreignFetcher
+	latency        *peerLatency
+	clock          *peerClock
+	reqClock       *requestClock
+	load           *loadTracker
+	prober         *latencyProber
+	inflight       int32
+	diverged       uint32
+	standby        uint32
+	promoteLock    sync.Mutex
 }
 
 // NewDatabase create a single database instance using config.
@@ -106,6 +136,30 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		connSeqEvictCh: make(chan uint64, 1),
 		privateKey:     privateKey,
 		accountAddr:    accountAddr,
+		quota: newQuotaTracker(
+			cfg.DatabaseID, cfg.DataDir, cfg.SpaceLimit, cfg.QuotaWarningThresholds),
+		idempotency: newIdempotencyCache(cfg.IdempotencyWindow),
+		admission:   newAdmissionControl(cfg.Admission),
+		asOf: newAsOfCache(
+			filepath.Join(cfg.RootDir, AsOfDirName, string(cfg.DatabaseID)), DefaultAsOfSnapshotCount),
+		cursors:  newCursorRegistry(cfg.ResultLimit),
+		stats:    newQueryStats(MaxQueryStatsEntries),
+		procs:    newProcessList(MaxTrackedConnections),
+		audit:    newWriteAuditor(cfg.Audit),
+		latency:  newPeerLatency(),
+		clock:    newPeerClock(),
+		reqClock: newRequestClock(),
+		prober:   newLatencyProber(cfg.LatencyProbeInterval),
+	}
+	db.load = newLoadTracker(time.Now(), db.quota.usage())
+	db.compression = newCompressionStats()
+	db.payload = newPayloadStats(cfg.DatabaseID, cfg.PayloadAlert, time.Now())
+	payloadSizeVars.Set(string(db.dbID), db.payload.vars)
+	db.ttl = newTTLJob(cfg.TTLCheckInterval)
+	db.jobs = newJobScheduler(cfg.JobCheckInterval)
+	db.fdw = newForeignFetcher(cfg.ForeignDataHosts)
+	if cfg.ResultCompressor != nil {
+		resultCompressionVars.Set(string(db.dbID), db.compression.vars)
 	}
 
 	defer func() {
@@ -127,15 +181,15 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		}
 	}()
 
-	// init storage
-	storageFile := filepath.Join(cfg.DataDir, StorageFileName)
-	storageDSN, err := storage.NewDSN(storageFile)
-	if err != nil {
+	// remove the historical states left by the previous run
+	if err = db.asOf.reset(); err != nil {
 		return
 	}
 
-	if cfg.EncryptionKey != "" {
-		storageDSN.AddParam("_crypto_key", cfg.EncryptionKey)
+	// init storage
+	storageDSN, err := newStorageDSN(cfg, filepath.Join(cfg.DataDir, StorageFileName))
+	if err != nil {
+		return
 	}
 
 	// init chain
@@ -144,6 +198,11 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		return
 	}
 
+	// refuse to serve with a sqlite build diverged from the peers
+	if err = db.handshakePeers(context.Background(), peers); err != nil {
+		return
+	}
+
 	chainCfg := &sqlchain.Config{
 		DatabaseID:      cfg.DatabaseID,
 		ChainFilePrefix: chainFile,
@@ -161,6 +220,16 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		LastBillingHeight: cfg.LastBillingHeight,
 		UpdatePeriod:      cfg.UpdateBlockCount,
 		IsolationLevel:    cfg.IsolationLevel,
+		SyncReadLimiter:   cfg.SyncReadLimiter,
+		SyncWriteLimiter:  cfg.SyncWriteLimiter,
+		ApplyConcurrency:  cfg.ApplyConcurrency,
+		GroupCommitDelay:  cfg.GroupCommitDelay,
+		SnapshotReads:     cfg.SnapshotReads,
+		ResultLimit:       resultLimit(cfg.ResultLimit),
+		Packing:           blockPacking(cfg.BlockPacking),
+
+		AckReconcileWindow: cfg.AckReconcileWindow,
+		LeaderTimestamp:    cfg.LeaderTimestamp,
 	}
 	if db.chain, err = sqlchain.NewChain(chainCfg); err != nil {
 		return
@@ -169,8 +238,23 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		return
 	}
 
+	if cfg.Standby {
+		atomic.StoreUint32(&db.standby, 1)
+	} else if err = db.startBftRaft(peers); err != nil {
+		return
+	}
+
+	// init sequence eviction processor
+	go db.evictSequences()
+
+	return
+}
+
+// startBftRaft starts the bftraft runtime of the database as a peer, and the background audit of
+// the sampled writes.
+func (db *Database) startBftRaft(peers *proto.Peers) (err error) {
 	// init bftraft config
-	bftraftWalPath := filepath.Join(cfg.DataDir, BftRaftWalFileName)
+	bftraftWalPath := filepath.Join(db.cfg.DataDir, BftRaftWalFileName)
 	if db.bftraftWal, err = kl.NewLevelDBWal(bftraftWalPath); err != nil {
 		err = errors.Wrap(err, "init bftraft log pool failed")
 		return
@@ -183,6 +267,7 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		PrepareTimeout:   PrepareTimeout,
 		CommitTimeout:    CommitTimeout,
 		LogWaitTimeout:   LogWaitTimeout,
+		LeaseDuration:    LeaderLeaseDuration,
 		Peers:            peers,
 		Wal:              db.bftraftWal,
 		NodeID:           db.nodeID,
@@ -190,6 +275,7 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		ServiceName:      DBBftRaftRPCName,
 		ApplyMethodName:  DBBftRaftApplyMethodName,
 		FetchMethodName:  DBBftRaftFetchMethodName,
+		Join:             db.cfg.Join,
 	}
 
 	// create bftraft runtime
@@ -205,14 +291,91 @@ func NewDatabase(cfg *DBConfig, peers *proto.Peers,
 		return
 	}
 
-	// init sequence eviction processor
-	go db.evictSequences()
+	// audit the sampled writes in background
+	if db.audit != nil {
+		db.audit.start(db)
+	}
+
+	// delete the expired rows of the tables with a row ttl in background
+	if db.ttl != nil {
+		ttlVars.Set(string(db.dbID), db.ttl.vars)
+		db.ttl.start(db)
+	}
+
+	// run the due scheduled jobs in background
+	if db.jobs != nil {
+		jobVars.Set(string(db.dbID), db.jobs.vars)
+		db.jobs.start(db)
+	}
+
+	// fetch the endpoints of the foreign tables in background
+	if db.fdw != nil {
+		fdwVars.Set(string(db.dbID), db.fdw.vars)
+		db.fdw.start(db)
+	}
+
+	// measure the round-trip times and the clock skews of the peers in background
+	requestSkewVars.Set(string(db.dbID), db.reqClock.vars)
+	if db.prober != nil {
+		latencyVars.Set(string(db.dbID), db.latency.vars)
+		peerSkewVars.Set(string(db.dbID), db.clock.vars)
+		db.prober.start(db)
+	}
 
 	return
 }
 
-// UpdatePeers defines peers update query interface.
+// isStandby returns whether the database is a warm standby, the bftraft runtime is only available
+// if it's not.
+func (db *Database) isStandby() bool {
+	return atomic.LoadUint32(&db.standby) == 1
+}
+
+// promote follows the peers update of a standby database, and starts serving as a peer once it's
+// included in the peers.
+func (db *Database) promote(peers *proto.Peers) (err error) {
+	db.promoteLock.Lock()
+	defer db.promoteLock.Unlock()
+	if !db.isStandby() {
+		return db.UpdatePeers(peers)
+	}
+	if err = db.chain.UpdatePeers(peers); err != nil {
+		return
+	}
+	if _, found := peers.Find(db.nodeID); !found {
+		return
+	}
+	// the state is synced from the blocks as a standby
+	db.cfg.Join = true
+	if err = db.startBftRaft(peers); err != nil {
+		if db.bftraftRuntime != nil {
+			if shutdownErr := db.bftraftRuntime.Shutdown(); shutdownErr != nil {
+				log.WithError(shutdownErr).Error("shutdown bftraft runtime failed")
+			}
+			db.bftraftRuntime = nil
+		}
+		if db.bftraftWal != nil {
+			db.bftraftWal.Close()
+			db.bftraftWal = nil
+		}
+		return
+	}
+	atomic.StoreUint32(&db.standby, 0)
+	log.WithField("db", db.dbID).Info("standby database promoted")
+	return
+}
+
+// UpdatePeers defines peers update query interface. A standby database is promoted once it's
+// included in the peers.
 func (db *Database) UpdatePeers(peers *proto.Peers) (err error) {
+	if db.isStandby() {
+		return db.promote(peers)
+	}
+
+	// the term of the peers only goes forward
+	if peers, err = nextTermPeers(db.bftraftRuntime.Peers(), peers, db.privateKey); err != nil {
+		return
+	}
 	if err = db.bftraftRuntime.UpdatePeers(peers); err != nil {
 		return
 	}
@@ -233,6 +396,18 @@ func (db *Database) Query(request *types.Request) (response *types.Response, err
 		tmStart     = time.Now()
 	)
 
+	if db.isDiverged() {
+		err = ErrSQLiteBuildMismatch
+		return
+	}
+	if db.isStandby() {
+		err = ErrStandbyMiner
+		return
+	}
+
+	atomic.AddInt32(&db.inflight, 1)
+	defer atomic.AddInt32(&db.inflight, -1)
+
 	// log the query if the underlying storage layer take too long to response
 	slowQueryTimer := time.AfterFunc(db.cfg.SlowQueryTime, func() {
 		// mark as slow query
@@ -246,19 +421,133 @@ func (db *Database) Query(request *types.Request) (response *types.Response, err
 			db.logSlow(request, true, tmStart)
 		}
 	}()
+	defer func() {
+		if err != nil {
+			// the trace id correlates the failure with the logs of the request node
+			log.WithFields(log.Fields{
+				"db":       request.Header.DatabaseID,
+				"req_node": request.Header.NodeID,
+				"type":     request.Header.QueryType.String(),
+				"trace":    request.Header.TraceID(),
+				"elapsed":  time.Since(tmStart).String(),
+			}).WithError(err).Info("query failed")
+		}
+	}()
+
+	// track the client connection, the running query is interrupted by KILL
+	proc := db.procs.begin(request, tmStart)
+	defer func() {
+		if db.procs.end(proc, time.Now()) && err != nil {
+			err = errors.Wrapf(ErrQueryKilled, "connection %d: %v", request.Header.ConnectionID, err)
+		}
+	}()
+	request.SetContext(proc.bind(request.GetContext()))
+
+	// queue or shed the batch queries while the interactive latency degrades, before the
+	// idempotency check so that a shed write is not cached for its retries
+	var admitted func()
+	if admitted, err = db.admission.admit(request.GetContext(), request.Header.Priority()); err != nil {
+		return
+	}
+	defer admitted()
+
+	// refuse the queries exceeding the cost budget of the request before they're executed or
+	// replicated, and before the idempotency check so that a retry with a larger budget runs
+	if err = db.chain.CheckCost(request.GetContext(), request); err != nil {
+		return
+	}
+
+	// deduplicate the retries of a write request by its idempotency key
+	if key := request.Header.IdempotencyKey(); key != "" &&
+		request.Header.QueryType == types.WriteQuery {
+		// the cached response is shared by the requesting node, so the signature must be checked
+		if err = request.Verify(); err != nil {
+			err = errors.Wrap(err, "failed to verify idempotent request")
+			return
+		}
+		e, first := db.idempotency.begin(request.Header.NodeID, key, time.Now())
+		db.load.cache(!first)
+		if !first {
+			<-e.done
+			return e.resp, e.err
+		}
+		defer func() { db.idempotency.finish(e, response, err) }()
+	}
 
 	switch request.Header.QueryType {
 	case types.ReadQuery:
-		if tracker, response, err = db.chain.Query(request, false); err != nil {
-			err = errors.Wrap(err, "failed to query read query")
+		// a leader may be deposed in a partition without knowing it
+		if err = db.checkLease(); err != nil {
+			return
+		}
+		if isShowQueryStats(request) {
+			if !db.chain.IsLeader() {
+				err = errors.Wrap(ErrNotLeader, "query stats are kept on the leader")
+				return
+			}
+			response = db.stats.response(db.nodeID, request)
+			break
+		}
+		if isShowProcessList(request) {
+			if !db.chain.IsLeader() {
+				err = errors.Wrap(ErrNotLeader, "connections are tracked on the leader")
+				return
+			}
+			response = db.procs.response(db.nodeID, request)
+			break
+		}
+		// the follower serving a stale read marks the response with the staleness bound of its
+		// state since the last contact with the leader before the query is executed
+		var (
+			stale       = request.Header.StaleRead() && !db.chain.IsLeader()
+			lastContact time.Time
+		)
+		if stale {
+			lastContact = db.bftraftRuntime.LastContact()
+			if _, err = staleReadBound(lastContact, tmStart); err != nil {
+				return
+			}
+		}
+		ctx, cancel := withMaxExecutionTime(request.GetContext(), request)
+		request.SetContext(ctx)
+		tracker, response, err = db.chain.Query(request, false)
+		cancel()
+		if err != nil {
+			err = errors.Wrap(executionError(ctx, request, err), "failed to query read query")
 			return
 		}
+		if stale {
+			bound, _ := staleReadBound(lastContact, time.Now())
+			if err = response.Header.SetStaleness(bound); err != nil {
+				return
+			}
+		}
+		if tracker.Cursor != nil {
+			// the rest rows of the spilled result are fetched by the request node later
+			if response.Cursor, err = db.cursors.add(request, tracker.Cursor); err != nil {
+				return
+			}
+		}
 	case types.WriteQuery:
+		// the permission of KILL is checked by the dbms
+		if id, ok := parseKillQuery(request); ok {
+			if response, err = db.kill(request, id); err != nil {
+				return
+			}
+			break
+		}
+		// check storage quota first, wal/bftraft/chain database size is not included
+		if err = db.quota.check(); err != nil {
+			return
+		}
 		if db.cfg.UseEventualConsistency {
 			// reset context
-			request.SetContext(context.Background())
-			if tracker, response, err = db.chain.Query(request, true); err != nil {
-				err = errors.Wrap(err, "failed to execute with eventual consistency")
+			ctx, cancel := withMaxExecutionTime(proc.bind(context.Background()), request)
+			request.SetContext(ctx)
+			tracker, response, err = db.chain.Query(request, true)
+			cancel()
+			if err != nil {
+				err = errors.Wrap(executionError(ctx, request, err), "failed to execute with eventual consistency")
 				return
 			}
 		} else {
@@ -267,6 +556,9 @@ func (db *Database) Query(request *types.Request) (response *types.Response, err
 				return
 			}
 		}
+		if db.audit != nil {
+			db.audit.sample(request)
+		}
 	default:
 		// TODO(xq262144): verbose errors with custom error structure
 		return nil, errors.Wrap(ErrInvalidRequest, "invalid query type")
@@ -274,17 +566,32 @@ func (db *Database) Query(request *types.Request) (response *types.Response, err
 
 	response.Header.ResponseAccount = db.accountAddr
 
-	// build hash
+	// build hash and sign
 	if err = response.BuildHash(); err != nil {
 		err = errors.Wrap(err, "failed to build response hash")
 		return
 	}
+	if err = response.Sign(db.privateKey); err != nil {
+		err = errors.Wrap(err, "failed to sign response")
+		return
+	}
 
 	if err = db.chain.AddResponse(&response.Header); err != nil {
 		log.WithError(err).Debug("failed to add response to index")
 		return
 	}
-	tracker.UpdateResp(response)
+	if tracker != nil {
+		tracker.UpdateResp(response)
+		if db.chain.IsLeader() {
+			var rows = response.Header.RowCount
+			if request.Header.QueryType == types.WriteQuery {
+				rows = uint64(response.Header.AffectedRows)
+			}
+			db.stats.record(request, time.Since(tmStart), rows, time.Now())
+		}
+	}
+	db.load.record(time.Since(tmStart))
+	db.payload.record(request, response, time.Now())
 
 	return
 }
@@ -311,16 +618,30 @@ func (db *Database) logSlow(request *types.Request, isFinished bool, tmStart tim
 		querySample += "..."
 	}
 
+	var elapsed = time.Since(tmStart)
+	slowQueries.record(SlowQuery{
+		DatabaseID: request.Header.DatabaseID,
+		NodeID:     request.Header.NodeID,
+		Trace:      request.Header.TraceID(),
+		Type:       request.Header.QueryType.String(),
+		Count:      request.Header.BatchCount,
+		Sample:     querySample,
+		Start:      tmStart,
+		Elapsed:    elapsed,
+		Finished:   isFinished,
+	})
+
 	log.WithFields(log.Fields{
 		"finished": isFinished,
 		"db":       request.Header.DatabaseID,
 		"req_time": request.Header.Timestamp.String(),
 		"req_node": request.Header.NodeID,
+		"trace":    request.Header.TraceID(),
 		"count":    request.Header.BatchCount,
 		"type":     request.Header.QueryType.String(),
 		"sample":   querySample,
 		"start":    tmStart.String(),
-		"elapsed":  time.Since(tmStart).String(),
+		"elapsed":  elapsed.String(),
 	}).Error("slow query detected")
 }
 
@@ -336,6 +657,32 @@ func (db *Database) Ack(ack *types.Ack) (err error) {
 
 // Shutdown stop database handles and stop service the database.
 func (db *Database) Shutdown() (err error) {
+	if db.audit != nil {
+		// stop auditing before the chain and the as-of states are released
+		db.audit.stop()
+	}
+
+	if db.prober != nil {
+		db.prober.stop()
+		latencyVars.Delete(string(db.dbID))
+		peerSkewVars.Delete(string(db.dbID))
+	}
+	if db.ttl != nil {
+		db.ttl.stop()
+		ttlVars.Delete(string(db.dbID))
+	}
+	if db.jobs != nil {
+		db.jobs.stop()
+		jobVars.Delete(string(db.dbID))
+	}
+	if db.fdw != nil {
+		db.fdw.stop()
+		fdwVars.Delete(string(db.dbID))
+	}
+	requestSkewVars.Delete(string(db.dbID))
+	resultCompressionVars.Delete(string(db.dbID))
+	payloadSizeVars.Delete(string(db.dbID))
+
 	if db.bftraftRuntime != nil {
 		// shutdown, stop bftraft
 		if err = db.bftraftRuntime.Shutdown(); err != nil {
@@ -358,6 +705,20 @@ func (db *Database) Shutdown() (err error) {
 		}
 	}
 
+	if db.quota != nil {
+		db.quota.close()
+	}
+
+	if db.cursors != nil {
+		db.cursors.close()
+	}
+
+	if db.asOf != nil {
+		if err = db.asOf.reset(); err != nil {
+			log.WithError(err).Warning("remove as-of states failed")
+		}
+	}
+
 	if db.connSeqEvictCh != nil {
 		// stop connection sequence evictions
 		select {
@@ -386,27 +747,10 @@ func (db *Database) Destroy() (err error) {
 }
 
 func (db *Database) writeQuery(request *types.Request) (tracker *x.QueryTracker, response *types.Response, err error) {
-	// check database size first, wal/bftraft/chain database size is not included
-	if db.cfg.SpaceLimit > 0 {
-		path := filepath.Join(db.cfg.DataDir, StorageFileName)
-		var statInfo os.FileInfo
-		if statInfo, err = os.Stat(path); err != nil {
-			if !os.IsNotExist(err) {
-				return
-			}
-		} else {
-			if uint64(statInfo.Size()) > db.cfg.SpaceLimit {
-				// rejected
-				err = ErrSpaceLimitExceeded
-				return
-			}
-		}
-	}
-
 	// call bftraft runtime Process
 	var result interface{}
 	if result, _, err = db.bftraftRuntime.Apply(request.GetContext(), request); err != nil {
-		err = errors.Wrap(err, "apply failed")
+		err = errors.Wrap(leaderError(err), "apply failed")
 		return
 	}
 
@@ -423,6 +767,24 @@ func (db *Database) writeQuery(request *types.Request) (tracker *x.QueryTracker,
 	return
 }
 
+// withMaxExecutionTime returns the context bounded by the max execution time of the request, the
+// sqlite driver interrupts the running statement when the context is done.
+func withMaxExecutionTime(ctx context.Context, req *types.Request) (context.Context, context.CancelFunc) {
+	if d := req.Header.MaxExecutionTime(); d > 0 {
+		return context.WithTimeout(ctx, d)
+	}
+	return context.WithCancel(ctx)
+}
+
+// executionError returns ErrExecutionTimeout if err is caused by the max execution time of req.
+func executionError(ctx context.Context, req *types.Request, err error) error {
+	if err != nil && req.Header.MaxExecutionTime() > 0 && ctx.Err() == context.DeadlineExceeded {
+		return errors.Wrapf(ErrExecutionTimeout, "interrupted after %s: %v",
+			req.Header.MaxExecutionTime(), err)
+	}
+	return err
+}
+
 func (db *Database) saveAck(ackHeader *types.SignedAckHeader) (err error) {
 	return db.chain.VerifyAndPushAckedQuery(ackHeader)
 }
@@ -430,3 +792,44 @@ func (db *Database) saveAck(ackHeader *types.SignedAckHeader) (err error) {
 func getLocalTime() time.Time {
 	return time.Now().UTC()
 }
+
+// newStorageDSN returns the storage dsn of file with the encryption and pool settings of cfg.
+func newStorageDSN(cfg *DBConfig, file string) (dsn *storage.DSN, err error) {
+	if dsn, err = storage.NewDSN(file); err != nil {
+		return
+	}
+	if cfg.EncryptionKey != "" {
+		dsn.AddParam(xs.CryptoKeyParam, storageKey(cfg.DatabaseID, cfg.EncryptionKey))
+		if cfg.AllowUnencrypted {
+			dsn.AddParam(xs.CryptoRequireParam, "false")
+		}
+	}
+	xs.AddPoolParams(dsn, poolOptions(cfg.Pool))
+	dsn.AddParam(xs.SynchronousParam, synchronousMode(cfg.Durability))
+	return
+}
+
+// storageKey derives the page encryption key of the database file from the database key, so
+// that the same key issued for different databases results in different file keys.
+func storageKey(dbID proto.DatabaseID, key string) string {
+	return hex.EncodeToString(symmetric.KeyDerivation([]byte(key), []byte(dbID)))
+}
+
+// poolOptions returns the storage connection pool options of the pool meta, the zero settings are
+// replaced by the defaults.
+func poolOptions(pool types.PoolMeta) (opts xi.PoolOptions) {
+	opts = xs.DefaultPoolOptions
+	if pool.MaxReaders != 0 {
+		opts.MaxReaders = pool.MaxReaders
+	}
+	if pool.BusyTimeout != 0 {
+		opts.BusyTimeout = time.Duration(pool.BusyTimeout) * time.Millisecond
+	}
+	if pool.CacheSize != 0 {
+		opts.CacheSize = pool.CacheSize
+	}
+	if pool.MMapSize != 0 {
+		opts.MMapSize = pool.MMapSize
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+const (
+	// DefaultAdmissionLatencyTarget defines the default interactive query latency above which the
+	// batch queries are throttled.
+	DefaultAdmissionLatencyTarget = 100 * time.Millisecond
+	// DefaultBatchConcurrency defines the default max count of running batch queries of a
+	// database while throttled.
+	DefaultBatchConcurrency = 1
+	// DefaultBatchQueueSize defines the default max count of waiting batch queries of a database.
+	DefaultBatchQueueSize = 64
+	// DefaultBatchQueueTimeout defines the default time a batch query waits before it is shed.
+	DefaultBatchQueueTimeout = 5 * time.Second
+
+	// the weight of a new sample in the moving average of the interactive latency
+	admissionLatencyWeight = 0.2
+	// the latency is considered recovered if there is no interactive query for a while
+	admissionSampleTTL = 10 * time.Second
+)
+
+// admissionControl protects the interactive queries of a database from the batch ones. It keeps
+// the moving average of the interactive latency, and limits the running batch queries while the
+// latency is above the target, the other batch queries wait in a bounded queue or are shed.
+type admissionControl struct {
+	sync.Mutex
+	target       time.Duration
+	concurrency  int
+	queueSize    int
+	queueTimeout time.Duration
+
+	latency time.Duration // moving average of the interactive latency
+	sampled time.Time
+	running int // running batch queries
+	queued  int // waiting batch queries
+	changed chan struct{}
+}
+
+func newAdmissionControl(cfg *conf.AdmissionInfo) *admissionControl {
+	c := &admissionControl{
+		target:       DefaultAdmissionLatencyTarget,
+		concurrency:  DefaultBatchConcurrency,
+		queueSize:    DefaultBatchQueueSize,
+		queueTimeout: DefaultBatchQueueTimeout,
+		changed:      make(chan struct{}),
+	}
+	if cfg != nil {
+		if cfg.LatencyTarget > 0 {
+			c.target = cfg.LatencyTarget
+		}
+		if cfg.BatchConcurrency > 0 {
+			c.concurrency = cfg.BatchConcurrency
+		}
+		if cfg.BatchQueueSize > 0 {
+			c.queueSize = cfg.BatchQueueSize
+		}
+		if cfg.BatchQueueTimeout > 0 {
+			c.queueTimeout = cfg.BatchQueueTimeout
+		}
+	}
+	return c
+}
+
+// degraded returns whether the interactive latency is above the target, the caller should hold
+// the lock.
+func (c *admissionControl) degraded(now time.Time) bool {
+	return c.latency > c.target && now.Sub(c.sampled) < admissionSampleTTL
+}
+
+// notify wakes up the waiting batch queries, the caller should hold the lock.
+func (c *admissionControl) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// observe adds an interactive latency sample.
+func (c *admissionControl) observe(latency time.Duration, now time.Time) {
+	c.Lock()
+	defer c.Unlock()
+	wasDegraded := c.degraded(now)
+	if c.sampled.IsZero() {
+		c.latency = latency
+	} else {
+		c.latency += time.Duration(admissionLatencyWeight * float64(latency-c.latency))
+	}
+	c.sampled = now
+	if wasDegraded && !c.degraded(now) {
+		c.notify()
+	}
+}
+
+// tryAcquire acquires a running slot of batch query, the caller should hold the lock.
+func (c *admissionControl) tryAcquire(now time.Time) bool {
+	if c.degraded(now) && c.running >= c.concurrency {
+		return false
+	}
+	c.running++
+	return true
+}
+
+func (c *admissionControl) release() {
+	c.Lock()
+	defer c.Unlock()
+	c.running--
+	c.notify()
+}
+
+// admit admits a query of the priority class, the returned done func should be called after the
+// query is finished. The batch queries may wait or be shed with ErrOverloaded.
+func (c *admissionControl) admit(ctx context.Context, priority types.QueryPriority) (
+	done func(), err error,
+) {
+	if priority != types.BatchPriority {
+		start := time.Now()
+		return func() { c.observe(time.Since(start), time.Now()) }, nil
+	}
+
+	c.Lock()
+	if c.tryAcquire(time.Now()) {
+		c.Unlock()
+		return c.release, nil
+	}
+	if c.queued >= c.queueSize {
+		c.Unlock()
+		return nil, errors.Wrapf(ErrOverloaded, "%d batch queries queued", c.queueSize)
+	}
+	c.queued++
+	defer func() {
+		c.Lock()
+		c.queued--
+		c.Unlock()
+	}()
+	timeout := time.NewTimer(c.queueTimeout)
+	defer timeout.Stop()
+	for {
+		changed := c.changed
+		c.Unlock()
+		select {
+		case <-changed:
+		case <-timeout.C:
+			return nil, errors.Wrapf(ErrOverloaded, "batch query queued over %s", c.queueTimeout)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		c.Lock()
+		if c.tryAcquire(time.Now()) {
+			c.Unlock()
+			return c.release, nil
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package worker
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+func TestAdmissionControl(t *testing.T) {
+	Convey("Given an admission control", t, func() {
+		c := newAdmissionControl(&conf.AdmissionInfo{
+			LatencyTarget:     10 * time.Millisecond,
+			BatchConcurrency:  1,
+			BatchQueueSize:    1,
+			BatchQueueTimeout: 50 * time.Millisecond,
+		})
+		ctx := context.Background()
+
+		Convey("The batch queries should run unlimited while the latency is fine", func() {
+			c.observe(time.Millisecond, time.Now())
+			for i := 0; i < 3; i++ {
+				_, err := c.admit(ctx, types.BatchPriority)
+				So(err, ShouldBeNil)
+			}
+			So(c.running, ShouldEqual, 3)
+		})
+		Convey("The batch queries should be queued and shed while the latency degrades", func() {
+			c.observe(time.Second, time.Now())
+			So(c.degraded(time.Now()), ShouldBeTrue)
+
+			done, err := c.admit(ctx, types.BatchPriority)
+			So(err, ShouldBeNil)
+
+			// the queue is full while the second batch query waits
+			var (
+				queuedDone func()
+				queuedErr  = make(chan error)
+			)
+			go func() {
+				var err error
+				queuedDone, err = c.admit(ctx, types.BatchPriority)
+				queuedErr <- err
+			}()
+			for i := 0; i < 100; i++ {
+				c.Lock()
+				queued := c.queued
+				c.Unlock()
+				if queued > 0 {
+					break
+				}
+				time.Sleep(time.Millisecond)
+			}
+			_, err = c.admit(ctx, types.BatchPriority)
+			So(errors.Cause(err), ShouldEqual, ErrOverloaded)
+
+			// the waiting query runs after the running one is finished
+			done()
+			So(<-queuedErr, ShouldBeNil)
+			So(queuedDone, ShouldNotBeNil)
+
+			// the next one is shed after the queue timeout
+			_, err = c.admit(ctx, types.BatchPriority)
+			So(errors.Cause(err), ShouldEqual, ErrOverloaded)
+			queuedDone()
+
+			// the interactive queries are always admitted
+			interactive, err := c.admit(ctx, types.InteractivePriority)
+			So(err, ShouldBeNil)
+			interactive()
+		})
+		Convey("The batch queries should resume after the latency recovers", func() {
+			now := time.Now()
+			c.observe(time.Second, now)
+			done, err := c.admit(ctx, types.BatchPriority)
+			So(err, ShouldBeNil)
+			defer done()
+			for i := 0; i < 50 && c.degraded(now); i++ {
+				c.observe(time.Millisecond, now)
+			}
+			So(c.degraded(now), ShouldBeFalse)
+			So(c.degraded(now.Add(admissionSampleTTL)), ShouldBeFalse)
+			_, err = c.admit(ctx, types.BatchPriority)
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,276 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/storage"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// AsOfDirName defines the dir of the historical states rebuilt for the as-of queries.
+	AsOfDirName = "asof"
+	// DefaultAsOfSnapshotCount defines the max count of the historical states cached per
+	// database.
+	DefaultAsOfSnapshotCount = 4
+
+	asOfFileExt = ".db3"
+)
+
+// asOfSnapshot defines the historical state of a database at a sqlchain height.
+type asOfSnapshot struct {
+	height int32
+	path   string
+	strg   *xs.SQLite3
+	used   time.Time
+}
+
+func (s *asOfSnapshot) close() {
+	if err := s.strg.Close(); err != nil {
+		log.WithField("height", s.height).WithError(err).Warning("close as-of snapshot failed")
+	}
+	removeStateFiles(s.path)
+}
+
+// removeStateFiles removes the sqlite file at path with its journal files.
+func removeStateFiles(path string) {
+	for _, suffix := range []string{"", "-wal", "-shm"} {
+		_ = os.Remove(path + suffix)
+	}
+}
+
+// asOfCache keeps the least recently used historical states of a database. A missing state is
+// rebuilt by replaying the local sqlchain blocks from genesis, the replays are serialized so that
+// the concurrent queries at the same height share the result.
+type asOfCache struct {
+	sync.Mutex
+	dir       string
+	size      int
+	snapshots []*asOfSnapshot
+}
+
+func newAsOfCache(dir string, size int) *asOfCache {
+	if size <= 0 {
+		size = DefaultAsOfSnapshotCount
+	}
+	return &asOfCache{
+		dir:  dir,
+		size: size,
+	}
+}
+
+// reset removes the cached states and the files left by the previous run.
+func (c *asOfCache) reset() (err error) {
+	c.Lock()
+	defer c.Unlock()
+	for _, s := range c.snapshots {
+		s.close()
+	}
+	c.snapshots = nil
+	return os.RemoveAll(c.dir)
+}
+
+// get returns the cached state at height, it rebuilds the state with db if missing.
+func (c *asOfCache) get(ctx context.Context, db *Database, height int32) (
+	s *asOfSnapshot, err error,
+) {
+	for _, v := range c.snapshots {
+		if v.height == height {
+			v.used = time.Now()
+			db.load.cache(true)
+			return v, nil
+		}
+	}
+	db.load.cache(false)
+	if s, err = c.build(ctx, db, height); err != nil {
+		return
+	}
+	if len(c.snapshots) >= c.size {
+		var lru int
+		for i, v := range c.snapshots {
+			if v.used.Before(c.snapshots[lru].used) {
+				lru = i
+			}
+		}
+		c.snapshots[lru].close()
+		c.snapshots = append(c.snapshots[:lru], c.snapshots[lru+1:]...)
+	}
+	c.snapshots = append(c.snapshots, s)
+	return
+}
+
+// build replays the blocks of db up to height into a new state file.
+func (c *asOfCache) build(ctx context.Context, db *Database, height int32) (
+	s *asOfSnapshot, err error,
+) {
+	var (
+		start = time.Now()
+		path  = filepath.Join(c.dir, fmt.Sprintf("%d%s", height, asOfFileExt))
+		dsn   *storage.DSN
+		strg  *xs.SQLite3
+		count int
+	)
+	if err = os.MkdirAll(c.dir, 0755); err != nil {
+		return
+	}
+	if dsn, err = newStorageDSN(db.cfg, path); err != nil {
+		return
+	}
+	if strg, err = xs.NewSqlite(dsn.Format()); err != nil {
+		return
+	}
+
+	st := x.NewState(sql.IsolationLevel(db.cfg.IsolationLevel), db.nodeID, strg)
+	for h := int32(0); h <= height && err == nil; h++ {
+		var block *types.Block
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		if block, err = db.chain.FetchBlock(h); err != nil || block == nil {
+			continue
+		}
+		if err = st.ReplayBlockWithContext(ctx, block); err != nil {
+			err = errors.Wrapf(err, "replay block at height %d", h)
+		}
+		count++
+	}
+	if cerr := st.Close(err == nil); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		strg, err = xs.NewSqlite(dsn.Format())
+	}
+	if err != nil {
+		removeStateFiles(path)
+		return
+	}
+
+	s = &asOfSnapshot{
+		height: height,
+		path:   path,
+		strg:   strg,
+		used:   time.Now(),
+	}
+	log.WithFields(log.Fields{
+		"db":      db.dbID,
+		"height":  height,
+		"blocks":  count,
+		"elapsed": time.Since(start).String(),
+	}).Info("rebuilt as-of state")
+	return
+}
+
+// query runs the read query req on the state of db at height.
+func (c *asOfCache) query(
+	ctx context.Context, db *Database, req *types.Request, height int32) (
+	resp *types.Response, err error,
+) {
+	c.Lock()
+	defer c.Unlock()
+	var s *asOfSnapshot
+	if s, err = c.get(ctx, db, height); err != nil {
+		return
+	}
+	return x.QuerySnapshot(ctx, s.strg.Reader(), db.nodeID, req, resultLimit(db.cfg.ResultLimit))
+}
+
+// queryAsOf runs the read query req on the state of the database at the sqlchain height. The
+// clone databases are seeded from a snapshot, so their states before the snapshot cannot be
+// rebuilt from the local blocks.
+func (db *Database) queryAsOf(
+	ctx context.Context, req *types.Request, height int32) (resp *types.Response, err error,
+) {
+	if db.cfg.Source != "" {
+		err = errors.Wrap(ErrInvalidAsOfHeight, "history of clone database is unavailable")
+		return
+	}
+	var head int32
+	if _, _, head, err = db.chain.FetchBlockByCount(-1); err != nil {
+		return
+	}
+	if height < 0 || height > head {
+		err = errors.Wrapf(ErrInvalidAsOfHeight, "height %d out of range [0, %d]", height, head)
+		return
+	}
+	if resp, err = db.asOf.query(ctx, db, req, height); err != nil {
+		return
+	}
+	resp.Header.ResponseAccount = db.accountAddr
+	return
+}
+
+// AsOfQuery serves the read query req from the historical state of the database at the height
+// set in the request header. The response is never acknowledged or packed into blocks.
+func (dbms *DBMS) AsOfQuery(req *types.Request) (res *types.Response, err error) {
+	if req.Header.QueryType != types.ReadQuery {
+		err = errors.Wrap(ErrInvalidRequest, "as-of query is read-only")
+		return
+	}
+	height, ok := req.Header.AsOfHeight()
+	if !ok {
+		err = errors.Wrap(ErrInvalidRequest, "as-of height is not set")
+		return
+	}
+	// the height is in the extension fields, which must be covered by the signature
+	if err = req.Verify(); err != nil {
+		return
+	}
+
+	// check permission
+	addr, err := crypto.PubKeyHash(req.Header.Signee)
+	if err != nil {
+		return
+	}
+	err = dbms.checkPermission(addr, req.Header.DatabaseID, req.Header.QueryType, req.Payload.Queries)
+	if err != nil {
+		return
+	}
+
+	db, exists := dbms.getMeta(req.Header.DatabaseID)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	ctx, cancel := withMaxExecutionTime(req.GetContext(), req)
+	defer cancel()
+	if res, err = db.queryAsOf(ctx, req, height); err != nil {
+		err = executionError(ctx, req, err)
+		return
+	}
+	if err = res.BuildHash(); err != nil {
+		err = errors.Wrap(err, "failed to build response hash")
+		return
+	}
+	if err = res.Sign(db.privateKey); err != nil {
+		err = errors.Wrap(err, "failed to sign response")
+	}
+	return
+}
+
+// AsOfQuery rpc, called by client to read the historical state of the database.
+func (rpc *DBMSRPCService) AsOfQuery(req *types.Request, res *types.Response) (err error) {
+	// verify query is sent from the request node
+	if req.Envelope.NodeID.String() != string(req.Header.NodeID) {
+		err = errors.Wrap(ErrInvalidRequest, "request node id mismatch in as-of query")
+		return
+	}
+
+	var r *types.Response
+	if r, err = rpc.dbms.AsOfQuery(req); err != nil {
+		return
+	}
+	*res = *r
+	return
+}
@@ -0,0 +1,355 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+	"hash"
+	"math/rand"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	chash "sqlit/src/crypto/hash"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/storage"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultAuditMaxPending defines the default max count of sampled writes waiting for the audit.
+	DefaultAuditMaxPending = 16
+	// AuditTimeout defines the max time to audit a write on the leader or a follower.
+	AuditTimeout = 5 * time.Minute
+
+	auditShadowPrefix = "audit-"
+)
+
+// AuditWriteReq defines the request of the leader to re-execute a sampled write on a shadow copy
+// of the database state at the sqlchain height.
+type AuditWriteReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+	Height     int32
+	Request    *types.Request
+}
+
+// AuditWriteResp defines the response of an audit write request.
+type AuditWriteResp struct {
+	Hash chash.Hash
+}
+
+// writeAuditor samples the writes served by the leader and audits them in the background. Each
+// sampled write is re-executed on shadow copies of the state at the same height by the leader and
+// a follower, a divergence of the result hashes means the write is not deterministic, which
+// is usually a gap of the query sanitizer.
+type writeAuditor struct {
+	rate    float64
+	pending chan *types.Request
+	stopCh  chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+}
+
+func newWriteAuditor(cfg *conf.AuditInfo) *writeAuditor {
+	if cfg == nil || cfg.SampleRate <= 0 {
+		return nil
+	}
+	var max = cfg.MaxPending
+	if max <= 0 {
+		max = DefaultAuditMaxPending
+	}
+	return &writeAuditor{
+		rate:    cfg.SampleRate,
+		pending: make(chan *types.Request, max),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+func (a *writeAuditor) start(db *Database) {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		for {
+			select {
+			case <-a.stopCh:
+				return
+			case req := <-a.pending:
+				db.auditWrite(req)
+			}
+		}
+	}()
+}
+
+func (a *writeAuditor) stop() {
+	a.once.Do(func() { close(a.stopCh) })
+	a.wg.Wait()
+}
+
+// sample queues the write request req for the audit at the sample rate, it's skipped if the
+// audit falls behind.
+func (a *writeAuditor) sample(req *types.Request) {
+	if rand.Float64() >= a.rate {
+		return
+	}
+	select {
+	case a.pending <- req:
+	default:
+		log.WithField("db", req.Header.DatabaseID).Debug("audit falls behind, skip sampled write")
+	}
+}
+
+// auditWrite re-executes the write request req on a shadow copy of the latest block state and
+// compares the result hash with a follower, the divergence is logged with the offending sql.
+func (db *Database) auditWrite(req *types.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), AuditTimeout)
+	defer cancel()
+	go func() {
+		select {
+		case <-db.audit.stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	var le = log.WithFields(log.Fields{"db": db.dbID, "trace": req.Header.TraceID()})
+	_, _, height, err := db.chain.FetchBlockByCount(-1)
+	if err != nil {
+		le.WithError(err).Warning("audit write failed to fetch head block")
+		return
+	}
+	var follower proto.NodeID
+	for _, s := range db.bftraftRuntime.Peers().Servers {
+		if s != db.nodeID {
+			follower = s
+			break
+		}
+	}
+	if follower == "" {
+		return
+	}
+
+	var (
+		local chash.Hash
+		resp  = &AuditWriteResp{}
+	)
+	if local, err = db.shadowWrite(ctx, req, height); err != nil {
+		le.WithError(err).Warning("audit write failed on shadow copy")
+		return
+	}
+	if err = mux.NewCaller().CallNodeWithContext(ctx, follower, route.DBSAuditWrite.String(),
+		&AuditWriteReq{DatabaseID: db.dbID, Height: height, Request: req}, resp,
+	); err != nil {
+		le.WithField("follower", follower).WithError(err).Warning("audit write failed on follower")
+		return
+	}
+	if !local.IsEqual(&resp.Hash) {
+		var queries = make([]string, len(req.Payload.Queries))
+		for i, q := range req.Payload.Queries {
+			queries[i] = q.Pattern
+		}
+		le.WithFields(log.Fields{
+			"height":   height,
+			"follower": follower,
+			"local":    local.String(),
+			"remote":   resp.Hash.String(),
+			"request":  req.Header.Hash().String(),
+			"sql":      strings.Join(queries, "; "),
+		}).Error("audited write diverged from follower, the query is not deterministic")
+	}
+}
+
+// shadowWrite executes the write request req on a shadow copy of the database state at height,
+// and returns the hash of the results and the resulting state.
+func (db *Database) shadowWrite(ctx context.Context, req *types.Request, height int32) (
+	h chash.Hash, err error,
+) {
+	if db.cfg.Source != "" {
+		err = errors.Wrap(ErrInvalidAsOfHeight, "history of clone database is unavailable")
+		return
+	}
+	var head int32
+	if _, _, head, err = db.chain.FetchBlockByCount(-1); err != nil {
+		return
+	}
+	if height < 0 || height > head {
+		err = errors.Wrapf(ErrInvalidAsOfHeight, "height %d out of range [0, %d]", height, head)
+		return
+	}
+
+	var (
+		path = filepath.Join(db.asOf.dir, fmt.Sprintf("%s%d-%d%s",
+			auditShadowPrefix, height, rand.Int63(), asOfFileExt))
+		dsn  *storage.DSN
+		strg *xs.SQLite3
+	)
+	defer removeStateFiles(path)
+	if err = db.asOf.copy(ctx, db, height, path); err != nil {
+		return
+	}
+	if dsn, err = newStorageDSN(db.cfg, path); err != nil {
+		return
+	}
+	if strg, err = xs.NewSqlite(dsn.Format()); err != nil {
+		return
+	}
+
+	var (
+		sh       = sha256.New()
+		st       = x.NewState(sql.IsolationLevel(db.cfg.IsolationLevel), db.nodeID, strg)
+		resp     *types.Response
+		queryErr error
+	)
+	// the ids generated by the write are derived from the leader timestamp, pin it to the request
+	// timestamp to audit the write the same way on all the miners
+	st.SetClock(func() time.Time { return req.Header.Timestamp })
+	if _, resp, queryErr = st.QueryWithContext(ctx, req, true); queryErr != nil {
+		fmt.Fprintf(sh, "error:%v\n", errors.Cause(queryErr))
+	} else {
+		fmt.Fprintf(sh, "affected:%d,last:%d\n",
+			resp.Header.AffectedRows, resp.Header.LastInsertID)
+	}
+	if err = st.Close(true); err != nil {
+		return
+	}
+
+	if strg, err = xs.NewSqlite(dsn.Format()); err != nil {
+		return
+	}
+	defer func() { _ = strg.Close() }()
+	if err = hashState(ctx, strg.Reader(), sh); err != nil {
+		return
+	}
+	err = h.SetBytes(sh.Sum(nil))
+	return
+}
+
+// hashState writes the schema and the rows of all tables in db to h, the rows are scanned in
+// the primary key order.
+func hashState(ctx context.Context, db *sql.DB, h hash.Hash) (err error) {
+	var tables []string
+	if err = func() (err error) {
+		var rows *sql.Rows
+		if rows, err = db.QueryContext(ctx,
+			`SELECT "type", "name", "sql" FROM "sqlite_master" ORDER BY "type", "name"`,
+		); err != nil {
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var typ, name string
+			var ddl sql.NullString
+			if err = rows.Scan(&typ, &name, &ddl); err != nil {
+				return
+			}
+			fmt.Fprintf(h, "%s:%s:%s\n", typ, name, ddl.String)
+			if typ == "table" && !strings.HasPrefix(name, "sqlite_") {
+				tables = append(tables, name)
+			}
+		}
+		return rows.Err()
+	}(); err != nil {
+		return errors.Wrap(err, "hash schema")
+	}
+	for _, t := range tables {
+		if err = hashTable(ctx, db, t, h); err != nil {
+			return errors.Wrapf(err, "hash table %s", t)
+		}
+	}
+	return
+}
+
+func hashTable(ctx context.Context, db *sql.DB, table string, h hash.Hash) (err error) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx,
+		`SELECT * FROM "`+strings.Replace(table, `"`, `""`, -1)+`"`,
+	); err != nil {
+		return
+	}
+	defer rows.Close()
+	var columns []string
+	if columns, err = rows.Columns(); err != nil {
+		return
+	}
+	var (
+		values = make([]interface{}, len(columns))
+		dest   = make([]interface{}, len(columns))
+	)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		for _, v := range values {
+			if err = writeHashValue(h, v); err != nil {
+				return
+			}
+		}
+		fmt.Fprintln(h)
+	}
+	return rows.Err()
+}
+
+// copy copies the state of db at height to path, the state is rebuilt if missing.
+func (c *asOfCache) copy(ctx context.Context, db *Database, height int32, path string) (err error) {
+	c.Lock()
+	defer c.Unlock()
+	var s *asOfSnapshot
+	if s, err = c.get(ctx, db, height); err != nil {
+		return
+	}
+	return s.strg.Backup(ctx, path)
+}
+
+// AuditWrite handles the audit write request from the leader of the database. The write is
+// executed on a shadow copy, the local state is never changed.
+func (dbms *DBMS) AuditWrite(node proto.NodeID, req *AuditWriteReq) (resp *AuditWriteResp, err error) {
+	if req.Request == nil || req.Request.Header.QueryType != types.WriteQuery {
+		err = errors.Wrap(ErrInvalidRequest, "audit request is not a write")
+		return
+	}
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	if db.isStandby() {
+		err = ErrStandbyMiner
+		return
+	}
+	if _, found := db.bftraftRuntime.Peers().Find(node); !found {
+		err = errors.Wrapf(ErrPermissionDeny, "node %s is not a peer", node)
+		return
+	}
+	// the audited write must be the one signed by the client
+	if err = req.Request.Verify(); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), AuditTimeout)
+	defer cancel()
+	resp = &AuditWriteResp{}
+	resp.Hash, err = db.shadowWrite(ctx, req.Request, req.Height)
+	return
+}
+
+// AuditWrite rpc, called by the leader of a database to audit a sampled write.
+func (rpc *DBMSRPCService) AuditWrite(req *AuditWriteReq, resp *AuditWriteResp) (err error) {
+	var r *AuditWriteResp
+	if r, err = rpc.dbms.AuditWrite(req.GetNodeID().ToNodeID(), req); err != nil {
+		return
+	}
+	*resp = *r
+	return
+}
@@ -0,0 +1,60 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/types"
+)
+
+func TestHashState(t *testing.T) {
+	Convey("Given two sqlite states", t, func() {
+		dir, err := os.MkdirTemp("", "audit")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(dir) })
+
+		var hashOf = func(name string, stmts ...string) []byte {
+			strg, err := xs.NewSqlite(filepath.Join(dir, name))
+			So(err, ShouldBeNil)
+			defer strg.Close()
+			for _, s := range stmts {
+				_, err = strg.Writer().Exec(s)
+				So(err, ShouldBeNil)
+			}
+			h := sha256.New()
+			So(hashState(context.Background(), strg.Reader(), h), ShouldBeNil)
+			return h.Sum(nil)
+		}
+		var schema = `CREATE TABLE "t" ("k" TEXT PRIMARY KEY, "v" INTEGER) WITHOUT ROWID`
+
+		a := hashOf("a.db3", schema, `INSERT INTO "t" VALUES ('b', 2), ('a', 1)`)
+		b := hashOf("b.db3", schema, `INSERT INTO "t" VALUES ('a', 1)`, `INSERT INTO "t" VALUES ('b', 2)`)
+		c := hashOf("c.db3", schema, `INSERT INTO "t" VALUES ('a', 1), ('b', 3)`)
+		So(a, ShouldResemble, b)
+		So(a, ShouldNotResemble, c)
+	})
+}
+
+func TestWriteAuditor(t *testing.T) {
+	Convey("Given the write audit config", t, func() {
+		So(newWriteAuditor(nil), ShouldBeNil)
+		So(newWriteAuditor(&conf.AuditInfo{}), ShouldBeNil)
+
+		a := newWriteAuditor(&conf.AuditInfo{SampleRate: 1, MaxPending: 2})
+		So(a, ShouldNotBeNil)
+		for i := 0; i < 3; i++ {
+			a.sample(&types.Request{})
+		}
+		// the sampled writes over the pending limit are skipped
+		So(len(a.pending), ShouldEqual, 2)
+		a.stop()
+		a.stop()
+	})
+}
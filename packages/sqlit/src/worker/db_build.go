@@ -0,0 +1,146 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/utils/log"
+)
+
+// PeerHandshakeTimeout defines the max time to exchange the SQLite build with a peer.
+const PeerHandshakeTimeout = 5 * time.Second
+
+// PeerHandshakeReq defines the request of a miner exchanging its SQLite build with the peers of a
+// database on startup.
+type PeerHandshakeReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+	Build      *xs.BuildInfo
+}
+
+// PeerHandshakeResp defines the response of a peer handshake request.
+type PeerHandshakeResp struct {
+	Build *xs.BuildInfo
+}
+
+// checkBuild returns ErrSQLiteBuildMismatch with the differences if the build of node differs
+// from the local one.
+func checkBuild(node proto.NodeID, build *xs.BuildInfo) (err error) {
+	var local *xs.BuildInfo
+	if local, err = xs.LocalBuildInfo(); err != nil {
+		return
+	}
+	if build == nil {
+		return errors.Wrapf(ErrSQLiteBuildMismatch, "node %s: unknown build", node)
+	}
+	if diff := local.Diff(build); diff != "" {
+		return errors.Wrapf(ErrSQLiteBuildMismatch, "node %s: %s", node, diff)
+	}
+	return
+}
+
+// handshakePeers exchanges the SQLite build with the reachable peers of the database, and returns
+// an error if any of them diverges. The unreachable peers check this node on their own startup.
+func (db *Database) handshakePeers(ctx context.Context, peers *proto.Peers) (err error) {
+	var local *xs.BuildInfo
+	if local, err = xs.LocalBuildInfo(); err != nil {
+		return
+	}
+	var (
+		errs = make([]error, len(peers.Servers))
+		wg   sync.WaitGroup
+	)
+	for i, s := range peers.Servers {
+		if s == db.nodeID {
+			continue
+		}
+		wg.Add(1)
+		go func(i int, s proto.NodeID) {
+			defer wg.Done()
+			var resp = &PeerHandshakeResp{}
+			ctx, cancel := context.WithTimeout(ctx, PeerHandshakeTimeout)
+			defer cancel()
+			if err := mux.NewCaller().CallNodeWithContext(ctx, s, route.DBSPeerHandshake.String(),
+				&PeerHandshakeReq{DatabaseID: db.dbID, Build: local}, resp,
+			); err != nil {
+				// a diverged peer refuses the handshake as well, the error is passed as text by rpc
+				if strings.Contains(err.Error(), ErrSQLiteBuildMismatch.Error()) {
+					errs[i] = errors.Wrapf(ErrSQLiteBuildMismatch, "node %s: %v", s, err)
+					return
+				}
+				log.WithFields(log.Fields{
+					"db":   db.dbID,
+					"peer": s,
+				}).WithError(err).Warning("handshake with peer failed")
+				return
+			}
+			errs[i] = checkBuild(s, resp.Build)
+		}(i, s)
+	}
+	wg.Wait()
+	for _, err = range errs {
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// setDiverged marks the database as diverged from a peer, it refuses to serve since then.
+func (db *Database) setDiverged(err error) {
+	if atomic.CompareAndSwapUint32(&db.diverged, 0, 1) {
+		log.WithField("db", db.dbID).WithError(err).Error(
+			"sqlite build diverged from peer, refuse to serve")
+	}
+}
+
+func (db *Database) isDiverged() bool {
+	return atomic.LoadUint32(&db.diverged) == 1
+}
+
+// PeerHandshake handles the SQLite build exchange from a peer of the database. A diverged build
+// is refused, and the database stops serving if it's running here, as the two replicas may not
+// produce the same state.
+func (dbms *DBMS) PeerHandshake(
+	node proto.NodeID, req *PeerHandshakeReq) (resp *PeerHandshakeResp, err error,
+) {
+	var local *xs.BuildInfo
+	if local, err = xs.LocalBuildInfo(); err != nil {
+		return
+	}
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if exists && !db.isStandby() {
+		if _, found := db.bftraftRuntime.Peers().Find(node); !found &&
+			!dbms.isStandbyNode(req.DatabaseID, node) {
+			err = errors.Wrapf(ErrPermissionDeny, "node %s is not a peer", node)
+			return
+		}
+	}
+	if err = checkBuild(node, req.Build); err != nil {
+		if exists {
+			db.setDiverged(err)
+		}
+		return
+	}
+	resp = &PeerHandshakeResp{Build: local}
+	return
+}
+
+// PeerHandshake rpc, called by the peers of a database to exchange their SQLite builds.
+func (rpc *DBMSRPCService) PeerHandshake(req *PeerHandshakeReq, resp *PeerHandshakeResp) (err error) {
+	var r *PeerHandshakeResp
+	if r, err = rpc.dbms.PeerHandshake(req.GetNodeID().ToNodeID(), req); err != nil {
+		return
+	}
+	*resp = *r
+	return
+}
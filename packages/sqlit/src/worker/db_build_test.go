@@ -0,0 +1,42 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestPeerHandshake(t *testing.T) {
+	Convey("Given the local sqlite build", t, func() {
+		var (
+			dbms = &DBMS{}
+			node = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000001")
+		)
+		local, err := xs.LocalBuildInfo()
+		So(err, ShouldBeNil)
+
+		Convey("The identical build should be accepted", func() {
+			resp, err := dbms.PeerHandshake(node, &PeerHandshakeReq{DatabaseID: "db", Build: local})
+			So(err, ShouldBeNil)
+			So(resp.Build, ShouldResemble, local)
+		})
+		Convey("The diverged build should be refused", func() {
+			var diverged = *local
+			diverged.Collations = append([]string{"CUSTOM"}, local.Collations...)
+			_, err := dbms.PeerHandshake(node, &PeerHandshakeReq{DatabaseID: "db", Build: &diverged})
+			So(errors.Cause(err), ShouldEqual, ErrSQLiteBuildMismatch)
+			So(errors.Cause(checkBuild(node, nil)), ShouldEqual, ErrSQLiteBuildMismatch)
+		})
+		Convey("The diverged database should refuse to serve", func() {
+			var db = &Database{}
+			db.setDiverged(ErrSQLiteBuildMismatch)
+			_, err := db.Query(&types.Request{})
+			So(err, ShouldEqual, ErrSQLiteBuildMismatch)
+		})
+	})
+}
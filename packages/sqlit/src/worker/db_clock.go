@@ -0,0 +1,174 @@
+package worker
+
+import (
+	"expvar"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// ClockSkewWarningRatio defines the ratio of the max request time gap, above which the clock
+	// skew of a peer or a client is warned before the requests are rejected.
+	ClockSkewWarningRatio = 0.5
+
+	mwMinerPeerClockSkew    = "service:miner:peer:clock_skew"
+	mwMinerRequestClockSkew = "service:miner:request:clock_skew"
+)
+
+var (
+	// peerSkewVars exports the median clock skews in milliseconds of the database peers relative
+	// to this miner, keyed by database id and then node id.
+	peerSkewVars = expvar.NewMap(mwMinerPeerClockSkew)
+	// requestSkewVars exports the clock skew statistics of the write requests, keyed by database
+	// id.
+	requestSkewVars = expvar.NewMap(mwMinerRequestClockSkew)
+)
+
+// peerClock records the recent clock skews of the peers of a database, which are estimated by the
+// peer pings like NTP: skew = peer time - (send time + round-trip time / 2).
+type peerClock struct {
+	sync.RWMutex
+	samples map[proto.NodeID][]time.Duration
+	warned  map[proto.NodeID]bool
+	vars    *expvar.Map
+}
+
+func newPeerClock() *peerClock {
+	return &peerClock{
+		samples: make(map[proto.NodeID][]time.Duration),
+		warned:  make(map[proto.NodeID]bool),
+		vars:    new(expvar.Map).Init(),
+	}
+}
+
+// record adds the clock skew of node, only the latest LatencySampleSize ones are kept. It returns
+// the median skew and whether the threshold is newly exceeded, so that a drifting peer is warned
+// once instead of on every ping.
+func (c *peerClock) record(
+	node proto.NodeID, skew, threshold time.Duration) (median time.Duration, warn bool,
+) {
+	c.Lock()
+	defer c.Unlock()
+	s := append(c.samples[node], skew)
+	if len(s) > LatencySampleSize {
+		s = s[len(s)-LatencySampleSize:]
+	}
+	c.samples[node] = s
+	median = medianDuration(s)
+	v := new(expvar.Float)
+	v.Set(float64(median) / float64(time.Millisecond))
+	c.vars.Set(string(node), v)
+	exceeded := threshold > 0 && absDuration(median) > threshold
+	warn = exceeded && !c.warned[node]
+	c.warned[node] = exceeded
+	return
+}
+
+// median returns the median of the recent clock skews of node.
+func (c *peerClock) median(node proto.NodeID) (skew time.Duration, ok bool) {
+	c.RLock()
+	defer c.RUnlock()
+	s := c.samples[node]
+	if len(s) == 0 {
+		return
+	}
+	return medianDuration(s), true
+}
+
+// requestClock counts the write requests by the clock skews of their timestamps.
+type requestClock struct {
+	checked  *expvar.Int   // requests checked
+	skewed   *expvar.Int   // requests skewed above the warning threshold
+	rejected *expvar.Int   // requests rejected by the max time gap
+	advisory *expvar.Int   // requests accepted beyond the max time gap in leader timestamp mode
+	last     *expvar.Float // skew of the last request in milliseconds
+	vars     *expvar.Map
+}
+
+func newRequestClock() *requestClock {
+	c := &requestClock{
+		checked:  new(expvar.Int),
+		skewed:   new(expvar.Int),
+		rejected: new(expvar.Int),
+		advisory: new(expvar.Int),
+		last:     new(expvar.Float),
+		vars:     new(expvar.Map).Init(),
+	}
+	c.vars.Set("checked", c.checked)
+	c.vars.Set("skewed", c.skewed)
+	c.vars.Set("rejected", c.rejected)
+	c.vars.Set("advisory", c.advisory)
+	c.vars.Set("last_skew_ms", c.last)
+	return c
+}
+
+// checkRequestTime checks the clock skew of a write request from node, which is the request time
+// minus the local time. The request is rejected if the skew exceeds the max time gap, unless the
+// leader timestamp mode is enabled: the client time is advisory then and the replays are still
+// refused by the signature and the connection sequence.
+func (db *Database) checkRequestTime(node proto.NodeID, skew time.Duration) (err error) {
+	var (
+		gap = db.cfg.MaxWriteTimeGap
+		abs = absDuration(skew)
+		le  = log.WithFields(log.Fields{
+			"db":      db.dbID,
+			"node":    node,
+			"skew":    skew,
+			"max_gap": gap,
+		})
+	)
+	db.reqClock.checked.Add(1)
+	db.reqClock.last.Set(float64(skew) / float64(time.Millisecond))
+	if abs <= time.Duration(float64(gap)*ClockSkewWarningRatio) {
+		return
+	}
+	db.reqClock.skewed.Add(1)
+	if abs <= gap {
+		le.Debug("request time is skewed, the client clock may be drifting")
+		return
+	}
+	if db.cfg.LeaderTimestamp {
+		db.reqClock.advisory.Add(1)
+		le.Warning("request time exceeds max time gap, accepted in leader timestamp mode")
+		return
+	}
+	db.reqClock.rejected.Add(1)
+	le.Warning("request time exceeds max time gap, check the client clock")
+	return errors.Wrapf(ErrInvalidRequest,
+		"invalid request time: client clock is skewed by %s, max time gap is %s", skew, gap)
+}
+
+// recordPeerSkew records the clock skew of peer estimated by a ping sent at start, which took rtt
+// and was answered at peerTime.
+func (db *Database) recordPeerSkew(peer proto.NodeID, start time.Time, rtt time.Duration,
+	peerTime time.Time,
+) {
+	if peerTime.IsZero() {
+		// the peer doesn't report its time
+		return
+	}
+	var (
+		skew      = peerTime.Sub(start.Add(rtt / 2))
+		threshold = time.Duration(float64(db.cfg.MaxWriteTimeGap) * ClockSkewWarningRatio)
+	)
+	if median, warn := db.clock.record(peer, skew, threshold); warn {
+		log.WithFields(log.Fields{
+			"db":      db.dbID,
+			"peer":    peer,
+			"skew":    median,
+			"max_gap": db.cfg.MaxWriteTimeGap,
+		}).Warning("peer clock is skewed, the requests may be rejected spuriously")
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPeerClock(t *testing.T) {
+	Convey("Given the clock skews of the peers", t, func() {
+		var c = newPeerClock()
+		for _, v := range []time.Duration{-30, 10, 20} {
+			_, warn := c.record("node2", v*time.Millisecond, time.Second)
+			So(warn, ShouldBeFalse)
+		}
+
+		Convey("The median clock skew should be reported", func() {
+			skew, ok := c.median("node2")
+			So(ok, ShouldBeTrue)
+			So(skew, ShouldEqual, 10*time.Millisecond)
+			_, ok = c.median("node3")
+			So(ok, ShouldBeFalse)
+			So(c.vars.Get("node2").(*expvar.Float).Value(), ShouldEqual, 10)
+		})
+		Convey("A drifting peer should be warned once", func() {
+			var warns int
+			for i := 0; i < LatencySampleSize; i++ {
+				if _, warn := c.record("node2", -2*time.Second, time.Second); warn {
+					warns++
+				}
+			}
+			So(warns, ShouldEqual, 1)
+			skew, _ := c.median("node2")
+			So(skew, ShouldEqual, -2*time.Second)
+			for i := 0; i < LatencySampleSize; i++ {
+				c.record("node2", 0, time.Second)
+			}
+			_, warn := c.record("node2", -2*time.Second, time.Second)
+			So(warn, ShouldBeFalse)
+			for i := 0; i < LatencySampleSize; i++ {
+				if _, warn = c.record("node2", -2*time.Second, time.Second); warn {
+					break
+				}
+			}
+			So(warn, ShouldBeTrue)
+		})
+	})
+	Convey("Given a database measuring the peer clocks", t, func() {
+		var (
+			db = &Database{
+				cfg:   &DBConfig{MaxWriteTimeGap: time.Minute},
+				clock: newPeerClock(),
+			}
+			start = time.Now()
+		)
+		db.recordPeerSkew("node2", start, 0, time.Time{})
+		_, ok := db.clock.median("node2")
+		So(ok, ShouldBeFalse)
+		db.recordPeerSkew("node2", start, 20*time.Millisecond, start.Add(5*time.Second))
+		skew, ok := db.clock.median("node2")
+		So(ok, ShouldBeTrue)
+		So(skew, ShouldEqual, 5*time.Second-10*time.Millisecond)
+	})
+}
+
+func TestCheckRequestTime(t *testing.T) {
+	Convey("Given a database checking the request time", t, func() {
+		var db = &Database{
+			cfg:      &DBConfig{MaxWriteTimeGap: time.Minute},
+			reqClock: newRequestClock(),
+		}
+
+		Convey("The requests within the max time gap should be accepted", func() {
+			So(db.checkRequestTime("node1", -10*time.Second), ShouldBeNil)
+			So(db.checkRequestTime("node1", 40*time.Second), ShouldBeNil)
+			So(db.reqClock.checked.Value(), ShouldEqual, 2)
+			So(db.reqClock.skewed.Value(), ShouldEqual, 1)
+			So(db.reqClock.last.Value(), ShouldEqual, 40000)
+		})
+		Convey("The requests beyond the max time gap should be rejected", func() {
+			err := db.checkRequestTime("node1", -2*time.Minute)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+			So(err.Error(), ShouldContainSubstring, "-2m0s")
+			So(db.reqClock.rejected.Value(), ShouldEqual, 1)
+			So(db.reqClock.advisory.Value(), ShouldEqual, 0)
+		})
+		Convey("The request time should be advisory in leader timestamp mode", func() {
+			db.cfg.LeaderTimestamp = true
+			So(db.checkRequestTime("node1", time.Hour), ShouldBeNil)
+			So(db.reqClock.rejected.Value(), ShouldEqual, 0)
+			So(db.reqClock.advisory.Value(), ShouldEqual, 1)
+		})
+	})
+}
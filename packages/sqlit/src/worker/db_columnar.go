@@ -0,0 +1,23 @@
+package worker
+
+import (
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// encodeColumnar returns the copy of resp with the rows columnar encoded if the request accepts
+// it, or resp itself. Like compressResult, the shared response is left unchanged.
+func (db *Database) encodeColumnar(resp *types.Response) *types.Response {
+	if resp == nil || resp.Header.Request.ResultEncoding() == "" {
+		return resp
+	}
+	var c = *resp
+	if err := types.EncodeColumnar(&c); err != nil {
+		log.WithField("db", db.dbID).WithError(err).Warning("encode columnar result failed")
+		return resp
+	}
+	if c.Encoding == "" {
+		return resp
+	}
+	return &c
+}
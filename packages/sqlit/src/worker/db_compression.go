@@ -0,0 +1,98 @@
+package worker
+
+import (
+	"expvar"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultResultCompressionMinSize defines the default min size of the encoded rows to
+	// compress, the smaller results are not worth the cpu.
+	DefaultResultCompressionMinSize = 1 << 10
+
+	mwMinerResultCompression = "service:miner:db:result_compression"
+)
+
+// resultCompressionVars exports the sizes of the compressed results, keyed by database id.
+var resultCompressionVars = expvar.NewMap(mwMinerResultCompression)
+
+// newResultCompressor returns the result compressor of cfg, nil if the compression is disabled.
+func newResultCompressor(cfg *conf.ResultCompressionInfo) (c *types.ResultCompressor, err error) {
+	if cfg == nil {
+		return
+	}
+	var dict []byte
+	if cfg.DictFile != "" {
+		if dict, err = os.ReadFile(cfg.DictFile); err != nil {
+			err = errors.Wrap(err, "read result compression dictionary failed")
+			return
+		}
+	}
+	return types.NewResultCompressor(dict)
+}
+
+// compressionStats counts the sizes of the results of a database before and after compression.
+type compressionStats struct {
+	responses  *expvar.Int // compressed responses
+	raw        *expvar.Int // encoded rows before compression
+	compressed *expvar.Int // encoded rows after compression
+	saved      *expvar.Int // bytes saved by compression
+	vars       *expvar.Map
+}
+
+func newCompressionStats() *compressionStats {
+	s := &compressionStats{
+		responses:  new(expvar.Int),
+		raw:        new(expvar.Int),
+		compressed: new(expvar.Int),
+		saved:      new(expvar.Int),
+		vars:       new(expvar.Map).Init(),
+	}
+	s.vars.Set("responses", s.responses)
+	s.vars.Set("raw_bytes", s.raw)
+	s.vars.Set("compressed_bytes", s.compressed)
+	s.vars.Set("saved_bytes", s.saved)
+	return s
+}
+
+func (s *compressionStats) record(raw, compressed int) {
+	s.responses.Add(1)
+	s.raw.Add(int64(raw))
+	s.compressed.Add(int64(compressed))
+	s.saved.Add(int64(raw - compressed))
+}
+
+// compressResult returns the copy of resp with the rows compressed if the request accepts it, or
+// resp itself. The response may be shared by the retries of an idempotent write, so it's left
+// unchanged.
+func (db *Database) compressResult(resp *types.Response) *types.Response {
+	if db.cfg.ResultCompressor == nil || resp == nil {
+		return resp
+	}
+	var (
+		c       = *resp
+		minSize int
+	)
+	if db.cfg.ResultCompression != nil {
+		minSize = db.cfg.ResultCompression.MinSize
+	}
+	if minSize <= 0 {
+		minSize = DefaultResultCompressionMinSize
+	}
+	raw, compressed, err := db.cfg.ResultCompressor.Compress(&c, minSize)
+	if err != nil {
+		log.WithField("db", db.dbID).WithError(err).Warning("compress result failed")
+		return resp
+	}
+	if compressed == 0 {
+		return resp
+	}
+	db.compression.record(raw, compressed)
+	return &c
+}
@@ -0,0 +1,70 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+func TestCompressResult(t *testing.T) {
+	Convey("Given a database compressing the results", t, func() {
+		c, err := newResultCompressor(&conf.ResultCompressionInfo{MinSize: 64})
+		So(err, ShouldBeNil)
+		var (
+			db = &Database{
+				cfg: &DBConfig{
+					ResultCompression: &conf.ResultCompressionInfo{MinSize: 64},
+					ResultCompressor:  c,
+				},
+				compression: newCompressionStats(),
+			}
+			resp = &types.Response{}
+		)
+		for i := 0; i < 16; i++ {
+			resp.Payload.Rows = append(resp.Payload.Rows, types.ResponseRow{
+				Values: []interface{}{strings.Repeat("x", 32)},
+			})
+		}
+
+		Convey("The response should be left unchanged if the request doesn't accept it", func() {
+			So(db.compressResult(resp), ShouldEqual, resp)
+			So(db.compression.responses.Value(), ShouldEqual, 0)
+		})
+		Convey("The copy of the response should be compressed", func() {
+			So(resp.Header.Request.SetResultCompression(types.ResultCompressionZstd, 0), ShouldBeNil)
+			var compressed = db.compressResult(resp)
+			So(compressed, ShouldNotEqual, resp)
+			So(compressed.Payload.Rows, ShouldBeNil)
+			So(resp.Payload.Rows, ShouldHaveLength, 16)
+			So(db.compression.responses.Value(), ShouldEqual, 1)
+			So(db.compression.saved.Value(), ShouldBeGreaterThan, 0)
+			So(db.compression.raw.Value()-db.compression.compressed.Value(),
+				ShouldEqual, db.compression.saved.Value())
+		})
+		Convey("The small columnar encoded copy of the response should not be compressed", func() {
+			resp.Payload.Columns = []string{"v"}
+			So(resp.Header.Request.SetResultEncoding(types.ResultEncodingColumnar), ShouldBeNil)
+			So(db.encodeColumnar(resp), ShouldNotEqual, resp)
+			So(resp.Payload.Rows, ShouldHaveLength, 16)
+
+			So(resp.Header.Request.SetResultCompression(types.ResultCompressionZstd, 0), ShouldBeNil)
+			var encoded = db.encodeColumnar(resp)
+			So(encoded.Encoding, ShouldEqual, types.ResultEncodingColumnar)
+			// the repeated values are run-length encoded below the min size of compression
+			So(db.compressResult(encoded), ShouldEqual, encoded)
+			So(db.compression.responses.Value(), ShouldEqual, 0)
+		})
+		Convey("The compression should be disabled without the compressor", func() {
+			c, err := newResultCompressor(nil)
+			So(err, ShouldBeNil)
+			So(c, ShouldBeNil)
+			db.cfg.ResultCompressor = nil
+			So(resp.Header.Request.SetResultCompression(types.ResultCompressionZstd, 0), ShouldBeNil)
+			So(db.compressResult(resp), ShouldEqual, resp)
+		})
+	})
+}
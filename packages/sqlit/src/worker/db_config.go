@@ -4,8 +4,11 @@ package worker
 import (
 	"time"
 
+	"sqlit/src/conf"
 	"sqlit/src/proto"
 	"sqlit/src/sqlchain"
+	"sqlit/src/types"
+	"sqlit/src/utils"
 )
 
 // DBConfig defines the database config.
@@ -17,11 +20,66 @@ type DBConfig struct {
 	ChainMux               *sqlchain.MuxService
 	MaxWriteTimeGap        time.Duration
 	EncryptionKey          string
+	AllowUnencrypted       bool
 	SpaceLimit             uint64
+	QuotaWarningThresholds []float64
 	UpdateBlockCount       uint64
 	LastBillingHeight      int32
 	UseEventualConsistency bool
 	ConsistencyLevel       float64
 	IsolationLevel         int
 	SlowQueryTime          time.Duration
+	IdempotencyWindow      time.Duration
+	Admission              *conf.AdmissionInfo
+	ResultLimit            *conf.ResultLimitInfo
+	ResultCompression      *conf.ResultCompressionInfo
+	ResultCompressor       *types.ResultCompressor
+	Audit                  *conf.AuditInfo
+	PayloadAlert           *conf.PayloadAlertInfo
+	BlockPacking           *conf.BlockPackingInfo
+	SyncReadLimiter        *utils.RateLimiter
+	SyncWriteLimiter       *utils.RateLimiter
+	ApplyConcurrency       int
+	GroupCommitDelay       time.Duration
+	SnapshotReads          bool
+	LatencyProbeInterval   time.Duration
+	TTLCheckInterval       time.Duration
+	JobCheckInterval       time.Duration
+	ForeignDataHosts       []string
+	AckReconcileWindow     time.Duration
+	LeaderTimestamp        bool
+	Pool                   types.PoolMeta
+	Durability             types.DurabilityLevel
+	Source                 proto.DatabaseID
+	// Standby indicates the database is replicated as a warm standby, which syncs the blocks from
+	// the peers without serving queries until it's promoted to a peer.
+	Standby bool
+	// Join indicates the database joins the existing peers with the state synced from the blocks,
+	// the bftraft logs committed before are not fetched.
+	Join bool
+}
+
+// synchronousMode returns the storage synchronous setting of the durability level.
+func synchronousMode(level types.DurabilityLevel) string {
+	switch level {
+	case types.StrictDurability:
+		return "FULL"
+	case types.RelaxedDurability:
+		return "OFF"
+	default:
+		return "NORMAL"
+	}
+}
+
+// blockPacking returns the packing limits of the sqlchain config, the zero value if disabled.
+func blockPacking(cfg *conf.BlockPackingInfo) (l sqlchain.PackingLimits) {
+	if cfg != nil {
+		l = sqlchain.PackingLimits{
+			MinQueries: cfg.MinQueries,
+			MaxQueries: cfg.MaxQueries,
+			MinTick:    cfg.MinTick,
+			MaxTick:    cfg.MaxTick,
+		}
+	}
+	return
 }
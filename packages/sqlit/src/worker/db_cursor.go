@@ -0,0 +1,211 @@
+package worker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	x "sqlit/src/dpos"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+const (
+	// DefaultCursorTTL defines the default idle time before a result cursor is closed.
+	DefaultCursorTTL = time.Minute
+	// DefaultMaxCursors defines the default max count of open result cursors of a database.
+	DefaultMaxCursors = 16
+
+	cursorIDSize = 16
+)
+
+// resultLimit returns the result limit of the read queries in cfg, nil means unlimited.
+func resultLimit(cfg *conf.ResultLimitInfo) (l x.ResultLimit) {
+	if cfg != nil {
+		l.MaxRows, l.MaxBytes = cfg.MaxRows, cfg.MaxBytes
+	}
+	return
+}
+
+// resultCursor is an open cursor of a spilled read query result.
+type resultCursor struct {
+	req   *types.Request
+	cur   *x.Cursor
+	timer *time.Timer
+}
+
+// cursorRegistry keeps the open result cursors of a database, an idle cursor is closed after the
+// ttl to release its read transaction.
+type cursorRegistry struct {
+	sync.Mutex
+	ttl     time.Duration
+	max     int
+	cursors map[string]*resultCursor
+}
+
+func newCursorRegistry(cfg *conf.ResultLimitInfo) *cursorRegistry {
+	r := &cursorRegistry{
+		ttl:     DefaultCursorTTL,
+		max:     DefaultMaxCursors,
+		cursors: make(map[string]*resultCursor),
+	}
+	if cfg != nil {
+		if cfg.CursorTTL > 0 {
+			r.ttl = cfg.CursorTTL
+		}
+		if cfg.MaxCursors > 0 {
+			r.max = cfg.MaxCursors
+		}
+	}
+	return r
+}
+
+// add registers the cursor of the read request req and returns its id, the cursor is closed if
+// it cannot be registered.
+func (r *cursorRegistry) add(req *types.Request, cur *x.Cursor) (id string, err error) {
+	r.Lock()
+	defer r.Unlock()
+	if len(r.cursors) >= r.max {
+		cur.Close()
+		err = errors.Wrapf(ErrTooManyCursors, "%d cursors open", r.max)
+		return
+	}
+	var b [cursorIDSize]byte
+	if _, err = rand.Read(b[:]); err != nil {
+		cur.Close()
+		return
+	}
+	id = hex.EncodeToString(b[:])
+	r.cursors[id] = &resultCursor{
+		req:   req,
+		cur:   cur,
+		timer: time.AfterFunc(r.ttl, func() { r.remove(id) }),
+	}
+	return
+}
+
+// get returns the cursor owned by node and postpones its expiration.
+func (r *cursorRegistry) get(id string, node proto.NodeID) (c *resultCursor, err error) {
+	r.Lock()
+	defer r.Unlock()
+	var ok bool
+	// the cursor of another node is reported as missing
+	if c, ok = r.cursors[id]; !ok || c.req.Header.NodeID != node {
+		err = errors.Wrapf(x.ErrCursorClosed, "cursor %s", id)
+		return
+	}
+	c.timer.Reset(r.ttl)
+	return
+}
+
+// remove closes the cursor and removes it from the registry.
+func (r *cursorRegistry) remove(id string) {
+	r.Lock()
+	c, ok := r.cursors[id]
+	delete(r.cursors, id)
+	r.Unlock()
+	if ok {
+		c.timer.Stop()
+		c.cur.Close()
+	}
+}
+
+// close closes all the cursors.
+func (r *cursorRegistry) close() {
+	r.Lock()
+	cursors := r.cursors
+	r.cursors = make(map[string]*resultCursor)
+	r.Unlock()
+	for _, c := range cursors {
+		c.timer.Stop()
+		c.cur.Close()
+	}
+}
+
+// fetchCursor returns the next page of the result cursor owned by node, the response carries the
+// cursor id unless it's the last page. The pages are not tracked by the chain, so they are never
+// acknowledged or packed into blocks.
+func (db *Database) fetchCursor(ctx context.Context, node proto.NodeID, id string) (
+	resp *types.Response, err error,
+) {
+	var (
+		c    *resultCursor
+		data [][]interface{}
+		last bool
+	)
+	if c, err = db.cursors.get(id, node); err != nil {
+		return
+	}
+	if data, last, err = c.cur.Next(ctx); last {
+		db.cursors.remove(id)
+	}
+	if err != nil {
+		return
+	}
+	cnames, ctypes := c.cur.Columns()
+	rows := make([]types.ResponseRow, len(data))
+	for i, v := range data {
+		rows[i].Values = v
+	}
+	resp = &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:         c.req.Header.RequestHeader,
+				RequestHash:     c.req.Header.Hash(),
+				NodeID:          db.nodeID,
+				Timestamp:       time.Now().UTC(),
+				ResponseAccount: db.accountAddr,
+			},
+		},
+		Payload: types.ResponsePayload{
+			Columns:   cnames,
+			DeclTypes: ctypes,
+			Rows:      rows,
+		},
+	}
+	if !last {
+		resp.Cursor = id
+	}
+	if err = resp.BuildHash(); err != nil {
+		return
+	}
+	err = resp.Sign(db.privateKey)
+	return
+}
+
+// FetchCursor returns the next page of the result cursor of the request node.
+func (dbms *DBMS) FetchCursor(req *types.FetchCursorReq) (res *types.Response, err error) {
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	if req.GetNodeID() == nil {
+		err = errors.Wrap(ErrInvalidRequest, "unknown request node of cursor")
+		return
+	}
+	var node = proto.NodeID(req.GetNodeID().String())
+	if req.Close {
+		if _, err = db.cursors.get(req.Cursor, node); err != nil {
+			return
+		}
+		db.cursors.remove(req.Cursor)
+		return &types.Response{}, nil
+	}
+	return db.fetchCursor(req.GetContext(), node, req.Cursor)
+}
+
+// FetchCursor rpc, called by client to page through a spilled read query result.
+func (rpc *DBMSRPCService) FetchCursor(req *types.FetchCursorReq, res *types.Response) (err error) {
+	var r *types.Response
+	if r, err = rpc.dbms.FetchCursor(req); err != nil {
+		return
+	}
+	*res = *r
+	return
+}
@@ -0,0 +1,51 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	x "sqlit/src/dpos"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestCursorRegistry(t *testing.T) {
+	Convey("Given a cursor registry", t, func() {
+		var (
+			r = newCursorRegistry(&conf.ResultLimitInfo{
+				CursorTTL:  50 * time.Millisecond,
+				MaxCursors: 1,
+			})
+			node = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000001")
+			req  = &types.Request{Header: types.SignedRequestHeader{
+				RequestHeader: types.RequestHeader{NodeID: node},
+			}}
+		)
+		defer r.close()
+		id, err := r.add(req, &x.Cursor{})
+		So(err, ShouldBeNil)
+		So(len(id), ShouldEqual, 2*cursorIDSize)
+
+		Convey("The cursor should only be accessed by its owner", func() {
+			_, err = r.get(id, node)
+			So(err, ShouldBeNil)
+			_, err = r.get(id, proto.NodeID("other"))
+			So(errors.Cause(err), ShouldEqual, x.ErrCursorClosed)
+		})
+		Convey("The cursors should be limited", func() {
+			_, err = r.add(req, &x.Cursor{})
+			So(errors.Cause(err), ShouldEqual, ErrTooManyCursors)
+		})
+		Convey("The idle cursor should be closed after the ttl", func() {
+			time.Sleep(200 * time.Millisecond)
+			_, err = r.get(id, node)
+			So(errors.Cause(err), ShouldEqual, x.ErrCursorClosed)
+			_, err = r.add(req, &x.Cursor{})
+			So(err, ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package worker
+
+import (
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/types"
+)
+
+// EstimateQuery returns the estimated costs of the queries of req by their query plans on the
+// local state of the database, the queries are not executed.
+func (dbms *DBMS) EstimateQuery(req *types.Request) (costs []types.QueryCost, err error) {
+	if err = req.Verify(); err != nil {
+		return
+	}
+
+	// check permission, the query plans disclose the schema of the database
+	addr, err := crypto.PubKeyHash(req.Header.Signee)
+	if err != nil {
+		return
+	}
+	err = dbms.checkPermission(addr, req.Header.DatabaseID, req.Header.QueryType, req.Payload.Queries)
+	if err != nil {
+		return
+	}
+
+	db, exists := dbms.getMeta(req.Header.DatabaseID)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	ctx, cancel := withMaxExecutionTime(req.GetContext(), req)
+	defer cancel()
+	return db.chain.EstimateQuery(ctx, req)
+}
+
+// EstimateQuery rpc, called by client to estimate the costs of the queries before running them.
+func (rpc *DBMSRPCService) EstimateQuery(
+	req *types.Request, resp *types.EstimateQueryResp) (err error,
+) {
+	// verify query is sent from the request node
+	if req.Envelope.NodeID.String() != string(req.Header.NodeID) {
+		err = errors.Wrap(ErrInvalidRequest, "request node id mismatch in estimate query")
+		return
+	}
+
+	resp.Costs, err = rpc.dbms.EstimateQuery(req)
+	return
+}
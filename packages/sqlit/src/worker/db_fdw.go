@@ -0,0 +1,399 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	x "sqlit/src/dpos"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// ForeignDataCheckInterval defines the interval of checking the foreign tables due to fetch.
+	ForeignDataCheckInterval = 10 * time.Second
+	// ForeignDataTimeout defines the max time of fetching the endpoint of a foreign table.
+	ForeignDataTimeout = 30 * time.Second
+	// ForeignRefreshTimeout defines the max time of a round of the foreign tables refreshes.
+	ForeignRefreshTimeout = 5 * time.Minute
+	// MaxForeignDataSize defines the max size of the response body of a foreign table endpoint.
+	MaxForeignDataSize = 4 << 20
+	// MaxForeignRows defines the max count of the rows of a foreign table.
+	MaxForeignRows = 10000
+
+	// foreignInsertBatch is the count of the rows inserted by a query of the fetched rows
+	foreignInsertBatch = 100
+
+	mwMinerDBFDW = "service:miner:db:fdw"
+)
+
+// fdwVars exports the counts of the foreign tables fetches, keyed by database id.
+var fdwVars = expvar.NewMap(mwMinerDBFDW)
+
+// foreignFetcher fetches the endpoints of the foreign tables of a database on the leader, the
+// fetched rows replace the rows of the data tables by the write queries signed by the leader, so
+// the followers replay the recorded rows without fetching the endpoints.
+type foreignFetcher struct {
+	hosts    []string
+	client   *http.Client
+	interval time.Duration
+	connID   uint64
+	stopCh   chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+
+	fetches *expvar.Int
+	rows    *expvar.Int
+	failed  *expvar.Int
+	vars    *expvar.Map
+}
+
+// newForeignFetcher returns the fetcher of the endpoints on the allowed hosts, a host prefixed
+// with "*." allows its subdomains. It returns nil if no host is allowed.
+func newForeignFetcher(hosts []string) *foreignFetcher {
+	if len(hosts) == 0 {
+		return nil
+	}
+	f := &foreignFetcher{
+		hosts:    hosts,
+		interval: ForeignDataCheckInterval,
+		connID:   rand.Uint64(),
+		stopCh:   make(chan struct{}),
+		fetches:  new(expvar.Int),
+		rows:     new(expvar.Int),
+		failed:   new(expvar.Int),
+		vars:     new(expvar.Map).Init(),
+	}
+	f.client = &http.Client{
+		Timeout: ForeignDataTimeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 5 {
+				return errors.New("too many redirects")
+			}
+			return f.check(req.URL)
+		},
+	}
+	f.vars.Set("fetches", f.fetches)
+	f.vars.Set("rows", f.rows)
+	f.vars.Set("failed", f.failed)
+	return f
+}
+
+func (f *foreignFetcher) start(db *Database) {
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		ticker := time.NewTicker(f.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-f.stopCh:
+				return
+			case <-ticker.C:
+			}
+			if !db.chain.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), ForeignRefreshTimeout)
+			if err := db.refreshForeignTables(ctx, getLocalTime()); err != nil {
+				log.WithField("db", db.dbID).WithError(err).Warning("refresh foreign tables failed")
+			}
+			cancel()
+		}
+	}()
+}
+
+func (f *foreignFetcher) stop() {
+	f.once.Do(func() { close(f.stopCh) })
+	f.wg.Wait()
+}
+
+// check returns an error if the url is not an https url on the allowed hosts.
+func (f *foreignFetcher) check(u *url.URL) error {
+	if u.Scheme != "https" {
+		return errors.Wrapf(x.ErrInvalidForeignTable, "url must be https: %s", u)
+	}
+	var host = strings.ToLower(u.Hostname())
+	for _, h := range f.hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasPrefix(h, "*.") && strings.HasSuffix(host, h[1:]) {
+			return nil
+		}
+	}
+	return errors.Errorf("host %s is not allowed", host)
+}
+
+// fetch fetches the endpoint of the foreign table and returns the rows of the table.
+func (f *foreignFetcher) fetch(ctx context.Context, t *x.ForeignTable) (rows [][]interface{}, err error) {
+	columns, err := x.ParseForeignColumns(t.Columns)
+	if err != nil {
+		return
+	}
+	u, err := url.Parse(t.URL)
+	if err != nil {
+		return
+	}
+	if err = f.check(u); err != nil {
+		return
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, MaxForeignDataSize+1))
+	if err != nil {
+		return
+	}
+	if len(body) > MaxForeignDataSize {
+		return nil, errors.Errorf("response body exceeds %d bytes", MaxForeignDataSize)
+	}
+	return foreignRows(body, t.Path, columns)
+}
+
+// foreignRows maps the json array at path of body to the rows of columns. The members of the
+// object elements are mapped to the columns by names, and the other elements are mapped to the
+// only column.
+func foreignRows(body []byte, path string, columns []string) (rows [][]interface{}, err error) {
+	var (
+		dec = json.NewDecoder(bytes.NewReader(body))
+		v   interface{}
+	)
+	dec.UseNumber()
+	if err = dec.Decode(&v); err != nil {
+		return nil, errors.Wrap(err, "decode response body failed")
+	}
+	if path != "" {
+		for _, p := range strings.Split(path, ".") {
+			switch c := v.(type) {
+			case map[string]interface{}:
+				v = c[p]
+			case []interface{}:
+				i, err := strconv.Atoi(p)
+				if err != nil || i < 0 || i >= len(c) {
+					return nil, errors.Errorf("invalid index %s of path %s", p, path)
+				}
+				v = c[i]
+			default:
+				return nil, errors.Errorf("member %s of path %s not found", p, path)
+			}
+		}
+	}
+	elems, ok := v.([]interface{})
+	if !ok {
+		return nil, errors.Errorf("value at path %q is not an array", path)
+	}
+	if len(elems) > MaxForeignRows {
+		return nil, errors.Errorf("%d rows exceed the limit %d", len(elems), MaxForeignRows)
+	}
+	rows = make([][]interface{}, 0, len(elems))
+	for i, e := range elems {
+		var row = make([]interface{}, len(columns))
+		if o, ok := e.(map[string]interface{}); ok {
+			for j, c := range columns {
+				if row[j], err = foreignValue(o[c]); err != nil {
+					return
+				}
+			}
+		} else if len(columns) == 1 {
+			if row[0], err = foreignValue(e); err != nil {
+				return
+			}
+		} else {
+			return nil, errors.Errorf("element %d is not an object", i)
+		}
+		rows = append(rows, row)
+	}
+	return
+}
+
+// foreignValue converts a json value to an sql value, the booleans are converted to 1 and 0, and
+// the objects and the arrays are kept as the json texts.
+func foreignValue(v interface{}) (interface{}, error) {
+	switch c := v.(type) {
+	case nil, string:
+		return c, nil
+	case json.Number:
+		if i, err := c.Int64(); err == nil {
+			return i, nil
+		}
+		return c.Float64()
+	case bool:
+		if c {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	default:
+		b, err := json.Marshal(c)
+		return string(b), err
+	}
+}
+
+// foreignQueries returns the queries replacing the rows of the data table of the foreign table
+// with the rows fetched at now, or recording the error of the failed fetch.
+func foreignQueries(
+	t *x.ForeignTable, columns []string, rows [][]interface{}, now time.Time, fetchErr error,
+) (queries []types.Query) {
+	var (
+		table = x.QuoteString(t.Table)
+		at    = strconv.FormatInt(now.Unix(), 10)
+	)
+	if fetchErr != nil {
+		return []types.Query{{
+			Pattern: `UPDATE "` + x.ForeignTableName + `" SET "refreshed_at" = ` + at +
+				`, "error" = ? WHERE "table" = ` + table,
+			Args: []types.NamedArg{{Value: fetchErr.Error()}},
+		}}
+	}
+	var (
+		data  = x.QuoteIdent(t.DataTable())
+		names = make([]string, len(columns))
+		marks = "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	)
+	for i, c := range columns {
+		names[i] = x.QuoteIdent(c)
+	}
+	queries = append(queries, types.Query{Pattern: `DELETE FROM ` + data})
+	for i := 0; i < len(rows); i += foreignInsertBatch {
+		var (
+			batch = rows[i:min(i+foreignInsertBatch, len(rows))]
+			q     = types.Query{Args: make([]types.NamedArg, 0, len(batch)*len(columns))}
+			b     strings.Builder
+		)
+		b.WriteString(`INSERT INTO ` + data + ` (` + strings.Join(names, ", ") + `) VALUES `)
+		for j, row := range batch {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			b.WriteString(marks)
+			for _, v := range row {
+				q.Args = append(q.Args, types.NamedArg{Value: v})
+			}
+		}
+		q.Pattern = b.String()
+		queries = append(queries, q)
+	}
+	return append(queries, types.Query{
+		Pattern: `UPDATE "` + x.ForeignTableName + `" SET "refreshed_at" = ` + at + `, "rows" = ` +
+			strconv.Itoa(len(rows)) + `, "error" = NULL WHERE "table" = ` + table,
+	})
+}
+
+// foreignTables returns the foreign tables of the database, and the names of the dropped ones.
+func (db *Database) foreignTables(ctx context.Context) (
+	tables []*x.ForeignTable, dropped []string, err error,
+) {
+	rows, err := db.readLocal(ctx, `SELECT "table", "url", "path", "columns", "refresh", `+
+		`"refreshed_at" FROM "`+x.ForeignTableName+`" ORDER BY "table"`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			// no foreign table is declared yet
+			err = nil
+		}
+		return
+	}
+	for _, r := range rows {
+		if len(r.Values) != 6 {
+			continue
+		}
+		var t = &x.ForeignTable{
+			Table:   valueString(r.Values[0]),
+			URL:     valueString(r.Values[1]),
+			Path:    valueString(r.Values[2]),
+			Columns: valueString(r.Values[3]),
+			Refresh: time.Duration(valueInt(r.Values[4])) * time.Second,
+		}
+		if r.Values[5] != nil {
+			t.RefreshedAt = time.Unix(valueInt(r.Values[5]), 0).UTC()
+		}
+		cols, err := db.readLocal(ctx, "PRAGMA table_info("+x.QuoteIdent(t.Table)+")")
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "read columns of table %s failed", t.Table)
+		}
+		if len(cols) == 0 {
+			// the view is dropped
+			dropped = append(dropped, t.Table)
+			continue
+		}
+		tables = append(tables, t)
+	}
+	return
+}
+
+// refreshForeignTables fetches the endpoints of the foreign tables due at now on the leader, and
+// replaces the rows of the tables by the fetched rows. The failed fetches are recorded in the
+// foreign table list and retried after the refresh intervals. The data tables and the definitions
+// of the dropped tables are removed.
+func (db *Database) refreshForeignTables(ctx context.Context, now time.Time) (err error) {
+	tables, dropped, err := db.foreignTables(ctx)
+	if err != nil {
+		return
+	}
+	if len(dropped) > 0 {
+		var queries []types.Query
+		for _, name := range dropped {
+			var t = &x.ForeignTable{Table: name}
+			queries = append(queries,
+				types.Query{Pattern: `DROP TABLE IF EXISTS ` + x.QuoteIdent(t.DataTable())},
+				types.Query{Pattern: `DELETE FROM "` + x.ForeignTableName + `" WHERE "table" = ` +
+					x.QuoteString(name)})
+		}
+		if _, err = db.leaderWrite(ctx, db.fdw.connID, "fdw", queries); err != nil {
+			return
+		}
+	}
+	for _, t := range tables {
+		if !t.RefreshedAt.IsZero() && now.Before(t.RefreshedAt.Add(t.Refresh)) {
+			continue
+		}
+		var (
+			columns, _     = x.ParseForeignColumns(t.Columns)
+			fctx, fcancel  = context.WithTimeout(ctx, ForeignDataTimeout)
+			rows, fetchErr = db.fdw.fetch(fctx, t)
+		)
+		fcancel()
+		if fetchErr == nil {
+			fetchErr = db.quota.check()
+		}
+		if fetchErr != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			db.fdw.failed.Add(1)
+			log.WithFields(log.Fields{
+				"db":    db.dbID,
+				"table": t.Table,
+			}).WithError(fetchErr).Warning("fetch foreign table failed")
+		}
+		if _, err = db.leaderWrite(
+			ctx, db.fdw.connID, "fdw", foreignQueries(t, columns, rows, now, fetchErr),
+		); err != nil {
+			return
+		}
+		if fetchErr == nil {
+			db.fdw.fetches.Add(1)
+			db.fdw.rows.Add(int64(len(rows)))
+		}
+	}
+	return
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	x "sqlit/src/dpos"
+)
+
+func TestForeignRows(t *testing.T) {
+	Convey("The json array at the path should be mapped to the rows", t, func() {
+		rows, err := foreignRows([]byte(`{"data": {"items": [`+
+			`{"code": "eur", "rate": 0.9, "live": true, "tags": ["a"]},`+
+			`{"code": "jpy", "rate": 150, "extra": 1}]}}`),
+			"data.items", []string{"code", "rate", "live", "tags"})
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]interface{}{
+			{"eur", 0.9, int64(1), `["a"]`},
+			{"jpy", int64(150), nil, nil},
+		})
+
+		rows, err = foreignRows([]byte(`[[1, 2], ["x", null]]`), "1", []string{"v"})
+		So(err, ShouldBeNil)
+		So(rows, ShouldResemble, [][]interface{}{{"x"}, {nil}})
+
+		for path, body := range map[string]string{
+			"":       `{"items": []}`,
+			"items":  `{"items": {}}`,
+			"a.b":    `{"a": 1}`,
+			"5":      `[1]`,
+			"x":      `{"x": [1, 2]`,
+			"values": `{"values": [1]}`,
+		} {
+			_, err = foreignRows([]byte(body), path, []string{"a", "b"})
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestRefreshForeignTable(t *testing.T) {
+	Convey("Given an https json endpoint of a foreign table", t, func() {
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"items": [{"code": "eur", "rate": 0.9}, {"code": "a;b"}]}`))
+		}))
+		defer srv.Close()
+		u, err := url.Parse(srv.URL)
+		So(err, ShouldBeNil)
+		var (
+			f  = newForeignFetcher([]string{u.Hostname()})
+			ft = &x.ForeignTable{
+				Table:   "rates",
+				URL:     srv.URL + "/rates",
+				Path:    "items",
+				Columns: "code TEXT, rate REAL",
+			}
+		)
+		f.client.Transport = srv.Client().Transport
+		So(newForeignFetcher(nil), ShouldBeNil)
+
+		Convey("The endpoint on the disallowed host should not be fetched", func() {
+			f.hosts = []string{"*.example.com"}
+			_, err := f.fetch(context.Background(), ft)
+			So(err, ShouldNotBeNil)
+			So(f.check(&url.URL{Scheme: "https", Host: "api.example.com:443"}), ShouldBeNil)
+			So(f.check(&url.URL{Scheme: "http", Host: "api.example.com"}), ShouldNotBeNil)
+		})
+		Convey("The fetched rows should replace the rows of the data table", func() {
+			rows, err := f.fetch(context.Background(), ft)
+			So(err, ShouldBeNil)
+			So(rows, ShouldHaveLength, 2)
+
+			db, err := sql.Open("sqlite3", ":memory:")
+			So(err, ShouldBeNil)
+			defer db.Close()
+			db.SetMaxOpenConns(1)
+			for _, q := range []string{
+				`CREATE TABLE "__sqlit_fdw_rates" (code TEXT, rate REAL)`,
+				`CREATE TABLE "__sqlit_fdw" ("table" TEXT PRIMARY KEY, "refreshed_at" INTEGER, ` +
+					`"rows" INTEGER, "error" TEXT)`,
+				`INSERT INTO "__sqlit_fdw" ("table") VALUES ('rates')`,
+				`INSERT INTO "__sqlit_fdw_rates" VALUES ('old', 1)`,
+			} {
+				_, err = db.Exec(q)
+				So(err, ShouldBeNil)
+			}
+			var (
+				now  = time.Unix(1700000000, 0)
+				exec = func(rows [][]interface{}, fetchErr error) {
+					for _, q := range foreignQueries(
+						ft, []string{"code", "rate"}, rows, now, fetchErr,
+					) {
+						var args []interface{}
+						for _, a := range q.Args {
+							args = append(args, a.Value)
+						}
+						_, err := db.Exec(q.Pattern, args...)
+						So(err, ShouldBeNil)
+					}
+				}
+				count int
+				code  string
+			)
+			exec(rows, nil)
+			So(db.QueryRow(`SELECT COUNT(1) FROM "__sqlit_fdw_rates"`).Scan(&count), ShouldBeNil)
+			So(count, ShouldEqual, 2)
+			So(db.QueryRow(`SELECT code FROM "__sqlit_fdw_rates" WHERE rate IS NULL`).Scan(&code),
+				ShouldBeNil)
+			So(code, ShouldEqual, "a;b")
+
+			exec(nil, errors.New("unexpected status 502 Bad Gateway"))
+			var (
+				refreshed int64
+				fetchErr  sql.NullString
+			)
+			So(db.QueryRow(`SELECT "refreshed_at", "rows", "error" FROM "__sqlit_fdw"`).
+				Scan(&refreshed, &count, &fetchErr), ShouldBeNil)
+			So(refreshed, ShouldEqual, now.Unix())
+			So(count, ShouldEqual, 2)
+			So(fetchErr.String, ShouldEqual, "unexpected status 502 Bad Gateway")
+		})
+	})
+}
@@ -0,0 +1,116 @@
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+const (
+	// DefaultIdempotencyWindow defines the default time window to deduplicate the write requests
+	// with the same idempotency key.
+	DefaultIdempotencyWindow = 10 * time.Minute
+)
+
+type idempotencyKey struct {
+	node proto.NodeID
+	key  string
+}
+
+// idempotencyEntry holds the result of the first write request with an idempotency key, the
+// retries wait for done and share the response.
+type idempotencyEntry struct {
+	key    idempotencyKey
+	expire time.Time
+	done   chan struct{}
+	resp   *types.Response
+	err    error
+}
+
+func (e *idempotencyEntry) finished() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// idempotencyCache deduplicates the write requests by the requesting node and the client generated
+// idempotency key within a time window. It is kept in the memory of the leader only, so retries
+// after a leadership change are not deduplicated.
+type idempotencyCache struct {
+	sync.Mutex
+	window  time.Duration
+	entries map[idempotencyKey]*idempotencyEntry
+	queue   []*idempotencyEntry // in creation order, for expiration
+}
+
+func newIdempotencyCache(window time.Duration) *idempotencyCache {
+	if window <= 0 {
+		window = DefaultIdempotencyWindow
+	}
+	return &idempotencyCache{
+		window:  window,
+		entries: make(map[idempotencyKey]*idempotencyEntry),
+	}
+}
+
+// begin returns the entry of the request key, and whether the caller is the first one which
+// should execute the request and finish the entry.
+func (c *idempotencyCache) begin(node proto.NodeID, key string, now time.Time) (
+	e *idempotencyEntry, first bool,
+) {
+	c.Lock()
+	defer c.Unlock()
+	c.expire(now)
+	k := idempotencyKey{node: node, key: key}
+	if e = c.entries[k]; e != nil {
+		return
+	}
+	e = &idempotencyEntry{
+		key:    k,
+		expire: now.Add(c.window),
+		done:   make(chan struct{}),
+	}
+	c.entries[k] = e
+	c.queue = append(c.queue, e)
+	first = true
+	return
+}
+
+// finish sets the result of the first request, a failed request is removed so that it can be
+// retried with the same key.
+func (c *idempotencyCache) finish(e *idempotencyEntry, resp *types.Response, err error) {
+	c.Lock()
+	defer c.Unlock()
+	e.resp, e.err = resp, err
+	if err != nil && c.entries[e.key] == e {
+		delete(c.entries, e.key)
+	}
+	close(e.done)
+}
+
+// expire removes the finished entries out of the window, the caller should hold the lock.
+func (c *idempotencyCache) expire(now time.Time) {
+	var i int
+	for ; i < len(c.queue); i++ {
+		e := c.queue[i]
+		if now.Before(e.expire) || !e.finished() {
+			break
+		}
+		if c.entries[e.key] == e {
+			delete(c.entries, e.key)
+		}
+		c.queue[i] = nil
+	}
+	c.queue = c.queue[i:]
+}
+
+func (c *idempotencyCache) len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.entries)
+}
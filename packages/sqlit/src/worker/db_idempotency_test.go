@@ -0,0 +1,59 @@
+package worker
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
+)
+
+func TestIdempotencyCache(t *testing.T) {
+	Convey("Given an idempotency cache", t, func() {
+		var (
+			c    = newIdempotencyCache(time.Minute)
+			now  = time.Now()
+			resp = &types.Response{}
+		)
+		So(c.window, ShouldEqual, time.Minute)
+		So(newIdempotencyCache(0).window, ShouldEqual, DefaultIdempotencyWindow)
+
+		e1, first := c.begin("node1", "key", now)
+		So(first, ShouldBeTrue)
+
+		Convey("The retries should share the response of the first request", func() {
+			e2, first := c.begin("node1", "key", now)
+			So(first, ShouldBeFalse)
+			So(e2, ShouldEqual, e1)
+			_, first = c.begin("node2", "key", now)
+			So(first, ShouldBeTrue)
+
+			go c.finish(e1, resp, nil)
+			<-e2.done
+			So(e2.resp, ShouldEqual, resp)
+			So(e2.err, ShouldBeNil)
+		})
+		Convey("The failed request should be able to retry", func() {
+			c.finish(e1, nil, errors.New("failed"))
+			So(e1.err, ShouldNotBeNil)
+			e2, first := c.begin("node1", "key", now)
+			So(first, ShouldBeTrue)
+			So(e2, ShouldNotEqual, e1)
+		})
+		Convey("The entries should expire out of the window after finished", func() {
+			_, first = c.begin("node1", "key", now.Add(2*time.Minute))
+			So(first, ShouldBeFalse)
+			So(c.len(), ShouldEqual, 1)
+
+			c.finish(e1, resp, nil)
+			_, first = c.begin("node1", "key2", now.Add(time.Minute))
+			So(first, ShouldBeTrue)
+			So(c.len(), ShouldEqual, 1)
+			_, first = c.begin("node1", "key", now.Add(time.Minute))
+			So(first, ShouldBeTrue)
+			So(c.len(), ShouldEqual, 2)
+		})
+	})
+}
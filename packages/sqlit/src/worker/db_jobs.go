@@ -0,0 +1,193 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	x "sqlit/src/dpos"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultJobCheckInterval defines the default interval of checking the due runs of the
+	// scheduled jobs, the runs start within the interval after the scheduled times.
+	DefaultJobCheckInterval = 10 * time.Second
+	// JobRunTimeout defines the max time of a round of the scheduled jobs runs.
+	JobRunTimeout = time.Minute
+
+	mwMinerDBJobs = "service:miner:db:jobs"
+)
+
+// jobVars exports the counts of the scheduled jobs runs, keyed by database id.
+var jobVars = expvar.NewMap(mwMinerDBJobs)
+
+// jobScheduler runs the due scheduled jobs of a database periodically on the leader, the runs are
+// sent as the write queries signed by the leader with the run history in the same requests, so
+// they are replicated and logged in the blocks like the queries of the clients.
+type jobScheduler struct {
+	interval time.Duration
+	connID   uint64
+	stopCh   chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+
+	runs   *expvar.Int
+	failed *expvar.Int
+	errors *expvar.Int
+	vars   *expvar.Map
+}
+
+func newJobScheduler(interval time.Duration) *jobScheduler {
+	if interval < 0 {
+		return nil
+	}
+	if interval == 0 {
+		interval = DefaultJobCheckInterval
+	}
+	s := &jobScheduler{
+		interval: interval,
+		connID:   rand.Uint64(),
+		stopCh:   make(chan struct{}),
+		runs:     new(expvar.Int),
+		failed:   new(expvar.Int),
+		errors:   new(expvar.Int),
+		vars:     new(expvar.Map).Init(),
+	}
+	s.vars.Set("runs", s.runs)
+	s.vars.Set("failed_runs", s.failed)
+	s.vars.Set("errors", s.errors)
+	return s
+}
+
+func (s *jobScheduler) start(db *Database) {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.stopCh:
+				return
+			case <-ticker.C:
+			}
+			if !db.chain.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), JobRunTimeout)
+			runs, failed, err := db.runJobs(ctx, getLocalTime())
+			cancel()
+			s.runs.Add(runs)
+			s.failed.Add(failed)
+			if err != nil {
+				s.errors.Add(1)
+				log.WithField("db", db.dbID).WithError(err).Warning("run scheduled jobs failed")
+			}
+		}
+	}()
+}
+
+func (s *jobScheduler) stop() {
+	s.once.Do(func() { close(s.stopCh) })
+	s.wg.Wait()
+}
+
+// scheduledJobs returns the scheduled jobs of the database.
+func (db *Database) scheduledJobs(ctx context.Context) (jobs []*x.Job, err error) {
+	rows, err := db.readLocal(ctx, `SELECT "name", "schedule", "query", "next_run" FROM "`+
+		x.JobTableName+`" ORDER BY "name"`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			// no job is created yet
+			err = nil
+		}
+		return
+	}
+	for _, r := range rows {
+		if len(r.Values) != 4 {
+			continue
+		}
+		var j = &x.Job{
+			Name:     valueString(r.Values[0]),
+			Schedule: valueString(r.Values[1]),
+			Query:    valueString(r.Values[2]),
+		}
+		if r.Values[3] != nil {
+			j.NextRun = time.Unix(valueInt(r.Values[3]), 0).UTC()
+		}
+		jobs = append(jobs, j)
+	}
+	return
+}
+
+// jobQueries returns the queries of the run of job started at now, which run the job query unless
+// the run is failed with runErr, record the run and schedule the next run of the job.
+func jobQueries(j *x.Job, next, now time.Time, runErr error) (queries []types.Query) {
+	if runErr == nil {
+		queries = append(queries, types.Query{Pattern: j.Query})
+	}
+	for _, q := range x.JobRunQueries(j, now, runErr) {
+		queries = append(queries, types.Query{Pattern: q})
+	}
+	return append(queries, types.Query{Pattern: x.JobScheduleQuery(j, next)})
+}
+
+// runJobs runs the scheduled jobs due at now on the leader, and schedules the next runs of the
+// new jobs. The runs missed, e.g. during a leader change, are run once. The failed runs are
+// recorded in the run history with the errors and don't stop the other jobs.
+func (db *Database) runJobs(ctx context.Context, now time.Time) (runs, failed int64, err error) {
+	jobs, err := db.scheduledJobs(ctx)
+	if err != nil || len(jobs) == 0 {
+		return
+	}
+	var schedules []types.Query
+	for _, j := range jobs {
+		s, perr := x.ParseCron(j.Schedule)
+		if perr != nil {
+			// the job table is modified by the client directly
+			log.WithFields(log.Fields{
+				"db":  db.dbID,
+				"job": j.Name,
+			}).WithError(perr).Warning("invalid job schedule, skip job")
+			continue
+		}
+		var next = s.Next(now)
+		if j.NextRun.IsZero() {
+			schedules = append(schedules, types.Query{Pattern: x.JobScheduleQuery(j, next)})
+			continue
+		}
+		if now.Before(j.NextRun) {
+			continue
+		}
+		var runErr error
+		if runErr = db.quota.check(); runErr == nil {
+			_, runErr = db.leaderWrite(ctx, db.jobs.connID, "job", jobQueries(j, next, now, nil))
+		}
+		if runErr == nil {
+			runs++
+			continue
+		}
+		failed++
+		log.WithFields(log.Fields{
+			"db":  db.dbID,
+			"job": j.Name,
+		}).WithError(runErr).Warning("scheduled job failed")
+		if ctx.Err() != nil {
+			return runs, failed, runErr
+		}
+		if _, err = db.leaderWrite(
+			ctx, db.jobs.connID, "job", jobQueries(j, next, now, runErr),
+		); err != nil {
+			return
+		}
+	}
+	if len(schedules) > 0 {
+		_, err = db.leaderWrite(ctx, db.jobs.connID, "job", schedules)
+	}
+	return
+}
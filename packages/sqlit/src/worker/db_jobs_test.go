@@ -0,0 +1,40 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	x "sqlit/src/dpos"
+)
+
+func TestJobQueries(t *testing.T) {
+	Convey("Given a scheduled job due at now", t, func() {
+		var (
+			now  = time.Date(2024, 2, 28, 10, 0, 5, 0, time.UTC)
+			next = now.Truncate(time.Hour).Add(time.Hour)
+			j    = &x.Job{
+				Name:     "rollup",
+				Schedule: "@hourly",
+				Query:    `INSERT INTO stats SELECT COUNT(1) FROM events`,
+				NextRun:  now.Truncate(time.Hour),
+			}
+		)
+		Convey("The run should execute the job query before recording the run", func() {
+			var q = jobQueries(j, next, now, nil)
+			So(q, ShouldHaveLength, 4)
+			So(q[0].Pattern, ShouldEqual, j.Query)
+			So(q[1].Pattern, ShouldContainSubstring, `changes()`)
+			So(q[3].Pattern, ShouldEqual, x.JobScheduleQuery(j, next))
+		})
+		Convey("The failed run should only be recorded with the error", func() {
+			var q = jobQueries(j, next, now, errors.New("no such table: events"))
+			So(q, ShouldHaveLength, 3)
+			So(q[0].Pattern, ShouldContainSubstring, `'no such table: events'`)
+			// only applies to the job still scheduled at the due time
+			So(q[2].Pattern, ShouldEndWith, `"next_run" = 1709114400`)
+		})
+	})
+}
@@ -0,0 +1,216 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// LatencySampleSize defines the count of the recent round-trip times kept for each peer.
+	LatencySampleSize = 16
+	// PeerPingTimeout defines the max time to ping a peer of the database.
+	PeerPingTimeout = 5 * time.Second
+
+	mwMinerPeerLatency = "service:miner:peer:latency"
+)
+
+// latencyVars exports the median round-trip times in milliseconds of the database peers, keyed by
+// database id and then node id.
+var latencyVars = expvar.NewMap(mwMinerPeerLatency)
+
+// PeerPingReq defines the request of a miner measuring the round-trip time to a peer of the
+// database.
+type PeerPingReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+}
+
+// PeerPingResp defines the response of a peer ping request.
+type PeerPingResp struct {
+	// Timestamp is the local time of the peer answering the ping, which estimates its clock skew.
+	Timestamp time.Time
+}
+
+// peerLatency records the recent round-trip times to the peers of a database.
+type peerLatency struct {
+	sync.RWMutex
+	samples map[proto.NodeID][]time.Duration
+	vars    *expvar.Map
+}
+
+func newPeerLatency() *peerLatency {
+	return &peerLatency{
+		samples: make(map[proto.NodeID][]time.Duration),
+		vars:    new(expvar.Map).Init(),
+	}
+}
+
+// record adds the round-trip time rtt to node, only the latest LatencySampleSize ones are kept.
+func (l *peerLatency) record(node proto.NodeID, rtt time.Duration) {
+	l.Lock()
+	defer l.Unlock()
+	s := append(l.samples[node], rtt)
+	if len(s) > LatencySampleSize {
+		s = s[len(s)-LatencySampleSize:]
+	}
+	l.samples[node] = s
+	v := new(expvar.Float)
+	v.Set(float64(medianDuration(s)) / float64(time.Millisecond))
+	l.vars.Set(string(node), v)
+}
+
+// median returns the median of the recent round-trip times to node.
+func (l *peerLatency) median(node proto.NodeID) (rtt time.Duration, ok bool) {
+	l.RLock()
+	defer l.RUnlock()
+	s := l.samples[node]
+	if len(s) == 0 {
+		return
+	}
+	return medianDuration(s), true
+}
+
+// rank returns the nodes ordered by the median round-trip time, the unmeasured nodes follow in
+// the original order.
+func (l *peerLatency) rank(nodes []proto.NodeID) (ranked []proto.NodeID) {
+	type entry struct {
+		node proto.NodeID
+		rtt  time.Duration
+		ok   bool
+	}
+	entries := make([]entry, len(nodes))
+	for i, n := range nodes {
+		entries[i].node = n
+		entries[i].rtt, entries[i].ok = l.median(n)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].ok != entries[j].ok {
+			return entries[i].ok
+		}
+		return entries[i].rtt < entries[j].rtt
+	})
+	ranked = make([]proto.NodeID, len(entries))
+	for i, e := range entries {
+		ranked[i] = e.node
+	}
+	return
+}
+
+func medianDuration(s []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), s...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// latencyProber pings the peers of the database periodically to record the round-trip times.
+type latencyProber struct {
+	interval time.Duration
+	stopCh   chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+func newLatencyProber(interval time.Duration) *latencyProber {
+	if interval <= 0 {
+		return nil
+	}
+	return &latencyProber{
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (p *latencyProber) start(db *Database) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+				db.pingPeers()
+			}
+		}
+	}()
+}
+
+func (p *latencyProber) stop() {
+	p.once.Do(func() { close(p.stopCh) })
+	p.wg.Wait()
+}
+
+// pingPeers measures the round-trip times and the clock skews of the other peers of the database
+// concurrently.
+func (db *Database) pingPeers() {
+	var wg sync.WaitGroup
+	for _, s := range db.bftraftRuntime.Peers().Servers {
+		if s == db.nodeID {
+			continue
+		}
+		wg.Add(1)
+		go func(s proto.NodeID) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), PeerPingTimeout)
+			defer cancel()
+			var (
+				resp  = &PeerPingResp{}
+				start = getLocalTime()
+			)
+			if err := mux.NewCaller().CallNodeWithContext(ctx, s, route.DBSPeerPing.String(),
+				&PeerPingReq{DatabaseID: db.dbID}, resp,
+			); err != nil {
+				log.WithFields(log.Fields{
+					"db":   db.dbID,
+					"peer": s,
+				}).WithError(err).Debug("ping peer failed")
+				return
+			}
+			rtt := time.Since(start)
+			db.latency.record(s, rtt)
+			db.recordPeerSkew(s, start, rtt, resp.Timestamp)
+		}(s)
+	}
+	wg.Wait()
+}
+
+// PeerPing handles the round-trip time measurement from a peer of the database.
+func (dbms *DBMS) PeerPing(node proto.NodeID, req *PeerPingReq) (err error) {
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if !exists {
+		return ErrNotExists
+	}
+	if db.isStandby() {
+		return ErrStandbyMiner
+	}
+	if _, found := db.bftraftRuntime.Peers().Find(node); !found {
+		return errors.Wrapf(ErrPermissionDeny, "node %s is not a peer", node)
+	}
+	return
+}
+
+// PeerPing rpc, called by the peers of a database to measure the round-trip time and the clock
+// skew.
+func (rpc *DBMSRPCService) PeerPing(req *PeerPingReq, resp *PeerPingResp) (err error) {
+	if err = rpc.dbms.PeerPing(req.GetNodeID().ToNodeID(), req); err != nil {
+		return
+	}
+	resp.Timestamp = getLocalTime()
+	return
+}
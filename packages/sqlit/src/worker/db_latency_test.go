@@ -0,0 +1,52 @@
+package worker
+
+import (
+	"expvar"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+)
+
+func TestPeerLatency(t *testing.T) {
+	Convey("Given the round-trip times to the peers", t, func() {
+		var l = newPeerLatency()
+		for _, v := range []time.Duration{30, 10, 20} {
+			l.record("node2", v*time.Millisecond)
+		}
+		for _, v := range []time.Duration{5, 100, 4, 200} {
+			l.record("node3", v*time.Millisecond)
+		}
+
+		Convey("The median round-trip time should be reported", func() {
+			rtt, ok := l.median("node2")
+			So(ok, ShouldBeTrue)
+			So(rtt, ShouldEqual, 20*time.Millisecond)
+			rtt, ok = l.median("node3")
+			So(ok, ShouldBeTrue)
+			So(rtt, ShouldEqual, 52500*time.Microsecond)
+			_, ok = l.median("node4")
+			So(ok, ShouldBeFalse)
+			So(l.vars.Get("node2").(*expvar.Float).Value(), ShouldEqual, 20)
+		})
+		Convey("Only the recent round-trip times should be kept", func() {
+			for i := 0; i < LatencySampleSize; i++ {
+				l.record("node2", time.Second)
+			}
+			rtt, _ := l.median("node2")
+			So(rtt, ShouldEqual, time.Second)
+			So(len(l.samples["node2"]), ShouldEqual, LatencySampleSize)
+		})
+		Convey("The nodes should be ranked by the median round-trip time", func() {
+			ranked := l.rank([]proto.NodeID{"node1", "node4", "node3", "node2"})
+			So(ranked, ShouldResemble, []proto.NodeID{"node2", "node3", "node1", "node4"})
+		})
+	})
+	Convey("Given a database without the latency probe", t, func() {
+		So(newLatencyProber(0), ShouldBeNil)
+		err := (&DBMS{}).PeerPing("node1", &PeerPingReq{DatabaseID: "db"})
+		So(err, ShouldEqual, ErrNotExists)
+	})
+}
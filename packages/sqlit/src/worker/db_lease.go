@@ -0,0 +1,96 @@
+package worker
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	kt "sqlit/src/bftraft/types"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// nextTermPeers returns the peers config to apply in place of peers, so that the term never goes
+// backwards. The peers assigned by block producer are always in term 0: they are ignored if the
+// leader and the servers are unchanged in the current term, otherwise they are applied in the
+// next term signed by signer. All the peers of a database derive the same term from the same
+// current one.
+func nextTermPeers(
+	current, peers *proto.Peers, signer *asymmetric.PrivateKey) (p *proto.Peers, err error,
+) {
+	if peers == nil || peers.Term > current.Term {
+		return peers, nil
+	}
+	if peers.Leader == current.Leader && peers.SameServers(current) {
+		if peers.Term == current.Term {
+			return peers, nil
+		}
+		return current, nil
+	}
+	clone := peers.Clone()
+	p = &clone
+	p.Term = current.Term + 1
+	if err = p.Sign(signer); err != nil {
+		err = errors.Wrap(err, "sign peers failed")
+	}
+	return
+}
+
+// leaderError converts the errors of a write refused by a stale or deposed leader to
+// ErrLeaderChanged, which tells the client to learn the current peers.
+func leaderError(err error) error {
+	if errors.Cause(err) == kt.ErrNotLeader ||
+		// the errors of the followers are passed as the strings through rpc
+		strings.Contains(err.Error(), kt.ErrStaleTerm.Error()) {
+		return errors.Wrap(ErrLeaderChanged, err.Error())
+	}
+	return err
+}
+
+// checkLease refuses the reads on the leader if its lease is not renewed by the followers, a new
+// leader may be serving the writes in another partition.
+func (db *Database) checkLease() (err error) {
+	if !db.chain.IsLeader() {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), PrepareTimeout)
+	defer cancel()
+	if err = db.bftraftRuntime.WaitLease(ctx); err != nil {
+		err = errors.Wrap(ErrLeaderChanged, err.Error())
+	}
+	return
+}
+
+// staleReadBound returns the staleness bound of the follower state for a stale read at now, the
+// follower may miss the writes committed by the leader since its last contact.
+func staleReadBound(lastContact, now time.Time) (bound time.Duration, err error) {
+	if lastContact.IsZero() {
+		return 0, ErrStalenessUnknown
+	}
+	if bound = now.Sub(lastContact); bound < 0 {
+		bound = 0
+	}
+	return
+}
+
+// QueryPeers returns the current peers of the database, the client learns the term and the leader
+// from the peers.
+func (dbms *DBMS) QueryPeers(req *types.QueryPeersReq) (peers *proto.Peers, err error) {
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if !exists {
+		return nil, ErrNotExists
+	}
+	if db.isStandby() {
+		return nil, ErrStandbyMiner
+	}
+	return db.bftraftRuntime.Peers(), nil
+}
+
+// QueryPeers rpc, called by client to learn the current peers of a database.
+func (rpc *DBMSRPCService) QueryPeers(req *types.QueryPeersReq, resp *types.QueryPeersResp) (err error) {
+	resp.Peers, err = rpc.dbms.QueryPeers(req)
+	return
+}
@@ -0,0 +1,99 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	kt "sqlit/src/bftraft/types"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestNextTermPeers(t *testing.T) {
+	Convey("Given the peers of a database in term 2", t, func() {
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		current := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Term:    2,
+				Leader:  "node2",
+				Servers: []proto.NodeID{"node1", "node2", "node3"},
+			},
+		}
+		So(current.Sign(privKey), ShouldBeNil)
+		assigned := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Leader:  "node1",
+				Servers: []proto.NodeID{"node1", "node2", "node3"},
+			},
+		}
+		So(assigned.Sign(privKey), ShouldBeNil)
+
+		Convey("The peers of a newer term should be applied as is", func() {
+			peers, err := newLeaderPeers(current, "node3", privKey)
+			So(err, ShouldBeNil)
+			p, err := nextTermPeers(current, peers, privKey)
+			So(err, ShouldBeNil)
+			So(p, ShouldEqual, peers)
+		})
+		Convey("The unchanged peers of an older term should be ignored", func() {
+			peers := current.Clone()
+			peers.Term = 0
+			So(peers.Sign(privKey), ShouldBeNil)
+			p, err := nextTermPeers(current, &peers, privKey)
+			So(err, ShouldBeNil)
+			So(p, ShouldEqual, current)
+		})
+		Convey("The changed peers of an older term should be applied in the next term", func() {
+			p, err := nextTermPeers(current, assigned, privKey)
+			So(err, ShouldBeNil)
+			So(p.Term, ShouldEqual, 3)
+			So(p.Leader, ShouldEqual, "node1")
+			So(p.Verify(), ShouldBeNil)
+			So(assigned.Term, ShouldEqual, 0)
+
+			// all the peers derive the same term
+			q, err := nextTermPeers(current, assigned, privKey)
+			So(err, ShouldBeNil)
+			So(q.PeersHeader, ShouldResemble, p.PeersHeader)
+
+			assigned.Servers = assigned.Servers[:2]
+			So(assigned.Sign(privKey), ShouldBeNil)
+			p, err = nextTermPeers(current, assigned, privKey)
+			So(err, ShouldBeNil)
+			So(p.Term, ShouldEqual, 3)
+		})
+	})
+}
+
+func TestLeaderError(t *testing.T) {
+	Convey("The writes refused by a stale leader should be reported as leader changes", t, func() {
+		err := leaderError(errors.Wrap(kt.ErrNotLeader, "deposed in term 1"))
+		So(errors.Cause(err), ShouldEqual, ErrLeaderChanged)
+		So(err.Error(), ShouldContainSubstring, types.ErrCodeLeaderChanged)
+
+		err = leaderError(errors.Errorf("fail on nodes: map[node2:%v]", kt.ErrStaleTerm))
+		So(errors.Cause(err), ShouldEqual, ErrLeaderChanged)
+
+		err = leaderError(kt.ErrPrepareTimeout)
+		So(err, ShouldEqual, kt.ErrPrepareTimeout)
+	})
+}
+
+func TestStaleReadBound(t *testing.T) {
+	Convey("The staleness bound should be measured since the last contact with the leader", t, func() {
+		var now = time.Now()
+		bound, err := staleReadBound(now.Add(-2*time.Second), now)
+		So(err, ShouldBeNil)
+		So(bound, ShouldEqual, 2*time.Second)
+		bound, err = staleReadBound(now.Add(time.Second), now)
+		So(err, ShouldBeNil)
+		So(bound, ShouldEqual, 0)
+		_, err = staleReadBound(time.Time{}, now)
+		So(err, ShouldEqual, ErrStalenessUnknown)
+	})
+}
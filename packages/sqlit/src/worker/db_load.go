@@ -0,0 +1,140 @@
+package worker
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultLoadReportInterval defines the default interval of reporting the database loads to
+	// the block producers.
+	DefaultLoadReportInterval = time.Minute
+	// LoadLatencySamples defines the max count of the query latencies kept in a report period for
+	// the percentile, the later ones replace the earlier ones at random.
+	LoadLatencySamples = 1024
+	// LoadReportTimeout defines the max time to report the database loads to a block producer.
+	LoadReportTimeout = 10 * time.Second
+)
+
+// loadTracker measures the load of a database between the reports to the block producers.
+type loadTracker struct {
+	sync.Mutex
+	start       time.Time
+	queries     uint64
+	samples     []time.Duration
+	cacheHits   uint64
+	cacheMisses uint64
+	storage     uint64
+}
+
+func newLoadTracker(now time.Time, storage uint64) *loadTracker {
+	return &loadTracker{
+		start:   now,
+		samples: make([]time.Duration, 0, LoadLatencySamples),
+		storage: storage,
+	}
+}
+
+// record adds a query served in latency.
+func (t *loadTracker) record(latency time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+	t.queries++
+	if len(t.samples) < LoadLatencySamples {
+		t.samples = append(t.samples, latency)
+		return
+	}
+	// reservoir sampling keeps each latency of the period with the same probability
+	if i := rand.Int63n(int64(t.queries)); i < LoadLatencySamples {
+		t.samples[i] = latency
+	}
+}
+
+// cache adds a cache lookup of the database.
+func (t *loadTracker) cache(hit bool) {
+	t.Lock()
+	defer t.Unlock()
+	if hit {
+		t.cacheHits++
+	} else {
+		t.cacheMisses++
+	}
+}
+
+// report returns the load of the database since the last report and starts a new period.
+func (t *loadTracker) report(dbID proto.DatabaseID, now time.Time, storage uint64) *types.DatabaseLoad {
+	t.Lock()
+	defer t.Unlock()
+	load := &types.DatabaseLoad{
+		DatabaseID:    dbID,
+		Timestamp:     now,
+		Period:        now.Sub(t.start),
+		Queries:       t.queries,
+		CacheHits:     t.cacheHits,
+		CacheMisses:   t.cacheMisses,
+		StorageBytes:  storage,
+		StorageGrowth: int64(storage) - int64(t.storage),
+	}
+	if len(t.samples) > 0 {
+		sort.Slice(t.samples, func(i, j int) bool { return t.samples[i] < t.samples[j] })
+		load.P95Latency = t.samples[(len(t.samples)*95+99)/100-1]
+	}
+	t.start = now
+	t.queries = 0
+	t.samples = t.samples[:0]
+	t.cacheHits = 0
+	t.cacheMisses = 0
+	t.storage = storage
+	return load
+}
+
+// reportLoads sends the loads of the serving databases to all block producers.
+func (dbms *DBMS) reportLoads() {
+	var (
+		now = time.Now()
+		req = &types.ReportDatabaseLoadReq{}
+	)
+	dbms.dbMap.Range(func(_, rawDB interface{}) bool {
+		db := rawDB.(*Database)
+		if !db.isStandby() {
+			req.Loads = append(req.Loads, db.load.report(db.dbID, now, db.quota.usage()))
+		}
+		return true
+	})
+	if len(req.Loads) == 0 {
+		return
+	}
+	for _, bp := range route.GetBPs() {
+		ctx, cancel := context.WithTimeout(context.Background(), LoadReportTimeout)
+		if err := mux.NewCaller().CallNodeWithContext(ctx, bp,
+			route.MCCReportDatabaseLoad.String(), req, &types.ReportDatabaseLoadResp{},
+		); err != nil {
+			log.WithField("bp", bp).WithError(err).Warning("report database loads failed")
+		}
+		cancel()
+	}
+}
+
+// runLoadReporter reports the loads of the databases to the block producers periodically until
+// ctx is done.
+func (dbms *DBMS) runLoadReporter(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dbms.reportLoads()
+		}
+	}
+}
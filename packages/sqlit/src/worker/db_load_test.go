@@ -0,0 +1,54 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLoadTracker(t *testing.T) {
+	Convey("Given a load tracker of a database", t, func() {
+		var (
+			now = time.Now()
+			l   = newLoadTracker(now, 1000)
+		)
+		for i := 1; i <= 100; i++ {
+			l.record(time.Duration(i) * time.Millisecond)
+		}
+		l.cache(true)
+		l.cache(true)
+		l.cache(false)
+
+		Convey("The load of the period should be reported", func() {
+			load := l.report("db", now.Add(10*time.Second), 3000)
+			So(load.DatabaseID, ShouldEqual, "db")
+			So(load.Period, ShouldEqual, 10*time.Second)
+			So(load.Queries, ShouldEqual, 100)
+			So(load.QPS(), ShouldEqual, 10)
+			So(load.P95Latency, ShouldEqual, 95*time.Millisecond)
+			So(load.CacheHits, ShouldEqual, 2)
+			So(load.CacheMisses, ShouldEqual, 1)
+			So(load.StorageBytes, ShouldEqual, 3000)
+			So(load.StorageGrowth, ShouldEqual, 2000)
+
+			Convey("The next period should start over", func() {
+				load = l.report("db", now.Add(20*time.Second), 2500)
+				So(load.Period, ShouldEqual, 10*time.Second)
+				So(load.Queries, ShouldEqual, 0)
+				So(load.P95Latency, ShouldEqual, 0)
+				So(load.CacheHitRate(), ShouldEqual, 0)
+				So(load.StorageGrowth, ShouldEqual, -500)
+			})
+		})
+		Convey("The latency samples should be bounded", func() {
+			for i := 0; i < 2*LoadLatencySamples; i++ {
+				l.record(time.Second)
+			}
+			So(len(l.samples), ShouldEqual, LoadLatencySamples)
+			load := l.report("db", now.Add(time.Second), 1000)
+			So(load.Queries, ShouldEqual, 100+2*LoadLatencySamples)
+			So(load.P95Latency, ShouldEqual, time.Second)
+		})
+	})
+}
@@ -0,0 +1,212 @@
+package worker
+
+import (
+	"expvar"
+	"strings"
+	"sync"
+	"time"
+
+	mw "github.com/zserge/metric"
+
+	"sqlit/src/conf"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// MaxPayloadFingerprints defines the max count of query fingerprints of which the payload sizes
+	// are tracked by a database, the least recently seen one is evicted for a new fingerprint.
+	MaxPayloadFingerprints = 100
+	// DefaultPayloadAlertMinSize defines the default min mean payload size to raise an alert.
+	DefaultPayloadAlertMinSize = 4 << 10
+	// DefaultPayloadAlertWindow defines the default interval of comparing the mean payload sizes
+	// with the baselines.
+	DefaultPayloadAlertWindow = time.Minute
+
+	// payloadBaselineWeight is the weight of the baseline blended with the mean size of a window.
+	payloadBaselineWeight = 0.8
+
+	mwMinerPayloadSize = "service:miner:db:payload_size"
+)
+
+// payloadSizeVars exports the histograms of the request and response payload sizes, keyed by
+// database id.
+var payloadSizeVars = expvar.NewMap(mwMinerPayloadSize)
+
+// payloadTrend tracks the mean payload size of the current window against the baseline of the
+// previous ones.
+type payloadTrend struct {
+	baseline   float64 // the moving average of the window means, 0 before the first window
+	sum, count float64
+}
+
+func (t *payloadTrend) add(size int) {
+	t.sum += float64(size)
+	t.count++
+}
+
+// roll closes the current window, it returns the mean size of the window and the baseline before
+// it, ok is false if the window is empty.
+func (t *payloadTrend) roll() (mean, baseline float64, ok bool) {
+	if t.count == 0 {
+		return
+	}
+	mean, baseline = t.sum/t.count, t.baseline
+	if t.baseline == 0 {
+		t.baseline = mean
+	} else {
+		t.baseline = payloadBaselineWeight*t.baseline + (1-payloadBaselineWeight)*mean
+	}
+	t.sum, t.count = 0, 0
+	return mean, baseline, true
+}
+
+// payloadStat is the payload sizes of a query fingerprint.
+type payloadStat struct {
+	request, response   mw.Metric
+	reqTrend, respTrend payloadTrend
+	vars                *expvar.Map
+	lastSeen            time.Time
+}
+
+func newPayloadStat() *payloadStat {
+	s := &payloadStat{
+		request:  mw.NewHistogram("10m1m"),
+		response: mw.NewHistogram("10m1m"),
+		vars:     new(expvar.Map).Init(),
+	}
+	s.vars.Set("request", s.request)
+	s.vars.Set("response", s.response)
+	return s
+}
+
+// payloadStats records the request and response payload sizes of a database and its query
+// fingerprints, and warns of the fingerprints of which the mean sizes suddenly grow, such as an
+// application starting to store the blobs it shouldn't.
+type payloadStats struct {
+	sync.Mutex
+	dbID        proto.DatabaseID
+	growthRatio float64
+	minSize     float64
+	window      time.Duration
+	windowStart time.Time
+
+	request, response mw.Metric
+	alerts            *expvar.Int
+	stats             map[string]*payloadStat
+	fpVars            *expvar.Map
+	vars              *expvar.Map
+}
+
+func newPayloadStats(dbID proto.DatabaseID, cfg *conf.PayloadAlertInfo, now time.Time) *payloadStats {
+	s := &payloadStats{
+		dbID:        dbID,
+		minSize:     DefaultPayloadAlertMinSize,
+		window:      DefaultPayloadAlertWindow,
+		windowStart: now,
+		request:     mw.NewHistogram("1h1m"),
+		response:    mw.NewHistogram("1h1m"),
+		alerts:      new(expvar.Int),
+		stats:       make(map[string]*payloadStat),
+		fpVars:      new(expvar.Map).Init(),
+		vars:        new(expvar.Map).Init(),
+	}
+	if cfg != nil {
+		s.growthRatio = cfg.GrowthRatio
+		if cfg.MinSize > 0 {
+			s.minSize = float64(cfg.MinSize)
+		}
+		if cfg.Window > 0 {
+			s.window = cfg.Window
+		}
+	}
+	s.vars.Set("request", s.request)
+	s.vars.Set("response", s.response)
+	s.vars.Set("alerts", s.alerts)
+	s.vars.Set("fingerprints", s.fpVars)
+	return s
+}
+
+// record adds the payload sizes of the request req and its response resp.
+func (p *payloadStats) record(req *types.Request, resp *types.Response, now time.Time) {
+	var (
+		reqSize  = req.Payload.Msgsize()
+		respSize int
+		fps      = make([]string, len(req.Payload.Queries))
+	)
+	if resp != nil {
+		respSize = resp.Payload.Msgsize()
+	}
+	for i, v := range req.Payload.Queries {
+		fps[i] = fingerprint(v.Pattern)
+	}
+	var fp = strings.Join(fps, "; ")
+	p.request.Add(float64(reqSize))
+	p.response.Add(float64(respSize))
+
+	p.Lock()
+	defer p.Unlock()
+	s, ok := p.stats[fp]
+	if !ok {
+		if len(p.stats) >= MaxPayloadFingerprints {
+			p.evict()
+		}
+		s = newPayloadStat()
+		p.stats[fp] = s
+		p.fpVars.Set(fp, s.vars)
+	}
+	s.request.Add(float64(reqSize))
+	s.response.Add(float64(respSize))
+	s.reqTrend.add(reqSize)
+	s.respTrend.add(respSize)
+	s.lastSeen = now
+
+	if now.Sub(p.windowStart) >= p.window {
+		p.windowStart = now
+		p.check()
+	}
+}
+
+// evict removes the least recently seen fingerprint, the caller must hold the lock.
+func (p *payloadStats) evict() {
+	var (
+		oldest string
+		seen   time.Time
+	)
+	for fp, s := range p.stats {
+		if oldest == "" || s.lastSeen.Before(seen) {
+			oldest, seen = fp, s.lastSeen
+		}
+	}
+	delete(p.stats, oldest)
+	p.fpVars.Delete(oldest)
+}
+
+// check closes the window of the fingerprints, and warns of the mean sizes exceeding the
+// baselines by the growth ratio, the caller must hold the lock.
+func (p *payloadStats) check() {
+	for fp, s := range p.stats {
+		for _, v := range []struct {
+			kind  string
+			trend *payloadTrend
+		}{
+			{"request", &s.reqTrend},
+			{"response", &s.respTrend},
+		} {
+			mean, baseline, ok := v.trend.roll()
+			if !ok || p.growthRatio <= 0 || baseline == 0 ||
+				mean < p.minSize || mean < baseline*p.growthRatio {
+				continue
+			}
+			p.alerts.Add(1)
+			log.WithFields(log.Fields{
+				"db":          p.dbID,
+				"fingerprint": fp,
+				"payload":     v.kind,
+				"mean":        int64(mean),
+				"baseline":    int64(baseline),
+			}).Warning("payload size of query fingerprint grew suddenly")
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package worker
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/types"
+)
+
+func TestPayloadStats(t *testing.T) {
+	Convey("Given the payload stats of a database alerting on the doubled sizes", t, func() {
+		var (
+			now = time.Now()
+			p   = newPayloadStats("db", &conf.PayloadAlertInfo{
+				GrowthRatio: 2,
+				MinSize:     1024,
+				Window:      time.Minute,
+			}, now)
+			insert = func(size int) *types.Request {
+				return &types.Request{Payload: types.RequestPayload{Queries: []types.Query{{
+					Pattern: "INSERT INTO t (v) VALUES (?)",
+					Args:    []types.NamedArg{{Value: strings.Repeat("x", size)}},
+				}}}}
+			}
+			selectReq = &types.Request{Payload: types.RequestPayload{Queries: []types.Query{{
+				Pattern: "SELECT v FROM t WHERE id = 1",
+			}}}}
+			resp = &types.Response{Payload: types.ResponsePayload{
+				Columns: []string{"v"},
+				Rows:    []types.ResponseRow{{Values: []interface{}{strings.Repeat("x", 512)}}},
+			}}
+		)
+		// the first window sets the baselines
+		for i := 0; i < 10; i++ {
+			p.record(insert(1024), &types.Response{}, now)
+			p.record(selectReq, resp, now)
+		}
+		now = now.Add(time.Minute)
+		p.record(selectReq, resp, now)
+		So(p.alerts.Value(), ShouldEqual, 0)
+		So(p.stats, ShouldHaveLength, 2)
+		So(p.fpVars.Get("insert into t(v) values(...)"), ShouldNotBeNil)
+		So(p.fpVars.Get("select v from t where id = ?"), ShouldNotBeNil)
+
+		Convey("The steady sizes should not raise alerts", func() {
+			for i := 0; i < 10; i++ {
+				p.record(insert(1100), &types.Response{}, now)
+			}
+			p.record(selectReq, resp, now.Add(time.Minute))
+			So(p.alerts.Value(), ShouldEqual, 0)
+		})
+		Convey("The sudden growth of the request sizes should raise an alert", func() {
+			for i := 0; i < 10; i++ {
+				p.record(insert(64<<10), &types.Response{}, now)
+			}
+			p.record(selectReq, resp, now.Add(time.Minute))
+			So(p.alerts.Value(), ShouldEqual, 1)
+		})
+		Convey("The sizes below the min size should not raise alerts", func() {
+			var big = &types.Response{Payload: types.ResponsePayload{
+				Columns: []string{"v"},
+				Rows:    []types.ResponseRow{{Values: []interface{}{strings.Repeat("x", 900)}}},
+			}}
+			p.record(selectReq, big, now)
+			p.record(selectReq, resp, now.Add(time.Minute))
+			So(p.alerts.Value(), ShouldEqual, 0)
+		})
+		Convey("The least recently seen fingerprints should be evicted", func() {
+			for i := 0; i < MaxPayloadFingerprints; i++ {
+				p.record(&types.Request{Payload: types.RequestPayload{Queries: []types.Query{{
+					Pattern: "SELECT * FROM t" + strings.Repeat("x", i),
+				}}}}, nil, now.Add(time.Second))
+			}
+			So(p.stats, ShouldHaveLength, MaxPayloadFingerprints)
+			So(p.fpVars.Get("insert into t(v) values(...)"), ShouldBeNil)
+		})
+	})
+}
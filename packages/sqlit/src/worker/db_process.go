@@ -0,0 +1,328 @@
+package worker
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+const (
+	// MaxTrackedConnections defines the max count of client connections tracked by a database,
+	// the longest idle one is evicted for a new connection.
+	MaxTrackedConnections = MaxRecordedConnectionSequences
+	// ConnectionIdleTimeout defines the idle time before a client connection is no longer listed.
+	ConnectionIdleTimeout = 10 * time.Minute
+
+	showProcessList = "show processlist"
+	killStatement   = "kill"
+)
+
+var (
+	processListColumns   = []string{"id", "node", "account", "command", "time_ms", "seq", "requests", "info"}
+	processListDeclTypes = []string{"TEXT", "TEXT", "TEXT", "TEXT", "REAL", "INTEGER", "INTEGER", "TEXT"}
+)
+
+// connKey identifies a client connection by the request node and its connection id.
+type connKey struct {
+	node proto.NodeID
+	id   uint64
+}
+
+// runningQuery is a query request running on a client connection.
+type runningQuery struct {
+	req    *types.Request
+	start  time.Time
+	ctx    context.Context
+	cancel context.CancelFunc
+	killed bool
+}
+
+// bind returns the child context of parent which is canceled once the query is killed or ended.
+func (q *runningQuery) bind(parent context.Context) context.Context {
+	ctx, cancel := context.WithCancel(parent)
+	context.AfterFunc(q.ctx, cancel)
+	return ctx
+}
+
+// clientConn is a client connection of a database, a connection runs one query at a time.
+type clientConn struct {
+	signee   *asymmetric.PublicKey
+	seq      uint64
+	requests uint64
+	lastSeen time.Time
+	running  []*runningQuery
+}
+
+// processList tracks the client connections of a database and their running queries, listed by
+// SHOW PROCESSLIST and interrupted by KILL.
+type processList struct {
+	sync.Mutex
+	max   int
+	conns map[connKey]*clientConn
+}
+
+func newProcessList(max int) *processList {
+	return &processList{
+		max:   max,
+		conns: make(map[connKey]*clientConn),
+	}
+}
+
+// begin tracks the query request req starting at now, the query must be ended by end with the
+// returned running query.
+func (l *processList) begin(req *types.Request, now time.Time) (q *runningQuery) {
+	var key = connKey{node: req.Header.NodeID, id: req.Header.ConnectionID}
+	q = &runningQuery{req: req, start: now}
+	q.ctx, q.cancel = context.WithCancel(context.Background())
+
+	l.Lock()
+	defer l.Unlock()
+	c, ok := l.conns[key]
+	if !ok {
+		if len(l.conns) >= l.max {
+			l.evict()
+		}
+		c = &clientConn{}
+		l.conns[key] = c
+	}
+	c.signee = req.Header.Signee
+	c.seq = req.Header.SeqNo
+	c.requests++
+	c.lastSeen = now
+	c.running = append(c.running, q)
+	return
+}
+
+// end stops tracking the running query q at now, and returns whether it's killed.
+func (l *processList) end(q *runningQuery, now time.Time) (killed bool) {
+	var key = connKey{node: q.req.Header.NodeID, id: q.req.Header.ConnectionID}
+	q.cancel()
+
+	l.Lock()
+	defer l.Unlock()
+	killed = q.killed
+	c, ok := l.conns[key]
+	if !ok {
+		return
+	}
+	for i, v := range c.running {
+		if v == q {
+			c.running = append(c.running[:i], c.running[i+1:]...)
+			break
+		}
+	}
+	c.lastSeen = now
+	return
+}
+
+// evict removes the longest idle connection without running queries, the caller must hold the
+// lock.
+func (l *processList) evict() {
+	var (
+		oldest connKey
+		seen   time.Time
+		found  bool
+	)
+	for k, c := range l.conns {
+		if len(c.running) == 0 && (!found || c.lastSeen.Before(seen)) {
+			oldest, seen, found = k, c.lastSeen, true
+		}
+	}
+	if found {
+		delete(l.conns, oldest)
+	}
+}
+
+// kill interrupts the running queries of the connection id, and returns the count of them.
+func (l *processList) kill(id uint64) (count int, err error) {
+	l.Lock()
+	defer l.Unlock()
+	var found bool
+	for k, c := range l.conns {
+		if k.id != id {
+			continue
+		}
+		found = true
+		for _, q := range c.running {
+			if !q.killed {
+				q.killed = true
+				q.cancel()
+				count++
+			}
+		}
+	}
+	if !found {
+		err = errors.Wrapf(ErrConnectionNotFound, "connection %d", id)
+	}
+	return
+}
+
+// rows returns the process list rows at now, the running connections first by their elapsed
+// time descending, then the idle ones. The connections idle longer than the timeout are removed.
+func (l *processList) rows(now time.Time) (rows []types.ResponseRow) {
+	type process struct {
+		key     connKey
+		conn    *clientConn
+		query   *runningQuery
+		elapsed time.Duration
+	}
+	var procs []*process
+
+	l.Lock()
+	for k, c := range l.conns {
+		if len(c.running) == 0 {
+			if idle := now.Sub(c.lastSeen); idle > ConnectionIdleTimeout {
+				delete(l.conns, k)
+			} else {
+				procs = append(procs, &process{key: k, conn: c, elapsed: idle})
+			}
+			continue
+		}
+		for _, q := range c.running {
+			procs = append(procs, &process{key: k, conn: c, query: q, elapsed: now.Sub(q.start)})
+		}
+	}
+	rows = make([]types.ResponseRow, len(procs))
+	for i, p := range procs {
+		var (
+			account string
+			command = "Sleep"
+			seq     = p.conn.seq
+			info    string
+		)
+		if p.conn.signee != nil {
+			if addr, err := crypto.PubKeyHash(p.conn.signee); err == nil {
+				account = addr.String()
+			}
+		}
+		if q := p.query; q != nil {
+			command = "Read"
+			if q.req.Header.QueryType == types.WriteQuery {
+				command = "Write"
+			}
+			if q.killed {
+				command = "Killed"
+			}
+			seq = q.req.Header.SeqNo
+			// the literals of the queries are not disclosed to the other users
+			var fps = make([]string, len(q.req.Payload.Queries))
+			for j, v := range q.req.Payload.Queries {
+				fps[j] = fingerprint(v.Pattern)
+			}
+			info = strings.Join(fps, "; ")
+		}
+		rows[i].Values = []interface{}{
+			strconv.FormatUint(p.key.id, 10),
+			string(p.key.node),
+			account,
+			command,
+			durationMillis(p.elapsed),
+			int64(seq),
+			int64(p.conn.requests),
+			info,
+		}
+	}
+	l.Unlock()
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		var si, sj = rows[i].Values[3] != "Sleep", rows[j].Values[3] != "Sleep"
+		if si != sj {
+			return si
+		}
+		if ei, ej := rows[i].Values[4].(float64), rows[j].Values[4].(float64); ei != ej {
+			return ei > ej
+		}
+		return rows[i].Values[0].(string) < rows[j].Values[0].(string)
+	})
+	return
+}
+
+// response builds the response of the SHOW PROCESSLIST request req.
+func (l *processList) response(node proto.NodeID, req *types.Request) *types.Response {
+	var rows = l.rows(time.Now())
+	return &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:     req.Header.RequestHeader,
+				RequestHash: req.Header.Hash(),
+				NodeID:      node,
+				Timestamp:   time.Now().UTC(),
+				RowCount:    uint64(len(rows)),
+			},
+		},
+		Payload: types.ResponsePayload{
+			Columns:   processListColumns,
+			DeclTypes: processListDeclTypes,
+			Rows:      rows,
+		},
+	}
+}
+
+// kill interrupts the running queries of the connection id for the KILL request req, the count of
+// the interrupted queries is returned as the affected rows. A write already committed by the peers
+// is not rolled back.
+func (db *Database) kill(req *types.Request, id uint64) (resp *types.Response, err error) {
+	if !db.chain.IsLeader() {
+		err = errors.Wrap(ErrNotLeader, "connections are tracked on the leader")
+		return
+	}
+	if req.Header.ConnectionID == id {
+		err = errors.Wrap(ErrInvalidRequest, "cannot kill the connection itself")
+		return
+	}
+	var count int
+	if count, err = db.procs.kill(id); err != nil {
+		return
+	}
+	resp = &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:      req.Header.RequestHeader,
+				RequestHash:  req.Header.Hash(),
+				NodeID:       db.nodeID,
+				Timestamp:    time.Now().UTC(),
+				AffectedRows: int64(count),
+			},
+		},
+	}
+	return
+}
+
+// isShowProcessList returns whether req is a SHOW PROCESSLIST request.
+func isShowProcessList(req *types.Request) bool {
+	if len(req.Payload.Queries) != 1 {
+		return false
+	}
+	var q = strings.TrimRight(strings.TrimSpace(req.Payload.Queries[0].Pattern), "; \t\r\n")
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ") == showProcessList
+}
+
+// parseKillQuery returns the connection id of the KILL <id> request req.
+func parseKillQuery(req *types.Request) (id uint64, ok bool) {
+	if len(req.Payload.Queries) != 1 {
+		return
+	}
+	var (
+		q      = strings.TrimRight(strings.TrimSpace(req.Payload.Queries[0].Pattern), "; \t\r\n")
+		fields = strings.Fields(q)
+		err    error
+	)
+	if len(fields) != 2 || strings.ToLower(fields[0]) != killStatement {
+		return
+	}
+	if id, err = strconv.ParseUint(fields[1], 10, 64); err != nil {
+		return
+	}
+	ok = true
+	return
+}
@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func newProcessRequest(node proto.NodeID, conn uint64, seq uint64, queries ...string) *types.Request {
+	var req = newStatsRequest(queries...)
+	req.Header.NodeID = node
+	req.Header.ConnectionID = conn
+	req.Header.SeqNo = seq
+	req.Header.QueryType = types.ReadQuery
+	return req
+}
+
+func TestProcessList(t *testing.T) {
+	Convey("Given the process list of a database", t, func() {
+		var (
+			procs = newProcessList(2)
+			now   = time.Now()
+			q1    = procs.begin(newProcessRequest("node1", 1, 1, "SELECT 1"), now)
+			q2    = procs.begin(newProcessRequest("node2", 2, 7, "SELECT * FROM t WHERE a = 'x'"), now.Add(time.Second))
+		)
+		So(procs.end(q1, now.Add(2*time.Second)), ShouldBeFalse)
+
+		Convey("The running queries should be listed before the idle connections", func() {
+			var rows = procs.rows(now.Add(3 * time.Second))
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0].Values[:4], ShouldResemble, []interface{}{"2", "node2", "", "Read"})
+			So(rows[0].Values[4], ShouldEqual, 2000.0)
+			So(rows[0].Values[5:], ShouldResemble, []interface{}{
+				int64(7), int64(1), "select * from t where a = ?"})
+			So(rows[1].Values[:4], ShouldResemble, []interface{}{"1", "node1", "", "Sleep"})
+			So(rows[1].Values[4], ShouldEqual, 1000.0)
+			So(rows[1].Values[7], ShouldEqual, "")
+		})
+		Convey("The connections idle too long should be removed", func() {
+			So(procs.rows(now.Add(ConnectionIdleTimeout+3*time.Second)), ShouldHaveLength, 1)
+			So(procs.conns, ShouldHaveLength, 1)
+		})
+		Convey("The idle connection should be evicted for a new one", func() {
+			procs.begin(newProcessRequest("node3", 3, 1, "SELECT 3"), now.Add(3*time.Second))
+			So(procs.conns, ShouldHaveLength, 2)
+			So(procs.conns, ShouldNotContainKey, connKey{node: "node1", id: 1})
+		})
+		Convey("The killed query should be interrupted", func() {
+			var ctx = q2.bind(q2.req.GetContext())
+			count, err := procs.kill(2)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 1)
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+			So(ctx.Err(), ShouldNotBeNil)
+			So(procs.rows(now.Add(3 * time.Second))[0].Values[3], ShouldEqual, "Killed")
+			So(procs.end(q2, now.Add(3*time.Second)), ShouldBeTrue)
+
+			count, err = procs.kill(1)
+			So(err, ShouldBeNil)
+			So(count, ShouldEqual, 0)
+			_, err = procs.kill(3)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+func TestParseProcessStatements(t *testing.T) {
+	Convey("The process list statements should be recognized", t, func() {
+		So(isShowProcessList(newStatsRequest("show  PROCESSLIST;")), ShouldBeTrue)
+		So(isShowProcessList(newStatsRequest("SHOW TABLES")), ShouldBeFalse)
+		id, ok := parseKillQuery(newStatsRequest("KILL 18446744073709551615;"))
+		So(ok, ShouldBeTrue)
+		So(id, ShouldEqual, uint64(18446744073709551615))
+		_, ok = parseKillQuery(newStatsRequest("KILL QUERY 1"))
+		So(ok, ShouldBeFalse)
+		_, ok = parseKillQuery(newStatsRequest("kill 1", "kill 2"))
+		So(ok, ShouldBeFalse)
+	})
+}
@@ -0,0 +1,122 @@
+package worker
+
+import (
+	"expvar"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sqlit/src/proto"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// QuotaCheckInterval defines the minimum interval between two disk usage checks of a database.
+	QuotaCheckInterval = time.Second
+
+	mwMinerDBQuotaUsage = "service:miner:db:quota:usage"
+)
+
+var (
+	// DefaultQuotaWarningThresholds defines the default quota usage ratios to emit warnings.
+	DefaultQuotaWarningThresholds = []float64{0.8, 0.9, 0.95}
+
+	quotaUsageVars = expvar.NewMap(mwMinerDBQuotaUsage)
+)
+
+// quotaTracker tracks the disk usage of a database against its storage quota.
+type quotaTracker struct {
+	sync.Mutex
+	dbID       proto.DatabaseID
+	files      []string
+	limit      uint64
+	thresholds []float64
+
+	used      uint64
+	lastCheck time.Time
+	level     int // count of thresholds crossed
+	usageVar  *expvar.Float
+}
+
+func newQuotaTracker(
+	dbID proto.DatabaseID, dataDir string, limit uint64, thresholds []float64) (q *quotaTracker,
+) {
+	if thresholds == nil {
+		thresholds = DefaultQuotaWarningThresholds
+	}
+	thresholds = append([]float64(nil), thresholds...)
+	sort.Float64s(thresholds)
+	storageFile := filepath.Join(dataDir, StorageFileName)
+	q = &quotaTracker{
+		dbID:       dbID,
+		files:      []string{storageFile, storageFile + "-wal"},
+		limit:      limit,
+		thresholds: thresholds,
+		usageVar:   new(expvar.Float),
+	}
+	quotaUsageVars.Set(string(dbID), q.usageVar)
+	return
+}
+
+// usage returns the current disk usage of the database in bytes.
+func (q *quotaTracker) usage() uint64 {
+	q.Lock()
+	defer q.Unlock()
+	q.refresh(time.Now())
+	return q.used
+}
+
+// check returns ErrSpaceLimitExceeded if the database has exceeded its quota.
+func (q *quotaTracker) check() (err error) {
+	if q.limit == 0 {
+		return
+	}
+	q.Lock()
+	defer q.Unlock()
+	if now := time.Now(); now.Sub(q.lastCheck) >= QuotaCheckInterval {
+		q.refresh(now)
+	}
+	if q.used > q.limit {
+		err = ErrSpaceLimitExceeded
+	}
+	return
+}
+
+func (q *quotaTracker) refresh(now time.Time) {
+	var used uint64
+	for _, f := range q.files {
+		if fi, err := os.Stat(f); err == nil {
+			used += uint64(fi.Size())
+		}
+	}
+	q.used = used
+	q.lastCheck = now
+	if q.limit == 0 {
+		return
+	}
+
+	ratio := float64(used) / float64(q.limit)
+	q.usageVar.Set(ratio)
+	level := 0
+	for _, t := range q.thresholds {
+		if ratio >= t {
+			level++
+		}
+	}
+	if level > q.level {
+		log.WithFields(log.Fields{
+			"db":        q.dbID,
+			"used":      used,
+			"limit":     q.limit,
+			"ratio":     ratio,
+			"threshold": q.thresholds[level-1],
+		}).Warning("database storage quota usage crossed warning threshold")
+	}
+	q.level = level
+}
+
+func (q *quotaTracker) close() {
+	quotaUsageVars.Delete(string(q.dbID))
+}
@@ -0,0 +1,46 @@
+package worker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/client"
+)
+
+func TestQuotaTracker(t *testing.T) {
+	Convey("Given a database data dir", t, func() {
+		dir, err := os.MkdirTemp("", "quota")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(dir) })
+		storageFile := filepath.Join(dir, StorageFileName)
+
+		Convey("Quota should not be enforced without limit", func() {
+			q := newQuotaTracker("db-no-limit", dir, 0, nil)
+			defer q.close()
+			So(os.WriteFile(storageFile, make([]byte, 1024), 0644), ShouldBeNil)
+			So(q.check(), ShouldBeNil)
+			So(q.usage(), ShouldEqual, 1024)
+		})
+		Convey("Writes should be rejected once the quota is exceeded", func() {
+			q := newQuotaTracker("db-limit", dir, 1000, []float64{0.5, 0.9})
+			defer q.close()
+			So(q.check(), ShouldBeNil)
+			So(q.level, ShouldEqual, 0)
+
+			So(os.WriteFile(storageFile, make([]byte, 600), 0644), ShouldBeNil)
+			So(q.usage(), ShouldEqual, 600)
+			So(q.level, ShouldEqual, 1)
+			So(q.check(), ShouldBeNil)
+
+			So(os.WriteFile(storageFile+"-wal", make([]byte, 500), 0644), ShouldBeNil)
+			So(q.usage(), ShouldEqual, 1100)
+			So(q.level, ShouldEqual, 2)
+			err := q.check()
+			So(err, ShouldEqual, ErrSpaceLimitExceeded)
+			So(client.IsQuotaExceeded(err), ShouldBeTrue)
+		})
+	})
+}
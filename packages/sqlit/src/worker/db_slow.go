@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"sqlit/src/diag"
+	"sqlit/src/proto"
+)
+
+const (
+	// SlowQueryHistorySize defines the count of the recent slow queries kept for the diagnostic
+	// bundles.
+	SlowQueryHistorySize = 256
+
+	diagSlowQueries = "slow_queries.json"
+)
+
+// SlowQuery is a record of a slow query.
+type SlowQuery struct {
+	DatabaseID proto.DatabaseID
+	NodeID     proto.NodeID
+	Trace      string
+	Type       string
+	Count      uint64
+	Sample     string
+	Start      time.Time
+	Elapsed    time.Duration
+	// Finished is false if the query was still running when it's recorded.
+	Finished bool
+}
+
+// slowQueryHistory keeps the recent slow queries of all the databases in a ring.
+type slowQueryHistory struct {
+	sync.Mutex
+	ring []SlowQuery
+	next int
+}
+
+var slowQueries = &slowQueryHistory{ring: make([]SlowQuery, 0, SlowQueryHistorySize)}
+
+func init() {
+	diag.Register(diagSlowQueries, func(w io.Writer) error {
+		return diag.WriteJSON(w, slowQueries.list())
+	})
+}
+
+// record adds q to the history, the oldest one is dropped if the history is full.
+func (h *slowQueryHistory) record(q SlowQuery) {
+	h.Lock()
+	defer h.Unlock()
+	if len(h.ring) < cap(h.ring) {
+		h.ring = append(h.ring, q)
+		return
+	}
+	h.ring[h.next] = q
+	h.next = (h.next + 1) % len(h.ring)
+}
+
+// list returns the recorded slow queries, the oldest first.
+func (h *slowQueryHistory) list() (queries []SlowQuery) {
+	h.Lock()
+	defer h.Unlock()
+	queries = make([]SlowQuery, 0, len(h.ring))
+	queries = append(queries, h.ring[h.next:]...)
+	queries = append(queries, h.ring[:h.next]...)
+	return
+}
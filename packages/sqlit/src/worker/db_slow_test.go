@@ -0,0 +1,25 @@
+package worker
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSlowQueryHistory(t *testing.T) {
+	Convey("Given a slow query history", t, func() {
+		var h = &slowQueryHistory{ring: make([]SlowQuery, 0, 3)}
+		So(h.list(), ShouldBeEmpty)
+		for i := 0; i < 2; i++ {
+			h.record(SlowQuery{Count: uint64(i)})
+		}
+		So(h.list(), ShouldResemble, []SlowQuery{{Count: 0}, {Count: 1}})
+
+		Convey("The oldest queries should be dropped once it's full", func() {
+			for i := 2; i < 5; i++ {
+				h.record(SlowQuery{Count: uint64(i)})
+			}
+			So(h.list(), ShouldResemble, []SlowQuery{{Count: 2}, {Count: 3}, {Count: 4}})
+		})
+	})
+}
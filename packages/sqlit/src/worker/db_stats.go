@@ -0,0 +1,296 @@
+package worker
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+const (
+	// MaxQueryStatsEntries defines the max count of query fingerprints tracked by a database, the
+	// least recently seen one is evicted for a new fingerprint.
+	MaxQueryStatsEntries = 1000
+	// QueryStatsLatencySamples defines the count of the recent latencies kept for the percentiles.
+	QueryStatsLatencySamples = 128
+
+	showQueryStats = "show query stats"
+	operatorBytes  = "<>=!|+-*/%&~^"
+)
+
+var (
+	queryStatsColumns   = []string{"fingerprint", "count", "total_ms", "mean_ms", "p95_ms", "rows"}
+	queryStatsDeclTypes = []string{"TEXT", "INTEGER", "REAL", "REAL", "REAL", "INTEGER"}
+
+	// placeholderListRegexp matches the normalized value lists like (?) and (?, ?, ?).
+	placeholderListRegexp = regexp.MustCompile(`\(\?(?:, \?)*\)`)
+)
+
+// queryStat is the aggregated statistics of a query fingerprint.
+type queryStat struct {
+	count    uint64
+	total    time.Duration
+	rows     uint64
+	samples  []time.Duration
+	next     int
+	lastSeen time.Time
+}
+
+func (s *queryStat) p95() time.Duration {
+	if len(s.samples) == 0 {
+		return 0
+	}
+	sorted := make([]time.Duration, len(s.samples))
+	copy(sorted, s.samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*95+99)/100-1]
+}
+
+// queryStats aggregates the statistics of the queries served by a database keyed by their
+// normalized fingerprints, queried by SHOW QUERY STATS for the performance triage.
+type queryStats struct {
+	sync.Mutex
+	max   int
+	stats map[string]*queryStat
+}
+
+func newQueryStats(max int) *queryStats {
+	return &queryStats{
+		max:   max,
+		stats: make(map[string]*queryStat),
+	}
+}
+
+// record adds a query request served in latency with rows returned or affected.
+func (q *queryStats) record(req *types.Request, latency time.Duration, rows uint64, now time.Time) {
+	var fps = make([]string, len(req.Payload.Queries))
+	for i, v := range req.Payload.Queries {
+		fps[i] = fingerprint(v.Pattern)
+	}
+	var fp = strings.Join(fps, "; ")
+
+	q.Lock()
+	defer q.Unlock()
+	s, ok := q.stats[fp]
+	if !ok {
+		if len(q.stats) >= q.max {
+			q.evict()
+		}
+		s = &queryStat{samples: make([]time.Duration, 0, QueryStatsLatencySamples)}
+		q.stats[fp] = s
+	}
+	s.count++
+	s.total += latency
+	s.rows += rows
+	s.lastSeen = now
+	if len(s.samples) < QueryStatsLatencySamples {
+		s.samples = append(s.samples, latency)
+	} else {
+		s.samples[s.next] = latency
+		s.next = (s.next + 1) % QueryStatsLatencySamples
+	}
+}
+
+// evict removes the least recently seen fingerprint, the caller must hold the lock.
+func (q *queryStats) evict() {
+	var (
+		oldest string
+		seen   time.Time
+	)
+	for fp, s := range q.stats {
+		if oldest == "" || s.lastSeen.Before(seen) {
+			oldest, seen = fp, s.lastSeen
+		}
+	}
+	delete(q.stats, oldest)
+}
+
+// rows returns the statistics rows ordered by the total latency descending.
+func (q *queryStats) rows() (rows []types.ResponseRow) {
+	q.Lock()
+	defer q.Unlock()
+	var fps = make([]string, 0, len(q.stats))
+	for fp := range q.stats {
+		fps = append(fps, fp)
+	}
+	sort.Slice(fps, func(i, j int) bool {
+		if ti, tj := q.stats[fps[i]].total, q.stats[fps[j]].total; ti != tj {
+			return ti > tj
+		}
+		return fps[i] < fps[j]
+	})
+	rows = make([]types.ResponseRow, len(fps))
+	for i, fp := range fps {
+		s := q.stats[fp]
+		rows[i].Values = []interface{}{
+			fp,
+			int64(s.count),
+			durationMillis(s.total),
+			durationMillis(s.total / time.Duration(s.count)),
+			durationMillis(s.p95()),
+			int64(s.rows),
+		}
+	}
+	return
+}
+
+// response builds the response of the SHOW QUERY STATS request req.
+func (q *queryStats) response(node proto.NodeID, req *types.Request) *types.Response {
+	var rows = q.rows()
+	return &types.Response{
+		Header: types.SignedResponseHeader{
+			ResponseHeader: types.ResponseHeader{
+				Request:     req.Header.RequestHeader,
+				RequestHash: req.Header.Hash(),
+				NodeID:      node,
+				Timestamp:   time.Now().UTC(),
+				RowCount:    uint64(len(rows)),
+			},
+		},
+		Payload: types.ResponsePayload{
+			Columns:   queryStatsColumns,
+			DeclTypes: queryStatsDeclTypes,
+			Rows:      rows,
+		},
+	}
+}
+
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// isShowQueryStats returns whether req is a SHOW QUERY STATS request.
+func isShowQueryStats(req *types.Request) bool {
+	if len(req.Payload.Queries) != 1 {
+		return false
+	}
+	var q = strings.TrimRight(strings.TrimSpace(req.Payload.Queries[0].Pattern), "; \t\r\n")
+	return strings.Join(strings.Fields(strings.ToLower(q)), " ") == showQueryStats
+}
+
+// fingerprint returns the normalized form of the sql query, the literals and placeholders are
+// replaced with ?, the comments are removed, the whitespaces are collapsed and the text out of
+// quoted identifiers is lower-cased.
+func fingerprint(query string) string {
+	var (
+		b     strings.Builder
+		space bool
+	)
+	write := func(s string) {
+		if space && b.Len() > 0 && s != ")" {
+			// no space within the parentheses or between a name and its parentheses
+			if last := b.String()[b.Len()-1]; last != '(' && !(s == "(" && isIdentByte(last)) {
+				b.WriteByte(' ')
+			}
+		}
+		space = false
+		b.WriteString(s)
+	}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			space = true
+			i++
+		case c == '-' && i+1 < len(query) && query[i+1] == '-':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+			space = true
+		case c == '/' && i+1 < len(query) && query[i+1] == '*':
+			if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = len(query)
+			}
+			space = true
+		case c == '\'':
+			i = skipQuoted(query, i, '\'')
+			write("?")
+		case (c == 'x' || c == 'X') && i+1 < len(query) && query[i+1] == '\'' && !isIdentByte(prevByte(query, i)):
+			i = skipQuoted(query, i+1, '\'')
+			write("?")
+		case c == '"' || c == '`':
+			end := skipQuoted(query, i, c)
+			write(query[i:end])
+			i = end
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				end = len(query) - i - 1
+			}
+			write(query[i : i+end+1])
+			i += end + 1
+		case c >= '0' && c <= '9' && !isIdentByte(prevByte(query, i)),
+			c == '.' && i+1 < len(query) && query[i+1] >= '0' && query[i+1] <= '9' && !isIdentByte(prevByte(query, i)):
+			for i < len(query) && (isIdentByte(query[i]) || query[i] == '.' ||
+				((query[i] == '+' || query[i] == '-') && (query[i-1] == 'e' || query[i-1] == 'E'))) {
+				i++
+			}
+			write("?")
+		case c == '?' || ((c == '$' || c == ':' || c == '@') && i+1 < len(query) && isIdentByte(query[i+1])):
+			i++
+			for i < len(query) && isIdentByte(query[i]) {
+				i++
+			}
+			write("?")
+		case isIdentByte(c):
+			start := i
+			for i < len(query) && isIdentByte(query[i]) {
+				i++
+			}
+			write(strings.ToLower(query[start:i]))
+		default:
+			// the commas are always followed by a space, to make the value lists uniform
+			if c == ',' {
+				b.WriteByte(',')
+				space = true
+			} else if c == ';' {
+				space = true
+			} else if strings.IndexByte(operatorBytes, c) >= 0 {
+				// the operators are always surrounded by spaces
+				start := i
+				for i+1 < len(query) && strings.IndexByte(operatorBytes, query[i+1]) >= 0 {
+					i++
+				}
+				space = true
+				write(query[start : i+1])
+				space = true
+			} else {
+				write(string(c))
+			}
+			i++
+		}
+	}
+	return placeholderListRegexp.ReplaceAllString(strings.TrimSpace(b.String()), "(...)")
+}
+
+// skipQuoted returns the index next to the quoted text starting at i, the doubled quotes are
+// escapes.
+func skipQuoted(s string, i int, quote byte) int {
+	for i++; i < len(s); i++ {
+		if s[i] == quote {
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+func prevByte(s string, i int) byte {
+	if i == 0 {
+		return 0
+	}
+	return s[i-1]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
@@ -0,0 +1,94 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
+)
+
+func newStatsRequest(queries ...string) *types.Request {
+	var req = &types.Request{}
+	for _, q := range queries {
+		req.Payload.Queries = append(req.Payload.Queries, types.Query{Pattern: q})
+	}
+	return req
+}
+
+func TestFingerprint(t *testing.T) {
+	Convey("Queries differing in literals should share the fingerprint", t, func() {
+		for _, c := range []struct {
+			queries []string
+			expect  string
+		}{
+			{
+				queries: []string{
+					"SELECT * FROM t WHERE a = 1 AND b = 'x'",
+					"select *  from t\n where a=2.5e-3 and b='it''s' -- comment",
+					"SELECT * FROM t WHERE a = ? AND /* c */ b = :name;",
+				},
+				expect: "select * from t where a = ? and b = ?",
+			},
+			{
+				queries: []string{
+					"INSERT INTO t (a, b) VALUES (1, 'a')",
+					"insert into t(a,b) values(x'00ff',$1)",
+				},
+				expect: "insert into t(a, b) values(...)",
+			},
+			{
+				queries: []string{
+					`SELECT "Col1", count( * ) FROM [T1] WHERE id IN (1, 2, 3) AND a >= 10`,
+					`select "Col1", COUNT(*) from [T1] where ID in (4) and A>=1`,
+				},
+				expect: `select "Col1", count(*) from [T1] where id in(...) and a >= ?`,
+			},
+		} {
+			for _, q := range c.queries {
+				So(fingerprint(q), ShouldEqual, c.expect)
+			}
+		}
+	})
+}
+
+func TestQueryStats(t *testing.T) {
+	Convey("Given the query stats of a database", t, func() {
+		var (
+			stats = newQueryStats(2)
+			now   = time.Now()
+		)
+		So(isShowQueryStats(newStatsRequest("show  QUERY stats;")), ShouldBeTrue)
+		So(isShowQueryStats(newStatsRequest("SHOW TABLES")), ShouldBeFalse)
+		So(isShowQueryStats(newStatsRequest("SHOW QUERY STATS", "SHOW QUERY STATS")), ShouldBeFalse)
+
+		for i := 1; i <= 100; i++ {
+			stats.record(newStatsRequest("SELECT * FROM t WHERE id = 1"),
+				time.Duration(i)*time.Millisecond, 1, now)
+		}
+		stats.record(newStatsRequest("INSERT INTO t VALUES (1)", "INSERT INTO t VALUES (2)"),
+			time.Second, 2, now.Add(time.Second))
+
+		Convey("The aggregated rows should be ordered by the total latency", func() {
+			rows := stats.rows()
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0].Values, ShouldResemble, []interface{}{
+				"select * from t where id = ?", int64(100), 5050.0, 50.5, 95.0, int64(100),
+			})
+			So(rows[1].Values, ShouldResemble, []interface{}{
+				"insert into t values(...); insert into t values(...)", int64(1), 1000.0, 1000.0, 1000.0, int64(2),
+			})
+			resp := stats.response("node", newStatsRequest("SHOW QUERY STATS"))
+			So(resp.Header.RowCount, ShouldEqual, 2)
+			So(resp.Payload.Columns, ShouldResemble, queryStatsColumns)
+		})
+		Convey("The least recently seen fingerprint should be evicted when full", func() {
+			stats.record(newStatsRequest("DELETE FROM t"), time.Millisecond, 0, now.Add(2*time.Second))
+			rows := stats.rows()
+			So(rows, ShouldHaveLength, 2)
+			So(rows[0].Values[0], ShouldEqual, "insert into t values(...); insert into t values(...)")
+			So(rows[1].Values[0], ShouldEqual, "delete from t")
+		})
+	})
+}
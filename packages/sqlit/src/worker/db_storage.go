@@ -65,12 +65,9 @@ func (db *Database) Check(rawReq interface{}) (err error) {
 	}
 
 	// verify timestamp
-	nowTime := getLocalTime()
-	minTime := nowTime.Add(-db.cfg.MaxWriteTimeGap)
-	maxTime := nowTime.Add(db.cfg.MaxWriteTimeGap)
-
-	if req.Header.Timestamp.Before(minTime) || req.Header.Timestamp.After(maxTime) {
-		err = errors.Wrap(ErrInvalidRequest, "invalid request time")
+	if err = db.checkRequestTime(
+		req.Header.NodeID, req.Header.Timestamp.Sub(getLocalTime()),
+	); err != nil {
 		return
 	}
 
@@ -105,11 +102,17 @@ func (db *Database) Commit(rawReq interface{}, isLeader bool) (result interface{
 		return
 	}
 
-	// reset context, commit should never be canceled
-	req.SetContext(context.Background())
+	// reset context, commit should never be canceled but by the max execution time of the request
+	//
+	// NOTE: the time limit is enforced on the leader and the followers separately, a write close
+	// to the limit may succeed on some of them only.
+	ctx, cancel := withMaxExecutionTime(context.Background(), req)
+	defer cancel()
+	req.SetContext(ctx)
 
 	// execute
 	if tracker, response, err = db.chain.Query(req, isLeader); err != nil {
+		err = executionError(ctx, req, err)
 		return
 	}
 	result = &TrackerAndResponse{
@@ -3,6 +3,8 @@ package worker
 
 import (
 	"context"
+	"fmt"
+	"math"
 	"os"
 	"reflect"
 	"strings"
@@ -10,11 +12,13 @@ import (
 	"time"
 
 	"github.com/fortytw2/leaktest"
+	"github.com/pkg/errors"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/crypto/kms"
+	xs "sqlit/src/dpos/sqlite"
 	"sqlit/src/proto"
 	rpc "sqlit/src/rpc/mux"
 	"sqlit/src/sqlchain"
@@ -212,6 +216,160 @@ func TestSingleDatabase(t *testing.T) {
 			So(err, ShouldBeNil)
 		})
 
+		Convey("test idempotent write", func() {
+			var (
+				writeQuery, retryQuery, readQuery *types.Request
+				res, retryRes                     *types.Response
+				privateKey                        *asymmetric.PrivateKey
+			)
+			privateKey, _, err = getKeys()
+			So(err, ShouldBeNil)
+			writeQuery, err = buildQuery(types.WriteQuery, 1, 1, []string{
+				"create table test (test int)",
+			})
+			So(err, ShouldBeNil)
+			_, err = db.Query(writeQuery)
+			So(err, ShouldBeNil)
+
+			// the retry is sent in another connection with the same idempotency key
+			writeQuery, err = buildQuery(types.WriteQuery, 1, 2, []string{
+				"insert into test values(1)",
+			})
+			So(err, ShouldBeNil)
+			So(writeQuery.Header.SetIdempotencyKey("insert-1"), ShouldBeNil)
+			So(writeQuery.Sign(privateKey), ShouldBeNil)
+			retryQuery, err = buildQuery(types.WriteQuery, 2, 1, []string{
+				"insert into test values(1)",
+			})
+			So(err, ShouldBeNil)
+			So(retryQuery.Header.SetIdempotencyKey("insert-1"), ShouldBeNil)
+			So(retryQuery.Sign(privateKey), ShouldBeNil)
+
+			res, err = db.Query(writeQuery)
+			So(err, ShouldBeNil)
+			retryRes, err = db.Query(retryQuery)
+			So(err, ShouldBeNil)
+			So(retryRes.Header.Hash(), ShouldResemble, res.Header.Hash())
+
+			readQuery, err = buildQuery(types.ReadQuery, 1, 3, []string{
+				"select * from test",
+			})
+			So(err, ShouldBeNil)
+			res, err = db.Query(readQuery)
+			So(err, ShouldBeNil)
+			So(res.Header.RowCount, ShouldEqual, uint64(1))
+
+			// tampered idempotent request should be rejected
+			So(retryQuery.Header.SetIdempotencyKey("insert-2"), ShouldBeNil)
+			_, err = db.Query(retryQuery)
+			So(err, ShouldNotBeNil)
+
+			err = db.Shutdown()
+			So(err, ShouldBeNil)
+		})
+
+		Convey("test as-of query", func() {
+			var (
+				writeQuery, readQuery *types.Request
+				res                   *types.Response
+				heights               []int32
+			)
+			// packedHeight returns the height of the block which packs the write query at offset
+			packedHeight := func(offset uint64) (int32, bool) {
+				_, _, head, err := db.chain.FetchBlockByCount(-1)
+				So(err, ShouldBeNil)
+				for h := int32(0); h <= head; h++ {
+					block, err := db.chain.FetchBlock(h)
+					So(err, ShouldBeNil)
+					if block == nil {
+						continue
+					}
+					if id, ok := block.CalcNextID(); ok && id > offset {
+						return h, true
+					}
+				}
+				return 0, false
+			}
+			for i := 1; i <= 2; i++ {
+				queries := []string{fmt.Sprintf("insert into test values(%d)", i)}
+				if i == 1 {
+					queries = append([]string{"create table test (test int)"}, queries...)
+				}
+				writeQuery, err = buildQuery(types.WriteQuery, 1, uint64(i), queries)
+				So(err, ShouldBeNil)
+				_, err = db.Query(writeQuery)
+				So(err, ShouldBeNil)
+
+				// wait until the write is packed into a block
+				var (
+					height int32
+					packed bool
+				)
+				for j := 0; j < 100 && !packed; j++ {
+					time.Sleep(100 * time.Millisecond)
+					height, packed = packedHeight(uint64(i))
+				}
+				So(packed, ShouldBeTrue)
+				heights = append(heights, height)
+			}
+			So(heights[1], ShouldBeGreaterThan, heights[0])
+
+			readQuery, err = buildQuery(types.ReadQuery, 1, 3, []string{
+				"select * from test",
+			})
+			So(err, ShouldBeNil)
+			for i, h := range heights {
+				res, err = db.queryAsOf(context.Background(), readQuery, h)
+				So(err, ShouldBeNil)
+				So(res.Header.RowCount, ShouldEqual, uint64(i+1))
+			}
+			res, err = db.queryAsOf(context.Background(), readQuery, heights[0])
+			So(err, ShouldBeNil)
+			So(res.Header.RowCount, ShouldEqual, uint64(1))
+			So(len(db.asOf.snapshots), ShouldEqual, 2)
+
+			_, err = db.queryAsOf(context.Background(), readQuery, math.MaxInt32)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidAsOfHeight)
+
+			err = db.Shutdown()
+			So(err, ShouldBeNil)
+			So(db.asOf.snapshots, ShouldBeEmpty)
+		})
+
+		Convey("test max execution time", func() {
+			privateKey, _, err := getKeys()
+			So(err, ShouldBeNil)
+			runaway := "with recursive c(x) as (select 1 union all select x+1 from c) select count(*) from c"
+			for i, qt := range []types.QueryType{types.ReadQuery, types.WriteQuery} {
+				q := runaway
+				if qt == types.WriteQuery {
+					q = "create table t as " + runaway
+				}
+				var req *types.Request
+				req, err = buildQuery(qt, 1, uint64(i+1), []string{q})
+				So(err, ShouldBeNil)
+				So(req.Header.SetMaxExecutionTime(100*time.Millisecond), ShouldBeNil)
+				So(req.Sign(privateKey), ShouldBeNil)
+				start := time.Now()
+				_, err = db.Query(req)
+				So(err, ShouldNotBeNil)
+				So(err.Error(), ShouldContainSubstring, types.ErrCodeExecutionTimeout)
+				So(time.Since(start), ShouldBeLessThan, 5*time.Second)
+			}
+
+			// the writer is available after the interruption
+			writeQuery, err := buildQuery(types.WriteQuery, 1, 3, []string{
+				"create table test (test int)",
+				"insert into test values(1)",
+			})
+			So(err, ShouldBeNil)
+			_, err = db.Query(writeQuery)
+			So(err, ShouldBeNil)
+
+			err = db.Shutdown()
+			So(err, ShouldBeNil)
+		})
+
 		Convey("test invalid request", func() {
 			var writeQuery *types.Request
 			var res *types.Response
@@ -504,6 +662,86 @@ func TestDatabaseRecycle(t *testing.T) {
 	})
 }
 
+func TestStandbyDatabase(t *testing.T) {
+	defer kms.ClosePublicKeyStore()
+
+	Convey("test standby database promotion", t, func() {
+		var err error
+		var server *rpc.Server
+		var cleanup func()
+		cleanup, server, err = initNode()
+		So(err, ShouldBeNil)
+
+		defer cleanup()
+
+		var rootDir string
+		rootDir, err = os.MkdirTemp("", "db_test_")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(rootDir)
+
+		bftraftMuxService, err := NewDBBftRaftMuxService("DBBftRaft", server)
+		So(err, ShouldBeNil)
+		chainMuxService, err := sqlchain.NewMuxService("sqlchain", server)
+		So(err, ShouldBeNil)
+
+		// the standby is not in the peers
+		privKey, _, err := getKeys()
+		So(err, ShouldBeNil)
+		var other = proto.NodeID("0000000000000000000000000000000000000000000000000000000000000001")
+		var standbyPeers = &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Term:    1,
+				Leader:  other,
+				Servers: []proto.NodeID{other},
+			},
+		}
+		err = standbyPeers.Sign(privKey)
+		So(err, ShouldBeNil)
+
+		cfg := &DBConfig{
+			DatabaseID:       "00000bef611d346c0cbe1beaa76e7f0ed705a194fdf9ac3a248ec70e9c198bf9",
+			RootDir:          rootDir,
+			DataDir:          rootDir,
+			BftRaftMux:       bftraftMuxService,
+			ChainMux:         chainMuxService,
+			MaxWriteTimeGap:  time.Duration(5 * time.Second),
+			UpdateBlockCount: 2,
+			Standby:          true,
+		}
+		// start before the genesis to skip syncing with the unreachable peer
+		var block *types.Block
+		block, err = types.CreateRandomBlock(rootHash, true)
+		So(err, ShouldBeNil)
+		block.SignedHeader.Timestamp = time.Now().Add(time.Hour).UTC()
+		err = block.PackAsGenesis()
+		So(err, ShouldBeNil)
+
+		var db *Database
+		db, err = NewDatabase(cfg, standbyPeers, block)
+		So(err, ShouldBeNil)
+		defer db.Shutdown()
+		So(db.isStandby(), ShouldBeTrue)
+
+		var writeQuery *types.Request
+		writeQuery, err = buildQuery(types.WriteQuery, 1, 1, []string{
+			"create table test (test int)",
+		})
+		So(err, ShouldBeNil)
+		_, err = db.Query(writeQuery)
+		So(err, ShouldEqual, ErrStandbyMiner)
+
+		// promoted in place of the other miner
+		var peers *proto.Peers
+		peers, err = getPeers(2)
+		So(err, ShouldBeNil)
+		err = db.UpdatePeers(peers)
+		So(err, ShouldBeNil)
+		So(db.isStandby(), ShouldBeFalse)
+		So(db.bftraftRuntime.Peers().Leader, ShouldEqual, peers.Leader)
+		So(db.chain.IsLeader(), ShouldBeTrue)
+	})
+}
+
 func TestDatabase_EncodePayload(t *testing.T) {
 	Convey("encode payload cache", t, func() {
 		db := &Database{}
@@ -677,3 +915,30 @@ func getKeys() (privKey *asymmetric.PrivateKey, pubKey *asymmetric.PublicKey, er
 
 	return
 }
+
+func TestPoolOptions(t *testing.T) {
+	Convey("Customized pool settings should override the storage defaults", t, func() {
+		So(poolOptions(types.PoolMeta{}), ShouldResemble, xs.DefaultPoolOptions)
+		opts := poolOptions(types.PoolMeta{MaxReaders: 4, BusyTimeout: 200})
+		So(opts.MaxReaders, ShouldEqual, 4)
+		So(opts.BusyTimeout, ShouldEqual, 200*time.Millisecond)
+		So(opts.CacheSize, ShouldEqual, xs.DefaultPoolOptions.CacheSize)
+		So(opts.MMapSize, ShouldEqual, xs.DefaultPoolOptions.MMapSize)
+	})
+}
+
+func TestStorageDurability(t *testing.T) {
+	Convey("The durability level should be set as the storage synchronous setting", t, func() {
+		for level, mode := range map[types.DurabilityLevel]string{
+			types.NormalDurability:  "NORMAL",
+			types.StrictDurability:  "FULL",
+			types.RelaxedDurability: "OFF",
+		} {
+			dsn, err := newStorageDSN(&DBConfig{Durability: level}, "file:storage.db3")
+			So(err, ShouldBeNil)
+			value, ok := dsn.GetParam(xs.SynchronousParam)
+			So(ok, ShouldBeTrue)
+			So(value, ShouldEqual, mode)
+		}
+	})
+}
@@ -0,0 +1,333 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	x "sqlit/src/dpos"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultTTLCheckInterval defines the default interval of deleting the expired rows of the
+	// tables with a row ttl.
+	DefaultTTLCheckInterval = time.Minute
+	// TTLDeleteTimeout defines the max time of a round of the expired rows deletion.
+	TTLDeleteTimeout = time.Minute
+
+	// ttlTimeLayout is the layout of the text ttl columns, which is the one of the sqlite date and
+	// time functions, the text values are compared as strings.
+	ttlTimeLayout = "2006-01-02 15:04:05"
+
+	mwMinerDBTTL = "service:miner:db:ttl"
+)
+
+// ttlVars exports the counts of the expired rows deletion, keyed by database id.
+var ttlVars = expvar.NewMap(mwMinerDBTTL)
+
+// ttlJob deletes the expired rows of the tables with a row ttl periodically on the leader, the
+// deletions are sent as the write queries signed by the leader, so they are replicated and logged
+// in the blocks like the queries of the clients.
+type ttlJob struct {
+	interval time.Duration
+	connID   uint64
+	stopCh   chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+
+	runs    *expvar.Int
+	deleted *expvar.Int
+	failed  *expvar.Int
+	vars    *expvar.Map
+}
+
+func newTTLJob(interval time.Duration) *ttlJob {
+	if interval < 0 {
+		return nil
+	}
+	if interval == 0 {
+		interval = DefaultTTLCheckInterval
+	}
+	j := &ttlJob{
+		interval: interval,
+		// the sequences of the deletions are verified by the peers like the ones of a client
+		connID:  rand.Uint64(),
+		stopCh:  make(chan struct{}),
+		runs:    new(expvar.Int),
+		deleted: new(expvar.Int),
+		failed:  new(expvar.Int),
+		vars:    new(expvar.Map).Init(),
+	}
+	j.vars.Set("runs", j.runs)
+	j.vars.Set("deleted_rows", j.deleted)
+	j.vars.Set("failed", j.failed)
+	return j
+}
+
+func (j *ttlJob) start(db *Database) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-j.stopCh:
+				return
+			case <-ticker.C:
+			}
+			if !db.chain.IsLeader() {
+				continue
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), TTLDeleteTimeout)
+			n, err := db.expireRows(ctx, getLocalTime())
+			cancel()
+			if err != nil {
+				j.failed.Add(1)
+				log.WithField("db", db.dbID).WithError(err).Warning("delete expired rows failed")
+				continue
+			}
+			j.runs.Add(1)
+			j.deleted.Add(n)
+		}
+	}()
+}
+
+func (j *ttlJob) stop() {
+	j.once.Do(func() { close(j.stopCh) })
+	j.wg.Wait()
+}
+
+// isTextAffinity returns whether the declared column type has the sqlite text affinity, by which
+// the numbers compared with the column are converted to texts.
+func isTextAffinity(declType string) bool {
+	var t = strings.ToUpper(declType)
+	if strings.Contains(t, "INT") {
+		return false
+	}
+	return strings.Contains(t, "CHAR") || strings.Contains(t, "CLOB") || strings.Contains(t, "TEXT")
+}
+
+// expireQuery returns the query deleting the rows of p expired at now. The numeric values of the
+// ttl column are unix timestamps in seconds, and the text values are in the layout of the sqlite
+// date and time functions in UTC. The cutoff is a literal, so the deletion replays the same.
+func expireQuery(p *x.TTLPolicy, declType string, now time.Time) string {
+	var (
+		cutoff  = now.Add(-p.TTL).UTC()
+		table   = x.QuoteIdent(p.Table)
+		column  = x.QuoteIdent(p.Column)
+		numeric = strconv.FormatInt(cutoff.Unix(), 10)
+		text    = x.QuoteString(cutoff.Format(ttlTimeLayout))
+	)
+	if isTextAffinity(declType) {
+		return fmt.Sprintf(`DELETE FROM %s WHERE %s < %s`, table, column, text)
+	}
+	// the values kept as texts in the other columns, such as DATETIME, are compared as texts,
+	// the numbers are always less than the texts
+	return fmt.Sprintf(`DELETE FROM %s WHERE %s < %s OR (%s >= '' AND %s < %s)`,
+		table, column, numeric, column, column, text)
+}
+
+// readLocal runs the read query on the committed local state without tracking.
+func (db *Database) readLocal(ctx context.Context, pattern string) (rows []types.ResponseRow, err error) {
+	var req = &types.Request{
+		Header: types.SignedRequestHeader{
+			RequestHeader: types.RequestHeader{
+				QueryType:  types.ReadQuery,
+				NodeID:     db.nodeID,
+				DatabaseID: db.dbID,
+				Timestamp:  getLocalTime(),
+			},
+		},
+		Payload: types.RequestPayload{Queries: []types.Query{{Pattern: pattern}}},
+	}
+	resp, _, err := db.chain.QueryReadOnly(ctx, req)
+	if err != nil {
+		return
+	}
+	return resp.Payload.Rows, nil
+}
+
+// ttlPolicies returns the row ttl policies of the tables with the declared types of the ttl
+// columns, the policies of the dropped tables are returned with the nil types.
+func (db *Database) ttlPolicies(ctx context.Context) (
+	policies []*x.TTLPolicy, declTypes []*string, err error,
+) {
+	rows, err := db.readLocal(ctx, `SELECT "table", "column", "ttl" FROM "`+x.TTLTableName+`"`)
+	if err != nil {
+		if strings.Contains(err.Error(), "no such table") {
+			// no table is declared with a row ttl yet
+			err = nil
+		}
+		return
+	}
+	for _, r := range rows {
+		if len(r.Values) != 3 {
+			continue
+		}
+		var p = &x.TTLPolicy{
+			Table:  valueString(r.Values[0]),
+			Column: valueString(r.Values[1]),
+			TTL:    time.Duration(valueInt(r.Values[2])) * time.Second,
+		}
+		cols, err := db.readLocal(ctx, "PRAGMA table_info("+x.QuoteIdent(p.Table)+")")
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "read columns of table %s failed", p.Table)
+		}
+		var declType *string
+		if len(cols) == 0 {
+			// the table is dropped
+			policies, declTypes = append(policies, p), append(declTypes, nil)
+			continue
+		}
+		for _, c := range cols {
+			// cid, name, type, notnull, dflt_value, pk
+			if len(c.Values) > 2 && strings.EqualFold(valueString(c.Values[1]), p.Column) {
+				var t = valueString(c.Values[2])
+				declType = &t
+			}
+		}
+		if declType == nil {
+			log.WithFields(log.Fields{
+				"db":     db.dbID,
+				"table":  p.Table,
+				"column": p.Column,
+			}).Warning("ttl column not found, skip expired rows deletion")
+			continue
+		}
+		policies, declTypes = append(policies, p), append(declTypes, declType)
+	}
+	return
+}
+
+// expireRows deletes the expired rows of the tables with a row ttl at now on the leader, and
+// returns the count of the deleted rows. The policies of the dropped tables are removed too.
+func (db *Database) expireRows(ctx context.Context, now time.Time) (deleted int64, err error) {
+	policies, declTypes, err := db.ttlPolicies(ctx)
+	if err != nil || len(policies) == 0 {
+		return
+	}
+	var queries = make([]types.Query, 0, len(policies))
+	for i, p := range policies {
+		if declTypes[i] == nil {
+			queries = append(queries, types.Query{Pattern: `DELETE FROM "` + x.TTLTableName +
+				`" WHERE "table" = ` + x.QuoteString(p.Table)})
+			continue
+		}
+		queries = append(queries, types.Query{Pattern: expireQuery(p, *declTypes[i], now)})
+	}
+
+	resp, err := db.leaderWrite(ctx, db.ttl.connID, "ttl", queries)
+	if err != nil {
+		return
+	}
+	return resp.Header.AffectedRows, nil
+}
+
+// leaderWrite sends the queries as a write request signed by the leader on connection connID, the
+// request is replicated and logged in the blocks like the ones of the clients, and acknowledged by
+// the leader as the request node.
+func (db *Database) leaderWrite(
+	ctx context.Context, connID uint64, trace string, queries []types.Query,
+) (resp *types.Response, err error) {
+	var req = &types.Request{
+		Header: types.SignedRequestHeader{
+			RequestHeader: types.RequestHeader{
+				QueryType:    types.WriteQuery,
+				NodeID:       db.nodeID,
+				DatabaseID:   db.dbID,
+				ConnectionID: connID,
+				SeqNo:        uint64(getLocalTime().UnixNano()),
+				Timestamp:    getLocalTime(),
+			},
+		},
+		Payload: types.RequestPayload{Queries: queries},
+	}
+	if err = req.Header.SetPriority(types.BatchPriority); err != nil {
+		return
+	}
+	if err = req.Header.SetTraceID(trace + "-" + strconv.FormatUint(req.Header.SeqNo, 16)); err != nil {
+		return
+	}
+	if err = req.Sign(db.privateKey); err != nil {
+		return
+	}
+	req.SetContext(ctx)
+
+	// the storage quota is checked by the callers if needed, e.g. the deletions release the space
+	var tracker *x.QueryTracker
+	if db.cfg.UseEventualConsistency {
+		tracker, resp, err = db.chain.Query(req, true)
+	} else {
+		tracker, resp, err = db.writeQuery(req)
+	}
+	if err != nil {
+		return
+	}
+	resp.Header.ResponseAccount = db.accountAddr
+	if err = resp.BuildHash(); err != nil {
+		return
+	}
+	if err = resp.Sign(db.privateKey); err != nil {
+		return
+	}
+	if err = db.chain.AddResponse(&resp.Header); err != nil {
+		return
+	}
+	tracker.UpdateResp(resp)
+
+	// acknowledge the response as the request node
+	var ack = &types.Ack{
+		Header: types.SignedAckHeader{
+			AckHeader: types.AckHeader{
+				Response:     resp.Header.ResponseHeader,
+				ResponseHash: resp.Header.Hash(),
+				NodeID:       db.nodeID,
+				Timestamp:    getLocalTime(),
+			},
+		},
+	}
+	if err = ack.Sign(db.privateKey); err != nil {
+		return
+	}
+	if err = db.saveAck(&ack.Header); err != nil {
+		return
+	}
+	return
+}
+
+// valueString returns the string of a text value read from the state.
+func valueString(v interface{}) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case []byte:
+		return string(s)
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(s)
+	}
+}
+
+// valueInt returns the integer of a numeric value read from the state.
+func valueInt(v interface{}) int64 {
+	switch i := v.(type) {
+	case int64:
+		return i
+	case float64:
+		return int64(i)
+	default:
+		n, _ := strconv.ParseInt(valueString(v), 10, 64)
+		return n
+	}
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	x "sqlit/src/dpos"
+)
+
+func TestExpireQuery(t *testing.T) {
+	Convey("Given the tables with a row ttl", t, func() {
+		db, err := sql.Open("sqlite3", ":memory:")
+		So(err, ShouldBeNil)
+		defer db.Close()
+		db.SetMaxOpenConns(1)
+
+		var (
+			now = time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+			p   = &x.TTLPolicy{Column: "at", TTL: 24 * time.Hour}
+			old = now.Add(-25 * time.Hour)
+			new = now.Add(-23 * time.Hour)
+		)
+		for _, s := range []string{
+			`CREATE TABLE i (id INT, at INTEGER)`,
+			`CREATE TABLE t (id INT, at DATETIME TEXT)`,
+			`CREATE TABLE b (id INT, at)`,
+			`CREATE TABLE d (id INT, at DATETIME)`,
+		} {
+			_, err = db.Exec(s)
+			So(err, ShouldBeNil)
+		}
+		for i, at := range []interface{}{old.Unix(), new.Unix(), nil} {
+			_, err = db.Exec(`INSERT INTO i VALUES (?, ?)`, i, at)
+			So(err, ShouldBeNil)
+		}
+		for i, at := range []interface{}{
+			old.Format(ttlTimeLayout), new.Format(ttlTimeLayout), nil,
+		} {
+			_, err = db.Exec(`INSERT INTO t VALUES (?, ?)`, i, at)
+			So(err, ShouldBeNil)
+		}
+		for i, at := range []interface{}{
+			old.Unix(), new.Unix(), old.Format(ttlTimeLayout), new.Format(ttlTimeLayout), []byte("x"),
+		} {
+			_, err = db.Exec(`INSERT INTO b VALUES (?, ?)`, i, at)
+			So(err, ShouldBeNil)
+			_, err = db.Exec(`INSERT INTO d VALUES (?, ?)`, i, at)
+			So(err, ShouldBeNil)
+		}
+
+		var expire = func(table, declType string) (ids []int64) {
+			p.Table = table
+			_, err := db.Exec(expireQuery(p, declType, now))
+			So(err, ShouldBeNil)
+			rows, err := db.Query(`SELECT id FROM "` + table + `" ORDER BY id`)
+			So(err, ShouldBeNil)
+			defer rows.Close()
+			for rows.Next() {
+				var id int64
+				So(rows.Scan(&id), ShouldBeNil)
+				ids = append(ids, id)
+			}
+			return
+		}
+
+		Convey("The expired unix timestamps should be deleted", func() {
+			So(isTextAffinity("INTEGER"), ShouldBeFalse)
+			So(expire("i", "INTEGER"), ShouldResemble, []int64{1, 2})
+		})
+		Convey("The expired text timestamps should be deleted", func() {
+			So(isTextAffinity("DATETIME TEXT"), ShouldBeTrue)
+			So(expire("t", "DATETIME TEXT"), ShouldResemble, []int64{1, 2})
+		})
+		Convey("The expired timestamps of both kinds should be deleted", func() {
+			So(isTextAffinity(""), ShouldBeFalse)
+			So(expire("b", ""), ShouldResemble, []int64{1, 3, 4})
+			So(isTextAffinity("DATETIME"), ShouldBeFalse)
+			So(expire("d", "DATETIME"), ShouldResemble, []int64{1, 3, 4})
+		})
+	})
+}
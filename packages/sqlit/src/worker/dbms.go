@@ -35,6 +35,13 @@ const (
 	// DefaultSlowQueryTime defines the default slow query log time
 	DefaultSlowQueryTime = time.Second * 5
 
+	// DefaultAckReconcileWindow defines the default age of the responses without acks reconciled
+	// by the leaders.
+	DefaultAckReconcileWindow = time.Minute * 5
+
+	// BackupTempDirName defines the temporary dir of backups to be uploaded to object store.
+	BackupTempDirName = "backup.tmp"
+
 	mwMinerDBCount = "service:miner:db:count"
 )
 
@@ -56,12 +63,48 @@ type DBMS struct {
 	busService *BusService
 	address    proto.AccountAddress
 	privKey    *asymmetric.PrivateKey
+
+	// shared state sync bandwidth limiters
+	syncReadLimiter  *utils.RateLimiter
+	syncWriteLimiter *utils.RateLimiter
+
+	// online backup
+	backups      *backupManager
+	backupCancel context.CancelFunc
+
+	// source snapshots served to clone databases
+	clones *cloneManager
+
+	// compressor of the read query results, nil if disabled
+	compressor *types.ResultCompressor
+
+	// background maintenance
+	maintenanceCancel context.CancelFunc
+
+	// database load reports
+	loadReportCancel context.CancelFunc
+
+	// standby snapshots of dropped databases
+	standby       *standbyManager
+	standbyCancel context.CancelFunc
+
+	// graceful shutdown
+	draining uint32
 }
 
 // NewDBMS returns new database management instance.
 func NewDBMS(cfg *DBMSConfig) (dbms *DBMS, err error) {
 	dbms = &DBMS{
-		cfg: cfg,
+		cfg:              cfg,
+		syncReadLimiter:  utils.NewRateLimiter(cfg.SyncReadBandwidth),
+		syncWriteLimiter: utils.NewRateLimiter(cfg.SyncWriteBandwidth),
+		backups:          newBackupManager(),
+		clones: newCloneManager(
+			filepath.Join(cfg.RootDir, CloneDirName), DefaultCloneSnapshotRetention),
+	}
+
+	if dbms.compressor, err = newResultCompressor(cfg.ResultCompression); err != nil {
+		return
 	}
 
 	// init bftraft rpc mux
@@ -141,6 +184,9 @@ func (dbms *DBMS) writeMeta() (err error) {
 	dbms.dbMap.Range(func(key, value interface{}) bool {
 		dbID := key.(proto.DatabaseID)
 		meta.DBS[dbID] = true
+		if db := value.(*Database); !db.isStandby() {
+			meta.Peers[dbID] = db.bftraftRuntime.Peers()
+		}
 		return true
 	})
 
@@ -181,8 +227,56 @@ func (dbms *DBMS) Init() (err error) {
 		err = errors.Wrap(err, "init chain bus failed")
 		return
 	}
+	if err = dbms.busService.Subscribe("/ReplaceMiner/", dbms.replaceMiner); err != nil {
+		err = errors.Wrap(err, "init chain bus failed")
+		return
+	}
+	if err = dbms.busService.Subscribe("/ExpandDatabase/", dbms.expandDatabase); err != nil {
+		err = errors.Wrap(err, "init chain bus failed")
+		return
+	}
 	dbms.busService.Start()
 
+	// remove the stale clone snapshots
+	if err = dbms.clones.reset(); err != nil {
+		err = errors.Wrap(err, "reset clone snapshots failed")
+		return
+	}
+
+	// start scheduled backups
+	if dbms.cfg.Backup != nil && dbms.cfg.Backup.Interval > 0 {
+		var ctx context.Context
+		ctx, dbms.backupCancel = context.WithCancel(context.Background())
+		go dbms.runBackupScheduler(ctx, dbms.cfg.Backup.Interval)
+	}
+
+	// load standby snapshots
+	if dbms.cfg.StandbyRetention > 0 {
+		dbms.standby = newStandbyManager(
+			filepath.Join(dbms.cfg.RootDir, StandbyDirName), dbms.cfg.StandbyRetention)
+		if err = dbms.standby.load(); err != nil {
+			err = errors.Wrap(err, "load standby snapshots failed")
+			return
+		}
+		var ctx context.Context
+		ctx, dbms.standbyCancel = context.WithCancel(context.Background())
+		go dbms.runStandbyExpiration(ctx)
+	}
+
+	// start background maintenance
+	if m := dbms.cfg.Maintenance; m != nil && (m.Interval > 0 || m.WALSizeTrigger > 0) {
+		var ctx context.Context
+		ctx, dbms.maintenanceCancel = context.WithCancel(context.Background())
+		go dbms.runMaintenanceScheduler(ctx, m)
+	}
+
+	// start reporting the database loads
+	if dbms.cfg.LoadReportInterval > 0 {
+		var ctx context.Context
+		ctx, dbms.loadReportCancel = context.WithCancel(context.Background())
+		go dbms.runLoadReporter(ctx, dbms.cfg.LoadReportInterval)
+	}
+
 	return
 }
 
@@ -250,7 +344,7 @@ func (dbms *DBMS) createDatabase(tx interfaces.Transaction, count uint32) {
 		}
 		nodeIDs[i] = mi.NodeID
 	}
-	if !isTargetMiner {
+	if !isTargetMiner && !containsMiner(p.Standby, dbms.address) {
 		return
 	}
 
@@ -303,6 +397,12 @@ func (dbms *DBMS) buildSQLChainServiceInstance(
 		ResourceMeta: profile.Meta,
 		GenesisBlock: genesis,
 	}
+	// prefer the key issued to this miner by IssueKeys transaction
+	for _, v := range profile.Miners {
+		if v.Address == dbms.address && v.EncryptionKey != "" {
+			instance.ResourceMeta.EncryptionKey = v.EncryptionKey
+		}
+	}
 	return
 }
 
@@ -363,6 +463,14 @@ func (dbms *DBMS) initDatabases(
 		if instance, err = dbms.buildSQLChainServiceInstance(profile); err != nil {
 			return
 		}
+		// keep the leader handed off in a newer term, and the term of the peers
+		if peers, ok := meta.Peers[id]; ok && peers != nil {
+			if checkLeaderPeers(instance.Peers, peers, instance.Peers.Leader) == nil {
+				instance.Peers = peers
+			} else if instance.Peers, err = nextTermPeers(peers, instance.Peers, dbms.privKey); err != nil {
+				return
+			}
+		}
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
@@ -396,6 +504,12 @@ func (dbms *DBMS) initDatabases(
 
 // Create add new database to the miner dbms.
 func (dbms *DBMS) Create(instance *types.ServiceInstance, cleanup bool) (err error) {
+	return dbms.create(instance, cleanup, false)
+}
+
+// create adds new database to the miner dbms, a joining database syncs the state of the existing
+// peers from the blocks.
+func (dbms *DBMS) create(instance *types.ServiceInstance, cleanup bool, join bool) (err error) {
 	if _, alreadyExists := dbms.getMeta(instance.DatabaseID); alreadyExists {
 		return ErrAlreadyExists
 	}
@@ -410,6 +524,14 @@ func (dbms *DBMS) Create(instance *types.ServiceInstance, cleanup bool) (err err
 		}
 	}
 
+	// seed the storage of a clone database with the snapshot of its source
+	if source := instance.ResourceMeta.Source; source != "" {
+		if err = dbms.seedClone(instance.DatabaseID, source, rootDir); err != nil {
+			err = errors.Wrapf(err, "seed clone database %s", instance.DatabaseID)
+			return
+		}
+	}
+
 	var db *Database
 
 	defer func() {
@@ -432,17 +554,44 @@ func (dbms *DBMS) Create(instance *types.ServiceInstance, cleanup bool) (err err
 		ChainMux:               dbms.chainMux,
 		MaxWriteTimeGap:        dbms.cfg.MaxReqTimeGap,
 		EncryptionKey:          instance.ResourceMeta.EncryptionKey,
+		AllowUnencrypted:       dbms.cfg.AllowUnencrypted,
 		SpaceLimit:             instance.ResourceMeta.Space,
+		QuotaWarningThresholds: dbms.cfg.QuotaWarningThresholds,
 		UpdateBlockCount:       conf.GConf.BillingBlockCount,
 		UseEventualConsistency: instance.ResourceMeta.UseEventualConsistency,
 		ConsistencyLevel:       instance.ResourceMeta.ConsistencyLevel,
 		IsolationLevel:         instance.ResourceMeta.IsolationLevel,
 		SlowQueryTime:          DefaultSlowQueryTime,
-	}
-
-	// set last billing height
+		IdempotencyWindow:      dbms.cfg.IdempotencyWindow,
+		Admission:              dbms.cfg.Admission,
+		ResultLimit:            dbms.cfg.ResultLimit,
+		ResultCompression:      dbms.cfg.ResultCompression,
+		ResultCompressor:       dbms.compressor,
+		Audit:                  dbms.cfg.Audit,
+		PayloadAlert:           dbms.cfg.PayloadAlert,
+		BlockPacking:           dbms.cfg.BlockPacking,
+		SyncReadLimiter:        dbms.syncReadLimiter,
+		SyncWriteLimiter:       dbms.syncWriteLimiter,
+		ApplyConcurrency:       dbms.cfg.ApplyConcurrency,
+		GroupCommitDelay:       dbms.cfg.GroupCommitDelay,
+		SnapshotReads:          dbms.cfg.SnapshotReads,
+		LatencyProbeInterval:   dbms.cfg.LatencyProbeInterval,
+		TTLCheckInterval:       dbms.cfg.TTLCheckInterval,
+		JobCheckInterval:       dbms.cfg.JobCheckInterval,
+		ForeignDataHosts:       dbms.cfg.ForeignDataHosts,
+		AckReconcileWindow:     dbms.cfg.AckReconcileWindow,
+		LeaderTimestamp:        dbms.cfg.LeaderTimestamp,
+		Pool:                   instance.ResourceMeta.Pool,
+		Durability:             instance.ResourceMeta.Durability,
+		Source:                 instance.ResourceMeta.Source,
+		Join:                   join,
+	}
+
+	// set last billing height and standby state
 	if profile, ok := dbms.busService.RequestSQLProfile(dbCfg.DatabaseID); ok {
 		dbCfg.LastBillingHeight = int32(profile.LastUpdatedHeight)
+		dbCfg.Standby = !containsMiner(profile.Miners, dbms.address) &&
+			containsMiner(profile.Standby, dbms.address)
 	}
 
 	if db, err = NewDatabase(dbCfg, instance.Peers, instance.GenesisBlock); err != nil {
@@ -467,6 +616,10 @@ func (dbms *DBMS) Drop(dbID proto.DatabaseID) (err error) {
 		return ErrNotExists
 	}
 
+	// keep a read-only snapshot for standby queries
+	dbms.retainStandby(db)
+	dbms.busService.forgetSQLProfile(dbID)
+
 	// shutdown database
 	if err = db.Destroy(); err != nil {
 		return
@@ -489,7 +642,17 @@ func (dbms *DBMS) Update(instance *types.ServiceInstance) (err error) {
 	}
 
 	// update peers
-	return db.UpdatePeers(instance.Peers)
+	if err = db.UpdatePeers(instance.Peers); err != nil {
+		return
+	}
+	// keep the term of the peers across restarts
+	return dbms.writeMeta()
+}
+
+// RPCService returns the RPC service of the dbms, for the gateways serving the RPC methods in
+// other protocols.
+func (dbms *DBMS) RPCService() *DBMSRPCService {
+	return dbms.rpc
 }
 
 // Query handles query request in dbms.
@@ -497,6 +660,12 @@ func (dbms *DBMS) Query(req *types.Request) (res *types.Response, err error) {
 	var db *Database
 	var exists bool
 
+	// refuse new queries on shutdown
+	if dbms.isDraining() {
+		err = ErrShuttingDown
+		return
+	}
+
 	// check permission
 	addr, err := crypto.PubKeyHash(req.Header.Signee)
 	if err != nil {
@@ -506,6 +675,12 @@ func (dbms *DBMS) Query(req *types.Request) (res *types.Response, err error) {
 	if err != nil {
 		return
 	}
+	// only the admins may interrupt the queries of the other connections
+	if _, ok := parseKillQuery(req); ok && req.Header.QueryType == types.WriteQuery {
+		if err = dbms.checkSuperPermission(addr, req.Header.DatabaseID); err != nil {
+			return
+		}
+	}
 
 	// find database
 	if db, exists = dbms.getMeta(req.Header.DatabaseID); !exists {
@@ -513,7 +688,11 @@ func (dbms *DBMS) Query(req *types.Request) (res *types.Response, err error) {
 		return
 	}
 
-	return db.Query(req)
+	if res, err = db.Query(req); err != nil {
+		return
+	}
+	// the columnar encoded rows are compressed as a whole
+	return db.compressResult(db.encodeColumnar(res)), nil
 }
 
 // Ack handles ack of previous response.
@@ -569,13 +748,8 @@ func (dbms *DBMS) checkPermission(addr proto.AccountAddress,
 	dbID proto.DatabaseID, queryType types.QueryType, queries []types.Query) (err error) {
 	log.Debugf("in checkPermission, database id: %s, user addr: %s", dbID, addr.String())
 
-	var (
-		permStat *types.PermStat
-		ok       bool
-	)
-
 	// get database perm stat
-	permStat, ok = dbms.busService.RequestPermStat(dbID, addr)
+	permStat, ok := dbms.busService.RequestPermStat(dbID, addr)
 
 	// perm stat not exists
 	if !ok {
@@ -583,6 +757,11 @@ func (dbms *DBMS) checkPermission(addr proto.AccountAddress,
 		return
 	}
 
+	return checkPermStat(permStat, queryType, queries)
+}
+
+// checkPermStat checks if the queries of queryType are permitted by the permission state.
+func checkPermStat(permStat *types.PermStat, queryType types.QueryType, queries []types.Query) (err error) {
 	// check if query is enabled
 	if !permStat.Status.EnableQuery() {
 		err = errors.Wrapf(ErrPermissionDeny, "cannot query, status: %d", permStat.Status)
@@ -596,6 +775,12 @@ func (dbms *DBMS) checkPermission(addr proto.AccountAddress,
 			err = errors.Wrapf(ErrPermissionDeny, "cannot read, permission: %v", permStat.Permission)
 			return
 		}
+		// the read-only accounts can't write or change the schema with the read queries either
+		if !permStat.Permission.HasWritePermission() {
+			if err = checkReadOnlyQueries(queries); err != nil {
+				return
+			}
+		}
 	case types.WriteQuery:
 		if !permStat.Permission.HasWritePermission() {
 			err = errors.Wrapf(ErrPermissionDeny, "cannot write, permission: %v", permStat.Permission)
@@ -627,6 +812,22 @@ func (dbms *DBMS) checkPermission(addr proto.AccountAddress,
 
 // Shutdown defines dbms shutdown logic.
 func (dbms *DBMS) Shutdown() (err error) {
+	if dbms.backupCancel != nil {
+		dbms.backupCancel()
+	}
+	if dbms.maintenanceCancel != nil {
+		dbms.maintenanceCancel()
+	}
+	if dbms.loadReportCancel != nil {
+		dbms.loadReportCancel()
+	}
+	if dbms.standbyCancel != nil {
+		dbms.standbyCancel()
+	}
+	if dbms.standby != nil {
+		dbms.standby.close()
+	}
+
 	dbms.dbMap.Range(func(_, rawDB interface{}) bool {
 		db := rawDB.(*Database)
 
@@ -0,0 +1,137 @@
+package worker
+
+import (
+	"path/filepath"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+// checkAttached checks that the requester addr may read the database attached as alias, and
+// that the database is owned by the same account as the queried database.
+func (dbms *DBMS) checkAttached(
+	addr proto.AccountAddress, owner proto.AccountAddress, alias string, dbID proto.DatabaseID,
+	queries []types.Query,
+) (db *Database, err error) {
+	if !xs.ValidAttachAlias(alias) {
+		err = errors.Wrapf(xs.ErrInvalidAttachAlias, "%q", alias)
+		return
+	}
+	if err = dbms.checkPermission(addr, dbID, types.ReadQuery, queries); err != nil {
+		return
+	}
+	p, ok := dbms.busService.RequestSQLProfile(dbID)
+	if !ok {
+		err = errors.Wrapf(ErrNotExists, "attached database %s", dbID)
+		return
+	}
+	if p.Owner != owner {
+		err = errors.Wrapf(ErrPermissionDeny, "attached database %s has another owner", dbID)
+		return
+	}
+	var exists bool
+	if db, exists = dbms.getMeta(dbID); !exists {
+		err = errors.Wrapf(ErrAttachNotLocal, "attached database %s", dbID)
+		return
+	}
+	if db.cfg.EncryptionKey != "" {
+		err = errors.Wrapf(ErrInvalidRequest, "attached database %s is encrypted", dbID)
+	}
+	return
+}
+
+// AttachedQuery serves the read query req on the database joined with the databases attached in
+// the request header, which must be owned by the same account and served by this miner too. The
+// databases are read from the committed local replicas, and the response is never acknowledged
+// or packed into blocks.
+func (dbms *DBMS) AttachedQuery(req *types.Request) (res *types.Response, err error) {
+	if req.Header.QueryType != types.ReadQuery {
+		err = errors.Wrap(ErrInvalidRequest, "attached query is read-only")
+		return
+	}
+	attached := req.Header.AttachedDatabases()
+	if len(attached) == 0 {
+		err = errors.Wrap(ErrInvalidRequest, "attached databases are not set")
+		return
+	}
+	// the attached databases are in the extension fields, which must be covered by the signature
+	if err = req.Verify(); err != nil {
+		return
+	}
+
+	// check permission
+	addr, err := crypto.PubKeyHash(req.Header.Signee)
+	if err != nil {
+		return
+	}
+	err = dbms.checkPermission(addr, req.Header.DatabaseID, req.Header.QueryType, req.Payload.Queries)
+	if err != nil {
+		return
+	}
+	p, ok := dbms.busService.RequestSQLProfile(req.Header.DatabaseID)
+	if !ok {
+		err = ErrNotExists
+		return
+	}
+	db, exists := dbms.getMeta(req.Header.DatabaseID)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	if db.cfg.EncryptionKey != "" {
+		err = errors.Wrap(ErrInvalidRequest, "encrypted database cannot be attached")
+		return
+	}
+	var files = make(map[string]string, len(attached))
+	for alias, dbID := range attached {
+		var adb *Database
+		if adb, err = dbms.checkAttached(addr, p.Owner, alias, dbID, req.Payload.Queries); err != nil {
+			return
+		}
+		files[alias] = filepath.Join(adb.cfg.DataDir, StorageFileName)
+	}
+
+	sqldb, err := xs.OpenAttached(filepath.Join(db.cfg.DataDir, StorageFileName), files)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sqldb.Close() }()
+	ctx, cancel := withMaxExecutionTime(req.GetContext(), req)
+	defer cancel()
+	if res, err = x.QuerySnapshot(
+		ctx, sqldb, db.nodeID, req, resultLimit(db.cfg.ResultLimit),
+	); err != nil {
+		err = executionError(ctx, req, err)
+		return
+	}
+	res.Header.ResponseAccount = db.accountAddr
+	if err = res.BuildHash(); err != nil {
+		err = errors.Wrap(err, "failed to build response hash")
+		return
+	}
+	if err = res.Sign(db.privateKey); err != nil {
+		err = errors.Wrap(err, "failed to sign response")
+	}
+	return
+}
+
+// AttachedQuery rpc, called by client to join the database with the co-located databases.
+func (rpc *DBMSRPCService) AttachedQuery(req *types.Request, res *types.Response) (err error) {
+	// verify query is sent from the request node
+	if req.Envelope.NodeID.String() != string(req.Header.NodeID) {
+		err = errors.Wrap(ErrInvalidRequest, "request node id mismatch in attached query")
+		return
+	}
+
+	var r *types.Response
+	if r, err = rpc.dbms.AttachedQuery(req); err != nil {
+		return
+	}
+	*res = *r
+	return
+}
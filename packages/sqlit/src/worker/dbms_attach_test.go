@@ -0,0 +1,38 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestAttachedQueryRequest(t *testing.T) {
+	Convey("Given a miner without databases", t, func() {
+		var (
+			dbms = &DBMS{}
+			req  = &types.Request{
+				Header: types.SignedRequestHeader{RequestHeader: types.RequestHeader{
+					QueryType:  types.ReadQuery,
+					DatabaseID: "db1",
+				}},
+				Payload: types.RequestPayload{Queries: []types.Query{
+					{Pattern: `SELECT * FROM "users" JOIN "o"."orders"`},
+				}},
+			}
+		)
+		Convey("The query without attached databases should be refused", func() {
+			_, err := dbms.AttachedQuery(req)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+		})
+		Convey("The write query should be refused", func() {
+			So(req.Header.SetAttachedDatabases(map[string]proto.DatabaseID{"o": "db2"}), ShouldBeNil)
+			req.Header.QueryType = types.WriteQuery
+			_, err := dbms.AttachedQuery(req)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+		})
+	})
+}
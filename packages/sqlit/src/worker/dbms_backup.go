@@ -0,0 +1,386 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+	"sqlit/src/storage/objstore"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// BackupDirName defines the dir under the root dir of the backups requested by the database
+	// admins, if no backup target is configured.
+	BackupDirName = "backups"
+
+	backupFileExt    = ".db3"
+	backupTimeLayout = "20060102T150405Z"
+)
+
+// BackupState defines the state of a database backup.
+type BackupState int
+
+const (
+	// BackupIdle indicates no backup has been taken yet.
+	BackupIdle BackupState = iota
+	// BackupRunning indicates a backup is in progress.
+	BackupRunning
+	// BackupSucceeded indicates the last backup succeeded.
+	BackupSucceeded
+	// BackupFailed indicates the last backup failed.
+	BackupFailed
+)
+
+// String implements fmt.Stringer.
+func (s BackupState) String() string {
+	switch s {
+	case BackupIdle:
+		return "Idle"
+	case BackupRunning:
+		return "Running"
+	case BackupSucceeded:
+		return "Succeeded"
+	case BackupFailed:
+		return "Failed"
+	}
+	return "Unknown"
+}
+
+// BackupStatus defines the status of the latest backup of a database.
+type BackupStatus struct {
+	DatabaseID proto.DatabaseID
+	State      BackupState
+	// Location is the local path or object store location of the backup file
+	Location string
+	// Height is the sqlchain head height when the backup is taken
+	Height int32
+	// Offset is the log offset of the first query not included in the backup
+	Offset    uint64
+	Size      int64
+	StartTime time.Time
+	EndTime   time.Time
+	Error     string
+}
+
+// BackupReq defines the request to trigger an online backup of a database.
+type BackupReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+	// Target is an object store prefix like "s3://bucket/prefix", or a relative name resolved
+	// under the configured target, empty means the configured target
+	Target string
+}
+
+// BackupResp defines the response of a backup request.
+type BackupResp struct {
+	Status BackupStatus
+}
+
+// BackupStatusReq defines the request to query the backup status of a database.
+type BackupStatusReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+}
+
+// BackupStatusResp defines the response of a backup status request.
+type BackupStatusResp struct {
+	Status BackupStatus
+}
+
+// backupManager tracks backup status of all databases.
+type backupManager struct {
+	sync.Mutex
+	status map[proto.DatabaseID]*BackupStatus
+}
+
+func newBackupManager() *backupManager {
+	return &backupManager{
+		status: make(map[proto.DatabaseID]*BackupStatus),
+	}
+}
+
+// begin marks the backup of dbID as running, it fails if another backup is in progress.
+func (m *backupManager) begin(dbID proto.DatabaseID) (err error) {
+	m.Lock()
+	defer m.Unlock()
+	if s, ok := m.status[dbID]; ok && s.State == BackupRunning {
+		return ErrBackupInProgress
+	}
+	m.status[dbID] = &BackupStatus{
+		DatabaseID: dbID,
+		State:      BackupRunning,
+		StartTime:  time.Now(),
+	}
+	return
+}
+
+func (m *backupManager) end(status *BackupStatus) {
+	m.Lock()
+	defer m.Unlock()
+	s := *status
+	m.status[status.DatabaseID] = &s
+}
+
+func (m *backupManager) get(dbID proto.DatabaseID) (status BackupStatus) {
+	m.Lock()
+	defer m.Unlock()
+	if s, ok := m.status[dbID]; ok {
+		return *s
+	}
+	return BackupStatus{DatabaseID: dbID, State: BackupIdle}
+}
+
+// Backup takes an online backup of database dbID to target, which is a local directory or an
+// object store prefix like "s3://bucket/prefix". Empty target means the configured target.
+func (dbms *DBMS) Backup(
+	ctx context.Context, dbID proto.DatabaseID, target string) (status BackupStatus, err error,
+) {
+	var db *Database
+	var exists bool
+	if db, exists = dbms.getMeta(dbID); !exists {
+		err = ErrNotExists
+		return
+	}
+	if target == "" && dbms.cfg.Backup != nil {
+		target = dbms.cfg.Backup.Target
+	}
+	if target == "" {
+		err = errors.Wrap(ErrInvalidRequest, "no backup target")
+		return
+	}
+	if err = dbms.backups.begin(dbID); err != nil {
+		return
+	}
+
+	status = dbms.backups.get(dbID)
+	defer func() {
+		status.EndTime = time.Now()
+		if err != nil {
+			status.State = BackupFailed
+			status.Error = err.Error()
+		} else {
+			status.State = BackupSucceeded
+		}
+		dbms.backups.end(&status)
+		log.WithFields(log.Fields{
+			"db":       dbID,
+			"location": status.Location,
+			"height":   status.Height,
+			"offset":   status.Offset,
+			"size":     status.Size,
+			"elapsed":  status.EndTime.Sub(status.StartTime).String(),
+		}).WithError(err).Info("database backup finished")
+	}()
+
+	// Always backup to a local file first, then move or upload it to the target
+	var (
+		isRemote = objstore.IsLocation(target)
+		tmpDir   = target
+		tmpFile  string
+		fi       os.FileInfo
+	)
+	if isRemote {
+		tmpDir = filepath.Join(dbms.cfg.RootDir, BackupTempDirName)
+	}
+	if err = os.MkdirAll(tmpDir, 0755); err != nil {
+		return
+	}
+	tmpFile = filepath.Join(tmpDir, fmt.Sprintf(".%s-%d.tmp", dbID, status.StartTime.UnixNano()))
+	defer os.Remove(tmpFile)
+
+	if status.Height, status.Offset, err = db.chain.Backup(ctx, tmpFile); err != nil {
+		return
+	}
+	if fi, err = os.Stat(tmpFile); err != nil {
+		return
+	}
+	status.Size = fi.Size()
+	name := backupFileName(dbID, status.Height, status.Offset, status.StartTime)
+
+	if !isRemote {
+		status.Location = filepath.Join(target, name)
+		err = os.Rename(tmpFile, status.Location)
+		return
+	}
+
+	var (
+		client      *objstore.Client
+		bucket, key string
+	)
+	if client, err = dbms.objectStoreClient(); err != nil {
+		return
+	}
+	if bucket, key, err = objstore.ParseLocation(target); err != nil {
+		return
+	}
+	key = path.Join(key, name)
+	status.Location = fmt.Sprintf("%s://%s/%s", objstore.Scheme, bucket, key)
+	err = client.PutFile(ctx, bucket, key, tmpFile)
+	return
+}
+
+// BackupStatus returns the status of the latest backup of database dbID.
+func (dbms *DBMS) BackupStatus(dbID proto.DatabaseID) (status BackupStatus, err error) {
+	if _, exists := dbms.getMeta(dbID); !exists {
+		err = ErrNotExists
+		return
+	}
+	status = dbms.backups.get(dbID)
+	return
+}
+
+func (dbms *DBMS) objectStoreClient() (client *objstore.Client, err error) {
+	if dbms.cfg.Backup == nil || dbms.cfg.Backup.ObjectStore == nil {
+		err = errors.Wrap(ErrInvalidDBConfig, "object store is not configured")
+		return
+	}
+	info := dbms.cfg.Backup.ObjectStore
+	return objstore.NewClient(info.Endpoint, info.Region, info.AccessKey, info.SecretKey)
+}
+
+// runBackupScheduler takes backups of all databases periodically until ctx is done.
+func (dbms *DBMS) runBackupScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		dbms.dbMap.Range(func(key, _ interface{}) bool {
+			dbID := key.(proto.DatabaseID)
+			if _, err := dbms.Backup(ctx, dbID, ""); err != nil {
+				log.WithField("db", dbID).WithError(err).Error("scheduled backup failed")
+			}
+			return ctx.Err() == nil
+		})
+	}
+}
+
+// checkAdminPermission checks if the node is the miner itself or a super user of the database.
+func (dbms *DBMS) checkAdminPermission(nodeID proto.NodeID, dbID proto.DatabaseID) (err error) {
+	if localID, lerr := kms.GetLocalNodeID(); lerr == nil && localID == nodeID {
+		return
+	}
+	var addr proto.AccountAddress
+	if pubKey, perr := kms.GetPublicKey(nodeID); perr != nil {
+		err = errors.Wrap(perr, "get public key failed")
+		return
+	} else if addr, err = crypto.PubKeyHash(pubKey); err != nil {
+		return
+	}
+	return dbms.checkSuperPermission(addr, dbID)
+}
+
+// checkSuperPermission checks if the account is a super user of the database.
+func (dbms *DBMS) checkSuperPermission(addr proto.AccountAddress, dbID proto.DatabaseID) (err error) {
+	permStat, ok := dbms.busService.RequestPermStat(dbID, addr)
+	if !ok {
+		err = errors.Wrap(ErrPermissionDeny, "database not exists")
+		return
+	}
+	if !permStat.Permission.HasSuperPermission() {
+		err = errors.Wrapf(ErrPermissionDeny, "not admin, permission: %v", permStat.Permission)
+	}
+	return
+}
+
+// Backup rpc, called by database admin to take an online backup of the database.
+func (rpc *DBMSRPCService) Backup(req *BackupReq, resp *BackupResp) (err error) {
+	if err = rpc.dbms.checkAdminPermission(req.GetNodeID().ToNodeID(), req.DatabaseID); err != nil {
+		return
+	}
+	var target string
+	if target, err = rpc.dbms.resolveBackupTarget(req.Target); err != nil {
+		return
+	}
+	resp.Status, err = rpc.dbms.Backup(context.Background(), req.DatabaseID, target)
+	return
+}
+
+// resolveBackupTarget resolves the backup target requested by a database admin. Only an object
+// store location or a relative name is accepted, which is resolved under the configured target
+// or the backup dir of the root dir, the arbitrary local targets are left to the miner config.
+func (dbms *DBMS) resolveBackupTarget(target string) (resolved string, err error) {
+	if target == "" || objstore.IsLocation(target) {
+		return target, nil
+	}
+	var name = filepath.ToSlash(target)
+	if filepath.IsAbs(target) || strings.HasPrefix(name, "/") {
+		return "", errors.Wrapf(ErrInvalidRequest, "absolute backup target: %s", target)
+	}
+	for _, v := range strings.Split(name, "/") {
+		if v == ".." {
+			return "", errors.Wrapf(ErrInvalidRequest, "backup target out of base dir: %s", target)
+		}
+	}
+	if name = path.Clean(name); name == "." {
+		return "", errors.Wrapf(ErrInvalidRequest, "empty backup target: %s", target)
+	}
+	var base string
+	if dbms.cfg.Backup != nil {
+		base = dbms.cfg.Backup.Target
+	}
+	switch {
+	case base == "":
+		return filepath.Join(dbms.cfg.RootDir, BackupDirName, filepath.FromSlash(name)), nil
+	case objstore.IsLocation(base):
+		return strings.TrimSuffix(base, "/") + "/" + name, nil
+	default:
+		return filepath.Join(base, filepath.FromSlash(name)), nil
+	}
+}
+
+// BackupStatus rpc, called by database admin to query the latest backup status of the database.
+func (rpc *DBMSRPCService) BackupStatus(req *BackupStatusReq, resp *BackupStatusResp) (err error) {
+	if err = rpc.dbms.checkAdminPermission(req.GetNodeID().ToNodeID(), req.DatabaseID); err != nil {
+		return
+	}
+	resp.Status, err = rpc.dbms.BackupStatus(req.DatabaseID)
+	return
+}
+
+func backupFileName(dbID proto.DatabaseID, height int32, offset uint64, t time.Time) string {
+	return fmt.Sprintf("%s-%d-%d-%s%s", dbID, height, offset, t.UTC().Format(backupTimeLayout),
+		backupFileExt)
+}
+
+// parseBackupFileName parses the database, the head height and the log offset of the backup
+// file name, the backups taken before the log offset is recorded can't be parsed.
+func parseBackupFileName(name string) (
+	dbID proto.DatabaseID, height int32, offset uint64, err error,
+) {
+	var parts = strings.Split(strings.TrimSuffix(name, backupFileExt), "-")
+	if !strings.HasSuffix(name, backupFileExt) || len(parts) < 4 {
+		err = errors.Wrapf(ErrInvalidRequest, "not a backup file name: %s", name)
+		return
+	}
+	var (
+		n = len(parts)
+		h int64
+	)
+	if _, err = time.Parse(backupTimeLayout, parts[n-1]); err == nil {
+		if offset, err = strconv.ParseUint(parts[n-2], 10, 64); err == nil {
+			h, err = strconv.ParseInt(parts[n-3], 10, 32)
+		}
+	}
+	if err != nil {
+		err = errors.Wrapf(ErrInvalidRequest, "not a backup file name: %s", name)
+		return
+	}
+	dbID, height = proto.DatabaseID(strings.Join(parts[:n-3], "-")), int32(h)
+	return
+}
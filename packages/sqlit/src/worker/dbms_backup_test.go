@@ -0,0 +1,58 @@
+package worker
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+)
+
+func TestResolveBackupTarget(t *testing.T) {
+	Convey("Given a dbms resolving the backup targets requested by the admins", t, func() {
+		var dbms = &DBMS{cfg: &DBMSConfig{RootDir: "/data/miner"}}
+
+		Convey("The object store locations should be accepted as is", func() {
+			for _, v := range []string{"", "s3://bucket/prefix", "s3://bucket/../other"} {
+				target, err := dbms.resolveBackupTarget(v)
+				So(err, ShouldBeNil)
+				So(target, ShouldEqual, v)
+			}
+		})
+		Convey("The names should be resolved under the backup dir of the root dir", func() {
+			target, err := dbms.resolveBackupTarget("daily/db1")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, filepath.Join("/data/miner", BackupDirName, "daily", "db1"))
+			target, err = dbms.resolveBackupTarget("./daily//")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, filepath.Join("/data/miner", BackupDirName, "daily"))
+		})
+		Convey("The names should be resolved under the configured target", func() {
+			dbms.cfg.Backup = &conf.BackupInfo{Target: "/backup"}
+			target, err := dbms.resolveBackupTarget("daily")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, filepath.Join("/backup", "daily"))
+			dbms.cfg.Backup.Target = "s3://bucket/prefix/"
+			target, err = dbms.resolveBackupTarget("daily")
+			So(err, ShouldBeNil)
+			So(target, ShouldEqual, "s3://bucket/prefix/daily")
+		})
+		Convey("The paths out of the base dir should be refused", func() {
+			for _, v := range []string{
+				"/etc",
+				"/data/miner/backups",
+				"..",
+				"../db",
+				"daily/../../db",
+				"daily/..",
+				".",
+				"./",
+			} {
+				_, err := dbms.resolveBackupTarget(v)
+				So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+			}
+		})
+	})
+}
@@ -0,0 +1,429 @@
+package worker
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	chash "sqlit/src/crypto/hash"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/storage"
+	"sqlit/src/storage/objstore"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultBackupVerifyTimeout defines the timeout of a backup verification, which includes
+	// waiting for the blocks of the queries in the live snapshot.
+	DefaultBackupVerifyTimeout = 10 * time.Minute
+
+	backupVerifyPollInterval = time.Second
+)
+
+var (
+	listStateSchemaSQL = `SELECT "type", "name", "tbl_name", "sql" FROM "sqlite_master"
+		WHERE "name" NOT LIKE 'sqlite\_stat%' ESCAPE '\' ORDER BY "type", "name"`
+	listStateTablesSQL = `SELECT "name" FROM "sqlite_master" WHERE "type" = 'table'
+		AND "name" NOT LIKE 'sqlite\_stat%' ESCAPE '\'
+		AND "sql" NOT LIKE 'CREATE VIRTUAL TABLE%' ORDER BY "name"`
+)
+
+// BackupVerifyReq defines the request to verify a backup of a database.
+type BackupVerifyReq struct {
+	proto.Envelope
+	// Location is the local path on the miner or the object store location of the backup file
+	Location string
+}
+
+// BackupVerifyResp defines the response of a backup verify request.
+type BackupVerifyResp struct {
+	Report BackupVerifyReport
+}
+
+// BackupVerifyReport defines the result of a backup verification. The backup is restored and
+// replayed to the log offset of a live snapshot, the backup is intact if the state hashes match.
+type BackupVerifyReport struct {
+	DatabaseID proto.DatabaseID
+	Location   string
+	// Height and Offset are the head height and the log offset when the backup is taken
+	Height int32
+	Offset uint64
+	// LiveHeight and LiveOffset are the head height and the log offset of the live snapshot
+	LiveHeight int32
+	LiveOffset uint64
+	// Blocks and Queries are the counts of the blocks and the queries replayed on the backup
+	Blocks     int
+	Queries    uint64
+	BackupHash chash.Hash
+	LiveHash   chash.Hash
+	Verified   bool
+	Elapsed    time.Duration
+}
+
+// VerifyBackup restores the backup at location into a temporary directory, replays the tail of the
+// sqlchain on it, and compares its state hash with a live snapshot of the database.
+func (dbms *DBMS) VerifyBackup(ctx context.Context, location string) (
+	report BackupVerifyReport, err error,
+) {
+	var (
+		start  = time.Now()
+		db     *Database
+		exists bool
+	)
+	report.Location = location
+	if report.DatabaseID, report.Height, report.Offset, err = parseBackupFileName(
+		path.Base(location)); err != nil {
+		return
+	}
+	if db, exists = dbms.getMeta(report.DatabaseID); !exists {
+		err = ErrNotExists
+		return
+	}
+	defer func() {
+		report.Elapsed = time.Since(start)
+		log.WithFields(log.Fields{
+			"db":       report.DatabaseID,
+			"location": location,
+			"blocks":   report.Blocks,
+			"queries":  report.Queries,
+			"verified": report.Verified,
+			"elapsed":  report.Elapsed.String(),
+		}).WithError(err).Info("database backup verification finished")
+	}()
+
+	var dir = filepath.Join(dbms.cfg.RootDir, BackupTempDirName,
+		fmt.Sprintf("verify-%s-%d", report.DatabaseID, start.UnixNano()))
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return
+	}
+	defer os.RemoveAll(dir)
+	var (
+		restored = filepath.Join(dir, "backup"+backupFileExt)
+		live     = filepath.Join(dir, "live"+backupFileExt)
+	)
+	if err = dbms.restoreBackup(ctx, location, restored); err != nil {
+		err = errors.Wrap(err, "restore backup failed")
+		return
+	}
+	if report.LiveHeight, report.LiveOffset, err = db.chain.Backup(ctx, live); err != nil {
+		return
+	}
+	if report.LiveOffset < report.Offset {
+		err = errors.Wrapf(ErrInvalidRequest, "backup at offset %d is ahead of the database at %d",
+			report.Offset, report.LiveOffset)
+		return
+	}
+
+	if report.Blocks, report.Queries, err = db.replayBackup(
+		ctx, restored, report.Height, report.Offset, report.LiveOffset); err != nil {
+		err = errors.Wrap(err, "replay backup failed")
+		return
+	}
+	if report.BackupHash, err = db.stateFileHash(ctx, restored); err != nil {
+		return
+	}
+	if report.LiveHash, err = db.stateFileHash(ctx, live); err != nil {
+		return
+	}
+	report.Verified = report.BackupHash.IsEqual(&report.LiveHash)
+	return
+}
+
+// restoreBackup copies the backup file at location to dst.
+func (dbms *DBMS) restoreBackup(ctx context.Context, location, dst string) (err error) {
+	var src io.ReadCloser
+	if objstore.IsLocation(location) {
+		var (
+			client      *objstore.Client
+			bucket, key string
+		)
+		if client, err = dbms.objectStoreClient(); err != nil {
+			return
+		}
+		if bucket, key, err = objstore.ParseLocation(location); err != nil {
+			return
+		}
+		if src, err = client.GetObject(ctx, bucket, key); err != nil {
+			return
+		}
+	} else if src, err = os.Open(location); err != nil {
+		return
+	}
+	defer src.Close()
+
+	var f *os.File
+	if f, err = os.Create(dst); err != nil {
+		return
+	}
+	if _, err = io.Copy(f, src); err != nil {
+		_ = f.Close()
+		return
+	}
+	return f.Close()
+}
+
+// replayBackup replays the write queries from offset to end of the blocks since height on the
+// state file. The queries before end may not be packed in the blocks yet, so it waits for the
+// new blocks until ctx is done.
+func (db *Database) replayBackup(
+	ctx context.Context, file string, height int32, offset, end uint64) (
+	blocks int, queries uint64, err error,
+) {
+	var (
+		dsn  *storage.DSN
+		strg *xs.SQLite3
+	)
+	if dsn, err = newStorageDSN(db.cfg, file); err != nil {
+		return
+	}
+	if strg, err = xs.NewSqlite(dsn.Format()); err != nil {
+		return
+	}
+	st := x.NewState(sql.IsolationLevel(db.cfg.IsolationLevel), db.nodeID, strg)
+	st.SetSeq(offset)
+	defer func() {
+		if cerr := st.Close(err == nil); err == nil {
+			err = cerr
+		}
+	}()
+
+	var seq = offset
+	for h := height; seq < end; {
+		var head int32
+		if _, _, head, err = db.chain.FetchBlockByCount(-1); err != nil {
+			return
+		}
+		if h > head {
+			select {
+			case <-ctx.Done():
+				err = errors.Wrapf(ctx.Err(), "wait for the block of offset %d", seq)
+				return
+			case <-time.After(backupVerifyPollInterval):
+			}
+			continue
+		}
+		var block *types.Block
+		if block, err = db.chain.FetchBlock(h); err != nil {
+			return
+		}
+		h++
+		if block = trimBlockQueries(block, seq, end); block == nil {
+			continue
+		}
+		if err = st.ReplayBlockWithContext(ctx, block); err != nil {
+			err = errors.Wrapf(err, "replay block at height %d", h-1)
+			return
+		}
+		next := blockEndOffset(block)
+		blocks, queries, seq = blocks+1, queries+next-seq, next
+	}
+	return
+}
+
+// trimBlockQueries returns a block of the write queries of block in [from, end), or nil if there
+// is none.
+func trimBlockQueries(block *types.Block, from, end uint64) (trimmed *types.Block) {
+	if block == nil {
+		return
+	}
+	var txs []*types.QueryAsTx
+	for _, q := range block.QueryTxs {
+		if q.Request.Header.QueryType != types.WriteQuery {
+			continue
+		}
+		if offset := q.Response.LogOffset; offset >= from && offset < end {
+			txs = append(txs, q)
+		}
+	}
+	if len(txs) == 0 {
+		return
+	}
+	return &types.Block{SignedHeader: block.SignedHeader, QueryTxs: txs}
+}
+
+// blockEndOffset returns the log offset next to the write queries of block.
+func blockEndOffset(block *types.Block) (end uint64) {
+	for _, q := range block.QueryTxs {
+		if next := q.Response.LogOffset + uint64(len(q.Request.Payload.Queries)); next > end {
+			end = next
+		}
+	}
+	return
+}
+
+func (db *Database) stateFileHash(ctx context.Context, file string) (h chash.Hash, err error) {
+	var (
+		dsn  *storage.DSN
+		strg *xs.SQLite3
+	)
+	if dsn, err = newStorageDSN(db.cfg, file); err != nil {
+		return
+	}
+	if strg, err = xs.NewSqlite(dsn.Format()); err != nil {
+		return
+	}
+	defer strg.Close()
+	return stateHash(ctx, strg.Reader())
+}
+
+// stateHash returns the hash of the schema and the table contents of the database. The rows of a
+// table are hashed in the order of all the columns, so the hash doesn't depend on the rowids or
+// the pages reorganized by the maintenance tasks.
+func stateHash(ctx context.Context, db *sql.DB) (h chash.Hash, err error) {
+	var (
+		d      = sha256.New()
+		tables []string
+	)
+	if err = hashRows(ctx, d, db, listStateSchemaSQL); err != nil {
+		return
+	}
+	if tables, err = listStateTables(ctx, db); err != nil {
+		return
+	}
+	for _, t := range tables {
+		var (
+			quoted  = `"` + strings.Replace(t, `"`, `""`, -1) + `"`
+			rows    *sql.Rows
+			columns []string
+		)
+		if rows, err = db.QueryContext(ctx, "SELECT * FROM "+quoted+" LIMIT 0"); err != nil {
+			return
+		}
+		columns, err = rows.Columns()
+		_ = rows.Close()
+		if err != nil {
+			return
+		}
+		var order = make([]string, len(columns))
+		for i := range columns {
+			order[i] = fmt.Sprint(i + 1)
+		}
+		if err = writeHashValue(d, t); err != nil {
+			return
+		}
+		if err = hashRows(ctx, d, db,
+			"SELECT * FROM "+quoted+" ORDER BY "+strings.Join(order, ", ")); err != nil {
+			err = errors.Wrapf(err, "hash table %s", t)
+			return
+		}
+	}
+	copy(h[:], d.Sum(nil))
+	return
+}
+
+func listStateTables(ctx context.Context, db *sql.DB) (tables []string, err error) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, listStateTablesSQL); err != nil {
+		return
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return
+		}
+		tables = append(tables, name)
+	}
+	err = rows.Err()
+	return
+}
+
+func hashRows(ctx context.Context, d hash.Hash, db *sql.DB, query string) (err error) {
+	var (
+		rows    *sql.Rows
+		columns []string
+	)
+	if rows, err = db.QueryContext(ctx, query); err != nil {
+		return
+	}
+	defer rows.Close()
+	if columns, err = rows.Columns(); err != nil {
+		return
+	}
+	var (
+		values = make([]interface{}, len(columns))
+		dest   = make([]interface{}, len(columns))
+	)
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+		for _, v := range values {
+			if err = writeHashValue(d, v); err != nil {
+				return
+			}
+		}
+	}
+	return rows.Err()
+}
+
+// writeHashValue writes the type tag and the length prefixed bytes of v to d. Each type scanned
+// from sqlite has an explicit encoding, so the hash doesn't depend on the platform: times are
+// encoded in UTC and all NaNs share one bit pattern.
+func writeHashValue(d hash.Hash, v interface{}) (err error) {
+	var (
+		tag  byte
+		data []byte
+		buf  [8]byte
+	)
+	switch v := v.(type) {
+	case nil:
+		tag = 'n'
+	case int64:
+		tag, data = 'i', buf[:]
+		binary.BigEndian.PutUint64(buf[:], uint64(v))
+	case float64:
+		if math.IsNaN(v) {
+			v = math.NaN()
+		}
+		tag, data = 'f', buf[:]
+		binary.BigEndian.PutUint64(buf[:], math.Float64bits(v))
+	case []byte:
+		tag, data = 'b', v
+	case string:
+		tag, data = 's', []byte(v)
+	case time.Time:
+		tag, data = 't', []byte(v.UTC().Format(time.RFC3339Nano))
+	default:
+		return errors.Wrapf(ErrUnsupportedValue, "%T", v)
+	}
+	var prefix [9]byte
+	prefix[0] = tag
+	binary.BigEndian.PutUint64(prefix[1:], uint64(len(data)))
+	_, _ = d.Write(prefix[:])
+	_, _ = d.Write(data)
+	return
+}
+
+// BackupVerify rpc, called by database admin to verify a backup of the database.
+func (rpc *DBMSRPCService) BackupVerify(req *BackupVerifyReq, resp *BackupVerifyResp) (err error) {
+	var dbID proto.DatabaseID
+	if dbID, _, _, err = parseBackupFileName(path.Base(req.Location)); err != nil {
+		return
+	}
+	if err = rpc.dbms.checkAdminPermission(req.GetNodeID().ToNodeID(), dbID); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultBackupVerifyTimeout)
+	defer cancel()
+	resp.Report, err = rpc.dbms.VerifyBackup(ctx, req.Location)
+	return
+}
@@ -0,0 +1,143 @@
+package worker
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestBackupFileName(t *testing.T) {
+	Convey("The backup file name should be parsed", t, func() {
+		var now = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		for _, id := range []proto.DatabaseID{"db", "db-with-dash"} {
+			name := backupFileName(id, 12, 4096, now)
+			So(name, ShouldEqual, string(id)+"-12-4096-20260102T030405Z.db3")
+			dbID, height, offset, err := parseBackupFileName(name)
+			So(err, ShouldBeNil)
+			So(dbID, ShouldEqual, id)
+			So(height, ShouldEqual, 12)
+			So(offset, ShouldEqual, 4096)
+		}
+		for _, name := range []string{
+			"db-12-20260102T030405Z.db3",
+			"db-12-4096-20260102T030405Z",
+			"db-x-4096-20260102T030405Z.db3",
+			"db-12--1-20260102T030405Z.db3",
+		} {
+			_, _, _, err := parseBackupFileName(name)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+		}
+	})
+}
+
+func TestStateHash(t *testing.T) {
+	Convey("Given two databases", t, func() {
+		dir, err := os.MkdirTemp("", "verify")
+		So(err, ShouldBeNil)
+		var strgs []*xs.SQLite3
+		Reset(func() {
+			for _, s := range strgs {
+				_ = s.Close()
+			}
+			_ = os.RemoveAll(dir)
+		})
+		for i, queries := range [][]string{
+			{
+				`CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT, b BLOB, f REAL)`,
+				`CREATE TABLE n (v)`,
+				`INSERT INTO t VALUES (1, 'a', x'01', 1.5), (2, NULL, NULL, 2)`,
+				`INSERT INTO n VALUES ('x'), ('y')`,
+			},
+			{
+				`CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT, b BLOB, f REAL)`,
+				`CREATE TABLE n (v)`,
+				`INSERT INTO t VALUES (2, NULL, NULL, 2), (1, 'a', x'01', 1.5)`,
+				`INSERT INTO n VALUES ('z'), ('y'), ('x')`,
+				`DELETE FROM n WHERE v = 'z'`,
+				`ANALYZE`,
+			},
+		} {
+			s, err := xs.NewSqlite(filepath.Join(dir, string(rune('a'+i))+".db3"))
+			So(err, ShouldBeNil)
+			strgs = append(strgs, s)
+			for _, q := range queries {
+				_, err = s.Writer().Exec(q)
+				So(err, ShouldBeNil)
+			}
+		}
+		var ctx = context.Background()
+
+		Convey("The same contents should have the same hash", func() {
+			h1, err := stateHash(ctx, strgs[0].Reader())
+			So(err, ShouldBeNil)
+			h2, err := stateHash(ctx, strgs[1].Reader())
+			So(err, ShouldBeNil)
+			So(h1, ShouldEqual, h2)
+		})
+		Convey("The changed contents should have different hashes", func() {
+			h1, err := stateHash(ctx, strgs[0].Reader())
+			So(err, ShouldBeNil)
+			for _, q := range []string{
+				`UPDATE t SET f = 1.25 WHERE id = 1`,
+				`UPDATE t SET f = 1.5 WHERE id = 1`,
+				`CREATE INDEX i ON t (v)`,
+			} {
+				_, err = strgs[1].Writer().Exec(q)
+				So(err, ShouldBeNil)
+				h2, err := stateHash(ctx, strgs[1].Reader())
+				So(err, ShouldBeNil)
+				if q == `UPDATE t SET f = 1.5 WHERE id = 1` {
+					So(h2, ShouldEqual, h1)
+				} else {
+					So(h2, ShouldNotEqual, h1)
+				}
+			}
+		})
+	})
+}
+
+func TestTrimBlockQueries(t *testing.T) {
+	Convey("The write queries of a block should be trimmed by the log offsets", t, func() {
+		var block = &types.Block{}
+		for _, v := range []struct {
+			qt      types.QueryType
+			offset  uint64
+			queries int
+		}{
+			{types.WriteQuery, 10, 2},
+			{types.ReadQuery, 0, 1},
+			{types.WriteQuery, 12, 1},
+			{types.WriteQuery, 13, 3},
+		} {
+			block.QueryTxs = append(block.QueryTxs, &types.QueryAsTx{
+				Request: &types.Request{
+					Header: types.SignedRequestHeader{
+						RequestHeader: types.RequestHeader{QueryType: v.qt},
+					},
+					Payload: types.RequestPayload{Queries: make([]types.Query, v.queries)},
+				},
+				Response: &types.SignedResponseHeader{
+					ResponseHeader: types.ResponseHeader{LogOffset: v.offset},
+				},
+			})
+		}
+		So(blockEndOffset(block), ShouldEqual, 16)
+
+		trimmed := trimBlockQueries(block, 12, 13)
+		So(trimmed.QueryTxs, ShouldHaveLength, 1)
+		So(blockEndOffset(trimmed), ShouldEqual, 13)
+		trimmed = trimBlockQueries(block, 0, 16)
+		So(trimmed.QueryTxs, ShouldHaveLength, 3)
+		So(trimBlockQueries(block, 16, 20), ShouldBeNil)
+		So(trimBlockQueries(nil, 0, 20), ShouldBeNil)
+	})
+}
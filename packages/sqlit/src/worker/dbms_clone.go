@@ -0,0 +1,313 @@
+package worker
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// CloneDirName defines the dir of the source snapshots served to the clone databases.
+	CloneDirName = "clone"
+	// DefaultCloneSnapshotRetention defines how long a source snapshot is served to the miners of
+	// a clone database.
+	DefaultCloneSnapshotRetention = 30 * time.Minute
+	// DefaultCloneFetchTimeout defines the timeout to fetch the source snapshot of a clone
+	// database.
+	DefaultCloneFetchTimeout = 10 * time.Minute
+
+	cloneChunkSize     = 4 * 1024 * 1024
+	cloneRetryInterval = 5 * time.Second
+	cloneFileExt       = ".db3"
+)
+
+// CloneSnapshotReq defines the request to fetch a chunk of the source database snapshot, which is
+// sent by the miners of the clone database.
+type CloneSnapshotReq struct {
+	proto.Envelope
+	Source proto.DatabaseID
+	Clone  proto.DatabaseID
+	Offset int64
+}
+
+// CloneSnapshotResp defines the response of a clone snapshot request.
+type CloneSnapshotResp struct {
+	// Height is the source sqlchain head height when the snapshot is taken
+	Height int32
+	Size   int64
+	Data   []byte
+}
+
+// cloneSnapshot defines a snapshot of the source database taken for a clone database.
+type cloneSnapshot struct {
+	clone   proto.DatabaseID
+	height  int32
+	size    int64
+	path    string
+	created time.Time
+}
+
+// read returns the chunk of the snapshot file at offset.
+func (s *cloneSnapshot) read(offset int64) (data []byte, err error) {
+	if offset < 0 || offset > s.size {
+		err = errors.Wrapf(ErrInvalidRequest, "invalid snapshot offset %d of size %d", offset, s.size)
+		return
+	}
+	n := s.size - offset
+	if n > cloneChunkSize {
+		n = cloneChunkSize
+	}
+	var f *os.File
+	if f, err = os.Open(s.path); err != nil {
+		return
+	}
+	defer f.Close()
+	data = make([]byte, n)
+	if _, err = f.ReadAt(data, offset); err == io.EOF {
+		err = nil
+	}
+	return
+}
+
+// cloneManager tracks the source snapshots served to the clone databases. A snapshot is taken
+// once per clone database, so that all the miners of the clone start from the same state.
+type cloneManager struct {
+	sync.Mutex
+	dir       string
+	retention time.Duration
+	snapshots map[proto.DatabaseID]*cloneSnapshot
+}
+
+func newCloneManager(dir string, retention time.Duration) *cloneManager {
+	return &cloneManager{
+		dir:       dir,
+		retention: retention,
+		snapshots: make(map[proto.DatabaseID]*cloneSnapshot),
+	}
+}
+
+// reset removes the snapshots left by the previous run.
+func (m *cloneManager) reset() (err error) {
+	m.Lock()
+	defer m.Unlock()
+	m.snapshots = make(map[proto.DatabaseID]*cloneSnapshot)
+	return os.RemoveAll(m.dir)
+}
+
+// get returns the snapshot of db taken for the clone database, it takes the snapshot at the
+// first request.
+func (m *cloneManager) get(
+	ctx context.Context, db *Database, clone proto.DatabaseID, now time.Time) (
+	s *cloneSnapshot, err error,
+) {
+	m.Lock()
+	defer m.Unlock()
+	m.expire(now)
+	if s = m.snapshots[clone]; s != nil {
+		return
+	}
+	if err = os.MkdirAll(m.dir, 0755); err != nil {
+		return
+	}
+
+	var (
+		ns = &cloneSnapshot{
+			clone:   clone,
+			path:    filepath.Join(m.dir, string(clone)+cloneFileExt),
+			created: now,
+		}
+		tmpFile = ns.path + ".tmp"
+		fi      os.FileInfo
+	)
+	defer os.Remove(tmpFile)
+	if ns.height, _, err = db.chain.Backup(ctx, tmpFile); err != nil {
+		return
+	}
+	if fi, err = os.Stat(tmpFile); err != nil {
+		return
+	}
+	ns.size = fi.Size()
+	if err = os.Rename(tmpFile, ns.path); err != nil {
+		return
+	}
+	m.snapshots[clone] = ns
+	s = ns
+	return
+}
+
+// expire removes the snapshots out of the retention, the caller should hold the lock.
+func (m *cloneManager) expire(now time.Time) {
+	for k, s := range m.snapshots {
+		if now.Sub(s.created) < m.retention {
+			continue
+		}
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			log.WithField("clone", k).WithError(err).Warning("remove clone snapshot failed")
+		}
+		delete(m.snapshots, k)
+	}
+}
+
+// checkClonePermission checks if the node is a miner of the clone database of source.
+func (dbms *DBMS) checkClonePermission(
+	nodeID proto.NodeID, source, clone proto.DatabaseID) (err error,
+) {
+	profile, ok := dbms.busService.RequestSQLProfile(clone)
+	if !ok {
+		return errors.Wrapf(ErrPermissionDeny, "clone database %s not found", clone)
+	}
+	if profile.Meta.Source != source {
+		return errors.Wrapf(ErrPermissionDeny, "database %s is not cloned from %s", clone, source)
+	}
+	for _, m := range profile.Miners {
+		if m.NodeID == nodeID {
+			return
+		}
+	}
+	return errors.Wrapf(ErrPermissionDeny, "node %s is not a miner of %s", nodeID, clone)
+}
+
+// CloneSnapshot returns a chunk of the snapshot of the source database taken for the clone.
+func (dbms *DBMS) CloneSnapshot(nodeID proto.NodeID, req *CloneSnapshotReq) (
+	resp *CloneSnapshotResp, err error,
+) {
+	if err = dbms.checkClonePermission(nodeID, req.Source, req.Clone); err != nil {
+		return
+	}
+	db, exists := dbms.getMeta(req.Source)
+	if !exists {
+		err = ErrNotExists
+		return
+	}
+	if db.cfg.EncryptionKey != "" {
+		err = errors.Wrap(ErrInvalidRequest, "encrypted database cannot be cloned")
+		return
+	}
+	var (
+		s    *cloneSnapshot
+		data []byte
+	)
+	if s, err = dbms.clones.get(context.Background(), db, req.Clone, time.Now()); err != nil {
+		return
+	}
+	if data, err = s.read(req.Offset); err != nil {
+		return
+	}
+	resp = &CloneSnapshotResp{
+		Height: s.height,
+		Size:   s.size,
+		Data:   data,
+	}
+	return
+}
+
+// seedClone fills the storage file of the clone database with the snapshot of its source, it
+// keeps the existing storage on restart.
+func (dbms *DBMS) seedClone(clone, source proto.DatabaseID, rootDir string) (err error) {
+	var dst = filepath.Join(rootDir, StorageFileName)
+	if _, err = os.Stat(dst); err == nil || !os.IsNotExist(err) {
+		return
+	}
+	if err = os.MkdirAll(rootDir, 0755); err != nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultCloneFetchTimeout)
+	defer cancel()
+	return dbms.fetchCloneSnapshot(ctx, clone, source, dst)
+}
+
+// fetchCloneSnapshot fetches the snapshot of the source database to dst. The snapshot is always
+// fetched from the first miner of the source, as the miners may be at different heights.
+func (dbms *DBMS) fetchCloneSnapshot(
+	ctx context.Context, clone, source proto.DatabaseID, dst string) (err error,
+) {
+	profile, ok := dbms.busService.RequestSQLProfile(source)
+	if !ok || len(profile.Miners) == 0 {
+		return errors.Wrapf(ErrNotExists, "clone source %s not found", source)
+	}
+	var (
+		node    = profile.Miners[0].NodeID
+		tmpFile = dst + ".tmp"
+		f       *os.File
+		height  int32
+		offset  int64
+		size    int64 = -1
+	)
+	if f, err = os.Create(tmpFile); err != nil {
+		return
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(tmpFile)
+	}()
+
+	for size < 0 || offset < size {
+		var (
+			req = &CloneSnapshotReq{
+				Source: source,
+				Clone:  clone,
+				Offset: offset,
+			}
+			resp = &CloneSnapshotResp{}
+		)
+		if err = mux.NewCaller().CallNodeWithContext(
+			ctx, node, route.DBSCloneSnapshot.String(), req, resp,
+		); err != nil {
+			log.WithFields(log.Fields{
+				"clone":  clone,
+				"source": source,
+				"node":   node,
+			}).WithError(err).Warning("fetch clone snapshot failed, will retry")
+			select {
+			case <-ctx.Done():
+				return errors.Wrapf(err, "fetch clone snapshot of %s", source)
+			case <-time.After(cloneRetryInterval):
+				continue
+			}
+		}
+		if size >= 0 && (resp.Size != size || resp.Height != height) {
+			return errors.Wrap(ErrInvalidRequest, "clone snapshot changed during fetch")
+		}
+		if len(resp.Data) == 0 && offset < resp.Size {
+			return errors.Wrap(ErrInvalidRequest, "empty clone snapshot chunk")
+		}
+		size, height = resp.Size, resp.Height
+		if _, err = f.Write(resp.Data); err != nil {
+			return
+		}
+		offset += int64(len(resp.Data))
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = os.Rename(tmpFile, dst); err != nil {
+		return
+	}
+	log.WithFields(log.Fields{
+		"clone":  clone,
+		"source": source,
+		"height": height,
+		"size":   size,
+	}).Info("clone database seeded from source snapshot")
+	return
+}
+
+// CloneSnapshot rpc, called by the miners of a clone database to fetch the source snapshot.
+func (rpc *DBMSRPCService) CloneSnapshot(req *CloneSnapshotReq, resp *CloneSnapshotResp) (err error) {
+	var r *CloneSnapshotResp
+	if r, err = rpc.dbms.CloneSnapshot(req.GetNodeID().ToNodeID(), req); err != nil {
+		return
+	}
+	*resp = *r
+	return
+}
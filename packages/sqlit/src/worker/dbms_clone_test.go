@@ -0,0 +1,72 @@
+package worker
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCloneManager(t *testing.T) {
+	Convey("Given a clone dir with a source snapshot", t, func() {
+		dir, err := os.MkdirTemp("", "clone")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(dir) })
+
+		var (
+			content = bytes.Repeat([]byte("0123456789abcdef"), cloneChunkSize/8)
+			now     = time.Now()
+			m       = newCloneManager(dir, time.Minute)
+			s       = &cloneSnapshot{
+				clone:   "clone",
+				height:  1,
+				size:    int64(len(content)),
+				path:    filepath.Join(dir, "clone"+cloneFileExt),
+				created: now,
+			}
+		)
+		So(os.WriteFile(s.path, content, 0644), ShouldBeNil)
+		m.snapshots[s.clone] = s
+
+		Convey("The snapshot should be read in chunks", func() {
+			var (
+				buf    []byte
+				offset int64
+			)
+			for offset < s.size {
+				data, err := s.read(offset)
+				So(err, ShouldBeNil)
+				So(len(data), ShouldBeLessThanOrEqualTo, cloneChunkSize)
+				So(len(data), ShouldBeGreaterThan, 0)
+				buf = append(buf, data...)
+				offset += int64(len(data))
+			}
+			So(buf, ShouldResemble, content)
+			data, err := s.read(s.size)
+			So(err, ShouldBeNil)
+			So(data, ShouldBeEmpty)
+			_, err = s.read(s.size + 1)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+			_, err = s.read(-1)
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+		})
+		Convey("The snapshot should be removed out of the retention", func() {
+			m.expire(now.Add(time.Second))
+			So(m.snapshots, ShouldContainKey, s.clone)
+			m.expire(now.Add(time.Minute))
+			So(m.snapshots, ShouldBeEmpty)
+			_, err = os.Stat(s.path)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+		Convey("The stale snapshots should be removed on reset", func() {
+			So(m.reset(), ShouldBeNil)
+			So(m.snapshots, ShouldBeEmpty)
+			_, err = os.Stat(dir)
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
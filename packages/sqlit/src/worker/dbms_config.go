@@ -4,6 +4,7 @@ package worker
 import (
 	"time"
 
+	"sqlit/src/conf"
 	"sqlit/src/rpc"
 	"sqlit/src/rpc/mux"
 )
@@ -20,4 +21,88 @@ type DBMSConfig struct {
 	DirectServer     *rpc.Server // optional server to provide DBMS service
 	MaxReqTimeGap    time.Duration
 	OnCreateDatabase func()
+
+	// SyncReadBandwidth and SyncWriteBandwidth limit the block catch-up traffic shared by all
+	// databases of this miner, in bytes per second, 0 means unlimited.
+	SyncReadBandwidth  int64
+	SyncWriteBandwidth int64
+
+	// ApplyConcurrency defines the max count of requests prepared in parallel for replaying on
+	// followers, 0 means the count of cpus.
+	ApplyConcurrency int
+
+	// GroupCommitDelay defines the max delay of committing the concurrent write requests in a
+	// single transaction on the leaders, 0 disables the group commit.
+	GroupCommitDelay time.Duration
+
+	// SnapshotReads defines whether the read queries of the read uncommitted databases run on the
+	// last committed snapshot.
+	SnapshotReads bool
+
+	// LatencyProbeInterval defines the interval of pinging the peers of the databases to measure
+	// the round-trip times, 0 disables it.
+	LatencyProbeInterval time.Duration
+
+	// TTLCheckInterval defines the interval of deleting the expired rows of the tables with a row
+	// ttl on the leaders, 0 means DefaultTTLCheckInterval and a negative value disables it.
+	TTLCheckInterval time.Duration
+
+	// JobCheckInterval defines the interval of checking the due runs of the scheduled jobs on the
+	// leaders, 0 means DefaultJobCheckInterval and a negative value disables it.
+	JobCheckInterval time.Duration
+
+	// ForeignDataHosts defines the hosts of the https endpoints the leaders fetch for the foreign
+	// tables, a host prefixed with "*." allows its subdomains, empty disables the fetches.
+	ForeignDataHosts []string
+
+	// AckReconcileWindow defines the age of the responses without acks, of which the leaders
+	// re-request the acks from the responders or expire them, 0 disables it.
+	AckReconcileWindow time.Duration
+
+	// LeaderTimestamp defines whether the client request time is advisory: the requests beyond
+	// MaxReqTimeGap are accepted with warnings and the queries are indexed by the response time.
+	LeaderTimestamp bool
+
+	// LoadReportInterval defines the interval of reporting the loads of the databases to the block
+	// producers, 0 disables it.
+	LoadReportInterval time.Duration
+
+	// QuotaWarningThresholds defines the quota usage ratios to emit warnings, nil means defaults.
+	QuotaWarningThresholds []float64
+
+	// AllowUnencrypted serves the databases with encryption key in plain text if the sqlite
+	// library has no page encryption support, they are refused by default.
+	AllowUnencrypted bool
+
+	// StandbyRetention defines how long the snapshots of dropped databases are served for standby
+	// queries, 0 disables snapshot retention.
+	StandbyRetention time.Duration
+
+	// IdempotencyWindow defines how long the write requests are deduplicated by idempotency key,
+	// 0 means DefaultIdempotencyWindow.
+	IdempotencyWindow time.Duration
+
+	// Backup defines the online backup config, nil disables scheduled backups.
+	Backup *conf.BackupInfo
+
+	// Maintenance defines the background maintenance config, nil disables maintenance.
+	Maintenance *conf.MaintenanceInfo
+
+	// Admission defines the admission control of the batch priority queries, nil means defaults.
+	Admission *conf.AdmissionInfo
+
+	// ResultLimit defines the limit of the read query results, nil means unlimited.
+	ResultLimit *conf.ResultLimitInfo
+
+	// ResultCompression defines the compression of the read query results, nil disables it.
+	ResultCompression *conf.ResultCompressionInfo
+
+	// Audit defines the write determinism audit of the led databases, nil disables the audit.
+	Audit *conf.AuditInfo
+
+	// PayloadAlert defines the alerts on the payload size growth, nil disables the alerts.
+	PayloadAlert *conf.PayloadAlertInfo
+
+	// BlockPacking defines the limits of the adaptive block packing, nil disables it.
+	BlockPacking *conf.BlockPackingInfo
 }
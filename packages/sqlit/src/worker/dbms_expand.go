@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// expandDatabase applies the miners addition of a database to this miner: a new miner syncs the
+// state from the blocks of the existing ones and joins the peers, and the existing miners update
+// the peers of the database.
+func (dbms *DBMS) expandDatabase(itx interfaces.Transaction, count uint32) {
+	tx, ok := itx.(*types.ExpandDatabase)
+	if !ok {
+		log.WithFields(log.Fields{
+			"type": itx.GetTransactionType(),
+		}).WithError(ErrInvalidTransactionType).Warn("invalid tx type in expand database")
+		return
+	}
+
+	var (
+		id = tx.TargetSQLChain.DatabaseID()
+		le = log.WithFields(log.Fields{
+			"id":   id,
+			"node": tx.Node,
+		})
+	)
+	profile, ok := dbms.busService.RequestSQLProfile(id)
+	if !ok {
+		le.Warn("cannot find profile")
+		return
+	}
+	if !containsMiner(profile.Miners, dbms.address) {
+		return
+	}
+
+	instance, err := dbms.buildSQLChainServiceInstance(profile)
+	if err != nil {
+		le.WithError(err).Warn("failed to build sqlchain service instance from profile")
+		return
+	}
+	db, exists := dbms.getMeta(id)
+	if !exists {
+		if err = dbms.create(instance, true, true); err != nil {
+			le.WithError(err).Error("join expanded database failed")
+		}
+		return
+	}
+	if err = db.UpdatePeers(instance.Peers); err != nil {
+		le.WithError(err).Error("update database peers failed")
+		return
+	}
+	if err = dbms.writeMeta(); err != nil {
+		le.WithError(err).Warn("write dbms meta failed")
+	}
+}
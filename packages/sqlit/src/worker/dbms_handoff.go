@@ -0,0 +1,234 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+	"sqlit/src/route"
+	"sqlit/src/rpc/mux"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// DefaultShutdownTimeout defines the default max time of the leadership handoff and block
+	// flushing on graceful shutdown.
+	DefaultShutdownTimeout = time.Minute
+
+	// TransferLeaderTimeout defines the max time for a follower to catch up with the leader
+	// before taking over the leadership.
+	TransferLeaderTimeout = 30 * time.Second
+)
+
+// TransferLeaderReq defines the request of a database leader to hand off the leadership.
+type TransferLeaderReq struct {
+	proto.Envelope
+	DatabaseID proto.DatabaseID
+	// Peers is the new peers config with the new leader, signed by the current leader
+	Peers *proto.Peers
+	// LastCommit and NextIndex are the bftraft log indexes of the current leader, which are
+	// caught up by the new leader before taking over the leadership
+	LastCommit uint64
+	NextIndex  uint64
+}
+
+// TransferLeaderResp defines the response of a leadership handoff request.
+type TransferLeaderResp struct{}
+
+// newLeaderPeers returns a copy of peers with the new leader in the next term, signed by the
+// current leader.
+func newLeaderPeers(
+	peers *proto.Peers, leader proto.NodeID, signer *asymmetric.PrivateKey) (p *proto.Peers, err error,
+) {
+	clone := peers.Clone()
+	p = &clone
+	p.Leader = leader
+	p.Term++
+	if err = p.Sign(signer); err != nil {
+		err = errors.Wrap(err, "sign peers failed")
+	}
+	return
+}
+
+// checkLeaderPeers checks the new peers config sent by node against the current one, only the
+// current leader is allowed to hand off the leadership to another peer in a newer term.
+func checkLeaderPeers(current, peers *proto.Peers, node proto.NodeID) (err error) {
+	if node != current.Leader {
+		return errors.Wrapf(ErrPermissionDeny, "node %s is not the current leader", node)
+	}
+	if peers == nil {
+		return errors.Wrap(ErrInvalidRequest, "nil peers")
+	}
+	if err = peers.Verify(); err != nil {
+		return errors.Wrap(err, "verify peers failed")
+	}
+	if peers.Term <= current.Term {
+		return errors.Wrapf(ErrInvalidRequest,
+			"stale peers term %d, current term %d", peers.Term, current.Term)
+	}
+	if !peers.SameServers(current) {
+		return errors.Wrap(ErrInvalidRequest, "peers servers mismatched")
+	}
+	if _, found := peers.Find(peers.Leader); !found || peers.Leader == current.Leader {
+		return errors.Wrapf(ErrInvalidRequest, "invalid new leader %s", peers.Leader)
+	}
+	return
+}
+
+// transferLeader hands off the leadership of the database to the first follower which catches
+// up with this leader, and returns the new leader. The client traffic of the database arrives at
+// this leader, so the followers with the lowest median round-trip time to it are tried first.
+func (db *Database) transferLeader(ctx context.Context) (leader proto.NodeID, err error) {
+	var (
+		peers    = db.bftraftRuntime.Peers()
+		newPeers *proto.Peers
+	)
+	if len(peers.Servers) < 2 {
+		err = ErrNoAvailableFollower
+		return
+	}
+
+	// stop accepting writes and wait for the in-flight ones, resume if no follower takes over
+	lastCommit, nextIndex := db.bftraftRuntime.Drain()
+	defer func() {
+		if err != nil {
+			db.bftraftRuntime.Resume()
+		}
+	}()
+
+	for _, s := range db.latency.rank(peers.Servers) {
+		if s == peers.Leader {
+			continue
+		}
+		if newPeers, err = newLeaderPeers(peers, s, db.privateKey); err != nil {
+			return
+		}
+		if err = mux.NewCaller().CallNodeWithContext(ctx, s, route.DBSTransferLeader.String(),
+			&TransferLeaderReq{
+				DatabaseID: db.dbID,
+				Peers:      newPeers,
+				LastCommit: lastCommit,
+				NextIndex:  nextIndex,
+			}, &TransferLeaderResp{},
+		); err != nil {
+			log.WithFields(log.Fields{
+				"db":       db.dbID,
+				"follower": s,
+			}).WithError(err).Warning("transfer leadership to follower failed")
+			continue
+		}
+		leader = s
+		break
+	}
+	if leader.IsEmpty() {
+		err = errors.Wrapf(ErrNoAvailableFollower, "last error: %v", err)
+		return
+	}
+
+	// inform the other followers of the new leader
+	for _, s := range peers.Servers {
+		if s == peers.Leader || s == leader {
+			continue
+		}
+		if err := mux.NewCaller().CallNodeWithContext(ctx, s, route.DBSTransferLeader.String(),
+			&TransferLeaderReq{
+				DatabaseID: db.dbID,
+				Peers:      newPeers,
+			}, &TransferLeaderResp{},
+		); err != nil {
+			log.WithFields(log.Fields{
+				"db":       db.dbID,
+				"follower": s,
+			}).WithError(err).Warning("inform follower of the new leader failed")
+		}
+	}
+
+	err = db.UpdatePeers(newPeers)
+	return
+}
+
+// acceptLeaderPeers applies the new peers config sent by the current leader node, the new leader
+// catches up with the current leader first.
+func (db *Database) acceptLeaderPeers(
+	ctx context.Context, node proto.NodeID, req *TransferLeaderReq) (err error,
+) {
+	if err = checkLeaderPeers(db.bftraftRuntime.Peers(), req.Peers, node); err != nil {
+		return
+	}
+	if req.Peers.Leader == db.nodeID {
+		if err = db.bftraftRuntime.CatchUp(ctx, req.LastCommit, req.NextIndex); err != nil {
+			err = errors.Wrap(err, "catch up with the leader failed")
+			return
+		}
+	}
+	return db.UpdatePeers(req.Peers)
+}
+
+// isDraining returns whether the dbms is shutting down and refusing new queries.
+func (dbms *DBMS) isDraining() bool {
+	return atomic.LoadUint32(&dbms.draining) == 1
+}
+
+// Drain prepares the miner for a graceful shutdown: it stops accepting new queries, hands off the
+// leadership of the databases to healthy followers, and waits for the pooled queries to be packed
+// into blocks, until all done or ctx is done.
+func (dbms *DBMS) Drain(ctx context.Context) (err error) {
+	atomic.StoreUint32(&dbms.draining, 1)
+
+	wg := &sync.WaitGroup{}
+	dbms.dbMap.Range(func(_, rawDB interface{}) bool {
+		db := rawDB.(*Database)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			le := log.WithField("db", db.dbID)
+			if db.chain.IsLeader() {
+				if leader, err := db.transferLeader(ctx); err != nil {
+					le.WithError(err).Warning("hand off database leadership failed")
+				} else {
+					le.WithField("leader", leader).Info("database leadership handed off")
+				}
+			}
+			if err := db.chain.Flush(ctx); err != nil {
+				le.WithError(err).Warning("flush pending blocks failed")
+			}
+		}()
+		return true
+	})
+	wg.Wait()
+
+	// persist the new leaders
+	return dbms.writeMeta()
+}
+
+// TransferLeader handles the leadership handoff request from the database leader.
+func (dbms *DBMS) TransferLeader(node proto.NodeID, req *TransferLeaderReq) (err error) {
+	db, exists := dbms.getMeta(req.DatabaseID)
+	if !exists {
+		return ErrNotExists
+	}
+	if db.isStandby() {
+		return ErrStandbyMiner
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), TransferLeaderTimeout)
+	defer cancel()
+	if err = db.acceptLeaderPeers(ctx, node, req); err != nil {
+		return
+	}
+	log.WithFields(log.Fields{
+		"db":     req.DatabaseID,
+		"leader": req.Peers.Leader,
+		"term":   req.Peers.Term,
+	}).Info("database leader updated")
+	return dbms.writeMeta()
+}
+
+// TransferLeader rpc, called by database leader to hand off the leadership on shutdown.
+func (rpc *DBMSRPCService) TransferLeader(req *TransferLeaderReq, _ *TransferLeaderResp) (err error) {
+	return rpc.dbms.TransferLeader(req.GetNodeID().ToNodeID(), req)
+}
@@ -0,0 +1,69 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/proto"
+)
+
+func TestLeaderPeers(t *testing.T) {
+	Convey("Given the peers of a database led by node1", t, func() {
+		privKey, _, err := asymmetric.GenSecp256k1KeyPair()
+		So(err, ShouldBeNil)
+		current := &proto.Peers{
+			PeersHeader: proto.PeersHeader{
+				Term:    1,
+				Leader:  "node1",
+				Servers: []proto.NodeID{"node1", "node2", "node3"},
+			},
+		}
+		So(current.Sign(privKey), ShouldBeNil)
+
+		Convey("The leader should be able to hand off the leadership", func() {
+			peers, err := newLeaderPeers(current, "node2", privKey)
+			So(err, ShouldBeNil)
+			So(peers.Leader, ShouldEqual, "node2")
+			So(peers.Term, ShouldEqual, 2)
+			So(peers.Servers, ShouldResemble, current.Servers)
+			So(current.Leader, ShouldEqual, "node1")
+			So(checkLeaderPeers(current, peers, "node1"), ShouldBeNil)
+		})
+		Convey("The handoff should be rejected if it's not from the leader", func() {
+			peers, err := newLeaderPeers(current, "node2", privKey)
+			So(err, ShouldBeNil)
+			err = checkLeaderPeers(current, peers, "node3")
+			So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+		})
+		Convey("The handoff should be rejected if the peers are invalid", func() {
+			peers, err := newLeaderPeers(current, "node2", privKey)
+			So(err, ShouldBeNil)
+			peers.Term = 1
+			So(peers.Sign(privKey), ShouldBeNil)
+			err = checkLeaderPeers(current, peers, "node1")
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+
+			peers, err = newLeaderPeers(current, "node4", privKey)
+			So(err, ShouldBeNil)
+			err = checkLeaderPeers(current, peers, "node1")
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+
+			peers, err = newLeaderPeers(current, "node2", privKey)
+			So(err, ShouldBeNil)
+			peers.Servers = peers.Servers[:2]
+			So(peers.Sign(privKey), ShouldBeNil)
+			err = checkLeaderPeers(current, peers, "node1")
+			So(errors.Cause(err), ShouldEqual, ErrInvalidRequest)
+
+			peers, err = newLeaderPeers(current, "node2", privKey)
+			So(err, ShouldBeNil)
+			peers.Leader = "node3"
+			So(checkLeaderPeers(current, peers, "node1"), ShouldNotBeNil)
+
+			So(checkLeaderPeers(current, nil, "node1"), ShouldNotBeNil)
+		})
+	})
+}
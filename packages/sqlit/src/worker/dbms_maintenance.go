@@ -0,0 +1,144 @@
+package worker
+
+import (
+	"context"
+	"expvar"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"sqlit/src/conf"
+	xi "sqlit/src/dpos/interfaces"
+	"sqlit/src/proto"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// MaintenanceCheckInterval defines the maximum interval between two maintenance trigger checks.
+	MaintenanceCheckInterval = 10 * time.Second
+
+	mwMinerDBMaintenance = "service:miner:db:maintenance"
+)
+
+var maintenanceVars = expvar.NewMap(mwMinerDBMaintenance)
+
+// maintenanceStats defines the maintenance metrics of a database.
+type maintenanceStats struct {
+	lastRun time.Time
+	vars    *expvar.Map
+}
+
+// maintenanceManager tracks background maintenance of all databases.
+type maintenanceManager struct {
+	sync.Mutex
+	cfg   *conf.MaintenanceInfo
+	start time.Time
+	stats map[proto.DatabaseID]*maintenanceStats
+}
+
+func newMaintenanceManager(cfg *conf.MaintenanceInfo) *maintenanceManager {
+	return &maintenanceManager{
+		cfg:   cfg,
+		start: time.Now(),
+		stats: make(map[proto.DatabaseID]*maintenanceStats),
+	}
+}
+
+func (m *maintenanceManager) get(dbID proto.DatabaseID) (s *maintenanceStats) {
+	m.Lock()
+	defer m.Unlock()
+	var ok bool
+	if s, ok = m.stats[dbID]; !ok {
+		s = &maintenanceStats{lastRun: m.start, vars: new(expvar.Map).Init()}
+		m.stats[dbID] = s
+		maintenanceVars.Set(string(dbID), s.vars)
+	}
+	return
+}
+
+// due returns whether the maintenance of a database should run now by schedule or wal size.
+func (m *maintenanceManager) due(lastRun, now time.Time, walSize int64) bool {
+	if m.cfg.Interval > 0 && now.Sub(lastRun) >= m.cfg.Interval {
+		return true
+	}
+	return m.cfg.WALSizeTrigger > 0 && walSize >= m.cfg.WALSizeTrigger
+}
+
+// busy returns whether the maintenance should be postponed to avoid slowing down a loaded leader.
+func (m *maintenanceManager) busy(isLeader bool, inflight int32) bool {
+	return isLeader && inflight > m.cfg.MaxLeaderInflight
+}
+
+// maintain runs the maintenance of db if it is due, and returns whether it has run.
+func (m *maintenanceManager) maintain(ctx context.Context, db *Database) (ran bool, err error) {
+	var (
+		s       = m.get(db.dbID)
+		now     = time.Now()
+		walSize int64
+		result  xi.MaintenanceResult
+	)
+	if fi, serr := os.Stat(filepath.Join(db.cfg.DataDir, StorageFileName+"-wal")); serr == nil {
+		walSize = fi.Size()
+	}
+	if !m.due(s.lastRun, now, walSize) {
+		return
+	}
+	if m.busy(db.chain.IsLeader(), atomic.LoadInt32(&db.inflight)) {
+		s.vars.Add("skipped", 1)
+		log.WithField("db", db.dbID).Debug("leader is busy, postpone database maintenance")
+		return
+	}
+
+	s.lastRun = now
+	ran = true
+	result, err = db.chain.Maintain(ctx, xi.MaintenanceOptions{
+		Checkpoint:        true,
+		IncrementalVacuum: m.cfg.IncrementalVacuum,
+		Analyze:           m.cfg.Analyze,
+	})
+	if err != nil {
+		s.vars.Add("failed", 1)
+		return
+	}
+	s.vars.Add("runs", 1)
+	s.vars.Add("reclaimed:bytes", result.ReclaimedBytes)
+	s.vars.Add("wal:bytes", result.WALBytes)
+	log.WithFields(log.Fields{
+		"db":        db.dbID,
+		"frames":    result.CheckpointedFrames,
+		"wal":       result.WALBytes,
+		"reclaimed": result.ReclaimedBytes,
+		"elapsed":   time.Since(now).String(),
+	}).Info("database maintenance finished")
+	return
+}
+
+// runMaintenanceScheduler checkpoints, vacuums and analyzes all databases by schedule or wal
+// size until ctx is done.
+func (dbms *DBMS) runMaintenanceScheduler(ctx context.Context, cfg *conf.MaintenanceInfo) {
+	var (
+		m        = newMaintenanceManager(cfg)
+		interval = MaintenanceCheckInterval
+	)
+	if cfg.Interval > 0 && cfg.Interval < interval {
+		interval = cfg.Interval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		dbms.dbMap.Range(func(key, rawDB interface{}) bool {
+			db := rawDB.(*Database)
+			if _, err := m.maintain(ctx, db); err != nil {
+				log.WithField("db", key).WithError(err).Error("database maintenance failed")
+			}
+			return ctx.Err() == nil
+		})
+	}
+}
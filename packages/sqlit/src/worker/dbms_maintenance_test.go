@@ -0,0 +1,41 @@
+package worker
+
+import (
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+)
+
+func TestMaintenanceManager(t *testing.T) {
+	Convey("Given a maintenance manager", t, func() {
+		var (
+			m = newMaintenanceManager(&conf.MaintenanceInfo{
+				Interval:          time.Hour,
+				WALSizeTrigger:    1 << 20,
+				MaxLeaderInflight: 2,
+			})
+			now = time.Now()
+		)
+		Convey("Maintenance should be due by schedule or wal size", func() {
+			So(m.due(now, now, 0), ShouldBeFalse)
+			So(m.due(now.Add(-time.Hour), now, 0), ShouldBeTrue)
+			So(m.due(now, now, 1<<20), ShouldBeTrue)
+			m.cfg.WALSizeTrigger = 0
+			So(m.due(now, now, 1<<30), ShouldBeFalse)
+		})
+		Convey("Maintenance should be postponed on a loaded leader only", func() {
+			So(m.busy(false, 100), ShouldBeFalse)
+			So(m.busy(true, 2), ShouldBeFalse)
+			So(m.busy(true, 3), ShouldBeTrue)
+		})
+		Convey("Stats should be created once per database", func() {
+			s := m.get("db-maintenance")
+			So(s.lastRun, ShouldEqual, m.start)
+			So(m.get("db-maintenance"), ShouldEqual, s)
+			So(maintenanceVars.Get("db-maintenance"), ShouldNotBeNil)
+		})
+	})
+}
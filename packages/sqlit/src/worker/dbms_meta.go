@@ -8,11 +8,15 @@ import (
 // DBMSMeta defines the meta structure.
 type DBMSMeta struct {
 	DBS map[proto.DatabaseID]bool
+	// Peers keeps the peers config of the databases, which may have a leader handed off from the
+	// one assigned by block producer.
+	Peers map[proto.DatabaseID]*proto.Peers
 }
 
 // NewDBMSMeta returns new DBMSMeta struct.
 func NewDBMSMeta() (meta *DBMSMeta) {
 	return &DBMSMeta{
-		DBS: make(map[proto.DatabaseID]bool),
+		DBS:   make(map[proto.DatabaseID]bool),
+		Peers: make(map[proto.DatabaseID]*proto.Peers),
 	}
 }
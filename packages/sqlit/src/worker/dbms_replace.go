@@ -0,0 +1,86 @@
+package worker
+
+import (
+	"sqlit/src/blockproducer/interfaces"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils/log"
+)
+
+// replaceMiner applies the standby miner addition or promotion of a database to this miner: a
+// new standby starts replicating the database, the peers of the database are updated on the
+// miners and the promoted standby, and the replaced miner drops the database.
+func (dbms *DBMS) replaceMiner(itx interfaces.Transaction, count uint32) {
+	tx, ok := itx.(*types.ReplaceMiner)
+	if !ok {
+		log.WithFields(log.Fields{
+			"type": itx.GetTransactionType(),
+		}).WithError(ErrInvalidTransactionType).Warn("invalid tx type in replace miner")
+		return
+	}
+
+	var (
+		id = tx.TargetSQLChain.DatabaseID()
+		le = log.WithFields(log.Fields{
+			"id":      id,
+			"miner":   tx.Miner,
+			"standby": tx.Standby,
+		})
+	)
+	profile, ok := dbms.busService.RequestSQLProfile(id)
+	if !ok {
+		le.Warn("cannot find profile")
+		return
+	}
+	db, exists := dbms.getMeta(id)
+	if !containsMiner(profile.Miners, dbms.address) && !containsMiner(profile.Standby, dbms.address) {
+		if exists {
+			if err := dbms.Drop(id); err != nil {
+				le.WithError(err).Error("drop replaced database failed")
+			}
+		}
+		return
+	}
+
+	instance, err := dbms.buildSQLChainServiceInstance(profile)
+	if err != nil {
+		le.WithError(err).Warn("failed to build sqlchain service instance from profile")
+		return
+	}
+	if !exists {
+		if err = dbms.Create(instance, true); err != nil {
+			le.WithError(err).Error("create standby database failed")
+		}
+		return
+	}
+	if err = db.UpdatePeers(instance.Peers); err != nil {
+		le.WithError(err).Error("update database peers failed")
+		return
+	}
+	if err = dbms.writeMeta(); err != nil {
+		le.WithError(err).Warn("write dbms meta failed")
+	}
+}
+
+// isStandbyNode returns whether node is a standby miner of the database.
+func (dbms *DBMS) isStandbyNode(dbID proto.DatabaseID, node proto.NodeID) bool {
+	profile, ok := dbms.busService.RequestSQLProfile(dbID)
+	if !ok {
+		return false
+	}
+	for _, m := range profile.Standby {
+		if m.NodeID == node {
+			return true
+		}
+	}
+	return false
+}
+
+func containsMiner(miners []*types.MinerInfo, addr proto.AccountAddress) bool {
+	for _, m := range miners {
+		if m.Address == addr {
+			return true
+		}
+	}
+	return false
+}
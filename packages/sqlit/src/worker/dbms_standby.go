@@ -0,0 +1,410 @@
+package worker
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/kms"
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+const (
+	// StandbyDirName defines the dir of the retained snapshots of dropped databases.
+	StandbyDirName = "standby"
+
+	standbyFileExt  = ".db3"
+	standbyUsersExt = ".users"
+)
+
+// standbySnapshot defines a read-only snapshot of a database retained after it is dropped, with
+// the users of the database at the time, as the database profile is removed from the chain.
+type standbySnapshot struct {
+	dbID    proto.DatabaseID
+	height  int32
+	path    string
+	created time.Time
+	users   []*types.SQLChainUser
+	strg    *xs.SQLite3
+	queries sync.WaitGroup
+}
+
+// open opens the snapshot storage lazily.
+func (s *standbySnapshot) open() (strg *xs.SQLite3, err error) {
+	if s.strg == nil {
+		if s.strg, err = xs.NewSqlite(s.path); err != nil {
+			return
+		}
+	}
+	return s.strg, nil
+}
+
+// permStat returns the permission state of user addr on the snapshot.
+func (s *standbySnapshot) permStat(addr proto.AccountAddress) (permStat *types.PermStat, ok bool) {
+	for _, u := range s.users {
+		if u.Address == addr {
+			return &types.PermStat{Permission: u.Permission, Status: u.Status}, true
+		}
+	}
+	return
+}
+
+func (s *standbySnapshot) loadUsers() (err error) {
+	var data []byte
+	if data, err = os.ReadFile(s.path + standbyUsersExt); err != nil {
+		return
+	}
+	return utils.DecodeMsgPack(data, &s.users)
+}
+
+func (s *standbySnapshot) saveUsers() (err error) {
+	var buf *bytes.Buffer
+	if buf, err = utils.EncodeMsgPack(s.users); err != nil {
+		return
+	}
+	return os.WriteFile(s.path+standbyUsersExt, buf.Bytes(), 0600)
+}
+
+// close closes the snapshot storage once the running queries are done.
+func (s *standbySnapshot) close() {
+	s.queries.Wait()
+	if s.strg != nil {
+		if err := s.strg.Close(); err != nil {
+			log.WithField("db", s.dbID).WithError(err).Warning("close standby snapshot failed")
+		}
+		s.strg = nil
+	}
+}
+
+// standbyManager tracks the retained snapshots of dropped databases.
+type standbyManager struct {
+	sync.Mutex
+	dir       string
+	retention time.Duration
+	snapshots map[proto.DatabaseID]*standbySnapshot
+}
+
+func newStandbyManager(dir string, retention time.Duration) *standbyManager {
+	return &standbyManager{
+		dir:       dir,
+		retention: retention,
+		snapshots: make(map[proto.DatabaseID]*standbySnapshot),
+	}
+}
+
+func standbyFileName(dbID proto.DatabaseID, height int32) string {
+	return fmt.Sprintf("%s-%d%s", dbID, height, standbyFileExt)
+}
+
+func parseStandbyFileName(name string) (dbID proto.DatabaseID, height int32, ok bool) {
+	if !strings.HasSuffix(name, standbyFileExt) {
+		return
+	}
+	name = strings.TrimSuffix(name, standbyFileExt)
+	i := strings.LastIndex(name, "-")
+	if i <= 0 {
+		return
+	}
+	h, err := strconv.ParseInt(name[i+1:], 10, 32)
+	if err != nil {
+		return
+	}
+	return proto.DatabaseID(name[:i]), int32(h), true
+}
+
+// load loads the retained snapshots in the standby dir.
+func (m *standbyManager) load() (err error) {
+	if err = os.MkdirAll(m.dir, 0755); err != nil {
+		return
+	}
+	var entries []os.DirEntry
+	if entries, err = os.ReadDir(m.dir); err != nil {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+	for _, e := range entries {
+		dbID, height, ok := parseStandbyFileName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, ierr := e.Info()
+		if ierr != nil {
+			continue
+		}
+		s := &standbySnapshot{
+			dbID:    dbID,
+			height:  height,
+			path:    filepath.Join(m.dir, e.Name()),
+			created: info.ModTime(),
+		}
+		// the snapshot without users is kept, but no one is permitted to query it
+		if uerr := s.loadUsers(); uerr != nil {
+			log.WithField("db", dbID).WithError(uerr).Warning("load standby snapshot users failed")
+		}
+		m.replace(s)
+	}
+	return
+}
+
+// replace adds snapshot s and removes the older one of the same database, the caller must hold
+// the lock.
+func (m *standbyManager) replace(s *standbySnapshot) {
+	if old, ok := m.snapshots[s.dbID]; ok {
+		if old.created.After(s.created) {
+			m.remove(s)
+			return
+		}
+		m.remove(old)
+	}
+	m.snapshots[s.dbID] = s
+}
+
+func (m *standbyManager) remove(s *standbySnapshot) {
+	// the snapshot is not found by the new queries, and closed after the running ones
+	go s.close()
+	for _, f := range []string{s.path, s.path + "-shm", s.path + "-wal", s.path + standbyUsersExt} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			log.WithField("file", f).WithError(err).Warning("remove standby snapshot failed")
+		}
+	}
+	if m.snapshots[s.dbID] == s {
+		delete(m.snapshots, s.dbID)
+	}
+}
+
+// retain takes a snapshot of database db with its users before it is dropped.
+func (m *standbyManager) retain(
+	ctx context.Context, db *Database, users []*types.SQLChainUser) (err error,
+) {
+	tmpFile := filepath.Join(m.dir, fmt.Sprintf(".%s.tmp", db.dbID))
+	defer os.Remove(tmpFile)
+
+	var height int32
+	if height, _, err = db.chain.Backup(ctx, tmpFile); err != nil {
+		return
+	}
+	s := &standbySnapshot{
+		dbID:    db.dbID,
+		height:  height,
+		path:    filepath.Join(m.dir, standbyFileName(db.dbID, height)),
+		created: time.Now(),
+		users:   users,
+	}
+	m.Lock()
+	defer m.Unlock()
+	if old, ok := m.snapshots[db.dbID]; ok {
+		m.remove(old)
+	}
+	if err = os.Rename(tmpFile, s.path); err != nil {
+		return
+	}
+	if err = s.saveUsers(); err != nil {
+		m.remove(s)
+		return
+	}
+	m.snapshots[db.dbID] = s
+	return
+}
+
+// get returns the snapshot of database dbID to query, the caller must call done of the snapshot
+// once the query is finished.
+func (m *standbyManager) get(dbID proto.DatabaseID) (s *standbySnapshot, strg *xs.SQLite3, err error) {
+	m.Lock()
+	defer m.Unlock()
+	s, ok := m.snapshots[dbID]
+	if !ok || m.expired(s, time.Now()) {
+		err = ErrNotExists
+		return
+	}
+	if strg, err = s.open(); err != nil {
+		return
+	}
+	s.queries.Add(1)
+	return
+}
+
+// checkPermission checks if user addr is permitted to query the snapshot of database dbID.
+func (m *standbyManager) checkPermission(addr proto.AccountAddress,
+	dbID proto.DatabaseID, queryType types.QueryType, queries []types.Query) (err error) {
+	m.Lock()
+	s, ok := m.snapshots[dbID]
+	m.Unlock()
+	if !ok {
+		return errors.Wrap(ErrPermissionDeny, "database not exists")
+	}
+	permStat, ok := s.permStat(addr)
+	if !ok {
+		return errors.Wrap(ErrPermissionDeny, "user not exists in standby snapshot")
+	}
+	return checkPermStat(permStat, queryType, queries)
+}
+
+// query runs the read query req on the snapshot of the database.
+func (m *standbyManager) query(
+	ctx context.Context, nodeID proto.NodeID, req *types.Request, limit x.ResultLimit) (
+	resp *types.Response, err error,
+) {
+	s, strg, err := m.get(req.Header.DatabaseID)
+	if err != nil {
+		return
+	}
+	defer s.queries.Done()
+	resp, err = x.QuerySnapshot(ctx, strg.Reader(), nodeID, req, limit)
+	return
+}
+
+func (m *standbyManager) expired(s *standbySnapshot, now time.Time) bool {
+	return now.Sub(s.created) >= m.retention
+}
+
+// expire removes the snapshots older than retention.
+func (m *standbyManager) expire(now time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.snapshots {
+		if m.expired(s, now) {
+			log.WithFields(log.Fields{
+				"db":     s.dbID,
+				"height": s.height,
+			}).Info("standby snapshot expired")
+			m.remove(s)
+		}
+	}
+}
+
+func (m *standbyManager) close() {
+	m.Lock()
+	defer m.Unlock()
+	for _, s := range m.snapshots {
+		s.close()
+	}
+}
+
+// runStandbyExpiration removes the expired standby snapshots periodically until ctx is done.
+func (dbms *DBMS) runStandbyExpiration(ctx context.Context) {
+	interval := time.Minute
+	if dbms.standby.retention < interval {
+		interval = dbms.standby.retention
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dbms.standby.expire(time.Now())
+		}
+	}
+}
+
+// retainStandby keeps a snapshot of db for standby queries before it is dropped. Databases with
+// encryption key are not retained, as the key is not available once the database is dropped.
+// The snapshot is queried by the users in the last profile of the database, it's not retained if
+// the profile is unknown, e.g. the database is removed from the chain while the miner is down.
+func (dbms *DBMS) retainStandby(db *Database) {
+	if dbms.standby == nil || db.cfg.EncryptionKey != "" {
+		return
+	}
+	profile, ok := dbms.busService.RequestLastSQLProfile(db.dbID)
+	if !ok {
+		log.WithField("db", db.dbID).Warning("retain standby snapshot without database profile")
+		return
+	}
+	if err := dbms.standby.retain(context.Background(), db, profile.Users); err != nil {
+		log.WithField("db", db.dbID).WithError(err).Warning("retain standby snapshot failed")
+	}
+}
+
+// StandbyQuery serves the read query req from the local state of the database, which may be
+// stale if the database is catching up, or from the retained snapshot if the database has been
+// removed from this miner.
+func (dbms *DBMS) StandbyQuery(req *types.Request) (res *types.Response, err error) {
+	if req.Header.QueryType != types.ReadQuery {
+		err = ErrStandbyReadOnly
+		return
+	}
+
+	addr, err := crypto.PubKeyHash(req.Header.Signee)
+	if err != nil {
+		return
+	}
+
+	var ctx = req.GetContext()
+	if db, exists := dbms.getMeta(req.Header.DatabaseID); exists {
+		// check permission
+		err = dbms.checkPermission(addr, req.Header.DatabaseID, req.Header.QueryType, req.Payload.Queries)
+		if err != nil {
+			return
+		}
+		var height int32
+		if res, height, err = db.chain.QueryReadOnly(ctx, req); err != nil {
+			return
+		}
+		log.WithFields(log.Fields{
+			"db":     db.dbID,
+			"height": height,
+		}).Debug("served standby query from local state")
+		res.Header.ResponseAccount = db.accountAddr
+	} else if dbms.standby != nil {
+		// check permission against the users retained with the snapshot
+		err = dbms.standby.checkPermission(
+			addr, req.Header.DatabaseID, req.Header.QueryType, req.Payload.Queries)
+		if err != nil {
+			return
+		}
+		var nodeID proto.NodeID
+		if nodeID, err = kms.GetLocalNodeID(); err != nil {
+			return
+		}
+		if res, err = dbms.standby.query(
+			ctx, nodeID, req, resultLimit(dbms.cfg.ResultLimit),
+		); err != nil {
+			return
+		}
+		res.Header.ResponseAccount = dbms.address
+	} else {
+		err = ErrNotExists
+		return
+	}
+
+	if err = res.BuildHash(); err != nil {
+		err = errors.Wrap(err, "failed to build response hash")
+		return
+	}
+	if err = res.Sign(dbms.privKey); err != nil {
+		err = errors.Wrap(err, "failed to sign response")
+	}
+	return
+}
+
+// StandbyQuery rpc, called by client to read a possibly stale snapshot of the database.
+func (rpc *DBMSRPCService) StandbyQuery(req *types.Request, res *types.Response) (err error) {
+	// verify query is sent from the request node
+	if req.Envelope.NodeID.String() != string(req.Header.NodeID) {
+		err = errors.Wrap(ErrInvalidRequest, "request node id mismatch in standby query")
+		return
+	}
+
+	var r *types.Response
+	if r, err = rpc.dbms.StandbyQuery(req); err != nil {
+		return
+	}
+	*res = *r
+	return
+}
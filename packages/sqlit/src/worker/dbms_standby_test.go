@@ -0,0 +1,132 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	x "sqlit/src/dpos"
+	xs "sqlit/src/dpos/sqlite"
+	"sqlit/src/proto"
+	"sqlit/src/types"
+)
+
+func TestStandbyManager(t *testing.T) {
+	Convey("Standby file names should be parsed", t, func() {
+		dbID, height, ok := parseStandbyFileName(standbyFileName("db-1", 42))
+		So(ok, ShouldBeTrue)
+		So(dbID, ShouldEqual, "db-1")
+		So(height, ShouldEqual, 42)
+		_, _, ok = parseStandbyFileName("db.db3")
+		So(ok, ShouldBeFalse)
+		_, _, ok = parseStandbyFileName("db-1.tmp")
+		So(ok, ShouldBeFalse)
+	})
+	Convey("Given a standby dir with retained snapshots", t, func() {
+		dir, err := os.MkdirTemp("", "standby")
+		So(err, ShouldBeNil)
+		Reset(func() { os.RemoveAll(dir) })
+
+		for _, h := range []int32{1, 2} {
+			strg, err := xs.NewSqlite(filepath.Join(dir, standbyFileName("db", h)))
+			So(err, ShouldBeNil)
+			_, err = strg.Writer().Exec(`CREATE TABLE "t1" ("k" INT)`)
+			So(err, ShouldBeNil)
+			_, err = strg.Writer().Exec(fmt.Sprintf(`INSERT INTO "t1" VALUES (%d)`, h))
+			So(err, ShouldBeNil)
+			So(strg.Close(), ShouldBeNil)
+		}
+		old := filepath.Join(dir, standbyFileName("db", 1))
+		So(os.Chtimes(old, time.Now(), time.Now().Add(-time.Minute)), ShouldBeNil)
+		var (
+			reader = proto.AccountAddress{0x01}
+			admin  = proto.AccountAddress{0x02}
+			users  = &standbySnapshot{
+				path: filepath.Join(dir, standbyFileName("db", 2)),
+				users: []*types.SQLChainUser{
+					{Address: reader, Permission: types.UserPermissionFromRole(types.Read), Status: types.Normal},
+					{Address: admin, Permission: types.UserPermissionFromRole(types.Admin), Status: types.Normal},
+				},
+			}
+		)
+		So(users.saveUsers(), ShouldBeNil)
+
+		m := newStandbyManager(dir, time.Hour)
+		So(m.load(), ShouldBeNil)
+		defer m.close()
+
+		Convey("The latest snapshot should be served and the older one removed", func() {
+			So(m.snapshots, ShouldContainKey, proto.DatabaseID("db"))
+			So(m.snapshots["db"].height, ShouldEqual, 2)
+			_, err = os.Stat(old)
+			So(os.IsNotExist(err), ShouldBeTrue)
+
+			req := &types.Request{}
+			req.Header.DatabaseID = "db"
+			req.Header.QueryType = types.ReadQuery
+			req.Payload.Queries = []types.Query{{Pattern: `SELECT "k" FROM "t1"`}}
+			resp, err := m.query(context.Background(), "node", req, x.ResultLimit{})
+			So(err, ShouldBeNil)
+			So(resp.Header.RowCount, ShouldEqual, 1)
+			So(resp.Payload.Rows[0].Values[0], ShouldEqual, 2)
+
+			req.Header.QueryType = types.WriteQuery
+			_, err = m.query(context.Background(), "node", req, x.ResultLimit{})
+			So(err, ShouldNotBeNil)
+
+			req.Header.DatabaseID = "unknown"
+			req.Header.QueryType = types.ReadQuery
+			_, err = m.query(context.Background(), "node", req, x.ResultLimit{})
+			So(err, ShouldEqual, ErrNotExists)
+		})
+		Convey("The snapshot should be queried by the retained users only", func() {
+			var (
+				reads  = []types.Query{{Pattern: `SELECT "k" FROM "t1"`}}
+				writes = []types.Query{{Pattern: `DELETE FROM "t1"`}}
+			)
+			So(m.checkPermission(reader, "db", types.ReadQuery, reads), ShouldBeNil)
+			So(m.checkPermission(admin, "db", types.ReadQuery, writes), ShouldBeNil)
+			err = m.checkPermission(reader, "db", types.ReadQuery, writes)
+			So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+			err = m.checkPermission(proto.AccountAddress{0x03}, "db", types.ReadQuery, reads)
+			So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+			err = m.checkPermission(reader, "unknown", types.ReadQuery, reads)
+			So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+		})
+		Convey("The snapshot should be closed after the running queries", func() {
+			s, strg, err := m.get("db")
+			So(err, ShouldBeNil)
+			m.Lock()
+			m.remove(s)
+			m.Unlock()
+			_, _, err = m.get("db")
+			So(err, ShouldEqual, ErrNotExists)
+			// the storage is still open for the running query
+			So(strg.Reader().Ping(), ShouldBeNil)
+			s.queries.Done()
+			So(func() bool {
+				for i := 0; i < 100; i++ {
+					if strg.Reader().Ping() != nil {
+						return true
+					}
+					time.Sleep(10 * time.Millisecond)
+				}
+				return false
+			}(), ShouldBeTrue)
+		})
+		Convey("Expired snapshots should be removed", func() {
+			m.expire(time.Now().Add(2 * time.Hour))
+			So(m.snapshots, ShouldBeEmpty)
+			_, err = os.Stat(filepath.Join(dir, standbyFileName("db", 2)))
+			So(os.IsNotExist(err), ShouldBeTrue)
+			_, err = os.Stat(filepath.Join(dir, standbyFileName("db", 2)+standbyUsersExt))
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
@@ -2,6 +2,7 @@
 package worker
 
 import (
+	"context"
 	"os"
 	"strings"
 	"sync/atomic"
@@ -14,6 +15,7 @@ import (
 	"sqlit/src/crypto/asymmetric"
 	"sqlit/src/crypto/hash"
 	"sqlit/src/crypto/kms"
+	xs "sqlit/src/dpos/sqlite"
 	"sqlit/src/proto"
 	"sqlit/src/route"
 	rpc "sqlit/src/rpc/mux"
@@ -179,6 +181,36 @@ func TestDBMS(t *testing.T) {
 				err = testRequest(route.DBSAck, ack, &ackRes)
 				So(err, ShouldBeNil)
 
+				// verify a backup with the tail of the chain
+				backupDir, err := os.MkdirTemp("", "dbms_backup_")
+				So(err, ShouldBeNil)
+				defer os.RemoveAll(backupDir)
+				status, err := dbms.Backup(context.Background(), dbID, backupDir)
+				So(err, ShouldBeNil)
+				writeQuery, err = buildQueryWithDatabaseID(types.WriteQuery,
+					1, atomic.AddUint64(&seqNo, 1),
+					dbID, []string{
+						"insert into test values(2)",
+					})
+				So(err, ShouldBeNil)
+				err = testRequest(route.DBSQuery, writeQuery, &queryRes)
+				So(err, ShouldBeNil)
+				ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+				defer cancel()
+				report, err := dbms.VerifyBackup(ctx, status.Location)
+				So(err, ShouldBeNil)
+				So(report.Verified, ShouldBeTrue)
+				So(report.Queries, ShouldEqual, 1)
+				So(report.LiveOffset, ShouldEqual, status.Offset+1)
+				tampered, err := xs.NewSqlite(status.Location)
+				So(err, ShouldBeNil)
+				_, err = tampered.Writer().Exec("insert into test values(3)")
+				So(err, ShouldBeNil)
+				So(tampered.Close(), ShouldBeNil)
+				report, err = dbms.VerifyBackup(ctx, status.Location)
+				So(err, ShouldBeNil)
+				So(report.Verified, ShouldBeFalse)
+
 				_, _, err = dbms.observerFetchBlock(dbID2, nodeID, 1)
 				So(err.Error(), ShouldContainSubstring, ErrPermissionDeny.Error())
 				_, _, err = dbms.observerFetchBlock(dbID, nodeID, 1)
@@ -220,6 +252,18 @@ func TestDBMS(t *testing.T) {
 					err = testRequest(route.DBSQuery, readQuery, &queryRes)
 					So(err, ShouldBeNil)
 
+					// sending write statement in read query
+					readQuery, err = buildQueryWithDatabaseID(types.ReadQuery,
+						1, atomic.AddUint64(&seqNo, 1),
+						dbID, []string{
+							"insert into test values(2) returning test",
+						})
+					So(err, ShouldBeNil)
+
+					err = testRequest(route.DBSQuery, readQuery, &queryRes)
+					So(err, ShouldNotBeNil)
+					So(err.Error(), ShouldContainSubstring, "read-only account")
+
 					_, _, err = dbms.observerFetchBlock(dbID, nodeID, 1)
 					So(err, ShouldBeNil)
 				})
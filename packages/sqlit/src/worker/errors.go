@@ -1,27 +1,70 @@
 
 package worker
 
-import "errors"
+import (
+	"errors"
+
+	"sqlit/src/types"
+)
 
 var (
 	// ErrInvalidRequest defines invalid request structure during request.
 	ErrInvalidRequest = errors.New("invalid request supplied")
 	// ErrInvalidRequestSeq defines invalid sequence no of request.
-	ErrInvalidRequestSeq = errors.New("invalid request sequence applied")
+	ErrInvalidRequestSeq = errors.New(types.ErrCodeDuplicateRequest + ": invalid request sequence applied")
 	// ErrAlreadyExists defines error on re-creating existing database instance.
 	ErrAlreadyExists = errors.New("database instance already exists")
 	// ErrNotExists defines errors on manipulating a non-exists database instance.
-	ErrNotExists = errors.New("database instance not exists")
+	ErrNotExists = errors.New(types.ErrCodeDatabaseNotFound + ": database instance not exists")
 	// ErrInvalidDBConfig defines errors on received invalid db config from block producer.
 	ErrInvalidDBConfig = errors.New("invalid database configuration")
 	// ErrSpaceLimitExceeded defines errors on disk space exceeding limit.
-	ErrSpaceLimitExceeded = errors.New("space limit exceeded")
+	ErrSpaceLimitExceeded = errors.New(types.ErrCodeQuotaExceeded + ": space limit exceeded")
 	// ErrUnknownMuxRequest indicates that the a multiplexing request endpoint is not found.
 	ErrUnknownMuxRequest = errors.New("unknown multiplexing request")
 	// ErrPermissionDeny indicates that the requester has no permission to send read or write query.
-	ErrPermissionDeny = errors.New("permission deny")
+	ErrPermissionDeny = errors.New(types.ErrCodePermissionDenied + ": permission deny")
 	// ErrInvalidPermission indicates that the requester sends a unrecognized permission.
 	ErrInvalidPermission = errors.New("invalid permission")
 	// ErrInvalidTransactionType indicates that the transaction type is invalid.
 	ErrInvalidTransactionType = errors.New("invalid transaction type")
+	// ErrBackupInProgress indicates that another backup of the database is in progress.
+	ErrBackupInProgress = errors.New("backup in progress")
+	// ErrStandbyReadOnly indicates that a write query is sent to a standby database.
+	ErrStandbyReadOnly = errors.New("standby database is read-only")
+	// ErrStandbyMiner indicates that a query is sent to a warm standby miner of the database.
+	ErrStandbyMiner = errors.New("miner is a standby of the database")
+	// ErrShuttingDown indicates that a query is sent to a miner which is shutting down.
+	ErrShuttingDown = errors.New(types.ErrCodeShuttingDown + ": miner is shutting down")
+	// ErrInvalidAsOfHeight indicates that the historical state at the as-of height is unavailable.
+	ErrInvalidAsOfHeight = errors.New("invalid as-of height")
+	// ErrOverloaded indicates that a batch priority query is shed to keep the interactive latency.
+	ErrOverloaded = errors.New(types.ErrCodeOverloaded + ": batch query shed on overload")
+	// ErrExecutionTimeout indicates that a query is interrupted after its max execution time.
+	ErrExecutionTimeout = errors.New(types.ErrCodeExecutionTimeout + ": max execution time exceeded")
+	// ErrTooManyCursors indicates that the max count of open result cursors of a database is
+	// reached.
+	ErrTooManyCursors = errors.New("too many result cursors")
+	// ErrSQLiteBuildMismatch indicates that the SQLite build of a database peer diverges from the
+	// local one.
+	ErrSQLiteBuildMismatch = errors.New("sqlite build mismatch")
+	// ErrNoAvailableFollower indicates that no follower is available to take over the leadership.
+	ErrNoAvailableFollower = errors.New("no available follower")
+	// ErrNotLeader indicates that a request served by the leader only is sent to a follower.
+	ErrNotLeader = errors.New("not the leader of the database")
+	// ErrLeaderChanged indicates that a query is refused by a stale or deposed leader of the
+	// database.
+	ErrLeaderChanged = errors.New(types.ErrCodeLeaderChanged + ": leader of the database changed")
+	// ErrAttachNotLocal indicates that an attached database is not served by the same miner.
+	ErrAttachNotLocal = errors.New(types.ErrCodeAttachNotLocal + ": attached database is not local")
+	// ErrConnectionNotFound indicates that the client connection to kill is not tracked by the
+	// database.
+	ErrConnectionNotFound = errors.New("connection not found")
+	// ErrQueryKilled indicates that a query is interrupted by the KILL of its connection.
+	ErrQueryKilled = errors.New("query killed")
+	// ErrStalenessUnknown indicates that a stale read is sent to a follower which applies no log of
+	// the leader since it starts, the staleness of its state is unknown.
+	ErrStalenessUnknown = errors.New("staleness of the follower is unknown")
+	// ErrUnsupportedValue indicates a value scanned from the storage without a canonical encoding.
+	ErrUnsupportedValue = errors.New("unsupported value type")
 )
@@ -0,0 +1,160 @@
+package worker
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/types"
+)
+
+var (
+	// readOnlyStatements are the leading keywords of the statements a read-only account may run,
+	// SHOW and DESC are translated to the pragmas by the miner.
+	readOnlyStatements = map[string]bool{
+		"select":   true,
+		"with":     true,
+		"values":   true,
+		"explain":  true,
+		"show":     true,
+		"desc":     true,
+		"describe": true,
+		"pragma":   true,
+	}
+	// readOnlyPragmas are the pragmas reading the schema, the others may change the database or
+	// the connection.
+	readOnlyPragmas = map[string]bool{
+		"table_info":       true,
+		"table_xinfo":      true,
+		"table_list":       true,
+		"index_list":       true,
+		"index_info":       true,
+		"index_xinfo":      true,
+		"foreign_key_list": true,
+		"database_list":    true,
+		"collation_list":   true,
+		"function_list":    true,
+		"compile_options":  true,
+	}
+	// writeKeywords are the keywords of the writes which may follow the common table expressions.
+	writeKeywords = map[string]bool{
+		"insert":  true,
+		"update":  true,
+		"delete":  true,
+		"replace": true,
+	}
+)
+
+// checkReadOnlyQueries returns an error if any statement of queries may write the database, it's
+// checked for the read-only accounts before the query-only connections refuse the writes, so that
+// the writes are rejected explicitly instead of being ignored.
+func checkReadOnlyQueries(queries []types.Query) (err error) {
+	for _, q := range queries {
+		for _, stmt := range splitStatements(q.Pattern) {
+			if !isReadOnlyStatement(stmt) {
+				return errors.Wrapf(ErrPermissionDeny,
+					"read-only account cannot run write statement: %s", q.Pattern)
+			}
+		}
+	}
+	return
+}
+
+// isReadOnlyStatement returns whether the tokens of a single statement only read the database.
+func isReadOnlyStatement(tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	if !readOnlyStatements[tokens[0]] {
+		return false
+	}
+	if tokens[0] == "pragma" {
+		// PRAGMA [schema.]name[(arg)], a pragma with "=" sets the value
+		if len(tokens) > 2 && tokens[2] == "." {
+			tokens = tokens[2:]
+		}
+		if len(tokens) < 2 || !readOnlyPragmas[tokens[1]] {
+			return false
+		}
+		for _, t := range tokens[2:] {
+			if t == "=" {
+				return false
+			}
+		}
+		return true
+	}
+	for i, t := range tokens {
+		// replace is also a function
+		if writeKeywords[t] && !(t == "replace" && i+1 < len(tokens) && tokens[i+1] == "(") {
+			return false
+		}
+	}
+	return true
+}
+
+// splitStatements splits the statements of pattern into the lower case tokens, the comments and
+// the quoted strings and identifiers are skipped.
+func splitStatements(pattern string) (stmts [][]string) {
+	var (
+		tokens []string
+		n      = len(pattern)
+	)
+	for i := 0; i < n; {
+		c := pattern[i]
+		switch {
+		case c == '-' && i+1 < n && pattern[i+1] == '-':
+			for i < n && pattern[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && pattern[i+1] == '*':
+			if end := strings.Index(pattern[i+2:], "*/"); end >= 0 {
+				i += end + 4
+			} else {
+				i = n
+			}
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			for i++; i < n; i++ {
+				if pattern[i] == closing {
+					// doubled quotes escape the quote
+					if closing != ']' && i+1 < n && pattern[i+1] == closing {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			i++
+			tokens = append(tokens, "?")
+		case c == ';':
+			if len(tokens) > 0 {
+				stmts = append(stmts, tokens)
+			}
+			tokens = nil
+			i++
+		case isIdentChar(c):
+			start := i
+			for i < n && isIdentChar(pattern[i]) {
+				i++
+			}
+			tokens = append(tokens, strings.ToLower(pattern[start:i]))
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	if len(tokens) > 0 {
+		stmts = append(stmts, tokens)
+	}
+	return
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' ||
+		c >= 'A' && c <= 'Z' || c >= 0x80
+}
@@ -0,0 +1,56 @@
+package worker
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/types"
+)
+
+func TestCheckReadOnlyQueries(t *testing.T) {
+	Convey("Given the statements of a read-only account", t, func() {
+		var check = func(pattern string) error {
+			return checkReadOnlyQueries([]types.Query{{Pattern: pattern}})
+		}
+		Convey("The read statements should be allowed", func() {
+			for _, q := range []string{
+				"SELECT * FROM t WHERE v = 'delete'",
+				"select replace(v, 'a', 'b') from t; select 1;",
+				`WITH x AS (SELECT 1) SELECT * FROM x JOIN "update" u`,
+				"/* insert */ SELECT 1 -- drop table t",
+				"VALUES (1), (2)",
+				"EXPLAIN QUERY PLAN SELECT * FROM t",
+				"SHOW TABLES",
+				"DESC t",
+				"PRAGMA table_info(t)",
+				"PRAGMA main.index_list('t')",
+				"",
+			} {
+				So(check(q), ShouldBeNil)
+			}
+		})
+		Convey("The writes and the schema changes should be rejected", func() {
+			for _, q := range []string{
+				"INSERT INTO t VALUES (1)",
+				"insert into t values (1) returning id",
+				"REPLACE INTO t VALUES (1)",
+				"WITH x AS (SELECT 1) DELETE FROM t",
+				"WITH x AS (SELECT 1) UPDATE t SET v = 1",
+				"SELECT 1; DROP TABLE t",
+				"CREATE TABLE t2 (k INT)",
+				"ALTER TABLE t ADD COLUMN v2",
+				"ATTACH 'other.db' AS o",
+				"VACUUM",
+				"PRAGMA user_version = 2",
+				"PRAGMA cache_size(100)",
+				"PRAGMA table_info = 1",
+				"BEGIN",
+			} {
+				err := check(q)
+				So(errors.Cause(err), ShouldEqual, ErrPermissionDeny)
+			}
+		})
+	})
+}
@@ -0,0 +1,157 @@
+package testnet
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	yaml "gopkg.in/yaml.v2"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto"
+	"sqlit/src/crypto/asymmetric"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/crypto/kms"
+	mine "sqlit/src/pow/cpuminer"
+	"sqlit/src/proto"
+)
+
+const (
+	configFileName     = "config.yaml"
+	privateKeyFileName = "private.key"
+	pubKeyStoreName    = "public.keystore"
+	dhtFileName        = "dht.db"
+	chainFileName      = "chain.db"
+)
+
+// mineNonce finds the nonce of the public key with at least the difficulty from the start nonce.
+func mineNonce(pub *asymmetric.PublicKey, start mine.Uint256, difficulty int) (ni mine.NonceInfo) {
+	data := pub.Serialize()
+	for nonce := start; ; nonce.Inc() {
+		h := mine.HashBlock(data, nonce)
+		if d := h.Difficulty(); d >= difficulty {
+			return mine.NonceInfo{Nonce: nonce, Difficulty: d, Hash: h}
+		}
+	}
+}
+
+// newNode generates the identity of a node, nodes sharing a key must use distinct seeds.
+func newNode(
+	role proto.ServerRole, index int, dir string, key *asymmetric.PrivateKey, seed uint64, difficulty int,
+) (node *Node, err error) {
+	node = &Node{
+		Role:       role,
+		Index:      index,
+		Dir:        dir,
+		ConfigFile: filepath.Join(dir, configFileName),
+		PrivateKey: key,
+	}
+	if node.Account, err = crypto.PubKeyHash(key.PubKey()); err != nil {
+		err = errors.Wrap(err, "get account address failed")
+		return
+	}
+	ni := mineNonce(key.PubKey(), mine.Uint256{D: seed}, difficulty)
+	node.ID = proto.NodeID(ni.Hash.String())
+	node.Nonce = ni.Nonce
+	return
+}
+
+func (n *Node) proto() proto.Node {
+	return proto.Node{
+		ID:        n.ID,
+		Role:      n.Role,
+		Addr:      n.Addr,
+		PublicKey: n.PrivateKey.PubKey(),
+		Nonce:     n.Nonce,
+	}
+}
+
+// baseConfig returns the config shared by all the nodes of the network.
+func (nw *Network) baseConfig() (cfg *conf.Config) {
+	var (
+		leader   = nw.BPs[0]
+		accounts = make([]conf.BaseAccountInfo, 0, len(nw.Miners)+1)
+		known    = make([]proto.Node, 0, len(nw.BPs)+len(nw.Miners)+1)
+	)
+	for _, n := range nw.nodes() {
+		known = append(known, n.proto())
+		if n.Role == proto.Miner || n.Role == proto.Client {
+			accounts = append(accounts, conf.BaseAccountInfo{Address: hash.Hash(n.Account)})
+		}
+	}
+	cfg = &conf.Config{
+		UseTestMasterKey:    true,
+		InsecureDevMode:     true,
+		PubKeyStoreFile:     pubKeyStoreName,
+		PrivateKeyFile:      privateKeyFileName,
+		DHTFileName:         dhtFileName,
+		MinNodeIDDifficulty: nw.opts.Difficulty,
+		BP: &conf.BPInfo{
+			PublicKey:     leader.PrivateKey.PubKey(),
+			NodeID:        leader.ID,
+			Nonce:         leader.Nonce,
+			ChainFileName: chainFileName,
+			BPGenesis: conf.BPGenesisInfo{
+				Version:      1,
+				Timestamp:    nw.genesisTime,
+				BaseAccounts: accounts,
+			},
+		},
+		KnownNodes:         known,
+		QPS:                1000,
+		ChainBusPeriod:     time.Second,
+		BillingBlockCount:  60,
+		BPPeriod:           nw.opts.BPPeriod,
+		BPTick:             nw.opts.BPPeriod / 3,
+		SQLChainPeriod:     nw.opts.SQLChainPeriod,
+		SQLChainTick:       nw.opts.SQLChainPeriod / 3,
+		SQLChainTTL:        10,
+		MinProviderDeposit: 1000000,
+	}
+	return
+}
+
+// writeConfig saves the private key and the config of the node.
+func (nw *Network) writeConfig(n *Node) (err error) {
+	if err = os.MkdirAll(n.Dir, 0755); err != nil {
+		err = errors.Wrapf(err, "create node directory failed: %s", n.Dir)
+		return
+	}
+	if err = kms.SavePrivateKey(filepath.Join(n.Dir, privateKeyFileName), n.PrivateKey, nil); err != nil {
+		err = errors.Wrapf(err, "save private key failed: %s", n.Dir)
+		return
+	}
+
+	cfg := nw.baseConfig()
+	cfg.WorkingRoot = n.Dir
+	cfg.ListenAddr = n.Addr
+	cfg.ThisNodeID = n.ID
+	cfg.WalletAddress = n.Account.String()
+	if n.Role == proto.Miner {
+		cfg.Miner = &conf.MinerInfo{
+			RootDir:                filepath.Join(n.Dir, "data"),
+			MaxReqTimeGap:          5 * time.Minute,
+			ProvideServiceInterval: time.Second,
+		}
+	}
+	if nw.opts.Configure != nil {
+		nw.opts.Configure(n, cfg)
+	}
+
+	out, err := yaml.Marshal(cfg)
+	if err != nil {
+		err = errors.Wrapf(err, "encode config failed: %s", n.Dir)
+		return
+	}
+	if err = os.WriteFile(n.ConfigFile, out, 0644); err != nil {
+		err = errors.Wrapf(err, "write config failed: %s", n.ConfigFile)
+	}
+	return
+}
+
+func joinHostPort(host string, port int) string {
+	return net.JoinHostPort(host, fmt.Sprint(port))
+}
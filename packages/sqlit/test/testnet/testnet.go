@@ -0,0 +1,423 @@
+// Package testnet launches a local SQLIT network of block producers and miners for the
+// integration tests and the localnet command of sqlitd.
+//
+// Each node runs as a subprocess of the node binaries, such as the bin/sqlitd.test and
+// bin/sqlit-minerd.test built by make, with the keys, node IDs and configs generated in the working
+// directory of the network:
+//
+//	nw, err := testnet.New(testnet.Options{BPCount: 3, MinerCount: 3})
+//	...
+//	defer nw.Close()
+//	err = nw.Start(ctx)
+//	...
+//	err = client.Init(nw.Client.ConfigFile, nil)
+package testnet
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/asymmetric"
+	mine "sqlit/src/pow/cpuminer"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+	"sqlit/src/utils/log"
+)
+
+const (
+	minPort      = 20000
+	maxPort      = 40000
+	waitInterval = 100 * time.Millisecond
+)
+
+// Options defines the options of a local network, zero values use the defaults.
+type Options struct {
+	// BPCount is the count of block producers, default 1.
+	BPCount int
+	// MinerCount is the count of miners.
+	MinerCount int
+	// Dir is the working directory of the network, a temporary directory is created and removed on
+	// Close if empty.
+	Dir string
+	// Host is the listen host of the nodes, default 127.0.0.1.
+	Host string
+	// BPBinary and MinerBinary are the node binaries, default bin/sqlitd.test and
+	// bin/sqlit-minerd.test of the project.
+	BPBinary    string
+	MinerBinary string
+	// CoverDir saves the cover profiles of the nodes if the binaries are test binaries.
+	CoverDir string
+	// Difficulty is the minimum node ID difficulty, default 2.
+	Difficulty int
+	// BPPeriod and SQLChainPeriod are the block periods of the main chain and the sqlchains,
+	// default 3s and 1s.
+	BPPeriod       time.Duration
+	SQLChainPeriod time.Duration
+	// StartTimeout and StopTimeout limit the time to wait for the nodes, default 30s and 10s.
+	StartTimeout time.Duration
+	StopTimeout  time.Duration
+	// LogToStd copies the node outputs to the standard outputs in addition to the log files.
+	LogToStd bool
+	// Configure customizes the config of each node before it is saved.
+	Configure func(node *Node, cfg *conf.Config)
+}
+
+// Node defines a node of the local network.
+type Node struct {
+	Role       proto.ServerRole
+	Index      int
+	ID         proto.NodeID
+	Nonce      mine.Uint256
+	Addr       string
+	Dir        string
+	ConfigFile string
+	PrivateKey *asymmetric.PrivateKey
+	Account    proto.AccountAddress
+
+	cmd     *utils.CMD
+	exited  chan struct{}
+	exitErr error
+	starts  int
+}
+
+// Name returns the process name of the node.
+func (n *Node) Name() string {
+	switch n.Role {
+	case proto.Leader, proto.Follower:
+		return "bp" + strconv.Itoa(n.Index)
+	case proto.Miner:
+		return "miner" + strconv.Itoa(n.Index)
+	default:
+		return "client"
+	}
+}
+
+// LogPath returns the log file of the node process.
+func (n *Node) LogPath() string {
+	return filepath.Join(n.Dir, n.Name()+".log")
+}
+
+// Running returns whether the node process is started and not stopped.
+func (n *Node) Running() bool {
+	if n.cmd == nil {
+		return false
+	}
+	select {
+	case <-n.exited:
+		return false
+	default:
+		return true
+	}
+}
+
+// Network defines a local network of block producers and miners, and a client config to access
+// the network.
+type Network struct {
+	BPs    []*Node
+	Miners []*Node
+	Client *Node
+
+	opts        Options
+	dir         string
+	tempDir     bool
+	genesisTime time.Time
+}
+
+func (o *Options) setDefaults() {
+	if o.BPCount <= 0 {
+		o.BPCount = 1
+	}
+	if o.Host == "" {
+		o.Host = "127.0.0.1"
+	}
+	if o.BPBinary == "" {
+		o.BPBinary = filepath.Join(utils.GetProjectSrcDir(), "bin", "sqlitd.test")
+	}
+	if o.MinerBinary == "" {
+		o.MinerBinary = filepath.Join(utils.GetProjectSrcDir(), "bin", "sqlit-minerd.test")
+	}
+	if o.Difficulty <= 0 {
+		o.Difficulty = 2
+	}
+	if o.BPPeriod <= 0 {
+		o.BPPeriod = 3 * time.Second
+	}
+	if o.SQLChainPeriod <= 0 {
+		o.SQLChainPeriod = time.Second
+	}
+	if o.StartTimeout <= 0 {
+		o.StartTimeout = 30 * time.Second
+	}
+	if o.StopTimeout <= 0 {
+		o.StopTimeout = 10 * time.Second
+	}
+}
+
+// New generates the keys and the configs of a local network, the nodes are not started.
+func New(opts Options) (nw *Network, err error) {
+	opts.setDefaults()
+	nw = &Network{
+		opts:        opts,
+		dir:         opts.Dir,
+		genesisTime: time.Now().UTC().Truncate(time.Second),
+	}
+	if nw.dir == "" {
+		if nw.dir, err = os.MkdirTemp("", "sqlit-testnet"); err != nil {
+			err = errors.Wrap(err, "create network directory failed")
+			return
+		}
+		nw.tempDir = true
+	}
+	if nw.dir, err = filepath.Abs(nw.dir); err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil && nw.tempDir {
+			_ = os.RemoveAll(nw.dir)
+		}
+	}()
+
+	ports, err := utils.GetRandomPorts(opts.Host, minPort, maxPort, opts.BPCount+opts.MinerCount)
+	if err != nil {
+		err = errors.Wrap(err, "allocate node ports failed")
+		return
+	}
+
+	// the block producers share the key of the chain and have distinct nonces, like the testnet
+	bpKey, _, err := asymmetric.GenSecp256k1KeyPair()
+	if err != nil {
+		return
+	}
+	for i := 0; i < opts.BPCount; i++ {
+		role := proto.Follower
+		if i == 0 {
+			role = proto.Leader
+		}
+		var n *Node
+		if n, err = newNode(role, i, filepath.Join(nw.dir, "bp"+strconv.Itoa(i)),
+			bpKey, uint64(i), opts.Difficulty); err != nil {
+			return
+		}
+		n.Addr = joinHostPort(opts.Host, ports[i])
+		nw.BPs = append(nw.BPs, n)
+	}
+	for i := 0; i < opts.MinerCount; i++ {
+		var (
+			key *asymmetric.PrivateKey
+			n   *Node
+		)
+		if key, _, err = asymmetric.GenSecp256k1KeyPair(); err != nil {
+			return
+		}
+		if n, err = newNode(proto.Miner, i, filepath.Join(nw.dir, "miner"+strconv.Itoa(i)),
+			key, 0, opts.Difficulty); err != nil {
+			return
+		}
+		n.Addr = joinHostPort(opts.Host, ports[opts.BPCount+i])
+		nw.Miners = append(nw.Miners, n)
+	}
+	clientKey, _, err := asymmetric.GenSecp256k1KeyPair()
+	if err != nil {
+		return
+	}
+	if nw.Client, err = newNode(proto.Client, 0, filepath.Join(nw.dir, "client"),
+		clientKey, 0, opts.Difficulty); err != nil {
+		return
+	}
+	nw.Client.Addr = joinHostPort(opts.Host, 0)
+
+	for _, n := range nw.nodes() {
+		if err = nw.writeConfig(n); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Dir returns the working directory of the network.
+func (nw *Network) Dir() string {
+	return nw.dir
+}
+
+func (nw *Network) nodes() (nodes []*Node) {
+	nodes = make([]*Node, 0, len(nw.BPs)+len(nw.Miners)+1)
+	nodes = append(nodes, nw.BPs...)
+	nodes = append(nodes, nw.Miners...)
+	return append(nodes, nw.Client)
+}
+
+// Start starts the block producers, waits for them to listen, then starts the miners and waits
+// for them to listen.
+func (nw *Network) Start(ctx context.Context) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, nw.opts.StartTimeout)
+	defer cancel()
+
+	defer func() {
+		if err != nil {
+			_ = nw.Stop()
+		}
+	}()
+
+	if err = nw.startNodes(ctx, nw.BPs); err != nil {
+		return
+	}
+	return nw.startNodes(ctx, nw.Miners)
+}
+
+func (nw *Network) startNodes(ctx context.Context, nodes []*Node) (err error) {
+	for _, n := range nodes {
+		if err = nw.StartNode(n); err != nil {
+			return
+		}
+	}
+	for _, n := range nodes {
+		if err = n.Wait(ctx); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// StartNode starts the process of the node without waiting for it to listen, a stopped node can
+// be started again with its previous data.
+func (nw *Network) StartNode(n *Node) (err error) {
+	if n.cmd != nil {
+		return errors.Errorf("node %s is already started", n.Name())
+	}
+	var bin string
+	switch n.Role {
+	case proto.Leader, proto.Follower:
+		bin = nw.opts.BPBinary
+	case proto.Miner:
+		bin = nw.opts.MinerBinary
+	default:
+		return errors.Errorf("node %s is not a server", n.Name())
+	}
+	args := []string{"-config", n.ConfigFile}
+	if nw.opts.CoverDir != "" && strings.HasSuffix(bin, ".test") {
+		n.starts++
+		args = append(args, "-test.coverprofile",
+			filepath.Join(nw.opts.CoverDir, fmt.Sprintf("%s-%d.cover.out", n.Name(), n.starts)))
+	}
+
+	cmd := &utils.CMD{LogPath: n.LogPath()}
+	if cmd.LogFD, err = os.OpenFile(cmd.LogPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+		err = errors.Wrapf(err, "create log file failed: %s", cmd.LogPath)
+		return
+	}
+	cmd.Cmd = exec.Command(bin, args...)
+	cmd.Cmd.Dir = n.Dir
+	// the nodes use the test master key of the insecure dev mode
+	cmd.Cmd.Env = append(os.Environ(), conf.InsecureDevModeEnv+"=1")
+	if nw.opts.LogToStd {
+		cmd.Cmd.Stdout = io.MultiWriter(os.Stdout, cmd.LogFD)
+		cmd.Cmd.Stderr = io.MultiWriter(os.Stderr, cmd.LogFD)
+	} else {
+		cmd.Cmd.Stdout = cmd.LogFD
+		cmd.Cmd.Stderr = cmd.LogFD
+	}
+	if err = cmd.Cmd.Start(); err != nil {
+		_ = cmd.LogFD.Close()
+		err = errors.Wrapf(err, "start node %s failed", n.Name())
+		return
+	}
+	log.WithFields(log.Fields{
+		"node": n.ID,
+		"name": n.Name(),
+		"addr": n.Addr,
+		"pid":  cmd.Cmd.Process.Pid,
+	}).Info("started testnet node")
+
+	n.cmd, n.exited = cmd, make(chan struct{})
+	go func(exited chan struct{}) {
+		n.exitErr = cmd.Cmd.Wait()
+		_ = cmd.LogFD.Close()
+		close(exited)
+	}(n.exited)
+	return
+}
+
+// Wait waits until the started node accepts connections.
+func (n *Node) Wait(ctx context.Context) (err error) {
+	if n.cmd == nil {
+		return errors.Errorf("node %s is not started", n.Name())
+	}
+	ticker := time.NewTicker(waitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return errors.Wrapf(ctx.Err(), "wait node %s failed, see %s", n.Name(), n.LogPath())
+		case <-n.exited:
+			return errors.Errorf("node %s exited: %v, see %s", n.Name(), n.exitErr, n.LogPath())
+		case <-ticker.C:
+			if conn, err := net.DialTimeout("tcp", n.Addr, waitInterval); err == nil {
+				_ = conn.Close()
+				return nil
+			}
+		}
+	}
+}
+
+// StopNode terminates the process of the node, the process is killed if it doesn't exit in the
+// stop timeout.
+func (nw *Network) StopNode(n *Node) (err error) {
+	if n.cmd == nil {
+		return
+	}
+	cmd, exited := n.cmd, n.exited
+	n.cmd = nil
+
+	_ = cmd.Cmd.Process.Signal(syscall.SIGTERM)
+	select {
+	case <-exited:
+	case <-time.After(nw.opts.StopTimeout):
+		log.WithField("name", n.Name()).Warning("kill testnet node after stop timeout")
+		_ = cmd.Cmd.Process.Kill()
+		<-exited
+	}
+	err = n.exitErr
+	if exitErr, ok := err.(*exec.ExitError); ok && !exitErr.Exited() {
+		// terminated by the signal
+		err = nil
+	}
+	if err != nil {
+		err = errors.Wrapf(err, "stop node %s failed, see %s", n.Name(), n.LogPath())
+	}
+	return
+}
+
+// Stop stops the miners and then the block producers.
+func (nw *Network) Stop() (err error) {
+	nodes := append(append([]*Node{}, nw.Miners...), nw.BPs...)
+	for _, n := range nodes {
+		if stopErr := nw.StopNode(n); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	return
+}
+
+// Close stops the network and removes the working directory if it is created by New.
+func (nw *Network) Close() (err error) {
+	err = nw.Stop()
+	if nw.tempDir {
+		if rmErr := os.RemoveAll(nw.dir); rmErr != nil && err == nil {
+			err = rmErr
+		}
+	}
+	return
+}
@@ -0,0 +1,190 @@
+package testnet
+
+import (
+	"context"
+	"flag"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"sqlit/src/conf"
+	"sqlit/src/crypto/hash"
+	"sqlit/src/crypto/kms"
+	"sqlit/src/proto"
+	"sqlit/src/utils"
+)
+
+// fakeNodeEnv makes the test binary act as a node which listens on the address of its config
+// until it is terminated, or exits at once if the value is "exit".
+const fakeNodeEnv = "SQLIT_TESTNET_FAKE_NODE"
+
+func TestMain(m *testing.M) {
+	if mode := os.Getenv(fakeNodeEnv); mode != "" {
+		os.Exit(runFakeNode(mode))
+	}
+	os.Exit(m.Run())
+}
+
+func runFakeNode(mode string) int {
+	if mode == "exit" {
+		return 1
+	}
+	var configFile string
+	fs := flag.NewFlagSet("fake", flag.ContinueOnError)
+	fs.StringVar(&configFile, "config", "", "")
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return 2
+	}
+	cfg, err := conf.LoadConfig(configFile)
+	if err != nil {
+		return 2
+	}
+	ln, err := net.Listen("tcp", cfg.ListenAddr)
+	if err != nil {
+		return 2
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM)
+	<-sig
+	return 0
+}
+
+func TestNew(t *testing.T) {
+	Convey("Given a generated network of 3 BPs and 2 miners", t, func() {
+		nw, err := New(Options{BPCount: 3, MinerCount: 2})
+		So(err, ShouldBeNil)
+		Reset(func() {
+			_ = nw.Close()
+		})
+		So(nw.BPs, ShouldHaveLength, 3)
+		So(nw.Miners, ShouldHaveLength, 2)
+		So(nw.Client, ShouldNotBeNil)
+
+		Convey("The nodes should have valid and unique identities", func() {
+			ids := map[proto.NodeID]bool{}
+			for _, n := range nw.nodes() {
+				So(ids[n.ID], ShouldBeFalse)
+				ids[n.ID] = true
+
+				h, err := hash.NewHashFromStr(string(n.ID))
+				So(err, ShouldBeNil)
+				So(h.Difficulty(), ShouldBeGreaterThanOrEqualTo, 2)
+			}
+			So(nw.BPs[0].Role, ShouldEqual, proto.Leader)
+			So(nw.BPs[1].Role, ShouldEqual, proto.Follower)
+			So(nw.BPs[1].PrivateKey, ShouldEqual, nw.BPs[0].PrivateKey)
+			So(nw.Miners[0].Account, ShouldNotEqual, nw.Miners[1].Account)
+		})
+		Convey("The configs should be loadable by the nodes", func() {
+			for _, n := range nw.nodes() {
+				cfg, err := conf.LoadConfig(n.ConfigFile)
+				So(err, ShouldBeNil)
+				So(cfg.ThisNodeID, ShouldEqual, n.ID)
+				So(cfg.ListenAddr, ShouldEqual, n.Addr)
+				So(cfg.WorkingRoot, ShouldEqual, n.Dir)
+				So(cfg.BP.NodeID, ShouldEqual, nw.BPs[0].ID)
+				So(cfg.BP.BPGenesis.BaseAccounts, ShouldHaveLength, 3)
+				So(cfg.KnownNodes, ShouldHaveLength, 6)
+				So(cfg.Miner != nil, ShouldEqual, n.Role == proto.Miner)
+
+				key, err := kms.LoadPrivateKey(cfg.PrivateKeyFile, nil)
+				So(err, ShouldBeNil)
+				So(key.PubKey().IsEqual(n.PrivateKey.PubKey()), ShouldBeTrue)
+			}
+		})
+		Convey("The temporary directory should be removed on close", func() {
+			So(nw.Close(), ShouldBeNil)
+			_, err := os.Stat(nw.Dir())
+			So(os.IsNotExist(err), ShouldBeTrue)
+		})
+	})
+}
+
+func TestStartStop(t *testing.T) {
+	Convey("Given a network of fake nodes", t, func() {
+		self, err := os.Executable()
+		So(err, ShouldBeNil)
+		nw, err := New(Options{
+			BPCount:      2,
+			MinerCount:   1,
+			BPBinary:     self,
+			MinerBinary:  self,
+			StartTimeout: 10 * time.Second,
+			StopTimeout:  5 * time.Second,
+		})
+		So(err, ShouldBeNil)
+		Reset(func() {
+			_ = nw.Close()
+			_ = os.Unsetenv(fakeNodeEnv)
+		})
+
+		Convey("The nodes should be started, restarted and stopped", func() {
+			So(os.Setenv(fakeNodeEnv, "listen"), ShouldBeNil)
+			So(nw.Start(context.Background()), ShouldBeNil)
+			for _, n := range nw.nodes()[:3] {
+				So(n.Running(), ShouldBeTrue)
+			}
+
+			So(nw.StopNode(nw.Miners[0]), ShouldBeNil)
+			So(nw.Miners[0].Running(), ShouldBeFalse)
+			So(nw.StartNode(nw.Miners[0]), ShouldBeNil)
+			So(nw.Miners[0].Wait(context.Background()), ShouldBeNil)
+
+			So(nw.Stop(), ShouldBeNil)
+			for _, n := range nw.nodes() {
+				So(n.Running(), ShouldBeFalse)
+			}
+		})
+		Convey("The start should fail fast if a node exits", func() {
+			So(os.Setenv(fakeNodeEnv, "exit"), ShouldBeNil)
+			begin := time.Now()
+			err := nw.Start(context.Background())
+			So(err, ShouldNotBeNil)
+			So(time.Since(begin), ShouldBeLessThan, 5*time.Second)
+			So(nw.BPs[0].Running(), ShouldBeFalse)
+		})
+		Convey("The client should not be started", func() {
+			So(nw.StartNode(nw.Client), ShouldNotBeNil)
+		})
+	})
+}
+
+func TestNetwork(t *testing.T) {
+	bin := filepath.Join(utils.GetProjectSrcDir(), "bin")
+	for _, name := range []string{"sqlitd.test", "sqlit-minerd.test"} {
+		if _, err := os.Stat(filepath.Join(bin, name)); err != nil {
+			t.Skipf("%s is not built, run make bp miner first", name)
+		}
+	}
+	if testing.Short() {
+		t.Skip("skip the local network in short mode")
+	}
+	Convey("Given a local network of 3 BPs and 2 miners", t, func() {
+		nw, err := New(Options{BPCount: 3, MinerCount: 2, StartTimeout: time.Minute})
+		So(err, ShouldBeNil)
+		Reset(func() {
+			_ = nw.Close()
+		})
+		So(nw.Start(context.Background()), ShouldBeNil)
+		for _, n := range nw.nodes()[:5] {
+			So(n.Running(), ShouldBeTrue)
+		}
+		So(nw.Stop(), ShouldBeNil)
+	})
+}